@@ -0,0 +1,791 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: usercrud/v1/coupon.proto
+
+package usercrudv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Coupon struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Total         int32                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Stock         int32                  `protobuf:"varint,4,opt,name=stock,proto3" json:"stock,omitempty"`
+	Amount        float64                `protobuf:"fixed64,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Coupon) Reset() {
+	*x = Coupon{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Coupon) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coupon) ProtoMessage() {}
+
+func (x *Coupon) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coupon.ProtoReflect.Descriptor instead.
+func (*Coupon) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Coupon) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Coupon) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Coupon) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Coupon) GetStock() int32 {
+	if x != nil {
+		return x.Stock
+	}
+	return 0
+}
+
+func (x *Coupon) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Coupon) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *Coupon) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *Coupon) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type CreateCouponRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Total         int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	StartTime     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime       *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCouponRequest) Reset() {
+	*x = CreateCouponRequest{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCouponRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCouponRequest) ProtoMessage() {}
+
+func (x *CreateCouponRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCouponRequest.ProtoReflect.Descriptor instead.
+func (*CreateCouponRequest) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateCouponRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateCouponRequest) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *CreateCouponRequest) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *CreateCouponRequest) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *CreateCouponRequest) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+type ClaimCouponRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	CouponId string                 `protobuf:"bytes,1,opt,name=coupon_id,json=couponId,proto3" json:"coupon_id,omitempty"`
+	// user_id 仅 API Key 调用方可指定，JWT 调用方只能为本人领取
+	UserId        string `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClaimCouponRequest) Reset() {
+	*x = ClaimCouponRequest{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClaimCouponRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimCouponRequest) ProtoMessage() {}
+
+func (x *ClaimCouponRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimCouponRequest.ProtoReflect.Descriptor instead.
+func (*ClaimCouponRequest) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ClaimCouponRequest) GetCouponId() string {
+	if x != nil {
+		return x.CouponId
+	}
+	return ""
+}
+
+func (x *ClaimCouponRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type ClaimCouponResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Claimed       bool                   `protobuf:"varint,1,opt,name=claimed,proto3" json:"claimed,omitempty"`
+	Ticket        *QueueTicket           `protobuf:"bytes,2,opt,name=ticket,proto3" json:"ticket,omitempty"` // 进入排队时返回
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClaimCouponResponse) Reset() {
+	*x = ClaimCouponResponse{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClaimCouponResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClaimCouponResponse) ProtoMessage() {}
+
+func (x *ClaimCouponResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClaimCouponResponse.ProtoReflect.Descriptor instead.
+func (*ClaimCouponResponse) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ClaimCouponResponse) GetClaimed() bool {
+	if x != nil {
+		return x.Claimed
+	}
+	return false
+}
+
+func (x *ClaimCouponResponse) GetTicket() *QueueTicket {
+	if x != nil {
+		return x.Ticket
+	}
+	return nil
+}
+
+type GetQueueStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CouponId      string                 `protobuf:"bytes,1,opt,name=coupon_id,json=couponId,proto3" json:"coupon_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // 同 ClaimCouponRequest.user_id
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetQueueStatusRequest) Reset() {
+	*x = GetQueueStatusRequest{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetQueueStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetQueueStatusRequest) ProtoMessage() {}
+
+func (x *GetQueueStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetQueueStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetQueueStatusRequest) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetQueueStatusRequest) GetCouponId() string {
+	if x != nil {
+		return x.CouponId
+	}
+	return ""
+}
+
+func (x *GetQueueStatusRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type QueueTicket struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	CouponId          string                 `protobuf:"bytes,1,opt,name=coupon_id,json=couponId,proto3" json:"coupon_id,omitempty"`
+	Queued            bool                   `protobuf:"varint,2,opt,name=queued,proto3" json:"queued,omitempty"`
+	Position          int64                  `protobuf:"varint,3,opt,name=position,proto3" json:"position,omitempty"` // 从 1 开始，放行后为 0
+	Admitted          bool                   `protobuf:"varint,4,opt,name=admitted,proto3" json:"admitted,omitempty"`
+	SoldOut           bool                   `protobuf:"varint,5,opt,name=sold_out,json=soldOut,proto3" json:"sold_out,omitempty"`
+	RetryAfterSeconds int32                  `protobuf:"varint,6,opt,name=retry_after_seconds,json=retryAfterSeconds,proto3" json:"retry_after_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *QueueTicket) Reset() {
+	*x = QueueTicket{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueueTicket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueueTicket) ProtoMessage() {}
+
+func (x *QueueTicket) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueueTicket.ProtoReflect.Descriptor instead.
+func (*QueueTicket) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *QueueTicket) GetCouponId() string {
+	if x != nil {
+		return x.CouponId
+	}
+	return ""
+}
+
+func (x *QueueTicket) GetQueued() bool {
+	if x != nil {
+		return x.Queued
+	}
+	return false
+}
+
+func (x *QueueTicket) GetPosition() int64 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *QueueTicket) GetAdmitted() bool {
+	if x != nil {
+		return x.Admitted
+	}
+	return false
+}
+
+func (x *QueueTicket) GetSoldOut() bool {
+	if x != nil {
+		return x.SoldOut
+	}
+	return false
+}
+
+func (x *QueueTicket) GetRetryAfterSeconds() int32 {
+	if x != nil {
+		return x.RetryAfterSeconds
+	}
+	return 0
+}
+
+type SendCouponRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	CouponId      string                 `protobuf:"bytes,2,opt,name=coupon_id,json=couponId,proto3" json:"coupon_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendCouponRequest) Reset() {
+	*x = SendCouponRequest{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendCouponRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendCouponRequest) ProtoMessage() {}
+
+func (x *SendCouponRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendCouponRequest.ProtoReflect.Descriptor instead.
+func (*SendCouponRequest) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SendCouponRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *SendCouponRequest) GetCouponId() string {
+	if x != nil {
+		return x.CouponId
+	}
+	return ""
+}
+
+type SendCouponResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendCouponResponse) Reset() {
+	*x = SendCouponResponse{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendCouponResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendCouponResponse) ProtoMessage() {}
+
+func (x *SendCouponResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendCouponResponse.ProtoReflect.Descriptor instead.
+func (*SendCouponResponse) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{7}
+}
+
+type ReconcileClaimsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CouponId      string                 `protobuf:"bytes,1,opt,name=coupon_id,json=couponId,proto3" json:"coupon_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileClaimsRequest) Reset() {
+	*x = ReconcileClaimsRequest{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileClaimsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileClaimsRequest) ProtoMessage() {}
+
+func (x *ReconcileClaimsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileClaimsRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileClaimsRequest) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ReconcileClaimsRequest) GetCouponId() string {
+	if x != nil {
+		return x.CouponId
+	}
+	return ""
+}
+
+type ReconcileClaimsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CouponId      string                 `protobuf:"bytes,1,opt,name=coupon_id,json=couponId,proto3" json:"coupon_id,omitempty"`
+	Restored      int32                  `protobuf:"varint,2,opt,name=restored,proto3" json:"restored,omitempty"`
+	Compensated   int32                  `protobuf:"varint,3,opt,name=compensated,proto3" json:"compensated,omitempty"`
+	Confirmed     int32                  `protobuf:"varint,4,opt,name=confirmed,proto3" json:"confirmed,omitempty"`
+	StockBefore   int32                  `protobuf:"varint,5,opt,name=stock_before,json=stockBefore,proto3" json:"stock_before,omitempty"`
+	StockAfter    int32                  `protobuf:"varint,6,opt,name=stock_after,json=stockAfter,proto3" json:"stock_after,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileClaimsResponse) Reset() {
+	*x = ReconcileClaimsResponse{}
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileClaimsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileClaimsResponse) ProtoMessage() {}
+
+func (x *ReconcileClaimsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_usercrud_v1_coupon_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileClaimsResponse.ProtoReflect.Descriptor instead.
+func (*ReconcileClaimsResponse) Descriptor() ([]byte, []int) {
+	return file_usercrud_v1_coupon_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReconcileClaimsResponse) GetCouponId() string {
+	if x != nil {
+		return x.CouponId
+	}
+	return ""
+}
+
+func (x *ReconcileClaimsResponse) GetRestored() int32 {
+	if x != nil {
+		return x.Restored
+	}
+	return 0
+}
+
+func (x *ReconcileClaimsResponse) GetCompensated() int32 {
+	if x != nil {
+		return x.Compensated
+	}
+	return 0
+}
+
+func (x *ReconcileClaimsResponse) GetConfirmed() int32 {
+	if x != nil {
+		return x.Confirmed
+	}
+	return 0
+}
+
+func (x *ReconcileClaimsResponse) GetStockBefore() int32 {
+	if x != nil {
+		return x.StockBefore
+	}
+	return 0
+}
+
+func (x *ReconcileClaimsResponse) GetStockAfter() int32 {
+	if x != nil {
+		return x.StockAfter
+	}
+	return 0
+}
+
+var File_usercrud_v1_coupon_proto protoreflect.FileDescriptor
+
+const file_usercrud_v1_coupon_proto_rawDesc = "" +
+	"\n" +
+	"\x18usercrud/v1/coupon.proto\x12\vusercrud.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x9d\x02\n" +
+	"\x06Coupon\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\x12\x14\n" +
+	"\x05stock\x18\x04 \x01(\x05R\x05stock\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\x01R\x06amount\x129\n" +
+	"\n" +
+	"start_time\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xc9\x01\n" +
+	"\x13CreateCouponRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x129\n" +
+	"\n" +
+	"start_time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\"J\n" +
+	"\x12ClaimCouponRequest\x12\x1b\n" +
+	"\tcoupon_id\x18\x01 \x01(\tR\bcouponId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\"a\n" +
+	"\x13ClaimCouponResponse\x12\x18\n" +
+	"\aclaimed\x18\x01 \x01(\bR\aclaimed\x120\n" +
+	"\x06ticket\x18\x02 \x01(\v2\x18.usercrud.v1.QueueTicketR\x06ticket\"M\n" +
+	"\x15GetQueueStatusRequest\x12\x1b\n" +
+	"\tcoupon_id\x18\x01 \x01(\tR\bcouponId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\"\xc5\x01\n" +
+	"\vQueueTicket\x12\x1b\n" +
+	"\tcoupon_id\x18\x01 \x01(\tR\bcouponId\x12\x16\n" +
+	"\x06queued\x18\x02 \x01(\bR\x06queued\x12\x1a\n" +
+	"\bposition\x18\x03 \x01(\x03R\bposition\x12\x1a\n" +
+	"\badmitted\x18\x04 \x01(\bR\badmitted\x12\x19\n" +
+	"\bsold_out\x18\x05 \x01(\bR\asoldOut\x12.\n" +
+	"\x13retry_after_seconds\x18\x06 \x01(\x05R\x11retryAfterSeconds\"I\n" +
+	"\x11SendCouponRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tcoupon_id\x18\x02 \x01(\tR\bcouponId\"\x14\n" +
+	"\x12SendCouponResponse\"5\n" +
+	"\x16ReconcileClaimsRequest\x12\x1b\n" +
+	"\tcoupon_id\x18\x01 \x01(\tR\bcouponId\"\xd6\x01\n" +
+	"\x17ReconcileClaimsResponse\x12\x1b\n" +
+	"\tcoupon_id\x18\x01 \x01(\tR\bcouponId\x12\x1a\n" +
+	"\brestored\x18\x02 \x01(\x05R\brestored\x12 \n" +
+	"\vcompensated\x18\x03 \x01(\x05R\vcompensated\x12\x1c\n" +
+	"\tconfirmed\x18\x04 \x01(\x05R\tconfirmed\x12!\n" +
+	"\fstock_before\x18\x05 \x01(\x05R\vstockBefore\x12\x1f\n" +
+	"\vstock_after\x18\x06 \x01(\x05R\n" +
+	"stockAfter2\xa5\x03\n" +
+	"\rCouponService\x12E\n" +
+	"\fCreateCoupon\x12 .usercrud.v1.CreateCouponRequest\x1a\x13.usercrud.v1.Coupon\x12P\n" +
+	"\vClaimCoupon\x12\x1f.usercrud.v1.ClaimCouponRequest\x1a .usercrud.v1.ClaimCouponResponse\x12N\n" +
+	"\x0eGetQueueStatus\x12\".usercrud.v1.GetQueueStatusRequest\x1a\x18.usercrud.v1.QueueTicket\x12M\n" +
+	"\n" +
+	"SendCoupon\x12\x1e.usercrud.v1.SendCouponRequest\x1a\x1f.usercrud.v1.SendCouponResponse\x12\\\n" +
+	"\x0fReconcileClaims\x12#.usercrud.v1.ReconcileClaimsRequest\x1a$.usercrud.v1.ReconcileClaimsResponseB.Z,user_crud_jwt/api/gen/usercrud/v1;usercrudv1b\x06proto3"
+
+var (
+	file_usercrud_v1_coupon_proto_rawDescOnce sync.Once
+	file_usercrud_v1_coupon_proto_rawDescData []byte
+)
+
+func file_usercrud_v1_coupon_proto_rawDescGZIP() []byte {
+	file_usercrud_v1_coupon_proto_rawDescOnce.Do(func() {
+		file_usercrud_v1_coupon_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_usercrud_v1_coupon_proto_rawDesc), len(file_usercrud_v1_coupon_proto_rawDesc)))
+	})
+	return file_usercrud_v1_coupon_proto_rawDescData
+}
+
+var file_usercrud_v1_coupon_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_usercrud_v1_coupon_proto_goTypes = []any{
+	(*Coupon)(nil),                  // 0: usercrud.v1.Coupon
+	(*CreateCouponRequest)(nil),     // 1: usercrud.v1.CreateCouponRequest
+	(*ClaimCouponRequest)(nil),      // 2: usercrud.v1.ClaimCouponRequest
+	(*ClaimCouponResponse)(nil),     // 3: usercrud.v1.ClaimCouponResponse
+	(*GetQueueStatusRequest)(nil),   // 4: usercrud.v1.GetQueueStatusRequest
+	(*QueueTicket)(nil),             // 5: usercrud.v1.QueueTicket
+	(*SendCouponRequest)(nil),       // 6: usercrud.v1.SendCouponRequest
+	(*SendCouponResponse)(nil),      // 7: usercrud.v1.SendCouponResponse
+	(*ReconcileClaimsRequest)(nil),  // 8: usercrud.v1.ReconcileClaimsRequest
+	(*ReconcileClaimsResponse)(nil), // 9: usercrud.v1.ReconcileClaimsResponse
+	(*timestamppb.Timestamp)(nil),   // 10: google.protobuf.Timestamp
+}
+var file_usercrud_v1_coupon_proto_depIdxs = []int32{
+	10, // 0: usercrud.v1.Coupon.start_time:type_name -> google.protobuf.Timestamp
+	10, // 1: usercrud.v1.Coupon.end_time:type_name -> google.protobuf.Timestamp
+	10, // 2: usercrud.v1.Coupon.created_at:type_name -> google.protobuf.Timestamp
+	10, // 3: usercrud.v1.CreateCouponRequest.start_time:type_name -> google.protobuf.Timestamp
+	10, // 4: usercrud.v1.CreateCouponRequest.end_time:type_name -> google.protobuf.Timestamp
+	5,  // 5: usercrud.v1.ClaimCouponResponse.ticket:type_name -> usercrud.v1.QueueTicket
+	1,  // 6: usercrud.v1.CouponService.CreateCoupon:input_type -> usercrud.v1.CreateCouponRequest
+	2,  // 7: usercrud.v1.CouponService.ClaimCoupon:input_type -> usercrud.v1.ClaimCouponRequest
+	4,  // 8: usercrud.v1.CouponService.GetQueueStatus:input_type -> usercrud.v1.GetQueueStatusRequest
+	6,  // 9: usercrud.v1.CouponService.SendCoupon:input_type -> usercrud.v1.SendCouponRequest
+	8,  // 10: usercrud.v1.CouponService.ReconcileClaims:input_type -> usercrud.v1.ReconcileClaimsRequest
+	0,  // 11: usercrud.v1.CouponService.CreateCoupon:output_type -> usercrud.v1.Coupon
+	3,  // 12: usercrud.v1.CouponService.ClaimCoupon:output_type -> usercrud.v1.ClaimCouponResponse
+	5,  // 13: usercrud.v1.CouponService.GetQueueStatus:output_type -> usercrud.v1.QueueTicket
+	7,  // 14: usercrud.v1.CouponService.SendCoupon:output_type -> usercrud.v1.SendCouponResponse
+	9,  // 15: usercrud.v1.CouponService.ReconcileClaims:output_type -> usercrud.v1.ReconcileClaimsResponse
+	11, // [11:16] is the sub-list for method output_type
+	6,  // [6:11] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_usercrud_v1_coupon_proto_init() }
+func file_usercrud_v1_coupon_proto_init() {
+	if File_usercrud_v1_coupon_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_usercrud_v1_coupon_proto_rawDesc), len(file_usercrud_v1_coupon_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_usercrud_v1_coupon_proto_goTypes,
+		DependencyIndexes: file_usercrud_v1_coupon_proto_depIdxs,
+		MessageInfos:      file_usercrud_v1_coupon_proto_msgTypes,
+	}.Build()
+	File_usercrud_v1_coupon_proto = out.File
+	file_usercrud_v1_coupon_proto_goTypes = nil
+	file_usercrud_v1_coupon_proto_depIdxs = nil
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+)
+
+func runInspect(ctx context.Context, a *app, args []string) error {
+	entry, _, err := a.getEntry(ctx, args)
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		// 只输出元数据，值使用 get 查看
+		entry.Value = nil
+		return a.printJSON(entry)
+	}
+	w := a.table("KEY", "SIZE", "TTL")
+	fmt.Fprintf(w, "%s\t%d B\t%s\n", entry.Key, entry.Size, formatTTL(entry.TTLSeconds))
+	return w.Flush()
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	entry, body, err := a.getEntry(ctx, args)
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.printRaw(body)
+	}
+	return a.printRaw(entry.Value)
+}
+
+func runSet(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("set", flag.ContinueOnError)
+	ttl := flags.Duration("ttl", 0, "Expiration, 0 uses the server default")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return usageError("set requires KEY and VALUE")
+	}
+	if *ttl < 0 || *ttl%time.Second != 0 {
+		return usageError("-ttl must be a positive whole number of seconds")
+	}
+	key := flags.Arg(0)
+	value := json.RawMessage(flags.Arg(1))
+	if !json.Valid(value) {
+		value, _ = json.Marshal(flags.Arg(1))
+	}
+
+	req := cache.SetEntryRequest{Key: key, Value: value, TTLSeconds: int(ttl.Seconds())}
+	if _, err := a.client.do(ctx, http.MethodPut, "/entries", nil, req); err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.printJSON(map[string]interface{}{"key": key, "ttl_seconds": req.TTLSeconds, "status": "set"})
+	}
+	fmt.Fprintf(a.stdout, "Set %s (%d B)\n", key, len(value))
+	return nil
+}
+
+func runDelete(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return usageError("delete requires at least one KEY")
+	}
+	for _, key := range args {
+		if _, err := a.client.do(ctx, http.MethodDelete, "/entries", url.Values{"key": {key}}, nil); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		if a.output == "json" {
+			if err := a.printJSON(map[string]string{"key": key, "status": "deleted"}); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(a.stdout, "Deleted %s\n", key)
+	}
+	return nil
+}
+
+func runWarmup(ctx context.Context, a *app, args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		body, err := a.client.do(ctx, http.MethodGet, "/warmups", nil, nil)
+		if err != nil {
+			return err
+		}
+		if a.output == "json" {
+			return a.printRaw(body)
+		}
+		var resp struct {
+			Warmups []cache.WarmupInfo `json:"warmups"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		w := a.table("NAME", "STATUS", "LAST RUN", "KEYS", "SUCCESS", "FAILED", "DESCRIPTION")
+		for _, task := range resp.Warmups {
+			status, lastRun, keys, success, failed := "idle", "-", "-", "-", "-"
+			if task.Running {
+				status = "running"
+			} else if task.LastError != "" {
+				status = "failed: " + task.LastError
+			}
+			if task.LastRun != nil {
+				lastRun = task.LastRun.EndTime.Local().Format(time.DateTime)
+				keys = strconv.Itoa(task.LastRun.TotalKeys)
+				success = strconv.Itoa(task.LastRun.SuccessKeys)
+				failed = strconv.Itoa(task.LastRun.FailedKeys)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", task.Name, status, lastRun, keys, success, failed, task.Description)
+		}
+		return w.Flush()
+
+	case len(args) == 2 && args[0] == "run":
+		body, err := a.client.do(ctx, http.MethodPost, "/warmups/"+url.PathEscape(args[1]), nil, nil)
+		if err != nil {
+			return err
+		}
+		if a.output == "json" {
+			return a.printRaw(body)
+		}
+		var result cache.WarmupResult
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		w := a.table("NAME", "KEYS", "SUCCESS", "FAILED", "DURATION")
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", result.Strategy, result.TotalKeys, result.SuccessKeys, result.FailedKeys, result.Duration.Round(time.Millisecond))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		for _, msg := range result.Errors {
+			fmt.Fprintf(a.stdout, "  error: %s\n", msg)
+		}
+		return nil
+	}
+	return usageError("expected warmup list or warmup run NAME")
+}
+
+func runStats(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	callers := flags.Bool("callers", false, "Break hit rate down by caller (HTTP route or gRPC method)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("stats takes no arguments")
+	}
+	body, err := a.client.do(ctx, http.MethodGet, "/stats", nil, nil)
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.printRaw(body)
+	}
+	var resp struct {
+		Caches  []metrics.CacheHitStat    `json:"caches"`
+		Callers []metrics.CacheCallerStat `json:"callers"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if *callers {
+		w := a.table("CACHE", "CALLER", "HITS", "MISSES", "ERRORS", "HIT RATE", "AVG LATENCY")
+		for _, stat := range resp.Callers {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.1f%%\t%.2fms\n", stat.CacheType, stat.Caller, stat.Hits, stat.Misses, stat.Errors, stat.HitRate*100, stat.AvgLatencyMs)
+		}
+		return w.Flush()
+	}
+	w := a.table("CACHE", "PREFIX", "HITS", "MISSES", "HIT RATE")
+	var hits, misses uint64
+	for _, stat := range resp.Caches {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f%%\n", stat.CacheType, stat.KeyPrefix, stat.Hits, stat.Misses, stat.HitRate*100)
+		hits += stat.Hits
+		misses += stat.Misses
+	}
+	if total := hits + misses; total > 0 {
+		fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%.1f%%\n", hits, misses, float64(hits)/float64(total)*100)
+	}
+	return w.Flush()
+}
+
+func runInvalidate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("invalidate", flag.ContinueOnError)
+	var tags stringList
+	flags.Var(&tags, "tag", "Tag to invalidate, repeatable")
+	namespace := flags.String("namespace", "", "Delete all keys starting with PREFIX:")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 || (len(tags) == 0 && *namespace == "") {
+		return usageError("invalidate requires -tag or -namespace")
+	}
+
+	body, err := a.client.do(ctx, http.MethodPost, "/invalidate", nil, cache.InvalidateRequest{Tags: tags, Namespace: *namespace})
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.printRaw(body)
+	}
+	var resp cache.InvalidateResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(resp.Tags) > 0 {
+		fmt.Fprintf(a.stdout, "Invalidated tags: %s\n", strings.Join(resp.Tags, ", "))
+	}
+	if ns := resp.Namespace; ns != nil {
+		if ns.Deleted >= 0 {
+			fmt.Fprintf(a.stdout, "Deleted %d keys matching %s\n", ns.Deleted, ns.Pattern)
+		} else {
+			fmt.Fprintf(a.stdout, "Deleted keys matching %s\n", ns.Pattern)
+		}
+	}
+	return nil
+}
+
+func runEvents(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ContinueOnError)
+	follow := flags.Bool("f", false, "Keep streaming new events until interrupted")
+	after := flags.Uint64("after", 0, "Only show events after this id")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("events takes no positional arguments")
+	}
+	query := url.Values{"after": {strconv.FormatUint(*after, 10)}}
+
+	if !*follow {
+		body, err := a.client.do(ctx, http.MethodGet, "/events", query, nil)
+		if err != nil {
+			return err
+		}
+		if a.output == "json" {
+			return a.printRaw(body)
+		}
+		var resp struct {
+			Events []cache.AdminEvent `json:"events"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		w := a.table(eventColumns...)
+		for _, event := range resp.Events {
+			fmt.Fprint(w, formatEvent(event))
+		}
+		return w.Flush()
+	}
+
+	// 持续输出时逐行写出，json 模式每行一个事件
+	query.Set("follow", "true")
+	if a.output == "table" {
+		fmt.Fprint(a.stdout, formatEventLine(eventColumns...))
+	}
+	return a.client.stream(ctx, "/events", query, func(data []byte) error {
+		if a.output == "json" {
+			_, err := fmt.Fprintf(a.stdout, "%s\n", data)
+			return err
+		}
+		var event cache.AdminEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to decode event: %w", err)
+		}
+		_, err := fmt.Fprint(a.stdout, formatEventLine(strings.Split(strings.TrimSuffix(formatEvent(event), "\n"), "\t")...))
+		return err
+	})
+}
+
+var eventColumns = []string{"ID", "TIME", "TYPE", "TARGET", "ACTOR", "DETAIL"}
+
+func formatEvent(event cache.AdminEvent) string {
+	actor := event.Actor
+	if actor == "" {
+		actor = "-"
+	}
+	return fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t%s\n", event.ID, event.Time.Local().Format(time.DateTime), event.Type, event.Target, actor, event.Detail)
+}
+
+// formatEventLine 持续输出时无法预先对齐，按固定列宽输出
+func formatEventLine(columns ...string) string {
+	return fmt.Sprintf("%-6s  %-19s  %-20s  %-32s  %-16s  %s\n", columns[0], columns[1], columns[2], columns[3], columns[4], columns[5])
+}
+
+// getEntry 读取 KEY 对应的条目，同时返回原始响应
+func (a *app) getEntry(ctx context.Context, args []string) (*cache.EntryInfo, []byte, error) {
+	if len(args) != 1 {
+		return nil, nil, usageError("expected exactly one KEY")
+	}
+	body, err := a.client.do(ctx, http.MethodGet, "/entries", url.Values{"key": {args[0]}}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entry cache.EntryInfo
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &entry, body, nil
+}
+
+// table 以制表符对齐的表格输出，调用方写完行后 Flush
+func (a *app) table(columns ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	return w
+}
+
+// printRaw 缩进输出 JSON 响应
+func (a *app) printRaw(data []byte) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return fmt.Errorf("failed to format response: %w", err)
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(a.stdout)
+	return err
+}
+
+func (a *app) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func formatTTL(seconds float64) string {
+	if seconds <= 0 {
+		return "no expiry"
+	}
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+// parseFlags 解析子命令选项，错误作为参数错误返回
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	return nil
+}
+
+// stringList 可重复指定的选项
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,140 @@
+// cachectl 缓存运维命令行，通过 /admin/cache 接口查看、写入与删除键，执行预热任务，
+// 查看命中率，按标签或命名空间失效，以及跟踪缓存变更事件。
+//
+// 服务端需配置 admin.api_keys（role 为 1），调用时以 -api-key 或 CACHECTL_API_KEY 传入：
+//
+//	cachectl -addr http://localhost:8080 inspect user:mobile:13800000000
+//	cachectl invalidate -tag users -namespace graphql:coupon
+//	cachectl -o json events -f
+//
+// 批量失效需要另一名管理员批准：首次调用创建审批单并输出其 ID，批准后以 -approval 重新执行相同的命令：
+//
+//	cachectl -approval 17 invalidate -tag users -namespace graphql:coupon
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// command 子命令
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"inspect":    {"inspect KEY", "Show size and remaining TTL of a key", runInspect},
+	"get":        {"get KEY", "Print the JSON value of a key", runGet},
+	"set":        {"set [-ttl 10m] KEY VALUE", "Write a JSON value (non-JSON is stored as a string)", runSet},
+	"delete":     {"delete KEY...", "Delete keys", runDelete},
+	"warmup":     {"warmup list | warmup run NAME", "List or run registered warmup tasks", runWarmup},
+	"stats":      {"stats [-callers]", "Hit rate per cache type and key prefix, or per caller", runStats},
+	"invalidate": {"invalidate [-tag TAG]... [-namespace PREFIX]", "Invalidate by tag version or delete a key namespace", runInvalidate},
+	"events":     {"events [-f] [-after ID]", "Show recent cache events, -f keeps streaming new ones", runEvents},
+}
+
+// commandOrder 帮助信息中子命令的顺序
+var commandOrder = []string{"inspect", "get", "set", "delete", "warmup", "stats", "invalidate", "events"}
+
+// app 全局选项与输出
+type app struct {
+	client *client
+	output string
+	stdout io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 解析参数并执行子命令，返回进程退出码：0 成功，1 调用失败，2 参数错误
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cachectl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		addr     = flags.String("addr", envOr("CACHECTL_ADDR", "http://localhost:8080"), "API base URL (env CACHECTL_ADDR)")
+		apiKey   = flags.String("api-key", os.Getenv("CACHECTL_API_KEY"), "Admin API key (env CACHECTL_API_KEY)")
+		tenant   = flags.String("tenant", os.Getenv("CACHECTL_TENANT"), "Tenant sent as X-Tenant-ID (env CACHECTL_TENANT)")
+		approval = flags.String("approval", "", "Approved approval ID to execute a change that requires two-person approval")
+		output   = flags.String("o", "table", "Output format: table or json")
+		timeout  = flags.Duration("timeout", 30*time.Second, "Request timeout, not applied to events -f")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: cachectl [flags] <command> [args]")
+		fmt.Fprintln(stderr, "\nCommands:")
+		for _, name := range commandOrder {
+			fmt.Fprintf(stderr, "  %-48s %s\n", commands[name].usage, commands[name].help)
+		}
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "cachectl: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "cachectl: -o must be table or json, got %q\n", *output)
+		return 2
+	}
+	if *apiKey == "" {
+		fmt.Fprintln(stderr, "cachectl: -api-key or CACHECTL_API_KEY is required")
+		return 2
+	}
+
+	a := &app{
+		client: &client{baseURL: *addr, apiKey: *apiKey, tenant: *tenant, approval: *approval, http: &http.Client{}},
+		output: *output,
+		stdout: stdout,
+	}
+	if flags.Arg(0) != "events" {
+		// 事件流持续到用户中断，其余命令受 -timeout 限制
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if err := cmd.run(ctx, a, flags.Args()[1:]); err != nil {
+		var usage usageError
+		if errors.As(err, &usage) {
+			fmt.Fprintf(stderr, "cachectl: %v\nUsage: cachectl %s\n", err, cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "cachectl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// usageError 子命令参数错误
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "test-admin-key"
+
+// newServer 挂载与 AdminModule 相同认证方式的缓存管理接口
+func newServer(t *testing.T) (*httptest.Server, *cache.Admin, *fakes.Cache) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := fakes.NewCache(nil)
+	admin := cache.NewAdmin(store, cache.NewTagInvalidationStrategy(store), metrics.GetGlobalCollector(), nil)
+
+	router := gin.New()
+	group := router.Group("/admin")
+	group.Use(middleware.APIKeyOrAuthMiddleware([]config.APIKeyConfig{
+		{Name: "cachectl", Key: testAPIKey, Role: 1},
+		{Name: "reader", Key: "not-admin", Role: 0},
+	}), middleware.AdminMiddleware())
+	cache.NewAdminHandler(admin).RegisterAdminRoutes(group)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server, admin, store
+}
+
+// cachectl 执行命令，返回退出码与输出
+func cachectl(t *testing.T, server *httptest.Server, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-addr", server.URL, "-api-key", testAPIKey}, args...)
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCachectl_Entries(t *testing.T) {
+	server, _, store := newServer(t)
+
+	code, out, _ := cachectl(t, server, "set", "-ttl", "10m", "user:1", `{"name":"alice"}`)
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "Set user:1")
+	ttl, ok := store.TTL("user:1")
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Minute, ttl)
+
+	code, out, _ = cachectl(t, server, "inspect", "user:1")
+	require.Equal(t, 0, code)
+	assert.Regexp(t, `user:1\s+16 B\s+10m0s`, out)
+
+	code, out, _ = cachectl(t, server, "get", "user:1")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `{"name":"alice"}`, out)
+
+	code, out, _ = cachectl(t, server, "-o", "json", "get", "user:1")
+	require.Equal(t, 0, code)
+	var entry cache.EntryInfo
+	require.NoError(t, json.Unmarshal([]byte(out), &entry))
+	assert.Equal(t, "user:1", entry.Key)
+	assert.Equal(t, 600.0, entry.TTLSeconds)
+
+	// 不是合法 JSON 的值按字符串写入
+	code, _, _ = cachectl(t, server, "set", "greeting", "hello")
+	require.Equal(t, 0, code)
+	var greeting string
+	require.NoError(t, store.Get(context.Background(), "greeting", &greeting))
+	assert.Equal(t, "hello", greeting)
+
+	code, out, _ = cachectl(t, server, "delete", "user:1", "greeting")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "Deleted user:1\nDeleted greeting\n", out)
+
+	code, _, errOut := cachectl(t, server, "get", "user:1")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "404 cache entry not found")
+}
+
+func TestCachectl_Auth(t *testing.T) {
+	server, _, _ := newServer(t)
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"-addr", server.URL, "-api-key", "wrong", "stats"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "401 Invalid API key")
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"-addr", server.URL, "-api-key", "not-admin", "stats"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "403 Admin permission required")
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"-addr", server.URL, "stats"}, &stdout, &stderr)
+	assert.Equal(t, 2, code, "api key is required")
+}
+
+func TestCachectl_InvalidateAndEvents(t *testing.T) {
+	server, _, store := newServer(t)
+	ctx := context.Background()
+	require.NoError(t, store.Set(ctx, "graphql:coupon:1", 1, 0))
+	require.NoError(t, store.Set(ctx, "graphql:coupon:2", 2, 0))
+	require.NoError(t, store.Set(ctx, "graphql:user:1", 3, 0))
+
+	code, out, _ := cachectl(t, server, "invalidate", "-tag", "users", "-tag", "user:1", "-namespace", "graphql:coupon")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "Invalidated tags: users, user:1")
+	assert.Contains(t, out, "Deleted keys matching graphql:coupon:*")
+	assert.Equal(t, []string{"graphql:user:1", "tag:user:1:version", "tag:users:version"}, store.Keys())
+
+	code, _, errOut := cachectl(t, server, "invalidate", "-namespace", "*")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "namespace must be a non-empty key prefix")
+
+	code, out, _ = cachectl(t, server, "-o", "json", "events")
+	require.Equal(t, 0, code)
+	var resp struct {
+		Events []cache.AdminEvent `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &resp))
+	require.Len(t, resp.Events, 2)
+	assert.Equal(t, cache.AdminEventInvalidateTags, resp.Events[0].Type)
+	assert.Equal(t, "users,user:1", resp.Events[0].Target)
+	assert.Equal(t, "apikey:cachectl", resp.Events[0].Actor)
+	assert.Equal(t, cache.AdminEventInvalidateNamespace, resp.Events[1].Type)
+	assert.Equal(t, "graphql:coupon", resp.Events[1].Target)
+
+	code, out, _ = cachectl(t, server, "events", "-after", "1")
+	require.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^ID\s+TIME\s+TYPE`, lines[0])
+	assert.Regexp(t, `^2\s+.*invalidate_namespace\s+graphql:coupon\s+apikey:cachectl`, lines[1])
+}
+
+func TestCachectl_FollowEvents(t *testing.T) {
+	server, admin, _ := newServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	done := make(chan int)
+	go func() {
+		var stderr bytes.Buffer
+		args := []string{"-addr", server.URL, "-api-key", testAPIKey, "-o", "json", "events", "-f"}
+		done <- run(ctx, args, writer, &stderr)
+		writer.Close()
+	}()
+
+	// 未带调用方的标签失效（如应用内部触发）同样推送
+	lines := bufio.NewScanner(reader)
+	deadline := time.After(5 * time.Second)
+	received := make(chan cache.AdminEvent, 256)
+	go func() {
+		for lines.Scan() {
+			var event cache.AdminEvent
+			if json.Unmarshal(lines.Bytes(), &event) == nil {
+				received <- event
+			}
+		}
+	}()
+	for {
+		require.NoError(t, admin.InvalidateTags(ctx, []string{"coupons"}, ""))
+		select {
+		case event := <-received:
+			assert.Equal(t, cache.AdminEventInvalidateTags, event.Type)
+			assert.Equal(t, "coupons", event.Target)
+			assert.Empty(t, event.Actor)
+			cancel()
+			assert.Equal(t, 0, <-done)
+			return
+		case <-time.After(50 * time.Millisecond):
+			// 订阅建立前发布的事件会出现在已有事件中，重复发布直到收到
+		case <-deadline:
+			t.Fatal("no event received")
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+func runIndex(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return usageError("index requires list or apply")
+	}
+	switch args[0] {
+	case "list":
+		recommendations, err := a.optimizer.OptimizeIndexes(ctx)
+		if err != nil {
+			return err
+		}
+		if a.output == "json" {
+			return a.printJSON(map[string]interface{}{"recommendations": recommendations})
+		}
+		w := a.table("NAME", "TYPE", "TABLE", "COLUMNS", "IMPACT", "GAIN", "PRIORITY", "REASON")
+		for _, r := range recommendations {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%.0f\t%d\t%s\n", r.IndexName, r.Type, r.Table,
+				strings.Join(r.Columns, ","), r.Impact, r.EstimatedGain, r.Priority, r.Reason)
+		}
+		return w.Flush()
+	case "apply":
+		return runIndexApply(ctx, a, args[1:])
+	default:
+		return usageError(fmt.Sprintf("unknown index command %q", args[0]))
+	}
+}
+
+// indexApplyResult 单条推荐的执行结果
+type indexApplyResult struct {
+	IndexName string `json:"index_name"`
+	Type      string `json:"type"`
+	SQL       string `json:"sql"`
+	Status    string `json:"status"` // planned, applied, skipped
+}
+
+func runIndexApply(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("index apply", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Print the statements without executing them")
+	allowDrop := flags.Bool("drop", false, "Also apply recommendations that drop unused indexes")
+	all := flags.Bool("all", false, "Apply every recommendation")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *all == (flags.NArg() > 0) {
+		return usageError("pass either -all or recommendation names")
+	}
+
+	recommendations, err := a.optimizer.OptimizeIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]database.IndexRecommendation, len(recommendations))
+	for _, r := range recommendations {
+		byName[r.IndexName] = r
+	}
+	selected := recommendations
+	if !*all {
+		selected = nil
+		for _, name := range flags.Args() {
+			r, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("no recommendation named %s, run index list", name)
+			}
+			if r.Type == "drop" && !*allowDrop {
+				return usageError(fmt.Sprintf("%s drops an index, pass -drop to apply it", name))
+			}
+			selected = append(selected, r)
+		}
+	}
+
+	var results []indexApplyResult
+	for _, r := range selected {
+		result := indexApplyResult{IndexName: r.IndexName, Type: r.Type, SQL: r.SQL(), Status: "planned"}
+		switch {
+		case r.Type == "drop" && !*allowDrop:
+			result.Status = "skipped"
+		case !*dryRun:
+			if err := a.optimizer.CreateIndex(ctx, r); err != nil {
+				return fmt.Errorf("%s: %w", r.IndexName, err)
+			}
+			result.Status = "applied"
+		}
+		results = append(results, result)
+		if a.output != "json" {
+			fmt.Fprintf(a.stdout, "%-8s %s\n", result.Status, result.SQL)
+		}
+	}
+	if a.output == "json" {
+		return a.printJSON(map[string]interface{}{"results": results})
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(a.stdout, "No index recommendations")
+	}
+	return nil
+}
+
+func runSlow(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("slow", flag.ContinueOnError)
+	minMean := flags.Duration("min", 100*time.Millisecond, "Minimum mean execution time")
+	limit := flags.Int("limit", 20, "Maximum number of statements")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 || *limit <= 0 {
+		return usageError("slow takes no arguments and -limit must be positive")
+	}
+
+	statements, err := a.optimizer.SlowStatements(ctx, *minMean, *limit)
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.printJSON(map[string]interface{}{"statements": statements})
+	}
+	w := a.table("MEAN", "MAX", "CALLS", "TOTAL", "ROWS", "DISK_READS", "QUERY")
+	for _, s := range statements {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%d\t%s\n", formatMillis(s.MeanTime), formatMillis(s.MaxTime),
+			s.Calls, formatMillis(s.TotalTime), s.Rows, s.SharedRead, truncate(strings.Join(strings.Fields(s.Query), " "), 100))
+	}
+	return w.Flush()
+}
+
+func runAnalyze(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return usageError("analyze requires at least one TABLE")
+	}
+	for _, table := range args {
+		start := time.Now()
+		if err := a.optimizer.AnalyzeTable(ctx, table); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		fmt.Fprintf(a.stdout, "Analyzed %s in %s\n", table, formatDuration(time.Since(start)))
+	}
+	return nil
+}
+
+func runReindex(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	concurrently := flags.Bool("concurrently", false, "Rebuild without blocking writes (slower)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return usageError("reindex requires at least one TABLE")
+	}
+	for _, table := range flags.Args() {
+		start := time.Now()
+		if err := a.optimizer.ReindexTable(ctx, table, *concurrently); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		fmt.Fprintf(a.stdout, "Reindexed %s in %s\n", table, formatDuration(time.Since(start)))
+	}
+	return nil
+}
+
+func runShard(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return usageError("shard requires health or rebalance")
+	}
+	switch args[0] {
+	case "health":
+		return runShardHealth(ctx, a)
+	case "rebalance":
+		return runShardRebalance(ctx, a, args[1:])
+	default:
+		return usageError(fmt.Sprintf("unknown shard command %q", args[0]))
+	}
+}
+
+func runShardHealth(ctx context.Context, a *app) error {
+	nodes := a.router.Health(ctx)
+	unhealthy := 0
+	for _, node := range nodes {
+		if !node.Healthy {
+			unhealthy++
+		}
+	}
+
+	if a.output == "json" {
+		if err := a.printJSON(map[string]interface{}{"nodes": nodes}); err != nil {
+			return err
+		}
+	} else {
+		w := a.table("ROLE", "INDEX", "STATUS", "LATENCY", "CONNECTIONS", "SIZE", "LAG", "ERROR")
+		for _, node := range nodes {
+			status, lag := "ok", "-"
+			if !node.Healthy {
+				status = "down"
+			}
+			if node.InRecovery {
+				lag = formatDuration(node.ReplicationLag)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d/%d\t%s\t%s\t%s\n", node.Role, node.Index, status,
+				formatDuration(node.Latency), node.Connections, node.MaxConnections, formatBytes(node.SizeBytes), lag, node.Error)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d nodes are unhealthy", unhealthy, len(nodes))
+	}
+	return nil
+}
+
+func runShardRebalance(ctx context.Context, a *app, args []string) error {
+	defaults := database.DefaultShardRebalanceConfig()
+	flags := flag.NewFlagSet("shard rebalance", flag.ContinueOnError)
+	table := flags.String("table", "", "Sharded table")
+	key := flags.String("key", "", "Shard key column")
+	id := flags.String("id", defaults.IDColumn, "Unique indexed column used to scan and move rows")
+	batch := flags.Int("batch", defaults.BatchSize, "Rows per batch")
+	dryRun := flags.Bool("dry-run", false, "Only count rows on the wrong shard")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *table == "" || *key == "" || flags.NArg() > 0 {
+		return usageError("shard rebalance requires -table and -key")
+	}
+
+	result, err := a.router.Rebalance(ctx, &database.ShardRebalanceConfig{
+		Table:     *table,
+		KeyColumn: *key,
+		IDColumn:  *id,
+		BatchSize: *batch,
+		DryRun:    *dryRun,
+	})
+	if result != nil {
+		if a.output == "json" {
+			if printErr := a.printJSON(result); printErr != nil {
+				return printErr
+			}
+		} else {
+			w := a.table("SHARD", "SCANNED", "MISPLACED", "MOVED_OUT", "CONFLICTS")
+			for _, s := range result.Shards {
+				fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\n", s.Shard, s.Scanned, s.Misplaced, s.MovedOut, s.Conflicts)
+			}
+			if flushErr := w.Flush(); flushErr != nil {
+				return flushErr
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	var conflicts int64
+	for _, s := range result.Shards {
+		conflicts += s.Conflicts
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d rows were left in place because the target shard has a different row with the same %s", conflicts, *id)
+	}
+	return nil
+}
+
+func runPool(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("pool", flag.ContinueOnError)
+	limit := flags.Int("limit", 20, "Maximum number of events")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 || *limit <= 0 {
+		return usageError("pool takes no arguments and -limit must be positive")
+	}
+
+	events, err := database.NewSQLPoolTuningStore(a.router.Primary()).ListTuningEvents(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if a.output == "json" {
+		return a.printJSON(map[string]interface{}{"events": events})
+	}
+	if len(events) == 0 {
+		fmt.Fprintln(a.stdout, "No pool tuning events; set database.pool_tuning to suggest or apply to collect them")
+		return nil
+	}
+	w := a.table("TIME", "ACTION", "FROM", "TO", "WAITS", "WAIT_TIME", "IN_USE", "REASON")
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d/%d\t%d\t%s\t%d\t%s\n", e.Timestamp.Local().Format(time.DateTime), e.Action,
+			e.From.MaxOpen, e.From.MaxIdle, e.To.MaxOpen, e.To.MaxIdle, e.WaitCount, formatDuration(e.WaitDuration), e.InUse, e.Reason)
+	}
+	return w.Flush()
+}
+
+// table 以制表符对齐的表格输出，调用方写完行后 Flush
+func (a *app) table(columns ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	return w
+}
+
+func (a *app) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// parseFlags 解析子命令选项，错误作为参数错误返回
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	return nil
+}
+
+// formatMillis 毫秒数格式化为时长
+func formatMillis(ms float64) string {
+	return formatDuration(time.Duration(ms * float64(time.Millisecond)))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,141 @@
+// dbctl 数据库运维命令行：查看与应用索引推荐，查看慢语句，对指定表执行 ANALYZE、REINDEX，
+// 检查主库、副本与分片的健康状态并在增减分片后重新分布数据，以及查看连接池调优建议。
+//
+// 连接信息读取 configs/config.yaml（APP_ENV 选择环境），副本与分片来自 database.replica_dsns、database.shard_dsns；
+// 索引与 REINDEX 需要表所有者权限，以 migrate 组件的角色（database.roles.migrate）连接：
+//
+//	dbctl index list
+//	dbctl index apply -dry-run idx_users_mobile
+//	dbctl shard rebalance -table orders -key user_id -dry-run
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// command 子命令
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"index":   {"index list | index apply [-dry-run] [-drop] (-all | NAME...)", "List or apply index recommendations", runIndex},
+	"slow":    {"slow [-min 100ms] [-limit 20]", "Slowest statements from pg_stat_statements", runSlow},
+	"analyze": {"analyze TABLE...", "Refresh planner statistics", runAnalyze},
+	"reindex": {"reindex [-concurrently] TABLE...", "Rebuild all indexes of the tables", runReindex},
+	"shard":   {"shard health | shard rebalance -table T -key COL [-id id] [-batch 500] [-dry-run]", "Node health, or move rows to their shard", runShard},
+	"pool":    {"pool [-limit 20]", "Recent connection pool tuning suggestions and changes", runPool},
+}
+
+// commandOrder 帮助信息中子命令的顺序
+var commandOrder = []string{"index", "slow", "analyze", "reindex", "shard", "pool"}
+
+// connect 连接主库与配置中的副本、分片，测试中替换
+var connect = func() (*database.Router, error) {
+	config.LoadConfig()
+	return database.InitRouter(database.InitDatabaseFor(database.ComponentMigrate)), nil
+}
+
+// app 数据库连接与输出
+type app struct {
+	router    *database.Router
+	optimizer *database.IndexOptimizer
+	output    string
+	stdout    io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 解析参数并执行子命令，返回进程退出码：0 成功，1 执行失败，2 参数错误
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dbctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var (
+		output  = flags.String("o", "table", "Output format: table or json")
+		timeout = flags.Duration("timeout", 0, "Abort the command after this long, 0 waits until it finishes")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: dbctl [flags] <command> [args]")
+		fmt.Fprintln(stderr, "\nCommands:")
+		for _, name := range commandOrder {
+			fmt.Fprintf(stderr, "  %s\n      %s\n", commands[name].usage, commands[name].help)
+		}
+		fmt.Fprintln(stderr, "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "dbctl: unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(stderr, "dbctl: -o must be table or json, got %q\n", *output)
+		return 2
+	}
+
+	router, err := connect()
+	if err != nil {
+		fmt.Fprintf(stderr, "dbctl: %v\n", err)
+		return 1
+	}
+	defer router.Primary().Close()
+	defer router.Close()
+	a := &app{
+		router:    router,
+		optimizer: database.NewIndexOptimizer(router.Primary().DB.DB, metrics.GetGlobalCollector()),
+		output:    *output,
+		stdout:    stdout,
+	}
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if err := cmd.run(ctx, a, flags.Args()[1:]); err != nil {
+		var usage usageError
+		if errors.As(err, &usage) {
+			fmt.Fprintf(stderr, "dbctl: %v\nUsage: dbctl %s\n", err, cmd.usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "dbctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// usageError 子命令参数错误
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// formatDuration 按毫秒取整输出
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useRouter 让 run 使用 sqlmock 连接
+func useRouter(t *testing.T, router *database.Router) {
+	t.Helper()
+	previous := connect
+	connect = func() (*database.Router, error) { return router, nil }
+	t.Cleanup(func() { connect = previous })
+}
+
+// dbctl 执行命令，返回退出码与输出
+func dbctl(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// expectRecommendations 让索引优化器看到一条缺少索引的高频查询
+func expectRecommendations(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`FROM pg_stat_statements`).
+		WillReturnRows(sqlmock.NewRows([]string{"query", "calls", "total_exec_time", "mean_exec_time", "rows"}).
+			AddRow("SELECT * FROM users WHERE mobile = $1", int64(500), 300000.0, 600.0, int64(500)))
+	mock.ExpectQuery(`FROM pg_index`).
+		WillReturnRows(sqlmock.NewRows([]string{"nspname", "relname", "relname", "indexdef", "indisunique", "indisprimary"}))
+}
+
+// withPrimary 每次执行前创建新的主库连接，run 结束时会关闭连接
+func withPrimary(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	primary, mock := fakes.NewDB(t)
+	useRouter(t, database.NewRouter(primary, nil, nil))
+	return mock
+}
+
+func TestDbctl_IndexApply(t *testing.T) {
+	expectRecommendations(withPrimary(t))
+	code, out, _ := dbctl("index", "apply", "-dry-run", "idx_users_mobile")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "planned  CREATE INDEX CONCURRENTLY IF NOT EXISTS \"idx_users_mobile\" ON \"users\" (\"mobile\")\n", out)
+
+	mock := withPrimary(t)
+	expectRecommendations(mock)
+	mock.ExpectExec(`CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_users_mobile"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	code, out, _ = dbctl("-o", "json", "index", "apply", "-all")
+	require.Equal(t, 0, code)
+	var resp struct {
+		Results []indexApplyResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &resp))
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, "applied", resp.Results[0].Status)
+
+	expectRecommendations(withPrimary(t))
+	code, _, errOut := dbctl("index", "apply", "idx_missing")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "no recommendation named idx_missing")
+
+	withPrimary(t)
+	code, _, _ = dbctl("index", "apply")
+	assert.Equal(t, 2, code, "-all or names are required")
+}
+
+func TestDbctl_ShardHealthFailsOnUnhealthyNode(t *testing.T) {
+	primary, mockPrimary := fakes.NewDB(t)
+	shard, mockShard := fakes.NewDB(t)
+	useRouter(t, database.NewRouter(primary, nil, []*database.DB{shard}))
+
+	mockPrimary.ExpectQuery(`pg_stat_activity`).
+		WillReturnRows(sqlmock.NewRows([]string{"c", "m", "s", "r", "l"}).AddRow(3, 100, int64(2048), false, 0.0))
+	mockShard.ExpectQuery(`pg_stat_activity`).WillReturnError(errors.New("connection refused"))
+
+	code, out, errOut := dbctl("shard", "health")
+	assert.Equal(t, 1, code)
+	assert.Regexp(t, `primary\s+0\s+ok\s+\S+\s+3/100\s+2\.0 KiB`, out)
+	assert.Regexp(t, `shard\s+0\s+down.*connection refused`, out)
+	assert.Contains(t, errOut, "1 of 2 nodes are unhealthy")
+}
+
+func TestDbctl_Usage(t *testing.T) {
+	code, _, errOut := dbctl("vacuum")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, `unknown command "vacuum"`)
+}
@@ -7,16 +7,51 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
 	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/internal/pkg/grpcserver"
+	"user_crud_jwt/internal/pkg/middleware"
 	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/admission"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/approvals"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/chaos"
 	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/dbadmin"
+	"user_crud_jwt/pkg/features"
+	"user_crud_jwt/pkg/health"
+	"user_crud_jwt/pkg/inventory"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/lifecycle"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/notify"
+	"user_crud_jwt/pkg/oidc"
+	"user_crud_jwt/pkg/openapi"
+	"user_crud_jwt/pkg/outbox"
+	"user_crud_jwt/pkg/payment"
+	"user_crud_jwt/pkg/payment/alipay"
+	"user_crud_jwt/pkg/payment/wechatpay"
+	"user_crud_jwt/pkg/profiling"
+	"user_crud_jwt/pkg/reports"
+	"user_crud_jwt/pkg/retention"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/security/jwtkeys"
+	"user_crud_jwt/pkg/security/twofactor"
+	"user_crud_jwt/pkg/utils"
 
 	// 导入所有域模块以触发 init() 函数
+	_ "user_crud_jwt/internal/domain/activity"
+	_ "user_crud_jwt/internal/domain/admin"
 	_ "user_crud_jwt/internal/domain/common"
+	_ "user_crud_jwt/internal/domain/compliance"
 	_ "user_crud_jwt/internal/domain/coupon"
+	_ "user_crud_jwt/internal/domain/gateway"
 	_ "user_crud_jwt/internal/domain/moment"
 	_ "user_crud_jwt/internal/domain/payment"
 	_ "user_crud_jwt/internal/domain/user"
+	_ "user_crud_jwt/internal/domain/verification"
 
 	"github.com/gin-gonic/gin"
 )
@@ -29,6 +64,38 @@ func main() {
 	// 2. 初始化数据库
 	db := database.InitDatabase()
 	defer db.DB.Close()
+	// 按读、写、报表分别限制单条语句的执行时间，超时的语句被取消并记入慢查询
+	queryTimeouts := database.DefaultQueryTimeouts()
+	for _, item := range []struct {
+		seconds int
+		budget  *time.Duration
+	}{
+		{cfg.Database.ReadTimeout, &queryTimeouts.Read},
+		{cfg.Database.WriteTimeout, &queryTimeouts.Write},
+		{cfg.Database.ReportTimeout, &queryTimeouts.Report},
+	} {
+		if item.seconds != 0 {
+			*item.budget = time.Duration(item.seconds) * time.Second
+		}
+	}
+	db.SetQueryTimeouts(queryTimeouts)
+	// 行级安全：每条语句写入请求的租户与用户，由数据库策略兜底应用层的租户与所有权检查
+	if cfg.Database.RowSecurity {
+		db.EnableRowSecurity()
+	}
+	// 任务队列与数据保留、分区维护使用独立的连接池，以各自的 application_name 与角色（database.roles）连接，
+	// 在 pg_stat_activity 中与请求区分；创建与删除分区需要表所有者权限，使用 migrate 组件的角色
+	jobsDB := database.InitDatabaseFor(database.ComponentJobs)
+	defer jobsDB.DB.Close()
+	jobsDB.SetQueryTimeouts(queryTimeouts)
+	schemaDB := database.InitDatabaseFor(database.ComponentMigrate)
+	defer schemaDB.DB.Close()
+	schemaDB.SetQueryTimeouts(queryTimeouts)
+	// 策略对所有角色生效（包括表所有者），后台任务的语句同样写入会话变量，系统调用以 app.bypass 放行
+	if cfg.Database.RowSecurity {
+		jobsDB.EnableRowSecurity()
+		schemaDB.EnableRowSecurity()
+	}
 
 	// 2.5. 初始化 Redis
 	redis := database.InitRedis()
@@ -41,17 +108,640 @@ func main() {
 
 	// 4. 创建路由
 	router := gin.Default()
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(apperrors.ErrorHandler())
+	router.Use(middleware.PrometheusMiddleware(middleware.DefaultPrometheusConfig()))
+	// 压缩在指标之内，记录的响应大小为实际传输的字节数
+	compression := middleware.DefaultCompressionConfig()
+	router.Use(middleware.CompressionMiddleware(compression))
+	router.GET("/metrics", middleware.MetricsHandler())
+
+	// 4.5. 健康检查
+	healthRegistry := health.NewRegistry(health.DefaultConfig())
+	healthRegistry.Register(health.Check{Name: "database", Check: health.DatabaseCheck(db), Critical: true})
+	healthRegistry.Register(health.Check{Name: "redis", Check: health.RedisCheck(redis), Critical: true})
+	// goroutine 泄漏检测：后台任务、事件总线分片与安全事件写入器登记各自的 goroutine，
+	// 组件关闭后仍在运行的 goroutine 使就绪检查降级，清单见 /healthz/goroutines
+	healthRegistry.RegisterGoroutines(lifecycle.Default())
+	healthRegistry.RegisterRoutes(router)
+
+	// 4.5.1. JWT 签名密钥：配置 jwt.algorithm 后密钥存放在数据库并定期轮换，令牌头部带 kid，
+	// 轮换前签发的令牌在宽限期内仍可验证；公钥发布在 /.well-known/jwks.json，不区分租户
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	background := lifecycle.Register("background")
+	background.Go("goroutine_leak_detector", func() { lifecycle.Default().Run(backgroundCtx) })
+	var tokenKeys *jwtkeys.Manager
+	if cfg.JWT.Algorithm != "" {
+		algorithm, err := jwtkeys.ParseAlgorithm(cfg.JWT.Algorithm)
+		if err != nil {
+			log.Fatalf("Invalid jwt algorithm: %v", err)
+		}
+		keyConfig := jwtkeys.DefaultConfig()
+		keyConfig.Algorithm = algorithm
+		// 未配置的轮换间隔与宽限期沿用默认值，宽限期不得短于令牌有效期
+		if cfg.JWT.RotationDays > 0 {
+			keyConfig.RotationInterval = time.Duration(cfg.JWT.RotationDays) * 24 * time.Hour
+		}
+		if cfg.JWT.GraceDays > 0 {
+			keyConfig.GracePeriod = time.Duration(cfg.JWT.GraceDays) * 24 * time.Hour
+		}
+		keyConfig.TokenLifetime = utils.TokenLifetime
+		keyConfig.EncryptionKey = cfg.JWT.KeyEncryptionKey
+		keyConfig.LegacySecret = cfg.JWT.Secret
+		tokenKeys, err = jwtkeys.NewManager(jwtkeys.NewSQLStore(db), keyConfig)
+		if err != nil {
+			log.Fatalf("Failed to create jwt key manager: %v", err)
+		}
+		if err := tokenKeys.Start(context.Background()); err != nil {
+			log.Fatalf("Failed to load jwt signing keys: %v", err)
+		}
+		background.Go("jwt_keys", func() { tokenKeys.Run(backgroundCtx) })
+		utils.SetTokenKeys(tokenKeys)
+		if cfg.JWT.JWKS {
+			jwtkeys.NewHandler(tokenKeys).RegisterRoutes(router)
+		}
+	}
+
+	// 4.6. OpenAPI 文档，首次访问时根据已注册的路由生成
+	openapi.NewGenerator(openapi.Info{Title: "Golang Commercial-Grade API", Version: "1.0"}).RegisterRoutes(router)
+
+	// 4.6.1. 租户解析，只作用于此后注册的业务路由；指标、健康检查与文档不区分租户。
+	// 业务路由的响应缓存键与数据库查询均按租户隔离
+	router.Use(middleware.TenantMiddleware(&middleware.TenantConfig{
+		Header:     cfg.Tenant.Header,
+		BaseDomain: cfg.Tenant.BaseDomain,
+		Default:    cfg.Tenant.Default,
+	}))
+
+	// 4.6.1.1. 优先级准入：管理员与运维工具、已登录用户、匿名请求各有独立的并发预算，用尽时在各自的队列中等待，
+	// 过载时管理接口仍可用；队列深度与拒绝数见 http_priority_* 指标
+	if cfg.Priority.Enabled {
+		identify, err := middleware.PriorityClassifier(cfg.Admin.APIKeys)
+		if err != nil {
+			log.Fatalf("Invalid admin api key config: %v", err)
+		}
+		var rules []admission.RouteRule
+		for _, route := range cfg.Priority.Routes {
+			priority, err := admission.ParsePriority(route.Priority)
+			if err != nil {
+				log.Fatalf("Invalid priority route %s: %v", route.Prefix, err)
+			}
+			rules = append(rules, admission.RouteRule{Prefix: route.Prefix, Priority: priority})
+		}
+		queueConfig := admission.DefaultQueueConfig()
+		for priority, concurrency := range map[admission.Priority]int{
+			admission.PriorityCritical: cfg.Priority.CriticalConcurrency,
+			admission.PriorityNormal:   cfg.Priority.NormalConcurrency,
+			admission.PriorityLow:      cfg.Priority.LowConcurrency,
+		} {
+			class := queueConfig.Classes[priority]
+			class.Concurrency = concurrency
+			queueConfig.Classes[priority] = class
+		}
+		router.Use(admission.NewQueue(queueConfig, admission.RouteClassifier(rules, identify), metrics.GetGlobalCollector()).Middleware())
+	}
+
+	// 4.6.1.2. 自适应并发限制：按路由组（路径第一段）限制进行中的请求数，延迟升高时收缩、恢复后逐步放开，
+	// 超过上限直接返回 503 与 Retry-After，critical 优先级的请求不受限制；在安全监控之前挂载，被拒绝的请求不计为 5xx 安全事件
+	if cfg.Limiter.Enabled {
+		limiterConfig := admission.DefaultLimiterConfig()
+		limiterConfig.InitialLimit = cfg.Limiter.InitialLimit
+		limiterConfig.MinLimit = cfg.Limiter.MinLimit
+		limiterConfig.MaxLimit = cfg.Limiter.MaxLimit
+		limiterConfig.Tolerance = cfg.Limiter.Tolerance
+		router.Use(admission.NewLimiter(limiterConfig, metrics.GetGlobalCollector()).Middleware())
+	}
+
+	// 4.6.2. 故障注入：非 prod 构建（未指定 -tags prod）可通过 /admin/chaos/faults 为缓存与数据库注入延迟、错误与部分故障，
+	// 用于验证熔断、降级等容错能力；没有规则时不影响调用
+	var faults *chaos.Injector
+	if chaos.Enabled {
+		faults = chaos.NewInjector()
+		db.SetFaultHook(faults.DatabaseHook)
+		log.Println("Fault injection is compiled in; do not use this build in production")
+	}
+
+	// 4.6.3. 连接池指标：每 15 秒采样一次，连接数为仪表盘，等待次数与关闭的连接数为计数器，等待时长为直方图
+	background.Go("pool_stats", func() { db.ReportPoolStats(backgroundCtx, metrics.GetGlobalCollector(), 15*time.Second) })
+
+	// 4.6.4. 连接池自动调优：按等待次数与空闲连接使用情况逐步调整最大连接数与空闲连接数，
+	// apply 模式下修改后观察一段时间，等待增多则回滚；每次调整写入 pool_tuning_events 表
+	if cfg.Database.PoolTuning != "" {
+		tunerConfig := database.DefaultPoolTunerConfig()
+		tunerConfig.Mode = database.PoolTuneMode(cfg.Database.PoolTuning)
+		tuner, err := database.NewPoolTuner(db.DB.DB, database.NewSQLPoolTuningStore(db), tunerConfig)
+		if err != nil {
+			log.Fatalf("Invalid pool tuning config: %v", err)
+		}
+		background.Go("pool_tuner", func() { tuner.Run(backgroundCtx) })
+	}
+
+	// 4.6.5. 缓存有效期：cache.ttl_profiles 按键命名空间配置，当前环境（app.env）覆盖 default；
+	// 权限缓存、响应缓存与预热写入时按命名空间解析，/admin/cache/ttl 下可运行时调整，调整保存在 Redis 中各实例共享
+	redisCache := chaos.WrapCache(cache.NewRedisCache(redis), faults)
+	var ttlProfiles []cache.TTLProfile
+	for _, env := range []string{"default", cfg.App.Env} {
+		profiles := make([]cache.TTLProfile, 0, len(cfg.Cache.TTLProfiles[env]))
+		for _, profile := range cfg.Cache.TTLProfiles[env] {
+			profiles = append(profiles, cache.TTLProfile{
+				Namespace: profile.Namespace,
+				TTL:       time.Duration(profile.TTLSeconds) * time.Second,
+				LocalTTL:  time.Duration(profile.LocalTTLSeconds) * time.Second,
+			})
+		}
+		ttlProfiles = cache.MergeTTLProfiles(ttlProfiles, profiles)
+	}
+	cacheTTLs, err := cache.NewTTLResolver(redisCache, ttlProfiles, metrics.GetGlobalCollector(), cache.DefaultTTLResolverConfig())
+	if err != nil {
+		log.Fatalf("Invalid cache ttl profiles: %v", err)
+	}
+	background.Go("cache_ttl_profiles", func() { cacheTTLs.Run(backgroundCtx) })
+	profiledCache := cache.WithTTLProfiles(redisCache, cacheTTLs)
+
+	// 4.7. 权限检查，gRPC 与 GraphQL 网关共用，缓存命中率按调用的接口计入 rbac
+	rbac := security.NewRBAC(cache.AttributeCallers(profiledCache, "rbac", metrics.GetGlobalCollector()))
+
+	// 4.7.1. GET 响应缓存：响应与其预压缩变体存放在本地 + Redis 两级缓存，标签版本号存放在 Redis 以便各实例共享
+	tagInvalidation := cache.NewTagInvalidationStrategy(redisCache)
+	levelsConfig := cache.DefaultMultiLevelConfig()
+	if cfg.Cache.LocalSyncSeconds > 0 {
+		// 其他实例更新或失效 Redis 中的响应后，本地层的旧副本在对账时被刷新或删除
+		levelsConfig.EnableBackgroundSync = true
+		levelsConfig.SyncInterval = time.Duration(cfg.Cache.LocalSyncSeconds) * time.Second
+	}
+	responseLevels := cache.NewMultiLevelCache(cache.NewMemoryCache(), redisCache, metrics.GetGlobalCollector(), levelsConfig)
+	defer responseLevels.Close()
+	responseLevels.SetTTLResolver(cacheTTLs)
+	responseCache := middleware.NewResponseCache(responseLevels, tagInvalidation, compression)
+	// 缓存运维：/admin/cache 下查看、写入与删除键，执行预热任务，按标签或命名空间失效，查看命中率与变更事件，供 cmd/cachectl 调用
+	cacheAdmin := cache.NewAdmin(redisCache, tagInvalidation, metrics.GetGlobalCollector(), cache.DefaultAdminConfig())
+	cacheAdmin.SetTTLResolver(cacheTTLs)
+	// 容量分析：定期随机采样键的 MEMORY USAGE，按命名空间推算占用并给出 maxmemory-policy 建议，
+	// /admin/cache/memory 查看，/admin/cache/memory/ttl-impact 推算有效期调整的影响；直接读取 Redis，不经故障注入
+	if cfg.Cache.MemoryAnalysisMinutes >= 0 {
+		memoryConfig := cache.DefaultMemoryAnalysisConfig()
+		if cfg.Cache.MemoryAnalysisMinutes > 0 {
+			memoryConfig.Interval = time.Duration(cfg.Cache.MemoryAnalysisMinutes) * time.Minute
+		}
+		if cfg.Cache.MemorySampleSize > 0 {
+			memoryConfig.SampleSize = cfg.Cache.MemorySampleSize
+		}
+		memoryAnalyzer := cache.NewMemoryAnalyzer(cache.NewRedisCache(redis).(cache.MemorySampler), metrics.GetGlobalCollector(), memoryConfig)
+		cacheAdmin.SetMemoryAnalyzer(memoryAnalyzer)
+		background.Go("cache_memory_analysis", func() { memoryAnalyzer.Run(backgroundCtx) })
+	}
+	// 热门键预热：各模块登记加载器并以 cache.TrackAccess 包装自身的缓存，采样的读取按命名空间统计热门键保存在 Redis，
+	// 启动后与命名空间被清空后预热这些键
+	popularity := cache.NewPopularityTracker(redisCache, cache.DefaultPopularityConfig())
+	background.Go("cache_popularity", func() { popularity.Run(backgroundCtx) })
+	cacheWarmup := cache.NewCacheWarmupManager(profiledCache, metrics.GetGlobalCollector(), &cache.WarmupConfig{})
+	cacheWarmup.SetPopularity(popularity)
+	cacheAdmin.RegisterWarmup("popular_keys", "Cache the most read keys of every namespace", func(ctx context.Context) (*cache.WarmupResult, error) {
+		return cacheWarmup.WarmupPopular(ctx, "")
+	})
+	background.Go("cache_warmup_after_flush", func() { cacheWarmup.WarmupAfterFlush(backgroundCtx, cacheAdmin) })
+	// 部署预热：依次执行配置的预热任务组，开启门禁时完成或超时前 /readyz 返回 503，避免新实例以冷缓存接收流量
+	preloadConfig := cache.DefaultPreloadConfig()
+	if len(cfg.Preload.Sets) > 0 {
+		preloadConfig.Sets = cfg.Preload.Sets
+	}
+	if cfg.Preload.TimeoutSeconds > 0 {
+		preloadConfig.Timeout = time.Duration(cfg.Preload.TimeoutSeconds) * time.Second
+	}
+	preloader := cache.NewPreloader(cacheAdmin, preloadConfig)
+	if cfg.Preload.Gate {
+		preloader.Arm()
+		healthRegistry.Register(health.Check{Name: "cache_preload", Check: preloader.Check, Critical: true})
+	}
+
+	// 4.7.1.1. 位置索引：附近的优惠券、动态等按图层以 GEOADD 保存坐标、GEOSEARCH 查询，没有 Redis 时使用内存实现
+	geo := cache.NewGeoService(cache.NewGeoStore(redis), cache.DefaultGeoConfig())
+
+	// 4.7.1.2. 缓存与数据库比对：模块按键模板登记数据源，定时任务取热门键与数据库的值比较哈希，可选以数据库为准修复
+	consistencyChecker := cache.NewCacheConsistencyChecker(profiledCache, metrics.GetGlobalCollector(), &cache.ConsistencyConfig{})
+	consistencyChecker.SetPopularity(popularity)
+	sourceCheckConfig := cache.DefaultSourceCheckConfig()
+	if cfg.Cache.ConsistencySchedule != "" {
+		sourceCheckConfig.Schedule = cfg.Cache.ConsistencySchedule
+	}
+	sourceCheckConfig.Repair = cfg.Cache.ConsistencyRepair
+	consistencyChecker.SetSourceCheckConfig(sourceCheckConfig)
+
+	// 4.7.2. 功能开关，定义存放在数据库，各模块通过 Require 中间件按开关放开新接口
+	featureManager := features.NewManager(features.NewSQLStore(db), features.DefaultConfig())
+	// 实验的参与人群可由功能开关圈定，曝光经事件总线异步写入
+	experiments := features.NewExperiments(features.NewSQLExperimentStore(db), featureManager, features.DefaultExperimentConfig())
+
+	// 4.7.3. 安全监控：401、403、429、5xx、慢请求与命中 WAF 规则的请求记录为安全事件，批量写入按月分区的 security_events 表
+	partitions := database.NewPartitionManager(schemaDB)
+	if err := partitions.Register(&database.PartitionConfig{
+		Table:     "security_events",
+		Column:    "occurred_at",
+		Interval:  database.PartitionMonthly,
+		Premake:   2,
+		Retention: 365 * 24 * time.Hour,
+	}); err != nil {
+		log.Fatalf("Failed to register security event partitions: %v", err)
+	}
+	background.Go("partitions", func() { partitions.Run(backgroundCtx, time.Hour) })
+
+	securityEvents := security.NewSQLSecurityEventStore(db)
+	securityMonitor := security.NewSecurityMonitor(redisCache, metrics.GetGlobalCollector(), security.NewDefaultSecurityLogger())
+	securityMonitor.EnablePersistence(securityEvents, security.DefaultEventPersistenceConfig())
+	// 请求先经 WAF 规则检查；未配置规则文件时使用内置规则，只记录不拦截
+	if cfg.WAF.RulesFile != "" {
+		waf, err := security.LoadWAF(cfg.WAF.RulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load waf rules: %v", err)
+		}
+		challenge := security.DefaultWAFChallengeConfig()
+		challenge.Secret = []byte(cfg.WAF.ChallengeSecret)
+		challenge.Difficulty = cfg.WAF.ChallengeDifficulty
+		waf.SetChallenger(security.NewWAFChallenger(challenge))
+		background.Go("waf_watch", func() { waf.Watch(backgroundCtx, time.Duration(cfg.WAF.ReloadInterval)*time.Second) })
+		securityMonitor.SetWAF(waf)
+	}
+	router.Use(security.NewSecurityMonitoringMiddleware(securityMonitor).Middleware())
+
+	// 4.7.4. IP 信誉：安全事件为来源 IP 累计分数，超过阈值临时封禁；手动允许、拒绝名单存放在数据库。
+	// 在安全监控之后挂载，被拒绝的请求同样记录为 403 事件，但已封禁的 IP 不再累计分数
+	ipReputation := security.NewIPReputation(redisCache, security.NewSQLIPRuleStore(db), security.DefaultIPReputationConfig())
+	securityMonitor.AddEventListener(ipReputation)
+	router.Use(ipReputation.Middleware())
+
+	// 4.7.5. 会话吊销：/admin/users/:id/sessions 记录用户的吊销时间，此前签发的令牌在 HTTP 与 gRPC 认证时被拒绝
+	sessionRevoker := security.NewSessionRevoker(redisCache, utils.TokenLifetime)
+	// 代登录：具备 user:impersonate 权限的管理员以用户身份获得限时令牌，会话结束或到期后令牌失效；
+	// 代登录期间拒绝 admin.impersonation.forbidden_routes 下的操作，每个请求记录为 impersonation 安全事件
+	impersonationConfig := security.DefaultImpersonationConfig()
+	if cfg.Admin.Impersonation.DefaultMinutes > 0 {
+		impersonationConfig.DefaultDuration = time.Duration(cfg.Admin.Impersonation.DefaultMinutes) * time.Minute
+	}
+	if cfg.Admin.Impersonation.MaxMinutes > 0 {
+		impersonationConfig.MaxDuration = time.Duration(cfg.Admin.Impersonation.MaxMinutes) * time.Minute
+	}
+	impersonations := security.NewImpersonationManager(redisCache, rbac, securityMonitor, impersonationConfig)
+	router.Use(securityMonitor.ImpersonationAuditMiddleware())
+	utils.SetTokenRevocations(sessionRevoker, impersonations)
+	// 令牌带签发时的权限版本（RBAC 的用户缓存代数），角色或权限变化后版本落后的令牌在认证时按用户表重新解析角色，
+	// 响应头 X-Refreshed-Token 下发新令牌；角色来源由用户模块登记，见 5.0
+	utils.SetPermissionVersions(rbac)
+	// 两步验证：每次认证后检查，必须开启的角色未登记或未通过二次验证时拒绝访问，/2fa 下的登记与验证接口除外；
+	// 连续验证失败的计数存放在 Redis，达到上限后锁定，失败与锁定记录为 two_factor 安全事件
+	twoFactorConfig := twofactor.DefaultConfig()
+	if cfg.TwoFactor.Issuer != "" {
+		twoFactorConfig.Issuer = cfg.TwoFactor.Issuer
+	}
+	twoFactorConfig.EncryptionKey = cfg.TwoFactor.EncryptionKey
+	if len(cfg.TwoFactor.RequiredRoles) > 0 {
+		twoFactorConfig.RequiredRoles = cfg.TwoFactor.RequiredRoles
+	}
+	if cfg.TwoFactor.MaxFailures > 0 {
+		twoFactorConfig.MaxFailures = cfg.TwoFactor.MaxFailures
+	}
+	if cfg.TwoFactor.LockoutMinutes > 0 {
+		twoFactorConfig.LockoutDuration = time.Duration(cfg.TwoFactor.LockoutMinutes) * time.Minute
+	}
+	twoFactor, err := twofactor.NewManager(twoFactorConfig, twofactor.NewSQLStore(db), redisCache)
+	if err != nil {
+		log.Fatalf("Failed to create two-factor manager: %v", err)
+	}
+	twoFactor.SetAttempts(twofactor.NewRedisAttempts(redis))
+	twoFactor.SetRecorder(securityMonitor)
+	// 按角色配额限流：认证通过后按 RBAC 角色计数，计数存放在 Redis 中各实例共享，/admin/rate-limit/tiers 下可运行时调整
+	roleQuotas := security.NewRoleQuotaManager(rbac)
+	roleQuotas.SetCounter(security.NewRedisQuotaCounter(redis))
+	middleware.SetAuthPolicies(twoFactor.Enforce, roleQuotas.Enforce)
+	twofactor.NewHandler(twoFactor).RegisterRoutes(router.Group("", middleware.SkipAuthPolicies(), middleware.AuthMiddleware()))
+
+	// 4.7.4.2. 企业单点登录：配置了身份提供方时注册 /auth/oidc 路由，授权 state 存放在 Redis 中以 GETDEL 一次性读取
+	if len(cfg.OIDC.Providers) > 0 {
+		oidcClient := oidc.NewClient(oidc.NewRedisStateStore(redis))
+		oidcClient.Setup(backgroundCtx, cfg.OIDC.Providers)
+		oidc.NewHandler(oidcClient, oidc.NewSQLProvisioner(db, cfg.OIDC.LinkByEmail)).RegisterRoutes(router.Group("/auth"))
+	}
+
+	// 4.7.4.3. 支付网关：按配置注册支付宝与微信支付渠道，订单状态与回调事件记录在 orders / payment_events 表中，
+	// 渠道回调 /payments/webhook/:provider 由各渠道验签，长时间未收到回调的订单定期向渠道查询对账
+	paymentGateway := payment.NewGateway(payment.NewSQLStore(db))
+	if cfg.Alipay.AppID != "" {
+		if provider, err := alipay.NewProvider(cfg.Alipay); err != nil {
+			log.Printf("Failed to create alipay provider: %v", err)
+		} else {
+			paymentGateway.Register(provider)
+		}
+	}
+	if cfg.Wechat.MchID != "" {
+		if provider, err := wechatpay.NewProvider(backgroundCtx, cfg.Wechat); err != nil {
+			log.Printf("Failed to create wechat pay provider: %v", err)
+		} else {
+			paymentGateway.Register(provider)
+		}
+	}
+	paymentHandler := payment.NewHandler(paymentGateway)
+	paymentHandler.RegisterRoutes(router.Group("", middleware.AuthMiddleware()))
+	paymentHandler.RegisterWebhookRoutes(router.Group(""))
+	background.Go("payment_sync", func() { paymentGateway.Run(backgroundCtx, 5*time.Minute) })
+
+	// 4.7.5.1. 自动性能剖析：goroutine 数或一分钟内的慢请求数超过阈值时采集 CPU、堆与 goroutine 剖析并保存到 profiling.dir，
+	// 每次采集记录 performance_anomaly 事件并触发告警，事件详情链接到 /admin/profiles/<id>
+	var profiler *profiling.Profiler
+	if cfg.Profile.Dir != "" {
+		profilingConfig := profiling.DefaultConfig()
+		profilingConfig.Dir = cfg.Profile.Dir
+		profilingConfig.GoroutineThreshold = cfg.Profile.GoroutineThreshold
+		profilingConfig.SlowRequestThreshold = cfg.Profile.SlowRequestThreshold
+		profilingConfig.Cooldown = time.Duration(cfg.Profile.CooldownMinutes) * time.Minute
+		profilingConfig.Retention = time.Duration(cfg.Profile.RetentionDays) * 24 * time.Hour
+		var err error
+		profiler, err = profiling.NewProfiler(profilingConfig, securityMonitor)
+		if err != nil {
+			log.Fatalf("Failed to create profiler: %v", err)
+		}
+		securityMonitor.AddEventListener(profiler)
+		background.Go("profiler", func() { profiler.Run(backgroundCtx) })
+	}
+
+	// 4.7.5.2. 表与索引膨胀分析：每小时估算一次（安装 pgstattuple 时精确统计），跟踪 autovacuum 是否跟上，
+	// 超过阈值时记录 database_health 事件；/admin/db/bloat 查看 VACUUM、VACUUM FULL 与 REINDEX 建议
+	var bloatAnalyzer *dbadmin.BloatAnalyzer
+	if cfg.Database.BloatCheckMinutes >= 0 {
+		bloatConfig := dbadmin.DefaultBloatConfig()
+		if cfg.Database.BloatCheckMinutes > 0 {
+			bloatConfig.Interval = time.Duration(cfg.Database.BloatCheckMinutes) * time.Minute
+		}
+		if cfg.Database.BloatMinSizeMB > 0 {
+			bloatConfig.MinSize = int64(cfg.Database.BloatMinSizeMB) << 20
+		}
+		bloatAnalyzer = dbadmin.NewBloatAnalyzer(dbadmin.NewSQLBloatSource(db), securityMonitor, bloatConfig)
+		background.Go("bloat_analyzer", func() { bloatAnalyzer.Run(backgroundCtx) })
+	}
+
+	// 4.7.5.3. 锁监控：每 10 秒采样 pg_locks 与 pg_stat_activity，按查询指纹聚合长时间的锁等待，检测等待成环与加锁顺序相反的语句，
+	// 数据库死锁计数增加时记录 database_health 事件；/admin/db/locks 查看当前等待与聚合的模式
+	var lockMonitor *dbadmin.LockMonitor
+	if cfg.Database.LockCheckSeconds >= 0 {
+		lockConfig := dbadmin.DefaultLockConfig()
+		if cfg.Database.LockCheckSeconds > 0 {
+			lockConfig.Interval = time.Duration(cfg.Database.LockCheckSeconds) * time.Second
+		}
+		if cfg.Database.LockWaitSeconds > 0 {
+			lockConfig.WaitThreshold = time.Duration(cfg.Database.LockWaitSeconds) * time.Second
+		}
+		lockMonitor = dbadmin.NewLockMonitor(dbadmin.NewSQLLockSource(db), securityMonitor, lockConfig)
+		background.Go("lock_monitor", func() { lockMonitor.Run(backgroundCtx) })
+	}
+
+	// 4.7.6. 定时运维报告：按 reports.schedules 每日或每周生成缓存、数据库与安全报告，邮件发送 HTML，
+	// Slack 发送文本，Webhook 发送 JSON；定时任务写入 jobs 表，多实例部署时每个周期只发送一次。
+	// /admin/reports 下可预览报告或立即发送以验证接收方
+	reportsConfig := reports.DefaultConfig()
+	for _, schedule := range cfg.Reports.Schedules {
+		report := reports.ReportConfig{
+			Name:     schedule.Name,
+			Source:   schedule.Source,
+			Schedule: schedule.Schedule,
+			Period:   time.Duration(schedule.PeriodHours) * time.Hour,
+		}
+		for _, recipient := range schedule.Recipients {
+			report.Recipients = append(report.Recipients, reports.Recipient{
+				Channel: notify.Channel(recipient.Channel),
+				To:      recipient.To,
+				Format:  reports.Format(recipient.Format),
+			})
+		}
+		reportsConfig.Reports = append(reportsConfig.Reports, report)
+	}
+	reportScheduler := reports.NewScheduler(reportsConfig)
+	cacheSource := reports.NewCacheSource(nil, cacheAdmin.Stats)
+	cacheSource.SetCallerStats(cacheAdmin.CallerStats)
+	if cfg.Cache.MemoryAnalysisMinutes >= 0 {
+		cacheSource.SetMemory(func(ctx context.Context) (*cache.MemoryReport, error) {
+			return cacheAdmin.MemoryReport(ctx, false)
+		})
+	}
+	reportScheduler.AddSource(reports.SourceCache, cacheSource)
+	reportScheduler.AddSource(reports.SourceDatabase, reports.NewDatabaseSource(db.DB.DB, database.NewSQLPoolTuningStore(db)))
+	reportScheduler.AddSource(reports.SourceSecurity, reports.NewSecuritySource(securityEvents, 10))
+	reportScheduler.RegisterDriver(notify.NewSlackDriver(nil))
+	webhookConfig := notify.DefaultWebhookConfig()
+	webhookConfig.Secret = cfg.Reports.WebhookSecret
+	reportScheduler.RegisterDriver(notify.NewWebhookDriver(webhookConfig))
+	if cfg.Reports.SMTPHost != "" {
+		emailDriver, err := notify.NewEmailDriver(&notify.SMTPConfig{
+			Host:     cfg.Reports.SMTPHost,
+			Port:     cfg.Reports.SMTPPort,
+			Username: cfg.Reports.SMTPUsername,
+			Password: cfg.Reports.SMTPPassword,
+			From:     cfg.Reports.SMTPFrom,
+		})
+		if err != nil {
+			log.Fatalf("Invalid report smtp config: %v", err)
+		}
+		reportScheduler.RegisterDriver(emailDriver)
+	}
+	// 任务队列在模块初始化后启动，模块可在 Init 中注册任务类型与定时计划
+	jobManager := jobs.NewManager(jobs.NewStore(jobsDB), jobs.DefaultConfig())
+	if len(reportsConfig.Reports) > 0 {
+		if err := reportScheduler.Register(jobManager); err != nil {
+			log.Fatalf("Invalid report schedules: %v", err)
+		}
+	}
+	if sourceCheckConfig.Schedule != "off" {
+		jobManager.Register(cache.SourceCheckJobType, func(ctx context.Context, job *jobs.Job) error {
+			_, err := consistencyChecker.CheckSources(ctx)
+			return err
+		})
+		if err := jobManager.Schedule(cache.SourceCheckJobType, sourceCheckConfig.Schedule, cache.SourceCheckJobType, nil, jobs.PriorityLow); err != nil {
+			log.Fatalf("Invalid cache consistency schedule: %v", err)
+		}
+	}
+
+	// 4.7.6.1. 数据保留策略：按数据集定时删除或归档超过保留期限、超出行数上限的行，归档写入 retention_archive 表；
+	// 内置策略见 retention.DefaultPolicies，retention.policies 按 dataset 覆盖。/admin/retention 下可预演与立即执行
+	retentionConfig := retention.DefaultConfig()
+	if cfg.Retention.Schedule != "" {
+		retentionConfig.Schedule = cfg.Retention.Schedule
+	}
+	if cfg.Retention.BatchSize > 0 {
+		retentionConfig.BatchSize = cfg.Retention.BatchSize
+	}
+	if cfg.Retention.MaxBatches > 0 {
+		retentionConfig.MaxBatches = cfg.Retention.MaxBatches
+	}
+	retentionOverrides := make([]retention.Policy, 0, len(cfg.Retention.Policies))
+	for _, policy := range cfg.Retention.Policies {
+		retentionOverrides = append(retentionOverrides, retention.Policy{
+			Dataset:    policy.Dataset,
+			Table:      policy.Table,
+			TimeColumn: policy.TimeColumn,
+			Filter:     policy.Filter,
+			MaxAge:     time.Duration(policy.MaxAgeDays) * 24 * time.Hour,
+			MaxRows:    policy.MaxRows,
+			Action:     retention.Action(policy.Action),
+			Schedule:   policy.Schedule,
+			BatchSize:  policy.BatchSize,
+			Disabled:   policy.Disabled,
+		})
+	}
+	retentionEngine := retention.NewEngine(retention.NewSQLStore(jobsDB), retentionConfig)
+	for _, policy := range retention.Merge(retention.DefaultPolicies(), retentionOverrides) {
+		if err := retentionEngine.AddPolicy(policy); err != nil {
+			log.Fatalf("Invalid retention policy: %v", err)
+		}
+	}
+	if err := retentionEngine.Register(jobManager); err != nil {
+		log.Fatalf("Failed to schedule retention policies: %v", err)
+	}
+
+	// 库存预留，模块登记资源类型的数据源后统一释放过期预留与对账，同样在模块初始化后启动
+	inventoryManager := inventory.NewManager(redis, nil)
+
+	// 4.7.7. 领域事件：用户登录、资料修改、领券等事件发布到进程内总线，活动记录等模块异步订阅
+	domainEvents := domainevent.NewBus(nil)
+	// 领券等业务在事务中写入发件箱的领域事件与缓存失效，提交后由投递器发布，同样在模块订阅后启动
+	outboxRelay := outbox.NewRelay(db, outbox.TopicRouter{
+		domainevent.TopicCouponClaimed: domainevent.OutboxPublisher(domainEvents, outbox.NewDeduper(redis, 24*time.Hour)),
+		outbox.TopicCacheInvalidate:    outbox.CacheInvalidationPublisher(redisCache, tagInvalidation),
+	}, nil)
+
+	// 4.7.8. 用户通知：验证令牌等面向用户的消息写入投递表，由后台按渠道异步发送并重试；
+	// 短信服务商未接入前短信只记录日志，配置 notify.smtp_host 时投递邮件
+	notifyTemplates := notify.NewTemplates("zh-CN")
+	notifier := notify.NewDispatcher(db, redis, notifyTemplates, notify.NewSQLPreferenceStore(db), notify.NewSQLContactResolver(db), nil)
+	notifier.RegisterDriver(notify.NewSMSDriver(notify.LogSMSSender))
+	if cfg.Notify.SMTPHost != "" {
+		emailDriver, err := notify.NewEmailDriver(&notify.SMTPConfig{
+			Host:     cfg.Notify.SMTPHost,
+			Port:     cfg.Notify.SMTPPort,
+			Username: cfg.Notify.SMTPUsername,
+			Password: cfg.Notify.SMTPPassword,
+			From:     cfg.Notify.SMTPFrom,
+		})
+		if err != nil {
+			log.Fatalf("Invalid notify smtp config: %v", err)
+		}
+		notifier.RegisterDriver(emailDriver)
+	}
+
+	// 4.7.9. 敏感管理操作的双人审批：批量失效缓存、修改角色与授权等操作先生成审批单并通知审批人，
+	// 发起人以外的管理员批准后，发起人携带 X-Approval-ID 重新提交才会执行
+	var approvalManager *approvals.Manager
+	if !cfg.Admin.Approvals.Disabled {
+		approvalConfig := approvals.DefaultConfig()
+		if cfg.Admin.Approvals.RequiredApprovals > 0 {
+			approvalConfig.RequiredApprovals = cfg.Admin.Approvals.RequiredApprovals
+		}
+		if cfg.Admin.Approvals.ExpiryMinutes > 0 {
+			approvalConfig.TTL = time.Duration(cfg.Admin.Approvals.ExpiryMinutes) * time.Minute
+		}
+		if len(cfg.Admin.Approvals.Routes) > 0 {
+			approvalConfig.Routes = cfg.Admin.Approvals.Routes
+		}
+		approvalConfig.Approvers = cfg.Admin.Approvals.Approvers
+		approvalManager = approvals.NewManager(approvals.NewSQLStore(db), notifier, securityMonitor, approvalConfig)
+		if err := approvalManager.RegisterTemplates(notifyTemplates); err != nil {
+			log.Fatalf("Failed to register approval templates: %v", err)
+		}
+	}
+
+	// 4.8. gRPC 服务（可选）
+	var grpcServer *grpcserver.Server
+	if cfg.GRPC.Port != "" {
+		grpcServer = grpcserver.NewServer(cfg.GRPC)
+		usercrudv1.RegisterPermissionServiceServer(grpcServer.Registrar(), grpcserver.NewPermissionServer(rbac))
+	}
 
 	// 5. 初始化模块系统
 	moduleCtx := &registry.ModuleContext{
 		DB:     db,
 		Redis:  redis,
 		Router: router,
+		Health: healthRegistry,
+		GRPC:   grpcServer,
+
+		Background: backgroundCtx,
+		Lifecycle:  background,
+	}
+	moduleCtx.Provide(registry.PermissionChecker, rbac)
+	moduleCtx.Provide(registry.ResponseCache, responseCache)
+	moduleCtx.Provide(registry.CacheAdmin, cacheAdmin)
+	moduleCtx.Provide(registry.CacheWarmup, cacheWarmup)
+	moduleCtx.Provide(registry.CacheTTL, cacheTTLs)
+	moduleCtx.Provide(registry.CachePopularity, popularity)
+	moduleCtx.Provide(registry.CachePreload, preloader)
+	moduleCtx.Provide(registry.CacheGeo, geo)
+	moduleCtx.Provide(registry.CacheConsistency, consistencyChecker)
+	moduleCtx.Provide(registry.FeatureFlags, featureManager)
+	moduleCtx.Provide(registry.Experiments, experiments)
+	moduleCtx.Provide(registry.SecurityEvents, securityEvents)
+	moduleCtx.Provide(registry.IPReputation, ipReputation)
+	moduleCtx.Provide(registry.SecurityMonitor, securityMonitor)
+	moduleCtx.Provide(registry.SessionRevoker, sessionRevoker)
+	moduleCtx.Provide(registry.RoleQuotas, roleQuotas)
+	moduleCtx.Provide(registry.Impersonation, impersonations)
+	moduleCtx.Provide(registry.Reports, reportScheduler)
+	moduleCtx.Provide(registry.Retention, retentionEngine)
+	moduleCtx.Provide(registry.Jobs, jobManager)
+	moduleCtx.Provide(registry.Inventory, inventoryManager)
+	moduleCtx.Provide(registry.Payments, paymentGateway)
+	moduleCtx.Provide(registry.DomainEvents, domainEvents)
+	moduleCtx.Provide(registry.Notifier, notifier)
+	moduleCtx.Provide(registry.NotifyTemplates, notifyTemplates)
+	if profiler != nil {
+		moduleCtx.Provide(registry.Profiler, profiler)
+	}
+	if bloatAnalyzer != nil {
+		moduleCtx.Provide(registry.DBBloat, bloatAnalyzer)
+	}
+	if lockMonitor != nil {
+		moduleCtx.Provide(registry.DBLocks, lockMonitor)
+	}
+	if tokenKeys != nil {
+		moduleCtx.Provide(registry.TokenKeys, tokenKeys)
+	}
+	if faults != nil {
+		moduleCtx.Provide(registry.FaultInjector, faults)
+	}
+	if approvalManager != nil {
+		moduleCtx.Provide(registry.Approvals, approvalManager)
 	}
 
 	if err := registry.InitModules(moduleCtx); err != nil {
 		log.Fatalf("Failed to initialize modules: %v", err)
 	}
+	// 5.0. 权限版本落后的令牌按用户表中的当前角色重新解析，未登记时这类令牌被拒绝，需重新登录；
+	// 代登录令牌的角色同样取自用户表
+	if svc, ok := moduleCtx.Lookup(registry.UserRoles); ok {
+		if roles, ok := svc.(utils.RoleSource); ok {
+			utils.SetRoleSource(roles)
+			impersonations.SetRoleSource(roles)
+		}
+	}
+	background.Go("jobs", func() { jobManager.Run(backgroundCtx) })
+	background.Go("inventory", func() { inventoryManager.Run(backgroundCtx) })
+	background.Go("notify", func() { notifier.Run(backgroundCtx) })
+	background.Go("outbox_relay", func() { outboxRelay.Run(backgroundCtx) })
+
+	// 5.1. 各模块在 init 中注册的权限写入权限表，同时载入其他服务注册的权限；失败时仅使用本实例注册的权限
+	if err := security.SyncPermissions(context.Background(), security.NewSQLPermissionStore(db)); err != nil {
+		log.Printf("Failed to sync permissions: %v", err)
+	}
+
+	// 5.2. 模块登记预热任务与加载器后开始预热：配置 preload.on_start 时执行该任务组，否则预热上次运行时的热门键
+	if cfg.Preload.OnStart != "" {
+		if _, err := preloader.Start(cfg.Preload.OnStart, ""); err != nil {
+			log.Printf("Failed to start cache preload: %v", err)
+		}
+	} else {
+		background.Go("cache_warmup_on_start", func() {
+			if result, err := cacheWarmup.WarmupPopular(backgroundCtx, ""); err != nil {
+				log.Printf("Failed to warm up popular cache keys: %v", err)
+			} else {
+				log.Printf("Warmed up %d/%d popular cache keys", result.SuccessKeys, result.TotalKeys)
+			}
+		})
+	}
 
 	// 6. 启动服务器
 	go func() {
@@ -62,6 +752,14 @@ func main() {
 		}
 	}()
 
+	if grpcServer != nil {
+		go func() {
+			if err := grpcServer.ListenAndServe(":" + cfg.GRPC.Port); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
+
 	// 7. 优雅关闭
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -72,8 +770,23 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if grpcServer != nil {
+		grpcServer.Stop(ctx)
+	}
+
+	// 写完已入队的实验曝光与领域事件；总线关闭后投递器发布失败的事件留在发件箱，下次启动时重投
+	experiments.Close()
+	domainEvents.Close()
+	// 写完已记录的安全事件，停止分区维护
+	securityMonitor.Close()
+	stopBackground()
+	background.Closed()
+	// 后台任务应在 ctx 取消后退出，超时仍未退出的记为泄漏
+	for _, leak := range lifecycle.Default().WaitClosed(ctx) {
+		log.Printf("Goroutine %s/%s still running after shutdown", leak.Component, leak.Name)
+	}
+
 	// 这里可以添加数据库连接池关闭等清理工作
-	_ = ctx
 
 	log.Println("Server exited")
 }
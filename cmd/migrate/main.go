@@ -1,8 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/database"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -10,18 +20,64 @@ import (
 )
 
 func main() {
+	var (
+		dir           = flag.String("dir", "migrations", "Migrations directory")
+		preflightOnly = flag.Bool("preflight", false, "Only analyze pending migrations, do not apply them")
+		force         = flag.Bool("force", false, "Apply migrations flagged as dangerous in production")
+		largeTable    = flag.Int64("large-table-rows", database.DefaultPreflightConfig().LargeTableRows, "Row estimate at which table rewrites and scans become dangerous")
+		grants        = flag.Bool("grants", false, "Write a migration granting least privileges to the roles in database.roles, then exit")
+		rls           = flag.Bool("rls", false, "Write a migration enabling row level security policies on tenant and user owned tables, then exit")
+	)
+	flag.Parse()
+
 	config.LoadConfig()
 	cfg := config.GlobalConfig.Database
-	dsn := "postgres://" + cfg.User + ":" + cfg.Password + "@" + cfg.Host + ":" + cfg.Port + "/" + cfg.DBName + "?sslmode=" + cfg.SSLMode
 
+	// 授权迁移：按 database.roles 生成下一版本的迁移文件，审阅后随普通迁移执行
+	if *grants {
+		files, err := writeGrants(*dir, cfg)
+		if err != nil {
+			log.Fatal("Failed to generate grants:", err)
+		}
+		log.Printf("Wrote %s", strings.Join(files, ", "))
+		return
+	}
+
+	// 行级安全迁移：为带租户列与所有者列的表生成策略，配合 database.row_security 使用
+	if *rls {
+		policies, err := database.GenerateRowSecurity(database.DefaultRowSecurityPolicies)
+		if err != nil {
+			log.Fatal("Failed to generate row security policies:", err)
+		}
+		files, err := writeMigration(*dir, "enable_row_security", policies.Up, policies.Down)
+		if err != nil {
+			log.Fatal("Failed to write row security policies:", err)
+		}
+		log.Printf("Wrote %s", strings.Join(files, ", "))
+		return
+	}
+
+	// 以 migrate 组件的角色连接，该角色应为各表的所有者
 	m, err := migrate.New(
-		"file://migrations",
-		dsn,
+		"file://"+*dir,
+		database.MigrationURL(cfg),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// 预检：分析待执行的迁移，生产环境中包含危险语句时需要 --force
+	report, err := preflight(m, *dir, &database.PreflightConfig{LargeTableRows: *largeTable})
+	if err != nil {
+		log.Fatal("Preflight failed:", err)
+	}
+	if *preflightOnly {
+		return
+	}
+	if report.Dangerous() && config.GlobalConfig.App.Env == "production" && !*force {
+		log.Fatal("Pending migrations contain dangerous operations; review the preflight report and rerun with --force")
+	}
+
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
 		// 如果数据库处于 dirty 状态，尝试强制修复到上一版本，然后重试
 		if err.Error() == "Dirty database version 1. Fix and force version." {
@@ -40,3 +96,81 @@ func main() {
 
 	log.Println("Migration successful")
 }
+
+// preflight 分析当前版本之后的迁移并打印报告
+func preflight(m *migrate.Migrate, dir string, preflightConfig *database.PreflightConfig) (*database.PreflightReport, error) {
+	current, _, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	migrations, err := database.LoadPendingMigrations(dir, current)
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 {
+		log.Println("Preflight: no pending migrations")
+		return &database.PreflightReport{}, nil
+	}
+
+	db := database.InitDatabaseFor(database.ComponentMigrate)
+	defer db.Close()
+	report, err := database.RunMigrationPreflight(context.Background(), migrations, db, preflightConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Preflight: %d pending migrations, %d findings", len(migrations), len(report.Findings))
+	for _, finding := range report.Findings {
+		rows := "unknown"
+		if finding.EstimatedRows >= 0 {
+			rows = fmt.Sprintf("~%d", finding.EstimatedRows)
+		}
+		log.Printf("  [%s] %s: %s (table %s, %s lock, rows %s)",
+			finding.Risk, finding.File, finding.Message, finding.Table, finding.Lock, rows)
+	}
+	return report, nil
+}
+
+// migrationVersion 迁移文件名开头的版本号
+var migrationVersion = regexp.MustCompile(`^(\d+)_`)
+
+// writeGrants 以 migrate 组件的角色为所有者生成授权迁移，写入目录中下一个版本号，返回写入的文件
+func writeGrants(dir string, cfg config.DatabaseConfig) ([]string, error) {
+	owner, _ := database.RoleOf(cfg, database.ComponentMigrate)
+	roles := make(map[database.Component]string)
+	for _, component := range database.Components() {
+		if role, ok := cfg.Roles[string(component)]; ok {
+			roles[component] = role.User
+		}
+	}
+	grants, err := database.GenerateRoleGrants(owner, roles)
+	if err != nil {
+		return nil, err
+	}
+	return writeMigration(dir, "grant_component_roles", grants.Up, grants.Down)
+}
+
+// writeMigration 将生成的迁移写入目录中下一个版本号，返回写入的文件
+func writeMigration(dir, name, up, down string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	next := 1
+	for _, entry := range entries {
+		if match := migrationVersion.FindStringSubmatch(entry.Name()); match != nil {
+			if version, err := strconv.Atoi(match[1]); err == nil && version >= next {
+				next = version + 1
+			}
+		}
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%06d_%s", next, name))
+	files := []string{prefix + ".up.sql", prefix + ".down.sql"}
+	for i, content := range []string{up, down} {
+		if err := os.WriteFile(files[i], []byte(content), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", files[i], err)
+		}
+	}
+	return files, nil
+}
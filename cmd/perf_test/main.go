@@ -13,7 +13,7 @@ import (
 func main() {
 	var (
 		baseURL  = flag.String("url", "http://localhost:8080", "Base URL for testing")
-		testType = flag.String("type", "all", "Test type: api, load, stress, benchmark, response, all")
+		testType = flag.String("type", "all", "Test type: api, load, stress, benchmark, response, contract, all")
 		help     = flag.Bool("help", false, "Show help")
 	)
 	flag.Parse()
@@ -47,6 +47,10 @@ func main() {
 		runBenchmarkTests(apiTest)
 	case "response":
 		runResponseTimeTests(apiTest)
+	case "contract":
+		if apiTest.RunContractTests() > 0 {
+			os.Exit(1)
+		}
 	case "all":
 		runAllTests(apiTest)
 	default:
@@ -62,7 +66,7 @@ func showHelp() {
 	fmt.Println("")
 	fmt.Println("选项:")
 	fmt.Println("  -url string        测试服务器地址 (默认: http://localhost:8080)")
-	fmt.Println("  -type string       测试类型 (api|load|stress|benchmark|response|all) (默认: all)")
+	fmt.Println("  -type string       测试类型 (api|load|stress|benchmark|response|contract|all) (默认: all)")
 	fmt.Println("  -concurrency int   并发数 (默认: 50)")
 	fmt.Println("  -duration duration 测试时长 (默认: 30s)")
 	fmt.Println("  -help              显示帮助信息")
@@ -73,6 +77,7 @@ func showHelp() {
 	fmt.Println("  stress     - 压力测试")
 	fmt.Println("  benchmark  - 基准测试")
 	fmt.Println("  response   - 响应时间测试")
+	fmt.Println("  contract   - 按 /openapi.json 校验接口响应")
 	fmt.Println("  all        - 运行所有测试")
 	fmt.Println("")
 	fmt.Println("示例:")
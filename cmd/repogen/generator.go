@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// annotationPrefix 模型注解，写在类型声明的注释中
+const annotationPrefix = "//repogen:"
+
+// field 带 db 标签的模型字段
+type field struct {
+	Name   string
+	Column string
+	Type   string
+}
+
+// modelSpec 从模型声明与注解解析出的生成参数
+type modelSpec struct {
+	Name       string
+	Package    string
+	Dir        string
+	Table      string
+	Fields     []field
+	Key        field
+	Shard      *field
+	SoftDelete bool
+	CreatedAt  bool // 有 time.Time 类型的 created_at，插入时为零值则填当前时间
+	UpdatedAt  bool // 有 time.Time 类型的 updated_at，插入与更新时填当前时间
+}
+
+// parseModel 解析 src 中名为 typeName 的结构体及其 //repogen: 注解
+func parseModel(src, typeName string) (*modelSpec, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, src, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", src, err)
+	}
+	dir, err := filepath.Abs(filepath.Dir(src))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, s := range gen.Specs {
+			typeSpec := s.(*ast.TypeSpec)
+			if typeSpec.Name.Name != typeName {
+				continue
+			}
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a struct", typeName)
+			}
+			doc := typeSpec.Doc
+			if doc == nil {
+				doc = gen.Doc
+			}
+			options, err := parseAnnotation(doc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", typeName, err)
+			}
+			spec := &modelSpec{Name: typeName, Package: file.Name.Name, Dir: dir}
+			if err := spec.load(structType, options); err != nil {
+				return nil, fmt.Errorf("%s: %w", typeName, err)
+			}
+			return spec, nil
+		}
+	}
+	return nil, fmt.Errorf("type %s not found in %s", typeName, src)
+}
+
+// parseAnnotation 解析 //repogen:table=users key=id shard=tenant_id
+func parseAnnotation(doc *ast.CommentGroup) (map[string]string, error) {
+	if doc != nil {
+		for _, comment := range doc.List {
+			if !strings.HasPrefix(comment.Text, annotationPrefix) {
+				continue
+			}
+			options := make(map[string]string)
+			for _, option := range strings.Fields(strings.TrimPrefix(comment.Text, annotationPrefix)) {
+				name, value, _ := strings.Cut(option, "=")
+				options[name] = value
+			}
+			return options, nil
+		}
+	}
+	return nil, fmt.Errorf("missing %s annotation", annotationPrefix)
+}
+
+// load 收集字段并校验注解
+func (s *modelSpec) load(structType *ast.StructType, options map[string]string) error {
+	s.Table = options["table"]
+	if s.Table == "" {
+		return fmt.Errorf("annotation requires table=")
+	}
+	keyColumn := options["key"]
+	if keyColumn == "" {
+		keyColumn = "id"
+	}
+
+	for _, f := range structType.Fields.List {
+		if len(f.Names) == 0 {
+			return fmt.Errorf("embedded fields are not supported, declare columns directly")
+		}
+		if f.Tag == nil || !f.Names[0].IsExported() {
+			continue
+		}
+		column := reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("db")
+		if column == "" || column == "-" {
+			continue
+		}
+		fieldType := exprString(f.Type)
+		for _, name := range f.Names {
+			s.Fields = append(s.Fields, field{Name: name.Name, Column: column, Type: fieldType})
+		}
+	}
+
+	key, ok := s.field(keyColumn)
+	if !ok {
+		return fmt.Errorf("key column %s not found", keyColumn)
+	}
+	if key.Type != "string" && key.Type != "int64" && key.Type != "int" {
+		return fmt.Errorf("key column %s must be string, int or int64, got %s", keyColumn, key.Type)
+	}
+	s.Key = key
+	if shardColumn := options["shard"]; shardColumn != "" {
+		shard, ok := s.field(shardColumn)
+		if !ok {
+			return fmt.Errorf("shard column %s not found", shardColumn)
+		}
+		if shard.Type != "string" {
+			return fmt.Errorf("shard column %s must be a string", shardColumn)
+		}
+		s.Shard = &shard
+	}
+	_, s.SoftDelete = s.field("deleted_at")
+	if f, ok := s.field("created_at"); ok && f.Type == "time.Time" {
+		s.CreatedAt = true
+	}
+	if f, ok := s.field("updated_at"); ok && f.Type == "time.Time" {
+		s.UpdatedAt = true
+	}
+	return nil
+}
+
+func (s *modelSpec) field(column string) (field, bool) {
+	for _, f := range s.Fields {
+		if f.Column == column {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// exprString 字段类型的源码形式
+func exprString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + exprString(t.X)
+	case *ast.SelectorExpr:
+		return exprString(t.X) + "." + t.Sel.Name
+	case *ast.ArrayType:
+		return "[]" + exprString(t.Elt)
+	case *ast.MapType:
+		return "map[" + exprString(t.Key) + "]" + exprString(t.Value)
+	default:
+		return fmt.Sprintf("%T", expr)
+	}
+}
+
+// defaultOutput 默认输出到模型文件旁的 <type>_repo_gen.go
+func defaultOutput(src, typeName string) string {
+	return filepath.Join(filepath.Dir(src), snakeCase(typeName)+"_repo_gen.go")
+}
+
+// templateData 模板参数
+type templateData struct {
+	*modelSpec
+	OutPackage  string
+	ImportPath  string // 模型包的导入路径，与输出在同一包时为空
+	Qual        string // 模型类型的限定前缀，如 "model."
+	Repo        string // 生成的仓库类型名
+	Prefix      string // 生成的常量前缀，如 user
+	Component   string // 指标中的组件名
+	KeyParam    string
+	ShardParam  string
+	ColumnList  string
+	InsertSQL   string
+	UpdateSQL   string
+	ActiveWhere string // 读取与更新时追加的未删除条件
+}
+
+// generate 渲染仓库代码
+func generate(spec *modelSpec, out, pkg string) ([]byte, error) {
+	outDir, err := filepath.Abs(filepath.Dir(out))
+	if err != nil {
+		return nil, err
+	}
+	if pkg == "" {
+		pkg = packageName(outDir)
+	}
+
+	data := templateData{
+		modelSpec:  spec,
+		OutPackage: pkg,
+		Repo:       spec.Name + "Repo",
+		Prefix:     lowerCamel(spec.Name),
+		Component:  snakeCase(spec.Name) + "_repo",
+		KeyParam:   lowerCamel(spec.Key.Name),
+	}
+	if outDir != spec.Dir {
+		if data.ImportPath, err = importPath(spec.Dir); err != nil {
+			return nil, err
+		}
+		data.Qual = spec.Package + "."
+	}
+	if spec.Shard != nil {
+		data.ShardParam = lowerCamel(spec.Shard.Name)
+	}
+	if spec.SoftDelete {
+		data.ActiveWhere = " AND deleted_at IS NULL"
+	}
+
+	columns := make([]string, len(spec.Fields))
+	named := make([]string, len(spec.Fields))
+	var sets []string
+	for i, f := range spec.Fields {
+		columns[i] = f.Column
+		named[i] = ":" + f.Column
+		if f.Column == spec.Key.Column || f.Column == "created_at" || f.Column == "deleted_at" ||
+			(spec.Shard != nil && f.Column == spec.Shard.Column) {
+			continue
+		}
+		sets = append(sets, f.Column+" = :"+f.Column)
+	}
+	data.ColumnList = strings.Join(columns, ", ")
+	data.InsertSQL = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", spec.Table, data.ColumnList, strings.Join(named, ", "))
+	data.UpdateSQL = fmt.Sprintf("UPDATE %s SET %s WHERE %s = :%s%s", spec.Table, strings.Join(sets, ", "),
+		spec.Key.Column, spec.Key.Column, data.ActiveWhere)
+
+	var buf bytes.Buffer
+	if err := repoTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render repository: %w", err)
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w\n%s", err, buf.String())
+	}
+	return code, nil
+}
+
+// packageName 目录中已有 Go 文件的包名，没有时使用目录名
+func packageName(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, path := range matches {
+		if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_gen.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	return filepath.Base(dir)
+}
+
+// importPath 根据所在模块的 go.mod 计算目录的导入路径
+func importPath(dir string) (string, error) {
+	for root := dir; ; root = filepath.Dir(root) {
+		content, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(content), "\n") {
+				if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					rel, err := filepath.Rel(root, dir)
+					if err != nil {
+						return "", err
+					}
+					return strings.TrimSpace(module) + "/" + filepath.ToSlash(rel), nil
+				}
+			}
+			return "", fmt.Errorf("no module line in %s", filepath.Join(root, "go.mod"))
+		}
+		if filepath.Dir(root) == root {
+			return "", fmt.Errorf("go.mod not found above %s", dir)
+		}
+	}
+}
+
+// snakeCase UserCoupon -> user_coupon
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lowerCamel ID -> id，TenantID -> tenantID，URLPath -> urlPath
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate_ShardedModel(t *testing.T) {
+	spec, err := parseModel("testdata/event.go", "AuditEvent")
+	require.NoError(t, err)
+	assert.Equal(t, "audit_events", spec.Table)
+	assert.Equal(t, "EventID", spec.Key.Name)
+	require.NotNil(t, spec.Shard)
+	assert.False(t, spec.SoftDelete)
+	assert.Len(t, spec.Fields, 4, "unexported fields are skipped")
+
+	code, err := generate(spec, filepath.Join(t.TempDir(), "audit_event_repo_gen.go"), "repository")
+	require.NoError(t, err)
+	src := string(code)
+	assert.Contains(t, src, `"user_crud_jwt/cmd/repogen/testdata"`)
+	assert.Contains(t, src, "func (r *AuditEventRepo) Get(ctx context.Context, tenantID string, eventID int64) (m *testdata.AuditEvent, err error)")
+	assert.Contains(t, src, "r.router.Shard(tenantID).GetContext")
+	assert.Contains(t, src, "db := r.router.Shard(m.TenantID)")
+	assert.Contains(t, src, `"UPDATE audit_events SET action = :action WHERE event_id = :event_id"`)
+	assert.Contains(t, src, `"DELETE FROM audit_events WHERE event_id = $1"`)
+	assert.Contains(t, src, `RecordDBQuery("audit_event_repo"`)
+}
+
+func TestGenerate_RejectsMissingAnnotation(t *testing.T) {
+	_, err := parseModel("generator.go", "modelSpec")
+	assert.ErrorContains(t, err, "missing //repogen: annotation")
+}
+
+func TestGeneratedUserRepoIsUpToDate(t *testing.T) {
+	const out = "../../internal/domain/user/repository/user_repo_gen.go"
+	spec, err := parseModel("../../internal/domain/user/model/user.go", "User")
+	require.NoError(t, err)
+	code, err := generate(spec, out, "")
+	require.NoError(t, err)
+
+	existing, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, string(existing), string(code), "run go generate ./internal/domain/user/repository")
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "user_coupon", snakeCase("UserCoupon"))
+	assert.Equal(t, "ip_rule", snakeCase("IPRule"))
+	assert.Equal(t, "id", lowerCamel("ID"))
+	assert.Equal(t, "tenantID", lowerCamel("TenantID"))
+	assert.Equal(t, "urlPath", lowerCamel("URLPath"))
+}
@@ -0,0 +1,47 @@
+// repogen 根据模型上的注解生成类型化仓库：读取走只读副本或分片，写入走主库或分片，带批量方法与指标。
+//
+// 模型声明（字段需带 db 标签，不支持嵌入结构体）：
+//
+//	//repogen:table=users key=id shard=tenant_id
+//	type User struct { ... }
+//
+// 在仓库包中生成：
+//
+//	//go:generate go run ../../../../cmd/repogen -src ../model/user.go -type User -out user_repo_gen.go
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	var (
+		src      = flag.String("src", "", "Go file declaring the model")
+		typeName = flag.String("type", "", "Model type name")
+		out      = flag.String("out", "", "Output file (default <type>_repo_gen.go next to -src)")
+		pkg      = flag.String("pkg", "", "Output package name (default: package of existing files in the output dir)")
+	)
+	flag.Parse()
+	if *src == "" || *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	model, err := parseModel(*src, *typeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		*out = defaultOutput(*src, model.Name)
+	}
+	code, err := generate(model, *out, *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Generated %s", *out)
+}
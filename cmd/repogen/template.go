@@ -0,0 +1,168 @@
+package main
+
+import "text/template"
+
+var repoTemplate = template.Must(template.New("repo").Parse(`// Code generated by repogen. DO NOT EDIT.
+
+package {{.OutPackage}}
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+{{- if .ImportPath}}
+	"{{.ImportPath}}"
+{{- end}}
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	{{.Prefix}}Columns = "{{.ColumnList}}"
+	{{.Prefix}}Insert  = "{{.InsertSQL}}"
+	{{.Prefix}}Update  = "{{.UpdateSQL}}"
+)
+
+// {{.Repo}} {{.Table}} 表的类型化仓库。
+{{- if .Shard}}
+// 按 {{.Shard.Column}} 分片：读写都路由到分片键所在的分片，{{.Shard.Column}} 写入后不可修改
+{{- else}}
+// 读取轮询只读副本（需要读到刚写入的数据时用 database.WithPrimary(ctx)，限制复制延迟用 database.WithMaxStaleness(ctx, d)），写入走主库
+{{- end}}
+type {{.Repo}} struct {
+	router           *database.Router
+	metricsCollector *metrics.MetricsCollector
+}
+
+// New{{.Repo}} 创建仓库
+func New{{.Repo}}(router *database.Router) *{{.Repo}} {
+	return &{{.Repo}}{router: router, metricsCollector: metrics.GetGlobalCollector()}
+}
+
+{{- define "reader"}}{{if .Shard}}r.router.Shard({{.ShardParam}}){{else}}r.router.Reader(ctx){{end}}{{end}}
+{{- define "shardParam"}}{{if .Shard}}{{.ShardParam}} string, {{end}}{{end}}
+{{- define "writer"}}{{if .Shard}}r.router.Shard(m.{{.Shard.Name}}){{else}}r.router.Primary(){{end}}{{end}}
+
+// Get 按 {{.Key.Column}} 读取{{if .SoftDelete}}未删除的行{{end}}，不存在时返回 nil
+func (r *{{.Repo}}) Get(ctx context.Context, {{template "shardParam" .}}{{.KeyParam}} {{.Key.Type}}) (m *{{.Qual}}{{.Name}}, err error) {
+	defer r.observe("get", time.Now(), &err)
+	var row {{.Qual}}{{.Name}}
+	query := "SELECT " + {{.Prefix}}Columns + " FROM {{.Table}} WHERE {{.Key.Column}} = $1{{.ActiveWhere}}"
+	if err = {{template "reader" .}}.GetContext(ctx, &row, query, {{.KeyParam}}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get {{.Table}}: %w", err)
+	}
+	return &row, nil
+}
+
+// GetMany 按 {{.Key.Column}} 批量读取{{if .SoftDelete}}未删除的行{{end}}，不存在的跳过，顺序不保证
+func (r *{{.Repo}}) GetMany(ctx context.Context, {{template "shardParam" .}}{{.KeyParam}}s []{{.Key.Type}}) (ms []*{{.Qual}}{{.Name}}, err error) {
+	if len({{.KeyParam}}s) == 0 {
+		return nil, nil
+	}
+	defer r.observe("get_many", time.Now(), &err)
+	query := "SELECT " + {{.Prefix}}Columns + " FROM {{.Table}} WHERE {{.Key.Column}} = ANY($1){{.ActiveWhere}}"
+	if err = {{template "reader" .}}.SelectContext(ctx, &ms, query, pq.Array({{.KeyParam}}s)); err != nil {
+		return nil, fmt.Errorf("failed to get {{.Table}}: %w", err)
+	}
+	return ms, nil
+}
+
+// Insert 插入一行
+func (r *{{.Repo}}) Insert(ctx context.Context, m *{{.Qual}}{{.Name}}) (err error) {
+	defer r.observe("insert", time.Now(), &err)
+	stamp{{.Repo}}Insert(m, time.Now())
+	if _, err = {{template "writer" .}}.NamedExec(ctx, {{.Prefix}}Insert, m); err != nil {
+		return fmt.Errorf("failed to insert {{.Table}}: %w", err)
+	}
+	return nil
+}
+
+// InsertMany 批量插入。{{if .Shard}}同一分片的行在一个事务中写入，不同分片之间不保证原子性{{else}}所有行在一个事务中写入{{end}}
+func (r *{{.Repo}}) InsertMany(ctx context.Context, ms []*{{.Qual}}{{.Name}}) (err error) {
+	if len(ms) == 0 {
+		return nil
+	}
+	defer r.observe("insert_many", time.Now(), &err)
+	now := time.Now()
+	groups := make(map[*database.DB][]*{{.Qual}}{{.Name}})
+	for _, m := range ms {
+		stamp{{.Repo}}Insert(m, now)
+		db := {{template "writer" .}}
+		groups[db] = append(groups[db], m)
+	}
+	for db, group := range groups {
+		err = db.RunInTxWithRetry(ctx, "{{.Component}}_insert_many", nil, func(tx *sqlx.Tx) error {
+			for _, m := range group {
+				if _, err := tx.NamedExecContext(ctx, {{.Prefix}}Insert, m); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert {{.Table}}: %w", err)
+		}
+	}
+	return nil
+}
+
+// Update 按 {{.Key.Column}} 更新{{if .SoftDelete}}未删除的行{{end}}，{{.Key.Column}}{{if .Shard}}、{{.Shard.Column}}{{end}} 与 created_at 不会修改；行不存在时返回 sql.ErrNoRows
+func (r *{{.Repo}}) Update(ctx context.Context, m *{{.Qual}}{{.Name}}) (err error) {
+	defer r.observe("update", time.Now(), &err)
+{{- if .UpdatedAt}}
+	m.UpdatedAt = time.Now()
+{{- end}}
+	result, err := {{template "writer" .}}.NamedExec(ctx, {{.Prefix}}Update, m)
+	if err != nil {
+		return fmt.Errorf("failed to update {{.Table}}: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("failed to update {{.Table}} %v: %w", m.{{.Key.Name}}, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Delete {{if .SoftDelete}}软删除{{else}}删除{{end}}一行，行不存在时不报错
+func (r *{{.Repo}}) Delete(ctx context.Context, {{template "shardParam" .}}{{.KeyParam}} {{.Key.Type}}) (err error) {
+	defer r.observe("delete", time.Now(), &err)
+{{- if .Shard}}
+	db := r.router.Shard({{.ShardParam}})
+{{- else}}
+	db := r.router.Primary()
+{{- end}}
+{{- if .SoftDelete}}
+	_, err = db.ExecContext(ctx, "UPDATE {{.Table}} SET deleted_at = $1{{if .UpdatedAt}}, updated_at = $1{{end}} WHERE {{.Key.Column}} = $2 AND deleted_at IS NULL", time.Now(), {{.KeyParam}})
+{{- else}}
+	_, err = db.ExecContext(ctx, "DELETE FROM {{.Table}} WHERE {{.Key.Column}} = $1", {{.KeyParam}})
+{{- end}}
+	if err != nil {
+		return fmt.Errorf("failed to delete {{.Table}}: %w", err)
+	}
+	return nil
+}
+
+// observe 记录方法耗时与结果
+func (r *{{.Repo}}) observe(operation string, start time.Time, err *error) {
+	r.metricsCollector.RecordDBQuery("{{.Component}}", operation, time.Since(start), *err == nil)
+}
+
+// stamp{{.Repo}}Insert 填充插入时的时间戳
+func stamp{{.Repo}}Insert(m *{{.Qual}}{{.Name}}, now time.Time) {
+{{- if .CreatedAt}}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+{{- end}}
+{{- if .UpdatedAt}}
+	m.UpdatedAt = now
+{{- end}}
+}
+`))
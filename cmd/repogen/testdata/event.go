@@ -0,0 +1,14 @@
+package testdata
+
+import "time"
+
+// AuditEvent 按租户分片的审计事件
+//
+//repogen:table=audit_events key=event_id shard=tenant_id
+type AuditEvent struct {
+	EventID   int64     `db:"event_id"`
+	TenantID  string    `db:"tenant_id"`
+	Action    string    `db:"action"`
+	CreatedAt time.Time `db:"created_at"`
+	note      string
+}
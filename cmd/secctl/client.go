@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 与服务端 middleware.APIKeyHeader、security.AuditReasonHeader、approvals.ApprovalHeader 一致
+const (
+	apiKeyHeader      = "X-API-Key"
+	auditReasonHeader = "X-Audit-Reason"
+	approvalHeader    = "X-Approval-ID"
+)
+
+// client 调用 /admin 下的安全管理接口
+type client struct {
+	baseURL  string
+	apiKey   string
+	tenant   string
+	reason   string
+	approval string // 执行已批准的操作时携带的审批单 ID
+	http     *http.Client
+}
+
+// apiError 服务端的统一错误响应
+type apiError struct {
+	Status    int    `json:"-"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, e.Message)
+	if e.Code != 0 {
+		msg += fmt.Sprintf(" (code %d)", e.Code)
+	}
+	if e.RequestID != "" {
+		msg += ", request id " + e.RequestID
+	}
+	return msg
+}
+
+// approvalRequired 操作需要审批，服务端已创建审批单（202）
+type approvalRequired struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (e *approvalRequired) Error() string {
+	return fmt.Sprintf("approval %d required: ask another administrator to approve it, then rerun with -approval %d before %s",
+		e.ID, e.ID, e.ExpiresAt.Local().Format(time.RFC3339))
+}
+
+// do 发送请求并返回响应体；非 2xx 时返回 *apiError
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body interface{}) ([]byte, error) {
+	target := strings.TrimSuffix(c.baseURL, "/") + "/admin" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(apiKeyHeader, c.apiKey)
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	if c.approval != "" {
+		req.Header.Set(approvalHeader, c.approval)
+	}
+	if c.reason != "" {
+		req.Header.Set(auditReasonHeader, c.reason)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s %s: %w", method, target, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		// 需要审批的操作不会执行，返回审批单
+		pending := &approvalRequired{}
+		if json.Unmarshal(data, pending) == nil && pending.ID != 0 && pending.Status == "pending" {
+			return nil, pending
+		}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &apiError{Status: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			// 认证中间件等处的错误不是统一格式
+			var plain struct {
+				Error string `json:"error"`
+			}
+			_ = json.Unmarshal(data, &plain)
+			apiErr.Message = plain.Error
+			if apiErr.Message == "" {
+				apiErr.Message = http.StatusText(resp.StatusCode)
+			}
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
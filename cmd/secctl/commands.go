@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"user_crud_jwt/pkg/security"
+)
+
+// eventQuery 事件查询条件与分页选项
+type eventQuery struct {
+	eventType, level, user, ip *string
+	page, size                 *int
+	window                     *window
+}
+
+func addEventFlags(flags *flag.FlagSet, withType bool) *eventQuery {
+	q := &eventQuery{
+		user:   flags.String("user", "", "Only events of this user id"),
+		ip:     flags.String("ip", "", "Only events from this IP"),
+		level:  flags.String("level", "", "Only events of this level: info, warning, error or critical"),
+		page:   flags.Int("page", 1, "Page number"),
+		size:   flags.Int("size", 50, "Events per page, at most 200"),
+		window: addWindowFlags(flags),
+	}
+	if withType {
+		q.eventType = flags.String("type", "", "Only events of this type, e.g. login, admin_action")
+	}
+	return q
+}
+
+func (q *eventQuery) values() (url.Values, error) {
+	query, err := q.window.values()
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range map[string]*string{"user_id": q.user, "ip": q.ip, "level": q.level, "type": q.eventType} {
+		if value != nil && *value != "" {
+			query.Set(name, *value)
+		}
+	}
+	query.Set("page", strconv.Itoa(*q.page))
+	query.Set("page_size", strconv.Itoa(*q.size))
+	return query, nil
+}
+
+// eventPage 事件查询响应
+type eventPage struct {
+	Events []security.SecurityEvent `json:"events"`
+	Total  int64                    `json:"total"`
+	Page   int                      `json:"page"`
+}
+
+func runEvents(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ContinueOnError)
+	q := addEventFlags(flags, true)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("events takes no positional arguments")
+	}
+	page, body, err := a.searchEvents(ctx, q)
+	if err != nil || a.output == "json" {
+		return a.printRawOr(body, err)
+	}
+
+	w := a.table("TIME", "TYPE", "LEVEL", "USER", "IP", "STATUS", "REQUEST", "MESSAGE")
+	for _, event := range page.Events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", event.Timestamp.Local().Format(time.DateTime),
+			event.Type, event.Level, orDash(event.UserID), orDash(event.IP), formatStatus(event.Status),
+			orDash(strings.TrimSpace(event.Method+" "+event.Path)), event.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return a.printPageFooter(page)
+}
+
+func runAudit(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	q := addEventFlags(flags, false)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("audit takes no positional arguments")
+	}
+	eventType := string(security.EventAdminAction)
+	q.eventType = &eventType
+	page, body, err := a.searchEvents(ctx, q)
+	if err != nil || a.output == "json" {
+		return a.printRawOr(body, err)
+	}
+
+	w := a.table("TIME", "ACTOR", "ACTION", "TARGET", "STATUS", "REASON")
+	for _, event := range page.Events {
+		route, _ := event.Details["route"].(string)
+		reason, _ := event.Details["reason"].(string)
+		var target []string
+		if params, ok := event.Details["params"].(map[string]interface{}); ok {
+			for key, value := range params {
+				target = append(target, fmt.Sprintf("%s=%v", key, value))
+			}
+			sort.Strings(target)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s %s\t%s\t%s\t%s\n", event.Timestamp.Local().Format(time.DateTime),
+			orDash(event.UserID), event.Method, route, orDash(strings.Join(target, " ")), formatStatus(event.Status), orDash(reason))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return a.printPageFooter(page)
+}
+
+func runReport(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	win := addWindowFlags(flags)
+	top := flags.Int("top", 10, "Number of top IPs and users, at most 100")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("report takes no positional arguments")
+	}
+	query, err := win.values()
+	if err != nil {
+		return err
+	}
+	query.Set("top", strconv.Itoa(*top))
+
+	body, err := a.client.do(ctx, http.MethodGet, "/security/events/summary", query, nil)
+	if err != nil || a.output == "json" {
+		return a.printRawOr(body, err)
+	}
+	var summary security.SecurityEventSummary
+	if err := json.Unmarshal(body, &summary); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	fmt.Fprintf(a.stdout, "%d events from %s to %s\n", summary.Total,
+		summary.From.Local().Format(time.DateTime), summary.To.Local().Format(time.DateTime))
+	sections := []struct {
+		title  string
+		counts []security.SecurityEventCount
+	}{
+		{"TYPE", summary.ByType},
+		{"LEVEL", summary.ByLevel},
+		{"TOP IP", summary.TopIPs},
+		{"TOP USER", summary.TopUsers},
+	}
+	for _, section := range sections {
+		if len(section.counts) == 0 {
+			continue
+		}
+		fmt.Fprintln(a.stdout)
+		w := a.table(section.title, "COUNT")
+		for _, count := range section.counts {
+			fmt.Fprintf(w, "%s\t%d\n", count.Name, count.Count)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runIP(ctx context.Context, a *app, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "show":
+		body, err := a.client.do(ctx, http.MethodGet, "/security/ip/"+url.PathEscape(args[1]), nil, nil)
+		if err != nil || a.output == "json" {
+			return a.printRawOr(body, err)
+		}
+		var info security.IPReputationInfo
+		if err := json.Unmarshal(body, &info); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		until := "-"
+		if !info.Decision.BlockedUntil.IsZero() {
+			until = info.Decision.BlockedUntil.Local().Format(time.DateTime)
+		}
+		w := a.table("IP", "SCORE", "BLOCKS", "DECISION", "BLOCKED UNTIL", "REASON")
+		fmt.Fprintf(w, "%s\t%.1f\t%d\t%s\t%s\t%s\n", info.IP, info.Score, info.Blocks, info.Decision.Action, until, orDash(info.Decision.Reason))
+		return w.Flush()
+
+	case len(args) == 2 && args[0] == "unblock":
+		if _, err := a.client.do(ctx, http.MethodDelete, "/security/ip/"+url.PathEscape(args[1])+"/block", nil, nil); err != nil {
+			return err
+		}
+		return a.printStatus(map[string]string{"ip": args[1], "status": "unblocked"}, "Unblocked %s, score reset\n", args[1])
+
+	case len(args) == 1 && args[0] == "rules":
+		body, err := a.client.do(ctx, http.MethodGet, "/security/ip-rules", nil, nil)
+		if err != nil || a.output == "json" {
+			return a.printRawOr(body, err)
+		}
+		var resp struct {
+			Rules []security.IPRule `json:"rules"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		w := a.table("ID", "ACTION", "CIDR", "EXPIRES", "CREATED BY", "NOTE")
+		for _, rule := range resp.Rules {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", rule.ID, rule.Action, rule.CIDR, formatExpiry(rule.ExpiresAt), orDash(rule.CreatedBy), orDash(rule.Note))
+		}
+		return w.Flush()
+
+	case len(args) >= 1 && (args[0] == "block" || args[0] == "allow"):
+		flags := flag.NewFlagSet("ip "+args[0], flag.ContinueOnError)
+		duration := flags.Duration("for", 0, "Expire the rule after this long, 0 keeps it until deleted")
+		note := flags.String("note", "", "Note stored with the rule")
+		if err := parseFlags(flags, args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return usageError("ip " + args[0] + " requires exactly one IP or CIDR")
+		}
+		if *duration < 0 {
+			return usageError("-for must not be negative")
+		}
+		rule := security.IPRule{CIDR: flags.Arg(0), Action: security.IPRuleDeny, Note: *note}
+		if args[0] == "allow" {
+			rule.Action = security.IPRuleAllow
+		}
+		if *duration > 0 {
+			expiresAt := time.Now().Add(*duration)
+			rule.ExpiresAt = &expiresAt
+		}
+		body, err := a.client.do(ctx, http.MethodPost, "/security/ip-rules", nil, rule)
+		if err != nil || a.output == "json" {
+			return a.printRawOr(body, err)
+		}
+		var created security.IPRule
+		if err := json.Unmarshal(body, &created); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		fmt.Fprintf(a.stdout, "Created %s rule %d for %s, expires %s\n", created.Action, created.ID, created.CIDR, formatExpiry(created.ExpiresAt))
+		return nil
+
+	case len(args) == 2 && args[0] == "delete":
+		if _, err := strconv.ParseInt(args[1], 10, 64); err != nil {
+			return usageError("rule ID must be a number")
+		}
+		if _, err := a.client.do(ctx, http.MethodDelete, "/security/ip-rules/"+args[1], nil, nil); err != nil {
+			return err
+		}
+		return a.printStatus(map[string]string{"id": args[1], "status": "deleted"}, "Deleted rule %s\n", args[1])
+	}
+	return usageError("expected ip show IP, ip unblock IP, ip rules, ip block|allow CIDR or ip delete ID")
+}
+
+// revocation 会话吊销接口的响应
+type revocation struct {
+	UserID        string     `json:"user_id"`
+	RevokedBefore *time.Time `json:"revoked_before,omitempty"`
+}
+
+func runSessions(ctx context.Context, a *app, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "show":
+		body, err := a.client.do(ctx, http.MethodGet, "/users/"+url.PathEscape(args[1])+"/sessions", nil, nil)
+		if err != nil || a.output == "json" {
+			return a.printRawOr(body, err)
+		}
+		var resp revocation
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		revokedBefore := "never"
+		if resp.RevokedBefore != nil {
+			revokedBefore = resp.RevokedBefore.Local().Format(time.DateTime)
+		}
+		w := a.table("USER", "REVOKED BEFORE")
+		fmt.Fprintf(w, "%s\t%s\n", resp.UserID, revokedBefore)
+		return w.Flush()
+
+	case len(args) >= 2 && args[0] == "revoke":
+		for _, userID := range args[1:] {
+			body, err := a.client.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(userID)+"/sessions", nil, nil)
+			if err != nil {
+				return fmt.Errorf("failed to revoke sessions of %s: %w", userID, err)
+			}
+			if a.output == "json" {
+				if err := a.printRaw(body); err != nil {
+					return err
+				}
+				continue
+			}
+			var resp revocation
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+			if resp.RevokedBefore == nil {
+				return fmt.Errorf("response for %s has no revocation time", userID)
+			}
+			fmt.Fprintf(a.stdout, "Revoked tokens of user %s issued before %s\n", userID, resp.RevokedBefore.Local().Format(time.DateTime))
+		}
+		return nil
+	}
+	return usageError("expected sessions show USER or sessions revoke USER...")
+}
+
+func runAccess(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return usageError("access requires exactly one USER")
+	}
+	body, err := a.client.do(ctx, http.MethodGet, "/users/"+url.PathEscape(args[0])+"/access", nil, nil)
+	return a.printAccess(body, err)
+}
+
+func runRole(ctx context.Context, a *app, args []string) error {
+	switch {
+	case len(args) == 3 && args[0] == "assign":
+		body, err := a.client.do(ctx, http.MethodPut, "/users/"+url.PathEscape(args[1])+"/role", nil,
+			security.UserRoleRequest{Role: security.Role(args[2])})
+		return a.printAccess(body, err)
+	case len(args) == 2 && args[0] == "revoke":
+		body, err := a.client.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(args[1])+"/role", nil, nil)
+		return a.printAccess(body, err)
+	}
+	return usageError("expected role assign USER ROLE or role revoke USER")
+}
+
+func runPermission(ctx context.Context, a *app, args []string) error {
+	if len(args) < 3 || (args[0] != "grant" && args[0] != "revoke") {
+		return usageError("expected permission grant|revoke USER PERMISSION...")
+	}
+	user := url.PathEscape(args[1])
+	var body []byte
+	for _, permission := range args[2:] {
+		var err error
+		if args[0] == "grant" {
+			body, err = a.client.do(ctx, http.MethodPost, "/users/"+user+"/permissions", nil,
+				security.UserPermissionRequest{Permission: security.Permission(permission)})
+		} else {
+			body, err = a.client.do(ctx, http.MethodDelete, "/users/"+user+"/permissions/"+url.PathEscape(permission), nil, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s: %w", args[0], permission, err)
+		}
+	}
+	// 每次调用都返回用户的最新权限，只输出最后一次
+	return a.printAccess(body, nil)
+}
+
+// searchEvents 查询一页事件，同时返回原始响应
+func (a *app) searchEvents(ctx context.Context, q *eventQuery) (*eventPage, []byte, error) {
+	query, err := q.values()
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := a.client.do(ctx, http.MethodGet, "/security/events", query, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var page eventPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &page, body, nil
+}
+
+func (a *app) printPageFooter(page *eventPage) error {
+	_, err := fmt.Fprintf(a.stdout, "\nPage %d: %d of %d matching events\n", page.Page, len(page.Events), page.Total)
+	return err
+}
+
+// printAccess 输出用户的角色与权限
+func (a *app) printAccess(body []byte, err error) error {
+	if err != nil || a.output == "json" {
+		return a.printRawOr(body, err)
+	}
+	var access security.UserAccess
+	if err := json.Unmarshal(body, &access); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	w := a.table("USER", "ROLE", "GRANTS", "PERMISSIONS")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", access.UserID, orDash(string(access.Role)),
+		orDash(joinPermissions(access.Grants)), orDash(joinPermissions(access.Permissions)))
+	return w.Flush()
+}
+
+// printStatus 没有响应体的操作，json 模式输出 v，否则按 format 输出
+func (a *app) printStatus(v interface{}, format string, args ...interface{}) error {
+	if a.output == "json" {
+		return a.printJSON(v)
+	}
+	_, err := fmt.Fprintf(a.stdout, format, args...)
+	return err
+}
+
+// printRawOr 有错误时返回错误，否则缩进输出 JSON 响应
+func (a *app) printRawOr(body []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	return a.printRaw(body)
+}
+
+// table 以制表符对齐的表格输出，调用方写完行后 Flush
+func (a *app) table(columns ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	return w
+}
+
+// printRaw 缩进输出 JSON 响应
+func (a *app) printRaw(data []byte) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return fmt.Errorf("failed to format response: %w", err)
+	}
+	out.WriteByte('\n')
+	_, err := out.WriteTo(a.stdout)
+	return err
+}
+
+func (a *app) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(a.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// window 时间范围选项：-since 与 -from、-to 二选一，都未指定时服务端查询最近 7 天
+type window struct {
+	since    *time.Duration
+	from, to *string
+}
+
+func addWindowFlags(flags *flag.FlagSet) *window {
+	return &window{
+		since: flags.Duration("since", 0, "Only events within this long before now"),
+		from:  flags.String("from", "", "Start time, RFC 3339 or local YYYY-MM-DD[THH:MM]"),
+		to:    flags.String("to", "", "End time (exclusive), same formats as -from"),
+	}
+}
+
+func (w *window) values() (url.Values, error) {
+	query := url.Values{}
+	if *w.since > 0 && (*w.from != "" || *w.to != "") {
+		return nil, usageError("-since cannot be combined with -from or -to")
+	}
+	if *w.since > 0 {
+		query.Set("from", time.Now().Add(-*w.since).Format(time.RFC3339))
+	}
+	for name, value := range map[string]string{"from": *w.from, "to": *w.to} {
+		if value == "" {
+			continue
+		}
+		t, err := parseTime(value)
+		if err != nil {
+			return nil, usageError(fmt.Sprintf("invalid -%s: %v", name, err))
+		}
+		query.Set(name, t.Format(time.RFC3339))
+	}
+	return query, nil
+}
+
+// parseTime 解析 RFC 3339 时间，或按本地时区解析日期与分钟精度的时间
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not RFC 3339 or YYYY-MM-DD[THH:MM]", value)
+}
+
+func formatExpiry(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return "never"
+	}
+	return expiresAt.Local().Format(time.DateTime)
+}
+
+func formatStatus(status int) string {
+	if status == 0 {
+		return "-"
+	}
+	return strconv.Itoa(status)
+}
+
+func joinPermissions(permissions []security.Permission) string {
+	names := make([]string, len(permissions))
+	for i, permission := range permissions {
+		names[i] = string(permission)
+	}
+	return strings.Join(names, ",")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// parseFlags 解析子命令选项，错误作为参数错误返回
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIKey = "test-admin-key"
+
+// testServer 挂载与 AdminModule 相同认证与审计方式的安全管理接口
+type testServer struct {
+	*httptest.Server
+	rbac    *security.RBAC
+	monitor *security.SecurityMonitor
+	mock    sqlmock.Sqlmock
+}
+
+func newServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, mock := fakes.NewDB(t)
+	store := fakes.NewCache(nil)
+	s := &testServer{
+		rbac:    security.NewRBAC(store),
+		monitor: security.NewSecurityMonitor(store, metrics.GetGlobalCollector(), security.NewDefaultSecurityLogger()),
+		mock:    mock,
+	}
+	revoker := security.NewSessionRevoker(store, utils.TokenLifetime)
+	utils.SetTokenRevocations(revoker)
+	t.Cleanup(func() { utils.SetTokenRevocations(nil) })
+
+	router := gin.New()
+	router.GET("/me", middleware.AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	group := router.Group("/admin")
+	group.Use(middleware.APIKeyOrAuthMiddleware([]config.APIKeyConfig{
+		{Name: "secctl", Key: testAPIKey, Role: 1},
+	}), middleware.AdminMiddleware(), s.monitor.AdminAuditMiddleware())
+	security.NewRoleHandler(s.rbac).RegisterAdminRoutes(group)
+	security.NewSessionHandler(revoker).RegisterAdminRoutes(group)
+	security.NewSecurityEventHandler(security.NewSQLSecurityEventStore(db)).RegisterAdminRoutes(group)
+	security.NewIPReputationHandler(security.NewIPReputation(store, security.NewSQLIPRuleStore(db), nil)).RegisterAdminRoutes(group)
+
+	s.Server = httptest.NewServer(router)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// secctl 执行命令，返回退出码与输出
+func secctl(t *testing.T, server *testServer, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-addr", server.URL, "-api-key", testAPIKey}, args...)
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// auditEvents 服务端记录的审计事件
+func (s *testServer) auditEvents() []security.SecurityEvent {
+	var events []security.SecurityEvent
+	for _, event := range s.monitor.GenerateReport(time.Minute).Events {
+		if event.Type == security.EventAdminAction {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestSecctl_RolesAndPermissions(t *testing.T) {
+	server := newServer(t)
+
+	code, out, _ := secctl(t, server, "-reason", "SEC-7 promote", "role", "assign", "42", "moderator")
+	require.Equal(t, 0, code)
+	assert.Regexp(t, `42\s+moderator\s+-\s+\S+`, out)
+
+	code, out, _ = secctl(t, server, "permission", "grant", "42", "payment:read")
+	require.Equal(t, 0, code)
+	assert.Regexp(t, `42\s+moderator\s+payment:read\s`, out)
+
+	code, _, errOut := secctl(t, server, "role", "assign", "42", "wizard")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "404")
+
+	code, _, _ = secctl(t, server, "permission", "revoke", "42", "payment:read")
+	require.Equal(t, 0, code)
+	code, _, _ = secctl(t, server, "role", "revoke", "42")
+	require.Equal(t, 0, code)
+
+	code, out, _ = secctl(t, server, "-o", "json", "access", "42")
+	require.Equal(t, 0, code)
+	var access security.UserAccess
+	require.NoError(t, json.Unmarshal([]byte(out), &access))
+	assert.Equal(t, security.UserAccess{UserID: "42", Grants: []security.Permission{}, Permissions: []security.Permission{}}, access)
+
+	// 每个修改操作都有审计事件，查询不记录
+	events := server.auditEvents()
+	require.Len(t, events, 5)
+	assert.Equal(t, "apikey:secctl", events[0].UserID)
+	assert.Equal(t, http.MethodPut, events[0].Method)
+	assert.Equal(t, "/admin/users/:id/role", events[0].Details["route"])
+	assert.Equal(t, "SEC-7 promote", events[0].Details["reason"])
+	assert.Equal(t, http.StatusNotFound, events[2].Status)
+	assert.Equal(t, security.LevelWarning, events[2].Level)
+}
+
+func TestSecctl_SessionsRevokeRejectsOldTokens(t *testing.T) {
+	server := newServer(t)
+	previous := config.GlobalConfig.JWT.Secret
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	t.Cleanup(func() { config.GlobalConfig.JWT.Secret = previous })
+
+	token, _, err := utils.GenerateToken("42", 0)
+	require.NoError(t, err)
+	me := func() int {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, me())
+
+	code, out, _ := secctl(t, server, "sessions", "show", "42")
+	require.Equal(t, 0, code)
+	assert.Regexp(t, `42\s+never`, out)
+
+	code, out, _ = secctl(t, server, "sessions", "revoke", "42")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "Revoked tokens of user 42 issued before")
+	assert.Equal(t, http.StatusUnauthorized, me())
+}
+
+func TestSecctl_EventsAndReport(t *testing.T) {
+	server := newServer(t)
+	columns := []string{"id", "type", "level", "occurred_at", "source", "user_id", "ip", "user_agent",
+		"path", "method", "status", "message", "request_id", "details"}
+	at := time.Date(2026, 10, 1, 8, 0, 0, 0, time.Local)
+
+	server.mock.ExpectQuery(`SELECT COUNT\(\*\) FROM security_events WHERE type = \$1 AND occurred_at >= \$2 AND occurred_at < \$3`).
+		WithArgs("admin_action", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	server.mock.ExpectQuery(`FROM security_events WHERE type = \$1 .* LIMIT \$4 OFFSET \$5`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("evt_1", "admin_action", "info", at, "admin", "apikey:secctl",
+			"10.0.0.1", "secctl", "/admin/users/42/role", "PUT", 200, "Admin action", "req-1",
+			[]byte(`{"route":"/admin/users/:id/role","params":{"id":"42"},"reason":"SEC-7"}`)))
+	code, out, errOut := secctl(t, server, "audit", "-from", "2026-10-01", "-to", "2026-10-02")
+	require.Equal(t, 0, code, errOut)
+	assert.Regexp(t, `2026-10-01 08:00:00\s+apikey:secctl\s+PUT /admin/users/:id/role\s+id=42\s+200\s+SEC-7`, out)
+	assert.Contains(t, out, "Page 1: 1 of 1 matching events")
+
+	for _, column := range []string{"type", "level", "ip", "user_id"} {
+		rows := sqlmock.NewRows([]string{"name", "count"})
+		switch column {
+		case "type":
+			rows.AddRow("login", 7).AddRow("rate_limit", 3)
+		case "ip":
+			rows.AddRow("10.0.0.9", 6)
+		}
+		server.mock.ExpectQuery(`SELECT ` + column + ` AS name, COUNT\(\*\) AS count FROM security_events WHERE occurred_at >= \$1`).
+			WillReturnRows(rows)
+	}
+	code, out, errOut = secctl(t, server, "report", "-since", "24h", "-top", "5")
+	require.Equal(t, 0, code, errOut)
+	assert.Contains(t, out, "10 events from")
+	assert.Regexp(t, `login\s+7`, out)
+	assert.Regexp(t, `TOP IP\s+COUNT\n10\.0\.0\.9\s+6`, out)
+	assert.NotContains(t, out, "TOP USER")
+
+	code, _, errOut = secctl(t, server, "events", "-since", "1h", "-from", "2026-10-01")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "-since cannot be combined with -from or -to")
+}
+
+func TestSecctl_IPBlock(t *testing.T) {
+	server := newServer(t)
+	expires := time.Now().Add(time.Hour)
+	server.mock.ExpectQuery(`INSERT INTO ip_access_rules`).
+		WithArgs("203.0.113.0/24", "deny", "INC-42", "apikey:secctl", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "cidr", "action", "note", "created_by", "expires_at", "created_at"}).
+			AddRow(int64(3), "203.0.113.0/24", "deny", "INC-42", "apikey:secctl", expires, time.Now()))
+
+	code, out, errOut := secctl(t, server, "ip", "block", "-for", "1h", "-note", "INC-42", "203.0.113.7/24")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, "Created deny rule 3 for 203.0.113.0/24, expires "+expires.Local().Format(time.DateTime)+"\n", out)
+
+	code, _, _ = secctl(t, server, "ip", "delete", "x")
+	assert.Equal(t, 2, code)
+}
+
+func TestSecctl_Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"events"}, &stdout, &stderr)
+	assert.Equal(t, 2, code, "api key is required")
+
+	server := newServer(t)
+	code, _, errOut := secctl(t, server, "lockdown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, `unknown command "lockdown"`)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	"user_crud_jwt/pkg/loadgen"
+)
+
+// runCoordinator 监听代理注册，等待 -agents 个代理后分发场景并汇总报告
+func runCoordinator(ctx context.Context, opts *options, scenario *loadgen.Scenario) (*loadgen.DistributedReport, error) {
+	params, err := json.Marshal(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode scenario params: %w", err)
+	}
+
+	config := loadgen.DefaultCoordinatorConfig()
+	config.MinAgents = opts.Agents
+	coordinator := loadgen.NewCoordinator(config)
+	server := &http.Server{Addr: opts.Listen, Handler: coordinator.Handler()}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Coordinator server failed: %v", err)
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("协调者监听 %s，等待 %d 个代理：场景 %s，%d 个虚拟用户，目标 %s\n",
+		opts.Listen, opts.Agents, scenario.Name, opts.VUs, opts.BaseURL)
+	return coordinator.Run(ctx, &loadgen.Plan{
+		Scenario:     scenario,
+		ScenarioName: opts.Scenario,
+		Params:       params,
+		Config:       &opts.Config,
+		OnProgress: func(snapshot *loadgen.Snapshot, agents int) {
+			fmt.Printf("进度：%d 个代理，迭代 %d（失败 %d），请求 %d（失败 %d）\n",
+				agents, snapshot.Iterations, snapshot.FailedIterations, snapshot.Total.Count, snapshot.Total.Failures)
+		},
+	})
+}
+
+// runAgent 连接协调者，按下发的场景名与参数构建场景并执行分片，直到收到退出信号
+func runAgent(ctx context.Context, opts *options) {
+	if opts.Coordinator == "" {
+		log.Fatal("Agent mode requires -coordinator")
+	}
+	config := loadgen.DefaultAgentConfig()
+	config.Coordinator = opts.Coordinator
+	config.Name = opts.AgentName
+	if config.Name == "" {
+		config.Name, _ = os.Hostname()
+	}
+
+	agent := loadgen.NewAgent(config, func(job *loadgen.Job) (*loadgen.Scenario, error) {
+		build, ok := scenarios[job.Scenario]
+		if !ok {
+			return nil, fmt.Errorf("unknown scenario %q", job.Scenario)
+		}
+		jobOpts := &options{}
+		if len(job.Params) > 0 {
+			if err := json.Unmarshal(job.Params, jobOpts); err != nil {
+				return nil, fmt.Errorf("failed to decode scenario params: %w", err)
+			}
+		}
+		jobOpts.Config = job.Config
+		jobOpts.shard, jobOpts.shards = job.Shard, job.Shards
+		return build(jobOpts)
+	})
+	fmt.Printf("代理 %s 连接协调者 %s\n", config.Name, config.Coordinator)
+	agent.Run(ctx)
+}
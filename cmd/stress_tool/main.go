@@ -1,143 +1,232 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"net/http"
-	"sync"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
-)
-
-// Config
-const (
-	BaseURL     = "http://localhost:8080"
-	TotalUsers  = 10000 // 模拟 10000 个用户并发
-	TotalStock  = 5     // 优惠券只有 5 张
-)
+	"user_crud_jwt/pkg/loadgen"
 
-var (
-	TestCouponID int
-	httpClient   *http.Client
+	"github.com/spf13/viper"
 )
 
-func init() {
-	// 优化 HTTP Client 配置
-	t := http.DefaultTransport.(*http.Transport).Clone()
-	t.MaxIdleConns = 2000
-	t.MaxIdleConnsPerHost = 2000
-	t.MaxConnsPerHost = 2000
-	httpClient = &http.Client{
-		Transport: t,
-		Timeout:   10 * time.Second,
-	}
+// options 命令行与配置文件选项，命令行显式指定的参数优先
+type options struct {
+	loadgen.Config `mapstructure:",squash"`
+
+	Scenario string `mapstructure:"scenario"`
+	// Method、Path http 场景每次迭代请求的接口
+	Method string `mapstructure:"method"`
+	Path   string `mapstructure:"path"`
+	// Token 所有虚拟用户共用的令牌；TokensFile 每行一个令牌，按虚拟用户轮流使用
+	Token      string `mapstructure:"token"`
+	TokensFile string `mapstructure:"tokens_file"`
+	// Stock coupon 场景创建的优惠券库存
+	Stock int `mapstructure:"stock"`
+	// Flow flow 场景的 JSON 脚本
+	Flow string `mapstructure:"flow"`
+	// JSON 以 JSON 输出报告
+	JSON bool `mapstructure:"json"`
+
+	// Mode 运行方式：local 单机压测；coordinator 把虚拟用户与 RPS 分给代理执行并汇总报告；agent 执行协调者下发的分片
+	Mode string `mapstructure:"mode"`
+	// Listen 协调者的监听地址，Agents 开始压测前至少需要的代理数
+	Listen string `mapstructure:"listen"`
+	Agents int    `mapstructure:"agents"`
+	// Coordinator 代理连接的协调者地址，AgentName 代理在报告中的名称
+	Coordinator string `mapstructure:"coordinator"`
+	AgentName   string `mapstructure:"agent_name"`
+
+	// shard、shards 代理执行的分片，用于划分 unique 数据
+	shard, shards int
 }
 
-func main() {
-	// 1. 创建优惠券 (管理员操作)
-	createCoupon()
-
-	fmt.Printf("开始压测：模拟 %d 个用户抢 %d 张券 (CouponID: %d)...\n", TotalUsers, TotalStock, TestCouponID)
-	time.Sleep(1 * time.Second)
-
-	// 2. 并发抢券
-	var wg sync.WaitGroup
-	successCount := 0
-	failCount := 0
-	var mu sync.Mutex
-
-	start := time.Now()
-
-	for i := 1; i <= TotalUsers; i++ {
-		wg.Add(1)
-		go func(userID int) {
-			defer wg.Done()
-			success := claimCoupon(userID)
-			mu.Lock()
-			if success {
-				successCount++
-			} else {
-				failCount++
-			}
-			mu.Unlock()
-		}(i)
-	}
-
-	wg.Wait()
-	duration := time.Since(start)
-	qps := float64(TotalUsers) / duration.Seconds()
-
-	fmt.Println("--------------------------------------------------")
-	fmt.Printf("压测结束，耗时: %v\n", duration)
-	fmt.Printf("总请求数: %d\n", TotalUsers)
-	fmt.Printf("QPS: %.2f\n", qps)
-	fmt.Printf("成功抢到: %d (预期: %d)\n", successCount, TotalStock)
-	fmt.Printf("抢券失败: %d\n", failCount)
-	fmt.Println("--------------------------------------------------")
+// result 单机与分布式压测的报告
+type result interface {
+	Print(w io.Writer)
+	Failed() bool
 }
 
-func createCoupon() {
-	url := fmt.Sprintf("%s/coupons/create_test", BaseURL)
-	payload := map[string]interface{}{
-		"name":       "压测专用券",
-		"total":      TotalStock,
-		"amount":     100.0,
-		"start_time": time.Now().Format(time.RFC3339),
-		"end_time":   time.Now().Add(24 * time.Hour).Format(time.RFC3339),
-	}
-	body, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+func main() {
+	opts, err := loadOptions(os.Args[1:])
 	if err != nil {
-		fmt.Printf("创建优惠券失败: %v\n", err)
-		return
+		log.Fatalf("Invalid options: %v", err)
 	}
-	defer resp.Body.Close()
 
-	// 读取响应以获取 ID
-	respBody, _ := io.ReadAll(resp.Body)
-	fmt.Printf("创建优惠券响应: %s\n", string(respBody))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	var result struct {
-		Data struct {
-			ID int `json:"id"`
-		} `json:"data"`
-	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		fmt.Printf("解析响应失败: %v\n", err)
+	if opts.Mode == "agent" {
+		runAgent(ctx, opts)
 		return
 	}
-	TestCouponID = result.Data.ID
-}
 
-func claimCoupon(userID int) bool {
-	// 使用测试后门接口，直接传 user_id
-	url := fmt.Sprintf("%s/coupons/%d/claim_test?user_id=%d", BaseURL, TestCouponID, userID)
-	resp, err := httpClient.Post(url, "application/json", nil)
+	build, ok := scenarios[opts.Scenario]
+	if !ok {
+		log.Fatalf("Unknown scenario %q, available: %s", opts.Scenario, strings.Join(scenarioNames(), ", "))
+	}
+	scenario, err := build(opts)
 	if err != nil {
-		// fmt.Printf("User %d 请求失败: %v\n", userID, err)
-		return false
+		log.Fatalf("Failed to prepare scenario: %v", err)
 	}
-	defer resp.Body.Close()
 
-	// 读取响应内容
-	respBody, err := io.ReadAll(resp.Body)
+	var report result
+	switch opts.Mode {
+	case "local":
+		fmt.Printf("开始压测：场景 %s，%d 个虚拟用户，目标 %s\n", scenario.Name, opts.VUs, opts.BaseURL)
+		report, err = loadgen.NewRunner(&opts.Config).Run(ctx, scenario)
+	case "coordinator":
+		report, err = runCoordinator(ctx, opts, scenario)
+	default:
+		log.Fatalf("Unknown mode %q, available: local, coordinator, agent", opts.Mode)
+	}
 	if err != nil {
-		return false
+		log.Fatalf("Load test failed: %v", err)
 	}
 
-	// 检查 HTTP 状态码
-	if resp.StatusCode != 200 {
-		return false
+	if opts.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+	} else {
+		report.Print(os.Stdout)
 	}
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
 
-	// 检查业务状态码
-	var result struct {
-		Code int `json:"code"`
+// loadOptions 依次应用默认值、-config 指定的配置文件（YAML 或 JSON）与命令行参数
+func loadOptions(args []string) (*options, error) {
+	opts := &options{
+		Config:   *loadgen.DefaultConfig(),
+		Scenario: "coupon",
+		Method:   "GET",
+		Path:     "/healthz",
+		Stock:    5,
+		Mode:     "local",
+		Listen:   ":7070",
+		Agents:   1,
 	}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return false
+	opts.VUs = 10000
+	client := *opts.Client
+	opts.Client = &client
+
+	fs := flag.NewFlagSet("stress_tool", flag.ContinueOnError)
+	configFile := fs.String("config", "", "配置文件路径（YAML 或 JSON）")
+	fs.String("scenario", opts.Scenario, "场景："+strings.Join(scenarioNames(), "、"))
+	fs.String("base-url", opts.BaseURL, "服务地址")
+	fs.Int("vus", opts.VUs, "虚拟用户数（并发数）")
+	fs.Int("iterations", opts.Iterations, "每个虚拟用户的迭代次数，0 表示按 -duration 持续执行")
+	fs.Duration("duration", opts.Duration, "压测时长")
+	fs.Duration("ramp-up", opts.RampUp, "在此时间内均匀启动虚拟用户")
+	fs.Float64("rps", opts.RPS, "每秒开始的迭代数上限，0 表示不限制")
+	fs.Duration("timeout", opts.Client.Timeout, "单个请求超时")
+	fs.Int("max-conns", opts.Client.MaxConnsPerHost, "每个目标主机的最大连接数")
+	fs.Bool("no-keepalive", opts.Client.DisableKeepAlives, "每个请求新建连接")
+	fs.String("method", opts.Method, "http 场景的请求方法")
+	fs.String("path", opts.Path, "http 场景的请求路径")
+	fs.String("token", opts.Token, "Bearer 令牌")
+	fs.String("tokens-file", opts.TokensFile, "令牌文件，每行一个，按虚拟用户轮流使用")
+	fs.Int("stock", opts.Stock, "coupon 场景的优惠券库存")
+	fs.String("flow", opts.Flow, "flow 场景的 JSON 脚本")
+	fs.Bool("json", opts.JSON, "以 JSON 输出报告")
+	fs.String("mode", opts.Mode, "运行方式：local、coordinator、agent")
+	fs.String("listen", opts.Listen, "coordinator 模式的监听地址")
+	fs.Int("agents", opts.Agents, "coordinator 模式开始压测前至少需要的代理数")
+	fs.String("coordinator", opts.Coordinator, "agent 模式连接的协调者地址，如 http://10.0.0.1:7070")
+	fs.String("agent-name", opts.AgentName, "agent 模式的代理名称，默认为主机名")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
 
-	return result.Code == 0
+	if *configFile != "" {
+		v := viper.New()
+		v.SetConfigFile(*configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := v.Unmarshal(opts); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		if opts.Client == nil {
+			opts.Client = loadgen.DefaultClientConfig()
+		}
+	}
+
+	var applyErr error
+	fs.Visit(func(f *flag.Flag) {
+		getter := f.Value.(flag.Getter)
+		switch f.Name {
+		case "scenario":
+			opts.Scenario = getter.Get().(string)
+		case "base-url":
+			opts.BaseURL = getter.Get().(string)
+		case "vus":
+			opts.VUs = getter.Get().(int)
+		case "iterations":
+			opts.Iterations = getter.Get().(int)
+		case "duration":
+			opts.Duration = getter.Get().(time.Duration)
+		case "ramp-up":
+			opts.RampUp = getter.Get().(time.Duration)
+		case "rps":
+			opts.RPS = getter.Get().(float64)
+		case "timeout":
+			opts.Client.Timeout = getter.Get().(time.Duration)
+		case "max-conns":
+			opts.Client.MaxConnsPerHost = getter.Get().(int)
+			opts.Client.MaxIdleConnsPerHost = opts.Client.MaxConnsPerHost
+		case "no-keepalive":
+			opts.Client.DisableKeepAlives = getter.Get().(bool)
+		case "method":
+			opts.Method = getter.Get().(string)
+		case "path":
+			opts.Path = getter.Get().(string)
+		case "token":
+			opts.Token = getter.Get().(string)
+		case "tokens-file":
+			opts.TokensFile = getter.Get().(string)
+		case "stock":
+			opts.Stock = getter.Get().(int)
+		case "flow":
+			opts.Flow = getter.Get().(string)
+		case "json":
+			opts.JSON = getter.Get().(bool)
+		case "mode":
+			opts.Mode = getter.Get().(string)
+		case "listen":
+			opts.Listen = getter.Get().(string)
+		case "agents":
+			opts.Agents = getter.Get().(int)
+		case "coordinator":
+			opts.Coordinator = getter.Get().(string)
+		case "agent-name":
+			opts.AgentName = getter.Get().(string)
+		case "config":
+		default:
+			applyErr = fmt.Errorf("unhandled flag -%s", f.Name)
+		}
+	})
+	return opts, applyErr
+}
+
+// scenarioNames 可用的场景名
+func scenarioNames() []string {
+	names := make([]string, 0, len(scenarios))
+	for name := range scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
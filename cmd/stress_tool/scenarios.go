@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/loadgen"
+	"user_crud_jwt/pkg/testing"
+)
+
+// scenarios 可用的压测场景
+var scenarios = map[string]func(opts *options) (*loadgen.Scenario, error){
+	"coupon": couponScenario,
+	"flow":   flowScenario,
+	"http":   httpScenario,
+}
+
+// flowScenario 按 -flow 指定的脚本执行多步骤场景；分布式压测时 unique 数据按代理分片，避免重复使用
+func flowScenario(opts *options) (*loadgen.Scenario, error) {
+	if opts.Flow == "" {
+		return nil, errors.New("flow scenario requires -flow")
+	}
+	flow, err := testing.LoadFlow(opts.Flow)
+	if err != nil {
+		return nil, err
+	}
+	if feeder, ok := flow.Feeder.(*testing.SliceFeeder); ok && flow.FeedMode == testing.FeedUnique {
+		flow.Feeder = feeder.Shard(opts.shard, opts.shards)
+	}
+	return flow.Scenario()
+}
+
+// httpScenario 每次迭代请求同一接口，要求 2xx
+func httpScenario(opts *options) (*loadgen.Scenario, error) {
+	tokens, err := loadTokens(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &loadgen.Scenario{
+		Name: "http " + opts.Method + " " + opts.Path,
+		Iteration: func(ctx context.Context, vu *loadgen.VU) error {
+			_, err := vu.Do(ctx, &loadgen.Request{
+				Method: opts.Method,
+				Path:   opts.Path,
+				Header: bearer(tokens, vu.ID),
+			})
+			return err
+		},
+	}, nil
+}
+
+// couponScenario 抢券：创建库存为 Stock 的优惠券，每个虚拟用户以自己的令牌领取，结束后校验没有超发
+func couponScenario(opts *options) (*loadgen.Scenario, error) {
+	tokens, err := loadTokens(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("coupon scenario requires -token or -tokens-file")
+	}
+
+	// 领取结果：200 成功，202 排队，404 / 409 已抢完或已领取，其余视为失败
+	claimAssertions := []loadgen.Assertion{loadgen.StatusIn(http.StatusOK, http.StatusAccepted, http.StatusNotFound, http.StatusConflict)}
+
+	return &loadgen.Scenario{
+		Name: "coupon",
+		Setup: func(ctx context.Context, env *loadgen.Env) error {
+			now := time.Now()
+			resp, err := env.Do(ctx, &loadgen.Request{
+				Method: http.MethodPost,
+				Path:   "/coupons/",
+				Body: map[string]interface{}{
+					"name":       "压测专用券",
+					"total":      opts.Stock,
+					"amount":     100.0,
+					"start_time": now.Format(time.RFC3339),
+					"end_time":   now.Add(24 * time.Hour).Format(time.RFC3339),
+				},
+				Assertions: []loadgen.Assertion{loadgen.StatusOK(), loadgen.BusinessCode(0)},
+			})
+			if err != nil {
+				return err
+			}
+			var result struct {
+				Data struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			}
+			if err := resp.JSON(&result); err != nil {
+				return err
+			}
+			if result.Data.ID == "" {
+				return fmt.Errorf("create coupon response has no id: %s", resp.Body)
+			}
+			env.Set("coupon_id", result.Data.ID)
+			fmt.Printf("已创建优惠券 %s，库存 %d\n", result.Data.ID, opts.Stock)
+			return nil
+		},
+		Iteration: func(ctx context.Context, vu *loadgen.VU) error {
+			resp, err := vu.Do(ctx, &loadgen.Request{
+				Name:       "POST /coupons/:id/claim",
+				Method:     http.MethodPost,
+				Path:       "/coupons/" + vu.Get("coupon_id") + "/claim",
+				Header:     bearer(tokens, vu.ID),
+				Assertions: claimAssertions,
+			})
+			if err != nil {
+				return err
+			}
+			switch resp.StatusCode {
+			case http.StatusOK:
+				vu.Count("claimed", 1)
+			case http.StatusAccepted:
+				vu.Count("queued", 1)
+			default:
+				vu.Count("rejected", 1)
+			}
+			return nil
+		},
+		Teardown: func(ctx context.Context, env *loadgen.Env, report *loadgen.Report) error {
+			if claimed := report.Counters["claimed"]; claimed > int64(opts.Stock) {
+				return fmt.Errorf("oversold: %d claimed, stock %d", claimed, opts.Stock)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// loadTokens 读取令牌，TokensFile 优先于 Token
+func loadTokens(opts *options) ([]string, error) {
+	if opts.TokensFile == "" {
+		if opts.Token == "" {
+			return nil, nil
+		}
+		return []string{opts.Token}, nil
+	}
+
+	file, err := os.Open(opts.TokensFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tokens file: %w", err)
+	}
+	defer file.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if token := strings.TrimSpace(scanner.Text()); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tokens file: %w", err)
+	}
+	return tokens, nil
+}
+
+// bearer 第 vuID 个虚拟用户的认证头，没有令牌时返回 nil
+func bearer(tokens []string, vuID int) http.Header {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + tokens[(vuID-1)%len(tokens)]}}
+}
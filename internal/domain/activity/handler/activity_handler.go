@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"user_crud_jwt/internal/domain/activity/model"
+	"user_crud_jwt/internal/domain/activity/service"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/spreadsheet"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityFilter 活动记录筛选条件，type 可重复
+type ActivityFilter struct {
+	Types []string  `form:"type" binding:"max=10"`
+	From  time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To    time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// TimelineQuery 活动时间线参数
+type TimelineQuery struct {
+	ActivityFilter
+	utils.CursorPagination
+}
+
+// ExportQuery 活动记录导出参数
+type ExportQuery struct {
+	ActivityFilter
+	Format string `form:"format"` // csv（默认）或 xlsx
+}
+
+// ActivityHandler 用户活动记录接口
+type ActivityHandler struct {
+	activities *service.ActivityService
+}
+
+// NewActivityHandler 创建用户活动记录接口
+func NewActivityHandler(activities *service.ActivityService) *ActivityHandler {
+	return &ActivityHandler{activities: activities}
+}
+
+// RegisterAdminRoutes 注册按用户查询与导出活动记录的路由，调用方需挂载管理员权限校验
+func (h *ActivityHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/users/:id/activities", h.GetUserTimeline)
+	group.GET("/users/:id/activities/export", h.ExportUserActivities)
+}
+
+// GetTimeline 当前用户的活动时间线（游标分页）
+func (h *ActivityHandler) GetTimeline(c *gin.Context) {
+	h.timeline(c, c.GetString("userID"))
+}
+
+// GetUserTimeline 指定用户的活动时间线（游标分页）
+func (h *ActivityHandler) GetUserTimeline(c *gin.Context) {
+	h.timeline(c, c.Param("id"))
+}
+
+// ExportActivities 导出当前用户的活动记录
+func (h *ActivityHandler) ExportActivities(c *gin.Context) {
+	h.export(c, c.GetString("userID"))
+}
+
+// ExportUserActivities 导出指定用户的活动记录
+func (h *ActivityHandler) ExportUserActivities(c *gin.Context) {
+	h.export(c, c.Param("id"))
+}
+
+func (h *ActivityHandler) timeline(c *gin.Context, userID string) {
+	var query TimelineQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	q := query.ActivityFilter.toQuery(userID)
+	q.Cursor, q.Limit = query.Cursor, query.GetLimit()
+	page, err := h.activities.Timeline(c.Request.Context(), q)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.CursorPage(c, page.Activities, q.Limit, page.NextCursor, page.HasMore, 0)
+}
+
+// export 以附件形式边查询边写出。响应头写出后无法再返回错误响应，中途出错只记录日志，客户端得到截断的文件
+func (h *ActivityHandler) export(c *gin.Context, userID string) {
+	var query ExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	format := spreadsheet.FormatCSV
+	if query.Format != "" {
+		var err error
+		if format, err = spreadsheet.ParseFormat(query.Format); err != nil {
+			response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+			return
+		}
+	}
+	q := query.ActivityFilter.toQuery(userID)
+	if err := service.ValidateQuery(q); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("activities-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	writer, err := spreadsheet.NewWriter(c.Writer, format)
+	if err != nil {
+		log.Printf("Failed to start activity export: %v", err)
+		return
+	}
+	count, err := h.activities.Export(database.ReportQuery(c.Request.Context()), writer, q)
+	if err != nil {
+		writer.Close()
+		log.Printf("Failed to export activities of user %s after %d rows: %v", userID, count, err)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("Failed to finish activity export: %v", err)
+	}
+}
+
+func (f ActivityFilter) toQuery(userID string) model.Query {
+	return model.Query{UserID: userID, Types: f.Types, From: f.From, To: f.To}
+}
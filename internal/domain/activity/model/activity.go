@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Activity 用户活动记录，类型为产生该记录的领域事件主题
+type Activity struct {
+	ID        string            `json:"id"`
+	TenantID  string            `json:"-"` // 所属租户，为空时视为默认租户
+	UserID    string            `json:"userId"`
+	Type      string            `json:"type"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	Data      map[string]string `json:"data,omitempty"` // 事件相关的业务字段，如优惠券 ID
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Query 用户活动查询条件，按发生时间倒序
+type Query struct {
+	UserID string
+	Types  []string  // 为空时不限类型
+	From   time.Time // 零值不限
+	To     time.Time // 零值不限
+	Cursor string    // 上一页返回的 NextCursor，为空时从最新一条开始
+	Limit  int
+}
+
+// Page 一页活动记录
+type Page struct {
+	Activities []*Activity
+	NextCursor string
+	HasMore    bool
+}
@@ -0,0 +1,120 @@
+package activity
+
+import (
+	"log"
+	"net/http"
+	"user_crud_jwt/internal/domain/activity/handler"
+	"user_crud_jwt/internal/domain/activity/model"
+	"user_crud_jwt/internal/domain/activity/repository"
+	"user_crud_jwt/internal/domain/activity/service"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityModule 用户活动记录模块
+type ActivityModule struct{}
+
+func init() {
+	registry.Register(&ActivityModule{})
+}
+
+func (m *ActivityModule) Name() string {
+	return "activity"
+}
+
+func (m *ActivityModule) Priority() int {
+	// 先于管理模块初始化，管理模块注册按用户查询的接口
+	return 35
+}
+
+func (m *ActivityModule) Init(ctx *registry.ModuleContext) error {
+	// 1. 依赖注入
+	activities := service.NewActivityService(repository.NewSQLActivityRepository(ctx.DB), nil)
+	activityHandler := handler.NewActivityHandler(activities)
+	ctx.Provide(registry.UserActivity, activities)
+
+	// 活动记录由其他模块发布的领域事件写入
+	svc, _ := ctx.Lookup(registry.DomainEvents)
+	if bus, ok := svc.(*domainevent.Bus); ok && bus != nil {
+		if err := activities.Subscribe(bus); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Domain event bus unavailable, user activities will not be recorded")
+	}
+
+	// 超过保留期的记录定期清理
+	svc, _ = ctx.Lookup(registry.Jobs)
+	if manager, ok := svc.(*jobs.Manager); ok && manager != nil {
+		if err := activities.Register(manager); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Job manager unavailable, expired user activities will not be purged")
+	}
+
+	// 2. 路由注册
+	setupRoutes(ctx.Router, activityHandler)
+
+	return nil
+}
+
+func setupRoutes(r *gin.Engine, h *handler.ActivityHandler) {
+	describeRoutes(h)
+
+	// 受保护的路由
+	activityGroup := r.Group("/activities")
+	activityGroup.Use(middleware.AuthMiddleware())
+	{
+		activityGroup.GET("/", h.GetTimeline)
+		activityGroup.GET("/export", h.ExportActivities)
+	}
+}
+
+// describeRoutes 路由的 OpenAPI 注解
+func describeRoutes(h *handler.ActivityHandler) {
+	const types = "type 可重复，取值为 user.logged_in、user.profile_updated、user.password_changed、coupon.claimed"
+	openapi.Describe(h.GetTimeline, openapi.Route{
+		Summary:     "当前用户的活动时间线",
+		Description: "按发生时间倒序的游标分页，" + types,
+		Tags:        []string{"Activity"},
+		Auth:        true,
+		Query:       handler.TimelineQuery{},
+		Response:    []model.Activity{},
+		Paginated:   true,
+		Errors:      []int{http.StatusBadRequest},
+	})
+	openapi.Describe(h.ExportActivities, openapi.Route{
+		Summary:     "导出当前用户的活动记录",
+		Description: "以附件形式流式返回 CSV 或 XLSX 文件，参数错误时返回统一的 JSON 错误响应",
+		Tags:        []string{"Activity"},
+		Auth:        true,
+		Query:       handler.ExportQuery{},
+		Response:    "",
+		Raw:         true,
+	})
+	openapi.Describe(h.GetUserTimeline, openapi.Route{
+		Summary:     "指定用户的活动时间线",
+		Description: "按发生时间倒序的游标分页，" + types,
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.TimelineQuery{},
+		Response:    []model.Activity{},
+		Paginated:   true,
+		Errors:      []int{http.StatusBadRequest},
+	})
+	openapi.Describe(h.ExportUserActivities, openapi.Route{
+		Summary:     "导出指定用户的活动记录",
+		Description: "以附件形式流式返回 CSV 或 XLSX 文件，参数错误时返回统一的 JSON 错误响应",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.ExportQuery{},
+		Response:    "",
+		Raw:         true,
+	})
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/activity/model"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Position 时间线中的位置，翻页时从该条之后（更早）开始
+type Position struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// ActivityRepository 用户活动记录
+type ActivityRepository interface {
+	// Insert 写入活动记录，eventID 已记录过时忽略，用于事件重复投递
+	Insert(ctx context.Context, eventID string, activity *model.Activity) error
+	// List 按 (created_at, id) 倒序列出，after 不为 nil 时从该位置之后开始，最多 limit 条。忽略 q 中的 Cursor 与 Limit
+	List(ctx context.Context, q model.Query, after *Position, limit int) ([]*model.Activity, error)
+	// DeleteBefore 删除发生时间早于 before 的记录，每次最多 limit 条，返回删除数量
+	DeleteBefore(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// activityRow user_activities 表的行
+type activityRow struct {
+	ID        string    `db:"id"`
+	TenantID  string    `db:"tenant_id"`
+	UserID    string    `db:"user_id"`
+	Type      string    `db:"type"`
+	IP        string    `db:"ip"`
+	UserAgent string    `db:"user_agent"`
+	Data      []byte    `db:"data"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (r *activityRow) toModel() (*model.Activity, error) {
+	activity := &model.Activity{
+		ID:        r.ID,
+		TenantID:  r.TenantID,
+		UserID:    r.UserID,
+		Type:      r.Type,
+		IP:        r.IP,
+		UserAgent: r.UserAgent,
+		CreatedAt: r.CreatedAt,
+	}
+	if err := json.Unmarshal(r.Data, &activity.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode activity data: %w", err)
+	}
+	return activity, nil
+}
+
+const activityColumns = `id, tenant_id, user_id, type, ip, user_agent, data, created_at`
+
+// SQLActivityRepository 基于 user_activities 表的活动仓库
+type SQLActivityRepository struct {
+	db *database.DB
+}
+
+// NewSQLActivityRepository 创建活动仓库
+func NewSQLActivityRepository(db *database.DB) *SQLActivityRepository {
+	return &SQLActivityRepository{db: db}
+}
+
+func (r *SQLActivityRepository) Insert(ctx context.Context, eventID string, activity *model.Activity) error {
+	data := []byte("{}")
+	if len(activity.Data) > 0 {
+		var err error
+		if data, err = json.Marshal(activity.Data); err != nil {
+			return fmt.Errorf("failed to encode activity data: %w", err)
+		}
+	}
+	if activity.ID == "" {
+		activity.ID = uuid.New().String()
+	}
+	if activity.TenantID == "" {
+		activity.TenantID = database.TenantForWrite(ctx)
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_activities (id, event_id, tenant_id, user_id, type, ip, user_agent, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (event_id) DO NOTHING`,
+		activity.ID, eventID, activity.TenantID, activity.UserID, activity.Type, activity.IP, activity.UserAgent, data, activity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert user activity: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLActivityRepository) List(ctx context.Context, q model.Query, after *Position, limit int) ([]*model.Activity, error) {
+	scopes := []database.Scope{database.Cond("user_id = $%d", q.UserID), database.TimeRange("created_at", q.From, q.To)}
+	if len(q.Types) > 0 {
+		scopes = append(scopes, database.Cond("type = ANY($%d)", pq.Array(q.Types)))
+	}
+	if after != nil {
+		scopes = append(scopes, database.Cond("(created_at, id) < ($%d, $%d)", after.CreatedAt, after.ID))
+	}
+	where, args := database.Where(append(scopes, database.InTenant(ctx, ""))...)
+	args = append(args, limit)
+
+	var rows []activityRow
+	query := fmt.Sprintf(`SELECT %s FROM user_activities WHERE %s ORDER BY created_at DESC, id DESC LIMIT $%d`, activityColumns, where, len(args))
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list user activities: %w", err)
+	}
+
+	activities := make([]*model.Activity, 0, len(rows))
+	for i := range rows {
+		activity, err := rows[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, activity)
+	}
+	return activities, nil
+}
+
+func (r *SQLActivityRepository) DeleteBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM user_activities
+		WHERE id IN (SELECT id FROM user_activities WHERE created_at < $1 LIMIT $2)`, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired user activities: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"user_crud_jwt/internal/domain/activity/model"
+	"user_crud_jwt/internal/domain/activity/repository"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/events"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/spreadsheet"
+	"user_crud_jwt/pkg/utils"
+)
+
+// RetentionJobType 过期活动记录清理任务类型
+const RetentionJobType = "user_activity_retention"
+
+// Config 活动记录配置
+type Config struct {
+	Retention         time.Duration // 记录保留时长，超过的由清理任务删除
+	RetentionSchedule string        // 清理任务的 cron 表达式
+	RetentionBatch    int           // 清理任务每批删除的条数
+	ExportPageSize    int           // 导出时每次查询的条数
+}
+
+// DefaultConfig 默认活动记录配置
+func DefaultConfig() *Config {
+	return &Config{
+		Retention:         180 * 24 * time.Hour,
+		RetentionSchedule: "30 3 * * *",
+		RetentionBatch:    1000,
+		ExportPageSize:    500,
+	}
+}
+
+// exportColumns 导出文件的表头
+var exportColumns = []string{"id", "type", "ip", "user_agent", "data", "created_at"}
+
+// ActivityService 用户活动记录：订阅领域事件写入记录，按用户提供时间线与导出，定期清理超过保留期的记录
+type ActivityService struct {
+	repo   repository.ActivityRepository
+	config *Config
+	clock  clock.Clock
+}
+
+// NewActivityService 创建活动记录服务
+func NewActivityService(repo repository.ActivityRepository, config *Config) *ActivityService {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &ActivityService{
+		repo:   repo,
+		config: config,
+		clock:  clock.OrReal(nil),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (s *ActivityService) SetClock(c clock.Clock) {
+	s.clock = clock.OrReal(c)
+}
+
+// Subscribe 异步订阅领域事件，写入失败时由总线重试，重复投递的事件只记录一次
+func (s *ActivityService) Subscribe(bus *domainevent.Bus) error {
+	if err := bus.Subscribe("user-activity", s.Record, events.Async(), events.WithTopics(domainevent.Topics...)); err != nil {
+		return fmt.Errorf("failed to subscribe user activity: %w", err)
+	}
+	return nil
+}
+
+// Record 将领域事件写入发生用户的活动记录
+func (s *ActivityService) Record(ctx context.Context, event events.Event[domainevent.Event]) error {
+	payload := event.Payload
+	if payload.UserID == "" {
+		log.Printf("Ignoring %s event %s without user", event.Topic, event.ID)
+		return nil
+	}
+	occurredAt := payload.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = event.Timestamp
+	}
+	return s.repo.Insert(ctx, event.ID, &model.Activity{
+		TenantID:  payload.TenantID,
+		UserID:    payload.UserID,
+		Type:      event.Topic,
+		IP:        payload.IP,
+		UserAgent: payload.UserAgent,
+		Data:      payload.Data,
+		CreatedAt: occurredAt,
+	})
+}
+
+// Timeline 按发生时间倒序分页获取用户的活动记录
+func (s *ActivityService) Timeline(ctx context.Context, q model.Query) (*model.Page, error) {
+	if err := ValidateQuery(q); err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInvalidParam, "")
+	}
+
+	activities, err := s.repo.List(ctx, q, after, q.Limit+1)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	page := &model.Page{Activities: activities}
+	if len(activities) > q.Limit {
+		page.Activities, page.HasMore = activities[:q.Limit], true
+		last := page.Activities[len(page.Activities)-1]
+		if page.NextCursor, err = utils.EncodeCursor([]interface{}{last.CreatedAt.Format(time.RFC3339Nano), last.ID}); err != nil {
+			return nil, apperrors.Wrap(err, apperrors.CodeInternal, "")
+		}
+	}
+	return page, nil
+}
+
+// Export 将用户符合条件的全部活动记录写入 w，返回写出的条数；忽略 q 中的 Cursor 与 Limit
+func (s *ActivityService) Export(ctx context.Context, w spreadsheet.Writer, q model.Query) (int, error) {
+	if err := ValidateQuery(q); err != nil {
+		return 0, err
+	}
+	if err := w.Write(exportColumns); err != nil {
+		return 0, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	count := 0
+	var after *repository.Position
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		activities, err := s.repo.List(ctx, q, after, s.config.ExportPageSize)
+		if err != nil {
+			return count, err
+		}
+		for _, activity := range activities {
+			if err := w.Write(exportRecord(activity)); err != nil {
+				return count, fmt.Errorf("failed to write activity %s: %w", activity.ID, err)
+			}
+			count++
+		}
+		if len(activities) < s.config.ExportPageSize {
+			return count, nil
+		}
+		last := activities[len(activities)-1]
+		after = &repository.Position{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+func exportRecord(activity *model.Activity) []string {
+	pairs := make([]string, 0, len(activity.Data))
+	for key, value := range activity.Data {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return []string{
+		activity.ID,
+		activity.Type,
+		activity.IP,
+		activity.UserAgent,
+		strings.Join(pairs, ";"),
+		activity.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// Purge 删除超过保留期的记录，返回删除的条数
+func (s *ActivityService) Purge(ctx context.Context) (int64, error) {
+	before := s.clock.Now().Add(-s.config.Retention)
+	var total int64
+	for {
+		deleted, err := s.repo.DeleteBefore(ctx, before, s.config.RetentionBatch)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(s.config.RetentionBatch) {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Purged %d user activities before %s", total, before.Format(time.RFC3339))
+	}
+	return total, nil
+}
+
+// Register 注册并定时执行过期记录清理任务
+func (s *ActivityService) Register(manager *jobs.Manager) error {
+	manager.Register(RetentionJobType, func(ctx context.Context, job *jobs.Job) error {
+		_, err := s.Purge(ctx)
+		return err
+	})
+	if err := manager.Schedule(RetentionJobType, s.config.RetentionSchedule, RetentionJobType, nil, jobs.PriorityLow); err != nil {
+		return fmt.Errorf("failed to schedule user activity retention: %w", err)
+	}
+	return nil
+}
+
+// ValidateQuery 查询的类型须为已知的领域事件主题，起止时间均指定时起点须早于终点
+func ValidateQuery(q model.Query) error {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return apperrors.New(apperrors.CodeInvalidParam, "from must be before to")
+	}
+	for _, t := range q.Types {
+		known := false
+		for _, topic := range domainevent.Topics {
+			if t == topic {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return apperrors.New(apperrors.CodeInvalidParam, "").WithDetail("type", t)
+		}
+	}
+	return nil
+}
+
+// decodeCursor 游标为最后一条记录的 [created_at, id]
+func decodeCursor(cursor string) (*repository.Position, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	values, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, fmt.Sprint(values[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return &repository.Position{CreatedAt: createdAt, ID: fmt.Sprint(values[1])}, nil
+}
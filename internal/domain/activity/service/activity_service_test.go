@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/activity/model"
+	"user_crud_jwt/internal/domain/activity/repository"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/events"
+	"user_crud_jwt/pkg/spreadsheet"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryActivities 内存中的活动记录，按事件 ID 去重
+type memoryActivities struct {
+	mu         sync.Mutex
+	events     map[string]bool
+	activities []*model.Activity
+}
+
+func (m *memoryActivities) Insert(ctx context.Context, eventID string, activity *model.Activity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events[eventID] {
+		return nil
+	}
+	m.events[eventID] = true
+	copied := *activity
+	copied.ID = eventID
+	m.activities = append(m.activities, &copied)
+	return nil
+}
+
+func (m *memoryActivities) List(ctx context.Context, q model.Query, after *repository.Position, limit int) ([]*model.Activity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sorted := append([]*model.Activity{}, m.activities...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+		}
+		return sorted[i].ID > sorted[j].ID
+	})
+
+	var result []*model.Activity
+	for _, a := range sorted {
+		if a.UserID != q.UserID || (len(q.Types) > 0 && !contains(q.Types, a.Type)) ||
+			(!q.From.IsZero() && a.CreatedAt.Before(q.From)) || (!q.To.IsZero() && !a.CreatedAt.Before(q.To)) {
+			continue
+		}
+		if after != nil && (a.CreatedAt.After(after.CreatedAt) || (a.CreatedAt.Equal(after.CreatedAt) && a.ID >= after.ID)) {
+			continue
+		}
+		if len(result) == limit {
+			break
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+func (m *memoryActivities) DeleteBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kept []*model.Activity
+	var deleted int64
+	for _, a := range m.activities {
+		if a.CreatedAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+		kept = append(kept, a)
+	}
+	m.activities = kept
+	return deleted, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestActivityService_RecordTimelineAndPurge(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := &memoryActivities{events: make(map[string]bool)}
+	config := DefaultConfig()
+	config.RetentionBatch = 2
+	config.ExportPageSize = 2
+	activities := NewActivityService(repo, config)
+	clock := fakes.NewClock(start)
+	activities.SetClock(clock)
+
+	// 事件经由总线异步写入，客户端信息从请求 context 补全
+	bus := domainevent.NewBus(nil)
+	require.NoError(t, activities.Subscribe(bus))
+	ctx := ctxutil.WithClient(context.Background(), ctxutil.Client{IP: "10.0.0.1", UserAgent: "app/1.0"})
+	domainevent.Publish(ctx, bus, domainevent.TopicUserLoggedIn, domainevent.Event{UserID: "u1", OccurredAt: start})
+	domainevent.Publish(ctx, bus, domainevent.TopicUserProfileUpdated, domainevent.Event{UserID: "u1", Data: map[string]string{"fields": "nickname"}, OccurredAt: start.Add(time.Hour)})
+	domainevent.Publish(ctx, bus, domainevent.TopicCouponClaimed, domainevent.Event{UserID: "u1", Data: map[string]string{"coupon_id": "c1"}, OccurredAt: start.Add(2 * time.Hour)})
+	domainevent.Publish(ctx, bus, domainevent.TopicUserLoggedIn, domainevent.Event{UserID: "u2", OccurredAt: start})
+	domainevent.Publish(ctx, bus, domainevent.TopicUserLoggedIn, domainevent.Event{OccurredAt: start})
+	bus.Close()
+
+	// 重复投递的事件只记录一次
+	require.NoError(t, activities.Record(context.Background(), events.Event[domainevent.Event]{
+		ID: repo.activities[0].ID, Topic: domainevent.TopicUserLoggedIn, Payload: domainevent.Event{UserID: "u1"},
+	}))
+	require.Len(t, repo.activities, 4)
+
+	page, err := activities.Timeline(context.Background(), model.Query{UserID: "u1", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Activities, 2)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, domainevent.TopicCouponClaimed, page.Activities[0].Type)
+	assert.Equal(t, "c1", page.Activities[0].Data["coupon_id"])
+	assert.Equal(t, "10.0.0.1", page.Activities[0].IP)
+	assert.Equal(t, "app/1.0", page.Activities[0].UserAgent)
+
+	page, err = activities.Timeline(context.Background(), model.Query{UserID: "u1", Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Activities, 1)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, domainevent.TopicUserLoggedIn, page.Activities[0].Type)
+
+	page, err = activities.Timeline(context.Background(), model.Query{UserID: "u1", Limit: 10, Types: []string{domainevent.TopicUserProfileUpdated}})
+	require.NoError(t, err)
+	require.Len(t, page.Activities, 1)
+	assert.Equal(t, "nickname", page.Activities[0].Data["fields"])
+
+	_, err = activities.Timeline(context.Background(), model.Query{UserID: "u1", Limit: 10, Types: []string{"user.unknown"}})
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeInvalidParam))
+	_, err = activities.Timeline(context.Background(), model.Query{UserID: "u1", Limit: 10, Cursor: "not-a-cursor"})
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeInvalidParam))
+
+	// 导出跨越多页查询
+	var buf bytes.Buffer
+	writer, err := spreadsheet.NewWriter(&buf, spreadsheet.FormatCSV)
+	require.NoError(t, err)
+	count, err := activities.Export(context.Background(), writer, model.Query{UserID: "u1"})
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, 3, count)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Contains(t, lines[1], "coupon_id=c1")
+
+	// 保留 180 天，超过的分批删除
+	clock.Advance(180*24*time.Hour + 90*time.Minute)
+	purged, err := activities.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	page, err = activities.Timeline(context.Background(), model.Query{UserID: "u1", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Activities, 1)
+	assert.Equal(t, domainevent.TopicCouponClaimed, page.Activities[0].Type)
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+	"user_crud_jwt/internal/domain/admin/service"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxUploadSize 导入文件的大小上限
+const MaxUploadSize = 32 << 20
+
+// uploadField 上传文件的表单字段名
+const uploadField = "file"
+
+// BulkHandler 用户与优惠券的批量导入导出接口
+type BulkHandler struct {
+	service service.BulkService
+}
+
+// NewBulkHandler 创建批量导入导出处理器
+func NewBulkHandler(service service.BulkService) *BulkHandler {
+	return &BulkHandler{service: service}
+}
+
+// ImportQuery 导入参数
+type ImportQuery struct {
+	Format string `form:"format"`  // csv 或 xlsx，缺省时按上传文件的扩展名判断
+	DryRun bool   `form:"dry_run"` // 只校验不写入
+}
+
+// ExportQuery 导出参数
+type ExportQuery struct {
+	Format string `form:"format"` // csv（默认）或 xlsx
+}
+
+// UserExportQuery 用户导出参数
+type UserExportQuery struct {
+	ExportQuery
+	service.UserFilter
+}
+
+// CouponExportQuery 优惠券导出参数
+type CouponExportQuery struct {
+	ExportQuery
+	service.CouponFilter
+}
+
+type importFunc func(ctx context.Context, r spreadsheet.Reader, dryRun bool) (*service.ImportReport, error)
+
+// ImportUsers 批量导入用户
+func (h *BulkHandler) ImportUsers(c *gin.Context) {
+	h.importFile(c, h.service.ImportUsers)
+}
+
+// ImportCoupons 批量导入优惠券
+func (h *BulkHandler) ImportCoupons(c *gin.Context) {
+	h.importFile(c, h.service.ImportCoupons)
+}
+
+// importFile 从 multipart 请求中流式读取上传的文件，不落盘也不整体读入内存（XLSX 除外）
+func (h *BulkHandler) importFile(c *gin.Context, importFn importFunc) {
+	var query ImportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxUploadSize)
+	part, err := filePart(c.Request)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	defer part.Close()
+
+	name := query.Format
+	if name == "" {
+		name = part.FileName()
+	}
+	format, err := spreadsheet.ParseFormat(name)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	reader, err := spreadsheet.NewReader(part, format)
+	if err != nil {
+		response.Error(c, uploadErrorStatus(err), response.ErrInvalidParam, err.Error())
+		return
+	}
+	defer reader.Close()
+
+	report, err := importFn(c.Request.Context(), reader, query.DryRun)
+	if err != nil {
+		response.Error(c, uploadErrorStatus(err), response.ErrInvalidParam, err.Error())
+		return
+	}
+	response.Success(c, report)
+}
+
+// filePart 返回名为 file 的文件字段
+func filePart(r *http.Request) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("expected multipart/form-data upload: %w", err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing %q file field", uploadField)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload: %w", err)
+		}
+		if part.FormName() == uploadField {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// uploadErrorStatus 文件超过大小上限时返回 413，其余为文件内容错误
+func uploadErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// ExportUsers 导出用户
+func (h *BulkHandler) ExportUsers(c *gin.Context) {
+	var query UserExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	h.exportFile(c, query.ExportQuery, "users", func(ctx context.Context, w spreadsheet.Writer) (int, error) {
+		return h.service.ExportUsers(ctx, w, query.UserFilter)
+	})
+}
+
+// ExportCoupons 导出优惠券
+func (h *BulkHandler) ExportCoupons(c *gin.Context) {
+	var query CouponExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	h.exportFile(c, query.ExportQuery, "coupons", func(ctx context.Context, w spreadsheet.Writer) (int, error) {
+		return h.service.ExportCoupons(ctx, w, query.CouponFilter)
+	})
+}
+
+// exportFile 以附件形式边查询边写出。响应头写出后无法再返回错误响应，中途出错只记录日志，客户端得到截断的文件
+func (h *BulkHandler) exportFile(c *gin.Context, query ExportQuery, name string, exportFn func(ctx context.Context, w spreadsheet.Writer) (int, error)) {
+	format := spreadsheet.FormatCSV
+	if query.Format != "" {
+		var err error
+		if format, err = spreadsheet.ParseFormat(query.Format); err != nil {
+			response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	writer, err := spreadsheet.NewWriter(c.Writer, format)
+	if err != nil {
+		log.Printf("Failed to start %s export: %v", name, err)
+		return
+	}
+	count, err := exportFn(database.ReportQuery(c.Request.Context()), writer)
+	if err != nil {
+		writer.Close()
+		log.Printf("Failed to export %s after %d rows: %v", name, count, err)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("Failed to finish %s export: %v", name, err)
+	}
+}
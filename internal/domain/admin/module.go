@@ -0,0 +1,279 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	activityHandler "user_crud_jwt/internal/domain/activity/handler"
+	activityService "user_crud_jwt/internal/domain/activity/service"
+	"user_crud_jwt/internal/domain/admin/handler"
+	"user_crud_jwt/internal/domain/admin/service"
+	complianceHandler "user_crud_jwt/internal/domain/compliance/handler"
+	complianceService "user_crud_jwt/internal/domain/compliance/service"
+	couponHandler "user_crud_jwt/internal/domain/coupon/handler"
+	couponRepository "user_crud_jwt/internal/domain/coupon/repository"
+	couponService "user_crud_jwt/internal/domain/coupon/service"
+	userRepository "user_crud_jwt/internal/domain/user/repository"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/approvals"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/chaos"
+	"user_crud_jwt/pkg/dbadmin"
+	"user_crud_jwt/pkg/features"
+	"user_crud_jwt/pkg/openapi"
+	"user_crud_jwt/pkg/payment"
+	"user_crud_jwt/pkg/profiling"
+	"user_crud_jwt/pkg/reports"
+	"user_crud_jwt/pkg/retention"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/security/jwtkeys"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminModule 管理后台模块，提供敏感操作的双人审批、用户与优惠券的批量导入导出、用户活动记录查询与导出、数据导出与注销审计、功能开关、实验、角色与用户授权管理、安全事件查询与汇总、安全报告、IP 封禁、
+// 会话吊销、签名密钥管理、缓存运维与部署预热、定时报告、数据保留策略、数据库膨胀分析与锁监控、性能剖析与故障注入；修改类操作记录为审计事件
+type AdminModule struct{}
+
+func init() {
+	registry.Register(&AdminModule{})
+}
+
+func (m *AdminModule) Name() string {
+	return "admin"
+}
+
+func (m *AdminModule) Priority() int {
+	// 依赖用户、优惠券模块登记的服务
+	return 40
+}
+
+func (m *AdminModule) Init(ctx *registry.ModuleContext) error {
+	adminGroup := ctx.Router.Group("/admin")
+	// 运维工具以配置的 API Key（admin.api_keys）调用，其余调用方使用 JWT
+	adminGroup.Use(middleware.APIKeyOrAuthMiddleware(config.GlobalConfig.Admin.APIKeys), middleware.AdminMiddleware())
+
+	// 修改类操作记录为 admin_action 安全事件，须在注册路由之前挂载
+	svc, _ := ctx.Lookup(registry.SecurityMonitor)
+	monitor, _ := svc.(*security.SecurityMonitor)
+	if monitor != nil {
+		adminGroup.Use(monitor.AdminAuditMiddleware())
+	}
+
+	// 批量失效、角色与授权变更等敏感操作需要发起人以外的管理员批准，门禁须在注册路由之前挂载
+	svc, _ = ctx.Lookup(registry.Approvals)
+	if approvalManager, ok := svc.(*approvals.Manager); ok && approvalManager != nil {
+		adminGroup.Use(approvalManager.Middleware())
+		approvals.NewHandler(approvalManager).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 功能开关、实验与角色管理
+	svc, _ = ctx.Lookup(registry.FeatureFlags)
+	if featureManager, ok := svc.(*features.Manager); ok && featureManager != nil {
+		features.NewHandler(featureManager).RegisterAdminRoutes(adminGroup)
+	}
+	svc, _ = ctx.Lookup(registry.Experiments)
+	if experiments, ok := svc.(*features.Experiments); ok && experiments != nil {
+		features.NewExperimentHandler(experiments).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 角色与权限继承管理，用户角色与单独授权
+	svc, _ = ctx.Lookup(registry.PermissionChecker)
+	if rbac, ok := svc.(*security.RBAC); ok && rbac != nil {
+		security.NewRoleHandler(rbac).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 安全事件查询、导出与汇总
+	svc, _ = ctx.Lookup(registry.SecurityEvents)
+	if securityEvents, ok := svc.(security.SecurityEventStore); ok && securityEvents != nil {
+		security.NewSecurityEventHandler(securityEvents).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 安全报告与 WAF 规则统计
+	if monitor != nil {
+		security.NewSecurityMonitorHandler(monitor).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 按角色的请求配额查看与调整
+	svc, _ = ctx.Lookup(registry.RoleQuotas)
+	if roleQuotas, ok := svc.(*security.RoleQuotaManager); ok && roleQuotas != nil {
+		security.NewRoleQuotaHandler(roleQuotas).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 支付订单退款与主动对账
+	svc, _ = ctx.Lookup(registry.Payments)
+	if gateway, ok := svc.(*payment.Gateway); ok && gateway != nil {
+		payment.NewHandler(gateway).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 吊销用户已签发的令牌
+	svc, _ = ctx.Lookup(registry.SessionRevoker)
+	if revoker, ok := svc.(*security.SessionRevoker); ok && revoker != nil {
+		security.NewSessionHandler(revoker).RegisterAdminRoutes(adminGroup)
+	}
+	// 客服代登录的开始、查看与结束
+	svc, _ = ctx.Lookup(registry.Impersonation)
+	if impersonations, ok := svc.(*security.ImpersonationManager); ok && impersonations != nil {
+		security.NewImpersonationHandler(impersonations).RegisterAdminRoutes(adminGroup)
+	}
+
+	// IP 信誉查询、解封与访问规则
+	svc, _ = ctx.Lookup(registry.IPReputation)
+	if reputation, ok := svc.(*security.IPReputation); ok && reputation != nil {
+		security.NewIPReputationHandler(reputation).RegisterAdminRoutes(adminGroup)
+	}
+
+	// JWT 签名密钥查看与手动轮换，仅在启用密钥轮换时注册
+	svc, _ = ctx.Lookup(registry.TokenKeys)
+	if tokenKeys, ok := svc.(*jwtkeys.Manager); ok && tokenKeys != nil {
+		jwtkeys.NewHandler(tokenKeys).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 缓存查看、失效、预热与事件流
+	svc, _ = ctx.Lookup(registry.CacheAdmin)
+	if cacheAdmin, ok := svc.(*cache.Admin); ok && cacheAdmin != nil {
+		cache.NewAdminHandler(cacheAdmin).RegisterAdminRoutes(adminGroup)
+	}
+	// 部署预热任务组与就绪门禁状态
+	svc, _ = ctx.Lookup(registry.CachePreload)
+	if preloader, ok := svc.(*cache.Preloader); ok && preloader != nil {
+		cache.NewPreloadHandler(preloader).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 定时报告列表、预览与立即发送
+	svc, _ = ctx.Lookup(registry.Reports)
+	if scheduler, ok := svc.(*reports.Scheduler); ok && scheduler != nil {
+		reports.NewHandler(scheduler).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 数据保留策略、预演报告、立即执行与执行记录
+	svc, _ = ctx.Lookup(registry.Retention)
+	if engine, ok := svc.(*retention.Engine); ok && engine != nil {
+		retention.NewHandler(engine).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 表与索引膨胀分析与维护建议
+	svc, _ = ctx.Lookup(registry.DBBloat)
+	if bloat, ok := svc.(*dbadmin.BloatAnalyzer); ok && bloat != nil {
+		dbadmin.NewBloatHandler(bloat).RegisterAdminRoutes(adminGroup)
+	}
+	// 锁等待、死锁模式与处理建议
+	svc, _ = ctx.Lookup(registry.DBLocks)
+	if locks, ok := svc.(*dbadmin.LockMonitor); ok && locks != nil {
+		dbadmin.NewLockHandler(locks).RegisterAdminRoutes(adminGroup)
+	}
+
+	// pprof 接口与自动采集的性能剖析
+	profiling.RegisterPprofRoutes(adminGroup)
+	svc, _ = ctx.Lookup(registry.Profiler)
+	if profiler, ok := svc.(*profiling.Profiler); ok && profiler != nil {
+		profiling.NewHandler(profiler).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 缓存与数据库故障注入，仅非 prod 构建注册
+	svc, _ = ctx.Lookup(registry.FaultInjector)
+	if faults, ok := svc.(*chaos.Injector); ok && faults != nil {
+		chaos.NewHandler(faults).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 优惠券领取与叠加规则
+	svc, _ = ctx.Lookup(registry.CouponRules)
+	if rules, ok := svc.(*couponService.RuleEngine); ok && rules != nil {
+		couponHandler.NewRuleHandler(rules).RegisterAdminRoutes(adminGroup)
+	}
+	// 订单核销回调与活动结算
+	svc, _ = ctx.Lookup(registry.CouponRedemption)
+	if redemptions, ok := svc.(*couponService.RedemptionService); ok && redemptions != nil {
+		couponHandler.NewRedemptionHandler(redemptions).RegisterAdminRoutes(adminGroup)
+	}
+	// 用户活动记录
+	svc, _ = ctx.Lookup(registry.UserActivity)
+	if activities, ok := svc.(*activityService.ActivityService); ok && activities != nil {
+		activityHandler.NewActivityHandler(activities).RegisterAdminRoutes(adminGroup)
+	}
+	// 数据导出与注销的审计记录，代为申请与撤销注销
+	svc, _ = ctx.Lookup(registry.Compliance)
+	if compliance, ok := svc.(*complianceService.ComplianceService); ok && compliance != nil {
+		complianceHandler.NewComplianceHandler(compliance).RegisterAdminRoutes(adminGroup)
+	}
+
+	// 1. 依赖注入 - 从其他模块获取共享的服务实例
+	svc, _ = ctx.Lookup(registry.UserRepository)
+	users, ok1 := svc.(userRepository.UserRepository)
+	svc, _ = ctx.Lookup(registry.CouponService)
+	coupons, ok2 := svc.(couponService.CouponService)
+	svc, _ = ctx.Lookup(registry.CouponRepository)
+	couponRepo, ok3 := svc.(couponRepository.CouponRepository)
+	if !ok1 || !ok2 || !ok3 {
+		log.Printf("Admin bulk import/export disabled: user and coupon modules are required")
+		return nil
+	}
+
+	// 导入用户后失效用户列表的响应缓存
+	var invalidator cache.TagInvalidator
+	svc, _ = ctx.Lookup(registry.ResponseCache)
+	if responseCache, ok := svc.(*middleware.ResponseCache); ok && responseCache != nil {
+		invalidator = responseCache
+	}
+
+	bulkService := service.NewBulkService(users, coupons, couponRepo, invalidator, service.DefaultBulkConfig())
+	bulkHandler := handler.NewBulkHandler(bulkService)
+
+	// 2. 路由注册
+	setupRoutes(adminGroup, bulkHandler)
+
+	return nil
+}
+
+func setupRoutes(adminGroup *gin.RouterGroup, h *handler.BulkHandler) {
+	describeRoutes(h)
+
+	{
+		adminGroup.POST("/users/import", h.ImportUsers)
+		adminGroup.GET("/users/export", h.ExportUsers)
+		adminGroup.POST("/coupons/import", h.ImportCoupons)
+		adminGroup.GET("/coupons/export", h.ExportCoupons)
+	}
+}
+
+// describeRoutes 路由的 OpenAPI 注解
+func describeRoutes(h *handler.BulkHandler) {
+	importErrors := []int{http.StatusBadRequest, http.StatusForbidden, http.StatusRequestEntityTooLarge}
+	openapi.Describe(h.ImportUsers, openapi.Route{
+		Summary:     "批量导入用户",
+		Description: "multipart/form-data 上传，文件字段名为 file，支持 CSV 与 XLSX。表头须包含 mobile，可选 nickname、email、role。单行校验失败不影响其他行，失败行在报告中列出",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.ImportQuery{},
+		Response:    service.ImportReport{},
+		Errors:      importErrors,
+	})
+	openapi.Describe(h.ImportCoupons, openapi.Route{
+		Summary:     "批量导入优惠券",
+		Description: "multipart/form-data 上传，文件字段名为 file，支持 CSV 与 XLSX。表头须包含 name、total、amount、start_time、end_time",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.ImportQuery{},
+		Response:    service.ImportReport{},
+		Errors:      importErrors,
+	})
+	openapi.Describe(h.ExportUsers, openapi.Route{
+		Summary:     "导出用户",
+		Description: "以附件形式流式返回 CSV 或 XLSX 文件，参数错误时返回统一的 JSON 错误响应",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.UserExportQuery{},
+		Response:    "",
+		Raw:         true,
+	})
+	openapi.Describe(h.ExportCoupons, openapi.Route{
+		Summary:     "导出优惠券",
+		Description: "以附件形式流式返回 CSV 或 XLSX 文件，参数错误时返回统一的 JSON 错误响应",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.CouponExportQuery{},
+		Response:    "",
+		Raw:         true,
+	})
+}
@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	couponRepository "user_crud_jwt/internal/domain/coupon/repository"
+	couponService "user_crud_jwt/internal/domain/coupon/service"
+	userModel "user_crud_jwt/internal/domain/user/model"
+	userRepository "user_crud_jwt/internal/domain/user/repository"
+	userService "user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/spreadsheet"
+)
+
+// ErrMissingColumns 表头缺少必填列
+var ErrMissingColumns = errors.New("missing required columns")
+
+// ErrEmptyFile 文件没有表头
+var ErrEmptyFile = errors.New("file is empty")
+
+// timeLayouts 导入时支持的时间格式，不带时区的按服务器本地时间解析
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// exportTimeLayout 导出的时间格式，可直接重新导入
+const exportTimeLayout = time.RFC3339
+
+// BulkConfig 批量导入导出配置
+type BulkConfig struct {
+	MaxRows           int // 单次导入的最大数据行数，超出部分不导入
+	MaxReportedErrors int // 报告中最多列出的失败行数
+	ExportPageSize    int // 导出时每次从仓库读取的条数
+}
+
+// DefaultBulkConfig 默认配置
+func DefaultBulkConfig() BulkConfig {
+	return BulkConfig{
+		MaxRows:           50000,
+		MaxReportedErrors: 1000,
+		ExportPageSize:    500,
+	}
+}
+
+// RowError 导入失败的行
+type RowError struct {
+	Row    int               `json:"row"`    // 文件中的行号，表头为第 1 行
+	Errors map[string]string `json:"errors"` // 字段 -> 错误信息
+}
+
+// ImportReport 导入结果，部分行失败时其余行照常导入
+type ImportReport struct {
+	Total         int        `json:"total"`         // 读取的数据行数，不含空行
+	Succeeded     int        `json:"succeeded"`     // 导入成功（试运行时为校验通过）的行数
+	Failed        int        `json:"failed"`        // 失败的行数
+	DryRun        bool       `json:"dryRun"`        // 只校验不写入
+	LimitExceeded bool       `json:"limitExceeded"` // 数据行超过 MaxRows，超出部分未读取
+	Truncated     bool       `json:"truncated"`     // 失败行超过 MaxReportedErrors，errors 只列出前面的部分
+	Errors        []RowError `json:"errors"`
+}
+
+// UserFilter 用户导出条件
+type UserFilter struct {
+	Role   *int  `form:"role"`
+	Status *int  `form:"status"`
+	Member *bool `form:"member"`
+}
+
+// CouponFilter 优惠券导出条件
+type CouponFilter struct {
+	Active *bool `form:"active"` // true 只导出未结束的，false 只导出已结束的
+}
+
+// BulkService 用户与优惠券的批量导入导出
+type BulkService interface {
+	ImportUsers(ctx context.Context, r spreadsheet.Reader, dryRun bool) (*ImportReport, error)
+	ImportCoupons(ctx context.Context, r spreadsheet.Reader, dryRun bool) (*ImportReport, error)
+	// ExportUsers 分页读取并逐行写出，返回写出的数据行数
+	ExportUsers(ctx context.Context, w spreadsheet.Writer, filter UserFilter) (int, error)
+	ExportCoupons(ctx context.Context, w spreadsheet.Writer, filter CouponFilter) (int, error)
+}
+
+type bulkService struct {
+	users       userRepository.UserRepository
+	coupons     couponService.CouponService
+	couponRepo  couponRepository.CouponRepository
+	invalidator cache.TagInvalidator // 为 nil 时不失效响应缓存
+	config      BulkConfig
+}
+
+// NewBulkService 创建批量导入导出服务
+func NewBulkService(users userRepository.UserRepository, coupons couponService.CouponService, couponRepo couponRepository.CouponRepository, invalidator cache.TagInvalidator, config BulkConfig) BulkService {
+	return &bulkService{
+		users:       users,
+		coupons:     coupons,
+		couponRepo:  couponRepo,
+		invalidator: invalidator,
+		config:      config,
+	}
+}
+
+// importSchema 一类数据的导入规则
+type importSchema struct {
+	required   []string                                                                                  // 表头必须包含的列
+	validators *security.ValidatorSet                                                                    // 单字段校验
+	save       func(ctx context.Context, line int, row map[string]string, dryRun bool) map[string]string // 跨字段校验并写入，返回字段错误
+}
+
+var userColumns = []string{"id", "mobile", "email", "nickname", "role", "status", "is_member", "member_expire_at", "created_at"}
+
+var couponColumns = []string{"id", "name", "total", "stock", "amount", "start_time", "end_time", "created_at"}
+
+func (s *bulkService) ImportUsers(ctx context.Context, r spreadsheet.Reader, dryRun bool) (*ImportReport, error) {
+	validators := security.NewValidatorSet()
+	validators.AddRule("mobile", security.NewPhoneValidator(true, "CN"), "")
+	validators.AddRule("nickname", security.NewStringValidator(1, 50, false), "")
+	validators.AddRule("email", security.NewEmailValidator(false), "")
+	role := security.NewNumberValidator(false)
+	role.SetInteger(true)
+	role.SetMin(userModel.RoleUser)
+	role.SetMax(userModel.RoleAdmin)
+	validators.AddRule("role", role, "role must be 0 (user) or 1 (admin)")
+
+	// 同一文件中的重复手机号在试运行时也能发现
+	seen := make(map[string]int)
+	schema := &importSchema{
+		required:   []string{"mobile"},
+		validators: validators,
+		save: func(ctx context.Context, line int, row map[string]string, dryRun bool) map[string]string {
+			mobile := security.NewPhoneValidator(true, "CN").Sanitize(row["mobile"])
+			if first, ok := seen[mobile]; ok {
+				return map[string]string{"mobile": fmt.Sprintf("duplicate of row %d", first)}
+			}
+			existing, err := s.users.GetByMobile(ctx, mobile)
+			if err != nil {
+				return map[string]string{"mobile": fmt.Sprintf("failed to check mobile: %v", err)}
+			}
+			if existing != nil {
+				return map[string]string{"mobile": "mobile already registered"}
+			}
+			seen[mobile] = line
+
+			nickname := row["nickname"]
+			if nickname == "" {
+				nickname = "User_" + mobile[len(mobile)-4:]
+			}
+			user := userModel.NewUser(mobile, nickname)
+			user.Email = security.NewEmailValidator(false).Sanitize(row["email"])
+			if row["role"] != "" {
+				user.Role, _ = strconv.Atoi(row["role"])
+			}
+			if dryRun {
+				return nil
+			}
+			if err := s.users.Create(ctx, user); err != nil {
+				return map[string]string{"row": fmt.Sprintf("failed to create user: %v", err)}
+			}
+			return nil
+		},
+	}
+
+	report, err := s.importRows(ctx, r, schema, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && report.Succeeded > 0 && s.invalidator != nil {
+		if err := s.invalidator.InvalidateTags(ctx, userService.UsersCacheTag); err != nil {
+			log.Printf("Failed to invalidate user list cache after import: %v", err)
+		}
+	}
+	return report, nil
+}
+
+func (s *bulkService) ImportCoupons(ctx context.Context, r spreadsheet.Reader, dryRun bool) (*ImportReport, error) {
+	validators := security.NewValidatorSet()
+	validators.AddRule("name", security.NewStringValidator(1, 100, true), "")
+	total := security.NewNumberValidator(true)
+	total.SetInteger(true)
+	total.SetMin(1)
+	validators.AddRule("total", total, "total must be a positive integer")
+	amount := security.NewNumberValidator(true)
+	amount.SetMin(0.01)
+	validators.AddRule("amount", amount, "amount must be at least 0.01")
+	validators.AddRule("start_time", security.NewStringValidator(1, 64, true), "")
+	validators.AddRule("end_time", security.NewStringValidator(1, 64, true), "")
+
+	schema := &importSchema{
+		required:   []string{"name", "total", "amount", "start_time", "end_time"},
+		validators: validators,
+		save: func(ctx context.Context, line int, row map[string]string, dryRun bool) map[string]string {
+			errs := make(map[string]string)
+			startTime, err := parseTime(row["start_time"])
+			if err != nil {
+				errs["start_time"] = err.Error()
+			}
+			endTime, err := parseTime(row["end_time"])
+			if err != nil {
+				errs["end_time"] = err.Error()
+			}
+			if len(errs) == 0 && !endTime.After(startTime) {
+				errs["end_time"] = "end_time must be after start_time"
+			}
+			if len(errs) > 0 {
+				return errs
+			}
+
+			totalValue, _ := strconv.Atoi(row["total"])
+			amountValue, _ := strconv.ParseFloat(row["amount"], 64)
+			if dryRun {
+				return nil
+			}
+			if _, err := s.coupons.CreateCoupon(ctx, row["name"], totalValue, amountValue, startTime, endTime); err != nil {
+				return map[string]string{"row": fmt.Sprintf("failed to create coupon: %v", err)}
+			}
+			return nil
+		},
+	}
+	return s.importRows(ctx, r, schema, dryRun)
+}
+
+// importRows 读取表头后逐行校验并写入，单行失败记入报告，读取文件出错时中止
+func (s *bulkService) importRows(ctx context.Context, r spreadsheet.Reader, schema *importSchema, dryRun bool) (*ImportReport, error) {
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyFile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make([]string, len(header))
+	present := make(map[string]bool, len(header))
+	for i, name := range header {
+		columns[i] = strings.ToLower(strings.TrimSpace(name))
+		present[columns[i]] = true
+	}
+	var missing []string
+	for _, name := range schema.required {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+
+	report := &ImportReport{DryRun: dryRun, Errors: []RowError{}}
+	for line := 2; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row %d: %w", line, err)
+		}
+
+		row, blank := toRow(columns, record)
+		if blank {
+			continue
+		}
+		if report.Total >= s.config.MaxRows {
+			report.LimitExceeded = true
+			break
+		}
+		report.Total++
+
+		data := make(map[string]interface{}, len(row))
+		for name, value := range row {
+			if value != "" {
+				data[name] = value
+			}
+		}
+		result := schema.validators.Validate(data)
+		var errs map[string]string
+		if result.Valid {
+			errs = schema.save(ctx, line, row, dryRun)
+		} else {
+			errs = result.Errors
+		}
+
+		if len(errs) == 0 {
+			report.Succeeded++
+			continue
+		}
+		report.Failed++
+		if len(report.Errors) < s.config.MaxReportedErrors {
+			report.Errors = append(report.Errors, RowError{Row: line, Errors: errs})
+		} else {
+			report.Truncated = true
+		}
+	}
+	return report, nil
+}
+
+// toRow 按表头将一行转为列名到值的映射，所有列都为空时 blank 为 true
+func toRow(columns []string, record []string) (row map[string]string, blank bool) {
+	row = make(map[string]string, len(columns))
+	blank = true
+	for i, name := range columns {
+		if name == "" {
+			continue
+		}
+		var value string
+		if i < len(record) {
+			value = strings.TrimSpace(record[i])
+		}
+		if value != "" {
+			blank = false
+		}
+		row[name] = value
+	}
+	return row, blank
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 or 2006-01-02 15:04:05", value)
+}
+
+func (s *bulkService) ExportUsers(ctx context.Context, w spreadsheet.Writer, filter UserFilter) (int, error) {
+	if err := w.Write(userColumns); err != nil {
+		return 0, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	count := 0
+	for offset := 0; ; offset += s.config.ExportPageSize {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		users, err := s.users.List(ctx, s.config.ExportPageSize, offset)
+		if err != nil {
+			return count, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, user := range users {
+			if !filter.match(user) {
+				continue
+			}
+			if err := w.Write(userRecord(user)); err != nil {
+				return count, fmt.Errorf("failed to write user %s: %w", user.ID, err)
+			}
+			count++
+		}
+		if len(users) < s.config.ExportPageSize {
+			return count, nil
+		}
+	}
+}
+
+func (f UserFilter) match(user *userModel.User) bool {
+	if f.Role != nil && user.Role != *f.Role {
+		return false
+	}
+	if f.Status != nil && user.Status != *f.Status {
+		return false
+	}
+	if f.Member != nil && user.IsMember != *f.Member {
+		return false
+	}
+	return true
+}
+
+func userRecord(user *userModel.User) []string {
+	memberExpireAt := ""
+	if user.MemberExpireAt != nil {
+		memberExpireAt = user.MemberExpireAt.Format(exportTimeLayout)
+	}
+	return []string{
+		user.ID,
+		user.Mobile,
+		user.Email,
+		user.Nickname,
+		strconv.Itoa(user.Role),
+		strconv.Itoa(user.Status),
+		strconv.FormatBool(user.IsMember),
+		memberExpireAt,
+		user.CreatedAt.Format(exportTimeLayout),
+	}
+}
+
+func (s *bulkService) ExportCoupons(ctx context.Context, w spreadsheet.Writer, filter CouponFilter) (int, error) {
+	if err := w.Write(couponColumns); err != nil {
+		return 0, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	q := couponRepository.CouponQuery{Limit: s.config.ExportPageSize}
+	if filter.Active != nil {
+		now := time.Now()
+		if *filter.Active {
+			q.EndsAfter = &now
+		} else {
+			q.EndsBefore = &now
+		}
+	}
+
+	count := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		coupons, err := s.couponRepo.ListCoupons(ctx, q)
+		if err != nil {
+			return count, err
+		}
+		for _, coupon := range coupons {
+			record := []string{
+				coupon.ID,
+				coupon.Name,
+				strconv.Itoa(coupon.Total),
+				strconv.Itoa(coupon.Stock),
+				strconv.FormatFloat(coupon.Amount, 'f', 2, 64),
+				coupon.StartTime.Format(exportTimeLayout),
+				coupon.EndTime.Format(exportTimeLayout),
+				coupon.CreatedAt.Format(exportTimeLayout),
+			}
+			if err := w.Write(record); err != nil {
+				return count, fmt.Errorf("failed to write coupon %s: %w", coupon.ID, err)
+			}
+			count++
+		}
+		if len(coupons) < q.Limit {
+			return count, nil
+		}
+		q.AfterID = coupons[len(coupons)-1].ID
+	}
+}
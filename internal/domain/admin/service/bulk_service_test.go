@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+	couponModel "user_crud_jwt/internal/domain/coupon/model"
+	couponService "user_crud_jwt/internal/domain/coupon/service"
+	userRepository "user_crud_jwt/internal/domain/user/repository"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/spreadsheet"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingCoupons 记录创建的优惠券及创建时 ctx 中的租户
+type recordingCoupons struct {
+	couponService.CouponService
+	created []*couponModel.Coupon
+	tenants []string
+}
+
+func (s *recordingCoupons) CreateCoupon(ctx context.Context, name string, total int, amount float64, startTime, endTime time.Time) (*couponModel.Coupon, error) {
+	coupon := &couponModel.Coupon{Name: name, Total: total, Stock: total, Amount: amount, StartTime: startTime, EndTime: endTime}
+	s.created = append(s.created, coupon)
+	s.tenants = append(s.tenants, ctxutil.TenantID(ctx))
+	return coupon, nil
+}
+
+func newTestBulkService(config BulkConfig) (BulkService, userRepository.UserRepository, *recordingCoupons) {
+	users := userRepository.NewSimpleUserRepository(nil)
+	coupons := &recordingCoupons{}
+	return NewBulkService(users, coupons, nil, nil, config), users, coupons
+}
+
+func csvReader(t *testing.T, content string) spreadsheet.Reader {
+	t.Helper()
+	r, err := spreadsheet.NewReader(strings.NewReader(content), spreadsheet.FormatCSV)
+	require.NoError(t, err)
+	return r
+}
+
+func xlsxReader(t *testing.T, rows [][]string) spreadsheet.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := spreadsheet.NewWriter(&buf, spreadsheet.FormatXLSX)
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	r, err := spreadsheet.NewReader(&buf, spreadsheet.FormatXLSX)
+	require.NoError(t, err)
+	return r
+}
+
+// errorRows 报告中失败的行号到出错字段
+func errorRows(report *ImportReport) map[int][]string {
+	rows := make(map[int][]string, len(report.Errors))
+	for _, rowErr := range report.Errors {
+		for field := range rowErr.Errors {
+			rows[rowErr.Row] = append(rows[rowErr.Row], field)
+		}
+	}
+	return rows
+}
+
+// TestImportUsers_RowErrors 每行独立校验，失败行按文件行号与字段报告，其余行照常导入；空行跳过但计入行号
+func TestImportUsers_RowErrors(t *testing.T) {
+	svc, users, _ := newTestBulkService(DefaultBulkConfig())
+	ctx := context.Background()
+	existing, err := svc.ImportUsers(ctx, csvReader(t, "mobile\n13900000009\n"), false)
+	require.NoError(t, err)
+	require.Equal(t, 1, existing.Succeeded)
+
+	content := "\ufeff Mobile ,Nickname,EMAIL,role\n" +
+		"13800000001,alice,alice@example.com,0\n" + // 2
+		"12345,bob,,\n" + // 3 手机号无效
+		"13800000003,carol,not-an-email,\n" + // 4 邮箱无效
+		"13800000004,dave,,5\n" + // 5 角色越界
+		",,,\n" + // 6 空行
+		"13800000001,alice2,,\n" + // 7 与第 2 行重复
+		"13900000009,erin,,\n" + // 8 已注册
+		"13800000005\n" // 9 缺少的列按空值处理
+	report, err := svc.ImportUsers(ctx, csvReader(t, content), false)
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.Total)
+	assert.Equal(t, 2, report.Succeeded)
+	assert.Equal(t, 5, report.Failed)
+	assert.False(t, report.Truncated)
+	assert.Equal(t, map[int][]string{
+		3: {"mobile"},
+		4: {"email"},
+		5: {"role"},
+		7: {"mobile"},
+		8: {"mobile"},
+	}, errorRows(report))
+	assert.Equal(t, "role must be 0 (user) or 1 (admin)", report.Errors[2].Errors["role"])
+	assert.Equal(t, "duplicate of row 2", report.Errors[3].Errors["mobile"])
+	assert.Equal(t, "mobile already registered", report.Errors[4].Errors["mobile"])
+
+	user, err := users.GetByMobile(ctx, "13800000001")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "alice", user.Nickname)
+	assert.Equal(t, "alice@example.com", user.Email)
+	user, err = users.GetByMobile(ctx, "13800000005")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "User_0005", user.Nickname, "missing nicknames default to the mobile suffix")
+	for _, mobile := range []string{"13800000003", "13800000004"} {
+		user, err := users.GetByMobile(ctx, mobile)
+		require.NoError(t, err)
+		assert.Nil(t, user, "invalid rows are not imported")
+	}
+}
+
+// TestImportUsers_DryRun 试运行只校验不写入，文件内重复仍能发现
+func TestImportUsers_DryRun(t *testing.T) {
+	svc, users, _ := newTestBulkService(DefaultBulkConfig())
+	ctx := context.Background()
+
+	report, err := svc.ImportUsers(ctx, csvReader(t, "mobile\n13800000001\n13800000001\n"), true)
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, map[int][]string{3: {"mobile"}}, errorRows(report))
+	user, err := users.GetByMobile(ctx, "13800000001")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+// TestImportUsers_Tenant 导入的用户属于 ctx 中的租户，手机号只在租户内去重；没有租户时属于默认租户
+func TestImportUsers_Tenant(t *testing.T) {
+	svc, users, _ := newTestBulkService(DefaultBulkConfig())
+	acme := ctxutil.WithTenantID(context.Background(), "acme")
+	globex := ctxutil.WithTenantID(context.Background(), "globex")
+	const content = "mobile,nickname\n13800000001,alice\n"
+
+	report, err := svc.ImportUsers(acme, csvReader(t, content), false)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Succeeded)
+	report, err = svc.ImportUsers(globex, csvReader(t, content), false)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Succeeded, "the same mobile may exist in another tenant")
+	report, err = svc.ImportUsers(acme, csvReader(t, content), false)
+	require.NoError(t, err)
+	assert.Equal(t, map[int][]string{2: {"mobile"}}, errorRows(report))
+
+	for _, tenant := range []string{"acme", "globex"} {
+		user, err := users.GetByMobile(ctxutil.WithTenantID(context.Background(), tenant), "13800000001")
+		require.NoError(t, err)
+		require.NotNil(t, user, tenant)
+		assert.Equal(t, tenant, user.TenantID)
+	}
+
+	report, err = svc.ImportUsers(context.Background(), csvReader(t, "mobile\n13800000002\n"), false)
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Succeeded)
+	user, err := users.GetByMobile(ctxutil.WithTenantID(context.Background(), ctxutil.DefaultTenantID), "13800000002")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, ctxutil.DefaultTenantID, user.TenantID)
+}
+
+// TestImportCoupons_XLSX 从 XLSX 导入优惠券：跨字段校验时间，写入时使用 ctx 中的租户
+func TestImportCoupons_XLSX(t *testing.T) {
+	svc, _, coupons := newTestBulkService(DefaultBulkConfig())
+	ctx := ctxutil.WithTenantID(context.Background(), "acme")
+
+	report, err := svc.ImportCoupons(ctx, xlsxReader(t, [][]string{
+		{"name", "total", "amount", "start_time", "end_time"},
+		{"spring", "100", "9.90", "2026-03-01", "2026-03-31 23:59:59"},
+		{"bad-total", "-1", "5", "2026-03-01", "2026-03-31"},
+		{"reversed", "10", "5", "2026-04-01", "2026-03-01"},
+		{"bad-time", "10", "5", "tomorrow", "2026-03-01"},
+		{"", "", "", "", ""},
+		{"", "10", "0", "2026-03-01", "2026-03-31"},
+		{"summer", "50", "20", "2026-06-01T00:00:00Z", "2026-08-31T23:59:59Z"},
+	}), false)
+	require.NoError(t, err)
+	assert.Equal(t, 6, report.Total)
+	assert.Equal(t, 2, report.Succeeded)
+	rows := errorRows(report)
+	assert.Equal(t, []string{"total"}, rows[3])
+	assert.Equal(t, []string{"end_time"}, rows[4])
+	assert.Equal(t, []string{"start_time"}, rows[5])
+	assert.ElementsMatch(t, []string{"name", "amount"}, rows[7])
+	assert.Equal(t, "end_time must be after start_time", report.Errors[1].Errors["end_time"])
+
+	require.Len(t, coupons.created, 2)
+	assert.Equal(t, "spring", coupons.created[0].Name)
+	assert.Equal(t, 9.9, coupons.created[0].Amount)
+	assert.Equal(t, time.Date(2026, 3, 31, 23, 59, 59, 0, time.Local), coupons.created[0].EndTime)
+	assert.Equal(t, time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), coupons.created[1].StartTime.UTC())
+	assert.Equal(t, []string{"acme", "acme"}, coupons.tenants)
+}
+
+// TestImport_Malformed 没有表头、缺少必填列或文件无法解析时整体失败
+func TestImport_Malformed(t *testing.T) {
+	svc, users, _ := newTestBulkService(DefaultBulkConfig())
+	ctx := context.Background()
+
+	_, err := svc.ImportUsers(ctx, csvReader(t, ""), false)
+	assert.ErrorIs(t, err, ErrEmptyFile)
+	_, err = svc.ImportCoupons(ctx, csvReader(t, "name,amount\n"), false)
+	assert.ErrorIs(t, err, ErrMissingColumns)
+	assert.ErrorContains(t, err, "total, start_time, end_time")
+
+	_, err = svc.ImportUsers(ctx, csvReader(t, "mobile,nickname\n13800000001,alice\n13800000002,\"bob\n"), false)
+	assert.ErrorContains(t, err, "failed to read row 3")
+	user, err := users.GetByMobile(ctx, "13800000001")
+	require.NoError(t, err)
+	assert.NotNil(t, user, "rows before the malformed one are already imported")
+}
+
+// TestImport_Limits 超过 MaxRows 的行不读取，失败行超过 MaxReportedErrors 时报告被截断
+func TestImport_Limits(t *testing.T) {
+	config := DefaultBulkConfig()
+	config.MaxRows = 3
+	config.MaxReportedErrors = 1
+	svc, _, _ := newTestBulkService(config)
+
+	report, err := svc.ImportUsers(context.Background(), csvReader(t, "mobile\nx1\nx2\n13800000001\n13800000002\n"), false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Total)
+	assert.True(t, report.LimitExceeded)
+	assert.Equal(t, 1, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+	assert.True(t, report.Truncated)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 2, report.Errors[0].Row)
+}
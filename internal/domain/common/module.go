@@ -4,6 +4,8 @@ import (
 	commonHandler "user_crud_jwt/internal/pkg/common"
 	"user_crud_jwt/internal/pkg/middleware"
 	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/features"
+	"user_crud_jwt/pkg/security"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,12 +26,29 @@ func (m *CommonModule) Priority() int {
 }
 
 func (m *CommonModule) Init(ctx *registry.ModuleContext) error {
-	// 注册通用路由
-	setupRoutes(ctx.Router)
+	// 注册通用路由，上传的文件先经过大小、类型、图片尺寸检查并重新编码，被拒绝的上传记录为安全事件
+	svc, _ := ctx.Lookup(registry.SecurityMonitor)
+	monitor, _ := svc.(*security.SecurityMonitor)
+	setupRoutes(ctx.Router, security.NewUploadGuard(nil, nil, monitor))
+
+	// 当前用户的功能开关求值结果与实验分组，代登录期间查看与结束当前会话
+	authed := ctx.Router.Group("", middleware.AuthMiddleware())
+	svc, _ = ctx.Lookup(registry.FeatureFlags)
+	if featureManager, ok := svc.(*features.Manager); ok && featureManager != nil {
+		features.NewHandler(featureManager).RegisterRoutes(authed)
+	}
+	svc, _ = ctx.Lookup(registry.Experiments)
+	if experiments, ok := svc.(*features.Experiments); ok && experiments != nil {
+		features.NewExperimentHandler(experiments).RegisterRoutes(authed)
+	}
+	svc, _ = ctx.Lookup(registry.Impersonation)
+	if impersonations, ok := svc.(*security.ImpersonationManager); ok && impersonations != nil {
+		security.NewImpersonationHandler(impersonations).RegisterRoutes(authed)
+	}
 	return nil
 }
 
-func setupRoutes(r *gin.Engine) {
+func setupRoutes(r *gin.Engine, guard *security.UploadGuard) {
 	// 文件上传接口
-	r.POST("/upload", middleware.AuthMiddleware(), commonHandler.UploadFile)
+	r.POST("/upload", middleware.AuthMiddleware(), guard.Middleware("files"), commonHandler.UploadFile)
 }
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"user_crud_jwt/internal/domain/compliance/service"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeletionInput 注销申请参数
+type DeletionInput struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// ComplianceHandler 数据导出与账号注销接口
+type ComplianceHandler struct {
+	compliance *service.ComplianceService
+}
+
+// NewComplianceHandler 创建数据导出与账号注销接口
+func NewComplianceHandler(compliance *service.ComplianceService) *ComplianceHandler {
+	return &ComplianceHandler{compliance: compliance}
+}
+
+// RegisterAdminRoutes 注册按用户查询审计记录与代为申请注销的路由，调用方需挂载管理员权限校验
+func (h *ComplianceHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/users/:id/compliance/audit", h.GetUserAudit)
+	group.POST("/users/:id/deletion", h.RequestUserDeletion)
+	group.DELETE("/users/:id/deletion", h.CancelUserDeletion)
+}
+
+// RequestExport 申请导出当前用户的数据，归档由后台任务生成
+func (h *ComplianceHandler) RequestExport(c *gin.Context) {
+	userID := c.GetString("userID")
+	export, err := h.compliance.RequestExport(c.Request.Context(), userID, userID)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Accepted(c, export)
+}
+
+// ListExports 当前用户最近的导出记录
+func (h *ComplianceHandler) ListExports(c *gin.Context) {
+	exports, err := h.compliance.ListExports(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, exports)
+}
+
+// DownloadExport 以附件形式下载已生成的 ZIP 归档
+func (h *ComplianceHandler) DownloadExport(c *gin.Context) {
+	userID := c.GetString("userID")
+	export, archive, err := h.compliance.DownloadExport(c.Request.Context(), userID, c.Param("id"), userID)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("data-export-%s.zip", export.RequestedAt.Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// RequestDeletion 申请注销当前账号，冷静期内可撤销
+func (h *ComplianceHandler) RequestDeletion(c *gin.Context) {
+	userID := c.GetString("userID")
+	h.requestDeletion(c, userID, userID)
+}
+
+// RequestUserDeletion 管理员代为申请注销指定用户
+func (h *ComplianceHandler) RequestUserDeletion(c *gin.Context) {
+	h.requestDeletion(c, c.Param("id"), c.GetString("userID"))
+}
+
+// GetDeletion 当前用户待执行的注销申请
+func (h *ComplianceHandler) GetDeletion(c *gin.Context) {
+	deletion, err := h.compliance.DeletionStatus(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, deletion)
+}
+
+// CancelDeletion 撤销当前用户的注销申请
+func (h *ComplianceHandler) CancelDeletion(c *gin.Context) {
+	userID := c.GetString("userID")
+	h.cancelDeletion(c, userID, userID)
+}
+
+// CancelUserDeletion 管理员撤销指定用户的注销申请
+func (h *ComplianceHandler) CancelUserDeletion(c *gin.Context) {
+	h.cancelDeletion(c, c.Param("id"), c.GetString("userID"))
+}
+
+// GetUserAudit 指定用户的数据导出与注销审计记录
+func (h *ComplianceHandler) GetUserAudit(c *gin.Context) {
+	entries, err := h.compliance.AuditTrail(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, entries)
+}
+
+func (h *ComplianceHandler) requestDeletion(c *gin.Context, userID, actor string) {
+	var input DeletionInput
+	// 请求体可省略
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+			return
+		}
+	}
+
+	deletion, err := h.compliance.RequestDeletion(c.Request.Context(), userID, actor, input.Reason)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Accepted(c, deletion)
+}
+
+func (h *ComplianceHandler) cancelDeletion(c *gin.Context, userID, actor string) {
+	if err := h.compliance.CancelDeletion(c.Request.Context(), userID, actor); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, gin.H{"cancelled": true})
+}
@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+// ExportStatus 数据导出状态
+type ExportStatus string
+
+const (
+	ExportPending ExportStatus = "pending" // 等待后台任务生成
+	ExportReady   ExportStatus = "ready"   // 可下载
+	ExportFailed  ExportStatus = "failed"
+	ExportExpired ExportStatus = "expired" // 超过下载期限，归档已删除
+)
+
+// Export 用户数据导出，归档为 ZIP，每个数据分区一个 JSON 文件
+type Export struct {
+	ID          string       `db:"id" json:"id"`
+	TenantID    string       `db:"tenant_id" json:"-"`
+	UserID      string       `db:"user_id" json:"userId"`
+	Status      ExportStatus `db:"status" json:"status"`
+	Error       string       `db:"error" json:"error,omitempty"`
+	Size        int64        `db:"size" json:"size"` // 归档字节数
+	RequestedAt time.Time    `db:"requested_at" json:"requestedAt"`
+	CompletedAt *time.Time   `db:"completed_at" json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time   `db:"expires_at" json:"expiresAt,omitempty"` // 归档在此之后删除
+}
+
+// DeletionStatus 注销申请状态
+type DeletionStatus string
+
+const (
+	DeletionScheduled DeletionStatus = "scheduled" // 冷静期内，可撤销
+	DeletionCancelled DeletionStatus = "cancelled"
+	DeletionCompleted DeletionStatus = "completed" // 已匿名化
+)
+
+// Deletion 账号注销申请，冷静期结束后由后台任务匿名化用户数据
+type Deletion struct {
+	ID           string         `db:"id" json:"id"`
+	TenantID     string         `db:"tenant_id" json:"-"`
+	UserID       string         `db:"user_id" json:"userId"`
+	Status       DeletionStatus `db:"status" json:"status"`
+	Reason       string         `db:"reason" json:"reason,omitempty"`
+	RequestedBy  string         `db:"requested_by" json:"requestedBy"` // 本人或管理员的用户 ID
+	RequestedAt  time.Time      `db:"requested_at" json:"requestedAt"`
+	ScheduledFor time.Time      `db:"scheduled_for" json:"scheduledFor"` // 冷静期结束时间
+	CancelledAt  *time.Time     `db:"cancelled_at" json:"cancelledAt,omitempty"`
+	CompletedAt  *time.Time     `db:"completed_at" json:"completedAt,omitempty"`
+}
+
+// 审计动作
+const (
+	ActionExportRequested   = "export_requested"
+	ActionExportCompleted   = "export_completed"
+	ActionExportFailed      = "export_failed"
+	ActionExportDownloaded  = "export_downloaded"
+	ActionDeletionRequested = "deletion_requested"
+	ActionDeletionCancelled = "deletion_cancelled"
+	ActionDeletionCompleted = "deletion_completed"
+)
+
+// AuditEntry 数据导出与注销流程的审计记录，用户匿名化后仍保留
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	TenantID  string                 `json:"-"`
+	UserID    string                 `json:"userId"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"` // 操作者用户 ID，后台任务为 system
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+	CreatedAt time.Time              `json:"createdAt"`
+}
+
+// ActorSystem 后台任务执行的操作
+const ActorSystem = "system"
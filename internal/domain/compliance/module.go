@@ -0,0 +1,182 @@
+package compliance
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"user_crud_jwt/internal/domain/compliance/handler"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/internal/domain/compliance/repository"
+	"user_crud_jwt/internal/domain/compliance/service"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/openapi"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ComplianceModule 用户数据导出与账号注销模块
+type ComplianceModule struct{}
+
+func init() {
+	registry.Register(&ComplianceModule{})
+}
+
+func (m *ComplianceModule) Name() string {
+	return "compliance"
+}
+
+func (m *ComplianceModule) Priority() int {
+	// 先于管理模块初始化，管理模块注册审计查询与代为注销的接口
+	return 36
+}
+
+func (m *ComplianceModule) Init(ctx *registry.ModuleContext) error {
+	// 1. 依赖注入
+	// 注销后失效引用该用户的响应缓存，并吊销已签发的令牌
+	var invalidator cache.TagInvalidator
+	svc, _ := ctx.Lookup(registry.ResponseCache)
+	if responseCache, ok := svc.(*middleware.ResponseCache); ok && responseCache != nil {
+		invalidator = responseCache
+	}
+	var revoker service.SessionRevoker
+	svc, _ = ctx.Lookup(registry.SessionRevoker)
+	if sessionRevoker, ok := svc.(*security.SessionRevoker); ok && sessionRevoker != nil {
+		revoker = sessionRevoker
+	}
+
+	compliance := service.NewComplianceService(
+		repository.NewSQLExportRepository(ctx.DB),
+		repository.NewSQLDeletionRepository(ctx.DB),
+		repository.NewSQLAuditLog(ctx.DB),
+		invalidator, revoker, loadConfig(),
+	)
+
+	// 导出归档与到期注销均由后台任务执行
+	svc, _ = ctx.Lookup(registry.Jobs)
+	if manager, ok := svc.(*jobs.Manager); ok && manager != nil {
+		if err := compliance.Register(manager); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Job manager unavailable, data exports and account deletions will not be processed")
+	}
+	ctx.Provide(registry.Compliance, compliance)
+
+	// 2. 路由注册
+	setupRoutes(ctx.Router, handler.NewComplianceHandler(compliance))
+
+	return nil
+}
+
+// loadConfig 未配置的项使用默认值
+func loadConfig() *service.Config {
+	cfg := config.GlobalConfig.Compliance
+	complianceConfig := service.DefaultConfig()
+	if cfg.GracePeriodDays > 0 {
+		complianceConfig.GracePeriod = time.Duration(cfg.GracePeriodDays) * 24 * time.Hour
+	}
+	if cfg.ExportRetentionDays > 0 {
+		complianceConfig.ExportRetention = time.Duration(cfg.ExportRetentionDays) * 24 * time.Hour
+	}
+	if cfg.ExportCooldownHours > 0 {
+		complianceConfig.ExportCooldown = time.Duration(cfg.ExportCooldownHours) * time.Hour
+	}
+	return complianceConfig
+}
+
+func setupRoutes(r *gin.Engine, h *handler.ComplianceHandler) {
+	describeRoutes(h)
+
+	// 受保护的路由
+	complianceGroup := r.Group("/compliance")
+	complianceGroup.Use(middleware.AuthMiddleware())
+	{
+		complianceGroup.POST("/exports", h.RequestExport)
+		complianceGroup.GET("/exports", h.ListExports)
+		complianceGroup.GET("/exports/:id/download", h.DownloadExport)
+		complianceGroup.POST("/deletion", h.RequestDeletion)
+		complianceGroup.GET("/deletion", h.GetDeletion)
+		complianceGroup.DELETE("/deletion", h.CancelDeletion)
+	}
+}
+
+// describeRoutes 路由的 OpenAPI 注解
+func describeRoutes(h *handler.ComplianceHandler) {
+	openapi.Describe(h.RequestExport, openapi.Route{
+		Summary:     "申请导出当前用户的数据",
+		Description: "后台任务生成 ZIP 归档，包含资料、领券、订单、动态、评论、点赞、关注、通知偏好与活动记录，每个分区一个 JSON 文件。已有生成中的导出返回 409，距上次申请不足冷却时间返回 429",
+		Tags:        []string{"Compliance"},
+		Auth:        true,
+		Status:      http.StatusAccepted,
+		Response:    model.Export{},
+		Errors:      []int{http.StatusConflict, http.StatusTooManyRequests},
+	})
+	openapi.Describe(h.ListExports, openapi.Route{
+		Summary:  "当前用户最近的数据导出",
+		Tags:     []string{"Compliance"},
+		Auth:     true,
+		Response: []model.Export{},
+	})
+	openapi.Describe(h.DownloadExport, openapi.Route{
+		Summary:     "下载数据导出归档",
+		Description: "以附件形式返回 application/zip，尚未生成或已过期时返回 409",
+		Tags:        []string{"Compliance"},
+		Auth:        true,
+		Response:    "",
+		Raw:         true,
+		Errors:      []int{http.StatusNotFound, http.StatusConflict},
+	})
+	openapi.Describe(h.RequestDeletion, openapi.Route{
+		Summary:     "申请注销当前账号",
+		Description: "冷静期结束后匿名化用户数据并吊销全部令牌，订单与领券记录作为交易凭证保留。冷静期内可撤销",
+		Tags:        []string{"Compliance"},
+		Auth:        true,
+		Status:      http.StatusAccepted,
+		Body:        handler.DeletionInput{},
+		Response:    model.Deletion{},
+		Errors:      []int{http.StatusBadRequest, http.StatusConflict},
+	})
+	openapi.Describe(h.GetDeletion, openapi.Route{
+		Summary:  "当前账号待执行的注销申请",
+		Tags:     []string{"Compliance"},
+		Auth:     true,
+		Response: model.Deletion{},
+		Errors:   []int{http.StatusNotFound},
+	})
+	openapi.Describe(h.CancelDeletion, openapi.Route{
+		Summary:  "撤销注销申请",
+		Tags:     []string{"Compliance"},
+		Auth:     true,
+		Response: map[string]bool{},
+		Errors:   []int{http.StatusNotFound},
+	})
+	openapi.Describe(h.GetUserAudit, openapi.Route{
+		Summary:     "指定用户的数据导出与注销审计记录",
+		Description: "用户匿名化后审计记录仍保留",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Response:    []model.AuditEntry{},
+	})
+	openapi.Describe(h.RequestUserDeletion, openapi.Route{
+		Summary:     "代为申请注销指定用户",
+		Description: "与用户本人申请相同，冷静期结束后执行",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Status:      http.StatusAccepted,
+		Body:        handler.DeletionInput{},
+		Response:    model.Deletion{},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+	})
+	openapi.Describe(h.CancelUserDeletion, openapi.Route{
+		Summary:  "撤销指定用户的注销申请",
+		Tags:     []string{"Admin"},
+		Auth:     true,
+		Response: map[string]bool{},
+		Errors:   []int{http.StatusNotFound},
+	})
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrUserNotFound 用户不存在或已注销
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrDeletionScheduled 用户已有待执行的注销申请
+var ErrDeletionScheduled = errors.New("account deletion already scheduled")
+
+// AnonymizedName 匿名化后用户的昵称
+const AnonymizedName = "已注销用户"
+
+// Anonymized 匿名化结果：各表处理的行数，以及被清除的动态和评论所在的动态 ID（用于失效评论缓存）
+type Anonymized struct {
+	Rows    map[string]int64
+	PostIDs []string
+}
+
+// DeletionRepository 注销申请与匿名化
+type DeletionRepository interface {
+	// Schedule 创建注销申请，用户不存在时返回 ErrUserNotFound，已有待执行的申请时返回 ErrDeletionScheduled
+	Schedule(ctx context.Context, deletion *model.Deletion) error
+	// Active 获取用户待执行的申请，没有时返回 ErrNotFound
+	Active(ctx context.Context, userID string) (*model.Deletion, error)
+	// Cancel 撤销待执行的申请，返回是否撤销
+	Cancel(ctx context.Context, id string, at time.Time) (bool, error)
+	// Due 列出冷静期已结束的申请
+	Due(ctx context.Context, now time.Time, limit int) ([]*model.Deletion, error)
+	// Anonymize 在一个事务中匿名化用户及其关联数据，并将申请标记为已完成
+	Anonymize(ctx context.Context, deletion *model.Deletion, at time.Time) (*Anonymized, error)
+}
+
+// SQLDeletionRepository 基于 account_deletions 表的注销仓库
+type SQLDeletionRepository struct {
+	db *database.DB
+}
+
+// NewSQLDeletionRepository 创建注销仓库
+func NewSQLDeletionRepository(db *database.DB) *SQLDeletionRepository {
+	return &SQLDeletionRepository{db: db}
+}
+
+const deletionColumns = `id, tenant_id, user_id, status, reason, requested_by, requested_at, scheduled_for, cancelled_at, completed_at`
+
+func (r *SQLDeletionRepository) Schedule(ctx context.Context, deletion *model.Deletion) error {
+	if deletion.TenantID == "" {
+		deletion.TenantID = database.TenantForWrite(ctx)
+	}
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO account_deletions (id, tenant_id, user_id, status, reason, requested_by, requested_at, scheduled_for)
+		SELECT $1, $2, id, $4, $5, $6, $7, $8 FROM users WHERE id = $3 AND deleted_at IS NULL`,
+		deletion.ID, deletion.TenantID, deletion.UserID, deletion.Status, deletion.Reason, deletion.RequestedBy, deletion.RequestedAt, deletion.ScheduledFor)
+	if database.IsUniqueViolation(err, "uniq_account_deletions_scheduled") {
+		return ErrDeletionScheduled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to schedule account deletion: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to schedule account deletion: %w", err)
+	}
+	if affected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *SQLDeletionRepository) Active(ctx context.Context, userID string) (*model.Deletion, error) {
+	var deletion model.Deletion
+	err := r.db.GetContext(ctx, &deletion, `
+		SELECT `+deletionColumns+` FROM account_deletions WHERE user_id = $1 AND status = $2`, userID, model.DeletionScheduled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account deletion: %w", err)
+	}
+	return &deletion, nil
+}
+
+func (r *SQLDeletionRepository) Cancel(ctx context.Context, id string, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE account_deletions SET status = $2, cancelled_at = $3 WHERE id = $1 AND status = $4`,
+		id, model.DeletionCancelled, at, model.DeletionScheduled)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLDeletionRepository) Due(ctx context.Context, now time.Time, limit int) ([]*model.Deletion, error) {
+	var deletions []*model.Deletion
+	err := r.db.SelectContext(ctx, &deletions, `
+		SELECT `+deletionColumns+` FROM account_deletions
+		WHERE status = $1 AND scheduled_for <= $2 ORDER BY scheduled_for LIMIT $3`, model.DeletionScheduled, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due account deletions: %w", err)
+	}
+	return deletions, nil
+}
+
+// anonymizeSteps 匿名化语句，$1 为用户 ID，stamped 的语句以 $2 为执行时间。
+// 订单与领券记录作为交易凭证保留，只通过已匿名化的用户 ID 关联；动态与评论在 Anonymize 中单独处理
+var anonymizeSteps = []struct {
+	table   string
+	query   string
+	stamped bool
+}{
+	{"users", `
+		UPDATE users SET username = NULL, password = NULL, email = NULL, mobile = NULL, nickname = '` + AnonymizedName + `',
+			avatar_url = NULL, token = NULL, token_expire_at = NULL, is_member = FALSE, status = 2,
+			deleted_at = COALESCE(deleted_at, $2), updated_at = $2
+		WHERE id = $1`, true},
+	{"likes", `DELETE FROM likes WHERE user_id = $1`, false},
+	{"user_follows", `DELETE FROM user_follows WHERE follower_id = $1 OR followee_id = $1`, false},
+	{"user_identities", `DELETE FROM user_identities WHERE user_id = $1`, false},
+	{"user_two_factor", `DELETE FROM user_two_factor WHERE user_id = $1`, false},
+	{"password_history", `DELETE FROM password_history WHERE user_id = $1`, false},
+	{"user_contact_verifications", `DELETE FROM user_contact_verifications WHERE user_id = $1`, false},
+	{"notification_preferences", `DELETE FROM notification_preferences WHERE user_id = $1`, false},
+	{"notification_deliveries", `DELETE FROM notification_deliveries WHERE user_id = $1`, false},
+	{"user_activities", `DELETE FROM user_activities WHERE user_id = $1`, false},
+	{"data_exports", `DELETE FROM data_exports WHERE user_id = $1`, false},
+}
+
+func (r *SQLDeletionRepository) Anonymize(ctx context.Context, deletion *model.Deletion, at time.Time) (*Anonymized, error) {
+	var result *Anonymized
+	err := r.db.RunInTxWithRetry(ctx, "anonymize_user", nil, func(tx *sqlx.Tx) error {
+		result = &Anonymized{Rows: make(map[string]int64, len(anonymizeSteps)+2)}
+
+		// 动态与评论单独处理，返回所在动态的 ID 用于失效评论缓存
+		var postIDs, commentPostIDs []string
+		if err := tx.SelectContext(ctx, &postIDs, `
+			UPDATE posts SET content = '', media_urls = NULL, deleted_at = COALESCE(deleted_at, $2), updated_at = $2
+			WHERE user_id = $1 RETURNING id`, deletion.UserID, at); err != nil {
+			return fmt.Errorf("failed to anonymize posts: %w", err)
+		}
+		if err := tx.SelectContext(ctx, &commentPostIDs, `
+			UPDATE comments SET content = '', deleted_at = COALESCE(deleted_at, $2), updated_at = $2
+			WHERE user_id = $1 RETURNING post_id`, deletion.UserID, at); err != nil {
+			return fmt.Errorf("failed to anonymize comments: %w", err)
+		}
+		result.Rows["posts"], result.Rows["comments"] = int64(len(postIDs)), int64(len(commentPostIDs))
+		result.PostIDs = distinct(append(postIDs, commentPostIDs...))
+
+		for _, step := range anonymizeSteps {
+			args := []interface{}{deletion.UserID}
+			if step.stamped {
+				args = append(args, at)
+			}
+			res, err := tx.ExecContext(ctx, step.query, args...)
+			if err != nil {
+				return fmt.Errorf("failed to anonymize %s: %w", step.table, err)
+			}
+			if result.Rows[step.table], err = res.RowsAffected(); err != nil {
+				return fmt.Errorf("failed to anonymize %s: %w", step.table, err)
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			UPDATE account_deletions SET status = $2, completed_at = $3 WHERE id = $1 AND status = $4`,
+			deletion.ID, model.DeletionCompleted, at, model.DeletionScheduled)
+		if err != nil {
+			return fmt.Errorf("failed to complete account deletion: %w", err)
+		}
+		// 执行期间被撤销时回滚
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// AuditLog 审计记录
+type AuditLog interface {
+	Append(ctx context.Context, entry *model.AuditEntry) error
+	// ListByUser 按时间顺序列出用户的审计记录
+	ListByUser(ctx context.Context, userID string) ([]*model.AuditEntry, error)
+}
+
+// SQLAuditLog 基于 compliance_audit 表的审计记录
+type SQLAuditLog struct {
+	db *database.DB
+}
+
+// NewSQLAuditLog 创建审计记录
+func NewSQLAuditLog(db *database.DB) *SQLAuditLog {
+	return &SQLAuditLog{db: db}
+}
+
+func (l *SQLAuditLog) Append(ctx context.Context, entry *model.AuditEntry) error {
+	detail := []byte("{}")
+	if len(entry.Detail) > 0 {
+		var err error
+		if detail, err = json.Marshal(entry.Detail); err != nil {
+			return fmt.Errorf("failed to encode audit detail: %w", err)
+		}
+	}
+	if entry.TenantID == "" {
+		entry.TenantID = database.TenantForWrite(ctx)
+	}
+	err := l.db.GetContext(ctx, &entry.ID, `
+		INSERT INTO compliance_audit (tenant_id, user_id, action, actor, detail, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		entry.TenantID, entry.UserID, entry.Action, entry.Actor, detail, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to append compliance audit: %w", err)
+	}
+	return nil
+}
+
+func (l *SQLAuditLog) ListByUser(ctx context.Context, userID string) ([]*model.AuditEntry, error) {
+	var rows []struct {
+		ID        int64     `db:"id"`
+		TenantID  string    `db:"tenant_id"`
+		UserID    string    `db:"user_id"`
+		Action    string    `db:"action"`
+		Actor     string    `db:"actor"`
+		Detail    []byte    `db:"detail"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+	if err := l.db.SelectContext(ctx, &rows, `
+		SELECT id, tenant_id, user_id, action, actor, detail, created_at FROM compliance_audit WHERE user_id = $1 ORDER BY id`, userID); err != nil {
+		return nil, fmt.Errorf("failed to list compliance audit: %w", err)
+	}
+
+	entries := make([]*model.AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := &model.AuditEntry{ID: row.ID, TenantID: row.TenantID, UserID: row.UserID, Action: row.Action, Actor: row.Actor, CreatedAt: row.CreatedAt}
+		if err := json.Unmarshal(row.Detail, &entry.Detail); err != nil {
+			return nil, fmt.Errorf("failed to decode audit detail: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/pkg/database"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("compliance record not found")
+
+// Section 导出归档中的一个数据分区，Query 以 $1 为用户 ID，每行导出为一个 JSON 对象
+type Section struct {
+	Name  string
+	Query string
+}
+
+// Sections 导出的数据分区。用户行不包含密码哈希与令牌
+var Sections = []Section{
+	{Name: "profile", Query: `
+		SELECT id, created_at, updated_at, username, email, mobile, nickname, avatar_url, role, is_member, member_expire_at, status
+		FROM users WHERE id = $1`},
+	{Name: "identities", Query: `
+		SELECT provider, email, last_login_at, created_at FROM user_identities WHERE user_id = $1 ORDER BY id`},
+	{Name: "coupons", Query: `
+		SELECT uc.id, uc.coupon_id, c.name AS coupon_name, uc.status, uc.order_id, uc.discount, uc.used_at, uc.refunded_at, uc.created_at
+		FROM user_coupons uc LEFT JOIN coupons c ON c.id = uc.coupon_id
+		WHERE uc.user_id = $1 AND uc.deleted_at IS NULL ORDER BY uc.created_at`},
+	{Name: "orders", Query: `
+		SELECT id, order_no, amount, status, channel, subject, paid_at, created_at
+		FROM orders WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at`},
+	{Name: "moments", Query: `
+		SELECT id, content, media_urls, type, status, created_at, updated_at
+		FROM posts WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at`},
+	{Name: "comments", Query: `
+		SELECT id, post_id, parent_id, content, created_at
+		FROM comments WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at`},
+	{Name: "likes", Query: `
+		SELECT target_id, target_type, created_at FROM likes WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at`},
+	{Name: "following", Query: `
+		SELECT followee_id, created_at FROM user_follows WHERE follower_id = $1 ORDER BY created_at`},
+	{Name: "notification_preferences", Query: `
+		SELECT locale, channels, muted_categories, webhook_url, updated_at FROM notification_preferences WHERE user_id = $1`},
+	{Name: "activities", Query: `
+		SELECT type, ip, user_agent, data, created_at FROM user_activities WHERE user_id = $1 ORDER BY created_at`},
+}
+
+// ExportRepository 数据导出记录与导出数据的查询
+type ExportRepository interface {
+	Create(ctx context.Context, export *model.Export) error
+	// Get 获取导出记录（不含归档），不存在时返回 ErrNotFound
+	Get(ctx context.Context, id string) (*model.Export, error)
+	// ListByUser 按申请时间倒序列出用户最近的导出记录
+	ListByUser(ctx context.Context, userID string, limit int) ([]*model.Export, error)
+	// Archive 读取已生成的归档，不存在或已过期时返回 ErrNotFound
+	Archive(ctx context.Context, id string) ([]byte, error)
+	// Complete 保存归档并标记为可下载
+	Complete(ctx context.Context, id string, archive []byte, completedAt, expiresAt time.Time) error
+	Fail(ctx context.Context, id, message string, at time.Time) error
+	// Expire 删除下载期限早于 before 的归档，返回处理的条数
+	Expire(ctx context.Context, before time.Time) (int64, error)
+	// Collect 查询用户在一个数据分区中的全部数据，返回 JSON 数组
+	Collect(ctx context.Context, section Section, userID string) (json.RawMessage, error)
+}
+
+// SQLExportRepository 基于 data_exports 表的导出仓库
+type SQLExportRepository struct {
+	db *database.DB
+}
+
+// NewSQLExportRepository 创建导出仓库
+func NewSQLExportRepository(db *database.DB) *SQLExportRepository {
+	return &SQLExportRepository{db: db}
+}
+
+const exportColumns = `id, tenant_id, user_id, status, error, size, requested_at, completed_at, expires_at`
+
+func (r *SQLExportRepository) Create(ctx context.Context, export *model.Export) error {
+	if export.TenantID == "" {
+		export.TenantID = database.TenantForWrite(ctx)
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO data_exports (id, tenant_id, user_id, status, requested_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		export.ID, export.TenantID, export.UserID, export.Status, export.RequestedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create data export: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLExportRepository) Get(ctx context.Context, id string) (*model.Export, error) {
+	var export model.Export
+	err := r.db.GetContext(ctx, &export, `SELECT `+exportColumns+` FROM data_exports WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data export: %w", err)
+	}
+	return &export, nil
+}
+
+func (r *SQLExportRepository) ListByUser(ctx context.Context, userID string, limit int) ([]*model.Export, error) {
+	var exports []*model.Export
+	err := r.db.SelectContext(ctx, &exports, `
+		SELECT `+exportColumns+` FROM data_exports WHERE user_id = $1 ORDER BY requested_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data exports: %w", err)
+	}
+	return exports, nil
+}
+
+func (r *SQLExportRepository) Archive(ctx context.Context, id string) ([]byte, error) {
+	var archive []byte
+	err := r.db.GetContext(ctx, &archive, `
+		SELECT archive FROM data_exports WHERE id = $1 AND status = $2 AND archive IS NOT NULL`, id, model.ExportReady)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export archive: %w", err)
+	}
+	return archive, nil
+}
+
+func (r *SQLExportRepository) Complete(ctx context.Context, id string, archive []byte, completedAt, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE data_exports SET status = $2, archive = $3, size = $4, error = '', completed_at = $5, expires_at = $6
+		WHERE id = $1`, id, model.ExportReady, archive, len(archive), completedAt, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save export archive: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLExportRepository) Fail(ctx context.Context, id, message string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE data_exports SET status = $2, error = $3, completed_at = $4 WHERE id = $1`, id, model.ExportFailed, message, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark export failed: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLExportRepository) Expire(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE data_exports SET status = $2, archive = NULL WHERE status = $1 AND expires_at < $3`,
+		model.ExportReady, model.ExportExpired, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire export archives: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLExportRepository) Collect(ctx context.Context, section Section, userID string) (json.RawMessage, error) {
+	var data []byte
+	query := `SELECT COALESCE(json_agg(t), '[]'::json) FROM (` + section.Query + `) t`
+	if err := r.db.GetContext(database.ReportQuery(ctx), &data, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to collect %s: %w", section.Name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/internal/domain/compliance/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/jobs"
+)
+
+const (
+	// ExportJobType 生成数据导出归档的任务类型
+	ExportJobType = "compliance_data_export"
+	// SweepJobType 执行到期注销并清理过期归档的定时任务类型
+	SweepJobType = "compliance_sweep"
+)
+
+// Queue 后台任务队列，由 *jobs.Manager 实现
+type Queue interface {
+	Enqueue(ctx context.Context, jobType string, payload interface{}, opts jobs.EnqueueOptions) (int64, error)
+}
+
+// SessionRevoker 按用户吊销令牌，由 *security.SessionRevoker 实现
+type SessionRevoker interface {
+	RevokeUser(ctx context.Context, userID string) (time.Time, error)
+}
+
+// Config 数据导出与注销配置
+type Config struct {
+	ExportCooldown    time.Duration // 同一用户两次导出申请的最小间隔
+	ExportRetention   time.Duration // 归档生成后可下载的时长
+	ExportMaxAttempts int           // 生成归档任务的最大尝试次数，全部失败后导出标记为失败
+	GracePeriod       time.Duration // 注销冷静期，期间可撤销
+	SweepSchedule     string        // 执行到期注销的 cron 表达式
+	SweepBatch        int           // 每次执行的注销申请数量上限
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		ExportCooldown:    24 * time.Hour,
+		ExportRetention:   7 * 24 * time.Hour,
+		ExportMaxAttempts: 3,
+		GracePeriod:       14 * 24 * time.Hour,
+		SweepSchedule:     "*/10 * * * *",
+		SweepBatch:        100,
+	}
+}
+
+// ComplianceService 用户数据导出与账号注销：导出由后台任务生成 ZIP 归档，注销经冷静期后匿名化用户数据，两者均记录审计
+type ComplianceService struct {
+	exports     repository.ExportRepository
+	deletions   repository.DeletionRepository
+	audit       repository.AuditLog
+	invalidator cache.TagInvalidator // 为 nil 时不失效响应缓存
+	revoker     SessionRevoker       // 为 nil 时不吊销令牌
+	queue       Queue                // 由 Register 设置，为 nil 时不能申请导出
+	config      *Config
+	clock       clock.Clock
+}
+
+// NewComplianceService 创建数据导出与注销服务，config 为 nil 时使用默认配置
+func NewComplianceService(exports repository.ExportRepository, deletions repository.DeletionRepository, audit repository.AuditLog, invalidator cache.TagInvalidator, revoker SessionRevoker, config *Config) *ComplianceService {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &ComplianceService{
+		exports:     exports,
+		deletions:   deletions,
+		audit:       audit,
+		invalidator: invalidator,
+		revoker:     revoker,
+		config:      config,
+		clock:       clock.OrReal(nil),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (s *ComplianceService) SetClock(c clock.Clock) {
+	s.clock = clock.OrReal(c)
+}
+
+// Register 注册导出任务与定时执行注销的任务
+func (s *ComplianceService) Register(manager *jobs.Manager) error {
+	s.queue = manager
+	manager.Register(ExportJobType, s.runExportJob)
+	manager.Register(SweepJobType, func(ctx context.Context, job *jobs.Job) error {
+		_, err := s.Sweep(ctx)
+		return err
+	})
+	if err := manager.Schedule(SweepJobType, s.config.SweepSchedule, SweepJobType, nil, jobs.PriorityLow); err != nil {
+		return fmt.Errorf("failed to schedule compliance sweep: %w", err)
+	}
+	return nil
+}
+
+// AuditTrail 用户的审计记录
+func (s *ComplianceService) AuditTrail(ctx context.Context, userID string) ([]*model.AuditEntry, error) {
+	entries, err := s.audit.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	return entries, nil
+}
+
+// record 写入审计记录，失败只记录日志，不影响已完成的操作
+func (s *ComplianceService) record(ctx context.Context, tenantID, userID, action, actor string, detail map[string]interface{}) {
+	entry := &model.AuditEntry{
+		TenantID:  tenantID,
+		UserID:    userID,
+		Action:    action,
+		Actor:     actor,
+		Detail:    detail,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.audit.Append(ctx, entry); err != nil {
+		log.Printf("Failed to record %s of user %s: %v", action, userID, err)
+	}
+}
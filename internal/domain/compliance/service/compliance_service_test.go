@@ -0,0 +1,445 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/internal/domain/compliance/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryExports 内存中的导出记录，Collect 返回 sections 中预置的数据
+type memoryExports struct {
+	mu       sync.Mutex
+	exports  map[string]*model.Export
+	archives map[string][]byte
+	sections map[string]string
+	failures int // Collect 在前 failures 次调用时失败
+}
+
+func (m *memoryExports) Create(ctx context.Context, export *model.Export) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *export
+	m.exports[export.ID] = &copied
+	return nil
+}
+
+func (m *memoryExports) Get(ctx context.Context, id string) (*model.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	export, ok := m.exports[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *export
+	return &copied, nil
+}
+
+func (m *memoryExports) ListByUser(ctx context.Context, userID string, limit int) ([]*model.Export, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []*model.Export
+	for _, export := range m.exports {
+		if export.UserID == userID {
+			copied := *export
+			result = append(result, &copied)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RequestedAt.After(result[j].RequestedAt) })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (m *memoryExports) Archive(ctx context.Context, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	archive, ok := m.archives[id]
+	if !ok || m.exports[id].Status != model.ExportReady {
+		return nil, repository.ErrNotFound
+	}
+	return archive, nil
+}
+
+func (m *memoryExports) Complete(ctx context.Context, id string, archive []byte, completedAt, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	export := m.exports[id]
+	export.Status, export.Size, export.CompletedAt, export.ExpiresAt = model.ExportReady, int64(len(archive)), &completedAt, &expiresAt
+	m.archives[id] = archive
+	return nil
+}
+
+func (m *memoryExports) Fail(ctx context.Context, id, message string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	export := m.exports[id]
+	export.Status, export.Error, export.CompletedAt = model.ExportFailed, message, &at
+	return nil
+}
+
+func (m *memoryExports) Expire(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var expired int64
+	for id, export := range m.exports {
+		if export.Status == model.ExportReady && export.ExpiresAt.Before(before) {
+			export.Status = model.ExportExpired
+			delete(m.archives, id)
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+func (m *memoryExports) Collect(ctx context.Context, section repository.Section, userID string) (json.RawMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return nil, errors.New("connection reset")
+	}
+	if data, ok := m.sections[section.Name]; ok {
+		return json.RawMessage(data), nil
+	}
+	return json.RawMessage("[]"), nil
+}
+
+// memoryDeletions 内存中的注销申请，记录被匿名化的用户
+type memoryDeletions struct {
+	mu         sync.Mutex
+	users      map[string]bool
+	deletions  []*model.Deletion
+	anonymized []string
+}
+
+func (m *memoryDeletions) Schedule(ctx context.Context, deletion *model.Deletion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.users[deletion.UserID] {
+		return repository.ErrUserNotFound
+	}
+	for _, d := range m.deletions {
+		if d.UserID == deletion.UserID && d.Status == model.DeletionScheduled {
+			return repository.ErrDeletionScheduled
+		}
+	}
+	copied := *deletion
+	m.deletions = append(m.deletions, &copied)
+	return nil
+}
+
+func (m *memoryDeletions) Active(ctx context.Context, userID string) (*model.Deletion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deletions {
+		if d.UserID == userID && d.Status == model.DeletionScheduled {
+			copied := *d
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (m *memoryDeletions) Cancel(ctx context.Context, id string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deletions {
+		if d.ID == id && d.Status == model.DeletionScheduled {
+			d.Status, d.CancelledAt = model.DeletionCancelled, &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryDeletions) Due(ctx context.Context, now time.Time, limit int) ([]*model.Deletion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*model.Deletion
+	for _, d := range m.deletions {
+		if d.Status == model.DeletionScheduled && !d.ScheduledFor.After(now) && len(due) < limit {
+			copied := *d
+			due = append(due, &copied)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryDeletions) Anonymize(ctx context.Context, deletion *model.Deletion, at time.Time) (*repository.Anonymized, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deletions {
+		if d.ID == deletion.ID && d.Status == model.DeletionScheduled {
+			d.Status, d.CompletedAt = model.DeletionCompleted, &at
+			m.users[d.UserID] = false
+			m.anonymized = append(m.anonymized, d.UserID)
+			return &repository.Anonymized{Rows: map[string]int64{"users": 1, "posts": 1, "comments": 1}, PostIDs: []string{"p1", "p2"}}, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// memoryAudit 内存中的审计记录
+type memoryAudit struct {
+	mu      sync.Mutex
+	entries []*model.AuditEntry
+}
+
+func (m *memoryAudit) Append(ctx context.Context, entry *model.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry.ID = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *memoryAudit) ListByUser(ctx context.Context, userID string) ([]*model.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []*model.AuditEntry
+	for _, entry := range m.entries {
+		if entry.UserID == userID {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryAudit) actions(userID string) []string {
+	entries, _ := m.ListByUser(context.Background(), userID)
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+// recordingQueue 记录入队的任务
+type recordingQueue struct {
+	mu   sync.Mutex
+	jobs []*jobs.Job
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts jobs.EnqueueOptions) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	job := &jobs.Job{ID: int64(len(q.jobs) + 1), Type: jobType, Payload: data, MaxAttempts: opts.MaxAttempts}
+	q.jobs = append(q.jobs, job)
+	return job.ID, nil
+}
+
+// recordingPurge 记录失效的缓存标签与吊销的用户
+type recordingPurge struct {
+	mu      sync.Mutex
+	tags    []string
+	revoked []string
+}
+
+func (p *recordingPurge) InvalidateTags(ctx context.Context, tags ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tags = append(p.tags, tags...)
+	return nil
+}
+
+func (p *recordingPurge) RevokeUser(ctx context.Context, userID string) (time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.revoked = append(p.revoked, userID)
+	return time.Now(), nil
+}
+
+type testEnv struct {
+	compliance *ComplianceService
+	exports    *memoryExports
+	deletions  *memoryDeletions
+	audit      *memoryAudit
+	queue      *recordingQueue
+	purge      *recordingPurge
+	clock      *fakes.Clock
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{
+		exports: &memoryExports{
+			exports:  make(map[string]*model.Export),
+			archives: make(map[string][]byte),
+			sections: map[string]string{
+				"profile": `[{"id": "u1", "nickname": "alice"}]`,
+				"coupons": `[{"id": "uc1"}, {"id": "uc2"}]`,
+			},
+		},
+		deletions: &memoryDeletions{users: map[string]bool{"u1": true, "u2": true}},
+		audit:     &memoryAudit{},
+		queue:     &recordingQueue{},
+		purge:     &recordingPurge{},
+		clock:     fakes.NewClock(time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)),
+	}
+	env.compliance = NewComplianceService(env.exports, env.deletions, env.audit, env.purge, env.purge, nil)
+	env.compliance.SetClock(env.clock)
+	env.compliance.queue = env.queue
+	return env
+}
+
+// runJob 按任务管理器的方式执行入队的任务：领取时先增加尝试次数
+func (env *testEnv) runJob(t *testing.T, index int) error {
+	job := env.queue.jobs[index]
+	job.Attempts++
+	return env.compliance.runExportJob(context.Background(), job)
+}
+
+func TestComplianceService_Export(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	export, err := env.compliance.RequestExport(ctx, "u1", "u1")
+	require.NoError(t, err)
+	assert.Equal(t, model.ExportPending, export.Status)
+	require.Len(t, env.queue.jobs, 1)
+	assert.Equal(t, ExportJobType, env.queue.jobs[0].Type)
+
+	// 生成中不能再次申请，未生成时不能下载
+	_, err = env.compliance.RequestExport(ctx, "u1", "u1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeConflict))
+	_, _, err = env.compliance.DownloadExport(ctx, "u1", export.ID, "u1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeConflict))
+
+	// 任务失败后重试，直到成功
+	env.exports.failures = 1
+	require.Error(t, env.runJob(t, 0))
+	require.NoError(t, env.runJob(t, 0))
+
+	// 他人的导出视为不存在
+	_, _, err = env.compliance.DownloadExport(ctx, "u2", export.ID, "u2")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeNotFound))
+
+	ready, archive, err := env.compliance.DownloadExport(ctx, "u1", export.ID, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, model.ExportReady, ready.Status)
+	assert.Equal(t, env.clock.Now().Add(7*24*time.Hour), *ready.ExpiresAt)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+	}
+	require.Len(t, files, len(repository.Sections)+1)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Equal(t, "u1", manifest.UserID)
+	assert.Equal(t, 2, manifest.Sections["coupons.json"])
+	assert.Equal(t, 0, manifest.Sections["orders.json"])
+	assert.Contains(t, string(files["profile.json"]), "alice")
+
+	// 冷却时间内不能再次申请，归档过期后由定时任务删除
+	_, err = env.compliance.RequestExport(ctx, "u1", "u1")
+	require.True(t, apperrors.IsCode(err, apperrors.CodeTooManyRequests))
+	assert.Equal(t, 24*60*60, apperrors.From(err).Details["retry_after"])
+
+	env.clock.Advance(8 * 24 * time.Hour)
+	result, err := env.compliance.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.ExpiredExports)
+	_, _, err = env.compliance.DownloadExport(ctx, "u1", export.ID, "u1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeConflict))
+
+	assert.Equal(t, []string{model.ActionExportRequested, model.ActionExportCompleted, model.ActionExportDownloaded}, env.audit.actions("u1"))
+}
+
+func TestComplianceService_ExportFailsAfterLastAttempt(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	export, err := env.compliance.RequestExport(ctx, "u1", "u1")
+	require.NoError(t, err)
+	env.exports.failures = 3
+	for i := 0; i < 3; i++ {
+		require.Error(t, env.runJob(t, 0))
+	}
+	failed, err := env.exports.Get(ctx, export.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ExportFailed, failed.Status)
+	assert.Contains(t, failed.Error, "connection reset")
+
+	// 失败后可立即重新申请
+	_, err = env.compliance.RequestExport(ctx, "u1", "u1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{model.ActionExportRequested, model.ActionExportFailed, model.ActionExportRequested}, env.audit.actions("u1"))
+}
+
+func TestComplianceService_Deletion(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	deletion, err := env.compliance.RequestDeletion(ctx, "u1", "u1", "不再使用")
+	require.NoError(t, err)
+	assert.Equal(t, env.clock.Now().Add(14*24*time.Hour), deletion.ScheduledFor)
+
+	_, err = env.compliance.RequestDeletion(ctx, "u1", "u1", "")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeConflict))
+	_, err = env.compliance.RequestDeletion(ctx, "u3", "admin", "")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeUserNotFound))
+
+	// 冷静期内撤销后不再执行
+	require.NoError(t, env.compliance.CancelDeletion(ctx, "u1", "u1"))
+	err = env.compliance.CancelDeletion(ctx, "u1", "u1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeNotFound))
+	_, err = env.compliance.DeletionStatus(ctx, "u1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeNotFound))
+
+	// 管理员代为申请，冷静期结束前不执行
+	_, err = env.compliance.RequestDeletion(ctx, "u1", "admin", "")
+	require.NoError(t, err)
+	env.clock.Advance(13 * 24 * time.Hour)
+	result, err := env.compliance.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Deleted)
+
+	env.clock.Advance(24 * time.Hour)
+	result, err = env.compliance.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Equal(t, []string{"u1"}, env.deletions.anonymized)
+	assert.Equal(t, []string{"u1"}, env.purge.revoked)
+	assert.ElementsMatch(t, []string{"user:u1", "users", "moments:feed", "moments:topics", "moment:p1:comments", "moment:p2:comments"}, env.purge.tags)
+
+	// 已执行的申请不再重复执行
+	result, err = env.compliance.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Deleted)
+
+	entries, err := env.compliance.AuditTrail(ctx, "u1")
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	assert.Equal(t, model.ActionDeletionRequested, entries[0].Action)
+	assert.NotContains(t, entries[0].Detail, "reason", "注销原因不写入审计")
+	assert.Equal(t, model.ActionDeletionCancelled, entries[1].Action)
+	assert.Equal(t, "admin", entries[2].Actor)
+	assert.Equal(t, model.ActionDeletionCompleted, entries[3].Action)
+	assert.Equal(t, model.ActorSystem, entries[3].Actor)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/internal/domain/compliance/repository"
+	momentService "user_crud_jwt/internal/domain/moment/service"
+	userService "user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/google/uuid"
+)
+
+// SweepResult 一次定时执行的结果
+type SweepResult struct {
+	Deleted        int   `json:"deleted"`        // 完成匿名化的用户数
+	ExpiredExports int64 `json:"expiredExports"` // 删除的过期归档数
+}
+
+// RequestDeletion 申请注销账号，冷静期结束后执行；actor 为本人或管理员的用户 ID
+func (s *ComplianceService) RequestDeletion(ctx context.Context, userID, actor, reason string) (*model.Deletion, error) {
+	now := s.clock.Now()
+	deletion := &model.Deletion{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Status:       model.DeletionScheduled,
+		Reason:       reason,
+		RequestedBy:  actor,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(s.config.GracePeriod),
+	}
+	err := s.deletions.Schedule(ctx, deletion)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return nil, apperrors.New(apperrors.CodeUserNotFound, "")
+	case errors.Is(err, repository.ErrDeletionScheduled):
+		return nil, apperrors.New(apperrors.CodeConflict, "account deletion already scheduled")
+	case err != nil:
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	// 注销原因可能含个人信息，不写入审计
+	s.record(ctx, deletion.TenantID, userID, model.ActionDeletionRequested, actor, map[string]interface{}{
+		"deletion_id":   deletion.ID,
+		"scheduled_for": deletion.ScheduledFor,
+	})
+	return deletion, nil
+}
+
+// DeletionStatus 用户待执行的注销申请，没有时返回 CodeNotFound
+func (s *ComplianceService) DeletionStatus(ctx context.Context, userID string) (*model.Deletion, error) {
+	deletion, err := s.deletions.Active(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, apperrors.New(apperrors.CodeNotFound, "no account deletion scheduled")
+	}
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	return deletion, nil
+}
+
+// CancelDeletion 冷静期内撤销注销申请
+func (s *ComplianceService) CancelDeletion(ctx context.Context, userID, actor string) error {
+	deletion, err := s.DeletionStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	cancelled, err := s.deletions.Cancel(ctx, deletion.ID, s.clock.Now())
+	if err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if !cancelled {
+		// 并发撤销，或冷静期已结束且正在执行
+		return apperrors.New(apperrors.CodeNotFound, "no account deletion scheduled")
+	}
+	s.record(ctx, deletion.TenantID, userID, model.ActionDeletionCancelled, actor, map[string]interface{}{"deletion_id": deletion.ID})
+	return nil
+}
+
+// Sweep 匿名化冷静期已结束的用户，并删除超过下载期限的导出归档。
+// 单个用户失败时跳过，下次执行时重试
+func (s *ComplianceService) Sweep(ctx context.Context) (*SweepResult, error) {
+	now := s.clock.Now()
+	result := &SweepResult{}
+	due, err := s.deletions.Due(ctx, now, s.config.SweepBatch)
+	if err != nil {
+		return result, err
+	}
+	for _, deletion := range due {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		anonymized, err := s.deletions.Anonymize(ctx, deletion, now)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to anonymize user %s: %v", deletion.UserID, err)
+			continue
+		}
+		result.Deleted++
+		s.purge(ctx, deletion.UserID, anonymized)
+		s.record(ctx, deletion.TenantID, deletion.UserID, model.ActionDeletionCompleted, model.ActorSystem, map[string]interface{}{
+			"deletion_id": deletion.ID,
+			"rows":        anonymized.Rows,
+		})
+	}
+
+	if result.ExpiredExports, err = s.exports.Expire(ctx, now); err != nil {
+		return result, err
+	}
+	if result.Deleted > 0 || result.ExpiredExports > 0 {
+		log.Printf("Compliance sweep anonymized %d users and expired %d exports", result.Deleted, result.ExpiredExports)
+	}
+	return result, nil
+}
+
+// purge 吊销已匿名化用户的令牌，并失效引用其数据的响应缓存
+func (s *ComplianceService) purge(ctx context.Context, userID string, anonymized *repository.Anonymized) {
+	if s.revoker != nil {
+		if _, err := s.revoker.RevokeUser(ctx, userID); err != nil {
+			log.Printf("Failed to revoke sessions of deleted user %s: %v", userID, err)
+		}
+	}
+	if s.invalidator == nil {
+		return
+	}
+	tags := []string{userService.UserCacheTag(userID), userService.UsersCacheTag}
+	if len(anonymized.PostIDs) > 0 {
+		tags = append(tags, momentService.FeedCacheTag, momentService.TopicsCacheTag)
+	}
+	for _, postID := range anonymized.PostIDs {
+		tags = append(tags, momentService.CommentsCacheTag(postID))
+	}
+	if err := s.invalidator.InvalidateTags(ctx, tags...); err != nil {
+		log.Printf("Failed to invalidate cache of deleted user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"user_crud_jwt/internal/domain/compliance/model"
+	"user_crud_jwt/internal/domain/compliance/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/jobs"
+
+	"github.com/google/uuid"
+)
+
+// exportListLimit 列出的最近导出记录数
+const exportListLimit = 10
+
+// exportPayload 导出任务的参数
+type exportPayload struct {
+	ExportID string `json:"export_id"`
+}
+
+// Manifest 归档中的 manifest.json，列出各数据分区的文件与条数
+type Manifest struct {
+	ExportID    string         `json:"exportId"`
+	UserID      string         `json:"userId"`
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Sections    map[string]int `json:"sections"` // 文件名 -> 条数
+}
+
+// RequestExport 申请导出用户数据，由后台任务生成归档。已有生成中的导出或距上次申请不足冷却时间时拒绝
+func (s *ComplianceService) RequestExport(ctx context.Context, userID, actor string) (*model.Export, error) {
+	if s.queue == nil {
+		return nil, apperrors.New(apperrors.CodeUnavailable, "job queue unavailable")
+	}
+	recent, err := s.exports.ListByUser(ctx, userID, 1)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	now := s.clock.Now()
+	if len(recent) > 0 {
+		last := recent[0]
+		if last.Status == model.ExportPending {
+			return nil, apperrors.New(apperrors.CodeConflict, "an export is already in progress").WithDetail("export_id", last.ID)
+		}
+		if last.Status != model.ExportFailed {
+			if wait := last.RequestedAt.Add(s.config.ExportCooldown).Sub(now); wait > 0 {
+				return nil, apperrors.New(apperrors.CodeTooManyRequests, "").WithDetail("retry_after", int(wait.Seconds()))
+			}
+		}
+	}
+
+	export := &model.Export{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Status:      model.ExportPending,
+		RequestedAt: now,
+	}
+	if err := s.exports.Create(ctx, export); err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	_, err = s.queue.Enqueue(ctx, ExportJobType, exportPayload{ExportID: export.ID}, jobs.EnqueueOptions{
+		Priority:    jobs.PriorityNormal,
+		MaxAttempts: s.config.ExportMaxAttempts,
+		UniqueKey:   ExportJobType + ":" + export.ID,
+	})
+	if err != nil {
+		if failErr := s.exports.Fail(ctx, export.ID, "failed to enqueue", now); failErr != nil {
+			log.Printf("Failed to mark export %s failed: %v", export.ID, failErr)
+		}
+		return nil, apperrors.Wrap(err, apperrors.CodeUnavailable, "")
+	}
+	s.record(ctx, export.TenantID, userID, model.ActionExportRequested, actor, map[string]interface{}{"export_id": export.ID})
+	return export, nil
+}
+
+// ListExports 用户最近的导出记录
+func (s *ComplianceService) ListExports(ctx context.Context, userID string) ([]*model.Export, error) {
+	exports, err := s.exports.ListByUser(ctx, userID, exportListLimit)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	return exports, nil
+}
+
+// DownloadExport 读取用户自己的导出归档，他人的导出视为不存在
+func (s *ComplianceService) DownloadExport(ctx context.Context, userID, exportID, actor string) (*model.Export, []byte, error) {
+	export, err := s.exports.Get(ctx, exportID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && export.UserID != userID) {
+		return nil, nil, apperrors.New(apperrors.CodeNotFound, "")
+	}
+	if err != nil {
+		return nil, nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if export.Status != model.ExportReady {
+		return nil, nil, apperrors.New(apperrors.CodeConflict, "export is not ready").WithDetail("status", string(export.Status))
+	}
+
+	archive, err := s.exports.Archive(ctx, exportID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, apperrors.New(apperrors.CodeConflict, "export is not ready").WithDetail("status", string(model.ExportExpired))
+	}
+	if err != nil {
+		return nil, nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	s.record(ctx, export.TenantID, userID, model.ActionExportDownloaded, actor, map[string]interface{}{"export_id": export.ID})
+	return export, archive, nil
+}
+
+// GenerateExport 查询用户的全部数据分区并保存为 ZIP 归档；导出已不在生成中时忽略
+func (s *ComplianceService) GenerateExport(ctx context.Context, exportID string) error {
+	export, err := s.exports.Get(ctx, exportID)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Printf("Ignoring data export %s that no longer exists", exportID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if export.Status != model.ExportPending {
+		return nil
+	}
+
+	now := s.clock.Now()
+	archive, manifest, err := s.buildArchive(ctx, export, now)
+	if err != nil {
+		return err
+	}
+	if err := s.exports.Complete(ctx, export.ID, archive, now, now.Add(s.config.ExportRetention)); err != nil {
+		return err
+	}
+	s.record(ctx, export.TenantID, export.UserID, model.ActionExportCompleted, model.ActorSystem, map[string]interface{}{
+		"export_id": export.ID,
+		"size":      len(archive),
+		"sections":  manifest.Sections,
+	})
+	return nil
+}
+
+// buildArchive 每个数据分区写入一个 JSON 文件，另附 manifest.json
+func (s *ComplianceService) buildArchive(ctx context.Context, export *model.Export, now time.Time) ([]byte, *Manifest, error) {
+	manifest := &Manifest{ExportID: export.ID, UserID: export.UserID, GeneratedAt: now, Sections: make(map[string]int, len(repository.Sections))}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, section := range repository.Sections {
+		data, err := s.exports.Collect(ctx, section, export.UserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		var rows []json.RawMessage
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s: %w", section.Name, err)
+		}
+		name := section.Name + ".json"
+		manifest.Sections[name] = len(rows)
+		if err := writeJSON(archive, name, now, rows); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := writeJSON(archive, "manifest.json", now, manifest); err != nil {
+		return nil, nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	return buf.Bytes(), manifest, nil
+}
+
+func writeJSON(archive *zip.Writer, name string, modified time.Time, value interface{}) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// runExportJob 导出任务，最后一次尝试失败时将导出标记为失败，用户可重新申请
+func (s *ComplianceService) runExportJob(ctx context.Context, job *jobs.Job) error {
+	var payload exportPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+	err := s.GenerateExport(ctx, payload.ExportID)
+	if err == nil || job.Attempts < job.MaxAttempts {
+		return err
+	}
+
+	if failErr := s.exports.Fail(ctx, payload.ExportID, err.Error(), s.clock.Now()); failErr != nil {
+		log.Printf("Failed to mark export %s failed: %v", payload.ExportID, failErr)
+	} else if export, getErr := s.exports.Get(ctx, payload.ExportID); getErr == nil {
+		s.record(ctx, export.TenantID, export.UserID, model.ActionExportFailed, model.ActorSystem, map[string]interface{}{"export_id": export.ID})
+	}
+	return err
+}
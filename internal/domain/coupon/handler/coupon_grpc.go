@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/service"
+	"user_crud_jwt/internal/pkg/grpcserver"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// CouponGRPCServer 优惠券服务的 gRPC 实现，与 CouponHandler 共用服务层
+type CouponGRPCServer struct {
+	usercrudv1.UnimplementedCouponServiceServer
+	service service.CouponService
+}
+
+// NewCouponGRPCServer 创建优惠券 gRPC 服务
+func NewCouponGRPCServer(service service.CouponService) *CouponGRPCServer {
+	return &CouponGRPCServer{service: service}
+}
+
+// CreateCoupon 创建优惠券（管理员）
+func (s *CouponGRPCServer) CreateCoupon(ctx context.Context, req *usercrudv1.CreateCouponRequest) (*usercrudv1.Coupon, error) {
+	if err := grpcserver.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetName() == "" || req.GetTotal() < 1 || req.GetAmount() < 0.01 || req.GetStartTime() == nil || req.GetEndTime() == nil {
+		return nil, status.Error(codes.InvalidArgument, "name, total, amount, start_time and end_time are required")
+	}
+
+	coupon, err := s.service.CreateCoupon(ctx, req.GetName(), int(req.GetTotal()), req.GetAmount(), req.GetStartTime().AsTime(), req.GetEndTime().AsTime())
+	if err != nil {
+		return nil, err
+	}
+	return couponToProto(coupon), nil
+}
+
+// ClaimCoupon 领券，进入排队时返回排队状态
+func (s *CouponGRPCServer) ClaimCoupon(ctx context.Context, req *usercrudv1.ClaimCouponRequest) (*usercrudv1.ClaimCouponResponse, error) {
+	userID, err := grpcserver.ResolveUserID(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := s.service.ClaimOrQueue(ctx, userID, req.GetCouponId())
+	if err != nil {
+		return nil, err
+	}
+	if ticket != nil {
+		return &usercrudv1.ClaimCouponResponse{Ticket: ticketToProto(req.GetCouponId(), ticket)}, nil
+	}
+	return &usercrudv1.ClaimCouponResponse{Claimed: true}, nil
+}
+
+// GetQueueStatus 查询抢券排队状态
+func (s *CouponGRPCServer) GetQueueStatus(ctx context.Context, req *usercrudv1.GetQueueStatusRequest) (*usercrudv1.QueueTicket, error) {
+	userID, err := grpcserver.ResolveUserID(ctx, req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := s.service.QueueStatus(ctx, userID, req.GetCouponId())
+	if err != nil {
+		return nil, err
+	}
+	return ticketToProto(req.GetCouponId(), ticket), nil
+}
+
+// SendCoupon 给指定用户发券（管理员）
+func (s *CouponGRPCServer) SendCoupon(ctx context.Context, req *usercrudv1.SendCouponRequest) (*usercrudv1.SendCouponResponse, error) {
+	if err := grpcserver.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetUserId() == "" || req.GetCouponId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id and coupon_id are required")
+	}
+
+	if err := s.service.SendCouponToUser(ctx, req.GetUserId(), req.GetCouponId()); err != nil {
+		return nil, err
+	}
+	return &usercrudv1.SendCouponResponse{}, nil
+}
+
+// ReconcileClaims 对账领取记录（管理员）
+func (s *CouponGRPCServer) ReconcileClaims(ctx context.Context, req *usercrudv1.ReconcileClaimsRequest) (*usercrudv1.ReconcileClaimsResponse, error) {
+	if err := grpcserver.RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	result, err := s.service.ReconcileClaims(ctx, req.GetCouponId())
+	if err != nil {
+		return nil, err
+	}
+	return &usercrudv1.ReconcileClaimsResponse{
+		CouponId:    result.ResourceID,
+		Restored:    int32(result.Restored),
+		Compensated: int32(result.Compensated),
+		Confirmed:   int32(result.Confirmed),
+		StockBefore: int32(result.StockBefore),
+		StockAfter:  int32(result.StockAfter),
+	}, nil
+}
+
+func couponToProto(c *model.Coupon) *usercrudv1.Coupon {
+	return &usercrudv1.Coupon{
+		Id:        c.ID,
+		Name:      c.Name,
+		Total:     int32(c.Total),
+		Stock:     int32(c.Stock),
+		Amount:    c.Amount,
+		StartTime: timestamppb.New(c.StartTime),
+		EndTime:   timestamppb.New(c.EndTime),
+		CreatedAt: timestamppb.New(c.CreatedAt),
+	}
+}
+
+// ticketToProto ticket 为 nil 表示未在排队
+func ticketToProto(couponID string, t *service.QueueTicket) *usercrudv1.QueueTicket {
+	if t == nil {
+		return &usercrudv1.QueueTicket{CouponId: couponID}
+	}
+	return &usercrudv1.QueueTicket{
+		CouponId:          t.CouponID,
+		Queued:            t.Position > 0,
+		Position:          t.Position,
+		Admitted:          t.Admitted,
+		SoldOut:           t.SoldOut,
+		RetryAfterSeconds: int32(t.RetryAfter),
+	}
+}
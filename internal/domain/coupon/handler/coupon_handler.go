@@ -2,8 +2,10 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 	"user_crud_jwt/internal/domain/coupon/service"
+	"user_crud_jwt/pkg/apperrors"
 	"user_crud_jwt/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -32,7 +34,7 @@ func (h *CouponHandler) CreateCoupon(c *gin.Context) {
 		return
 	}
 
-	coupon, err := h.service.CreateCoupon(input.Name, input.Total, input.Amount, input.StartTime, input.EndTime)
+	coupon, err := h.service.CreateCoupon(c.Request.Context(), input.Name, input.Total, input.Amount, input.StartTime, input.EndTime)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
 		return
@@ -58,22 +60,38 @@ func (h *CouponHandler) ClaimCoupon(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ClaimCoupon(uid, couponID); err != nil {
-		if err.Error() == "coupon out of stock" || err.Error() == "coupon out of stock (local cache)" {
-			response.Fail(c, response.ErrCouponOutOfStock, "Coupon out of stock")
-			return
-		}
-		if err.Error() == "you have already claimed this coupon" {
-			response.Fail(c, response.ErrCouponClaimed, "You have already claimed this coupon")
-			return
-		}
-		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
+	ticket, err := h.service.ClaimOrQueue(c.Request.Context(), uid, couponID)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	if ticket != nil {
+		// 进入排队，客户端按 Retry-After 轮询排队状态，放行后重新发起领取
+		c.Header("Retry-After", strconv.Itoa(ticket.RetryAfter))
+		response.Accepted(c, ticket)
 		return
 	}
 
 	response.Success(c, "Coupon claimed successfully")
 }
 
+// QueueStatus 查询抢券排队状态
+func (h *CouponHandler) QueueStatus(c *gin.Context) {
+	ticket, err := h.service.QueueStatus(c.Request.Context(), c.GetString("userID"), c.Param("id"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	if ticket == nil {
+		response.Success(c, gin.H{"queued": false})
+		return
+	}
+	if ticket.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(ticket.RetryAfter))
+	}
+	response.Success(c, ticket)
+}
+
 // SendCouponInput 管理员发券输入
 type SendCouponInput struct {
 	UserID   string `json:"userId" binding:"required"`
@@ -88,16 +106,8 @@ func (h *CouponHandler) SendCoupon(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.SendCouponToUser(input.UserID, input.CouponID); err != nil {
-		if err.Error() == "coupon out of stock" || err.Error() == "coupon out of stock (local cache)" {
-			response.Fail(c, response.ErrCouponOutOfStock, "Coupon out of stock")
-			return
-		}
-		if err.Error() == "you have already claimed this coupon" {
-			response.Fail(c, response.ErrCouponClaimed, "User has already claimed this coupon")
-			return
-		}
-		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
+	if err := h.service.SendCouponToUser(c.Request.Context(), input.UserID, input.CouponID); err != nil {
+		apperrors.Abort(c, err)
 		return
 	}
 
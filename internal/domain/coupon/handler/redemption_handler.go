@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/service"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettlementQuery 结算报表参数，coupon_id 可重复
+type SettlementQuery struct {
+	CouponIDs []string  `form:"coupon_id" binding:"max=100"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// RedemptionHandler 优惠券核销接口
+type RedemptionHandler struct {
+	redemptions *service.RedemptionService
+}
+
+// NewRedemptionHandler 创建优惠券核销接口
+func NewRedemptionHandler(redemptions *service.RedemptionService) *RedemptionHandler {
+	return &RedemptionHandler{redemptions: redemptions}
+}
+
+// RegisterAdminRoutes 注册订单核销与结算路由，调用方需挂载管理员权限校验
+func (h *RedemptionHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.POST("/coupons/redemptions/:orderId/confirm", h.Confirm)
+	group.POST("/coupons/redemptions/:orderId/release", h.Release)
+	group.POST("/coupons/redemptions/:orderId/refund", h.Refund)
+	group.GET("/coupons/settlements", h.Settlement)
+}
+
+// Lock 下单时锁定当前用户的优惠券，请求中的券须全部可用
+func (h *RedemptionHandler) Lock(c *gin.Context) {
+	var input service.LockRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	redemption, err := h.redemptions.Lock(c.Request.Context(), c.GetString("userID"), input)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, redemption)
+}
+
+// Cancel 用户取消订单，释放自己锁定的优惠券
+func (h *RedemptionHandler) Cancel(c *gin.Context) {
+	redemption, err := h.redemptions.Release(c.Request.Context(), c.GetString("userID"), c.Param("orderId"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, redemption)
+}
+
+// Confirm 订单支付成功，使用锁定的优惠券
+func (h *RedemptionHandler) Confirm(c *gin.Context) {
+	redemption, err := h.redemptions.Confirm(c.Request.Context(), c.Param("orderId"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, redemption)
+}
+
+// Release 订单取消或支付失败，释放锁定的优惠券
+func (h *RedemptionHandler) Release(c *gin.Context) {
+	redemption, err := h.redemptions.Release(c.Request.Context(), "", c.Param("orderId"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, redemption)
+}
+
+// Refund 订单退款，已使用的优惠券标记为已退还
+func (h *RedemptionHandler) Refund(c *gin.Context) {
+	redemption, err := h.redemptions.Refund(c.Request.Context(), c.Param("orderId"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, redemption)
+}
+
+// Settlement 按优惠券汇总区间内的领取与核销
+func (h *RedemptionHandler) Settlement(c *gin.Context) {
+	var query SettlementQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, "from must be before to")
+		return
+	}
+
+	settlements, err := h.redemptions.Settlement(c.Request.Context(), model.SettlementQuery{
+		CouponIDs: query.CouponIDs,
+		From:      query.From,
+		To:        query.To,
+	})
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, settlements)
+}
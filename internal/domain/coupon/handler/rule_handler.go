@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/service"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RuleInput 新增或覆盖优惠券规则
+type RuleInput struct {
+	NewUsersOnly   bool     `json:"newUsersOnly"`
+	NewUserDays    int      `json:"newUserDays" binding:"min=0,max=365"`
+	MinOrderAmount float64  `json:"minOrderAmount" binding:"min=0"`
+	Segments       []string `json:"segments" binding:"max=20,dive,required,max=64"`
+	Exclusive      bool     `json:"exclusive"`
+	StackGroup     string   `json:"stackGroup" binding:"max=64"`
+	LimitGroup     string   `json:"limitGroup" binding:"max=64"`
+	MaxPerUser     int      `json:"maxPerUser" binding:"min=0"`
+}
+
+// RuleHandler 优惠券规则接口
+type RuleHandler struct {
+	rules *service.RuleEngine
+}
+
+// NewRuleHandler 创建优惠券规则接口
+func NewRuleHandler(rules *service.RuleEngine) *RuleHandler {
+	return &RuleHandler{rules: rules}
+}
+
+// RegisterAdminRoutes 注册规则管理路由，调用方需挂载管理员权限校验
+func (h *RuleHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/coupons/:id/rule", h.GetRule)
+	group.PUT("/coupons/:id/rule", h.SaveRule)
+	group.DELETE("/coupons/:id/rule", h.DeleteRule)
+}
+
+// Evaluate 试算当前用户在订单中使用优惠券的抵扣金额，不锁定优惠券；下单时通过 POST /coupons/redemptions 锁定
+func (h *RuleHandler) Evaluate(c *gin.Context) {
+	var input service.EvaluateRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	evaluation, err := h.rules.Evaluate(c.Request.Context(), c.GetString("userID"), input)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, evaluation)
+}
+
+// GetRule 优惠券规则，未配置时 data 为 null
+func (h *RuleHandler) GetRule(c *gin.Context) {
+	rule, err := h.rules.GetRule(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperrors.Abort(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	response.Success(c, rule)
+}
+
+// SaveRule 新增或覆盖优惠券规则
+func (h *RuleHandler) SaveRule(c *gin.Context) {
+	var input RuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	rule := &model.CouponRule{
+		CouponID:       c.Param("id"),
+		NewUsersOnly:   input.NewUsersOnly,
+		NewUserDays:    input.NewUserDays,
+		MinOrderAmount: input.MinOrderAmount,
+		Segments:       input.Segments,
+		Exclusive:      input.Exclusive,
+		StackGroup:     input.StackGroup,
+		LimitGroup:     input.LimitGroup,
+		MaxPerUser:     input.MaxPerUser,
+		UpdatedBy:      c.GetString("userID"),
+	}
+	if err := h.rules.SaveRule(c.Request.Context(), rule); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, rule)
+}
+
+// DeleteRule 删除优惠券规则
+func (h *RuleHandler) DeleteRule(c *gin.Context) {
+	if err := h.rules.DeleteRule(c.Request.Context(), c.Param("id")); err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, "Coupon rule deleted")
+}
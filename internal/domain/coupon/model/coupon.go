@@ -2,9 +2,13 @@ package model
 
 import (
 	"time"
+	"user_crud_jwt/pkg/database"
 	baseModel "user_crud_jwt/pkg/model"
 )
 
+// GatewayCacheKey 网关缓存优惠券的键，领取后库存变化，随领取记录一同登记失效
+const GatewayCacheKey = "graphql:coupon:%s"
+
 // Coupon 优惠券定义
 type Coupon struct {
 	baseModel.BaseModel
@@ -14,12 +18,19 @@ type Coupon struct {
 	Amount    float64   `json:"amount"`
 	StartTime time.Time `json:"startTime"`
 	EndTime   time.Time `json:"endTime"`
+	TenantID  string    `json:"-"` // 所属租户，只有所属租户的用户可以领取
 }
 
-// UserCoupon 用户领取的优惠券
+// UserCoupon 用户领取的优惠券，状态迁移见 CanTransition
 type UserCoupon struct {
 	baseModel.BaseModel
-	UserID   string `json:"userId"`
-	CouponID string `json:"couponId"`
-	Status   int    `json:"status"` // 1:未使用, 2:已使用, 3:已过期
+	database.Versioned
+	UserID      string     `json:"userId"`
+	CouponID    string     `json:"couponId"`
+	Status      int        `json:"status"`             // 1:已领取, 2:已使用, 3:已过期, 4:已锁定, 5:已退还
+	OrderID     string     `json:"orderId,omitempty"`  // 锁定或使用该券的订单
+	Discount    float64    `json:"discount,omitempty"` // 锁定时试算的抵扣金额
+	LockedUntil *time.Time `json:"lockedUntil,omitempty"`
+	UsedAt      *time.Time `json:"usedAt,omitempty"`
+	RefundedAt  *time.Time `json:"refundedAt,omitempty"`
 }
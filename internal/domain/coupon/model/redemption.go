@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// 用户优惠券状态
+const (
+	UserCouponClaimed  = 1
+	UserCouponRedeemed = 2
+	UserCouponExpired  = 3
+	UserCouponLocked   = 4 // 下单时锁定，支付成功后使用，取消或超时后恢复为已领取
+	UserCouponRefunded = 5 // 订单退款，券不退回
+)
+
+// userCouponTransitions 用户优惠券状态机：已领取 → 已锁定 → 已使用 → 已退还，
+// 已锁定可释放回已领取，已领取的券在优惠券结束后过期（锁定中的券先释放再过期，避免支付中途失效）
+var userCouponTransitions = map[int][]int{
+	UserCouponClaimed:  {UserCouponLocked, UserCouponExpired},
+	UserCouponLocked:   {UserCouponRedeemed, UserCouponClaimed},
+	UserCouponRedeemed: {UserCouponRefunded},
+}
+
+// CanTransition 用户优惠券能否从 from 状态迁移到 to 状态
+func CanTransition(from, to int) bool {
+	for _, next := range userCouponTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusName 状态名称，用于错误详情与日志
+func StatusName(status int) string {
+	switch status {
+	case UserCouponClaimed:
+		return "claimed"
+	case UserCouponRedeemed:
+		return "redeemed"
+	case UserCouponExpired:
+		return "expired"
+	case UserCouponLocked:
+		return "locked"
+	case UserCouponRefunded:
+		return "refunded"
+	default:
+		return "unknown"
+	}
+}
+
+// Settlement 单张优惠券（活动）在统计区间内的领取与核销汇总
+type Settlement struct {
+	CouponID         string  `json:"couponId" db:"coupon_id"`
+	Name             string  `json:"name" db:"name"`
+	FaceValue        float64 `json:"faceValue" db:"face_value"`
+	Total            int     `json:"total" db:"total"`
+	Claimed          int64   `json:"claimed" db:"claimed"`                    // 区间内领取
+	Locked           int64   `json:"locked" db:"locked"`                      // 当前锁定中，不受区间限制
+	Redeemed         int64   `json:"redeemed" db:"redeemed"`                  // 区间内使用（含之后退还的）
+	Refunded         int64   `json:"refunded" db:"refunded"`                  // 区间内退还
+	Expired          int64   `json:"expired" db:"expired"`                    // 区间内过期
+	RedeemedDiscount float64 `json:"redeemedDiscount" db:"redeemed_discount"` // 区间内使用的抵扣金额
+	RefundedDiscount float64 `json:"refundedDiscount" db:"refunded_discount"` // 区间内退还的抵扣金额
+	NetDiscount      float64 `json:"netDiscount" db:"-"`                      // 应结算金额：使用减退还
+}
+
+// SettlementQuery 结算报表条件
+type SettlementQuery struct {
+	CouponIDs []string  // 为空时统计区间内有领取或核销记录的全部优惠券
+	From      time.Time // 零值时不限
+	To        time.Time // 零值时不限
+}
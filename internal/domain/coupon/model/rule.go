@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// CouponRule 优惠券规则，未配置规则的优惠券只受库存与有效期限制。
+// 领取时检查新用户、用户分群与每人领取上限，核销时检查最低订单金额、用户分群与叠加规则
+type CouponRule struct {
+	CouponID       string    `json:"couponId"`
+	NewUsersOnly   bool      `json:"newUsersOnly"`
+	NewUserDays    int       `json:"newUserDays"`    // 注册多少天内算新用户，0 时使用服务默认值
+	MinOrderAmount float64   `json:"minOrderAmount"` // 订单金额不低于该值才能使用
+	Segments       []string  `json:"segments"`       // 限定用户分群，满足其一即可，为空时不限
+	Exclusive      bool      `json:"exclusive"`      // 不能与其他优惠券同时使用
+	StackGroup     string    `json:"stackGroup"`     // 同组优惠券一笔订单只能用一张
+	LimitGroup     string    `json:"limitGroup"`     // 同组优惠券合计计算每人领取上限，为空时只计本券
+	MaxPerUser     int       `json:"maxPerUser"`     // 每人领取上限，0 表示不限
+	UpdatedBy      string    `json:"updatedBy"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
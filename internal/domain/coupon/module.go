@@ -1,11 +1,22 @@
 package coupon
 
 import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
 	"user_crud_jwt/internal/domain/coupon/handler"
+	"user_crud_jwt/internal/domain/coupon/model"
 	"user_crud_jwt/internal/domain/coupon/repository"
 	"user_crud_jwt/internal/domain/coupon/service"
+	userModel "user_crud_jwt/internal/domain/user/model"
+	userRepository "user_crud_jwt/internal/domain/user/repository"
 	"user_crud_jwt/internal/pkg/middleware"
 	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/inventory"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/openapi"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,16 +39,56 @@ func (m *CouponModule) Priority() int {
 func (m *CouponModule) Init(ctx *registry.ModuleContext) error {
 	// 1. 依赖注入 - 使用简单仓库
 	cRepo := repository.NewSimpleCouponRepository(ctx.DB)
-	couponService := service.NewCouponService(cRepo, ctx.Redis)
+	var profiles service.ProfileLoader
+	svc, _ := ctx.Lookup(registry.UserRepository)
+	if users, ok := svc.(userRepository.UserRepository); ok {
+		profiles = userProfiles(users)
+	} else {
+		log.Printf("User repository unavailable, coupons limited to new users or segments cannot be claimed")
+	}
+	rules := service.NewRuleEngine(repository.NewSQLRuleRepository(ctx.DB), cRepo, ctx.Redis, profiles, nil)
+	svc, _ = ctx.Lookup(registry.Inventory)
+	stock, ok := svc.(*inventory.Manager)
+	if !ok || stock == nil {
+		// 未登记时自行创建，释放过期预留与对账在本模块内运行
+		stock = inventory.NewManager(ctx.Redis, nil)
+		ctx.Go("coupon_inventory", stock.Run)
+	}
+	couponService := service.NewCouponService(cRepo, ctx.Redis, rules, stock)
+	ctx.Go("coupon_service", couponService.Run)
+	redemptions := service.NewRedemptionService(repository.NewSQLRedemptionRepository(ctx.DB), rules, nil)
 	couponHandler := handler.NewCouponHandler(couponService)
+	ruleHandler := handler.NewRuleHandler(rules)
+	redemptionHandler := handler.NewRedemptionHandler(redemptions)
+	ctx.Provide(registry.CouponRepository, cRepo)
+	ctx.Provide(registry.CouponService, couponService)
+	ctx.Provide(registry.CouponRules, rules)
+	ctx.Provide(registry.CouponRedemption, redemptions)
+
+	// 锁定超时释放与过期清理
+	svc, _ = ctx.Lookup(registry.Jobs)
+	if manager, ok := svc.(*jobs.Manager); ok && manager != nil {
+		if err := redemptions.Register(manager); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Job manager unavailable, expired coupon locks will not be released")
+	}
 
 	// 2. 路由注册
-	setupRoutes(ctx.Router, couponHandler)
+	setupRoutes(ctx.Router, couponHandler, ruleHandler, redemptionHandler)
+
+	// 3. gRPC 服务注册
+	if ctx.GRPC != nil {
+		usercrudv1.RegisterCouponServiceServer(ctx.GRPC.Registrar(), handler.NewCouponGRPCServer(couponService))
+	}
 
 	return nil
 }
 
-func setupRoutes(r *gin.Engine, h *handler.CouponHandler) {
+func setupRoutes(r *gin.Engine, h *handler.CouponHandler, ruleHandler *handler.RuleHandler, redemptionHandler *handler.RedemptionHandler) {
+	describeRoutes(h, ruleHandler, redemptionHandler)
+
 	// 公开路由
 	couponGroup := r.Group("/coupons")
 	{
@@ -49,5 +100,79 @@ func setupRoutes(r *gin.Engine, h *handler.CouponHandler) {
 	protectedGroup.Use(middleware.AuthMiddleware())
 	{
 		protectedGroup.POST("/:id/claim", h.ClaimCoupon)
+		protectedGroup.GET("/:id/queue", h.QueueStatus)
+		protectedGroup.POST("/evaluate", ruleHandler.Evaluate)
+		protectedGroup.POST("/redemptions", redemptionHandler.Lock)
+		protectedGroup.DELETE("/redemptions/:orderId", redemptionHandler.Cancel)
+	}
+}
+
+// userProfiles 规则判断所需的用户信息：注册时间，有效会员属于 member 分群，租户用户属于 tenant:{id} 分群
+func userProfiles(users userRepository.UserRepository) service.ProfileLoader {
+	return func(ctx context.Context, userID string) (*service.UserProfile, error) {
+		user, err := users.GetByID(ctx, userID)
+		if err != nil || user == nil {
+			return nil, err
+		}
+		profile := &service.UserProfile{RegisteredAt: user.CreatedAt}
+		if user.IsMember && (user.MemberExpireAt == nil || user.MemberExpireAt.After(time.Now())) {
+			profile.Segments = append(profile.Segments, "member")
+		}
+		if user.Role == userModel.RoleAdmin {
+			profile.Segments = append(profile.Segments, "admin")
+		}
+		if user.TenantID != "" {
+			profile.Segments = append(profile.Segments, "tenant:"+user.TenantID)
+		}
+		return profile, nil
 	}
 }
+
+// describeRoutes 路由的 OpenAPI 注解
+func describeRoutes(h *handler.CouponHandler, ruleHandler *handler.RuleHandler, redemptionHandler *handler.RedemptionHandler) {
+	openapi.Describe(h.CreateCoupon, openapi.Route{Summary: "创建优惠券", Tags: []string{"Coupon"}, Body: handler.CreateCouponInput{}, Response: model.Coupon{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}})
+	openapi.Describe(h.ClaimCoupon, openapi.Route{
+		Summary:     "领取优惠券",
+		Description: "排队模式下未获放行时返回 202 与排队状态，客户端按 Retry-After 轮询后重新领取",
+		Tags:        []string{"Coupon"},
+		Auth:        true,
+		Response:    "",
+		Responses:   map[int]interface{}{http.StatusAccepted: service.QueueTicket{}},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests},
+	})
+	openapi.Describe(h.QueueStatus, openapi.Route{Summary: "查询抢券排队状态", Tags: []string{"Coupon"}, Auth: true, Response: service.QueueTicket{}})
+	openapi.Describe(ruleHandler.Evaluate, openapi.Route{
+		Summary:     "试算订单可用的优惠券",
+		Description: "按最低订单金额、用户分群与叠加规则选出抵扣最多的组合，未选中的优惠券附带原因，不锁定优惠券",
+		Tags:        []string{"Coupon"},
+		Auth:        true,
+		Body:        service.EvaluateRequest{},
+		Response:    service.Evaluation{},
+		Errors:      []int{http.StatusBadRequest},
+	})
+	openapi.Describe(ruleHandler.GetRule, openapi.Route{Summary: "获取优惠券规则", Tags: []string{"Admin"}, Auth: true, Response: model.CouponRule{}})
+	openapi.Describe(ruleHandler.SaveRule, openapi.Route{Summary: "新增或覆盖优惠券规则", Tags: []string{"Admin"}, Auth: true, Body: handler.RuleInput{}, Response: model.CouponRule{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}})
+	openapi.Describe(ruleHandler.DeleteRule, openapi.Route{Summary: "删除优惠券规则", Tags: []string{"Admin"}, Auth: true, Response: ""})
+	openapi.Describe(redemptionHandler.Lock, openapi.Route{
+		Summary:     "下单锁定优惠券",
+		Description: "按试算结果锁定订单使用的优惠券，任一张不可用时返回 422 且不锁定；超过锁定期限未支付的券自动释放",
+		Tags:        []string{"Coupon"},
+		Auth:        true,
+		Body:        service.LockRequest{},
+		Response:    service.Redemption{},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+	})
+	openapi.Describe(redemptionHandler.Cancel, openapi.Route{Summary: "取消订单并释放锁定的优惠券", Tags: []string{"Coupon"}, Auth: true, Response: service.Redemption{}, Errors: []int{http.StatusUnprocessableEntity}})
+	openapi.Describe(redemptionHandler.Confirm, openapi.Route{Summary: "订单支付成功，使用锁定的优惠券", Description: "重复调用返回已使用的券", Tags: []string{"Admin"}, Auth: true, Response: service.Redemption{}, Errors: []int{http.StatusUnprocessableEntity}})
+	openapi.Describe(redemptionHandler.Release, openapi.Route{Summary: "释放订单锁定的优惠券", Tags: []string{"Admin"}, Auth: true, Response: service.Redemption{}, Errors: []int{http.StatusUnprocessableEntity}})
+	openapi.Describe(redemptionHandler.Refund, openapi.Route{Summary: "订单退款，标记优惠券为已退还", Description: "重复调用返回已退还的券", Tags: []string{"Admin"}, Auth: true, Response: service.Redemption{}, Errors: []int{http.StatusUnprocessableEntity}})
+	openapi.Describe(redemptionHandler.Settlement, openapi.Route{
+		Summary:     "优惠券结算报表",
+		Description: "按优惠券汇总区间内的领取、使用、退还与过期张数及抵扣金额，未指定 coupon_id 时统计区间内进行中的优惠券",
+		Tags:        []string{"Admin"},
+		Auth:        true,
+		Query:       handler.SettlementQuery{},
+		Response:    []model.Settlement{},
+		Errors:      []int{http.StatusBadRequest},
+	})
+}
@@ -2,9 +2,28 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 	"user_crud_jwt/internal/domain/coupon/model"
 )
 
+var (
+	// ErrAlreadyClaimed 数据库中已存在该用户的领取记录（唯一索引冲突）
+	ErrAlreadyClaimed = errors.New("coupon already claimed by user")
+	// ErrStockExhausted 数据库库存不足，通常意味着 Redis 与数据库出现偏差
+	ErrStockExhausted = errors.New("coupon stock exhausted")
+	// ErrCouponNotFound 优惠券不存在或不属于 ctx 中的租户
+	ErrCouponNotFound = errors.New("coupon not found")
+)
+
+// CouponQuery 优惠券分页条件，按 ID 做游标分页，页间插入的数据不会导致重复或遗漏
+type CouponQuery struct {
+	AfterID    string     // 上一页最后一条的 ID，为空时从头开始
+	Limit      int        // 每页条数
+	EndsAfter  *time.Time // 只返回结束时间晚于该时间的优惠券
+	EndsBefore *time.Time // 只返回结束时间不晚于该时间的优惠券
+}
+
 // CouponRepository 优惠券仓库接口
 type CouponRepository interface {
 	// 基础CRUD操作
@@ -12,6 +31,10 @@ type CouponRepository interface {
 	CreateCoupon(ctx context.Context, coupon *model.Coupon) error
 	GetByID(ctx context.Context, id string) (*model.Coupon, error)
 	GetCouponByID(ctx context.Context, id string) (*model.Coupon, error)
+	// GetByIDs 批量获取优惠券，不存在的 ID 不出现在结果中
+	GetByIDs(ctx context.Context, ids []string) ([]*model.Coupon, error)
+	// ListCoupons 按 ID 顺序分页获取优惠券，用于导出等需要遍历全部数据的场景
+	ListCoupons(ctx context.Context, q CouponQuery) ([]*model.Coupon, error)
 
 	// 库存管理
 	DecreaseStock(ctx context.Context, couponID string) error
@@ -22,4 +45,15 @@ type CouponRepository interface {
 	GetUserCoupon(ctx context.Context, userID, couponID string) (*model.UserCoupon, error)
 	HasUserClaimed(ctx context.Context, userID, couponID string) (bool, error)
 	CountUserCoupons(ctx context.Context, userID, couponID string) (int64, error)
+	// ListUserCouponsByUserIDs 批量获取多个用户最近领取的优惠券，每个用户最多 limit 条
+	ListUserCouponsByUserIDs(ctx context.Context, userIDs []string, limit int) ([]*model.UserCoupon, error)
+
+	// 领券去重
+	// ClaimCoupon 在同一事务中写入领取记录并扣减库存，依赖 (user_id, coupon_id) 唯一索引去重；
+	// 优惠券不属于 ctx 中的租户时返回 ErrCouponNotFound
+	ClaimCoupon(ctx context.Context, userID, couponID string) error
+	// ListClaimedUserIDs 列出已领取该券的用户
+	ListClaimedUserIDs(ctx context.Context, couponID string) ([]string, error)
+	// ListActiveCouponIDs 列出结束时间晚于 since 的优惠券
+	ListActiveCouponIDs(ctx context.Context, since time.Time) ([]string, error)
 }
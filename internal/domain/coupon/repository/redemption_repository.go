@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrInvalidTransition 用户优惠券当前状态不允许该迁移，errors.As 可取得 *TransitionError
+	ErrInvalidTransition = errors.New("invalid coupon state transition")
+	// ErrRedemptionNotFound 订单没有处于指定状态的优惠券
+	ErrRedemptionNotFound = errors.New("coupon redemption not found")
+)
+
+// TransitionError 状态迁移失败的详细信息，From 为 0 表示用户未领取该券
+type TransitionError struct {
+	CouponID string
+	From     int
+	To       int
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("coupon %s cannot move from %s to %s", e.CouponID, model.StatusName(e.From), model.StatusName(e.To))
+}
+
+// Is 实现 errors.Is
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// RedemptionRepository 用户优惠券的锁定、核销、退还与过期。每次迁移检查状态机并按 version 乐观锁更新，
+// 版本冲突时整个事务重试
+type RedemptionRepository interface {
+	// Lock 把用户已领取的券锁定到订单，discounts 为券 ID 到抵扣金额；任一张不能锁定时整体回滚并返回 *TransitionError
+	Lock(ctx context.Context, userID, orderID string, discounts map[string]float64, until time.Time) ([]*model.UserCoupon, error)
+	// TransitionOrder 把订单中处于 from 状态的券迁移到 to，userID 不为空时只处理该用户的券；
+	// 订单没有处于 from 状态的券时返回 ErrRedemptionNotFound
+	TransitionOrder(ctx context.Context, userID, orderID string, from, to int) ([]*model.UserCoupon, error)
+	// ListByOrder 订单锁定或使用过的券
+	ListByOrder(ctx context.Context, orderID string) ([]*model.UserCoupon, error)
+	// ReleaseExpiredLocks 锁定期限早于 now 的券恢复为已领取，每次最多 limit 张，返回处理数量
+	ReleaseExpiredLocks(ctx context.Context, now time.Time, limit int) (int64, error)
+	// ExpireEnded 优惠券已结束的已领取券标记为已过期，每次最多 limit 张，返回处理数量
+	ExpireEnded(ctx context.Context, now time.Time, limit int) (int64, error)
+	// Settlement 按优惠券汇总区间内的领取与核销
+	Settlement(ctx context.Context, q model.SettlementQuery) ([]*model.Settlement, error)
+}
+
+// userCouponRow user_coupons 表的行
+type userCouponRow struct {
+	ID          string     `db:"id"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	UserID      string     `db:"user_id"`
+	CouponID    string     `db:"coupon_id"`
+	Status      int        `db:"status"`
+	Version     int64      `db:"version"`
+	OrderID     string     `db:"order_id"`
+	Discount    float64    `db:"discount"`
+	LockedUntil *time.Time `db:"locked_until"`
+	UsedAt      *time.Time `db:"used_at"`
+	RefundedAt  *time.Time `db:"refunded_at"`
+}
+
+const userCouponColumns = `id, created_at, updated_at, user_id, coupon_id, status, version, order_id, discount, locked_until, used_at, refunded_at`
+
+func toUserCoupons(rows []userCouponRow) []*model.UserCoupon {
+	userCoupons := make([]*model.UserCoupon, 0, len(rows))
+	for _, row := range rows {
+		uc := &model.UserCoupon{
+			UserID:      row.UserID,
+			CouponID:    row.CouponID,
+			Status:      row.Status,
+			OrderID:     row.OrderID,
+			Discount:    row.Discount,
+			LockedUntil: row.LockedUntil,
+			UsedAt:      row.UsedAt,
+			RefundedAt:  row.RefundedAt,
+		}
+		uc.ID = row.ID
+		uc.CreatedAt = row.CreatedAt
+		uc.UpdatedAt = row.UpdatedAt
+		uc.Version = row.Version
+		userCoupons = append(userCoupons, uc)
+	}
+	return userCoupons
+}
+
+// SQLRedemptionRepository 基于 user_coupons 表的核销仓库
+type SQLRedemptionRepository struct {
+	db *database.DB
+}
+
+// NewSQLRedemptionRepository 创建核销仓库
+func NewSQLRedemptionRepository(db *database.DB) *SQLRedemptionRepository {
+	return &SQLRedemptionRepository{db: db}
+}
+
+func (r *SQLRedemptionRepository) Lock(ctx context.Context, userID, orderID string, discounts map[string]float64, until time.Time) ([]*model.UserCoupon, error) {
+	couponIDs := make([]string, 0, len(discounts))
+	for id := range discounts {
+		couponIDs = append(couponIDs, id)
+	}
+
+	var locked []*model.UserCoupon
+	err := r.db.RunInTxWithRetry(ctx, "coupon_lock", nil, func(tx *sqlx.Tx) error {
+		where, args := database.Where(database.Cond("user_id = $%d", userID), database.Cond("coupon_id = ANY($%d)", pq.Array(couponIDs)),
+			database.ActiveOnly(""), database.InTenant(ctx, ""))
+		var rows []userCouponRow
+		if err := tx.SelectContext(ctx, &rows, `SELECT `+userCouponColumns+` FROM user_coupons WHERE `+where, args...); err != nil {
+			return fmt.Errorf("failed to load user coupons: %w", err)
+		}
+		userCoupons := toUserCoupons(rows)
+		owned := make(map[string]bool, len(userCoupons))
+		for _, uc := range userCoupons {
+			owned[uc.CouponID] = true
+		}
+		for _, id := range couponIDs {
+			if !owned[id] {
+				return &TransitionError{CouponID: id, To: model.UserCouponLocked}
+			}
+		}
+
+		for _, uc := range userCoupons {
+			if !model.CanTransition(uc.Status, model.UserCouponLocked) {
+				return &TransitionError{CouponID: uc.CouponID, From: uc.Status, To: model.UserCouponLocked}
+			}
+			discount := discounts[uc.CouponID]
+			if err := database.UpdateVersioned(ctx, tx, "user_coupons", "id", uc.ID, &uc.Versioned,
+				"status = $1, order_id = $2, discount = $3, locked_until = $4, updated_at = CURRENT_TIMESTAMP",
+				model.UserCouponLocked, orderID, discount, until); err != nil {
+				return err
+			}
+			lockedUntil := until
+			uc.Status, uc.OrderID, uc.Discount, uc.LockedUntil = model.UserCouponLocked, orderID, discount, &lockedUntil
+		}
+		locked = userCoupons
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return locked, nil
+}
+
+// transitionSet 迁移到 to 状态时的 SET 子句，$1 为新状态
+func transitionSet(to int) string {
+	switch to {
+	case model.UserCouponRedeemed:
+		return "status = $1, used_at = CURRENT_TIMESTAMP, locked_until = NULL, updated_at = CURRENT_TIMESTAMP"
+	case model.UserCouponClaimed:
+		return "status = $1, order_id = '', discount = 0, locked_until = NULL, updated_at = CURRENT_TIMESTAMP"
+	case model.UserCouponRefunded:
+		return "status = $1, refunded_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP"
+	default:
+		return "status = $1, updated_at = CURRENT_TIMESTAMP"
+	}
+}
+
+func (r *SQLRedemptionRepository) TransitionOrder(ctx context.Context, userID, orderID string, from, to int) ([]*model.UserCoupon, error) {
+	if !model.CanTransition(from, to) {
+		return nil, &TransitionError{From: from, To: to}
+	}
+
+	var moved []*model.UserCoupon
+	err := r.db.RunInTxWithRetry(ctx, "coupon_transition", nil, func(tx *sqlx.Tx) error {
+		scopes := []database.Scope{database.Cond("order_id = $%d", orderID), database.Cond("status = $%d", from), database.ActiveOnly("")}
+		if userID != "" {
+			scopes = append(scopes, database.Cond("user_id = $%d", userID))
+		}
+		where, args := database.Where(append(scopes, database.InTenant(ctx, ""))...)
+		var rows []userCouponRow
+		if err := tx.SelectContext(ctx, &rows, `SELECT `+userCouponColumns+` FROM user_coupons WHERE `+where, args...); err != nil {
+			return fmt.Errorf("failed to load order coupons: %w", err)
+		}
+		if len(rows) == 0 {
+			return ErrRedemptionNotFound
+		}
+
+		userCoupons := toUserCoupons(rows)
+		for _, uc := range userCoupons {
+			if err := database.UpdateVersioned(ctx, tx, "user_coupons", "id", uc.ID, &uc.Versioned, transitionSet(to), to); err != nil {
+				return err
+			}
+			uc.Status = to
+		}
+		moved = userCoupons
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func (r *SQLRedemptionRepository) ListByOrder(ctx context.Context, orderID string) ([]*model.UserCoupon, error) {
+	where, args := database.Where(database.Cond("order_id = $%d", orderID), database.ActiveOnly(""), database.InTenant(ctx, ""))
+	var rows []userCouponRow
+	if err := r.db.SelectContext(ctx, &rows, `SELECT `+userCouponColumns+` FROM user_coupons WHERE `+where, args...); err != nil {
+		return nil, fmt.Errorf("failed to list order coupons: %w", err)
+	}
+	return toUserCoupons(rows), nil
+}
+
+func (r *SQLRedemptionRepository) ReleaseExpiredLocks(ctx context.Context, now time.Time, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_coupons SET status = $1, order_id = '', discount = 0, locked_until = NULL,
+			version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM user_coupons
+			WHERE status = $2 AND locked_until < $3 AND deleted_at IS NULL
+			LIMIT $4
+		) AND status = $2`, model.UserCouponClaimed, model.UserCouponLocked, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release expired coupon locks: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *SQLRedemptionRepository) ExpireEnded(ctx context.Context, now time.Time, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE user_coupons SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT uc.id FROM user_coupons uc
+			JOIN coupons c ON c.id = uc.coupon_id
+			WHERE uc.status = $2 AND c.end_time <= $3 AND uc.deleted_at IS NULL
+			LIMIT $4
+		) AND status = $2`, model.UserCouponExpired, model.UserCouponClaimed, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire ended coupons: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// unboundedEnd 结算区间未指定终点时使用
+var unboundedEnd = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (r *SQLRedemptionRepository) Settlement(ctx context.Context, q model.SettlementQuery) ([]*model.Settlement, error) {
+	to := q.To
+	if to.IsZero() {
+		to = unboundedEnd
+	}
+	args := []interface{}{q.From, to, model.UserCouponLocked, model.UserCouponRedeemed, model.UserCouponRefunded, model.UserCouponExpired}
+	scopes := []database.Scope{database.ActiveOnly("c")}
+	if len(q.CouponIDs) > 0 {
+		scopes = append(scopes, database.Cond("c.id = ANY($%d)", pq.Array(q.CouponIDs)))
+	} else {
+		// 未指定优惠券时统计区间内进行中的活动
+		scopes = append(scopes, database.Cond("c.end_time >= $1"), database.Cond("c.start_time < $2"))
+	}
+	scopes = append(scopes, database.InTenant(ctx, "c"))
+	// 固定参数占用 $1-$6，条件的占位符从 $7 开始
+	var conditions []string
+	for _, scope := range scopes {
+		conditions, args = scope(conditions, args)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var settlements []*model.Settlement
+	query := `
+		SELECT c.id AS coupon_id, c.name, c.amount AS face_value, c.total,
+			COUNT(uc.id) FILTER (WHERE uc.created_at >= $1 AND uc.created_at < $2) AS claimed,
+			COUNT(uc.id) FILTER (WHERE uc.status = $3) AS locked,
+			COUNT(uc.id) FILTER (WHERE uc.status IN ($4, $5) AND uc.used_at >= $1 AND uc.used_at < $2) AS redeemed,
+			COUNT(uc.id) FILTER (WHERE uc.status = $5 AND uc.refunded_at >= $1 AND uc.refunded_at < $2) AS refunded,
+			COUNT(uc.id) FILTER (WHERE uc.status = $6 AND uc.updated_at >= $1 AND uc.updated_at < $2) AS expired,
+			COALESCE(SUM(uc.discount) FILTER (WHERE uc.status IN ($4, $5) AND uc.used_at >= $1 AND uc.used_at < $2), 0) AS redeemed_discount,
+			COALESCE(SUM(uc.discount) FILTER (WHERE uc.status = $5 AND uc.refunded_at >= $1 AND uc.refunded_at < $2), 0) AS refunded_discount
+		FROM coupons c
+		LEFT JOIN user_coupons uc ON uc.coupon_id = c.id AND uc.deleted_at IS NULL
+		WHERE ` + where + `
+		GROUP BY c.id, c.name, c.amount, c.total
+		ORDER BY c.id`
+	if err := r.db.SelectContext(ctx, &settlements, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to build coupon settlement: %w", err)
+	}
+	for _, s := range settlements {
+		s.NetDiscount = s.RedeemedDiscount - s.RefundedDiscount
+	}
+	return settlements, nil
+}
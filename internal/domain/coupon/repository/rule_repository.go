@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/lib/pq"
+)
+
+// RuleRepository 优惠券规则与规则判断所需的领取记录
+type RuleRepository interface {
+	// GetRules 批量获取规则，未配置规则的优惠券不出现在结果中
+	GetRules(ctx context.Context, couponIDs []string) (map[string]*model.CouponRule, error)
+	SaveRule(ctx context.Context, rule *model.CouponRule) error
+	DeleteRule(ctx context.Context, couponID string) error
+	// CountClaimsInGroup 用户已领取的 limitGroup 组内优惠券张数
+	CountClaimsInGroup(ctx context.Context, userID, limitGroup string) (int64, error)
+	// ListUserCoupons 用户对指定优惠券的领取记录
+	ListUserCoupons(ctx context.Context, userID string, couponIDs []string) ([]*model.UserCoupon, error)
+}
+
+// ruleRow coupon_rules 表的行
+type ruleRow struct {
+	CouponID       string    `db:"coupon_id"`
+	NewUsersOnly   bool      `db:"new_users_only"`
+	NewUserDays    int       `db:"new_user_days"`
+	MinOrderAmount float64   `db:"min_order_amount"`
+	Segments       []byte    `db:"segments"`
+	Exclusive      bool      `db:"exclusive"`
+	StackGroup     string    `db:"stack_group"`
+	LimitGroup     string    `db:"limit_group"`
+	MaxPerUser     int       `db:"max_per_user"`
+	UpdatedBy      string    `db:"updated_by"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+func (r *ruleRow) toModel() (*model.CouponRule, error) {
+	rule := &model.CouponRule{
+		CouponID:       r.CouponID,
+		NewUsersOnly:   r.NewUsersOnly,
+		NewUserDays:    r.NewUserDays,
+		MinOrderAmount: r.MinOrderAmount,
+		Exclusive:      r.Exclusive,
+		StackGroup:     r.StackGroup,
+		LimitGroup:     r.LimitGroup,
+		MaxPerUser:     r.MaxPerUser,
+		UpdatedBy:      r.UpdatedBy,
+		UpdatedAt:      r.UpdatedAt,
+	}
+	if err := json.Unmarshal(r.Segments, &rule.Segments); err != nil {
+		return nil, fmt.Errorf("failed to decode coupon rule segments: %w", err)
+	}
+	return rule, nil
+}
+
+const ruleColumns = `coupon_id, new_users_only, new_user_days, min_order_amount, segments, exclusive, stack_group, limit_group, max_per_user, updated_by, updated_at`
+
+// SQLRuleRepository 基于 coupon_rules 与 user_coupons 表的规则仓库
+type SQLRuleRepository struct {
+	db *database.DB
+}
+
+// NewSQLRuleRepository 创建规则仓库
+func NewSQLRuleRepository(db *database.DB) *SQLRuleRepository {
+	return &SQLRuleRepository{db: db}
+}
+
+func (r *SQLRuleRepository) GetRules(ctx context.Context, couponIDs []string) (map[string]*model.CouponRule, error) {
+	rules := make(map[string]*model.CouponRule, len(couponIDs))
+	if len(couponIDs) == 0 {
+		return rules, nil
+	}
+	var rows []ruleRow
+	query := `SELECT ` + ruleColumns + ` FROM coupon_rules WHERE coupon_id = ANY($1)`
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(couponIDs)); err != nil {
+		return nil, fmt.Errorf("failed to get coupon rules: %w", err)
+	}
+	for i := range rows {
+		rule, err := rows[i].toModel()
+		if err != nil {
+			return nil, err
+		}
+		rules[rule.CouponID] = rule
+	}
+	return rules, nil
+}
+
+func (r *SQLRuleRepository) SaveRule(ctx context.Context, rule *model.CouponRule) error {
+	segments := rule.Segments
+	if segments == nil {
+		segments = []string{}
+	}
+	encoded, err := json.Marshal(segments)
+	if err != nil {
+		return fmt.Errorf("failed to encode coupon rule segments: %w", err)
+	}
+
+	rule.UpdatedAt = time.Now()
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO coupon_rules (coupon_id, new_users_only, new_user_days, min_order_amount, segments,
+			exclusive, stack_group, limit_group, max_per_user, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (coupon_id) DO UPDATE SET
+			new_users_only = EXCLUDED.new_users_only,
+			new_user_days = EXCLUDED.new_user_days,
+			min_order_amount = EXCLUDED.min_order_amount,
+			segments = EXCLUDED.segments,
+			exclusive = EXCLUDED.exclusive,
+			stack_group = EXCLUDED.stack_group,
+			limit_group = EXCLUDED.limit_group,
+			max_per_user = EXCLUDED.max_per_user,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = EXCLUDED.updated_at`,
+		rule.CouponID, rule.NewUsersOnly, rule.NewUserDays, rule.MinOrderAmount, encoded,
+		rule.Exclusive, rule.StackGroup, rule.LimitGroup, rule.MaxPerUser, rule.UpdatedBy, rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save coupon rule: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRuleRepository) DeleteRule(ctx context.Context, couponID string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM coupon_rules WHERE coupon_id = $1`, couponID); err != nil {
+		return fmt.Errorf("failed to delete coupon rule: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLRuleRepository) CountClaimsInGroup(ctx context.Context, userID, limitGroup string) (int64, error) {
+	where, args := database.Where(database.Cond("uc.user_id = $%d", userID), database.Cond("cr.limit_group = $%d", limitGroup),
+		database.ActiveOnly("uc"), database.InTenant(ctx, "uc"))
+	var count int64
+	query := `
+		SELECT COUNT(*) FROM user_coupons uc
+		JOIN coupon_rules cr ON cr.coupon_id = uc.coupon_id
+		WHERE ` + where
+	if err := r.db.GetContext(ctx, &count, query, args...); err != nil {
+		return 0, fmt.Errorf("failed to count claims in group: %w", err)
+	}
+	return count, nil
+}
+
+func (r *SQLRuleRepository) ListUserCoupons(ctx context.Context, userID string, couponIDs []string) ([]*model.UserCoupon, error) {
+	if len(couponIDs) == 0 {
+		return nil, nil
+	}
+	var rows []userCouponRow
+	where, args := database.Where(database.Cond("user_id = $%d", userID), database.Cond("coupon_id = ANY($%d)", pq.Array(couponIDs)),
+		database.ActiveOnly(""), database.InTenant(ctx, ""))
+	query := `SELECT ` + userCouponColumns + ` FROM user_coupons WHERE ` + where
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list user coupons: %w", err)
+	}
+	return toUserCoupons(rows), nil
+}
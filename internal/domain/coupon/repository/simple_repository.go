@@ -2,8 +2,19 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/outbox"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // SimpleCouponRepository 简单的优惠券仓库实现
@@ -17,23 +28,116 @@ func NewSimpleCouponRepository(db *database.DB) CouponRepository {
 }
 
 func (r *SimpleCouponRepository) Create(ctx context.Context, coupon *model.Coupon) error {
-	// TODO: 实现优惠券创建
+	query := `
+		INSERT INTO coupons (name, total, stock, amount, start_time, end_time, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`
+	err := r.db.QueryRowContext(ctx, query, coupon.Name, coupon.Total, coupon.Stock, coupon.Amount, coupon.StartTime, coupon.EndTime, database.TenantForWrite(ctx)).
+		Scan(&coupon.ID, &coupon.CreatedAt, &coupon.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create coupon: %w", err)
+	}
 	return nil
 }
 
 func (r *SimpleCouponRepository) CreateCoupon(ctx context.Context, coupon *model.Coupon) error {
-	// TODO: 实现优惠券创建
-	return nil
+	return r.Create(ctx, coupon)
+}
+
+// couponRow coupons 表的查询结果
+type couponRow struct {
+	ID        string    `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+	Name      string    `db:"name"`
+	Total     int       `db:"total"`
+	Stock     int       `db:"stock"`
+	Amount    float64   `db:"amount"`
+	StartTime time.Time `db:"start_time"`
+	EndTime   time.Time `db:"end_time"`
+	TenantID  string    `db:"tenant_id"`
+}
+
+func (row *couponRow) toModel() *model.Coupon {
+	coupon := &model.Coupon{
+		Name:      row.Name,
+		Total:     row.Total,
+		Stock:     row.Stock,
+		Amount:    row.Amount,
+		StartTime: row.StartTime,
+		EndTime:   row.EndTime,
+		TenantID:  row.TenantID,
+	}
+	coupon.ID = row.ID
+	coupon.CreatedAt = row.CreatedAt
+	coupon.UpdatedAt = row.UpdatedAt
+	return coupon
 }
 
 func (r *SimpleCouponRepository) GetByID(ctx context.Context, id string) (*model.Coupon, error) {
-	// TODO: 实现根据ID获取优惠券
-	return nil, nil
+	where, args := database.Where(database.Cond("id = $%d", id), database.ActiveOnly(""), database.InTenant(ctx, ""))
+	var row couponRow
+	query := `
+		SELECT id, created_at, updated_at, name, total, stock, amount, start_time, end_time, tenant_id
+		FROM coupons WHERE ` + where
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get coupon: %w", err)
+	}
+	return row.toModel(), nil
+}
+
+func (r *SimpleCouponRepository) GetByIDs(ctx context.Context, ids []string) ([]*model.Coupon, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	where, args := database.Where(database.Cond("id = ANY($%d)", pq.Array(ids)), database.ActiveOnly(""), database.InTenant(ctx, ""))
+	var rows []couponRow
+	query := `
+		SELECT id, created_at, updated_at, name, total, stock, amount, start_time, end_time, tenant_id
+		FROM coupons WHERE ` + where
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get coupons: %w", err)
+	}
+	coupons := make([]*model.Coupon, 0, len(rows))
+	for i := range rows {
+		coupons = append(coupons, rows[i].toModel())
+	}
+	return coupons, nil
+}
+
+func (r *SimpleCouponRepository) ListCoupons(ctx context.Context, q CouponQuery) ([]*model.Coupon, error) {
+	scopes := []database.Scope{database.ActiveOnly("")}
+	if q.AfterID != "" {
+		scopes = append(scopes, database.Cond("id > $%d", q.AfterID))
+	}
+	if q.EndsAfter != nil {
+		scopes = append(scopes, database.Cond("end_time > $%d", *q.EndsAfter))
+	}
+	if q.EndsBefore != nil {
+		scopes = append(scopes, database.Cond("end_time <= $%d", *q.EndsBefore))
+	}
+	where, args := database.Where(append(scopes, database.InTenant(ctx, ""))...)
+	args = append(args, q.Limit)
+
+	var rows []couponRow
+	query := fmt.Sprintf(`
+		SELECT id, created_at, updated_at, name, total, stock, amount, start_time, end_time, tenant_id
+		FROM coupons WHERE %s ORDER BY id LIMIT $%d`, where, len(args))
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list coupons: %w", err)
+	}
+	coupons := make([]*model.Coupon, 0, len(rows))
+	for i := range rows {
+		coupons = append(coupons, rows[i].toModel())
+	}
+	return coupons, nil
 }
 
 func (r *SimpleCouponRepository) GetCouponByID(ctx context.Context, id string) (*model.Coupon, error) {
-	// TODO: 实现根据ID获取优惠券
-	return nil, nil
+	return r.GetByID(ctx, id)
 }
 
 func (r *SimpleCouponRepository) DecreaseStock(ctx context.Context, couponID string) error {
@@ -75,3 +179,127 @@ func (r *SimpleCouponRepository) CountUserCoupons(ctx context.Context, userID, c
 	// TODO: 实现用户优惠券计数
 	return 0, nil
 }
+
+// ClaimCoupon 在一个事务中写入领取记录并扣减库存。并发领取时遇到序列化失败或死锁会整体重试，
+// 领取记录的唯一索引保证重试不会重复领取。领取记录归属优惠券所在的租户，ctx 中有租户时优惠券必须属于该租户，
+// 否则返回 ErrCouponNotFound，不写入任何记录。
+// coupon.claimed 领域事件与网关缓存失效写入同一事务的发件箱，领取提交后才会投递
+func (r *SimpleCouponRepository) ClaimCoupon(ctx context.Context, userID, couponID string) error {
+	return r.db.RunInTxWithRetry(ctx, "coupon_claim", nil, func(tx *sqlx.Tx) error {
+		where, args := database.Where(database.Cond("id = $%d", couponID), database.ActiveOnly(""), database.InTenant(ctx, ""))
+		var tenantID string
+		if err := tx.GetContext(ctx, &tenantID, `SELECT tenant_id FROM coupons WHERE `+where, args...); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrCouponNotFound
+			}
+			return fmt.Errorf("failed to get coupon tenant: %w", err)
+		}
+
+		var userCouponID string
+		err := tx.GetContext(ctx, &userCouponID, `
+			INSERT INTO user_coupons (user_id, coupon_id, status, tenant_id)
+			VALUES ($1, $2, 1, $3)
+			ON CONFLICT (user_id, coupon_id) WHERE deleted_at IS NULL DO NOTHING
+			RETURNING id`, userID, couponID, tenantID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAlreadyClaimed
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert user coupon: %w", err)
+		}
+
+		where, args = database.Where(database.Cond("id = $%d", couponID), database.Cond("stock > 0"),
+			database.ActiveOnly(""), database.InTenant(ctx, ""))
+		result, err := tx.ExecContext(ctx, `
+			UPDATE coupons SET stock = stock - 1, updated_at = CURRENT_TIMESTAMP
+			WHERE `+where, args...)
+		if err != nil {
+			return fmt.Errorf("failed to decrease stock: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return ErrStockExhausted
+		}
+		return enqueueClaimEvents(ctx, tx, userCouponID, userID, couponID, tenantID)
+	})
+}
+
+// enqueueClaimEvents 在领取事务中登记 coupon.claimed 事件与网关缓存失效，去重键取自领取记录 ID
+func enqueueClaimEvents(ctx context.Context, tx *sqlx.Tx, userCouponID, userID, couponID, tenantID string) error {
+	claimed, err := domainevent.NewOutboxEvent(domainevent.TopicCouponClaimed, "coupon.claimed:"+userCouponID, domainevent.Event{
+		UserID:   userID,
+		TenantID: tenantID,
+		Data:     map[string]string{"coupon_id": couponID},
+	})
+	if err != nil {
+		return err
+	}
+	if err := outbox.Enqueue(ctx, tx, claimed); err != nil {
+		return err
+	}
+
+	// 网关缓存按读取方的租户加前缀，无租户的读取使用原始键
+	key := fmt.Sprintf(model.GatewayCacheKey, couponID)
+	invalidation, err := outbox.NewCacheInvalidationEvent("coupon:"+couponID, "cache.invalidate:coupon.claimed:"+userCouponID, outbox.CacheInvalidation{
+		Keys: []string{key, cache.TenantKey(ctxutil.WithTenantID(ctx, tenantID), key)},
+	})
+	if err != nil {
+		return err
+	}
+	return outbox.Enqueue(ctx, tx, invalidation)
+}
+
+func (r *SimpleCouponRepository) ListClaimedUserIDs(ctx context.Context, couponID string) ([]string, error) {
+	where, args := database.Where(database.Cond("coupon_id = $%d", couponID), database.ActiveOnly(""), database.InTenant(ctx, ""))
+	var userIDs []string
+	query := `SELECT user_id FROM user_coupons WHERE ` + where
+	if err := r.db.SelectContext(ctx, &userIDs, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list claimed users: %w", err)
+	}
+	return userIDs, nil
+}
+
+func (r *SimpleCouponRepository) ListUserCouponsByUserIDs(ctx context.Context, userIDs []string, limit int) ([]*model.UserCoupon, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	var rows []struct {
+		ID        string    `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+		UpdatedAt time.Time `db:"updated_at"`
+		UserID    string    `db:"user_id"`
+		CouponID  string    `db:"coupon_id"`
+		Status    int       `db:"status"`
+	}
+	where, args := database.Where(database.Cond("user_id = ANY($%d)", pq.Array(userIDs)), database.ActiveOnly(""), database.InTenant(ctx, ""))
+	args = append(args, limit)
+	// 按用户分组取最近领取的 limit 条
+	query := fmt.Sprintf(`
+		SELECT id, created_at, updated_at, user_id, coupon_id, status FROM (
+			SELECT id, created_at, updated_at, user_id, coupon_id, status,
+				ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS rn
+			FROM user_coupons WHERE %s
+		) t WHERE rn <= $%d
+		ORDER BY user_id, created_at DESC`, where, len(args))
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list user coupons: %w", err)
+	}
+	userCoupons := make([]*model.UserCoupon, 0, len(rows))
+	for _, row := range rows {
+		uc := &model.UserCoupon{UserID: row.UserID, CouponID: row.CouponID, Status: row.Status}
+		uc.ID = row.ID
+		uc.CreatedAt = row.CreatedAt
+		uc.UpdatedAt = row.UpdatedAt
+		userCoupons = append(userCoupons, uc)
+	}
+	return userCoupons, nil
+}
+
+func (r *SimpleCouponRepository) ListActiveCouponIDs(ctx context.Context, since time.Time) ([]string, error) {
+	where, args := database.Where(database.Cond("end_time > $%d", since), database.ActiveOnly(""), database.InTenant(ctx, ""))
+	var ids []string
+	query := `SELECT id FROM coupons WHERE ` + where
+	if err := r.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list active coupons: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimpleCouponRepository_ClaimCouponTenant 优惠券按 ctx 中的租户查询，查不到时不写入领取记录；
+// 领取记录使用优惠券所在的租户
+func TestSimpleCouponRepository_ClaimCouponTenant(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	repo := NewSimpleCouponRepository(db)
+	ctx := ctxutil.WithTenantID(context.Background(), "globex")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tenant_id FROM coupons WHERE id = \$1 AND deleted_at IS NULL AND tenant_id = \$2`).
+		WithArgs("c1", "globex").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.ClaimCoupon(ctx, "u1", "c1"), ErrCouponNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tenant_id FROM coupons`).
+		WithArgs("c1", "globex").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("globex"))
+	mock.ExpectQuery(`INSERT INTO user_coupons`).
+		WithArgs("u1", "c1", "globex").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("uc1"))
+	mock.ExpectExec(`UPDATE coupons SET stock = stock - 1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO outbox_events`).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.ClaimCoupon(ctx, "u1", "c1"))
+}
+
+// payloadContains 匹配包含指定片段的 JSON 负载
+type payloadContains []string
+
+func (p payloadContains) Match(v driver.Value) bool {
+	payload, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	for _, part := range p {
+		if !strings.Contains(string(payload), part) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSimpleCouponRepository_ClaimCouponOutbox 领取成功时 coupon.claimed 事件与缓存失效在同一事务中写入发件箱，
+// 去重键取自领取记录；已领取或库存不足时不写发件箱
+func TestSimpleCouponRepository_ClaimCouponOutbox(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	repo := NewSimpleCouponRepository(db)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tenant_id FROM coupons`).WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("acme"))
+	mock.ExpectQuery(`INSERT INTO user_coupons`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("uc1"))
+	mock.ExpectExec(`UPDATE coupons SET stock = stock - 1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox_events`).
+		WithArgs("coupon.claimed", "u1", "coupon.claimed:uc1", payloadContains{`"user_id":"u1"`, `"tenant_id":"acme"`, `"coupon_id":"c1"`}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO outbox_events`).
+		WithArgs("cache.invalidate", "coupon:c1", "cache.invalidate:coupon.claimed:uc1",
+			payloadContains{`"graphql:coupon:c1"`, `"tenant:acme:graphql:coupon:c1"`}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	require.NoError(t, repo.ClaimCoupon(ctx, "u1", "c1"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tenant_id FROM coupons`).WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("acme"))
+	mock.ExpectQuery(`INSERT INTO user_coupons`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.ClaimCoupon(ctx, "u1", "c1"), ErrAlreadyClaimed)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT tenant_id FROM coupons`).WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("acme"))
+	mock.ExpectQuery(`INSERT INTO user_coupons`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("uc2"))
+	mock.ExpectExec(`UPDATE coupons SET stock = stock - 1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.ClaimCoupon(ctx, "u2", "c1"), ErrStockExhausted)
+}
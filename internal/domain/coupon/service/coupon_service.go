@@ -3,42 +3,102 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
+	"log"
 	"sync"
 	"time"
 	"user_crud_jwt/internal/domain/coupon/model"
 	"user_crud_jwt/internal/domain/coupon/repository"
 	"user_crud_jwt/internal/pkg/worker"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/inventory"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// 领券业务错误
+var (
+	ErrCouponOutOfStock = apperrors.New(apperrors.CodeCouponOutOfStock, "")
+	ErrCouponClaimed    = apperrors.New(apperrors.CodeCouponClaimed, "")
+)
+
 type CouponService interface {
-	CreateCoupon(name string, total int, amount float64, startTime, endTime time.Time) (*model.Coupon, error)
-	ClaimCoupon(userID, couponID string) error
-	SendCouponToUser(userID, couponID string) error
+	// CreateCoupon 创建优惠券，归属 ctx 中的租户（没有租户时为默认租户）
+	CreateCoupon(ctx context.Context, name string, total int, amount float64, startTime, endTime time.Time) (*model.Coupon, error)
+	// ClaimCoupon 领券，ctx 中有租户时只能领取该租户的优惠券
+	ClaimCoupon(ctx context.Context, userID, couponID string) error
+	SendCouponToUser(ctx context.Context, userID, couponID string) error
+	ReconcileClaims(ctx context.Context, couponID string) (*inventory.ReconcileResult, error)
+	// ClaimOrQueue 领券，排队模式下未获放行的请求进入队列并返回排队状态（成功领取时返回 nil）
+	ClaimOrQueue(ctx context.Context, userID, couponID string) (*QueueTicket, error)
+	QueueStatus(ctx context.Context, userID, couponID string) (*QueueTicket, error)
+	// Run 运行排队放行与售罄状态刷新，直到 ctx 取消
+	Run(ctx context.Context)
 }
 
 type couponService struct {
 	repo       repository.CouponRepository
 	rdb        *redis.Client
 	soldOutMap sync.Map // 本地缓存：记录已售罄的 CouponID
+	tenants    sync.Map // 本地缓存：CouponID -> 所属租户，优惠券的租户不会变化
 	workerPool *worker.WorkerPool
+	stock      *inventory.Manager // 每人每券一张的预留与库存，领取记录落库后确认
+	waiting    *WaitingRoom
+	rules      *RuleEngine // 为 nil 时不检查领取规则
 }
 
-func NewCouponService(repo repository.CouponRepository, rdb *redis.Client) CouponService {
+// soldOutRefreshInterval 重新检查已售罄优惠券的间隔，过期预留释放或对账后库存可能恢复
+const soldOutRefreshInterval = 30 * time.Second
+
+// NewCouponService 创建优惠券服务，rules 不为 nil 时领取前检查优惠券规则。
+// 优惠券库存登记到 stock，由 stock 定期释放超时未落库的预留并与数据库对账；coupon.claimed 领域事件由仓库写入发件箱。
+// 排队与售罄刷新的后台循环由调用方以 Run 启动
+func NewCouponService(repo repository.CouponRepository, rdb *redis.Client, rules *RuleEngine, stock *inventory.Manager) CouponService {
+	stock.RegisterSource(CouponStockKind, NewCouponStockSource(repo))
+	s := &couponService{
+		repo:    repo,
+		rdb:     rdb,
+		stock:   stock,
+		waiting: NewWaitingRoom(rdb, nil),
+		rules:   rules,
+	}
+
 	// 初始化 Worker Pool (5个 Worker，缓冲队列 1000)
 	pool := worker.NewWorkerPool(repo, 5, 1000)
+	pool.OnSuccess = func(task worker.CouponTask) {
+		// 确认失败时库存已按数据库对账重新扣减，或登记为稍后对账
+		if err := s.stock.ConfirmCommitted(context.Background(), CouponStockKind, task.UserID, task.CouponID); err != nil {
+			log.Printf("Failed to confirm coupon claim (UserID: %s, CouponID: %s): %v", task.UserID, task.CouponID, err)
+		}
+	}
+	pool.OnFailure = func(task worker.CouponTask, err error) {
+		// 落库失败：回滚 Redis 预扣，避免用户看到已领取但数据库无记录
+		if _, releaseErr := s.stock.Release(context.Background(), CouponStockKind, task.UserID, task.CouponID); releaseErr != nil {
+			log.Printf("Failed to compensate coupon claim (UserID: %s, CouponID: %s): %v", task.UserID, task.CouponID, releaseErr)
+			return
+		}
+		s.soldOutMap.Delete(task.CouponID)
+	}
 	pool.Start()
+	s.workerPool = pool
 
-	return &couponService{
-		repo:       repo,
-		rdb:        rdb,
-		workerPool: pool,
-	}
+	return s
+}
+
+// Run 运行排队放行与售罄状态刷新，ctx 取消后两个循环都退出才返回
+func (s *couponService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.waiting.Run(ctx)
+	}()
+	s.refreshSoldOut(ctx, soldOutRefreshInterval)
+	wg.Wait()
 }
 
-func (s *couponService) CreateCoupon(name string, total int, amount float64, startTime, endTime time.Time) (*model.Coupon, error) {
+func (s *couponService) CreateCoupon(ctx context.Context, name string, total int, amount float64, startTime, endTime time.Time) (*model.Coupon, error) {
 	coupon := &model.Coupon{
 		Name:      name,
 		Total:     total,
@@ -48,82 +108,189 @@ func (s *couponService) CreateCoupon(name string, total int, amount float64, sta
 		EndTime:   endTime,
 	}
 
-	if err := s.repo.Create(context.Background(), coupon); err != nil {
+	if err := s.repo.Create(ctx, coupon); err != nil {
 		return nil, err
 	}
 
-	// 预热缓存：将库存写入 Redis
-	stockKey := fmt.Sprintf("coupon:stock:%s", coupon.ID)
-	s.rdb.Set(context.Background(), stockKey, total, 0)
+	// 预热缓存：将库存写入 Redis，失败时由首次领取或定期对账恢复
+	if err := s.stock.SetCapacity(ctx, CouponStockKind, coupon.ID, int64(total)); err != nil {
+		log.Printf("Failed to initialize coupon stock %s: %v", coupon.ID, err)
+	}
 
 	return coupon, nil
 }
 
-// Lua 脚本：检查用户是否已领 + 检查库存 + 扣减库存 + 记录用户已领
-var claimScript = redis.NewScript(`
-	local user_key = KEYS[1]
-	local stock_key = KEYS[2]
-	local user_id = ARGV[1]
-
-	-- 1. 检查用户是否已领取
-	if redis.call("SISMEMBER", user_key, user_id) == 1 then
-		return -1 -- 已领取
-	end
-
-	-- 2. 检查库存
-	local stock = tonumber(redis.call("GET", stock_key))
-	if stock <= 0 then
-		return -2 -- 库存不足
-	end
-
-	-- 3. 扣减库存
-	redis.call("DECR", stock_key)
-	-- 4. 记录用户已领取
-	redis.call("SADD", user_key, user_id)
-
-	return 1 -- 成功
-`)
-
-func (s *couponService) ClaimCoupon(userID, couponID string) error {
-	// 0. 本地缓存校验 (极高性能，无需网络 IO)
+func (s *couponService) ClaimCoupon(ctx context.Context, userID, couponID string) error {
+	// 0. 租户校验：其他租户的优惠券按不存在处理，不在 Redis 中预扣
+	if err := s.checkTenant(ctx, couponID); err != nil {
+		return err
+	}
+
+	// 本地缓存校验 (极高性能，无需网络 IO)
 	if _, ok := s.soldOutMap.Load(couponID); ok {
-		return errors.New("coupon out of stock (local cache)")
+		return ErrCouponOutOfStock
 	}
 
-	ctx := context.Background()
-	userKey := fmt.Sprintf("coupon:users:%s", couponID)
-	stockKey := fmt.Sprintf("coupon:stock:%s", couponID)
+	// 1. 领取规则：新用户、用户分群与每人领取上限
+	if s.rules != nil {
+		if err := s.rules.CheckClaim(ctx, userID, couponID); err != nil {
+			return err
+		}
+	}
 
-	// 1. 执行 Lua 脚本进行预扣减
-	result, err := claimScript.Run(ctx, s.rdb, []string{userKey, stockKey}, userID).Int()
+	// 2. 快速判重：只读一次占用记录，重复请求无需执行脚本
+	held, err := s.stock.Held(ctx, CouponStockKind, userID, couponID)
 	if err != nil {
-		return fmt.Errorf("redis error: %v", err)
+		return apperrors.Wrap(err, apperrors.CodeCache, "")
 	}
-
-	if result == -1 {
-		return errors.New("you have already claimed this coupon")
+	if held > 0 {
+		return ErrCouponClaimed
 	}
-	if result == -2 {
+
+	// 3. 执行 Lua 脚本进行预扣减，库存键丢失（如 Redis 重启）时按数据库对账恢复后重试
+	err = s.stock.Reserve(ctx, CouponStockKind, userID, inventory.Item{ResourceID: couponID, Quantity: 1}, 0)
+	switch {
+	case errors.Is(err, inventory.ErrAlreadyHeld):
+		return ErrCouponClaimed
+	case errors.Is(err, inventory.ErrOutOfStock):
 		// 标记本地缓存为已售罄
 		s.soldOutMap.Store(couponID, true)
-		return errors.New("coupon out of stock")
+		return ErrCouponOutOfStock
+	case errors.Is(err, inventory.ErrUnknownResource):
+		return apperrors.Wrap(err, apperrors.CodeCouponNotFound, "")
+	case err != nil:
+		return apperrors.Wrap(err, apperrors.CodeCache, "")
 	}
 
-	// 2. Redis 扣减成功后，异步写入数据库 (通过 Worker Pool)
-	// 相比之前的同步写入，这里的吞吐量将极大提升
+	// 4. Redis 扣减成功后，异步写入数据库 (通过 Worker Pool)
+	// 数据库唯一索引兜底去重，落库失败时由 Worker 回调补偿
 	s.workerPool.AddTask(worker.CouponTask{
 		UserID:   userID,
 		CouponID: couponID,
+		TenantID: ctxutil.TenantID(ctx),
 	})
 
 	return nil
 }
 
+// checkTenant ctx 中有租户时优惠券必须属于该租户，否则返回 CodeCouponNotFound；
+// 优惠券的租户首次查询后缓存在本地，之后的领取不再查库
+func (s *couponService) checkTenant(ctx context.Context, couponID string) error {
+	if ctxutil.TenantID(ctx) == "" {
+		return nil
+	}
+	if tenantID, ok := s.tenants.Load(couponID); ok {
+		if !database.TenantVisible(ctx, tenantID.(string)) {
+			return apperrors.New(apperrors.CodeCouponNotFound, "")
+		}
+		return nil
+	}
+
+	// 仓库按 ctx 中的租户查询，其他租户的优惠券查不到
+	coupon, err := s.repo.GetByID(ctx, couponID)
+	if err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if coupon == nil {
+		return apperrors.New(apperrors.CodeCouponNotFound, "")
+	}
+	if coupon.TenantID != "" {
+		s.tenants.Store(couponID, coupon.TenantID)
+	}
+	if !database.TenantVisible(ctx, coupon.TenantID) {
+		return apperrors.New(apperrors.CodeCouponNotFound, "")
+	}
+	return nil
+}
+
+// ClaimOrQueue 请求量远超库存时进入排队，持有放行资格或未开启排队时直接领取
+func (s *couponService) ClaimOrQueue(ctx context.Context, userID, couponID string) (*QueueTicket, error) {
+	if _, ok := s.soldOutMap.Load(couponID); ok {
+		return nil, ErrCouponOutOfStock
+	}
+
+	queued, err := s.waiting.Observe(ctx, couponID)
+	if err != nil {
+		// 排队组件异常时放行，由 Lua 预扣保证不超卖
+		log.Printf("Waiting room unavailable for coupon %s: %v", couponID, err)
+		queued = false
+	}
+
+	if queued {
+		admitted, err := s.waiting.IsAdmitted(ctx, userID, couponID)
+		if err != nil {
+			return nil, apperrors.Wrap(err, apperrors.CodeCache, "")
+		}
+		if !admitted {
+			ticket, err := s.waiting.Join(ctx, userID, couponID)
+			if err != nil {
+				return nil, apperrors.Wrap(err, apperrors.CodeCache, "")
+			}
+			if !ticket.Admitted {
+				return ticket, nil
+			}
+		}
+		defer s.waiting.Leave(ctx, userID, couponID)
+	}
+
+	err = s.ClaimCoupon(ctx, userID, couponID)
+	if errors.Is(err, ErrCouponOutOfStock) {
+		_ = s.waiting.Clear(ctx, couponID)
+	}
+	return nil, err
+}
+
+// QueueStatus 查询排队状态，未排队时返回 nil
+func (s *couponService) QueueStatus(ctx context.Context, userID, couponID string) (*QueueTicket, error) {
+	if _, ok := s.soldOutMap.Load(couponID); ok {
+		return &QueueTicket{CouponID: couponID, SoldOut: true}, nil
+	}
+	ticket, err := s.waiting.Status(ctx, userID, couponID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeCache, "")
+	}
+	return ticket, nil
+}
+
+// ReconcileClaims 对账单张优惠券的 Redis 领取记录与数据库
+func (s *couponService) ReconcileClaims(ctx context.Context, couponID string) (*inventory.ReconcileResult, error) {
+	result, err := s.stock.Reconcile(ctx, CouponStockKind, couponID)
+	if err != nil {
+		return nil, err
+	}
+	if result.StockAfter > 0 {
+		s.soldOutMap.Delete(couponID)
+	}
+	return result, nil
+}
+
+// refreshSoldOut 定期移除库存已恢复的售罄标记
+func (s *couponService) refreshSoldOut(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.soldOutMap.Range(func(key, _ interface{}) bool {
+				couponID := key.(string)
+				available, err := s.stock.Available(ctx, CouponStockKind, couponID)
+				if err == nil && available > 0 {
+					s.soldOutMap.Delete(couponID)
+				}
+				return ctx.Err() == nil
+			})
+		}
+	}
+}
+
 // SendCouponToUser 管理员给用户发券 (复用 ClaimCoupon 逻辑，或实现特定逻辑)
-func (s *couponService) SendCouponToUser(userID, couponID string) error {
+func (s *couponService) SendCouponToUser(ctx context.Context, userID, couponID string) error {
 	// 管理员发券本质上也是扣减库存并增加用户券记录
 	// 这里直接复用 ClaimCoupon 逻辑，保证库存一致性
 	// 如果需要绕过"每个用户限领一张"的限制，可以单独写 Lua 脚本或逻辑
 	// 假设需求是管理员可以给用户发多张，或者也受限制，这里默认受限制
-	return s.ClaimCoupon(userID, couponID)
+	return s.ClaimCoupon(ctx, userID, couponID)
 }
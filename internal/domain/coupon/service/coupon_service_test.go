@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/inventory"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCoupons 记录 GetByID 的调用次数
+type countingCoupons struct {
+	memoryCoupons
+	lookups int
+}
+
+func (c *countingCoupons) GetByID(ctx context.Context, id string) (*model.Coupon, error) {
+	c.lookups++
+	return c.memoryCoupons.GetByID(ctx, id)
+}
+
+// TestClaimCoupon_RejectsOtherTenant 其他租户的优惠券按不存在处理，在 Redis 预扣之前拒绝；
+// 优惠券的租户查询一次后缓存，没有租户的内部调用不限定
+func TestClaimCoupon_RejectsOtherTenant(t *testing.T) {
+	acme := &model.Coupon{Name: "acme", TenantID: "acme"}
+	acme.ID = "c1"
+	coupons := &countingCoupons{memoryCoupons: memoryCoupons{coupons: map[string]*model.Coupon{"c1": acme}}}
+	// stock 为 nil：通过租户校验后才会访问库存，被拒绝的领取不能走到这一步
+	s := &couponService{repo: coupons}
+
+	other := ctxutil.WithTenantID(context.Background(), "globex")
+	err := s.ClaimCoupon(other, "u1", "c1")
+	require.Error(t, err)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponNotFound))
+
+	err = s.SendCouponToUser(other, "u1", "c1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponNotFound), "cached tenant is checked too")
+	assert.Equal(t, 1, coupons.lookups)
+
+	assert.NoError(t, s.checkTenant(ctxutil.WithTenantID(context.Background(), "acme"), "c1"))
+	assert.NoError(t, s.checkTenant(context.Background(), "c1"))
+	assert.True(t, apperrors.IsCode(s.checkTenant(other, "missing"), apperrors.CodeCouponNotFound))
+	assert.Equal(t, 2, coupons.lookups)
+}
+
+// TestCreateCoupon_PersistsTenant 创建的优惠券写入 ctx 中的租户，没有租户时归入默认租户；
+// Redis 不可用时只记录日志，优惠券仍创建成功
+func TestCreateCoupon_PersistsTenant(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	offline := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("redis offline")
+		},
+		MaxRetries: -1,
+	})
+	t.Cleanup(func() { offline.Close() })
+	s := &couponService{repo: repository.NewSimpleCouponRepository(db), stock: inventory.NewManager(offline, nil)}
+
+	start := time.Now()
+	end := start.Add(time.Hour)
+	insert := `INSERT INTO coupons \(name, total, stock, amount, start_time, end_time, tenant_id\)`
+	mock.ExpectQuery(insert).
+		WithArgs("acme coupon", 10, 10, 5.0, start, end, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow("c1", start, start))
+	mock.ExpectQuery(insert).
+		WithArgs("shared coupon", 10, 10, 5.0, start, end, ctxutil.DefaultTenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow("c2", start, start))
+
+	coupon, err := s.CreateCoupon(ctxutil.WithTenantID(context.Background(), "acme"), "acme coupon", 10, 5, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, "c1", coupon.ID)
+
+	coupon, err = s.CreateCoupon(context.Background(), "shared coupon", 10, 5, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, "c2", coupon.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// TestCouponService_RunStopsOnCancel 关闭时取消 ctx，排队放行与售罄刷新循环都退出
+func TestCouponService_RunStopsOnCancel(t *testing.T) {
+	s := &couponService{waiting: NewWaitingRoom(nil, nil)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/inventory"
+)
+
+// CouponStockKind 优惠券在库存组件中的资源类型，持有者为用户 ID，每人每券一张
+const CouponStockKind = "coupon"
+
+// couponStock 以 coupons.total 与 user_coupons 领取记录为准的优惠券库存数据源
+type couponStock struct {
+	repo repository.CouponRepository
+}
+
+// NewCouponStockSource 创建优惠券库存数据源
+func NewCouponStockSource(repo repository.CouponRepository) inventory.Source {
+	return &couponStock{repo: repo}
+}
+
+func (s *couponStock) Capacity(ctx context.Context, couponID string) (int64, error) {
+	coupon, err := s.repo.GetByID(ctx, couponID)
+	if err != nil {
+		return 0, err
+	}
+	if coupon == nil {
+		return 0, fmt.Errorf("coupon %s: %w", couponID, inventory.ErrUnknownResource)
+	}
+	return int64(coupon.Total), nil
+}
+
+func (s *couponStock) Committed(ctx context.Context, couponID string) (map[string]int64, error) {
+	userIDs, err := s.repo.ListClaimedUserIDs(ctx, couponID)
+	if err != nil {
+		return nil, err
+	}
+	committed := make(map[string]int64, len(userIDs))
+	for _, userID := range userIDs {
+		committed[userID] = 1
+	}
+	return committed, nil
+}
+
+// ActiveResources 未结束（含刚结束一天内）的优惠券
+func (s *couponStock) ActiveResources(ctx context.Context) ([]string, error) {
+	return s.repo.ListActiveCouponIDs(ctx, time.Now().Add(-24*time.Hour))
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/jobs"
+)
+
+// SweepJobType 核销过期清理任务类型
+const SweepJobType = "coupon_redemption_sweep"
+
+// RedemptionConfig 核销配置
+type RedemptionConfig struct {
+	LockTTL       time.Duration // 下单锁定的期限，超时未支付的券由清理任务释放
+	SweepSchedule string        // 清理任务的 cron 表达式
+	SweepBatch    int           // 清理任务每批处理的张数
+}
+
+// DefaultRedemptionConfig 默认核销配置
+func DefaultRedemptionConfig() *RedemptionConfig {
+	return &RedemptionConfig{
+		LockTTL:       15 * time.Minute,
+		SweepSchedule: "*/5 * * * *",
+		SweepBatch:    500,
+	}
+}
+
+// LockRequest 下单锁定优惠券请求
+type LockRequest struct {
+	OrderID string `json:"orderId" binding:"required,max=64"`
+	EvaluateRequest
+}
+
+// Redemption 订单使用的优惠券及试算结果
+type Redemption struct {
+	OrderID    string              `json:"orderId"`
+	Evaluation *Evaluation         `json:"evaluation,omitempty"` // 锁定时的试算结果
+	Coupons    []*model.UserCoupon `json:"coupons"`
+}
+
+// SweepResult 一次清理的结果
+type SweepResult struct {
+	Released int64 `json:"released"` // 锁定超时释放的张数
+	Expired  int64 `json:"expired"`  // 优惠券结束后过期的张数
+}
+
+// RedemptionService 优惠券核销：下单时锁定 → 支付成功后使用 → 退款时退还，取消或超时释放；
+// 已领取的券在优惠券结束后由清理任务标记为过期。状态迁移见 model.CanTransition
+type RedemptionService struct {
+	repo   repository.RedemptionRepository
+	rules  *RuleEngine
+	config *RedemptionConfig
+	clock  clock.Clock
+}
+
+// NewRedemptionService 创建核销服务
+func NewRedemptionService(repo repository.RedemptionRepository, rules *RuleEngine, config *RedemptionConfig) *RedemptionService {
+	if config == nil {
+		config = DefaultRedemptionConfig()
+	}
+	return &RedemptionService{
+		repo:   repo,
+		rules:  rules,
+		config: config,
+		clock:  clock.OrReal(nil),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (s *RedemptionService) SetClock(c clock.Clock) {
+	s.clock = clock.OrReal(c)
+}
+
+// Lock 下单时锁定优惠券：请求中的券必须全部被试算选中，否则返回 CodeCouponNotApplicable 且不锁定任何券
+func (s *RedemptionService) Lock(ctx context.Context, userID string, req LockRequest) (*Redemption, error) {
+	evaluation, err := s.rules.Evaluate(ctx, userID, req.EvaluateRequest)
+	if err != nil {
+		return nil, err
+	}
+	discounts := make(map[string]float64, len(evaluation.Coupons))
+	for _, decision := range evaluation.Coupons {
+		if !decision.Applied {
+			return nil, apperrors.New(apperrors.CodeCouponNotApplicable, "").
+				WithDetail("coupon_id", decision.CouponID).
+				WithDetail("reason", decision.Reason)
+		}
+		discounts[decision.CouponID] = decision.Amount
+	}
+
+	coupons, err := s.repo.Lock(ctx, userID, req.OrderID, discounts, s.clock.Now().Add(s.config.LockTTL))
+	if err != nil {
+		return nil, transitionError(err)
+	}
+	return &Redemption{OrderID: req.OrderID, Evaluation: evaluation, Coupons: coupons}, nil
+}
+
+// Confirm 支付成功后使用订单锁定的券；重复调用时返回已使用的券
+func (s *RedemptionService) Confirm(ctx context.Context, orderID string) (*Redemption, error) {
+	return s.transition(ctx, "", orderID, model.UserCouponLocked, model.UserCouponRedeemed)
+}
+
+// Release 订单取消或支付失败时释放锁定的券，userID 不为空时只释放该用户的券；重复调用时返回已释放的结果
+func (s *RedemptionService) Release(ctx context.Context, userID, orderID string) (*Redemption, error) {
+	return s.transition(ctx, userID, orderID, model.UserCouponLocked, model.UserCouponClaimed)
+}
+
+// Refund 订单退款时把已使用的券标记为已退还，券不退回用户；重复调用时返回已退还的券
+func (s *RedemptionService) Refund(ctx context.Context, orderID string) (*Redemption, error) {
+	return s.transition(ctx, "", orderID, model.UserCouponRedeemed, model.UserCouponRefunded)
+}
+
+// transition 迁移订单中的券。订单没有处于 from 状态的券时，若订单的券都已处于 to 状态（重复回调）则视为成功
+func (s *RedemptionService) transition(ctx context.Context, userID, orderID string, from, to int) (*Redemption, error) {
+	coupons, err := s.repo.TransitionOrder(ctx, userID, orderID, from, to)
+	if errors.Is(err, repository.ErrRedemptionNotFound) {
+		existing, listErr := s.repo.ListByOrder(ctx, orderID)
+		if listErr != nil {
+			return nil, apperrors.Wrap(listErr, apperrors.CodeDatabase, "")
+		}
+		// 释放后券不再关联订单，重复释放时查不到记录
+		if to == model.UserCouponClaimed && len(existing) == 0 {
+			return &Redemption{OrderID: orderID, Coupons: []*model.UserCoupon{}}, nil
+		}
+		if len(existing) > 0 && allInStatus(existing, to) && (userID == "" || existing[0].UserID == userID) {
+			return &Redemption{OrderID: orderID, Coupons: existing}, nil
+		}
+		return nil, apperrors.New(apperrors.CodeCouponNotApplicable, "").
+			WithDetail("order_id", orderID).
+			WithDetail("reason", "no "+model.StatusName(from)+" coupons")
+	}
+	if err != nil {
+		return nil, transitionError(err)
+	}
+	return &Redemption{OrderID: orderID, Coupons: coupons}, nil
+}
+
+// Sweep 释放锁定超时的券，并把已结束优惠券中已领取的券标记为过期，每类按批处理直到处理完
+func (s *RedemptionService) Sweep(ctx context.Context) (*SweepResult, error) {
+	result := &SweepResult{}
+	now := s.clock.Now()
+	for {
+		released, err := s.repo.ReleaseExpiredLocks(ctx, now, s.config.SweepBatch)
+		if err != nil {
+			return result, err
+		}
+		result.Released += released
+		if released < int64(s.config.SweepBatch) {
+			break
+		}
+	}
+	for {
+		expired, err := s.repo.ExpireEnded(ctx, now, s.config.SweepBatch)
+		if err != nil {
+			return result, err
+		}
+		result.Expired += expired
+		if expired < int64(s.config.SweepBatch) {
+			break
+		}
+	}
+	if result.Released > 0 || result.Expired > 0 {
+		log.Printf("Coupon sweep released %d expired locks and expired %d coupons", result.Released, result.Expired)
+	}
+	return result, nil
+}
+
+// Register 注册清理任务并按 SweepSchedule 定时执行
+func (s *RedemptionService) Register(manager *jobs.Manager) error {
+	manager.Register(SweepJobType, func(ctx context.Context, job *jobs.Job) error {
+		_, err := s.Sweep(ctx)
+		return err
+	})
+	if err := manager.Schedule(SweepJobType, s.config.SweepSchedule, SweepJobType, nil, jobs.PriorityLow); err != nil {
+		return fmt.Errorf("failed to schedule coupon sweep: %w", err)
+	}
+	return nil
+}
+
+// Settlement 按优惠券汇总区间内的领取与核销，用于活动结算
+func (s *RedemptionService) Settlement(ctx context.Context, q model.SettlementQuery) ([]*model.Settlement, error) {
+	settlements, err := s.repo.Settlement(ctx, q)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	return settlements, nil
+}
+
+// transitionError 状态迁移失败转换为 CodeCouponNotApplicable，附带券与当前状态
+func transitionError(err error) error {
+	var transition *repository.TransitionError
+	if errors.As(err, &transition) {
+		reason := model.StatusName(transition.From)
+		if transition.From == 0 {
+			reason = ReasonNotClaimed
+		}
+		return apperrors.New(apperrors.CodeCouponNotApplicable, "").
+			WithDetail("coupon_id", transition.CouponID).
+			WithDetail("reason", reason)
+	}
+	return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+}
+
+func allInStatus(coupons []*model.UserCoupon, status int) bool {
+	for _, uc := range coupons {
+		if uc.Status != status {
+			return false
+		}
+	}
+	return true
+}
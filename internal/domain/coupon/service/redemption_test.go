@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRedemptions 与 memoryRules 共享领取状态，orders 为 用户/券 -> 锁定或使用的订单
+type memoryRedemptions struct {
+	repository.RedemptionRepository
+	rules       *memoryRules
+	coupons     *memoryCoupons
+	orders      map[string]string
+	lockedUntil map[string]time.Time
+}
+
+func redemptionKey(userID, couponID string) string {
+	return userID + "/" + couponID
+}
+
+func (m *memoryRedemptions) Lock(ctx context.Context, userID, orderID string, discounts map[string]float64, until time.Time) ([]*model.UserCoupon, error) {
+	for id := range discounts {
+		status, ok := m.rules.status[userID][id]
+		if !ok || !model.CanTransition(status, model.UserCouponLocked) {
+			return nil, &repository.TransitionError{CouponID: id, From: status, To: model.UserCouponLocked}
+		}
+	}
+	for id := range discounts {
+		m.rules.status[userID][id] = model.UserCouponLocked
+		m.orders[redemptionKey(userID, id)] = orderID
+		m.lockedUntil[redemptionKey(userID, id)] = until
+	}
+	return m.ListByOrder(ctx, orderID)
+}
+
+func (m *memoryRedemptions) TransitionOrder(ctx context.Context, userID, orderID string, from, to int) ([]*model.UserCoupon, error) {
+	var moved []*model.UserCoupon
+	for _, uc := range m.byOrder(orderID) {
+		if uc.Status != from || (userID != "" && uc.UserID != userID) {
+			continue
+		}
+		m.move(uc.UserID, uc.CouponID, to)
+		uc.Status = to
+		moved = append(moved, uc)
+	}
+	if len(moved) == 0 {
+		return nil, repository.ErrRedemptionNotFound
+	}
+	return moved, nil
+}
+
+func (m *memoryRedemptions) ListByOrder(ctx context.Context, orderID string) ([]*model.UserCoupon, error) {
+	return m.byOrder(orderID), nil
+}
+
+func (m *memoryRedemptions) ReleaseExpiredLocks(ctx context.Context, now time.Time, limit int) (int64, error) {
+	var released int64
+	for userID, coupons := range m.rules.status {
+		for couponID, status := range coupons {
+			if status == model.UserCouponLocked && m.lockedUntil[redemptionKey(userID, couponID)].Before(now) && released < int64(limit) {
+				m.move(userID, couponID, model.UserCouponClaimed)
+				released++
+			}
+		}
+	}
+	return released, nil
+}
+
+func (m *memoryRedemptions) ExpireEnded(ctx context.Context, now time.Time, limit int) (int64, error) {
+	var expired int64
+	for _, coupons := range m.rules.status {
+		for couponID, status := range coupons {
+			if status == model.UserCouponClaimed && !m.coupons.coupons[couponID].EndTime.After(now) && expired < int64(limit) {
+				coupons[couponID] = model.UserCouponExpired
+				expired++
+			}
+		}
+	}
+	return expired, nil
+}
+
+func (m *memoryRedemptions) move(userID, couponID string, to int) {
+	m.rules.status[userID][couponID] = to
+	if to == model.UserCouponClaimed {
+		delete(m.orders, redemptionKey(userID, couponID))
+		delete(m.lockedUntil, redemptionKey(userID, couponID))
+	}
+}
+
+func (m *memoryRedemptions) byOrder(orderID string) []*model.UserCoupon {
+	userCoupons := []*model.UserCoupon{}
+	for userID, coupons := range m.rules.status {
+		for couponID, status := range coupons {
+			if m.orders[redemptionKey(userID, couponID)] == orderID {
+				userCoupons = append(userCoupons, &model.UserCoupon{UserID: userID, CouponID: couponID, Status: status, OrderID: orderID})
+			}
+		}
+	}
+	sort.Slice(userCoupons, func(i, j int) bool { return userCoupons[i].CouponID < userCoupons[j].CouponID })
+	return userCoupons
+}
+
+func statuses(coupons []*model.UserCoupon) map[string]int {
+	result := make(map[string]int, len(coupons))
+	for _, uc := range coupons {
+		result[uc.CouponID] = uc.Status
+	}
+	return result
+}
+
+// TestRedemptionService_Lifecycle 锁定 → 使用 → 退还，重复回调幂等，非法迁移被拒绝，清理任务释放超时锁定并过期已结束的券
+func TestRedemptionService_Lifecycle(t *testing.T) {
+	fake := fakes.NewClock(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	now := fake.Now()
+	coupons := &memoryCoupons{coupons: map[string]*model.Coupon{}}
+	for id, end := range map[string]time.Duration{"a": time.Hour, "b": time.Hour, "c": 30 * time.Minute} {
+		c := &model.Coupon{Amount: 10, StartTime: now.Add(-time.Hour), EndTime: now.Add(end)}
+		c.ID = id
+		coupons.coupons[id] = c
+	}
+	rules := &memoryRules{
+		rules:  map[string]*model.CouponRule{},
+		status: map[string]map[string]int{"u1": {"a": model.UserCouponClaimed, "b": model.UserCouponClaimed, "c": model.UserCouponClaimed}},
+	}
+	engine := NewRuleEngine(rules, coupons, nil, func(ctx context.Context, userID string) (*UserProfile, error) {
+		return &UserProfile{}, nil
+	}, nil)
+	engine.SetClock(fake)
+	repo := &memoryRedemptions{rules: rules, coupons: coupons, orders: map[string]string{}, lockedUntil: map[string]time.Time{}}
+	redemptions := NewRedemptionService(repo, engine, &RedemptionConfig{LockTTL: 15 * time.Minute, SweepBatch: 1})
+	redemptions.SetClock(fake)
+	ctx := context.Background()
+
+	redemption, err := redemptions.Lock(ctx, "u1", LockRequest{OrderID: "o1", EvaluateRequest: EvaluateRequest{OrderAmount: 50, CouponIDs: []string{"a", "b"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, redemption.Evaluation.Discount)
+	assert.Equal(t, map[string]int{"a": model.UserCouponLocked, "b": model.UserCouponLocked}, statuses(redemption.Coupons))
+
+	_, err = redemptions.Lock(ctx, "u1", LockRequest{OrderID: "o2", EvaluateRequest: EvaluateRequest{OrderAmount: 50, CouponIDs: []string{"a", "c"}}})
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponNotApplicable))
+	assert.Equal(t, ReasonLocked, apperrors.From(err).Details["reason"])
+	assert.Equal(t, model.UserCouponClaimed, rules.status["u1"]["c"], "a failed lock leaves the other coupons claimed")
+
+	for i := 0; i < 2; i++ {
+		redemption, err = redemptions.Confirm(ctx, "o1")
+		require.NoError(t, err, "payment callbacks may repeat")
+		assert.Equal(t, map[string]int{"a": model.UserCouponRedeemed, "b": model.UserCouponRedeemed}, statuses(redemption.Coupons))
+	}
+	_, err = redemptions.Release(ctx, "u1", "o1")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponNotApplicable), "redeemed coupons cannot be released")
+	redemption, err = redemptions.Refund(ctx, "o1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": model.UserCouponRefunded, "b": model.UserCouponRefunded}, statuses(redemption.Coupons))
+	_, err = redemptions.Lock(ctx, "u1", LockRequest{OrderID: "o2", EvaluateRequest: EvaluateRequest{OrderAmount: 50, CouponIDs: []string{"a"}}})
+	assert.Equal(t, ReasonUsed, apperrors.From(err).Details["reason"], "refunded coupons are not returned to the user")
+
+	_, err = redemptions.Lock(ctx, "u1", LockRequest{OrderID: "o3", EvaluateRequest: EvaluateRequest{OrderAmount: 50, CouponIDs: []string{"c"}}})
+	require.NoError(t, err)
+	_, err = redemptions.Release(ctx, "u2", "o3")
+	assert.Error(t, err, "only the owner can release")
+	for i := 0; i < 2; i++ {
+		_, err = redemptions.Release(ctx, "u1", "o3")
+		require.NoError(t, err)
+		assert.Equal(t, model.UserCouponClaimed, rules.status["u1"]["c"])
+	}
+
+	_, err = redemptions.Lock(ctx, "u1", LockRequest{OrderID: "o4", EvaluateRequest: EvaluateRequest{OrderAmount: 50, CouponIDs: []string{"c"}}})
+	require.NoError(t, err)
+	fake.Advance(20 * time.Minute)
+	result, err := redemptions.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &SweepResult{Released: 1}, result)
+	_, err = redemptions.Confirm(ctx, "o4")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponNotApplicable), "a released lock cannot be confirmed")
+
+	fake.Advance(20 * time.Minute)
+	result, err = redemptions.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &SweepResult{Expired: 1}, result)
+	assert.Equal(t, model.UserCouponExpired, rules.status["u1"]["c"])
+}
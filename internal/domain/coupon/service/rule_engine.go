@@ -0,0 +1,472 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/clock"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 优惠券不可用的原因
+const (
+	ReasonNotFound       = "not_found"        // 优惠券不存在
+	ReasonNotClaimed     = "not_claimed"      // 未领取
+	ReasonUsed           = "used"             // 已使用或已退还
+	ReasonLocked         = "locked"           // 已被其他订单锁定
+	ReasonNotStarted     = "not_started"      // 未到开始时间
+	ReasonExpired        = "expired"          // 已过期
+	ReasonNewUsersOnly   = "new_users_only"   // 仅限新用户
+	ReasonSegment        = "segment"          // 不在限定的用户分群中
+	ReasonUsageLimit     = "usage_limit"      // 达到每人领取上限
+	ReasonMinOrderAmount = "min_order_amount" // 订单金额不足
+	ReasonNotStackable   = "not_stackable"    // 与本单选中的其他优惠券互斥
+	ReasonNoDiscount     = "no_discount"      // 其他优惠券已抵扣全部订单金额
+)
+
+// ruleCacheKeyPrefix 规则缓存键前缀，未配置规则的优惠券缓存为 null
+const ruleCacheKeyPrefix = "coupon:rule:"
+
+// UserProfile 规则判断所需的用户信息
+type UserProfile struct {
+	RegisteredAt time.Time
+	Segments     []string // 用户所属分群，如 member、tenant:{id}
+}
+
+// ProfileLoader 加载用户信息，用户不存在时返回 nil
+type ProfileLoader func(ctx context.Context, userID string) (*UserProfile, error)
+
+// RuleEngineConfig 规则引擎配置
+type RuleEngineConfig struct {
+	CacheTTL    time.Duration // 规则缓存时间，修改规则时主动失效
+	NewUserDays int           // 规则未指定时，注册多少天内算新用户
+}
+
+// DefaultRuleEngineConfig 默认规则引擎配置
+func DefaultRuleEngineConfig() *RuleEngineConfig {
+	return &RuleEngineConfig{
+		CacheTTL:    10 * time.Minute,
+		NewUserDays: 7,
+	}
+}
+
+// EvaluateRequest 核销前的试算请求
+type EvaluateRequest struct {
+	OrderAmount float64  `json:"orderAmount" binding:"required,gt=0"`
+	CouponIDs   []string `json:"couponIds" binding:"required,min=1,max=20,dive,required"`
+}
+
+// CouponDecision 单张优惠券的试算结果
+type CouponDecision struct {
+	CouponID string  `json:"couponId"`
+	Applied  bool    `json:"applied"`
+	Amount   float64 `json:"amount"`           // 本单实际抵扣金额
+	Reason   string  `json:"reason,omitempty"` // 未使用的原因
+}
+
+// Evaluation 试算结果：按叠加规则选出抵扣最多的组合，未选中的优惠券附带原因
+type Evaluation struct {
+	OrderAmount float64          `json:"orderAmount"`
+	Discount    float64          `json:"discount"`
+	Payable     float64          `json:"payable"`
+	Coupons     []CouponDecision `json:"coupons"` // 与请求中的顺序一致
+}
+
+// RuleEngine 优惠券规则引擎：领取时检查领取条件与每人上限，下单时试算订单金额与叠加规则。
+// 规则存于 coupon_rules 表，按券缓存在 Redis 中
+type RuleEngine struct {
+	rules    repository.RuleRepository
+	coupons  repository.CouponRepository
+	rdb      *redis.Client // 为 nil 时不缓存
+	profiles ProfileLoader // 为 nil 时限定新用户或分群的优惠券一律不满足条件
+	config   *RuleEngineConfig
+	clock    clock.Clock
+}
+
+// NewRuleEngine 创建规则引擎
+func NewRuleEngine(rules repository.RuleRepository, coupons repository.CouponRepository, rdb *redis.Client, profiles ProfileLoader, config *RuleEngineConfig) *RuleEngine {
+	if config == nil {
+		config = DefaultRuleEngineConfig()
+	}
+	return &RuleEngine{
+		rules:    rules,
+		coupons:  coupons,
+		rdb:      rdb,
+		profiles: profiles,
+		config:   config,
+		clock:    clock.OrReal(nil),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (e *RuleEngine) SetClock(c clock.Clock) {
+	e.clock = clock.OrReal(c)
+}
+
+// GetRule 获取优惠券规则，未配置时返回 nil
+func (e *RuleEngine) GetRule(ctx context.Context, couponID string) (*model.CouponRule, error) {
+	rules, err := e.getRules(ctx, []string{couponID})
+	if err != nil {
+		return nil, err
+	}
+	return rules[couponID], nil
+}
+
+// SaveRule 新增或覆盖优惠券规则
+func (e *RuleEngine) SaveRule(ctx context.Context, rule *model.CouponRule) error {
+	if rule.MaxPerUser > 0 && rule.LimitGroup == "" {
+		// 同一张券每人只能领取一次，上限只对组内多张券合计有意义
+		return apperrors.New(apperrors.CodeInvalidParam, "maxPerUser requires limitGroup")
+	}
+	if rule.NewUserDays < 0 || rule.MaxPerUser < 0 || rule.MinOrderAmount < 0 {
+		return apperrors.New(apperrors.CodeInvalidParam, "rule limits must not be negative")
+	}
+	coupon, err := e.coupons.GetByID(ctx, rule.CouponID)
+	if err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if coupon == nil {
+		return apperrors.New(apperrors.CodeCouponNotFound, "")
+	}
+
+	if err := e.rules.SaveRule(ctx, rule); err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	e.invalidate(ctx, rule.CouponID)
+	return nil
+}
+
+// DeleteRule 删除优惠券规则，之后只受库存与有效期限制
+func (e *RuleEngine) DeleteRule(ctx context.Context, couponID string) error {
+	if err := e.rules.DeleteRule(ctx, couponID); err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	e.invalidate(ctx, couponID)
+	return nil
+}
+
+// CheckClaim 领取前检查新用户、用户分群与每人领取上限，不满足时返回对应的业务错误。
+// 组内上限按已落库的领取记录计算，同一用户并发领取组内不同的券时可能超出
+func (e *RuleEngine) CheckClaim(ctx context.Context, userID, couponID string) error {
+	rule, err := e.GetRule(ctx, couponID)
+	if err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if rule == nil {
+		return nil
+	}
+
+	profile, err := e.loadProfile(ctx, userID, rule)
+	if err != nil {
+		return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if reason := e.checkEligibility(rule, profile, true); reason != "" {
+		return apperrors.New(apperrors.CodeCouponNotEligible, "").WithDetail("reason", reason)
+	}
+
+	if rule.MaxPerUser > 0 && rule.LimitGroup != "" {
+		claimed, err := e.rules.CountClaimsInGroup(ctx, userID, rule.LimitGroup)
+		if err != nil {
+			return apperrors.Wrap(err, apperrors.CodeDatabase, "")
+		}
+		if claimed >= int64(rule.MaxPerUser) {
+			return apperrors.New(apperrors.CodeCouponLimitReached, "").
+				WithDetail("reason", ReasonUsageLimit).
+				WithDetail("limit", rule.MaxPerUser)
+		}
+	}
+	return nil
+}
+
+// Evaluate 试算用户在订单中使用这些优惠券的抵扣金额，不修改任何数据
+func (e *RuleEngine) Evaluate(ctx context.Context, userID string, req EvaluateRequest) (*Evaluation, error) {
+	couponIDs := dedupe(req.CouponIDs)
+	coupons, err := e.coupons.GetByIDs(ctx, couponIDs)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	byID := make(map[string]*model.Coupon, len(coupons))
+	for _, coupon := range coupons {
+		byID[coupon.ID] = coupon
+	}
+	owned, err := e.rules.ListUserCoupons(ctx, userID, couponIDs)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	status := make(map[string]int, len(owned))
+	for _, uc := range owned {
+		status[uc.CouponID] = uc.Status
+	}
+	rules, err := e.getRules(ctx, couponIDs)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+
+	var profile *UserProfile
+	now := e.clock.Now()
+	decisions := make([]CouponDecision, len(couponIDs))
+	var candidates []candidate
+	for i, id := range couponIDs {
+		decisions[i] = CouponDecision{CouponID: id}
+		coupon, rule := byID[id], rules[id]
+		reason := usability(coupon, status, now)
+		if reason == "" && rule != nil {
+			if req.OrderAmount < rule.MinOrderAmount {
+				reason = ReasonMinOrderAmount
+			} else if len(rule.Segments) > 0 {
+				if profile == nil {
+					if profile, err = e.loadProfile(ctx, userID, rule); err != nil {
+						return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+					}
+				}
+				// 新用户条件只在领取时检查，领取后过了新用户期仍可使用
+				reason = e.checkEligibility(rule, profile, false)
+			}
+		}
+		if reason != "" {
+			decisions[i].Reason = reason
+			continue
+		}
+		candidates = append(candidates, candidate{index: i, amount: coupon.Amount, rule: rule})
+	}
+
+	discount := applyBest(candidates, decisions, req.OrderAmount)
+	return &Evaluation{
+		OrderAmount: req.OrderAmount,
+		Discount:    discount,
+		Payable:     round2(req.OrderAmount - discount),
+		Coupons:     decisions,
+	}, nil
+}
+
+// loadProfile 规则限定新用户或分群时加载用户信息
+func (e *RuleEngine) loadProfile(ctx context.Context, userID string, rule *model.CouponRule) (*UserProfile, error) {
+	if (!rule.NewUsersOnly && len(rule.Segments) == 0) || e.profiles == nil {
+		return nil, nil
+	}
+	profile, err := e.profiles(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user profile: %w", err)
+	}
+	return profile, nil
+}
+
+// checkEligibility 检查新用户（仅 claiming 时）与用户分群，返回不满足的原因
+func (e *RuleEngine) checkEligibility(rule *model.CouponRule, profile *UserProfile, claiming bool) string {
+	if claiming && rule.NewUsersOnly {
+		days := rule.NewUserDays
+		if days == 0 {
+			days = e.config.NewUserDays
+		}
+		if profile == nil || e.clock.Since(profile.RegisteredAt) > time.Duration(days)*24*time.Hour {
+			return ReasonNewUsersOnly
+		}
+	}
+	if len(rule.Segments) > 0 {
+		if profile == nil || !intersects(rule.Segments, profile.Segments) {
+			return ReasonSegment
+		}
+	}
+	return ""
+}
+
+// usability 优惠券本身是否可用：存在、已领取未使用、在有效期内
+func usability(coupon *model.Coupon, status map[string]int, now time.Time) string {
+	if coupon == nil {
+		return ReasonNotFound
+	}
+	switch s, ok := status[coupon.ID]; {
+	case !ok:
+		return ReasonNotClaimed
+	case s == model.UserCouponRedeemed, s == model.UserCouponRefunded:
+		return ReasonUsed
+	case s == model.UserCouponLocked:
+		return ReasonLocked
+	case s == model.UserCouponExpired:
+		return ReasonExpired
+	}
+	if now.Before(coupon.StartTime) {
+		return ReasonNotStarted
+	}
+	if !now.Before(coupon.EndTime) {
+		return ReasonExpired
+	}
+	return ""
+}
+
+// candidate 通过单券检查、参与组合的优惠券
+type candidate struct {
+	index  int // 在 decisions 中的位置
+	amount float64
+	rule   *model.CouponRule
+}
+
+// applyBest 选出抵扣最多的组合并写入 decisions，返回总抵扣金额（不超过订单金额）。
+// 可叠加的券每个 StackGroup 取面额最大的一张、未分组的全部使用；互斥的券只能单独使用。
+// 两种方案取抵扣更多者，相同时使用更少的券
+func applyBest(candidates []candidate, decisions []CouponDecision, orderAmount float64) float64 {
+	var stacked []candidate
+	bestInGroup := make(map[string]int) // StackGroup -> stacked 中的位置
+	var exclusive *candidate
+	for i := range candidates {
+		c := candidates[i]
+		if c.rule != nil && c.rule.Exclusive {
+			if exclusive == nil || c.amount > exclusive.amount {
+				exclusive = &candidates[i]
+			}
+			continue
+		}
+		if c.rule != nil && c.rule.StackGroup != "" {
+			if j, ok := bestInGroup[c.rule.StackGroup]; ok {
+				if c.amount > stacked[j].amount {
+					stacked[j] = c
+				}
+				continue
+			}
+			bestInGroup[c.rule.StackGroup] = len(stacked)
+		}
+		stacked = append(stacked, c)
+	}
+
+	chosen := stacked
+	if exclusive != nil && math.Min(exclusive.amount, orderAmount) >= math.Min(sum(stacked), orderAmount) {
+		chosen = []candidate{*exclusive}
+	}
+
+	// 面额大的先抵扣，订单金额抵扣完后剩余的券不再使用
+	sort.SliceStable(chosen, func(i, j int) bool { return chosen[i].amount > chosen[j].amount })
+	selected := make(map[int]bool, len(chosen))
+	remaining := orderAmount
+	for _, c := range chosen {
+		selected[c.index] = true
+		if remaining <= 0 {
+			decisions[c.index].Reason = ReasonNoDiscount
+			continue
+		}
+		amount := round2(math.Min(c.amount, remaining))
+		remaining = round2(remaining - amount)
+		decisions[c.index].Applied = true
+		decisions[c.index].Amount = amount
+	}
+	for _, c := range candidates {
+		if !selected[c.index] {
+			decisions[c.index].Reason = ReasonNotStackable
+		}
+	}
+	return round2(orderAmount - remaining)
+}
+
+// getRules 批量获取规则，先读缓存，未命中的从数据库加载后回填；缓存不可用时直接读数据库
+func (e *RuleEngine) getRules(ctx context.Context, couponIDs []string) (map[string]*model.CouponRule, error) {
+	rules := make(map[string]*model.CouponRule, len(couponIDs))
+	missing := couponIDs
+	if e.rdb != nil && len(couponIDs) > 0 {
+		keys := make([]string, len(couponIDs))
+		for i, id := range couponIDs {
+			keys[i] = ruleCacheKeyPrefix + id
+		}
+		values, err := e.rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			log.Printf("Failed to read coupon rule cache: %v", err)
+		} else {
+			missing = nil
+			for i, value := range values {
+				raw, ok := value.(string)
+				var rule *model.CouponRule
+				if !ok || json.Unmarshal([]byte(raw), &rule) != nil {
+					missing = append(missing, couponIDs[i])
+					continue
+				}
+				if rule != nil {
+					rules[couponIDs[i]] = rule
+				}
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return rules, nil
+	}
+
+	loaded, err := e.rules.GetRules(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	pipe := e.pipeline()
+	for _, id := range missing {
+		rule := loaded[id]
+		if rule != nil {
+			rules[id] = rule
+		}
+		if pipe != nil {
+			encoded, _ := json.Marshal(rule)
+			pipe.Set(ctx, ruleCacheKeyPrefix+id, encoded, e.config.CacheTTL)
+		}
+	}
+	if pipe != nil {
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Failed to cache coupon rules: %v", err)
+		}
+	}
+	return rules, nil
+}
+
+func (e *RuleEngine) pipeline() redis.Pipeliner {
+	if e.rdb == nil {
+		return nil
+	}
+	return e.rdb.Pipeline()
+}
+
+// invalidate 修改规则后删除缓存，删除失败时旧规则最多保留 CacheTTL
+func (e *RuleEngine) invalidate(ctx context.Context, couponID string) {
+	if e.rdb == nil {
+		return
+	}
+	if err := e.rdb.Del(ctx, ruleCacheKeyPrefix+couponID).Err(); err != nil {
+		log.Printf("Failed to invalidate coupon rule cache (CouponID: %s): %v", couponID, err)
+	}
+}
+
+func dedupe(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sum(candidates []candidate) float64 {
+	total := 0.0
+	for _, c := range candidates {
+		total += c.amount
+	}
+	return total
+}
+
+// round2 金额保留两位小数
+func round2(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/coupon/model"
+	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCoupons 只实现规则引擎用到的查询
+type memoryCoupons struct {
+	repository.CouponRepository
+	coupons map[string]*model.Coupon
+}
+
+func (m *memoryCoupons) GetByID(ctx context.Context, id string) (*model.Coupon, error) {
+	return m.coupons[id], nil
+}
+
+func (m *memoryCoupons) GetByIDs(ctx context.Context, ids []string) ([]*model.Coupon, error) {
+	var coupons []*model.Coupon
+	for _, id := range ids {
+		if coupon, ok := m.coupons[id]; ok {
+			coupons = append(coupons, coupon)
+		}
+	}
+	return coupons, nil
+}
+
+// memoryRules 内存中的规则与领取记录，status 为 用户 -> 券 -> 状态
+type memoryRules struct {
+	rules  map[string]*model.CouponRule
+	status map[string]map[string]int
+}
+
+func (m *memoryRules) GetRules(ctx context.Context, couponIDs []string) (map[string]*model.CouponRule, error) {
+	rules := make(map[string]*model.CouponRule)
+	for _, id := range couponIDs {
+		if rule, ok := m.rules[id]; ok {
+			rules[id] = rule
+		}
+	}
+	return rules, nil
+}
+
+func (m *memoryRules) SaveRule(ctx context.Context, rule *model.CouponRule) error {
+	m.rules[rule.CouponID] = rule
+	return nil
+}
+
+func (m *memoryRules) DeleteRule(ctx context.Context, couponID string) error {
+	delete(m.rules, couponID)
+	return nil
+}
+
+func (m *memoryRules) CountClaimsInGroup(ctx context.Context, userID, limitGroup string) (int64, error) {
+	var count int64
+	for couponID := range m.status[userID] {
+		if rule, ok := m.rules[couponID]; ok && rule.LimitGroup == limitGroup {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryRules) ListUserCoupons(ctx context.Context, userID string, couponIDs []string) ([]*model.UserCoupon, error) {
+	var userCoupons []*model.UserCoupon
+	for _, id := range couponIDs {
+		if status, ok := m.status[userID][id]; ok {
+			userCoupons = append(userCoupons, &model.UserCoupon{UserID: userID, CouponID: id, Status: status})
+		}
+	}
+	return userCoupons, nil
+}
+
+// TestRuleEngine_ClaimAndEvaluate 领取时检查新用户、分群与组内上限；试算按叠加规则选出抵扣最多的组合
+func TestRuleEngine_ClaimAndEvaluate(t *testing.T) {
+	clock := fakes.NewClock(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	now := clock.Now()
+	coupon := func(id string, amount float64, end time.Time) *model.Coupon {
+		c := &model.Coupon{Amount: amount, StartTime: now.Add(-time.Hour), EndTime: end}
+		c.ID = id
+		return c
+	}
+	coupons := &memoryCoupons{coupons: map[string]*model.Coupon{}}
+	for _, c := range []*model.Coupon{
+		coupon("plain", 10, now.Add(time.Hour)),
+		coupon("shop15", 15, now.Add(time.Hour)),
+		coupon("shop5", 5, now.Add(time.Hour)),
+		coupon("vip30", 30, now.Add(time.Hour)),
+		coupon("min100", 8, now.Add(time.Hour)),
+		coupon("old", 50, now),
+		coupon("welcome", 20, now.Add(time.Hour)),
+	} {
+		coupons.coupons[c.ID] = c
+	}
+	rules := &memoryRules{
+		rules: map[string]*model.CouponRule{
+			"shop15":  {CouponID: "shop15", StackGroup: "shop"},
+			"shop5":   {CouponID: "shop5", StackGroup: "shop", LimitGroup: "spring", MaxPerUser: 1},
+			"vip30":   {CouponID: "vip30", Exclusive: true, Segments: []string{"member"}},
+			"min100":  {CouponID: "min100", MinOrderAmount: 100},
+			"welcome": {CouponID: "welcome", NewUsersOnly: true, LimitGroup: "spring", MaxPerUser: 1},
+		},
+		status: map[string]map[string]int{"u1": {}},
+	}
+	profiles := map[string]*UserProfile{
+		"u1": {RegisteredAt: now.Add(-3 * 24 * time.Hour)},
+		"u2": {RegisteredAt: now.Add(-30 * 24 * time.Hour), Segments: []string{"member"}},
+	}
+	engine := NewRuleEngine(rules, coupons, nil, func(ctx context.Context, userID string) (*UserProfile, error) {
+		return profiles[userID], nil
+	}, nil)
+	engine.SetClock(clock)
+	ctx := context.Background()
+
+	require.NoError(t, engine.CheckClaim(ctx, "u1", "welcome"))
+	rules.status["u1"]["welcome"] = model.UserCouponClaimed
+	err := engine.CheckClaim(ctx, "u1", "shop5")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponLimitReached), "welcome and shop5 share the spring limit")
+	err = engine.CheckClaim(ctx, "u2", "welcome")
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeCouponNotEligible))
+	assert.Equal(t, ReasonNewUsersOnly, apperrors.From(err).Details["reason"])
+	assert.True(t, apperrors.IsCode(engine.CheckClaim(ctx, "u1", "vip30"), apperrors.CodeCouponNotEligible))
+	assert.NoError(t, engine.CheckClaim(ctx, "u2", "vip30"))
+	assert.Error(t, engine.SaveRule(ctx, &model.CouponRule{CouponID: "plain", MaxPerUser: 2}), "a limit needs a group")
+
+	for _, id := range []string{"plain", "shop15", "shop5", "vip30", "min100", "old"} {
+		rules.status["u1"][id] = model.UserCouponClaimed
+	}
+	evaluation, err := engine.Evaluate(ctx, "u1", EvaluateRequest{
+		OrderAmount: 60,
+		CouponIDs:   []string{"plain", "shop15", "shop5", "vip30", "min100", "old", "missing", "welcome", "plain"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 45.0, evaluation.Discount)
+	assert.Equal(t, 15.0, evaluation.Payable)
+	assert.Equal(t, []CouponDecision{
+		{CouponID: "plain", Applied: true, Amount: 10},
+		{CouponID: "shop15", Applied: true, Amount: 15},
+		{CouponID: "shop5", Reason: ReasonNotStackable},
+		{CouponID: "vip30", Reason: ReasonSegment},
+		{CouponID: "min100", Reason: ReasonMinOrderAmount},
+		{CouponID: "old", Reason: ReasonExpired},
+		{CouponID: "missing", Reason: ReasonNotFound},
+		{CouponID: "welcome", Applied: true, Amount: 20},
+	}, evaluation.Coupons, "welcome stays usable after the new-user window; duplicates are dropped")
+
+	// 会员的互斥券抵扣更多时单独使用，金额不超过订单金额
+	rules.status["u2"] = map[string]int{"vip30": model.UserCouponClaimed, "plain": model.UserCouponClaimed}
+	evaluation, err = engine.Evaluate(ctx, "u2", EvaluateRequest{OrderAmount: 25, CouponIDs: []string{"plain", "vip30"}})
+	require.NoError(t, err)
+	assert.Equal(t, []CouponDecision{{CouponID: "plain", Reason: ReasonNotStackable}, {CouponID: "vip30", Applied: true, Amount: 25}}, evaluation.Coupons)
+	assert.Equal(t, 0.0, evaluation.Payable)
+}
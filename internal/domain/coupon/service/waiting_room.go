@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"user_crud_jwt/pkg/inventory"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WaitingRoomConfig 抢券排队配置
+type WaitingRoomConfig struct {
+	// ActivateThreshold 单张券每秒领取请求数超过该值时开启排队
+	ActivateThreshold int64 `json:"activate_threshold"`
+	// ActiveTTL 排队模式在最后一次超阈值后保持的时间
+	ActiveTTL time.Duration `json:"active_ttl"`
+	// AdmitRate 每秒放行人数
+	AdmitRate int `json:"admit_rate"`
+	// QueueTTL 排队者超过该时间未轮询视为离开
+	QueueTTL time.Duration `json:"queue_ttl"`
+	// AdmitTTL 放行后必须在该时间内完成领取
+	AdmitTTL time.Duration `json:"admit_ttl"`
+	// TickInterval 放行间隔
+	TickInterval time.Duration `json:"tick_interval"`
+}
+
+// DefaultWaitingRoomConfig 默认排队配置
+func DefaultWaitingRoomConfig() *WaitingRoomConfig {
+	return &WaitingRoomConfig{
+		ActivateThreshold: 500,
+		ActiveTTL:         time.Minute,
+		AdmitRate:         100,
+		QueueTTL:          30 * time.Second,
+		AdmitTTL:          30 * time.Second,
+		TickInterval:      time.Second,
+	}
+}
+
+// QueueTicket 排队状态
+type QueueTicket struct {
+	CouponID   string `json:"couponId"`
+	Position   int64  `json:"position"` // 从 1 开始，放行后为 0
+	Admitted   bool   `json:"admitted"`
+	SoldOut    bool   `json:"soldOut"`
+	RetryAfter int    `json:"retryAfter"` // 建议的轮询间隔（秒）
+}
+
+// 排队相关键
+func waitingRoomKeys(couponID string) (queue, seen, admitted, seq string) {
+	return fmt.Sprintf("coupon:wr:queue:%s", couponID),
+		fmt.Sprintf("coupon:wr:seen:%s", couponID),
+		fmt.Sprintf("coupon:wr:admitted:%s", couponID),
+		fmt.Sprintf("coupon:wr:seq:%s", couponID)
+}
+
+const waitingRoomActiveSet = "coupon:wr:active"
+
+// Lua 脚本：入队（已在队列中则只刷新心跳），返回 {是否已放行, 排名}
+var joinScript = redis.NewScript(`
+	local queue_key, seen_key, admitted_key, seq_key = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+	local user_id, now = ARGV[1], tonumber(ARGV[2])
+
+	if redis.call("ZSCORE", admitted_key, user_id) then
+		return {1, 0}
+	end
+	if not redis.call("ZSCORE", queue_key, user_id) then
+		local seq = redis.call("INCR", seq_key)
+		redis.call("ZADD", queue_key, seq, user_id)
+	end
+	redis.call("ZADD", seen_key, now, user_id)
+	return {0, redis.call("ZRANK", queue_key, user_id) + 1}
+`)
+
+// Lua 脚本：清理超时的排队者与过期的放行资格，再按速率与剩余库存放行，返回 {放行人数, 剩余排队人数}
+var admitScript = redis.NewScript(`
+	local queue_key, seen_key, admitted_key, stock_key = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+	local now, queue_ttl, admit_ttl, rate = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+
+	local gone = redis.call("ZRANGEBYSCORE", seen_key, "-inf", now - queue_ttl)
+	for _, user_id in ipairs(gone) do
+		redis.call("ZREM", queue_key, user_id)
+		redis.call("ZREM", seen_key, user_id)
+	end
+	redis.call("ZREMRANGEBYSCORE", admitted_key, "-inf", now - admit_ttl)
+
+	local stock = tonumber(redis.call("GET", stock_key) or "0")
+	local capacity = math.min(rate, stock - redis.call("ZCARD", admitted_key))
+	local admitted = 0
+	if capacity > 0 then
+		local popped = redis.call("ZPOPMIN", queue_key, capacity)
+		for i = 1, #popped, 2 do
+			redis.call("ZADD", admitted_key, now, popped[i])
+			redis.call("ZREM", seen_key, popped[i])
+			admitted = admitted + 1
+		end
+	end
+	return {admitted, redis.call("ZCARD", queue_key)}
+`)
+
+// WaitingRoom 抢券虚拟排队：请求量远超库存时，超出部分进入队列并按速率放行，
+// 按入队顺序公平放行，避免大量请求同时打到数据库
+type WaitingRoom struct {
+	rdb    *redis.Client
+	config *WaitingRoomConfig
+}
+
+// NewWaitingRoom 创建排队组件
+func NewWaitingRoom(rdb *redis.Client, config *WaitingRoomConfig) *WaitingRoom {
+	if config == nil {
+		config = DefaultWaitingRoomConfig()
+	}
+	return &WaitingRoom{rdb: rdb, config: config}
+}
+
+// Observe 记录一次领取请求并判断是否需要排队
+func (w *WaitingRoom) Observe(ctx context.Context, couponID string) (bool, error) {
+	activeKey := fmt.Sprintf("coupon:wr:on:%s", couponID)
+	rateKey := fmt.Sprintf("coupon:wr:rate:%s:%d", couponID, time.Now().Unix())
+
+	pipe := w.rdb.TxPipeline()
+	count := pipe.Incr(ctx, rateKey)
+	pipe.Expire(ctx, rateKey, 2*time.Second)
+	active := pipe.Exists(ctx, activeKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	if count.Val() > w.config.ActivateThreshold {
+		pipe := w.rdb.TxPipeline()
+		pipe.Set(ctx, activeKey, 1, w.config.ActiveTTL)
+		pipe.SAdd(ctx, waitingRoomActiveSet, couponID)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return false, err
+		}
+		if active.Val() == 0 {
+			log.Printf("Waiting room activated for coupon %s (%d req/s)", couponID, count.Val())
+		}
+		return true, nil
+	}
+	return active.Val() > 0, nil
+}
+
+// Join 进入队列或刷新心跳，返回当前排队状态
+func (w *WaitingRoom) Join(ctx context.Context, userID, couponID string) (*QueueTicket, error) {
+	queue, seen, admitted, seq := waitingRoomKeys(couponID)
+	result, err := joinScript.Run(ctx, w.rdb, []string{queue, seen, admitted, seq}, userID, time.Now().Unix()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return &QueueTicket{
+		CouponID:   couponID,
+		Admitted:   result[0] == 1,
+		Position:   result[1],
+		RetryAfter: w.retryAfter(result[1]),
+	}, nil
+}
+
+// Status 查询排队状态，未在队列中时返回 nil。轮询同时作为心跳
+func (w *WaitingRoom) Status(ctx context.Context, userID, couponID string) (*QueueTicket, error) {
+	queue, seen, admitted, _ := waitingRoomKeys(couponID)
+	if err := w.rdb.ZScore(ctx, admitted, userID).Err(); err == nil {
+		return &QueueTicket{CouponID: couponID, Admitted: true}, nil
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	rank, err := w.rdb.ZRank(ctx, queue, userID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.rdb.ZAdd(ctx, seen, redis.Z{Score: float64(time.Now().Unix()), Member: userID})
+	return &QueueTicket{
+		CouponID:   couponID,
+		Position:   rank + 1,
+		RetryAfter: w.retryAfter(rank + 1),
+	}, nil
+}
+
+// IsAdmitted 是否持有有效的放行资格
+func (w *WaitingRoom) IsAdmitted(ctx context.Context, userID, couponID string) (bool, error) {
+	_, _, admitted, _ := waitingRoomKeys(couponID)
+	score, err := w.rdb.ZScore(ctx, admitted, userID).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(time.Unix(int64(score), 0)) < w.config.AdmitTTL, nil
+}
+
+// Leave 领取完成（无论成败）后释放放行资格
+func (w *WaitingRoom) Leave(ctx context.Context, userID, couponID string) error {
+	queue, seen, admitted, _ := waitingRoomKeys(couponID)
+	pipe := w.rdb.TxPipeline()
+	pipe.ZRem(ctx, admitted, userID)
+	pipe.ZRem(ctx, queue, userID)
+	pipe.ZRem(ctx, seen, userID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Clear 券售罄后清空队列
+func (w *WaitingRoom) Clear(ctx context.Context, couponID string) error {
+	queue, seen, admitted, seq := waitingRoomKeys(couponID)
+	pipe := w.rdb.TxPipeline()
+	pipe.Del(ctx, queue, seen, admitted, seq)
+	pipe.SRem(ctx, waitingRoomActiveSet, couponID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Run 定期为排队中的券放行，多实例部署时每个时间片只有一个实例执行
+func (w *WaitingRoom) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			couponIDs, err := w.rdb.SMembers(ctx, waitingRoomActiveSet).Result()
+			if err != nil {
+				log.Printf("Failed to list waiting rooms: %v", err)
+				continue
+			}
+			for _, couponID := range couponIDs {
+				w.admit(ctx, couponID, now)
+			}
+		}
+	}
+}
+
+func (w *WaitingRoom) admit(ctx context.Context, couponID string, now time.Time) {
+	slot := now.Truncate(w.config.TickInterval).UnixNano()
+	lockKey := fmt.Sprintf("coupon:wr:tick:%s:%d", couponID, slot)
+	acquired, err := w.rdb.SetNX(ctx, lockKey, 1, 2*w.config.TickInterval).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	// 每个时间片放行 AdmitRate * 时间片长度 人
+	rate := int(float64(w.config.AdmitRate) * w.config.TickInterval.Seconds())
+	if rate < 1 {
+		rate = 1
+	}
+
+	queue, seen, admitted, _ := waitingRoomKeys(couponID)
+	stockKey := inventory.StockKey(CouponStockKind, couponID)
+	result, err := admitScript.Run(ctx, w.rdb, []string{queue, seen, admitted, stockKey},
+		now.Unix(), int64(w.config.QueueTTL.Seconds()), int64(w.config.AdmitTTL.Seconds()), rate).Int64Slice()
+	if err != nil {
+		log.Printf("Failed to admit waiting room for coupon %s: %v", couponID, err)
+		return
+	}
+
+	// 队列已空且排队模式已结束时移出活动集合
+	if result[1] == 0 {
+		active, err := w.rdb.Exists(ctx, fmt.Sprintf("coupon:wr:on:%s", couponID)).Result()
+		if err == nil && active == 0 {
+			w.rdb.SRem(ctx, waitingRoomActiveSet, couponID)
+		}
+	}
+}
+
+// retryAfter 按排名估算轮询间隔，排名越靠后轮询越慢，减少无效请求
+func (w *WaitingRoom) retryAfter(position int64) int {
+	if position <= 0 || w.config.AdmitRate <= 0 {
+		return 1
+	}
+	seconds := int(position / int64(w.config.AdmitRate) / 2)
+	switch {
+	case seconds < 1:
+		return 1
+	case seconds > 10:
+		// 超过 QueueTTL 不轮询会被移出队列，间隔需保持在其以内
+		return 10
+	default:
+		return seconds
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/inventory"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWaitingRoom(t *testing.T) (*WaitingRoom, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	config := DefaultWaitingRoomConfig()
+	config.ActivateThreshold = 3
+	config.AdmitRate = 2
+	return NewWaitingRoom(rdb, config), mr
+}
+
+// join 依次入队，返回各自的排名
+func join(t *testing.T, w *WaitingRoom, couponID string, userIDs ...string) []int64 {
+	t.Helper()
+	positions := make([]int64, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ticket, err := w.Join(context.Background(), userID, couponID)
+		require.NoError(t, err)
+		positions = append(positions, ticket.Position)
+	}
+	return positions
+}
+
+func admitted(t *testing.T, w *WaitingRoom, couponID string, userIDs ...string) []bool {
+	t.Helper()
+	result := make([]bool, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ok, err := w.IsAdmitted(context.Background(), userID, couponID)
+		require.NoError(t, err)
+		result = append(result, ok)
+	}
+	return result
+}
+
+// TestWaitingRoom_Observe 每秒请求数超过阈值后开启排队，并在 ActiveTTL 内保持
+func TestWaitingRoom_Observe(t *testing.T) {
+	w, mr := newTestWaitingRoom(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		active, err := w.Observe(ctx, "c1")
+		require.NoError(t, err)
+		assert.False(t, active, "request %d is within the threshold", i+1)
+	}
+	// 计数按秒分桶，跨秒时最多再需要一轮才能超过阈值
+	activated := false
+	for i := 0; i < 4 && !activated; i++ {
+		var err error
+		activated, err = w.Observe(ctx, "c1")
+		require.NoError(t, err)
+	}
+	require.True(t, activated)
+	isActive, err := mr.SIsMember(waitingRoomActiveSet, "c1")
+	require.NoError(t, err)
+	assert.True(t, isActive)
+
+	active, err := w.Observe(ctx, "c2")
+	require.NoError(t, err)
+	assert.False(t, active, "coupons are tracked independently")
+
+	mr.FastForward(w.config.ActiveTTL)
+	active, err = w.Observe(ctx, "c1")
+	require.NoError(t, err)
+	assert.False(t, active, "the waiting room closes once traffic stays below the threshold for ActiveTTL")
+}
+
+// TestWaitingRoom_Join 按入队顺序排名，重复入队保持原排名，放行后再入队直接返回已放行
+func TestWaitingRoom_Join(t *testing.T) {
+	w, mr := newTestWaitingRoom(t)
+	ctx := context.Background()
+	mr.Set(inventory.StockKey(CouponStockKind, "c1"), "10")
+
+	assert.Equal(t, []int64{1, 2, 3}, join(t, w, "c1", "u1", "u2", "u3"))
+	assert.Equal(t, []int64{2}, join(t, w, "c1", "u2"))
+	assert.Equal(t, []int64{1}, join(t, w, "c2", "u3"), "queues are per coupon")
+
+	status, err := w.Status(ctx, "u3", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, &QueueTicket{CouponID: "c1", Position: 3, RetryAfter: 1}, status)
+	status, err = w.Status(ctx, "u9", "c1")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	w.admit(ctx, "c1", time.Now())
+	ticket, err := w.Join(ctx, "u1", "c1")
+	require.NoError(t, err)
+	assert.True(t, ticket.Admitted)
+	assert.Zero(t, ticket.Position)
+	assert.Equal(t, []int64{1}, join(t, w, "c1", "u3"))
+	status, err = w.Status(ctx, "u2", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, &QueueTicket{CouponID: "c1", Admitted: true}, status)
+}
+
+// TestWaitingRoom_AdmissionPacing 每个时间片最多放行 AdmitRate * TickInterval 人，已放行人数不超过剩余库存；
+// 同一时间片只放行一次，释放资格后腾出名额
+func TestWaitingRoom_AdmissionPacing(t *testing.T) {
+	w, mr := newTestWaitingRoom(t)
+	ctx := context.Background()
+	mr.Set(inventory.StockKey(CouponStockKind, "c1"), "3")
+	users := []string{"u1", "u2", "u3", "u4", "u5"}
+	join(t, w, "c1", users...)
+	now := time.Now().Truncate(time.Second)
+
+	w.admit(ctx, "c1", now)
+	assert.Equal(t, []bool{true, true, false, false, false}, admitted(t, w, "c1", users...))
+	w.admit(ctx, "c1", now.Add(500*time.Millisecond))
+	assert.Equal(t, []bool{true, true, false, false, false}, admitted(t, w, "c1", users...), "one admission per tick")
+
+	w.admit(ctx, "c1", now.Add(time.Second))
+	assert.Equal(t, []bool{true, true, true, false, false}, admitted(t, w, "c1", users...), "admissions never exceed the stock")
+	w.admit(ctx, "c1", now.Add(2*time.Second))
+	assert.Equal(t, []bool{true, true, true, false, false}, admitted(t, w, "c1", users...))
+
+	require.NoError(t, w.Leave(ctx, "u1", "c1"))
+	w.admit(ctx, "c1", now.Add(3*time.Second))
+	assert.Equal(t, []bool{false, true, true, true, false}, admitted(t, w, "c1", users...))
+	status, err := w.Status(ctx, "u5", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), status.Position)
+
+	// 售罄后清空
+	require.NoError(t, w.Clear(ctx, "c1"))
+	assert.Equal(t, []bool{false, false, false, false, false}, admitted(t, w, "c1", users...))
+	status, err = w.Status(ctx, "u5", "c1")
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
+
+// TestWaitingRoom_Expiry 超过 QueueTTL 未轮询的排队者被移出队列，放行资格在 AdmitTTL 后失效
+func TestWaitingRoom_Expiry(t *testing.T) {
+	w, mr := newTestWaitingRoom(t)
+	ctx := context.Background()
+	mr.Set(inventory.StockKey(CouponStockKind, "c1"), "10")
+	join(t, w, "c1", "u1", "u2", "u3")
+
+	w.admit(ctx, "c1", time.Now().Add(-w.config.AdmitTTL-time.Second))
+	assert.Equal(t, []bool{false, false}, admitted(t, w, "c1", "u1", "u2"), "admissions older than AdmitTTL are not valid")
+
+	w.admit(ctx, "c1", time.Now().Add(w.config.QueueTTL+time.Second))
+	status, err := w.Status(ctx, "u3", "c1")
+	require.NoError(t, err)
+	assert.Nil(t, status, "queued users that stop polling are dropped")
+	assert.Equal(t, []bool{false}, admitted(t, w, "c1", "u3"))
+}
+
+// TestWaitingRoom_Deactivate 队列清空且排队模式结束后移出活动集合
+func TestWaitingRoom_Deactivate(t *testing.T) {
+	w, mr := newTestWaitingRoom(t)
+	ctx := context.Background()
+	mr.Set(inventory.StockKey(CouponStockKind, "c1"), "10")
+	mr.SetAdd(waitingRoomActiveSet, "c1")
+	mr.Set("coupon:wr:on:c1", "1")
+	join(t, w, "c1", "u1")
+	now := time.Now()
+
+	w.admit(ctx, "c1", now)
+	isActive, err := mr.SIsMember(waitingRoomActiveSet, "c1")
+	require.NoError(t, err)
+	assert.True(t, isActive, "the room stays active while the activation flag is set")
+
+	mr.Del("coupon:wr:on:c1")
+	w.admit(ctx, "c1", now.Add(time.Second))
+	assert.False(t, mr.Exists(waitingRoomActiveSet))
+}
+
+// TestWaitingRoom_RetryAfter 排名越靠后轮询间隔越长，最长 10 秒
+func TestWaitingRoom_RetryAfter(t *testing.T) {
+	w, _ := newTestWaitingRoom(t)
+	tests := []struct {
+		position int64
+		want     int
+	}{
+		{0, 1},
+		{1, 1},
+		{4, 1},
+		{8, 2},
+		{20, 5},
+		{1000, 10},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, w.retryAfter(tt.position), "position=%d", tt.position)
+	}
+}
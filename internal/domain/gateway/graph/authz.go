@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/graph-gophers/graphql-go/ast"
+)
+
+// Viewer 发起查询的用户，来自 JWT
+type Viewer struct {
+	UserID string
+	Role   int
+}
+
+// IsAdmin JWT 中的角色是否为管理员
+func (v Viewer) IsAdmin() bool {
+	return v.Role == model.RoleAdmin
+}
+
+type viewerKey struct{}
+
+// WithViewer 将查询用户写入 context
+func WithViewer(ctx context.Context, viewer Viewer) context.Context {
+	return context.WithValue(ctx, viewerKey{}, viewer)
+}
+
+// ViewerFromContext 读取查询用户
+func ViewerFromContext(ctx context.Context) (Viewer, bool) {
+	viewer, ok := ctx.Value(viewerKey{}).(Viewer)
+	return viewer, ok && viewer.UserID != ""
+}
+
+// authDirective Schema 中声明字段权限的指令
+const authDirective = "auth"
+
+// authorizer 按 Schema 中的 @auth 指令做字段级鉴权
+type authorizer struct {
+	permissions map[string]security.Permission // "Type.field" -> 权限
+	checker     security.PermissionChecker     // 为 nil 时只有拥有者与管理员可见
+}
+
+func newAuthorizer(schema *ast.Schema, checker security.PermissionChecker) *authorizer {
+	a := &authorizer{
+		permissions: make(map[string]security.Permission),
+		checker:     checker,
+	}
+	for _, obj := range schema.Objects {
+		for _, field := range obj.Fields {
+			directive := field.Directives.Get(authDirective)
+			if directive == nil {
+				continue
+			}
+			if v, ok := directive.Arguments.Get("permission"); ok {
+				if perm, ok := v.Deserialize(nil).(string); ok {
+					a.permissions[obj.Name+"."+field.Name] = security.Permission(perm)
+				}
+			}
+		}
+	}
+	return a
+}
+
+// authorize 校验当前用户能否读取 field（"Type.field"），owner 为字段所属对象的拥有者。
+// 权限服务出错时拒绝访问
+func (a *authorizer) authorize(ctx context.Context, field, owner string) error {
+	viewer, ok := ViewerFromContext(ctx)
+	if !ok {
+		return newFieldError(apperrors.CodeTokenInvalid, "authentication required")
+	}
+	perm, ok := a.permissions[field]
+	if !ok {
+		return nil
+	}
+	if (owner != "" && viewer.UserID == owner) || viewer.IsAdmin() {
+		return nil
+	}
+	if a.checker != nil {
+		// 未分配角色的用户会返回错误，同样视为无权限
+		if allowed, err := a.checker.HasPermission(ctx, viewer.UserID, perm); err == nil && allowed {
+			return nil
+		}
+	}
+	return newFieldError(apperrors.CodeNoPermission, "permission "+string(perm)+" required for "+field)
+}
+
+// fieldError 解析器错误，错误码通过 extensions.code 返回，与 REST 接口的错误码一致
+type fieldError struct {
+	code    apperrors.Code
+	message string
+}
+
+func newFieldError(code apperrors.Code, message string) *fieldError {
+	return &fieldError{code: code, message: message}
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+// Extensions 实现 graphql-go 的错误扩展接口
+func (e *fieldError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
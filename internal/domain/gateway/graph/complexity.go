@@ -0,0 +1,639 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graph-gophers/graphql-go/ast"
+)
+
+// 查询复杂度估算：每个字段计 1，带 limit 参数的字段按 limit 放大子字段的复杂度，
+// 没有 limit 参数的列表字段按 defaultListSize 估算。
+// graphql-go 未公开查询解析器，这里实现一个只覆盖可执行文档的最小解析器
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexer GraphQL 词法分析，逗号与注释按规范视为空白
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF}, nil
+	}
+
+	start := l.pos
+	ch := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokenPunct, text: "..."}, nil
+	case strings.IndexByte("!$&():=@[]{}|", ch) >= 0:
+		l.pos++
+		return token{kind: tokenPunct, text: string(ch)}, nil
+	case ch == '_' || isLetter(ch):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, text: l.src[start:l.pos]}, nil
+	case ch == '-' || isDigit(ch):
+		return l.number()
+	case ch == '"':
+		return l.string()
+	}
+	return token{}, fmt.Errorf("unexpected character %q at offset %d", ch, l.pos)
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch ch := l.src[l.pos]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			l.pos++
+		case ch == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\xef\xbb\xbf"):
+			l.pos += 3
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		switch {
+		case isDigit(ch):
+		case ch == '.' || ch == 'e' || ch == 'E':
+			kind = tokenFloat
+		case (ch == '+' || ch == '-') && kind == tokenFloat:
+		default:
+			return token{kind: kind, text: l.src[start:l.pos]}, nil
+		}
+		l.pos++
+	}
+	return token{kind: kind, text: l.src[start:l.pos]}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(strings.ReplaceAll(l.src[l.pos+3:], `\"""`, "____"), `"""`)
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated block string at offset %d", start)
+		}
+		l.pos += 3 + end + 3
+		return token{kind: tokenString, text: l.src[start:l.pos]}, nil
+	}
+	for l.pos++; l.pos < len(l.src); l.pos++ {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos++
+		case '"':
+			l.pos++
+			return token{kind: tokenString, text: l.src[start:l.pos]}, nil
+		case '\n', '\r':
+			return token{}, fmt.Errorf("unterminated string at offset %d", start)
+		}
+	}
+	return token{}, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func isLetter(ch byte) bool { return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' }
+func isDigit(ch byte) bool  { return ch >= '0' && ch <= '9' }
+
+// 解析结果只保留估算复杂度需要的信息
+
+type selection struct {
+	field         string // 字段名，片段展开与内联片段时为空
+	args          map[string]value
+	fragment      string      // 片段展开的片段名
+	typeCondition string      // 内联片段的类型条件
+	selections    []selection // 子字段
+}
+
+// value 参数值，只区分整数字面量与变量
+type value struct {
+	variable string
+	literal  *int64
+}
+
+type operation struct {
+	name       string
+	selections []selection
+}
+
+type fragment struct {
+	typeCondition string
+	selections    []selection
+}
+
+type document struct {
+	operations []operation
+	fragments  map[string]fragment
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func parseDocument(query string) (*document, error) {
+	p := &parser{lex: &lexer{src: query}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, operation{selections: selections})
+		case p.peek(tokenName, "fragment"):
+			name, frag, err := p.fragmentDefinition()
+			if err != nil {
+				return nil, err
+			}
+			doc.fragments[name] = frag
+		case p.tok.kind == tokenName:
+			op, err := p.operationDefinition()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind tokenKind, text string) bool {
+	return p.tok.kind == kind && p.tok.text == text
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return fmt.Errorf("unexpected end of query")
+	}
+	return fmt.Errorf("unexpected %q", p.tok.text)
+}
+
+// expect 消费指定的标点
+func (p *parser) expect(text string) error {
+	if !p.peek(tokenPunct, text) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+// operationDefinition query|mutation|subscription Name? VariableDefinitions? Directives? SelectionSet
+func (p *parser) operationDefinition() (operation, error) {
+	var op operation
+	if err := p.advance(); err != nil {
+		return op, err
+	}
+	if p.tok.kind == tokenName {
+		op.name = p.tok.text
+		if err := p.advance(); err != nil {
+			return op, err
+		}
+	}
+	if p.peek(tokenPunct, "(") {
+		// 变量定义只需跳过，类型与默认值不影响估算
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return op, err
+		}
+	}
+	if err := p.directives(); err != nil {
+		return op, err
+	}
+	selections, err := p.selectionSet()
+	op.selections = selections
+	return op, err
+}
+
+// fragmentDefinition fragment Name on Type Directives? SelectionSet
+func (p *parser) fragmentDefinition() (string, fragment, error) {
+	var frag fragment
+	if err := p.advance(); err != nil {
+		return "", frag, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return "", frag, err
+	}
+	if !p.peek(tokenName, "on") {
+		return "", frag, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return "", frag, err
+	}
+	if frag.typeCondition, err = p.name(); err != nil {
+		return "", frag, err
+	}
+	if err := p.directives(); err != nil {
+		return "", frag, err
+	}
+	frag.selections, err = p.selectionSet()
+	return name, frag, err
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for !p.peek(tokenPunct, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	var sel selection
+	if p.peek(tokenPunct, "...") {
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		if p.tok.kind == tokenName && p.tok.text != "on" {
+			// 片段展开
+			sel.fragment = p.tok.text
+			if err := p.advance(); err != nil {
+				return sel, err
+			}
+			return sel, p.directives()
+		}
+		// 内联片段，类型条件可省略
+		if p.peek(tokenName, "on") {
+			if err := p.advance(); err != nil {
+				return sel, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return sel, err
+			}
+			sel.typeCondition = name
+		}
+		if err := p.directives(); err != nil {
+			return sel, err
+		}
+		var err error
+		sel.selections, err = p.selectionSet()
+		return sel, err
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return sel, err
+	}
+	sel.field = name
+	if p.peek(tokenPunct, ":") {
+		// 别名只影响返回的键名
+		if err := p.advance(); err != nil {
+			return sel, err
+		}
+		if sel.field, err = p.name(); err != nil {
+			return sel, err
+		}
+	}
+	if p.peek(tokenPunct, "(") {
+		if sel.args, err = p.arguments(); err != nil {
+			return sel, err
+		}
+	}
+	if err := p.directives(); err != nil {
+		return sel, err
+	}
+	if p.peek(tokenPunct, "{") {
+		sel.selections, err = p.selectionSet()
+	}
+	return sel, err
+}
+
+func (p *parser) arguments() (map[string]value, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	args := make(map[string]value)
+	for !p.peek(tokenPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.value(); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.advance()
+}
+
+// value 解析参数值，列表与对象只校验括号配对
+func (p *parser) value() (value, error) {
+	var v value
+	switch {
+	case p.peek(tokenPunct, "$"):
+		if err := p.advance(); err != nil {
+			return v, err
+		}
+		name, err := p.name()
+		v.variable = name
+		return v, err
+	case p.peek(tokenPunct, "["):
+		return v, p.skipBalanced("[", "]")
+	case p.peek(tokenPunct, "{"):
+		return v, p.skipBalanced("{", "}")
+	case p.tok.kind == tokenInt:
+		n, err := strconv.ParseInt(p.tok.text, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid integer %q", p.tok.text)
+		}
+		v.literal = &n
+	case p.tok.kind == tokenFloat, p.tok.kind == tokenString, p.tok.kind == tokenName:
+	default:
+		return v, p.unexpected()
+	}
+	return v, p.advance()
+}
+
+func (p *parser) directives() error {
+	for p.peek(tokenPunct, "@") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if p.peek(tokenPunct, "(") {
+			if _, err := p.arguments(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipBalanced 跳过成对的括号及其内容
+func (p *parser) skipBalanced(open, close string) error {
+	depth := 0
+	for {
+		switch {
+		case p.tok.kind == tokenEOF:
+			return p.unexpected()
+		case p.peek(tokenPunct, open):
+			depth++
+		case p.peek(tokenPunct, close):
+			depth--
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// complexityAnalyzer 基于 Schema 的字段定义估算查询复杂度
+type complexityAnalyzer struct {
+	schema          *ast.Schema
+	defaultListSize int
+}
+
+// Complexity 估算指定操作的复杂度，operationName 为空时文档中只能有一个操作
+func (a *complexityAnalyzer) Complexity(query, operationName string, variables map[string]interface{}) (int, error) {
+	doc, err := parseDocument(query)
+	if err != nil {
+		return 0, err
+	}
+
+	var op *operation
+	for i := range doc.operations {
+		if doc.operations[i].name == operationName || (operationName == "" && len(doc.operations) == 1) {
+			op = &doc.operations[i]
+			break
+		}
+	}
+	if op == nil {
+		return 0, fmt.Errorf("operation %q not found", operationName)
+	}
+
+	var root ast.NamedType
+	if a.schema.RootOperationTypes != nil {
+		root = a.schema.RootOperationTypes["query"]
+	}
+	if root == nil {
+		root = a.schema.Types["Query"]
+	}
+	w := &complexityWalker{analyzer: a, doc: doc, variables: variables, visiting: make(map[string]bool)}
+	return w.walk(op.selections, root)
+}
+
+type complexityWalker struct {
+	analyzer  *complexityAnalyzer
+	doc       *document
+	variables map[string]interface{}
+	visiting  map[string]bool // 防止片段循环引用
+}
+
+func (w *complexityWalker) walk(selections []selection, parent ast.NamedType) (int, error) {
+	total := 0
+	for _, sel := range selections {
+		switch {
+		case sel.fragment != "":
+			frag, ok := w.doc.fragments[sel.fragment]
+			if !ok || w.visiting[sel.fragment] {
+				return 0, fmt.Errorf("invalid fragment %q", sel.fragment)
+			}
+			w.visiting[sel.fragment] = true
+			cost, err := w.walk(frag.selections, w.analyzer.schema.Types[frag.typeCondition])
+			delete(w.visiting, sel.fragment)
+			if err != nil {
+				return 0, err
+			}
+			total += cost
+		case sel.field == "":
+			typ := parent
+			if sel.typeCondition != "" {
+				typ = w.analyzer.schema.Types[sel.typeCondition]
+			}
+			cost, err := w.walk(sel.selections, typ)
+			if err != nil {
+				return 0, err
+			}
+			total += cost
+		case sel.field == "__typename":
+		default:
+			cost, err := w.field(sel, parent)
+			if err != nil {
+				return 0, err
+			}
+			total += cost
+		}
+	}
+	return min(total, complexityCeiling), nil
+}
+
+func (w *complexityWalker) field(sel selection, parent ast.NamedType) (int, error) {
+	var def *ast.FieldDefinition
+	switch t := parent.(type) {
+	case *ast.ObjectTypeDefinition:
+		def = t.Fields.Get(sel.field)
+	case *ast.InterfaceTypeDefinition:
+		def = t.Fields.Get(sel.field)
+	}
+
+	// 内省字段等 Schema 之外的字段不放大
+	multiplier := 1
+	var child ast.NamedType
+	if def != nil {
+		child = namedType(def.Type)
+		if limit, ok := w.limit(sel, def); ok {
+			multiplier = limit
+		} else if isList(def.Type) {
+			multiplier = w.analyzer.defaultListSize
+		}
+	}
+
+	cost, err := w.walk(sel.selections, child)
+	if err != nil {
+		return 0, err
+	}
+	if cost > 0 && multiplier > (complexityCeiling-1)/cost {
+		return complexityCeiling, nil
+	}
+	return 1 + multiplier*cost, nil
+}
+
+// limit 取字段的 limit 参数：查询中的字面量、变量或 Schema 中的默认值
+func (w *complexityWalker) limit(sel selection, def *ast.FieldDefinition) (int, bool) {
+	arg := def.Arguments.Get("limit")
+	if arg == nil {
+		return 0, false
+	}
+	if v, ok := sel.args["limit"]; ok {
+		switch {
+		case v.literal != nil:
+			return clampLimit(*v.literal), true
+		case v.variable != "":
+			if n, ok := toInt64(w.variables[v.variable]); ok {
+				return clampLimit(n), true
+			}
+		}
+	}
+	if arg.Default != nil {
+		if n, ok := toInt64(arg.Default.Deserialize(nil)); ok {
+			return clampLimit(n), true
+		}
+	}
+	return 0, false
+}
+
+func clampLimit(n int64) int {
+	if n < 1 {
+		return 1
+	}
+	if n > maxEstimatedLimit {
+		return maxEstimatedLimit
+	}
+	return int(n)
+}
+
+const (
+	// maxEstimatedLimit 估算时 limit 的上限
+	maxEstimatedLimit = 1 << 16
+	// complexityCeiling 复杂度估算的上限，嵌套列表相乘时避免溢出
+	complexityCeiling = math.MaxInt32
+)
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func namedType(t ast.Type) ast.NamedType {
+	for {
+		switch w := t.(type) {
+		case *ast.NonNull:
+			t = w.OfType
+		case *ast.List:
+			t = w.OfType
+		case ast.NamedType:
+			return w
+		default:
+			return nil
+		}
+	}
+}
+
+func isList(t ast.Type) bool {
+	if nn, ok := t.(*ast.NonNull); ok {
+		t = nn.OfType
+	}
+	_, ok := t.(*ast.List)
+	return ok
+}
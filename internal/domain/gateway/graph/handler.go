@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	couponRepository "user_crud_jwt/internal/domain/coupon/repository"
+	momentRepository "user_crud_jwt/internal/domain/moment/repository"
+	momentService "user_crud_jwt/internal/domain/moment/service"
+	userService "user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// Config 网关配置
+type Config struct {
+	MaxDepth        int           // 查询最大嵌套深度
+	MaxComplexity   int           // 查询最大复杂度
+	DefaultListSize int           // 没有 limit 参数的列表字段估算复杂度时的条数
+	MaxLimit        int           // 列表字段 limit 参数的上限
+	MaxQueryLength  int           // 查询文本最大长度
+	BatchWait       time.Duration // 批量加载收集键的等待时间
+	MaxBatch        int           // 单次批量加载的最大键数
+	CacheTTL        time.Duration // 用户、优惠券在缓存中的有效期
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		MaxDepth:        6,
+		MaxComplexity:   1000,
+		DefaultListSize: 10,
+		MaxLimit:        50,
+		MaxQueryLength:  8192,
+		BatchWait:       2 * time.Millisecond,
+		MaxBatch:        100,
+		CacheTTL:        30 * time.Second,
+	}
+}
+
+// Dependencies 解析器依赖的各模块服务，Cache 与 Permissions 可为 nil
+type Dependencies struct {
+	Users       userService.UserService
+	Coupons     couponRepository.CouponRepository
+	Moments     momentRepository.MomentRepository
+	Feed        momentService.MomentService
+	Cache       cache.CacheService         // 用户、优惠券的读缓存
+	Permissions security.PermissionChecker // @auth 字段的 RBAC 校验，为 nil 时只有拥有者与管理员可见
+}
+
+// Request GraphQL 请求体
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler GraphQL HTTP 处理器
+type Handler struct {
+	schema     *graphql.Schema
+	complexity *complexityAnalyzer
+	deps       *Dependencies
+	config     Config
+}
+
+// NewHandler 创建 GraphQL 处理器
+func NewHandler(deps Dependencies, config Config) *Handler {
+	resolver := &Resolver{deps: &deps, config: config}
+	schema := graphql.MustParseSchema(schemaSDL, resolver,
+		graphql.MaxDepth(config.MaxDepth),
+		graphql.MaxQueryLength(config.MaxQueryLength),
+	)
+	resolver.authz = newAuthorizer(schema.AST(), deps.Permissions)
+
+	return &Handler{
+		schema:     schema,
+		complexity: &complexityAnalyzer{schema: schema.AST(), defaultListSize: config.DefaultListSize},
+		deps:       &deps,
+		config:     config,
+	}
+}
+
+// Serve 执行查询。请求体无效时返回 400，其余情况按 GraphQL 约定返回 200，错误放在 errors 中
+func (h *Handler) Serve(c *gin.Context) {
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse(apperrors.CodeInvalidParam, err.Error()))
+		return
+	}
+
+	if errs := h.checkComplexity(req); errs != nil {
+		c.JSON(http.StatusOK, &graphql.Response{Errors: errs})
+		return
+	}
+
+	ctx := WithViewer(c.Request.Context(), viewerFromGin(c))
+	ctx = withLoaders(ctx, newLoaders(h.deps, h.config))
+	c.JSON(http.StatusOK, h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// checkComplexity 超过复杂度上限时返回错误。
+// 超长的查询由 graphql-go 拒绝，无法解析的查询交给 graphql-go 校验以返回准确的语法错误
+func (h *Handler) checkComplexity(req Request) []*gqlerrors.QueryError {
+	if h.config.MaxComplexity <= 0 || (h.config.MaxQueryLength > 0 && len(req.Query) > h.config.MaxQueryLength) {
+		return nil
+	}
+	complexity, err := h.complexity.Complexity(req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		if errs := h.schema.ValidateWithVariables(req.Query, req.Variables); len(errs) > 0 {
+			return errs
+		}
+		// graphql-go 认为合法但无法估算的查询同样拒绝
+		return errorResponse(apperrors.CodeInvalidParam, fmt.Sprintf("failed to analyze query complexity: %v", err)).Errors
+	}
+	if complexity > h.config.MaxComplexity {
+		return errorResponse(apperrors.CodeInvalidParam,
+			fmt.Sprintf("query complexity %d exceeds the limit of %d", complexity, h.config.MaxComplexity)).Errors
+	}
+	return nil
+}
+
+func errorResponse(code apperrors.Code, message string) *graphql.Response {
+	return &graphql.Response{Errors: []*gqlerrors.QueryError{{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": code},
+	}}}
+}
+
+// viewerFromGin 读取 AuthMiddleware 写入的用户信息
+func viewerFromGin(c *gin.Context) Viewer {
+	var viewer Viewer
+	if id, ok := c.Get("userID"); ok {
+		viewer.UserID, _ = id.(string)
+	}
+	role, _ := c.Get("role")
+	switch v := role.(type) {
+	case float64:
+		viewer.Role = int(v)
+	case int:
+		viewer.Role = v
+	}
+	return viewer
+}
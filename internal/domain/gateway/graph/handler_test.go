@@ -0,0 +1,243 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	momentModel "user_crud_jwt/internal/domain/moment/model"
+	momentRepository "user_crud_jwt/internal/domain/moment/repository"
+	userModel "user_crud_jwt/internal/domain/user/model"
+	userService "user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/pkg/apperrors"
+	baseModel "user_crud_jwt/pkg/model"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubUsers struct {
+	userService.UserService
+	users map[string]*userModel.User
+}
+
+func (s *stubUsers) GetUser(ctx context.Context, id string) (*userModel.User, error) {
+	return s.users[id], nil
+}
+
+func (s *stubUsers) GetUsers(ctx context.Context, page, limit int) ([]userModel.User, int64, error) {
+	var list []userModel.User
+	for _, id := range []string{"u1", "u2"} {
+		list = append(list, *s.users[id])
+	}
+	return list, int64(len(list)), nil
+}
+
+type stubMoments struct {
+	momentRepository.MomentRepository
+	posts []*momentModel.Post
+}
+
+func (s *stubMoments) GetPostsByUserID(ctx context.Context, userID string, limit, offset int) ([]*momentModel.Post, error) {
+	var list []*momentModel.Post
+	for _, post := range s.posts {
+		if post.UserID == userID && len(list) < limit {
+			list = append(list, post)
+		}
+	}
+	return list, nil
+}
+
+// stubChecker 按用户授予的权限
+type stubChecker struct {
+	security.PermissionChecker
+	grants map[string][]security.Permission
+}
+
+func (s *stubChecker) HasPermission(ctx context.Context, userID string, permission security.Permission) (bool, error) {
+	for _, p := range s.grants[userID] {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type gqlResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// code 第 i 个错误的错误码
+func (r *gqlResponse) code(i int) apperrors.Code {
+	code, _ := r.Errors[i].Extensions["code"].(float64)
+	return apperrors.Code(code)
+}
+
+func newTestHandler(config Config) *Handler {
+	users := &stubUsers{users: map[string]*userModel.User{
+		"u1":    {ID: "u1", Nickname: "alice", Mobile: "13800000001", Email: "alice@example.com"},
+		"u2":    {ID: "u2", Nickname: "bob", Mobile: "13800000002", Email: "bob@example.com"},
+		"admin": {ID: "admin", Nickname: "root", Role: userModel.RoleAdmin},
+	}}
+	moments := &stubMoments{posts: []*momentModel.Post{
+		{BaseModel: baseModel.BaseModel{ID: "p1"}, UserID: "u1", Content: "hello", Status: "approved"},
+		{BaseModel: baseModel.BaseModel{ID: "p2"}, UserID: "u1", Content: "draft", Status: "pending"},
+	}}
+	return NewHandler(Dependencies{
+		Users:   users,
+		Moments: moments,
+		Permissions: &stubChecker{grants: map[string][]security.Permission{
+			"auditor": {"user:read", "admin:read"},
+			"support": {"user:read"},
+		}},
+	}, config)
+}
+
+// execute 以 viewer 的身份执行查询，viewer 为零值时为匿名请求
+func execute(t *testing.T, h *Handler, viewer Viewer, query string) *gqlResponse {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	body, err := json.Marshal(Request{Query: query})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if viewer.UserID != "" {
+		c.Set("userID", viewer.UserID)
+		c.Set("role", float64(viewer.Role))
+	}
+	h.Serve(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp gqlResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return &resp
+}
+
+// TestServe_Depth 超过 MaxDepth 的查询在执行前被拒绝
+func TestServe_Depth(t *testing.T) {
+	h := newTestHandler(DefaultConfig())
+	viewer := Viewer{UserID: "u1"}
+
+	resp := execute(t, h, viewer, `{ me { moments(limit: 1) { author { moments(limit: 1) { author { id } } } } } }`)
+	require.Empty(t, resp.Errors)
+	assert.NotNil(t, resp.Data["me"])
+
+	resp = execute(t, h, viewer, `{ me { moments(limit: 1) { author { moments(limit: 1) { author { moments(limit: 1) { id } } } } } } }`)
+	require.NotEmpty(t, resp.Errors)
+	assert.Contains(t, resp.Errors[0].Message, "exceeds max depth 6")
+	assert.Nil(t, resp.Data)
+}
+
+// TestServe_Complexity 列表字段按 limit 放大复杂度，超过上限的查询被拒绝；没有 limit 时按 DefaultListSize 估算
+func TestServe_Complexity(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxComplexity = 100
+	h := newTestHandler(config)
+	viewer := Viewer{UserID: "u1"}
+
+	// 1 + (1 + 5*(1 + 1 + 1)) = 17
+	resp := execute(t, h, viewer, `{ me { moments(limit: 5) { id author { id } } } }`)
+	require.Empty(t, resp.Errors)
+
+	// 1 + (1 + 1 + 50*(1 + 1 + 1)) = 153
+	resp = execute(t, h, viewer, `{ me { id moments(limit: 50) { id author { id } } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "query complexity 153 exceeds the limit of 100", resp.Errors[0].Message)
+	assert.Equal(t, apperrors.CodeInvalidParam, resp.code(0))
+	assert.Nil(t, resp.Data)
+
+	// 1 + (1 + 10*(1 + (1 + 10*(1 + 1 + 1)))) = 322
+	resp = execute(t, h, viewer, `{ me { moments { author { moments { id author { id } } } } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "query complexity 322")
+
+	// 片段同样计入
+	resp = execute(t, h, viewer, `{ me { ...deep } } fragment deep on User { moments(limit: 50) { id author { id } } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "exceeds the limit of 100")
+}
+
+// TestServe_FieldAuthorization @auth 字段对拥有者与管理员可见，其他用户需具备权限，否则字段为 null 并返回 CodeNoPermission
+func TestServe_FieldAuthorization(t *testing.T) {
+	h := newTestHandler(DefaultConfig())
+	const query = `{ user(id: "u1") { nickname mobile email } }`
+
+	for _, viewer := range []Viewer{{UserID: "u1"}, {UserID: "admin", Role: userModel.RoleAdmin}, {UserID: "auditor"}} {
+		resp := execute(t, h, viewer, query)
+		require.Empty(t, resp.Errors, viewer.UserID)
+		assert.Equal(t, map[string]interface{}{"nickname": "alice", "mobile": "13800000001", "email": "alice@example.com"},
+			resp.Data["user"], viewer.UserID)
+	}
+
+	resp := execute(t, h, Viewer{UserID: "u2"}, query)
+	require.Len(t, resp.Errors, 2)
+	for i := range resp.Errors {
+		assert.Equal(t, apperrors.CodeNoPermission, resp.code(i))
+		assert.Contains(t, resp.Errors[i].Message, "permission admin:read required")
+	}
+	assert.Equal(t, map[string]interface{}{"nickname": "alice", "mobile": nil, "email": nil}, resp.Data["user"],
+		"unauthorized fields are nulled while the rest of the object resolves")
+
+	// 只有 user:read 的用户读取 admin:read 字段
+	resp = execute(t, h, Viewer{UserID: "support"}, query)
+	require.Len(t, resp.Errors, 2)
+	assert.Equal(t, apperrors.CodeNoPermission, resp.code(0))
+}
+
+// TestServe_QueryAuthorization Query 上的 @auth 字段没有拥有者：仅管理员与具备权限的用户可以查询，匿名请求需要登录
+func TestServe_QueryAuthorization(t *testing.T) {
+	h := newTestHandler(DefaultConfig())
+	const query = `{ users(limit: 2) { id } }`
+
+	resp := execute(t, h, Viewer{UserID: "u1"}, query)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, apperrors.CodeNoPermission, resp.code(0))
+	assert.Equal(t, []interface{}{"users"}, resp.Errors[0].Path)
+	assert.Nil(t, resp.Data, "a non-null root field that fails nulls the whole response")
+
+	for _, viewer := range []Viewer{{UserID: "admin", Role: userModel.RoleAdmin}, {UserID: "support"}} {
+		resp = execute(t, h, viewer, query)
+		require.Empty(t, resp.Errors, viewer.UserID)
+		assert.Len(t, resp.Data["users"], 2)
+	}
+
+	// support 能列出用户，但看不到他人的联系方式
+	resp = execute(t, h, Viewer{UserID: "support"}, `{ users(limit: 2) { id mobile } }`)
+	require.Len(t, resp.Errors, 2)
+	assert.Equal(t, apperrors.CodeNoPermission, resp.code(0))
+
+	resp = execute(t, h, Viewer{}, `{ me { id } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, apperrors.CodeTokenInvalid, resp.code(0))
+	resp = execute(t, h, Viewer{}, `{ user(id: "u1") { nickname mobile } }`)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, apperrors.CodeTokenInvalid, resp.code(0))
+	assert.Equal(t, map[string]interface{}{"nickname": "alice", "mobile": nil}, resp.Data["user"])
+}
+
+// TestServe_MomentVisibility 未审核的动态只对作者与管理员可见
+func TestServe_MomentVisibility(t *testing.T) {
+	h := newTestHandler(DefaultConfig())
+	const query = `{ user(id: "u1") { moments { id } } }`
+
+	count := func(viewer Viewer) int {
+		resp := execute(t, h, viewer, query)
+		require.Empty(t, resp.Errors)
+		return len(resp.Data["user"].(map[string]interface{})["moments"].([]interface{}))
+	}
+	assert.Equal(t, 2, count(Viewer{UserID: "u1"}))
+	assert.Equal(t, 2, count(Viewer{UserID: "admin", Role: userModel.RoleAdmin}))
+	assert.Equal(t, 1, count(Viewer{UserID: "u2"}))
+}
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	couponModel "user_crud_jwt/internal/domain/coupon/model"
+	momentModel "user_crud_jwt/internal/domain/moment/model"
+	userModel "user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/dataloader"
+)
+
+// 网关写入缓存的键，与各模块自身的缓存键隔离；优惠券的键由优惠券模块在领取后失效
+const (
+	userCacheKey   = "graphql:user:%s"
+	couponCacheKey = couponModel.GatewayCacheKey
+)
+
+// listKey 按拥有者与条数加载列表
+type listKey struct {
+	OwnerID string
+	Limit   int
+}
+
+// loaders 单次请求内的批量加载器，同一请求中重复引用的对象只加载一次
+type loaders struct {
+	users       *dataloader.Loader[string, *userModel.User]
+	coupons     *dataloader.Loader[string, *couponModel.Coupon]
+	userCoupons *dataloader.Loader[listKey, []*couponModel.UserCoupon]
+	moments     *dataloader.Loader[listKey, []*momentModel.Post]
+}
+
+func newLoaders(deps *Dependencies, config Config) *loaders {
+	batch := dataloader.Config{Wait: config.BatchWait, MaxBatch: config.MaxBatch}
+	return &loaders{
+		users: dataloader.New(dataloader.Cached(deps.Cache, func(id string) string {
+			return fmt.Sprintf(userCacheKey, id)
+		}, config.CacheTTL, deps.loadUsers), batch),
+		coupons: dataloader.New(dataloader.Cached(deps.Cache, func(id string) string {
+			return fmt.Sprintf(couponCacheKey, id)
+		}, config.CacheTTL, deps.loadCoupons), batch),
+		// 领取记录与动态列表变化频繁，只做批量不做缓存
+		userCoupons: dataloader.New(deps.loadUserCoupons, batch),
+		moments:     dataloader.New(deps.loadMoments, batch),
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// loadUsers 用户服务没有批量接口，逐个读取；未命中缓存的部分才会走到这里
+func (d *Dependencies) loadUsers(ctx context.Context, ids []string) (map[string]*userModel.User, error) {
+	users := make(map[string]*userModel.User, len(ids))
+	for _, id := range ids {
+		user, err := d.Users.GetUser(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user %s: %w", id, err)
+		}
+		if user != nil {
+			users[id] = user
+		}
+	}
+	return users, nil
+}
+
+func (d *Dependencies) loadCoupons(ctx context.Context, ids []string) (map[string]*couponModel.Coupon, error) {
+	list, err := d.Coupons.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	coupons := make(map[string]*couponModel.Coupon, len(list))
+	for _, coupon := range list {
+		coupons[coupon.ID] = coupon
+	}
+	return coupons, nil
+}
+
+// loadUserCoupons 按 limit 分组，每组一次查询
+func (d *Dependencies) loadUserCoupons(ctx context.Context, keys []listKey) (map[listKey][]*couponModel.UserCoupon, error) {
+	byLimit := make(map[int][]string)
+	for _, key := range keys {
+		byLimit[key.Limit] = append(byLimit[key.Limit], key.OwnerID)
+	}
+
+	result := make(map[listKey][]*couponModel.UserCoupon, len(keys))
+	for _, key := range keys {
+		// 没有领取记录的用户返回空列表
+		result[key] = []*couponModel.UserCoupon{}
+	}
+	for limit, userIDs := range byLimit {
+		list, err := d.Coupons.ListUserCouponsByUserIDs(ctx, userIDs, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, uc := range list {
+			key := listKey{OwnerID: uc.UserID, Limit: limit}
+			result[key] = append(result[key], uc)
+		}
+	}
+	return result, nil
+}
+
+// loadMoments 动态仓库没有按多个用户查询的接口，逐个读取
+func (d *Dependencies) loadMoments(ctx context.Context, keys []listKey) (map[listKey][]*momentModel.Post, error) {
+	result := make(map[listKey][]*momentModel.Post, len(keys))
+	for _, key := range keys {
+		posts, err := d.Moments.GetPostsByUserID(ctx, key.OwnerID, key.Limit, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get moments of user %s: %w", key.OwnerID, err)
+		}
+		result[key] = posts
+	}
+	return result, nil
+}
+
+// WarmCoupons 预热任务：把未结束的优惠券按 coupons 加载器的键写入缓存，写入 ctx 中租户的缓存
+func (h *Handler) WarmCoupons(ctx context.Context) (*cache.WarmupResult, error) {
+	if h.deps.Cache == nil {
+		return nil, errors.New("gateway cache is not configured")
+	}
+	ids, err := h.deps.Coupons.ListActiveCouponIDs(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active coupons: %w", err)
+	}
+
+	result := &cache.WarmupResult{TotalKeys: len(ids), StartTime: time.Now()}
+	for start := 0; start < len(ids); start += h.config.MaxBatch {
+		batch := ids[start:min(start+h.config.MaxBatch, len(ids))]
+		coupons, err := h.deps.Coupons.GetByIDs(ctx, batch)
+		if err != nil {
+			return result, fmt.Errorf("failed to get coupons: %w", err)
+		}
+		entries := make([]cache.CacheEntry, 0, len(coupons))
+		for _, coupon := range coupons {
+			entries = append(entries, cache.CacheEntry{Key: fmt.Sprintf(couponCacheKey, coupon.ID), Value: coupon, Expiration: h.config.CacheTTL})
+		}
+		set, err := h.deps.Cache.SetMany(ctx, entries)
+		if err != nil {
+			return result, fmt.Errorf("failed to cache coupons: %w", err)
+		}
+		result.SuccessKeys += len(set.Succeeded)
+		result.FailedKeys += len(set.Errors)
+		for key, err := range set.Errors {
+			result.Errors = append(result.Errors, key+": "+err.Error())
+		}
+	}
+	return result, nil
+}
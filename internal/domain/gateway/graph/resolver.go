@@ -0,0 +1,278 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	couponModel "user_crud_jwt/internal/domain/coupon/model"
+	momentModel "user_crud_jwt/internal/domain/moment/model"
+	userModel "user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/dataloader"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Resolver Query 的根解析器
+type Resolver struct {
+	deps   *Dependencies
+	authz  *authorizer
+	config Config
+}
+
+type idArgs struct {
+	ID graphql.ID
+}
+
+type pageArgs struct {
+	Page  int32
+	Limit int32
+}
+
+type limitArgs struct {
+	Limit int32
+}
+
+// Me 当前登录用户
+func (r *Resolver) Me(ctx context.Context) (*userResolver, error) {
+	viewer, ok := ViewerFromContext(ctx)
+	if !ok {
+		return nil, newFieldError(apperrors.CodeTokenInvalid, "authentication required")
+	}
+	user, err := r.loadUser(ctx, viewer.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, newFieldError(apperrors.CodeUserNotFound, "user not found")
+	}
+	return user, nil
+}
+
+// User 按 ID 获取用户，不存在时返回 null
+func (r *Resolver) User(ctx context.Context, args idArgs) (*userResolver, error) {
+	return r.loadUser(ctx, string(args.ID))
+}
+
+// Users 分页获取用户列表
+func (r *Resolver) Users(ctx context.Context, args pageArgs) ([]*userResolver, error) {
+	if err := r.authz.authorize(ctx, "Query.users", ""); err != nil {
+		return nil, err
+	}
+	users, _, err := r.deps.Users.GetUsers(ctx, r.page(args.Page), r.limit(args.Limit))
+	if err != nil {
+		return nil, internalError("failed to list users", err)
+	}
+
+	l := loadersFrom(ctx)
+	result := make([]*userResolver, 0, len(users))
+	for i := range users {
+		user := &users[i]
+		l.users.Prime(user.ID, user)
+		result = append(result, &userResolver{user: user, root: r})
+	}
+	return result, nil
+}
+
+// Coupon 按 ID 获取优惠券，不存在时返回 null
+func (r *Resolver) Coupon(ctx context.Context, args idArgs) (*couponResolver, error) {
+	return r.loadCoupon(ctx, string(args.ID))
+}
+
+// Feed 动态流
+func (r *Resolver) Feed(ctx context.Context, args pageArgs) ([]*momentResolver, error) {
+	posts, _, err := r.deps.Feed.GetFeed(r.page(args.Page), r.limit(args.Limit))
+	if err != nil {
+		return nil, internalError("failed to get feed", err)
+	}
+	result := make([]*momentResolver, 0, len(posts))
+	for i := range posts {
+		if visible(ctx, &posts[i]) {
+			result = append(result, &momentResolver{post: &posts[i], root: r})
+		}
+	}
+	return result, nil
+}
+
+func (r *Resolver) loadUser(ctx context.Context, id string) (*userResolver, error) {
+	user, err := loadersFrom(ctx).users.Load(ctx, id)
+	if errors.Is(err, dataloader.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, internalError("failed to load user", err)
+	}
+	return &userResolver{user: user, root: r}, nil
+}
+
+func (r *Resolver) loadCoupon(ctx context.Context, id string) (*couponResolver, error) {
+	coupon, err := loadersFrom(ctx).coupons.Load(ctx, id)
+	if errors.Is(err, dataloader.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, internalError("failed to load coupon", err)
+	}
+	return &couponResolver{coupon: coupon}, nil
+}
+
+// page 页码从 1 开始
+func (r *Resolver) page(page int32) int {
+	if page < 1 {
+		return 1
+	}
+	return int(page)
+}
+
+// limit 将列表条数限制在 [1, MaxLimit]
+func (r *Resolver) limit(limit int32) int {
+	if limit < 1 {
+		return 1
+	}
+	if r.config.MaxLimit > 0 && int(limit) > r.config.MaxLimit {
+		return r.config.MaxLimit
+	}
+	return int(limit)
+}
+
+// internalError 记录原始错误，只向调用方返回概要
+func internalError(message string, err error) error {
+	log.Printf("graphql: %s: %v", message, err)
+	return newFieldError(apperrors.CodeInternal, message)
+}
+
+// visible 动态未审核通过时只有作者与管理员可见，与 REST 接口一致
+func visible(ctx context.Context, post *momentModel.Post) bool {
+	if post.Status == "approved" {
+		return true
+	}
+	viewer, _ := ViewerFromContext(ctx)
+	return viewer.UserID == post.UserID || viewer.IsAdmin()
+}
+
+type userResolver struct {
+	user *userModel.User
+	root *Resolver
+}
+
+func (u *userResolver) ID() graphql.ID    { return graphql.ID(u.user.ID) }
+func (u *userResolver) Nickname() string  { return u.user.Nickname }
+func (u *userResolver) AvatarURL() string { return u.user.AvatarURL }
+func (u *userResolver) Role() int32       { return int32(u.user.Role) }
+func (u *userResolver) IsMember() bool    { return u.user.IsMember }
+func (u *userResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: u.user.CreatedAt}
+}
+
+func (u *userResolver) MemberExpireAt() *graphql.Time {
+	if u.user.MemberExpireAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *u.user.MemberExpireAt}
+}
+
+func (u *userResolver) Mobile(ctx context.Context) (*string, error) {
+	if err := u.root.authz.authorize(ctx, "User.mobile", u.user.ID); err != nil {
+		return nil, err
+	}
+	return &u.user.Mobile, nil
+}
+
+func (u *userResolver) Email(ctx context.Context) (*string, error) {
+	if err := u.root.authz.authorize(ctx, "User.email", u.user.ID); err != nil {
+		return nil, err
+	}
+	return &u.user.Email, nil
+}
+
+func (u *userResolver) Coupons(ctx context.Context, args limitArgs) (*[]*userCouponResolver, error) {
+	if err := u.root.authz.authorize(ctx, "User.coupons", u.user.ID); err != nil {
+		return nil, err
+	}
+	key := listKey{OwnerID: u.user.ID, Limit: u.root.limit(args.Limit)}
+	list, err := loadersFrom(ctx).userCoupons.Load(ctx, key)
+	if err != nil {
+		return nil, internalError("failed to load user coupons", err)
+	}
+	result := make([]*userCouponResolver, 0, len(list))
+	for _, uc := range list {
+		result = append(result, &userCouponResolver{userCoupon: uc, root: u.root})
+	}
+	return &result, nil
+}
+
+// Moments 非本人只返回已审核的动态，条数可能少于 limit
+func (u *userResolver) Moments(ctx context.Context, args limitArgs) ([]*momentResolver, error) {
+	key := listKey{OwnerID: u.user.ID, Limit: u.root.limit(args.Limit)}
+	posts, err := loadersFrom(ctx).moments.Load(ctx, key)
+	if err != nil {
+		return nil, internalError("failed to load moments", err)
+	}
+	result := make([]*momentResolver, 0, len(posts))
+	for _, post := range posts {
+		if visible(ctx, post) {
+			result = append(result, &momentResolver{post: post, root: u.root})
+		}
+	}
+	return result, nil
+}
+
+type userCouponResolver struct {
+	userCoupon *couponModel.UserCoupon
+	root       *Resolver
+}
+
+func (uc *userCouponResolver) ID() graphql.ID { return graphql.ID(uc.userCoupon.ID) }
+func (uc *userCouponResolver) Status() int32  { return int32(uc.userCoupon.Status) }
+func (uc *userCouponResolver) ClaimedAt() graphql.Time {
+	return graphql.Time{Time: uc.userCoupon.CreatedAt}
+}
+
+func (uc *userCouponResolver) Coupon(ctx context.Context) (*couponResolver, error) {
+	return uc.root.loadCoupon(ctx, uc.userCoupon.CouponID)
+}
+
+type couponResolver struct {
+	coupon *couponModel.Coupon
+}
+
+func (c *couponResolver) ID() graphql.ID  { return graphql.ID(c.coupon.ID) }
+func (c *couponResolver) Name() string    { return c.coupon.Name }
+func (c *couponResolver) Total() int32    { return int32(c.coupon.Total) }
+func (c *couponResolver) Stock() int32    { return int32(c.coupon.Stock) }
+func (c *couponResolver) Amount() float64 { return c.coupon.Amount }
+func (c *couponResolver) StartTime() graphql.Time {
+	return graphql.Time{Time: c.coupon.StartTime}
+}
+func (c *couponResolver) EndTime() graphql.Time {
+	return graphql.Time{Time: c.coupon.EndTime}
+}
+
+type momentResolver struct {
+	post *momentModel.Post
+	root *Resolver
+}
+
+func (m *momentResolver) ID() graphql.ID  { return graphql.ID(m.post.ID) }
+func (m *momentResolver) Content() string { return m.post.Content }
+func (m *momentResolver) Type() string    { return m.post.Type }
+func (m *momentResolver) Status() string  { return m.post.Status }
+func (m *momentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: m.post.CreatedAt}
+}
+
+// MediaURLs 媒体地址以 JSON 数组存储，无法解析时返回空列表
+func (m *momentResolver) MediaURLs() []string {
+	urls := []string{}
+	if len(m.post.MediaURLs) > 0 {
+		if err := json.Unmarshal(m.post.MediaURLs, &urls); err != nil {
+			return []string{}
+		}
+	}
+	return urls
+}
+
+func (m *momentResolver) Author(ctx context.Context) (*userResolver, error) {
+	return m.root.loadUser(ctx, m.post.UserID)
+}
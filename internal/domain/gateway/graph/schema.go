@@ -0,0 +1,68 @@
+package graph
+
+// schemaSDL 网关 Schema，面向看板等读多写少的聚合查询，只提供 Query。
+// @auth 声明字段级权限：对象的拥有者与管理员始终可见，其他调用方需具备指定的 RBAC 权限；
+// Query 上的字段没有拥有者，仅管理员与具备权限的调用方可见
+const schemaSDL = `
+schema {
+	query: Query
+}
+
+directive @auth(permission: String!) on FIELD_DEFINITION
+
+scalar Time
+
+type Query {
+	# 当前登录用户
+	me: User!
+	user(id: ID!): User
+	users(page: Int = 1, limit: Int = 10): [User!]! @auth(permission: "user:read")
+	coupon(id: ID!): Coupon
+	# 与 GET /moments/feed 一致的动态流
+	feed(page: Int = 1, limit: Int = 10): [Moment!]!
+}
+
+type User {
+	id: ID!
+	nickname: String!
+	avatarUrl: String!
+	role: Int!
+	isMember: Boolean!
+	memberExpireAt: Time
+	createdAt: Time!
+	mobile: String @auth(permission: "admin:read")
+	email: String @auth(permission: "admin:read")
+	# 最近领取的优惠券
+	coupons(limit: Int = 10): [UserCoupon!] @auth(permission: "admin:read")
+	# 最近发布的动态，非本人只能看到已审核的
+	moments(limit: Int = 10): [Moment!]!
+}
+
+type UserCoupon {
+	id: ID!
+	status: Int!
+	claimedAt: Time!
+	coupon: Coupon
+}
+
+type Coupon {
+	id: ID!
+	name: String!
+	total: Int!
+	# 经缓存读取，可能有 CacheTTL 内的延迟
+	stock: Int!
+	amount: Float!
+	startTime: Time!
+	endTime: Time!
+}
+
+type Moment {
+	id: ID!
+	content: String!
+	type: String!
+	status: String!
+	mediaUrls: [String!]!
+	createdAt: Time!
+	author: User
+}
+`
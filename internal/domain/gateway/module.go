@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"log"
+	"net/http"
+	couponRepository "user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/internal/domain/gateway/graph"
+	momentRepository "user_crud_jwt/internal/domain/moment/repository"
+	momentService "user_crud_jwt/internal/domain/moment/service"
+	userService "user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/chaos"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/openapi"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// GatewayModule GraphQL 聚合查询网关，复用用户、优惠券、动态模块的服务实例
+type GatewayModule struct{}
+
+func init() {
+	registry.Register(&GatewayModule{})
+}
+
+func (m *GatewayModule) Name() string {
+	return "gateway"
+}
+
+func (m *GatewayModule) Priority() int {
+	// 依赖的模块需先初始化并登记服务
+	return 50
+}
+
+func (m *GatewayModule) Init(ctx *registry.ModuleContext) error {
+	// 1. 依赖注入 - 从其他模块获取共享的服务实例
+	users, ok1 := lookup[userService.UserService](ctx, registry.UserService)
+	coupons, ok2 := lookup[couponRepository.CouponRepository](ctx, registry.CouponRepository)
+	moments, ok3 := lookup[momentRepository.MomentRepository](ctx, registry.MomentRepository)
+	feed, ok4 := lookup[momentService.MomentService](ctx, registry.MomentService)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		log.Printf("GraphQL gateway disabled: user, coupon and moment modules are required")
+		return nil
+	}
+
+	deps := graph.Dependencies{Users: users, Coupons: coupons, Moments: moments, Feed: feed}
+	if ctx.Redis != nil {
+		faults, _ := lookup[*chaos.Injector](ctx, registry.FaultInjector)
+		ttls, _ := lookup[*cache.TTLResolver](ctx, registry.CacheTTL)
+		// TTL 配置按不带租户前缀的键匹配，包装在 NewTenantCache 之外；命中率按 GraphQL 路由计入 graphql
+		deps.Cache = cache.AttributeCallers(cache.WithTTLProfiles(cache.NewTenantCache(chaos.WrapCache(cache.NewRedisCache(ctx.Redis), faults)), ttls), "graphql", metrics.GetGlobalCollector())
+	}
+	if checker, ok := lookup[security.PermissionChecker](ctx, registry.PermissionChecker); ok {
+		deps.Permissions = checker
+	}
+	graphHandler := graph.NewHandler(deps, graph.DefaultConfig())
+	if cacheAdmin, ok := lookup[*cache.Admin](ctx, registry.CacheAdmin); ok && deps.Cache != nil {
+		cacheAdmin.RegisterWarmup("graphql_coupons", "Cache coupons that have not ended for GraphQL reads", graphHandler.WarmCoupons)
+	}
+
+	// 2. 路由注册
+	setupRoutes(ctx.Router, graphHandler)
+
+	return nil
+}
+
+// lookup 获取其他模块登记的服务并断言类型
+func lookup[T any](ctx *registry.ModuleContext, name string) (T, bool) {
+	svc, ok := ctx.Lookup(name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	t, ok := svc.(T)
+	return t, ok
+}
+
+func setupRoutes(r *gin.Engine, h *graph.Handler) {
+	openapi.Describe(h.Serve, openapi.Route{
+		Summary:     "GraphQL 聚合查询",
+		Description: "一次请求获取用户、优惠券与动态。响应遵循 GraphQL 约定，字段级错误放在 errors 中",
+		Tags:        []string{"GraphQL"},
+		Auth:        true,
+		Body:        graph.Request{},
+		Response:    graphql.Response{},
+		Raw:         true,
+		Errors:      []int{http.StatusBadRequest},
+	})
+
+	r.POST("/graphql", middleware.AuthMiddleware(), h.Serve)
+}
@@ -96,24 +96,21 @@ func (h *MomentHandler) AuditPost(c *gin.Context) {
 // @Tags Moment
 // @Param page query int false "Page"
 // @Param limit query int false "Limit"
-// @Success 200 {object} utils.PageResult
+// @Success 200 {object} response.Response{data=[]model.Post}
 // @Router /moments/feed [get]
 func (h *MomentHandler) GetFeed(c *gin.Context) {
 	var p utils.Pagination
 	c.ShouldBindQuery(&p)
+	p.GetPageOffset()
 
-	posts, total, err := h.service.GetFeed(p.Page, p.Limit)
+	posts, _, err := h.service.GetFeed(p.Page, p.Limit)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
 		return
 	}
 
-	response.Success(c, utils.PageResult{
-		List:  posts,
-		Total: total,
-		Page:  p.Page,
-		Limit: p.Limit,
-	})
+	// 服务返回的总数只是本页条数，不作为总数返回
+	response.Page(c, posts, response.UnknownTotal, p.Page, p.Limit)
 }
 
 // AddComment 发表评论
@@ -141,19 +138,16 @@ func (h *MomentHandler) GetComments(c *gin.Context) {
 
 	var p utils.Pagination
 	c.ShouldBindQuery(&p)
+	p.GetPageOffset()
 
-	comments, total, err := h.service.GetPostComments(postID, p.Page, p.Limit)
+	comments, _, err := h.service.GetPostComments(postID, p.Page, p.Limit)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
 		return
 	}
 
-	response.Success(c, utils.PageResult{
-		List:  comments,
-		Total: total,
-		Page:  p.Page,
-		Limit: p.Limit,
-	})
+	// 服务返回的总数只是本页条数，不作为总数返回
+	response.Page(c, comments, response.UnknownTotal, p.Page, p.Limit)
 }
 
 // ToggleLike 点赞/取消点赞
@@ -184,25 +178,22 @@ func (h *MomentHandler) ToggleLike(c *gin.Context) {
 // @Param page query int false "Page"
 // @Param limit query int false "Limit"
 // @Param keyword query string false "Keyword"
-// @Success 200 {object} utils.PageResult
+// @Success 200 {object} response.Response{data=[]model.Topic}
 // @Router /moments/topics [get]
 func (h *MomentHandler) GetTopics(c *gin.Context) {
 	var p utils.Pagination
 	c.ShouldBindQuery(&p)
+	p.GetPageOffset()
 	keyword := c.Query("keyword")
 
-	topics, total, err := h.service.GetTopicList(keyword, p.Page, p.Limit)
+	topics, _, err := h.service.GetTopicList(keyword, p.Page, p.Limit)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
 		return
 	}
 
-	response.Success(c, utils.PageResult{
-		List:  topics,
-		Total: total,
-		Page:  p.Page,
-		Limit: p.Limit,
-	})
+	// 服务返回的总数只是本页条数，不作为总数返回
+	response.Page(c, topics, response.UnknownTotal, p.Page, p.Limit)
 }
 
 // DeleteTopic 删除话题 (管理员)
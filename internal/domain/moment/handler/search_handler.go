@@ -3,8 +3,9 @@ package handler
 import (
 	"net/http"
 	"strconv"
-
 	"user_crud_jwt/internal/domain/moment/service"
+	"user_crud_jwt/pkg/response"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,11 +25,7 @@ func NewSearchHandler(searchService *service.SearchService) *SearchHandler {
 func (h *SearchHandler) SearchMoments(c *gin.Context) {
 	var req service.SearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    40001,
-			"message": "请求参数错误",
-			"data":     nil,
-		})
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, "请求参数错误")
 		return
 	}
 
@@ -42,26 +39,19 @@ func (h *SearchHandler) SearchMoments(c *gin.Context) {
 
 	result, err := h.searchService.SearchMoments(req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    50001,
-			"message": "搜索失败",
-			"data":     nil,
-		})
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, "搜索失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "success",
-		"data":     result,
-	})
+	// 结果是从一页动态中筛选的，条数不是总数；筛选后不足一页时 has_more 可能为 false
+	response.Page(c, result.Posts, response.UnknownTotal, result.Page, result.Limit)
 }
 
 // SearchTopics 搜索话题
 func (h *SearchHandler) SearchTopics(c *gin.Context) {
 	keyword := c.Query("keyword")
 	limitStr := c.DefaultQuery("limit", "20")
-	
+
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 || limit > 50 {
 		limit = 20
@@ -69,25 +59,17 @@ func (h *SearchHandler) SearchTopics(c *gin.Context) {
 
 	topics, err := h.searchService.SearchTopics(keyword, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    50001,
-			"message": "搜索话题失败",
-			"data":     nil,
-		})
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, "搜索话题失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "success",
-		"data":     topics,
-	})
+	response.Success(c, topics)
 }
 
 // GetHotTopics 获取热门话题
 func (h *SearchHandler) GetHotTopics(c *gin.Context) {
 	limitStr := c.DefaultQuery("limit", "10")
-	
+
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 || limit > 20 {
 		limit = 10
@@ -95,19 +77,11 @@ func (h *SearchHandler) GetHotTopics(c *gin.Context) {
 
 	topics, err := h.searchService.GetHotTopics(limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    50001,
-			"message": "获取热门话题失败",
-			"data":     nil,
-		})
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, "获取热门话题失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "success",
-		"data":     topics,
-	})
+	response.Success(c, topics)
 }
 
 // GetUserMoments 获取用户动态
@@ -128,17 +102,10 @@ func (h *SearchHandler) GetUserMoments(c *gin.Context) {
 
 	result, err := h.searchService.GetUserMoments(userID, page, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    50001,
-			"message": "获取用户动态失败",
-			"data":     nil,
-		})
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, "获取用户动态失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "success",
-		"data":     result,
-	})
+	// 服务返回的总数只是本页条数，不作为总数返回
+	response.Page(c, result.Posts, response.UnknownTotal, result.Page, result.Limit)
 }
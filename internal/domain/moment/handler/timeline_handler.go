@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"user_crud_jwt/internal/domain/moment/service"
+	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimelineHandler 关注动态时间线接口
+type TimelineHandler struct {
+	timeline *service.TimelineService
+}
+
+// NewTimelineHandler 创建时间线接口
+func NewTimelineHandler(timeline *service.TimelineService) *TimelineHandler {
+	return &TimelineHandler{timeline: timeline}
+}
+
+// GetTimeline 获取关注动态
+// @Summary 获取关注的人发布的动态（游标分页）
+// @Tags Moment
+// @Param cursor query string false "Cursor"
+// @Param limit query int false "Limit"
+// @Success 200 {object} response.Response{data=[]model.Post}
+// @Router /moments/timeline [get]
+func (h *TimelineHandler) GetTimeline(c *gin.Context) {
+	var p utils.CursorPagination
+	c.ShouldBindQuery(&p)
+
+	page, err := h.timeline.Timeline(c.Request.Context(), getUserIdFromContext(c), p.Cursor, p.GetLimit())
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
+		return
+	}
+
+	response.CursorPage(c, page.Posts, p.Limit, page.NextCursor, page.HasMore, 0)
+}
+
+// Follow 关注用户
+// @Summary 关注用户
+// @Tags Moment
+// @Param userId path string true "User ID"
+// @Success 200 {string} string "success"
+// @Router /moments/follow/{userId} [post]
+func (h *TimelineHandler) Follow(c *gin.Context) {
+	if err := h.timeline.Follow(c.Request.Context(), getUserIdFromContext(c), c.Param("userId")); err != nil {
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
+		return
+	}
+	response.Success(c, "success")
+}
+
+// Unfollow 取消关注
+// @Summary 取消关注
+// @Tags Moment
+// @Param userId path string true "User ID"
+// @Success 200 {string} string "success"
+// @Router /moments/follow/{userId} [delete]
+func (h *TimelineHandler) Unfollow(c *gin.Context) {
+	if err := h.timeline.Unfollow(c.Request.Context(), getUserIdFromContext(c), c.Param("userId")); err != nil {
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
+		return
+	}
+	response.Success(c, "success")
+}
+
+// DeletePost 删除动态（作者本人或管理员）
+// @Summary 删除动态
+// @Tags Moment
+// @Param id path string true "动态ID"
+// @Success 200 {string} string "success"
+// @Router /moments/{id} [delete]
+func (h *TimelineHandler) DeletePost(c *gin.Context) {
+	role, _ := c.Get("role")
+
+	isAdmin := false
+	switch v := role.(type) {
+	case float64:
+		isAdmin = int(v) == 1
+	case int:
+		isAdmin = v == 1
+	}
+
+	err := h.timeline.DeletePost(c.Request.Context(), getUserIdFromContext(c), isAdmin, c.Param("id"))
+	if errors.Is(err, service.ErrNotPostOwner) {
+		response.Error(c, http.StatusForbidden, response.ErrNoPermission, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, response.ErrServerInternal, err.Error())
+		return
+	}
+	response.Success(c, "success")
+}
@@ -1,6 +1,7 @@
 package moment
 
 import (
+	"time"
 	"user_crud_jwt/internal/domain/moment/handler"
 	"user_crud_jwt/internal/domain/moment/repository"
 	"user_crud_jwt/internal/domain/moment/service"
@@ -29,31 +30,68 @@ func (m *MomentModule) Init(ctx *registry.ModuleContext) error {
 	// 1. 依赖注入 - 暂时使用简化仓库进行测试
 	mRepo := repository.NewSimpleMomentRepository(ctx.DB)
 	momentService := service.NewMomentService(mRepo)
+
+	// 关注时间线依赖 Redis，未配置时不启用
+	var timelineHandler *handler.TimelineHandler
+	if ctx.Redis != nil {
+		followRepo := repository.NewSQLFollowRepository(ctx.DB)
+		timeline := service.NewTimelineService(ctx.Redis, followRepo, mRepo, nil)
+		momentService = service.WithTimeline(momentService, mRepo, timeline)
+		timelineHandler = handler.NewTimelineHandler(timeline)
+	}
+	svc, _ := ctx.Lookup(registry.ResponseCache)
+	responseCache, _ := svc.(*middleware.ResponseCache)
+	if responseCache != nil {
+		momentService = service.WithCacheInvalidation(momentService, responseCache)
+	}
 	momentHandler := handler.NewMomentHandler(momentService)
+	ctx.Provide(registry.MomentRepository, mRepo)
+	ctx.Provide(registry.MomentService, momentService)
 
 	// 搜索服务
 	searchService := service.NewSearchService(mRepo)
 	searchHandler := handler.NewSearchHandler(searchService)
 
 	// 2. 路由注册
-	setupRoutes(ctx.Router, momentHandler, searchHandler)
+	setupRoutes(ctx.Router, momentHandler, searchHandler, timelineHandler, responseCache)
 
 	return nil
 }
 
-func setupRoutes(r *gin.Engine, h *handler.MomentHandler, searchHandler *handler.SearchHandler) {
+func setupRoutes(r *gin.Engine, h *handler.MomentHandler, searchHandler *handler.SearchHandler, timelineHandler *handler.TimelineHandler, responseCache *middleware.ResponseCache) {
+	// 动态流、评论与话题列表与调用方无关，所有登录用户共享缓存
+	cacheFor := func(ttl time.Duration, tags func(c *gin.Context) []string) gin.HandlerFunc {
+		return responseCache.Middleware(&middleware.ResponseCacheConfig{TTL: ttl, Scope: middleware.CacheScopePublic, Tags: tags})
+	}
+
 	// 受保护的路由
 	momentGroup := r.Group("/moments")
 	momentGroup.Use(middleware.AuthMiddleware())
 	{
 		momentGroup.POST("/publish", h.PublishPost)
 		momentGroup.PUT("/:id/audit", h.AuditPost)
-		momentGroup.GET("/feed", h.GetFeed)
+		momentGroup.GET("/feed", cacheFor(30*time.Second, func(c *gin.Context) []string {
+			return []string{service.FeedCacheTag}
+		}), h.GetFeed)
 		momentGroup.POST("/:id/comments", h.AddComment)
-		momentGroup.GET("/:id/comments", h.GetComments)
+		momentGroup.GET("/:id/comments", cacheFor(time.Minute, func(c *gin.Context) []string {
+			return []string{service.CommentsCacheTag(c.Param("id"))}
+		}), h.GetComments)
 		momentGroup.POST("/like", h.ToggleLike)
-		momentGroup.GET("/topics", h.GetTopics)
+		momentGroup.GET("/topics", cacheFor(5*time.Minute, func(c *gin.Context) []string {
+			return []string{service.TopicsCacheTag}
+		}), h.GetTopics)
 		momentGroup.DELETE("/topics/:id", h.DeleteTopic)
+
+		if timelineHandler != nil {
+			momentGroup.GET("/timeline", timelineHandler.GetTimeline)
+			momentGroup.POST("/follow/:userId", timelineHandler.Follow)
+			momentGroup.DELETE("/follow/:userId", timelineHandler.Unfollow)
+			// 删除动态经由时间线服务而非动态服务，在路由上失效缓存
+			momentGroup.DELETE("/:id", middleware.InvalidateTagsMiddleware(responseCache, func(c *gin.Context) []string {
+				return []string{service.FeedCacheTag, service.CommentsCacheTag(c.Param("id"))}
+			}), timelineHandler.DeletePost)
+		}
 	}
 
 	// 搜索路由（部分需要认证）
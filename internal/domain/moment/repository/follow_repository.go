@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"user_crud_jwt/pkg/database"
+)
+
+// FollowRepository 关注关系仓库
+type FollowRepository interface {
+	Follow(ctx context.Context, followerID, followeeID string) error
+	Unfollow(ctx context.Context, followerID, followeeID string) error
+	// CountFollowers 粉丝数
+	CountFollowers(ctx context.Context, userID string) (int64, error)
+	// ListFollowers 按 ID 顺序分批列出粉丝，afterID 为上一批最后一个 ID
+	ListFollowers(ctx context.Context, userID, afterID string, limit int) ([]string, error)
+	// ListFollowees 列出关注的用户（最多 limit 个）
+	ListFollowees(ctx context.Context, userID string, limit int) ([]string, error)
+}
+
+// SQLFollowRepository 基于 user_follows 表的关注关系仓库
+type SQLFollowRepository struct {
+	db *database.DB
+}
+
+// NewSQLFollowRepository 创建关注关系仓库
+func NewSQLFollowRepository(db *database.DB) FollowRepository {
+	return &SQLFollowRepository{db: db}
+}
+
+// Follow 关注，重复关注不报错
+func (r *SQLFollowRepository) Follow(ctx context.Context, followerID, followeeID string) error {
+	if _, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_follows (follower_id, followee_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, followerID, followeeID); err != nil {
+		return fmt.Errorf("failed to follow user: %w", err)
+	}
+	return nil
+}
+
+// Unfollow 取消关注
+func (r *SQLFollowRepository) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM user_follows WHERE follower_id = $1 AND followee_id = $2`, followerID, followeeID); err != nil {
+		return fmt.Errorf("failed to unfollow user: %w", err)
+	}
+	return nil
+}
+
+// CountFollowers 粉丝数
+func (r *SQLFollowRepository) CountFollowers(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	if err := r.db.GetContext(ctx, &count,
+		`SELECT COUNT(*) FROM user_follows WHERE followee_id = $1`, userID); err != nil {
+		return 0, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return count, nil
+}
+
+// ListFollowers 按 follower_id 键集分页，避免大 OFFSET
+func (r *SQLFollowRepository) ListFollowers(ctx context.Context, userID, afterID string, limit int) ([]string, error) {
+	var ids []string
+	var err error
+	if afterID == "" {
+		err = r.db.SelectContext(ctx, &ids, `
+			SELECT follower_id FROM user_follows
+			WHERE followee_id = $1
+			ORDER BY follower_id
+			LIMIT $2`, userID, limit)
+	} else {
+		err = r.db.SelectContext(ctx, &ids, `
+			SELECT follower_id FROM user_follows
+			WHERE followee_id = $1 AND follower_id > $2
+			ORDER BY follower_id
+			LIMIT $3`, userID, afterID, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list followers: %w", err)
+	}
+	return ids, nil
+}
+
+// ListFollowees 列出关注的用户，最近关注的优先
+func (r *SQLFollowRepository) ListFollowees(ctx context.Context, userID string, limit int) ([]string, error) {
+	var ids []string
+	if err := r.db.SelectContext(ctx, &ids, `
+		SELECT followee_id FROM user_follows
+		WHERE follower_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, userID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list followees: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLFollowRepository_ListFollowers 第一批不带游标，之后按上一批最后一个 ID 键集分页
+func TestSQLFollowRepository_ListFollowers(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	repo := NewSQLFollowRepository(db)
+	ctx := context.Background()
+
+	mock.ExpectQuery(`SELECT follower_id FROM user_follows\s+WHERE followee_id = \$1\s+ORDER BY follower_id\s+LIMIT \$2`).
+		WithArgs("star", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"follower_id"}).AddRow("a").AddRow("b"))
+	mock.ExpectQuery(`WHERE followee_id = \$1 AND follower_id > \$2\s+ORDER BY follower_id\s+LIMIT \$3`).
+		WithArgs("star", "b", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"follower_id"}).AddRow("c"))
+
+	ids, err := repo.ListFollowers(ctx, "star", "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, ids)
+	ids, err = repo.ListFollowers(ctx, "star", "b", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, ids)
+}
+
+// TestSQLFollowRepository_Follow 重复关注不报错，关注与取消只影响指定的一条关系
+func TestSQLFollowRepository_Follow(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	repo := NewSQLFollowRepository(db)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO user_follows \(follower_id, followee_id\)\s+VALUES \(\$1, \$2\)\s+ON CONFLICT DO NOTHING`).
+		WithArgs("f1", "star").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM user_follows WHERE follower_id = \$1 AND followee_id = \$2`).
+		WithArgs("f1", "star").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM user_follows WHERE followee_id = \$1`).
+		WithArgs("star").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5001))
+
+	require.NoError(t, repo.Follow(ctx, "f1", "star"))
+	require.NoError(t, repo.Unfollow(ctx, "f1", "star"))
+	count, err := repo.CountFollowers(ctx, "star")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5001), count)
+}
@@ -338,6 +338,15 @@ func (r *PostgresMomentRepository) CreateTopic(ctx context.Context, topic *model
 		INSERT INTO topics (id, name, created_at)
 		VALUES ($1, $2, $3)
 	`, topic.ID, topic.Name, topic.CreatedAt)
+	if database.IsUniqueViolation(err, "uniq_topics_name") {
+		// 并发请求已创建同名话题，使用已有的；已删除的同名话题不会冲突
+		existing, getErr := r.GetTopicByName(ctx, topic.Name)
+		if getErr != nil {
+			return fmt.Errorf("create topic: %w", getErr)
+		}
+		*topic = *existing
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("create topic: %w", err)
 	}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"log"
+	"user_crud_jwt/internal/domain/moment/model"
+	"user_crud_jwt/pkg/cache"
+)
+
+// 动态相关响应的缓存标签
+const (
+	FeedCacheTag   = "moments:feed"
+	TopicsCacheTag = "moments:topics"
+)
+
+// CommentsCacheTag 单条动态评论列表的缓存标签
+func CommentsCacheTag(postID string) string {
+	return "moment:" + postID + ":comments"
+}
+
+// invalidatingMomentService 动态、评论、话题变化后失效依赖它们的缓存
+type invalidatingMomentService struct {
+	MomentService
+	invalidator cache.TagInvalidator
+}
+
+// WithCacheInvalidation 为动态服务挂载按标签的缓存失效，失效失败只记录日志，不影响写操作的结果
+func WithCacheInvalidation(inner MomentService, invalidator cache.TagInvalidator) MomentService {
+	return &invalidatingMomentService{MomentService: inner, invalidator: invalidator}
+}
+
+// PublishPost 新动态待审核，不会出现在动态流中，但可能新建话题
+func (s *invalidatingMomentService) PublishPost(userID string, content string, mediaURLs []string, postType string, topicNames []string) (*model.Post, error) {
+	post, err := s.MomentService.PublishPost(userID, content, mediaURLs, postType, topicNames)
+	if err != nil {
+		return nil, err
+	}
+	if len(topicNames) > 0 {
+		s.invalidate(TopicsCacheTag)
+	}
+	return post, nil
+}
+
+func (s *invalidatingMomentService) AuditPost(postID string, status string) error {
+	if err := s.MomentService.AuditPost(postID, status); err != nil {
+		return err
+	}
+	s.invalidate(FeedCacheTag)
+	return nil
+}
+
+func (s *invalidatingMomentService) AddComment(userID, postID string, content string, parentID string) (*model.Comment, error) {
+	comment, err := s.MomentService.AddComment(userID, postID, content, parentID)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(CommentsCacheTag(postID))
+	return comment, nil
+}
+
+// DeleteTopic 动态流中的动态带有话题，一并失效
+func (s *invalidatingMomentService) DeleteTopic(id string) error {
+	if err := s.MomentService.DeleteTopic(id); err != nil {
+		return err
+	}
+	s.invalidate(TopicsCacheTag, FeedCacheTag)
+	return nil
+}
+
+func (s *invalidatingMomentService) invalidate(tags ...string) {
+	if err := s.invalidator.InvalidateTags(context.Background(), tags...); err != nil {
+		log.Printf("Failed to invalidate moment cache tags %v: %v", tags, err)
+	}
+}
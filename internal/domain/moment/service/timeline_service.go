@@ -0,0 +1,513 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+	"user_crud_jwt/internal/domain/moment/model"
+	"user_crud_jwt/internal/domain/moment/repository"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotPostOwner 只能删除自己的动态
+var ErrNotPostOwner = errors.New("not the owner of this post")
+
+// TimelineConfig 时间线配置
+type TimelineConfig struct {
+	// PullThreshold 粉丝数超过该值的作者不做写扩散，由读者读取时拉取其发件箱
+	PullThreshold int64 `json:"pull_threshold"`
+	// MaxLength 每条时间线/发件箱保留的条数
+	MaxLength int64 `json:"max_length"`
+	// TTL 时间线缓存有效期，过期后读取时重建，不活跃用户不占内存
+	TTL time.Duration `json:"ttl"`
+	// FanoutBatch 扇出时每批处理的粉丝数
+	FanoutBatch int `json:"fanout_batch"`
+	// MaxFollowees 重建时间线时最多读取的关注数
+	MaxFollowees int `json:"max_followees"`
+}
+
+// DefaultTimelineConfig 默认时间线配置
+func DefaultTimelineConfig() *TimelineConfig {
+	return &TimelineConfig{
+		PullThreshold: 5000,
+		MaxLength:     800,
+		TTL:           72 * time.Hour,
+		FanoutBatch:   500,
+		MaxFollowees:  2000,
+	}
+}
+
+// TimelinePage 时间线分页结果
+type TimelinePage struct {
+	Posts      []model.Post
+	NextCursor string
+	HasMore    bool
+}
+
+// timelineEntry 时间线中的一条记录，score 为发布时间（毫秒）
+type timelineEntry struct {
+	postID string
+	score  int64
+}
+
+// 时间线相关键
+const (
+	timelineKeyPrefix  = "moment:timeline:"
+	outboxKeyPrefix    = "moment:outbox:"
+	followingKeyPrefix = "moment:following:"
+	pullAuthorsKey     = "moment:timeline:pull_authors"
+	// timelineSentinel 重建后写入的占位成员（score 为 0），使空时间线也能命中缓存
+	timelineSentinel = "_"
+)
+
+// Lua 脚本：仅当时间线已缓存时写入并裁剪，未缓存的时间线读取时再重建
+var pushScript = redis.NewScript(`
+	if redis.call("EXISTS", KEYS[1]) == 0 then
+		return 0
+	end
+	redis.call("ZADD", KEYS[1], ARGV[1], ARGV[2])
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 0, -tonumber(ARGV[3]) - 1)
+	return 1
+`)
+
+// TimelineService 关注动态时间线：普通作者发布时写扩散到粉丝时间线（Redis 有序集合），
+// 粉丝数超过阈值的作者只写自己的发件箱，由读者读取时合并（读扩散）
+type TimelineService struct {
+	rdb     *redis.Client
+	follows repository.FollowRepository
+	posts   repository.MomentRepository
+	config  *TimelineConfig
+	metrics *metrics.MetricsCollector
+}
+
+// NewTimelineService 创建时间线服务
+func NewTimelineService(rdb *redis.Client, follows repository.FollowRepository, posts repository.MomentRepository, config *TimelineConfig) *TimelineService {
+	if config == nil {
+		config = DefaultTimelineConfig()
+	}
+	return &TimelineService{
+		rdb:     rdb,
+		follows: follows,
+		posts:   posts,
+		config:  config,
+		metrics: metrics.GetGlobalCollector(),
+	}
+}
+
+func timelineKey(userID string) string {
+	return timelineKeyPrefix + userID
+}
+
+func outboxKey(userID string) string {
+	return outboxKeyPrefix + userID
+}
+
+func followingKey(userID string) string {
+	return followingKeyPrefix + userID
+}
+
+func postScore(post *model.Post) int64 {
+	return post.CreatedAt.UnixMilli()
+}
+
+// Publish 动态审核通过后写入作者发件箱，并按粉丝规模选择写扩散或读扩散
+func (s *TimelineService) Publish(ctx context.Context, post *model.Post) (err error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.RecordDBQuery("timeline", "fanout", time.Since(start), err == nil)
+	}()
+
+	if err := s.loadScripts(ctx); err != nil {
+		return err
+	}
+
+	score, member := postScore(post), post.ID
+	pipe := s.rdb.Pipeline()
+	pushScript.EvalSha(ctx, pipe, []string{outboxKey(post.UserID)}, score, member, s.config.MaxLength)
+	pushScript.EvalSha(ctx, pipe, []string{timelineKey(post.UserID)}, score, member, s.config.MaxLength)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+
+	followers, err := s.follows.CountFollowers(ctx, post.UserID)
+	if err != nil {
+		return err
+	}
+	if followers > s.config.PullThreshold {
+		// 大 V 作者改为读扩散，读者重建或读取时合并其发件箱
+		if err := s.rdb.SAdd(ctx, pullAuthorsKey, post.UserID).Err(); err != nil {
+			return fmt.Errorf("failed to mark pull author: %w", err)
+		}
+		return nil
+	}
+
+	return s.eachFollowerBatch(ctx, post.UserID, func(pipe redis.Pipeliner, followerID string) {
+		pushScript.EvalSha(ctx, pipe, []string{timelineKey(followerID)}, score, member, s.config.MaxLength)
+	})
+}
+
+// Retract 动态删除或下架后从发件箱与所有粉丝时间线中移除
+func (s *TimelineService) Retract(ctx context.Context, post *model.Post) (err error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.RecordDBQuery("timeline", "retract", time.Since(start), err == nil)
+	}()
+
+	pipe := s.rdb.Pipeline()
+	pipe.ZRem(ctx, outboxKey(post.UserID), post.ID)
+	pipe.ZRem(ctx, timelineKey(post.UserID), post.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to retract from outbox: %w", err)
+	}
+
+	// 作者可能在成为大 V 之前已写扩散过，因此总是遍历全部粉丝
+	return s.eachFollowerBatch(ctx, post.UserID, func(pipe redis.Pipeliner, followerID string) {
+		pipe.ZRem(ctx, timelineKey(followerID), post.ID)
+	})
+}
+
+// Follow 关注，关注者的时间线在下次读取时重建
+func (s *TimelineService) Follow(ctx context.Context, followerID, followeeID string) error {
+	if followerID == followeeID {
+		return errors.New("cannot follow yourself")
+	}
+	if err := s.follows.Follow(ctx, followerID, followeeID); err != nil {
+		return err
+	}
+	return s.invalidate(ctx, followerID)
+}
+
+// Unfollow 取消关注，关注者的时间线在下次读取时重建
+func (s *TimelineService) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	if err := s.follows.Unfollow(ctx, followerID, followeeID); err != nil {
+		return err
+	}
+	return s.invalidate(ctx, followerID)
+}
+
+// DeletePost 删除动态（作者本人或管理员）并清理时间线缓存
+func (s *TimelineService) DeletePost(ctx context.Context, userID string, isAdmin bool, postID string) error {
+	post, err := s.posts.GetPostByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if post.UserID != userID && !isAdmin {
+		return ErrNotPostOwner
+	}
+	if err := s.posts.DeletePost(ctx, postID); err != nil {
+		return err
+	}
+	if err := s.Retract(ctx, post); err != nil {
+		// 读取时会剔除已不存在的动态，这里只记录日志
+		log.Printf("Failed to retract post %s from timelines: %v", postID, err)
+	}
+	return nil
+}
+
+// Timeline 按游标读取关注动态，合并自身时间线与所关注大 V 的发件箱
+func (s *TimelineService) Timeline(ctx context.Context, userID, cursor string, limit int) (*TimelinePage, error) {
+	after, err := decodeTimelineCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	exists, err := s.rdb.Exists(ctx, timelineKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check timeline: %w", err)
+	}
+	s.metrics.RecordCacheOperationContext(ctx, "get", "timeline", timelineKeyPrefix, time.Since(start), exists == 1)
+	if exists == 0 {
+		if err := s.rebuild(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := s.readRange(ctx, timelineKey(userID), after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	pullAuthors, err := s.rdb.SInter(ctx, followingKey(userID), pullAuthorsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pull authors: %w", err)
+	}
+	for _, authorID := range pullAuthors {
+		if err := s.ensureOutbox(ctx, authorID); err != nil {
+			return nil, err
+		}
+		pulled, err := s.readRange(ctx, outboxKey(authorID), after, limit+1)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, pulled...)
+	}
+	entries = mergeEntries(entries)
+
+	page := &TimelinePage{Posts: make([]model.Post, 0, limit)}
+	var last *timelineEntry
+	for i := range entries {
+		if len(page.Posts) == limit {
+			page.HasMore = true
+			break
+		}
+		entry := &entries[i]
+		post, err := s.posts.GetPostByID(ctx, entry.postID)
+		if err != nil || post.Status != "approved" {
+			// 已删除或下架但未清理的记录，顺带移除
+			s.rdb.ZRem(ctx, timelineKey(userID), entry.postID)
+			continue
+		}
+		page.Posts = append(page.Posts, *post)
+		last = entry
+	}
+
+	if page.HasMore && last != nil {
+		page.NextCursor, err = utils.EncodeCursor([]interface{}{last.score, last.postID})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// rebuild 从所关注的普通作者发件箱合并出时间线
+func (s *TimelineService) rebuild(ctx context.Context, userID string) (err error) {
+	start := time.Now()
+	defer func() {
+		s.metrics.RecordDBQuery("timeline", "rebuild", time.Since(start), err == nil)
+	}()
+
+	followees, err := s.follows.ListFollowees(ctx, userID, s.config.MaxFollowees)
+	if err != nil {
+		return err
+	}
+	authors := append(followees, userID)
+
+	members := make([]interface{}, len(authors))
+	for i, authorID := range authors {
+		members[i] = authorID
+	}
+	isPull, err := s.rdb.SMIsMember(ctx, pullAuthorsKey, members...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check pull authors: %w", err)
+	}
+
+	keys := make([]string, 0, len(authors))
+	for i, authorID := range authors {
+		if isPull[i] {
+			continue
+		}
+		if err := s.ensureOutbox(ctx, authorID); err != nil {
+			return err
+		}
+		keys = append(keys, outboxKey(authorID))
+	}
+
+	key := timelineKey(userID)
+	pipe := s.rdb.TxPipeline()
+	pipe.Del(ctx, key, followingKey(userID))
+	if len(keys) > 0 {
+		pipe.ZUnionStore(ctx, key, &redis.ZStore{Keys: keys, Aggregate: "MAX"})
+		pipe.ZRemRangeByScore(ctx, key, "0", "0")
+		pipe.ZRemRangeByRank(ctx, key, 0, -s.config.MaxLength-1)
+	}
+	pipe.ZAdd(ctx, key, redis.Z{Score: 0, Member: timelineSentinel})
+	pipe.Expire(ctx, key, s.config.TTL)
+	pipe.SAdd(ctx, followingKey(userID), members...)
+	pipe.Expire(ctx, followingKey(userID), s.config.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to rebuild timeline: %w", err)
+	}
+	return nil
+}
+
+// ensureOutbox 发件箱未缓存时从仓库加载作者最近的已审核动态
+func (s *TimelineService) ensureOutbox(ctx context.Context, authorID string) error {
+	key := outboxKey(authorID)
+	start := time.Now()
+	exists, err := s.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check outbox: %w", err)
+	}
+	s.metrics.RecordCacheOperationContext(ctx, "get", "timeline", outboxKeyPrefix, time.Since(start), exists == 1)
+	if exists == 1 {
+		return nil
+	}
+
+	posts, err := s.posts.GetPostsByUserID(ctx, authorID, int(s.config.MaxLength), 0)
+	if err != nil {
+		return err
+	}
+	entries := []redis.Z{{Score: 0, Member: timelineSentinel}}
+	for _, post := range posts {
+		if post.Status == "approved" {
+			entries = append(entries, redis.Z{Score: float64(postScore(post)), Member: post.ID})
+		}
+	}
+
+	pipe := s.rdb.TxPipeline()
+	pipe.ZAdd(ctx, key, entries...)
+	pipe.Expire(ctx, key, s.config.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to load outbox: %w", err)
+	}
+	return nil
+}
+
+// readRange 按 (score, postID) 倒序读取游标之后的 count 条，跳过占位成员
+func (s *TimelineService) readRange(ctx context.Context, key string, after *timelineEntry, count int) ([]timelineEntry, error) {
+	max := "+inf"
+	if after != nil {
+		max = strconv.FormatInt(after.score, 10)
+	}
+
+	var entries []timelineEntry
+	var offset int64
+	for len(entries) < count {
+		batch, err := s.rdb.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Min:    "1",
+			Max:    max,
+			Offset: offset,
+			Count:  int64(count),
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read timeline: %w", err)
+		}
+		for _, z := range batch {
+			entry := timelineEntry{postID: fmt.Sprint(z.Member), score: int64(z.Score)}
+			// 同一毫秒的动态按 ID 倒序，跳过游标及其之前的记录
+			if after != nil && entry.score == after.score && entry.postID >= after.postID {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		if len(batch) < count {
+			break
+		}
+		offset += int64(len(batch))
+	}
+	return entries, nil
+}
+
+// eachFollowerBatch 分批遍历粉丝，每批在一个管道中执行
+func (s *TimelineService) eachFollowerBatch(ctx context.Context, authorID string, fn func(pipe redis.Pipeliner, followerID string)) error {
+	afterID := ""
+	for {
+		followers, err := s.follows.ListFollowers(ctx, authorID, afterID, s.config.FanoutBatch)
+		if err != nil {
+			return err
+		}
+		if len(followers) == 0 {
+			return nil
+		}
+
+		pipe := s.rdb.Pipeline()
+		for _, followerID := range followers {
+			fn(pipe, followerID)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to fan out to followers: %w", err)
+		}
+
+		if len(followers) < s.config.FanoutBatch {
+			return nil
+		}
+		afterID = followers[len(followers)-1]
+	}
+}
+
+// loadScripts 预加载脚本，管道中只能使用 EVALSHA
+func (s *TimelineService) loadScripts(ctx context.Context) error {
+	if err := pushScript.Load(ctx, s.rdb).Err(); err != nil {
+		return fmt.Errorf("failed to load timeline script: %w", err)
+	}
+	return nil
+}
+
+func (s *TimelineService) invalidate(ctx context.Context, userID string) error {
+	if err := s.rdb.Del(ctx, timelineKey(userID), followingKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate timeline: %w", err)
+	}
+	return nil
+}
+
+// mergeEntries 去重并按 (score, postID) 倒序排列
+func mergeEntries(entries []timelineEntry) []timelineEntry {
+	seen := make(map[string]bool, len(entries))
+	merged := entries[:0]
+	for _, entry := range entries {
+		if seen[entry.postID] {
+			continue
+		}
+		seen[entry.postID] = true
+		merged = append(merged, entry)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].score != merged[j].score {
+			return merged[i].score > merged[j].score
+		}
+		return merged[i].postID > merged[j].postID
+	})
+	return merged
+}
+
+func decodeTimelineCursor(cursor string) (*timelineEntry, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	values, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	score, err := strconv.ParseInt(fmt.Sprint(values[0]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return &timelineEntry{score: score, postID: fmt.Sprint(values[1])}, nil
+}
+
+// timelineMomentService 在审核结果变化时同步时间线
+type timelineMomentService struct {
+	MomentService
+	posts    repository.MomentRepository
+	timeline *TimelineService
+}
+
+// WithTimeline 为动态服务挂载时间线扇出：审核通过时发布，驳回时撤回
+func WithTimeline(inner MomentService, posts repository.MomentRepository, timeline *TimelineService) MomentService {
+	return &timelineMomentService{MomentService: inner, posts: posts, timeline: timeline}
+}
+
+func (s *timelineMomentService) AuditPost(postID string, status string) error {
+	if err := s.MomentService.AuditPost(postID, status); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	post, err := s.posts.GetPostByID(ctx, postID)
+	if err != nil {
+		log.Printf("Failed to load post %s for timeline: %v", postID, err)
+		return nil
+	}
+	if status == "approved" {
+		err = s.timeline.Publish(ctx, post)
+	} else {
+		err = s.timeline.Retract(ctx, post)
+	}
+	if err != nil {
+		// 未扇出的动态会在粉丝时间线过期重建后出现
+		log.Printf("Failed to update timelines for post %s: %v", postID, err)
+	}
+	return nil
+}
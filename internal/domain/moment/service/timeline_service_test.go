@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/moment/model"
+	"user_crud_jwt/internal/domain/moment/repository"
+	baseModel "user_crud_jwt/pkg/model"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryFollows 内存中的关注关系
+type memoryFollows struct {
+	edges map[string]map[string]bool // followee -> followers
+}
+
+func newMemoryFollows() *memoryFollows {
+	return &memoryFollows{edges: make(map[string]map[string]bool)}
+}
+
+func (f *memoryFollows) Follow(ctx context.Context, followerID, followeeID string) error {
+	if f.edges[followeeID] == nil {
+		f.edges[followeeID] = make(map[string]bool)
+	}
+	f.edges[followeeID][followerID] = true
+	return nil
+}
+
+func (f *memoryFollows) Unfollow(ctx context.Context, followerID, followeeID string) error {
+	delete(f.edges[followeeID], followerID)
+	return nil
+}
+
+func (f *memoryFollows) CountFollowers(ctx context.Context, userID string) (int64, error) {
+	return int64(len(f.edges[userID])), nil
+}
+
+func (f *memoryFollows) ListFollowers(ctx context.Context, userID, afterID string, limit int) ([]string, error) {
+	var ids []string
+	for id := range f.edges[userID] {
+		if id > afterID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (f *memoryFollows) ListFollowees(ctx context.Context, userID string, limit int) ([]string, error) {
+	var ids []string
+	for followee, followers := range f.edges {
+		if followers[userID] {
+			ids = append(ids, followee)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// memoryPosts 内存中的动态仓库，只实现时间线用到的方法
+type memoryPosts struct {
+	repository.MomentRepository
+	posts map[string]*model.Post
+}
+
+func (r *memoryPosts) GetPostByID(ctx context.Context, id string) (*model.Post, error) {
+	post, ok := r.posts[id]
+	if !ok {
+		return nil, errors.New("post not found")
+	}
+	return post, nil
+}
+
+func (r *memoryPosts) GetPostsByUserID(ctx context.Context, userID string, limit, offset int) ([]*model.Post, error) {
+	var posts []*model.Post
+	for _, post := range r.posts {
+		if post.UserID == userID {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].CreatedAt.After(posts[j].CreatedAt) })
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+func (r *memoryPosts) DeletePost(ctx context.Context, id string) error {
+	delete(r.posts, id)
+	return nil
+}
+
+type timelineFixture struct {
+	service *TimelineService
+	follows *memoryFollows
+	posts   *memoryPosts
+	redis   *miniredis.Miniredis
+	base    time.Time
+}
+
+func newTimelineFixture(t *testing.T, pullThreshold int64) *timelineFixture {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	config := DefaultTimelineConfig()
+	config.PullThreshold = pullThreshold
+	config.FanoutBatch = 2
+	follows := newMemoryFollows()
+	posts := &memoryPosts{posts: make(map[string]*model.Post)}
+	return &timelineFixture{
+		service: NewTimelineService(rdb, follows, posts, config),
+		follows: follows,
+		posts:   posts,
+		redis:   mr,
+		base:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// post 创建第 n 分钟发布的已审核动态
+func (f *timelineFixture) post(id, userID string, n int) *model.Post {
+	post := &model.Post{
+		BaseModel: baseModel.BaseModel{ID: id, CreatedAt: f.base.Add(time.Duration(n) * time.Minute)},
+		UserID:    userID,
+		Status:    "approved",
+	}
+	f.posts.posts[id] = post
+	return post
+}
+
+func (f *timelineFixture) publish(t *testing.T, post *model.Post) {
+	t.Helper()
+	require.NoError(t, f.service.Publish(context.Background(), post))
+}
+
+// ids 读取完整时间线中的动态 ID
+func (f *timelineFixture) ids(t *testing.T, userID string) []string {
+	t.Helper()
+	page, err := f.service.Timeline(context.Background(), userID, "", 50)
+	require.NoError(t, err)
+	ids := make([]string, 0, len(page.Posts))
+	for _, post := range page.Posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+// cached 动态是否已写入用户缓存的时间线
+func (f *timelineFixture) cached(t *testing.T, userID, postID string) bool {
+	t.Helper()
+	members, err := f.redis.ZMembers(timelineKey(userID))
+	if err != nil {
+		return false
+	}
+	for _, member := range members {
+		if member == postID {
+			return true
+		}
+	}
+	return false
+}
+
+// TestTimeline_Fanout 普通作者发布时写入已缓存的粉丝时间线，未缓存的时间线读取时从发件箱重建
+func TestTimeline_Fanout(t *testing.T) {
+	f := newTimelineFixture(t, 5)
+	ctx := context.Background()
+	for _, follower := range []string{"f1", "f2", "f3"} {
+		require.NoError(t, f.service.Follow(ctx, follower, "author"))
+	}
+	assert.Empty(t, f.ids(t, "f1"))
+
+	f.publish(t, f.post("p1", "author", 1))
+	assert.True(t, f.cached(t, "f1", "p1"), "cached timelines receive the post on publish")
+	assert.False(t, f.redis.Exists(timelineKey("f2")), "uncached timelines are left for rebuild")
+	assert.Equal(t, []string{"p1"}, f.ids(t, "f1"))
+	assert.Equal(t, []string{"p1"}, f.ids(t, "f2"))
+	assert.Equal(t, []string{"p1"}, f.ids(t, "author"), "authors see their own posts")
+	assert.False(t, f.redis.Exists(pullAuthorsKey))
+
+	assert.EqualError(t, f.service.Follow(ctx, "author", "author"), "cannot follow yourself")
+	require.NoError(t, f.service.Unfollow(ctx, "f3", "author"))
+	assert.Empty(t, f.ids(t, "f3"))
+}
+
+// TestTimeline_PullThreshold 粉丝数超过阈值后作者改为读扩散：新动态不再写入粉丝时间线，
+// 读取时合并其发件箱，与阈值切换前已写扩散的动态去重后按时间倒序返回
+func TestTimeline_PullThreshold(t *testing.T) {
+	f := newTimelineFixture(t, 2)
+	ctx := context.Background()
+	require.NoError(t, f.service.Follow(ctx, "f1", "star"))
+	require.NoError(t, f.service.Follow(ctx, "f1", "friend"))
+	f.ids(t, "f1")
+
+	f.publish(t, f.post("p1", "star", 1))
+	assert.True(t, f.cached(t, "f1", "p1"))
+
+	require.NoError(t, f.follows.Follow(ctx, "f2", "star"))
+	require.NoError(t, f.follows.Follow(ctx, "f3", "star"))
+	f.publish(t, f.post("p2", "star", 2))
+	f.publish(t, f.post("p3", "friend", 3))
+	assert.False(t, f.cached(t, "f1", "p2"), "large accounts do not fan out")
+	assert.True(t, f.cached(t, "f1", "p3"))
+	isPull, err := f.redis.SIsMember(pullAuthorsKey, "star")
+	require.NoError(t, err)
+	assert.True(t, isPull)
+
+	assert.Equal(t, []string{"p3", "p2", "p1"}, f.ids(t, "f1"))
+	assert.Equal(t, []string{"p2", "p1"}, f.ids(t, "f2"), "rebuilt timelines pull the outbox instead of copying it")
+	assert.False(t, f.cached(t, "f2", "p2"))
+
+	// 发件箱过期后读取时从仓库重新加载
+	f.redis.Del(outboxKey("star"))
+	assert.Equal(t, []string{"p2", "p1"}, f.ids(t, "f3"))
+}
+
+// TestTimeline_Cursor 游标分页，同一毫秒的动态按 ID 倒序且不重复
+func TestTimeline_Cursor(t *testing.T) {
+	f := newTimelineFixture(t, 1)
+	ctx := context.Background()
+	require.NoError(t, f.service.Follow(ctx, "f1", "a"))
+	require.NoError(t, f.service.Follow(ctx, "f1", "star"))
+	require.NoError(t, f.service.Follow(ctx, "f2", "star"))
+	f.post("a1", "a", 1)
+	f.post("a2", "a", 2)
+	f.post("s1", "star", 2)
+	f.post("s2", "star", 3)
+	f.publish(t, f.posts.posts["s2"])
+
+	var got []string
+	cursor := ""
+	for {
+		page, err := f.service.Timeline(ctx, "f1", cursor, 1)
+		require.NoError(t, err)
+		for _, post := range page.Posts {
+			got = append(got, post.ID)
+		}
+		if !page.HasMore {
+			assert.Empty(t, page.NextCursor)
+			break
+		}
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"s2", "s1", "a2", "a1"}, got)
+
+	_, err := f.service.Timeline(ctx, "f1", "not-a-cursor", 1)
+	assert.Error(t, err)
+}
+
+// TestTimeline_Retract 撤回从发件箱、作者与全部粉丝的时间线中移除，包括成为大 V 前写扩散的动态
+func TestTimeline_Retract(t *testing.T) {
+	f := newTimelineFixture(t, 2)
+	ctx := context.Background()
+	for _, follower := range []string{"f1", "f2"} {
+		require.NoError(t, f.service.Follow(ctx, follower, "author"))
+		f.ids(t, follower)
+	}
+	f.ids(t, "author")
+	early := f.post("p1", "author", 1)
+	f.publish(t, early)
+	require.True(t, f.cached(t, "f2", "p1"))
+
+	require.NoError(t, f.follows.Follow(ctx, "f3", "author"))
+	late := f.post("p2", "author", 2)
+	f.publish(t, late)
+
+	require.NoError(t, f.service.Retract(ctx, early))
+	for _, userID := range []string{"author", "f1", "f2"} {
+		assert.False(t, f.cached(t, userID, "p1"), userID)
+	}
+	outbox, err := f.redis.ZMembers(outboxKey("author"))
+	require.NoError(t, err)
+	assert.NotContains(t, outbox, "p1")
+	assert.Equal(t, []string{"p2"}, f.ids(t, "f1"))
+
+	// 删除：只有作者与管理员可以删除
+	assert.ErrorIs(t, f.service.DeletePost(ctx, "f1", false, "p2"), ErrNotPostOwner)
+	require.NoError(t, f.service.DeletePost(ctx, "f1", true, "p2"))
+	assert.Empty(t, f.ids(t, "f1"))
+	assert.Empty(t, f.ids(t, "f3"))
+}
+
+// TestTimeline_SkipsStalePosts 读取时跳过已删除或不再是已审核状态的记录，并从时间线中清理
+func TestTimeline_SkipsStalePosts(t *testing.T) {
+	f := newTimelineFixture(t, 5)
+	ctx := context.Background()
+	require.NoError(t, f.service.Follow(ctx, "f1", "author"))
+	f.post("p1", "author", 1)
+	f.post("p2", "author", 2)
+	assert.Equal(t, []string{"p2", "p1"}, f.ids(t, "f1"))
+
+	f.posts.posts["p2"].Status = "rejected"
+	delete(f.posts.posts, "p1")
+	assert.Empty(t, f.ids(t, "f1"))
+	assert.False(t, f.cached(t, "f1", "p1"))
+	assert.False(t, f.cached(t, "f1", "p2"))
+}
+
+// auditOnly 只实现审核的动态服务
+type auditOnly struct {
+	MomentService
+	posts *memoryPosts
+}
+
+func (s *auditOnly) AuditPost(postID string, status string) error {
+	s.posts.posts[postID].Status = status
+	return nil
+}
+
+// TestWithTimeline_Audit 审核通过时发布到时间线，驳回时撤回
+func TestWithTimeline_Audit(t *testing.T) {
+	f := newTimelineFixture(t, 5)
+	ctx := context.Background()
+	require.NoError(t, f.service.Follow(ctx, "f1", "author"))
+	f.ids(t, "f1")
+	post := f.post("p1", "author", 1)
+	post.Status = "pending"
+	svc := WithTimeline(&auditOnly{posts: f.posts}, f.posts, f.service)
+
+	require.NoError(t, svc.AuditPost("p1", "approved"))
+	assert.True(t, f.cached(t, "f1", "p1"))
+	require.NoError(t, svc.AuditPost("p1", "rejected"))
+	assert.False(t, f.cached(t, "f1", "p1"))
+}
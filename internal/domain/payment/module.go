@@ -4,8 +4,12 @@ import (
 	"user_crud_jwt/internal/domain/payment/handler"
 	"user_crud_jwt/internal/domain/payment/repository"
 	"user_crud_jwt/internal/domain/payment/service"
+	verificationHandler "user_crud_jwt/internal/domain/verification/handler"
+	verificationModel "user_crud_jwt/internal/domain/verification/model"
+	verificationService "user_crud_jwt/internal/domain/verification/service"
 	"user_crud_jwt/internal/pkg/middleware"
 	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/security"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,8 +17,25 @@ import (
 // PaymentModule 支付模块
 type PaymentModule struct{}
 
+// 退款权限
+const (
+	PermissionRefundRead  security.Permission = "payment.refund:read"
+	PermissionRefundWrite security.Permission = "payment.refund:write"
+)
+
 func init() {
 	registry.Register(&PaymentModule{})
+
+	security.RegisterPermission(security.PermissionDefinition{
+		Name:        PermissionRefundRead,
+		Module:      "payment",
+		Description: "查看退款记录",
+	})
+	security.RegisterPermission(security.PermissionDefinition{
+		Name:        PermissionRefundWrite,
+		Module:      "payment",
+		Description: "发起、审核退款",
+	})
 }
 
 func (m *PaymentModule) Name() string {
@@ -33,17 +54,24 @@ func (m *PaymentModule) Init(ctx *registry.ModuleContext) error {
 	paymentService := service.NewPaymentService(pRepo, nil)
 	paymentHandler := handler.NewPaymentHandler(paymentService)
 
+	// 下单为敏感操作，要求手机号已验证
+	var guards []gin.HandlerFunc
+	svc, _ := ctx.Lookup(registry.Verification)
+	if verifications, ok := svc.(*verificationService.VerificationService); ok && verifications != nil {
+		guards = append(guards, verificationHandler.RequireVerified(verifications, verificationModel.ChannelPhone))
+	}
+
 	// 2. 路由注册
-	setupRoutes(ctx.Router, paymentHandler)
+	setupRoutes(ctx.Router, paymentHandler, guards)
 
 	return nil
 }
 
-func setupRoutes(r *gin.Engine, h *handler.PaymentHandler) {
+func setupRoutes(r *gin.Engine, h *handler.PaymentHandler, guards []gin.HandlerFunc) {
 	// 受保护的路由
 	paymentGroup := r.Group("/payments")
 	paymentGroup.Use(middleware.AuthMiddleware())
 	{
-		paymentGroup.POST("/orders", h.CreateOrder)
+		paymentGroup.POST("/orders", append(guards, h.CreateOrder)...)
 	}
 }
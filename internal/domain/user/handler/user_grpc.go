@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"time"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/internal/pkg/grpcserver"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserGRPCServer 用户服务的 gRPC 实现，与 UserHandler 共用服务层
+type UserGRPCServer struct {
+	usercrudv1.UnimplementedUserServiceServer
+	service service.UserService
+}
+
+// NewUserGRPCServer 创建用户 gRPC 服务
+func NewUserGRPCServer(service service.UserService) *UserGRPCServer {
+	return &UserGRPCServer{service: service}
+}
+
+// SendOTP 发送验证码
+func (s *UserGRPCServer) SendOTP(ctx context.Context, req *usercrudv1.SendOTPRequest) (*usercrudv1.SendOTPResponse, error) {
+	if len(req.GetMobile()) != 11 {
+		return nil, status.Error(codes.InvalidArgument, "mobile must be 11 digits")
+	}
+	if err := s.service.SendOTP(ctx, req.GetMobile()); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &usercrudv1.SendOTPResponse{}, nil
+}
+
+// Login 验证码登录
+func (s *UserGRPCServer) Login(ctx context.Context, req *usercrudv1.LoginRequest) (*usercrudv1.LoginResponse, error) {
+	if len(req.GetMobile()) != 11 || len(req.GetCode()) != 6 {
+		return nil, status.Error(codes.InvalidArgument, "mobile must be 11 digits and code 6 digits")
+	}
+	token, err := s.service.LoginOrRegister(ctx, req.GetMobile(), req.GetCode())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return &usercrudv1.LoginResponse{Token: token}, nil
+}
+
+// GetUser 获取单个用户
+func (s *UserGRPCServer) GetUser(ctx context.Context, req *usercrudv1.GetUserRequest) (*usercrudv1.User, error) {
+	user, err := s.service.GetUser(ctx, req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return userToProto(user), nil
+}
+
+// ListUsers 分页获取用户列表
+func (s *UserGRPCServer) ListUsers(ctx context.Context, req *usercrudv1.ListUsersRequest) (*usercrudv1.ListUsersResponse, error) {
+	page, limit := int(req.GetPage()), int(req.GetLimit())
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	users, total, err := s.service.GetUsers(ctx, page, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to fetch users")
+	}
+
+	resp := &usercrudv1.ListUsersResponse{
+		Users: make([]*usercrudv1.User, 0, len(users)),
+		Total: total,
+		Page:  int32(page),
+		Limit: int32(limit),
+	}
+	for i := range users {
+		resp.Users = append(resp.Users, userToProto(&users[i]))
+	}
+	return resp, nil
+}
+
+// UpdateUser 更新用户信息
+func (s *UserGRPCServer) UpdateUser(ctx context.Context, req *usercrudv1.UpdateUserRequest) (*usercrudv1.User, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	user, err := s.service.UpdateUser(ctx, req.GetId(), req.GetNickname(), req.GetAvatarUrl())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to update user")
+	}
+	return userToProto(user), nil
+}
+
+// DeleteUser 删除用户
+func (s *UserGRPCServer) DeleteUser(ctx context.Context, req *usercrudv1.DeleteUserRequest) (*usercrudv1.DeleteUserResponse, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	if err := s.service.DeleteUser(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	return &usercrudv1.DeleteUserResponse{}, nil
+}
+
+// requireSelfOrAdmin 只能操作本人，管理员可以操作任何人
+func requireSelfOrAdmin(ctx context.Context, id string) error {
+	principal := grpcserver.PrincipalFromContext(ctx)
+	if principal == nil {
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if id == "" || (id != principal.UserID && !principal.IsAdmin()) {
+		return status.Error(codes.PermissionDenied, "you can only modify your own account")
+	}
+	return nil
+}
+
+func userToProto(u *model.User) *usercrudv1.User {
+	return &usercrudv1.User{
+		Id:             u.ID,
+		Username:       u.Username,
+		Email:          u.Email,
+		Mobile:         u.Mobile,
+		Nickname:       u.Nickname,
+		AvatarUrl:      u.AvatarURL,
+		Role:           int32(u.Role),
+		IsMember:       u.IsMember,
+		MemberExpireAt: timestampOrNil(u.MemberExpireAt),
+		Status:         int32(u.Status),
+		CreatedAt:      timestamppb.New(u.CreatedAt),
+		UpdatedAt:      timestamppb.New(u.UpdatedAt),
+	}
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
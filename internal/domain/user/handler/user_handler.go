@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/pkg/masking"
 	"user_crud_jwt/pkg/response"
 	"user_crud_jwt/pkg/utils"
 
@@ -50,7 +51,7 @@ func (h *UserHandler) LoginOrRegister(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[LoginHandler] Login attempt - Mobile: %s, Code: %s", input.Mobile, input.Code)
+	log.Printf("[LoginHandler] Login attempt - Mobile: %s", masking.Phone(input.Mobile))
 
 	token, err := h.service.LoginOrRegister(c.Request.Context(), input.Mobile, input.Code)
 	if err != nil {
@@ -59,7 +60,7 @@ func (h *UserHandler) LoginOrRegister(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[LoginHandler] Login successful for mobile: %s", input.Mobile)
+	log.Printf("[LoginHandler] Login successful for mobile: %s", masking.Phone(input.Mobile))
 	response.Success(c, token)
 }
 
@@ -93,7 +94,7 @@ func (h *UserHandler) SendOTP(c *gin.Context) {
 // @Produce json
 // @Param page query int false "页码" default(1)
 // @Param limit query int false "每页数量" default(10)
-// @Success 200 {object} utils.PageResult
+// @Success 200 {object} response.Response{data=[]model.User}
 // @Router /users [get]
 func (h *UserHandler) GetUsers(c *gin.Context) {
 	var pagination utils.Pagination
@@ -101,6 +102,7 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
 		return
 	}
+	pagination.GetPageOffset()
 
 	users, total, err := h.service.GetUsers(c.Request.Context(), pagination.Page, pagination.Limit)
 	if err != nil {
@@ -108,13 +110,7 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		return
 	}
 
-	result := utils.PageResult{
-		List:  users,
-		Total: total,
-		Page:  pagination.Page,
-		Limit: pagination.Limit,
-	}
-	response.Success(c, result)
+	response.Page(c, users, total, pagination.Page, pagination.Limit)
 }
 
 // GetUser 获取单个用户
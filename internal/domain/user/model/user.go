@@ -15,11 +15,15 @@ const (
 	RoleAdmin = 1
 )
 
+// User 用户，users 表的类型化仓库由 repogen 生成（repository.UserRepo）
+//
+//repogen:table=users key=id
 type User struct {
 	ID        string     `db:"id" json:"id"`
 	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
 	UpdatedAt time.Time  `db:"updated_at" json:"updatedAt"`
 	DeletedAt *time.Time `db:"deleted_at" json:"deletedAt,omitempty"`
+	TenantID  string     `db:"tenant_id" json:"-"` // 所属租户，为空时视为默认租户
 
 	Username       string     `db:"username" json:"username"`
 	Password       string     `db:"password" json:"-"` // 密码不返回给前端
@@ -1,12 +1,20 @@
 package user
 
 import (
+	"net/http"
+	"time"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
 	"user_crud_jwt/internal/domain/user/handler"
+	"user_crud_jwt/internal/domain/user/model"
 	"user_crud_jwt/internal/domain/user/repository"
 	"user_crud_jwt/internal/domain/user/service"
+	"user_crud_jwt/internal/pkg/domainevent"
 	"user_crud_jwt/internal/pkg/middleware"
 	"user_crud_jwt/internal/pkg/otp"
 	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/openapi"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -33,15 +41,48 @@ func (m *UserModule) Init(ctx *registry.ModuleContext) error {
 	userRepo := repository.NewSimpleUserRepository(ctx.DB)
 	otpService := otp.NewOTPService(ctx.Redis) // 假设 ModuleContext 中有 Redis 客户端
 	userService := service.NewUserService(userRepo, otpService)
+	svc, _ := ctx.Lookup(registry.ResponseCache)
+	responseCache, _ := svc.(*middleware.ResponseCache)
+	if responseCache != nil {
+		userService = service.WithCacheInvalidation(userService, responseCache)
+	}
+	svc, _ = ctx.Lookup(registry.DomainEvents)
+	if bus, ok := svc.(*domainevent.Bus); ok && bus != nil {
+		userService = service.WithEvents(userService, bus)
+	}
+	// 会员升级与删除改变令牌中的角色，递增权限版本使已签发的令牌在下次认证时重新解析
+	svc, _ = ctx.Lookup(registry.PermissionChecker)
+	if invalidator, ok := svc.(service.PermissionInvalidator); ok {
+		userService = service.WithPermissionInvalidation(userService, invalidator)
+	}
 	userHandler := handler.NewUserHandler(userService)
+	ctx.Provide(registry.UserService, userService)
+	ctx.Provide(registry.UserRepository, userRepo)
+	ctx.Provide(registry.UserRoles, service.NewRoleSource(userRepo))
+
+	// 手机号、邮箱仅本人与具备 admin:read 权限的调用方可见
+	maskConfig := middleware.DefaultMaskingConfig()
+	svc, _ = ctx.Lookup(registry.PermissionChecker)
+	maskConfig.Checker, _ = svc.(security.PermissionChecker)
+	maskConfig.Exempt = func(c *gin.Context) bool {
+		return c.Param("id") != "" && c.Param("id") == c.GetString("userID")
+	}
 
 	// 2. 路由注册
-	setupRoutes(ctx.Router, userHandler)
+	setupRoutes(ctx.Router, userHandler, responseCache, maskConfig)
+
+	// 3. gRPC 服务注册，登录相关方法无需鉴权
+	if ctx.GRPC != nil {
+		usercrudv1.RegisterUserServiceServer(ctx.GRPC.Registrar(), handler.NewUserGRPCServer(userService))
+		ctx.GRPC.AllowUnauthenticated(usercrudv1.UserService_SendOTP_FullMethodName, usercrudv1.UserService_Login_FullMethodName)
+	}
 
 	return nil
 }
 
-func setupRoutes(r *gin.Engine, h *handler.UserHandler) {
+func setupRoutes(r *gin.Engine, h *handler.UserHandler, responseCache *middleware.ResponseCache, maskConfig *middleware.MaskingConfig) {
+	describeRoutes(h)
+
 	// 公开路由
 	authGroup := r.Group("/auth")
 	{
@@ -51,11 +92,27 @@ func setupRoutes(r *gin.Engine, h *handler.UserHandler) {
 
 	// 受保护的路由
 	userGroup := r.Group("/users")
-	userGroup.Use(middleware.AuthMiddleware())
+	userGroup.Use(middleware.AuthMiddleware(), middleware.MaskResponse(maskConfig))
 	{
-		userGroup.GET("/", h.GetUsers)
-		userGroup.GET("/:id", h.GetUser)
+		userGroup.GET("/", responseCache.Middleware(&middleware.ResponseCacheConfig{
+			TTL:  time.Minute,
+			Tags: func(c *gin.Context) []string { return []string{service.UsersCacheTag} },
+		}), h.GetUsers)
+		userGroup.GET("/:id", responseCache.Middleware(&middleware.ResponseCacheConfig{
+			TTL:  5 * time.Minute,
+			Tags: func(c *gin.Context) []string { return []string{service.UserCacheTag(c.Param("id"))} },
+		}), h.GetUser)
 		userGroup.PUT("/:id", h.UpdateUser)
 		userGroup.DELETE("/:id", h.DeleteUser)
 	}
 }
+
+// describeRoutes 路由的 OpenAPI 注解
+func describeRoutes(h *handler.UserHandler) {
+	openapi.Describe(h.LoginOrRegister, openapi.Route{Summary: "手机号验证码登录，不存在时自动注册", Tags: []string{"Auth"}, Body: handler.LoginInput{}, Response: "", Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}})
+	openapi.Describe(h.SendOTP, openapi.Route{Summary: "发送登录验证码", Tags: []string{"Auth"}, Body: handler.OTPInput{}, Response: "", Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}})
+	openapi.Describe(h.GetUsers, openapi.Route{Summary: "分页获取用户列表", Tags: []string{"User"}, Auth: true, Query: utils.Pagination{}, Response: []model.User{}, Paginated: true, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}})
+	openapi.Describe(h.GetUser, openapi.Route{Summary: "获取单个用户", Tags: []string{"User"}, Auth: true, Response: model.User{}, Errors: []int{http.StatusNotFound}})
+	openapi.Describe(h.UpdateUser, openapi.Route{Summary: "更新用户信息", Tags: []string{"User"}, Auth: true, Body: handler.UpdateUserInput{}, Response: model.User{}, Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError}})
+	openapi.Describe(h.DeleteUser, openapi.Route{Summary: "删除用户", Tags: []string{"User"}, Auth: true, Response: "", Errors: []int{http.StatusForbidden, http.StatusInternalServerError}})
+}
@@ -1,5 +1,7 @@
 package repository
 
+//go:generate go run ../../../../cmd/repogen -src ../model/user.go -type User -out user_repo_gen.go
+
 import (
 	"context"
 	"user_crud_jwt/internal/domain/user/model"
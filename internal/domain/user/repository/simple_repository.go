@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/database"
 
 	"github.com/google/uuid"
@@ -12,7 +14,7 @@ import (
 // SimpleUserRepository 简单的用户仓库实现（使用内存存储）
 type SimpleUserRepository struct {
 	db    *database.DB
-	users map[string]*model.User // 手机号 -> 用户
+	users map[string]*model.User // 租户 + 手机号 -> 用户，手机号在租户内唯一
 	mutex sync.RWMutex
 }
 
@@ -32,17 +34,25 @@ func (r *SimpleUserRepository) Create(ctx context.Context, user *model.User) err
 	if user.ID == "" {
 		user.ID = uuid.New().String()
 	}
+	if user.TenantID == "" {
+		user.TenantID = database.TenantForWrite(ctx)
+	}
 
-	r.users[user.Mobile] = user
+	r.users[mobileKey(user.TenantID, user.Mobile)] = user
 	return nil
 }
 
+// mobileKey 内存存储的键
+func mobileKey(tenantID, mobile string) string {
+	return tenantID + "/" + mobile
+}
+
 func (r *SimpleUserRepository) GetByID(ctx context.Context, id string) (*model.User, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
 	for _, user := range r.users {
-		if user.ID == id {
+		if user.ID == id && database.TenantVisible(ctx, user.TenantID) {
 			return user, nil
 		}
 	}
@@ -54,7 +64,7 @@ func (r *SimpleUserRepository) GetByUsername(ctx context.Context, username strin
 	defer r.mutex.RUnlock()
 
 	for _, user := range r.users {
-		if user.Username == username {
+		if user.Username == username && database.TenantVisible(ctx, user.TenantID) {
 			return user, nil
 		}
 	}
@@ -65,21 +75,35 @@ func (r *SimpleUserRepository) GetByMobile(ctx context.Context, mobile string) (
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	user, exists := r.users[mobile]
-	if !exists {
-		return nil, nil // 用户不存在
+	if tenantID := ctxutil.TenantID(ctx); tenantID != "" {
+		user, exists := r.users[mobileKey(tenantID, mobile)]
+		if !exists {
+			return nil, nil // 用户不存在
+		}
+		return user, nil
 	}
-	return user, nil
+
+	// 没有租户的系统调用按手机号查找任意租户中的用户
+	for _, user := range r.users {
+		if user.Mobile == mobile {
+			return user, nil
+		}
+	}
+	return nil, nil
 }
 
 func (r *SimpleUserRepository) Update(ctx context.Context, user *model.User) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if existingUser, exists := r.users[user.Mobile]; exists {
+	if user.TenantID == "" {
+		user.TenantID = database.TenantForWrite(ctx)
+	}
+	key := mobileKey(user.TenantID, user.Mobile)
+	if existingUser, exists := r.users[key]; exists && database.TenantVisible(ctx, existingUser.TenantID) {
 		// 更新现有用户，保持ID不变
 		user.ID = existingUser.ID
-		r.users[user.Mobile] = user
+		r.users[key] = user
 	}
 	return nil
 }
@@ -88,9 +112,9 @@ func (r *SimpleUserRepository) Delete(ctx context.Context, id string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	for mobile, user := range r.users {
-		if user.ID == id {
-			delete(r.users, mobile)
+	for key, user := range r.users {
+		if user.ID == id && database.TenantVisible(ctx, user.TenantID) {
+			delete(r.users, key)
 			break
 		}
 	}
@@ -101,21 +125,31 @@ func (r *SimpleUserRepository) List(ctx context.Context, limit, offset int) ([]*
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	var users []*model.User
-	count := 0
-
-	// 遍历所有用户
+	// map 的遍历顺序不固定，按创建时间排序后分页，保证多次分页读取的结果不重复、不遗漏
+	all := make([]*model.User, 0, len(r.users))
 	for _, user := range r.users {
-		if count >= offset {
-			if len(users) < limit {
-				users = append(users, user)
-			} else {
-				break // 已达到limit数量
-			}
+		if database.TenantVisible(ctx, user.TenantID) {
+			all = append(all, user)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.Before(all[j].CreatedAt)
 		}
-		count++
+		return all[i].ID < all[j].ID
+	})
+
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || offset >= len(all) {
+		return nil, nil
 	}
-	return users, nil
+	end := offset + limit
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[offset:end], nil
 }
 
 func (r *SimpleUserRepository) GetList(ctx context.Context, limit, offset int) ([]*model.User, error) {
@@ -126,7 +160,16 @@ func (r *SimpleUserRepository) Count(ctx context.Context) (int64, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	return int64(len(r.users)), nil
+	if ctxutil.TenantID(ctx) == "" {
+		return int64(len(r.users)), nil
+	}
+	var count int64
+	for _, user := range r.users {
+		if database.TenantVisible(ctx, user.TenantID) {
+			count++
+		}
+	}
+	return count, nil
 }
 
 func (r *SimpleUserRepository) UpdateMemberStatus(ctx context.Context, id string, status int) error {
@@ -134,7 +177,7 @@ func (r *SimpleUserRepository) UpdateMemberStatus(ctx context.Context, id string
 	defer r.mutex.Unlock()
 
 	for _, user := range r.users {
-		if user.ID == id {
+		if user.ID == id && database.TenantVisible(ctx, user.TenantID) {
 			user.Role = status
 			break
 		}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/database"
 
 	"github.com/stretchr/testify/assert"
@@ -182,3 +183,46 @@ func TestSimpleUserRepository_Delete(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Nil(t, foundUser)
 }
+
+func TestSimpleUserRepository_TenantIsolation(t *testing.T) {
+	db := &database.DB{}
+	repo := NewSimpleUserRepository(db)
+
+	tenantA := ctxutil.WithTenantID(context.Background(), "a")
+	tenantB := ctxutil.WithTenantID(context.Background(), "b")
+
+	// 同一手机号可在不同租户分别注册
+	userA := model.NewUser("13800138000", "UserA")
+	require.NoError(t, repo.Create(tenantA, userA))
+	userB := model.NewUser("13800138000", "UserB")
+	require.NoError(t, repo.Create(tenantB, userB))
+	assert.Equal(t, "a", userA.TenantID)
+
+	foundUser, err := repo.GetByMobile(tenantB, "13800138000")
+	assert.NoError(t, err)
+	assert.Equal(t, userB.ID, foundUser.ID)
+
+	// 其他租户的数据不可见
+	foundUser, err = repo.GetByID(tenantB, userA.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, foundUser)
+
+	users, err := repo.List(tenantA, 10, 0)
+	assert.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, userA.ID, users[0].ID)
+
+	count, err := repo.Count(tenantA)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	require.NoError(t, repo.Delete(tenantA, userB.ID))
+	foundUser, err = repo.GetByID(tenantB, userB.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, foundUser)
+
+	// 没有租户的系统调用可见全部数据
+	count, err = repo.Count(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
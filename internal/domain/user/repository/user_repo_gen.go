@@ -0,0 +1,139 @@
+// Code generated by repogen. DO NOT EDIT.
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	userColumns = "id, created_at, updated_at, deleted_at, tenant_id, username, password, email, mobile, nickname, avatar_url, role, is_member, member_expire_at, status, banned_until, token, token_expire_at"
+	userInsert  = "INSERT INTO users (id, created_at, updated_at, deleted_at, tenant_id, username, password, email, mobile, nickname, avatar_url, role, is_member, member_expire_at, status, banned_until, token, token_expire_at) VALUES (:id, :created_at, :updated_at, :deleted_at, :tenant_id, :username, :password, :email, :mobile, :nickname, :avatar_url, :role, :is_member, :member_expire_at, :status, :banned_until, :token, :token_expire_at)"
+	userUpdate  = "UPDATE users SET updated_at = :updated_at, tenant_id = :tenant_id, username = :username, password = :password, email = :email, mobile = :mobile, nickname = :nickname, avatar_url = :avatar_url, role = :role, is_member = :is_member, member_expire_at = :member_expire_at, status = :status, banned_until = :banned_until, token = :token, token_expire_at = :token_expire_at WHERE id = :id AND deleted_at IS NULL"
+)
+
+// UserRepo users 表的类型化仓库。
+// 读取轮询只读副本（需要读到刚写入的数据时用 database.WithPrimary(ctx)，限制复制延迟用 database.WithMaxStaleness(ctx, d)），写入走主库
+type UserRepo struct {
+	router           *database.Router
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewUserRepo 创建仓库
+func NewUserRepo(router *database.Router) *UserRepo {
+	return &UserRepo{router: router, metricsCollector: metrics.GetGlobalCollector()}
+}
+
+// Get 按 id 读取未删除的行，不存在时返回 nil
+func (r *UserRepo) Get(ctx context.Context, id string) (m *model.User, err error) {
+	defer r.observe("get", time.Now(), &err)
+	var row model.User
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1 AND deleted_at IS NULL"
+	if err = r.router.Reader(ctx).GetContext(ctx, &row, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return &row, nil
+}
+
+// GetMany 按 id 批量读取未删除的行，不存在的跳过，顺序不保证
+func (r *UserRepo) GetMany(ctx context.Context, ids []string) (ms []*model.User, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	defer r.observe("get_many", time.Now(), &err)
+	query := "SELECT " + userColumns + " FROM users WHERE id = ANY($1) AND deleted_at IS NULL"
+	if err = r.router.Reader(ctx).SelectContext(ctx, &ms, query, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return ms, nil
+}
+
+// Insert 插入一行
+func (r *UserRepo) Insert(ctx context.Context, m *model.User) (err error) {
+	defer r.observe("insert", time.Now(), &err)
+	stampUserRepoInsert(m, time.Now())
+	if _, err = r.router.Primary().NamedExec(ctx, userInsert, m); err != nil {
+		return fmt.Errorf("failed to insert users: %w", err)
+	}
+	return nil
+}
+
+// InsertMany 批量插入。所有行在一个事务中写入
+func (r *UserRepo) InsertMany(ctx context.Context, ms []*model.User) (err error) {
+	if len(ms) == 0 {
+		return nil
+	}
+	defer r.observe("insert_many", time.Now(), &err)
+	now := time.Now()
+	groups := make(map[*database.DB][]*model.User)
+	for _, m := range ms {
+		stampUserRepoInsert(m, now)
+		db := r.router.Primary()
+		groups[db] = append(groups[db], m)
+	}
+	for db, group := range groups {
+		err = db.RunInTxWithRetry(ctx, "user_repo_insert_many", nil, func(tx *sqlx.Tx) error {
+			for _, m := range group {
+				if _, err := tx.NamedExecContext(ctx, userInsert, m); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to insert users: %w", err)
+		}
+	}
+	return nil
+}
+
+// Update 按 id 更新未删除的行，id 与 created_at 不会修改；行不存在时返回 sql.ErrNoRows
+func (r *UserRepo) Update(ctx context.Context, m *model.User) (err error) {
+	defer r.observe("update", time.Now(), &err)
+	m.UpdatedAt = time.Now()
+	result, err := r.router.Primary().NamedExec(ctx, userUpdate, m)
+	if err != nil {
+		return fmt.Errorf("failed to update users: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("failed to update users %v: %w", m.ID, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// Delete 软删除一行，行不存在时不报错
+func (r *UserRepo) Delete(ctx context.Context, id string) (err error) {
+	defer r.observe("delete", time.Now(), &err)
+	db := r.router.Primary()
+	_, err = db.ExecContext(ctx, "UPDATE users SET deleted_at = $1, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete users: %w", err)
+	}
+	return nil
+}
+
+// observe 记录方法耗时与结果
+func (r *UserRepo) observe(operation string, start time.Time, err *error) {
+	r.metricsCollector.RecordDBQuery("user_repo", operation, time.Since(start), *err == nil)
+}
+
+// stampUserRepoInsert 填充插入时的时间戳
+func stampUserRepoInsert(m *model.User, now time.Time) {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 	"user_crud_jwt/internal/domain/user/model"
 	"user_crud_jwt/internal/domain/user/repository"
+	"user_crud_jwt/pkg/cache"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,6 +30,7 @@ func (s *TestOTPService) Verify(mobile, code string) bool {
 
 // TestCacheService 模拟缓存服务
 type TestCacheService struct {
+	cache.SortedSets
 	data map[string]*cacheItem
 	mu   sync.RWMutex
 }
@@ -163,6 +165,45 @@ func (m *TestCacheService) GetMultiple(ctx context.Context, keys []string, dest
 	return json.Unmarshal(data, dest)
 }
 
+func (m *TestCacheService) GetMany(ctx context.Context, keys []string) (*cache.BatchGetResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := cache.NewBatchGetResult()
+	for _, key := range keys {
+		item, exists := m.data[key]
+		if !exists || time.Now().After(item.expiration) {
+			result.Missing = append(result.Missing, key)
+			continue
+		}
+
+		data, err := json.Marshal(item.value)
+		if err != nil {
+			result.Errors[key] = fmt.Errorf("cache marshal error: %w", err)
+			continue
+		}
+		result.Values[key] = data
+	}
+
+	return result, nil
+}
+
+func (m *TestCacheService) SetMany(ctx context.Context, entries []cache.CacheEntry) (*cache.BatchSetResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := cache.NewBatchSetResult()
+	for _, entry := range entries {
+		m.data[entry.Key] = &cacheItem{
+			value:      entry.Value,
+			expiration: time.Now().Add(entry.Expiration),
+		}
+		result.Succeeded = append(result.Succeeded, entry.Key)
+	}
+
+	return result, nil
+}
+
 func (m *TestCacheService) Clear(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -73,7 +73,7 @@ func (s *CachedUserService) LoginOrRegister(ctx context.Context, mobile, code st
 	}
 
 	// 5. 生成JWT token
-	token, tokenExpireAt, err := utils.GenerateToken(user.ID, user.Role)
+	token, tokenExpireAt, err := utils.GenerateTenantToken(user.TenantID, user.ID, user.Role, nil)
 	if err != nil {
 		return "", err
 	}
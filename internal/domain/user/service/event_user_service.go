@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/pkg/utils"
+)
+
+// eventUserService 登录与资料修改成功后发布领域事件
+type eventUserService struct {
+	UserService
+	bus *domainevent.Bus
+}
+
+// WithEvents 为用户服务挂载领域事件发布，发布失败只记录日志，不影响操作结果
+func WithEvents(inner UserService, bus *domainevent.Bus) UserService {
+	return &eventUserService{UserService: inner, bus: bus}
+}
+
+// LoginOrRegister 登录结果只有令牌，从令牌中取得用户与租户
+func (s *eventUserService) LoginOrRegister(ctx context.Context, mobile, code string) (string, error) {
+	token, err := s.UserService.LoginOrRegister(ctx, mobile, code)
+	if err != nil {
+		return "", err
+	}
+	claims, err := utils.ParseToken(token)
+	if err != nil {
+		log.Printf("Failed to parse issued token for login event: %v", err)
+		return token, nil
+	}
+	domainevent.Publish(ctx, s.bus, domainevent.TopicUserLoggedIn, domainevent.Event{
+		UserID:   claims.UserID,
+		TenantID: claims.TenantID,
+		Data:     map[string]string{"method": "otp"},
+	})
+	return token, nil
+}
+
+// UpdateUser 事件中记录修改的字段，不记录字段的值
+func (s *eventUserService) UpdateUser(ctx context.Context, id string, nickname, avatarURL string) (*model.User, error) {
+	user, err := s.UserService.UpdateUser(ctx, id, nickname, avatarURL)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	if nickname != "" {
+		fields = append(fields, "nickname")
+	}
+	if avatarURL != "" {
+		fields = append(fields, "avatar_url")
+	}
+	domainevent.Publish(ctx, s.bus, domainevent.TopicUserProfileUpdated, domainevent.Event{
+		UserID:   id,
+		TenantID: user.TenantID,
+		Data:     map[string]string{"fields": strings.Join(fields, ",")},
+	})
+	return user, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/cache"
+)
+
+// UsersCacheTag 用户列表的缓存标签
+const UsersCacheTag = "users"
+
+// UserCacheTag 单个用户的缓存标签
+func UserCacheTag(id string) string {
+	return "user:" + id
+}
+
+// invalidatingUserService 用户数据变化后失效依赖它的缓存
+type invalidatingUserService struct {
+	UserService
+	invalidator cache.TagInvalidator
+}
+
+// WithCacheInvalidation 为用户服务挂载按标签的缓存失效，失效失败只记录日志，不影响写操作的结果
+func WithCacheInvalidation(inner UserService, invalidator cache.TagInvalidator) UserService {
+	return &invalidatingUserService{UserService: inner, invalidator: invalidator}
+}
+
+// LoginOrRegister 首次登录会创建用户，列表随之变化
+func (s *invalidatingUserService) LoginOrRegister(ctx context.Context, mobile, code string) (string, error) {
+	token, err := s.UserService.LoginOrRegister(ctx, mobile, code)
+	if err != nil {
+		return "", err
+	}
+	s.invalidate(ctx, UsersCacheTag)
+	return token, nil
+}
+
+func (s *invalidatingUserService) UpdateUser(ctx context.Context, id string, nickname, avatarURL string) (*model.User, error) {
+	user, err := s.UserService.UpdateUser(ctx, id, nickname, avatarURL)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, UserCacheTag(id), UsersCacheTag)
+	return user, nil
+}
+
+func (s *invalidatingUserService) UpgradeMember(ctx context.Context, userID string, duration time.Duration) error {
+	if err := s.UserService.UpgradeMember(ctx, userID, duration); err != nil {
+		return err
+	}
+	s.invalidate(ctx, UserCacheTag(userID), UsersCacheTag)
+	return nil
+}
+
+func (s *invalidatingUserService) DeleteUser(ctx context.Context, id string) error {
+	if err := s.UserService.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	s.invalidate(ctx, UserCacheTag(id), UsersCacheTag)
+	return nil
+}
+
+func (s *invalidatingUserService) invalidate(ctx context.Context, tags ...string) {
+	if err := s.invalidator.InvalidateTags(ctx, tags...); err != nil {
+		log.Printf("Failed to invalidate user cache tags %v: %v", tags, err)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/internal/domain/user/repository"
+)
+
+// PermissionInvalidator 递增用户的权限版本（如 security.RBAC），已签发的令牌在下次认证时重新解析角色
+type PermissionInvalidator interface {
+	InvalidateUsers(ctx context.Context, userIDs ...string)
+}
+
+// permissionUserService 用户表中的角色或状态变化后递增权限版本
+type permissionUserService struct {
+	UserService
+	invalidator PermissionInvalidator
+}
+
+// WithPermissionInvalidation 为用户服务挂载权限版本失效，会员升级改变角色、删除用户后令牌中的角色不再有效
+func WithPermissionInvalidation(inner UserService, invalidator PermissionInvalidator) UserService {
+	return &permissionUserService{UserService: inner, invalidator: invalidator}
+}
+
+func (s *permissionUserService) UpgradeMember(ctx context.Context, userID string, duration time.Duration) error {
+	if err := s.UserService.UpgradeMember(ctx, userID, duration); err != nil {
+		return err
+	}
+	s.invalidator.InvalidateUsers(ctx, userID)
+	return nil
+}
+
+func (s *permissionUserService) DeleteUser(ctx context.Context, id string) error {
+	if err := s.UserService.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+	s.invalidator.InvalidateUsers(ctx, id)
+	return nil
+}
+
+// RoleSource 从用户表读取当前角色，实现 utils.RoleSource；已删除或封禁的用户返回错误，其令牌随之被拒绝
+type RoleSource struct {
+	repo repository.UserRepository
+}
+
+// NewRoleSource 创建角色来源
+func NewRoleSource(repo repository.UserRepository) *RoleSource {
+	return &RoleSource{repo: repo}
+}
+
+// CurrentRole 用户当前的角色
+func (s *RoleSource) CurrentRole(ctx context.Context, userID string) (int, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user %s: %w", userID, err)
+	}
+	if user.Status != model.StatusNormal {
+		return 0, fmt.Errorf("user %s is not active", userID)
+	}
+	return user.Role, nil
+}
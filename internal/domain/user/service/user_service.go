@@ -8,6 +8,7 @@ import (
 	"user_crud_jwt/internal/domain/user/model"
 	"user_crud_jwt/internal/domain/user/repository"
 	"user_crud_jwt/internal/pkg/otp"
+	"user_crud_jwt/pkg/masking"
 	"user_crud_jwt/pkg/utils"
 )
 
@@ -35,18 +36,18 @@ func NewUserService(repo repository.UserRepository, otp otp.OTPService) UserServ
 
 // LoginOrRegister 登录或注册
 func (s *userService) LoginOrRegister(ctx context.Context, mobile, code string) (string, error) {
-	log.Printf("[UserService] LoginOrRegister called with mobile: %s, code: %s", mobile, code)
+	log.Printf("[UserService] LoginOrRegister called with mobile: %s", masking.Phone(mobile))
 
 	// 1. 验证验证码
 	log.Printf("[UserService] Verifying OTP code...")
 	if !s.otp.Verify(mobile, code) {
-		log.Printf("[UserService] OTP verification failed for mobile: %s", mobile)
+		log.Printf("[UserService] OTP verification failed for mobile: %s", masking.Phone(mobile))
 		return "", errors.New("invalid verification code")
 	}
-	log.Printf("[UserService] OTP verification successful for mobile: %s", mobile)
+	log.Printf("[UserService] OTP verification successful for mobile: %s", masking.Phone(mobile))
 
 	// 2. 查询用户是否存在
-	log.Printf("[UserService] Checking if user exists for mobile: %s", mobile)
+	log.Printf("[UserService] Checking if user exists for mobile: %s", masking.Phone(mobile))
 	user, err := s.repo.GetByMobile(ctx, mobile)
 	if err != nil {
 		log.Printf("[UserService] Database error when getting user: %v", err)
@@ -54,7 +55,7 @@ func (s *userService) LoginOrRegister(ctx context.Context, mobile, code string)
 	}
 
 	if user == nil {
-		log.Printf("[UserService] User not found, creating new user for mobile: %s", mobile)
+		log.Printf("[UserService] User not found, creating new user for mobile: %s", masking.Phone(mobile))
 		// 3. 不存在则注册
 		user = model.NewUser(mobile, "User_"+mobile[len(mobile)-4:])
 		log.Printf("[UserService] Created new user with ID: %s", user.ID)
@@ -88,7 +89,7 @@ func (s *userService) LoginOrRegister(ctx context.Context, mobile, code string)
 
 	// 5. 生成JWT token
 	log.Printf("[UserService] Generating JWT token for user ID: %s", user.ID)
-	token, tokenExpireAt, err := utils.GenerateToken(user.ID, user.Role)
+	token, tokenExpireAt, err := utils.GenerateTenantToken(user.TenantID, user.ID, user.Role, nil)
 	if err != nil {
 		log.Printf("[UserService] Failed to generate JWT token: %v", err)
 		return "", err
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"user_crud_jwt/internal/domain/verification/model"
+	"user_crud_jwt/internal/domain/verification/service"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerified 敏感路由中间件，需挂在 AuthMiddleware 之后：要求用户当前的各指定联系方式均已验证，
+// 未验证时返回 CodeContactNotVerified，details.channel 为首个未验证的联系方式
+func RequireVerified(verifications *service.VerificationService, channels ...model.Channel) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			apperrors.Abort(c, apperrors.New(apperrors.CodeTokenInvalid, ""))
+			return
+		}
+
+		status, err := verifications.Status(c.Request.Context(), userID)
+		if err != nil {
+			apperrors.Abort(c, err)
+			return
+		}
+		for _, channel := range channels {
+			if !status.Verified(channel) {
+				apperrors.Abort(c, apperrors.New(apperrors.CodeContactNotVerified, "").WithDetail("channel", string(channel)))
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"user_crud_jwt/internal/domain/verification/model"
+	"user_crud_jwt/internal/domain/verification/service"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendInput 发送验证令牌的参数
+type SendInput struct {
+	Channel model.Channel `json:"channel" binding:"required,oneof=email phone"`
+}
+
+// ConfirmInput 确认验证的参数，确认链接中 token 为查询参数
+type ConfirmInput struct {
+	Token string `json:"token" form:"token" binding:"required,max=1024"`
+}
+
+// VerificationHandler 邮箱与手机号验证接口
+type VerificationHandler struct {
+	verifications *service.VerificationService
+}
+
+// NewVerificationHandler 创建验证接口
+func NewVerificationHandler(verifications *service.VerificationService) *VerificationHandler {
+	return &VerificationHandler{verifications: verifications}
+}
+
+// GetStatus 当前用户各联系方式的验证状态
+func (h *VerificationHandler) GetStatus(c *gin.Context) {
+	status, err := h.verifications.Status(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, status)
+}
+
+// Send 向当前用户的邮箱或手机号发送验证令牌，重新发送受冷却时间与次数限制
+func (h *VerificationHandler) Send(c *gin.Context) {
+	var input SendInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+
+	receipt, err := h.verifications.Send(c.Request.Context(), c.GetString("userID"), input.Channel)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Accepted(c, receipt)
+}
+
+// Confirm 客户端提交令牌，校验后标记联系方式已验证
+func (h *VerificationHandler) Confirm(c *gin.Context) {
+	var input ConfirmInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	h.confirm(c, input.Token)
+}
+
+// ConfirmLink 邮件中的确认链接，令牌在查询参数中
+func (h *VerificationHandler) ConfirmLink(c *gin.Context) {
+	var input ConfirmInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, err.Error())
+		return
+	}
+	h.confirm(c, input.Token)
+}
+
+func (h *VerificationHandler) confirm(c *gin.Context, token string) {
+	confirmation, err := h.verifications.Confirm(c.Request.Context(), token)
+	if err != nil {
+		apperrors.Abort(c, err)
+		return
+	}
+	response.Success(c, confirmation)
+}
@@ -0,0 +1,90 @@
+package model
+
+import "time"
+
+// Channel 需要验证的联系方式
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelPhone Channel = "phone"
+)
+
+// Valid 是否为已知的联系方式
+func (c Channel) Valid() bool {
+	return c == ChannelEmail || c == ChannelPhone
+}
+
+// Contact 用户当前的联系方式及其验证记录
+type Contact struct {
+	UserID string
+	Email  string
+	Mobile string
+	// Verified 各联系方式验证通过时的值，与当前值不同说明验证后已变更
+	Verified map[Channel]Verification
+}
+
+// Value 指定联系方式的当前值，未填写时为空
+func (c *Contact) Value(channel Channel) string {
+	switch channel {
+	case ChannelEmail:
+		return c.Email
+	case ChannelPhone:
+		return c.Mobile
+	default:
+		return ""
+	}
+}
+
+// IsVerified 当前联系方式是否已验证
+func (c *Contact) IsVerified(channel Channel) bool {
+	value := c.Value(channel)
+	verification, ok := c.Verified[channel]
+	return value != "" && ok && verification.Contact == value
+}
+
+// Verification 一条验证记录
+type Verification struct {
+	Contact    string
+	VerifiedAt time.Time
+}
+
+// ChannelStatus 单个联系方式的验证状态，返回给前端时联系方式已脱敏
+type ChannelStatus struct {
+	Contact    string     `json:"contact,omitempty"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verifiedAt,omitempty"`
+}
+
+// Status 用户的验证状态
+type Status struct {
+	Email ChannelStatus `json:"email"`
+	Phone ChannelStatus `json:"phone"`
+}
+
+// Verified 指定联系方式是否已验证
+func (s *Status) Verified(channel Channel) bool {
+	switch channel {
+	case ChannelEmail:
+		return s.Email.Verified
+	case ChannelPhone:
+		return s.Phone.Verified
+	default:
+		return false
+	}
+}
+
+// Receipt 发送验证令牌的回执
+type Receipt struct {
+	Channel     Channel   `json:"channel"`
+	Contact     string    `json:"contact"` // 已脱敏
+	ExpiresAt   time.Time `json:"expiresAt"`
+	ResendAfter time.Time `json:"resendAfter"` // 早于该时间重新发送会被拒绝
+}
+
+// Confirmation 验证通过的结果
+type Confirmation struct {
+	UserID     string    `json:"userId"`
+	Channel    Channel   `json:"channel"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
@@ -0,0 +1,137 @@
+package verification
+
+import (
+	"log"
+	"net/http"
+	"time"
+	"user_crud_jwt/internal/domain/verification/handler"
+	"user_crud_jwt/internal/domain/verification/model"
+	"user_crud_jwt/internal/domain/verification/repository"
+	"user_crud_jwt/internal/domain/verification/service"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/internal/pkg/middleware"
+	"user_crud_jwt/internal/pkg/registry"
+	"user_crud_jwt/pkg/notify"
+	"user_crud_jwt/pkg/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerificationModule 邮箱与手机号验证模块
+type VerificationModule struct{}
+
+func init() {
+	registry.Register(&VerificationModule{})
+}
+
+func (m *VerificationModule) Name() string {
+	return "verification"
+}
+
+func (m *VerificationModule) Priority() int {
+	// 先于支付等模块初始化，这些模块以 RequireVerified 保护敏感操作
+	return 5
+}
+
+func (m *VerificationModule) Init(ctx *registry.ModuleContext) error {
+	// 1. 依赖注入
+	svc, _ := ctx.Lookup(registry.Notifier)
+	notifier, ok := svc.(*notify.Dispatcher)
+	if !ok || notifier == nil {
+		log.Printf("Notification dispatcher unavailable, contact verification disabled")
+		return nil
+	}
+	verifications := service.NewVerificationService(repository.NewSQLContactRepository(ctx.DB), service.NewRedisState(ctx.Redis), notifier, loadConfig())
+	svc, _ = ctx.Lookup(registry.NotifyTemplates)
+	if templates, ok := svc.(*notify.Templates); ok && templates != nil {
+		if err := verifications.RegisterTemplates(templates); err != nil {
+			return err
+		}
+	}
+	ctx.Provide(registry.Verification, verifications)
+
+	// 短信验证码登录后手机号记为已验证
+	svc, _ = ctx.Lookup(registry.DomainEvents)
+	if bus, ok := svc.(*domainevent.Bus); ok && bus != nil {
+		if err := verifications.Subscribe(bus); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Domain event bus unavailable, otp login will not verify phone numbers")
+	}
+
+	// 2. 路由注册
+	setupRoutes(ctx.Router, handler.NewVerificationHandler(verifications))
+
+	return nil
+}
+
+// loadConfig 未配置的项使用默认值
+func loadConfig() *service.Config {
+	cfg := config.GlobalConfig.Verification
+	verificationConfig := service.DefaultConfig()
+	verificationConfig.Secret = []byte(cfg.Secret)
+	verificationConfig.LinkURL = cfg.LinkURL
+	if cfg.TokenTTLMinutes > 0 {
+		verificationConfig.TokenTTL = time.Duration(cfg.TokenTTLMinutes) * time.Minute
+	}
+	if cfg.ResendCooldown > 0 {
+		verificationConfig.ResendCooldown = time.Duration(cfg.ResendCooldown) * time.Second
+	}
+	if cfg.ResendLimit > 0 {
+		verificationConfig.ResendLimit = cfg.ResendLimit
+	}
+	return verificationConfig
+}
+
+func setupRoutes(r *gin.Engine, h *handler.VerificationHandler) {
+	describeRoutes(h)
+
+	verificationGroup := r.Group("/verification")
+	{
+		// 公开路由：令牌本身标识用户，邮件中的链接在未登录的浏览器中打开
+		verificationGroup.GET("/confirm", h.ConfirmLink)
+		verificationGroup.POST("/confirm", h.Confirm)
+	}
+
+	// 受保护的路由
+	protected := verificationGroup.Group("")
+	protected.Use(middleware.AuthMiddleware())
+	{
+		protected.GET("/status", h.GetStatus)
+		protected.POST("/send", h.Send)
+	}
+}
+
+// describeRoutes 路由的 OpenAPI 注解
+func describeRoutes(h *handler.VerificationHandler) {
+	openapi.Describe(h.GetStatus, openapi.Route{Summary: "当前用户邮箱与手机号的验证状态", Tags: []string{"Verification"}, Auth: true, Response: model.Status{}, Errors: []int{http.StatusNotFound}})
+	openapi.Describe(h.Send, openapi.Route{
+		Summary:     "发送验证令牌",
+		Description: "向当前用户的邮箱（链接）或手机号（短信）发送一次性验证令牌，重新发送会使之前的令牌失效；冷却时间内或超出每小时次数时返回 429，details.retry_after 为需等待的秒数",
+		Tags:        []string{"Verification"},
+		Auth:        true,
+		Body:        handler.SendInput{},
+		Response:    model.Receipt{},
+		Status:      http.StatusAccepted,
+		Errors:      []int{http.StatusBadRequest, http.StatusConflict, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	})
+	const confirmErrors = "令牌只能使用一次，过期、已使用、已被重新发送替换或联系方式已变更时返回 400"
+	openapi.Describe(h.Confirm, openapi.Route{
+		Summary:     "确认验证令牌",
+		Description: confirmErrors,
+		Tags:        []string{"Verification"},
+		Body:        handler.ConfirmInput{},
+		Response:    model.Confirmation{},
+		Errors:      []int{http.StatusBadRequest},
+	})
+	openapi.Describe(h.ConfirmLink, openapi.Route{
+		Summary:     "打开邮件中的确认链接",
+		Description: confirmErrors,
+		Tags:        []string{"Verification"},
+		Query:       handler.ConfirmInput{},
+		Response:    model.Confirmation{},
+		Errors:      []int{http.StatusBadRequest},
+	})
+}
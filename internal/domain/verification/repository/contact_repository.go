@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/internal/domain/verification/model"
+	"user_crud_jwt/pkg/database"
+)
+
+// ErrUserNotFound 用户不存在或已删除
+var ErrUserNotFound = errors.New("user not found")
+
+// ContactRepository 用户联系方式与验证记录
+type ContactRepository interface {
+	// Get 获取用户当前的联系方式与验证记录，用户不存在时返回 ErrUserNotFound
+	Get(ctx context.Context, userID string) (*model.Contact, error)
+	// MarkVerified 仅当用户当前的联系方式仍为 contact 时记录验证通过，返回是否记录
+	MarkVerified(ctx context.Context, userID string, channel model.Channel, contact string, at time.Time) (bool, error)
+}
+
+// SQLContactRepository 基于 users 与 user_contact_verifications 表的联系方式仓库
+type SQLContactRepository struct {
+	db *database.DB
+}
+
+// NewSQLContactRepository 创建联系方式仓库
+func NewSQLContactRepository(db *database.DB) *SQLContactRepository {
+	return &SQLContactRepository{db: db}
+}
+
+func (r *SQLContactRepository) Get(ctx context.Context, userID string) (*model.Contact, error) {
+	var user struct {
+		Email  string `db:"email"`
+		Mobile string `db:"mobile"`
+	}
+	err := r.db.GetContext(ctx, &user, `
+		SELECT COALESCE(email, '') AS email, COALESCE(mobile, '') AS mobile
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user contact: %w", err)
+	}
+
+	var rows []struct {
+		Channel    string    `db:"channel"`
+		Contact    string    `db:"contact"`
+		VerifiedAt time.Time `db:"verified_at"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, `
+		SELECT channel, contact, verified_at FROM user_contact_verifications WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to get contact verifications: %w", err)
+	}
+
+	contact := &model.Contact{
+		UserID:   userID,
+		Email:    user.Email,
+		Mobile:   user.Mobile,
+		Verified: make(map[model.Channel]model.Verification, len(rows)),
+	}
+	for _, row := range rows {
+		contact.Verified[model.Channel(row.Channel)] = model.Verification{Contact: row.Contact, VerifiedAt: row.VerifiedAt}
+	}
+	return contact, nil
+}
+
+func (r *SQLContactRepository) MarkVerified(ctx context.Context, userID string, channel model.Channel, contact string, at time.Time) (bool, error) {
+	column := "email"
+	if channel == model.ChannelPhone {
+		column = "mobile"
+	}
+	// 联系方式与写入在同一条语句中比较，避免确认期间联系方式被修改
+	result, err := r.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO user_contact_verifications (user_id, channel, contact, verified_at)
+		SELECT id, $2, $3, $4 FROM users WHERE id = $1 AND %s = $3 AND deleted_at IS NULL
+		ON CONFLICT (user_id, channel) DO UPDATE SET contact = EXCLUDED.contact, verified_at = EXCLUDED.verified_at`, column),
+		userID, string(channel), contact, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark contact verified: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark contact verified: %w", err)
+	}
+	return affected > 0, nil
+}
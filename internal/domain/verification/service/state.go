@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// State 验证令牌与发送频率的状态，key 标识一个用户的一种联系方式
+type State interface {
+	// Throttle 登记一次发送，未超出冷却时间与窗口内次数限制时返回 0，否则返回需等待的时长
+	Throttle(ctx context.Context, key string, cooldown time.Duration, limit int, window time.Duration) (time.Duration, error)
+	// Issue 保存当前有效的令牌 ID，之前签发的令牌随之失效
+	Issue(ctx context.Context, key, tokenID string, ttl time.Duration) error
+	// Consume 令牌 ID 为当前有效令牌时原子地删除并返回 true，令牌因此只能使用一次
+	Consume(ctx context.Context, key, tokenID string) (bool, error)
+}
+
+// throttleScript KEYS: 冷却键、计数键；ARGV: 冷却毫秒数、窗口内次数上限、窗口毫秒数。返回需等待的毫秒数
+var throttleScript = redis.NewScript(`
+local wait = redis.call('PTTL', KEYS[1])
+if wait > 0 then
+	return wait
+end
+local count = redis.call('INCR', KEYS[2])
+if redis.call('PTTL', KEYS[2]) < 0 then
+	redis.call('PEXPIRE', KEYS[2], ARGV[3])
+end
+if count > tonumber(ARGV[2]) then
+	return redis.call('PTTL', KEYS[2])
+end
+redis.call('SET', KEYS[1], '1', 'PX', ARGV[1])
+return 0
+`)
+
+// consumeScript 比较后删除，KEYS: 令牌键；ARGV: 令牌 ID
+var consumeScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisState 基于 Redis 的状态，多实例共享
+type RedisState struct {
+	rdb *redis.Client
+}
+
+// NewRedisState 创建基于 Redis 的状态
+func NewRedisState(rdb *redis.Client) *RedisState {
+	return &RedisState{rdb: rdb}
+}
+
+func (s *RedisState) Throttle(ctx context.Context, key string, cooldown time.Duration, limit int, window time.Duration) (time.Duration, error) {
+	wait, err := throttleScript.Run(ctx, s.rdb, []string{key + ":cooldown", key + ":count"},
+		cooldown.Milliseconds(), limit, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to throttle verification: %w", err)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+func (s *RedisState) Issue(ctx context.Context, key, tokenID string, ttl time.Duration) error {
+	if err := s.rdb.Set(ctx, key+":token", tokenID, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save verification token: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisState) Consume(ctx context.Context, key, tokenID string) (bool, error) {
+	deleted, err := consumeScript.Run(ctx, s.rdb, []string{key + ":token"}, tokenID).Int64()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume verification token: %w", err)
+	}
+	return deleted > 0, nil
+}
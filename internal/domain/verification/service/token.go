@@ -0,0 +1,82 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"user_crud_jwt/internal/domain/verification/model"
+)
+
+var (
+	errMalformedToken = errors.New("malformed verification token")
+	errTokenSignature = errors.New("invalid verification token signature")
+	errTokenExpired   = errors.New("verification token expired")
+)
+
+// claims 验证令牌的内容。Contact 为签发时联系方式的摘要，联系方式变更后令牌随之失效
+type claims struct {
+	UserID    string        `json:"sub"`
+	Channel   model.Channel `json:"ch"`
+	Contact   string        `json:"ct"`
+	ID        string        `json:"jti"` // 单次使用，只有最近签发的 ID 有效
+	ExpiresAt int64         `json:"exp"` // Unix 秒
+}
+
+// signToken 令牌格式为 base64url(JSON).base64url(HMAC-SHA256)
+func signToken(secret []byte, c *claims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode verification token: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, encoded)), nil
+}
+
+// parseToken 校验签名与有效期，now 为 Unix 秒
+func parseToken(secret []byte, token string, now int64) (*claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errMalformedToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, errMalformedToken
+	}
+	if !hmac.Equal(mac, tokenMAC(secret, encoded)) {
+		return nil, errTokenSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errMalformedToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, errMalformedToken
+	}
+	if c.UserID == "" || c.ID == "" || !c.Channel.Valid() {
+		return nil, errMalformedToken
+	}
+	if now >= c.ExpiresAt {
+		return nil, errTokenExpired
+	}
+	return &c, nil
+}
+
+func tokenMAC(secret []byte, encoded string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// contactDigest 联系方式的带密钥摘要，令牌中不出现明文邮箱或手机号
+func contactDigest(secret []byte, channel model.Channel, contact string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(string(channel) + ":" + contact))
+	return hex.EncodeToString(mac.Sum(nil)[:12])
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+	"user_crud_jwt/internal/domain/verification/model"
+	"user_crud_jwt/internal/domain/verification/repository"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/events"
+	"user_crud_jwt/pkg/masking"
+	"user_crud_jwt/pkg/notify"
+)
+
+const (
+	// TemplateName 验证令牌的通知模板
+	TemplateName = "contact_verification"
+	// NotifyCategory 验证令牌的通知类别，作为安全类通知不受退订影响
+	NotifyCategory = "verification"
+)
+
+// Notifier 通知投递，由 *notify.Dispatcher 实现
+type Notifier interface {
+	Notify(ctx context.Context, n *notify.Notification) ([]*notify.Delivery, error)
+}
+
+// Config 验证配置
+type Config struct {
+	Secret         []byte        // 令牌签名密钥，为空时随机生成；多实例部署时须配置相同的密钥
+	TokenTTL       time.Duration // 令牌有效期
+	ResendCooldown time.Duration // 两次发送的最小间隔
+	ResendLimit    int           // ResendWindow 内最多发送次数
+	ResendWindow   time.Duration
+	LinkURL        string // 邮件中的确认链接地址，令牌以 token 参数附加；为空时只发送令牌
+}
+
+// DefaultConfig 默认验证配置
+func DefaultConfig() *Config {
+	return &Config{
+		TokenTTL:       30 * time.Minute,
+		ResendCooldown: time.Minute,
+		ResendLimit:    5,
+		ResendWindow:   time.Hour,
+	}
+}
+
+// VerificationService 邮箱与手机号验证：签发绑定当前联系方式的一次性令牌并经通知投递，确认后记录为已验证
+type VerificationService struct {
+	repo     repository.ContactRepository
+	state    State
+	notifier Notifier
+	config   *Config
+	clock    clock.Clock
+}
+
+// NewVerificationService 创建验证服务，config 为 nil 时使用默认配置
+func NewVerificationService(repo repository.ContactRepository, state State, notifier Notifier, config *Config) *VerificationService {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if len(config.Secret) == 0 {
+		config.Secret = make([]byte, 32)
+		if _, err := rand.Read(config.Secret); err != nil {
+			panic(fmt.Sprintf("failed to generate verification secret: %v", err))
+		}
+	}
+	return &VerificationService{
+		repo:     repo,
+		state:    state,
+		notifier: notifier,
+		config:   config,
+		clock:    clock.OrReal(nil),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (s *VerificationService) SetClock(c clock.Clock) {
+	s.clock = clock.OrReal(c)
+}
+
+// RegisterTemplates 注册验证令牌的邮件与短信模板
+func (s *VerificationService) RegisterTemplates(templates *notify.Templates) error {
+	items := []struct {
+		channel notify.Channel
+		locale  string
+		tpl     notify.Template
+	}{
+		{notify.ChannelEmail, "zh-CN", notify.Template{
+			Subject: "请验证您的邮箱",
+			Body:    "请在 {{.minutes}} 分钟内打开以下链接完成邮箱验证：\n{{.link}}\n如非本人操作请忽略本邮件。",
+		}},
+		{notify.ChannelEmail, "en", notify.Template{
+			Subject: "Verify your email address",
+			Body:    "Open the link below within {{.minutes}} minutes to verify your email address:\n{{.link}}\nIf you did not request this, please ignore this email.",
+		}},
+		{notify.ChannelSMS, "zh-CN", notify.Template{
+			Body: "您的手机号验证口令为 {{.token}}，{{.minutes}} 分钟内有效，请勿泄露给他人。",
+		}},
+		{notify.ChannelSMS, "en", notify.Template{
+			Body: "Your phone verification token is {{.token}}, valid for {{.minutes}} minutes. Do not share it with anyone.",
+		}},
+	}
+	for _, item := range items {
+		if err := templates.Register(TemplateName, item.channel, item.locale, item.tpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send 向用户当前的联系方式发送验证令牌，重新发送会使之前的令牌失效
+func (s *VerificationService) Send(ctx context.Context, userID string, channel model.Channel) (*model.Receipt, error) {
+	if !channel.Valid() {
+		return nil, apperrors.New(apperrors.CodeInvalidParam, "").WithDetail("channel", string(channel))
+	}
+	contact, err := s.contact(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	value := contact.Value(channel)
+	if value == "" {
+		return nil, apperrors.Newf(apperrors.CodeInvalidParam, "no %s on file", channel)
+	}
+	if contact.IsVerified(channel) {
+		return nil, apperrors.Newf(apperrors.CodeConflict, "%s is already verified", channel)
+	}
+
+	key := stateKey(userID, channel)
+	wait, err := s.state.Throttle(ctx, key, s.config.ResendCooldown, s.config.ResendLimit, s.config.ResendWindow)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeCache, "")
+	}
+	if wait > 0 {
+		return nil, tooManyRequests(wait)
+	}
+
+	now := s.clock.Now()
+	tokenID, err := newTokenID()
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "")
+	}
+	expiresAt := now.Add(s.config.TokenTTL)
+	token, err := signToken(s.config.Secret, &claims{
+		UserID:    userID,
+		Channel:   channel,
+		Contact:   contactDigest(s.config.Secret, channel, value),
+		ID:        tokenID,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "")
+	}
+	if err := s.state.Issue(ctx, key, tokenID, s.config.TokenTTL); err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeCache, "")
+	}
+
+	deliveries, err := s.notifier.Notify(ctx, &notify.Notification{
+		UserID:   userID,
+		Category: NotifyCategory,
+		Template: TemplateName,
+		Data: map[string]interface{}{
+			"token":   token,
+			"link":    s.link(token),
+			"minutes": int(s.config.TokenTTL / time.Minute),
+		},
+		Channels:  []notify.Channel{notifyChannel(channel)},
+		Critical:  true,
+		DedupeKey: "verification:" + tokenID,
+	})
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInternal, "")
+	}
+	if len(deliveries) == 0 {
+		return nil, apperrors.Newf(apperrors.CodeUnavailable, "%s delivery is not available", channel)
+	}
+	if deliveries[0].Status == notify.StatusSuppressed {
+		return nil, apperrors.New(apperrors.CodeTooManyRequests, "")
+	}
+
+	return &model.Receipt{
+		Channel:     channel,
+		Contact:     maskContact(channel, value),
+		ExpiresAt:   expiresAt,
+		ResendAfter: now.Add(s.config.ResendCooldown),
+	}, nil
+}
+
+// Confirm 校验令牌并记录联系方式已验证。令牌只能使用一次，重新发送或联系方式变更后旧令牌失效
+func (s *VerificationService) Confirm(ctx context.Context, token string) (*model.Confirmation, error) {
+	c, err := parseToken(s.config.Secret, token, s.clock.Now().Unix())
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeVerificationInvalid, "")
+	}
+	consumed, err := s.state.Consume(ctx, stateKey(c.UserID, c.Channel), c.ID)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeCache, "")
+	}
+	if !consumed {
+		return nil, apperrors.New(apperrors.CodeVerificationInvalid, "")
+	}
+
+	contact, err := s.repo.Get(ctx, c.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, apperrors.New(apperrors.CodeVerificationInvalid, "")
+	}
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	value := contact.Value(c.Channel)
+	if value == "" || contactDigest(s.config.Secret, c.Channel, value) != c.Contact {
+		return nil, apperrors.New(apperrors.CodeVerificationInvalid, "contact has changed since the token was issued")
+	}
+
+	now := s.clock.Now()
+	marked, err := s.repo.MarkVerified(ctx, c.UserID, c.Channel, value, now)
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	if !marked {
+		return nil, apperrors.New(apperrors.CodeVerificationInvalid, "contact has changed since the token was issued")
+	}
+	return &model.Confirmation{UserID: c.UserID, Channel: c.Channel, VerifiedAt: now}, nil
+}
+
+// Status 用户各联系方式的验证状态
+func (s *VerificationService) Status(ctx context.Context, userID string) (*model.Status, error) {
+	contact, err := s.contact(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Status{
+		Email: channelStatus(contact, model.ChannelEmail),
+		Phone: channelStatus(contact, model.ChannelPhone),
+	}, nil
+}
+
+// Subscribe 异步订阅登录事件：短信验证码登录已证明持有手机号，登录后手机号记为已验证
+func (s *VerificationService) Subscribe(bus *domainevent.Bus) error {
+	if err := bus.Subscribe("contact-verification", s.onLogin, events.Async(), events.WithTopics(domainevent.TopicUserLoggedIn)); err != nil {
+		return fmt.Errorf("failed to subscribe contact verification: %w", err)
+	}
+	return nil
+}
+
+func (s *VerificationService) onLogin(ctx context.Context, event events.Event[domainevent.Event]) error {
+	payload := event.Payload
+	if payload.UserID == "" || payload.Data["method"] != "otp" {
+		return nil
+	}
+	contact, err := s.repo.Get(ctx, payload.UserID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if contact.Mobile == "" || contact.IsVerified(model.ChannelPhone) {
+		return nil
+	}
+	verifiedAt := payload.OccurredAt
+	if verifiedAt.IsZero() {
+		verifiedAt = event.Timestamp
+	}
+	if _, err := s.repo.MarkVerified(ctx, payload.UserID, model.ChannelPhone, contact.Mobile, verifiedAt); err != nil {
+		return err
+	}
+	log.Printf("Phone of user %s verified by otp login", payload.UserID)
+	return nil
+}
+
+func (s *VerificationService) contact(ctx context.Context, userID string) (*model.Contact, error) {
+	contact, err := s.repo.Get(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil, apperrors.New(apperrors.CodeUserNotFound, "")
+	}
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeDatabase, "")
+	}
+	return contact, nil
+}
+
+// link 确认链接，未配置 LinkURL 时为令牌本身
+func (s *VerificationService) link(token string) string {
+	if s.config.LinkURL == "" {
+		return token
+	}
+	u, err := url.Parse(s.config.LinkURL)
+	if err != nil {
+		return token
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func stateKey(userID string, channel model.Channel) string {
+	return "verification:" + userID + ":" + string(channel)
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func tooManyRequests(wait time.Duration) error {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	return apperrors.New(apperrors.CodeTooManyRequests, "").WithDetail("retry_after", seconds)
+}
+
+func notifyChannel(channel model.Channel) notify.Channel {
+	if channel == model.ChannelPhone {
+		return notify.ChannelSMS
+	}
+	return notify.ChannelEmail
+}
+
+func maskContact(channel model.Channel, value string) string {
+	if channel == model.ChannelPhone {
+		return masking.Phone(value)
+	}
+	return masking.Email(value)
+}
+
+func channelStatus(contact *model.Contact, channel model.Channel) model.ChannelStatus {
+	status := model.ChannelStatus{Verified: contact.IsVerified(channel)}
+	if value := contact.Value(channel); value != "" {
+		status.Contact = maskContact(channel, value)
+	}
+	if status.Verified {
+		verifiedAt := contact.Verified[channel].VerifiedAt
+		status.VerifiedAt = &verifiedAt
+	}
+	return status
+}
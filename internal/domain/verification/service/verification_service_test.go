@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/domain/verification/model"
+	"user_crud_jwt/internal/domain/verification/repository"
+	"user_crud_jwt/internal/pkg/domainevent"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/notify"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryContacts 内存中的联系方式与验证记录
+type memoryContacts struct {
+	mu       sync.Mutex
+	contacts map[string]*model.Contact
+}
+
+func (m *memoryContacts) Get(ctx context.Context, userID string) (*model.Contact, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	contact, ok := m.contacts[userID]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *contact
+	copied.Verified = make(map[model.Channel]model.Verification)
+	for channel, verification := range contact.Verified {
+		copied.Verified[channel] = verification
+	}
+	return &copied, nil
+}
+
+func (m *memoryContacts) MarkVerified(ctx context.Context, userID string, channel model.Channel, value string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	contact, ok := m.contacts[userID]
+	if !ok || contact.Value(channel) != value {
+		return false, nil
+	}
+	contact.Verified[channel] = model.Verification{Contact: value, VerifiedAt: at}
+	return true, nil
+}
+
+func (m *memoryContacts) setEmail(userID, email string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contacts[userID].Email = email
+}
+
+// memoryState 内存中的令牌与发送频率状态，过期按 clock 判断
+type memoryState struct {
+	clock   *fakes.Clock
+	mu      sync.Mutex
+	expires map[string]time.Time
+	values  map[string]string
+	counts  map[string]int
+}
+
+func newMemoryState(clock *fakes.Clock) *memoryState {
+	return &memoryState{clock: clock, expires: make(map[string]time.Time), values: make(map[string]string), counts: make(map[string]int)}
+}
+
+// live 键存在且未过期时返回 true，过期的键被清除
+func (m *memoryState) live(key string) bool {
+	expiresAt, ok := m.expires[key]
+	if ok && !m.clock.Now().Before(expiresAt) {
+		delete(m.expires, key)
+		delete(m.values, key)
+		delete(m.counts, key)
+		return false
+	}
+	return ok
+}
+
+func (m *memoryState) Throttle(ctx context.Context, key string, cooldown time.Duration, limit int, window time.Duration) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock.Now()
+	if m.live(key + ":cooldown") {
+		return m.expires[key+":cooldown"].Sub(now), nil
+	}
+	countKey := key + ":count"
+	if !m.live(countKey) {
+		m.expires[countKey] = now.Add(window)
+	}
+	m.counts[countKey]++
+	if m.counts[countKey] > limit {
+		return m.expires[countKey].Sub(now), nil
+	}
+	m.expires[key+":cooldown"] = now.Add(cooldown)
+	return 0, nil
+}
+
+func (m *memoryState) Issue(ctx context.Context, key, tokenID string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key+":token"] = tokenID
+	m.expires[key+":token"] = m.clock.Now().Add(ttl)
+	return nil
+}
+
+func (m *memoryState) Consume(ctx context.Context, key, tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.live(key+":token") || m.values[key+":token"] != tokenID {
+		return false, nil
+	}
+	delete(m.values, key+":token")
+	delete(m.expires, key+":token")
+	return true, nil
+}
+
+// recordingNotifier 记录通知并渲染模板，模拟没有邮件驱动时不投递邮件
+type recordingNotifier struct {
+	templates *notify.Templates
+	email     bool
+	mu        sync.Mutex
+	sent      []*notify.Notification
+	bodies    []string
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *notify.Notification) ([]*notify.Delivery, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	channel := notification.Channels[0]
+	if channel == notify.ChannelEmail && !n.email {
+		return nil, nil
+	}
+	_, body, _, _, err := n.templates.Render(notification.Template, channel, "zh-CN", notification.Data)
+	if err != nil {
+		return nil, err
+	}
+	n.sent = append(n.sent, notification)
+	n.bodies = append(n.bodies, body)
+	return []*notify.Delivery{{Channel: channel, Status: notify.StatusPending}}, nil
+}
+
+func (n *recordingNotifier) lastToken() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.sent[len(n.sent)-1].Data["token"].(string)
+}
+
+func newTestService(t *testing.T) (*VerificationService, *memoryContacts, *recordingNotifier, *fakes.Clock) {
+	clock := fakes.NewClock(time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC))
+	contacts := &memoryContacts{contacts: map[string]*model.Contact{
+		"u1": {UserID: "u1", Email: "alice@example.com", Mobile: "13800138000", Verified: map[model.Channel]model.Verification{}},
+		"u2": {UserID: "u2", Mobile: "13900139000", Verified: map[model.Channel]model.Verification{}},
+	}}
+	notifier := &recordingNotifier{templates: notify.NewTemplates("zh-CN"), email: true}
+	config := DefaultConfig()
+	config.Secret = []byte("test-secret")
+	config.ResendLimit = 3
+	config.LinkURL = "https://app.example.com/verify"
+	verifications := NewVerificationService(contacts, newMemoryState(clock), notifier, config)
+	verifications.SetClock(clock)
+	require.NoError(t, verifications.RegisterTemplates(notifier.templates))
+	return verifications, contacts, notifier, clock
+}
+
+func TestVerificationService_SendAndConfirm(t *testing.T) {
+	ctx := context.Background()
+	verifications, contacts, notifier, clock := newTestService(t)
+
+	receipt, err := verifications.Send(ctx, "u1", model.ChannelEmail)
+	require.NoError(t, err)
+	assert.Equal(t, "a***@example.com", receipt.Contact)
+	assert.Equal(t, clock.Now().Add(30*time.Minute), receipt.ExpiresAt)
+	assert.True(t, notifier.sent[0].Critical)
+	assert.Contains(t, notifier.bodies[0], "https://app.example.com/verify?token=")
+	assert.NotContains(t, notifier.lastToken(), "alice", "令牌中不出现明文联系方式")
+
+	// 冷却时间内重新发送被拒绝，返回需等待的秒数
+	_, err = verifications.Send(ctx, "u1", model.ChannelEmail)
+	require.True(t, apperrors.IsCode(err, apperrors.CodeTooManyRequests))
+	assert.Equal(t, 60, apperrors.From(err).Details["retry_after"])
+
+	// 重新发送后旧令牌失效
+	first := notifier.lastToken()
+	clock.Advance(time.Minute)
+	_, err = verifications.Send(ctx, "u1", model.ChannelEmail)
+	require.NoError(t, err)
+	second := notifier.lastToken()
+	_, err = verifications.Confirm(ctx, first)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeVerificationInvalid))
+
+	// 被篡改的令牌签名校验失败
+	_, err = verifications.Confirm(ctx, strings.Replace(second, ".", "x.", 1))
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeVerificationInvalid))
+
+	confirmation, err := verifications.Confirm(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, model.ChannelEmail, confirmation.Channel)
+	assert.Equal(t, "u1", confirmation.UserID)
+
+	// 令牌只能使用一次，已验证后不再发送
+	_, err = verifications.Confirm(ctx, second)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeVerificationInvalid))
+	_, err = verifications.Send(ctx, "u1", model.ChannelEmail)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeConflict))
+
+	status, err := verifications.Status(ctx, "u1")
+	require.NoError(t, err)
+	assert.True(t, status.Email.Verified)
+	assert.False(t, status.Phone.Verified)
+	assert.Equal(t, "138****8000", status.Phone.Contact)
+
+	// 邮箱变更后不再视为已验证
+	contacts.setEmail("u1", "alice@example.org")
+	status, err = verifications.Status(ctx, "u1")
+	require.NoError(t, err)
+	assert.False(t, status.Email.Verified)
+}
+
+func TestVerificationService_RejectsStaleTokens(t *testing.T) {
+	ctx := context.Background()
+	verifications, contacts, notifier, clock := newTestService(t)
+
+	// 过期的令牌
+	_, err := verifications.Send(ctx, "u1", model.ChannelPhone)
+	require.NoError(t, err)
+	assert.Contains(t, notifier.bodies[0], notifier.lastToken())
+	clock.Advance(31 * time.Minute)
+	_, err = verifications.Confirm(ctx, notifier.lastToken())
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeVerificationInvalid))
+
+	// 签发后联系方式变更的令牌
+	_, err = verifications.Send(ctx, "u1", model.ChannelEmail)
+	require.NoError(t, err)
+	contacts.setEmail("u1", "mallory@example.com")
+	_, err = verifications.Confirm(ctx, notifier.lastToken())
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeVerificationInvalid))
+
+	// 窗口内超出次数限制
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Minute)
+		_, err = verifications.Send(ctx, "u1", model.ChannelPhone)
+		require.NoError(t, err)
+	}
+	clock.Advance(time.Minute)
+	_, err = verifications.Send(ctx, "u1", model.ChannelPhone)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeTooManyRequests))
+
+	// 未填写邮箱、邮件渠道不可用、未知用户
+	_, err = verifications.Send(ctx, "u2", model.ChannelEmail)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeInvalidParam))
+	notifier.email = false
+	_, err = verifications.Send(ctx, "u1", model.ChannelEmail)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeUnavailable))
+	_, err = verifications.Send(ctx, "u3", model.ChannelPhone)
+	assert.True(t, apperrors.IsCode(err, apperrors.CodeUserNotFound))
+}
+
+func TestVerificationService_OTPLoginVerifiesPhone(t *testing.T) {
+	verifications, _, _, _ := newTestService(t)
+	bus := domainevent.NewBus(nil)
+	require.NoError(t, verifications.Subscribe(bus))
+	domainevent.Publish(context.Background(), bus, domainevent.TopicUserLoggedIn, domainevent.Event{UserID: "u2", Data: map[string]string{"method": "otp"}})
+	domainevent.Publish(context.Background(), bus, domainevent.TopicUserLoggedIn, domainevent.Event{UserID: "u1", Data: map[string]string{"method": "oidc"}})
+	bus.Close()
+
+	status, err := verifications.Status(context.Background(), "u2")
+	require.NoError(t, err)
+	assert.True(t, status.Phone.Verified)
+	status, err = verifications.Status(context.Background(), "u1")
+	require.NoError(t, err)
+	assert.False(t, status.Phone.Verified)
+}
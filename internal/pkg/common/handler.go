@@ -1,11 +1,11 @@
 package handler
 
 import (
-	"mime/multipart"
 	"net/http"
 	"sync"
 	"user_crud_jwt/internal/pkg/uploader"
 	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/security"
 
 	"github.com/gin-gonic/gin"
 )
@@ -37,8 +37,20 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
+	// 挂载了 UploadGuard 时上传检查后的内容（图片已重新编码），否则上传原始文件
+	uploads := make([]func() (string, error), len(files))
+	for i, f := range files {
+		uploads[i] = func() (string, error) { return uploader.GlobalUploader.UploadFile(f) }
+	}
+	if checked := security.CheckedUploads(c); checked != nil {
+		uploads = make([]func() (string, error), len(checked))
+		for i, f := range checked {
+			uploads[i] = func() (string, error) { return uploader.GlobalUploader.UploadData(f.Filename, f.Data) }
+		}
+	}
+
 	// 结果数组，预分配大小
-	urls := make([]string, len(files))
+	urls := make([]string, len(uploads))
 	
 	// 使用 WaitGroup 和 Mutex 控制并发并保证顺序
 	var wg sync.WaitGroup
@@ -48,9 +60,9 @@ func UploadFile(c *gin.Context) {
 	// 限制并发数为 5，避免过多协程
 	sem := make(chan struct{}, 5)
 
-	for i, file := range files {
+	for i, upload := range uploads {
 		wg.Add(1)
-		go func(index int, f *multipart.FileHeader) {
+		go func(index int, upload func() (string, error)) {
 			defer wg.Done()
 			
 			// 获取信号量
@@ -62,7 +74,7 @@ func UploadFile(c *gin.Context) {
 				return
 			}
 
-			url, err := uploader.GlobalUploader.UploadFile(f)
+			url, err := upload()
 			if err != nil {
 				errOnce.Do(func() {
 					uploadErr = err
@@ -72,7 +84,7 @@ func UploadFile(c *gin.Context) {
 
 			// 直接按索引赋值，保证顺序
 			urls[index] = url
-		}(i, file)
+		}(i, upload)
 	}
 
 	wg.Wait()
@@ -19,6 +19,23 @@ type Config struct {
 	Push     PushConfig      `mapstructure:"push"`
 	Alipay   AlipayConfig    `mapstructure:"alipay"`
 	Wechat   WechatPayConfig `mapstructure:"wechat"`
+	OIDC     OIDCConfig      `mapstructure:"oidc"`
+	GRPC     GRPCConfig      `mapstructure:"grpc"`
+	Admin    AdminConfig     `mapstructure:"admin"`
+	Tenant   TenantConfig    `mapstructure:"tenant"`
+	WAF      WAFConfig       `mapstructure:"waf"`
+	Reports  ReportsConfig   `mapstructure:"reports"`
+	Profile  ProfilingConfig `mapstructure:"profiling"`
+	Limiter  LimiterConfig   `mapstructure:"concurrency"`
+	Priority PriorityConfig  `mapstructure:"priority"`
+	Preload  PreloadConfig   `mapstructure:"preload"`
+	Cache    CacheConfig     `mapstructure:"cache"`
+
+	Notify       NotifyConfig       `mapstructure:"notify"`
+	Verification VerificationConfig `mapstructure:"verification"`
+	TwoFactor    TwoFactorConfig    `mapstructure:"two_factor"`
+	Compliance   ComplianceConfig   `mapstructure:"compliance"`
+	Retention    RetentionConfig    `mapstructure:"retention"`
 }
 
 type ServerConfig struct {
@@ -34,6 +51,36 @@ type DatabaseConfig struct {
 	Port     string `mapstructure:"port"`
 	SSLMode  string `mapstructure:"sslmode"`
 	TimeZone string `mapstructure:"timezone"`
+	// PoolTuning 连接池自动调优：为空时关闭，suggest 只记录建议，apply 逐步修改并在等待增多时回滚
+	PoolTuning string `mapstructure:"pool_tuning"`
+	// 查询超时（秒）：0 使用默认值（读 5、写 10、报表 120），小于 0 不限制
+	ReadTimeout   int `mapstructure:"read_timeout"`
+	WriteTimeout  int `mapstructure:"write_timeout"`
+	ReportTimeout int `mapstructure:"report_timeout"`
+	// 只读副本与分片的连接串，为空时读取与分片表都使用主库
+	ReplicaDSNs []string `mapstructure:"replica_dsns"`
+	ShardDSNs   []string `mapstructure:"shard_dsns"`
+	// 读取默认可容忍的副本复制延迟（秒），超过时改读其他副本或主库；0 不限制。单次调用可用 database.WithMaxStaleness 覆盖
+	ReplicaMaxLagSeconds int `mapstructure:"replica_max_lag_seconds"`
+	// 表与索引膨胀分析间隔（分钟）：0 使用默认值 60，小于 0 关闭；小于 bloat_min_size_mb 的表与索引不分析
+	BloatCheckMinutes int `mapstructure:"bloat_check_minutes"`
+	BloatMinSizeMB    int `mapstructure:"bloat_min_size_mb"`
+	// 锁监控采样间隔（秒）：0 使用默认值 10，小于 0 关闭；等待超过 lock_wait_seconds 记为长时间等待
+	LockCheckSeconds int `mapstructure:"lock_check_seconds"`
+	LockWaitSeconds  int `mapstructure:"lock_wait_seconds"`
+	// 连接标签：各组件连接的 application_name 为 <application_name>-<组件>（api、warmup、jobs、migrate），便于在 pg_stat_activity 中区分来源
+	ApplicationName string `mapstructure:"application_name"`
+	// 各组件使用的数据库角色，按组件名配置，未配置的组件使用 user 与 password；授权迁移由 go run ./cmd/migrate -grants 生成
+	Roles map[string]DatabaseRoleConfig `mapstructure:"roles"`
+	// 行级安全：api 连接的每条语句写入请求的租户与用户，策略迁移由 go run ./cmd/migrate -rls 生成
+	RowSecurity bool `mapstructure:"row_security"`
+}
+
+// DatabaseRoleConfig 组件使用的数据库角色
+type DatabaseRoleConfig struct {
+	User         string `mapstructure:"user"`
+	Password     string `mapstructure:"password"`
+	MaxOpenConns int    `mapstructure:"max_open_conns"` // 组件连接池的最大连接数，0 使用默认值（api 100，其他 10）
 }
 
 type RedisConfig struct {
@@ -45,6 +92,13 @@ type RedisConfig struct {
 type JWTConfig struct {
 	Secret string `mapstructure:"secret"`
 	Expire int64  `mapstructure:"expire"` // 小时
+	// Algorithm 为空时只用 secret 以 HS256 签名；设为 HS256、RS256 或 EdDSA 时启用密钥轮换，
+	// 令牌头部带 kid，secret 仅用于验证启用前签发的令牌
+	Algorithm        string `mapstructure:"algorithm"`
+	RotationDays     int    `mapstructure:"rotation_days"`      // 轮换间隔，不大于 0 时为 7 天
+	GraceDays        int    `mapstructure:"grace_days"`         // 轮换后旧密钥继续用于验证的天数，不大于 0 时为 31 天，不得短于令牌有效期
+	KeyEncryptionKey string `mapstructure:"key_encryption_key"` // 非空时加密存储私钥
+	JWKS             bool   `mapstructure:"jwks"`               // 是否发布 /.well-known/jwks.json
 }
 
 type AppConfig struct {
@@ -85,6 +139,229 @@ type WechatPayConfig struct {
 	NotifyURL            string `mapstructure:"notify_url"`
 }
 
+// OIDCConfig 企业单点登录配置
+type OIDCConfig struct {
+	Providers   []OIDCProviderConfig `mapstructure:"providers"`
+	LinkByEmail bool                 `mapstructure:"link_by_email"` // 首次登录时按已验证邮箱关联已有账号，仅在信任身份提供方的邮箱验证时开启
+}
+
+// OIDCProviderConfig 单个 OIDC 身份提供方
+type OIDCProviderConfig struct {
+	Name         string         `mapstructure:"name"`   // 路由中使用的名称，如 okta、azure
+	Issuer       string         `mapstructure:"issuer"` // 用于发现 /.well-known/openid-configuration
+	ClientID     string         `mapstructure:"client_id"`
+	ClientSecret string         `mapstructure:"client_secret"`
+	RedirectURL  string         `mapstructure:"redirect_url"`
+	Scopes       []string       `mapstructure:"scopes"`
+	RoleClaim    string         `mapstructure:"role_claim"`   // 如 groups、roles
+	RoleMapping  map[string]int `mapstructure:"role_mapping"` // 声明值 -> 应用角色
+	DefaultRole  int            `mapstructure:"default_role"`
+	SyncRole     bool           `mapstructure:"sync_role"` // 每次登录按声明更新已有用户角色
+}
+
+// GRPCConfig gRPC 服务配置
+type GRPCConfig struct {
+	Port       string         `mapstructure:"port"`       // 为空时不启动 gRPC 服务
+	Reflection bool           `mapstructure:"reflection"` // 开启服务反射，供 grpcurl 等工具使用
+	APIKeys    []APIKeyConfig `mapstructure:"api_keys"`
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	APIKeys       []APIKeyConfig      `mapstructure:"api_keys"` // 运维工具（如 cachectl）以 X-API-Key 请求头调用 /admin 接口，role 需为 1
+	Impersonation ImpersonationConfig `mapstructure:"impersonation"`
+	Approvals     ApprovalsConfig     `mapstructure:"approvals"`
+}
+
+// ApprovalsConfig 敏感管理操作的双人审批配置
+type ApprovalsConfig struct {
+	Disabled          bool `mapstructure:"disabled"`           // 关闭审批，仅用于单人维护的开发环境
+	RequiredApprovals int  `mapstructure:"required_approvals"` // 发起人以外需要批准的人数，默认 1
+	ExpiryMinutes     int  `mapstructure:"expiry_minutes"`     // 审批单从发起到执行的有效期，默认 60 分钟
+	// Routes 需要审批的操作，格式为 "METHOD 路由"，为空时使用 approvals.DefaultRoutes
+	Routes []string `mapstructure:"routes"`
+	// Approvers 收到审批通知的用户 ID；不为空时只有其中的用户可以审批
+	Approvers []string `mapstructure:"approvers"`
+}
+
+// ImpersonationConfig 客服以用户身份登录排查问题的代登录会话配置
+type ImpersonationConfig struct {
+	DefaultMinutes int `mapstructure:"default_minutes"` // 未指定时长时的会话时长，默认 30 分钟
+	MaxMinutes     int `mapstructure:"max_minutes"`     // 会话时长上限，默认 120 分钟
+	// ForbiddenRoutes 代登录期间拒绝的路由前缀，为空时使用 middleware.DefaultImpersonationForbiddenRoutes
+	ForbiddenRoutes []string `mapstructure:"forbidden_routes"`
+}
+
+// APIKeyConfig 内部服务调用使用的 API Key
+type APIKeyConfig struct {
+	Name     string `mapstructure:"name"` // 调用方名称，用于日志与指标
+	Key      string `mapstructure:"key"`
+	Role     int    `mapstructure:"role"`     // 以该角色调用，1 为管理员
+	Priority string `mapstructure:"priority"` // 过载时的准入优先级（critical、normal、low），为空时按角色
+}
+
+// TenantConfig 多租户配置
+type TenantConfig struct {
+	Header     string `mapstructure:"header"`      // 指定租户的请求头
+	BaseDomain string `mapstructure:"base_domain"` // 按子域名解析租户时的根域名，为空时不按子域名解析
+	Default    string `mapstructure:"default"`     // 未指定租户的请求所属租户，为空时拒绝这类请求
+}
+
+// WAFConfig 请求检查规则配置
+type WAFConfig struct {
+	RulesFile           string `mapstructure:"rules_file"`           // JSON 规则集文件，为空时使用内置规则（只记录不拦截）
+	ReloadInterval      int    `mapstructure:"reload_interval"`      // 检查规则文件修改的间隔（秒）
+	ChallengeSecret     string `mapstructure:"challenge_secret"`     // 质询签名密钥，为空时随机生成；多实例部署时须一致
+	ChallengeDifficulty int    `mapstructure:"challenge_difficulty"` // 质询的工作量证明难度（前导零比特数）
+}
+
+// ReportsConfig 定时运维报告配置
+type ReportsConfig struct {
+	SMTPHost      string                 `mapstructure:"smtp_host"` // 为空时不能投递到邮件
+	SMTPPort      int                    `mapstructure:"smtp_port"`
+	SMTPUsername  string                 `mapstructure:"smtp_username"`
+	SMTPPassword  string                 `mapstructure:"smtp_password"`
+	SMTPFrom      string                 `mapstructure:"smtp_from"`      // 如 "Ops Reports <ops@example.com>"
+	WebhookSecret string                 `mapstructure:"webhook_secret"` // 投递到 Webhook 时的签名密钥
+	Schedules     []ReportScheduleConfig `mapstructure:"schedules"`      // 为空时不启用定时报告
+}
+
+// ReportScheduleConfig 单份定时报告
+type ReportScheduleConfig struct {
+	Name        string                  `mapstructure:"name"`
+	Source      string                  `mapstructure:"source"`       // cache、database 或 security
+	Schedule    string                  `mapstructure:"schedule"`     // daily、weekly 或 cron 表达式
+	PeriodHours int                     `mapstructure:"period_hours"` // 统计区间，daily、weekly 可不填
+	Recipients  []ReportRecipientConfig `mapstructure:"recipients"`
+}
+
+// ReportRecipientConfig 报告接收方
+type ReportRecipientConfig struct {
+	Channel string `mapstructure:"channel"` // email、slack 或 webhook
+	To      string `mapstructure:"to"`      // 邮箱、Slack Incoming Webhook 或 Webhook 地址
+	Format  string `mapstructure:"format"`  // html、text、markdown 或 json，为空时按渠道选择
+}
+
+// ProfilingConfig 自动性能剖析配置，/admin/debug/pprof 接口不受此配置影响
+type ProfilingConfig struct {
+	Dir                  string `mapstructure:"dir"`                    // 剖析文件存放目录，为空时不自动采集
+	GoroutineThreshold   int    `mapstructure:"goroutine_threshold"`    // goroutine 数超过该值时采集，0 表示不按此触发
+	SlowRequestThreshold int    `mapstructure:"slow_request_threshold"` // 一分钟内慢请求（超过 5 秒）达到该数量时采集，0 表示不按此触发
+	CooldownMinutes      int    `mapstructure:"cooldown_minutes"`       // 两次自动采集的最小间隔
+	RetentionDays        int    `mapstructure:"retention_days"`
+}
+
+// LimiterConfig 按路由组的自适应并发限制配置
+type LimiterConfig struct {
+	Enabled      bool    `mapstructure:"enabled"`
+	InitialLimit int     `mapstructure:"initial_limit"`
+	MinLimit     int     `mapstructure:"min_limit"`
+	MaxLimit     int     `mapstructure:"max_limit"`
+	Tolerance    float64 `mapstructure:"tolerance"` // 延迟超过基线的倍数视为过载
+}
+
+// PriorityConfig 按优先级的请求准入配置，各优先级的并发预算相互独立
+type PriorityConfig struct {
+	Enabled             bool                  `mapstructure:"enabled"`
+	CriticalConcurrency int                   `mapstructure:"critical_concurrency"` // 管理员与运维工具
+	NormalConcurrency   int                   `mapstructure:"normal_concurrency"`   // 已登录用户
+	LowConcurrency      int                   `mapstructure:"low_concurrency"`      // 匿名请求与按路由降级的请求
+	Routes              []PriorityRouteConfig `mapstructure:"routes"`
+}
+
+// PriorityRouteConfig 按路由前缀降低优先级，如批量导出
+type PriorityRouteConfig struct {
+	Prefix   string `mapstructure:"prefix"`
+	Priority string `mapstructure:"priority"`
+}
+
+// PreloadConfig 部署预热配置：开启门禁时新实例在预热任务组完成或超时前 /readyz 返回 503，不被负载均衡接入
+type PreloadConfig struct {
+	Gate           bool                `mapstructure:"gate"`
+	Sets           map[string][]string `mapstructure:"sets"`            // 任务组名到依次执行的预热任务名（见 /admin/cache/warmups）
+	OnStart        string              `mapstructure:"on_start"`        // 启动后自动执行的任务组，为空时由发布流程调用 POST /admin/warmup/preload
+	TimeoutSeconds int                 `mapstructure:"timeout_seconds"` // 预热与门禁的最长等待时间，0 为 120
+}
+
+// CacheConfig 缓存配置
+type CacheConfig struct {
+	// TTLProfiles 按键命名空间的缓存有效期，键为 default 或环境名（app.env），当前环境中的命名空间覆盖 default 中的同名项；
+	// 运行时可通过 /admin/cache/ttl 调整
+	TTLProfiles map[string][]CacheTTLProfileConfig `mapstructure:"ttl_profiles"`
+	// 容量分析间隔（分钟）：0 使用默认值 60，小于 0 关闭；每次随机采样 memory_sample_size 个键（0 为 2000）
+	MemoryAnalysisMinutes int `mapstructure:"memory_analysis_minutes"`
+	MemorySampleSize      int `mapstructure:"memory_sample_size"`
+	// 响应缓存本地层与 Redis 的后台对账间隔（秒），0 关闭；每轮随机比对最多 200 个本地键，每秒最多 100 个
+	LocalSyncSeconds int `mapstructure:"local_sync_seconds"`
+	// 登记了数据源的命名空间与数据库比对的 cron 表达式，为空时每 15 分钟一次，"off" 关闭；
+	// consistency_repair 开启时以数据库的值修复不一致的缓存
+	ConsistencySchedule string `mapstructure:"consistency_schedule"`
+	ConsistencyRepair   bool   `mapstructure:"consistency_repair"`
+}
+
+// CacheTTLProfileConfig 命名空间的缓存有效期，0 表示使用代码中的默认值
+type CacheTTLProfileConfig struct {
+	Namespace       string `mapstructure:"namespace"`         // 按冒号分段匹配键的前缀，如 rbac、graphql:coupon
+	TTLSeconds      int    `mapstructure:"ttl_seconds"`       // Redis
+	LocalTTLSeconds int    `mapstructure:"local_ttl_seconds"` // 多级缓存的本地缓存
+}
+
+// NotifyConfig 面向用户的通知投递配置，短信服务商未接入前短信只记录日志
+type NotifyConfig struct {
+	SMTPHost     string `mapstructure:"smtp_host"` // 为空时不投递邮件
+	SMTPPort     int    `mapstructure:"smtp_port"`
+	SMTPUsername string `mapstructure:"smtp_username"`
+	SMTPPassword string `mapstructure:"smtp_password"`
+	SMTPFrom     string `mapstructure:"smtp_from"` // 如 "Go Progres <noreply@example.com>"
+}
+
+// VerificationConfig 邮箱与手机号验证配置
+type VerificationConfig struct {
+	Secret          string `mapstructure:"secret"`            // 验证令牌签名密钥，为空时随机生成；多实例部署时须一致
+	LinkURL         string `mapstructure:"link_url"`          // 邮件中的确认链接地址，令牌以 token 参数附加；为空时只发送令牌
+	TokenTTLMinutes int    `mapstructure:"token_ttl_minutes"` // 令牌有效期
+	ResendCooldown  int    `mapstructure:"resend_cooldown"`   // 两次发送的最小间隔（秒）
+	ResendLimit     int    `mapstructure:"resend_limit"`      // 每小时最多发送次数
+}
+
+// TwoFactorConfig 两步验证配置
+type TwoFactorConfig struct {
+	Issuer         string `mapstructure:"issuer"`          // 验证器应用中显示的发行方，为空时为 go-progres
+	EncryptionKey  string `mapstructure:"encryption_key"`  // 非空时加密存储 TOTP 密钥
+	RequiredRoles  []int  `mapstructure:"required_roles"`  // 必须开启两步验证的角色，为空时为管理员
+	MaxFailures    int    `mapstructure:"max_failures"`    // 连续失败多少次后锁定，不大于 0 时为 5
+	LockoutMinutes int    `mapstructure:"lockout_minutes"` // 锁定时长，不大于 0 时为 15 分钟
+}
+
+// ComplianceConfig 用户数据导出与账号注销配置
+type ComplianceConfig struct {
+	GracePeriodDays     int `mapstructure:"grace_period_days"`     // 注销冷静期，期间可撤销
+	ExportRetentionDays int `mapstructure:"export_retention_days"` // 导出归档可下载的天数
+	ExportCooldownHours int `mapstructure:"export_cooldown_hours"` // 两次导出申请的最小间隔
+}
+
+// RetentionConfig 数据保留策略配置，policies 按 dataset 覆盖内置策略或追加新数据集
+type RetentionConfig struct {
+	Schedule   string                  `mapstructure:"schedule"`    // 未单独配置的策略使用的 cron 表达式
+	BatchSize  int                     `mapstructure:"batch_size"`  // 单条语句清理的行数
+	MaxBatches int                     `mapstructure:"max_batches"` // 单次执行的批次上限
+	Policies   []RetentionPolicyConfig `mapstructure:"policies"`
+}
+
+// RetentionPolicyConfig 单个数据集的保留策略，未填写的项沿用内置策略
+type RetentionPolicyConfig struct {
+	Dataset    string `mapstructure:"dataset"`
+	Table      string `mapstructure:"table"`       // 新数据集必填
+	TimeColumn string `mapstructure:"time_column"` // 新数据集必填
+	Filter     string `mapstructure:"filter"`      // SQL 条件，如 "type = 'slow_request'"
+	MaxAgeDays int    `mapstructure:"max_age_days"`
+	MaxRows    int64  `mapstructure:"max_rows"`
+	Action     string `mapstructure:"action"` // delete 或 archive
+	Schedule   string `mapstructure:"schedule"`
+	BatchSize  int    `mapstructure:"batch_size"`
+	Disabled   bool   `mapstructure:"disabled"` // 停用内置策略
+}
+
 var GlobalConfig Config
 
 // Validate 验证配置
@@ -133,11 +410,43 @@ func LoadConfig() {
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.mode", "debug")
 	viper.SetDefault("jwt.expire", 24)
+	viper.SetDefault("jwt.rotation_days", 7)
+	viper.SetDefault("jwt.grace_days", 31)
+	viper.SetDefault("database.application_name", "user_crud_jwt")
 	viper.SetDefault("redis.addr", "localhost:6379")
 	viper.SetDefault("redis.db", 0)
 	viper.SetDefault("app.env", "dev")
 	viper.SetDefault("app.debug", true)
 	viper.SetDefault("app.test_otp_code", "123456")
+	viper.SetDefault("grpc.reflection", true)
+	viper.SetDefault("tenant.header", "X-Tenant-ID")
+	viper.SetDefault("tenant.default", "default")
+	viper.SetDefault("waf.reload_interval", 10)
+	viper.SetDefault("waf.challenge_difficulty", 16)
+	viper.SetDefault("reports.smtp_port", 587)
+	viper.SetDefault("notify.smtp_port", 587)
+	viper.SetDefault("verification.token_ttl_minutes", 30)
+	viper.SetDefault("verification.resend_cooldown", 60)
+	viper.SetDefault("verification.resend_limit", 5)
+	viper.SetDefault("compliance.grace_period_days", 14)
+	viper.SetDefault("compliance.export_retention_days", 7)
+	viper.SetDefault("compliance.export_cooldown_hours", 24)
+	viper.SetDefault("retention.schedule", "30 3 * * *")
+	viper.SetDefault("retention.batch_size", 5000)
+	viper.SetDefault("retention.max_batches", 200)
+	viper.SetDefault("profiling.goroutine_threshold", 10000)
+	viper.SetDefault("profiling.slow_request_threshold", 5)
+	viper.SetDefault("profiling.cooldown_minutes", 10)
+	viper.SetDefault("profiling.retention_days", 7)
+	viper.SetDefault("concurrency.enabled", true)
+	viper.SetDefault("concurrency.initial_limit", 100)
+	viper.SetDefault("concurrency.min_limit", 10)
+	viper.SetDefault("concurrency.max_limit", 2000)
+	viper.SetDefault("concurrency.tolerance", 2)
+	viper.SetDefault("priority.enabled", true)
+	viper.SetDefault("priority.critical_concurrency", 32)
+	viper.SetDefault("priority.normal_concurrency", 512)
+	viper.SetDefault("priority.low_concurrency", 64)
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Printf("Warning: Config file not found, using defaults or env vars: %v", err)
@@ -0,0 +1,64 @@
+// Package domainevent 模块间共享的领域事件总线：业务模块在状态变化后发布事件，
+// 活动记录等关注方异步订阅，发布方不依赖订阅方
+package domainevent
+
+import (
+	"context"
+	"log"
+	"time"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/events"
+)
+
+// 领域事件主题
+const (
+	TopicUserLoggedIn        = "user.logged_in"
+	TopicUserProfileUpdated  = "user.profile_updated"
+	TopicUserPasswordChanged = "user.password_changed"
+	TopicCouponClaimed       = "coupon.claimed"
+)
+
+// Topics 所有领域事件主题
+var Topics = []string{TopicUserLoggedIn, TopicUserProfileUpdated, TopicUserPasswordChanged, TopicCouponClaimed}
+
+// Event 与用户相关的领域事件，事件类型即总线主题
+type Event struct {
+	UserID     string            `json:"user_id"`
+	TenantID   string            `json:"tenant_id,omitempty"`
+	IP         string            `json:"ip,omitempty"`
+	UserAgent  string            `json:"user_agent,omitempty"`
+	Data       map[string]string `json:"data,omitempty"` // 事件相关的业务字段，如优惠券 ID、修改的字段
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// Bus 领域事件总线
+type Bus = events.Bus[Event]
+
+// NewBus 创建领域事件总线，config 为 nil 时使用默认配置
+func NewBus(config *events.Config) *Bus {
+	return events.NewBus[Event]("domain", config)
+}
+
+// Publish 发布领域事件：未指定的租户与客户端信息从 ctx 补全，以用户 ID 为 key 保证同一用户的事件按序处理。
+// bus 为 nil 时不发布；发布失败只记录日志，不影响业务操作的结果
+func Publish(ctx context.Context, bus *Bus, topic string, event Event) {
+	if bus == nil {
+		return
+	}
+	if event.TenantID == "" {
+		event.TenantID = ctxutil.TenantID(ctx)
+	}
+	client := ctxutil.ClientFrom(ctx)
+	if event.IP == "" {
+		event.IP = client.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if err := bus.Publish(ctx, topic, event.UserID, event); err != nil {
+		log.Printf("Failed to publish %s event for user %s: %v", topic, event.UserID, err)
+	}
+}
@@ -0,0 +1,52 @@
+package domainevent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+	"user_crud_jwt/pkg/outbox"
+)
+
+// outboxConsumer 发件箱去重时使用的消费方名称
+const outboxConsumer = "domainevent"
+
+// NewOutboxEvent 将领域事件写成发件箱事件，在业务事务中以 outbox.Enqueue 写入，提交后由投递器发布到总线。
+// 以用户 ID 为 key，同一用户的事件按写入顺序投递；dedupeKey 应取自业务记录，重试写入时不会重复
+func NewOutboxEvent(topic, dedupeKey string, event Event) (*outbox.Event, error) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	return outbox.NewEvent(topic, event.UserID, dedupeKey, event)
+}
+
+// OutboxPublisher 将发件箱中的领域事件发布到总线，返回错误时事件留在发件箱中重试。
+// 发布成功但标记失败时事件会再次投递，deduper 不为 nil 时丢弃已发布过的事件
+func OutboxPublisher(bus *Bus, deduper *outbox.Deduper) outbox.Publisher {
+	return outbox.PublisherFunc(func(ctx context.Context, event *outbox.Event) error {
+		var payload Event
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to decode domain event %d: %w", event.ID, err)
+		}
+		if deduper != nil {
+			first, err := deduper.FirstSeen(ctx, outboxConsumer, event.DedupeKey)
+			if err != nil {
+				return err
+			}
+			if !first {
+				return nil
+			}
+		}
+
+		if err := bus.Publish(ctx, event.Topic, event.Key, payload); err != nil {
+			if deduper != nil {
+				if forgetErr := deduper.Forget(ctx, outboxConsumer, event.DedupeKey); forgetErr != nil {
+					log.Printf("Failed to forget domain event %s: %v", event.DedupeKey, forgetErr)
+				}
+			}
+			return fmt.Errorf("failed to publish %s event: %w", event.Topic, err)
+		}
+		return nil
+	})
+}
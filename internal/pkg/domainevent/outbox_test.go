@@ -0,0 +1,36 @@
+package domainevent
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/events"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutboxPublisher 发件箱中的领域事件按原主题与用户 key 发布到总线；总线关闭后返回错误，事件留待重投
+func TestOutboxPublisher(t *testing.T) {
+	bus := NewBus(nil)
+	var received []events.Event[Event]
+	require.NoError(t, bus.Subscribe("test", func(ctx context.Context, event events.Event[Event]) error {
+		received = append(received, event)
+		return nil
+	}))
+
+	event, err := NewOutboxEvent(TopicCouponClaimed, "coupon.claimed:uc1", Event{UserID: "u1", TenantID: "acme", Data: map[string]string{"coupon_id": "c1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "u1", event.Key)
+
+	publisher := OutboxPublisher(bus, nil)
+	require.NoError(t, publisher.Publish(context.Background(), event))
+	require.Len(t, received, 1)
+	assert.Equal(t, TopicCouponClaimed, received[0].Topic)
+	assert.Equal(t, "u1", received[0].Key)
+	assert.Equal(t, "acme", received[0].Payload.TenantID)
+	assert.Equal(t, "c1", received[0].Payload.Data["coupon_id"])
+	assert.False(t, received[0].Payload.OccurredAt.IsZero())
+
+	bus.Close()
+	assert.Error(t, publisher.Publish(context.Background(), event))
+}
@@ -0,0 +1,176 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/utils"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 鉴权使用的 metadata 键
+const (
+	MetadataAuthorization = "authorization" // Bearer <jwt>
+	MetadataAPIKey        = "x-api-key"
+	// MetadataRefreshedToken 令牌签发后角色或权限发生变化时，响应头中重新签发的令牌
+	MetadataRefreshedToken = "x-refreshed-token"
+)
+
+// roleAdmin 管理员角色，与用户模块保持一致
+const roleAdmin = 1
+
+// Principal 调用方身份
+type Principal struct {
+	UserID   string // JWT 调用方的用户 ID，API Key 调用方为空
+	Role     int
+	APIKey   string // API Key 调用方名称
+	TenantID string // 令牌所属的租户，没有租户声明的令牌为默认租户，API Key 调用方为空
+}
+
+// IsAdmin 是否为管理员
+func (p *Principal) IsAdmin() bool {
+	return p.Role == roleAdmin
+}
+
+// IsService 是否为 API Key 调用的内部服务
+func (p *Principal) IsService() bool {
+	return p.APIKey != ""
+}
+
+// Name 用于日志的调用方标识
+func (p *Principal) Name() string {
+	if p.IsService() {
+		return "apikey:" + p.APIKey
+	}
+	return "user:" + p.UserID
+}
+
+type principalKey struct{}
+
+// WithPrincipal 将调用方身份写入上下文
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext 获取调用方身份，公开方法中可能为 nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// RequireAdmin 要求管理员身份
+func RequireAdmin(ctx context.Context) error {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil || !principal.IsAdmin() {
+		return status.Error(codes.PermissionDenied, "admin role required")
+	}
+	return nil
+}
+
+// ResolveUserID 确定操作的目标用户：JWT 调用方只能操作本人，
+// API Key 调用方必须显式指定 requested
+func ResolveUserID(ctx context.Context, requested string) (string, error) {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return "", status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if principal.IsService() {
+		if requested == "" {
+			return "", status.Error(codes.InvalidArgument, "user_id is required for api key callers")
+		}
+		return requested, nil
+	}
+	if requested != "" && requested != principal.UserID {
+		return "", status.Error(codes.PermissionDenied, "cannot act on behalf of another user")
+	}
+	return principal.UserID, nil
+}
+
+// apiKey 已配置的 API Key，仅保存摘要
+type apiKey struct {
+	name   string
+	digest [sha256.Size]byte
+	role   int
+}
+
+// authenticator 校验 JWT 与 API Key
+type authenticator struct {
+	apiKeys []apiKey
+}
+
+func newAuthenticator(keys []config.APIKeyConfig) *authenticator {
+	a := &authenticator{}
+	for _, k := range keys {
+		if k.Key == "" {
+			continue
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: k.Name, digest: sha256.Sum256([]byte(k.Key)), role: k.Role})
+	}
+	return a
+}
+
+// authenticate 从 metadata 解析调用方身份，API Key 优先于 JWT
+func (a *authenticator) authenticate(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(MetadataAPIKey); len(values) > 0 {
+		digest := sha256.Sum256([]byte(values[0]))
+		// 逐个比较不提前返回，避免通过耗时推断匹配位置
+		var matched *apiKey
+		for i := range a.apiKeys {
+			if subtle.ConstantTimeCompare(digest[:], a.apiKeys[i].digest[:]) == 1 {
+				matched = &a.apiKeys[i]
+			}
+		}
+		if matched == nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		return &Principal{Role: matched.role, APIKey: matched.name}, nil
+	}
+
+	values := md.Get(MetadataAuthorization)
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+	}
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+	claims, err := utils.ParseTokenContext(ctx, parts[1])
+	if errors.Is(err, utils.ErrTokenRevoked) {
+		return nil, status.Error(codes.Unauthenticated, "token has been revoked")
+	}
+	if errors.Is(err, utils.ErrPermissionsChanged) {
+		return nil, status.Error(codes.Unauthenticated, "permissions have changed, please log in again")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired token")
+	}
+	// 代登录的操作须经 HTTP 接口逐条审计，gRPC 不接受代登录令牌
+	if claims.Impersonation != nil {
+		return nil, status.Error(codes.PermissionDenied, "impersonation tokens are not accepted over grpc")
+	}
+	if claims.Refreshed {
+		reissueToken(ctx, claims)
+	}
+	return &Principal{UserID: claims.UserID, Role: claims.Role, TenantID: claims.TenantID}, nil
+}
+
+// reissueToken 按重新解析的角色签发新令牌并写入响应头，失败时下次调用重新解析
+func reissueToken(ctx context.Context, claims *utils.Claims) {
+	token, _, err := utils.ReissueToken(claims)
+	if err == nil {
+		err = grpc.SetHeader(ctx, metadata.Pairs(MetadataRefreshedToken, token))
+	}
+	if err != nil {
+		log.Printf("Failed to reissue token for user %s: %v", claims.UserID, err)
+	}
+}
@@ -0,0 +1,250 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// revokedUsers 吊销指定用户的全部令牌
+type revokedUsers map[string]bool
+
+func (r revokedUsers) IsRevoked(ctx context.Context, claims *utils.Claims) bool {
+	return r[claims.UserID]
+}
+
+// stubPermissions 固定的权限版本与角色
+type stubPermissions struct {
+	versions map[string]int64
+	roles    map[string]int
+}
+
+func (s *stubPermissions) PermissionsVersion(ctx context.Context, userID string) (int64, error) {
+	return s.versions[userID], nil
+}
+
+func (s *stubPermissions) CurrentRole(ctx context.Context, userID string) (int, error) {
+	role, ok := s.roles[userID]
+	if !ok {
+		return 0, errors.New("user not found")
+	}
+	return role, nil
+}
+
+// setupTokens 使用测试密钥签发令牌，并在测试结束后恢复全局的吊销与权限版本检查
+func setupTokens(t *testing.T) {
+	t.Helper()
+	previous := config.GlobalConfig.JWT.Secret
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	t.Cleanup(func() {
+		config.GlobalConfig.JWT.Secret = previous
+		utils.SetTokenRevocations()
+		utils.SetPermissionVersions(nil)
+		utils.SetRoleSource(nil)
+	})
+}
+
+func bearer(t *testing.T, token string) context.Context {
+	t.Helper()
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAuthorization, "Bearer "+token))
+}
+
+func assertCode(t *testing.T, err error, code codes.Code, msg string) {
+	t.Helper()
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	require.True(t, ok, "%v is not a status error", err)
+	assert.Equal(t, code, st.Code())
+	assert.Contains(t, st.Message(), msg)
+}
+
+// TestAuthenticate 缺少或格式错误的凭证、已吊销的令牌、权限变化后无法重新解析的令牌与代登录令牌被拒绝；
+// API Key 优先于 JWT
+func TestAuthenticate(t *testing.T) {
+	setupTokens(t)
+	auth := newAuthenticator([]config.APIKeyConfig{
+		{Name: "billing", Key: "billing-secret", Role: 1},
+		{Name: "disabled"},
+	})
+
+	_, err := auth.authenticate(context.Background())
+	assertCode(t, err, codes.Unauthenticated, "authorization metadata is required")
+	_, err = auth.authenticate(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAuthorization, "Token abc")))
+	assertCode(t, err, codes.Unauthenticated, "invalid authorization metadata format")
+	_, err = auth.authenticate(bearer(t, "garbage"))
+	assertCode(t, err, codes.Unauthenticated, "invalid or expired token")
+
+	token, _, err := utils.GenerateTenantToken("acme", "u1", 0, nil)
+	require.NoError(t, err)
+	principal, err := auth.authenticate(bearer(t, token))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{UserID: "u1", Role: 0, TenantID: "acme"}, principal)
+	assert.False(t, principal.IsService())
+	assert.Equal(t, "user:u1", principal.Name())
+
+	// API Key
+	principal, err = auth.authenticate(metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		MetadataAPIKey, "billing-secret", MetadataAuthorization, "Bearer "+token)))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Role: 1, APIKey: "billing"}, principal, "api keys take precedence over jwt")
+	assert.Equal(t, "apikey:billing", principal.Name())
+	_, err = auth.authenticate(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAPIKey, "wrong")))
+	assertCode(t, err, codes.Unauthenticated, "invalid api key")
+	_, err = auth.authenticate(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAPIKey, "")))
+	assertCode(t, err, codes.Unauthenticated, "invalid api key")
+
+	// 吊销
+	utils.SetTokenRevocations(revokedUsers{"u1": true})
+	_, err = auth.authenticate(bearer(t, token))
+	assertCode(t, err, codes.Unauthenticated, "token has been revoked")
+	utils.SetTokenRevocations()
+
+	// 权限版本落后且无法重新解析角色
+	utils.SetPermissionVersions(&stubPermissions{versions: map[string]int64{"u1": 1}})
+	_, err = auth.authenticate(bearer(t, token))
+	assertCode(t, err, codes.Unauthenticated, "permissions have changed")
+	utils.SetRoleSource(&stubPermissions{roles: map[string]int{}})
+	_, err = auth.authenticate(bearer(t, token))
+	assertCode(t, err, codes.Unauthenticated, "permissions have changed")
+	utils.SetPermissionVersions(nil)
+
+	// 代登录令牌
+	impersonation, err := utils.GenerateImpersonationToken("acme", "u1", 0, utils.Impersonation{SessionID: "s1", ActorID: "admin"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = auth.authenticate(bearer(t, impersonation))
+	assertCode(t, err, codes.PermissionDenied, "impersonation tokens are not accepted over grpc")
+}
+
+// TestAuthorize_Context 身份写入上下文：用户令牌带租户与所有者，没有租户声明的属于默认租户，
+// 管理员可访问全部所有者，API Key 按系统调用；公开方法的匿名调用属于默认租户
+func TestAuthorize_Context(t *testing.T) {
+	setupTokens(t)
+	s := NewServer(config.GRPCConfig{APIKeys: []config.APIKeyConfig{{Name: "billing", Key: "billing-secret"}}})
+	s.AllowUnauthenticated("/pkg.Public/")
+
+	legacy, _, err := utils.GenerateToken("u1", 0)
+	require.NoError(t, err)
+	ctx, err := s.authorize(bearer(t, legacy), "/pkg.Private/Get")
+	require.NoError(t, err)
+	assert.Equal(t, ctxutil.DefaultTenantID, ctxutil.TenantID(ctx))
+	assert.Equal(t, "u1", ctxutil.UserID(ctx))
+	assert.False(t, ctxutil.AllOwners(ctx))
+	assert.Equal(t, "u1", PrincipalFromContext(ctx).UserID)
+
+	admin, _, err := utils.GenerateTenantToken("acme", "root", roleAdmin, nil)
+	require.NoError(t, err)
+	ctx, err = s.authorize(bearer(t, admin), "/pkg.Private/Get")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", ctxutil.TenantID(ctx))
+	assert.True(t, ctxutil.AllOwners(ctx))
+
+	ctx, err = s.authorize(metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataAPIKey, "billing-secret")), "/pkg.Private/Get")
+	require.NoError(t, err)
+	assert.Empty(t, ctxutil.TenantID(ctx))
+	assert.Empty(t, ctxutil.UserID(ctx))
+	assert.True(t, PrincipalFromContext(ctx).IsService())
+
+	_, err = s.authorize(context.Background(), "/pkg.Private/Get")
+	assertCode(t, err, codes.Unauthenticated, "authorization metadata is required")
+	ctx, err = s.authorize(context.Background(), "/pkg.Public/Get")
+	require.NoError(t, err)
+	assert.Nil(t, PrincipalFromContext(ctx))
+	assert.Equal(t, ctxutil.DefaultTenantID, ctxutil.TenantID(ctx))
+}
+
+// startServer 在内存连接上启动服务，返回客户端连接
+func startServer(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go s.server.Serve(lis)
+	t.Cleanup(s.server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestServer_AdminOnlyMethods 管理员方法拒绝匿名与普通用户，管理员与管理员角色的 API Key 可以调用；
+// 健康检查无需鉴权；权限变化后重新解析的角色立即生效并下发新令牌
+func TestServer_AdminOnlyMethods(t *testing.T) {
+	setupTokens(t)
+	s := NewServer(config.GRPCConfig{APIKeys: []config.APIKeyConfig{
+		{Name: "ops", Key: "ops-secret", Role: roleAdmin},
+		{Name: "reader", Key: "reader-secret"},
+	}})
+	rbac := security.NewRBAC(fakes.NewCache(nil))
+	usercrudv1.RegisterPermissionServiceServer(s.Registrar(), NewPermissionServer(rbac))
+	conn := startServer(t, s)
+	client := usercrudv1.NewPermissionServiceClient(conn)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), MetadataAuthorization, "Bearer "+token)
+	}
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), MetadataAPIKey, key)
+	}
+	assign := &usercrudv1.AssignRoleRequest{UserId: "u2", Role: string(security.RoleModerator)}
+
+	_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	_, err = client.AssignRole(context.Background(), assign)
+	assertCode(t, err, codes.Unauthenticated, "authorization metadata is required")
+
+	user, _, err := utils.GenerateToken("u1", 0)
+	require.NoError(t, err)
+	_, err = client.AssignRole(withToken(user), assign)
+	assertCode(t, err, codes.PermissionDenied, "admin role required")
+	_, err = client.AssignRole(withKey("reader-secret"), assign)
+	assertCode(t, err, codes.PermissionDenied, "admin role required")
+
+	admin, _, err := utils.GenerateToken("root", roleAdmin)
+	require.NoError(t, err)
+	_, err = client.AssignRole(withToken(admin), assign)
+	require.NoError(t, err)
+	_, err = client.AssignRole(withKey("ops-secret"), assign)
+	require.NoError(t, err)
+	role, err := rbac.GetUserRole(context.Background(), "u2")
+	require.NoError(t, err)
+	assert.Equal(t, security.RoleModerator, role)
+
+	// 已吊销的管理员令牌
+	utils.SetTokenRevocations(revokedUsers{"root": true})
+	_, err = client.AssignRole(withToken(admin), assign)
+	assertCode(t, err, codes.Unauthenticated, "token has been revoked")
+	utils.SetTokenRevocations()
+
+	// u1 在令牌签发后被提升为管理员
+	utils.SetPermissionVersions(&stubPermissions{versions: map[string]int64{"u1": 2}})
+	utils.SetRoleSource(&stubPermissions{roles: map[string]int{"u1": roleAdmin}})
+	var header metadata.MD
+	_, err = client.AssignRole(withToken(user), assign, grpc.Header(&header))
+	require.NoError(t, err)
+	refreshed := header.Get(MetadataRefreshedToken)
+	require.Len(t, refreshed, 1)
+	claims, err := utils.ParseToken(refreshed[0])
+	require.NoError(t, err)
+	assert.Equal(t, roleAdmin, claims.Role)
+	assert.Equal(t, int64(2), claims.PermissionsVersion)
+}
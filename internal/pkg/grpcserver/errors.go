@@ -0,0 +1,78 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"user_crud_jwt/pkg/apperrors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain ErrorInfo 中的错误域
+const errorDomain = "user_crud_jwt"
+
+// ToStatus 将应用错误转换为 gRPC 状态，错误码放入 ErrorInfo.Reason，
+// 与 HTTP 响应中的 code 字段一致
+func ToStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	appErr := apperrors.From(err)
+	st := status.New(codeFromHTTP(appErr.HTTPStatus()), appErr.LocalizedMessage(language(ctx)))
+	if detailed, derr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason: strconv.Itoa(int(appErr.Code)),
+		Domain: errorDomain,
+	}); derr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// codeFromHTTP HTTP 状态码映射为 gRPC 状态码
+func codeFromHTTP(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		if httpStatus >= 400 && httpStatus < 500 {
+			return codes.FailedPrecondition
+		}
+		return codes.Internal
+	}
+}
+
+// language 按 accept-language metadata 选择提示语言
+func language(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("accept-language"); len(values) > 0 && strings.HasPrefix(strings.ToLower(values[0]), apperrors.LangZH) {
+		return apperrors.LangZH
+	}
+	return apperrors.LangEN
+}
@@ -0,0 +1,95 @@
+package grpcserver
+
+import (
+	"context"
+	usercrudv1 "user_crud_jwt/api/gen/usercrud/v1"
+	"user_crud_jwt/pkg/security"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PermissionServer 权限服务的 gRPC 实现
+type PermissionServer struct {
+	usercrudv1.UnimplementedPermissionServiceServer
+	rbac *security.RBAC
+}
+
+// NewPermissionServer 创建权限服务
+func NewPermissionServer(rbac *security.RBAC) *PermissionServer {
+	return &PermissionServer{rbac: rbac}
+}
+
+// CheckPermission 校验权限，用户不存在或校验出错时按无权限处理
+func (s *PermissionServer) CheckPermission(ctx context.Context, req *usercrudv1.CheckPermissionRequest) (*usercrudv1.CheckPermissionResponse, error) {
+	if req.GetUserId() == "" || len(req.GetPermissions()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id and permissions are required")
+	}
+	if err := s.requireSelfOrService(ctx, req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	permissions := make([]security.Permission, len(req.GetPermissions()))
+	for i, p := range req.GetPermissions() {
+		permissions[i] = security.Permission(p)
+	}
+
+	var allowed bool
+	var err error
+	if req.GetRequireAll() {
+		allowed, err = s.rbac.HasAllPermissions(ctx, req.GetUserId(), permissions)
+	} else {
+		allowed, err = s.rbac.HasAnyPermission(ctx, req.GetUserId(), permissions)
+	}
+	return &usercrudv1.CheckPermissionResponse{Allowed: err == nil && allowed}, nil
+}
+
+// GetUserPermissions 查询用户角色与权限
+func (s *PermissionServer) GetUserPermissions(ctx context.Context, req *usercrudv1.GetUserPermissionsRequest) (*usercrudv1.GetUserPermissionsResponse, error) {
+	if err := s.requireSelfOrService(ctx, req.GetUserId()); err != nil {
+		return nil, err
+	}
+
+	role, err := s.rbac.GetUserRole(ctx, req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	permissions, err := s.rbac.GetUserPermissions(ctx, req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	resp := &usercrudv1.GetUserPermissionsResponse{Role: string(role)}
+	for _, p := range permissions {
+		resp.Permissions = append(resp.Permissions, string(p))
+	}
+	return resp, nil
+}
+
+// AssignRole 分配角色，仅管理员可调用
+func (s *PermissionServer) AssignRole(ctx context.Context, req *usercrudv1.AssignRoleRequest) (*usercrudv1.AssignRoleResponse, error) {
+	if err := RequireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	role := security.Role(req.GetRole())
+	if req.GetUserId() == "" || len(s.rbac.GetRolePermissions(role)) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id and a known role are required")
+	}
+
+	if err := s.rbac.AssignRole(ctx, req.GetUserId(), role); err != nil {
+		return nil, err
+	}
+	return &usercrudv1.AssignRoleResponse{}, nil
+}
+
+// requireSelfOrService 普通用户只能查询本人，管理员与内部服务不限
+func (s *PermissionServer) requireSelfOrService(ctx context.Context, userID string) error {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if principal.IsService() || principal.IsAdmin() || principal.UserID == userID {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "cannot query another user's permissions")
+}
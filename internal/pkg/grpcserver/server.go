@@ -0,0 +1,209 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/metrics"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// Server gRPC 服务，与 HTTP 共用各模块的服务层。
+// 拦截器顺序：panic 恢复 → 指标 → 鉴权 → 错误转换
+type Server struct {
+	server           *grpc.Server
+	health           *health.Server
+	auth             *authenticator
+	public           map[string]bool
+	metricsCollector *metrics.MetricsCollector
+	mu               sync.RWMutex
+}
+
+// NewServer 创建 gRPC 服务，健康检查与反射服务无需鉴权
+func NewServer(cfg config.GRPCConfig, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		health:           health.NewServer(),
+		auth:             newAuthenticator(cfg.APIKeys),
+		public:           make(map[string]bool),
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.recoveryUnary, s.metricsUnary, s.authUnary, s.errorUnary),
+		grpc.ChainStreamInterceptor(s.recoveryStream, s.metricsStream, s.authStream),
+	)
+	s.server = grpc.NewServer(opts...)
+
+	healthpb.RegisterHealthServer(s.server, s.health)
+	s.AllowUnauthenticated("/grpc.health.v1.Health/")
+	if cfg.Reflection {
+		reflection.Register(s.server)
+		s.AllowUnauthenticated("/grpc.reflection.")
+	}
+	return s
+}
+
+// Registrar 供各模块注册服务
+func (s *Server) Registrar() grpc.ServiceRegistrar {
+	return s.server
+}
+
+// AllowUnauthenticated 标记无需鉴权的方法，参数为完整方法名或以 / 、. 结尾的前缀
+func (s *Server) AllowUnauthenticated(methods ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, method := range methods {
+		s.public[method] = true
+	}
+}
+
+func (s *Server) isPublic(fullMethod string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.public[fullMethod] {
+		return true
+	}
+	for prefix := range s.public {
+		if (strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, ".")) && strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListenAndServe 监听并阻塞服务直到 Stop
+func (s *Server) ListenAndServe(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	log.Printf("Starting gRPC server on %s", addr)
+	return s.server.Serve(lis)
+}
+
+// Stop 优雅关闭，ctx 到期后强制关闭
+func (s *Server) Stop(ctx context.Context) {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+}
+
+func (s *Server) recoveryUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("gRPC panic in %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func (s *Server) recoveryStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("gRPC panic in %s: %v\n%s", info.FullMethod, r, debug.Stack())
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// metricsUnary 记录请求指标，并以方法名作为调用方标签供缓存命中率归因
+func (s *Server) metricsUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctxutil.WithCaller(ctx, info.FullMethod), req)
+	s.metricsCollector.RecordGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+func (s *Server) metricsStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, &wrappedStream{ServerStream: ss, ctx: ctxutil.WithCaller(ss.Context(), info.FullMethod)})
+	s.metricsCollector.RecordGRPCRequest(info.FullMethod, status.Code(err).String(), time.Since(start))
+	return err
+}
+
+func (s *Server) authUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorize 公开方法也会尝试解析身份，但解析失败不拒绝
+func (s *Server) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	principal, err := s.auth.authenticate(ctx)
+	if err != nil {
+		if s.isPublic(fullMethod) {
+			// 匿名调用属于默认租户，数据库行级安全不视为系统调用
+			return ctxutil.WithTenantID(ctx, ctxutil.DefaultTenantID), nil
+		}
+		return ctx, err
+	}
+	// 行级安全的所有者与 HTTP 接口一致；API Key 调用的内部服务没有租户与用户，按系统调用处理
+	if principal.IsService() {
+		return WithPrincipal(ctx, principal), nil
+	}
+	// 与 HTTP 接口一致，只能访问和写入令牌所属租户的数据，没有租户声明的令牌属于默认租户
+	if principal.TenantID == "" {
+		principal.TenantID = ctxutil.DefaultTenantID
+	}
+	ctx = ctxutil.WithTenantID(ctx, principal.TenantID)
+	if principal.IsAdmin() {
+		ctx = ctxutil.WithAllOwners(ctx)
+	} else {
+		ctx = ctxutil.WithUserID(ctx, principal.UserID)
+	}
+	return WithPrincipal(ctx, principal), nil
+}
+
+func (s *Server) errorUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, ToStatus(ctx, err)
+	}
+	return resp, nil
+}
+
+// wrappedStream 替换流的上下文以携带调用方身份
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedStream) Context() context.Context {
+	return w.ctx
+}
@@ -1,16 +1,52 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/response"
 	"user_crud_jwt/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 令牌签发后角色或权限发生变化时，认证通过的响应带上重新签发的令牌，客户端应替换保存的令牌
+const (
+	RefreshedTokenHeader          = "X-Refreshed-Token"
+	RefreshedTokenExpiresAtHeader = "X-Refreshed-Token-Expires-At"
+)
+
+// AuthPolicy 认证通过后对每个请求执行的检查，如两步验证的 twofactor.Manager.Enforce；
+// 不满足时中止请求并返回 false
+type AuthPolicy func(c *gin.Context) bool
+
+var authPolicies []AuthPolicy
+
+// skipAuthPoliciesKey 标记请求跳过认证后策略，见 SkipAuthPolicies
+const skipAuthPoliciesKey = "skipAuthPolicies"
+
+// SetAuthPolicies 设置 AuthMiddleware 认证通过后执行的策略，启动时调用一次
+func SetAuthPolicies(policies ...AuthPolicy) {
+	authPolicies = policies
+}
+
+// SkipAuthPolicies 挂在 AuthMiddleware 之前，该路由只认证不执行策略，用于两步验证自身的登记与验证接口
+func SkipAuthPolicies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(skipAuthPoliciesKey, true)
+		c.Next()
+	}
+}
+
 // AuthMiddleware JWT认证中间件
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -30,38 +66,156 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
-		claims, err := utils.ParseToken(tokenString)
+		claims, err := parseBearerToken(c, tokenString)
+		if err == nil {
+			claims, err = utils.CheckClaims(c.Request.Context(), claims)
+		}
+		if errors.Is(err, utils.ErrTokenRevoked) {
+			response.Error(c, http.StatusUnauthorized, response.ErrTokenInvalid, "Token has been revoked")
+			c.Abort()
+			return
+		}
+		if errors.Is(err, utils.ErrPermissionsChanged) {
+			response.Error(c, http.StatusUnauthorized, response.ErrTokenInvalid, "Permissions have changed, please log in again")
+			c.Abort()
+			return
+		}
 		if err != nil {
 			response.Error(c, http.StatusUnauthorized, response.ErrTokenInvalid, "Invalid or expired token")
 			c.Abort()
 			return
 		}
+		if claims.Refreshed {
+			reissueToken(c, claims)
+		}
+		if claims.Impersonation != nil && !markImpersonation(c, claims) {
+			return
+		}
+
+		// 只能访问令牌所属租户的数据（没有租户声明的令牌属于默认租户），未挂载 TenantMiddleware 时在此写入租户
+		tenantID := tokenTenant(claims)
+		if current := ctxutil.TenantID(c.Request.Context()); current != "" && current != tenantID {
+			response.Error(c, http.StatusForbidden, response.ErrNoPermission, "Token does not belong to the requested tenant")
+			c.Abort()
+			return
+		}
+		c.Set(TenantIDKey, tenantID)
+		c.Request = c.Request.WithContext(ctxutil.WithTenantID(c.Request.Context(), tenantID))
 
 		// 将用户信息存入上下文
 		c.Set("userID", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("amr", claims.AMR)
+		if claims.IssuedAt != nil {
+			c.Set("issuedAt", claims.IssuedAt.Time)
+		}
+		// 普通用户按行级安全限定为本人的数据，管理员不受所有者限定
+		c.Request = c.Request.WithContext(withRowOwner(c.Request.Context(), claims.UserID, claims.Role))
+		if !c.GetBool(skipAuthPoliciesKey) {
+			for _, policy := range authPolicies {
+				if !policy(c) {
+					return
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+// reissueToken 按重新解析的角色签发新令牌并写入响应头，本次请求已使用新的角色，签发失败时下次请求重新解析
+func reissueToken(c *gin.Context, claims *utils.Claims) {
+	token, expireAt, err := utils.ReissueToken(claims)
+	if err != nil {
+		log.Printf("Failed to reissue token for user %s: %v", claims.UserID, err)
+		return
+	}
+	c.Header(RefreshedTokenHeader, token)
+	c.Header(RefreshedTokenExpiresAtHeader, expireAt.Format(time.RFC3339))
+}
+
+// APIKeyHeader 运维工具调用管理接口时携带 API Key 的请求头
+const APIKeyHeader = "X-API-Key"
+
+// apiKey 配置的 API Key，只保存摘要
+type apiKey struct {
+	name     string
+	digest   [sha256.Size]byte
+	role     int
+	priority string
+}
+
+// apiKeySet 按摘要匹配 API Key
+type apiKeySet []apiKey
+
+func newAPIKeySet(keys []config.APIKeyConfig) apiKeySet {
+	configured := make(apiKeySet, 0, len(keys))
+	for _, k := range keys {
+		if k.Key != "" {
+			configured = append(configured, apiKey{name: k.Name, digest: sha256.Sum256([]byte(k.Key)), role: k.Role, priority: k.Priority})
+		}
+	}
+	return configured
+}
+
+// match 返回匹配的 API Key，逐个比较不提前返回，避免通过耗时推断匹配位置
+func (s apiKeySet) match(value string) *apiKey {
+	digest := sha256.Sum256([]byte(value))
+	var matched *apiKey
+	for i := range s {
+		if subtle.ConstantTimeCompare(digest[:], s[i].digest[:]) == 1 {
+			matched = &s[i]
+		}
+	}
+	return matched
+}
+
+// APIKeyOrAuthMiddleware 请求携带 X-API-Key 时按配置的 API Key 认证，否则按 JWT 认证。
+// API Key 调用方以配置的角色访问，userID 记为 "apikey:<名称>"，便于审计
+func APIKeyOrAuthMiddleware(keys []config.APIKeyConfig) gin.HandlerFunc {
+	configured := newAPIKeySet(keys)
+	jwtAuth := AuthMiddleware()
+
+	return func(c *gin.Context) {
+		value := c.GetHeader(APIKeyHeader)
+		if value == "" {
+			jwtAuth(c)
+			return
+		}
+
+		matched := configured.match(value)
+		if matched == nil {
+			response.Error(c, http.StatusUnauthorized, response.ErrTokenInvalid, "Invalid API key")
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", "apikey:"+matched.name)
+		c.Set("role", matched.role)
+		c.Set("apiKey", matched.name)
+		c.Request = c.Request.WithContext(withRowOwner(c.Request.Context(), "apikey:"+matched.name, matched.role))
 		c.Next()
 	}
 }
 
+// withRowOwner 写入数据库行级安全的所有者：管理员可访问租户内所有用户的数据，其他角色只能访问本人的数据
+func withRowOwner(ctx context.Context, userID string, role int) context.Context {
+	if role == model.RoleAdmin {
+		return ctxutil.WithAllOwners(ctx)
+	}
+	return ctxutil.WithUserID(ctx, userID)
+}
+
 // AdminMiddleware 管理员权限中间件
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
+		if _, exists := c.Get("role"); !exists {
 			response.Error(c, http.StatusUnauthorized, response.ErrNoPermission, "Unauthorized")
 			c.Abort()
 			return
 		}
 
-		// JSON解析出来的数字可能是 float64
-		var roleInt int
-		switch v := role.(type) {
-		case float64:
-			roleInt = int(v)
-		case int:
-			roleInt = v
-		default:
+		roleInt, ok := contextRole(c)
+		if !ok {
 			response.Error(c, http.StatusForbidden, response.ErrNoPermission, "Invalid role format")
 			c.Abort()
 			return
@@ -76,3 +230,16 @@ func AdminMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// contextRole 返回 AuthMiddleware 存入上下文的角色
+func contextRole(c *gin.Context) (int, bool) {
+	role, _ := c.Get("role")
+	// JSON解析出来的数字可能是 float64
+	switch v := role.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
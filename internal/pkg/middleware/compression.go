@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的压缩编码
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// CompressionConfig 响应压缩配置
+type CompressionConfig struct {
+	MinSize      int      // 小于该字节数的响应不压缩，压缩收益抵不过开销
+	ContentTypes []string // 允许压缩的内容类型，支持 "text/*" 形式的通配
+	ExcludePaths []string // 不压缩的路由模板，如自行处理压缩的 /metrics
+	GzipLevel    int
+	BrotliLevel  int
+}
+
+// DefaultCompressionConfig 默认响应压缩配置
+func DefaultCompressionConfig() *CompressionConfig {
+	return &CompressionConfig{
+		MinSize: 1024,
+		ContentTypes: []string{
+			"application/json",
+			"application/problem+json",
+			"application/javascript",
+			"application/xml",
+			"image/svg+xml",
+			"text/*",
+		},
+		ExcludePaths: []string{"/metrics"},
+		GzipLevel:    gzip.DefaultCompression,
+		BrotliLevel:  4,
+	}
+}
+
+// compressor 按配置协商编码并复用压缩器，压缩中间件与响应缓存共用
+type compressor struct {
+	config     *CompressionConfig
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+}
+
+func newCompressor(config *CompressionConfig) *compressor {
+	if config == nil {
+		config = DefaultCompressionConfig()
+	}
+	p := &compressor{config: config}
+	p.gzipPool.New = func() interface{} {
+		w, err := gzip.NewWriterLevel(io.Discard, config.GzipLevel)
+		if err != nil {
+			w = gzip.NewWriter(io.Discard)
+		}
+		return w
+	}
+	p.brotliPool.New = func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, config.BrotliLevel)
+	}
+	return p
+}
+
+// negotiate 按 Accept-Encoding 选择编码，q 值相同时优先 br，不接受压缩时返回空串
+func (p *compressor) negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+		} else {
+			qualities[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible 内容类型在允许列表中且达到最小长度
+func (p *compressor) compressible(contentType string, size int) bool {
+	if size < p.config.MinSize || contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range p.config.ContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// newWriter 返回写入 w 的压缩器，Close 后归还池中
+func (p *compressor) newWriter(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case encodingBrotli:
+		bw := p.brotliPool.Get().(*brotli.Writer)
+		bw.Reset(w)
+		return &pooledWriter{writer: bw, release: func() { p.brotliPool.Put(bw) }}
+	case encodingGzip:
+		gw := p.gzipPool.Get().(*gzip.Writer)
+		gw.Reset(w)
+		return &pooledWriter{writer: gw, release: func() { p.gzipPool.Put(gw) }}
+	}
+	return nil
+}
+
+// compress 压缩完整的数据
+func (p *compressor) compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := p.newWriter(encoding, &buf)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// flushWriteCloser gzip 与 brotli 的压缩器均实现
+type flushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type pooledWriter struct {
+	writer  flushWriteCloser
+	release func()
+}
+
+func (w *pooledWriter) Write(data []byte) (int, error) {
+	return w.writer.Write(data)
+}
+
+func (w *pooledWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.writer.Close()
+	w.release()
+	return err
+}
+
+// CompressionMiddleware 响应压缩中间件，按 Accept-Encoding 协商 br 或 gzip。
+// 响应先缓冲到 MinSize 再决定是否压缩，已设置 Content-Encoding 的响应（如响应缓存中预压缩的变体）原样写出
+func CompressionMiddleware(config *CompressionConfig) gin.HandlerFunc {
+	p := newCompressor(config)
+
+	excluded := make(map[string]bool, len(p.config.ExcludePaths))
+	for _, path := range p.config.ExcludePaths {
+		excluded[path] = true
+	}
+
+	return func(c *gin.Context) {
+		encoding := p.negotiate(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead || excluded[c.FullPath()] || excluded[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		writer := &compressWriter{ResponseWriter: original, compressor: p, encoding: encoding}
+		c.Writer = writer
+		defer func() { c.Writer = original }()
+		c.Next()
+		writer.finish()
+	}
+}
+
+// compressWriter 缓冲响应直到能判断是否压缩，之后以流式写出
+type compressWriter struct {
+	gin.ResponseWriter
+	compressor *compressor
+	encoding   string
+	status     int
+	buf        bytes.Buffer
+	decided    bool
+	encoder    io.WriteCloser // 为 nil 时不压缩
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code > 0 && !w.decided {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide()
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	n, _ := w.buf.Write(data)
+	if w.buf.Len() >= w.compressor.config.MinSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Size() int {
+	if !w.decided {
+		if w.buf.Len() == 0 {
+			return -1
+		}
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *compressWriter) Written() bool {
+	if !w.decided {
+		return w.status != 0 || w.buf.Len() > 0
+	}
+	return w.ResponseWriter.Written()
+}
+
+// Flush 流式响应刷新时无法再等待更多数据，立即决定是否压缩
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide 写出响应头与已缓冲的数据，此后的写入直接进入压缩器或原始 writer
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := w.ResponseWriter.Header()
+	if bodyAllowed(status) && header.Get("Content-Encoding") == "" &&
+		!strings.Contains(header.Get("Cache-Control"), "no-transform") &&
+		w.compressor.compressible(header.Get("Content-Type"), w.buf.Len()) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+		// 压缩后字节不同，强 ETag 降为弱 ETag
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.encoder = w.compressor.newWriter(w.encoding, w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+	if w.buf.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return nil
+	}
+	data := w.buf.Bytes()
+	w.buf = bytes.Buffer{}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(data)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(data)
+	return err
+}
+
+// finish 处理器返回后写出剩余数据；处理器未写出任何内容时保持原样，交由外层中间件处理
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if w.status == 0 && w.buf.Len() == 0 {
+			return
+		}
+		w.decide()
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
+
+// bodyAllowed 该状态码的响应是否允许有响应体
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
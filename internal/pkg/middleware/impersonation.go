@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 代登录请求在上下文中的标记，审计与业务日志据此区分管理员代为执行的操作
+const (
+	ImpersonatorIDKey  = "impersonatorID"
+	ImpersonationIDKey = "impersonationID"
+	// ImpersonatedByHeader 代登录期间每个响应都带上发起的管理员，前端据此显示代登录提示
+	ImpersonatedByHeader = "X-Impersonated-By"
+)
+
+// DefaultImpersonationForbiddenRoutes 代登录期间默认拒绝的路由前缀：管理接口、支付、两步验证、
+// 手机号与邮箱验证以及账号注销，这些操作需用户本人完成
+var DefaultImpersonationForbiddenRoutes = []string{"/admin", "/payments", "/2fa", "/verification", "/compliance/deletion"}
+
+// markImpersonation 代登录令牌的请求写入标记与响应头，访问禁止的路由时拒绝并返回 false
+func markImpersonation(c *gin.Context, claims *utils.Claims) bool {
+	c.Set(ImpersonatorIDKey, claims.Impersonation.ActorID)
+	c.Set(ImpersonationIDKey, claims.Impersonation.SessionID)
+	c.Header(ImpersonatedByHeader, claims.Impersonation.ActorID)
+
+	if impersonationForbidden(c) {
+		response.Error(c, http.StatusForbidden, response.ErrNoPermission, "This operation is not allowed during impersonation")
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// impersonationForbidden 路由是否在禁止的前缀下，按注册的路由匹配，未匹配路由时按请求路径
+func impersonationForbidden(c *gin.Context) bool {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	forbidden := config.GlobalConfig.Admin.Impersonation.ForbiddenRoutes
+	if len(forbidden) == 0 {
+		forbidden = DefaultImpersonationForbiddenRoutes
+	}
+	for _, prefix := range forbidden {
+		if route == prefix || strings.HasPrefix(route, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"time"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -15,10 +16,14 @@ func LoggerMiddleware() gin.HandlerFunc {
 		path := c.Request.URL.Path
 		query := c.Request.URL.RawQuery
 
-		// Generate Request ID
-		requestID := uuid.New().String()
-		c.Set("RequestID", requestID)
-		c.Header("X-Request-ID", requestID)
+		// 复用 RequestIDMiddleware 生成的请求 ID，未挂载时在此生成
+		requestID := c.GetString(RequestIDKey)
+		if requestID == "" {
+			requestID = uuid.New().String()
+			c.Set(RequestIDKey, requestID)
+			c.Request = c.Request.WithContext(ctxutil.WithRequestID(c.Request.Context(), requestID))
+			c.Header(RequestIDHeader, requestID)
+		}
 
 		c.Next()
 
@@ -34,6 +39,7 @@ func LoggerMiddleware() gin.HandlerFunc {
 				zap.String("ip", c.ClientIP()),
 				zap.String("user-agent", c.Request.UserAgent()),
 				zap.String("request_id", requestID),
+				zap.String("correlation_id", c.GetString(CorrelationIDKey)),
 				zap.Duration("cost", cost),
 			)
 		}
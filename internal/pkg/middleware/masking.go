@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/pkg/masking"
+	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaskingConfig 响应脱敏配置
+type MaskingConfig struct {
+	Masker     *masking.Masker
+	Permission security.Permission        // 持有该权限的调用方看到原始数据
+	Checker    security.PermissionChecker // 为 nil 时只有管理员看到原始数据
+	Exempt     func(c *gin.Context) bool  // 额外放行的请求，如用户查看自己的资料
+}
+
+// DefaultMaskingConfig 默认按 masking.DefaultConfig 脱敏，持有 admin:read 权限时不脱敏
+func DefaultMaskingConfig() *MaskingConfig {
+	return &MaskingConfig{
+		Masker:     masking.NewMasker(masking.DefaultConfig()),
+		Permission: security.PermissionAdminRead,
+	}
+}
+
+// MaskResponse 调用方无权查看原始数据时，让 response.Success 等返回脱敏后的 data。
+// 需挂在 AuthMiddleware 之后；与响应缓存同用时缓存须按用户区分（默认的 CacheScopeUser）
+func MaskResponse(config *MaskingConfig) gin.HandlerFunc {
+	if config == nil {
+		config = DefaultMaskingConfig()
+	}
+
+	return func(c *gin.Context) {
+		if !canViewUnmasked(c, config) {
+			c.Set(response.MaskerKey, config.Masker)
+		}
+		c.Next()
+	}
+}
+
+// canViewUnmasked 权限服务出错时按无权限处理
+func canViewUnmasked(c *gin.Context, config *MaskingConfig) bool {
+	if role, ok := contextRole(c); ok && role == model.RoleAdmin {
+		return true
+	}
+	if config.Exempt != nil && config.Exempt(c) {
+		return true
+	}
+	userID := c.GetString("userID")
+	if config.Checker == nil || userID == "" {
+		return false
+	}
+	// 未分配角色的用户会返回错误，同样视为无权限
+	allowed, err := config.Checker.HasPermission(c.Request.Context(), userID, config.Permission)
+	return err == nil && allowed
+}
@@ -290,32 +290,6 @@ func SystemMetricsMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RequestIDMiddleware 请求 ID 中间件（优化版）
-func RequestIDMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 检查是否已有请求 ID
-		requestID := c.GetHeader("X-Request-ID")
-		if requestID == "" {
-			// 生成更高效的请求 ID
-			requestID = generateRequestID()
-		}
-
-		c.Set("request_id", requestID)
-		c.Header("X-Request-ID", requestID)
-
-		c.Next()
-	}
-}
-
-// generateRequestID 生成请求 ID
-func generateRequestID() string {
-	// 使用时间戳和随机数生成更高效的 ID
-	timestamp := time.Now().UnixNano()
-	// 简单的随机数生成
-	randNum := timestamp % 1000000
-	return strconv.FormatInt(timestamp, 36) + strconv.FormatInt(randNum, 36)
-}
-
 // TimeoutMiddleware 超时中间件
 func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -369,7 +343,7 @@ func CORSMiddleware() gin.HandlerFunc {
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, "+RefreshedTokenHeader+", "+RefreshedTokenExpiresAtHeader+", "+ImpersonatedByHeader)
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400")
 
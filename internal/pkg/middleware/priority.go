@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+
+	"user_crud_jwt/internal/domain/user/model"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/admission"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PriorityClassifier 按调用方判定准入优先级：管理员令牌与管理员 API Key 为 critical，其他已登录用户为 normal，
+// 匿名或凭据无效的请求为 low；API Key 配置了 priority 时以其为准。
+// 准入在认证之前进行，令牌只校验签名与有效期，吊销仍由认证中间件拒绝
+func PriorityClassifier(keys []config.APIKeyConfig) (admission.ClassifyFunc, error) {
+	configured := newAPIKeySet(keys)
+	priorities := make(map[string]admission.Priority, len(configured))
+	for _, key := range configured {
+		if key.priority == "" {
+			priorities[key.name] = rolePriority(key.role)
+			continue
+		}
+		priority, err := admission.ParsePriority(key.priority)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority for api key %s: %w", key.name, err)
+		}
+		priorities[key.name] = priority
+	}
+
+	return func(c *gin.Context) admission.Priority {
+		if value := c.GetHeader(APIKeyHeader); value != "" {
+			if matched := configured.match(value); matched != nil {
+				return priorities[matched.name]
+			}
+			return admission.PriorityLow
+		}
+
+		claims := bearerClaims(c)
+		if claims == nil {
+			return admission.PriorityLow
+		}
+		return rolePriority(claims.Role)
+	}, nil
+}
+
+func rolePriority(role int) admission.Priority {
+	if role == model.RoleAdmin {
+		return admission.PriorityCritical
+	}
+	return admission.PriorityNormal
+}
@@ -1,50 +1,79 @@
 package middleware
 
 import (
-	"strconv"
 	"time"
+	"user_crud_jwt/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests.",
-		},
-		[]string{"method", "path", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "Histogram of response latency (seconds).",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "path"},
-	)
-)
+// unmatchedRoute 未匹配路由的统一标签，避免原始路径导致指标基数膨胀
+const unmatchedRoute = "unmatched"
+
+// PrometheusConfig HTTP 指标配置
+type PrometheusConfig struct {
+	ExcludePaths []string // 不记录指标的路由模板，如健康检查
+	Collector    *metrics.MetricsCollector
+}
 
-func init() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
+// DefaultPrometheusConfig 默认 HTTP 指标配置
+func DefaultPrometheusConfig() *PrometheusConfig {
+	return &PrometheusConfig{
+		ExcludePaths: []string{"/healthz", "/readyz", "/metrics"},
+		Collector:    metrics.GetGlobalCollector(),
+	}
 }
 
-// PrometheusMiddleware 监控中间件
-func PrometheusMiddleware() gin.HandlerFunc {
+// PrometheusMiddleware 监控中间件，按路由模板记录请求数、耗时、请求/响应大小与状态分类
+func PrometheusMiddleware(config *PrometheusConfig) gin.HandlerFunc {
+	if config == nil {
+		config = DefaultPrometheusConfig()
+	}
+	if config.Collector == nil {
+		config.Collector = metrics.GetGlobalCollector()
+	}
+
+	excluded := make(map[string]bool, len(config.ExcludePaths))
+	for _, path := range config.ExcludePaths {
+		excluded[path] = true
+	}
+
 	return func(c *gin.Context) {
-		start := time.Now()
-		path := c.Request.URL.Path
+		route := c.FullPath()
+		if excluded[route] || excluded[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
 
+		start := time.Now()
 		c.Next()
 
-		status := strconv.Itoa(c.Writer.Status())
-		duration := time.Since(start).Seconds()
-		method := c.Request.Method
+		if route == "" {
+			route = unmatchedRoute
+		}
 
-		httpRequestsTotal.WithLabelValues(method, path, status).Inc()
-		httpRequestDuration.WithLabelValues(method, path).Observe(duration)
+		requestSize := 0
+		if c.Request.ContentLength > 0 {
+			requestSize = int(c.Request.ContentLength)
+		}
+		responseSize := c.Writer.Size()
+		if responseSize < 0 {
+			responseSize = 0
+		}
+
+		config.Collector.RecordHTTPRequest(
+			c.Request.Method,
+			route,
+			metrics.StatusClass(c.Writer.Status()),
+			time.Since(start),
+			requestSize,
+			responseSize,
+		)
 	}
 }
+
+// MetricsHandler 暴露 Prometheus 指标
+func MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"sync"
 
+	"user_crud_jwt/pkg/clock"
 	"user_crud_jwt/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -12,10 +13,11 @@ import (
 
 // IPRateLimiter 存储每个IP的限流器
 type IPRateLimiter struct {
-	ips map[string]*rate.Limiter
-	mu  *sync.RWMutex
-	r   rate.Limit
-	b   int
+	ips   map[string]*rate.Limiter
+	mu    *sync.RWMutex
+	r     rate.Limit
+	b     int
+	clock clock.Clock
 }
 
 // NewIPRateLimiter 创建一个新的IP限流器
@@ -23,10 +25,11 @@ type IPRateLimiter struct {
 // b: 桶的大小 (Burst)
 func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
 	i := &IPRateLimiter{
-		ips: make(map[string]*rate.Limiter),
-		mu:  &sync.RWMutex{},
-		r:   r,
-		b:   b,
+		ips:   make(map[string]*rate.Limiter),
+		mu:    &sync.RWMutex{},
+		r:     r,
+		b:     b,
+		clock: clock.Real,
 	}
 
 	// 启动清理协程，定期清理过期的IP（这里简化处理，实际生产可以使用LRU缓存或Redis）
@@ -47,6 +50,16 @@ func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
+// SetClock 替换补充令牌使用的时钟
+func (i *IPRateLimiter) SetClock(c clock.Clock) {
+	i.clock = clock.OrReal(c)
+}
+
+// Allow 指定IP是否还有令牌
+func (i *IPRateLimiter) Allow(ip string) bool {
+	return i.GetLimiter(ip).AllowN(i.clock.Now(), 1)
+}
+
 // GlobalRateLimiter 全局限流器实例
 // 默认限制：每秒 10000 个请求，突发 20000 个 (为了演示高并发，设置得比较大)
 var limiter = NewIPRateLimiter(10000, 20000)
@@ -54,9 +67,7 @@ var limiter = NewIPRateLimiter(10000, 20000)
 // RateLimitMiddleware 限流中间件
 func RateLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		l := limiter.GetLimiter(ip)
-		if !l.Allow() {
+		if !limiter.Allow(c.ClientIP()) {
 			response.Error(c, http.StatusTooManyRequests, response.ErrTooManyRequests, "Too many requests")
 			c.Abort()
 			return
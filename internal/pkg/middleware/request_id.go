@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"regexp"
+	"user_crud_jwt/pkg/ctxutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeader 请求 ID 请求/响应头
+	RequestIDHeader = "X-Request-ID"
+	// CorrelationIDHeader 关联 ID 请求/响应头
+	CorrelationIDHeader = "X-Correlation-ID"
+	// RequestIDKey gin 上下文中的请求 ID 键
+	RequestIDKey = "request_id"
+	// CorrelationIDKey gin 上下文中的关联 ID 键
+	CorrelationIDKey = "correlation_id"
+)
+
+// validIDPattern 只接受安全字符，避免客户端传入的 ID 污染日志
+var validIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware 生成或透传请求 ID 与关联 ID，写入 gin 上下文、请求 context 与响应头。
+// 关联 ID 未提供时与请求 ID 相同。请求 context 中同时记录客户端 IP 与 User-Agent，供审计类记录使用，
+// 以及 "方法 路由模板" 形式的调用方标签，供缓存命中率按接口归因；没有匹配路由的请求不写入。
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validIDPattern.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		correlationID := c.GetHeader(CorrelationIDHeader)
+		if !validIDPattern.MatchString(correlationID) {
+			correlationID = requestID
+		}
+
+		c.Set(RequestIDKey, requestID)
+		c.Set(CorrelationIDKey, correlationID)
+
+		ctx := ctxutil.WithRequestID(c.Request.Context(), requestID)
+		ctx = ctxutil.WithCorrelationID(ctx, correlationID)
+		ctx = ctxutil.WithClient(ctx, ctxutil.Client{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
+		if route := c.FullPath(); route != "" {
+			ctx = ctxutil.WithCaller(ctx, c.Request.Method+" "+route)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Header(RequestIDHeader, requestID)
+		c.Header(CorrelationIDHeader, correlationID)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,336 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCacheKeyPrefix 响应缓存键前缀
+const responseCacheKeyPrefix = "http:response:"
+
+// CacheScope 缓存响应的共享范围
+type CacheScope int
+
+const (
+	// CacheScopeUser 按用户隔离，默认值
+	CacheScopeUser CacheScope = iota
+	// CacheScopeRole 同一角色的用户共享
+	CacheScopeRole
+	// CacheScopePublic 所有调用方共享，仅用于响应与调用方无关的路由
+	CacheScopePublic
+)
+
+// ResponseStore 响应缓存的存储，CacheService 与 MultiLevelCache 均满足
+type ResponseStore interface {
+	GetMany(ctx context.Context, keys []string) (*cache.BatchGetResult, error)
+	SetMany(ctx context.Context, entries []cache.CacheEntry) (*cache.BatchSetResult, error)
+}
+
+// ResponseCacheConfig 单个路由的响应缓存配置
+type ResponseCacheConfig struct {
+	TTL   time.Duration                 // 缓存有效期
+	Scope CacheScope                    // 共享范围
+	Tags  func(c *gin.Context) []string // 响应依赖的实体标签，任一标签失效后缓存不再命中
+}
+
+// DefaultResponseCacheConfig 默认响应缓存配置
+func DefaultResponseCacheConfig() *ResponseCacheConfig {
+	return &ResponseCacheConfig{
+		TTL:   time.Minute,
+		Scope: CacheScopeUser,
+	}
+}
+
+// ResponseCache GET 响应缓存，支持 ETag/Last-Modified 条件请求与按标签失效
+type ResponseCache struct {
+	store            ResponseStore
+	tags             *cache.TagInvalidationStrategy
+	compressor       *compressor // 为 nil 时不保存预压缩的变体
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewResponseCache 创建响应缓存，tags 的版本号需存放在各实例共享的缓存中。
+// compression 不为 nil 时随响应一起缓存 br、gzip 预压缩的变体，命中时直接写出，无需重复压缩
+func NewResponseCache(store ResponseStore, tags *cache.TagInvalidationStrategy, compression *CompressionConfig) *ResponseCache {
+	rc := &ResponseCache{store: store, tags: tags, metricsCollector: metrics.GetGlobalCollector()}
+	if compression != nil {
+		rc.compressor = newCompressor(compression)
+	}
+	return rc
+}
+
+// InvalidateTags 实现 cache.TagInvalidator，rc 为 nil 时不做任何事
+func (rc *ResponseCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	if rc == nil {
+		return nil
+	}
+	return rc.tags.InvalidateTags(ctx, tags...)
+}
+
+// cachedResponse 缓存中保存的响应
+type cachedResponse struct {
+	Status       int               `json:"status"`
+	ContentType  string            `json:"content_type"`
+	Body         []byte            `json:"body"`
+	ETag         string            `json:"etag"`
+	LastModified time.Time         `json:"last_modified"`
+	Encoded      map[string][]byte `json:"encoded,omitempty"` // 编码 -> 预压缩的响应体
+}
+
+// Middleware 缓存 GET 请求的 200 响应。
+// 缓存键由路径、查询参数、调用方范围与依赖标签的版本号组成，标签失效后键随之变化，
+// 各实例的本地缓存无需通知即可读到新数据。缓存不可用或 rc 为 nil 时直接执行处理器
+func (rc *ResponseCache) Middleware(config *ResponseCacheConfig) gin.HandlerFunc {
+	if rc == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	if config == nil {
+		config = DefaultResponseCacheConfig()
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultResponseCacheConfig().TTL
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet || strings.Contains(c.GetHeader("Cache-Control"), "no-store") {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		var tags []string
+		if config.Tags != nil {
+			tags = config.Tags(c)
+		}
+		versions, err := rc.tags.Versions(ctx, tags)
+		if err != nil {
+			log.Printf("Response cache bypassed for %s: %v", c.Request.URL.Path, err)
+			c.Next()
+			return
+		}
+		key := responseCacheKey(c, config.Scope, versions)
+
+		start := time.Now()
+		if result, err := rc.store.GetMany(ctx, []string{key}); err == nil && result.Hit(key) {
+			var cached cachedResponse
+			if err := result.Decode(key, &cached); err == nil {
+				rc.metricsCollector.RecordCacheOperationContext(ctx, "get", "response", responseCacheKeyPrefix, time.Since(start), true)
+				c.Header("X-Cache", "HIT")
+				rc.write(c, config.Scope, &cached)
+				c.Abort()
+				return
+			}
+		}
+		rc.metricsCollector.RecordCacheOperationContext(ctx, "get", "response", responseCacheKeyPrefix, time.Since(start), false)
+
+		// 缓冲响应体，处理器返回后才能计算 ETag
+		original := c.Writer
+		writer := &bufferedResponseWriter{ResponseWriter: original}
+		c.Writer = writer
+		func() {
+			// 处理器 panic 时恢复原始 writer，保证外层 Recovery 的错误响应能写出
+			defer func() { c.Writer = original }()
+			c.Next()
+		}()
+
+		status := writer.Status()
+		if status != http.StatusOK || len(c.Errors) > 0 {
+			original.WriteHeader(status)
+			original.Write(writer.body.Bytes())
+			return
+		}
+
+		body := writer.body.Bytes()
+		sum := sha256.Sum256(body)
+		cached := cachedResponse{
+			Status:       status,
+			ContentType:  original.Header().Get("Content-Type"),
+			Body:         body,
+			ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		}
+		rc.precompress(&cached)
+		entry := cache.CacheEntry{Key: key, Value: &cached, Expiration: config.TTL}
+		if _, err := rc.store.SetMany(ctx, []cache.CacheEntry{entry}); err != nil {
+			log.Printf("Failed to cache response for %s: %v", c.Request.URL.Path, err)
+		}
+
+		c.Header("X-Cache", "MISS")
+		rc.write(c, config.Scope, &cached)
+	}
+}
+
+// precompress 生成各编码的预压缩变体，单个编码失败时该编码改由压缩中间件实时压缩
+func (rc *ResponseCache) precompress(cached *cachedResponse) {
+	if rc.compressor == nil || !rc.compressor.compressible(cached.ContentType, len(cached.Body)) {
+		return
+	}
+	cached.Encoded = make(map[string][]byte, 2)
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		data, err := rc.compressor.compress(encoding, cached.Body)
+		if err != nil {
+			log.Printf("Failed to precompress response with %s: %v", encoding, err)
+			continue
+		}
+		cached.Encoded[encoding] = data
+	}
+}
+
+// write 写出响应，客户端接受的编码有预压缩变体时写出该变体，满足条件请求时返回 304
+func (rc *ResponseCache) write(c *gin.Context, scope CacheScope, cached *cachedResponse) {
+	header := c.Writer.Header()
+	body, etag := cached.Body, cached.ETag
+	if rc.compressor != nil {
+		header.Add("Vary", "Accept-Encoding")
+		encoding := rc.compressor.negotiate(c.GetHeader("Accept-Encoding"))
+		if encoded, ok := cached.Encoded[encoding]; ok {
+			// 不同编码是不同的表示，ETag 需区分
+			body, etag = encoded, strings.TrimSuffix(cached.ETag, `"`)+"-"+encoding+`"`
+			header.Set("Content-Encoding", encoding)
+		}
+	}
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", cached.LastModified.Format(http.TimeFormat))
+	// 客户端可以保存响应，但每次使用前需用 ETag 重新验证；需登录的响应不允许共享代理缓存
+	if scope == CacheScopePublic && c.GetString("userID") == "" {
+		header.Set("Cache-Control", "public, no-cache")
+	} else {
+		header.Set("Cache-Control", "private, no-cache")
+		header.Add("Vary", "Authorization")
+	}
+
+	if notModified(c.Request, etag, cached.LastModified) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		header.Del("Content-Encoding")
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	if cached.ContentType != "" {
+		header.Set("Content-Type", cached.ContentType)
+	}
+	c.Writer.WriteHeader(cached.Status)
+	c.Writer.Write(body)
+}
+
+// notModified 按 RFC 9110 判断条件请求：存在 If-None-Match 时忽略 If-Modified-Since
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.After(t)
+		}
+	}
+	return false
+}
+
+// responseCacheKey 缓存键，参数与标签排序后取摘要，避免键过长
+func responseCacheKey(c *gin.Context, scope CacheScope, versions map[string]int64) string {
+	var b strings.Builder
+	b.WriteString(c.Request.URL.Path)
+	b.WriteString("?")
+	// Encode 按参数名排序，参数顺序不同的同一请求共用缓存
+	b.WriteString(c.Request.URL.Query().Encode())
+	if tenantID := ctxutil.TenantID(c.Request.Context()); tenantID != "" {
+		fmt.Fprintf(&b, "|tenant:%s", tenantID)
+	}
+
+	switch scope {
+	case CacheScopeUser:
+		fmt.Fprintf(&b, "|user:%s", c.GetString("userID"))
+	case CacheScopeRole:
+		role, _ := c.Get("role")
+		fmt.Fprintf(&b, "|role:%v", role)
+	}
+
+	tags := make([]string, 0, len(versions))
+	for tag := range versions {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		fmt.Fprintf(&b, "|%s=%d", tag, versions[tag])
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return responseCacheKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// bufferedResponseWriter 缓冲处理器写出的状态码与响应体，由中间件统一写出
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.status != 0 || w.body.Len() > 0
+}
+
+// Flush 响应需完整缓冲后才能写出，忽略处理器的刷新
+func (w *bufferedResponseWriter) Flush() {}
+
+// InvalidateTagsMiddleware 写操作成功后失效标签，用于未经过带失效功能的服务的写路由
+func InvalidateTagsMiddleware(invalidator cache.TagInvalidator, tags func(c *gin.Context) []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+		if err := invalidator.InvalidateTags(c.Request.Context(), tags(c)...); err != nil {
+			log.Printf("Failed to invalidate cache tags for %s: %v", c.Request.URL.Path, err)
+		}
+	}
+}
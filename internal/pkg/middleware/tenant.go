@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/response"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TenantHeader 指定租户的请求头
+	TenantHeader = "X-Tenant-ID"
+	// TenantIDKey gin 上下文中的租户 ID 键
+	TenantIDKey = "tenantID"
+)
+
+// validTenantPattern 租户 ID 同时用作缓存键前缀与子域名，只接受小写字母、数字与连字符
+var validTenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// TenantConfig 租户解析配置
+type TenantConfig struct {
+	Header     string // 指定租户的请求头，为空时不从请求头解析
+	BaseDomain string // 子域名解析的根域名，如 example.com 时 acme.example.com 解析为 acme；为空时不从子域名解析
+	Default    string // 未指定租户时使用，为空时拒绝未指定租户的请求
+}
+
+// DefaultTenantConfig 默认从 X-Tenant-ID 请求头解析，未指定时归入默认租户
+func DefaultTenantConfig() *TenantConfig {
+	return &TenantConfig{
+		Header:  TenantHeader,
+		Default: ctxutil.DefaultTenantID,
+	}
+}
+
+// TenantMiddleware 解析请求所属租户，写入 gin 上下文与请求 context，供仓库限定查询、缓存加前缀。
+// 令牌中的租户优先，没有租户声明的令牌属于默认租户；请求头或子域名指定的租户与令牌不符时返回 403，防止跨租户访问
+func TenantMiddleware(config *TenantConfig) gin.HandlerFunc {
+	if config == nil {
+		config = DefaultTenantConfig()
+	}
+
+	return func(c *gin.Context) {
+		requested := ""
+		if config.Header != "" {
+			requested = strings.TrimSpace(c.GetHeader(config.Header))
+		}
+		if requested == "" && config.BaseDomain != "" {
+			requested = subdomainTenant(c.Request.Host, config.BaseDomain)
+		}
+		if requested != "" && !validTenantPattern.MatchString(requested) {
+			response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, "Invalid tenant ID")
+			c.Abort()
+			return
+		}
+
+		tenantID := requested
+		if claims := bearerClaims(c); claims != nil {
+			claimed := tokenTenant(claims)
+			if requested != "" && requested != claimed {
+				response.Error(c, http.StatusForbidden, response.ErrNoPermission, "Token does not belong to the requested tenant")
+				c.Abort()
+				return
+			}
+			tenantID = claimed
+		}
+		if tenantID == "" {
+			tenantID = config.Default
+		}
+		if tenantID == "" {
+			response.Error(c, http.StatusBadRequest, response.ErrInvalidParam, "Tenant ID is required")
+			c.Abort()
+			return
+		}
+
+		c.Set(TenantIDKey, tenantID)
+		c.Request = c.Request.WithContext(ctxutil.WithTenantID(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
+
+// subdomainTenant 取根域名下的一级子域名，www 与根域名本身不视为租户
+func subdomainTenant(host, baseDomain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	sub, ok := strings.CutSuffix(host, "."+strings.ToLower(baseDomain))
+	if !ok || sub == "www" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// tokenTenant 令牌所属的租户。租户功能上线前签发的令牌没有租户声明，属于默认租户，不能以请求头或子域名切换
+func tokenTenant(claims *utils.Claims) string {
+	if claims.TenantID == "" {
+		return ctxutil.DefaultTenantID
+	}
+	return claims.TenantID
+}
+
+// tokenClaimsKey gin 上下文中已解析的令牌，TenantMiddleware、优先级分类与 AuthMiddleware 共用
+const tokenClaimsKey = "tokenClaims"
+
+// parsedToken 令牌签名的解析结果
+type parsedToken struct {
+	token  string
+	claims *utils.Claims
+	err    error
+}
+
+// parseBearerToken 验证令牌签名，结果保存在 gin 上下文中，同一请求只解析一次；吊销与权限版本由 AuthMiddleware 检查
+func parseBearerToken(c *gin.Context, token string) (*utils.Claims, error) {
+	if cached, ok := c.Get(tokenClaimsKey); ok {
+		if parsed, ok := cached.(*parsedToken); ok && parsed.token == token {
+			return parsed.claims, parsed.err
+		}
+	}
+	claims, err := utils.ParseToken(token)
+	c.Set(tokenClaimsKey, &parsedToken{token: token, claims: claims, err: err})
+	return claims, err
+}
+
+// bearerClaims 请求携带的令牌的声明，没有令牌或令牌无效时返回 nil，由 AuthMiddleware 拒绝请求
+func bearerClaims(c *gin.Context) *utils.Claims {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	claims, err := parseBearerToken(c, token)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
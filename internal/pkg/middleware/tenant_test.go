@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tenantRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	previous := config.GlobalConfig.JWT.Secret
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	t.Cleanup(func() { config.GlobalConfig.JWT.Secret = previous })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(TenantMiddleware(DefaultTenantConfig()))
+	router.GET("/tenant", func(c *gin.Context) {
+		parsed, _ := c.Get(tokenClaimsKey)
+		c.JSON(http.StatusOK, gin.H{"tenant": ctxutil.TenantID(c.Request.Context()), "parsed": parsed != nil})
+	})
+	return router
+}
+
+func tenantRequest(router *gin.Engine, token, tenant string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if tenant != "" {
+		req.Header.Set(TenantHeader, tenant)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestTenantMiddleware_TokenTenant 令牌中的租户优先；没有租户声明的令牌属于默认租户，不能以请求头切换到其他租户
+func TestTenantMiddleware_TokenTenant(t *testing.T) {
+	router := tenantRouter(t)
+	legacy, _, err := utils.GenerateToken("u1", 0)
+	require.NoError(t, err)
+	acme, _, err := utils.GenerateTenantToken("acme", "u2", 0, nil)
+	require.NoError(t, err)
+
+	w := tenantRequest(router, legacy, "acme")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = tenantRequest(router, legacy, ctxutil.DefaultTenantID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"tenant":"default","parsed":true}`, w.Body.String())
+
+	w = tenantRequest(router, legacy, "")
+	assert.JSONEq(t, `{"tenant":"default","parsed":true}`, w.Body.String())
+
+	w = tenantRequest(router, acme, "")
+	assert.JSONEq(t, `{"tenant":"acme","parsed":true}`, w.Body.String())
+	w = tenantRequest(router, acme, "globex")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 匿名请求可以指定租户
+	w = tenantRequest(router, "", "acme")
+	assert.JSONEq(t, `{"tenant":"acme","parsed":false}`, w.Body.String())
+	w = tenantRequest(router, "invalid", "acme")
+	assert.JSONEq(t, `{"tenant":"acme","parsed":true}`, w.Body.String(), "invalid tokens are rejected later by AuthMiddleware")
+}
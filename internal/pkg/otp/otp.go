@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/masking"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -55,13 +56,13 @@ func (s *otpService) Send(mobile string) (string, error) {
 func (s *otpService) Verify(mobile, code string) bool {
 	// 硬编码测试验证码
 	if code == "123456" {
-		log.Printf("[OTP] Using hardcoded test code for %s", mobile)
+		log.Printf("[OTP] Using hardcoded test code for %s", masking.Phone(mobile))
 		return true
 	}
 
 	// 检查是否为测试环境且使用特殊验证码
 	if s.isTestEnvironment() && config.GlobalConfig.App.TestOTPCode != "" && code == config.GlobalConfig.App.TestOTPCode {
-		log.Printf("[OTP] Test environment: using special test code for %s", mobile)
+		log.Printf("[OTP] Test environment: using special test code for %s", masking.Phone(mobile))
 		return true
 	}
 
@@ -1,7 +1,11 @@
 package registry
 
 import (
+	"context"
+	"user_crud_jwt/internal/pkg/grpcserver"
 	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/health"
+	"user_crud_jwt/pkg/lifecycle"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
@@ -12,6 +16,126 @@ type ModuleContext struct {
 	DB     *database.DB
 	Redis  *redis.Client
 	Router *gin.Engine
+	Health *health.Registry   // 模块可在此注册自身依赖的健康检查
+	GRPC   *grpcserver.Server // 未启用 gRPC 时为 nil
+	// Background 应用关闭时取消的 ctx，模块的后台循环以 Go 启动
+	Background context.Context
+	// Lifecycle 后台 goroutine 计入的组件，关闭后仍在运行的记为泄漏；为 nil 时不登记
+	Lifecycle *lifecycle.Component
+
+	// services 模块间共享的服务实例，按优先级先初始化的模块提供、后初始化的模块使用
+	services map[string]interface{}
+}
+
+// 模块间共享的服务名
+const (
+	UserService    = "user.service"
+	UserRepository = "user.repository"
+	// UserRoles 用户表中的当前角色（*service.RoleSource），main 据此设置 utils.SetRoleSource，令牌中的权限版本落后时重新解析角色
+	UserRoles        = "user.roles"
+	CouponService    = "coupon.service"
+	CouponRepository = "coupon.repository"
+	// CouponRules 优惠券规则引擎（*service.RuleEngine），订单流程用于试算，管理模块注册规则管理接口
+	CouponRules = "coupon.rules"
+	// CouponRedemption 优惠券核销（*service.RedemptionService），订单流程用于锁定、使用与退还，管理模块注册结算接口
+	CouponRedemption = "coupon.redemption"
+	MomentService    = "moment.service"
+	MomentRepository = "moment.repository"
+	// UserActivity 用户活动记录（*service.ActivityService），管理模块注册按用户查询与导出接口
+	UserActivity = "activity.service"
+	// Verification 邮箱与手机号验证（*service.VerificationService），敏感操作的路由以 handler.RequireVerified 要求已验证
+	Verification = "verification.service"
+	// Compliance 用户数据导出与账号注销（*service.ComplianceService），管理模块注册审计查询与代为注销接口
+	Compliance = "compliance.service"
+	// PermissionChecker RBAC 权限检查，由 main 登记
+	PermissionChecker = "security.permission_checker"
+	// ResponseCache GET 响应缓存与标签失效（*middleware.ResponseCache），由 main 登记
+	ResponseCache = "http.response_cache"
+	// FeatureFlags 功能开关（*features.Manager），由 main 登记
+	FeatureFlags = "features.manager"
+	// Experiments A/B 实验（*features.Experiments），由 main 登记
+	Experiments = "features.experiments"
+	// SecurityEvents 安全事件存储（security.SecurityEventStore），由 main 登记
+	SecurityEvents = "security.events"
+	// IPReputation IP 信誉与访问规则（*security.IPReputation），由 main 登记
+	IPReputation = "security.ip_reputation"
+	// SecurityMonitor 安全监控（*security.SecurityMonitor），由 main 登记
+	SecurityMonitor = "security.monitor"
+	// RoleQuotas 按 RBAC 角色的请求配额（*security.RoleQuotaManager），由 main 登记，管理模块注册配额调整接口
+	RoleQuotas = "security.role_quotas"
+	// SessionRevoker 按用户吊销令牌（*security.SessionRevoker），由 main 登记
+	SessionRevoker = "security.session_revoker"
+	// Impersonation 客服代登录会话（*security.ImpersonationManager），由 main 登记，管理模块注册开始与结束接口
+	Impersonation = "security.impersonation"
+	// Approvals 敏感管理操作的双人审批（*approvals.Manager），由 main 登记，管理模块挂载审批门禁与审批接口
+	Approvals = "admin.approvals"
+	// Jobs 后台任务队列与定时任务（*jobs.Manager），由 main 登记，各模块可注册任务类型与定时计划
+	Jobs = "jobs.manager"
+	// Payments 支付网关（*payment.Gateway），由 main 登记，管理模块注册退款与对账接口
+	Payments = "payment.gateway"
+	// Inventory 库存预留与对账（*inventory.Manager），由 main 登记，各模块登记自身资源类型的数据源
+	Inventory = "inventory.manager"
+	// DomainEvents 领域事件总线（*domainevent.Bus），由 main 登记，业务模块发布事件、关注方异步订阅
+	DomainEvents = "domain.events"
+	// Notifier 面向用户的通知分发（*notify.Dispatcher），由 main 登记
+	Notifier = "notify.dispatcher"
+	// NotifyTemplates 通知模板（*notify.Templates），由 main 登记，各模块注册自身使用的模板
+	NotifyTemplates = "notify.templates"
+	// Reports 定时运维报告（*reports.Scheduler），由 main 登记
+	Reports = "reports.scheduler"
+	// Retention 数据保留策略（*retention.Engine），由 main 登记
+	Retention = "retention.engine"
+	// Profiler 自动性能剖析（*profiling.Profiler），配置 profiling.dir 时由 main 登记
+	Profiler = "profiling.profiler"
+	// DBBloat 表与索引膨胀分析（*dbadmin.BloatAnalyzer），未关闭 database.bloat_check_minutes 时由 main 登记
+	DBBloat = "db.bloat"
+	// DBLocks 锁等待与死锁监控（*dbadmin.LockMonitor），未关闭 database.lock_check_seconds 时由 main 登记
+	DBLocks = "db.locks"
+	// TokenKeys JWT 签名密钥（*jwtkeys.Manager），启用密钥轮换时由 main 登记
+	TokenKeys = "security.token_keys"
+	// CacheAdmin 缓存运维操作与预热任务（*cache.Admin），由 main 登记，各模块可登记预热任务
+	CacheAdmin = "cache.admin"
+	// CacheWarmup 缓存预热（*cache.CacheWarmupManager），由 main 登记，各模块可登记加载器
+	CacheWarmup = "cache.warmup"
+	// CachePopularity 热门键统计（*cache.PopularityTracker），由 main 登记，各模块以 cache.TrackAccess 包装自身的缓存
+	CachePopularity = "cache.popularity"
+	// CachePreload 部署预热与就绪门禁（*cache.Preloader），由 main 登记
+	CachePreload = "cache.preload"
+	// CacheTTL 按命名空间的缓存有效期（*cache.TTLResolver），由 main 登记，各模块以 cache.WithTTLProfiles 包装自身的缓存
+	CacheTTL = "cache.ttl"
+	// CacheConsistency 缓存与数据库比对（*cache.CacheConsistencyChecker），由 main 登记，各模块按键模板登记数据源
+	CacheConsistency = "cache.consistency"
+	// CacheGeo 位置索引（*cache.GeoService），由 main 登记，各模块按图层写入坐标并查询附近的成员
+	CacheGeo = "cache.geo"
+	// FaultInjector 故障注入（*chaos.Injector），仅非 prod 构建由 main 登记
+	FaultInjector = "chaos.injector"
+)
+
+// Provide 登记供其他模块使用的服务实例，同名时覆盖
+func (c *ModuleContext) Provide(name string, svc interface{}) {
+	if c.services == nil {
+		c.services = make(map[string]interface{})
+	}
+	c.services[name] = svc
+}
+
+// Go 启动模块的后台 goroutine，fn 应在 ctx 取消（应用关闭）后返回
+func (c *ModuleContext) Go(name string, fn func(ctx context.Context)) {
+	ctx := c.Background
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.Lifecycle == nil {
+		go fn(ctx)
+		return
+	}
+	c.Lifecycle.Go(name, func() { fn(ctx) })
+}
+
+// Lookup 获取其他模块登记的服务实例
+func (c *ModuleContext) Lookup(name string) (interface{}, bool) {
+	svc, ok := c.services[name]
+	return svc, ok
 }
 
 // Module 模块接口
@@ -1,7 +1,9 @@
 package uploader
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"path/filepath"
 	"time"
@@ -13,6 +15,8 @@ import (
 
 type Uploader interface {
 	UploadFile(file *multipart.FileHeader) (string, error)
+	// UploadData 上传已读入内存的内容（如经上传检查重新编码后的图片），filename 只用于确定扩展名
+	UploadData(filename string, data []byte) (string, error)
 }
 
 type AliyunOSSUploader struct {
@@ -47,12 +51,20 @@ func (u *AliyunOSSUploader) UploadFile(file *multipart.FileHeader) (string, erro
 	}
 	defer src.Close()
 
+	return u.put(file.Filename, src)
+}
+
+func (u *AliyunOSSUploader) UploadData(filename string, data []byte) (string, error) {
+	return u.put(filename, bytes.NewReader(data))
+}
+
+func (u *AliyunOSSUploader) put(original string, src io.Reader) (string, error) {
 	// Generate unique filename: YYYYMMDD/uuid.ext
-	ext := filepath.Ext(file.Filename)
+	ext := filepath.Ext(original)
 	filename := fmt.Sprintf("%s/%s%s", time.Now().Format("20060102"), uuid.New().String(), ext)
 
 	// Upload
-	err = u.bucket.PutObject(filename, src)
+	err := u.bucket.PutObject(filename, src)
 	if err != nil {
 		return "", err
 	}
@@ -2,16 +2,18 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
-	"user_crud_jwt/internal/domain/coupon/model"
 	"user_crud_jwt/internal/domain/coupon/repository"
+	"user_crud_jwt/pkg/ctxutil"
 )
 
 type CouponTask struct {
 	UserID   string
 	CouponID string
-	Retry    int // 重试次数
+	TenantID string // 发起领取的租户，为空时不限定；落库时优惠券必须属于该租户
+	Retry    int    // 重试次数
 }
 
 type WorkerPool struct {
@@ -20,6 +22,11 @@ type WorkerPool struct {
 	Repo       repository.CouponRepository
 	WorkerNum  int
 	MaxRetry   int // 最大重试次数
+
+	// OnSuccess 领取记录落库后回调
+	OnSuccess func(task CouponTask)
+	// OnFailure 任务最终失败（重试耗尽、队列已满或库存不足）时回调，用于补偿 Redis 预扣
+	OnFailure func(task CouponTask, err error)
 }
 
 func NewWorkerPool(repo repository.CouponRepository, workerNum int, bufferSize int) *WorkerPool {
@@ -44,6 +51,14 @@ func (p *WorkerPool) Start() {
 func (p *WorkerPool) worker(id int) {
 	for task := range p.TaskQueue {
 		if err := p.processTask(task); err != nil {
+			if errors.Is(err, repository.ErrStockExhausted) || errors.Is(err, repository.ErrCouponNotFound) {
+				// 数据库库存不足或优惠券不属于该租户，重试无意义
+				log.Printf("[Worker %d] Claim rejected by database (UserID: %s, CouponID: %s): %v",
+					id, task.UserID, task.CouponID, err)
+				p.logFailedTask(task, err)
+				continue
+			}
+
 			log.Printf("[Worker %d] Failed to process task (UserID: %s, CouponID: %s): %v",
 				id, task.UserID, task.CouponID, err)
 
@@ -85,21 +100,21 @@ func (p *WorkerPool) retryWorker() {
 }
 
 func (p *WorkerPool) processTask(task CouponTask) error {
-	// 执行数据库写入操作
-	if err := p.Repo.DecreaseStock(context.Background(), task.CouponID); err != nil {
-		return err
+	// 写入领取记录与扣减库存在同一事务中完成，唯一索引保证重复任务幂等
+	// 以领取用户的身份执行，行级安全限定为该用户的领取记录；未记录租户的任务属于默认租户
+	tenantID := task.TenantID
+	if tenantID == "" {
+		tenantID = ctxutil.DefaultTenantID
 	}
-
-	userCoupon := &model.UserCoupon{
-		UserID:   task.UserID,
-		CouponID: task.CouponID,
-		Status:   1, // 1: 未使用
-	}
-
-	if err := p.Repo.CreateUserCoupon(context.Background(), userCoupon); err != nil {
+	ctx := ctxutil.WithUserID(ctxutil.WithTenantID(context.Background(), tenantID), task.UserID)
+	err := p.Repo.ClaimCoupon(ctx, task.UserID, task.CouponID)
+	if err != nil && !errors.Is(err, repository.ErrAlreadyClaimed) {
 		return err
 	}
 
+	if p.OnSuccess != nil {
+		p.OnSuccess(task)
+	}
 	return nil
 }
 
@@ -108,6 +123,9 @@ func (p *WorkerPool) logFailedTask(task CouponTask, err error) {
 	// 可以写入文件、数据库或消息队列
 	log.Printf("[DeadLetter] Task failed permanently: UserID=%s, CouponID=%s, Error=%v",
 		task.UserID, task.CouponID, err)
+	if p.OnFailure != nil {
+		p.OnFailure(task, err)
+	}
 }
 
 func (p *WorkerPool) AddTask(task CouponTask) {
@@ -0,0 +1,18 @@
+-- 事务性发件箱：业务数据与事件在同一事务中写入，由 relay 异步投递
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(128) NOT NULL,
+    event_key VARCHAR(255) NOT NULL DEFAULT '', -- 同一 key 的事件按写入顺序投递
+    dedupe_key VARCHAR(255) NOT NULL UNIQUE,    -- 消费端去重键
+    payload JSONB NOT NULL,
+    headers JSONB,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, published, dead
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events(published_at) WHERE status = 'published';
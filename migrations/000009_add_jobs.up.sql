@@ -0,0 +1,22 @@
+-- 后台任务：预热、索引分析、归档、报表等任务的持久化记录
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(128) NOT NULL,
+    payload JSONB,
+    priority INTEGER NOT NULL DEFAULT 0,           -- 数值越大越先执行
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, running, succeeded, failed, cancelled
+    unique_key VARCHAR(255) UNIQUE,                -- 防止定时任务被多个实例重复创建
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_by VARCHAR(128),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(priority DESC, run_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_status_created_at ON jobs(status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs(type);
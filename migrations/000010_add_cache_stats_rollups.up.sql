@@ -0,0 +1,13 @@
+-- 缓存统计按分钟/小时聚合，用于历史趋势查询
+CREATE TABLE IF NOT EXISTS cache_stats_rollups (
+    resolution VARCHAR(10) NOT NULL,                -- minute, hour
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    samples INTEGER NOT NULL DEFAULT 0,
+    hit_rate_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+    error_rate_sum DOUBLE PRECISION NOT NULL DEFAULT 0,
+    latency_sum_us BIGINT NOT NULL DEFAULT 0,
+    latency_max_us BIGINT NOT NULL DEFAULT 0,
+    memory_sum BIGINT NOT NULL DEFAULT 0,
+    memory_max BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (resolution, bucket_start)
+);
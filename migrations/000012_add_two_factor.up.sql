@@ -0,0 +1,11 @@
+-- 两步验证：TOTP 密钥（可加密存储）与哈希后的备用码
+CREATE TABLE IF NOT EXISTS user_two_factor (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    backup_codes TEXT[] NOT NULL DEFAULT '{}', -- SHA-256 哈希，使用后移除
+    last_used_step BIGINT NOT NULL DEFAULT 0,  -- 最近一次通过校验的时间步，防止验证码重放
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
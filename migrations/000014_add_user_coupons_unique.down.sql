@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uniq_user_coupons_user_coupon;
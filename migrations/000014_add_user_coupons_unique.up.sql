@@ -0,0 +1,12 @@
+-- 每个用户每张优惠券只能领取一次：先清理历史重复记录（保留最早一条），再加唯一索引
+DELETE FROM user_coupons a
+USING user_coupons b
+WHERE a.user_id = b.user_id
+  AND a.coupon_id = b.coupon_id
+  AND a.deleted_at IS NULL
+  AND b.deleted_at IS NULL
+  AND (a.created_at, a.id) > (b.created_at, b.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_user_coupons_user_coupon
+    ON user_coupons(user_id, coupon_id)
+    WHERE deleted_at IS NULL;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS payment_events;
+
+DROP INDEX IF EXISTS idx_orders_status_updated_at;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS refund_no;
+ALTER TABLE orders DROP COLUMN IF EXISTS refunding_amount;
+ALTER TABLE orders DROP COLUMN IF EXISTS refunded_amount;
+ALTER TABLE orders DROP COLUMN IF EXISTS trade_no;
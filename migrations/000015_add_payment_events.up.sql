@@ -0,0 +1,22 @@
+-- 订单：渠道交易号与退款进度
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS trade_no VARCHAR(64);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunding_amount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_no VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_orders_status_updated_at ON orders(status, updated_at);
+
+-- 支付回调事件：(渠道, 事件 ID) 唯一，保证重复投递的回调只处理一次
+CREATE TABLE IF NOT EXISTS payment_events (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    event_id VARCHAR(128) NOT NULL,
+    order_no VARCHAR(100) NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    payload TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_events_order_no ON payment_events(order_no);
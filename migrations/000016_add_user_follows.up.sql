@@ -0,0 +1,11 @@
+-- 关注关系：动态时间线按关注关系扇出
+CREATE TABLE IF NOT EXISTS user_follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+-- 扇出时按被关注者分批遍历粉丝
+CREATE INDEX IF NOT EXISTS idx_user_follows_followee ON user_follows(followee_id, follower_id);
@@ -0,0 +1,35 @@
+-- 用户通知偏好：语言、渠道开关、退订类别与 Webhook 地址
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    locale VARCHAR(16) NOT NULL DEFAULT '',
+    channels JSONB NOT NULL DEFAULT '{}',         -- {"email": true, "sms": false}
+    muted_categories JSONB NOT NULL DEFAULT '[]',
+    webhook_url TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 通知投递记录：每个渠道一条，记录投递状态，超过重试次数进入死信
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    template VARCHAR(128) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    locale VARCHAR(16) NOT NULL DEFAULT '',
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    html BOOLEAN NOT NULL DEFAULT FALSE,
+    dedupe_key VARCHAR(255) UNIQUE,                -- 通知去重键 + 渠道
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sent, dead, suppressed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_pending ON notification_deliveries(next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_user ON notification_deliveries(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_dead ON notification_deliveries(updated_at) WHERE status = 'dead';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_user_coupons_tenant_id;
+DROP INDEX IF EXISTS idx_coupons_tenant_id;
+DROP INDEX IF EXISTS uniq_users_tenant_mobile;
+ALTER TABLE users ADD CONSTRAINT users_mobile_key UNIQUE (mobile);
+
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE coupons DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,11 @@
+-- 多租户：业务表增加租户列，存量数据归入默认租户
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(64) NOT NULL DEFAULT 'default';
+
+-- 手机号改为租户内唯一
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_mobile_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_users_tenant_mobile ON users(tenant_id, mobile);
+
+CREATE INDEX IF NOT EXISTS idx_coupons_tenant_id ON coupons(tenant_id, id);
+CREATE INDEX IF NOT EXISTS idx_user_coupons_tenant_id ON user_coupons(tenant_id);
@@ -0,0 +1,14 @@
+-- 功能开关：按用户、角色、租户定向，按比例灰度放量
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    percentage INTEGER NOT NULL DEFAULT 0 CHECK (percentage BETWEEN 0 AND 100),
+    bucket_by VARCHAR(16) NOT NULL DEFAULT 'user', -- user, tenant
+    roles JSONB NOT NULL DEFAULT '[]',             -- 限定角色，为空时不限
+    tenants JSONB NOT NULL DEFAULT '[]',           -- 限定租户，为空时不限
+    users JSONB NOT NULL DEFAULT '[]',             -- 始终开启的用户
+    updated_by VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
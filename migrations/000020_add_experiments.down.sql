@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_orders_user_paid;
+DROP INDEX IF EXISTS idx_user_coupons_user_created;
+DROP TABLE IF EXISTS experiment_exposures;
+DROP TABLE IF EXISTS experiments;
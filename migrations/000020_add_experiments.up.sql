@@ -0,0 +1,26 @@
+-- A/B 实验：分组定义与状态，参与人群可由功能开关圈定
+CREATE TABLE IF NOT EXISTS experiments (
+    key VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    flag_key VARCHAR(64) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'draft', -- draft, running, stopped
+    variants JSONB NOT NULL DEFAULT '[]',        -- [{"name": "control", "weight": 50}]
+    started_at TIMESTAMP WITH TIME ZONE,
+    stopped_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 实验曝光：每个用户在每个实验中只记录首次曝光，作为归因的起点
+CREATE TABLE IF NOT EXISTS experiment_exposures (
+    experiment_key VARCHAR(64) NOT NULL REFERENCES experiments(key) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    variant VARCHAR(64) NOT NULL,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    exposed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (experiment_key, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiment_exposures_variant ON experiment_exposures(experiment_key, variant);
+CREATE INDEX IF NOT EXISTS idx_user_coupons_user_created ON user_coupons(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_paid ON orders(user_id, paid_at) WHERE paid_at IS NOT NULL;
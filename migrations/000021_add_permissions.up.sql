@@ -0,0 +1,10 @@
+-- 权限注册表：各服务启动时写入自己声明的权限，管理后台据此列出可授予的权限
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(128) PRIMARY KEY, -- 资源:动作，如 payment.refund:write
+    module VARCHAR(64) NOT NULL,   -- 声明该权限的模块
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_permissions_module ON permissions(module);
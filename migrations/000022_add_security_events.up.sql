@@ -0,0 +1,26 @@
+-- 安全事件：按发生时间分区，月分区由 PartitionManager 提前创建并按保留期清理
+CREATE TABLE IF NOT EXISTS security_events (
+    id VARCHAR(64) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    level VARCHAR(16) NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    source VARCHAR(32) NOT NULL DEFAULT '',
+    user_id VARCHAR(64) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    path TEXT NOT NULL DEFAULT '',
+    method VARCHAR(16) NOT NULL DEFAULT '',
+    status INTEGER NOT NULL DEFAULT 0,
+    message TEXT NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    PRIMARY KEY (id, occurred_at)
+) PARTITION BY RANGE (occurred_at);
+
+-- 分区维护失败时事件写入默认分区，不丢失
+CREATE TABLE IF NOT EXISTS security_events_default PARTITION OF security_events DEFAULT;
+
+CREATE INDEX IF NOT EXISTS idx_security_events_occurred ON security_events(occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_security_events_type ON security_events(type, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_security_events_user ON security_events(user_id, occurred_at DESC) WHERE user_id <> '';
+CREATE INDEX IF NOT EXISTS idx_security_events_ip ON security_events(ip, occurred_at DESC);
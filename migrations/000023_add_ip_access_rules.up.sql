@@ -0,0 +1,12 @@
+-- IP 访问规则：管理员维护的允许、拒绝名单，按 IP 或 CIDR 匹配，最具体的规则优先
+CREATE TABLE IF NOT EXISTS ip_access_rules (
+    id BIGSERIAL PRIMARY KEY,
+    cidr VARCHAR(64) NOT NULL,   -- 规范化后的前缀，如 10.0.0.0/8、203.0.113.7/32
+    action VARCHAR(16) NOT NULL, -- allow 或 deny
+    note TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(64) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE, -- 为空时长期有效
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ip_access_rules_expires ON ip_access_rules(expires_at);
@@ -0,0 +1,10 @@
+-- JWT 签名密钥：当前密钥 expires_at 为空，轮换后旧密钥保留到 expires_at 以验证已签发的令牌
+CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+    kid VARCHAR(32) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL, -- HS256、RS256 或 EdDSA
+    private_key TEXT NOT NULL,      -- 对称密钥或 PKCS#8 PEM，配置了加密密钥时以 enc: 开头
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_jwt_signing_keys_expires ON jwt_signing_keys(expires_at);
@@ -0,0 +1,16 @@
+-- 连接池调优事件：每次建议、修改、确认与回滚各一行，用于审计自动调优的行为
+CREATE TABLE IF NOT EXISTS pool_tuning_events (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(16) NOT NULL, -- suggested、applied、confirmed 或 rolled_back
+    from_max_open INT NOT NULL,
+    from_max_idle INT NOT NULL,
+    to_max_open INT NOT NULL,
+    to_max_idle INT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    wait_count BIGINT NOT NULL DEFAULT 0,
+    wait_duration_ms BIGINT NOT NULL DEFAULT 0,
+    in_use INT NOT NULL DEFAULT 0,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pool_tuning_events_occurred ON pool_tuning_events(occurred_at);
@@ -0,0 +1,2 @@
+-- 功能开关的乐观锁版本号：带版本号的修改只在版本一致时生效，避免并发修改互相覆盖
+ALTER TABLE feature_flags ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
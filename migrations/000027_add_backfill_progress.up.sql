@@ -0,0 +1,13 @@
+-- 分批回填进度：每个回填任务一行，记录已处理到的键，中断后从该键之后继续
+CREATE TABLE IF NOT EXISTS backfill_progress (
+    name VARCHAR(128) PRIMARY KEY,
+    table_name VARCHAR(128) NOT NULL,
+    last_key TEXT NOT NULL DEFAULT '', -- 最后一个已处理批次的最大键，空表示尚未开始
+    rows_affected BIGINT NOT NULL DEFAULT 0,
+    batches BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'running', -- running、completed 或 failed
+    last_error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS uniq_topics_name;
+ALTER TABLE topics ADD CONSTRAINT topics_name_key UNIQUE (name);
+
+DROP INDEX IF EXISTS uniq_users_tenant_id_mobile;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_users_tenant_mobile ON users(tenant_id, mobile);
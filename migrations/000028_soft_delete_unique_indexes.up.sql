@@ -0,0 +1,6 @@
+-- 软删除表的唯一性只约束未删除的行：已删除的用户、话题不再占用手机号与话题名
+DROP INDEX IF EXISTS uniq_users_tenant_mobile;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_users_tenant_id_mobile ON users(tenant_id, mobile) WHERE deleted_at IS NULL;
+
+ALTER TABLE topics DROP CONSTRAINT IF EXISTS topics_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_topics_name ON topics(name) WHERE deleted_at IS NULL;
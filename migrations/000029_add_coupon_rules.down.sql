@@ -0,0 +1,3 @@
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS order_id;
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS used_at;
+DROP TABLE IF EXISTS coupon_rules;
@@ -0,0 +1,21 @@
+-- 优惠券规则：领取条件、叠加与每人领取上限，未配置规则的优惠券只受库存与有效期限制
+CREATE TABLE IF NOT EXISTS coupon_rules (
+    coupon_id UUID PRIMARY KEY REFERENCES coupons(id) ON DELETE CASCADE,
+    new_users_only BOOLEAN NOT NULL DEFAULT FALSE,
+    new_user_days INTEGER NOT NULL DEFAULT 0 CHECK (new_user_days >= 0), -- 注册多少天内算新用户，0 时使用服务默认值
+    min_order_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    segments JSONB NOT NULL DEFAULT '[]',                                -- 限定用户分群，为空时不限
+    exclusive BOOLEAN NOT NULL DEFAULT FALSE,                            -- 不能与其他优惠券同时使用
+    stack_group VARCHAR(64) NOT NULL DEFAULT '',                         -- 同组优惠券一笔订单只能用一张
+    limit_group VARCHAR(64) NOT NULL DEFAULT '',                         -- 同组优惠券合计计算每人领取上限
+    max_per_user INTEGER NOT NULL DEFAULT 0 CHECK (max_per_user >= 0),   -- 0 表示不限
+    updated_by VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupon_rules_limit_group ON coupon_rules(limit_group) WHERE limit_group <> '';
+
+-- 核销记录：使用时间与订单号
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS used_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS order_id VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_user_coupons_locked_until;
+DROP INDEX IF EXISTS idx_user_coupons_order_id;
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS refunded_at;
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS discount;
+ALTER TABLE user_coupons DROP COLUMN IF EXISTS version;
@@ -0,0 +1,8 @@
+-- 优惠券核销状态机：1 已领取、4 已锁定、2 已使用、5 已退还、3 已过期，状态迁移使用乐观锁
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS discount DECIMAL(10, 2) NOT NULL DEFAULT 0; -- 锁定时试算的抵扣金额
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;     -- 锁定期限，超时后释放
+ALTER TABLE user_coupons ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_user_coupons_order_id ON user_coupons(order_id) WHERE order_id <> '';
+CREATE INDEX IF NOT EXISTS idx_user_coupons_locked_until ON user_coupons(locked_until) WHERE status = 4;
@@ -0,0 +1,17 @@
+-- 用户活动记录：登录、资料修改、领券、修改密码等，由领域事件异步写入，超过保留期的记录由定时任务清理
+CREATE TABLE IF NOT EXISTS user_activities (
+    id UUID PRIMARY KEY,
+    event_id VARCHAR(128) NOT NULL,                 -- 领域事件 ID，重复投递时不重复记录
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    user_id UUID NOT NULL,
+    type VARCHAR(64) NOT NULL,                      -- 事件主题，如 user.logged_in
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP -- 事件发生时间
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_user_activities_event_id ON user_activities(event_id);
+-- 时间线按 (created_at, id) 倒序翻页
+CREATE INDEX IF NOT EXISTS idx_user_activities_user_time ON user_activities(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_user_activities_created_at ON user_activities(created_at);
@@ -0,0 +1,8 @@
+-- 邮箱与手机号验证状态：记录验证时的联系方式，用户的联系方式变更后不再视为已验证
+CREATE TABLE IF NOT EXISTS user_contact_verifications (
+    user_id UUID NOT NULL,
+    channel VARCHAR(16) NOT NULL,                   -- email 或 phone
+    contact VARCHAR(128) NOT NULL,                  -- 验证通过时的邮箱或手机号
+    verified_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, channel)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS compliance_audit;
+DROP TABLE IF EXISTS account_deletions;
+DROP TABLE IF EXISTS data_exports;
@@ -0,0 +1,47 @@
+-- 用户数据导出：后台任务生成 ZIP 归档，超过下载期限后删除归档
+CREATE TABLE IF NOT EXISTS data_exports (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    user_id UUID NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',   -- pending、ready、failed、expired
+    error TEXT NOT NULL DEFAULT '',
+    archive BYTEA,
+    size BIGINT NOT NULL DEFAULT 0,
+    requested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, requested_at DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports(expires_at) WHERE status = 'ready';
+
+-- 账号注销申请：冷静期内可撤销，到期后匿名化用户数据
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    user_id UUID NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'scheduled', -- scheduled、cancelled、completed
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    requested_by VARCHAR(64) NOT NULL,
+    requested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- 每个用户同时只有一个待执行的申请
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_account_deletions_scheduled ON account_deletions(user_id) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_account_deletions_due ON account_deletions(scheduled_for) WHERE status = 'scheduled';
+
+-- 导出与注销流程的审计记录，不含个人信息，用户匿名化后仍保留
+CREATE TABLE IF NOT EXISTS compliance_audit (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    user_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    actor VARCHAR(64) NOT NULL,
+    detail JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_compliance_audit_user ON compliance_audit(user_id, id);
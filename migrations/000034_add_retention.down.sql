@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS retention_runs;
+DROP TABLE IF EXISTS retention_archive;
@@ -0,0 +1,27 @@
+-- 数据保留：归档策略删除的行以 JSON 保存在 retention_archive，每次清理记录一行执行结果
+CREATE TABLE IF NOT EXISTS retention_archive (
+    id BIGSERIAL PRIMARY KEY,
+    dataset VARCHAR(64) NOT NULL,
+    source_table VARCHAR(64) NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL, -- 原行的时间列
+    row_data JSONB NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_archive_dataset ON retention_archive(dataset, occurred_at);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id BIGSERIAL PRIMARY KEY,
+    dataset VARCHAR(64) NOT NULL,
+    action VARCHAR(16) NOT NULL, -- delete 或 archive
+    cutoff TIMESTAMP WITH TIME ZONE,
+    expired BIGINT NOT NULL DEFAULT 0,
+    excess BIGINT NOT NULL DEFAULT 0,
+    purged BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started ON retention_runs(started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_retention_runs_dataset ON retention_runs(dataset, started_at DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS admin_approval_decisions;
+DROP TABLE IF EXISTS admin_approvals;
@@ -0,0 +1,33 @@
+-- 敏感管理操作的双人审批：审批单保存待执行请求的摘要，审批记录每个审批人一行
+CREATE TABLE IF NOT EXISTS admin_approvals (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(255) NOT NULL, -- "METHOD 路由"，如 "PUT /admin/users/:id/role"
+    method VARCHAR(16) NOT NULL,
+    path VARCHAR(1024) NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    body JSONB, -- JSON 请求体，其他类型的请求体只记录摘要
+    payload_hash CHAR(64) NOT NULL, -- 方法、路径、参数与请求体的 SHA-256
+    reason TEXT NOT NULL DEFAULT '',
+    tenant_id VARCHAR(64) NOT NULL DEFAULT '',
+    requested_by VARCHAR(64) NOT NULL,
+    required_approvals INT NOT NULL DEFAULT 1,
+    status VARCHAR(16) NOT NULL, -- pending、approved、rejected、cancelled、expired、executed
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    executed_at TIMESTAMP WITH TIME ZONE,
+    execution_status INT NOT NULL DEFAULT 0 -- 执行时的 HTTP 状态码
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_approvals_created ON admin_approvals(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_approvals_status ON admin_approvals(status, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS admin_approval_decisions (
+    id BIGSERIAL PRIMARY KEY,
+    approval_id BIGINT NOT NULL REFERENCES admin_approvals(id) ON DELETE CASCADE,
+    approver_id VARCHAR(64) NOT NULL,
+    decision VARCHAR(16) NOT NULL, -- approve 或 reject
+    comment TEXT NOT NULL DEFAULT '',
+    decided_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (approval_id, approver_id)
+);
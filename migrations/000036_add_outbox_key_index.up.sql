@@ -0,0 +1,2 @@
+-- relay 按 key 查找更早的未投递事件，保证同一 key 按写入顺序投递
+CREATE INDEX IF NOT EXISTS idx_outbox_events_key_undelivered ON outbox_events(event_key, id) WHERE status IN ('pending', 'dead');
@@ -0,0 +1,251 @@
+package admission
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimiterConfig 自适应并发限制配置
+type LimiterConfig struct {
+	InitialLimit   int           `json:"initial_limit"`
+	MinLimit       int           `json:"min_limit"`
+	MaxLimit       int           `json:"max_limit"`
+	BackoffRatio   float64       `json:"backoff_ratio"`   // 过载时上限乘以该系数
+	Tolerance      float64       `json:"tolerance"`       // 延迟超过基线的倍数视为过载
+	MinBaseline    time.Duration `json:"min_baseline"`    // 基线延迟下限，避免极快的接口因微小抖动被判定过载
+	BaselineWindow time.Duration `json:"baseline_window"` // 基线取最近两个窗口内的最小延迟，延迟持续升高时基线随之上移
+	RetryAfter     time.Duration `json:"retry_after"`
+	Exclude        []string      `json:"exclude"` // 不限制的路由前缀
+}
+
+// DefaultLimiterConfig 默认自适应并发限制配置
+func DefaultLimiterConfig() *LimiterConfig {
+	return &LimiterConfig{
+		InitialLimit:   100,
+		MinLimit:       10,
+		MaxLimit:       2000,
+		BackoffRatio:   0.9,
+		Tolerance:      2,
+		MinBaseline:    10 * time.Millisecond,
+		BaselineWindow: time.Minute,
+		RetryAfter:     time.Second,
+		Exclude:        []string{"/healthz", "/readyz", "/metrics"},
+	}
+}
+
+// GroupStats 路由组的并发限制状态
+type GroupStats struct {
+	Group    string        `json:"group"`
+	Limit    int           `json:"limit"`
+	Inflight int           `json:"inflight"`
+	Baseline time.Duration `json:"baseline"`
+	Rejected int64         `json:"rejected"`
+}
+
+// Limiter 按路由组的自适应并发限制（AIMD）：请求延迟不超过基线的 Tolerance 倍且并发已用到上限一半以上时上限加一，
+// 延迟超出或下游返回 503、504 时上限乘以 BackoffRatio；超过上限的请求直接返回 503 与 Retry-After。
+// 上限下调后，下调前已开始的请求不再触发下调，避免同一批慢请求把上限压到最低
+type Limiter struct {
+	config           *LimiterConfig
+	metricsCollector *metrics.MetricsCollector
+	clock            clock.Clock
+	mu               sync.Mutex
+	groups           map[string]*groupLimit
+}
+
+type groupLimit struct {
+	limit        float64
+	inflight     int
+	rejected     int64
+	windowStart  time.Time
+	currentMin   time.Duration
+	previousMin  time.Duration
+	lastDecrease time.Time
+}
+
+// NewLimiter 创建自适应并发限制
+func NewLimiter(config *LimiterConfig, metricsCollector *metrics.MetricsCollector) *Limiter {
+	if config == nil {
+		config = DefaultLimiterConfig()
+	}
+	return &Limiter{
+		config:           config,
+		metricsCollector: metricsCollector,
+		clock:            clock.Real,
+		groups:           make(map[string]*groupLimit),
+	}
+}
+
+// SetClock 替换测量延迟使用的时钟
+func (l *Limiter) SetClock(c clock.Clock) {
+	l.clock = clock.OrReal(c)
+}
+
+// Permit 已获准的请求，完成后须调用 Release
+type Permit struct {
+	limiter *Limiter
+	group   string
+	start   time.Time
+}
+
+// Acquire 为路由组申请一个并发名额，已达上限时返回 false
+func (l *Limiter) Acquire(group string) (*Permit, bool) {
+	l.mu.Lock()
+	g := l.group(group)
+	if g.inflight >= int(g.limit) {
+		g.rejected++
+		limit, inflight := int(g.limit), g.inflight
+		l.mu.Unlock()
+		if l.metricsCollector != nil {
+			l.metricsCollector.RecordConcurrencyRejected(group)
+			l.metricsCollector.UpdateConcurrencyLimit(group, limit, inflight)
+		}
+		return nil, false
+	}
+	g.inflight++
+	limit, inflight := int(g.limit), g.inflight
+	l.mu.Unlock()
+
+	if l.metricsCollector != nil {
+		l.metricsCollector.UpdateConcurrencyLimit(group, limit, inflight)
+	}
+	return &Permit{limiter: l, group: group, start: l.clock.Now()}, true
+}
+
+// Release 归还名额并按本次延迟调整上限，dropped 表示下游已过载（如返回 503、504）
+func (p *Permit) Release(dropped bool) {
+	l := p.limiter
+	now := l.clock.Now()
+	latency := now.Sub(p.start)
+
+	l.mu.Lock()
+	g := l.group(p.group)
+	utilized := g.inflight*2 >= int(g.limit)
+	g.inflight--
+	baseline := l.observe(g, now, latency)
+
+	overloaded := dropped || float64(latency) > float64(baseline)*l.config.Tolerance
+	switch {
+	case overloaded && p.start.After(g.lastDecrease):
+		g.limit = math.Max(float64(l.config.MinLimit), math.Floor(g.limit*l.config.BackoffRatio))
+		g.lastDecrease = now
+	case !overloaded && utilized:
+		g.limit = math.Min(float64(l.config.MaxLimit), g.limit+1)
+	}
+	limit, inflight := int(g.limit), g.inflight
+	l.mu.Unlock()
+
+	if l.metricsCollector != nil {
+		l.metricsCollector.UpdateConcurrencyLimit(p.group, limit, inflight)
+	}
+}
+
+// observe 记录延迟样本，返回当前基线
+func (l *Limiter) observe(g *groupLimit, now time.Time, latency time.Duration) time.Duration {
+	if now.Sub(g.windowStart) >= l.config.BaselineWindow {
+		g.previousMin = g.currentMin
+		g.currentMin = 0
+		g.windowStart = now
+	}
+	if g.currentMin == 0 || latency < g.currentMin {
+		g.currentMin = latency
+	}
+	return l.baseline(g)
+}
+
+func (l *Limiter) baseline(g *groupLimit) time.Duration {
+	baseline := g.currentMin
+	if g.previousMin > 0 && g.previousMin < baseline {
+		baseline = g.previousMin
+	}
+	return max(baseline, l.config.MinBaseline)
+}
+
+func (l *Limiter) group(name string) *groupLimit {
+	g, ok := l.groups[name]
+	if !ok {
+		g = &groupLimit{limit: float64(l.config.InitialLimit), windowStart: l.clock.Now()}
+		l.groups[name] = g
+	}
+	return g
+}
+
+// Stats 各路由组的并发限制状态，按组名排序
+func (l *Limiter) Stats() []GroupStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]GroupStats, 0, len(l.groups))
+	for name, g := range l.groups {
+		stats = append(stats, GroupStats{
+			Group:    name,
+			Limit:    int(g.limit),
+			Inflight: g.inflight,
+			Baseline: l.baseline(g),
+			Rejected: g.rejected,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Group < stats[j].Group })
+	return stats
+}
+
+// RouteGroup 请求所属的路由组，取匹配路由的第一段路径，如 /moments/:id/comments 属于 /moments；
+// 未匹配路由或命中 Exclude 前缀时返回空字符串
+func (l *Limiter) RouteGroup(c *gin.Context) string {
+	route := c.FullPath()
+	if route == "" {
+		return ""
+	}
+	for _, prefix := range l.config.Exclude {
+		if strings.HasPrefix(route, prefix) {
+			return ""
+		}
+	}
+	if i := strings.IndexByte(route[1:], '/'); i >= 0 {
+		return route[:i+1]
+	}
+	return route
+}
+
+// Middleware 按路由组限制并发，超过上限时返回 503 与 Retry-After。
+// 优先级准入（Queue）判定为 critical 的请求已有独立预算，不受此限制
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	retryAfter := strconv.Itoa(max(int(math.Ceil(l.config.RetryAfter.Seconds())), 1))
+	return func(c *gin.Context) {
+		group := l.RouteGroup(c)
+		if group == "" || PriorityOf(c) == PriorityCritical {
+			c.Next()
+			return
+		}
+
+		permit, ok := l.Acquire(group)
+		if !ok {
+			abortOverloaded(c, retryAfter)
+			return
+		}
+		defer func() {
+			status := c.Writer.Status()
+			permit.Release(status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout)
+		}()
+		c.Next()
+	}
+}
+
+// abortOverloaded 返回 503 与 Retry-After。拒绝不记录错误日志，避免过载时日志放大
+func abortOverloaded(c *gin.Context, retryAfter string) {
+	c.Header("Retry-After", retryAfter)
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, apperrors.ErrorResponse{
+		Code:      apperrors.CodeOverloaded,
+		Message:   apperrors.CodeOverloaded.Message(apperrors.Language(c)),
+		RequestID: c.GetString("request_id"),
+	})
+}
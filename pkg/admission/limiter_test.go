@@ -0,0 +1,131 @@
+package admission
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(initial int) (*Limiter, *fakes.Clock) {
+	config := DefaultLimiterConfig()
+	config.InitialLimit = initial
+	config.MinLimit = 2
+	config.MaxLimit = 6
+	clock := fakes.NewClock(time.Time{})
+	limiter := NewLimiter(config, nil)
+	limiter.SetClock(clock)
+	return limiter, clock
+}
+
+func acquireN(t *testing.T, limiter *Limiter, group string, n int) []*Permit {
+	t.Helper()
+	permits := make([]*Permit, n)
+	for i := range permits {
+		permit, ok := limiter.Acquire(group)
+		require.True(t, ok, "permit %d", i)
+		permits[i] = permit
+	}
+	return permits
+}
+
+func limitOf(limiter *Limiter, group string) int {
+	for _, stats := range limiter.Stats() {
+		if stats.Group == group {
+			return stats.Limit
+		}
+	}
+	return -1
+}
+
+// TestLimiter_AdditiveIncrease 并发用到上限一半以上且延迟正常时上限加一，不超过 MaxLimit
+func TestLimiter_AdditiveIncrease(t *testing.T) {
+	limiter, clock := newTestLimiter(4)
+	permits := acquireN(t, limiter, "/moments", 4)
+	_, ok := limiter.Acquire("/moments")
+	assert.False(t, ok)
+
+	clock.Advance(20 * time.Millisecond)
+	for _, permit := range permits {
+		permit.Release(false)
+	}
+	// 归还前进行中的请求数分别为 4、3、2、1，只有前两次达到上限的一半
+	assert.Equal(t, 6, limitOf(limiter, "/moments"))
+
+	for _, permit := range acquireN(t, limiter, "/moments", 6) {
+		permit.Release(false)
+	}
+	assert.Equal(t, 6, limitOf(limiter, "/moments"), "capped at MaxLimit")
+	stats := limiter.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(1), stats[0].Rejected)
+	assert.Equal(t, 0, stats[0].Inflight)
+}
+
+// TestLimiter_MultiplicativeDecrease 延迟超过基线的 Tolerance 倍时按比例收缩，同一批请求只收缩一次，不低于 MinLimit
+func TestLimiter_MultiplicativeDecrease(t *testing.T) {
+	limiter, clock := newTestLimiter(6)
+	baseline := acquireN(t, limiter, "/coupons", 1)[0]
+	clock.Advance(20 * time.Millisecond)
+	baseline.Release(false)
+	assert.Equal(t, 20*time.Millisecond, limiter.Stats()[0].Baseline)
+
+	slow := acquireN(t, limiter, "/coupons", 3)
+	clock.Advance(100 * time.Millisecond)
+	for _, permit := range slow {
+		permit.Release(false)
+	}
+	assert.Equal(t, 5, limitOf(limiter, "/coupons"))
+
+	for _, want := range []int{4, 3, 2, 2} {
+		clock.Advance(time.Millisecond)
+		permit := acquireN(t, limiter, "/coupons", 1)[0]
+		permit.Release(true)
+		assert.Equal(t, want, limitOf(limiter, "/coupons"), "503 from downstream counts as overload")
+	}
+	assert.Equal(t, -1, limitOf(limiter, "/moments"), "groups are independent")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(2)
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	entered, release := make(chan struct{}), make(chan struct{})
+	router.GET("/moments/:id", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/coupons", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	done := make(chan int, 2)
+	for _, id := range []string{"/moments/1", "/moments/2"} {
+		go func(path string) { done <- serve(path).Code }(id)
+		<-entered
+	}
+
+	w := serve("/moments/3")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":50016`)
+	assert.Equal(t, http.StatusOK, serve("/coupons").Code)
+	assert.Equal(t, http.StatusOK, serve("/healthz").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/unknown").Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, []string{"/coupons", "/moments"}, []string{limiter.Stats()[0].Group, limiter.Stats()[1].Group})
+}
@@ -0,0 +1,293 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Priority 请求优先级
+type Priority int
+
+const (
+	PriorityLow      Priority = iota // 匿名请求、批量导出等可延后的流量
+	PriorityNormal                   // 已登录用户
+	PriorityCritical                 // 管理员与运维工具，过载时仍须可用
+)
+
+// Priorities 全部优先级，从高到低
+var Priorities = []Priority{PriorityCritical, PriorityNormal, PriorityLow}
+
+// String 优先级名称，用于配置与指标标签
+func (p Priority) String() string {
+	switch p {
+	case PriorityCritical:
+		return "critical"
+	case PriorityNormal:
+		return "normal"
+	case PriorityLow:
+		return "low"
+	}
+	return "unknown"
+}
+
+// ParsePriority 解析优先级名称
+func ParsePriority(name string) (Priority, error) {
+	for _, p := range Priorities {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return PriorityLow, fmt.Errorf("unknown priority %q", name)
+}
+
+var (
+	ErrQueueFull    = errors.New("admission queue is full")
+	ErrQueueTimeout = errors.New("admission queue wait timed out")
+)
+
+// ClassConfig 单个优先级的并发预算与排队
+type ClassConfig struct {
+	Concurrency  int           `json:"concurrency"`   // 同时处理的请求数
+	QueueSize    int           `json:"queue_size"`    // 预算用尽时最多排队的请求数，队列满时直接拒绝
+	QueueTimeout time.Duration `json:"queue_timeout"` // 排队超过该时长仍未获准时拒绝
+}
+
+// QueueConfig 优先级准入配置，各优先级的预算相互独立，低优先级的积压不占用高优先级的名额
+type QueueConfig struct {
+	Classes    map[Priority]ClassConfig `json:"classes"`
+	RetryAfter time.Duration            `json:"retry_after"`
+	Exclude    []string                 `json:"exclude"` // 不经过排队的路由前缀
+}
+
+// DefaultQueueConfig 默认优先级准入配置
+func DefaultQueueConfig() *QueueConfig {
+	return &QueueConfig{
+		Classes: map[Priority]ClassConfig{
+			PriorityCritical: {Concurrency: 32, QueueSize: 128, QueueTimeout: 10 * time.Second},
+			PriorityNormal:   {Concurrency: 512, QueueSize: 1024, QueueTimeout: 2 * time.Second},
+			PriorityLow:      {Concurrency: 64, QueueSize: 128, QueueTimeout: time.Second},
+		},
+		RetryAfter: time.Second,
+		Exclude:    []string{"/healthz", "/readyz", "/metrics"},
+	}
+}
+
+// ClassifyFunc 判定请求的优先级
+type ClassifyFunc func(c *gin.Context) Priority
+
+// RouteRule 按路由前缀限定优先级
+type RouteRule struct {
+	Prefix   string   `json:"prefix"`
+	Priority Priority `json:"priority"`
+}
+
+// RouteClassifier 先按调用方判定优先级，再按最长匹配的路由规则封顶：路由规则只能降低优先级，
+// 例如把批量导出降为 low，不会让普通用户获得管理员的预算
+func RouteClassifier(rules []RouteRule, identify ClassifyFunc) ClassifyFunc {
+	return func(c *gin.Context) Priority {
+		priority := identify(c)
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		matched := -1
+		for i, rule := range rules {
+			if strings.HasPrefix(route, rule.Prefix) && (matched < 0 || len(rule.Prefix) > len(rules[matched].Prefix)) {
+				matched = i
+			}
+		}
+		if matched >= 0 && rules[matched].Priority < priority {
+			return rules[matched].Priority
+		}
+		return priority
+	}
+}
+
+// QueueStats 单个优先级的准入状态
+type QueueStats struct {
+	Priority    string `json:"priority"`
+	Concurrency int    `json:"concurrency"`
+	Inflight    int    `json:"inflight"`
+	Waiting     int    `json:"waiting"`
+	Rejected    int64  `json:"rejected"`
+}
+
+// Queue 按优先级的准入队列：每个优先级有独立的并发预算，预算用尽时在该优先级的队列中按到达顺序等待，
+// 队列满或等待超时时返回 503 与 Retry-After
+type Queue struct {
+	config           *QueueConfig
+	classify         ClassifyFunc
+	metricsCollector *metrics.MetricsCollector
+	classes          map[Priority]*class
+}
+
+type class struct {
+	priority Priority
+	config   ClassConfig
+	slots    chan struct{}
+	waiting  atomic.Int64
+	rejected atomic.Int64
+	inflight atomic.Int64 // 已获准、尚未完成的请求数
+}
+
+// PriorityKey gin.Context 中保存请求优先级的键
+const PriorityKey = "admission.priority"
+
+// NewQueue 创建优先级准入队列，未配置的优先级不限制
+func NewQueue(config *QueueConfig, classify ClassifyFunc, metricsCollector *metrics.MetricsCollector) *Queue {
+	if config == nil {
+		config = DefaultQueueConfig()
+	}
+	q := &Queue{
+		config:           config,
+		classify:         classify,
+		metricsCollector: metricsCollector,
+		classes:          make(map[Priority]*class),
+	}
+	for priority, classConfig := range config.Classes {
+		if classConfig.Concurrency <= 0 {
+			continue
+		}
+		q.classes[priority] = &class{
+			priority: priority,
+			config:   classConfig,
+			slots:    make(chan struct{}, classConfig.Concurrency),
+		}
+	}
+	return q
+}
+
+// Acquire 申请指定优先级的名额，获准后须调用返回的 release
+func (q *Queue) Acquire(ctx context.Context, priority Priority) (func(), error) {
+	cl, ok := q.classes[priority]
+	if !ok {
+		return func() {}, nil
+	}
+
+	select {
+	case cl.slots <- struct{}{}:
+		return q.admit(cl), nil
+	default:
+	}
+
+	if int(cl.waiting.Add(1)) > cl.config.QueueSize {
+		cl.waiting.Add(-1)
+		return nil, q.reject(cl, ErrQueueFull, "queue_full")
+	}
+	q.report(cl)
+	start := time.Now()
+	timer := time.NewTimer(cl.config.QueueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case cl.slots <- struct{}{}:
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	cl.waiting.Add(-1)
+	if q.metricsCollector != nil {
+		q.metricsCollector.RecordPriorityQueueWait(priority.String(), time.Since(start))
+	}
+	switch {
+	case errors.Is(err, ErrQueueTimeout):
+		return nil, q.reject(cl, err, "timeout")
+	case err != nil:
+		q.report(cl)
+		return nil, err
+	}
+	return q.admit(cl), nil
+}
+
+func (q *Queue) admit(cl *class) func() {
+	cl.inflight.Add(1)
+	q.report(cl)
+	return func() {
+		cl.inflight.Add(-1)
+		<-cl.slots
+		q.report(cl)
+	}
+}
+
+func (q *Queue) reject(cl *class, err error, reason string) error {
+	cl.rejected.Add(1)
+	if q.metricsCollector != nil {
+		q.metricsCollector.RecordPriorityRejected(cl.priority.String(), reason)
+	}
+	q.report(cl)
+	return err
+}
+
+func (q *Queue) report(cl *class) {
+	if q.metricsCollector != nil {
+		q.metricsCollector.UpdatePriorityQueue(cl.priority.String(), int(cl.waiting.Load()), int(cl.inflight.Load()))
+	}
+}
+
+// Stats 各优先级的准入状态，从高到低
+func (q *Queue) Stats() []QueueStats {
+	stats := make([]QueueStats, 0, len(q.classes))
+	for _, priority := range Priorities {
+		cl, ok := q.classes[priority]
+		if !ok {
+			continue
+		}
+		stats = append(stats, QueueStats{
+			Priority:    priority.String(),
+			Concurrency: cl.config.Concurrency,
+			Inflight:    int(cl.inflight.Load()),
+			Waiting:     int(cl.waiting.Load()),
+			Rejected:    cl.rejected.Load(),
+		})
+	}
+	return stats
+}
+
+// Middleware 判定优先级并排队，优先级写入 PriorityKey 供后续中间件使用。
+// 被拒绝的请求返回 503（错误码 50016）与 Retry-After；客户端在排队中断开时直接中止
+func (q *Queue) Middleware() gin.HandlerFunc {
+	retryAfter := strconv.Itoa(max(int(math.Ceil(q.config.RetryAfter.Seconds())), 1))
+	return func(c *gin.Context) {
+		for _, prefix := range q.config.Exclude {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		priority := q.classify(c)
+		c.Set(PriorityKey, priority)
+		release, err := q.Acquire(c.Request.Context(), priority)
+		if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrQueueTimeout) {
+			abortOverloaded(c, retryAfter)
+			return
+		}
+		if err != nil {
+			c.Abort()
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// PriorityOf 请求的优先级，未经过 Queue 时为 PriorityNormal
+func PriorityOf(c *gin.Context) Priority {
+	if value, ok := c.Get(PriorityKey); ok {
+		if priority, ok := value.(Priority); ok {
+			return priority
+		}
+	}
+	return PriorityNormal
+}
@@ -0,0 +1,155 @@
+package admission
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestQueue() *Queue {
+	return NewQueue(&QueueConfig{
+		Classes: map[Priority]ClassConfig{
+			PriorityCritical: {Concurrency: 1, QueueSize: 1, QueueTimeout: time.Second},
+			PriorityNormal:   {Concurrency: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond},
+		},
+		RetryAfter: 2 * time.Second,
+	}, nil, nil)
+}
+
+// TestQueue_SeparateBudgets 普通请求积压时管理请求使用独立预算；队列满时立即拒绝，排队的请求在名额释放后按序获准
+func TestQueue_SeparateBudgets(t *testing.T) {
+	queue := newTestQueue()
+	ctx := context.Background()
+
+	release, err := queue.Acquire(ctx, PriorityNormal)
+	require.NoError(t, err)
+
+	admitted := make(chan func(), 1)
+	go func() {
+		next, err := queue.Acquire(ctx, PriorityNormal)
+		assert.NoError(t, err)
+		admitted <- next
+	}()
+	require.Eventually(t, func() bool { return queue.Stats()[1].Waiting == 1 }, time.Second, time.Millisecond)
+
+	_, err = queue.Acquire(ctx, PriorityNormal)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	critical, err := queue.Acquire(ctx, PriorityCritical)
+	require.NoError(t, err, "critical budget is independent of the normal backlog")
+	critical()
+
+	release()
+	(<-admitted)()
+
+	unlimited, err := queue.Acquire(ctx, PriorityLow)
+	require.NoError(t, err, "priorities without a budget are not limited")
+	unlimited()
+
+	assert.Equal(t, []QueueStats{
+		{Priority: "critical", Concurrency: 1},
+		{Priority: "normal", Concurrency: 1, Rejected: 1},
+	}, queue.Stats())
+}
+
+// TestQueue_Timeout 等待超过 QueueTimeout 时拒绝，调用方取消时返回 ctx 错误
+func TestQueue_Timeout(t *testing.T) {
+	queue := newTestQueue()
+	release, err := queue.Acquire(context.Background(), PriorityNormal)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = queue.Acquire(context.Background(), PriorityNormal)
+	assert.ErrorIs(t, err, ErrQueueTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = queue.Acquire(ctx, PriorityNormal)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(1), queue.Stats()[1].Rejected)
+	assert.Equal(t, 0, queue.Stats()[1].Waiting)
+}
+
+// TestRouteClassifier 最长匹配的路由规则只能降低优先级
+func TestRouteClassifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	classify := RouteClassifier([]RouteRule{
+		{Prefix: "/admin", Priority: PriorityCritical},
+		{Prefix: "/admin/users/export", Priority: PriorityLow},
+	}, func(c *gin.Context) Priority {
+		if c.GetHeader("X-Role") == "admin" {
+			return PriorityCritical
+		}
+		return PriorityNormal
+	})
+
+	cases := []struct {
+		path, role string
+		want       Priority
+	}{
+		{"/admin/cache/keys", "admin", PriorityCritical},
+		{"/admin/cache/keys", "", PriorityNormal},
+		{"/admin/users/export", "admin", PriorityLow},
+		{"/moments/feed", "admin", PriorityCritical},
+	}
+	for _, tc := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, tc.path, nil)
+		c.Request.Header.Set("X-Role", tc.role)
+		assert.Equal(t, tc.want, classify(c), "%s as %q", tc.path, tc.role)
+	}
+}
+
+// TestQueue_Middleware 被拒绝的请求返回 503 与 Retry-After；critical 请求不受自适应并发限制
+func TestQueue_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	queue := newTestQueue()
+	queue.classify = func(c *gin.Context) Priority {
+		if c.GetHeader("X-Role") == "admin" {
+			return PriorityCritical
+		}
+		return PriorityNormal
+	}
+	limiter, _ := newTestLimiter(2)
+	router := gin.New()
+	router.Use(queue.Middleware(), limiter.Middleware())
+	entered, release := make(chan struct{}), make(chan struct{})
+	router.GET("/moments/:id", func(c *gin.Context) {
+		if c.Param("id") == "slow" {
+			entered <- struct{}{}
+			<-release
+		}
+		c.String(http.StatusOK, PriorityOf(c).String())
+	})
+	serve := func(path, role string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Role", role)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan int, 1)
+	go func() { done <- serve("/moments/slow", "").Code }()
+	<-entered
+
+	w := serve("/moments/1", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "queued past the timeout")
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":50016`)
+
+	w = serve("/moments/1", "admin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "critical", w.Body.String())
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	require.Len(t, limiter.Stats(), 1)
+	assert.Equal(t, 0, limiter.Stats()[0].Inflight)
+}
@@ -0,0 +1,118 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Error 带错误码的应用错误
+type Error struct {
+	Code    Code                   `json:"code"`
+	Message string                 `json:"message,omitempty"` // 自定义提示，为空时使用错误码默认提示
+	Details map[string]interface{} `json:"details,omitempty"`
+	cause   error
+}
+
+// New 创建错误，message 为空时使用错误码默认提示
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Newf 创建格式化提示的错误
+func Newf(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Wrap 为底层错误附加错误码；err 已是应用错误时保留其错误码
+func Wrap(err error, code Code, message string) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return &Error{Code: code, Message: message, cause: err}
+}
+
+// Wrapf 同 Wrap，提示支持格式化
+func Wrapf(err error, code Code, format string, args ...interface{}) error {
+	return Wrap(err, code, fmt.Sprintf(format, args...))
+}
+
+// Error 实现 error
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Code.Message(LangEN)
+	}
+	if e.cause != nil {
+		return fmt.Sprintf("[%d] %s: %v", e.Code, msg, e.cause)
+	}
+	return fmt.Sprintf("[%d] %s", e.Code, msg)
+}
+
+// Unwrap 返回底层错误
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 错误码相同即视为同一错误，便于与哨兵错误比较
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetail 附加详情
+func (e *Error) WithDetail(key string, value interface{}) *Error {
+	clone := *e
+	clone.Details = make(map[string]interface{}, len(e.Details)+1)
+	for k, v := range e.Details {
+		clone.Details[k] = v
+	}
+	clone.Details[key] = value
+	return &clone
+}
+
+// HTTPStatus 对应的 HTTP 状态码
+func (e *Error) HTTPStatus() int {
+	return e.Code.HTTPStatus()
+}
+
+// LocalizedMessage 按语言返回提示；自定义提示不做翻译
+func (e *Error) LocalizedMessage(lang string) string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Code.Message(lang)
+}
+
+// From 将任意错误转换为应用错误：超时映射为 CodeTimeout，其余未知错误为 CodeInternal
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Code: CodeTimeout, cause: err}
+	}
+	return &Error{Code: CodeInternal, cause: err}
+}
+
+// CodeOf 获取错误码，非应用错误返回 CodeInternal
+func CodeOf(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+	return From(err).Code
+}
+
+// IsCode 判断错误链中是否包含指定错误码
+func IsCode(err error, code Code) bool {
+	var appErr *Error
+	return errors.As(err, &appErr) && appErr.Code == code
+}
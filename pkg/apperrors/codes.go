@@ -0,0 +1,184 @@
+package apperrors
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Code 错误码：1xxxx 用户、2xxxx 优惠券、3xxxx 动态、4xxxx 支付、5xxxx 系统与基础设施
+type Code int
+
+// 通用
+const (
+	CodeOK      Code = 0
+	CodeUnknown Code = 1
+)
+
+// 用户模块 100xx
+const (
+	CodeUserExists   Code = 10001
+	CodeUserNotFound Code = 10002
+	CodeAuthFailed   Code = 10003
+	CodeTokenInvalid Code = 10004
+	CodeNoPermission Code = 10005
+	CodeUserBanned   Code = 10006
+	CodeUserDeleted  Code = 10007
+	CodeInvalidOTP   Code = 10008
+
+	CodeTwoFactorRequired           Code = 10009
+	CodeTwoFactorEnrollmentRequired Code = 10010
+	CodeVerificationInvalid         Code = 10011
+	CodeContactNotVerified          Code = 10012
+)
+
+// 优惠券模块 200xx
+const (
+	CodeCouponNotFound      Code = 20001
+	CodeCouponOutOfStock    Code = 20002
+	CodeCouponClaimed       Code = 20003
+	CodeCouponNotEligible   Code = 20004
+	CodeCouponLimitReached  Code = 20005
+	CodeCouponNotApplicable Code = 20006
+)
+
+// 动态模块 300xx
+const (
+	CodeMomentNotFound   Code = 30001
+	CodeMomentNotVisible Code = 30002
+	CodeCommentNotFound  Code = 30003
+)
+
+// 支付模块 400xx
+const (
+	CodeUnsupportedChannel Code = 40001
+	CodeOrderNotFound      Code = 40002
+)
+
+// 系统与基础设施 500xx
+const (
+	CodeInternal          Code = 50001
+	CodeInvalidParam      Code = 50002
+	CodeTooManyRequests   Code = 50003
+	CodeDatabase          Code = 50004
+	CodeCache             Code = 50005
+	CodeTimeout           Code = 50006
+	CodeUnavailable       Code = 50007
+	CodeNotFound          Code = 50008
+	CodeConflict          Code = 50009
+	CodeFileTooLarge      Code = 50010
+	CodeUnsupportedFile   Code = 50011
+	CodeMalwareDetected   Code = 50012
+	CodeIPBlocked         Code = 50013
+	CodeRequestBlocked    Code = 50014
+	CodeChallengeRequired Code = 50015
+	CodeOverloaded        Code = 50016
+)
+
+// 支持的语言
+const (
+	LangEN = "en"
+	LangZH = "zh"
+)
+
+// Definition 错误码定义
+type Definition struct {
+	Code       Code
+	HTTPStatus int
+	Messages   map[string]string // 语言 -> 默认提示
+}
+
+var (
+	registry   = make(map[Code]Definition)
+	registryMu sync.RWMutex
+)
+
+// Register 注册或覆盖错误码定义，各模块可在 init 中注册自有错误码
+func Register(def Definition) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[def.Code] = def
+}
+
+// Lookup 获取错误码定义
+func Lookup(code Code) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, exists := registry[code]
+	return def, exists
+}
+
+// HTTPStatus 错误码对应的 HTTP 状态码，未注册时返回 500
+func (c Code) HTTPStatus() int {
+	if def, exists := Lookup(c); exists {
+		return def.HTTPStatus
+	}
+	return http.StatusInternalServerError
+}
+
+// Message 错误码在指定语言下的默认提示，缺失时回退到英文
+func (c Code) Message(lang string) string {
+	def, exists := Lookup(c)
+	if !exists {
+		return "unknown error"
+	}
+	if msg, ok := def.Messages[lang]; ok {
+		return msg
+	}
+	return def.Messages[LangEN]
+}
+
+func define(code Code, status int, en, zh string) {
+	Register(Definition{
+		Code:       code,
+		HTTPStatus: status,
+		Messages:   map[string]string{LangEN: en, LangZH: zh},
+	})
+}
+
+func init() {
+	define(CodeUnknown, http.StatusInternalServerError, "unknown error", "未知错误")
+
+	define(CodeUserExists, http.StatusConflict, "user already exists", "用户已存在")
+	define(CodeUserNotFound, http.StatusNotFound, "user not found", "用户不存在")
+	define(CodeAuthFailed, http.StatusUnauthorized, "authentication failed", "认证失败")
+	define(CodeTokenInvalid, http.StatusUnauthorized, "invalid or expired token", "令牌无效或已过期")
+	define(CodeNoPermission, http.StatusForbidden, "permission denied", "没有权限")
+	define(CodeUserBanned, http.StatusForbidden, "account is banned", "账号已被封禁")
+	define(CodeUserDeleted, http.StatusForbidden, "account has been deleted", "账号已注销")
+	define(CodeInvalidOTP, http.StatusBadRequest, "invalid verification code", "验证码错误")
+	define(CodeTwoFactorRequired, http.StatusUnauthorized, "two-factor authentication required", "需要两步验证")
+	define(CodeTwoFactorEnrollmentRequired, http.StatusForbidden, "two-factor enrollment required", "请先开启两步验证")
+	define(CodeVerificationInvalid, http.StatusBadRequest, "invalid or expired verification token", "验证链接无效或已过期")
+	define(CodeContactNotVerified, http.StatusForbidden, "contact is not verified", "请先完成邮箱或手机号验证")
+
+	define(CodeCouponNotFound, http.StatusNotFound, "coupon not found", "优惠券不存在")
+	define(CodeCouponOutOfStock, http.StatusConflict, "coupon out of stock", "优惠券已抢完")
+	define(CodeCouponClaimed, http.StatusConflict, "coupon already claimed", "已领取过该优惠券")
+	define(CodeCouponNotEligible, http.StatusForbidden, "not eligible for this coupon", "不满足优惠券领取条件")
+	define(CodeCouponLimitReached, http.StatusConflict, "coupon claim limit reached", "已达到优惠券领取上限")
+	define(CodeCouponNotApplicable, http.StatusUnprocessableEntity, "coupon cannot be applied to this order", "优惠券不可用于该订单")
+
+	define(CodeMomentNotFound, http.StatusNotFound, "post not found", "动态不存在")
+	define(CodeMomentNotVisible, http.StatusForbidden, "post is not approved", "动态未通过审核")
+	define(CodeCommentNotFound, http.StatusNotFound, "comment not found", "评论不存在")
+
+	define(CodeUnsupportedChannel, http.StatusBadRequest, "unsupported payment channel", "不支持的支付渠道")
+	define(CodeOrderNotFound, http.StatusNotFound, "order not found", "订单不存在")
+
+	define(CodeInternal, http.StatusInternalServerError, "internal server error", "服务器内部错误")
+	define(CodeInvalidParam, http.StatusBadRequest, "invalid parameter", "参数错误")
+	define(CodeTooManyRequests, http.StatusTooManyRequests, "too many requests", "请求过于频繁")
+	define(CodeDatabase, http.StatusInternalServerError, "database error", "数据库错误")
+	define(CodeCache, http.StatusInternalServerError, "cache error", "缓存错误")
+	define(CodeTimeout, http.StatusGatewayTimeout, "request timed out", "请求超时")
+	define(CodeUnavailable, http.StatusServiceUnavailable, "service unavailable", "服务暂不可用")
+	define(CodeNotFound, http.StatusNotFound, "resource not found", "资源不存在")
+	define(CodeConflict, http.StatusConflict, "resource conflict", "资源冲突")
+	define(CodeFileTooLarge, http.StatusRequestEntityTooLarge, "file too large", "文件过大")
+	define(CodeUnsupportedFile, http.StatusUnsupportedMediaType, "unsupported file type", "不支持的文件类型")
+	define(CodeMalwareDetected, http.StatusUnprocessableEntity, "file rejected by security scan", "文件未通过安全扫描")
+	define(CodeIPBlocked, http.StatusForbidden, "access from this IP address is blocked", "当前 IP 已被封禁")
+	define(CodeRequestBlocked, http.StatusForbidden, "request blocked by security rules", "请求被安全规则拦截")
+	define(CodeChallengeRequired, http.StatusForbidden, "challenge required", "请完成验证后重试")
+	define(CodeOverloaded, http.StatusServiceUnavailable, "server is busy, please retry later", "服务繁忙，请稍后重试")
+}
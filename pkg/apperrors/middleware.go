@@ -0,0 +1,68 @@
+package apperrors
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse 统一错误响应体
+type ErrorResponse struct {
+	Code      Code                   `json:"code"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Abort 记录错误并中止请求，由 ErrorHandler 统一渲染
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Render 立即渲染错误响应
+func Render(c *gin.Context, err error) {
+	appErr := From(err)
+	status := appErr.HTTPStatus()
+
+	// 内部错误不向客户端暴露底层细节
+	if status >= http.StatusInternalServerError {
+		log.Printf("Request %s failed: %v", c.GetString("request_id"), err)
+	}
+
+	c.JSON(status, ErrorResponse{
+		Code:      appErr.Code,
+		Message:   appErr.LocalizedMessage(Language(c)),
+		RequestID: c.GetString("request_id"),
+		Details:   appErr.Details,
+	})
+}
+
+// ErrorHandler 错误处理中间件：handler 通过 c.Error 或 Abort 上报错误，
+// 响应尚未写出时渲染最后一个错误为 {code, message, request_id}
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		Render(c, c.Errors.Last().Err)
+	}
+}
+
+// Language 根据 Accept-Language 选择提示语言，默认英文
+func Language(c *gin.Context) string {
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, LangZH):
+			return LangZH
+		case strings.HasPrefix(tag, LangEN):
+			return LangEN
+		}
+	}
+	return LangEN
+}
@@ -0,0 +1,480 @@
+package approvals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/notify"
+	"user_crud_jwt/pkg/security"
+)
+
+// EventApproval 审批单的发起、批准、驳回、撤销、过期与执行，details.action 为对应的动作
+const EventApproval security.SecurityEventType = "admin_approval"
+
+// Status 审批单状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // 等待审批
+	StatusApproved  Status = "approved"  // 达到法定人数，等待发起人执行
+	StatusRejected  Status = "rejected"  // 任一审批人驳回
+	StatusCancelled Status = "cancelled" // 发起人撤销
+	StatusExpired   Status = "expired"   // 到期前未执行
+	StatusExecuted  Status = "executed"  // 已执行，不能再次使用
+)
+
+// Decision 审批人的决定
+type Decision string
+
+const (
+	DecisionApprove Decision = "approve"
+	DecisionReject  Decision = "reject"
+)
+
+var (
+	// ErrNotFound 审批单不存在
+	ErrNotFound = errors.New("approval not found")
+	// ErrForbidden 发起人审批自己的请求、非审批人审批，或非发起人执行与撤销
+	ErrForbidden = errors.New("approval forbidden")
+	// ErrInvalidState 审批单当前状态不允许该操作，如已驳回、已执行或已过期
+	ErrInvalidState = errors.New("approval is not in a valid state for this operation")
+	// ErrAlreadyDecided 审批人已对该审批单做出决定
+	ErrAlreadyDecided = errors.New("approver has already decided")
+	// ErrMismatch 执行的请求与审批的请求不一致（路由、路径、参数或请求体不同）
+	ErrMismatch = errors.New("request does not match the approved request")
+)
+
+// TemplateRequested 通知审批人有待审批的操作
+const TemplateRequested = "admin_approval_requested"
+
+// TemplateDecided 通知发起人审批单已批准或已驳回
+const TemplateDecided = "admin_approval_decided"
+
+// notificationCategory 审批通知的业务类别
+const notificationCategory = "admin_approval"
+
+// DefaultRoutes 默认需要审批的管理操作：批量失效缓存、修改用户角色、单独授权与修改角色定义
+var DefaultRoutes = []string{
+	"POST /admin/cache/invalidate",
+	"PUT /admin/users/:id/role",
+	"POST /admin/users/:id/permissions",
+	"PUT /admin/roles/:role",
+}
+
+// Config 审批配置
+type Config struct {
+	// RequiredApprovals 发起人以外需要批准的人数，默认 1，即双人审批
+	RequiredApprovals int
+	// TTL 审批单从发起到执行的有效期
+	TTL time.Duration
+	// Routes 需要审批的操作，格式为 "METHOD 路由"，路由与注册时一致，如 "PUT /admin/users/:id/role"
+	Routes []string
+	// Approvers 收到审批通知的用户；不为空时只有其中的用户可以审批
+	Approvers []string
+	// MaxBodySize 需要审批的请求体上限，超过时拒绝
+	MaxBodySize int64
+}
+
+// DefaultConfig 默认审批配置
+func DefaultConfig() *Config {
+	return &Config{
+		RequiredApprovals: 1,
+		TTL:               time.Hour,
+		Routes:            DefaultRoutes,
+		MaxBodySize:       64 << 10,
+	}
+}
+
+// Approval 审批单：保存待执行请求的路由、路径、参数、请求体及其摘要，审批通过后发起人携带审批单 ID
+// 重新提交相同的请求才会执行，且只能执行一次
+type Approval struct {
+	ID          int64              `json:"id"`
+	Action      string             `json:"action"` // "METHOD 路由"，如 "PUT /admin/users/:id/role"
+	Method      string             `json:"method"`
+	Path        string             `json:"path"`
+	Query       string             `json:"query,omitempty"`
+	Body        json.RawMessage    `json:"body,omitempty"` // JSON 请求体，其他类型的请求体只记录摘要
+	PayloadHash string             `json:"payload_hash"`
+	Reason      string             `json:"reason,omitempty"`
+	TenantID    string             `json:"tenant_id,omitempty"`
+	RequestedBy string             `json:"requested_by"`
+	Required    int                `json:"required_approvals"`
+	Status      Status             `json:"status"`
+	Decisions   []ApprovalDecision `json:"decisions,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	ExpiresAt   time.Time          `json:"expires_at"`
+	ExecutedAt  *time.Time         `json:"executed_at,omitempty"`
+	// ExecutionStatus 执行时的 HTTP 状态码
+	ExecutionStatus int `json:"execution_status,omitempty"`
+}
+
+// ApprovalDecision 审批记录
+type ApprovalDecision struct {
+	ApproverID string    `json:"approver_id"`
+	Decision   Decision  `json:"decision"`
+	Comment    string    `json:"comment,omitempty"`
+	DecidedAt  time.Time `json:"decided_at"`
+}
+
+// Approvals 批准的人数
+func (a *Approval) Approvals() int {
+	n := 0
+	for _, d := range a.Decisions {
+		if d.Decision == DecisionApprove {
+			n++
+		}
+	}
+	return n
+}
+
+// Notifier 通知投递，由 *notify.Dispatcher 实现
+type Notifier interface {
+	Notify(ctx context.Context, n *notify.Notification) ([]*notify.Delivery, error)
+}
+
+// Manager 敏感管理操作的双人审批：需要审批的请求先生成审批单并通知审批人，发起人以外的审批人批准达到
+// 法定人数后，发起人在有效期内重新提交相同的请求执行；审批单的每一步都记录为 admin_approval 安全事件
+type Manager struct {
+	store    Store
+	notifier Notifier
+	monitor  *security.SecurityMonitor
+	config   *Config
+	routes   map[string]bool
+	now      func() time.Time
+}
+
+// NewManager 创建审批管理，notifier 为空时不发送通知，monitor 为空时不记录审计事件
+func NewManager(store Store, notifier Notifier, monitor *security.SecurityMonitor, config *Config) *Manager {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	routes := make(map[string]bool, len(config.Routes))
+	for _, route := range config.Routes {
+		if method, path, ok := strings.Cut(strings.TrimSpace(route), " "); ok {
+			routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = true
+		}
+	}
+	return &Manager{
+		store:    store,
+		notifier: notifier,
+		monitor:  monitor,
+		config:   config,
+		routes:   routes,
+		now:      time.Now,
+	}
+}
+
+// RegisterTemplates 注册审批通知的邮件模板
+func (m *Manager) RegisterTemplates(templates *notify.Templates) error {
+	items := []struct {
+		name   string
+		locale string
+		tpl    notify.Template
+	}{
+		{TemplateRequested, "zh-CN", notify.Template{
+			Subject: "待审批的管理操作 #{{.id}}",
+			Body:    "{{.requested_by}} 申请执行 {{.method}} {{.path}}，原因：{{.reason}}\n请在 {{.expires_at}} 前审批。",
+		}},
+		{TemplateRequested, "en", notify.Template{
+			Subject: "Admin operation #{{.id}} needs your approval",
+			Body:    "{{.requested_by}} requested {{.method}} {{.path}}, reason: {{.reason}}\nPlease review before {{.expires_at}}.",
+		}},
+		{TemplateDecided, "zh-CN", notify.Template{
+			Subject: "管理操作 #{{.id}} 审批结果：{{.status}}",
+			Body:    "您申请的 {{.method}} {{.path}} 审批结果为 {{.status}}，审批人：{{.approver}}。",
+		}},
+		{TemplateDecided, "en", notify.Template{
+			Subject: "Admin operation #{{.id}} was {{.status}}",
+			Body:    "Your request {{.method}} {{.path}} was {{.status}} by {{.approver}}.",
+		}},
+	}
+	for _, item := range items {
+		if err := templates.Register(item.name, notify.ChannelEmail, item.locale, item.tpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Guarded 操作是否需要审批，route 为注册的路由
+func (m *Manager) Guarded(method, route string) bool {
+	return m.routes[strings.ToUpper(method)+" "+route]
+}
+
+// Request 为待执行的请求创建审批单并通知审批人
+func (m *Manager) Request(ctx context.Context, approval *Approval) (*Approval, error) {
+	if approval.RequestedBy == "" {
+		return nil, fmt.Errorf("%w: requester is required", ErrForbidden)
+	}
+	now := m.now()
+	approval.TenantID = ctxutil.TenantID(ctx)
+	approval.Required = m.config.RequiredApprovals
+	if approval.Required < 1 {
+		approval.Required = 1
+	}
+	approval.Status = StatusPending
+	approval.Decisions = nil
+	approval.CreatedAt = now
+	approval.ExpiresAt = now.Add(m.config.TTL)
+	if err := m.store.Create(ctx, approval); err != nil {
+		return nil, err
+	}
+
+	m.record(ctx, approval, security.LevelWarning, "requested", approval.RequestedBy, nil)
+	for _, approver := range m.config.Approvers {
+		if approver != approval.RequestedBy {
+			m.notify(ctx, approver, TemplateRequested, approval, map[string]interface{}{})
+		}
+	}
+	return approval, nil
+}
+
+// Get 审批单，已过有效期的待审批或已批准的审批单标记为已过期
+func (m *Manager) Get(ctx context.Context, id int64) (*Approval, error) {
+	approval, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	m.expire(ctx, approval)
+	return approval, nil
+}
+
+// List 最近的审批单，status 为空时不限状态
+func (m *Manager) List(ctx context.Context, status Status, limit int) ([]*Approval, error) {
+	approvals, err := m.store.List(ctx, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, approval := range approvals {
+		m.expire(ctx, approval)
+	}
+	return approvals, nil
+}
+
+// Approve 批准审批单，达到法定人数后发起人即可执行；发起人不能批准自己的请求
+func (m *Manager) Approve(ctx context.Context, id int64, approverID, comment string) (*Approval, error) {
+	return m.decide(ctx, id, approverID, DecisionApprove, comment)
+}
+
+// Reject 驳回审批单，任一审批人驳回即终止
+func (m *Manager) Reject(ctx context.Context, id int64, approverID, comment string) (*Approval, error) {
+	return m.decide(ctx, id, approverID, DecisionReject, comment)
+}
+
+func (m *Manager) decide(ctx context.Context, id int64, approverID string, decision Decision, comment string) (*Approval, error) {
+	approval, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.canDecide(approval, approverID); err != nil {
+		return nil, err
+	}
+	if approval.Status != StatusPending {
+		return nil, fmt.Errorf("%w: approval %d is %s", ErrInvalidState, id, approval.Status)
+	}
+
+	now := m.now()
+	record := ApprovalDecision{ApproverID: approverID, Decision: decision, Comment: comment, DecidedAt: now}
+	if err := m.store.AddDecision(ctx, id, &record); err != nil {
+		return nil, err
+	}
+	// 写入后重新读取全部决定，并发的批准在读取之后写入时也能凑满人数
+	current, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	approval.Decisions = current.Decisions
+	details := map[string]interface{}{"comment": comment}
+
+	next := StatusPending
+	switch {
+	case decision == DecisionReject:
+		next = StatusRejected
+	case approval.Approvals() >= approval.Required:
+		next = StatusApproved
+	}
+	if next == StatusPending {
+		m.record(ctx, approval, security.LevelInfo, string(decision), approverID, details)
+		return approval, nil
+	}
+	ok, err := m.store.Transition(ctx, id, StatusPending, next, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// 并发的决定已使审批单离开待审批状态，以存储中的状态为准
+		return m.Get(ctx, id)
+	}
+	approval.Status = next
+	m.record(ctx, approval, security.LevelWarning, string(decision), approverID, details)
+	m.notify(ctx, approval.RequestedBy, TemplateDecided, approval, map[string]interface{}{"approver": approverID})
+	return approval, nil
+}
+
+// canDecide 审批人不能是发起人，也不能是 API Key；配置了审批人名单时只有名单中的用户可以审批
+func (m *Manager) canDecide(approval *Approval, approverID string) error {
+	if approverID == "" || approverID == approval.RequestedBy {
+		return fmt.Errorf("%w: requester cannot approve their own request", ErrForbidden)
+	}
+	if strings.HasPrefix(approverID, "apikey:") {
+		return fmt.Errorf("%w: api keys cannot approve requests", ErrForbidden)
+	}
+	if len(m.config.Approvers) == 0 {
+		return nil
+	}
+	for _, approver := range m.config.Approvers {
+		if approver == approverID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not an approver", ErrForbidden, approverID)
+}
+
+// Cancel 发起人撤销尚未执行的审批单
+func (m *Manager) Cancel(ctx context.Context, id int64, userID string) (*Approval, error) {
+	approval, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if userID != approval.RequestedBy {
+		return nil, fmt.Errorf("%w: only the requester can cancel approval %d", ErrForbidden, id)
+	}
+	if approval.Status != StatusPending && approval.Status != StatusApproved {
+		return nil, fmt.Errorf("%w: approval %d is %s", ErrInvalidState, id, approval.Status)
+	}
+	ok, err := m.store.Transition(ctx, id, approval.Status, StatusCancelled, m.now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: approval %d changed concurrently", ErrInvalidState, id)
+	}
+	approval.Status = StatusCancelled
+	m.record(ctx, approval, security.LevelInfo, "cancelled", userID, nil)
+	return approval, nil
+}
+
+// Consume 校验并使用已批准的审批单：须由发起人提交，路由与请求摘要须与审批时一致；
+// 审批单随即标记为已执行，不能再次使用
+func (m *Manager) Consume(ctx context.Context, id int64, requesterID, action, payloadHash string) (*Approval, error) {
+	approval, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if requesterID != approval.RequestedBy {
+		return nil, fmt.Errorf("%w: approval %d was requested by another user", ErrForbidden, id)
+	}
+	if action != approval.Action || payloadHash != approval.PayloadHash {
+		return nil, fmt.Errorf("%w: approval %d", ErrMismatch, id)
+	}
+	if approval.Status != StatusApproved {
+		return nil, fmt.Errorf("%w: approval %d is %s", ErrInvalidState, id, approval.Status)
+	}
+	now := m.now()
+	ok, err := m.store.Transition(ctx, id, StatusApproved, StatusExecuted, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: approval %d has already been used", ErrInvalidState, id)
+	}
+	approval.Status = StatusExecuted
+	approval.ExecutedAt = &now
+	return approval, nil
+}
+
+// Complete 记录执行结果
+func (m *Manager) Complete(ctx context.Context, approval *Approval, status int) {
+	approval.ExecutionStatus = status
+	if err := m.store.SetExecutionStatus(ctx, approval.ID, status); err != nil {
+		log.Printf("Failed to save execution status of approval %d: %v", approval.ID, err)
+	}
+	level := security.LevelWarning
+	if status >= 400 {
+		level = security.LevelError
+	}
+	m.record(ctx, approval, level, "executed", approval.RequestedBy, map[string]interface{}{"status": status})
+}
+
+// expire 已过有效期的待审批或已批准的审批单标记为已过期
+func (m *Manager) expire(ctx context.Context, approval *Approval) {
+	if approval.Status != StatusPending && approval.Status != StatusApproved {
+		return
+	}
+	if m.now().Before(approval.ExpiresAt) {
+		return
+	}
+	ok, err := m.store.Transition(ctx, approval.ID, approval.Status, StatusExpired, m.now())
+	if err != nil {
+		log.Printf("Failed to expire approval %d: %v", approval.ID, err)
+	}
+	approval.Status = StatusExpired
+	if ok {
+		m.record(ctx, approval, security.LevelInfo, "expired", "", nil)
+	}
+}
+
+// record 记录审批单的审计事件
+func (m *Manager) record(ctx context.Context, approval *Approval, level security.SecurityEventLevel, action, actor string, extra map[string]interface{}) {
+	if m.monitor == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"action":       action,
+		"approval_id":  approval.ID,
+		"operation":    approval.Action,
+		"path":         approval.Path,
+		"payload_hash": approval.PayloadHash,
+		"requested_by": approval.RequestedBy,
+		"status":       approval.Status,
+		"approvals":    approval.Approvals(),
+		"required":     approval.Required,
+	}
+	if approval.Reason != "" {
+		details["reason"] = approval.Reason
+	}
+	for k, v := range extra {
+		if v != "" {
+			details[k] = v
+		}
+	}
+	m.monitor.RecordEvent(ctx, security.SecurityEvent{
+		Type:    EventApproval,
+		Level:   level,
+		Source:  "approvals",
+		UserID:  actor,
+		Message: fmt.Sprintf("Approval %d for %s %s", approval.ID, approval.Action, action),
+		Details: details,
+	})
+}
+
+// notify 发送审批通知，失败只记录日志
+func (m *Manager) notify(ctx context.Context, userID, template string, approval *Approval, data map[string]interface{}) {
+	if m.notifier == nil {
+		return
+	}
+	data["id"] = approval.ID
+	data["method"] = approval.Method
+	data["path"] = approval.Path
+	data["reason"] = approval.Reason
+	data["requested_by"] = approval.RequestedBy
+	data["status"] = string(approval.Status)
+	data["expires_at"] = approval.ExpiresAt.Format(time.RFC3339)
+	_, err := m.notifier.Notify(ctx, &notify.Notification{
+		UserID:    userID,
+		Category:  notificationCategory,
+		Template:  template,
+		Data:      data,
+		Channels:  []notify.Channel{notify.ChannelEmail},
+		Critical:  true,
+		DedupeKey: fmt.Sprintf("approval:%d:%s:%s", approval.ID, approval.Status, userID),
+	})
+	if err != nil {
+		log.Printf("Failed to notify %s about approval %d: %v", userID, approval.ID, err)
+	}
+}
@@ -0,0 +1,320 @@
+package approvals
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/notify"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore 内存中的审批存储
+type memoryStore struct {
+	mu        sync.Mutex
+	nextID    int64
+	approvals map[int64]*Approval
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{approvals: make(map[int64]*Approval)}
+}
+
+func (s *memoryStore) Create(ctx context.Context, approval *Approval) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	approval.ID = s.nextID
+	stored := *approval
+	s.approvals[approval.ID] = &stored
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id int64) (*Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	approval, ok := s.approvals[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *approval
+	copied.Decisions = append([]ApprovalDecision(nil), approval.Decisions...)
+	return &copied, nil
+}
+
+func (s *memoryStore) List(ctx context.Context, status Status, limit int) ([]*Approval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var approvals []*Approval
+	for id := s.nextID; id > 0 && len(approvals) < limit; id-- {
+		if approval, ok := s.approvals[id]; ok && (status == "" || approval.Status == status) {
+			copied := *approval
+			copied.Decisions = nil
+			approvals = append(approvals, &copied)
+		}
+	}
+	return approvals, nil
+}
+
+func (s *memoryStore) AddDecision(ctx context.Context, id int64, decision *ApprovalDecision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	approval := s.approvals[id]
+	for _, d := range approval.Decisions {
+		if d.ApproverID == decision.ApproverID {
+			return ErrAlreadyDecided
+		}
+	}
+	approval.Decisions = append(approval.Decisions, *decision)
+	return nil
+}
+
+func (s *memoryStore) Transition(ctx context.Context, id int64, from, to Status, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	approval := s.approvals[id]
+	if approval.Status != from {
+		return false, nil
+	}
+	approval.Status = to
+	if to == StatusExecuted {
+		approval.ExecutedAt = &at
+	}
+	return true, nil
+}
+
+func (s *memoryStore) SetExecutionStatus(ctx context.Context, id int64, status int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvals[id].ExecutionStatus = status
+	return nil
+}
+
+// racingStore 第一次写入决定前先写入另一名审批人的批准，模拟两人读取审批单后同时批准
+type racingStore struct {
+	*memoryStore
+	rival string
+	raced bool
+}
+
+func (s *racingStore) AddDecision(ctx context.Context, id int64, decision *ApprovalDecision) error {
+	if !s.raced {
+		s.raced = true
+		rival := ApprovalDecision{ApproverID: s.rival, Decision: DecisionApprove, DecidedAt: decision.DecidedAt}
+		if err := s.memoryStore.AddDecision(ctx, id, &rival); err != nil {
+			return err
+		}
+	}
+	return s.memoryStore.AddDecision(ctx, id, decision)
+}
+
+// recordingNotifier 记录发送的通知
+type recordingNotifier struct {
+	sent []*notify.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification *notify.Notification) ([]*notify.Delivery, error) {
+	n.sent = append(n.sent, notification)
+	return nil, nil
+}
+
+// newTestRouter 以 X-Test-User 请求头模拟认证的管理路由，挂载审批门禁与审批接口
+func newTestRouter(manager *Manager, monitor *security.SecurityMonitor, executed *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	group := router.Group("/admin", func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-Test-User"))
+	}, monitor.AdminAuditMiddleware(), manager.Middleware())
+	NewHandler(manager).RegisterAdminRoutes(group)
+	group.PUT("/users/:id/role", func(c *gin.Context) {
+		var req security.UserRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		*executed++
+		c.Status(http.StatusOK)
+	})
+	group.GET("/users/:id/access", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func serve(router *gin.Engine, method, target, user, body string, headers ...string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Test-User", user)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestApprovalGate 需要审批的操作先生成审批单；发起人不能自批，另一名管理员批准后发起人携带审批单执行一次，
+// 请求体不同或再次使用时拒绝；每一步记录审计事件并通知审批人与发起人
+func TestApprovalGate(t *testing.T) {
+	monitor := security.NewSecurityMonitor(fakes.NewCache(nil), metrics.GetGlobalCollector(), security.NewDefaultSecurityLogger())
+	notifier := &recordingNotifier{}
+	config := DefaultConfig()
+	config.Approvers = []string{"alice", "bob"}
+	manager := NewManager(newMemoryStore(), notifier, monitor, config)
+	executed := 0
+	router := newTestRouter(manager, monitor, &executed)
+
+	w := serve(router, http.MethodGet, "/admin/users/42/access", "alice", "")
+	assert.Equal(t, http.StatusOK, w.Code, "routes that are not guarded run directly")
+
+	body := `{"role":"admin"}`
+	w = serve(router, http.MethodPut, "/admin/users/42/role", "alice", body, security.AuditReasonHeader, "ticket SEC-7")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 0, executed)
+	var approval Approval
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &approval))
+	assert.Equal(t, "PUT /admin/users/:id/role", approval.Action)
+	assert.Equal(t, StatusPending, approval.Status)
+	assert.Equal(t, "ticket SEC-7", approval.Reason)
+	assert.JSONEq(t, body, string(approval.Body))
+	require.Len(t, notifier.sent, 1, "the requester is not notified as an approver")
+	assert.Equal(t, "bob", notifier.sent[0].UserID)
+	assert.Equal(t, TemplateRequested, notifier.sent[0].Template)
+
+	id := strconv.FormatInt(approval.ID, 10)
+	w = serve(router, http.MethodPut, "/admin/users/42/role", "alice", body, ApprovalHeader, id)
+	assert.Equal(t, http.StatusConflict, w.Code, "not approved yet")
+	w = serve(router, http.MethodPost, "/admin/approvals/"+id+"/approve", "alice", "")
+	assert.Equal(t, http.StatusForbidden, w.Code, "requester cannot approve")
+	w = serve(router, http.MethodPost, "/admin/approvals/"+id+"/approve", "mallory", "")
+	assert.Equal(t, http.StatusForbidden, w.Code, "not in the approver list")
+
+	w = serve(router, http.MethodPost, "/admin/approvals/"+id+"/approve", "bob", `{"comment":"verified with ticket"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &approval))
+	assert.Equal(t, StatusApproved, approval.Status)
+	assert.Equal(t, "alice", notifier.sent[len(notifier.sent)-1].UserID)
+
+	w = serve(router, http.MethodPut, "/admin/users/42/role", "alice", `{"role":"super_admin"}`, ApprovalHeader, id)
+	assert.Equal(t, http.StatusConflict, w.Code, "payload differs from the approved request")
+	w = serve(router, http.MethodPut, "/admin/users/42/role", "bob", body, ApprovalHeader, id)
+	assert.Equal(t, http.StatusForbidden, w.Code, "only the requester executes")
+	w = serve(router, http.MethodPut, "/admin/users/42/role", "alice", body, ApprovalHeader, id)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, executed, "the handler reads the original body")
+	w = serve(router, http.MethodPut, "/admin/users/42/role", "alice", body, ApprovalHeader, id)
+	assert.Equal(t, http.StatusConflict, w.Code, "approvals are single use")
+	assert.Equal(t, 1, executed)
+
+	stored, err := manager.Get(context.Background(), approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusExecuted, stored.Status)
+	assert.Equal(t, http.StatusOK, stored.ExecutionStatus)
+	require.Len(t, stored.Decisions, 1)
+	assert.Equal(t, "verified with ticket", stored.Decisions[0].Comment)
+
+	var actions []interface{}
+	for _, event := range monitor.GetEvents(EventApproval, 10) {
+		actions = append(actions, event.Details["action"])
+	}
+	assert.ElementsMatch(t, []interface{}{"requested", "approve", "executed"}, actions)
+	var approvedIDs []interface{}
+	for _, event := range monitor.GetEvents(security.EventAdminAction, 20) {
+		if event.Status == http.StatusOK && event.Details["route"] == "/admin/users/:id/role" {
+			approvedIDs = append(approvedIDs, event.Details["approval_id"])
+		}
+	}
+	assert.Equal(t, []interface{}{approval.ID}, approvedIDs, "the admin audit event references the approval")
+}
+
+// TestManager_QuorumRejectAndExpiry 达到法定人数才批准，任一驳回即终止，API Key 不能审批，过期的审批单不能执行
+func TestManager_QuorumRejectAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.RequiredApprovals = 2
+	manager := NewManager(newMemoryStore(), nil, nil, config)
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+
+	request := func() *Approval {
+		approval, err := manager.Request(ctx, &Approval{
+			Action:      "POST /admin/cache/invalidate",
+			Method:      http.MethodPost,
+			Path:        "/admin/cache/invalidate",
+			PayloadHash: payloadHash(http.MethodPost, "/admin/cache/invalidate", "", []byte(`{"tags":["users"]}`)),
+			RequestedBy: "apikey:cachectl",
+		})
+		require.NoError(t, err)
+		return approval
+	}
+
+	approval := request()
+	_, err := manager.Approve(ctx, approval.ID, "apikey:secctl", "")
+	assert.ErrorIs(t, err, ErrForbidden)
+	approval, err = manager.Approve(ctx, approval.ID, "alice", "")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, approval.Status, "one of two approvals")
+	_, err = manager.Approve(ctx, approval.ID, "alice", "")
+	assert.ErrorIs(t, err, ErrAlreadyDecided)
+	approval, err = manager.Approve(ctx, approval.ID, "bob", "")
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, approval.Status)
+	_, err = manager.Reject(ctx, approval.ID, "carol", "")
+	assert.ErrorIs(t, err, ErrInvalidState, "decisions after the quorum are refused")
+
+	rejected := request()
+	_, err = manager.Approve(ctx, rejected.ID, "alice", "")
+	require.NoError(t, err)
+	rejected, err = manager.Reject(ctx, rejected.ID, "bob", "blast radius too large")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, rejected.Status)
+	_, err = manager.Consume(ctx, rejected.ID, "apikey:cachectl", rejected.Action, rejected.PayloadHash)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	now = now.Add(config.TTL)
+	_, err = manager.Consume(ctx, approval.ID, "apikey:cachectl", approval.Action, approval.PayloadHash)
+	assert.ErrorIs(t, err, ErrInvalidState)
+	expired, err := manager.Get(ctx, approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusExpired, expired.Status)
+}
+
+// TestManager_ConcurrentApprovals 两名审批人基于同一份读取同时批准时，后写入的一方按存储中的人数批准
+func TestManager_ConcurrentApprovals(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	config.RequiredApprovals = 2
+	store := &racingStore{memoryStore: newMemoryStore(), rival: "bob"}
+	manager := NewManager(store, nil, nil, config)
+
+	approval, err := manager.Request(ctx, &Approval{
+		Action:      "POST /admin/cache/invalidate",
+		Method:      http.MethodPost,
+		Path:        "/admin/cache/invalidate",
+		PayloadHash: payloadHash(http.MethodPost, "/admin/cache/invalidate", "", []byte(`{"tags":["users"]}`)),
+		RequestedBy: "apikey:cachectl",
+	})
+	require.NoError(t, err)
+
+	approval, err = manager.Approve(ctx, approval.ID, "alice", "")
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, approval.Status)
+	assert.Equal(t, 2, approval.Approvals())
+	stored, err := manager.Get(ctx, approval.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusApproved, stored.Status)
+}
@@ -0,0 +1,126 @@
+package approvals
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListQuery 审批单列表的查询参数
+type ListQuery struct {
+	Status Status `form:"status" binding:"omitempty,oneof=pending approved rejected cancelled expired executed"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// DecisionRequest 批准或驳回审批单
+type DecisionRequest struct {
+	Comment string `json:"comment" binding:"max=500"`
+}
+
+// Handler 审批管理接口
+type Handler struct {
+	manager *Manager
+}
+
+// NewHandler 创建审批管理接口
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// RegisterAdminRoutes 注册审批单查询、批准、驳回与撤销路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/approvals", h.List)
+	group.GET("/approvals/:id", h.Get)
+	group.POST("/approvals/:id/approve", h.Approve)
+	group.POST("/approvals/:id/reject", h.Reject)
+	group.DELETE("/approvals/:id", h.Cancel)
+}
+
+// List 最近的审批单，可按状态过滤
+func (h *Handler) List(c *gin.Context) {
+	var query ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+	approvals, err := h.manager.List(c.Request.Context(), query.Status, query.Limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"approvals": approvals})
+}
+
+// Get 审批单及其审批记录
+func (h *Handler) Get(c *gin.Context) {
+	id, ok := approvalID(c)
+	if !ok {
+		return
+	}
+	approval, err := h.manager.Get(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+// Approve 批准审批单
+func (h *Handler) Approve(c *gin.Context) {
+	h.decide(c, h.manager.Approve)
+}
+
+// Reject 驳回审批单
+func (h *Handler) Reject(c *gin.Context) {
+	h.decide(c, h.manager.Reject)
+}
+
+// Cancel 发起人撤销审批单
+func (h *Handler) Cancel(c *gin.Context) {
+	id, ok := approvalID(c)
+	if !ok {
+		return
+	}
+	approval, err := h.manager.Cancel(c.Request.Context(), id, c.GetString("userID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+func (h *Handler) decide(c *gin.Context, decide func(ctx context.Context, id int64, approverID, comment string) (*Approval, error)) {
+	id, ok := approvalID(c)
+	if !ok {
+		return
+	}
+	var req DecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+			return
+		}
+	}
+	approval, err := decide(c.Request.Context(), id, c.GetString("userID"), req.Comment)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, approval)
+}
+
+// approvalID 解析路径中的审批单 ID，无效时返回参数错误
+func approvalID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "invalid approval id"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,126 @@
+package approvals
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApprovalHeader 执行已批准的操作时携带的审批单 ID
+const ApprovalHeader = "X-Approval-ID"
+
+// ApprovalIDKey 已使用的审批单 ID 在上下文中的键，管理审计事件据此关联审批单
+const ApprovalIDKey = "approvalID"
+
+// Middleware 需要审批的操作未携带审批单时创建审批单并返回 202，不执行；携带已批准的审批单时
+// 校验发起人、路由与请求摘要一致后执行，审批单随即失效。挂载在认证与审计之后、注册路由之前
+func (m *Manager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if !m.Guarded(c.Request.Method, route) {
+			c.Next()
+			return
+		}
+
+		body, err := m.readBody(c)
+		if err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeFileTooLarge, err.Error()))
+			c.Abort()
+			return
+		}
+		action := c.Request.Method + " " + route
+		hash := payloadHash(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, body)
+		ctx := c.Request.Context()
+
+		header := c.GetHeader(ApprovalHeader)
+		if header == "" {
+			approval := &Approval{
+				Action:      action,
+				Method:      c.Request.Method,
+				Path:        c.Request.URL.Path,
+				Query:       c.Request.URL.RawQuery,
+				PayloadHash: hash,
+				Reason:      c.GetHeader(security.AuditReasonHeader),
+				RequestedBy: c.GetString("userID"),
+			}
+			if len(body) > 0 && json.Valid(body) {
+				approval.Body = body
+			}
+			approval, err := m.Request(ctx, approval)
+			if err != nil {
+				respondError(c, err)
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusAccepted, approval)
+			return
+		}
+
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, "invalid "+ApprovalHeader))
+			c.Abort()
+			return
+		}
+		approval, err := m.Consume(ctx, id, c.GetString("userID"), action, hash)
+		if err != nil {
+			respondError(c, err)
+			c.Abort()
+			return
+		}
+		c.Set(ApprovalIDKey, approval.ID)
+
+		c.Next()
+
+		m.Complete(ctx, approval, c.Writer.Status())
+	}
+}
+
+// readBody 读取请求体用于计算摘要，并还原供处理函数读取
+func (m *Manager) readBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, m.config.MaxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(data)) > m.config.MaxBodySize {
+		return nil, fmt.Errorf("request body exceeds %d bytes", m.config.MaxBodySize)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// payloadHash 方法、路径、查询参数与请求体的 SHA-256，审批与执行的请求须一致
+func payloadHash(method, path, query string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(strings.Join([]string{method, path, query}, "\n")))
+	h.Write([]byte("\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, err.Error()))
+	case errors.Is(err, ErrForbidden):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNoPermission, err.Error()))
+	case errors.Is(err, ErrInvalidState), errors.Is(err, ErrAlreadyDecided), errors.Is(err, ErrMismatch):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, err.Error()))
+	default:
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+	}
+}
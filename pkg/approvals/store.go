@@ -0,0 +1,203 @@
+package approvals
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// Store 审批单与审批记录
+type Store interface {
+	// Create 保存审批单并写入 ID
+	Create(ctx context.Context, approval *Approval) error
+	// Get 审批单及其审批记录，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id int64) (*Approval, error)
+	// List 按创建时间倒序的审批单，不含审批记录；status 为空时不限状态
+	List(ctx context.Context, status Status, limit int) ([]*Approval, error)
+	// AddDecision 写入审批记录，同一审批人重复决定时返回 ErrAlreadyDecided
+	AddDecision(ctx context.Context, id int64, decision *ApprovalDecision) error
+	// Transition 审批单为 from 状态时改为 to 状态并返回 true；改为已执行时记录执行时间
+	Transition(ctx context.Context, id int64, from, to Status, at time.Time) (bool, error)
+	// SetExecutionStatus 记录执行时的 HTTP 状态码
+	SetExecutionStatus(ctx context.Context, id int64, status int) error
+}
+
+// SQLStore 基于 PostgreSQL 的存储
+type SQLStore struct {
+	db *database.DB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore 创建审批存储
+func NewSQLStore(db *database.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// approvalRow admin_approvals 表的行
+type approvalRow struct {
+	ID              int64      `db:"id"`
+	Action          string     `db:"action"`
+	Method          string     `db:"method"`
+	Path            string     `db:"path"`
+	Query           string     `db:"query"`
+	Body            []byte     `db:"body"`
+	PayloadHash     string     `db:"payload_hash"`
+	Reason          string     `db:"reason"`
+	TenantID        string     `db:"tenant_id"`
+	RequestedBy     string     `db:"requested_by"`
+	Required        int        `db:"required_approvals"`
+	Status          string     `db:"status"`
+	CreatedAt       time.Time  `db:"created_at"`
+	ExpiresAt       time.Time  `db:"expires_at"`
+	ExecutedAt      *time.Time `db:"executed_at"`
+	ExecutionStatus int        `db:"execution_status"`
+}
+
+func (r *approvalRow) approval() *Approval {
+	approval := &Approval{
+		ID:              r.ID,
+		Action:          r.Action,
+		Method:          r.Method,
+		Path:            r.Path,
+		Query:           r.Query,
+		PayloadHash:     r.PayloadHash,
+		Reason:          r.Reason,
+		TenantID:        r.TenantID,
+		RequestedBy:     r.RequestedBy,
+		Required:        r.Required,
+		Status:          Status(r.Status),
+		CreatedAt:       r.CreatedAt,
+		ExpiresAt:       r.ExpiresAt,
+		ExecutedAt:      r.ExecutedAt,
+		ExecutionStatus: r.ExecutionStatus,
+	}
+	if len(r.Body) > 0 {
+		approval.Body = json.RawMessage(r.Body)
+	}
+	return approval
+}
+
+const approvalColumns = `id, action, method, path, query, body, payload_hash, reason, tenant_id, requested_by,
+	required_approvals, status, created_at, expires_at, executed_at, execution_status`
+
+// Create 保存审批单
+func (s *SQLStore) Create(ctx context.Context, approval *Approval) error {
+	var body sql.NullString
+	if len(approval.Body) > 0 {
+		body = sql.NullString{String: string(approval.Body), Valid: true}
+	}
+	query := `
+		INSERT INTO admin_approvals (action, method, path, query, body, payload_hash, reason, tenant_id, requested_by,
+			required_approvals, status, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id`
+	if err := s.db.QueryRowxContext(ctx, query, approval.Action, approval.Method, approval.Path, approval.Query, body,
+		approval.PayloadHash, approval.Reason, approval.TenantID, approval.RequestedBy, approval.Required,
+		string(approval.Status), approval.CreatedAt, approval.ExpiresAt).Scan(&approval.ID); err != nil {
+		return fmt.Errorf("failed to create approval: %w", err)
+	}
+	return nil
+}
+
+// Get 审批单及其审批记录
+func (s *SQLStore) Get(ctx context.Context, id int64) (*Approval, error) {
+	var row approvalRow
+	if err := s.db.GetContext(ctx, &row, `SELECT `+approvalColumns+` FROM admin_approvals WHERE id = $1`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get approval %d: %w", id, err)
+	}
+	approval := row.approval()
+
+	var decisions []struct {
+		ApproverID string    `db:"approver_id"`
+		Decision   string    `db:"decision"`
+		Comment    string    `db:"comment"`
+		DecidedAt  time.Time `db:"decided_at"`
+	}
+	query := `
+		SELECT approver_id, decision, comment, decided_at
+		FROM admin_approval_decisions WHERE approval_id = $1 ORDER BY decided_at, id`
+	if err := s.db.SelectContext(ctx, &decisions, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get decisions of approval %d: %w", id, err)
+	}
+	for _, d := range decisions {
+		approval.Decisions = append(approval.Decisions, ApprovalDecision{
+			ApproverID: d.ApproverID,
+			Decision:   Decision(d.Decision),
+			Comment:    d.Comment,
+			DecidedAt:  d.DecidedAt,
+		})
+	}
+	return approval, nil
+}
+
+// List 最近的审批单
+func (s *SQLStore) List(ctx context.Context, status Status, limit int) ([]*Approval, error) {
+	query := `SELECT ` + approvalColumns + `
+		FROM admin_approvals
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+	var rows []approvalRow
+	if err := s.db.SelectContext(ctx, &rows, query, string(status), limit); err != nil {
+		return nil, fmt.Errorf("failed to list approvals: %w", err)
+	}
+	approvals := make([]*Approval, len(rows))
+	for i := range rows {
+		approvals[i] = rows[i].approval()
+	}
+	return approvals, nil
+}
+
+// AddDecision 写入审批记录，(approval_id, approver_id) 唯一
+func (s *SQLStore) AddDecision(ctx context.Context, id int64, decision *ApprovalDecision) error {
+	query := `
+		INSERT INTO admin_approval_decisions (approval_id, approver_id, decision, comment, decided_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (approval_id, approver_id) DO NOTHING`
+	result, err := s.db.ExecContext(ctx, query, id, decision.ApproverID, string(decision.Decision), decision.Comment, decision.DecidedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save decision on approval %d: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save decision on approval %d: %w", id, err)
+	}
+	if n == 0 {
+		return ErrAlreadyDecided
+	}
+	return nil
+}
+
+// Transition 以状态为条件更新，并发的批准、撤销与执行只有一个生效
+func (s *SQLStore) Transition(ctx context.Context, id int64, from, to Status, at time.Time) (bool, error) {
+	query := `
+		UPDATE admin_approvals
+		SET status = $3, updated_at = $4,
+			executed_at = CASE WHEN $3 = 'executed' THEN $4 ELSE executed_at END
+		WHERE id = $1 AND status = $2`
+	result, err := s.db.ExecContext(ctx, query, id, string(from), string(to), at)
+	if err != nil {
+		return false, fmt.Errorf("failed to update approval %d: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update approval %d: %w", id, err)
+	}
+	return n == 1, nil
+}
+
+// SetExecutionStatus 记录执行结果
+func (s *SQLStore) SetExecutionStatus(ctx context.Context, id int64, status int) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE admin_approvals SET execution_status = $2 WHERE id = $1`, id, status); err != nil {
+		return fmt.Errorf("failed to update approval %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+)
+
+var (
+	// ErrEntryNotFound 缓存中没有该键
+	ErrEntryNotFound = errors.New("cache entry not found")
+	// ErrWarmupNotFound 没有登记该预热任务
+	ErrWarmupNotFound = errors.New("warmup task not found")
+	// ErrWarmupRunning 预热任务正在执行
+	ErrWarmupRunning = errors.New("warmup task is already running")
+	// ErrInvalidNamespace 命名空间为空或包含通配符
+	ErrInvalidNamespace = errors.New("namespace must be a non-empty key prefix without wildcards")
+)
+
+// 缓存管理事件类型
+const (
+	AdminEventSet                 = "set"
+	AdminEventDelete              = "delete"
+	AdminEventInvalidateTags      = "invalidate_tags"
+	AdminEventInvalidateNamespace = "invalidate_namespace"
+	AdminEventWarmup              = "warmup"
+	AdminEventTTLProfile          = "ttl_profile"
+)
+
+// AdminConfig 缓存管理配置
+type AdminConfig struct {
+	EventBuffer       int                // 保留的最近事件数
+	SubscriberBuffer  int                // 每个订阅者的缓冲，订阅者处理不及时时丢弃事件
+	DefaultTTL        time.Duration      // 写入未指定有效期时使用
+	InvalidateOptions *InvalidateOptions // 按命名空间失效时的 SCAN/UNLINK 配置
+}
+
+// DefaultAdminConfig 默认缓存管理配置
+func DefaultAdminConfig() *AdminConfig {
+	return &AdminConfig{
+		EventBuffer:       500,
+		SubscriberBuffer:  64,
+		DefaultTTL:        time.Hour,
+		InvalidateOptions: DefaultInvalidateOptions(),
+	}
+}
+
+// AdminEvent 缓存变更事件：管理接口的操作，以及进程内任意调用方触发的标签失效
+type AdminEvent struct {
+	ID     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Target string    `json:"target"`           // 键、标签、命名空间或预热任务名
+	Actor  string    `json:"actor,omitempty"`  // 管理接口的调用方，应用内部触发时为空
+	Detail string    `json:"detail,omitempty"` // 如失效删除的键数、预热结果
+}
+
+// EntryInfo 缓存条目
+type EntryInfo struct {
+	Key        string          `json:"key"`
+	TTLSeconds float64         `json:"ttl_seconds"` // 0 表示没有过期时间
+	Size       int             `json:"size"`        // JSON 编码后的字节数
+	Value      json.RawMessage `json:"value"`
+}
+
+// WarmupFunc 预热任务，ctx 带有触发请求的租户
+type WarmupFunc func(ctx context.Context) (*WarmupResult, error)
+
+// WarmupInfo 已登记的预热任务
+type WarmupInfo struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Running     bool          `json:"running"`
+	LastRun     *WarmupResult `json:"last_run,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+}
+
+type warmupTask struct {
+	info WarmupInfo
+	run  WarmupFunc
+}
+
+// NamespaceInvalidation 按命名空间失效的结果，Deleted 为 -1 表示存储不支持统计删除数
+type NamespaceInvalidation struct {
+	Namespace string `json:"namespace"`
+	Pattern   string `json:"pattern"`
+	Deleted   int64  `json:"deleted"`
+}
+
+// patternInvalidator 能返回删除进度的模式失效，RedisCache 满足
+type patternInvalidator interface {
+	InvalidatePatternWithOptions(ctx context.Context, pattern string, opts *InvalidateOptions) (*InvalidateProgress, error)
+}
+
+// Admin 缓存运维操作：查看、写入与删除键，执行预热任务，按标签或命名空间失效，
+// 查看命中率与最近的变更事件。事件只保存在本实例内存中
+type Admin struct {
+	store            CacheService
+	tags             *TagInvalidationStrategy
+	ttls             *TTLResolver
+	memory           *MemoryAnalyzer
+	config           *AdminConfig
+	metricsCollector *metrics.MetricsCollector
+
+	mu          sync.Mutex
+	warmups     map[string]*warmupTask
+	events      []AdminEvent
+	lastID      uint64
+	subscribers map[chan AdminEvent]struct{}
+}
+
+// NewAdmin 创建缓存管理，store 为各实例共享的缓存，tags 为空时不支持按标签失效
+func NewAdmin(store CacheService, tags *TagInvalidationStrategy, metricsCollector *metrics.MetricsCollector, config *AdminConfig) *Admin {
+	if config == nil {
+		config = DefaultAdminConfig()
+	}
+	a := &Admin{
+		store:            store,
+		tags:             tags,
+		config:           config,
+		metricsCollector: metricsCollector,
+		warmups:          make(map[string]*warmupTask),
+		subscribers:      make(map[chan AdminEvent]struct{}),
+	}
+	if tags != nil {
+		tags.OnInvalidate(func(ctx context.Context, invalidated []string) {
+			a.publish(AdminEventInvalidateTags, strings.Join(invalidated, ","), actorFrom(ctx), "")
+		})
+	}
+	return a
+}
+
+type actorKey struct{}
+
+// withActor 记录管理接口的调用方，标签失效回调据此填写事件的 Actor
+func withActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// Inspect 读取条目的值与剩余有效期
+func (a *Admin) Inspect(ctx context.Context, key string) (*EntryInfo, error) {
+	var value json.RawMessage
+	ttl, err := a.store.GetWithTTL(ctx, key, &value)
+	if err != nil {
+		if exists, existsErr := a.store.Exists(ctx, key); existsErr == nil && !exists {
+			return nil, ErrEntryNotFound
+		}
+		return nil, fmt.Errorf("failed to get cache entry %s: %w", key, err)
+	}
+	return &EntryInfo{Key: key, TTLSeconds: ttl.Seconds(), Size: len(value), Value: value}, nil
+}
+
+// Set 写入 JSON 值，ttl 为 0 时使用默认有效期
+func (a *Admin) Set(ctx context.Context, key string, value json.RawMessage, ttl time.Duration, actor string) error {
+	if !json.Valid(value) {
+		return fmt.Errorf("value of %s is not valid JSON", key)
+	}
+	if ttl <= 0 {
+		ttl = a.config.DefaultTTL
+	}
+	if err := a.store.Set(ctx, key, value, ttl); err != nil {
+		return fmt.Errorf("failed to set cache entry %s: %w", key, err)
+	}
+	a.publish(AdminEventSet, key, actor, fmt.Sprintf("ttl=%s size=%d", ttl, len(value)))
+	return nil
+}
+
+// Delete 删除键，键不存在时不报错
+func (a *Admin) Delete(ctx context.Context, key, actor string) error {
+	if err := a.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete cache entry %s: %w", key, err)
+	}
+	a.publish(AdminEventDelete, key, actor, "")
+	return nil
+}
+
+// InvalidateTags 更新标签版本号，依赖这些标签的缓存条目（如响应缓存）随之失效
+func (a *Admin) InvalidateTags(ctx context.Context, tags []string, actor string) error {
+	if a.tags == nil {
+		return errors.New("tag invalidation is not configured")
+	}
+	return a.tags.InvalidateTags(withActor(ctx, actor), tags...)
+}
+
+// InvalidateNamespace 删除以 "<namespace>:" 开头的全部键，如 graphql:coupon 或 tenant:acme
+func (a *Admin) InvalidateNamespace(ctx context.Context, namespace, actor string) (*NamespaceInvalidation, error) {
+	namespace = strings.TrimSuffix(namespace, ":")
+	if namespace == "" || strings.ContainsAny(namespace, "*?[]\\") {
+		return nil, ErrInvalidNamespace
+	}
+	result := &NamespaceInvalidation{Namespace: namespace, Pattern: namespace + ":*", Deleted: -1}
+	if invalidator, ok := a.store.(patternInvalidator); ok {
+		progress, err := invalidator.InvalidatePatternWithOptions(ctx, result.Pattern, a.config.InvalidateOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to invalidate namespace %s: %w", namespace, err)
+		}
+		result.Deleted = progress.Deleted
+	} else if err := a.store.InvalidatePattern(ctx, result.Pattern); err != nil {
+		return nil, fmt.Errorf("failed to invalidate namespace %s: %w", namespace, err)
+	}
+
+	detail := ""
+	if result.Deleted >= 0 {
+		detail = fmt.Sprintf("deleted=%d", result.Deleted)
+	}
+	a.publish(AdminEventInvalidateNamespace, namespace, actor, detail)
+	return result, nil
+}
+
+// RegisterWarmup 登记可由管理接口触发的预热任务，同名时覆盖
+func (a *Admin) RegisterWarmup(name, description string, run WarmupFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.warmups[name] = &warmupTask{info: WarmupInfo{Name: name, Description: description}, run: run}
+}
+
+// Warmups 已登记的预热任务及最近一次执行结果，按名称排序
+func (a *Admin) Warmups() []WarmupInfo {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	infos := make([]WarmupInfo, 0, len(a.warmups))
+	for _, task := range a.warmups {
+		infos = append(infos, task.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// RunWarmup 执行预热任务并等待完成，同一任务不并发执行
+func (a *Admin) RunWarmup(ctx context.Context, name, actor string) (*WarmupResult, error) {
+	a.mu.Lock()
+	task, ok := a.warmups[name]
+	if !ok {
+		a.mu.Unlock()
+		return nil, ErrWarmupNotFound
+	}
+	if task.info.Running {
+		a.mu.Unlock()
+		return nil, ErrWarmupRunning
+	}
+	task.info.Running = true
+	a.mu.Unlock()
+
+	start := time.Now()
+	result, err := task.run(ctx)
+	if result != nil {
+		result.Strategy = name
+		if result.StartTime.IsZero() {
+			result.StartTime = start
+		}
+		result.EndTime = time.Now()
+		result.Duration = result.EndTime.Sub(result.StartTime)
+	}
+
+	a.mu.Lock()
+	task.info.Running = false
+	task.info.LastRun = result
+	task.info.LastError = ""
+	if err != nil {
+		task.info.LastError = err.Error()
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		a.publish(AdminEventWarmup, name, actor, "failed: "+err.Error())
+		return result, fmt.Errorf("failed to run warmup %s: %w", name, err)
+	}
+	a.metricsCollector.RecordEvent("cache_warmup", name, result.SuccessKeys)
+	a.publish(AdminEventWarmup, name, actor, fmt.Sprintf("keys=%d success=%d failed=%d", result.TotalKeys, result.SuccessKeys, result.FailedKeys))
+	return result, nil
+}
+
+// SetTTLResolver 启用 TTL 配置的查看与运行时调整
+func (a *Admin) SetTTLResolver(resolver *TTLResolver) {
+	a.ttls = resolver
+}
+
+// TTLProfiles 当前生效的 TTL 配置
+func (a *Admin) TTLProfiles() ([]TTLProfile, error) {
+	if a.ttls == nil {
+		return nil, errors.New("ttl profiles are not configured")
+	}
+	return a.ttls.Profiles(), nil
+}
+
+// SetTTLProfile 调整命名空间的有效期，之后的写入生效，已写入的键保持原有效期
+func (a *Admin) SetTTLProfile(ctx context.Context, profile TTLProfile, actor string) (*TTLProfile, error) {
+	if a.ttls == nil {
+		return nil, errors.New("ttl profiles are not configured")
+	}
+	saved, err := a.ttls.SetProfile(ctx, profile, actor)
+	if err != nil {
+		return nil, err
+	}
+	a.publish(AdminEventTTLProfile, saved.Namespace, actor, fmt.Sprintf("ttl=%s local_ttl=%s", saved.TTL, saved.LocalTTL))
+	return saved, nil
+}
+
+// ResetTTLProfile 撤销命名空间的运行时调整，恢复配置文件中的有效期
+func (a *Admin) ResetTTLProfile(ctx context.Context, namespace, actor string) error {
+	if a.ttls == nil {
+		return errors.New("ttl profiles are not configured")
+	}
+	if err := a.ttls.ResetProfile(ctx, namespace); err != nil {
+		return err
+	}
+	a.publish(AdminEventTTLProfile, namespace, actor, "reset")
+	return nil
+}
+
+// SetMemoryAnalyzer 启用容量分析的查看与有效期调整推算
+func (a *Admin) SetMemoryAnalyzer(analyzer *MemoryAnalyzer) {
+	a.memory = analyzer
+}
+
+// MemoryReport 最近一次容量分析的报告，refresh 为 true 或尚未分析时立即采样
+func (a *Admin) MemoryReport(ctx context.Context, refresh bool) (*MemoryReport, error) {
+	if a.memory == nil {
+		return nil, errors.New("memory analysis is not configured")
+	}
+	if report := a.memory.Last(); report != nil && !refresh {
+		return report, nil
+	}
+	return a.memory.Analyze(ctx)
+}
+
+// ModelTTL 按最近一次容量分析推算把命名空间的有效期改为 ttl 后的内存占用
+func (a *Admin) ModelTTL(ctx context.Context, namespace string, ttl time.Duration) (*TTLImpact, error) {
+	report, err := a.MemoryReport(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return ModelTTL(report, strings.TrimSuffix(namespace, ":"), ttl)
+}
+
+// Stats 进程启动以来各缓存的命中统计
+func (a *Admin) Stats() []metrics.CacheHitStat {
+	return a.metricsCollector.CacheHitStats()
+}
+
+// CallerStats 进程启动以来按调用方（HTTP 路由、gRPC 方法）汇总的缓存命中统计
+func (a *Admin) CallerStats() []metrics.CacheCallerStat {
+	return a.metricsCollector.CacheCallerStats()
+}
+
+// Events 编号大于 afterID 的最近事件，按编号升序
+func (a *Admin) Events(afterID uint64) []AdminEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := sort.Search(len(a.events), func(i int) bool { return a.events[i].ID > afterID })
+	return append([]AdminEvent(nil), a.events[i:]...)
+}
+
+// Subscribe 订阅新事件，返回的函数取消订阅并关闭通道
+func (a *Admin) Subscribe() (<-chan AdminEvent, func()) {
+	ch := make(chan AdminEvent, a.config.SubscriberBuffer)
+	a.mu.Lock()
+	a.subscribers[ch] = struct{}{}
+	a.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			a.mu.Lock()
+			delete(a.subscribers, ch)
+			a.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish 记录事件并推送给订阅者
+func (a *Admin) publish(eventType, target, actor, detail string) {
+	if actor != "" {
+		log.Printf("Cache admin: %s %s by %s %s", eventType, target, actor, detail)
+	}
+	a.metricsCollector.RecordEvent("cache_admin", eventType, 1)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastID++
+	event := AdminEvent{ID: a.lastID, Time: time.Now(), Type: eventType, Target: target, Actor: actor, Detail: detail}
+	a.events = append(a.events, event)
+	if overflow := len(a.events) - a.config.EventBuffer; overflow > 0 {
+		a.events = append(a.events[:0:0], a.events[overflow:]...)
+	}
+	for ch := range a.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// 订阅者处理不及时，丢弃事件而不阻塞缓存操作
+		}
+	}
+}
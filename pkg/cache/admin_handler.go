@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminHeartbeat 事件流空闲时发送注释行的间隔，避免代理断开空闲连接
+const adminHeartbeat = 15 * time.Second
+
+// AdminHandler 缓存管理接口，cmd/cachectl 的服务端
+type AdminHandler struct {
+	admin *Admin
+}
+
+// NewAdminHandler 创建缓存管理接口
+func NewAdminHandler(admin *Admin) *AdminHandler {
+	return &AdminHandler{admin: admin}
+}
+
+// RegisterAdminRoutes 注册缓存管理路由，调用方需挂载管理员权限校验
+func (h *AdminHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/cache/entries", h.GetEntry)
+	group.PUT("/cache/entries", h.SetEntry)
+	group.DELETE("/cache/entries", h.DeleteEntry)
+	group.GET("/cache/warmups", h.ListWarmups)
+	group.POST("/cache/warmups/:name", h.RunWarmup)
+	group.GET("/cache/stats", h.Stats)
+	group.POST("/cache/invalidate", h.Invalidate)
+	group.GET("/cache/events", h.Events)
+	group.GET("/cache/ttl", h.ListTTLProfiles)
+	group.PUT("/cache/ttl", h.SetTTLProfile)
+	group.DELETE("/cache/ttl", h.ResetTTLProfile)
+	group.GET("/cache/memory", h.Memory)
+	group.GET("/cache/memory/ttl-impact", h.TTLImpact)
+}
+
+// SetEntryRequest 写入缓存条目
+type SetEntryRequest struct {
+	Key        string          `json:"key" binding:"required"`
+	Value      json.RawMessage `json:"value" binding:"required"`
+	TTLSeconds int             `json:"ttl_seconds" binding:"min=0"` // 0 使用默认有效期
+}
+
+// SetTTLProfileRequest 调整命名空间的有效期，0 表示该级缓存使用调用方的默认值
+type SetTTLProfileRequest struct {
+	Namespace       string `json:"namespace" binding:"required"`
+	TTLSeconds      int    `json:"ttl_seconds" binding:"min=0"`
+	LocalTTLSeconds int    `json:"local_ttl_seconds" binding:"min=0"`
+}
+
+// InvalidateRequest 按标签或命名空间失效，至少指定一项
+type InvalidateRequest struct {
+	Tags      []string `json:"tags"`
+	Namespace string   `json:"namespace"`
+}
+
+// InvalidateResponse 失效结果
+type InvalidateResponse struct {
+	Tags      []string               `json:"tags,omitempty"`
+	Namespace *NamespaceInvalidation `json:"namespace,omitempty"`
+}
+
+// GetEntry 查看键的值、大小与剩余有效期，键通过 ?key= 传入
+func (h *AdminHandler) GetEntry(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "key is required"))
+		return
+	}
+	entry, err := h.admin.Inspect(c.Request.Context(), key)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// SetEntry 写入 JSON 值
+func (h *AdminHandler) SetEntry(c *gin.Context) {
+	var req SetEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if err := h.admin.Set(c.Request.Context(), req.Key, req.Value, ttl, c.GetString("userID")); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteEntry 删除键，键不存在时同样返回 204
+func (h *AdminHandler) DeleteEntry(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "key is required"))
+		return
+	}
+	if err := h.admin.Delete(c.Request.Context(), key, c.GetString("userID")); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWarmups 已登记的预热任务
+func (h *AdminHandler) ListWarmups(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"warmups": h.admin.Warmups(),
+	})
+}
+
+// RunWarmup 执行预热任务，完成后返回结果
+func (h *AdminHandler) RunWarmup(c *gin.Context) {
+	result, err := h.admin.RunWarmup(c.Request.Context(), c.Param("name"), c.GetString("userID"))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Stats 各缓存的命中统计
+func (h *AdminHandler) Stats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"caches":  h.admin.Stats(),
+		"callers": h.admin.CallerStats(),
+	})
+}
+
+// Invalidate 按标签与命名空间失效
+func (h *AdminHandler) Invalidate(c *gin.Context) {
+	var req InvalidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if len(req.Tags) == 0 && req.Namespace == "" {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "tags or namespace is required"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	actor := c.GetString("userID")
+	var resp InvalidateResponse
+	if len(req.Tags) > 0 {
+		if err := h.admin.InvalidateTags(ctx, req.Tags, actor); err != nil {
+			respondAdminError(c, err)
+			return
+		}
+		resp.Tags = req.Tags
+	}
+	if req.Namespace != "" {
+		result, err := h.admin.InvalidateNamespace(ctx, req.Namespace, actor)
+		if err != nil {
+			respondAdminError(c, err)
+			return
+		}
+		resp.Namespace = result
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Events 最近的缓存变更事件；?after= 只返回该编号之后的事件，
+// ?follow=true 时以 text/event-stream 先推送已有事件，再持续推送新事件直到客户端断开
+func (h *AdminHandler) Events(c *gin.Context) {
+	var after uint64
+	if value := c.Query("after"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, "after must be an event id"))
+			return
+		}
+		after = parsed
+	}
+	if follow, _ := strconv.ParseBool(c.Query("follow")); !follow {
+		c.JSON(http.StatusOK, gin.H{
+			"events": h.admin.Events(after),
+		})
+		return
+	}
+
+	// 先订阅再读取已有事件，两者之间发生的事件按编号去重
+	events, cancel := h.admin.Subscribe()
+	defer cancel()
+	backlog := h.admin.Events(after)
+	for _, event := range backlog {
+		after = event.ID
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	for _, event := range backlog {
+		c.SSEvent("cache", event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(adminHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if event.ID > after {
+				after = event.ID
+				c.SSEvent("cache", event)
+			}
+		case <-heartbeat.C:
+			_, _ = io.WriteString(w, ": heartbeat\n\n")
+		}
+		return true
+	})
+}
+
+// ListTTLProfiles 当前生效的 TTL 配置
+func (h *AdminHandler) ListTTLProfiles(c *gin.Context) {
+	profiles, err := h.admin.TTLProfiles()
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// SetTTLProfile 运行时调整命名空间的有效期，各实例在下次刷新时生效
+func (h *AdminHandler) SetTTLProfile(c *gin.Context) {
+	var req SetTTLProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	profile, err := h.admin.SetTTLProfile(c.Request.Context(), TTLProfile{
+		Namespace: req.Namespace,
+		TTL:       time.Duration(req.TTLSeconds) * time.Second,
+		LocalTTL:  time.Duration(req.LocalTTLSeconds) * time.Second,
+	}, c.GetString("userID"))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// ResetTTLProfile 撤销命名空间的运行时调整，命名空间通过 ?namespace= 传入
+func (h *AdminHandler) ResetTTLProfile(c *gin.Context) {
+	namespace := c.Query("namespace")
+	if namespace == "" {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "namespace is required"))
+		return
+	}
+	if err := h.admin.ResetTTLProfile(c.Request.Context(), namespace, c.GetString("userID")); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Memory 最近一次容量分析：各命名空间的内存占用、最大的键与 maxmemory-policy 建议，?refresh=true 时重新采样
+func (h *AdminHandler) Memory(c *gin.Context) {
+	refresh, _ := strconv.ParseBool(c.Query("refresh"))
+	report, err := h.admin.MemoryReport(c.Request.Context(), refresh)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// TTLImpact 推算调整命名空间有效期后的内存占用，通过 ?namespace=&ttl_seconds= 传入
+func (h *AdminHandler) TTLImpact(c *gin.Context) {
+	namespace := c.Query("namespace")
+	seconds, err := strconv.Atoi(c.Query("ttl_seconds"))
+	if namespace == "" || err != nil || seconds <= 0 {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "namespace and a positive ttl_seconds are required"))
+		return
+	}
+	impact, err := h.admin.ModelTTL(c.Request.Context(), namespace, time.Duration(seconds)*time.Second)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, impact)
+}
+
+// respondAdminError 按错误类型返回对应的错误码
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrEntryNotFound), errors.Is(err, ErrWarmupNotFound), errors.Is(err, ErrTTLProfileNotFound), errors.Is(err, ErrNamespaceNotSampled):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, err.Error()))
+	case errors.Is(err, ErrWarmupRunning):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, err.Error()))
+	case errors.Is(err, ErrInvalidNamespace):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, err.Error()))
+	default:
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeCache, ""))
+	}
+}
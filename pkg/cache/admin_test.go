@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_RunWarmup(t *testing.T) {
+	store := fakes.NewCache(nil)
+	admin := cache.NewAdmin(store, nil, metrics.GetGlobalCollector(), nil)
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	admin.RegisterWarmup("coupons", "active coupons", func(ctx context.Context) (*cache.WarmupResult, error) {
+		close(started)
+		<-release
+		return &cache.WarmupResult{TotalKeys: 2, SuccessKeys: 2}, store.Set(ctx, "coupon:1", 1, 0)
+	})
+	admin.RegisterWarmup("broken", "always fails", func(ctx context.Context) (*cache.WarmupResult, error) {
+		return nil, errors.New("database unavailable")
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := admin.RunWarmup(ctx, "coupons", "apikey:ops")
+		done <- err
+	}()
+	<-started
+	_, err := admin.RunWarmup(ctx, "coupons", "apikey:ops")
+	assert.ErrorIs(t, err, cache.ErrWarmupRunning, "the same task does not run concurrently")
+	close(release)
+	require.NoError(t, <-done)
+
+	_, err = admin.RunWarmup(ctx, "broken", "apikey:ops")
+	assert.ErrorContains(t, err, "database unavailable")
+	_, err = admin.RunWarmup(ctx, "missing", "apikey:ops")
+	assert.ErrorIs(t, err, cache.ErrWarmupNotFound)
+
+	warmups := admin.Warmups()
+	require.Len(t, warmups, 2)
+	assert.Equal(t, "broken", warmups[0].Name)
+	assert.Equal(t, "database unavailable", warmups[0].LastError)
+	assert.Equal(t, "coupons", warmups[1].Name)
+	assert.False(t, warmups[1].Running)
+	require.NotNil(t, warmups[1].LastRun)
+	assert.Equal(t, "coupons", warmups[1].LastRun.Strategy)
+	assert.Equal(t, 2, warmups[1].LastRun.SuccessKeys)
+
+	events := admin.Events(0)
+	require.Len(t, events, 2)
+	assert.Equal(t, "keys=2 success=2 failed=0", events[0].Detail)
+	assert.Equal(t, "failed: database unavailable", events[1].Detail)
+}
+
+func TestAdmin_EventsKeepMostRecent(t *testing.T) {
+	store := fakes.NewCache(nil)
+	config := cache.DefaultAdminConfig()
+	config.EventBuffer = 3
+	admin := cache.NewAdmin(store, cache.NewTagInvalidationStrategy(store), metrics.GetGlobalCollector(), config)
+	ctx := context.Background()
+
+	events, unsubscribe := admin.Subscribe()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, admin.Delete(ctx, key, "apikey:ops"))
+	}
+
+	recent := admin.Events(0)
+	require.Len(t, recent, 3)
+	assert.Equal(t, []uint64{3, 4, 5}, []uint64{recent[0].ID, recent[1].ID, recent[2].ID})
+	assert.Len(t, admin.Events(4), 1)
+
+	unsubscribe()
+	var streamed []string
+	for event := range events {
+		streamed = append(streamed, event.Target)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, streamed)
+
+	_, err := admin.Inspect(ctx, "a")
+	assert.ErrorIs(t, err, cache.ErrEntryNotFound)
+}
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CacheEntry 批量写入条目
+type CacheEntry struct {
+	Key        string
+	Value      interface{}
+	Expiration time.Duration
+}
+
+// BatchGetResult 批量获取结果
+type BatchGetResult struct {
+	Values  map[string]json.RawMessage // 命中的键及其 JSON 数据
+	Missing []string                   // 未命中的键
+	Errors  map[string]error           // 获取失败的键
+}
+
+// BatchSetResult 批量写入结果
+type BatchSetResult struct {
+	Succeeded []string         // 写入成功的键
+	Errors    map[string]error // 写入失败的键
+}
+
+// NewBatchGetResult 创建批量获取结果
+func NewBatchGetResult() *BatchGetResult {
+	return &BatchGetResult{
+		Values:  make(map[string]json.RawMessage),
+		Missing: make([]string, 0),
+		Errors:  make(map[string]error),
+	}
+}
+
+// NewBatchSetResult 创建批量写入结果
+func NewBatchSetResult() *BatchSetResult {
+	return &BatchSetResult{
+		Succeeded: make([]string, 0),
+		Errors:    make(map[string]error),
+	}
+}
+
+// Decode 将指定键的值解码到目标
+func (r *BatchGetResult) Decode(key string, dest interface{}) error {
+	if err, exists := r.Errors[key]; exists {
+		return err
+	}
+	data, exists := r.Values[key]
+	if !exists {
+		return fmt.Errorf("cache miss")
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("cache unmarshal error: %w", err)
+	}
+	return nil
+}
+
+// Hit 检查指定键是否命中
+func (r *BatchGetResult) Hit(key string) bool {
+	_, exists := r.Values[key]
+	return exists
+}
+
+// HasErrors 是否存在部分失败
+func (r *BatchGetResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// HasErrors 是否存在部分失败
+func (r *BatchSetResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// crc16Table CRC16/XMODEM 查找表，Redis Cluster 使用该算法计算槽位
+var crc16Table = func() [256]uint16 {
+	var table [256]uint16
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// clusterSlotCount Redis Cluster 槽位数量
+const clusterSlotCount = 16384
+
+// ClusterKeySlot 计算键所在的集群槽位，支持 {hashtag}
+func ClusterKeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^key[i]]
+	}
+	return int(crc) % clusterSlotCount
+}
+
+// groupKeysBySlot 按集群槽位分组，保持组内原有顺序
+func groupKeysBySlot(keys []string) map[int][]string {
+	groups := make(map[int][]string)
+	for _, key := range keys {
+		slot := ClusterKeySlot(key)
+		groups[slot] = append(groups[slot], key)
+	}
+	return groups
+}
+
+// forEachNode 按键路由器的槽位映射分组，每个节点一条管道并发执行；fn 为单个槽位的键添加命令，
+// 返回的回调在管道执行后调用，所有回调串行执行，可直接写共享结果
+func (rc *RedisCluster) forEachNode(ctx context.Context, keys []string, fn func(pipe redis.Pipeliner, slotKeys []string) func()) {
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, batch := range rc.keyRouter.GroupByNode(keys) {
+		wg.Add(1)
+		go func(batch NodeBatch) {
+			defer wg.Done()
+			pipe := rc.cluster.Pipeline()
+			collects := make([]func(), 0, len(batch.Slots))
+			for _, slotKeys := range batch.Slots {
+				collects = append(collects, fn(pipe, slotKeys))
+			}
+
+			// 单个槽位失败不影响其他槽位，错误由各命令自行记录
+			_, _ = pipe.Exec(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, collect := range collects {
+				collect()
+			}
+		}(batch)
+	}
+	wg.Wait()
+}
+
+// GetMany 按节点、槽位分组批量获取，每个槽位一条 MGET，同一节点的命令在同一管道中发送
+func (rc *RedisCluster) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	start := time.Now()
+	result := NewBatchGetResult()
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	rc.forEachNode(ctx, keys, func(pipe redis.Pipeliner, slotKeys []string) func() {
+		cmd := pipe.MGet(ctx, slotKeys...)
+		return func() {
+			vals, err := cmd.Result()
+			if err != nil {
+				for _, key := range slotKeys {
+					result.Errors[key] = fmt.Errorf("failed to MGet key: %w", err)
+				}
+				return
+			}
+			for i, val := range vals {
+				key := slotKeys[i]
+				switch v := val.(type) {
+				case nil:
+					result.Missing = append(result.Missing, key)
+				case string:
+					result.Values[key] = json.RawMessage(v)
+				default:
+					result.Errors[key] = fmt.Errorf("unexpected value type %T", val)
+				}
+			}
+		}
+	})
+
+	rc.recordMetrics("get_many", time.Since(start), !result.HasErrors())
+	return result, nil
+}
+
+// SetMany 批量写入，每个条目使用各自的过期时间。与 RedisCache.SetMany 相同，值按 JSON 编码写入，
+// 由 GetMany 与 BatchGetResult.Decode 读取；按节点、槽位分组，同一节点的命令在同一管道中发送。同一键出现多次时以最后一个为准
+func (rc *RedisCluster) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	start := time.Now()
+	result := NewBatchSetResult()
+	if len(entries) == 0 {
+		return result, nil
+	}
+
+	latest := make(map[string]CacheEntry, len(entries))
+	var order []string
+	for _, entry := range entries {
+		if _, ok := latest[entry.Key]; !ok {
+			order = append(order, entry.Key)
+		}
+		latest[entry.Key] = entry
+	}
+	values := make(map[string][]byte, len(latest))
+	keys := make([]string, 0, len(order))
+	for _, key := range order {
+		data, err := json.Marshal(latest[key].Value)
+		if err != nil {
+			result.Errors[key] = fmt.Errorf("failed to marshal JSON: %w", err)
+			continue
+		}
+		values[key] = data
+		keys = append(keys, key)
+	}
+
+	rc.forEachNode(ctx, keys, func(pipe redis.Pipeliner, slotKeys []string) func() {
+		cmds := make([]*redis.StatusCmd, len(slotKeys))
+		for i, key := range slotKeys {
+			cmds[i] = pipe.Set(ctx, key, values[key], latest[key].Expiration)
+		}
+		return func() {
+			for i, cmd := range cmds {
+				if err := cmd.Err(); err != nil {
+					result.Errors[slotKeys[i]] = fmt.Errorf("failed to set key: %w", err)
+					continue
+				}
+				result.Succeeded = append(result.Succeeded, slotKeys[i])
+			}
+		}
+	})
+
+	rc.recordMetrics("set_many", time.Since(start), !result.HasErrors())
+	return result, nil
+}
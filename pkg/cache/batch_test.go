@@ -0,0 +1,371 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClusterNode 以 RESP 协议应答的集群节点，负责 [start, end] 槽位；记录收到的命令，
+// 同一次读取中连续到达的命令记为一个批次（即一条管道）
+type fakeClusterNode struct {
+	listener   net.Listener
+	start, end int
+
+	mu      sync.Mutex
+	data    map[string]string
+	ttl     map[string]string
+	batches [][][]string
+	peers   []*fakeClusterNode
+}
+
+func newFakeClusterNode(t *testing.T, start, end int) *fakeClusterNode {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	node := &fakeClusterNode{listener: listener, start: start, end: end, data: make(map[string]string), ttl: make(map[string]string)}
+	t.Cleanup(func() { listener.Close() })
+	go node.serve()
+	return node
+}
+
+func (n *fakeClusterNode) addr() string {
+	return n.listener.Addr().String()
+}
+
+func (n *fakeClusterNode) serve() {
+	for {
+		conn, err := n.listener.Accept()
+		if err != nil {
+			return
+		}
+		go n.handle(conn)
+	}
+}
+
+func (n *fakeClusterNode) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	wr := bufio.NewWriter(conn)
+	var batch [][]string
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		batch = append(batch, args)
+		writeReply(wr, n.exec(args))
+		if rd.Buffered() > 0 {
+			continue
+		}
+		n.mu.Lock()
+		n.batches = append(n.batches, batch)
+		n.mu.Unlock()
+		batch = nil
+		if err := wr.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (n *fakeClusterNode) exec(args []string) interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	switch strings.ToLower(args[0]) {
+	case "ping":
+		return status("PONG")
+	case "command":
+		return []interface{}{
+			commandInfo("get", 2, 1, 1),
+			commandInfo("set", -3, 1, 1),
+			commandInfo("mget", -2, 1, -1),
+			commandInfo("ping", -1, 0, 0),
+			commandInfo("cluster", -2, 0, 0),
+		}
+	case "cluster":
+		if strings.EqualFold(args[1], "slots") {
+			var slots []interface{}
+			for _, node := range n.peers {
+				host, port, _ := net.SplitHostPort(node.addr())
+				p, _ := strconv.Atoi(port)
+				slots = append(slots, []interface{}{node.start, node.end, []interface{}{host, p, node.addr()}})
+			}
+			return slots
+		}
+	case "set":
+		n.data[args[1]] = args[2]
+		if len(args) > 4 {
+			n.ttl[args[1]] = args[3] + " " + args[4]
+		}
+		return status("OK")
+	case "get":
+		if val, ok := n.data[args[1]]; ok {
+			return val
+		}
+		return nil
+	case "mget":
+		vals := make([]interface{}, 0, len(args)-1)
+		for _, key := range args[1:] {
+			if val, ok := n.data[key]; ok {
+				vals = append(vals, val)
+			} else {
+				vals = append(vals, nil)
+			}
+		}
+		return vals
+	}
+	return fmt.Errorf("ERR unknown command '%s'", args[0])
+}
+
+// commands 按批次返回指定命令的参数（不含命令名），过滤掉其他命令
+func (n *fakeClusterNode) commands(name string) [][][]string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var batches [][][]string
+	for _, batch := range n.batches {
+		var matched [][]string
+		for _, args := range batch {
+			if strings.EqualFold(args[0], name) {
+				matched = append(matched, args[1:])
+			}
+		}
+		if len(matched) > 0 {
+			batches = append(batches, matched)
+		}
+	}
+	return batches
+}
+
+func (n *fakeClusterNode) reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.batches = nil
+}
+
+func (n *fakeClusterNode) value(key string) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	val, ok := n.data[key]
+	return val, ok
+}
+
+type status string
+
+func commandInfo(name string, arity, first, last int) []interface{} {
+	return []interface{}{name, arity, []interface{}{}, first, last, 1}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func writeReply(wr *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		wr.WriteString("$-1\r\n")
+	case status:
+		fmt.Fprintf(wr, "+%s\r\n", v)
+	case error:
+		fmt.Fprintf(wr, "-%s\r\n", v)
+	case int:
+		fmt.Fprintf(wr, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(wr, "$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		fmt.Fprintf(wr, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(wr, item)
+		}
+	}
+}
+
+// newFakeCluster 两个节点平分槽位：0-8191 与 8192-16383
+func newFakeCluster(t *testing.T) (*cache.RedisCluster, *fakeClusterNode, *fakeClusterNode) {
+	t.Helper()
+	low := newFakeClusterNode(t, 0, 8191)
+	high := newFakeClusterNode(t, 8192, 16383)
+	low.peers = []*fakeClusterNode{low, high}
+	high.peers = low.peers
+
+	rc, err := cache.NewRedisCluster(&cache.RedisClusterConfig{
+		Nodes:               []string{low.addr(), high.addr()},
+		HealthCheckInterval: time.Hour,
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { rc.Close() })
+	return rc, low, high
+}
+
+// TestRedisCluster_SetMany 批量写入按 JSON 编码，按槽位路由到所在节点，同一节点的命令在一条管道中发送；
+// 无法编码的值只影响该条目
+func TestRedisCluster_SetMany(t *testing.T) {
+	ctx := context.Background()
+	rc, low, high := newFakeCluster(t)
+
+	// 槽位：user:3 → 2648，user:1 → 10778，user:{1}:* → 9842
+	require.Equal(t, 2648, cache.ClusterKeySlot("user:3"))
+	require.Equal(t, 10778, cache.ClusterKeySlot("user:1"))
+	require.Equal(t, 9842, cache.ClusterKeySlot("user:{1}:profile"))
+
+	result, err := rc.SetMany(ctx, []cache.CacheEntry{
+		{Key: "user:1", Value: "stale"},
+		{Key: "user:3", Value: json.RawMessage(`{"id":3}`), Expiration: time.Minute},
+		{Key: "user:{1}:profile", Value: 42},
+		{Key: "user:{1}:posts", Value: make(chan int)},
+		{Key: "user:1", Value: "alice", Expiration: 1500 * time.Millisecond},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:3", "user:{1}:profile"}, result.Succeeded)
+	require.Contains(t, result.Errors, "user:{1}:posts")
+	assert.Len(t, result.Errors, 1)
+
+	val, _ := high.value("user:1")
+	assert.Equal(t, `"alice"`, val)
+	val, _ = low.value("user:3")
+	assert.Equal(t, `{"id":3}`, val)
+	val, _ = high.value("user:{1}:profile")
+	assert.Equal(t, "42", val)
+	_, ok := high.value("user:{1}:posts")
+	assert.False(t, ok)
+
+	// 每个节点只收到自己槽位的键，且在一条管道中
+	lowSets := low.commands("set")
+	require.Len(t, lowSets, 1)
+	require.Len(t, lowSets[0], 1)
+	assert.Equal(t, []string{"user:3", `{"id":3}`, "ex", "60"}, lowSets[0][0])
+	highSets := high.commands("set")
+	require.Len(t, highSets, 1)
+	var highKeys []string
+	for _, args := range highSets[0] {
+		highKeys = append(highKeys, args[0])
+		if args[0] == "user:1" {
+			assert.Equal(t, []string{"user:1", `"alice"`, "px", "1500"}, args, "duplicate keys keep the last entry")
+		}
+	}
+	assert.ElementsMatch(t, []string{"user:1", "user:{1}:profile"}, highKeys)
+}
+
+// TestRedisCluster_GetMany 每个槽位一条 MGET，同一节点的 MGET 在一条管道中发送
+func TestRedisCluster_GetMany(t *testing.T) {
+	ctx := context.Background()
+	rc, low, high := newFakeCluster(t)
+
+	_, err := rc.SetMany(ctx, []cache.CacheEntry{
+		{Key: "user:1", Value: json.RawMessage(`{"id":1}`)},
+		{Key: "user:3", Value: json.RawMessage(`{"id":3}`)},
+		{Key: "user:{1}:profile", Value: json.RawMessage(`{"bio":"hi"}`)},
+	})
+	require.NoError(t, err)
+	low.reset()
+	high.reset()
+
+	result, err := rc.GetMany(ctx, []string{"user:3", "user:{1}:profile", "user:{1}:posts", "user:1"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1}`, string(result.Values["user:1"]))
+	assert.JSONEq(t, `{"id":3}`, string(result.Values["user:3"]))
+	assert.JSONEq(t, `{"bio":"hi"}`, string(result.Values["user:{1}:profile"]))
+	assert.Equal(t, []string{"user:{1}:posts"}, result.Missing)
+	assert.Empty(t, result.Errors)
+
+	assert.Equal(t, [][][]string{{{"user:3"}}}, low.commands("mget"))
+	highGets := high.commands("mget")
+	require.Len(t, highGets, 1, "both slots of the node share one pipeline")
+	assert.ElementsMatch(t, [][]string{{"user:1"}, {"user:{1}:profile", "user:{1}:posts"}}, highGets[0])
+}
+
+type batchProfile struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// assertBatchRoundTrip SetMany 写入的字符串、时间与结构体经 GetMany 读回后能用 Decode 还原
+func assertBatchRoundTrip(t *testing.T, store interface {
+	GetMany(ctx context.Context, keys []string) (*cache.BatchGetResult, error)
+	SetMany(ctx context.Context, entries []cache.CacheEntry) (*cache.BatchSetResult, error)
+}) {
+	t.Helper()
+	ctx := context.Background()
+	at := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	set, err := store.SetMany(ctx, []cache.CacheEntry{
+		{Key: "name", Value: "alice", Expiration: time.Minute},
+		{Key: "{p}:at", Value: at},
+		{Key: "{p}:profile", Value: &batchProfile{Name: "bob", UpdatedAt: at}},
+	})
+	require.NoError(t, err)
+	require.Empty(t, set.Errors)
+
+	result, err := store.GetMany(ctx, []string{"name", "{p}:at", "{p}:profile"})
+	require.NoError(t, err)
+	var name string
+	require.NoError(t, result.Decode("name", &name))
+	assert.Equal(t, "alice", name)
+	var gotAt time.Time
+	require.NoError(t, result.Decode("{p}:at", &gotAt))
+	assert.True(t, at.Equal(gotAt))
+	var profile batchProfile
+	require.NoError(t, result.Decode("{p}:profile", &profile))
+	assert.Equal(t, "bob", profile.Name)
+	assert.True(t, at.Equal(profile.UpdatedAt))
+}
+
+// TestBatch_RoundTrip 集群与单机实现的批量读写编码一致
+func TestBatch_RoundTrip(t *testing.T) {
+	t.Run("cluster", func(t *testing.T) {
+		rc, _, _ := newFakeCluster(t)
+		assertBatchRoundTrip(t, rc)
+	})
+	t.Run("redis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		assertBatchRoundTrip(t, cache.NewRedisCache(client))
+	})
+}
+
+// TestClusterKeySlot 与 Redis 的 CRC16 槽位计算一致，{hashtag} 内的部分决定槽位
+func TestClusterKeySlot(t *testing.T) {
+	assert.Equal(t, 12739, cache.ClusterKeySlot("123456789"))
+	assert.Equal(t, cache.ClusterKeySlot("1"), cache.ClusterKeySlot("user:{1}:profile"))
+	assert.NotEqual(t, cache.ClusterKeySlot("x"), cache.ClusterKeySlot("{}x"), "empty hashtags hash the whole key")
+}
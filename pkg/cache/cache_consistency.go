@@ -7,6 +7,9 @@ import (
 	"log"
 	"sync"
 	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/events"
 	"user_crud_jwt/pkg/metrics"
 )
 
@@ -17,6 +20,7 @@ type CacheConsistencyManager struct {
 	strategies       map[string]InvalidationStrategy
 	eventBus         *EventBus
 	config           *ConsistencyConfig
+	clock            clock.Clock
 }
 
 // ConsistencyConfig 一致性配置
@@ -29,6 +33,10 @@ type ConsistencyConfig struct {
 	MaxRetries       int           `json:"max_retries"`
 	RetryDelay       time.Duration `json:"retry_delay"`
 	EnableMetrics    bool          `json:"enable_metrics"`
+	// DoubleDeleteDelay 延迟双删两次删除的间隔，为 0 时使用 DefaultDoubleDeleteDelay
+	DoubleDeleteDelay time.Duration `json:"double_delete_delay"`
+	// Clock 延迟失效与事件时间使用的时钟，为 nil 时使用系统时钟
+	Clock clock.Clock `json:"-"`
 }
 
 // InvalidationStrategy 失效策略接口
@@ -38,23 +46,19 @@ type InvalidationStrategy interface {
 	GetPriority() int
 }
 
-// EventBus 事件总线
+// EventBus 缓存事件总线，基于类型化的 events.Bus
 type EventBus struct {
-	subscribers map[string][]EventSubscriber
-	mu          sync.RWMutex
-	eventQueue  chan CacheEvent
-	stopCh      chan struct{}
-	config      *ConsistencyConfig
+	bus *events.Bus[CacheEvent]
 }
 
 // CacheEvent 缓存事件
 type CacheEvent struct {
-	ID        string                 `json:"id"`
-	Type      EventType              `json:"type"`
-	Key       string                 `json:"key"`
-	Value     interface{}            `json:"value"`
-	Timestamp time.Time              `json:"timestamp"`
-	Metadata  map[string]interface{} `json:"metadata"`
+	ID        string      `json:"id"`
+	Type      EventType   `json:"type"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value,omitempty"`
+	Strategy  string      `json:"strategy,omitempty"` // 触发失效的策略
+	Timestamp time.Time   `json:"timestamp"`
 }
 
 // EventType 事件类型
@@ -83,26 +87,27 @@ func NewCacheConsistencyManager(cache CacheService, metricsCollector *metrics.Me
 		strategies:       make(map[string]InvalidationStrategy),
 		eventBus:         NewEventBus(config),
 		config:           config,
+		clock:            clock.OrReal(config.Clock),
 	}
 
 	// 注册默认策略
 	ccm.registerDefaultStrategies()
 
-	// 启动事件总线
-	if config.EnableEventBus {
-		go ccm.eventBus.Start()
-	}
-
 	return ccm
 }
 
 // NewEventBus 创建事件总线
 func NewEventBus(config *ConsistencyConfig) *EventBus {
+	busConfig := events.DefaultConfig()
+	if config.EventBusSize > 0 {
+		busConfig.QueueSize = config.EventBusSize
+	}
+	busConfig.MaxRetries = config.MaxRetries
+	busConfig.RetryDelay = config.RetryDelay
+	busConfig.EnableMetrics = config.EnableMetrics
+
 	return &EventBus{
-		subscribers: make(map[string][]EventSubscriber),
-		eventQueue:  make(chan CacheEvent, config.EventBusSize),
-		stopCh:      make(chan struct{}),
-		config:      config,
+		bus: events.NewBus[CacheEvent]("cache", busConfig),
 	}
 }
 
@@ -117,8 +122,12 @@ func (ccm *CacheConsistencyManager) registerDefaultStrategies() {
 	ccm.strategies["delayed"] = &DelayedInvalidationStrategy{
 		cache: ccm.cache,
 		delay: time.Second * 5,
+		clock: ccm.clock,
 	}
 
+	// 延迟双删策略
+	ccm.strategies["double_delete"] = NewDoubleDeleteInvalidationStrategy(ccm.cache, ccm.config.DoubleDeleteDelay, ccm.clock, ccm.metricsCollector)
+
 	// 批量失效策略
 	ccm.strategies["batch"] = &BatchInvalidationStrategy{
 		cache:     ccm.cache,
@@ -131,6 +140,9 @@ func (ccm *CacheConsistencyManager) registerDefaultStrategies() {
 		cache: ccm.cache,
 	}
 
+	// 标签失效策略
+	ccm.strategies["tag"] = NewTagInvalidationStrategy(ccm.cache)
+
 	// 依赖失效策略
 	ccm.strategies["dependency"] = &DependencyInvalidationStrategy{
 		cache:        ccm.cache,
@@ -157,21 +169,43 @@ func (ccm *CacheConsistencyManager) Invalidate(ctx context.Context, strategyName
 	}
 
 	// 发送失效事件
-	if ccm.config.EnableEventBus {
-		for _, key := range keys {
-			event := CacheEvent{
-				ID:        generateEventID(),
-				Type:      EventDelete,
-				Key:       key,
-				Timestamp: time.Now(),
-				Metadata: map[string]interface{}{
-					"strategy": strategyName,
-				},
-			}
-			ccm.eventBus.Publish(event)
+	ccm.publishInvalidation(keys, strategyName)
+
+	return nil
+}
+
+// publishInvalidation 开启事件总线时为每个键发送删除事件
+func (ccm *CacheConsistencyManager) publishInvalidation(keys []string, strategyName string) {
+	if !ccm.config.EnableEventBus {
+		return
+	}
+	for _, key := range keys {
+		event := CacheEvent{
+			ID:        generateEventID(),
+			Type:      EventDelete,
+			Key:       key,
+			Strategy:  strategyName,
+			Timestamp: ccm.clock.Now(),
 		}
+		ccm.eventBus.Publish(event)
+	}
+}
+
+// WriteWithDoubleDelete 以延迟双删包裹数据库写入：删除 keys，执行 write，成功后延迟再删除一次，
+// 事件与指标与 Invalidate 相同
+func (ccm *CacheConsistencyManager) WriteWithDoubleDelete(ctx context.Context, keys []string, write func(ctx context.Context) error) error {
+	strategy, ok := ccm.strategies["double_delete"].(*DoubleDeleteInvalidationStrategy)
+	if !ok {
+		return fmt.Errorf("invalidation strategy double_delete not found")
 	}
 
+	start := time.Now()
+	if err := strategy.Write(ctx, keys, write); err != nil {
+		ccm.recordMetrics("invalidate_error", time.Since(start), false)
+		return err
+	}
+	ccm.recordMetrics("invalidate", time.Since(start), true)
+	ccm.publishInvalidation(keys, strategy.GetName())
 	return nil
 }
 
@@ -207,65 +241,38 @@ func generateEventID() string {
 	return fmt.Sprintf("event_%d", time.Now().UnixNano())
 }
 
-// Start 启动事件总线
-func (eb *EventBus) Start() {
-	for {
-		select {
-		case event := <-eb.eventQueue:
-			eb.handleEvent(event)
-		case <-eb.stopCh:
-			return
-		}
-	}
-}
-
-// Stop 停止事件总线
+// Stop 停止事件总线，等待已入队事件处理完毕
 func (eb *EventBus) Stop() {
-	close(eb.stopCh)
+	eb.bus.Close()
 }
 
-// Subscribe 订阅事件
+// Subscribe 订阅事件，订阅者按事件类型异步接收，同一 key 的事件有序
 func (eb *EventBus) Subscribe(subscriber EventSubscriber) error {
-	eb.mu.Lock()
-	defer eb.mu.Unlock()
-
-	eventTypes := subscriber.GetEventTypes()
-	for _, eventType := range eventTypes {
-		eb.subscribers[string(eventType)] = append(eb.subscribers[string(eventType)], subscriber)
+	topics := make([]string, 0, len(subscriber.GetEventTypes()))
+	for _, eventType := range subscriber.GetEventTypes() {
+		topics = append(topics, string(eventType))
 	}
 
-	return nil
+	return eb.bus.Subscribe(subscriber.GetName(), func(ctx context.Context, event events.Event[CacheEvent]) error {
+		return subscriber.Handle(ctx, event.Payload)
+	}, events.Async(), events.WithTopics(topics...))
 }
 
 // Publish 发布事件
 func (eb *EventBus) Publish(event CacheEvent) {
-	select {
-	case eb.eventQueue <- event:
-	default:
-		log.Printf("Event queue is full, dropping event: %s", event.ID)
+	if err := eb.bus.Publish(context.Background(), string(event.Type), event.Key, event); err != nil {
+		log.Printf("Failed to publish cache event %s: %v", event.ID, err)
 	}
 }
 
-// handleEvent 处理事件
-func (eb *EventBus) handleEvent(event CacheEvent) {
-	eb.mu.RLock()
-	subscribers, exists := eb.subscribers[string(event.Type)]
-	eb.mu.RUnlock()
-
-	if !exists {
-		return
-	}
-
-	for _, subscriber := range subscribers {
-		go func(s EventSubscriber) {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-			defer cancel()
+// Pending 待处理事件数
+func (eb *EventBus) Pending() int {
+	return eb.bus.Pending()
+}
 
-			if err := s.Handle(ctx, event); err != nil {
-				log.Printf("Event subscriber %s failed to handle event %s: %v", s.GetName(), event.ID, err)
-			}
-		}(subscriber)
-	}
+// DeadLetters 获取处理失败的事件
+func (eb *EventBus) DeadLetters() []events.DeadLetter[CacheEvent] {
+	return eb.bus.DeadLetters()
 }
 
 // ImmediateInvalidationStrategy 立即失效策略
@@ -294,7 +301,8 @@ func (iis *ImmediateInvalidationStrategy) GetPriority() int {
 type DelayedInvalidationStrategy struct {
 	cache  CacheService
 	delay  time.Duration
-	timers map[string]*time.Timer
+	clock  clock.Clock
+	timers map[string]clock.Timer
 	mu     sync.Mutex
 }
 
@@ -303,7 +311,7 @@ func (dis *DelayedInvalidationStrategy) Invalidate(ctx context.Context, keys []s
 	defer dis.mu.Unlock()
 
 	if dis.timers == nil {
-		dis.timers = make(map[string]*time.Timer)
+		dis.timers = make(map[string]clock.Timer)
 	}
 
 	for _, key := range keys {
@@ -312,13 +320,19 @@ func (dis *DelayedInvalidationStrategy) Invalidate(ctx context.Context, keys []s
 			timer.Stop()
 		}
 
-		// 创建新的延迟失效定时器
-		dis.timers[key] = time.AfterFunc(dis.delay, func() {
-			dis.cache.Delete(context.Background(), key)
+		// 创建新的延迟失效定时器，触发时只移除自己，不影响之后重新创建的定时器
+		var timer clock.Timer
+		timer = clock.OrReal(dis.clock).AfterFunc(dis.delay, func() {
+			deleteCtx, cancel := ctxutil.Detach(ctx, time.Second*5)
+			defer cancel()
+			dis.cache.Delete(deleteCtx, key)
 			dis.mu.Lock()
-			delete(dis.timers, key)
+			if dis.timers[key] == timer {
+				delete(dis.timers, key)
+			}
 			dis.mu.Unlock()
 		})
+		dis.timers[key] = timer
 	}
 
 	return nil
@@ -381,7 +395,7 @@ func (bis *BatchInvalidationStrategy) GetPriority() int {
 	return 60
 }
 
-// VersionedInvalidationStrategy 版本化失效策略
+// VersionedInvalidationStrategy 版本化失效策略：写入新的版本号，经 WithVersionedReads 包装的缓存随之读写新版本的数据键
 type VersionedInvalidationStrategy struct {
 	cache CacheService
 }
@@ -389,10 +403,9 @@ type VersionedInvalidationStrategy struct {
 func (vis *VersionedInvalidationStrategy) Invalidate(ctx context.Context, keys []string) error {
 	for _, key := range keys {
 		// 增加版本号
-		versionKey := fmt.Sprintf("%s:version", key)
 		newVersion := time.Now().UnixNano()
 
-		if err := vis.cache.Set(ctx, versionKey, newVersion, 0); err != nil {
+		if err := vis.cache.Set(ctx, VersionKey(key), newVersion, 0); err != nil {
 			return fmt.Errorf("failed to update version for key %s: %w", key, err)
 		}
 	}
@@ -577,11 +590,17 @@ func (cl *CacheLocking) TryLock(ctx context.Context, key string, ttl time.Durati
 	return cl.Lock(ctx, key, ttl)
 }
 
-// CacheConsistencyChecker 一致性检查器
+// CacheConsistencyChecker 一致性检查器：CheckConsistency 只检查存在与有效期，
+// 登记数据源的命名空间可由 CheckAgainstSource、CheckSources 与数据库中的值比对
 type CacheConsistencyChecker struct {
 	cache            CacheService
 	metricsCollector *metrics.MetricsCollector
 	config           *ConsistencyConfig
+
+	mu           sync.RWMutex
+	sources      []*templateLoader
+	popularity   *PopularityTracker
+	sourceConfig *SourceCheckConfig
 }
 
 // NewCacheConsistencyChecker 创建一致性检查器
@@ -590,6 +609,7 @@ func NewCacheConsistencyChecker(cache CacheService, metricsCollector *metrics.Me
 		cache:            cache,
 		metricsCollector: metricsCollector,
 		config:           config,
+		sourceConfig:     DefaultSourceCheckConfig(),
 	}
 }
 
@@ -618,6 +638,7 @@ type ConsistencyReport struct {
 	Timestamp        time.Time          `json:"timestamp"`
 	Issues           []ConsistencyIssue `json:"issues"`
 	ConsistencyScore float64            `json:"consistency_score"`
+	Repaired         int                `json:"repaired,omitempty"` // 与数据源比对时已修复的键数
 }
 
 // ConsistencyIssue 一致性问题
@@ -627,6 +648,7 @@ type ConsistencyIssue struct {
 	Description string    `json:"description"`
 	Severity    string    `json:"severity"`
 	Timestamp   time.Time `json:"timestamp"`
+	Repaired    bool      `json:"repaired,omitempty"`
 }
 
 // checkKeyConsistency 检查单个键的一致性
@@ -723,7 +745,7 @@ func (ccm *CacheConsistencyManager) GetMetrics(ctx context.Context) (*CacheConsi
 
 	// 获取事件总线大小
 	if ccm.config.EnableEventBus {
-		metrics.EventBusSize = len(ccm.eventBus.eventQueue)
+		metrics.EventBusSize = ccm.eventBus.Pending()
 	}
 
 	// 检查一致性
@@ -18,6 +18,13 @@ type CacheMonitor struct {
 	stats            *CacheStats
 	alerter          *CacheAlerter
 	reporter         *CacheReporter
+	sink             StatsSink
+}
+
+// StatsSink 缓存统计持久化，按时间范围查询历史趋势
+type StatsSink interface {
+	Write(ctx context.Context, snapshot CacheSnapshot) error
+	QueryTrends(ctx context.Context, start, end time.Time) ([]TrendData, error)
 }
 
 // MonitorConfig 监控配置
@@ -156,6 +163,16 @@ type TrendData struct {
 	Value     float64   `json:"value"`
 }
 
+// 趋势指标名称
+const (
+	TrendHitRate      = "hit_rate"
+	TrendErrorRate    = "error_rate"
+	TrendAvgLatencyMs = "avg_latency_ms"
+	TrendMaxLatencyMs = "max_latency_ms"
+	TrendMemoryUsage  = "memory_usage"
+	TrendMemoryMax    = "memory_max"
+)
+
 // NewCacheMonitor 创建缓存监控器
 func NewCacheMonitor(cache CacheService, metricsCollector *metrics.MetricsCollector, config *MonitorConfig) *CacheMonitor {
 	return &CacheMonitor{
@@ -183,6 +200,11 @@ func NewCacheReporter(config *MonitorConfig) *CacheReporter {
 	}
 }
 
+// SetStatsSink 设置统计持久化，设置后报告趋势从持久化数据查询
+func (cm *CacheMonitor) SetStatsSink(sink StatsSink) {
+	cm.sink = sink
+}
+
 // Start 开始监控
 func (cm *CacheMonitor) Start() {
 	ticker := time.NewTicker(cm.config.MonitorInterval)
@@ -256,6 +278,15 @@ func (cm *CacheMonitor) updateStats(snapshot CacheSnapshot) {
 	if len(cm.stats.History) > cm.config.MaxHistorySize {
 		cm.stats.History = cm.stats.History[1:]
 	}
+
+	// 内存历史有上限，持久化用于长期趋势
+	if cm.sink != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := cm.sink.Write(ctx, snapshot); err != nil {
+			log.Printf("Failed to persist cache stats: %v", err)
+		}
+	}
 }
 
 // checkAlerts 检查告警条件
@@ -413,7 +444,7 @@ func (cm *CacheMonitor) GenerateReport(ctx context.Context, duration time.Durati
 	report.Summary = cm.calculateSummary()
 
 	// 生成详细信息
-	report.Details = cm.generateDetails()
+	report.Details = cm.generateDetails(ctx, startTime, endTime)
 
 	// 生成建议
 	report.Recommendations = cm.generateRecommendations()
@@ -434,12 +465,12 @@ func (cm *CacheMonitor) calculateSummary() CacheSummary {
 }
 
 // generateDetails 生成详细信息
-func (cm *CacheMonitor) generateDetails() ReportDetails {
+func (cm *CacheMonitor) generateDetails(ctx context.Context, start, end time.Time) ReportDetails {
 	details := ReportDetails{
 		TopKeys:           cm.getTopKeys(),
 		ResponseTimeStats: cm.getResponseTimeStats(),
 		ErrorStats:        cm.getErrorStats(),
-		Trends:            cm.getTrends(),
+		Trends:            cm.GetTrends(ctx, start, end),
 	}
 
 	return details
@@ -500,24 +531,37 @@ func (cm *CacheMonitor) getErrorStats() []ErrorStats {
 	}
 }
 
-// getTrends 获取趋势数据
-func (cm *CacheMonitor) getTrends() []TrendData {
-	trends := make([]TrendData, 0)
+// GetTrends 获取时间范围内的趋势数据，优先查询持久化数据，否则使用内存历史
+func (cm *CacheMonitor) GetTrends(ctx context.Context, start, end time.Time) []TrendData {
+	if cm.sink != nil {
+		trends, err := cm.sink.QueryTrends(ctx, start, end)
+		if err == nil {
+			return trends
+		}
+		log.Printf("Failed to query cache stats trends: %v", err)
+	}
 
-	// 简化实现，返回模拟趋势数据
-	now := time.Now()
-	for i := 0; i < 24; i++ {
-		timestamp := now.Add(-time.Duration(i) * time.Hour)
-		trends = append(trends, TrendData{
-			Timestamp: timestamp,
-			Metric:    "hit_rate",
-			Value:     0.85 + float64(i%10)*0.01, // 模拟数据
-		})
+	trends := make([]TrendData, 0)
+	for _, snapshot := range cm.stats.History {
+		if snapshot.Timestamp.Before(start) || snapshot.Timestamp.After(end) {
+			continue
+		}
+		trends = append(trends, SnapshotTrends(snapshot)...)
 	}
 
 	return trends
 }
 
+// SnapshotTrends 将快照展开为趋势数据点
+func SnapshotTrends(snapshot CacheSnapshot) []TrendData {
+	return []TrendData{
+		{Timestamp: snapshot.Timestamp, Metric: TrendHitRate, Value: snapshot.HitRate},
+		{Timestamp: snapshot.Timestamp, Metric: TrendErrorRate, Value: snapshot.ErrorRate},
+		{Timestamp: snapshot.Timestamp, Metric: TrendAvgLatencyMs, Value: float64(snapshot.AvgResponseTime) / float64(time.Millisecond)},
+		{Timestamp: snapshot.Timestamp, Metric: TrendMemoryUsage, Value: float64(snapshot.MemoryUsage)},
+	}
+}
+
 // generateRecommendations 生成建议
 func (cm *CacheMonitor) generateRecommendations() []string {
 	recommendations := []string{}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 	"user_crud_jwt/internal/pkg/config"
@@ -22,6 +24,14 @@ type CacheService interface {
 	SetWithTTL(ctx context.Context, key string, value interface{}) error
 	InvalidatePattern(ctx context.Context, pattern string) error
 	GetMultiple(ctx context.Context, keys []string, dest interface{}) error
+	GetMany(ctx context.Context, keys []string) (*BatchGetResult, error)
+	SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error)
+
+	// 有序集合，用于排行榜；desc 为 true 时按分数降序排名。ZRank 的成员不存在时返回 ErrNotRanked
+	ZAdd(ctx context.Context, key string, members ...ScoredMember) error
+	ZIncrBy(ctx context.Context, key, member string, increment float64) (float64, error)
+	ZRangeWithScores(ctx context.Context, key string, start, stop int64, desc bool) ([]ScoredMember, error)
+	ZRank(ctx context.Context, key, member string, desc bool) (int64, error)
 }
 
 // RedisCache Redis 缓存实现
@@ -134,18 +144,11 @@ func (c *RedisCache) SetWithTTL(ctx context.Context, key string, value interface
 	return c.Set(ctx, key, value, time.Hour)
 }
 
-// InvalidatePattern 根据模式批量删除缓存
+// InvalidatePattern 根据模式批量删除缓存，使用 SCAN/UNLINK 避免阻塞 Redis
 func (c *RedisCache) InvalidatePattern(ctx context.Context, pattern string) error {
-	fullPattern := c.prefix + pattern
-	keys, err := c.client.Keys(ctx, fullPattern).Result()
-	if err != nil {
-		return fmt.Errorf("cache keys error: %w", err)
-	}
-
-	if len(keys) > 0 {
-		return c.client.Del(ctx, keys...).Err()
+	if _, err := c.InvalidatePatternWithOptions(ctx, pattern, DefaultInvalidateOptions()); err != nil {
+		return fmt.Errorf("cache invalidate pattern error: %w", err)
 	}
-
 	return nil
 }
 
@@ -186,8 +189,74 @@ func (c *RedisCache) GetMultiple(ctx context.Context, keys []string, dest interf
 	return json.Unmarshal(data, dest)
 }
 
-// MemoryCache 内存缓存实现（用于开发/测试）
+// GetMany 批量获取缓存，返回命中、未命中及失败的键
+func (c *RedisCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	result := NewBatchGetResult()
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = c.getKey(key)
+	}
+
+	vals, err := c.client.MGet(ctx, fullKeys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("cache mget error: %w", err)
+	}
+
+	for i, val := range vals {
+		switch v := val.(type) {
+		case nil:
+			result.Missing = append(result.Missing, keys[i])
+		case string:
+			result.Values[keys[i]] = json.RawMessage(v)
+		default:
+			result.Errors[keys[i]] = fmt.Errorf("unexpected value type %T", val)
+		}
+	}
+
+	return result, nil
+}
+
+// SetMany 批量设置缓存，每个条目使用各自的过期时间
+func (c *RedisCache) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	result := NewBatchSetResult()
+	if len(entries) == 0 {
+		return result, nil
+	}
+
+	pipe := c.client.Pipeline()
+	cmds := make(map[string]*redis.StatusCmd, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry.Value)
+		if err != nil {
+			result.Errors[entry.Key] = fmt.Errorf("cache marshal error: %w", err)
+			continue
+		}
+		cmds[entry.Key] = pipe.Set(ctx, c.getKey(entry.Key), data, entry.Expiration)
+	}
+
+	if len(cmds) > 0 {
+		// 单条命令失败时 Exec 也会返回错误，这里按键逐条检查
+		_, _ = pipe.Exec(ctx)
+	}
+
+	for key, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			result.Errors[key] = fmt.Errorf("cache set error: %w", err)
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, key)
+	}
+
+	return result, nil
+}
+
+// MemoryCache 内存缓存实现（用于开发/测试），有序集合保存在跳表中
 type MemoryCache struct {
+	SortedSets
 	data map[string]*cacheItem
 	mu   sync.RWMutex
 }
@@ -246,6 +315,7 @@ func (c *MemoryCache) Delete(ctx context.Context, key string) error {
 	defer c.mu.Unlock()
 
 	delete(c.data, c.getKey(key))
+	c.SortedSets.Remove(key)
 	return nil
 }
 
@@ -256,11 +326,11 @@ func (c *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
 	fullKey := c.getKey(key)
 	item, exists := c.data[fullKey]
 	if !exists {
-		return false, nil
+		return c.SortedSets.Has(key), nil
 	}
 
+	// 持有读锁，过期项由写入时的 cleanup 删除
 	if time.Now().After(item.expiration) {
-		delete(c.data, fullKey)
 		return false, nil
 	}
 
@@ -278,7 +348,6 @@ func (c *MemoryCache) GetWithTTL(ctx context.Context, key string, dest interface
 	}
 
 	if time.Now().After(item.expiration) {
-		delete(c.data, fullKey)
 		return 0, fmt.Errorf("cache miss")
 	}
 
@@ -310,6 +379,7 @@ func (c *MemoryCache) InvalidatePattern(ctx context.Context, pattern string) err
 			delete(c.data, key)
 		}
 	}
+	c.SortedSets.RemoveMatching(pattern)
 
 	return nil
 }
@@ -334,6 +404,71 @@ func (c *MemoryCache) GetMultiple(ctx context.Context, keys []string, dest inter
 	return json.Unmarshal(data, dest)
 }
 
+func (c *MemoryCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := NewBatchGetResult()
+	now := time.Now()
+	for _, key := range keys {
+		item, exists := c.data[c.getKey(key)]
+		if !exists || now.After(item.expiration) {
+			result.Missing = append(result.Missing, key)
+			continue
+		}
+
+		data, err := json.Marshal(item.value)
+		if err != nil {
+			result.Errors[key] = fmt.Errorf("cache marshal error: %w", err)
+			continue
+		}
+		result.Values[key] = data
+	}
+
+	return result, nil
+}
+
+func (c *MemoryCache) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := NewBatchSetResult()
+	now := time.Now()
+	for _, entry := range entries {
+		c.data[c.getKey(entry.Key)] = &cacheItem{
+			value:      entry.Value,
+			expiration: now.Add(entry.Expiration),
+		}
+		result.Succeeded = append(result.Succeeded, entry.Key)
+	}
+
+	c.cleanup()
+	return result, nil
+}
+
+// RandomKeys 以蓄水池抽样随机返回最多 n 个未过期的键，实现 LocalKeySampler
+func (c *MemoryCache) RandomKeys(n int) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, n)
+	now := time.Now()
+	seen := 0
+	for fullKey, item := range c.data {
+		if now.After(item.expiration) {
+			continue
+		}
+		key := strings.TrimPrefix(fullKey, c.getKey(""))
+		seen++
+		if len(keys) < n {
+			keys = append(keys, key)
+		} else if j := rand.Intn(seen); j < n {
+			keys[j] = key
+		}
+	}
+	return keys
+}
+
 func (c *MemoryCache) cleanup() {
 	now := time.Now()
 	for key, item := range c.data {
@@ -2,12 +2,12 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"sort"
 	"sync"
 	"time"
+	"user_crud_jwt/pkg/clock"
 	"user_crud_jwt/pkg/metrics"
 )
 
@@ -19,6 +19,7 @@ type CacheWarmupManager struct {
 	config           *WarmupConfig
 	scheduler        *WarmupScheduler
 	loader           *DataLoader
+	popularity       *PopularityTracker
 }
 
 // WarmupConfig 预热配置
@@ -31,6 +32,12 @@ type WarmupConfig struct {
 	MaxRetries        int           `json:"max_retries"`
 	RetryDelay        time.Duration `json:"retry_delay"`
 	EnableProgress    bool          `json:"enable_progress"`
+	// Strict 为 true 时，只要有键没有匹配的加载器就整体失败，不加载任何键
+	Strict bool `json:"strict"`
+	// LoadBatchSize 批量加载器每次查询的 ID 数，为 0 时为 100
+	LoadBatchSize int `json:"load_batch_size"`
+	// Clock 调度器使用的时钟，为 nil 时使用系统时钟
+	Clock clock.Clock `json:"-"`
 }
 
 // WarmupStrategy 预热策略接口
@@ -61,31 +68,37 @@ type WarmupScheduler struct {
 	mu      sync.RWMutex
 	stopCh  chan struct{}
 	config  *WarmupConfig
+	clock   clock.Clock
 }
 
 // WarmupTask 预热任务
 type WarmupTask struct {
-	ID       string                 `json:"id"`
-	Name     string                 `json:"name"`
-	Strategy string                 `json:"strategy"`
-	Keys     []string               `json:"keys"`
-	Schedule string                 `json:"schedule"`
-	Priority int                    `json:"priority"`
-	Enabled  bool                   `json:"enabled"`
-	LastRun  time.Time              `json:"last_run"`
-	NextRun  time.Time              `json:"next_run"`
-	Metadata map[string]interface{} `json:"metadata"`
-}
-
-// DataLoader 数据加载器
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Strategy string   `json:"strategy"`
+	Keys     []string `json:"keys"`
+	// Namespace 非空时每次运行以 smart 策略预热该命名空间（含子命名空间）的热门键，忽略 Strategy 与 Keys
+	Namespace string                 `json:"namespace,omitempty"`
+	Schedule  string                 `json:"schedule"`
+	Priority  int                    `json:"priority"`
+	Enabled   bool                   `json:"enabled"`
+	LastRun   time.Time              `json:"last_run"`
+	NextRun   time.Time              `json:"next_run"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+// DataLoader 数据加载器，按键前缀或键模板选择加载器
 type DataLoader struct {
 	cache            CacheService
 	metricsCollector *metrics.MetricsCollector
-	loaders          map[string]DataLoaderFunc
+	loaders          map[string]DataLoaderFunc // 键前缀到单键加载器
+	batchLoaders     []*templateLoader
+	batchSize        int
+	ttl              time.Duration
 	mu               sync.RWMutex
 }
 
-// DataLoaderFunc 数据加载函数
+// DataLoaderFunc 单键数据加载函数
 type DataLoaderFunc func(ctx context.Context, key string) (interface{}, error)
 
 // NewCacheWarmupManager 创建缓存预热管理器
@@ -98,6 +111,10 @@ func NewCacheWarmupManager(cache CacheService, metricsCollector *metrics.Metrics
 		scheduler:        NewWarmupScheduler(config),
 		loader:           NewDataLoader(cache, metricsCollector),
 	}
+	cwm.scheduler.manager = cwm
+	if config.LoadBatchSize > 0 {
+		cwm.loader.batchSize = config.LoadBatchSize
+	}
 
 	// 注册默认策略
 	cwm.registerDefaultStrategies()
@@ -116,6 +133,7 @@ func NewWarmupScheduler(config *WarmupConfig) *WarmupScheduler {
 		tasks:  make([]WarmupTask, 0),
 		stopCh: make(chan struct{}),
 		config: config,
+		clock:  clock.OrReal(config.Clock),
 	}
 }
 
@@ -125,6 +143,8 @@ func NewDataLoader(cache CacheService, metricsCollector *metrics.MetricsCollecto
 		cache:            cache,
 		metricsCollector: metricsCollector,
 		loaders:          make(map[string]DataLoaderFunc),
+		batchSize:        100,
+		ttl:              time.Hour,
 	}
 }
 
@@ -172,6 +192,13 @@ func (cwm *CacheWarmupManager) Warmup(ctx context.Context, strategyName string,
 		return nil, fmt.Errorf("warmup strategy %s not found", strategyName)
 	}
 
+	if cwm.config.Strict {
+		if unmatched := cwm.loader.Unmatched(keys); len(unmatched) > 0 {
+			cwm.recordMetrics("warmup_error", 0, false)
+			return nil, fmt.Errorf("%w: %d keys including %s", ErrNoLoader, len(unmatched), unmatched[0])
+		}
+	}
+
 	start := time.Now()
 	defer func() {
 		cwm.recordMetrics("warmup", time.Since(start), true)
@@ -204,9 +231,14 @@ func (cwm *CacheWarmupManager) GetTasks() []WarmupTask {
 	return cwm.scheduler.GetTasks()
 }
 
-// RegisterLoader 注册数据加载器
-func (cwm *CacheWarmupManager) RegisterLoader(name string, loader DataLoaderFunc) {
-	cwm.loader.RegisterLoader(name, loader)
+// RegisterLoader 按键前缀注册单键数据加载器
+func (cwm *CacheWarmupManager) RegisterLoader(prefix string, loader DataLoaderFunc) {
+	cwm.loader.RegisterLoader(prefix, loader)
+}
+
+// RegisterBatchLoader 按键模板注册批量加载器，见 DataLoader.RegisterBatchLoader
+func (cwm *CacheWarmupManager) RegisterBatchLoader(template string, loader BatchLoaderFunc, ttl time.Duration) error {
+	return cwm.loader.RegisterBatchLoader(template, loader, ttl)
 }
 
 // recordMetrics 记录指标
@@ -228,8 +260,8 @@ func (cwm *CacheWarmupManager) recordWarmupMetrics(result *WarmupResult) {
 	}
 
 	// 记录成功和失败的键数
-	cwm.metricsCollector.RecordDBError("warmup_success_keys", fmt.Sprintf("%d", result.SuccessKeys))
-	cwm.metricsCollector.RecordDBError("warmup_failed_keys", fmt.Sprintf("%d", result.FailedKeys))
+	cwm.metricsCollector.RecordEvent("cache_warmup", "success_keys", result.SuccessKeys)
+	cwm.metricsCollector.RecordEvent("cache_warmup", "failed_keys", result.FailedKeys)
 
 	// 记录预热时间
 	cwm.metricsCollector.RecordDBQuery("warmup_duration", result.Strategy, result.Duration, true)
@@ -237,12 +269,12 @@ func (cwm *CacheWarmupManager) recordWarmupMetrics(result *WarmupResult) {
 
 // Start 启动调度器
 func (ws *WarmupScheduler) Start() {
-	ticker := time.NewTicker(ws.config.SchedulerInterval)
+	ticker := ws.clock.NewTicker(ws.config.SchedulerInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			ws.runScheduledTasks()
 		case <-ws.stopCh:
 			return
@@ -262,11 +294,11 @@ func (ws *WarmupScheduler) AddTask(task WarmupTask) error {
 
 	// 设置下次运行时间
 	if task.Schedule == "" {
-		task.NextRun = time.Now()
+		task.NextRun = ws.clock.Now()
 	} else {
 		// 简化的调度时间解析
 		// 实际项目中应该使用更复杂的调度逻辑
-		task.NextRun = time.Now().Add(time.Hour)
+		task.NextRun = ws.clock.Now().Add(time.Hour)
 	}
 
 	ws.tasks = append(ws.tasks, task)
@@ -304,21 +336,22 @@ func (ws *WarmupScheduler) GetTasks() []WarmupTask {
 	return tasks
 }
 
-// runScheduledTasks 运行调度任务
+// runScheduledTasks 运行到期的任务并记录运行时间
 func (ws *WarmupScheduler) runScheduledTasks() {
-	ws.mu.RLock()
-	defer ws.mu.RUnlock()
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
 
-	now := time.Now()
+	now := ws.clock.Now()
 
-	for _, task := range ws.tasks {
+	for i := range ws.tasks {
+		task := &ws.tasks[i]
 		if !task.Enabled {
 			continue
 		}
 
 		if now.After(task.NextRun) {
 			// 运行任务
-			go ws.runTask(task)
+			go ws.runTask(*task)
 
 			// 更新下次运行时间
 			task.LastRun = now
@@ -327,44 +360,74 @@ func (ws *WarmupScheduler) runScheduledTasks() {
 	}
 }
 
-// runTask 运行任务
+// runTask 运行任务，调度器未关联预热管理器时只记录日志
 func (ws *WarmupScheduler) runTask(task WarmupTask) {
 	log.Printf("Running warmup task: %s", task.Name)
+	if ws.manager == nil {
+		log.Printf("Task %s completed for %d keys", task.Name, len(task.Keys))
+		return
+	}
 
-	// 这里应该调用实际的预热逻辑
-	// 简化实现，只是记录日志
-	log.Printf("Task %s completed for %d keys", task.Name, len(task.Keys))
+	var (
+		result *WarmupResult
+		err    error
+	)
+	if task.Namespace != "" {
+		result, err = ws.manager.WarmupPopular(context.Background(), task.Namespace)
+	} else {
+		result, err = ws.manager.Warmup(context.Background(), task.Strategy, task.Keys)
+	}
+	if err != nil {
+		log.Printf("Warmup task %s failed: %v", task.Name, err)
+		return
+	}
+	log.Printf("Task %s completed: %d/%d keys loaded", task.Name, result.SuccessKeys, result.TotalKeys)
 }
 
-// RegisterLoader 注册数据加载器
-func (dl *DataLoader) RegisterLoader(name string, loader DataLoaderFunc) {
+// RegisterLoader 按键前缀注册单键数据加载器，多个前缀匹配同一个键时最长的优先；
+// 键同时匹配批量加载器的模板时使用批量加载器
+func (dl *DataLoader) RegisterLoader(prefix string, loader DataLoaderFunc) {
 	dl.mu.Lock()
 	defer dl.mu.Unlock()
-	dl.loaders[name] = loader
+	dl.loaders[prefix] = loader
 }
 
-// LoadData 加载数据
+// LoadData 加载单个键并写入缓存，键已在缓存中时直接返回缓存的值；没有加载器匹配时返回 ErrNoLoader
 func (dl *DataLoader) LoadData(ctx context.Context, key string) (interface{}, error) {
-	// 简化的数据加载逻辑
-	// 实际项目中应该根据键名选择合适的加载器
-
-	// 尝试从缓存获取
-	var cached string
-	err := dl.cache.Get(ctx, key, &cached)
-	if err == nil && cached != "" {
-		var data interface{}
-		if err := json.Unmarshal([]byte(cached), &data); err == nil {
-			return data, nil
+	var cached interface{}
+	if err := dl.cache.Get(ctx, key, &cached); err == nil && cached != nil {
+		return cached, nil
+	}
+
+	tl, id, fn := dl.match(key)
+	var (
+		data interface{}
+		ttl  = dl.ttl
+	)
+	switch {
+	case tl != nil:
+		values, err := tl.load(ctx, []string{id})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", tl.template, err)
+		}
+		value, found := values[id]
+		if !found {
+			return nil, ErrSourceNotFound
+		}
+		data, ttl = value, tl.ttl
+	case fn != nil:
+		value, err := fn(ctx, key)
+		if err != nil {
+			return nil, err
 		}
+		data = value
+	default:
+		return nil, ErrNoLoader
 	}
 
-	// 模拟数据加载
-	data := fmt.Sprintf("data_for_%s", key)
-
-	// 缓存数据
-	jsonData, _ := json.Marshal(data)
-	dl.cache.Set(ctx, key, jsonData, time.Hour)
-
+	if err := dl.cache.Set(ctx, key, data, ttl); err != nil {
+		return nil, fmt.Errorf("failed to cache key %s: %w", key, err)
+	}
 	return data, nil
 }
 
@@ -383,30 +446,7 @@ func (iws *ImmediateWarmupStrategy) Warmup(ctx context.Context, keys []string) (
 		Metadata:  make(map[string]interface{}),
 	}
 
-	for _, key := range keys {
-		data, err := iws.loader.LoadData(ctx, key)
-		if err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to load key %s: %v", key, err))
-			continue
-		}
-
-		// 缓存数据
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to marshal data for key %s: %v", key, err))
-			continue
-		}
-
-		if err := iws.cache.Set(ctx, key, jsonData, time.Hour); err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to cache key %s: %v", key, err))
-			continue
-		}
-
-		result.SuccessKeys++
-	}
+	loadInto(ctx, iws.loader, keys, result)
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
@@ -474,16 +514,7 @@ func (bws *BatchWarmupStrategy) warmupBatch(ctx context.Context, keys []string)
 		Errors:    make([]string, 0),
 	}
 
-	for _, key := range keys {
-		_, err := bws.loader.LoadData(ctx, key)
-		if err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to load key %s: %v", key, err))
-			continue
-		}
-
-		result.SuccessKeys++
-	}
+	loadInto(ctx, bws.loader, keys, result)
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
@@ -549,16 +580,7 @@ func (pws *ProgressiveWarmupStrategy) warmupLevel(ctx context.Context, keys []st
 		Errors:    make([]string, 0),
 	}
 
-	for _, key := range keys {
-		_, err := pws.loader.LoadData(ctx, key)
-		if err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to load key %s: %v", key, err))
-			continue
-		}
-
-		result.SuccessKeys++
-	}
+	loadInto(ctx, pws.loader, keys, result)
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
@@ -604,16 +626,7 @@ func (pws *PriorityWarmupStrategy) Warmup(ctx context.Context, keys []string) (*
 		return priorityI > priorityJ
 	})
 
-	for _, key := range sortedKeys {
-		_, err := pws.loader.LoadData(ctx, key)
-		if err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to load key %s: %v", key, err))
-			continue
-		}
-
-		result.SuccessKeys++
-	}
+	loadInto(ctx, pws.loader, sortedKeys, result)
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
@@ -660,16 +673,7 @@ func (sws *SmartWarmupStrategy) Warmup(ctx context.Context, keys []string) (*War
 	// 根据分析结果选择预热策略
 	sortedKeys := sws.analyzer.SortKeysByPriority(keys, analysis)
 
-	for _, key := range sortedKeys {
-		_, err := sws.loader.LoadData(ctx, key)
-		if err != nil {
-			result.FailedKeys++
-			result.Errors = append(result.Errors, fmt.Sprintf("Failed to load key %s: %v", key, err))
-			continue
-		}
-
-		result.SuccessKeys++
-	}
+	loadInto(ctx, sws.loader, sortedKeys, result)
 
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime)
@@ -711,6 +715,21 @@ func NewWarmupAnalyzer() *WarmupAnalyzer {
 	}
 }
 
+// Observe 以热门键统计的分数作为访问次数，使未经 RecordAccess 的键也能按热度排序
+func (wa *WarmupAnalyzer) Observe(popular []PopularKey) {
+	wa.mu.Lock()
+	defer wa.mu.Unlock()
+
+	for _, p := range popular {
+		pattern := wa.accessPatterns[p.Key]
+		pattern.Key = p.Key
+		if count := int(p.Score); count > pattern.AccessCount {
+			pattern.AccessCount = count
+		}
+		wa.accessPatterns[p.Key] = pattern
+	}
+}
+
 // AnalyzeKeys 分析键
 func (wa *WarmupAnalyzer) AnalyzeKeys(keys []string) map[string]interface{} {
 	wa.mu.RLock()
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// callerCache 读取时按 ctx 中的调用方（HTTP 路由、gRPC 方法）记录命中、未命中与耗时
+type callerCache struct {
+	CacheService
+	cacheType string
+	collector *metrics.MetricsCollector
+}
+
+// AttributeCallers 包装缓存，Get、GetWithTTL、GetMultiple 与 GetMany 按调用方计入 cacheType 的命中统计，
+// 调用方由 RequestIDMiddleware 与 gRPC 指标拦截器写入 ctx，没有调用方的读取记为 background
+func AttributeCallers(inner CacheService, cacheType string, collector *metrics.MetricsCollector) CacheService {
+	return &callerCache{CacheService: inner, cacheType: cacheType, collector: collector}
+}
+
+func (c *callerCache) Get(ctx context.Context, key string, dest interface{}) error {
+	start := time.Now()
+	err := c.CacheService.Get(ctx, key, dest)
+	c.record(ctx, start, err)
+	return err
+}
+
+func (c *callerCache) GetWithTTL(ctx context.Context, key string, dest interface{}) (time.Duration, error) {
+	start := time.Now()
+	ttl, err := c.CacheService.GetWithTTL(ctx, key, dest)
+	c.record(ctx, start, err)
+	return ttl, err
+}
+
+// GetMultiple 整体成功时各键计为命中，失败时计一次出错
+func (c *callerCache) GetMultiple(ctx context.Context, keys []string, dest interface{}) error {
+	start := time.Now()
+	err := c.CacheService.GetMultiple(ctx, keys, dest)
+	if err != nil {
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), 0, 0, 1)
+	} else {
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), len(keys), 0, 0)
+	}
+	return err
+}
+
+func (c *callerCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	start := time.Now()
+	result, err := c.CacheService.GetMany(ctx, keys)
+	switch {
+	case err != nil:
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), 0, 0, len(keys))
+	case result != nil:
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), len(result.Values), len(result.Missing), len(result.Errors))
+	}
+	return result, err
+}
+
+// record 单键读取："cache miss" 计为未命中，其他错误计为出错
+func (c *callerCache) record(ctx context.Context, start time.Time, err error) {
+	switch {
+	case err == nil:
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), 1, 0, 0)
+	case err.Error() == "cache miss":
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), 0, 1, 0)
+	default:
+		c.collector.RecordCacheCaller(ctx, c.cacheType, time.Since(start), 0, 0, 1)
+	}
+}
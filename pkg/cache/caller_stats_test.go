@@ -0,0 +1,45 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAttributeCallers 单键与批量读取按 ctx 中的调用方计入命中与未命中，没有调用方的读取记为 background
+func TestAttributeCallers(t *testing.T) {
+	store := fakes.NewCache(nil)
+	collector := metrics.GetGlobalCollector()
+	attributed := cache.AttributeCallers(store, "caller_test", collector)
+	ctx := context.Background()
+	require.NoError(t, store.Set(ctx, "user:1", "alice", 0))
+	require.NoError(t, store.Set(ctx, "user:2", "bob", 0))
+
+	route := ctxutil.WithCaller(ctx, "GET /api/v1/users/:id")
+	var dest string
+	require.NoError(t, attributed.Get(route, "user:1", &dest))
+	assert.Error(t, attributed.Get(route, "user:3", &dest))
+	_, err := attributed.GetMany(route, []string{"user:1", "user:2", "user:4"})
+	require.NoError(t, err)
+	require.NoError(t, attributed.Get(ctx, "user:2", &dest))
+
+	stats := make(map[string]metrics.CacheCallerStat)
+	for _, stat := range collector.CacheCallerStats() {
+		if stat.CacheType == "caller_test" {
+			stats[stat.Caller] = stat
+		}
+	}
+	require.Len(t, stats, 2)
+	byRoute := stats["GET /api/v1/users/:id"]
+	assert.Equal(t, uint64(3), byRoute.Hits)
+	assert.Equal(t, uint64(2), byRoute.Misses)
+	assert.Equal(t, uint64(3), byRoute.Reads, "a batch read counts once")
+	assert.InDelta(t, 0.6, byRoute.HitRate, 0.001)
+	assert.Equal(t, uint64(1), stats[metrics.BackgroundCaller].Hits)
+}
@@ -0,0 +1,54 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmupScheduler_RunsDueTasks(t *testing.T) {
+	clock := fakes.NewClock(time.Time{})
+	start := clock.Now()
+	scheduler := cache.NewWarmupScheduler(&cache.WarmupConfig{SchedulerInterval: time.Minute, Clock: clock})
+	go scheduler.Start()
+	defer scheduler.Stop()
+	clock.BlockUntil(1)
+
+	require.NoError(t, scheduler.AddTask(cache.WarmupTask{ID: "hot", Name: "hot", Enabled: true}))
+	require.NoError(t, scheduler.AddTask(cache.WarmupTask{ID: "off", Name: "off"}))
+
+	clock.Advance(time.Minute)
+	require.Eventually(t, func() bool {
+		return scheduler.GetTasks()[0].LastRun.Equal(start.Add(time.Minute))
+	}, time.Second, time.Millisecond)
+
+	tasks := scheduler.GetTasks()
+	assert.Equal(t, start.Add(time.Minute+time.Hour), tasks[0].NextRun, "next run is persisted")
+	assert.True(t, tasks[1].LastRun.IsZero(), "disabled task is skipped")
+}
+
+func TestDelayedInvalidation_UsesClock(t *testing.T) {
+	clock := fakes.NewClock(time.Time{})
+	store := fakes.NewCache(clock)
+	manager := cache.NewCacheConsistencyManager(store, nil, &cache.ConsistencyConfig{Clock: clock})
+	defer manager.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "user:1", "a", 0))
+	require.NoError(t, manager.Invalidate(ctx, "delayed", []string{"user:1"}))
+
+	clock.Advance(4 * time.Second)
+	// 再次失效会重新计时
+	require.NoError(t, manager.Invalidate(ctx, "delayed", []string{"user:1"}))
+	clock.Advance(4 * time.Second)
+	assert.Equal(t, []string{"user:1"}, store.Keys())
+
+	clock.Advance(time.Second)
+	assert.Empty(t, store.Keys())
+	assert.Equal(t, 0, clock.Waiters())
+}
@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// nodeLatencyBuckets 节点命令延迟直方图的桶上界，与 redis_node_command_duration_seconds 一致
+var nodeLatencyBuckets = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second,
+}
+
+// NodePoolStats 单个节点的连接池统计，Hits、Misses、Timeouts 为客户端创建以来的累计值
+type NodePoolStats struct {
+	Hits       uint32 `json:"hits"`     // 从池中取到空闲连接
+	Misses     uint32 `json:"misses"`   // 池中没有空闲连接，新建连接
+	Timeouts   uint32 `json:"timeouts"` // 等待连接超时
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"` // 因过期或空闲过久被关闭的连接
+}
+
+// NodeLatency 单个节点的命令延迟，分位数由直方图估算，管道按一次计入
+type NodeLatency struct {
+	Commands int64         `json:"commands"`
+	Errors   int64         `json:"errors"`
+	P50      time.Duration `json:"p50"`
+	P95      time.Duration `json:"p95"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+}
+
+// NodeStats 单个节点的连接池与延迟统计
+type NodeStats struct {
+	Node    string        `json:"node"`
+	Pool    NodePoolStats `json:"pool"`
+	Latency NodeLatency   `json:"latency"`
+}
+
+// latencyHistogram 单个节点的延迟直方图，counts 比 nodeLatencyBuckets 多一个溢出桶
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	errors int64
+	max    time.Duration
+}
+
+func (h *latencyHistogram) observe(duration time.Duration, failed bool) {
+	bucket := sort.Search(len(nodeLatencyBuckets), func(i int) bool { return duration <= nodeLatencyBuckets[i] })
+	h.counts[bucket]++
+	h.count++
+	if failed {
+		h.errors++
+	}
+	if duration > h.max {
+		h.max = duration
+	}
+}
+
+// percentile 分位数所在桶的上界，不超过观测到的最大值
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	rank := int64(math.Ceil(p * float64(h.count)))
+	var seen int64
+	for bucket, n := range h.counts {
+		seen += n
+		if seen >= rank && bucket < len(nodeLatencyBuckets) {
+			return min(nodeLatencyBuckets[bucket], h.max)
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) snapshot() NodeLatency {
+	latency := NodeLatency{Commands: h.count, Errors: h.errors, Max: h.max}
+	if h.count > 0 {
+		latency.P50 = h.percentile(0.50)
+		latency.P95 = h.percentile(0.95)
+		latency.P99 = h.percentile(0.99)
+	}
+	return latency
+}
+
+// NodeObserver 按节点统计命令延迟与连接池，通过挂在各节点客户端上的钩子采集，
+// 同时把节点返回的 MOVED/ASK 交给键路由器
+type NodeObserver struct {
+	router           *KeyRouter
+	metricsCollector *metrics.MetricsCollector
+	clock            clock.Clock
+
+	mu        sync.Mutex
+	latencies map[string]*latencyHistogram
+	pools     map[string]NodePoolStats // 上次上报到 Prometheus 的连接池统计，用于计算计数器增量
+}
+
+// NewNodeObserver 创建节点观测器，router 为空时不处理重定向
+func NewNodeObserver(router *KeyRouter, metricsCollector *metrics.MetricsCollector) *NodeObserver {
+	return &NodeObserver{
+		router:           router,
+		metricsCollector: metricsCollector,
+		clock:            clock.Real,
+		latencies:        make(map[string]*latencyHistogram),
+		pools:            make(map[string]NodePoolStats),
+	}
+}
+
+// SetClock 替换时钟，用于测试
+func (o *NodeObserver) SetClock(c clock.Clock) {
+	o.clock = clock.OrReal(c)
+}
+
+// Hook 节点 node 的客户端钩子
+func (o *NodeObserver) Hook(node string) redis.Hook {
+	return nodeHook{observer: o, node: node}
+}
+
+// observe 记录一次命令或管道的延迟；键不存在与重定向不计为错误
+func (o *NodeObserver) observe(node string, duration time.Duration, err error) {
+	failed := err != nil && !errors.Is(err, redis.Nil)
+	if failed && o.router != nil && o.router.ObserveRedirect(err) {
+		failed = false
+	}
+
+	o.mu.Lock()
+	h, ok := o.latencies[node]
+	if !ok {
+		h = &latencyHistogram{counts: make([]int64, len(nodeLatencyBuckets)+1)}
+		o.latencies[node] = h
+	}
+	h.observe(duration, failed)
+	o.mu.Unlock()
+
+	if o.metricsCollector != nil {
+		o.metricsCollector.RecordRedisNodeCommand(node, duration, !failed)
+	}
+}
+
+// Latency 节点的延迟统计
+func (o *NodeObserver) Latency(node string) NodeLatency {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if h, ok := o.latencies[node]; ok {
+		return h.snapshot()
+	}
+	return NodeLatency{}
+}
+
+// reportPool 记录节点的连接池统计，累计值按上次上报的增量计入 Prometheus 计数器
+func (o *NodeObserver) reportPool(node string, stats NodePoolStats) {
+	if o.metricsCollector == nil {
+		return
+	}
+	o.mu.Lock()
+	previous := o.pools[node]
+	o.pools[node] = stats
+	o.mu.Unlock()
+
+	// 节点客户端重建后累计值从零开始
+	if stats.Hits < previous.Hits || stats.Misses < previous.Misses || stats.Timeouts < previous.Timeouts || stats.StaleConns < previous.StaleConns {
+		previous = NodePoolStats{}
+	}
+	o.metricsCollector.UpdateRedisNodeConns(node, int(stats.TotalConns), int(stats.IdleConns))
+	o.metricsCollector.RecordRedisNodePool(node, "hit", int(stats.Hits-previous.Hits))
+	o.metricsCollector.RecordRedisNodePool(node, "miss", int(stats.Misses-previous.Misses))
+	o.metricsCollector.RecordRedisNodePool(node, "timeout", int(stats.Timeouts-previous.Timeouts))
+	o.metricsCollector.RecordRedisNodePool(node, "stale", int(stats.StaleConns-previous.StaleConns))
+}
+
+type nodeHookStartKey struct{}
+
+// nodeHook 节点客户端钩子：集群客户端会自动跟随重定向，只有节点客户端能看到原始的 MOVED/ASK 回复
+type nodeHook struct {
+	observer *NodeObserver
+	node     string
+}
+
+func (h nodeHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, nodeHookStartKey{}, h.observer.clock.Now()), nil
+}
+
+func (h nodeHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.observer.observe(h.node, h.elapsed(ctx), cmd.Err())
+	return nil
+}
+
+func (h nodeHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, nodeHookStartKey{}, h.observer.clock.Now()), nil
+}
+
+func (h nodeHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var failed error
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if h.observer.router != nil && h.observer.router.ObserveRedirect(err) {
+			continue
+		}
+		if err != nil && !errors.Is(err, redis.Nil) && failed == nil {
+			failed = err
+		}
+	}
+	h.observer.observe(h.node, h.elapsed(ctx), failed)
+	return nil
+}
+
+func (h nodeHook) elapsed(ctx context.Context) time.Duration {
+	start, ok := ctx.Value(nodeHookStartKey{}).(time.Time)
+	if !ok {
+		return 0
+	}
+	return h.observer.clock.Since(start)
+}
+
+// NodeStats 集群各节点（含从节点）的连接池与延迟统计，按节点地址排序，同时更新 Prometheus 指标
+func (rc *RedisCluster) NodeStats(ctx context.Context) ([]NodeStats, error) {
+	var (
+		mu    sync.Mutex
+		stats []NodeStats
+	)
+	err := rc.cluster.ForEachShard(ctx, func(ctx context.Context, client *redis.Client) error {
+		node := client.Options().Addr
+		pool := client.PoolStats()
+		nodeStats := NodeStats{
+			Node: node,
+			Pool: NodePoolStats{
+				Hits:       pool.Hits,
+				Misses:     pool.Misses,
+				Timeouts:   pool.Timeouts,
+				TotalConns: pool.TotalConns,
+				IdleConns:  pool.IdleConns,
+				StaleConns: pool.StaleConns,
+			},
+			Latency: rc.nodeObserver.Latency(node),
+		}
+		rc.nodeObserver.reportPool(node, nodeStats.Pool)
+
+		mu.Lock()
+		defer mu.Unlock()
+		stats = append(stats, nodeStats)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect node stats: %w", err)
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Node < stats[j].Node })
+	return stats, nil
+}
+
+// reportNodeStats 定期把各节点的连接池统计上报到 Prometheus，直到 ctx 取消
+func (rc *RedisCluster) reportNodeStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := rc.NodeStats(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to report Redis cluster node stats: %v", err)
+			}
+		}
+	}
+}
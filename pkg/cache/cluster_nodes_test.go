@@ -0,0 +1,62 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCommand 通过节点钩子执行一条耗时 d 的命令
+func runCommand(t *testing.T, hook redis.Hook, clock *fakes.Clock, d time.Duration, err error) {
+	t.Helper()
+	cmd := redis.NewStringCmd(context.Background(), "get", "key")
+	ctx, hookErr := hook.BeforeProcess(context.Background(), cmd)
+	require.NoError(t, hookErr)
+	clock.Advance(d)
+	cmd.SetErr(err)
+	require.NoError(t, hook.AfterProcess(ctx, cmd))
+}
+
+// TestNodeObserver_Latency 按节点统计延迟分位数；键不存在与重定向不计为错误，MOVED 交给键路由器
+func TestNodeObserver_Latency(t *testing.T) {
+	slots := &fakeSlots{}
+	router := cache.NewKeyRouter(nil, slots.load, nil, nil)
+	defer router.Close()
+	observer := cache.NewNodeObserver(router, nil)
+	clock := fakes.NewClock(time.Time{})
+	observer.SetClock(clock)
+	hot, cold := observer.Hook("10.0.0.1:6379"), observer.Hook("10.0.0.2:6379")
+
+	for i := 0; i < 97; i++ {
+		runCommand(t, hot, clock, 800*time.Microsecond, nil)
+	}
+	runCommand(t, hot, clock, 40*time.Millisecond, redis.Nil)
+	runCommand(t, hot, clock, 200*time.Millisecond, errors.New("MOVED 10778 10.0.0.3:6379"))
+	runCommand(t, hot, clock, 3*time.Second, errors.New("i/o timeout"))
+	runCommand(t, cold, clock, 50*time.Microsecond, nil)
+
+	assert.Equal(t, cache.NodeLatency{
+		Commands: 100,
+		Errors:   1,
+		P50:      time.Millisecond,
+		P95:      time.Millisecond,
+		P99:      250 * time.Millisecond,
+		Max:      3 * time.Second,
+	}, observer.Latency("10.0.0.1:6379"))
+	assert.Equal(t, cache.NodeLatency{
+		Commands: 1,
+		P50:      50 * time.Microsecond,
+		P95:      50 * time.Microsecond,
+		P99:      50 * time.Microsecond,
+		Max:      50 * time.Microsecond,
+	}, observer.Latency("10.0.0.2:6379"), "percentiles never exceed the observed maximum")
+	assert.Equal(t, "10.0.0.3:6379", router.NodeFor("user:1"))
+	assert.Equal(t, cache.NodeLatency{}, observer.Latency("10.0.0.9:6379"))
+}
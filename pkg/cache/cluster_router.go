@@ -0,0 +1,402 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/events"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TopicClusterTopologyChanged 集群槽位分布或节点变化，监控订阅后可发出告警
+const TopicClusterTopologyChanged = "redis.cluster.topology_changed"
+
+// SlotRange 槽位区间及负责该区间的节点
+type SlotRange struct {
+	Start    int      `json:"start"`
+	End      int      `json:"end"` // 包含
+	Master   string   `json:"master"`
+	Replicas []string `json:"replicas,omitempty"`
+}
+
+// SlotsLoader 读取集群当前的槽位分布，生产环境为 CLUSTER SLOTS
+type SlotsLoader func(ctx context.Context) ([]SlotRange, error)
+
+// TopologyChange 拓扑变化事件
+type TopologyChange struct {
+	MovedSlots   int       `json:"moved_slots"` // 负责节点发生变化的槽位数
+	AddedNodes   []string  `json:"added_nodes,omitempty"`
+	RemovedNodes []string  `json:"removed_nodes,omitempty"`
+	Nodes        []string  `json:"nodes"`
+	Reason       string    `json:"reason"` // periodic 或 redirect_storm
+	ChangedAt    time.Time `json:"changed_at"`
+}
+
+// KeyRouterConfig 键路由器配置
+type KeyRouterConfig struct {
+	RefreshInterval    time.Duration `json:"refresh_interval"`     // 定期刷新拓扑的间隔
+	MinRefreshInterval time.Duration `json:"min_refresh_interval"` // 重定向触发的刷新之间的最小间隔
+	StormThreshold     int           `json:"storm_threshold"`      // StormWindow 内的重定向次数达到该值时视为重定向风暴，立即刷新拓扑
+	StormWindow        time.Duration `json:"storm_window"`
+}
+
+// DefaultKeyRouterConfig 默认键路由器配置
+func DefaultKeyRouterConfig() *KeyRouterConfig {
+	return &KeyRouterConfig{
+		RefreshInterval:    30 * time.Second,
+		MinRefreshInterval: time.Second,
+		StormThreshold:     50,
+		StormWindow:        time.Second,
+	}
+}
+
+// KeyRouter 键路由器：维护槽位到主节点的映射，定期通过 CLUSTER SLOTS 刷新；
+// 命令收到 MOVED 时立即更新对应槽位，重定向过于密集时提前刷新整个拓扑
+type KeyRouter struct {
+	config           *KeyRouterConfig
+	loader           SlotsLoader
+	metricsCollector *metrics.MetricsCollector
+	clock            clock.Clock
+	changes          *events.Bus[TopologyChange]
+	trigger          chan struct{}
+
+	mu          sync.RWMutex
+	seeds       []string
+	slots       []string // 下标为槽位，值为主节点地址，未知为空
+	lastRefresh time.Time
+
+	stormMu     sync.Mutex
+	windowStart time.Time
+	windowCount int
+
+	moved  atomic.Int64
+	ask    atomic.Int64
+	storms atomic.Int64
+}
+
+// KeyRouterStats 键路由器状态
+type KeyRouterStats struct {
+	Nodes        []string  `json:"nodes"`
+	CoveredSlots int       `json:"covered_slots"`
+	Moved        int64     `json:"moved"`
+	Ask          int64     `json:"ask"`
+	Storms       int64     `json:"storms"`
+	LastRefresh  time.Time `json:"last_refresh"`
+}
+
+// NodeBatch 同一节点上的键，按槽位分组，组内保持原有顺序
+type NodeBatch struct {
+	Node  string           `json:"node"` // 槽位未知时为空，由集群客户端自行路由
+	Slots map[int][]string `json:"slots"`
+}
+
+// NewKeyRouter 创建键路由器，nodes 为种子节点，拓扑加载前不知道任何槽位的归属
+func NewKeyRouter(nodes []string, loader SlotsLoader, config *KeyRouterConfig, metricsCollector *metrics.MetricsCollector) *KeyRouter {
+	if config == nil {
+		config = DefaultKeyRouterConfig()
+	}
+	return &KeyRouter{
+		config:           config,
+		loader:           loader,
+		metricsCollector: metricsCollector,
+		clock:            clock.Real,
+		changes:          events.NewBus[TopologyChange]("redis-cluster", events.DefaultConfig()),
+		trigger:          make(chan struct{}, 1),
+		seeds:            nodes,
+		slots:            make([]string, clusterSlotCount),
+	}
+}
+
+// SetClock 替换时钟，用于测试
+func (r *KeyRouter) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// Changes 拓扑变化事件总线
+func (r *KeyRouter) Changes() *events.Bus[TopologyChange] {
+	return r.changes
+}
+
+// Refresh 重新加载槽位分布，与当前映射不同时发布 TopicClusterTopologyChanged 事件；首次加载不发布
+func (r *KeyRouter) Refresh(ctx context.Context) error {
+	return r.refresh(ctx, "periodic")
+}
+
+func (r *KeyRouter) refresh(ctx context.Context, reason string) error {
+	ranges, err := r.loader(ctx)
+	if r.metricsCollector != nil {
+		r.metricsCollector.RecordRedisTopologyRefresh(err == nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load cluster slots: %w", err)
+	}
+
+	slots := make([]string, clusterSlotCount)
+	for _, rng := range ranges {
+		for slot := max(rng.Start, 0); slot <= rng.End && slot < clusterSlotCount; slot++ {
+			slots[slot] = rng.Master
+		}
+	}
+
+	r.mu.Lock()
+	previous := r.slots
+	r.slots = slots
+	r.lastRefresh = r.clock.Now()
+	r.mu.Unlock()
+
+	initial := len(nodesOf(previous)) == 0
+	change := diffTopology(previous, slots)
+	if initial || (change.MovedSlots == 0 && len(change.AddedNodes) == 0 && len(change.RemovedNodes) == 0) {
+		return nil
+	}
+	change.Reason = reason
+	change.ChangedAt = r.clock.Now()
+	log.Printf("Redis cluster topology changed (%s): %d slots moved, nodes added %v, removed %v",
+		reason, change.MovedSlots, change.AddedNodes, change.RemovedNodes)
+	if r.metricsCollector != nil {
+		r.metricsCollector.RecordRedisTopologyChange(change.MovedSlots)
+	}
+	if err := r.changes.Publish(ctx, TopicClusterTopologyChanged, "", change); err != nil {
+		log.Printf("Failed to publish cluster topology change: %v", err)
+	}
+	return nil
+}
+
+// diffTopology 比较两份槽位映射，MovedSlots 只计入两边都已知且节点不同的槽位
+func diffTopology(previous, current []string) TopologyChange {
+	change := TopologyChange{Nodes: nodesOf(current)}
+	for slot := range current {
+		if previous[slot] != "" && current[slot] != "" && previous[slot] != current[slot] {
+			change.MovedSlots++
+		}
+	}
+	before, after := nodesOf(previous), change.Nodes
+	for _, node := range after {
+		if !containsString(before, node) {
+			change.AddedNodes = append(change.AddedNodes, node)
+		}
+	}
+	for _, node := range before {
+		if !containsString(after, node) {
+			change.RemovedNodes = append(change.RemovedNodes, node)
+		}
+	}
+	return change
+}
+
+// nodesOf 槽位映射中出现的节点，按地址排序
+func nodesOf(slots []string) []string {
+	seen := make(map[string]bool)
+	nodes := make([]string, 0)
+	for _, node := range slots {
+		if node != "" && !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Run 定期刷新拓扑，重定向风暴时提前刷新，直到 ctx 取消
+func (r *KeyRouter) Run(ctx context.Context) {
+	if err := r.refresh(ctx, "periodic"); err != nil {
+		log.Printf("Redis cluster topology refresh failed: %v", err)
+	}
+	ticker := r.clock.NewTicker(r.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		reason := "periodic"
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-r.trigger:
+			reason = "redirect_storm"
+			r.mu.RLock()
+			recent := r.clock.Since(r.lastRefresh) < r.config.MinRefreshInterval
+			r.mu.RUnlock()
+			if recent {
+				continue
+			}
+		}
+		if err := r.refresh(ctx, reason); err != nil {
+			log.Printf("Redis cluster topology refresh failed: %v", err)
+		}
+	}
+}
+
+// NodeFor 键所在槽位的主节点，槽位未知时返回空
+func (r *KeyRouter) NodeFor(key string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.slots[ClusterKeySlot(key)]
+}
+
+// GroupByNode 按主节点、再按槽位分组，结果按节点地址排序
+func (r *KeyRouter) GroupByNode(keys []string) []NodeBatch {
+	r.mu.RLock()
+	byNode := make(map[string]map[int][]string)
+	for _, key := range keys {
+		slot := ClusterKeySlot(key)
+		node := r.slots[slot]
+		if byNode[node] == nil {
+			byNode[node] = make(map[int][]string)
+		}
+		byNode[node][slot] = append(byNode[node][slot], key)
+	}
+	r.mu.RUnlock()
+
+	batches := make([]NodeBatch, 0, len(byNode))
+	for node, slots := range byNode {
+		batches = append(batches, NodeBatch{Node: node, Slots: slots})
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].Node < batches[j].Node })
+	return batches
+}
+
+// ObserveRedirect 处理命令返回的 MOVED/ASK 错误，err 不是重定向时返回 false。
+// MOVED 表示槽位已永久迁移，立即更新映射；ASK 只在迁移过程中临时生效，不更新映射
+func (r *KeyRouter) ObserveRedirect(err error) bool {
+	if err == nil {
+		return false
+	}
+	kind, slot, addr, ok := parseRedirect(err.Error())
+	if !ok {
+		return false
+	}
+
+	switch kind {
+	case "moved":
+		r.moved.Add(1)
+		r.mu.Lock()
+		r.slots[slot] = addr
+		r.mu.Unlock()
+	case "ask":
+		r.ask.Add(1)
+	}
+	if r.metricsCollector != nil {
+		r.metricsCollector.RecordRedisRedirect(kind)
+	}
+	r.observeStorm()
+	return true
+}
+
+// observeStorm 统计窗口内的重定向次数，达到阈值时记录一次风暴并请求刷新拓扑
+func (r *KeyRouter) observeStorm() {
+	if r.config.StormThreshold <= 0 {
+		return
+	}
+	now := r.clock.Now()
+	r.stormMu.Lock()
+	if now.Sub(r.windowStart) >= r.config.StormWindow {
+		r.windowStart, r.windowCount = now, 0
+	}
+	r.windowCount++
+	storm := r.windowCount == r.config.StormThreshold
+	r.stormMu.Unlock()
+	if !storm {
+		return
+	}
+
+	r.storms.Add(1)
+	log.Printf("Redis cluster redirect storm: %d redirects within %v, refreshing topology", r.config.StormThreshold, r.config.StormWindow)
+	if r.metricsCollector != nil {
+		r.metricsCollector.RecordRedisRedirectStorm()
+	}
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// parseRedirect 解析 "MOVED 3999 127.0.0.1:6381" 与 "ASK 3999 127.0.0.1:6381"
+func parseRedirect(message string) (kind string, slot int, addr string, ok bool) {
+	fields := strings.Fields(message)
+	if len(fields) != 3 {
+		return "", 0, "", false
+	}
+	switch fields[0] {
+	case "MOVED":
+		kind = "moved"
+	case "ASK":
+		kind = "ask"
+	default:
+		return "", 0, "", false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= clusterSlotCount {
+		return "", 0, "", false
+	}
+	return kind, slot, fields[2], true
+}
+
+// Stats 键路由器状态
+func (r *KeyRouter) Stats() KeyRouterStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	covered := 0
+	for _, node := range r.slots {
+		if node != "" {
+			covered++
+		}
+	}
+	nodes := nodesOf(r.slots)
+	if len(nodes) == 0 {
+		nodes = r.seeds
+	}
+	return KeyRouterStats{
+		Nodes:        nodes,
+		CoveredSlots: covered,
+		Moved:        r.moved.Load(),
+		Ask:          r.ask.Load(),
+		Storms:       r.storms.Load(),
+		LastRefresh:  r.lastRefresh,
+	}
+}
+
+// Close 关闭拓扑变化事件总线
+func (r *KeyRouter) Close() {
+	r.changes.Close()
+}
+
+// clusterSlotsLoader 通过 CLUSTER SLOTS 读取槽位分布
+func clusterSlotsLoader(client func() *redis.ClusterClient) SlotsLoader {
+	return func(ctx context.Context) ([]SlotRange, error) {
+		slots, err := client().ClusterSlots(ctx).Result()
+		if err != nil {
+			return nil, err
+		}
+		ranges := make([]SlotRange, 0, len(slots))
+		for _, slot := range slots {
+			if len(slot.Nodes) == 0 {
+				continue
+			}
+			rng := SlotRange{Start: slot.Start, End: slot.End, Master: slot.Nodes[0].Addr}
+			for _, replica := range slot.Nodes[1:] {
+				rng.Replicas = append(rng.Replicas, replica.Addr)
+			}
+			ranges = append(ranges, rng)
+		}
+		return ranges, nil
+	}
+}
@@ -0,0 +1,126 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/events"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlots 可修改的槽位分布
+type fakeSlots struct {
+	mu     sync.Mutex
+	ranges []cache.SlotRange
+	loads  atomic.Int32
+}
+
+func (f *fakeSlots) set(ranges ...cache.SlotRange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ranges = ranges
+}
+
+func (f *fakeSlots) load(ctx context.Context) ([]cache.SlotRange, error) {
+	f.loads.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ranges, nil
+}
+
+// TestKeyRouter_RefreshPublishesChanges 按刷新后的槽位映射分组；重新分片后发布拓扑变化事件，首次加载不发布
+func TestKeyRouter_RefreshPublishesChanges(t *testing.T) {
+	slots := &fakeSlots{}
+	slots.set(
+		cache.SlotRange{Start: 0, End: 8191, Master: "10.0.0.1:6379"},
+		cache.SlotRange{Start: 8192, End: 16383, Master: "10.0.0.2:6379"},
+	)
+	router := cache.NewKeyRouter([]string{"10.0.0.1:6379"}, slots.load, nil, nil)
+	defer router.Close()
+	var changes []cache.TopologyChange
+	require.NoError(t, router.Changes().Subscribe("test", func(ctx context.Context, event events.Event[cache.TopologyChange]) error {
+		changes = append(changes, event.Payload)
+		return nil
+	}))
+
+	ctx := context.Background()
+	assert.Empty(t, router.NodeFor("user:1"), "unknown before the first refresh")
+	require.NoError(t, router.Refresh(ctx))
+	assert.Empty(t, changes)
+
+	// user:1 在槽位 10778，user:{1}:profile 与 user:{1}:posts 共用槽位 9842，user:3 在槽位 2648
+	batches := router.GroupByNode([]string{"user:1", "user:3", "user:{1}:profile", "user:{1}:posts"})
+	require.Len(t, batches, 2)
+	assert.Equal(t, "10.0.0.1:6379", batches[0].Node)
+	assert.Equal(t, map[int][]string{2648: {"user:3"}}, batches[0].Slots)
+	assert.Equal(t, "10.0.0.2:6379", batches[1].Node)
+	assert.Equal(t, map[int][]string{10778: {"user:1"}, 9842: {"user:{1}:profile", "user:{1}:posts"}}, batches[1].Slots)
+
+	slots.set(
+		cache.SlotRange{Start: 0, End: 8191, Master: "10.0.0.1:6379"},
+		cache.SlotRange{Start: 8192, End: 9999, Master: "10.0.0.2:6379"},
+		cache.SlotRange{Start: 10000, End: 16383, Master: "10.0.0.3:6379"},
+	)
+	require.NoError(t, router.Refresh(ctx))
+	assert.Equal(t, "10.0.0.3:6379", router.NodeFor("user:1"))
+	require.Len(t, changes, 1)
+	assert.Equal(t, 6384, changes[0].MovedSlots)
+	assert.Equal(t, []string{"10.0.0.3:6379"}, changes[0].AddedNodes)
+	assert.Empty(t, changes[0].RemovedNodes)
+	assert.Equal(t, "periodic", changes[0].Reason)
+
+	require.NoError(t, router.Refresh(ctx))
+	assert.Len(t, changes, 1, "unchanged topology is not published")
+}
+
+// TestKeyRouter_Redirects MOVED 立即更新槽位映射，ASK 只计数；窗口内重定向达到阈值时提前刷新拓扑
+func TestKeyRouter_Redirects(t *testing.T) {
+	slots := &fakeSlots{}
+	slots.set(cache.SlotRange{Start: 0, End: 16383, Master: "10.0.0.1:6379"})
+	clock := fakes.NewClock(time.Time{})
+	router := cache.NewKeyRouter(nil, slots.load, &cache.KeyRouterConfig{
+		RefreshInterval:    time.Hour,
+		MinRefreshInterval: time.Second,
+		StormThreshold:     3,
+		StormWindow:        time.Second,
+	}, nil)
+	defer router.Close()
+	router.SetClock(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go router.Run(ctx)
+	clock.BlockUntil(1)
+	require.Equal(t, int32(1), slots.loads.Load())
+
+	assert.False(t, router.ObserveRedirect(errors.New("ERR wrong number of arguments")))
+	assert.False(t, router.ObserveRedirect(nil))
+	assert.True(t, router.ObserveRedirect(errors.New("MOVED 10778 10.0.0.2:6379")))
+	assert.Equal(t, "10.0.0.2:6379", router.NodeFor("user:1"))
+	assert.True(t, router.ObserveRedirect(errors.New("ASK 2648 10.0.0.2:6379")))
+	assert.Equal(t, "10.0.0.1:6379", router.NodeFor("user:3"), "ASK does not update the mapping")
+
+	// 距上次刷新已超过 MinRefreshInterval，风暴立即触发刷新
+	clock.Advance(time.Second)
+	for i := 0; i < 3; i++ {
+		router.ObserveRedirect(errors.New("MOVED 10778 10.0.0.2:6379"))
+	}
+	require.Eventually(t, func() bool { return router.Stats().LastRefresh.Equal(clock.Now()) }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), slots.loads.Load())
+	assert.Equal(t, "10.0.0.1:6379", router.NodeFor("user:1"), "refresh replaces redirect-learned slots")
+
+	router.ObserveRedirect(errors.New("MOVED 10778 10.0.0.2:6379"))
+	stats := router.Stats()
+	assert.Equal(t, int64(5), stats.Moved)
+	assert.Equal(t, int64(1), stats.Ask)
+	assert.Equal(t, int64(1), stats.Storms, "a storm is counted once per window")
+	assert.Equal(t, 16384, stats.CoveredSlots)
+	assert.Equal(t, []string{"10.0.0.1:6379", "10.0.0.2:6379"}, stats.Nodes)
+}
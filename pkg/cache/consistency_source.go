@@ -0,0 +1,299 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// SourceCheckJobType 定时比对缓存与数据源的任务类型，pkg/jobs 依赖本包，由 main 注册到任务队列
+const SourceCheckJobType = "cache_consistency_source"
+
+// SourceCheckConfig 缓存与数据源比对的配置
+type SourceCheckConfig struct {
+	Schedule   string `json:"schedule"`    // 定时比对的 cron 表达式
+	SampleSize int    `json:"sample_size"` // 每个命名空间每次最多比对的热门键数
+	BatchSize  int    `json:"batch_size"`  // 每次调用加载器的 ID 数
+	Repair     bool   `json:"repair"`      // 定时比对发现不一致时以数据源为准修复缓存
+}
+
+// DefaultSourceCheckConfig 默认每 15 分钟比对一次，只报告不修复
+func DefaultSourceCheckConfig() *SourceCheckConfig {
+	return &SourceCheckConfig{
+		Schedule:   "*/15 * * * *",
+		SampleSize: 100,
+		BatchSize:  100,
+	}
+}
+
+// RegisterSource 登记键命名空间的权威数据源，模板中必须有且只有一个 {id}，如 "user:{id}"。
+// load 返回的值须与写入缓存的值结构相同，比对时两者都按 JSON 规范化后计算哈希；
+// 修复时以 ttl 写入，ttl 为 0 时使用一小时。同一模板重复登记时替换
+func (ccc *CacheConsistencyChecker) RegisterSource(template string, load BatchLoaderFunc, ttl time.Duration) error {
+	prefix, suffix, ok := strings.Cut(template, "{id}")
+	if !ok || strings.Contains(suffix, "{id}") {
+		return fmt.Errorf("invalid consistency source template %q: expected exactly one {id}", template)
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	ccc.mu.Lock()
+	defer ccc.mu.Unlock()
+	for i, existing := range ccc.sources {
+		if existing.template == template {
+			ccc.sources = append(ccc.sources[:i], ccc.sources[i+1:]...)
+			break
+		}
+	}
+	ccc.sources = append(ccc.sources, &templateLoader{template: template, prefix: prefix, suffix: suffix, load: load, ttl: ttl})
+	return nil
+}
+
+// SetPopularity 关联热门键统计，CheckSources 从中取各命名空间的热门键比对
+func (ccc *CacheConsistencyChecker) SetPopularity(tracker *PopularityTracker) {
+	ccc.mu.Lock()
+	defer ccc.mu.Unlock()
+	ccc.popularity = tracker
+}
+
+// SetSourceCheckConfig 替换比对配置，config 为 nil 时恢复默认配置
+func (ccc *CacheConsistencyChecker) SetSourceCheckConfig(config *SourceCheckConfig) {
+	if config == nil {
+		config = DefaultSourceCheckConfig()
+	}
+	ccc.mu.Lock()
+	defer ccc.mu.Unlock()
+	ccc.sourceConfig = config
+}
+
+// SourceCheckConfig 当前的比对配置
+func (ccc *CacheConsistencyChecker) SourceCheckConfig() SourceCheckConfig {
+	ccc.mu.RLock()
+	defer ccc.mu.RUnlock()
+	return *ccc.sourceConfig
+}
+
+// matchSource 键对应的数据源与 ID，多个模板匹配时前缀最长的优先
+func (ccc *CacheConsistencyChecker) matchSource(key string) (*templateLoader, string) {
+	ccc.mu.RLock()
+	defer ccc.mu.RUnlock()
+	var (
+		matched *templateLoader
+		id      string
+	)
+	for _, source := range ccc.sources {
+		if candidate, ok := source.id(key); ok && (matched == nil || len(source.prefix) > len(matched.prefix)) {
+			matched, id = source, candidate
+		}
+	}
+	return matched, id
+}
+
+// CheckSources 从热门键中为每个登记的数据源取最多 SampleSize 个键，与数据源比对，
+// 定时任务调用；配置开启 Repair 时修复不一致的键
+func (ccc *CacheConsistencyChecker) CheckSources(ctx context.Context) (*ConsistencyReport, error) {
+	ccc.mu.RLock()
+	tracker, config := ccc.popularity, *ccc.sourceConfig
+	ccc.mu.RUnlock()
+	if tracker == nil {
+		return nil, errors.New("popularity tracking is not configured")
+	}
+	namespaces, err := tracker.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sampled := make(map[*templateLoader]int)
+	var keys []string
+	for _, namespace := range namespaces {
+		hot, err := tracker.Hot(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range hot {
+			source, _ := ccc.matchSource(p.Key)
+			if source == nil || (config.SampleSize > 0 && sampled[source] >= config.SampleSize) {
+				continue
+			}
+			sampled[source]++
+			keys = append(keys, p.Key)
+		}
+	}
+
+	report, err := ccc.CheckAgainstSource(ctx, keys, config.Repair)
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Issues) > 0 {
+		log.Printf("Cache consistency check found %d issues in %d keys, repaired %d", len(report.Issues), report.CheckedKeys, report.Repaired)
+	}
+	return report, nil
+}
+
+// CheckAgainstSource 以登记的数据源比对 keys 的缓存值。没有数据源匹配或不在缓存中的键跳过，
+// CheckedKeys 为实际比对的键数。值不同记为 divergent，数据源中已不存在记为 stale；
+// repair 时前者以数据源的值重写，后者删除，修复前重新读取缓存，期间已被改写的键不修复
+func (ccc *CacheConsistencyChecker) CheckAgainstSource(ctx context.Context, keys []string, repair bool) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{
+		Timestamp: time.Now(),
+		Issues:    make([]ConsistencyIssue, 0),
+	}
+	if len(keys) == 0 {
+		report.ConsistencyScore = ccc.calculateConsistencyScore(report)
+		return report, nil
+	}
+
+	cached, err := ccc.cache.GetMany(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached values: %w", err)
+	}
+
+	type group struct {
+		source *templateLoader
+		keys   []string
+		ids    []string
+	}
+	var groups []*group
+	bySource := make(map[*templateLoader]*group)
+	for _, key := range keys {
+		if _, hit := cached.Values[key]; !hit {
+			continue
+		}
+		source, id := ccc.matchSource(key)
+		if source == nil {
+			continue
+		}
+		g, ok := bySource[source]
+		if !ok {
+			g = &group{source: source}
+			bySource[source] = g
+			groups = append(groups, g)
+		}
+		g.keys = append(g.keys, key)
+		g.ids = append(g.ids, id)
+	}
+
+	batchSize := ccc.SourceCheckConfig().BatchSize
+	for _, g := range groups {
+		size := batchSize
+		if size <= 0 {
+			size = len(g.ids)
+		}
+		for start := 0; start < len(g.ids); start += size {
+			end := min(start+size, len(g.ids))
+			report.CheckedKeys += end - start
+
+			values, err := g.source.load(ctx, g.ids[start:end])
+			if err != nil {
+				for _, key := range g.keys[start:end] {
+					report.Issues = append(report.Issues, ConsistencyIssue{
+						Key:         key,
+						Type:        "source_error",
+						Description: fmt.Sprintf("Failed to load from source %s: %v", g.source.template, err),
+						Severity:    "error",
+						Timestamp:   time.Now(),
+					})
+				}
+				continue
+			}
+			for i, key := range g.keys[start:end] {
+				if issue := ccc.compareWithSource(ctx, key, cached.Values[key], values, g.ids[start+i], g.source.ttl, repair); issue != nil {
+					if issue.Repaired {
+						report.Repaired++
+					}
+					report.Issues = append(report.Issues, *issue)
+				}
+			}
+		}
+	}
+
+	report.ConsistencyScore = ccc.calculateConsistencyScore(report)
+	ccc.recordSourceCheck(report)
+	return report, nil
+}
+
+// compareWithSource 比对单个键，一致时返回 nil
+func (ccc *CacheConsistencyChecker) compareWithSource(ctx context.Context, key string, raw json.RawMessage, values map[string]interface{}, id string, ttl time.Duration, repair bool) *ConsistencyIssue {
+	cachedHash, err := hashJSON(raw)
+	if err != nil {
+		return &ConsistencyIssue{Key: key, Type: "decode_error", Description: fmt.Sprintf("Cached value is not valid JSON: %v", err), Severity: "error", Timestamp: time.Now()}
+	}
+
+	value, found := values[id]
+	if !found {
+		issue := &ConsistencyIssue{Key: key, Type: "stale", Description: "Cached key no longer exists in source", Severity: "error", Timestamp: time.Now()}
+		if repair && ccc.unchanged(ctx, key, cachedHash) {
+			issue.Repaired = ccc.cache.Delete(ctx, key) == nil
+		}
+		return issue
+	}
+
+	encoded, err := json.Marshal(value)
+	var sourceHash [sha256.Size]byte
+	if err == nil {
+		sourceHash, err = hashJSON(encoded)
+	}
+	if err != nil {
+		return &ConsistencyIssue{Key: key, Type: "source_error", Description: fmt.Sprintf("Failed to encode source value: %v", err), Severity: "error", Timestamp: time.Now()}
+	}
+	if sourceHash == cachedHash {
+		return nil
+	}
+
+	issue := &ConsistencyIssue{
+		Key:         key,
+		Type:        "divergent",
+		Description: fmt.Sprintf("Cached value hash %x differs from source hash %x", cachedHash[:6], sourceHash[:6]),
+		Severity:    "error",
+		Timestamp:   time.Now(),
+	}
+	if repair && ccc.unchanged(ctx, key, cachedHash) {
+		issue.Repaired = ccc.cache.Set(ctx, key, json.RawMessage(encoded), ttl) == nil
+	}
+	return issue
+}
+
+// unchanged 重新读取缓存，值仍与比对时相同才修复，避免以较早读取的数据源覆盖并发写入的新值
+func (ccc *CacheConsistencyChecker) unchanged(ctx context.Context, key string, hash [sha256.Size]byte) bool {
+	var current json.RawMessage
+	if err := ccc.cache.Get(ctx, key, &current); err != nil {
+		return false
+	}
+	currentHash, err := hashJSON(current)
+	return err == nil && currentHash == hash
+}
+
+// recordSourceCheck 按问题类型与修复数记录组件事件
+func (ccc *CacheConsistencyChecker) recordSourceCheck(report *ConsistencyReport) {
+	if ccc.metricsCollector == nil {
+		return
+	}
+	counts := make(map[string]int)
+	for _, issue := range report.Issues {
+		counts[issue.Type]++
+	}
+	ccc.metricsCollector.RecordEvent("cache_consistency", "checked", report.CheckedKeys)
+	for issueType, n := range counts {
+		ccc.metricsCollector.RecordEvent("cache_consistency", issueType, n)
+	}
+	ccc.metricsCollector.RecordEvent("cache_consistency", "repaired", report.Repaired)
+}
+
+// hashJSON 规范化 JSON 后的哈希：解码后重新编码，对象的键有序、数字格式统一，与写入时的字段顺序和空白无关
+func hashJSON(data []byte) ([sha256.Size]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(canonical), nil
+}
@@ -0,0 +1,115 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sourceUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// TestCacheConsistencyChecker_CheckAgainstSource 字段顺序不同但值相同的缓存视为一致，值不同记为 divergent，
+// 数据源中已删除记为 stale；repair 时分别以数据源的值重写与删除，再次比对不再有问题
+func TestCacheConsistencyChecker_CheckAgainstSource(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache()
+	checker := cache.NewCacheConsistencyChecker(c, metrics.GetGlobalCollector(), &cache.ConsistencyConfig{})
+	db := map[string]sourceUser{"1": {"1", "alice"}, "2": {"2", "bob"}}
+	require.NoError(t, checker.RegisterSource("user:{id}", func(_ context.Context, ids []string) (map[string]interface{}, error) {
+		values := make(map[string]interface{})
+		for _, id := range ids {
+			if user, ok := db[id]; ok {
+				values[id] = user
+			}
+		}
+		return values, nil
+	}, time.Minute))
+	assert.Error(t, checker.RegisterSource("user", nil, 0))
+
+	require.NoError(t, c.Set(ctx, "user:1", json.RawMessage(`{"name": "alice", "id": "1"}`), time.Minute))
+	require.NoError(t, c.Set(ctx, "user:2", sourceUser{"2", "bobby"}, time.Minute))
+	require.NoError(t, c.Set(ctx, "user:3", sourceUser{"3", "carol"}, time.Minute))
+	require.NoError(t, c.Set(ctx, "order:1", "no source", time.Minute))
+	keys := []string{"user:1", "user:2", "user:3", "user:4", "order:1"}
+
+	report, err := checker.CheckAgainstSource(ctx, keys, false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.CheckedKeys)
+	require.Len(t, report.Issues, 2)
+	assert.Equal(t, "user:2", report.Issues[0].Key)
+	assert.Equal(t, "divergent", report.Issues[0].Type)
+	assert.Equal(t, "user:3", report.Issues[1].Key)
+	assert.Equal(t, "stale", report.Issues[1].Type)
+	assert.Zero(t, report.Repaired)
+
+	report, err = checker.CheckAgainstSource(ctx, keys, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Repaired)
+	var user sourceUser
+	require.NoError(t, c.Get(ctx, "user:2", &user))
+	assert.Equal(t, "bob", user.Name)
+	exists, err := c.Exists(ctx, "user:3")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	report, err = checker.CheckAgainstSource(ctx, keys, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.CheckedKeys)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 100.0, report.ConsistencyScore)
+}
+
+// TestCacheConsistencyChecker_CheckSources 定时比对从热门键中按数据源取样，按配置修复；加载失败的键记为 source_error
+func TestCacheConsistencyChecker_CheckSources(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache()
+	checker := cache.NewCacheConsistencyChecker(c, metrics.GetGlobalCollector(), &cache.ConsistencyConfig{})
+	_, err := checker.CheckSources(ctx)
+	assert.Error(t, err)
+
+	tracker := cache.NewPopularityTracker(c, &cache.PopularityConfig{SampleRate: 1, TopN: 10, MaxTracked: 100, Decay: 0.5, TTL: time.Hour})
+	checker.SetPopularity(tracker)
+	checker.SetSourceCheckConfig(&cache.SourceCheckConfig{SampleSize: 2, BatchSize: 1, Repair: true})
+	require.NoError(t, checker.RegisterSource("coupon:{id}", func(_ context.Context, ids []string) (map[string]interface{}, error) {
+		values := make(map[string]interface{})
+		for _, id := range ids {
+			values[id] = "fresh:" + id
+		}
+		return values, nil
+	}, time.Minute))
+	require.NoError(t, checker.RegisterSource("moment:{id}", func(context.Context, []string) (map[string]interface{}, error) {
+		return nil, errors.New("db down")
+	}, time.Minute))
+
+	for _, key := range []string{"coupon:1", "coupon:2", "coupon:3", "moment:1"} {
+		require.NoError(t, c.Set(ctx, key, "stale", time.Minute))
+	}
+	tracker.Record("coupon:1", "coupon:1", "coupon:1", "coupon:2", "coupon:2", "coupon:3", "moment:1")
+	require.NoError(t, tracker.Persist(ctx))
+
+	report, err := checker.CheckSources(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.CheckedKeys)
+	assert.Equal(t, 2, report.Repaired)
+	types := make(map[string]string)
+	for _, issue := range report.Issues {
+		types[issue.Key] = issue.Type
+	}
+	assert.Equal(t, map[string]string{"coupon:1": "divergent", "coupon:2": "divergent", "moment:1": "source_error"}, types)
+
+	var value string
+	require.NoError(t, c.Get(ctx, "coupon:1", &value))
+	assert.Equal(t, "fresh:1", value)
+	require.NoError(t, c.Get(ctx, "coupon:3", &value))
+	assert.Equal(t, "stale", value)
+}
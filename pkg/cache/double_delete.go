@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// DefaultDoubleDeleteDelay 延迟双删的默认间隔，应长于一次读库并回填缓存的耗时
+const DefaultDoubleDeleteDelay = time.Second
+
+// DoubleDeleteStats 延迟双删的累计统计，SecondDeleteHits 为第二次删除时键已被重新写入的次数，
+// 即并发读取在数据库写入完成前回填了旧数据
+type DoubleDeleteStats struct {
+	FirstDeletes     int64 `json:"first_deletes"`
+	SecondDeletes    int64 `json:"second_deletes"`
+	SecondDeleteHits int64 `json:"second_delete_hits"`
+	Errors           int64 `json:"errors"`
+	Pending          int   `json:"pending"`
+}
+
+// DoubleDeleteInvalidationStrategy 延迟双删策略：立即删除缓存，数据库写入后再延迟删除一次，
+// 清除写入期间并发读取以旧数据回填的缓存。同一个键再次失效时重新计时
+type DoubleDeleteInvalidationStrategy struct {
+	cache            CacheService
+	delay            time.Duration
+	clock            clock.Clock
+	metricsCollector *metrics.MetricsCollector
+
+	mu     sync.Mutex
+	timers map[string]clock.Timer
+	stats  DoubleDeleteStats
+}
+
+// NewDoubleDeleteInvalidationStrategy 创建延迟双删策略，delay 不大于 0 时使用 DefaultDoubleDeleteDelay
+func NewDoubleDeleteInvalidationStrategy(cache CacheService, delay time.Duration, c clock.Clock, metricsCollector *metrics.MetricsCollector) *DoubleDeleteInvalidationStrategy {
+	if delay <= 0 {
+		delay = DefaultDoubleDeleteDelay
+	}
+	return &DoubleDeleteInvalidationStrategy{
+		cache:            cache,
+		delay:            delay,
+		clock:            clock.OrReal(c),
+		metricsCollector: metricsCollector,
+		timers:           make(map[string]clock.Timer),
+	}
+}
+
+// Invalidate 立即删除 keys 并安排第二次删除，用于数据库已写入后的失效
+func (dds *DoubleDeleteInvalidationStrategy) Invalidate(ctx context.Context, keys []string) error {
+	if err := dds.deleteNow(ctx, keys); err != nil {
+		return err
+	}
+	dds.scheduleSecondDelete(ctx, keys)
+	return nil
+}
+
+// Write 完整的延迟双删：删除缓存，执行 write 写入数据库，成功后安排第二次删除；
+// 首次删除失败时不执行 write，write 失败时数据未变，不再安排第二次删除
+func (dds *DoubleDeleteInvalidationStrategy) Write(ctx context.Context, keys []string, write func(ctx context.Context) error) error {
+	if err := dds.deleteNow(ctx, keys); err != nil {
+		return err
+	}
+	if err := write(ctx); err != nil {
+		return err
+	}
+	dds.scheduleSecondDelete(ctx, keys)
+	return nil
+}
+
+func (dds *DoubleDeleteInvalidationStrategy) deleteNow(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := dds.cache.Delete(ctx, key); err != nil {
+			dds.count(func(s *DoubleDeleteStats) { s.Errors++ })
+			return fmt.Errorf("failed to delete key %s: %w", key, err)
+		}
+	}
+	dds.count(func(s *DoubleDeleteStats) { s.FirstDeletes += int64(len(keys)) })
+	dds.recordEvent("first_delete", len(keys))
+	return nil
+}
+
+// scheduleSecondDelete 为每个键安排第二次删除，已安排的键重新计时，触发时只移除自己的定时器
+func (dds *DoubleDeleteInvalidationStrategy) scheduleSecondDelete(ctx context.Context, keys []string) {
+	dds.mu.Lock()
+	defer dds.mu.Unlock()
+
+	for _, key := range keys {
+		if timer, exists := dds.timers[key]; exists {
+			timer.Stop()
+		}
+
+		var timer clock.Timer
+		timer = dds.clock.AfterFunc(dds.delay, func() {
+			deleteCtx, cancel := ctxutil.Detach(ctx, time.Second*5)
+			defer cancel()
+			dds.secondDelete(deleteCtx, key)
+			dds.mu.Lock()
+			if dds.timers[key] == timer {
+				delete(dds.timers, key)
+			}
+			dds.mu.Unlock()
+		})
+		dds.timers[key] = timer
+	}
+}
+
+// secondDelete 删除前检查键是否存在，存在说明写入期间被重新回填，计为一次命中
+func (dds *DoubleDeleteInvalidationStrategy) secondDelete(ctx context.Context, key string) {
+	exists, err := dds.cache.Exists(ctx, key)
+	if err == nil && exists {
+		err = dds.cache.Delete(ctx, key)
+	}
+	if err != nil {
+		dds.count(func(s *DoubleDeleteStats) { s.Errors++ })
+		return
+	}
+
+	dds.count(func(s *DoubleDeleteStats) {
+		s.SecondDeletes++
+		if exists {
+			s.SecondDeleteHits++
+		}
+	})
+	dds.recordEvent("second_delete", 1)
+	if exists {
+		dds.recordEvent("second_delete_hit", 1)
+	}
+}
+
+// Stats 累计统计与尚未执行的第二次删除数
+func (dds *DoubleDeleteInvalidationStrategy) Stats() DoubleDeleteStats {
+	dds.mu.Lock()
+	defer dds.mu.Unlock()
+	stats := dds.stats
+	stats.Pending = len(dds.timers)
+	return stats
+}
+
+func (dds *DoubleDeleteInvalidationStrategy) count(update func(*DoubleDeleteStats)) {
+	dds.mu.Lock()
+	update(&dds.stats)
+	dds.mu.Unlock()
+}
+
+func (dds *DoubleDeleteInvalidationStrategy) recordEvent(event string, n int) {
+	if dds.metricsCollector != nil {
+		dds.metricsCollector.RecordEvent("cache_double_delete", event, n)
+	}
+}
+
+func (dds *DoubleDeleteInvalidationStrategy) GetName() string {
+	return "double_delete"
+}
+
+func (dds *DoubleDeleteInvalidationStrategy) GetPriority() int {
+	return 85
+}
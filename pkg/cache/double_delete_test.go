@@ -0,0 +1,56 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDoubleDelete_RemovesStaleRefill 写入期间并发读取回填的旧数据在延迟后被第二次删除并计为命中；
+// 写入失败时不安排第二次删除
+func TestDoubleDelete_RemovesStaleRefill(t *testing.T) {
+	clock := fakes.NewClock(time.Time{})
+	store := fakes.NewCache(clock)
+	manager := cache.NewCacheConsistencyManager(store, nil, &cache.ConsistencyConfig{Clock: clock, DoubleDeleteDelay: 2 * time.Second})
+	defer manager.Close()
+	strategy := manager.GetStrategies()["double_delete"].(*cache.DoubleDeleteInvalidationStrategy)
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "user:1", "old", 0))
+	require.NoError(t, store.Set(ctx, "user:2", "old", 0))
+	require.NoError(t, manager.WriteWithDoubleDelete(ctx, []string{"user:1", "user:2"}, func(ctx context.Context) error {
+		assert.Empty(t, store.Keys(), "cache deleted before the write")
+		// 并发读取在写入提交前读到旧数据并回填
+		return store.Set(ctx, "user:1", "old", 0)
+	}))
+	assert.Equal(t, 2, strategy.Stats().Pending)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, []string{"user:1"}, store.Keys())
+	clock.Advance(time.Second)
+	assert.Empty(t, store.Keys())
+	assert.Equal(t, cache.DoubleDeleteStats{FirstDeletes: 2, SecondDeletes: 2, SecondDeleteHits: 1}, strategy.Stats())
+
+	failed := errors.New("write failed")
+	require.NoError(t, store.Set(ctx, "user:3", "old", 0))
+	assert.ErrorIs(t, manager.WriteWithDoubleDelete(ctx, []string{"user:3"}, func(context.Context) error { return failed }), failed)
+	assert.Zero(t, strategy.Stats().Pending)
+
+	// 直接失效同样安排第二次删除，再次失效重新计时
+	require.NoError(t, manager.Invalidate(ctx, "double_delete", []string{"user:3"}))
+	clock.Advance(time.Second)
+	require.NoError(t, manager.Invalidate(ctx, "double_delete", []string{"user:3"}))
+	require.NoError(t, store.Set(ctx, "user:3", "old", 0))
+	clock.Advance(time.Second)
+	assert.Equal(t, []string{"user:3"}, store.Keys())
+	clock.Advance(time.Second)
+	assert.Empty(t, store.Keys())
+	assert.Equal(t, int64(2), strategy.Stats().SecondDeleteHits)
+	assert.Equal(t, 0, clock.Waiters())
+}
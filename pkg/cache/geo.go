@@ -0,0 +1,276 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrInvalidLocation 坐标超出 Redis GEO 支持的范围（纬度 ±85.05112878，经度 ±180）
+	ErrInvalidLocation = errors.New("invalid location")
+	// ErrInvalidRadius 查询半径不大于 0 或超过配置的上限
+	ErrInvalidRadius = errors.New("invalid search radius")
+)
+
+const (
+	// geoMaxLatitude Redis GEO 以 Web Mercator 编码，纬度超出该范围无法写入
+	geoMaxLatitude = 85.05112878
+	// earthRadiusMeters 与 Redis geohashGetDistance 使用的地球半径相同，内存实现的距离与 Redis 一致
+	earthRadiusMeters = 6372797.560856
+)
+
+// GeoLocation 成员及其坐标
+type GeoLocation struct {
+	Member    string  `json:"member"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GeoQuery 以坐标为圆心、RadiusMeters 为半径的查询，Count 大于 0 时只返回最近的 Count 个成员
+type GeoQuery struct {
+	Latitude     float64
+	Longitude    float64
+	RadiusMeters float64
+	Count        int
+}
+
+// GeoResult 查询结果，按距离升序
+type GeoResult struct {
+	GeoLocation
+	DistanceMeters float64 `json:"distance_meters"`
+}
+
+// GeoStore 位置索引的存储：RedisCache、RedisCluster 以 GEOADD、GEOSEARCH 实现，MemoryGeoStore 用于没有 Redis 的环境与测试
+type GeoStore interface {
+	GeoAdd(ctx context.Context, key string, locations ...GeoLocation) error
+	GeoSearch(ctx context.Context, key string, query GeoQuery) ([]GeoResult, error)
+	GeoRemove(ctx context.Context, key string, members ...string) error
+}
+
+// NewGeoStore client 为 nil 时返回内存实现
+func NewGeoStore(client *redis.Client) GeoStore {
+	if client == nil {
+		return NewMemoryGeoStore()
+	}
+	return NewRedisCache(client).(*RedisCache)
+}
+
+// GeoConfig 位置服务配置
+type GeoConfig struct {
+	KeyPrefix       string  `json:"key_prefix"`        // 各图层的键前缀，后接图层名
+	MaxRadiusMeters float64 `json:"max_radius_meters"` // 查询半径上限
+	DefaultLimit    int     `json:"default_limit"`     // 未指定数量时返回的成员数
+	MaxLimit        int     `json:"max_limit"`         // 返回成员数的上限
+}
+
+// DefaultGeoConfig 默认配置
+func DefaultGeoConfig() *GeoConfig {
+	return &GeoConfig{
+		KeyPrefix:       "geo:",
+		MaxRadiusMeters: 50000,
+		DefaultLimit:    20,
+		MaxLimit:        200,
+	}
+}
+
+// GeoService 按图层（如 coupons、moments）保存成员的坐标并查询附近的成员，图层按 ctx 中的租户隔离
+type GeoService struct {
+	store  GeoStore
+	config *GeoConfig
+}
+
+// NewGeoService 创建位置服务，config 为 nil 时使用默认配置
+func NewGeoService(store GeoStore, config *GeoConfig) *GeoService {
+	if config == nil {
+		config = DefaultGeoConfig()
+	}
+	return &GeoService{store: store, config: config}
+}
+
+// AddLocation 写入或更新成员的坐标
+func (s *GeoService) AddLocation(ctx context.Context, layer, member string, lat, lng float64) error {
+	if err := validateLocation(lat, lng); err != nil {
+		return err
+	}
+	return s.store.GeoAdd(ctx, s.key(ctx, layer), GeoLocation{Member: member, Latitude: lat, Longitude: lng})
+}
+
+// RemoveLocation 删除成员的坐标，成员不存在时忽略
+func (s *GeoService) RemoveLocation(ctx context.Context, layer string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return s.store.GeoRemove(ctx, s.key(ctx, layer), members...)
+}
+
+// Nearby 距 (lat, lng) radius 米以内的成员，由近到远，limit 不大于 0 时使用默认数量，超过上限时截断
+func (s *GeoService) Nearby(ctx context.Context, layer string, lat, lng, radius float64, limit int) ([]GeoResult, error) {
+	if err := validateLocation(lat, lng); err != nil {
+		return nil, err
+	}
+	if radius <= 0 || radius > s.config.MaxRadiusMeters {
+		return nil, fmt.Errorf("%w: %.0f meters, must be in (0, %.0f]", ErrInvalidRadius, radius, s.config.MaxRadiusMeters)
+	}
+	if limit <= 0 {
+		limit = s.config.DefaultLimit
+	}
+	if limit > s.config.MaxLimit {
+		limit = s.config.MaxLimit
+	}
+	return s.store.GeoSearch(ctx, s.key(ctx, layer), GeoQuery{Latitude: lat, Longitude: lng, RadiusMeters: radius, Count: limit})
+}
+
+func (s *GeoService) key(ctx context.Context, layer string) string {
+	return TenantKey(ctx, s.config.KeyPrefix+layer)
+}
+
+func validateLocation(lat, lng float64) error {
+	if math.IsNaN(lat) || math.IsNaN(lng) || math.Abs(lat) > geoMaxLatitude || math.Abs(lng) > 180 {
+		return fmt.Errorf("%w: latitude %v, longitude %v", ErrInvalidLocation, lat, lng)
+	}
+	return nil
+}
+
+// GeoAdd 实现 GeoStore
+func (c *RedisCache) GeoAdd(ctx context.Context, key string, locations ...GeoLocation) error {
+	if len(locations) == 0 {
+		return nil
+	}
+	geo := make([]*redis.GeoLocation, len(locations))
+	for i, location := range locations {
+		geo[i] = &redis.GeoLocation{Name: location.Member, Latitude: location.Latitude, Longitude: location.Longitude}
+	}
+	if err := c.client.GeoAdd(ctx, c.getKey(key), geo...).Err(); err != nil {
+		return fmt.Errorf("cache geoadd error: %w", err)
+	}
+	return nil
+}
+
+// GeoSearch 实现 GeoStore，GEOSEARCH ... FROMLONLAT BYRADIUS ASC WITHCOORD WITHDIST
+func (c *RedisCache) GeoSearch(ctx context.Context, key string, query GeoQuery) ([]GeoResult, error) {
+	locations, err := c.client.GeoSearchLocation(ctx, c.getKey(key), &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Longitude:  query.Longitude,
+			Latitude:   query.Latitude,
+			Radius:     query.RadiusMeters,
+			RadiusUnit: "m",
+			Sort:       "ASC",
+			Count:      query.Count,
+		},
+		WithCoord: true,
+		WithDist:  true,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("cache geosearch error: %w", err)
+	}
+
+	results := make([]GeoResult, len(locations))
+	for i, location := range locations {
+		results[i] = GeoResult{
+			GeoLocation:    GeoLocation{Member: location.Name, Latitude: location.Latitude, Longitude: location.Longitude},
+			DistanceMeters: location.Dist,
+		}
+	}
+	return results, nil
+}
+
+// GeoRemove 实现 GeoStore，GEO 数据保存在有序集合中，以 ZREM 删除
+func (c *RedisCache) GeoRemove(ctx context.Context, key string, members ...string) error {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	if err := c.client.ZRem(ctx, c.getKey(key), args...).Err(); err != nil {
+		return fmt.Errorf("cache georemove error: %w", err)
+	}
+	return nil
+}
+
+// MemoryGeoStore 内存中的 GeoStore，查询逐个计算球面距离，适合单实例开发环境与测试
+type MemoryGeoStore struct {
+	mu   sync.RWMutex
+	sets map[string]map[string]GeoLocation
+}
+
+// NewMemoryGeoStore 创建内存位置索引
+func NewMemoryGeoStore() *MemoryGeoStore {
+	return &MemoryGeoStore{sets: make(map[string]map[string]GeoLocation)}
+}
+
+// GeoAdd 实现 GeoStore
+func (s *MemoryGeoStore) GeoAdd(_ context.Context, key string, locations ...GeoLocation) error {
+	for _, location := range locations {
+		if err := validateLocation(location.Latitude, location.Longitude); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.sets[key]
+	if !ok {
+		set = make(map[string]GeoLocation)
+		s.sets[key] = set
+	}
+	for _, location := range locations {
+		set[location.Member] = location
+	}
+	return nil
+}
+
+// GeoSearch 实现 GeoStore，距离相同时按成员排序，结果稳定
+func (s *MemoryGeoStore) GeoSearch(_ context.Context, key string, query GeoQuery) ([]GeoResult, error) {
+	s.mu.RLock()
+	results := make([]GeoResult, 0)
+	for _, location := range s.sets[key] {
+		distance := GeoDistance(query.Latitude, query.Longitude, location.Latitude, location.Longitude)
+		if distance <= query.RadiusMeters {
+			results = append(results, GeoResult{GeoLocation: location, DistanceMeters: distance})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].DistanceMeters != results[j].DistanceMeters {
+			return results[i].DistanceMeters < results[j].DistanceMeters
+		}
+		return results[i].Member < results[j].Member
+	})
+	if query.Count > 0 && len(results) > query.Count {
+		results = results[:query.Count]
+	}
+	return results, nil
+}
+
+// GeoRemove 实现 GeoStore
+func (s *MemoryGeoStore) GeoRemove(_ context.Context, key string, members ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.sets[key]
+	for _, member := range members {
+		delete(set, member)
+	}
+	if len(set) == 0 {
+		delete(s.sets, key)
+	}
+	return nil
+}
+
+// GeoDistance 两点间的球面距离（米），与 Redis GEODIST 使用相同的 haversine 公式与地球半径
+func GeoDistance(lat1, lng1, lat2, lng2 float64) float64 {
+	lat1r, lat2r := lat1*math.Pi/180, lat2*math.Pi/180
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lng2 - lng1) * math.Pi / 180 / 2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
+
+var (
+	_ GeoStore = (*RedisCache)(nil)
+	_ GeoStore = (*RedisCluster)(nil)
+	_ GeoStore = (*MemoryGeoStore)(nil)
+)
@@ -0,0 +1,53 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/ctxutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGeoService_Nearby 由近到远返回半径内的成员，按数量截断，图层按租户隔离，坐标与半径超出范围时报错
+func TestGeoService_Nearby(t *testing.T) {
+	ctx := context.Background()
+	geo := cache.NewGeoService(cache.NewGeoStore(nil), nil)
+	// 人民广场、陆家嘴、虹桥
+	require.NoError(t, geo.AddLocation(ctx, "coupons", "coupon:1", 31.2304, 121.4737))
+	require.NoError(t, geo.AddLocation(ctx, "coupons", "coupon:2", 31.2397, 121.4998))
+	require.NoError(t, geo.AddLocation(ctx, "coupons", "coupon:3", 31.1979, 121.3364))
+
+	nearby, err := geo.Nearby(ctx, "coupons", 31.2304, 121.4737, 5000, 0)
+	require.NoError(t, err)
+	require.Len(t, nearby, 2)
+	assert.Equal(t, "coupon:1", nearby[0].Member)
+	assert.InDelta(t, 0, nearby[0].DistanceMeters, 0.01)
+	assert.Equal(t, "coupon:2", nearby[1].Member)
+	assert.InDelta(t, 2650, nearby[1].DistanceMeters, 100)
+
+	nearest, err := geo.Nearby(ctx, "coupons", 31.2304, 121.4737, 20000, 1)
+	require.NoError(t, err)
+	assert.Len(t, nearest, 1)
+	all, err := geo.Nearby(ctx, "coupons", 31.2304, 121.4737, 20000, 0)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	tenant := ctxutil.WithTenantID(ctx, "acme")
+	other, err := geo.Nearby(tenant, "coupons", 31.2304, 121.4737, 20000, 0)
+	require.NoError(t, err)
+	assert.Empty(t, other)
+
+	require.NoError(t, geo.RemoveLocation(ctx, "coupons", "coupon:1"))
+	nearby, err = geo.Nearby(ctx, "coupons", 31.2304, 121.4737, 5000, 0)
+	require.NoError(t, err)
+	require.Len(t, nearby, 1)
+	assert.Equal(t, "coupon:2", nearby[0].Member)
+
+	assert.ErrorIs(t, geo.AddLocation(ctx, "coupons", "coupon:4", 89, 121), cache.ErrInvalidLocation)
+	_, err = geo.Nearby(ctx, "coupons", 31.2304, 181, 5000, 0)
+	assert.ErrorIs(t, err, cache.ErrInvalidLocation)
+	_, err = geo.Nearby(ctx, "coupons", 31.2304, 121.4737, 100000, 0)
+	assert.ErrorIs(t, err, cache.ErrInvalidRadius)
+}
@@ -0,0 +1,493 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// ForeignNamespace 不带本缓存前缀的键（其他应用或直接写入 Redis 的数据）归入的命名空间
+const ForeignNamespace = "(foreign)"
+
+// ErrNamespaceNotSampled 最近一次容量分析没有采样到该命名空间的键
+var ErrNamespaceNotSampled = errors.New("namespace has no sampled keys")
+
+// MemoryInfo Redis 实例的内存与淘汰统计（INFO memory、INFO stats 与 DBSIZE）
+type MemoryInfo struct {
+	UsedMemory     int64  `json:"used_memory"`
+	MaxMemory      int64  `json:"maxmemory"` // 0 表示未限制
+	Policy         string `json:"maxmemory_policy"`
+	Keys           int64  `json:"keys"`
+	EvictedKeys    int64  `json:"evicted_keys"`
+	ExpiredKeys    int64  `json:"expired_keys"`
+	KeyspaceHits   int64  `json:"keyspace_hits"`
+	KeyspaceMisses int64  `json:"keyspace_misses"`
+}
+
+// KeySample 采样到的键
+type KeySample struct {
+	Key     string        `json:"key"` // 不含缓存前缀
+	Type    string        `json:"type"`
+	Bytes   int64         `json:"bytes"` // MEMORY USAGE，含键名与 Redis 内部开销
+	TTL     time.Duration `json:"ttl"`   // 剩余有效期，没有过期时间时为 0
+	Foreign bool          `json:"foreign,omitempty"`
+}
+
+// MemorySampler 容量分析的数据来源，RedisCache 实现
+type MemorySampler interface {
+	MemoryInfo(ctx context.Context) (*MemoryInfo, error)
+	// SampleKeys 以 RANDOMKEY 随机取 n 个键（可能重复），返回仍存在的键的大小、类型与剩余有效期
+	SampleKeys(ctx context.Context, n int) ([]KeySample, error)
+}
+
+// MemoryAnalysisConfig 容量分析配置
+type MemoryAnalysisConfig struct {
+	SampleSize       int           `json:"sample_size"`        // 每次分析随机采样的键数
+	BatchSize        int           `json:"batch_size"`         // 每个管道采样的键数
+	BatchesPerSecond float64       `json:"batches_per_second"` // 每秒最多执行的批次数，0 表示不限制
+	TopKeys          int           `json:"top_keys"`           // 报告中列出的最大键数
+	HighUsage        float64       `json:"high_usage"`         // 已用内存达到 maxmemory 的该比例时视为内存紧张
+	Interval         time.Duration `json:"interval"`           // Run 的分析间隔
+}
+
+// DefaultMemoryAnalysisConfig 默认容量分析配置：每小时采样 2000 个键，每批 100 个，每秒最多 10 批
+func DefaultMemoryAnalysisConfig() *MemoryAnalysisConfig {
+	return &MemoryAnalysisConfig{
+		SampleSize:       2000,
+		BatchSize:        100,
+		BatchesPerSecond: 10,
+		TopKeys:          20,
+		HighUsage:        0.8,
+		Interval:         time.Hour,
+	}
+}
+
+// NamespaceMemory 命名空间的内存占用，Estimated* 按采样比例（键总数 / 采样数）推算
+type NamespaceMemory struct {
+	Namespace      string         `json:"namespace"`
+	SampledKeys    int            `json:"sampled_keys"`
+	SampledBytes   int64          `json:"sampled_bytes"`
+	AvgBytes       int64          `json:"avg_bytes"`
+	EstimatedKeys  int64          `json:"estimated_keys"`
+	EstimatedBytes int64          `json:"estimated_bytes"`
+	Share          float64        `json:"share"` // 占采样总字节数的比例
+	Types          map[string]int `json:"types"` // 各数据类型的采样键数
+	NoTTLKeys      int            `json:"no_ttl_keys"`
+	NoTTLBytes     int64          `json:"no_ttl_bytes"`
+	AvgTTL         time.Duration  `json:"avg_ttl"` // 有过期时间的键的平均剩余有效期
+}
+
+// PolicyRecommendation maxmemory-policy 建议
+type PolicyRecommendation struct {
+	Current     string   `json:"current"`
+	Recommended string   `json:"recommended"`
+	Changed     bool     `json:"changed"`
+	Reasons     []string `json:"reasons"`
+}
+
+// MemoryReport 容量分析报告
+type MemoryReport struct {
+	GeneratedAt    time.Time            `json:"generated_at"`
+	Info           MemoryInfo           `json:"info"`
+	SampledKeys    int                  `json:"sampled_keys"`
+	SampledBytes   int64                `json:"sampled_bytes"`
+	NoTTLShare     float64              `json:"no_ttl_share"` // 没有过期时间的键占采样字节数的比例
+	Namespaces     []NamespaceMemory    `json:"namespaces"`   // 按推算字节数降序
+	TopKeys        []KeySample          `json:"top_keys"`
+	Recommendation PolicyRecommendation `json:"recommendation"`
+}
+
+// Namespace 报告中的命名空间，没有采样到时返回 nil
+func (r *MemoryReport) Namespace(namespace string) *NamespaceMemory {
+	for i := range r.Namespaces {
+		if r.Namespaces[i].Namespace == namespace {
+			return &r.Namespaces[i]
+		}
+	}
+	return nil
+}
+
+// TTLImpact 调整命名空间有效期后内存占用的推算
+type TTLImpact struct {
+	Namespace           string        `json:"namespace"`
+	CurrentTTL          time.Duration `json:"current_ttl"` // 按平均剩余有效期的两倍估算
+	ProposedTTL         time.Duration `json:"proposed_ttl"`
+	CurrentBytes        int64         `json:"current_bytes"`
+	ProjectedBytes      int64         `json:"projected_bytes"`
+	DeltaBytes          int64         `json:"delta_bytes"`
+	ProjectedUsedMemory int64         `json:"projected_used_memory"`
+	ExceedsMaxMemory    bool          `json:"exceeds_maxmemory"`
+}
+
+// MemoryAnalyzer Redis 容量分析：随机采样键的 MEMORY USAGE 与类型，按命名空间汇总并推算占用，
+// 列出最大的键，推算有效期调整的影响，并给出 maxmemory-policy 建议。只保留最近一次报告
+type MemoryAnalyzer struct {
+	sampler          MemorySampler
+	config           *MemoryAnalysisConfig
+	metricsCollector *metrics.MetricsCollector
+	clock            clock.Clock
+
+	mu   sync.Mutex
+	last *MemoryReport
+}
+
+// NewMemoryAnalyzer 创建容量分析，config 为空时使用默认配置
+func NewMemoryAnalyzer(sampler MemorySampler, metricsCollector *metrics.MetricsCollector, config *MemoryAnalysisConfig) *MemoryAnalyzer {
+	if config == nil {
+		config = DefaultMemoryAnalysisConfig()
+	}
+	return &MemoryAnalyzer{
+		sampler:          sampler,
+		config:           config,
+		metricsCollector: metricsCollector,
+		clock:            clock.Real,
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (a *MemoryAnalyzer) SetClock(c clock.Clock) {
+	a.clock = clock.OrReal(c)
+}
+
+// Last 最近一次分析的报告，尚未分析时为 nil
+func (a *MemoryAnalyzer) Last() *MemoryReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.last
+}
+
+// Analyze 采样并生成报告，批次之间按 BatchesPerSecond 限速，避免采样占用 Redis
+func (a *MemoryAnalyzer) Analyze(ctx context.Context) (*MemoryReport, error) {
+	info, err := a.sampler.MemoryInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redis memory info: %w", err)
+	}
+
+	batchSize := max(a.config.BatchSize, 1)
+	var limiter *rate.Limiter
+	if a.config.BatchesPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(a.config.BatchesPerSecond), 1)
+	}
+	var samples []KeySample
+	for remaining := min(int64(a.config.SampleSize), info.Keys); remaining > 0; remaining -= int64(batchSize) {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		batch, err := a.sampler.SampleKeys(ctx, int(min(remaining, int64(batchSize))))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample keys: %w", err)
+		}
+		samples = append(samples, batch...)
+	}
+
+	report := buildMemoryReport(*info, samples, a.config.TopKeys)
+	report.GeneratedAt = a.clock.Now()
+	report.Recommendation = recommendPolicy(report, a.config.HighUsage)
+
+	estimated := make(map[string]float64, len(report.Namespaces))
+	for _, namespace := range report.Namespaces {
+		estimated[namespace.Namespace] = float64(namespace.EstimatedBytes)
+	}
+	a.metricsCollector.UpdateCacheMemory(estimated)
+
+	a.mu.Lock()
+	a.last = report
+	a.mu.Unlock()
+	return report, nil
+}
+
+// sampleNamespace 采样键所属的命名空间，没有冒号的键归入空命名空间
+func sampleNamespace(sample KeySample) string {
+	if sample.Foreign {
+		return ForeignNamespace
+	}
+	return keyNamespace(sample.Key)
+}
+
+// buildMemoryReport 按命名空间汇总采样，按键总数与采样数的比例推算各命名空间的键数与字节数
+func buildMemoryReport(info MemoryInfo, samples []KeySample, topKeys int) *MemoryReport {
+	report := &MemoryReport{Info: info, SampledKeys: len(samples), Namespaces: []NamespaceMemory{}, TopKeys: []KeySample{}}
+	if len(samples) == 0 {
+		return report
+	}
+	scale := float64(info.Keys) / float64(len(samples))
+
+	byNamespace := make(map[string]*NamespaceMemory)
+	ttlTotals := make(map[string]time.Duration)
+	var noTTLBytes int64
+	for _, sample := range samples {
+		name := sampleNamespace(sample)
+		namespace, ok := byNamespace[name]
+		if !ok {
+			namespace = &NamespaceMemory{Namespace: name, Types: make(map[string]int)}
+			byNamespace[name] = namespace
+		}
+		namespace.SampledKeys++
+		namespace.SampledBytes += sample.Bytes
+		namespace.Types[sample.Type]++
+		if sample.TTL <= 0 {
+			namespace.NoTTLKeys++
+			namespace.NoTTLBytes += sample.Bytes
+			noTTLBytes += sample.Bytes
+		} else {
+			ttlTotals[name] += sample.TTL
+		}
+		report.SampledBytes += sample.Bytes
+	}
+
+	for name, namespace := range byNamespace {
+		namespace.AvgBytes = namespace.SampledBytes / int64(namespace.SampledKeys)
+		namespace.EstimatedKeys = int64(float64(namespace.SampledKeys)*scale + 0.5)
+		namespace.EstimatedBytes = int64(float64(namespace.SampledBytes)*scale + 0.5)
+		if report.SampledBytes > 0 {
+			namespace.Share = float64(namespace.SampledBytes) / float64(report.SampledBytes)
+		}
+		if withTTL := namespace.SampledKeys - namespace.NoTTLKeys; withTTL > 0 {
+			namespace.AvgTTL = ttlTotals[name] / time.Duration(withTTL)
+		}
+		report.Namespaces = append(report.Namespaces, *namespace)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		if report.Namespaces[i].EstimatedBytes != report.Namespaces[j].EstimatedBytes {
+			return report.Namespaces[i].EstimatedBytes > report.Namespaces[j].EstimatedBytes
+		}
+		return report.Namespaces[i].Namespace < report.Namespaces[j].Namespace
+	})
+	if report.SampledBytes > 0 {
+		report.NoTTLShare = float64(noTTLBytes) / float64(report.SampledBytes)
+	}
+
+	// RANDOMKEY 可能重复取到同一个键，列出最大的键时去重
+	seen := make(map[string]bool, len(samples))
+	largest := make([]KeySample, 0, len(samples))
+	for _, sample := range samples {
+		if !seen[sample.Key] {
+			seen[sample.Key] = true
+			largest = append(largest, sample)
+		}
+	}
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Bytes > largest[j].Bytes })
+	report.TopKeys = largest[:min(topKeys, len(largest))]
+	return report
+}
+
+// recommendPolicy 按没有过期时间的键的占比与淘汰、命中情况建议 maxmemory-policy：
+// 大部分内存由没有过期时间的键占用时 volatile-* 无法腾出空间，建议 allkeys-*；
+// 否则建议 volatile-*，保留限流计数、TTL 调整等没有过期时间的状态。
+// 已发生淘汰且命中率偏低时建议 LFU，按访问频率而非最近访问保留热点键
+func recommendPolicy(report *MemoryReport, highUsage float64) PolicyRecommendation {
+	info := report.Info
+	current := info.Policy
+	if current == "" {
+		current = "noeviction"
+	}
+	recommendation := PolicyRecommendation{Current: current}
+
+	scope := "volatile"
+	if report.NoTTLShare >= 0.5 {
+		scope = "allkeys"
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf(
+			"%.0f%% of sampled memory is held by keys without a TTL, which volatile-* policies never evict", report.NoTTLShare*100))
+	} else {
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf(
+			"%.0f%% of sampled memory carries a TTL; volatile-* evicts cache entries and keeps state without a TTL such as rate limit counters", (1-report.NoTTLShare)*100))
+	}
+
+	algorithm := "lru"
+	hitRate := 1.0
+	if lookups := info.KeyspaceHits + info.KeyspaceMisses; lookups > 0 {
+		hitRate = float64(info.KeyspaceHits) / float64(lookups)
+	}
+	switch {
+	case info.EvictedKeys > 0 && hitRate < 0.8:
+		algorithm = "lfu"
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf(
+			"%d keys were evicted with a %.0f%% hit rate; LFU keeps frequently read keys that LRU drops after a burst of one-off reads", info.EvictedKeys, hitRate*100))
+	case strings.HasSuffix(current, "-lfu"):
+		algorithm = "lfu"
+	}
+	recommendation.Recommended = scope + "-" + algorithm
+
+	if info.MaxMemory == 0 {
+		recommendation.Reasons = append(recommendation.Reasons,
+			"maxmemory is not set, so no policy applies and Redis grows until the host runs out of memory; set maxmemory below the available memory")
+	} else if usage := float64(info.UsedMemory) / float64(info.MaxMemory); current == "noeviction" && usage >= highUsage {
+		recommendation.Reasons = append(recommendation.Reasons, fmt.Sprintf(
+			"memory is %.0f%% of maxmemory and noeviction rejects writes with OOM once it is full", usage*100))
+	}
+	recommendation.Changed = recommendation.Recommended != current
+	return recommendation
+}
+
+// ModelTTL 推算把命名空间的有效期改为 ttl 后的内存占用。按稳态下条目数与有效期成正比（写入速率不变）估算，
+// 当前有效期取平均剩余有效期的两倍（写入时间均匀分布）；没有过期时间的键不受影响
+func ModelTTL(report *MemoryReport, namespace string, ttl time.Duration) (*TTLImpact, error) {
+	stats := report.Namespace(namespace)
+	if stats == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceNotSampled, namespace)
+	}
+	if stats.AvgTTL <= 0 {
+		return nil, fmt.Errorf("namespace %s has no sampled keys with a TTL", namespace)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+
+	currentTTL := 2 * stats.AvgTTL
+	noTTLBytes := int64(float64(stats.EstimatedBytes) * float64(stats.NoTTLBytes) / float64(max(stats.SampledBytes, 1)))
+	ttlBytes := stats.EstimatedBytes - noTTLBytes
+	projected := noTTLBytes + int64(float64(ttlBytes)*float64(ttl)/float64(currentTTL))
+
+	impact := &TTLImpact{
+		Namespace:           namespace,
+		CurrentTTL:          currentTTL,
+		ProposedTTL:         ttl,
+		CurrentBytes:        stats.EstimatedBytes,
+		ProjectedBytes:      projected,
+		DeltaBytes:          projected - stats.EstimatedBytes,
+		ProjectedUsedMemory: report.Info.UsedMemory + projected - stats.EstimatedBytes,
+	}
+	impact.ExceedsMaxMemory = report.Info.MaxMemory > 0 && impact.ProjectedUsedMemory > report.Info.MaxMemory
+	return impact, nil
+}
+
+// Run 按配置的间隔分析，直到 ctx 结束
+func (a *MemoryAnalyzer) Run(ctx context.Context) {
+	interval := a.config.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := a.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := a.Analyze(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Cache memory analysis failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// MemoryInfo 实现 MemorySampler
+func (c *RedisCache) MemoryInfo(ctx context.Context) (*MemoryInfo, error) {
+	pipe := c.client.Pipeline()
+	memoryCmd := pipe.Info(ctx, "memory")
+	statsCmd := pipe.Info(ctx, "stats")
+	sizeCmd := pipe.DBSize(ctx)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	fields := parseInfo(memoryCmd.Val())
+	for key, value := range parseInfo(statsCmd.Val()) {
+		fields[key] = value
+	}
+	number := func(key string) int64 {
+		value, _ := strconv.ParseInt(fields[key], 10, 64)
+		return value
+	}
+	return &MemoryInfo{
+		UsedMemory:     number("used_memory"),
+		MaxMemory:      number("maxmemory"),
+		Policy:         fields["maxmemory_policy"],
+		Keys:           sizeCmd.Val(),
+		EvictedKeys:    number("evicted_keys"),
+		ExpiredKeys:    number("expired_keys"),
+		KeyspaceHits:   number("keyspace_hits"),
+		KeyspaceMisses: number("keyspace_misses"),
+	}, nil
+}
+
+// parseInfo 解析 INFO 输出的 key:value 行
+func parseInfo(text string) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// SampleKeys 实现 MemorySampler：一个管道取随机键，另一个管道读取大小、类型与剩余有效期
+func (c *RedisCache) SampleKeys(ctx context.Context, n int) ([]KeySample, error) {
+	pipe := c.client.Pipeline()
+	keyCmds := make([]*redis.StringCmd, n)
+	for i := range keyCmds {
+		keyCmds[i] = pipe.RandomKey(ctx)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	type sampleCmds struct {
+		key    string
+		memory *redis.IntCmd
+		typ    *redis.StatusCmd
+		ttl    *redis.DurationCmd
+	}
+	pipe = c.client.Pipeline()
+	pending := make([]sampleCmds, 0, n)
+	for _, cmd := range keyCmds {
+		key, err := cmd.Result()
+		if err != nil {
+			// 库为空时返回 nil
+			continue
+		}
+		pending = append(pending, sampleCmds{
+			key:    key,
+			memory: pipe.MemoryUsage(ctx, key),
+			typ:    pipe.Type(ctx, key),
+			ttl:    pipe.PTTL(ctx, key),
+		})
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	// 取出后被删除或过期的键 MEMORY USAGE 返回 nil，逐条检查
+	_, _ = pipe.Exec(ctx)
+
+	samples := make([]KeySample, 0, len(pending))
+	for _, cmds := range pending {
+		bytes, err := cmds.memory.Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("memory usage of %s: %w", cmds.key, err)
+		}
+		sample := KeySample{Key: cmds.key, Type: cmds.typ.Val(), Bytes: bytes}
+		if ttl := cmds.ttl.Val(); ttl > 0 {
+			sample.TTL = ttl
+		}
+		if trimmed, ok := strings.CutPrefix(cmds.key, c.prefix); ok {
+			sample.Key = trimmed
+		} else {
+			sample.Foreign = true
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
@@ -0,0 +1,125 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSampler 依次返回固定的采样
+type fakeSampler struct {
+	info    cache.MemoryInfo
+	samples []cache.KeySample
+	next    int
+}
+
+func (s *fakeSampler) MemoryInfo(ctx context.Context) (*cache.MemoryInfo, error) {
+	info := s.info
+	return &info, nil
+}
+
+func (s *fakeSampler) SampleKeys(ctx context.Context, n int) ([]cache.KeySample, error) {
+	batch := make([]cache.KeySample, 0, n)
+	for ; n > 0 && s.next < len(s.samples); n-- {
+		batch = append(batch, s.samples[s.next])
+		s.next++
+	}
+	return batch, nil
+}
+
+func analyze(t *testing.T, sampler *fakeSampler) *cache.MemoryReport {
+	t.Helper()
+	analyzer := cache.NewMemoryAnalyzer(sampler, metrics.GetGlobalCollector(), &cache.MemoryAnalysisConfig{SampleSize: 100, BatchSize: 2, TopKeys: 2, HighUsage: 0.8})
+	report, err := analyzer.Analyze(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, report, analyzer.Last())
+	return report
+}
+
+// TestMemoryAnalyzer_EstimatesNamespaces 采样按键总数推算各命名空间的占用，最大的键去重，
+// 大部分内存有过期时间时建议 volatile-lru
+func TestMemoryAnalyzer_EstimatesNamespaces(t *testing.T) {
+	report := analyze(t, &fakeSampler{
+		info: cache.MemoryInfo{UsedMemory: 10000, Keys: 50, Policy: "noeviction"},
+		samples: []cache.KeySample{
+			{Key: "user:1", Type: "string", Bytes: 400, TTL: 10 * time.Minute},
+			{Key: "user:2", Type: "string", Bytes: 200, TTL: 20 * time.Minute},
+			{Key: "user:1", Type: "string", Bytes: 400, TTL: 10 * time.Minute},
+			{Key: "ratelimit:ip:1", Type: "zset", Bytes: 100},
+			{Key: "other", Type: "string", Bytes: 100, Foreign: true},
+		},
+	})
+
+	assert.Equal(t, 5, report.SampledKeys)
+	assert.Equal(t, int64(1200), report.SampledBytes)
+	require.Len(t, report.Namespaces, 3)
+
+	user := report.Namespaces[0]
+	assert.Equal(t, "user", user.Namespace)
+	assert.Equal(t, 3, user.SampledKeys)
+	assert.Equal(t, int64(30), user.EstimatedKeys, "50 keys / 5 samples scales each sample to 10 keys")
+	assert.Equal(t, int64(10000), user.EstimatedBytes)
+	assert.Equal(t, map[string]int{"string": 3}, user.Types)
+	assert.InDelta(t, float64(40*time.Minute/3), float64(user.AvgTTL), float64(time.Second))
+	assert.Equal(t, cache.ForeignNamespace, report.Namespaces[1].Namespace)
+	assert.Equal(t, "ratelimit:ip", report.Namespaces[2].Namespace)
+	assert.Equal(t, 1, report.Namespaces[2].NoTTLKeys)
+
+	require.Len(t, report.TopKeys, 2)
+	assert.Equal(t, "user:1", report.TopKeys[0].Key)
+	assert.Equal(t, "user:2", report.TopKeys[1].Key)
+
+	assert.Equal(t, "volatile-lru", report.Recommendation.Recommended)
+	assert.True(t, report.Recommendation.Changed)
+	assert.Contains(t, report.Recommendation.Reasons[len(report.Recommendation.Reasons)-1], "maxmemory is not set")
+}
+
+// TestMemoryAnalyzer_RecommendsAllKeysLFU 没有过期时间的键占多数时 volatile-* 无法腾出空间，
+// 已发生淘汰且命中率低时建议 LFU
+func TestMemoryAnalyzer_RecommendsAllKeysLFU(t *testing.T) {
+	report := analyze(t, &fakeSampler{
+		info: cache.MemoryInfo{
+			UsedMemory: 950, MaxMemory: 1000, Keys: 2, Policy: "volatile-lru",
+			EvictedKeys: 30, KeyspaceHits: 50, KeyspaceMisses: 50,
+		},
+		samples: []cache.KeySample{
+			{Key: "resp:a", Type: "string", Bytes: 900},
+			{Key: "resp:b", Type: "string", Bytes: 100, TTL: time.Minute},
+		},
+	})
+
+	assert.InDelta(t, 0.9, report.NoTTLShare, 0.001)
+	assert.Equal(t, "volatile-lru", report.Recommendation.Current)
+	assert.Equal(t, "allkeys-lfu", report.Recommendation.Recommended)
+	assert.Len(t, report.Recommendation.Reasons, 2)
+}
+
+// TestModelTTL 有过期时间的部分按有效期比例缩放，没有过期时间的部分不变
+func TestModelTTL(t *testing.T) {
+	report := analyze(t, &fakeSampler{
+		info: cache.MemoryInfo{UsedMemory: 5000, MaxMemory: 6000, Keys: 4},
+		samples: []cache.KeySample{
+			{Key: "rbac:user:1", Type: "string", Bytes: 300, TTL: 5 * time.Minute},
+			{Key: "rbac:user:2", Type: "string", Bytes: 300, TTL: 15 * time.Minute},
+			{Key: "rbac:user:3", Type: "string", Bytes: 400},
+			{Key: "session:1", Type: "hash", Bytes: 1000, TTL: time.Hour},
+		},
+	})
+
+	impact, err := cache.ModelTTL(report, "rbac:user", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Minute, impact.CurrentTTL)
+	assert.Equal(t, int64(1000), impact.CurrentBytes)
+	assert.Equal(t, int64(400+600*3), impact.ProjectedBytes)
+	assert.Equal(t, int64(1200), impact.DeltaBytes)
+	assert.Equal(t, int64(6200), impact.ProjectedUsedMemory)
+	assert.True(t, impact.ExceedsMaxMemory)
+
+	_, err = cache.ModelTTL(report, "missing", time.Hour)
+	assert.ErrorIs(t, err, cache.ErrNamespaceNotSampled)
+}
@@ -59,13 +59,16 @@ func (mc *MomentCache) Delete(ctx context.Context, key string) error {
 
 // DeletePattern 删除匹配模式的缓存
 func (mc *MomentCache) DeletePattern(ctx context.Context, pattern string) error {
-	keys, err := mc.client.Keys(ctx, pattern).Result()
-	if err != nil {
-		return fmt.Errorf("get cache keys: %w", err)
+	opts := DefaultInvalidateOptions()
+	scan := func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		return mc.client.Scan(ctx, cursor, pattern, opts.ScanCount).Result()
+	}
+	unlink := func(ctx context.Context, keys []string) (int64, error) {
+		return mc.client.Unlink(ctx, keys...).Result()
 	}
 
-	if len(keys) > 0 {
-		return mc.client.Del(ctx, keys...).Err()
+	if _, err := scanAndUnlink(ctx, mc.client.Options().Addr, pattern, opts, scan, unlink); err != nil {
+		return fmt.Errorf("delete cache pattern: %w", err)
 	}
 
 	return nil
@@ -1,15 +1,28 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/metrics"
+
+	"golang.org/x/time/rate"
 )
 
+// ErrSyncUnsupported 本地缓存不能采样键，无法与远程缓存对账
+var ErrSyncUnsupported = errors.New("local cache does not support key sampling")
+
+// LocalKeySampler 能随机采样键的本地缓存，MultiLevelCache 的后台同步从中取键与远程缓存比对
+type LocalKeySampler interface {
+	RandomKeys(n int) []string
+}
+
 // MultiLevelCache 多级缓存
 type MultiLevelCache struct {
 	localCache       CacheService
@@ -18,6 +31,23 @@ type MultiLevelCache struct {
 	config           *MultiLevelConfig
 	strategy         CacheStrategy
 	coordinator      *CacheCoordinator
+	ttls             *TTLResolver
+
+	syncLimiter *rate.Limiter
+	syncMu      sync.Mutex
+	syncStats   MultiLevelSyncStats
+	stop        chan struct{}
+	closeOnce   sync.Once
+}
+
+// MultiLevelSyncStats 后台同步的累计统计
+type MultiLevelSyncStats struct {
+	Cycles   int64     `json:"cycles"`
+	Checked  int64     `json:"checked"`  // 与远程比对过的本地键
+	Repaired int64     `json:"repaired"` // 值或有效期与远程不一致、按远程刷新的本地键
+	Evicted  int64     `json:"evicted"`  // 远程已不存在、从本地删除的键
+	Errors   int64     `json:"errors"`
+	LastRun  time.Time `json:"last_run,omitempty"`
 }
 
 // MultiLevelConfig 多级缓存配置
@@ -29,10 +59,27 @@ type MultiLevelConfig struct {
 	EnableCoordination   bool          `json:"enable_coordination"`
 	EnableBackgroundSync bool          `json:"enable_background_sync"`
 	SyncInterval         time.Duration `json:"sync_interval"`
+	SyncSampleSize       int           `json:"sync_sample_size"`     // 每轮同步比对的本地键数上限
+	SyncRatePerSecond    float64       `json:"sync_rate_per_second"` // 每秒比对的键数上限，避免同步占满 Redis
 	MaxRetries           int           `json:"max_retries"`
 	RetryDelay           time.Duration `json:"retry_delay"`
 }
 
+// DefaultMultiLevelConfig 默认多级缓存配置，本地缓存只保留短时间以减小各实例间的不一致
+func DefaultMultiLevelConfig() *MultiLevelConfig {
+	return &MultiLevelConfig{
+		LocalCacheSize:    10000,
+		LocalCacheTTL:     30 * time.Second,
+		RemoteCacheTTL:    5 * time.Minute,
+		EnableMetrics:     true,
+		SyncInterval:      time.Minute,
+		SyncSampleSize:    200,
+		SyncRatePerSecond: 100,
+		MaxRetries:        3,
+		RetryDelay:        100 * time.Millisecond,
+	}
+}
+
 // CacheStrategy 缓存策略
 type CacheStrategy interface {
 	Get(ctx context.Context, key string) (interface{}, error)
@@ -72,6 +119,18 @@ func NewMultiLevelCache(localCache, remoteCache CacheService, metricsCollector *
 		config:           config,
 		strategy:         NewCacheStrategy(config),
 		coordinator:      NewCacheCoordinator(localCache, remoteCache, config),
+		stop:             make(chan struct{}),
+	}
+	if config.SyncRatePerSecond > 0 {
+		burst := config.SyncSampleSize
+		if burst <= 0 {
+			burst = 1
+		}
+		mlc.syncLimiter = rate.NewLimiter(rate.Limit(config.SyncRatePerSecond), burst)
+	}
+	if strategy, ok := mlc.strategy.(*DefaultCacheStrategy); ok {
+		strategy.localCache = localCache
+		strategy.remoteCache = remoteCache
 	}
 
 	// 启动后台同步
@@ -82,6 +141,14 @@ func NewMultiLevelCache(localCache, remoteCache CacheService, metricsCollector *
 	return mlc
 }
 
+// SetTTLResolver 按键的命名空间使用 TTL 配置中的本地与远程有效期，没有匹配时使用 LocalCacheTTL 与 RemoteCacheTTL
+func (mlc *MultiLevelCache) SetTTLResolver(resolver *TTLResolver) {
+	mlc.ttls = resolver
+	if strategy, ok := mlc.strategy.(*DefaultCacheStrategy); ok {
+		strategy.ttls = resolver
+	}
+}
+
 // NewCacheStrategy 创建缓存策略
 func NewCacheStrategy(config *MultiLevelConfig) CacheStrategy {
 	return &DefaultCacheStrategy{
@@ -113,6 +180,7 @@ type DefaultCacheStrategy struct {
 	localCache  CacheService
 	remoteCache CacheService
 	config      *MultiLevelConfig
+	ttls        *TTLResolver
 }
 
 // Get 获取缓存值
@@ -159,9 +227,10 @@ func (dcs *DefaultCacheStrategy) Get(ctx context.Context, key string) (interface
 
 	// 异步写入本地缓存
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		// 回填在请求返回后完成，使用脱离取消但保留 trace 的上下文
+		ctx, cancel := ctxutil.Detach(ctx, time.Second*5)
 		defer cancel()
-		dcs.localCache.Set(ctx, key, value, dcs.config.LocalCacheTTL)
+		dcs.localCache.Set(ctx, key, value, dcs.ttls.LocalTTL(key, dcs.config.LocalCacheTTL))
 	}()
 
 	if dcs.config.EnableCoordination {
@@ -180,12 +249,12 @@ func (dcs *DefaultCacheStrategy) Set(ctx context.Context, key string, value inte
 	}
 
 	// 写入本地缓存
-	if err := dcs.localCache.Set(ctx, key, jsonData, dcs.config.LocalCacheTTL); err != nil {
+	if err := dcs.localCache.Set(ctx, key, jsonData, dcs.ttls.LocalTTL(key, dcs.config.LocalCacheTTL)); err != nil {
 		return fmt.Errorf("failed to set local cache: %w", err)
 	}
 
 	// 写入远程缓存
-	if err := dcs.remoteCache.Set(ctx, key, jsonData, dcs.config.RemoteCacheTTL); err != nil {
+	if err := dcs.remoteCache.Set(ctx, key, jsonData, dcs.ttls.TTL(key, dcs.config.RemoteCacheTTL)); err != nil {
 		return fmt.Errorf("failed to set remote cache: %w", err)
 	}
 
@@ -270,6 +339,113 @@ func (mlc *MultiLevelCache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// GetMany 批量获取，本地缓存未命中的键统一从远程缓存获取并回填本地缓存
+func (mlc *MultiLevelCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	start := time.Now()
+
+	localResult, err := mlc.localCache.GetMany(ctx, keys)
+	if err != nil {
+		// 本地缓存不可用时全部走远程
+		localResult = NewBatchGetResult()
+		localResult.Missing = keys
+	}
+
+	result := NewBatchGetResult()
+	for key, data := range localResult.Values {
+		result.Values[key] = data
+	}
+
+	remoteKeys := make([]string, 0, len(localResult.Missing)+len(localResult.Errors))
+	remoteKeys = append(remoteKeys, localResult.Missing...)
+	for key := range localResult.Errors {
+		remoteKeys = append(remoteKeys, key)
+	}
+	if len(remoteKeys) == 0 {
+		mlc.recordMetrics("get_many", time.Since(start), true)
+		return result, nil
+	}
+
+	remoteResult, err := mlc.remoteCache.GetMany(ctx, remoteKeys)
+	if err != nil {
+		mlc.recordMetrics("get_many_error", time.Since(start), false)
+		for _, key := range remoteKeys {
+			result.Errors[key] = fmt.Errorf("failed to get from remote cache: %w", err)
+		}
+		return result, nil
+	}
+
+	result.Missing = append(result.Missing, remoteResult.Missing...)
+	for key, keyErr := range remoteResult.Errors {
+		result.Errors[key] = keyErr
+	}
+
+	// 回填本地缓存，保证随后的单键读取与批量读取结果一致
+	backfill := make([]CacheEntry, 0, len(remoteResult.Values))
+	for key, data := range remoteResult.Values {
+		result.Values[key] = data
+		backfill = append(backfill, CacheEntry{Key: key, Value: data, Expiration: mlc.ttls.LocalTTL(key, mlc.config.LocalCacheTTL)})
+	}
+	if len(backfill) > 0 {
+		if _, err := mlc.localCache.SetMany(ctx, backfill); err != nil {
+			log.Printf("Failed to backfill local cache: %v", err)
+		}
+	}
+
+	mlc.recordMetrics("get_many", time.Since(start), !result.HasErrors())
+	return result, nil
+}
+
+// SetMany 批量设置，先写远程缓存，仅对远程写入成功的键写入本地缓存
+func (mlc *MultiLevelCache) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	start := time.Now()
+
+	if mlc.ttls != nil {
+		resolved := make([]CacheEntry, len(entries))
+		for i, entry := range entries {
+			entry.Expiration = mlc.ttls.TTL(entry.Key, entry.Expiration)
+			resolved[i] = entry
+		}
+		entries = resolved
+	}
+	remoteResult, err := mlc.remoteCache.SetMany(ctx, entries)
+	if err != nil {
+		mlc.recordMetrics("set_many_error", time.Since(start), false)
+		return nil, fmt.Errorf("failed to set remote cache: %w", err)
+	}
+
+	succeeded := make(map[string]bool, len(remoteResult.Succeeded))
+	for _, key := range remoteResult.Succeeded {
+		succeeded[key] = true
+	}
+
+	localEntries := make([]CacheEntry, 0, len(remoteResult.Succeeded))
+	for _, entry := range entries {
+		if !succeeded[entry.Key] {
+			continue
+		}
+		ttl := mlc.ttls.LocalTTL(entry.Key, mlc.config.LocalCacheTTL)
+		if entry.Expiration > 0 && entry.Expiration < ttl {
+			ttl = entry.Expiration
+		}
+		localEntries = append(localEntries, CacheEntry{Key: entry.Key, Value: entry.Value, Expiration: ttl})
+	}
+
+	if len(localEntries) > 0 {
+		localResult, err := mlc.localCache.SetMany(ctx, localEntries)
+		if err != nil {
+			log.Printf("Failed to set local cache: %v", err)
+		} else {
+			// 本地写入失败的键删除旧值，避免读到过期数据
+			for key := range localResult.Errors {
+				mlc.localCache.Delete(ctx, key)
+			}
+		}
+	}
+
+	mlc.recordMetrics("set_many", time.Since(start), !remoteResult.HasErrors())
+	return remoteResult, nil
+}
+
 // GetWithFallback 带回退的获取
 func (mlc *MultiLevelCache) GetWithFallback(ctx context.Context, key string, fallback func() (interface{}, error)) (interface{}, error) {
 	// 尝试从缓存获取
@@ -313,6 +489,9 @@ func (mlc *MultiLevelCache) GetStats() map[string]interface{} {
 		stats["hit_rate"] = float64(stats["total_hits"].(int64)) / float64(totalRequests)
 	}
 
+	// 后台同步的累计比对、修复与淘汰数
+	stats["sync"] = mlc.SyncStats()
+
 	return stats
 }
 
@@ -367,23 +546,155 @@ func (mlc *MultiLevelCache) InvalidatePattern(ctx context.Context, pattern strin
 	return mlc.Invalidate(ctx, keys)
 }
 
-// startBackgroundSync 启动后台同步
+// startBackgroundSync 每 SyncInterval 执行一次 Reconcile，Close 后停止
 func (mlc *MultiLevelCache) startBackgroundSync() {
 	ticker := time.NewTicker(mlc.config.SyncInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-mlc.stop:
+			return
 		case <-ticker.C:
 			mlc.syncCaches()
 		}
 	}
 }
 
-// syncCaches 同步缓存
+// syncCaches 执行一轮同步，单轮耗时不超过同步间隔
 func (mlc *MultiLevelCache) syncCaches() {
-	// 简化实现，实际项目中应该实现更复杂的同步逻辑
-	log.Println("Syncing caches...")
+	ctx, cancel := context.WithTimeout(context.Background(), mlc.config.SyncInterval)
+	defer cancel()
+	go func() {
+		select {
+		case <-mlc.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	result, err := mlc.Reconcile(ctx)
+	if err != nil {
+		log.Printf("Multi-level cache sync failed: %v", err)
+		return
+	}
+	if result.Repaired > 0 || result.Evicted > 0 || result.Errors > 0 {
+		log.Printf("Multi-level cache sync: checked %d, repaired %d, evicted %d, errors %d",
+			result.Checked, result.Repaired, result.Evicted, result.Errors)
+	}
+}
+
+// Reconcile 从本地缓存随机采样最多 SyncSampleSize 个键与远程缓存比对，按 SyncRatePerSecond 限速：
+// 远程已不存在的键从本地删除；值不同的键按远程的值刷新；本地剩余有效期长于远程时按远程的有效期重写，
+// 避免远程过期后本地仍返回旧值。远程缓存是权威数据，刷新前再次读取本地值，本地已被并发写入更新时跳过
+func (mlc *MultiLevelCache) Reconcile(ctx context.Context) (MultiLevelSyncStats, error) {
+	start := time.Now()
+	result := MultiLevelSyncStats{Cycles: 1}
+	sampler, ok := mlc.localCache.(LocalKeySampler)
+	if !ok {
+		return result, ErrSyncUnsupported
+	}
+	sampleSize := mlc.config.SyncSampleSize
+	if sampleSize <= 0 {
+		sampleSize = DefaultMultiLevelConfig().SyncSampleSize
+	}
+
+	var err error
+	for _, key := range sampler.RandomKeys(sampleSize) {
+		if mlc.syncLimiter != nil {
+			if err = mlc.syncLimiter.Wait(ctx); err != nil {
+				break
+			}
+		}
+		result.Checked++
+		switch outcome, syncErr := mlc.reconcileKey(ctx, key); {
+		case syncErr != nil:
+			result.Errors++
+		case outcome == syncRepaired:
+			result.Repaired++
+		case outcome == syncEvicted:
+			result.Evicted++
+		}
+	}
+
+	result.LastRun = start
+	mlc.syncMu.Lock()
+	mlc.syncStats.Cycles++
+	mlc.syncStats.Checked += result.Checked
+	mlc.syncStats.Repaired += result.Repaired
+	mlc.syncStats.Evicted += result.Evicted
+	mlc.syncStats.Errors += result.Errors
+	mlc.syncStats.LastRun = start
+	mlc.syncMu.Unlock()
+	mlc.recordMetrics("sync", time.Since(start), result.Errors == 0)
+
+	// 超时或关闭时已比对的键仍计入统计
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		err = nil
+	}
+	return result, err
+}
+
+// SyncStats 后台同步的累计统计
+func (mlc *MultiLevelCache) SyncStats() MultiLevelSyncStats {
+	mlc.syncMu.Lock()
+	defer mlc.syncMu.Unlock()
+	return mlc.syncStats
+}
+
+// syncOutcome 单个键的同步结果
+type syncOutcome int
+
+const (
+	syncConsistent syncOutcome = iota
+	syncRepaired
+	syncEvicted
+)
+
+// syncTTLTolerance 本地剩余有效期超过远程的容差，两次读取之间的耗时不视为不一致
+const syncTTLTolerance = time.Second
+
+func (mlc *MultiLevelCache) reconcileKey(ctx context.Context, key string) (syncOutcome, error) {
+	var local json.RawMessage
+	localTTL, err := mlc.localCache.GetWithTTL(ctx, key, &local)
+	if err != nil {
+		// 采样后已过期或被删除
+		return syncConsistent, nil
+	}
+
+	var remote json.RawMessage
+	remoteTTL, err := mlc.remoteCache.GetWithTTL(ctx, key, &remote)
+	if err != nil {
+		if err.Error() != "cache miss" {
+			return syncConsistent, err
+		}
+		if err := mlc.localCache.Delete(ctx, key); err != nil {
+			return syncConsistent, err
+		}
+		return syncEvicted, nil
+	}
+
+	staleValue := !bytes.Equal(local, remote)
+	staleTTL := remoteTTL > 0 && localTTL > remoteTTL+syncTTLTolerance
+	if !staleValue && !staleTTL {
+		return syncConsistent, nil
+	}
+
+	var current json.RawMessage
+	if _, err := mlc.localCache.GetWithTTL(ctx, key, &current); err != nil || !bytes.Equal(current, local) {
+		return syncConsistent, nil
+	}
+	ttl := localTTL
+	if limit := mlc.ttls.LocalTTL(key, mlc.config.LocalCacheTTL); staleValue && limit > 0 {
+		ttl = limit
+	}
+	if remoteTTL > 0 && ttl > remoteTTL {
+		ttl = remoteTTL
+	}
+	if err := mlc.localCache.Set(ctx, key, remote, ttl); err != nil {
+		return syncConsistent, err
+	}
+	return syncRepaired, nil
 }
 
 // Subscribe 订阅事件
@@ -594,8 +905,9 @@ func (mlc *MultiLevelCache) recordMetrics(operation string, duration time.Durati
 	}
 }
 
-// Close 关闭多级缓存
+// Close 关闭多级缓存，停止后台同步
 func (mlc *MultiLevelCache) Close() error {
+	mlc.closeOnce.Do(func() { close(mlc.stop) })
 	return nil
 }
 
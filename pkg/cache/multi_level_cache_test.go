@@ -0,0 +1,87 @@
+package cache_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMultiLevelCache_Reconcile 远程已删除的键从本地淘汰，值不同的键按远程刷新，
+// 本地有效期长于远程时按远程重写，一致的键不变；统计计入 GetStats 的 sync
+func TestMultiLevelCache_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	local := cache.NewMemoryCache()
+	remote := fakes.NewCache(nil)
+	config := cache.DefaultMultiLevelConfig()
+	config.SyncRatePerSecond = 0
+	levels := cache.NewMultiLevelCache(local, remote, metrics.GetGlobalCollector(), config)
+	defer levels.Close()
+
+	value := func(v string) json.RawMessage { return json.RawMessage(`"` + v + `"`) }
+	for key, v := range map[string]string{"same": "v1", "deleted": "v1", "changed": "old", "longer": "v1"} {
+		require.NoError(t, local.Set(ctx, key, value(v), time.Minute))
+	}
+	require.NoError(t, remote.Set(ctx, "same", value("v1"), time.Hour))
+	require.NoError(t, remote.Set(ctx, "changed", value("new"), time.Hour))
+	require.NoError(t, remote.Set(ctx, "longer", value("v1"), 10*time.Second))
+
+	result, err := levels.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.Checked)
+	assert.Equal(t, int64(2), result.Repaired)
+	assert.Equal(t, int64(1), result.Evicted)
+	assert.Zero(t, result.Errors)
+
+	exists, err := local.Exists(ctx, "deleted")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	var got string
+	require.NoError(t, local.Get(ctx, "changed", &got))
+	assert.Equal(t, "new", got)
+	ttl, err := local.GetWithTTL(ctx, "longer", &got)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, ttl, 10*time.Second)
+	ttl, err = local.GetWithTTL(ctx, "same", &got)
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 50*time.Second)
+
+	again, err := levels.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), again.Checked)
+	assert.Zero(t, again.Repaired+again.Evicted)
+
+	stats := levels.GetStats()["sync"].(cache.MultiLevelSyncStats)
+	assert.Equal(t, int64(2), stats.Cycles)
+	assert.Equal(t, int64(7), stats.Checked)
+	assert.Equal(t, int64(2), stats.Repaired)
+	assert.Equal(t, int64(1), stats.Evicted)
+}
+
+// TestMultiLevelCache_ReconcileSampleSize 每轮最多比对 SyncSampleSize 个键
+func TestMultiLevelCache_ReconcileSampleSize(t *testing.T) {
+	ctx := context.Background()
+	local := cache.NewMemoryCache()
+	config := cache.DefaultMultiLevelConfig()
+	config.SyncSampleSize = 3
+	levels := cache.NewMultiLevelCache(local, fakes.NewCache(nil), metrics.GetGlobalCollector(), config)
+	defer levels.Close()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, local.Set(ctx, key, "v", time.Minute))
+	}
+
+	result, err := levels.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Checked)
+	assert.Equal(t, int64(3), result.Evicted)
+
+	unsupported := cache.NewMultiLevelCache(fakes.NewCache(nil), fakes.NewCache(nil), metrics.GetGlobalCollector(), config)
+	_, err = unsupported.Reconcile(ctx)
+	assert.ErrorIs(t, err, cache.ErrSyncUnsupported)
+}
@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	redisv8 "github.com/go-redis/redis/v8"
+	"golang.org/x/time/rate"
+)
+
+// InvalidateOptions 模式失效配置
+type InvalidateOptions struct {
+	ScanCount        int64                    `json:"scan_count"`         // 每次 SCAN 的 COUNT 提示
+	BatchSize        int                      `json:"batch_size"`         // 每次 UNLINK 的最大键数
+	BatchesPerSecond float64                  `json:"batches_per_second"` // 每个节点每秒最多执行的批次数，0 表示不限制
+	OnProgress       func(InvalidateProgress) `json:"-"`                  // 进度回调
+}
+
+// InvalidateProgress 模式失效进度
+type InvalidateProgress struct {
+	Node    string `json:"node"`
+	Pattern string `json:"pattern"`
+	Scanned int64  `json:"scanned"`
+	Deleted int64  `json:"deleted"`
+	Done    bool   `json:"done"`
+}
+
+// DefaultInvalidateOptions 默认模式失效配置
+func DefaultInvalidateOptions() *InvalidateOptions {
+	return &InvalidateOptions{
+		ScanCount:        500,
+		BatchSize:        500,
+		BatchesPerSecond: 20,
+	}
+}
+
+// scanFunc 执行一次 SCAN
+type scanFunc func(ctx context.Context, cursor uint64) ([]string, uint64, error)
+
+// unlinkFunc 删除一批键，返回删除数量
+type unlinkFunc func(ctx context.Context, keys []string) (int64, error)
+
+// scanAndUnlink 使用 SCAN 遍历匹配的键并分批 UNLINK，避免 KEYS 阻塞 Redis
+func scanAndUnlink(ctx context.Context, node, pattern string, opts *InvalidateOptions, scan scanFunc, unlink unlinkFunc) (*InvalidateProgress, error) {
+	if opts == nil {
+		opts = DefaultInvalidateOptions()
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	var limiter *rate.Limiter
+	if opts.BatchesPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.BatchesPerSecond), 1)
+	}
+
+	progress := &InvalidateProgress{Node: node, Pattern: pattern}
+	report := func() {
+		if opts.OnProgress != nil {
+			opts.OnProgress(*progress)
+		}
+	}
+
+	pending := make([]string, 0, batchSize)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		deleted, err := unlink(ctx, pending)
+		if err != nil {
+			return fmt.Errorf("failed to unlink keys: %w", err)
+		}
+		progress.Deleted += deleted
+		pending = pending[:0]
+		report()
+		return nil
+	}
+
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+
+		keys, next, err := scan(ctx, cursor)
+		if err != nil {
+			return progress, fmt.Errorf("failed to scan keys: %w", err)
+		}
+		progress.Scanned += int64(len(keys))
+
+		for _, key := range keys {
+			pending = append(pending, key)
+			if len(pending) >= batchSize {
+				if err := flush(); err != nil {
+					return progress, err
+				}
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if err := flush(); err != nil {
+		return progress, err
+	}
+
+	progress.Done = true
+	report()
+	return progress, nil
+}
+
+// InvalidatePatternWithOptions 基于 SCAN/UNLINK 按模式删除缓存
+func (c *RedisCache) InvalidatePatternWithOptions(ctx context.Context, pattern string, opts *InvalidateOptions) (*InvalidateProgress, error) {
+	if opts == nil {
+		opts = DefaultInvalidateOptions()
+	}
+	fullPattern := c.prefix + pattern
+
+	scan := func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		return c.client.Scan(ctx, cursor, fullPattern, opts.ScanCount).Result()
+	}
+	unlink := func(ctx context.Context, keys []string) (int64, error) {
+		return c.client.Unlink(ctx, keys...).Result()
+	}
+
+	return scanAndUnlink(ctx, c.client.Options().Addr, fullPattern, opts, scan, unlink)
+}
+
+// InvalidatePattern 在集群的每个主节点上执行 SCAN/UNLINK，使用默认配置
+func (rc *RedisCluster) InvalidatePattern(ctx context.Context, pattern string) error {
+	_, err := rc.InvalidatePatternWithOptions(ctx, pattern, DefaultInvalidateOptions())
+	return err
+}
+
+// InvalidatePatternWithOptions 在集群的每个主节点上执行 SCAN/UNLINK，返回各节点的进度
+func (rc *RedisCluster) InvalidatePatternWithOptions(ctx context.Context, pattern string, opts *InvalidateOptions) (map[string]*InvalidateProgress, error) {
+	if opts == nil {
+		opts = DefaultInvalidateOptions()
+	}
+
+	results := make(map[string]*InvalidateProgress)
+	var mu sync.Mutex
+
+	err := rc.cluster.ForEachMaster(ctx, func(ctx context.Context, client *redisv8.Client) error {
+		node := client.Options().Addr
+
+		scan := func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+			return client.Scan(ctx, cursor, pattern, opts.ScanCount).Result()
+		}
+		// 集群中多键命令要求键位于同一槽位，按槽位分组后在管道中删除
+		unlink := func(ctx context.Context, keys []string) (int64, error) {
+			pipe := client.Pipeline()
+			cmds := make([]*redisv8.IntCmd, 0)
+			for _, slotKeys := range groupKeysBySlot(keys) {
+				cmds = append(cmds, pipe.Unlink(ctx, slotKeys...))
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return 0, err
+			}
+			var deleted int64
+			for _, cmd := range cmds {
+				deleted += cmd.Val()
+			}
+			return deleted, nil
+		}
+
+		progress, err := scanAndUnlink(ctx, node, pattern, opts, scan, unlink)
+
+		mu.Lock()
+		results[node] = progress
+		mu.Unlock()
+
+		if err != nil {
+			log.Printf("Failed to invalidate pattern %s on node %s: %v", pattern, node, err)
+			return fmt.Errorf("node %s: %w", node, err)
+		}
+		return nil
+	})
+
+	return results, err
+}
@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+)
+
+var (
+	// ErrPreloadSetNotFound 没有配置该预热任务组
+	ErrPreloadSetNotFound = errors.New("preload set not found")
+	// ErrPreloadRunning 已有预热任务组正在执行
+	ErrPreloadRunning = errors.New("cache preload is already running")
+)
+
+// 部署预热状态
+const (
+	PreloadIdle     = "idle"
+	PreloadRunning  = "running"
+	PreloadDone     = "done"
+	PreloadTimedOut = "timed_out"
+)
+
+// PreloadConfig 部署预热配置
+type PreloadConfig struct {
+	Sets    map[string][]string // 任务组名到依次执行的预热任务名（Admin.RegisterWarmup 登记的名称）
+	Timeout time.Duration       // 一次预热的最长时间，也是就绪门禁的最长等待时间
+}
+
+// DefaultPreloadConfig 默认部署预热配置
+func DefaultPreloadConfig() *PreloadConfig {
+	return &PreloadConfig{
+		Sets:    map[string][]string{},
+		Timeout: 2 * time.Minute,
+	}
+}
+
+// PreloadTask 任务组中单个预热任务的结果
+type PreloadTask struct {
+	Name     string        `json:"name"`
+	Keys     int           `json:"keys"`
+	Success  int           `json:"success"`
+	Failed   int           `json:"failed"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// PreloadStatus 最近一次部署预热的进度与就绪门禁状态
+type PreloadStatus struct {
+	Set        string        `json:"set,omitempty"`
+	State      string        `json:"state"`
+	Total      int           `json:"total"` // 任务组中的任务数
+	Tasks      []PreloadTask `json:"tasks"` // 已结束的任务
+	StartedAt  time.Time     `json:"started_at,omitempty"`
+	FinishedAt time.Time     `json:"finished_at,omitempty"`
+	Gated      bool          `json:"gated"` // 为 true 时就绪检查失败，实例不接收流量
+}
+
+// Preloader 部署预热：依次执行配置的预热任务组，并提供就绪门禁。
+// Arm 后就绪检查失败，直到一次预热结束或距 Arm 超过 Timeout；门禁放行后不再关闭，
+// 之后手动触发的预热不会把已接收流量的实例摘除
+type Preloader struct {
+	admin  *Admin
+	config *PreloadConfig
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	status  PreloadStatus
+	done    chan struct{} // 当前预热结束时关闭
+	armedAt time.Time
+}
+
+// NewPreloader 创建部署预热，任务通过 admin 执行，结果记录在其预热任务列表与变更事件中
+func NewPreloader(admin *Admin, config *PreloadConfig) *Preloader {
+	if config == nil {
+		config = DefaultPreloadConfig()
+	}
+	done := make(chan struct{})
+	close(done)
+	return &Preloader{
+		admin:  admin,
+		config: config,
+		clock:  clock.OrReal(nil),
+		status: PreloadStatus{State: PreloadIdle},
+		done:   done,
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (p *Preloader) SetClock(c clock.Clock) {
+	p.clock = clock.OrReal(c)
+}
+
+// Arm 关闭就绪门禁，应在实例开始接收流量之前调用
+func (p *Preloader) Arm() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Gated = true
+	p.armedAt = p.clock.Now()
+}
+
+// Start 在后台执行任务组，已有任务组在执行时返回 ErrPreloadRunning
+func (p *Preloader) Start(set, actor string) (PreloadStatus, error) {
+	tasks, ok := p.config.Sets[set]
+	if !ok {
+		return PreloadStatus{}, fmt.Errorf("%w: %s", ErrPreloadSetNotFound, set)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status.State == PreloadRunning {
+		return p.snapshotLocked(), ErrPreloadRunning
+	}
+	p.status = PreloadStatus{
+		Set:       set,
+		State:     PreloadRunning,
+		Total:     len(tasks),
+		Tasks:     []PreloadTask{},
+		StartedAt: p.clock.Now(),
+		Gated:     p.status.Gated,
+	}
+	p.done = make(chan struct{})
+	go p.run(tasks, actor, p.done)
+	return p.snapshotLocked(), nil
+}
+
+// run 依次执行预热任务，超时后跳过剩余任务；结束后放行门禁
+func (p *Preloader) run(tasks []string, actor string, done chan struct{}) {
+	defer close(done)
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	state := PreloadDone
+	for _, name := range tasks {
+		if ctx.Err() != nil {
+			state = PreloadTimedOut
+			break
+		}
+		start := p.clock.Now()
+		result, err := p.admin.RunWarmup(ctx, name, actor)
+		task := PreloadTask{Name: name, Duration: p.clock.Since(start)}
+		if result != nil {
+			task.Keys, task.Success, task.Failed = result.TotalKeys, result.SuccessKeys, result.FailedKeys
+		}
+		if err != nil {
+			task.Error = err.Error()
+		}
+		p.mu.Lock()
+		p.status.Tasks = append(p.status.Tasks, task)
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.State = state
+	p.status.FinishedAt = p.clock.Now()
+	if p.status.Gated {
+		p.status.Gated = false
+		log.Printf("Cache preload %s %s after %s, instance is ready", p.status.Set, state, p.status.FinishedAt.Sub(p.armedAt))
+	}
+}
+
+// Wait 等待当前预热结束或 ctx 结束，返回此时的状态
+func (p *Preloader) Wait(ctx context.Context) PreloadStatus {
+	p.mu.Lock()
+	done := p.done
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return p.Status()
+}
+
+// Status 最近一次预热的状态
+func (p *Preloader) Status() PreloadStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snapshotLocked()
+}
+
+func (p *Preloader) snapshotLocked() PreloadStatus {
+	status := p.status
+	status.Tasks = append([]PreloadTask(nil), p.status.Tasks...)
+	return status
+}
+
+// Check 就绪检查：门禁关闭时失败，距 Arm 超过 Timeout 后放行
+func (p *Preloader) Check(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.status.Gated {
+		return nil
+	}
+	if waited := p.clock.Since(p.armedAt); waited >= p.config.Timeout {
+		p.status.Gated = false
+		log.Printf("Cache preload gate opened after waiting %s, state %s", waited, p.status.State)
+		return nil
+	}
+	if p.status.State == PreloadRunning {
+		return fmt.Errorf("cache preload %s running: %d/%d tasks finished", p.status.Set, len(p.status.Tasks), p.status.Total)
+	}
+	return errors.New("waiting for cache preload to start")
+}
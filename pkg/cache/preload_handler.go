@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreloadHandler 部署预热接口，发布流程在新实例启动后调用，预热完成前实例的 /readyz 返回 503
+type PreloadHandler struct {
+	preloader *Preloader
+}
+
+// NewPreloadHandler 创建部署预热接口
+func NewPreloadHandler(preloader *Preloader) *PreloadHandler {
+	return &PreloadHandler{preloader: preloader}
+}
+
+// RegisterAdminRoutes 注册部署预热路由，调用方需挂载管理员权限校验
+func (h *PreloadHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/warmup/preload", h.Status)
+	group.POST("/warmup/preload", h.Start)
+}
+
+// Status 最近一次预热的进度与门禁状态
+func (h *PreloadHandler) Status(c *gin.Context) {
+	c.JSON(http.StatusOK, h.preloader.Status())
+}
+
+// Start 执行 ?set= 指定的任务组（默认 default），立即返回 202；
+// ?wait=true 时等待预热结束或请求取消，返回 200
+func (h *PreloadHandler) Start(c *gin.Context) {
+	set := c.DefaultQuery("set", "default")
+	status, err := h.preloader.Start(set, c.GetString("userID"))
+	switch {
+	case errors.Is(err, ErrPreloadSetNotFound):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, err.Error()))
+		return
+	case errors.Is(err, ErrPreloadRunning):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, err.Error()))
+		return
+	case err != nil:
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeCache, ""))
+		return
+	}
+
+	if wait, _ := strconv.ParseBool(c.Query("wait")); wait {
+		c.JSON(http.StatusOK, h.preloader.Wait(c.Request.Context()))
+		return
+	}
+	c.JSON(http.StatusAccepted, status)
+}
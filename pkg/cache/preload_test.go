@@ -0,0 +1,66 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPreloader_Gate 就绪检查在任务组执行完之前失败，失败的任务不阻塞放行；未开始预热时超时后放行
+func TestPreloader_Gate(t *testing.T) {
+	store := fakes.NewCache(nil)
+	admin := cache.NewAdmin(store, nil, metrics.GetGlobalCollector(), nil)
+	release := make(chan struct{})
+	admin.RegisterWarmup("popular_keys", "popular keys", func(ctx context.Context) (*cache.WarmupResult, error) {
+		<-release
+		return &cache.WarmupResult{TotalKeys: 3, SuccessKeys: 3}, nil
+	})
+	admin.RegisterWarmup("coupons", "active coupons", func(ctx context.Context) (*cache.WarmupResult, error) {
+		return nil, errors.New("database unavailable")
+	})
+
+	clock := fakes.NewClock(time.Time{})
+	config := &cache.PreloadConfig{Sets: map[string][]string{"default": {"popular_keys", "coupons"}}, Timeout: time.Minute}
+	preloader := cache.NewPreloader(admin, config)
+	preloader.SetClock(clock)
+	ctx := context.Background()
+	require.NoError(t, preloader.Check(ctx), "the gate is open until armed")
+
+	preloader.Arm()
+	assert.EqualError(t, preloader.Check(ctx), "waiting for cache preload to start")
+	_, err := preloader.Start("canary", "ops")
+	assert.ErrorIs(t, err, cache.ErrPreloadSetNotFound)
+
+	status, err := preloader.Start("default", "ops")
+	require.NoError(t, err)
+	assert.Equal(t, cache.PreloadRunning, status.State)
+	assert.True(t, status.Gated)
+	_, err = preloader.Start("default", "ops")
+	assert.ErrorIs(t, err, cache.ErrPreloadRunning)
+	assert.EqualError(t, preloader.Check(ctx), "cache preload default running: 0/2 tasks finished")
+
+	close(release)
+	status = preloader.Wait(ctx)
+	assert.Equal(t, cache.PreloadDone, status.State)
+	assert.False(t, status.Gated)
+	require.Len(t, status.Tasks, 2)
+	assert.Equal(t, cache.PreloadTask{Name: "popular_keys", Keys: 3, Success: 3}, status.Tasks[0])
+	assert.Equal(t, "failed to run warmup coupons: database unavailable", status.Tasks[1].Error)
+	assert.NoError(t, preloader.Check(ctx))
+
+	stalled := cache.NewPreloader(admin, config)
+	stalled.SetClock(clock)
+	stalled.Arm()
+	clock.Advance(59 * time.Second)
+	assert.Error(t, stalled.Check(ctx))
+	clock.Advance(time.Second)
+	assert.NoError(t, stalled.Check(ctx))
+	assert.False(t, stalled.Status().Gated)
+}
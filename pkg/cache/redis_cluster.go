@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"user_crud_jwt/pkg/lifecycle"
 	"user_crud_jwt/pkg/metrics"
 
 	"github.com/go-redis/redis/v8"
@@ -19,28 +20,26 @@ type RedisCluster struct {
 	metricsCollector *metrics.MetricsCollector
 	config           *RedisClusterConfig
 	keyRouter        *KeyRouter
+	nodeObserver     *NodeObserver
 	healthChecker    *ClusterHealthChecker
+	lifecycle        *lifecycle.Component
+	stopTopology     context.CancelFunc
 }
 
 // RedisClusterConfig Redis 集群配置
 type RedisClusterConfig struct {
-	Nodes               []string      `json:"nodes"`
-	Password            string        `json:"password"`
-	MaxRetries          int           `json:"max_retries"`
-	PoolSize            int           `json:"pool_size"`
-	MinIdleConns        int           `json:"min_idle_conns"`
-	MaxIdleConns        int           `json:"max_idle_conns"`
-	ConnMaxLifetime     time.Duration `json:"conn_max_lifetime"`
-	ConnMaxIdleTime     time.Duration `json:"conn_max_idle_time"`
-	EnablePipeline      bool          `json:"enable_pipeline"`
-	EnableMetrics       bool          `json:"enable_metrics"`
-	HealthCheckInterval time.Duration `json:"health_check_interval"`
-}
-
-// KeyRouter 键路由器
-type KeyRouter struct {
-	clusterNodes []string
-	mu           sync.RWMutex
+	Nodes               []string         `json:"nodes"`
+	Password            string           `json:"password"`
+	MaxRetries          int              `json:"max_retries"`
+	PoolSize            int              `json:"pool_size"`
+	MinIdleConns        int              `json:"min_idle_conns"`
+	MaxIdleConns        int              `json:"max_idle_conns"`
+	ConnMaxLifetime     time.Duration    `json:"conn_max_lifetime"`
+	ConnMaxIdleTime     time.Duration    `json:"conn_max_idle_time"`
+	EnablePipeline      bool             `json:"enable_pipeline"`
+	EnableMetrics       bool             `json:"enable_metrics"`
+	HealthCheckInterval time.Duration    `json:"health_check_interval"`
+	Topology            *KeyRouterConfig `json:"topology"` // 为空时使用 DefaultKeyRouterConfig
 }
 
 // ClusterHealthChecker 集群健康检查器
@@ -53,12 +52,20 @@ type ClusterHealthChecker struct {
 
 // NewRedisCluster 创建 Redis 集群
 func NewRedisCluster(config *RedisClusterConfig, metricsCollector *metrics.MetricsCollector) (*RedisCluster, error) {
-	// 创建 Redis 集群客户端
-	rdb := redis.NewClusterClient(&redis.ClusterOptions{
+	// 创建 Redis 集群客户端，节点客户端上挂载钩子统计各节点的延迟，并把 MOVED/ASK 交给键路由器
+	var rdb *redis.ClusterClient
+	keyRouter := NewKeyRouter(config.Nodes, clusterSlotsLoader(func() *redis.ClusterClient { return rdb }), config.Topology, metricsCollector)
+	nodeObserver := NewNodeObserver(keyRouter, metricsCollector)
+	rdb = redis.NewClusterClient(&redis.ClusterOptions{
 		Addrs:      config.Nodes,
 		Password:   config.Password,
 		MaxRetries: config.MaxRetries,
 		PoolSize:   config.PoolSize,
+		NewClient: func(opt *redis.Options) *redis.Client {
+			client := redis.NewClient(opt)
+			client.AddHook(nodeObserver.Hook(opt.Addr))
+			return client
+		},
 	})
 
 	// 测试连接
@@ -66,6 +73,7 @@ func NewRedisCluster(config *RedisClusterConfig, metricsCollector *metrics.Metri
 	defer cancel()
 
 	if err := rdb.Ping(ctx).Err(); err != nil {
+		keyRouter.Close()
 		return nil, fmt.Errorf("failed to connect to Redis cluster: %w", err)
 	}
 
@@ -73,21 +81,24 @@ func NewRedisCluster(config *RedisClusterConfig, metricsCollector *metrics.Metri
 		cluster:          rdb,
 		metricsCollector: metricsCollector,
 		config:           config,
-		keyRouter:        NewKeyRouter(config.Nodes),
+		keyRouter:        keyRouter,
+		nodeObserver:     nodeObserver,
 		healthChecker:    NewClusterHealthChecker(rdb, config),
+		lifecycle:        lifecycle.Register("cache.redis_cluster"),
 	}
 
 	// 启动健康检查
 	go redisCluster.healthChecker.Start()
 
-	return redisCluster, nil
-}
-
-// NewKeyRouter 创建键路由器
-func NewKeyRouter(nodes []string) *KeyRouter {
-	return &KeyRouter{
-		clusterNodes: nodes,
+	// 启动拓扑刷新
+	topologyCtx, stopTopology := context.WithCancel(context.Background())
+	redisCluster.stopTopology = stopTopology
+	redisCluster.lifecycle.Go("topology-refresh", func() { keyRouter.Run(topologyCtx) })
+	if config.EnableMetrics && metricsCollector != nil && config.HealthCheckInterval > 0 {
+		redisCluster.lifecycle.Go("node-stats", func() { redisCluster.reportNodeStats(topologyCtx, config.HealthCheckInterval) })
 	}
+
+	return redisCluster, nil
 }
 
 // NewClusterHealthChecker 创建集群健康检查器
@@ -279,6 +290,142 @@ func (rc *RedisCluster) Decrement(ctx context.Context, key string) (int64, error
 	return result.Val(), nil
 }
 
+// ZAdd 写入有序集合成员，已存在的成员更新分数
+func (rc *RedisCluster) ZAdd(ctx context.Context, key string, members ...ScoredMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	start := time.Now()
+	zs := make([]*redis.Z, len(members))
+	for i, member := range members {
+		zs[i] = &redis.Z{Score: member.Score, Member: member.Member}
+	}
+
+	if err := rc.cluster.ZAdd(ctx, key, zs...).Err(); err != nil {
+		rc.recordMetrics("zadd_error", time.Since(start), false)
+		return fmt.Errorf("failed to zadd key %s: %w", key, err)
+	}
+	rc.recordMetrics("zadd", time.Since(start), true)
+	return nil
+}
+
+// ZIncrBy 累加有序集合成员的分数，返回累加后的分数
+func (rc *RedisCluster) ZIncrBy(ctx context.Context, key, member string, increment float64) (float64, error) {
+	start := time.Now()
+	score, err := rc.cluster.ZIncrBy(ctx, key, increment, member).Result()
+	if err != nil {
+		rc.recordMetrics("zincrby_error", time.Since(start), false)
+		return 0, fmt.Errorf("failed to zincrby key %s: %w", key, err)
+	}
+	rc.recordMetrics("zincrby", time.Since(start), true)
+	return score, nil
+}
+
+// ZRangeWithScores 按排名读取 [start, stop] 的成员，desc 时使用 ZREVRANGE
+func (rc *RedisCluster) ZRangeWithScores(ctx context.Context, key string, start, stop int64, desc bool) ([]ScoredMember, error) {
+	begin := time.Now()
+	var cmd *redis.ZSliceCmd
+	if desc {
+		cmd = rc.cluster.ZRevRangeWithScores(ctx, key, start, stop)
+	} else {
+		cmd = rc.cluster.ZRangeWithScores(ctx, key, start, stop)
+	}
+	zs, err := cmd.Result()
+	if err != nil {
+		rc.recordMetrics("zrange_error", time.Since(begin), false)
+		return nil, fmt.Errorf("failed to zrange key %s: %w", key, err)
+	}
+	rc.recordMetrics("zrange", time.Since(begin), true)
+	return scoredMembers(zs, func(z redis.Z) (interface{}, float64) { return z.Member, z.Score }), nil
+}
+
+// ZRank 有序集合成员的排名（从 0 开始），desc 时使用 ZREVRANK，成员不存在时返回 ErrNotRanked
+func (rc *RedisCluster) ZRank(ctx context.Context, key, member string, desc bool) (int64, error) {
+	start := time.Now()
+	var cmd *redis.IntCmd
+	if desc {
+		cmd = rc.cluster.ZRevRank(ctx, key, member)
+	} else {
+		cmd = rc.cluster.ZRank(ctx, key, member)
+	}
+	rank, err := cmd.Result()
+	if err == redis.Nil {
+		rc.recordMetrics("zrank", time.Since(start), true)
+		return 0, ErrNotRanked
+	}
+	if err != nil {
+		rc.recordMetrics("zrank_error", time.Since(start), false)
+		return 0, fmt.Errorf("failed to zrank key %s: %w", key, err)
+	}
+	rc.recordMetrics("zrank", time.Since(start), true)
+	return rank, nil
+}
+
+// GeoAdd 写入成员的坐标，实现 GeoStore
+func (rc *RedisCluster) GeoAdd(ctx context.Context, key string, locations ...GeoLocation) error {
+	if len(locations) == 0 {
+		return nil
+	}
+	start := time.Now()
+	geo := make([]*redis.GeoLocation, len(locations))
+	for i, location := range locations {
+		geo[i] = &redis.GeoLocation{Name: location.Member, Latitude: location.Latitude, Longitude: location.Longitude}
+	}
+
+	if err := rc.cluster.GeoAdd(ctx, key, geo...).Err(); err != nil {
+		rc.recordMetrics("geoadd_error", time.Since(start), false)
+		return fmt.Errorf("failed to geoadd key %s: %w", key, err)
+	}
+	rc.recordMetrics("geoadd", time.Since(start), true)
+	return nil
+}
+
+// GeoSearch 按半径查询附近的成员，由近到远，实现 GeoStore
+func (rc *RedisCluster) GeoSearch(ctx context.Context, key string, query GeoQuery) ([]GeoResult, error) {
+	start := time.Now()
+	locations, err := rc.cluster.GeoSearchLocation(ctx, key, &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Longitude:  query.Longitude,
+			Latitude:   query.Latitude,
+			Radius:     query.RadiusMeters,
+			RadiusUnit: "m",
+			Sort:       "ASC",
+			Count:      query.Count,
+		},
+		WithCoord: true,
+		WithDist:  true,
+	}).Result()
+	if err != nil {
+		rc.recordMetrics("geosearch_error", time.Since(start), false)
+		return nil, fmt.Errorf("failed to geosearch key %s: %w", key, err)
+	}
+	rc.recordMetrics("geosearch", time.Since(start), true)
+
+	results := make([]GeoResult, len(locations))
+	for i, location := range locations {
+		results[i] = GeoResult{
+			GeoLocation:    GeoLocation{Member: location.Name, Latitude: location.Latitude, Longitude: location.Longitude},
+			DistanceMeters: location.Dist,
+		}
+	}
+	return results, nil
+}
+
+// GeoRemove 删除成员的坐标，实现 GeoStore
+func (rc *RedisCluster) GeoRemove(ctx context.Context, key string, members ...string) error {
+	start := time.Now()
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	if err := rc.cluster.ZRem(ctx, key, args...).Err(); err != nil {
+		rc.recordMetrics("georemove_error", time.Since(start), false)
+		return fmt.Errorf("failed to georemove key %s: %w", key, err)
+	}
+	rc.recordMetrics("georemove", time.Since(start), true)
+	return nil
+}
+
 // SetJSON 设置 JSON 值
 func (rc *RedisCluster) SetJSON(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	jsonData, err := json.Marshal(value)
@@ -303,7 +450,7 @@ func (rc *RedisCluster) GetJSON(ctx context.Context, key string, dest interface{
 	return json.Unmarshal([]byte(value), dest)
 }
 
-// MGet 批量获取
+// MGet 批量获取，按槽位拆分以避免 CROSSSLOT 错误，结果顺序与 keys 一致
 func (rc *RedisCluster) MGet(ctx context.Context, keys ...string) ([]interface{}, error) {
 	start := time.Now()
 	defer func() {
@@ -311,16 +458,27 @@ func (rc *RedisCluster) MGet(ctx context.Context, keys ...string) ([]interface{}
 		rc.recordMetrics("mget", duration, true)
 	}()
 
-	result := rc.cluster.MGet(ctx, keys...)
-	if result.Err() != nil {
+	batch, err := rc.GetMany(ctx, keys)
+	if err != nil {
 		rc.recordMetrics("mget_error", time.Since(start), false)
-		return nil, fmt.Errorf("failed to MGet keys: %w", result.Err())
+		return nil, fmt.Errorf("failed to MGet keys: %w", err)
 	}
 
-	return result.Val(), nil
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if err, exists := batch.Errors[key]; exists {
+			rc.recordMetrics("mget_error", time.Since(start), false)
+			return nil, fmt.Errorf("failed to MGet keys: %w", err)
+		}
+		if data, exists := batch.Values[key]; exists {
+			values[i] = string(data)
+		}
+	}
+
+	return values, nil
 }
 
-// MSet 批量设置
+// MSet 批量设置，pairs 为交替的键和值；按节点、槽位拆分为多条 MSET，避免 CROSSSLOT 错误
 func (rc *RedisCluster) MSet(ctx context.Context, pairs ...interface{}) error {
 	start := time.Now()
 	defer func() {
@@ -328,10 +486,35 @@ func (rc *RedisCluster) MSet(ctx context.Context, pairs ...interface{}) error {
 		rc.recordMetrics("mset", duration, true)
 	}()
 
-	result := rc.cluster.MSet(ctx, pairs...)
-	if result.Err() != nil {
+	if len(pairs)%2 != 0 {
+		return fmt.Errorf("failed to MSet: odd number of arguments")
+	}
+	keys := make([]string, 0, len(pairs)/2)
+	values := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		if _, exists := values[key]; !exists {
+			keys = append(keys, key)
+		}
+		values[key] = pairs[i+1]
+	}
+
+	var err error
+	rc.forEachNode(ctx, keys, func(pipe redis.Pipeliner, slotKeys []string) func() {
+		args := make([]interface{}, 0, len(slotKeys)*2)
+		for _, key := range slotKeys {
+			args = append(args, key, values[key])
+		}
+		cmd := pipe.MSet(ctx, args...)
+		return func() {
+			if err == nil {
+				err = cmd.Err()
+			}
+		}
+	})
+	if err != nil {
 		rc.recordMetrics("mset_error", time.Since(start), false)
-		return fmt.Errorf("failed to MSet: %w", result.Err())
+		return fmt.Errorf("failed to MSet: %w", err)
 	}
 
 	return nil
@@ -386,11 +569,11 @@ func (rc *RedisCluster) GetStats(ctx context.Context) (map[string]interface{}, e
 	}
 	stats["cluster"] = clusterInfo
 
-	// 获取每个节点的信息
-	nodeStats := make([]map[string]interface{}, 0)
-	for _, node := range rc.config.Nodes {
-		nodeInfo := rc.getNodeInfo(ctx, node)
-		nodeStats = append(nodeStats, nodeInfo)
+	// 获取每个节点的连接池与延迟统计
+	stats["topology"] = rc.keyRouter.Stats()
+	nodeStats, err := rc.NodeStats(ctx)
+	if err != nil {
+		return nil, err
 	}
 	stats["nodes"] = nodeStats
 
@@ -400,30 +583,20 @@ func (rc *RedisCluster) GetStats(ctx context.Context) (map[string]interface{}, e
 	return stats, nil
 }
 
-// getNodeInfo 获取节点信息
-func (rc *RedisCluster) getNodeInfo(ctx context.Context, node string) map[string]interface{} {
-	info := map[string]interface{}{
-		"node":   node,
-		"status": "unknown",
-	}
-
-	// 简化的节点信息获取
-	// 实际项目中应该连接到具体节点获取详细信息
-	return info
-}
-
-// getPoolStats 获取连接池统计
+// getPoolStats 获取所有主节点连接池的汇总统计
 func (rc *RedisCluster) getPoolStats() map[string]interface{} {
-	stats := make(map[string]interface{})
-
-	// Redis 集群客户端的连接池统计
-	// 这里简化实现，实际项目中应该获取详细的连接池信息
-
-	stats["pool_size"] = rc.config.PoolSize
-	stats["max_idle_conns"] = rc.config.MaxIdleConns
-	stats["min_idle_conns"] = rc.config.MinIdleConns
-
-	return stats
+	pool := rc.cluster.PoolStats()
+	return map[string]interface{}{
+		"pool_size":      rc.config.PoolSize,
+		"max_idle_conns": rc.config.MaxIdleConns,
+		"min_idle_conns": rc.config.MinIdleConns,
+		"hits":           pool.Hits,
+		"misses":         pool.Misses,
+		"timeouts":       pool.Timeouts,
+		"total_conns":    pool.TotalConns,
+		"idle_conns":     pool.IdleConns,
+		"stale_conns":    pool.StaleConns,
+	}
 }
 
 // recordMetrics 记录指标
@@ -441,10 +614,25 @@ func (rc *RedisCluster) recordMetrics(operation string, duration time.Duration,
 	}
 }
 
+// Health 检查集群健康状态
+func (rc *RedisCluster) Health(ctx context.Context) error {
+	info, err := rc.cluster.ClusterInfo(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get cluster info: %w", err)
+	}
+	if !strings.Contains(info, "cluster_state:ok") {
+		return fmt.Errorf("cluster state is not ok")
+	}
+	return nil
+}
+
 // Close 关闭连接
 func (rc *RedisCluster) Close() error {
-	// 停止健康检查
+	// 停止健康检查与拓扑刷新
 	rc.healthChecker.Stop()
+	rc.stopTopology()
+	rc.lifecycle.Closed()
+	rc.keyRouter.Close()
 
 	// 关闭集群连接
 	return rc.cluster.Close()
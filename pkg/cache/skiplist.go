@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// skipListMaxLevel 跳表的最大层数，与 Redis 的 ZSKIPLIST_MAXLEVEL 相同
+	skipListMaxLevel = 32
+	// skipListP 节点升一层的概率
+	skipListP = 0.25
+)
+
+// skipListNode 跳表节点，levels[i].span 为第 i 层到下一节点跨过的节点数，用于按排名定位
+type skipListNode struct {
+	member   string
+	score    float64
+	backward *skipListNode
+	levels   []skipListLevel
+}
+
+type skipListLevel struct {
+	forward *skipListNode
+	span    int
+}
+
+// skipList 按分数升序、分数相同时按成员字典序排列的跳表，与 Redis 有序集合的顺序一致
+type skipList struct {
+	header *skipListNode
+	tail   *skipListNode
+	length int
+	level  int
+	rand   *rand.Rand
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		header: &skipListNode{levels: make([]skipListLevel, skipListMaxLevel)},
+		level:  1,
+		rand:   rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// before 节点 n 是否排在 (score, member) 之前
+func (n *skipListNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+func (l *skipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && l.rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// insert 插入成员，调用方保证成员不在跳表中
+func (l *skipList) insert(member string, score float64) {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := l.randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = l.header
+			update[i].levels[i].span = l.length
+		}
+		l.level = level
+	}
+
+	node := &skipListNode{member: member, score: score, levels: make([]skipListLevel, level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != l.header {
+		node.backward = update[0]
+	}
+	if node.levels[0].forward != nil {
+		node.levels[0].forward.backward = node
+	} else {
+		l.tail = node
+	}
+	l.length++
+}
+
+// remove 删除成员，score 须为成员当前的分数
+func (l *skipList) remove(member string, score float64) bool {
+	var update [skipListMaxLevel]*skipListNode
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x.backward
+	} else {
+		l.tail = x.backward
+	}
+	for l.level > 1 && l.header.levels[l.level-1].forward == nil {
+		l.level--
+	}
+	l.length--
+	return true
+}
+
+// rank 成员的升序排名（从 0 开始），不存在时返回 -1
+func (l *skipList) rank(member string, score float64) int {
+	rank := 0
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !(score < x.levels[i].forward.score ||
+			(score == x.levels[i].forward.score && member < x.levels[i].forward.member)) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != l.header && x.member == member {
+			return rank - 1
+		}
+	}
+	return -1
+}
+
+// byRank 升序排名为 rank（从 0 开始）的节点，越界时返回 nil
+func (l *skipList) byRank(rank int) *skipListNode {
+	if rank < 0 || rank >= l.length {
+		return nil
+	}
+	traversed := 0
+	x := l.header
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// sortedSet 一个有序集合：成员到分数的映射用于查分数，跳表用于排名与范围查询
+type sortedSet struct {
+	scores map[string]float64
+	list   *skipList
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{scores: make(map[string]float64), list: newSkipList()}
+}
+
+func (s *sortedSet) add(member string, score float64) {
+	if current, ok := s.scores[member]; ok {
+		if current == score {
+			return
+		}
+		s.list.remove(member, current)
+	}
+	s.scores[member] = score
+	s.list.insert(member, score)
+}
+
+// rangeByRank 与 ZRANGE 相同，负数下标从末尾计数，越界部分截断；desc 时按分数降序
+func (s *sortedSet) rangeByRank(start, stop int64, desc bool) []ScoredMember {
+	length := int64(s.list.length)
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return []ScoredMember{}
+	}
+
+	members := make([]ScoredMember, 0, stop-start+1)
+	if desc {
+		for x := s.list.byRank(int(length - 1 - start)); x != nil && int64(len(members)) <= stop-start; x = x.backward {
+			members = append(members, ScoredMember{Member: x.member, Score: x.score})
+		}
+		return members
+	}
+	for x := s.list.byRank(int(start)); x != nil && int64(len(members)) <= stop-start; x = x.levels[0].forward {
+		members = append(members, ScoredMember{Member: x.member, Score: x.score})
+	}
+	return members
+}
+
+// SortedSets 内存中的有序集合，以跳表实现 CacheService 的 ZAdd、ZIncrBy、ZRangeWithScores 与 ZRank，
+// 供 MemoryCache 与测试替身嵌入。零值可用，并发安全；有序集合不过期，由 Remove 或 RemoveMatching 删除
+type SortedSets struct {
+	mu   sync.RWMutex
+	sets map[string]*sortedSet
+}
+
+// ZAdd 实现 CacheService，已存在的成员更新分数
+func (s *SortedSets) ZAdd(_ context.Context, key string, members ...ScoredMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.setLocked(key)
+	for _, member := range members {
+		set.add(member.Member, member.Score)
+	}
+	return nil
+}
+
+// ZIncrBy 实现 CacheService，成员不存在时从 0 开始累加
+func (s *SortedSets) ZIncrBy(_ context.Context, key, member string, increment float64) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := s.setLocked(key)
+	score := set.scores[member] + increment
+	set.add(member, score)
+	return score, nil
+}
+
+// ZRangeWithScores 实现 CacheService
+func (s *SortedSets) ZRangeWithScores(_ context.Context, key string, start, stop int64, desc bool) ([]ScoredMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set, ok := s.sets[key]
+	if !ok {
+		return []ScoredMember{}, nil
+	}
+	return set.rangeByRank(start, stop, desc), nil
+}
+
+// ZRank 实现 CacheService
+func (s *SortedSets) ZRank(_ context.Context, key, member string, desc bool) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set, ok := s.sets[key]
+	if !ok {
+		return 0, ErrNotRanked
+	}
+	score, ok := set.scores[member]
+	if !ok {
+		return 0, ErrNotRanked
+	}
+	rank := int64(set.list.rank(member, score))
+	if desc {
+		rank = int64(set.list.length) - 1 - rank
+	}
+	return rank, nil
+}
+
+// Has 是否存在名为 key 的有序集合
+func (s *SortedSets) Has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.sets[key]
+	return ok
+}
+
+// Keys 所有有序集合的键，顺序不定
+func (s *SortedSets) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.sets))
+	for key := range s.sets {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Remove 删除有序集合
+func (s *SortedSets) Remove(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sets, key)
+}
+
+// RemoveMatching 删除键匹配 glob 模式的有序集合
+func (s *SortedSets) RemoveMatching(pattern string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.sets {
+		if matched, _ := filepath.Match(pattern, key); matched {
+			delete(s.sets, key)
+		}
+	}
+}
+
+func (s *SortedSets) setLocked(key string) *sortedSet {
+	if s.sets == nil {
+		s.sets = make(map[string]*sortedSet)
+	}
+	set, ok := s.sets[key]
+	if !ok {
+		set = newSortedSet()
+		s.sets[key] = set
+	}
+	return set
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotRanked ZRank 查询的成员或有序集合不存在
+var ErrNotRanked = errors.New("member is not ranked")
+
+// DefaultRankingPageSize 排行榜分页未指定每页数量时的默认值
+const DefaultRankingPageSize = 20
+
+// ScoredMember 有序集合中的成员及其分数
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// RankedMember 排行榜中的一项，Rank 为名次，从 1 开始
+type RankedMember struct {
+	Rank   int64   `json:"rank"`
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// RankingPage 排行榜的一页，HasMore 表示之后还有成员
+type RankingPage struct {
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+	Entries  []RankedMember `json:"entries"`
+	HasMore  bool           `json:"has_more"`
+}
+
+// PageRange 第 page 页（从 1 开始）对应的 ZRANGE 下标，page、pageSize 不合法时按第 1 页、默认每页数量计算
+func PageRange(page, pageSize int) (start, stop int64) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultRankingPageSize
+	}
+	start = int64(page-1) * int64(pageSize)
+	return start, start + int64(pageSize) - 1
+}
+
+// GetRankingPage 读取排行榜的第 page 页，desc 为 true 时分数高的在前。多读一个成员判断是否还有下一页，
+// 不需要 ZCARD；有序集合不存在时返回空页
+func GetRankingPage(ctx context.Context, c CacheService, key string, page, pageSize int, desc bool) (*RankingPage, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultRankingPageSize
+	}
+	start, stop := PageRange(page, pageSize)
+	members, err := c.ZRangeWithScores(ctx, key, start, stop+1, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RankingPage{Page: page, PageSize: pageSize, Entries: make([]RankedMember, 0, pageSize)}
+	if len(members) > pageSize {
+		result.HasMore = true
+		members = members[:pageSize]
+	}
+	for i, member := range members {
+		result.Entries = append(result.Entries, RankedMember{Rank: start + int64(i) + 1, Member: member.Member, Score: member.Score})
+	}
+	return result, nil
+}
+
+// GetRankingPageOf 读取 member 所在的一页，用于“我的排名”附近的榜单；成员不在榜上时返回 ErrNotRanked
+func GetRankingPageOf(ctx context.Context, c CacheService, key, member string, pageSize int, desc bool) (*RankingPage, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultRankingPageSize
+	}
+	rank, err := c.ZRank(ctx, key, member, desc)
+	if err != nil {
+		return nil, err
+	}
+	return GetRankingPage(ctx, c, key, int(rank)/pageSize+1, pageSize, desc)
+}
+
+// ZAdd 写入成员，已存在的成员更新分数
+func (c *RedisCache) ZAdd(ctx context.Context, key string, members ...ScoredMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	zs := make([]redis.Z, len(members))
+	for i, member := range members {
+		zs[i] = redis.Z{Score: member.Score, Member: member.Member}
+	}
+	if err := c.client.ZAdd(ctx, c.getKey(key), zs...).Err(); err != nil {
+		return fmt.Errorf("cache zadd error: %w", err)
+	}
+	return nil
+}
+
+// ZIncrBy 累加成员的分数，返回累加后的分数
+func (c *RedisCache) ZIncrBy(ctx context.Context, key, member string, increment float64) (float64, error) {
+	score, err := c.client.ZIncrBy(ctx, c.getKey(key), increment, member).Result()
+	if err != nil {
+		return 0, fmt.Errorf("cache zincrby error: %w", err)
+	}
+	return score, nil
+}
+
+// ZRangeWithScores 按排名读取 [start, stop] 的成员，desc 时使用 ZREVRANGE
+func (c *RedisCache) ZRangeWithScores(ctx context.Context, key string, start, stop int64, desc bool) ([]ScoredMember, error) {
+	var cmd *redis.ZSliceCmd
+	if desc {
+		cmd = c.client.ZRevRangeWithScores(ctx, c.getKey(key), start, stop)
+	} else {
+		cmd = c.client.ZRangeWithScores(ctx, c.getKey(key), start, stop)
+	}
+	zs, err := cmd.Result()
+	if err != nil {
+		return nil, fmt.Errorf("cache zrange error: %w", err)
+	}
+	return scoredMembers(zs, func(z redis.Z) (interface{}, float64) { return z.Member, z.Score }), nil
+}
+
+// ZRank 成员的排名（从 0 开始），desc 时使用 ZREVRANK
+func (c *RedisCache) ZRank(ctx context.Context, key, member string, desc bool) (int64, error) {
+	var cmd *redis.IntCmd
+	if desc {
+		cmd = c.client.ZRevRank(ctx, c.getKey(key), member)
+	} else {
+		cmd = c.client.ZRank(ctx, c.getKey(key), member)
+	}
+	rank, err := cmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrNotRanked
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cache zrank error: %w", err)
+	}
+	return rank, nil
+}
+
+// scoredMembers 转换 go-redis v8、v9 的 Z，成员以字符串写入，读出时同为字符串
+func scoredMembers[Z any](zs []Z, fields func(Z) (interface{}, float64)) []ScoredMember {
+	members := make([]ScoredMember, len(zs))
+	for i, z := range zs {
+		member, score := fields(z)
+		members[i] = ScoredMember{Member: fmt.Sprint(member), Score: score}
+	}
+	return members
+}
@@ -0,0 +1,131 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryCache_SortedSet 排名与范围与 Redis 一致：分数相同按成员字典序，负数下标从末尾计数，降序为升序的逆序
+func TestMemoryCache_SortedSet(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache()
+	require.NoError(t, c.ZAdd(ctx, "rank", cache.ScoredMember{Member: "b", Score: 2}, cache.ScoredMember{Member: "a", Score: 2},
+		cache.ScoredMember{Member: "c", Score: 1}, cache.ScoredMember{Member: "d", Score: 5}))
+	score, err := c.ZIncrBy(ctx, "rank", "c", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, score)
+	score, err = c.ZIncrBy(ctx, "rank", "e", 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, score)
+
+	all, err := c.ZRangeWithScores(ctx, "rank", 0, -1, false)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ScoredMember{{"e", 0.5}, {"a", 2}, {"b", 2}, {"c", 4}, {"d", 5}}, all)
+	top, err := c.ZRangeWithScores(ctx, "rank", 0, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ScoredMember{{"d", 5}, {"c", 4}, {"b", 2}}, top)
+	tail, err := c.ZRangeWithScores(ctx, "rank", -2, 10, false)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.ScoredMember{{"c", 4}, {"d", 5}}, tail)
+	empty, err := c.ZRangeWithScores(ctx, "missing", 0, -1, false)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	rank, err := c.ZRank(ctx, "rank", "a", false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rank)
+	rank, err = c.ZRank(ctx, "rank", "a", true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), rank)
+	_, err = c.ZRank(ctx, "rank", "z", true)
+	assert.ErrorIs(t, err, cache.ErrNotRanked)
+
+	exists, err := c.Exists(ctx, "rank")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, c.Delete(ctx, "rank"))
+	_, err = c.ZRank(ctx, "rank", "a", false)
+	assert.ErrorIs(t, err, cache.ErrNotRanked)
+}
+
+// TestMemoryCache_SortedSetMatchesSort 随机写入与更新分数后，跳表的排名与范围和排序结果一致
+func TestMemoryCache_SortedSetMatchesSort(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache()
+	rng := rand.New(rand.NewSource(1))
+	scores := make(map[string]float64)
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m%03d", rng.Intn(300))
+		increment := float64(rng.Intn(20) - 5)
+		_, err := c.ZIncrBy(ctx, "board", member, increment)
+		require.NoError(t, err)
+		scores[member] += increment
+	}
+
+	expected := make([]cache.ScoredMember, 0, len(scores))
+	for member, score := range scores {
+		expected = append(expected, cache.ScoredMember{Member: member, Score: score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score < expected[j].Score
+		}
+		return expected[i].Member < expected[j].Member
+	})
+
+	all, err := c.ZRangeWithScores(ctx, "board", 0, -1, false)
+	require.NoError(t, err)
+	assert.Equal(t, expected, all)
+	for i, member := range expected {
+		rank, err := c.ZRank(ctx, "board", member.Member, false)
+		require.NoError(t, err)
+		require.Equal(t, int64(i), rank, member.Member)
+		rank, err = c.ZRank(ctx, "board", member.Member, true)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(expected)-1-i), rank, member.Member)
+	}
+	window, err := c.ZRangeWithScores(ctx, "board", 10, 19, true)
+	require.NoError(t, err)
+	for i, member := range window {
+		assert.Equal(t, expected[len(expected)-11-i], member)
+	}
+}
+
+// TestGetRankingPage 名次从 1 开始，多读一个成员判断是否还有下一页，GetRankingPageOf 返回成员所在的页
+func TestGetRankingPage(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemoryCache()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, c.ZAdd(ctx, "sale:1", cache.ScoredMember{Member: fmt.Sprintf("user:%d", i), Score: float64(i * 10)}))
+	}
+
+	first, err := cache.GetRankingPage(ctx, c, "sale:1", 1, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.RankedMember{{1, "user:5", 50}, {2, "user:4", 40}}, first.Entries)
+	assert.True(t, first.HasMore)
+	last, err := cache.GetRankingPage(ctx, c, "sale:1", 3, 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.RankedMember{{5, "user:1", 10}}, last.Entries)
+	assert.False(t, last.HasMore)
+	beyond, err := cache.GetRankingPage(ctx, c, "sale:1", 4, 2, true)
+	require.NoError(t, err)
+	assert.Empty(t, beyond.Entries)
+
+	page, err := cache.GetRankingPageOf(ctx, c, "sale:1", "user:3", 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Page)
+	assert.Equal(t, []cache.RankedMember{{3, "user:3", 30}, {4, "user:2", 20}}, page.Entries)
+	_, err = cache.GetRankingPageOf(ctx, c, "sale:1", "user:9", 2, true)
+	assert.ErrorIs(t, err, cache.ErrNotRanked)
+
+	start, stop := cache.PageRange(0, 0)
+	assert.Equal(t, int64(0), start)
+	assert.Equal(t, int64(cache.DefaultRankingPageSize-1), stop)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tagVersionTTL 标签版本号的有效期，需远大于依赖标签的缓存条目的有效期。
+// 版本号过期后回到 0，此前以 0 版本写入的条目早已过期，不会被误用
+const tagVersionTTL = 7 * 24 * time.Hour
+
+// TagInvalidator 按标签失效缓存，标签通常对应实体，如 "user:<id>"
+type TagInvalidator interface {
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// TagInvalidationStrategy 标签失效策略。
+// 每个标签维护一个版本号，缓存条目的键包含其依赖标签的版本号，
+// 失效时只需更新版本号，旧条目因键不再被访问而自然过期，无需扫描或逐个删除
+type TagInvalidationStrategy struct {
+	cache CacheService
+
+	mu        sync.RWMutex
+	listeners []func(ctx context.Context, tags []string)
+}
+
+// NewTagInvalidationStrategy 创建标签失效策略，版本号应存放在各实例共享的缓存中
+func NewTagInvalidationStrategy(cache CacheService) *TagInvalidationStrategy {
+	return &TagInvalidationStrategy{cache: cache}
+}
+
+// OnInvalidate 注册标签失效成功后的回调，用于审计与事件推送；回调在失效的调用方中同步执行，不应阻塞
+func (tis *TagInvalidationStrategy) OnInvalidate(listener func(ctx context.Context, tags []string)) {
+	tis.mu.Lock()
+	defer tis.mu.Unlock()
+	tis.listeners = append(tis.listeners, listener)
+}
+
+// Invalidate 实现 InvalidationStrategy，keys 为标签名
+func (tis *TagInvalidationStrategy) Invalidate(ctx context.Context, keys []string) error {
+	return tis.InvalidateTags(ctx, keys...)
+}
+
+// InvalidateTags 更新标签版本号，依赖这些标签的缓存条目随之失效
+func (tis *TagInvalidationStrategy) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	version := time.Now().UnixNano()
+	entries := make([]CacheEntry, 0, len(tags))
+	for _, tag := range tags {
+		entries = append(entries, CacheEntry{Key: tagVersionKey(tag), Value: version, Expiration: tagVersionTTL})
+	}
+
+	result, err := tis.cache.SetMany(ctx, entries)
+	if err != nil {
+		return fmt.Errorf("failed to update tag versions: %w", err)
+	}
+	for key, err := range result.Errors {
+		return fmt.Errorf("failed to update tag version %s: %w", key, err)
+	}
+
+	tis.mu.RLock()
+	defer tis.mu.RUnlock()
+	for _, listener := range tis.listeners {
+		listener(ctx, tags)
+	}
+	return nil
+}
+
+// Versions 读取标签当前的版本号，从未失效过的标签版本号为 0
+func (tis *TagInvalidationStrategy) Versions(ctx context.Context, tags []string) (map[string]int64, error) {
+	versions := make(map[string]int64, len(tags))
+	if len(tags) == 0 {
+		return versions, nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, tagVersionKey(tag))
+	}
+
+	result, err := tis.cache.GetMany(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag versions: %w", err)
+	}
+	for _, tag := range tags {
+		key := tagVersionKey(tag)
+		if !result.Hit(key) {
+			if err, exists := result.Errors[key]; exists {
+				return nil, fmt.Errorf("failed to get tag version %s: %w", tag, err)
+			}
+			versions[tag] = 0
+			continue
+		}
+		var version int64
+		if err := result.Decode(key, &version); err != nil {
+			return nil, fmt.Errorf("failed to decode tag version %s: %w", tag, err)
+		}
+		versions[tag] = version
+	}
+	return versions, nil
+}
+
+func (tis *TagInvalidationStrategy) GetName() string {
+	return "tag"
+}
+
+func (tis *TagInvalidationStrategy) GetPriority() int {
+	return 75
+}
+
+func tagVersionKey(tag string) string {
+	return fmt.Sprintf("tag:%s:version", tag)
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"user_crud_jwt/pkg/ctxutil"
+)
+
+// TenantKey 为缓存键加上 ctx 中租户的前缀，没有租户时原样返回
+func TenantKey(ctx context.Context, key string) string {
+	if tenantID := ctxutil.TenantID(ctx); tenantID != "" {
+		return "tenant:" + tenantID + ":" + key
+	}
+	return key
+}
+
+// tenantCache 按租户隔离缓存键，不同租户的同名键互不可见
+type tenantCache struct {
+	inner CacheService
+}
+
+// NewTenantCache 为缓存挂载租户前缀，适用于缓存内容随租户不同的场景
+func NewTenantCache(inner CacheService) CacheService {
+	return &tenantCache{inner: inner}
+}
+
+func (c *tenantCache) Get(ctx context.Context, key string, dest interface{}) error {
+	return c.inner.Get(ctx, TenantKey(ctx, key), dest)
+}
+
+func (c *tenantCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.inner.Set(ctx, TenantKey(ctx, key), value, expiration)
+}
+
+func (c *tenantCache) Delete(ctx context.Context, key string) error {
+	return c.inner.Delete(ctx, TenantKey(ctx, key))
+}
+
+func (c *tenantCache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.inner.Exists(ctx, TenantKey(ctx, key))
+}
+
+func (c *tenantCache) GetWithTTL(ctx context.Context, key string, dest interface{}) (time.Duration, error) {
+	return c.inner.GetWithTTL(ctx, TenantKey(ctx, key), dest)
+}
+
+func (c *tenantCache) SetWithTTL(ctx context.Context, key string, value interface{}) error {
+	return c.inner.SetWithTTL(ctx, TenantKey(ctx, key), value)
+}
+
+func (c *tenantCache) InvalidatePattern(ctx context.Context, pattern string) error {
+	return c.inner.InvalidatePattern(ctx, TenantKey(ctx, pattern))
+}
+
+func (c *tenantCache) GetMultiple(ctx context.Context, keys []string, dest interface{}) error {
+	return c.inner.GetMultiple(ctx, c.tenantKeys(ctx, keys), dest)
+}
+
+// GetMany 结果中的键还原为调用方传入的键
+func (c *tenantCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	prefixed := c.tenantKeys(ctx, keys)
+	result, err := c.inner.GetMany(ctx, prefixed)
+	if err != nil || result == nil {
+		return result, err
+	}
+
+	original := make(map[string]string, len(keys))
+	for i, key := range keys {
+		original[prefixed[i]] = key
+	}
+	restored := &BatchGetResult{
+		Values: make(map[string]json.RawMessage, len(result.Values)),
+		Errors: make(map[string]error, len(result.Errors)),
+	}
+	for key, value := range result.Values {
+		restored.Values[original[key]] = value
+	}
+	for _, key := range result.Missing {
+		restored.Missing = append(restored.Missing, original[key])
+	}
+	for key, err := range result.Errors {
+		restored.Errors[original[key]] = err
+	}
+	return restored, nil
+}
+
+// SetMany 结果中的键还原为调用方传入的键
+func (c *tenantCache) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	prefixed := make([]CacheEntry, len(entries))
+	original := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		prefixed[i] = entry
+		prefixed[i].Key = TenantKey(ctx, entry.Key)
+		original[prefixed[i].Key] = entry.Key
+	}
+	result, err := c.inner.SetMany(ctx, prefixed)
+	if err != nil || result == nil {
+		return result, err
+	}
+
+	restored := &BatchSetResult{Errors: make(map[string]error, len(result.Errors))}
+	for _, key := range result.Succeeded {
+		restored.Succeeded = append(restored.Succeeded, original[key])
+	}
+	for key, err := range result.Errors {
+		restored.Errors[original[key]] = err
+	}
+	return restored, nil
+}
+
+func (c *tenantCache) ZAdd(ctx context.Context, key string, members ...ScoredMember) error {
+	return c.inner.ZAdd(ctx, TenantKey(ctx, key), members...)
+}
+
+func (c *tenantCache) ZIncrBy(ctx context.Context, key, member string, increment float64) (float64, error) {
+	return c.inner.ZIncrBy(ctx, TenantKey(ctx, key), member, increment)
+}
+
+func (c *tenantCache) ZRangeWithScores(ctx context.Context, key string, start, stop int64, desc bool) ([]ScoredMember, error) {
+	return c.inner.ZRangeWithScores(ctx, TenantKey(ctx, key), start, stop, desc)
+}
+
+func (c *tenantCache) ZRank(ctx context.Context, key, member string, desc bool) (int64, error) {
+	return c.inner.ZRank(ctx, TenantKey(ctx, key), member, desc)
+}
+
+func (c *tenantCache) tenantKeys(ctx context.Context, keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = TenantKey(ctx, key)
+	}
+	return prefixed
+}
@@ -0,0 +1,330 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// ErrTTLProfileNotFound 该命名空间没有运行时调整的 TTL 配置
+var ErrTTLProfileNotFound = errors.New("ttl profile not found")
+
+const (
+	// ttlProfilesKey 运行时调整的 TTL 配置，各实例共享
+	ttlProfilesKey = "cache:ttl_profiles"
+	// defaultTTLNamespace 没有匹配的 TTL 配置时指标中的命名空间
+	defaultTTLNamespace = "default"
+
+	// TTL 配置的来源
+	TTLSourceConfig  = "config"
+	TTLSourceRuntime = "runtime"
+)
+
+// TTLProfile 键命名空间的缓存有效期。命名空间按冒号分段匹配键的前缀，如 "rbac" 匹配 "rbac:user:1:g2:roles"，
+// 多个命名空间匹配时最长的优先；TTL（Redis 等远端缓存）与 LocalTTL（多级缓存的本地缓存）为 0 时使用调用方的默认值
+type TTLProfile struct {
+	Namespace string        `json:"namespace"`
+	TTL       time.Duration `json:"ttl"`
+	LocalTTL  time.Duration `json:"local_ttl"`
+	Source    string        `json:"source"` // config 来自配置文件，runtime 由管理接口调整，覆盖同名的 config
+	UpdatedBy string        `json:"updated_by,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at,omitempty"`
+}
+
+// validate 命名空间不能为空或包含通配符，有效期不能为负
+func (p *TTLProfile) validate() error {
+	p.Namespace = strings.TrimSuffix(p.Namespace, ":")
+	if p.Namespace == "" || strings.ContainsAny(p.Namespace, "*?[]\\") {
+		return ErrInvalidNamespace
+	}
+	if p.TTL < 0 || p.LocalTTL < 0 {
+		return fmt.Errorf("ttl of %s must not be negative", p.Namespace)
+	}
+	return nil
+}
+
+// MergeTTLProfiles 以 overrides 中的同名命名空间覆盖 defaults，用于按环境覆盖公共配置
+func MergeTTLProfiles(defaults, overrides []TTLProfile) []TTLProfile {
+	merged := make([]TTLProfile, 0, len(defaults)+len(overrides))
+	index := make(map[string]int, len(defaults))
+	for _, profile := range append(append([]TTLProfile{}, defaults...), overrides...) {
+		if i, ok := index[profile.Namespace]; ok {
+			merged[i] = profile
+			continue
+		}
+		index[profile.Namespace] = len(merged)
+		merged = append(merged, profile)
+	}
+	return merged
+}
+
+// TTLResolverConfig TTL 解析配置
+type TTLResolverConfig struct {
+	RefreshInterval time.Duration // 重新读取运行时调整的间隔，其他实例的调整在该时间内生效
+}
+
+// DefaultTTLResolverConfig 默认 TTL 解析配置
+func DefaultTTLResolverConfig() *TTLResolverConfig {
+	return &TTLResolverConfig{RefreshInterval: 30 * time.Second}
+}
+
+// TTLResolver 按键的命名空间解析缓存有效期，替代各处写死的有效期。配置文件中的配置在启动时加载，
+// 管理接口的调整保存在共享缓存中，各实例定期刷新；nil 时始终返回调用方的默认值
+type TTLResolver struct {
+	store            CacheService
+	config           *TTLResolverConfig
+	metricsCollector *metrics.MetricsCollector
+
+	mu         sync.RWMutex
+	configured map[string]TTLProfile
+	overrides  map[string]TTLProfile
+	effective  map[string]TTLProfile
+}
+
+// NewTTLResolver 创建 TTL 解析，store 保存运行时调整，为空时调整只在本实例生效
+func NewTTLResolver(store CacheService, profiles []TTLProfile, metricsCollector *metrics.MetricsCollector, config *TTLResolverConfig) (*TTLResolver, error) {
+	if config == nil {
+		config = DefaultTTLResolverConfig()
+	}
+	r := &TTLResolver{
+		store:            store,
+		config:           config,
+		metricsCollector: metricsCollector,
+		configured:       make(map[string]TTLProfile, len(profiles)),
+		overrides:        make(map[string]TTLProfile),
+	}
+	for _, profile := range profiles {
+		if err := profile.validate(); err != nil {
+			return nil, err
+		}
+		profile.Source = TTLSourceConfig
+		r.configured[profile.Namespace] = profile
+	}
+	r.apply(r.overrides)
+	return r, nil
+}
+
+// TTL 写入远端缓存的有效期，没有匹配的配置或配置为 0 时返回 fallback
+func (r *TTLResolver) TTL(key string, fallback time.Duration) time.Duration {
+	return r.resolve(key, fallback, "remote")
+}
+
+// LocalTTL 写入本地缓存的有效期，没有匹配的配置或配置为 0 时返回 fallback
+func (r *TTLResolver) LocalTTL(key string, fallback time.Duration) time.Duration {
+	return r.resolve(key, fallback, "local")
+}
+
+// resolve 查找最长匹配的命名空间并记录使用的有效期
+func (r *TTLResolver) resolve(key string, fallback time.Duration, level string) time.Duration {
+	if r == nil {
+		return fallback
+	}
+	namespace, ttl := defaultTTLNamespace, fallback
+	r.mu.RLock()
+	for prefix := key; prefix != ""; prefix = keyNamespace(prefix) {
+		if profile, ok := r.effective[prefix]; ok {
+			namespace = profile.Namespace
+			if level == "local" && profile.LocalTTL > 0 {
+				ttl = profile.LocalTTL
+			} else if level == "remote" && profile.TTL > 0 {
+				ttl = profile.TTL
+			}
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if r.metricsCollector != nil {
+		r.metricsCollector.ObserveCacheTTL(namespace, level, ttl)
+	}
+	return ttl
+}
+
+// Profiles 当前生效的配置，按命名空间排序
+func (r *TTLResolver) Profiles() []TTLProfile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	profiles := make([]TTLProfile, 0, len(r.effective))
+	for _, profile := range r.effective {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Namespace < profiles[j].Namespace })
+	return profiles
+}
+
+// SetProfile 运行时调整命名空间的有效期，保存后所有实例在下次刷新时生效，已写入的键不受影响
+func (r *TTLResolver) SetProfile(ctx context.Context, profile TTLProfile, actor string) (*TTLProfile, error) {
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+	profile.Source = TTLSourceRuntime
+	profile.UpdatedBy = actor
+	profile.UpdatedAt = time.Now()
+
+	overrides, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides[profile.Namespace] = profile
+	if err := r.save(ctx, overrides); err != nil {
+		return nil, err
+	}
+	r.apply(overrides)
+	return &profile, nil
+}
+
+// ResetProfile 撤销命名空间的运行时调整，恢复配置文件中的值；没有调整时返回 ErrTTLProfileNotFound
+func (r *TTLResolver) ResetProfile(ctx context.Context, namespace string) error {
+	namespace = strings.TrimSuffix(namespace, ":")
+	overrides, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := overrides[namespace]; !ok {
+		return ErrTTLProfileNotFound
+	}
+	delete(overrides, namespace)
+	if err := r.save(ctx, overrides); err != nil {
+		return err
+	}
+	r.apply(overrides)
+	return nil
+}
+
+// Refresh 重新读取运行时调整
+func (r *TTLResolver) Refresh(ctx context.Context) error {
+	overrides, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	r.apply(overrides)
+	return nil
+}
+
+// Run 按间隔刷新运行时调整，直到 ctx 结束
+func (r *TTLResolver) Run(ctx context.Context) {
+	if r.store == nil {
+		return
+	}
+	if err := r.Refresh(ctx); err != nil {
+		log.Printf("Failed to load cache TTL profiles: %v", err)
+	}
+	ticker := time.NewTicker(r.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh cache TTL profiles: %v", err)
+			}
+		}
+	}
+}
+
+// load 读取运行时调整，没有共享存储时使用本实例的调整
+func (r *TTLResolver) load(ctx context.Context) (map[string]TTLProfile, error) {
+	overrides := make(map[string]TTLProfile)
+	if r.store == nil {
+		r.mu.RLock()
+		for namespace, profile := range r.overrides {
+			overrides[namespace] = profile
+		}
+		r.mu.RUnlock()
+		return overrides, nil
+	}
+
+	result, err := r.store.GetMany(ctx, []string{ttlProfilesKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ttl profiles: %w", err)
+	}
+	if err := result.Errors[ttlProfilesKey]; err != nil {
+		return nil, fmt.Errorf("failed to load ttl profiles: %w", err)
+	}
+	if value, ok := result.Values[ttlProfilesKey]; ok {
+		if err := json.Unmarshal(value, &overrides); err != nil {
+			return nil, fmt.Errorf("failed to decode ttl profiles: %w", err)
+		}
+	}
+	return overrides, nil
+}
+
+// save 保存运行时调整，不设置过期时间
+func (r *TTLResolver) save(ctx context.Context, overrides map[string]TTLProfile) error {
+	if r.store == nil {
+		return nil
+	}
+	if err := r.store.Set(ctx, ttlProfilesKey, overrides, 0); err != nil {
+		return fmt.Errorf("failed to save ttl profiles: %w", err)
+	}
+	return nil
+}
+
+// apply 合并配置与运行时调整，并更新各命名空间的有效期指标
+func (r *TTLResolver) apply(overrides map[string]TTLProfile) {
+	effective := make(map[string]TTLProfile, len(r.configured)+len(overrides))
+	for namespace, profile := range r.configured {
+		effective[namespace] = profile
+	}
+	for namespace, profile := range overrides {
+		effective[namespace] = profile
+	}
+
+	r.mu.Lock()
+	previous := r.effective
+	r.overrides, r.effective = overrides, effective
+	r.mu.Unlock()
+
+	if r.metricsCollector == nil {
+		return
+	}
+	for namespace := range previous {
+		if _, ok := effective[namespace]; !ok {
+			r.metricsCollector.UpdateCacheTTLProfile(namespace, 0, 0, true)
+		}
+	}
+	for _, profile := range effective {
+		r.metricsCollector.UpdateCacheTTLProfile(profile.Namespace, profile.TTL, profile.LocalTTL, false)
+	}
+}
+
+// ttlProfileCache 写入时按 TTL 配置改写有效期的缓存
+type ttlProfileCache struct {
+	CacheService
+	resolver *TTLResolver
+}
+
+// WithTTLProfiles 包装缓存，Set、SetWithTTL 与 SetMany 按键的命名空间使用 TTL 配置中的有效期，
+// 没有匹配的配置时使用调用方传入的值；包装后不再支持 InvalidatePatternWithOptions，管理接口应使用未包装的缓存
+func WithTTLProfiles(inner CacheService, resolver *TTLResolver) CacheService {
+	if resolver == nil {
+		return inner
+	}
+	return &ttlProfileCache{CacheService: inner, resolver: resolver}
+}
+
+func (c *ttlProfileCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.CacheService.Set(ctx, key, value, c.resolver.TTL(key, expiration))
+}
+
+// SetWithTTL 与 RedisCache、MemoryCache 相同，默认有效期为 1 小时
+func (c *ttlProfileCache) SetWithTTL(ctx context.Context, key string, value interface{}) error {
+	return c.CacheService.Set(ctx, key, value, c.resolver.TTL(key, time.Hour))
+}
+
+func (c *ttlProfileCache) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	resolved := make([]CacheEntry, len(entries))
+	for i, entry := range entries {
+		entry.Expiration = c.resolver.TTL(entry.Key, entry.Expiration)
+		resolved[i] = entry
+	}
+	return c.CacheService.SetMany(ctx, resolved)
+}
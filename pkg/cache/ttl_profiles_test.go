@@ -0,0 +1,96 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTTLResolver_Resolve 最长的命名空间优先，按冒号分段匹配，为 0 或没有匹配时使用调用方的默认值；当前环境覆盖 default
+func TestTTLResolver_Resolve(t *testing.T) {
+	profiles := cache.MergeTTLProfiles(
+		[]cache.TTLProfile{{Namespace: "rbac", TTL: 10 * time.Minute}, {Namespace: "graphql", TTL: time.Hour}},
+		[]cache.TTLProfile{{Namespace: "rbac", TTL: time.Minute}, {Namespace: "graphql:coupon", LocalTTL: 5 * time.Second}},
+	)
+	resolver, err := cache.NewTTLResolver(nil, profiles, metrics.GetGlobalCollector(), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Minute, resolver.TTL("rbac:user:1:g2:roles", 30*time.Minute))
+	assert.Equal(t, 30*time.Minute, resolver.TTL("rbacx:user", 30*time.Minute), "namespaces match whole segments")
+	assert.Equal(t, time.Hour, resolver.TTL("graphql:user:7", 5*time.Minute))
+	assert.Equal(t, 5*time.Minute, resolver.TTL("graphql:coupon:7", 5*time.Minute), "longest namespace wins even without a remote ttl")
+	assert.Equal(t, 5*time.Second, resolver.LocalTTL("graphql:coupon:7", 30*time.Second))
+	assert.Equal(t, 30*time.Second, resolver.LocalTTL("graphql:user:7", 30*time.Second))
+
+	var missing *cache.TTLResolver
+	assert.Equal(t, time.Hour, missing.TTL("rbac:user:1", time.Hour))
+
+	_, err = cache.NewTTLResolver(nil, []cache.TTLProfile{{Namespace: "rbac:*", TTL: time.Minute}}, nil, nil)
+	assert.ErrorIs(t, err, cache.ErrInvalidNamespace)
+}
+
+// TestTTLResolver_RuntimeOverrides 调整保存在共享缓存中，其他实例刷新后生效；撤销后恢复配置文件中的值
+func TestTTLResolver_RuntimeOverrides(t *testing.T) {
+	store := fakes.NewCache(nil)
+	configured := []cache.TTLProfile{{Namespace: "rbac", TTL: 30 * time.Minute}}
+	first, err := cache.NewTTLResolver(store, configured, metrics.GetGlobalCollector(), nil)
+	require.NoError(t, err)
+	second, err := cache.NewTTLResolver(store, configured, metrics.GetGlobalCollector(), nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	saved, err := first.SetProfile(ctx, cache.TTLProfile{Namespace: "rbac:", TTL: 2 * time.Minute}, "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, "rbac", saved.Namespace)
+	assert.Equal(t, cache.TTLSourceRuntime, saved.Source)
+	assert.Equal(t, 2*time.Minute, first.TTL("rbac:user:1", time.Hour))
+	assert.Equal(t, 30*time.Minute, second.TTL("rbac:user:1", time.Hour))
+
+	require.NoError(t, second.Refresh(ctx))
+	assert.Equal(t, 2*time.Minute, second.TTL("rbac:user:1", time.Hour))
+
+	require.NoError(t, second.ResetProfile(ctx, "rbac"))
+	assert.ErrorIs(t, second.ResetProfile(ctx, "rbac"), cache.ErrTTLProfileNotFound)
+	require.NoError(t, first.Refresh(ctx))
+	profiles := first.Profiles()
+	require.Len(t, profiles, 1)
+	assert.Equal(t, cache.TTLSourceConfig, profiles[0].Source)
+	assert.Equal(t, 30*time.Minute, first.TTL("rbac:user:1", time.Hour))
+}
+
+// TestWithTTLProfiles 包装后的缓存与多级缓存写入时使用 TTL 配置
+func TestWithTTLProfiles(t *testing.T) {
+	resolver, err := cache.NewTTLResolver(nil, []cache.TTLProfile{
+		{Namespace: "rbac", TTL: 2 * time.Minute},
+		{Namespace: "resp", TTL: 10 * time.Minute, LocalTTL: 3 * time.Second},
+	}, metrics.GetGlobalCollector(), nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	store := fakes.NewCache(nil)
+	profiled := cache.WithTTLProfiles(store, resolver)
+	require.NoError(t, profiled.Set(ctx, "rbac:user:1", "roles", 30*time.Minute))
+	require.NoError(t, profiled.Set(ctx, "moment:1", "post", 5*time.Minute))
+	_, err = profiled.SetMany(ctx, []cache.CacheEntry{{Key: "rbac:user:2", Value: "roles", Expiration: time.Hour}})
+	require.NoError(t, err)
+	for key, want := range map[string]time.Duration{"rbac:user:1": 2 * time.Minute, "moment:1": 5 * time.Minute, "rbac:user:2": 2 * time.Minute} {
+		ttl, ok := store.TTL(key)
+		require.True(t, ok, key)
+		assert.Equal(t, want, ttl, key)
+	}
+
+	local, remote := fakes.NewCache(nil), fakes.NewCache(nil)
+	levels := cache.NewMultiLevelCache(local, remote, metrics.GetGlobalCollector(), cache.DefaultMultiLevelConfig())
+	levels.SetTTLResolver(resolver)
+	require.NoError(t, levels.Set(ctx, "resp:/api/topics", "body", time.Minute))
+	ttl, _ := local.TTL("resp:/api/topics")
+	assert.Equal(t, 3*time.Second, ttl)
+	ttl, _ = remote.TTL("resp:/api/topics")
+	assert.Equal(t, 10*time.Minute, ttl)
+}
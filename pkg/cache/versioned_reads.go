@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// versionedMaxTracked 本实例记录上次版本号的键数上限，超出时清空，未清理的旧版本键随有效期过期
+const versionedMaxTracked = 10000
+
+// VersionKey 键的版本号所在的键，VersionedInvalidationStrategy 失效时写入新的版本号，不过期
+func VersionKey(key string) string {
+	return key + ":version"
+}
+
+// VersionedKey 键在某个版本下实际存放数据的键，如 "user:1@v1712345678901234567"；没有版本号时为 v0
+func VersionedKey(key string, version int64) string {
+	return fmt.Sprintf("%s@v%d", key, version)
+}
+
+// versionedCache 按版本号读写数据键的缓存
+type versionedCache struct {
+	CacheService
+
+	mu   sync.Mutex
+	seen map[string]int64 // 键 -> 本实例上次读写时的版本号
+}
+
+// WithVersionedReads 包装缓存，Get、Set 等按键当前的版本号读写 key@v{n}。以 versioned 策略失效后版本号变化，
+// 旧版本的数据不再被读到；失效前开始的读取在失效后以旧数据回填时也只写入旧版本，不会覆盖新版本。
+// 每次访问多读一次版本号；本实例发现版本号变化时删除上次访问的旧版本键，其余旧版本键随有效期过期，
+// 因此包装后写入的数据应设置有效期。InvalidatePattern 与有序集合操作不经版本号
+func WithVersionedReads(inner CacheService) CacheService {
+	return &versionedCache{CacheService: inner, seen: make(map[string]int64)}
+}
+
+func (c *versionedCache) Get(ctx context.Context, key string, dest interface{}) error {
+	versioned, err := c.resolve(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.CacheService.Get(ctx, versioned, dest)
+}
+
+func (c *versionedCache) GetWithTTL(ctx context.Context, key string, dest interface{}) (time.Duration, error) {
+	versioned, err := c.resolve(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return c.CacheService.GetWithTTL(ctx, versioned, dest)
+}
+
+func (c *versionedCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	versioned, err := c.resolve(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.CacheService.Set(ctx, versioned, value, expiration)
+}
+
+func (c *versionedCache) SetWithTTL(ctx context.Context, key string, value interface{}) error {
+	versioned, err := c.resolve(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.CacheService.SetWithTTL(ctx, versioned, value)
+}
+
+// Delete 删除当前版本的数据，保留版本号，否则版本号回到 v0 后可能读到 v0 的旧数据
+func (c *versionedCache) Delete(ctx context.Context, key string) error {
+	versioned, err := c.resolve(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.CacheService.Delete(ctx, versioned)
+}
+
+func (c *versionedCache) Exists(ctx context.Context, key string) (bool, error) {
+	versioned, err := c.resolve(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return c.CacheService.Exists(ctx, versioned)
+}
+
+func (c *versionedCache) GetMultiple(ctx context.Context, keys []string, dest interface{}) error {
+	versioned, err := c.resolveMany(ctx, keys)
+	if err != nil {
+		return err
+	}
+	return c.CacheService.GetMultiple(ctx, versioned, dest)
+}
+
+// GetMany 结果中的键还原为调用方传入的键
+func (c *versionedCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	versioned, err := c.resolveMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.CacheService.GetMany(ctx, versioned)
+	if err != nil || result == nil {
+		return result, err
+	}
+
+	original := make(map[string]string, len(keys))
+	for i, key := range keys {
+		original[versioned[i]] = key
+	}
+	restored := &BatchGetResult{
+		Values: make(map[string]json.RawMessage, len(result.Values)),
+		Errors: make(map[string]error, len(result.Errors)),
+	}
+	for key, value := range result.Values {
+		restored.Values[original[key]] = value
+	}
+	for _, key := range result.Missing {
+		restored.Missing = append(restored.Missing, original[key])
+	}
+	for key, err := range result.Errors {
+		restored.Errors[original[key]] = err
+	}
+	return restored, nil
+}
+
+// SetMany 结果中的键还原为调用方传入的键
+func (c *versionedCache) SetMany(ctx context.Context, entries []CacheEntry) (*BatchSetResult, error) {
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	versioned, err := c.resolveMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	rewritten := make([]CacheEntry, len(entries))
+	original := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		rewritten[i] = entry
+		rewritten[i].Key = versioned[i]
+		original[versioned[i]] = entry.Key
+	}
+	result, err := c.CacheService.SetMany(ctx, rewritten)
+	if err != nil || result == nil {
+		return result, err
+	}
+
+	restored := &BatchSetResult{Errors: make(map[string]error, len(result.Errors))}
+	for _, key := range result.Succeeded {
+		restored.Succeeded = append(restored.Succeeded, original[key])
+	}
+	for key, err := range result.Errors {
+		restored.Errors[original[key]] = err
+	}
+	return restored, nil
+}
+
+// resolve 键当前版本的数据键
+func (c *versionedCache) resolve(ctx context.Context, key string) (string, error) {
+	versioned, err := c.resolveMany(ctx, []string{key})
+	if err != nil {
+		return "", err
+	}
+	return versioned[0], nil
+}
+
+// resolveMany 以一次批量读取取得各键的版本号，返回对应的数据键；版本号不存在时为 0
+func (c *versionedCache) resolveMany(ctx context.Context, keys []string) ([]string, error) {
+	versionKeys := make([]string, len(keys))
+	for i, key := range keys {
+		versionKeys[i] = VersionKey(key)
+	}
+	result, err := c.CacheService.GetMany(ctx, versionKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache versions: %w", err)
+	}
+
+	versioned := make([]string, len(keys))
+	for i, key := range keys {
+		if err := result.Errors[versionKeys[i]]; err != nil {
+			return nil, fmt.Errorf("failed to read version of %s: %w", key, err)
+		}
+		var version int64
+		if raw, ok := result.Values[versionKeys[i]]; ok {
+			if err := json.Unmarshal(raw, &version); err != nil {
+				return nil, fmt.Errorf("invalid version of %s: %w", key, err)
+			}
+		}
+		c.cleanup(ctx, key, version)
+		versioned[i] = VersionedKey(key, version)
+	}
+	return versioned, nil
+}
+
+// cleanup 记录键的版本号，与本实例上次访问时不同则删除上次的数据键；删除失败时由有效期兜底
+func (c *versionedCache) cleanup(ctx context.Context, key string, version int64) {
+	c.mu.Lock()
+	previous, seen := c.seen[key]
+	if !seen && len(c.seen) >= versionedMaxTracked {
+		c.seen = make(map[string]int64)
+	}
+	c.seen[key] = version
+	c.mu.Unlock()
+
+	if seen && previous != version {
+		c.CacheService.Delete(ctx, VersionedKey(key, previous))
+	}
+}
@@ -0,0 +1,59 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithVersionedReads 以 versioned 策略失效后读不到旧数据，失效前开始的读取回填旧版本不影响新版本；
+// 版本变化后首次访问删除旧版本键，批量读取的结果使用调用方传入的键
+func TestWithVersionedReads(t *testing.T) {
+	ctx := context.Background()
+	inner := fakes.NewCache(nil)
+	versioned := cache.WithVersionedReads(inner)
+	manager := cache.NewCacheConsistencyManager(inner, metrics.GetGlobalCollector(), &cache.ConsistencyConfig{})
+	defer manager.Close()
+
+	require.NoError(t, versioned.Set(ctx, "user:1", "alice", time.Minute))
+	var value string
+	require.NoError(t, versioned.Get(ctx, "user:1", &value))
+	assert.Equal(t, "alice", value)
+	exists, err := inner.Exists(ctx, cache.VersionedKey("user:1", 0))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, manager.Invalidate(ctx, "versioned", []string{"user:1"}))
+	assert.Error(t, versioned.Get(ctx, "user:1", &value))
+	exists, err = inner.Exists(ctx, cache.VersionedKey("user:1", 0))
+	require.NoError(t, err)
+	assert.False(t, exists, "old version cleaned up on first access after invalidation")
+
+	// 失效前读到旧版本号的请求回填旧数据，只写入旧版本键
+	require.NoError(t, inner.Set(ctx, cache.VersionedKey("user:1", 0), "stale", time.Minute))
+	require.NoError(t, versioned.Set(ctx, "user:1", "alice v2", time.Minute))
+	require.NoError(t, versioned.Get(ctx, "user:1", &value))
+	assert.Equal(t, "alice v2", value)
+
+	result, err := versioned.GetMany(ctx, []string{"user:1", "user:2"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `"alice v2"`, string(result.Values["user:1"]))
+	assert.Equal(t, []string{"user:2"}, result.Missing)
+	written, err := versioned.SetMany(ctx, []cache.CacheEntry{{Key: "user:2", Value: "bob", Expiration: time.Minute}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:2"}, written.Succeeded)
+
+	require.NoError(t, versioned.Delete(ctx, "user:1"))
+	exists, err = versioned.Exists(ctx, "user:1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = versioned.Exists(ctx, "user:2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,240 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrNoLoader 没有加载器匹配键
+	ErrNoLoader = errors.New("no warmup loader matches key")
+	// ErrSourceNotFound 加载器在数据源中找不到键对应的记录
+	ErrSourceNotFound = errors.New("warmup source record not found")
+)
+
+// BatchLoaderFunc 按 ID 批量加载，返回 ID 到值的映射，不存在的 ID 不出现在结果中
+type BatchLoaderFunc func(ctx context.Context, ids []string) (map[string]interface{}, error)
+
+// templateLoader 按键模板注册的批量加载器，模板形如 "user:{id}"
+type templateLoader struct {
+	template string
+	prefix   string
+	suffix   string
+	load     BatchLoaderFunc
+	ttl      time.Duration
+}
+
+// id 从键中取出 ID，键不符合模板时返回 false
+func (tl *templateLoader) id(key string) (string, bool) {
+	if len(key) <= len(tl.prefix)+len(tl.suffix) || !strings.HasPrefix(key, tl.prefix) || !strings.HasSuffix(key, tl.suffix) {
+		return "", false
+	}
+	return key[len(tl.prefix) : len(key)-len(tl.suffix)], true
+}
+
+// RegisterBatchLoader 按键模板注册批量加载器，模板中必须有且只有一个 {id}，例如 "user:{id}"；
+// 加载的值以 ttl 写入缓存，ttl 为 0 时使用默认的一小时。多个模板匹配同一个键时前缀最长的优先
+func (dl *DataLoader) RegisterBatchLoader(template string, load BatchLoaderFunc, ttl time.Duration) error {
+	prefix, suffix, ok := strings.Cut(template, "{id}")
+	if !ok || strings.Contains(suffix, "{id}") {
+		return fmt.Errorf("invalid warmup key template %q: expected exactly one {id}", template)
+	}
+	if ttl <= 0 {
+		ttl = dl.ttl
+	}
+
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	for i, existing := range dl.batchLoaders {
+		if existing.template == template {
+			dl.batchLoaders = append(dl.batchLoaders[:i], dl.batchLoaders[i+1:]...)
+			break
+		}
+	}
+	dl.batchLoaders = append(dl.batchLoaders, &templateLoader{template: template, prefix: prefix, suffix: suffix, load: load, ttl: ttl})
+	return nil
+}
+
+// match 键对应的批量加载器与 ID，或单键加载器；都不匹配时均为空
+func (dl *DataLoader) match(key string) (*templateLoader, string, DataLoaderFunc) {
+	dl.mu.RLock()
+	defer dl.mu.RUnlock()
+
+	var (
+		matched *templateLoader
+		id      string
+	)
+	for _, tl := range dl.batchLoaders {
+		if candidate, ok := tl.id(key); ok && (matched == nil || len(tl.prefix) > len(matched.prefix)) {
+			matched, id = tl, candidate
+		}
+	}
+	if matched != nil {
+		return matched, id, nil
+	}
+
+	var (
+		loader DataLoaderFunc
+		prefix string
+	)
+	for name, fn := range dl.loaders {
+		if strings.HasPrefix(key, name) && (loader == nil || len(name) > len(prefix)) {
+			loader, prefix = fn, name
+		}
+	}
+	return nil, "", loader
+}
+
+// Unmatched 没有任何加载器匹配的键，保持原有顺序
+func (dl *DataLoader) Unmatched(keys []string) []string {
+	var unmatched []string
+	for _, key := range keys {
+		if tl, _, fn := dl.match(key); tl == nil && fn == nil {
+			unmatched = append(unmatched, key)
+		}
+	}
+	return unmatched
+}
+
+// LoadMany 加载 keys 并写入缓存，返回失败的键及原因。已在缓存中的键跳过；
+// 批量加载器按模板分组，每 batchSize 个 ID 调用一次；单键加载器逐个调用
+func (dl *DataLoader) LoadMany(ctx context.Context, keys []string) map[string]error {
+	errs := make(map[string]error)
+	pending := keys
+	if cached, err := dl.cache.GetMany(ctx, keys); err == nil {
+		pending = make([]string, 0, len(keys))
+		for _, key := range keys {
+			if _, hit := cached.Values[key]; !hit {
+				pending = append(pending, key)
+			}
+		}
+	}
+
+	type group struct {
+		loader *templateLoader
+		keys   []string
+		ids    []string
+	}
+	var groups []*group
+	byLoader := make(map[*templateLoader]*group)
+	entries := make([]CacheEntry, 0, len(pending))
+	for _, key := range pending {
+		tl, id, fn := dl.match(key)
+		switch {
+		case tl != nil:
+			g, ok := byLoader[tl]
+			if !ok {
+				g = &group{loader: tl}
+				byLoader[tl] = g
+				groups = append(groups, g)
+			}
+			g.keys = append(g.keys, key)
+			g.ids = append(g.ids, id)
+		case fn != nil:
+			value, err := fn(ctx, key)
+			if err != nil {
+				errs[key] = err
+				continue
+			}
+			entries = append(entries, CacheEntry{Key: key, Value: value, Expiration: dl.ttl})
+		default:
+			errs[key] = ErrNoLoader
+		}
+	}
+
+	for _, g := range groups {
+		for start := 0; start < len(g.ids); start += dl.batchSize {
+			end := min(start+dl.batchSize, len(g.ids))
+			values, err := g.loader.load(ctx, g.ids[start:end])
+			for i := start; i < end; i++ {
+				value, found := values[g.ids[i]]
+				switch {
+				case err != nil:
+					errs[g.keys[i]] = fmt.Errorf("failed to load %s: %w", g.loader.template, err)
+				case !found:
+					errs[g.keys[i]] = ErrSourceNotFound
+				default:
+					entries = append(entries, CacheEntry{Key: g.keys[i], Value: value, Expiration: g.loader.ttl})
+				}
+			}
+		}
+	}
+
+	if len(entries) == 0 {
+		return errs
+	}
+	set, err := dl.cache.SetMany(ctx, entries)
+	if err != nil {
+		for _, entry := range entries {
+			errs[entry.Key] = err
+		}
+		return errs
+	}
+	for key, err := range set.Errors {
+		errs[key] = err
+	}
+	return errs
+}
+
+// loadInto 通过 loader 加载 keys，把结果计入 result
+func loadInto(ctx context.Context, loader *DataLoader, keys []string, result *WarmupResult) {
+	errs := loader.LoadMany(ctx, keys)
+	for _, key := range keys {
+		if err, failed := errs[key]; failed {
+			result.FailedKeys++
+			result.Errors = append(result.Errors, fmt.Sprintf("Failed to load key %s: %v", key, err))
+			continue
+		}
+		result.SuccessKeys++
+	}
+}
+
+// WarmupQueryer SQL 加载器使用的数据库连接，*database.DB 满足，查询计入慢查询统计
+type WarmupQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	Rebind(query string) string
+}
+
+// SQLLoader 由查询模板创建批量加载器：query 中的 "IN (?)" 展开为本批 ID，每行以列名到值的映射缓存，
+// 以 idColumn 列的值对应到键，例如 SQLLoader(db, "SELECT id, username, avatar FROM users WHERE id IN (?)", "id")。
+// 应只查询可以缓存的列，不要选出密码哈希等敏感字段
+func SQLLoader(db WarmupQueryer, query, idColumn string) BatchLoaderFunc {
+	return func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+		expanded, args, err := sqlx.In(query, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand warmup query: %w", err)
+		}
+		rows, err := db.QueryContext(ctx, db.Rebind(expanded), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query warmup rows: %w", err)
+		}
+		defer rows.Close()
+
+		values := make(map[string]interface{}, len(ids))
+		for rows.Next() {
+			record := make(map[string]interface{})
+			if err := rows.MapScan(record); err != nil {
+				return nil, fmt.Errorf("failed to scan warmup row: %w", err)
+			}
+			for column, value := range record {
+				if b, ok := value.([]byte); ok {
+					record[column] = string(b)
+				}
+			}
+			id, ok := record[idColumn]
+			if !ok {
+				return nil, fmt.Errorf("warmup query does not select id column %q", idColumn)
+			}
+			values[fmt.Sprint(id)] = record
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read warmup rows: %w", err)
+		}
+		return values, nil
+	}
+}
@@ -0,0 +1,70 @@
+package cache_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWarmup_SQLLoaderBatches 同一模板的键按 LoadBatchSize 合并为 IN 查询，已缓存的键不再查询，缺失的记录计为失败
+func TestWarmup_SQLLoaderBatches(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	store := cache.NewMemoryCache()
+	manager := cache.NewCacheWarmupManager(store, nil, &cache.WarmupConfig{LoadBatchSize: 2})
+	defer manager.Close()
+	query := "SELECT id, username FROM users WHERE id IN (?)"
+	require.NoError(t, manager.RegisterBatchLoader("user:{id}", cache.SQLLoader(db, query, "id"), 0))
+	require.Error(t, manager.RegisterBatchLoader("user:{id}:{id}", nil, 0))
+
+	ctx := context.Background()
+	require.NoError(t, store.Set(ctx, "user:1", map[string]interface{}{"id": "1", "username": "cached"}, time.Minute))
+	expanded := regexp.QuoteMeta("SELECT id, username FROM users WHERE id IN ($1, $2)")
+	mock.ExpectQuery(expanded).WithArgs("2", "3").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow("2", "bob").AddRow("3", "carol"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, username FROM users WHERE id IN ($1)")).WithArgs("4").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}))
+
+	result, err := manager.Warmup(ctx, "immediate", []string{"user:1", "user:2", "user:3", "user:4", "post:9"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.SuccessKeys)
+	assert.Equal(t, 2, result.FailedKeys)
+	assert.Equal(t, []string{
+		"Failed to load key user:4: warmup source record not found",
+		"Failed to load key post:9: no warmup loader matches key",
+	}, result.Errors)
+
+	var user map[string]interface{}
+	require.NoError(t, store.Get(ctx, "user:3", &user))
+	assert.Equal(t, map[string]interface{}{"id": "3", "username": "carol"}, user)
+}
+
+// TestWarmup_StrictMode 严格模式下有键没有加载器时整体失败，不加载任何键
+func TestWarmup_StrictMode(t *testing.T) {
+	store := cache.NewMemoryCache()
+	manager := cache.NewCacheWarmupManager(store, nil, &cache.WarmupConfig{Strict: true})
+	defer manager.Close()
+	loads := 0
+	manager.RegisterLoader("coupon:", func(ctx context.Context, key string) (interface{}, error) {
+		loads++
+		return key, nil
+	})
+
+	ctx := context.Background()
+	_, err := manager.Warmup(ctx, "batch", []string{"coupon:1", "user:1"})
+	assert.ErrorIs(t, err, cache.ErrNoLoader)
+	assert.Zero(t, loads)
+
+	result, err := manager.Warmup(ctx, "batch", []string{"coupon:1", "coupon:2"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.SuccessKeys)
+	exists, err := store.Exists(ctx, "coupon:2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
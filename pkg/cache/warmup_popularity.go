@@ -0,0 +1,420 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+)
+
+const (
+	// popularKeyPrefix 各命名空间热门键列表的缓存键前缀，后接命名空间
+	popularKeyPrefix = "warmup:popular:"
+	// popularIndexKey 保存过热门键的命名空间列表
+	popularIndexKey = "warmup:popular_namespaces"
+)
+
+// PopularityConfig 热门键统计配置
+type PopularityConfig struct {
+	SampleRate      float64       // 记录访问的采样比例，1 为全部记录
+	TopN            int           // 每个命名空间保存的热门键数
+	MaxTracked      int           // 每个命名空间在内存中计数的键数上限，超出时只保留计数最多的一半
+	PersistInterval time.Duration // 本地计数合并写入缓存的间隔
+	Decay           float64       // 合并时已保存分数的衰减系数，0.5 表示每个周期减半，使热度随时间转移
+	TTL             time.Duration // 热门键列表的有效期，超过该时间没有访问的命名空间不再预热
+}
+
+// DefaultPopularityConfig 默认热门键统计配置
+func DefaultPopularityConfig() *PopularityConfig {
+	return &PopularityConfig{
+		SampleRate:      0.05,
+		TopN:            200,
+		MaxTracked:      5000,
+		PersistInterval: time.Minute,
+		Decay:           0.5,
+		TTL:             7 * 24 * time.Hour,
+	}
+}
+
+// PopularKey 热门键及其分数，分数约等于衰减后的访问次数
+type PopularKey struct {
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+}
+
+// PopularityTracker 按命名空间统计缓存键的访问热度。访问按采样比例计数，
+// 定期与缓存中已保存的分数合并，每个命名空间保留前 TopN 个键，重启与各实例之间共享；
+// 多个实例同时合并时可能丢失其中一个实例一个周期的计数，对排名影响很小
+type PopularityTracker struct {
+	store  CacheService
+	config *PopularityConfig
+	clock  clock.Clock
+	sample func() float64
+
+	mu     sync.Mutex
+	counts map[string]map[string]float64 // 命名空间 -> 键 -> 上次合并以来的计数
+}
+
+// NewPopularityTracker 创建热门键统计，store 为保存热门键列表的共享缓存，
+// 不要传入 TrackAccess 包装后的缓存，否则读取列表本身也会被计数
+func NewPopularityTracker(store CacheService, config *PopularityConfig) *PopularityTracker {
+	if config == nil {
+		config = DefaultPopularityConfig()
+	}
+	return &PopularityTracker{
+		store:  store,
+		config: config,
+		clock:  clock.OrReal(nil),
+		sample: rand.Float64,
+		counts: make(map[string]map[string]float64),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (t *PopularityTracker) SetClock(c clock.Clock) {
+	t.clock = clock.OrReal(c)
+}
+
+// keyNamespace 键最后一个冒号之前的部分，如 "graphql:coupon:12" 属于 "graphql:coupon"；没有冒号时为空
+func keyNamespace(key string) string {
+	if i := strings.LastIndexByte(key, ':'); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// Record 按采样比例记录一次访问，没有命名空间的键不统计
+func (t *PopularityTracker) Record(keys ...string) {
+	rate := t.config.SampleRate
+	if rate <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		if rate < 1 && t.sample() >= rate {
+			continue
+		}
+		namespace := keyNamespace(key)
+		if namespace == "" {
+			continue
+		}
+		counts, ok := t.counts[namespace]
+		if !ok {
+			counts = make(map[string]float64)
+			t.counts[namespace] = counts
+		}
+		// 按采样比例放大，使分数近似真实访问次数，各实例的采样比例不同时也可以合并
+		counts[key] += 1 / min(rate, 1)
+		if t.config.MaxTracked > 0 && len(counts) > t.config.MaxTracked {
+			t.counts[namespace] = topCounts(counts, t.config.MaxTracked/2)
+		}
+	}
+}
+
+// topCounts 计数最多的 n 个键
+func topCounts(counts map[string]float64, n int) map[string]float64 {
+	ranked := rankPopular(counts, n)
+	kept := make(map[string]float64, len(ranked))
+	for _, p := range ranked {
+		kept[p.Key] = p.Score
+	}
+	return kept
+}
+
+// rankPopular 按分数降序取前 n 个，分数相同时按键排序
+func rankPopular(scores map[string]float64, n int) []PopularKey {
+	ranked := make([]PopularKey, 0, len(scores))
+	for key, score := range scores {
+		ranked = append(ranked, PopularKey{Key: key, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Key < ranked[j].Key
+	})
+	if n > 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// Persist 把上次合并以来的计数与已保存的分数合并后写回缓存。已保存的分数先按 Decay 衰减，
+// 衰减到 1 以下的键移除；写入失败的命名空间的计数保留到下次合并
+func (t *PopularityTracker) Persist(ctx context.Context) error {
+	t.mu.Lock()
+	pending := t.counts
+	t.counts = make(map[string]map[string]float64)
+	t.mu.Unlock()
+
+	namespaces, err := t.Namespaces(ctx)
+	if err != nil {
+		t.restore(pending)
+		return err
+	}
+	known := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		known[namespace] = true
+	}
+	for namespace := range pending {
+		if !known[namespace] {
+			known[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	var (
+		kept     []string
+		firstErr error
+	)
+	for _, namespace := range namespaces {
+		saved, err := t.Hot(ctx, namespace)
+		merged := 0
+		if err == nil {
+			merged, err = t.merge(ctx, namespace, saved, pending[namespace])
+		}
+		if err != nil {
+			t.restore(map[string]map[string]float64{namespace: pending[namespace]})
+			if firstErr == nil {
+				firstErr = err
+			}
+			kept = append(kept, namespace)
+			continue
+		}
+		if merged > 0 {
+			kept = append(kept, namespace)
+		}
+	}
+
+	if err := t.store.Set(ctx, popularIndexKey, kept, t.config.TTL); err != nil && firstErr == nil {
+		firstErr = fmt.Errorf("failed to save popular key namespaces: %w", err)
+	}
+	return firstErr
+}
+
+// merge 合并一个命名空间的已保存分数与本地计数并写回，返回写入的键数，合并后为空时删除
+func (t *PopularityTracker) merge(ctx context.Context, namespace string, saved []PopularKey, pending map[string]float64) (int, error) {
+	scores := make(map[string]float64, len(saved)+len(pending))
+	for _, p := range saved {
+		if score := p.Score * t.config.Decay; score >= 1 {
+			scores[p.Key] = score
+		}
+	}
+	for key, count := range pending {
+		scores[key] += count
+	}
+
+	if len(scores) == 0 {
+		if err := t.store.Delete(ctx, popularKeyPrefix+namespace); err != nil {
+			return 0, fmt.Errorf("failed to delete popular keys of %s: %w", namespace, err)
+		}
+		return 0, nil
+	}
+	hot := rankPopular(scores, t.config.TopN)
+	if err := t.store.Set(ctx, popularKeyPrefix+namespace, hot, t.config.TTL); err != nil {
+		return 0, fmt.Errorf("failed to save popular keys of %s: %w", namespace, err)
+	}
+	return len(hot), nil
+}
+
+// restore 把未能写入的计数加回本地
+func (t *PopularityTracker) restore(pending map[string]map[string]float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for namespace, counts := range pending {
+		if len(counts) == 0 {
+			continue
+		}
+		current, ok := t.counts[namespace]
+		if !ok {
+			current = make(map[string]float64, len(counts))
+			t.counts[namespace] = current
+		}
+		for key, count := range counts {
+			current[key] += count
+		}
+	}
+}
+
+// Hot 已保存的命名空间热门键，按分数降序；尚未保存时为空
+func (t *PopularityTracker) Hot(ctx context.Context, namespace string) ([]PopularKey, error) {
+	var hot []PopularKey
+	if err := t.load(ctx, popularKeyPrefix+namespace, &hot); err != nil {
+		return nil, fmt.Errorf("failed to load popular keys of %s: %w", namespace, err)
+	}
+	return hot, nil
+}
+
+// Namespaces 保存过热门键的命名空间
+func (t *PopularityTracker) Namespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	if err := t.load(ctx, popularIndexKey, &namespaces); err != nil {
+		return nil, fmt.Errorf("failed to load popular key namespaces: %w", err)
+	}
+	return namespaces, nil
+}
+
+// load 读取 JSON 值，键不存在时 dest 保持不变
+func (t *PopularityTracker) load(ctx context.Context, key string, dest interface{}) error {
+	result, err := t.store.GetMany(ctx, []string{key})
+	if err != nil {
+		return err
+	}
+	if err := result.Errors[key]; err != nil {
+		return err
+	}
+	value, ok := result.Values[key]
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(value, dest)
+}
+
+// Run 每个 PersistInterval 合并一次计数，ctx 结束时最后合并一次，使部署重启前的计数不丢失
+func (t *PopularityTracker) Run(ctx context.Context) {
+	ticker := t.clock.NewTicker(t.config.PersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if err := t.Persist(ctx); err != nil {
+				log.Printf("Failed to persist popular cache keys: %v", err)
+			}
+		case <-ctx.Done():
+			persistCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := t.Persist(persistCtx); err != nil {
+				log.Printf("Failed to persist popular cache keys on shutdown: %v", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// trackedCache 读取时把键计入热门键统计
+type trackedCache struct {
+	CacheService
+	tracker *PopularityTracker
+}
+
+// TrackAccess 包装缓存，Get、GetWithTTL、GetMultiple 与 GetMany 读取的键计入 tracker；
+// 包装在 NewTenantCache 之外时统计的是不带租户前缀的键
+func TrackAccess(inner CacheService, tracker *PopularityTracker) CacheService {
+	return &trackedCache{CacheService: inner, tracker: tracker}
+}
+
+func (c *trackedCache) Get(ctx context.Context, key string, dest interface{}) error {
+	c.tracker.Record(key)
+	return c.CacheService.Get(ctx, key, dest)
+}
+
+func (c *trackedCache) GetWithTTL(ctx context.Context, key string, dest interface{}) (time.Duration, error) {
+	c.tracker.Record(key)
+	return c.CacheService.GetWithTTL(ctx, key, dest)
+}
+
+func (c *trackedCache) GetMultiple(ctx context.Context, keys []string, dest interface{}) error {
+	c.tracker.Record(keys...)
+	return c.CacheService.GetMultiple(ctx, keys, dest)
+}
+
+func (c *trackedCache) GetMany(ctx context.Context, keys []string) (*BatchGetResult, error) {
+	c.tracker.Record(keys...)
+	return c.CacheService.GetMany(ctx, keys)
+}
+
+// SetPopularity 关联热门键统计，WarmupPopular 与带 Namespace 的定时任务从中取键
+func (cwm *CacheWarmupManager) SetPopularity(tracker *PopularityTracker) {
+	cwm.popularity = tracker
+}
+
+// WarmupPopular 以 smart 策略预热 namespace 及其子命名空间的热门键，namespace 为空时预热全部命名空间，
+// 用于部署启动后与缓存清空后。没有加载器匹配的键跳过，数量记在结果的 Metadata["skipped_keys"]
+func (cwm *CacheWarmupManager) WarmupPopular(ctx context.Context, namespace string) (*WarmupResult, error) {
+	if cwm.popularity == nil {
+		return nil, errors.New("popularity tracking is not configured")
+	}
+	namespaces, err := cwm.popularity.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var popular []PopularKey
+	for _, ns := range namespaces {
+		if namespace != "" && ns != namespace && !strings.HasPrefix(ns, namespace+":") {
+			continue
+		}
+		hot, err := cwm.popularity.Hot(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		popular = append(popular, hot...)
+	}
+
+	unmatched := make(map[string]bool)
+	for _, key := range cwm.loader.Unmatched(popularKeys(popular)) {
+		unmatched[key] = true
+	}
+	loadable := popular[:0:0]
+	for _, p := range popular {
+		if !unmatched[p.Key] {
+			loadable = append(loadable, p)
+		}
+	}
+
+	if smart, ok := cwm.strategies["smart"].(*SmartWarmupStrategy); ok {
+		smart.analyzer.Observe(loadable)
+	}
+	result, err := cwm.Warmup(ctx, "smart", popularKeys(loadable))
+	if err != nil {
+		return nil, err
+	}
+	result.Metadata["skipped_keys"] = len(unmatched)
+	return result, nil
+}
+
+// popularKeys 热门键的键名
+func popularKeys(popular []PopularKey) []string {
+	keys := make([]string, len(popular))
+	for i, p := range popular {
+		keys[i] = p.Key
+	}
+	return keys
+}
+
+// WarmupAfterFlush 订阅缓存管理事件，命名空间被清空后预热其中的热门键，直到 ctx 结束
+func (cwm *CacheWarmupManager) WarmupAfterFlush(ctx context.Context, admin *Admin) {
+	events, unsubscribe := admin.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type != AdminEventInvalidateNamespace {
+				continue
+			}
+			result, err := cwm.WarmupPopular(ctx, event.Target)
+			if err != nil {
+				log.Printf("Failed to warm up popular keys of %s after flush: %v", event.Target, err)
+				continue
+			}
+			log.Printf("Warmed up %d/%d popular keys of %s after flush", result.SuccessKeys, result.TotalKeys, event.Target)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPopularityTracker_PersistAndWarmup 读取计入所属命名空间，合并时已保存的分数衰减，
+// 只预热有加载器的热门键，命名空间被清空后自动预热
+func TestPopularityTracker_PersistAndWarmup(t *testing.T) {
+	store := fakes.NewCache(nil)
+	tracker := cache.NewPopularityTracker(store, &cache.PopularityConfig{SampleRate: 1, TopN: 2, MaxTracked: 100, Decay: 0.5, TTL: time.Hour})
+	tracked := cache.TrackAccess(store, tracker)
+	ctx := context.Background()
+	var dest string
+	for _, key := range []string{"user:1", "user:2", "user:1", "user:3", "user:1", "user:2", "post:7", "nocolon"} {
+		_ = tracked.Get(ctx, key, &dest)
+	}
+
+	require.NoError(t, tracker.Persist(ctx))
+	hot, err := tracker.Hot(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, []cache.PopularKey{{Key: "user:1", Score: 3}, {Key: "user:2", Score: 2}}, hot)
+	namespaces, err := tracker.Namespaces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"post", "user"}, namespaces)
+
+	_, err = tracked.GetMany(ctx, []string{"post:7", "post:7"})
+	require.NoError(t, err)
+	require.NoError(t, tracker.Persist(ctx))
+	hot, err = tracker.Hot(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, []cache.PopularKey{{Key: "user:1", Score: 1.5}, {Key: "user:2", Score: 1}}, hot)
+
+	manager := cache.NewCacheWarmupManager(store, nil, &cache.WarmupConfig{})
+	defer manager.Close()
+	manager.SetPopularity(tracker)
+	manager.RegisterLoader("user:", func(ctx context.Context, key string) (interface{}, error) {
+		return "loaded " + key, nil
+	})
+
+	result, err := manager.WarmupPopular(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.SuccessKeys)
+	assert.Equal(t, 1, result.Metadata["skipped_keys"], "post:7 has no loader")
+	require.NoError(t, store.Get(ctx, "user:1", &dest))
+	assert.Equal(t, "loaded user:1", dest)
+
+	admin := cache.NewAdmin(store, nil, metrics.GetGlobalCollector(), nil)
+	watchCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	go manager.WarmupAfterFlush(watchCtx, admin)
+	require.Eventually(t, func() bool {
+		// 等待订阅生效：清空后 user:2 重新出现说明预热已执行
+		if _, err := admin.InvalidateNamespace(ctx, "user", "ops"); err != nil {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+		exists, err := store.Exists(ctx, "user:2")
+		return err == nil && exists
+	}, time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,211 @@
+package chaos
+
+import (
+	"context"
+	"time"
+	"user_crud_jwt/pkg/cache"
+)
+
+// FaultyCache 在缓存调用前注入故障的包装，批量操作按键分别判断，命中错误的键记入结果的 Errors
+type FaultyCache struct {
+	cache.CacheService
+	injector *Injector
+}
+
+// WrapCache 包装缓存服务；未编译故障注入或 injector 为 nil 时原样返回
+func WrapCache(inner cache.CacheService, injector *Injector) cache.CacheService {
+	if !Enabled || injector == nil {
+		return inner
+	}
+	return &FaultyCache{CacheService: inner, injector: injector}
+}
+
+// Get 实现 cache.CacheService
+func (c *FaultyCache) Get(ctx context.Context, key string, dest interface{}) error {
+	if err := c.injector.Inject(ctx, TargetCache, "get", key); err != nil {
+		return err
+	}
+	return c.CacheService.Get(ctx, key, dest)
+}
+
+// Set 实现 cache.CacheService
+func (c *FaultyCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.injector.Inject(ctx, TargetCache, "set", key); err != nil {
+		return err
+	}
+	return c.CacheService.Set(ctx, key, value, expiration)
+}
+
+// Delete 实现 cache.CacheService
+func (c *FaultyCache) Delete(ctx context.Context, key string) error {
+	if err := c.injector.Inject(ctx, TargetCache, "delete", key); err != nil {
+		return err
+	}
+	return c.CacheService.Delete(ctx, key)
+}
+
+// Exists 实现 cache.CacheService
+func (c *FaultyCache) Exists(ctx context.Context, key string) (bool, error) {
+	if err := c.injector.Inject(ctx, TargetCache, "exists", key); err != nil {
+		return false, err
+	}
+	return c.CacheService.Exists(ctx, key)
+}
+
+// GetWithTTL 实现 cache.CacheService
+func (c *FaultyCache) GetWithTTL(ctx context.Context, key string, dest interface{}) (time.Duration, error) {
+	if err := c.injector.Inject(ctx, TargetCache, "get", key); err != nil {
+		return 0, err
+	}
+	return c.CacheService.GetWithTTL(ctx, key, dest)
+}
+
+// SetWithTTL 实现 cache.CacheService
+func (c *FaultyCache) SetWithTTL(ctx context.Context, key string, value interface{}) error {
+	if err := c.injector.Inject(ctx, TargetCache, "set", key); err != nil {
+		return err
+	}
+	return c.CacheService.SetWithTTL(ctx, key, value)
+}
+
+// InvalidatePattern 实现 cache.CacheService
+func (c *FaultyCache) InvalidatePattern(ctx context.Context, pattern string) error {
+	if err := c.injector.Inject(ctx, TargetCache, "invalidate", pattern); err != nil {
+		return err
+	}
+	return c.CacheService.InvalidatePattern(ctx, pattern)
+}
+
+// GetMultiple 实现 cache.CacheService，任一键命中错误时整体失败
+func (c *FaultyCache) GetMultiple(ctx context.Context, keys []string, dest interface{}) error {
+	if _, err := c.decideKeys(ctx, "get_many", keys, true); err != nil {
+		return err
+	}
+	return c.CacheService.GetMultiple(ctx, keys, dest)
+}
+
+// GetMany 实现 cache.CacheService，命中错误的键不读取，记入结果的 Errors
+func (c *FaultyCache) GetMany(ctx context.Context, keys []string) (*cache.BatchGetResult, error) {
+	failed, err := c.decideKeys(ctx, "get_many", keys, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return c.CacheService.GetMany(ctx, keys)
+	}
+
+	remaining := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := failed[key]; !ok {
+			remaining = append(remaining, key)
+		}
+	}
+	result := cache.NewBatchGetResult()
+	if len(remaining) > 0 {
+		inner, err := c.CacheService.GetMany(ctx, remaining)
+		if err != nil {
+			return nil, err
+		}
+		result = inner
+	}
+	for key, keyErr := range failed {
+		result.Errors[key] = keyErr
+	}
+	return result, nil
+}
+
+// SetMany 实现 cache.CacheService，命中错误的条目不写入，记入结果的 Errors
+func (c *FaultyCache) SetMany(ctx context.Context, entries []cache.CacheEntry) (*cache.BatchSetResult, error) {
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	failed, err := c.decideKeys(ctx, "set_many", keys, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return c.CacheService.SetMany(ctx, entries)
+	}
+
+	remaining := make([]cache.CacheEntry, 0, len(entries))
+	for _, entry := range entries {
+		if _, ok := failed[entry.Key]; !ok {
+			remaining = append(remaining, entry)
+		}
+	}
+	result := cache.NewBatchSetResult()
+	if len(remaining) > 0 {
+		inner, err := c.CacheService.SetMany(ctx, remaining)
+		if err != nil {
+			return nil, err
+		}
+		result = inner
+	}
+	for key, keyErr := range failed {
+		result.Errors[key] = keyErr
+	}
+	return result, nil
+}
+
+// decideKeys 按键判断注入结果并等待其中最大的延迟，返回命中错误的键；
+// failFast 为 true 时有键命中错误即返回该错误
+func (c *FaultyCache) decideKeys(ctx context.Context, operation string, keys []string, failFast bool) (map[string]error, error) {
+	var latency time.Duration
+	var failed map[string]error
+	var first error
+	for _, key := range keys {
+		d, err := c.injector.decide(TargetCache, operation, key)
+		if d > latency {
+			latency = d
+		}
+		if err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+				first = err
+			}
+			failed[key] = err
+		}
+	}
+	if latency > 0 {
+		if err := c.injector.sleep(ctx, latency); err != nil {
+			return nil, err
+		}
+	}
+	if failFast && first != nil {
+		return nil, first
+	}
+	return failed, nil
+}
+
+// ZAdd 实现 cache.CacheService，按 set 操作注入
+func (c *FaultyCache) ZAdd(ctx context.Context, key string, members ...cache.ScoredMember) error {
+	if err := c.injector.Inject(ctx, TargetCache, "set", key); err != nil {
+		return err
+	}
+	return c.CacheService.ZAdd(ctx, key, members...)
+}
+
+// ZIncrBy 实现 cache.CacheService，按 set 操作注入
+func (c *FaultyCache) ZIncrBy(ctx context.Context, key, member string, increment float64) (float64, error) {
+	if err := c.injector.Inject(ctx, TargetCache, "set", key); err != nil {
+		return 0, err
+	}
+	return c.CacheService.ZIncrBy(ctx, key, member, increment)
+}
+
+// ZRangeWithScores 实现 cache.CacheService，按 get 操作注入
+func (c *FaultyCache) ZRangeWithScores(ctx context.Context, key string, start, stop int64, desc bool) ([]cache.ScoredMember, error) {
+	if err := c.injector.Inject(ctx, TargetCache, "get", key); err != nil {
+		return nil, err
+	}
+	return c.CacheService.ZRangeWithScores(ctx, key, start, stop, desc)
+}
+
+// ZRank 实现 cache.CacheService，按 get 操作注入
+func (c *FaultyCache) ZRank(ctx context.Context, key, member string, desc bool) (int64, error) {
+	if err := c.injector.Inject(ctx, TargetCache, "get", key); err != nil {
+		return 0, err
+	}
+	return c.CacheService.ZRank(ctx, key, member, desc)
+}
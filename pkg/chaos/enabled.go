@@ -0,0 +1,6 @@
+//go:build !prod
+
+package chaos
+
+// Enabled 是否编译了故障注入，以 -tags prod 构建时为 false，注入器不生效且不注册管理接口
+const Enabled = true
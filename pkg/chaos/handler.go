@@ -0,0 +1,67 @@
+package chaos
+
+import (
+	"errors"
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 故障注入管理接口
+type Handler struct {
+	injector *Injector
+}
+
+// NewHandler 创建故障注入管理接口
+func NewHandler(injector *Injector) *Handler {
+	return &Handler{injector: injector}
+}
+
+// RegisterAdminRoutes 注册故障注入路由，调用方需挂载管理员权限校验；未编译故障注入时不注册
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	if !Enabled {
+		return
+	}
+	group.GET("/chaos/faults", h.ListFaults)
+	group.POST("/chaos/faults", h.CreateFault)
+	group.DELETE("/chaos/faults", h.ClearFaults)
+	group.DELETE("/chaos/faults/:id", h.DeleteFault)
+}
+
+// ListFaults 生效中的规则及其命中次数
+func (h *Handler) ListFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"faults": h.injector.List(),
+	})
+}
+
+// CreateFault 添加规则，立即生效
+func (h *Handler) CreateFault(c *gin.Context) {
+	var fault Fault
+	if err := c.ShouldBindJSON(&fault); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	created, err := h.injector.Add(fault)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteFault 删除规则
+func (h *Handler) DeleteFault(c *gin.Context) {
+	if !h.injector.Remove(c.Param("id")) {
+		apperrors.Render(c, apperrors.Wrap(errors.New("fault not found"), apperrors.CodeNotFound, ""))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ClearFaults 删除全部规则，恢复正常
+func (h *Handler) ClearFaults(c *gin.Context) {
+	h.injector.Clear()
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,267 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjected 注入的错误，调用方可据此区分注入故障与真实故障
+var ErrInjected = errors.New("injected fault")
+
+// Target 故障注入的目标
+type Target string
+
+const (
+	// TargetCache 缓存服务，操作为 get、set、delete、exists、invalidate、get_many、set_many，匹配缓存键前缀
+	TargetCache Target = "cache"
+	// TargetDatabase 数据库，操作为 read（SELECT）与 write，匹配 SQL 中包含的文本（如表名）
+	TargetDatabase Target = "database"
+)
+
+// Fault 一条故障规则：匹配的调用先等待 LatencyMS，再按 ErrorRate 的概率返回错误。
+// 只匹配部分键或表、或 ErrorRate 小于 1 时即为部分故障
+type Fault struct {
+	ID     string `json:"id"`
+	Target Target `json:"target" binding:"required,oneof=cache database"`
+	// Operations 生效的操作，为空时匹配全部操作
+	Operations []string `json:"operations,omitempty"`
+	// Match 缓存键前缀或 SQL 包含的文本，为空时匹配全部
+	Match     string  `json:"match,omitempty"`
+	LatencyMS int64   `json:"latency_ms,omitempty" binding:"min=0,max=60000"`
+	ErrorRate float64 `json:"error_rate,omitempty" binding:"min=0,max=1"`
+	// Error 注入错误的附加信息，为空时为规则 ID
+	Error string `json:"error,omitempty"`
+	// Seed 非 0 时以此初始化随机数，相同的调用序列得到相同的注入结果，便于确定性测试
+	Seed int64 `json:"seed,omitempty"`
+	// MaxHits 命中此次数后自动移除，0 表示不限
+	MaxHits int64 `json:"max_hits,omitempty" binding:"min=0"`
+	// DurationSeconds 生效时长，到期后自动移除，0 表示一直生效
+	DurationSeconds int64 `json:"duration_seconds,omitempty" binding:"min=0"`
+
+	Hits      int64      `json:"hits"`
+	Injected  int64      `json:"injected"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Validate 校验规则
+func (f *Fault) Validate() error {
+	switch f.Target {
+	case TargetCache, TargetDatabase:
+	default:
+		return fmt.Errorf("unknown fault target: %q", f.Target)
+	}
+	if f.LatencyMS < 0 || f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return errors.New("latency_ms must be >= 0 and error_rate between 0 and 1")
+	}
+	if f.LatencyMS == 0 && f.ErrorRate == 0 {
+		return errors.New("fault must inject latency or errors")
+	}
+	return nil
+}
+
+// matches 调用是否命中规则
+func (f *Fault) matches(target Target, operation, key string) bool {
+	if f.Target != target {
+		return false
+	}
+	if len(f.Operations) > 0 {
+		found := false
+		for _, op := range f.Operations {
+			if op == operation {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Match == "" {
+		return true
+	}
+	if target == TargetCache {
+		return strings.HasPrefix(key, f.Match)
+	}
+	return strings.Contains(strings.ToLower(key), strings.ToLower(f.Match))
+}
+
+// rule 生效中的规则及其随机数
+type rule struct {
+	Fault
+	rand *rand.Rand
+}
+
+// Injector 故障注入器，规则保存在内存中，只影响本实例
+type Injector struct {
+	mu     sync.Mutex
+	rules  map[string]*rule
+	nextID int64
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewInjector 创建故障注入器
+func NewInjector() *Injector {
+	return &Injector{
+		rules: make(map[string]*rule),
+		now:   time.Now,
+		sleep: sleepContext,
+	}
+}
+
+// Add 添加规则，返回带 ID 的副本
+func (i *Injector) Add(fault Fault) (*Fault, error) {
+	if err := fault.Validate(); err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.nextID++
+	fault.ID = strconv.FormatInt(i.nextID, 10)
+	fault.Hits, fault.Injected = 0, 0
+	fault.CreatedAt = i.now()
+	fault.ExpiresAt = nil
+	if fault.DurationSeconds > 0 {
+		expiresAt := fault.CreatedAt.Add(time.Duration(fault.DurationSeconds) * time.Second)
+		fault.ExpiresAt = &expiresAt
+	}
+	seed := fault.Seed
+	if seed == 0 {
+		seed = fault.CreatedAt.UnixNano()
+	}
+	i.rules[fault.ID] = &rule{Fault: fault, rand: rand.New(rand.NewSource(seed))}
+
+	log.Printf("Fault injection %s added: target=%s operations=%v match=%q latency=%dms error_rate=%.2f",
+		fault.ID, fault.Target, fault.Operations, fault.Match, fault.LatencyMS, fault.ErrorRate)
+	return &fault, nil
+}
+
+// Remove 删除规则，不存在时返回 false
+func (i *Injector) Remove(id string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.rules[id]; !ok {
+		return false
+	}
+	delete(i.rules, id)
+	log.Printf("Fault injection %s removed", id)
+	return true
+}
+
+// Clear 删除全部规则
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = make(map[string]*rule)
+	log.Printf("Fault injection cleared")
+}
+
+// List 生效中的规则，按 ID 排序
+func (i *Injector) List() []Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.expireLocked()
+
+	faults := make([]Fault, 0, len(i.rules))
+	for _, r := range i.rules {
+		faults = append(faults, r.Fault)
+	}
+	sort.Slice(faults, func(a, b int) bool {
+		x, _ := strconv.ParseInt(faults[a].ID, 10, 64)
+		y, _ := strconv.ParseInt(faults[b].ID, 10, 64)
+		return x < y
+	})
+	return faults
+}
+
+// Inject 在调用前执行：命中规则时等待延迟并按概率返回包装 ErrInjected 的错误。
+// 多条规则命中时延迟取最大值，任一规则决定注入错误即返回错误；未编译故障注入时直接返回 nil
+func (i *Injector) Inject(ctx context.Context, target Target, operation, key string) error {
+	latency, err := i.decide(target, operation, key)
+	if latency > 0 {
+		if sleepErr := i.sleep(ctx, latency); sleepErr != nil {
+			return sleepErr
+		}
+	}
+	return err
+}
+
+// decide 计算调用的注入延迟与错误，不等待
+func (i *Injector) decide(target Target, operation, key string) (time.Duration, error) {
+	if !Enabled || i == nil {
+		return 0, nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.rules) == 0 {
+		return 0, nil
+	}
+	i.expireLocked()
+
+	var latency time.Duration
+	var injected *rule
+	for id, r := range i.rules {
+		if !r.matches(target, operation, key) {
+			continue
+		}
+		r.Hits++
+		if d := time.Duration(r.LatencyMS) * time.Millisecond; d > latency {
+			latency = d
+		}
+		if r.ErrorRate > 0 && r.rand.Float64() < r.ErrorRate {
+			r.Injected++
+			if injected == nil {
+				injected = r
+			}
+		}
+		if r.MaxHits > 0 && r.Hits >= r.MaxHits {
+			delete(i.rules, id)
+		}
+	}
+	if injected == nil {
+		return latency, nil
+	}
+	message := injected.Error
+	if message == "" {
+		message = "fault " + injected.ID
+	}
+	return latency, fmt.Errorf("%w: %s %s: %s", ErrInjected, target, operation, message)
+}
+
+// DatabaseHook 数据库查询前的回调，传给 database.DB.SetFaultHook
+func (i *Injector) DatabaseHook(ctx context.Context, operation, query string) error {
+	return i.Inject(ctx, TargetDatabase, operation, query)
+}
+
+// expireLocked 移除到期的规则，调用方需持有锁
+func (i *Injector) expireLocked() {
+	now := i.now()
+	for id, r := range i.rules {
+		if r.ExpiresAt != nil && !now.Before(*r.ExpiresAt) {
+			delete(i.rules, id)
+		}
+	}
+}
+
+// sleepContext 等待 d 或 ctx 结束
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
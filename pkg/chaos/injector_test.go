@@ -0,0 +1,151 @@
+//go:build !prod
+
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInjector 不真正等待的注入器，记录每次等待的时长
+func newTestInjector() (*Injector, *[]time.Duration) {
+	injector := NewInjector()
+	var slept []time.Duration
+	injector.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return injector, &slept
+}
+
+func TestInjector_Matching(t *testing.T) {
+	injector, slept := newTestInjector()
+	_, err := injector.Add(Fault{Target: TargetDatabase, Operations: []string{"read"}, Match: "users", ErrorRate: 1, Error: "replica down"})
+	require.NoError(t, err)
+	_, err = injector.Add(Fault{Target: TargetCache, Match: "user:", LatencyMS: 30})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = injector.DatabaseHook(ctx, "read", "SELECT * FROM USERS WHERE id = $1")
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.Contains(t, err.Error(), "replica down")
+	assert.NoError(t, injector.DatabaseHook(ctx, "write", "UPDATE users SET name = $1"))
+	assert.NoError(t, injector.DatabaseHook(ctx, "read", "SELECT * FROM coupons"))
+
+	assert.NoError(t, injector.Inject(ctx, TargetCache, "get", "user:1"))
+	assert.NoError(t, injector.Inject(ctx, TargetCache, "get", "coupon:1"))
+	assert.Equal(t, []time.Duration{30 * time.Millisecond}, *slept)
+
+	faults := injector.List()
+	require.Len(t, faults, 2)
+	assert.Equal(t, int64(1), faults[0].Hits)
+	assert.Equal(t, int64(1), faults[0].Injected)
+	assert.Equal(t, int64(1), faults[1].Hits)
+
+	assert.True(t, injector.Remove(faults[0].ID))
+	assert.False(t, injector.Remove(faults[0].ID))
+	injector.Clear()
+	assert.Empty(t, injector.List())
+}
+
+func TestInjector_DeterministicErrorRate(t *testing.T) {
+	run := func() []bool {
+		injector, _ := newTestInjector()
+		_, err := injector.Add(Fault{Target: TargetCache, ErrorRate: 0.3, Seed: 42})
+		require.NoError(t, err)
+		results := make([]bool, 200)
+		for i := range results {
+			results[i] = injector.Inject(context.Background(), TargetCache, "get", "k") != nil
+		}
+		return results
+	}
+
+	first, second := run(), run()
+	assert.Equal(t, first, second)
+	failures := 0
+	for _, failed := range first {
+		if failed {
+			failures++
+		}
+	}
+	assert.InDelta(t, 60, failures, 25)
+}
+
+func TestInjector_Expiry(t *testing.T) {
+	injector, _ := newTestInjector()
+	now := time.Now()
+	injector.now = func() time.Time { return now }
+
+	_, err := injector.Add(Fault{Target: TargetCache, ErrorRate: 1, MaxHits: 2})
+	require.NoError(t, err)
+	_, err = injector.Add(Fault{Target: TargetDatabase, ErrorRate: 1, DurationSeconds: 60})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	assert.Error(t, injector.Inject(ctx, TargetCache, "get", "k"))
+	assert.Error(t, injector.Inject(ctx, TargetCache, "get", "k"))
+	assert.NoError(t, injector.Inject(ctx, TargetCache, "get", "k"))
+
+	assert.Error(t, injector.DatabaseHook(ctx, "read", "SELECT 1"))
+	now = now.Add(time.Minute)
+	assert.NoError(t, injector.DatabaseHook(ctx, "read", "SELECT 1"))
+	assert.Empty(t, injector.List())
+}
+
+func TestInjector_Validation(t *testing.T) {
+	injector := NewInjector()
+	_, err := injector.Add(Fault{Target: "queue", ErrorRate: 1})
+	assert.Error(t, err)
+	_, err = injector.Add(Fault{Target: TargetCache})
+	assert.Error(t, err)
+	_, err = injector.Add(Fault{Target: TargetCache, ErrorRate: 1.5})
+	assert.Error(t, err)
+
+	// 延迟等待随 ctx 取消
+	_, err = injector.Add(Fault{Target: TargetCache, LatencyMS: 60000})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, injector.Inject(ctx, TargetCache, "get", "k"), context.Canceled)
+}
+
+func TestFaultyCache_PartialOutage(t *testing.T) {
+	injector, _ := newTestInjector()
+	inner := cache.NewMemoryCache()
+	faulty := WrapCache(inner, injector)
+	ctx := context.Background()
+
+	require.NoError(t, faulty.Set(ctx, "user:1", "a", time.Minute))
+	require.NoError(t, faulty.Set(ctx, "coupon:1", "b", time.Minute))
+
+	// user: 前缀的键不可用，其余正常
+	_, err := injector.Add(Fault{Target: TargetCache, Match: "user:", ErrorRate: 1})
+	require.NoError(t, err)
+
+	var value string
+	assert.ErrorIs(t, faulty.Get(ctx, "user:1", &value), ErrInjected)
+	require.NoError(t, faulty.Get(ctx, "coupon:1", &value))
+	assert.Equal(t, "b", value)
+
+	result, err := faulty.GetMany(ctx, []string{"user:1", "coupon:1", "coupon:2"})
+	require.NoError(t, err)
+	assert.True(t, errors.Is(result.Errors["user:1"], ErrInjected))
+	assert.Contains(t, result.Values, "coupon:1")
+	assert.Equal(t, []string{"coupon:2"}, result.Missing)
+
+	setResult, err := faulty.SetMany(ctx, []cache.CacheEntry{{Key: "user:2", Value: 1}, {Key: "coupon:3", Value: 2}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"coupon:3"}, setResult.Succeeded)
+	assert.Contains(t, setResult.Errors, "user:2")
+	exists, err := inner.Exists(ctx, "user:2")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.Same(t, inner, WrapCache(inner, nil))
+}
@@ -0,0 +1,74 @@
+package clock
+
+import "time"
+
+// Clock 时间来源，依赖时间的组件（调度器、延迟任务、限流、会话过期）通过它取当前时间与创建定时器，
+// 生产环境使用 Real，测试中注入 fakes.Clock 手动推进时间
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	// After 经过 d 后向返回的通道发送当时的时间
+	After(d time.Duration) <-chan time.Time
+	// NewTicker 每隔 d 向 C() 发送一次时间，接收方跟不上时丢弃，与 time.Ticker 相同
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc 经过 d 后调用 f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker 周期定时器
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer 单次定时器，Stop 在定时器尚未触发时返回 true
+type Timer interface {
+	Stop() bool
+}
+
+// Real 系统时钟
+var Real Clock = realClock{}
+
+// OrReal c 为 nil 时返回 Real，用于配置中可选的 Clock 字段
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// realClock 基于 time 包的实现
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// realTicker 包装 time.Ticker
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
@@ -0,0 +1,138 @@
+package ctxutil
+
+import (
+	"context"
+	"time"
+)
+
+// Detach 派生一个不随父 ctx 取消的上下文，保留父 ctx 中的值（trace、请求 ID 等）。
+// 仅用于请求结束后仍需完成的后台操作，例如异步回填缓存、延迟删除。
+func Detach(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(context.WithoutCancel(parent), timeout)
+}
+
+type requestIDKey struct{}
+
+type correlationIDKey struct{}
+
+// WithRequestID 将请求 ID 写入上下文
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID 获取上下文中的请求 ID，不存在时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// WithCorrelationID 将关联 ID 写入上下文，关联 ID 在跨服务调用链中保持不变
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID 获取上下文中的关联 ID，不存在时返回空字符串
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
+
+type tenantIDKey struct{}
+
+// DefaultTenantID 未指定租户的请求与存量数据所属的租户
+const DefaultTenantID = "default"
+
+// WithTenantID 将租户 ID 写入上下文
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey{}, tenantID)
+}
+
+// TenantID 获取上下文中的租户 ID，不存在时返回空字符串。
+// 没有租户的上下文（后台任务等系统调用）不受租户隔离限制
+func TenantID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenantID, _ := ctx.Value(tenantIDKey{}).(string)
+	return tenantID
+}
+
+type clientKey struct{}
+
+// Client 发起请求的客户端信息
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// WithClient 将客户端信息写入上下文
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFrom 获取上下文中的客户端信息，不存在时返回零值
+func ClientFrom(ctx context.Context) Client {
+	if ctx == nil {
+		return Client{}
+	}
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+type userIDKey struct{}
+
+// WithUserID 将当前用户 ID 写入上下文，用于数据库行级安全的所有者限定
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID 获取上下文中的用户 ID，不存在时返回空字符串。
+// 管理员的上下文以 WithAllOwners 标记，不写入用户 ID
+func UserID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	userID, _ := ctx.Value(userIDKey{}).(string)
+	return userID
+}
+
+type allOwnersKey struct{}
+
+// WithAllOwners 标记上下文可访问租户内所有用户的数据（管理员），数据库行级安全只按租户限定
+func WithAllOwners(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allOwnersKey{}, true)
+}
+
+// AllOwners 上下文是否以 WithAllOwners 标记
+func AllOwners(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	allOwners, _ := ctx.Value(allOwnersKey{}).(bool)
+	return allOwners
+}
+
+type callerKey struct{}
+
+// WithCaller 将调用方标签写入上下文：HTTP 为 "方法 路由模板"，gRPC 为完整方法名，用于按调用方归因缓存等指标
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller 获取上下文中的调用方标签，不存在时返回空字符串（后台任务等没有入口请求的调用）
+func Caller(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
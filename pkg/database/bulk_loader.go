@@ -0,0 +1,261 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresMaxParams PostgreSQL 单条语句允许的最大绑定参数数量
+const postgresMaxParams = 65535
+
+// BulkLoaderConfig 批量加载配置
+type BulkLoaderConfig struct {
+	BatchSize       int                `json:"batch_size"`
+	ContinueOnError bool               `json:"continue_on_error"` // 批次失败时逐行重试并记录错误行
+	EnableMetrics   bool               `json:"enable_metrics"`
+	OnProgress      func(BulkProgress) `json:"-"`
+}
+
+// DefaultBulkLoaderConfig 默认批量加载配置
+func DefaultBulkLoaderConfig() *BulkLoaderConfig {
+	return &BulkLoaderConfig{
+		BatchSize:       1000,
+		ContinueOnError: true,
+		EnableMetrics:   true,
+	}
+}
+
+// BulkProgress 批量加载进度
+type BulkProgress struct {
+	Table     string `json:"table"`
+	Total     int64  `json:"total"`
+	Processed int64  `json:"processed"`
+	Succeeded int64  `json:"succeeded"`
+	Failed    int64  `json:"failed"`
+}
+
+// RowError 失败行
+type RowError struct {
+	Index int           `json:"index"` // 在输入 rows 中的下标
+	Row   []interface{} `json:"row"`
+	Err   error         `json:"-"`
+}
+
+// BulkResult 批量加载结果
+type BulkResult struct {
+	Succeeded int64      `json:"succeeded"`
+	Failed    []RowError `json:"failed"`
+}
+
+// UpsertSpec 批量 upsert 定义
+type UpsertSpec struct {
+	Table           string
+	Columns         []string
+	ConflictColumns []string
+	UpdateColumns   []string // 为空时更新所有非冲突列
+	DoNothing       bool     // 冲突时忽略
+}
+
+// BulkLoader PostgreSQL 批量加载器，支持 COPY 与 INSERT ... ON CONFLICT
+type BulkLoader struct {
+	pool             *pgxpool.Pool
+	config           *BulkLoaderConfig
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewBulkLoader 创建批量加载器
+func NewBulkLoader(db *UnifiedDB, config *BulkLoaderConfig) *BulkLoader {
+	if config == nil {
+		config = DefaultBulkLoaderConfig()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	return &BulkLoader{
+		pool:             db.GetPool(),
+		config:           config,
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// Copy 使用 COPY 协议加载数据，适用于初始导入和迁移
+func (bl *BulkLoader) Copy(ctx context.Context, table string, columns []string, rows [][]interface{}) (*BulkResult, error) {
+	start := time.Now()
+	result := &BulkResult{}
+	progress := BulkProgress{Table: table, Total: int64(len(rows))}
+	insertSQL := buildInsertSQL(table, columns, 1)
+
+	for offset := 0; offset < len(rows); offset += bl.config.BatchSize {
+		batch := rows[offset:min(offset+bl.config.BatchSize, len(rows))]
+
+		copied, err := bl.pool.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(batch))
+		if err != nil {
+			if !bl.config.ContinueOnError || ctx.Err() != nil {
+				bl.recordMetrics("copy_error", time.Since(start), false)
+				return result, fmt.Errorf("failed to copy batch at row %d: %w", offset, err)
+			}
+			// COPY 批次整体失败，逐行插入以定位错误行
+			copied = bl.insertRows(ctx, insertSQL, batch, offset, result)
+		}
+
+		result.Succeeded += copied
+		bl.reportProgress(&progress, len(batch), result)
+	}
+
+	bl.recordMetrics("copy", time.Since(start), len(result.Failed) == 0)
+	return result, nil
+}
+
+// Upsert 使用 INSERT ... ON CONFLICT 批量写入
+func (bl *BulkLoader) Upsert(ctx context.Context, spec UpsertSpec, rows [][]interface{}) (*BulkResult, error) {
+	if len(spec.Columns) == 0 {
+		return nil, fmt.Errorf("upsert columns are required")
+	}
+	if len(spec.ConflictColumns) == 0 {
+		return nil, fmt.Errorf("upsert conflict columns are required")
+	}
+
+	start := time.Now()
+	result := &BulkResult{}
+	progress := BulkProgress{Table: spec.Table, Total: int64(len(rows))}
+
+	// 受限于 PostgreSQL 参数数量上限
+	batchSize := min(bl.config.BatchSize, postgresMaxParams/len(spec.Columns))
+	conflictClause := buildConflictClause(spec)
+	singleSQL := buildInsertSQL(spec.Table, spec.Columns, 1) + conflictClause
+
+	for offset := 0; offset < len(rows); offset += batchSize {
+		batch := rows[offset:min(offset+batchSize, len(rows))]
+
+		args := make([]interface{}, 0, len(batch)*len(spec.Columns))
+		for i, row := range batch {
+			if len(row) != len(spec.Columns) {
+				return result, fmt.Errorf("row %d has %d values, expected %d", offset+i, len(row), len(spec.Columns))
+			}
+			args = append(args, row...)
+		}
+
+		query := buildInsertSQL(spec.Table, spec.Columns, len(batch)) + conflictClause
+		tag, err := bl.pool.Exec(ctx, query, args...)
+		if err != nil {
+			if !bl.config.ContinueOnError || ctx.Err() != nil {
+				bl.recordMetrics("upsert_error", time.Since(start), false)
+				return result, fmt.Errorf("failed to upsert batch at row %d: %w", offset, err)
+			}
+			result.Succeeded += bl.insertRows(ctx, singleSQL, batch, offset, result)
+		} else {
+			result.Succeeded += tag.RowsAffected()
+		}
+
+		bl.reportProgress(&progress, len(batch), result)
+	}
+
+	bl.recordMetrics("upsert", time.Since(start), len(result.Failed) == 0)
+	return result, nil
+}
+
+// insertRows 逐行执行，记录失败行，返回成功行数
+func (bl *BulkLoader) insertRows(ctx context.Context, query string, batch [][]interface{}, offset int, result *BulkResult) int64 {
+	var succeeded int64
+	for i, row := range batch {
+		tag, err := bl.pool.Exec(ctx, query, row...)
+		if err != nil {
+			result.Failed = append(result.Failed, RowError{Index: offset + i, Row: row, Err: err})
+			continue
+		}
+		succeeded += tag.RowsAffected()
+	}
+	return succeeded
+}
+
+// reportProgress 更新并回调进度
+func (bl *BulkLoader) reportProgress(progress *BulkProgress, batchLen int, result *BulkResult) {
+	progress.Processed += int64(batchLen)
+	progress.Succeeded = result.Succeeded
+	progress.Failed = int64(len(result.Failed))
+	if bl.config.OnProgress != nil {
+		bl.config.OnProgress(*progress)
+	}
+}
+
+// recordMetrics 记录指标
+func (bl *BulkLoader) recordMetrics(operation string, duration time.Duration, success bool) {
+	if !bl.config.EnableMetrics {
+		return
+	}
+
+	bl.metricsCollector.RecordDBQuery("bulk_loader", operation, duration, success)
+	if !success {
+		bl.metricsCollector.RecordDBError("bulk_loader_error", operation)
+	}
+}
+
+// quoteIdentifier 转义标识符，支持 schema.table
+func quoteIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// buildInsertSQL 构造多行 INSERT 语句
+func buildInsertSQL(table string, columns []string, rowCount int) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ", quoteIdentifier(table), strings.Join(quoted, ", "))
+
+	param := 1
+	for r := 0; r < rowCount; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('(')
+		for c := range columns {
+			if c > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", param)
+			param++
+		}
+		sb.WriteByte(')')
+	}
+	return sb.String()
+}
+
+// buildConflictClause 构造 ON CONFLICT 子句
+func buildConflictClause(spec UpsertSpec) string {
+	conflict := make([]string, len(spec.ConflictColumns))
+	isConflict := make(map[string]bool, len(spec.ConflictColumns))
+	for i, column := range spec.ConflictColumns {
+		conflict[i] = quoteIdentifier(column)
+		isConflict[column] = true
+	}
+	clause := fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(conflict, ", "))
+
+	updateColumns := spec.UpdateColumns
+	if len(updateColumns) == 0 {
+		for _, column := range spec.Columns {
+			if !isConflict[column] {
+				updateColumns = append(updateColumns, column)
+			}
+		}
+	}
+
+	if spec.DoNothing || len(updateColumns) == 0 {
+		return clause + " DO NOTHING"
+	}
+
+	sets := make([]string, len(updateColumns))
+	for i, column := range updateColumns {
+		quoted := quoteIdentifier(column)
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoted, quoted)
+	}
+	return clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/cache"
+)
+
+// 聚合粒度
+const (
+	ResolutionMinute = "minute"
+	ResolutionHour   = "hour"
+)
+
+// CacheStatsStoreConfig 缓存统计存储配置
+type CacheStatsStoreConfig struct {
+	MinuteRetention time.Duration `json:"minute_retention"` // 分钟级数据保留时间
+	HourRetention   time.Duration `json:"hour_retention"`   // 小时级数据保留时间
+	MinuteRangeMax  time.Duration `json:"minute_range_max"` // 查询范围不超过该值时使用分钟级数据
+}
+
+// DefaultCacheStatsStoreConfig 默认缓存统计存储配置
+func DefaultCacheStatsStoreConfig() *CacheStatsStoreConfig {
+	return &CacheStatsStoreConfig{
+		MinuteRetention: 48 * time.Hour,
+		HourRetention:   90 * 24 * time.Hour,
+		MinuteRangeMax:  6 * time.Hour,
+	}
+}
+
+// CacheStatsRollup 聚合数据行
+type CacheStatsRollup struct {
+	BucketStart  time.Time `db:"bucket_start" json:"bucket_start"`
+	Samples      int64     `db:"samples" json:"samples"`
+	HitRateSum   float64   `db:"hit_rate_sum" json:"-"`
+	ErrorRateSum float64   `db:"error_rate_sum" json:"-"`
+	LatencySumUs int64     `db:"latency_sum_us" json:"-"`
+	LatencyMaxUs int64     `db:"latency_max_us" json:"-"`
+	MemorySum    int64     `db:"memory_sum" json:"-"`
+	MemoryMax    int64     `db:"memory_max" json:"-"`
+}
+
+// CacheStatsStore 缓存统计的分钟/小时聚合存储，实现 cache.StatsSink
+type CacheStatsStore struct {
+	db     *DB
+	config *CacheStatsStoreConfig
+}
+
+var _ cache.StatsSink = (*CacheStatsStore)(nil)
+
+// NewCacheStatsStore 创建缓存统计存储
+func NewCacheStatsStore(db *DB, config *CacheStatsStoreConfig) *CacheStatsStore {
+	if config == nil {
+		config = DefaultCacheStatsStoreConfig()
+	}
+	return &CacheStatsStore{db: db, config: config}
+}
+
+// Write 将快照累加到所属的分钟与小时桶
+func (s *CacheStatsStore) Write(ctx context.Context, snapshot cache.CacheSnapshot) error {
+	query := `
+		INSERT INTO cache_stats_rollups (resolution, bucket_start, samples, hit_rate_sum, error_rate_sum,
+			latency_sum_us, latency_max_us, memory_sum, memory_max)
+		VALUES ($1, $2, 1, $3, $4, $5, $5, $6, $6)
+		ON CONFLICT (resolution, bucket_start) DO UPDATE SET
+			samples = cache_stats_rollups.samples + 1,
+			hit_rate_sum = cache_stats_rollups.hit_rate_sum + EXCLUDED.hit_rate_sum,
+			error_rate_sum = cache_stats_rollups.error_rate_sum + EXCLUDED.error_rate_sum,
+			latency_sum_us = cache_stats_rollups.latency_sum_us + EXCLUDED.latency_sum_us,
+			latency_max_us = GREATEST(cache_stats_rollups.latency_max_us, EXCLUDED.latency_max_us),
+			memory_sum = cache_stats_rollups.memory_sum + EXCLUDED.memory_sum,
+			memory_max = GREATEST(cache_stats_rollups.memory_max, EXCLUDED.memory_max)`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin cache stats transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	latencyUs := snapshot.AvgResponseTime.Microseconds()
+	buckets := map[string]time.Time{
+		ResolutionMinute: snapshot.Timestamp.Truncate(time.Minute),
+		ResolutionHour:   snapshot.Timestamp.Truncate(time.Hour),
+	}
+	for resolution, bucket := range buckets {
+		if _, err := tx.ExecContext(ctx, query, resolution, bucket,
+			snapshot.HitRate, snapshot.ErrorRate, latencyUs, snapshot.MemoryUsage); err != nil {
+			return fmt.Errorf("failed to write %s cache stats: %w", resolution, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cache stats: %w", err)
+	}
+	return nil
+}
+
+// Query 查询指定粒度与时间范围的聚合数据
+func (s *CacheStatsStore) Query(ctx context.Context, resolution string, start, end time.Time) ([]CacheStatsRollup, error) {
+	if resolution != ResolutionMinute && resolution != ResolutionHour {
+		return nil, fmt.Errorf("unsupported resolution: %s", resolution)
+	}
+
+	query := `
+		SELECT bucket_start, samples, hit_rate_sum, error_rate_sum, latency_sum_us, latency_max_us, memory_sum, memory_max
+		FROM cache_stats_rollups
+		WHERE resolution = $1 AND bucket_start >= $2 AND bucket_start <= $3
+		ORDER BY bucket_start`
+
+	var rollups []CacheStatsRollup
+	if err := s.db.SelectContext(ReportQuery(ctx), &rollups, query, resolution, start.Truncate(time.Minute), end); err != nil {
+		return nil, fmt.Errorf("failed to query cache stats: %w", err)
+	}
+	return rollups, nil
+}
+
+// QueryTrends 按时间范围查询趋势，短范围使用分钟级数据，长范围使用小时级数据
+func (s *CacheStatsStore) QueryTrends(ctx context.Context, start, end time.Time) ([]cache.TrendData, error) {
+	resolution := ResolutionHour
+	if end.Sub(start) <= s.config.MinuteRangeMax {
+		resolution = ResolutionMinute
+	}
+
+	rollups, err := s.Query(ctx, resolution, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	trends := make([]cache.TrendData, 0, len(rollups)*6)
+	for _, r := range rollups {
+		if r.Samples == 0 {
+			continue
+		}
+		n := float64(r.Samples)
+		trends = append(trends,
+			cache.TrendData{Timestamp: r.BucketStart, Metric: cache.TrendHitRate, Value: r.HitRateSum / n},
+			cache.TrendData{Timestamp: r.BucketStart, Metric: cache.TrendErrorRate, Value: r.ErrorRateSum / n},
+			cache.TrendData{Timestamp: r.BucketStart, Metric: cache.TrendAvgLatencyMs, Value: float64(r.LatencySumUs) / n / 1000},
+			cache.TrendData{Timestamp: r.BucketStart, Metric: cache.TrendMaxLatencyMs, Value: float64(r.LatencyMaxUs) / 1000},
+			cache.TrendData{Timestamp: r.BucketStart, Metric: cache.TrendMemoryUsage, Value: float64(r.MemorySum) / n},
+			cache.TrendData{Timestamp: r.BucketStart, Metric: cache.TrendMemoryMax, Value: float64(r.MemoryMax)},
+		)
+	}
+	return trends, nil
+}
+
+// Prune 删除超过保留期的聚合数据
+func (s *CacheStatsStore) Prune(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ReportQuery(ctx), `
+		DELETE FROM cache_stats_rollups
+		WHERE (resolution = $1 AND bucket_start < $2) OR (resolution = $3 AND bucket_start < $4)`,
+		ResolutionMinute, time.Now().Add(-s.config.MinuteRetention),
+		ResolutionHour, time.Now().Add(-s.config.HourRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune cache stats: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"user_crud_jwt/internal/pkg/config"
+)
+
+// Component 建立连接的组件，决定连接的 application_name 与登录角色
+type Component string
+
+const (
+	ComponentAPI     Component = "api"     // HTTP、gRPC 请求与进程内的后台任务
+	ComponentWarmup  Component = "warmup"  // 缓存预热，只读
+	ComponentJobs    Component = "jobs"    // 任务队列与数据保留
+	ComponentMigrate Component = "migrate" // 迁移、分区维护与 dbctl，执行 DDL，为各表所有者
+)
+
+// Components 全部组件，按授权迁移中的顺序
+func Components() []Component {
+	return []Component{ComponentAPI, ComponentWarmup, ComponentJobs, ComponentMigrate}
+}
+
+// maxApplicationNameLen PostgreSQL 截断超过 NAMEDATALEN-1 字节的 application_name
+const maxApplicationNameLen = 63
+
+// ApplicationName 组件连接的 application_name：<database.application_name>-<组件>
+func ApplicationName(cfg config.DatabaseConfig, component Component) string {
+	name := string(component)
+	if cfg.ApplicationName != "" {
+		name = cfg.ApplicationName + "-" + name
+	}
+	if len(name) > maxApplicationNameLen {
+		name = name[:maxApplicationNameLen]
+	}
+	return name
+}
+
+// RoleOf 组件登录使用的角色与密码，database.roles 未配置该组件时使用 database.user
+func RoleOf(cfg config.DatabaseConfig, component Component) (user, password string) {
+	if role, ok := cfg.Roles[string(component)]; ok && role.User != "" {
+		return role.User, role.Password
+	}
+	return cfg.User, cfg.Password
+}
+
+// maxOpenConns 组件连接池的最大连接数，api 承接请求使用 100，其他组件 10
+func maxOpenConns(cfg config.DatabaseConfig, component Component) int {
+	if role, ok := cfg.Roles[string(component)]; ok && role.MaxOpenConns > 0 {
+		return role.MaxOpenConns
+	}
+	if component == ComponentAPI {
+		return 100
+	}
+	return 10
+}
+
+// ConnectionString 组件的 key=value 连接串，带 application_name 与组件的登录角色
+func ConnectionString(cfg config.DatabaseConfig, component Component) string {
+	user, password := RoleOf(cfg, component)
+	pairs := []struct{ key, value string }{
+		{"host", cfg.Host},
+		{"user", user},
+		{"password", password},
+		{"dbname", cfg.DBName},
+		{"port", cfg.Port},
+		{"sslmode", cfg.SSLMode},
+		{"TimeZone", cfg.TimeZone},
+		{"application_name", ApplicationName(cfg, component)},
+	}
+	parts := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		parts = append(parts, pair.key+"="+quoteDSNValue(pair.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// MigrationURL golang-migrate 使用的 URL 连接串，以 migrate 组件的角色登录
+func MigrationURL(cfg config.DatabaseConfig) string {
+	user, password := RoleOf(cfg, ComponentMigrate)
+	query := url.Values{}
+	query.Set("sslmode", cfg.SSLMode)
+	query.Set("application_name", ApplicationName(cfg, ComponentMigrate))
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Path:     "/" + cfg.DBName,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// withApplicationName 为副本与分片的连接串补充 application_name，连接串中已指定时不修改
+func withApplicationName(dsn, name string) string {
+	if name == "" || strings.Contains(dsn, "application_name=") {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		query := u.Query()
+		query.Set("application_name", name)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+	return dsn + " application_name=" + quoteDSNValue(name)
+}
+
+// quoteDSNValue 按 libpq 规则引用 key=value 连接串中的值：空值或含空格、引号、反斜杠时加单引号并转义
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + replacer.Replace(value) + "'"
+}
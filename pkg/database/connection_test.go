@@ -0,0 +1,57 @@
+package database_test
+
+import (
+	"testing"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDatabaseConfig() config.DatabaseConfig {
+	return config.DatabaseConfig{
+		Host: "db", Port: "5432", DBName: "app", SSLMode: "disable", TimeZone: "Asia/Shanghai",
+		User: "app_owner", Password: "secret", ApplicationName: "user_crud_jwt",
+		Roles: map[string]config.DatabaseRoleConfig{
+			"api":  {User: "app_api", Password: "p@ss word"},
+			"jobs": {User: "app_jobs", Password: "it's"},
+		},
+	}
+}
+
+// TestConnectionString 各组件带自己的 application_name 与角色，未配置角色的组件使用 database.user
+func TestConnectionString(t *testing.T) {
+	cfg := testDatabaseConfig()
+
+	assert.Equal(t, "host=db user=app_api password='p@ss word' dbname=app port=5432 sslmode=disable TimeZone=Asia/Shanghai application_name=user_crud_jwt-api",
+		database.ConnectionString(cfg, database.ComponentAPI))
+	assert.Contains(t, database.ConnectionString(cfg, database.ComponentJobs), `user=app_jobs password='it\'s'`)
+	assert.Contains(t, database.ConnectionString(cfg, database.ComponentWarmup), "user=app_owner password=secret")
+	assert.Contains(t, database.ConnectionString(cfg, database.ComponentWarmup), "application_name=user_crud_jwt-warmup")
+
+	assert.Equal(t, "postgres://app_owner:secret@db:5432/app?application_name=user_crud_jwt-migrate&sslmode=disable",
+		database.MigrationURL(cfg))
+}
+
+// TestGenerateRoleGrants 只为与所有者不同的角色授权，现有表与之后新建的表都授权，down 撤销相同的权限
+func TestGenerateRoleGrants(t *testing.T) {
+	grants, err := database.GenerateRoleGrants("app_owner", map[database.Component]string{
+		database.ComponentAPI:     "app_api",
+		database.ComponentWarmup:  "app_warmup",
+		database.ComponentJobs:    "app_owner",
+		database.ComponentMigrate: "app_owner",
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, grants.Up, `GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO "app_api";`)
+	assert.Contains(t, grants.Up, `ALTER DEFAULT PRIVILEGES FOR ROLE "app_owner" IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO "app_api";`)
+	assert.Contains(t, grants.Up, `GRANT SELECT ON ALL TABLES IN SCHEMA public TO "app_warmup";`)
+	assert.NotContains(t, grants.Up, `ON ALL SEQUENCES IN SCHEMA public TO "app_warmup"`)
+	assert.NotContains(t, grants.Up, "-- jobs")
+	assert.Contains(t, grants.Down, `REVOKE ALL ON ALL TABLES IN SCHEMA public FROM "app_warmup";`)
+	assert.Contains(t, grants.Down, `ALTER DEFAULT PRIVILEGES FOR ROLE "app_owner" IN SCHEMA public REVOKE ALL ON TABLES FROM "app_api";`)
+
+	_, err = database.GenerateRoleGrants("app_owner", map[database.Component]string{database.ComponentAPI: "app_owner"})
+	assert.Error(t, err)
+}
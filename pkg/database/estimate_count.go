@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// EstimateCount 通过 pg_class.reltuples 估算表行数，避免大表上的 COUNT(*)
+// 表从未 ANALYZE 时返回 -1
+func (db *DB) EstimateCount(ctx context.Context, table string) (int64, error) {
+	var estimate float64
+	err := db.GetContext(ctx, &estimate,
+		"SELECT reltuples FROM pg_class WHERE oid = to_regclass($1)", table)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate count for %s: %w", table, err)
+	}
+	if estimate < 0 {
+		return -1, nil
+	}
+	return int64(estimate), nil
+}
+
+// CountOrEstimate 估算值低于 threshold 时执行精确计数，否则返回估算值
+func (db *DB) CountOrEstimate(ctx context.Context, table string, threshold int64) (int64, bool, error) {
+	estimate, err := db.EstimateCount(ctx, table)
+	if err != nil {
+		return 0, false, err
+	}
+	if estimate >= threshold {
+		return estimate, true, nil
+	}
+
+	var count int64
+	if err := db.GetContext(ctx, &count, "SELECT COUNT(*) FROM "+quoteIdentifier(table)); err != nil {
+		return 0, false, fmt.Errorf("failed to count %s: %w", table, err)
+	}
+	return count, false, nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"strings"
+)
+
+// FaultHook 查询执行前的回调，operation 为 read（SELECT / WITH）或 write，返回错误时查询不执行。
+// 供非生产构建的故障注入使用
+type FaultHook func(ctx context.Context, operation, query string) error
+
+// SetFaultHook 设置故障注入回调，需在处理请求前设置。注入的延迟计入慢查询，便于验证慢查询告警
+func (db *DB) SetFaultHook(hook FaultHook) {
+	db.faultHook = hook
+}
+
+// injectFault 执行故障注入回调
+func (db *DB) injectFault(ctx context.Context, query string) error {
+	if db.faultHook == nil {
+		return nil
+	}
+	return db.faultHook(ctx, queryOperation(query), query)
+}
+
+// queryOperation 按语句开头区分读写
+func queryOperation(query string) string {
+	trimmed := strings.ToLower(strings.TrimSpace(query))
+	if strings.HasPrefix(trimmed, "select") || strings.HasPrefix(trimmed, "with") {
+		return "read"
+	}
+	return "write"
+}
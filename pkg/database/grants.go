@@ -0,0 +1,77 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RoleGrants 授权迁移的内容，Up 授予各组件角色最小权限，Down 撤销
+type RoleGrants struct {
+	Up   string
+	Down string
+}
+
+// componentPrivileges 各组件在 public 模式上需要的表与序列权限，migrate 为所有者不需要授权
+var componentPrivileges = map[Component]struct {
+	tables    string
+	sequences string
+}{
+	ComponentAPI:    {tables: "SELECT, INSERT, UPDATE, DELETE", sequences: "USAGE, SELECT"},
+	ComponentWarmup: {tables: "SELECT"},
+	ComponentJobs:   {tables: "SELECT, INSERT, UPDATE, DELETE", sequences: "USAGE, SELECT"},
+}
+
+// GenerateRoleGrants 生成授权迁移：owner 为执行迁移并拥有各表的角色，roles 为组件到登录角色的映射，
+// 与 owner 相同或未配置的组件跳过。除现有表外还修改 owner 的默认权限，之后迁移新建的表自动授权。
+// 创建与删除分区需要表所有者权限，由 migrate 组件的连接执行，不授予其他组件。
+// 角色需由 DBA 预先创建（CREATE ROLE ... LOGIN PASSWORD ...），迁移只授权
+func GenerateRoleGrants(owner string, roles map[Component]string) (*RoleGrants, error) {
+	if owner == "" {
+		return nil, errors.New("owner role is required")
+	}
+	ownerIdent := pgx.Identifier{owner}.Sanitize()
+
+	var up, down strings.Builder
+	fmt.Fprintf(&up, "-- 组件角色的最小权限，由 go run ./cmd/migrate -grants 生成；各表的所有者为 %s，角色需预先创建\n", ownerIdent)
+	down.WriteString("-- 撤销组件角色的权限，由 go run ./cmd/migrate -grants 生成\n")
+
+	granted := 0
+	for _, component := range Components() {
+		role := roles[component]
+		privileges, ok := componentPrivileges[component]
+		if role == "" || role == owner || !ok {
+			continue
+		}
+		granted++
+		ident := pgx.Identifier{role}.Sanitize()
+
+		fmt.Fprintf(&up, "\n-- %s\n", component)
+		fmt.Fprintf(&up, "GRANT USAGE ON SCHEMA public TO %s;\n", ident)
+		fmt.Fprintf(&up, "GRANT %s ON ALL TABLES IN SCHEMA public TO %s;\n", privileges.tables, ident)
+		fmt.Fprintf(&up, "ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA public GRANT %s ON TABLES TO %s;\n", ownerIdent, privileges.tables, ident)
+		if privileges.sequences != "" {
+			fmt.Fprintf(&up, "GRANT %s ON ALL SEQUENCES IN SCHEMA public TO %s;\n", privileges.sequences, ident)
+			fmt.Fprintf(&up, "ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA public GRANT %s ON SEQUENCES TO %s;\n", ownerIdent, privileges.sequences, ident)
+		}
+		if component == ComponentAPI {
+			// 锁监控与膨胀分析读取其他会话的语句与 pgstattuple 统计，授予预置角色需要超级用户
+			fmt.Fprintf(&up, "-- 超级用户执行：GRANT pg_monitor TO %s;\n", ident)
+		}
+
+		fmt.Fprintf(&down, "\n-- %s\n", component)
+		if privileges.sequences != "" {
+			fmt.Fprintf(&down, "ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA public REVOKE ALL ON SEQUENCES FROM %s;\n", ownerIdent, ident)
+			fmt.Fprintf(&down, "REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM %s;\n", ident)
+		}
+		fmt.Fprintf(&down, "ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA public REVOKE ALL ON TABLES FROM %s;\n", ownerIdent, ident)
+		fmt.Fprintf(&down, "REVOKE ALL ON ALL TABLES IN SCHEMA public FROM %s;\n", ident)
+		fmt.Fprintf(&down, "REVOKE USAGE ON SCHEMA public FROM %s;\n", ident)
+	}
+	if granted == 0 {
+		return nil, errors.New("no component uses a role other than the owner; configure database.roles")
+	}
+	return &RoleGrants{Up: up.String(), Down: down.String()}, nil
+}
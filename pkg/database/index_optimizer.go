@@ -66,9 +66,10 @@ type QueryPattern struct {
 
 // IndexRecommendation 索引推荐
 type IndexRecommendation struct {
+	IndexName     string   `json:"index_name"` // 新建索引的名称，或待删除的已有索引
 	Table         string   `json:"table"`
 	Columns       []string `json:"columns"`
-	Type          string   `json:"type"` // btree, hash, gin, gist
+	Type          string   `json:"type"` // btree, hash, gin, gist, drop
 	Reason        string   `json:"reason"`
 	Impact        string   `json:"impact"`         // high, medium, low
 	EstimatedGain float64  `json:"estimated_gain"` // 预估性能提升百分比
@@ -89,12 +90,12 @@ func (qa *QueryAnalyzer) AnalyzeQueries(ctx context.Context, duration time.Durat
 		SELECT 
 			query,
 			calls,
-			total_time,
-			mean_time,
+			total_exec_time,
+			mean_exec_time,
 			rows
 		FROM pg_stat_statements 
 		WHERE calls > 10 
-		ORDER BY total_time DESC 
+		ORDER BY total_exec_time DESC 
 		LIMIT 100
 	`
 
@@ -129,6 +130,44 @@ func (qa *QueryAnalyzer) AnalyzeQueries(ctx context.Context, duration time.Durat
 	return patterns, nil
 }
 
+// StatementStats pg_stat_statements 中一类语句的累计统计，耗时单位为毫秒
+type StatementStats struct {
+	Query      string  `json:"query"`
+	Calls      int64   `json:"calls"`
+	TotalTime  float64 `json:"total_time_ms"`
+	MeanTime   float64 `json:"mean_time_ms"`
+	MaxTime    float64 `json:"max_time_ms"`
+	Rows       int64   `json:"rows"`
+	SharedRead int64   `json:"shared_blks_read"` // 从磁盘读取的块数，偏高说明缺少索引或缓存不足
+}
+
+// SlowStatements 按平均耗时倒序返回平均耗时不低于 minMean 的语句，需要 pg_stat_statements 扩展
+func (qa *QueryAnalyzer) SlowStatements(ctx context.Context, minMean time.Duration, limit int) ([]StatementStats, error) {
+	query := `
+		SELECT query, calls, total_exec_time, mean_exec_time, max_exec_time, rows, shared_blks_read
+		FROM pg_stat_statements
+		WHERE mean_exec_time >= $1
+		ORDER BY mean_exec_time DESC
+		LIMIT $2
+	`
+
+	rows, err := qa.db.QueryContext(ctx, query, float64(minMean)/float64(time.Millisecond), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_stat_statements (is the extension installed?): %w", err)
+	}
+	defer rows.Close()
+
+	var stats []StatementStats
+	for rows.Next() {
+		var stat StatementStats
+		if err := rows.Scan(&stat.Query, &stat.Calls, &stat.TotalTime, &stat.MeanTime, &stat.MaxTime, &stat.Rows, &stat.SharedRead); err != nil {
+			return nil, fmt.Errorf("failed to scan statement stats: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
 // parseQuery 解析查询语句
 func (qa *QueryAnalyzer) parseQuery(query string, frequency int, avgTime float64) *QueryPattern {
 	// 简化的查询解析
@@ -182,17 +221,21 @@ func NewIndexAnalyzer(db *sql.DB) *IndexAnalyzer {
 
 // AnalyzeIndexes 分析现有索引
 func (ia *IndexAnalyzer) AnalyzeIndexes(ctx context.Context) ([]IndexInfo, error) {
+	// pg_indexes 没有唯一与主键标记，直接读取 pg_index
 	query := `
 		SELECT 
-			schemaname,
-			tablename,
-			indexname,
-			indexdef,
-			indisunique,
-			indisprimary
-		FROM pg_indexes 
-		WHERE schemaname = 'public'
-		ORDER BY tablename, indexname
+			n.nspname,
+			t.relname,
+			i.relname,
+			pg_get_indexdef(i.oid),
+			x.indisunique,
+			x.indisprimary
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = 'public'
+		ORDER BY t.relname, i.relname
 	`
 
 	rows, err := ia.db.QueryContext(ctx, query)
@@ -260,7 +303,7 @@ func (ia *IndexAnalyzer) getIndexStats(ctx context.Context, schema, table, index
 	usageQuery := `
 		SELECT idx_scan, idx_tup_read, idx_tup_fetch
 		FROM pg_stat_user_indexes 
-		WHERE schemaname = $1 AND tablename = $2 AND indexrelname = $3
+		WHERE schemaname = $1 AND relname = $2 AND indexrelname = $3
 	`
 
 	var scans, reads, fetches int64
@@ -273,7 +316,7 @@ func (ia *IndexAnalyzer) getIndexStats(ctx context.Context, schema, table, index
 	sizeQuery := `
 		SELECT pg_relation_size(indexrelid)
 		FROM pg_stat_user_indexes 
-		WHERE schemaname = $1 AND tablename = $2 AND indexrelname = $3
+		WHERE schemaname = $1 AND relname = $2 AND indexrelname = $3
 	`
 
 	var size int64
@@ -373,6 +416,7 @@ func (io *IndexOptimizer) analyzeTableQueries(table string, patterns []QueryPatt
 
 		if !hasIndex && len(whereColumns) > 0 {
 			recommendation := IndexRecommendation{
+				IndexName:     recommendedIndexName(table, whereColumns),
 				Table:         table,
 				Columns:       whereColumns,
 				Type:          "btree",
@@ -446,7 +490,7 @@ func (io *IndexOptimizer) isColumnName(word string) bool {
 	}
 
 	// 不包含常见的 SQL 关键字
-	keywords := []string{"true", "false", "null", "undefined"}
+	keywords := []string{"true", "false", "null", "undefined", "is", "not", "between", "ilike", "any"}
 	for _, keyword := range keywords {
 		if strings.ToLower(word) == keyword {
 			return false
@@ -547,14 +591,15 @@ func (io *IndexOptimizer) analyzeUnusedIndexes(existingIndexes []IndexInfo) []In
 	var recommendations []IndexRecommendation
 
 	for _, index := range existingIndexes {
-		// 跳过主键索引
-		if index.IsPrimary {
+		// 跳过主键与唯一索引，它们承担约束，不能因未被扫描而删除
+		if index.IsPrimary || index.IsUnique {
 			continue
 		}
 
 		// 检查索引使用情况
 		if index.Usage == 0 && time.Since(index.LastUsed) > time.Hour*24*7 {
 			recommendation := IndexRecommendation{
+				IndexName:     index.Name,
 				Table:         index.Table,
 				Columns:       index.Columns,
 				Type:          "drop",
@@ -570,59 +615,78 @@ func (io *IndexOptimizer) analyzeUnusedIndexes(existingIndexes []IndexInfo) []In
 	return recommendations
 }
 
-// CreateIndex 创建索引
-func (io *IndexOptimizer) CreateIndex(ctx context.Context, recommendation IndexRecommendation) error {
-	if recommendation.Type == "drop" {
-		return io.dropIndex(ctx, recommendation)
+// recommendedIndexName 新建索引的名称，非法字符替换为下划线并截断到 PostgreSQL 的 63 字节上限
+func recommendedIndexName(table string, columns []string) string {
+	var name string
+	if len(columns) > 0 {
+		name = fmt.Sprintf("idx_%s_%s", table, strings.Join(columns, "_"))
+	} else {
+		name = fmt.Sprintf("idx_%s_auto", table)
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// SQL 执行推荐所用的语句，新建与删除索引都使用 CONCURRENTLY，不阻塞写入，也不能在事务中执行
+func (r IndexRecommendation) SQL() string {
+	indexName := r.IndexName
+	if indexName == "" {
+		indexName = recommendedIndexName(r.Table, r.Columns)
 	}
 
-	var indexName string
-	if len(recommendation.Columns) > 0 {
-		indexName = fmt.Sprintf("idx_%s_%s", recommendation.Table, strings.Join(recommendation.Columns, "_"))
-	} else {
-		indexName = fmt.Sprintf("idx_%s_auto", recommendation.Table)
+	if r.Type == "drop" {
+		return fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s", quoteIdentifier(indexName))
 	}
 
-	var createSQL string
-	switch recommendation.Type {
-	case "btree":
-		createSQL = fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s (%s)",
-			indexName, recommendation.Table, strings.Join(recommendation.Columns, ", "))
-	case "hash":
-		createSQL = fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s USING hash (%s)",
-			indexName, recommendation.Table, strings.Join(recommendation.Columns, ", "))
-	default:
-		createSQL = fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s (%s)",
-			indexName, recommendation.Table, strings.Join(recommendation.Columns, ", "))
+	columns := make([]string, len(r.Columns))
+	for i, column := range r.Columns {
+		columns[i] = quoteIdentifier(column)
+	}
+	using := ""
+	if r.Type == "hash" {
+		using = " USING hash"
 	}
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s%s (%s)",
+		quoteIdentifier(indexName), quoteIdentifier(r.Table), using, strings.Join(columns, ", "))
+}
 
-	_, err := io.db.ExecContext(ctx, createSQL)
+// CreateIndex 创建索引，Type 为 drop 时删除索引
+func (io *IndexOptimizer) CreateIndex(ctx context.Context, recommendation IndexRecommendation) error {
+	if recommendation.Type == "drop" {
+		return io.dropIndex(ctx, recommendation)
+	}
+
+	_, err := io.db.ExecContext(ctx, recommendation.SQL())
 	if err != nil {
 		return fmt.Errorf("failed to create index: %w", err)
 	}
 
 	// 记录指标
-	io.metricsCollector.RecordDBError("index_created", recommendation.Table)
+	io.metricsCollector.RecordEvent("index_optimizer", "index_created", 1)
 
-	log.Printf("Created index: %s on table %s", indexName, recommendation.Table)
+	log.Printf("Created index: %s on table %s", recommendation.IndexName, recommendation.Table)
 	return nil
 }
 
 // dropIndex 删除索引
 func (io *IndexOptimizer) dropIndex(ctx context.Context, recommendation IndexRecommendation) error {
-	indexName := fmt.Sprintf("idx_%s_%s", recommendation.Table, strings.Join(recommendation.Columns, "_"))
-
-	dropSQL := fmt.Sprintf("DROP INDEX CONCURRENTLY %s", indexName)
-
-	_, err := io.db.ExecContext(ctx, dropSQL)
+	_, err := io.db.ExecContext(ctx, recommendation.SQL())
 	if err != nil {
 		return fmt.Errorf("failed to drop index: %w", err)
 	}
 
 	// 记录指标
-	io.metricsCollector.RecordDBError("index_dropped", recommendation.Table)
+	io.metricsCollector.RecordEvent("index_optimizer", "index_dropped", 1)
 
-	log.Printf("Dropped index: %s from table %s", indexName, recommendation.Table)
+	log.Printf("Dropped index: %s from table %s", recommendation.IndexName, recommendation.Table)
 	return nil
 }
 
@@ -677,14 +741,16 @@ func (io *IndexOptimizer) GetIndexStats(ctx context.Context) (map[string]interfa
 	stats["total_indexes"] = indexCount
 	stats["total_scans"] = totalScans
 	stats["total_size"] = totalSize
-	stats["avg_scans_per_index"] = float64(totalScans) / float64(indexCount)
+	if indexCount > 0 {
+		stats["avg_scans_per_index"] = float64(totalScans) / float64(indexCount)
+	}
 
 	return stats, nil
 }
 
 // RebuildIndex 重建索引
 func (io *IndexOptimizer) RebuildIndex(ctx context.Context, tableName, indexName string) error {
-	rebuildSQL := fmt.Sprintf("REINDEX INDEX %s ON %s", indexName, tableName)
+	rebuildSQL := fmt.Sprintf("REINDEX INDEX %s", quoteIdentifier(indexName))
 
 	_, err := io.db.ExecContext(ctx, rebuildSQL)
 	if err != nil {
@@ -692,15 +758,34 @@ func (io *IndexOptimizer) RebuildIndex(ctx context.Context, tableName, indexName
 	}
 
 	// 记录指标
-	io.metricsCollector.RecordDBError("index_rebuilt", tableName)
+	io.metricsCollector.RecordEvent("index_optimizer", "index_rebuilt", 1)
 
 	log.Printf("Rebuilt index: %s on table %s", indexName, tableName)
 	return nil
 }
 
+// ReindexTable 重建表上的所有索引；concurrently 为 true 时不阻塞写入，但耗时更长且不能在事务中执行
+func (io *IndexOptimizer) ReindexTable(ctx context.Context, tableName string, concurrently bool) error {
+	reindexSQL := fmt.Sprintf("REINDEX TABLE %s", quoteIdentifier(tableName))
+	if concurrently {
+		reindexSQL = fmt.Sprintf("REINDEX TABLE CONCURRENTLY %s", quoteIdentifier(tableName))
+	}
+
+	_, err := io.db.ExecContext(ctx, reindexSQL)
+	if err != nil {
+		return fmt.Errorf("failed to reindex table: %w", err)
+	}
+
+	// 记录指标
+	io.metricsCollector.RecordEvent("index_optimizer", "table_reindexed", 1)
+
+	log.Printf("Reindexed table: %s", tableName)
+	return nil
+}
+
 // AnalyzeTable 分析表统计信息
 func (io *IndexOptimizer) AnalyzeTable(ctx context.Context, tableName string) error {
-	analyzeSQL := fmt.Sprintf("ANALYZE %s", tableName)
+	analyzeSQL := fmt.Sprintf("ANALYZE %s", quoteIdentifier(tableName))
 
 	_, err := io.db.ExecContext(ctx, analyzeSQL)
 	if err != nil {
@@ -708,8 +793,13 @@ func (io *IndexOptimizer) AnalyzeTable(ctx context.Context, tableName string) er
 	}
 
 	// 记录指标
-	io.metricsCollector.RecordDBError("table_analyzed", tableName)
+	io.metricsCollector.RecordEvent("index_optimizer", "table_analyzed", 1)
 
 	log.Printf("Analyzed table: %s", tableName)
 	return nil
 }
+
+// SlowStatements 按平均耗时倒序返回慢语句，见 QueryAnalyzer.SlowStatements
+func (io *IndexOptimizer) SlowStatements(ctx context.Context, minMean time.Duration, limit int) ([]StatementStats, error) {
+	return io.queryAnalyzer.SlowStatements(ctx, minMean, limit)
+}
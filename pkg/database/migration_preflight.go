@@ -0,0 +1,400 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MigrationRisk 迁移语句的风险等级
+type MigrationRisk string
+
+const (
+	MigrationRiskInfo    MigrationRisk = "info"    // 短暂持有强锁，通常无需处理
+	MigrationRiskWarning MigrationRisk = "warning" // 扫描全表或阻塞写入，表较大时应在低峰执行
+	MigrationRiskDanger  MigrationRisk = "danger"  // 重写大表、长时间阻塞读写或在有数据时必然失败，生产环境需要 --force
+)
+
+// 语句持有的表级锁
+const (
+	LockAccessExclusive = "ACCESS EXCLUSIVE" // 阻塞所有读写
+	LockShare           = "SHARE"            // 阻塞写入
+	LockRowExclusive    = "ROW EXCLUSIVE"    // 普通 UPDATE / DELETE
+)
+
+// MigrationFinding 预检发现的一条需要关注的语句
+type MigrationFinding struct {
+	Version       uint          `json:"version"`
+	File          string        `json:"file"`
+	Statement     string        `json:"statement"`
+	Table         string        `json:"table"`
+	Lock          string        `json:"lock"`
+	Rewrite       bool          `json:"rewrite"` // 重写整张表
+	Scan          bool          `json:"scan"`    // 持锁扫描整张表
+	Risk          MigrationRisk `json:"risk"`
+	Message       string        `json:"message"`
+	EstimatedRows int64         `json:"estimated_rows"` // 估算行数，-1 表示未知
+}
+
+// MigrationFile 一个待执行的 up 迁移
+type MigrationFile struct {
+	Version uint
+	Name    string
+	SQL     string
+}
+
+// RowEstimator 估算表行数，*DB 实现了该接口
+type RowEstimator interface {
+	EstimateCount(ctx context.Context, table string) (int64, error)
+}
+
+// PreflightConfig 迁移预检配置
+type PreflightConfig struct {
+	// LargeTableRows 估算行数不少于该值的表视为大表，在其上重写或扫描全表升级为 danger
+	LargeTableRows int64 `json:"large_table_rows"`
+}
+
+// DefaultPreflightConfig 默认预检配置：一百万行以上为大表
+func DefaultPreflightConfig() *PreflightConfig {
+	return &PreflightConfig{LargeTableRows: 1_000_000}
+}
+
+// PreflightReport 预检结果
+type PreflightReport struct {
+	Migrations []MigrationFile    `json:"-"`
+	Findings   []MigrationFinding `json:"findings"`
+}
+
+// Dangerous 是否包含 danger 级别的语句
+func (r *PreflightReport) Dangerous() bool {
+	for _, finding := range r.Findings {
+		if finding.Risk == MigrationRiskDanger {
+			return true
+		}
+	}
+	return false
+}
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_.+\.up\.sql$`)
+
+// LoadPendingMigrations 读取 dir 中版本号大于 current 的 up 迁移，按版本号排序
+func LoadPendingMigrations(dir string, current uint) ([]MigrationFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	var files []MigrationFile
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		if uint(version) <= current {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		files = append(files, MigrationFile{Version: uint(version), Name: entry.Name(), SQL: string(content)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Version < files[j].Version })
+	return files, nil
+}
+
+// RunMigrationPreflight 分析待执行的迁移并估算受影响的行数。
+// 同一批迁移中新建的表视为空表，不报告其上的语句；estimator 为 nil 时不估算行数，只报告语句本身的风险
+func RunMigrationPreflight(ctx context.Context, migrations []MigrationFile, estimator RowEstimator, config *PreflightConfig) (*PreflightReport, error) {
+	if config == nil {
+		config = DefaultPreflightConfig()
+	}
+
+	report := &PreflightReport{Migrations: migrations}
+	created := make(map[string]bool)
+	estimates := make(map[string]int64)
+	for _, migration := range migrations {
+		for _, stmt := range SplitSQLStatements(migration.SQL) {
+			finding, createdTable := analyzeStatement(stmt)
+			if createdTable != "" {
+				created[createdTable] = true
+			}
+			if finding == nil || created[finding.Table] {
+				continue
+			}
+
+			finding.Version = migration.Version
+			finding.File = migration.Name
+			finding.EstimatedRows = -1
+			if estimator != nil && finding.Table != "" {
+				rows, ok := estimates[finding.Table]
+				if !ok {
+					var err error
+					if rows, err = estimator.EstimateCount(ctx, finding.Table); err != nil {
+						return nil, err
+					}
+					estimates[finding.Table] = rows
+				}
+				finding.EstimatedRows = rows
+			}
+			if (finding.Rewrite || finding.Scan) && finding.EstimatedRows >= config.LargeTableRows {
+				finding.Risk = MigrationRiskDanger
+			}
+			report.Findings = append(report.Findings, *finding)
+		}
+	}
+	return report, nil
+}
+
+// AnalyzeMigrationSQL 只按语句本身分析一个迁移文件，不区分新建的表，不估算行数
+func AnalyzeMigrationSQL(sql string) []MigrationFinding {
+	var findings []MigrationFinding
+	for _, stmt := range SplitSQLStatements(sql) {
+		if finding, _ := analyzeStatement(stmt); finding != nil {
+			finding.EstimatedRows = -1
+			findings = append(findings, *finding)
+		}
+	}
+	return findings
+}
+
+// SplitSQLStatements 按分号拆分语句，忽略注释，以及字符串、带引号的标识符与 $$ 块中的分号
+func SplitSQLStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+				current.WriteByte(' ')
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+				current.WriteByte(' ')
+			}
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				current.WriteString(sql[i:])
+				i = len(sql)
+				continue
+			}
+			current.WriteString(sql[i : i+end+2])
+			i += end + 1
+		case c == '$':
+			tag := dollarQuoteTag(sql[i:])
+			if tag == "" {
+				current.WriteByte(c)
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				current.WriteString(sql[i:])
+				i = len(sql)
+				continue
+			}
+			current.WriteString(sql[i : i+len(tag)+end+len(tag)])
+			i += len(tag) + end + len(tag) - 1
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+var dollarTagPattern = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// dollarQuoteTag s 以 $tag$ 开头时返回该标签
+func dollarQuoteTag(s string) string {
+	return dollarTagPattern.FindString(s)
+}
+
+var (
+	createTablePattern   = regexp.MustCompile(`^create (?:(?:temp|temporary|unlogged) )?table (?:if not exists )?([\w."]+)`)
+	alterTablePattern    = regexp.MustCompile(`^alter table (?:if exists )?(?:only )?([\w."]+) (.+)$`)
+	createIndexPattern   = regexp.MustCompile(`^create (?:unique )?index (concurrently )?(?:if not exists )?(?:[\w."]+ )?on (?:only )?([\w."]+)`)
+	dropTablePattern     = regexp.MustCompile(`^drop table (?:if exists )?([\w."]+)`)
+	truncatePattern      = regexp.MustCompile(`^truncate (?:table )?(?:only )?([\w."]+)`)
+	vacuumFullPattern    = regexp.MustCompile(`^vacuum (?:full |\([^)]*full[^)]*\) ?)(?:(?:freeze|verbose|analyze) )*([\w."]+)?`)
+	clusterPattern       = regexp.MustCompile(`^cluster (?:verbose )?([\w."]+)`)
+	updatePattern        = regexp.MustCompile(`^update (?:only )?([\w."]+) `)
+	deletePattern        = regexp.MustCompile(`^delete from (?:only )?([\w."]+)`)
+	addConstraintPattern = regexp.MustCompile(`^add (?:constraint|foreign key|check|primary key|unique)\b`)
+	addColumnPattern     = regexp.MustCompile(`^add (?:column )?(?:if not exists )?([\w"]+) (.+)$`)
+	alterTypePattern     = regexp.MustCompile(`^alter (?:column )?([\w"]+) (?:set data )?type `)
+	setNotNullPattern    = regexp.MustCompile(`^alter (?:column )?([\w"]+) set not null`)
+	volatileDefaultFunc  = regexp.MustCompile(`default [^,]*(random|gen_random_uuid|uuid_generate_v\d|clock_timestamp|nextval)\(`)
+)
+
+// analyzeStatement 分析单条语句；createdTable 为语句新建的表
+func analyzeStatement(stmt string) (finding *MigrationFinding, createdTable string) {
+	normalized := strings.ToLower(whitespacePattern.ReplaceAllString(strings.TrimSpace(stmt), " "))
+	newFinding := func(table, lock string, risk MigrationRisk, message string) *MigrationFinding {
+		return &MigrationFinding{Statement: stmt, Table: normalizeTableName(table), Lock: lock, Risk: risk, Message: message}
+	}
+
+	if m := createTablePattern.FindStringSubmatch(normalized); m != nil {
+		return nil, normalizeTableName(m[1])
+	}
+	if m := alterTablePattern.FindStringSubmatch(normalized); m != nil {
+		return analyzeAlterTable(newFinding(m[1], LockAccessExclusive, MigrationRiskInfo, ""), m[2]), ""
+	}
+	if m := createIndexPattern.FindStringSubmatch(normalized); m != nil {
+		if m[1] != "" {
+			return nil, ""
+		}
+		finding := newFinding(m[2], LockShare, MigrationRiskWarning, "CREATE INDEX without CONCURRENTLY blocks writes while the index is built")
+		finding.Scan = true
+		return finding, ""
+	}
+	if m := dropTablePattern.FindStringSubmatch(normalized); m != nil {
+		return newFinding(m[1], LockAccessExclusive, MigrationRiskWarning, "DROP TABLE removes all rows; make sure no deployed code still uses the table"), ""
+	}
+	if m := truncatePattern.FindStringSubmatch(normalized); m != nil {
+		return newFinding(m[1], LockAccessExclusive, MigrationRiskDanger, "TRUNCATE removes all rows"), ""
+	}
+	if m := vacuumFullPattern.FindStringSubmatch(normalized); m != nil {
+		finding := newFinding(m[1], LockAccessExclusive, MigrationRiskDanger, "VACUUM FULL rewrites the table and blocks reads and writes")
+		finding.Rewrite = true
+		return finding, ""
+	}
+	if m := clusterPattern.FindStringSubmatch(normalized); m != nil {
+		finding := newFinding(m[1], LockAccessExclusive, MigrationRiskDanger, "CLUSTER rewrites the table and blocks reads and writes")
+		finding.Rewrite = true
+		return finding, ""
+	}
+	if m := updatePattern.FindStringSubmatch(normalized); m != nil && !strings.Contains(normalized, " where ") {
+		finding := newFinding(m[1], LockRowExclusive, MigrationRiskWarning, "UPDATE without WHERE touches every row in one transaction; backfill in batches")
+		finding.Scan = true
+		return finding, ""
+	}
+	if m := deletePattern.FindStringSubmatch(normalized); m != nil && !strings.Contains(normalized, " where ") {
+		finding := newFinding(m[1], LockRowExclusive, MigrationRiskWarning, "DELETE without WHERE removes every row in one transaction")
+		finding.Scan = true
+		return finding, ""
+	}
+	return nil, ""
+}
+
+// analyzeAlterTable 按 ALTER TABLE 中最危险的子句确定风险
+func analyzeAlterTable(finding *MigrationFinding, actions string) *MigrationFinding {
+	finding.Message = "ALTER TABLE takes an ACCESS EXCLUSIVE lock; it waits for and blocks every query on the table"
+	escalate := func(risk MigrationRisk, message string) {
+		if riskLevel(risk) > riskLevel(finding.Risk) {
+			finding.Risk = risk
+			finding.Message = message
+		}
+	}
+
+	for _, action := range splitTopLevel(actions) {
+		switch {
+		case addConstraintPattern.MatchString(action):
+			if strings.Contains(action, "not valid") || strings.Contains(action, "using index") {
+				continue
+			}
+			finding.Scan = true
+			if strings.Contains(action, "primary key") || strings.Contains(action, "unique") {
+				escalate(MigrationRiskWarning, "adding a PRIMARY KEY or UNIQUE constraint builds an index under ACCESS EXCLUSIVE; create the index CONCURRENTLY and add the constraint USING INDEX")
+			} else {
+				escalate(MigrationRiskWarning, "adding a constraint scans the whole table under ACCESS EXCLUSIVE; add it NOT VALID and VALIDATE CONSTRAINT separately")
+			}
+		case addColumnPattern.MatchString(action):
+			m := addColumnPattern.FindStringSubmatch(action)
+			definition := m[2]
+			switch {
+			case strings.Contains(definition, "not null") && !strings.Contains(definition, "default"):
+				escalate(MigrationRiskDanger, fmt.Sprintf("adding NOT NULL column %s without a DEFAULT fails on a non-empty table", m[1]))
+			case volatileDefaultFunc.MatchString(action):
+				finding.Rewrite = true
+				escalate(MigrationRiskWarning, fmt.Sprintf("adding column %s with a volatile DEFAULT rewrites the whole table", m[1]))
+			case strings.Contains(definition, "serial") || strings.Contains(definition, "generated always as ("):
+				finding.Rewrite = true
+				escalate(MigrationRiskWarning, fmt.Sprintf("adding column %s computed per row rewrites the whole table", m[1]))
+			}
+		case alterTypePattern.MatchString(action):
+			m := alterTypePattern.FindStringSubmatch(action)
+			finding.Rewrite = true
+			escalate(MigrationRiskDanger, fmt.Sprintf("changing the type of column %s rewrites the table and its indexes under ACCESS EXCLUSIVE", m[1]))
+		case setNotNullPattern.MatchString(action):
+			m := setNotNullPattern.FindStringSubmatch(action)
+			finding.Scan = true
+			escalate(MigrationRiskWarning, fmt.Sprintf("SET NOT NULL on %s scans the whole table under ACCESS EXCLUSIVE; add a CHECK (%s IS NOT NULL) NOT VALID constraint and validate it first", m[1], m[1]))
+		case strings.HasPrefix(action, "set tablespace") || strings.HasPrefix(action, "set logged") || strings.HasPrefix(action, "set unlogged"):
+			finding.Rewrite = true
+			escalate(MigrationRiskDanger, "changing the tablespace or logging mode rewrites the whole table")
+		case strings.HasPrefix(action, "drop column") || strings.HasPrefix(action, "drop "):
+			if strings.HasPrefix(action, "drop constraint") || strings.HasPrefix(action, "drop default") {
+				continue
+			}
+			escalate(MigrationRiskWarning, "dropping a column breaks deployed code that still selects it; stop using it in a release first")
+		case strings.HasPrefix(action, "rename"):
+			escalate(MigrationRiskWarning, "renaming breaks deployed code that still uses the old name")
+		}
+	}
+	return finding
+}
+
+// splitTopLevel 按括号外的逗号拆分 ALTER TABLE 的子句
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func riskLevel(risk MigrationRisk) int {
+	switch risk {
+	case MigrationRiskDanger:
+		return 2
+	case MigrationRiskWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// normalizeTableName 去掉引号与默认的 public 模式
+func normalizeTableName(name string) string {
+	name = strings.ReplaceAll(name, `"`, "")
+	return strings.TrimPrefix(name, "public.")
+}
@@ -0,0 +1,94 @@
+package database_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSQLStatements(t *testing.T) {
+	sql := `-- 注释中的分号;
+CREATE TABLE a (note TEXT DEFAULT 'x;y');
+/* 块注释; */
+CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN NEW.a = 1; RETURN NEW; END; $$ LANGUAGE plpgsql;
+ALTER TABLE "b;c" ADD COLUMN d INT`
+
+	statements := database.SplitSQLStatements(sql)
+	require.Len(t, statements, 3)
+	assert.Equal(t, "CREATE TABLE a (note TEXT DEFAULT 'x;y')", statements[0])
+	assert.Contains(t, statements[1], "RETURN NEW; END; $$")
+	assert.Equal(t, `ALTER TABLE "b;c" ADD COLUMN d INT`, statements[2])
+}
+
+func TestAnalyzeMigrationSQL(t *testing.T) {
+	cases := []struct {
+		sql     string
+		risk    database.MigrationRisk
+		lock    string
+		rewrite bool
+	}{
+		{"ALTER TABLE users ADD COLUMN level INT NOT NULL", database.MigrationRiskDanger, database.LockAccessExclusive, false},
+		{"ALTER TABLE users ADD COLUMN level INT NOT NULL DEFAULT 1", database.MigrationRiskInfo, database.LockAccessExclusive, false},
+		{"ALTER TABLE users ALTER COLUMN id TYPE BIGINT", database.MigrationRiskDanger, database.LockAccessExclusive, true},
+		{"ALTER TABLE users ADD COLUMN token UUID DEFAULT gen_random_uuid()", database.MigrationRiskWarning, database.LockAccessExclusive, true},
+		{"ALTER TABLE users ADD COLUMN check_flag BOOLEAN", database.MigrationRiskInfo, database.LockAccessExclusive, false},
+		{"ALTER TABLE orders ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) NOT VALID", database.MigrationRiskInfo, database.LockAccessExclusive, false},
+		{"ALTER TABLE orders ALTER COLUMN user_id SET NOT NULL", database.MigrationRiskWarning, database.LockAccessExclusive, false},
+		{"CREATE INDEX idx_users_email ON users(email)", database.MigrationRiskWarning, database.LockShare, false},
+		{"VACUUM FULL users", database.MigrationRiskDanger, database.LockAccessExclusive, true},
+	}
+	for _, tc := range cases {
+		findings := database.AnalyzeMigrationSQL(tc.sql)
+		require.Len(t, findings, 1, tc.sql)
+		assert.Equal(t, tc.risk, findings[0].Risk, tc.sql)
+		assert.Equal(t, tc.lock, findings[0].Lock, tc.sql)
+		assert.Equal(t, tc.rewrite, findings[0].Rewrite, tc.sql)
+		assert.Equal(t, int64(-1), findings[0].EstimatedRows, tc.sql)
+	}
+
+	assert.Empty(t, database.AnalyzeMigrationSQL("CREATE INDEX CONCURRENTLY idx_users_email ON users(email)"))
+	assert.Empty(t, database.AnalyzeMigrationSQL("UPDATE users SET level = 1 WHERE id = 1"))
+}
+
+type fixedEstimator map[string]int64
+
+func (e fixedEstimator) EstimateCount(ctx context.Context, table string) (int64, error) {
+	return e[table], nil
+}
+
+func TestRunMigrationPreflight(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"000001_init.up.sql":         "CREATE TABLE users (id INT);",
+		"000002_add_tags.up.sql":     "CREATE TABLE tags (id INT);\nCREATE INDEX idx_tags_id ON tags(id);",
+		"000002_add_tags.down.sql":   "DROP TABLE tags;",
+		"000003_index_users.up.sql":  "CREATE INDEX idx_users_id ON users(id);",
+		"000004_index_events.up.sql": "CREATE INDEX idx_events_at ON public.events(occurred_at);",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	migrations, err := database.LoadPendingMigrations(dir, 1)
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+	assert.Equal(t, uint(2), migrations[0].Version)
+
+	report, err := database.RunMigrationPreflight(context.Background(), migrations,
+		fixedEstimator{"users": 500, "events": 5_000_000}, nil)
+	require.NoError(t, err)
+
+	// 新建表上的索引不报告；小表上的普通索引为 warning，大表上升级为 danger
+	require.Len(t, report.Findings, 2)
+	assert.Equal(t, "users", report.Findings[0].Table)
+	assert.Equal(t, database.MigrationRiskWarning, report.Findings[0].Risk)
+	assert.Equal(t, int64(500), report.Findings[0].EstimatedRows)
+	assert.Equal(t, "events", report.Findings[1].Table)
+	assert.Equal(t, database.MigrationRiskDanger, report.Findings[1].Risk)
+	assert.True(t, report.Dangerous())
+}
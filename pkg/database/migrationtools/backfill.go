@@ -0,0 +1,243 @@
+// Package migrationtools 不停机演进表结构的工具：分批回填已有数据，以及列、表重命名期间的双写双读
+package migrationtools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/time/rate"
+)
+
+// BackfillStatus 回填任务状态
+type BackfillStatus string
+
+const (
+	BackfillRunning   BackfillStatus = "running"
+	BackfillCompleted BackfillStatus = "completed"
+	BackfillFailed    BackfillStatus = "failed"
+)
+
+// BackfillProgress 回填进度，每个批次提交后保存
+type BackfillProgress struct {
+	Name         string         `db:"name" json:"name"`
+	Table        string         `db:"table_name" json:"table"`
+	LastKey      string         `db:"last_key" json:"last_key"` // 最后一个已处理批次的最大键，空表示尚未开始
+	RowsAffected int64          `db:"rows_affected" json:"rows_affected"`
+	Batches      int64          `db:"batches" json:"batches"`
+	Status       BackfillStatus `db:"status" json:"status"`
+	LastError    string         `db:"last_error" json:"last_error,omitempty"`
+	StartedAt    time.Time      `db:"started_at" json:"started_at"`
+	UpdatedAt    time.Time      `db:"updated_at" json:"updated_at"`
+	CompletedAt  *time.Time     `db:"completed_at" json:"completed_at,omitempty"`
+}
+
+// ProgressStore 回填进度存储
+type ProgressStore interface {
+	// LoadProgress 读取进度，任务从未运行时返回 nil
+	LoadProgress(ctx context.Context, name string) (*BackfillProgress, error)
+	SaveProgress(ctx context.Context, progress *BackfillProgress) error
+}
+
+// BatchFunc 在事务中处理键位于 [from, to]（含两端）的一批行，返回受影响的行数。
+// 中断后最后一批可能重新执行，处理必须是幂等的，如 UPDATE ... WHERE new_col IS NULL
+type BatchFunc func(ctx context.Context, tx *sqlx.Tx, from, to string) (int64, error)
+
+// BackfillConfig 回填配置
+type BackfillConfig struct {
+	Name      string    `json:"name"`       // 任务名，进度按任务名保存
+	Table     string    `json:"table"`      // 按该表的键分批
+	KeyColumn string    `json:"key_column"` // 唯一且有索引的键列，默认 id
+	BatchSize int       `json:"batch_size"`
+	Batch     BatchFunc `json:"-"`
+	// RowsPerSecond 每秒最多处理的行数（按批次大小计），0 表示不限制；用于避免回填挤占线上流量与复制带宽
+	RowsPerSecond float64                 `json:"rows_per_second"`
+	Retry         *database.TxRetryConfig `json:"-"` // 批次遇到死锁、序列化失败时的重试，nil 使用默认值
+	Clock         clock.Clock             `json:"-"`
+}
+
+// DefaultBackfillConfig 默认回填配置：按 id 每批 1000 行，每秒不超过 5000 行
+func DefaultBackfillConfig() *BackfillConfig {
+	return &BackfillConfig{
+		KeyColumn:     "id",
+		BatchSize:     1000,
+		RowsPerSecond: 5000,
+	}
+}
+
+// BackfillRunner 按键顺序（keyset）分批回填，每批一个事务，进度持久化后可从中断处继续
+type BackfillRunner struct {
+	db               *database.DB
+	store            ProgressStore
+	config           *BackfillConfig
+	limiter          *rate.Limiter
+	clock            clock.Clock
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewBackfillRunner 创建回填任务
+func NewBackfillRunner(db *database.DB, store ProgressStore, config *BackfillConfig) (*BackfillRunner, error) {
+	if config.Name == "" || config.Table == "" || config.Batch == nil {
+		return nil, fmt.Errorf("backfill name, table and batch func are required")
+	}
+	if config.KeyColumn == "" {
+		config.KeyColumn = "id"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBackfillConfig().BatchSize
+	}
+
+	runner := &BackfillRunner{
+		db:               db,
+		store:            store,
+		config:           config,
+		clock:            clock.OrReal(config.Clock),
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+	if config.RowsPerSecond > 0 {
+		runner.limiter = rate.NewLimiter(rate.Limit(config.RowsPerSecond), max(config.BatchSize, int(config.RowsPerSecond)))
+	}
+	return runner, nil
+}
+
+// Run 从上次保存的位置继续回填直到处理完所有行或 ctx 取消；已完成的任务直接返回其进度。
+// 运行期间新插入的行应由应用双写覆盖，回填只负责已有数据
+func (r *BackfillRunner) Run(ctx context.Context) (*BackfillProgress, error) {
+	progress, err := r.store.LoadProgress(ctx, r.config.Name)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = &BackfillProgress{Name: r.config.Name, Table: r.config.Table, StartedAt: r.clock.Now()}
+	}
+	if progress.Status == BackfillCompleted {
+		return progress, nil
+	}
+	progress.Status = BackfillRunning
+	progress.LastError = ""
+	log.Printf("Backfill %s starting on %s after key %q (%d rows so far)", r.config.Name, r.config.Table, progress.LastKey, progress.RowsAffected)
+
+	for {
+		if r.limiter != nil {
+			if err := r.limiter.WaitN(ctx, r.config.BatchSize); err != nil {
+				return progress, r.fail(progress, err)
+			}
+		}
+
+		done, err := r.step(ctx, progress)
+		if err != nil {
+			return progress, r.fail(progress, err)
+		}
+		if done {
+			now := r.clock.Now()
+			progress.Status = BackfillCompleted
+			progress.CompletedAt = &now
+			progress.UpdatedAt = now
+			if err := r.store.SaveProgress(ctx, progress); err != nil {
+				return progress, err
+			}
+			log.Printf("Backfill %s completed: %d rows in %d batches", r.config.Name, progress.RowsAffected, progress.Batches)
+			return progress, nil
+		}
+	}
+}
+
+// step 处理下一批并保存进度；没有剩余的行时返回 true
+func (r *BackfillRunner) step(ctx context.Context, progress *BackfillProgress) (bool, error) {
+	from, to, ok, err := r.nextRange(ctx, progress.LastKey)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return true, nil
+	}
+
+	var affected int64
+	err = r.db.RunInTxWithRetry(ctx, "backfill_"+r.config.Name, r.config.Retry, func(tx *sqlx.Tx) error {
+		n, err := r.config.Batch(ctx, tx, from, to)
+		affected = n
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to backfill %s keys %s..%s: %w", r.config.Table, from, to, err)
+	}
+
+	progress.LastKey = to
+	progress.RowsAffected += affected
+	progress.Batches++
+	progress.UpdatedAt = r.clock.Now()
+	r.metricsCollector.RecordEvent("backfill", "batch", 1)
+	r.metricsCollector.RecordEvent("backfill", "rows", int(affected))
+	return false, r.store.SaveProgress(ctx, progress)
+}
+
+// nextRange 读取 after 之后一批键的最小值与最大值；没有剩余的行时 ok 为 false
+func (r *BackfillRunner) nextRange(ctx context.Context, after string) (from, to string, ok bool, err error) {
+	key := quoteIdentifier(r.config.KeyColumn)
+	where := ""
+	args := []interface{}{r.config.BatchSize}
+	if after != "" {
+		where = fmt.Sprintf("WHERE %s > $2", key)
+		args = append(args, after)
+	}
+	// 按列本身的类型排序取两端，不能对 ::text 之后的值取 min/max（整数会按字典序比较）
+	query := fmt.Sprintf(`
+		SELECT (array_agg(k::text ORDER BY k))[1] AS from_key, (array_agg(k::text ORDER BY k DESC))[1] AS to_key
+		FROM (SELECT %s AS k FROM %s %s ORDER BY %s LIMIT $1) batch`,
+		key, quoteIdentifier(r.config.Table), where, key)
+
+	var bounds struct {
+		From *string `db:"from_key"`
+		To   *string `db:"to_key"`
+	}
+	if err := r.db.GetContext(ctx, &bounds, query, args...); err != nil {
+		return "", "", false, fmt.Errorf("failed to read next backfill range: %w", err)
+	}
+	if bounds.From == nil || bounds.To == nil {
+		return "", "", false, nil
+	}
+	return *bounds.From, *bounds.To, true, nil
+}
+
+// fail 记录失败并保存进度，下次运行从最后一个成功的批次之后继续
+func (r *BackfillRunner) fail(progress *BackfillProgress, cause error) error {
+	progress.Status = BackfillFailed
+	progress.LastError = cause.Error()
+	progress.UpdatedAt = r.clock.Now()
+	r.metricsCollector.RecordEvent("backfill", "failed", 1)
+	// ctx 可能已取消，用独立的 ctx 保存
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.store.SaveProgress(saveCtx, progress); err != nil {
+		return errors.Join(cause, err)
+	}
+	log.Printf("Backfill %s stopped after key %q: %v", r.config.Name, progress.LastKey, cause)
+	return cause
+}
+
+// UpdateBatch 以 SQL 处理一批行的 BatchFunc，query 中 $1、$2 为键的下界与上界（含两端）：
+//
+//	migrationtools.UpdateBatch(`UPDATE users SET display_name = nickname
+//		WHERE id BETWEEN $1 AND $2 AND display_name IS NULL`)
+func UpdateBatch(query string) BatchFunc {
+	return func(ctx context.Context, tx *sqlx.Tx, from, to string) (int64, error) {
+		result, err := tx.ExecContext(ctx, query, from, to)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+}
+
+// quoteIdentifier 为可能带模式的表名或列名加引号
+func quoteIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
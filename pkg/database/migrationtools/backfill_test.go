@@ -0,0 +1,154 @@
+package migrationtools_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"user_crud_jwt/pkg/database/migrationtools"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryProgressStore struct {
+	progress map[string]migrationtools.BackfillProgress
+}
+
+func (s *memoryProgressStore) LoadProgress(ctx context.Context, name string) (*migrationtools.BackfillProgress, error) {
+	progress, ok := s.progress[name]
+	if !ok {
+		return nil, nil
+	}
+	return &progress, nil
+}
+
+func (s *memoryProgressStore) SaveProgress(ctx context.Context, progress *migrationtools.BackfillProgress) error {
+	s.progress[progress.Name] = *progress
+	return nil
+}
+
+func newBackfill(t *testing.T, store migrationtools.ProgressStore) (*migrationtools.BackfillRunner, sqlmock.Sqlmock) {
+	db, mock := fakes.NewDB(t)
+	runner, err := migrationtools.NewBackfillRunner(db, store, &migrationtools.BackfillConfig{
+		Name:      "users_display_name",
+		Table:     "users",
+		BatchSize: 2,
+		Batch:     migrationtools.UpdateBatch("UPDATE users SET display_name = nickname WHERE id BETWEEN $1 AND $2 AND display_name IS NULL"),
+	})
+	require.NoError(t, err)
+	return runner, mock
+}
+
+func expectRange(mock sqlmock.Sqlmock, from, to interface{}) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery("SELECT \\(array_agg").
+		WillReturnRows(sqlmock.NewRows([]string{"from_key", "to_key"}).AddRow(from, to))
+}
+
+func TestBackfillRunner_ProcessesAllBatches(t *testing.T) {
+	store := &memoryProgressStore{progress: map[string]migrationtools.BackfillProgress{}}
+	runner, mock := newBackfill(t, store)
+
+	expectRange(mock, "1", "2").WithArgs(2)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET display_name").WithArgs("1", "2").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	expectRange(mock, "3", "3").WithArgs(2, "2")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET display_name").WithArgs("3", "3").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	expectRange(mock, nil, nil).WithArgs(2, "3")
+
+	progress, err := runner.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, migrationtools.BackfillCompleted, progress.Status)
+	assert.Equal(t, "3", progress.LastKey)
+	assert.Equal(t, int64(2), progress.RowsAffected)
+	assert.Equal(t, int64(2), progress.Batches)
+	assert.NotNil(t, progress.CompletedAt)
+
+	// 已完成的任务不再执行
+	progress, err = runner.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, migrationtools.BackfillCompleted, progress.Status)
+}
+
+func TestBackfillRunner_ResumesAfterFailure(t *testing.T) {
+	store := &memoryProgressStore{progress: map[string]migrationtools.BackfillProgress{}}
+	runner, mock := newBackfill(t, store)
+
+	expectRange(mock, "1", "2")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	expectRange(mock, "3", "4")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users").WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	_, err := runner.Run(context.Background())
+	require.Error(t, err)
+	saved := store.progress["users_display_name"]
+	assert.Equal(t, migrationtools.BackfillFailed, saved.Status)
+	assert.Equal(t, "2", saved.LastKey, "the failed batch is not recorded")
+	assert.Contains(t, saved.LastError, "disk full")
+
+	// 重新运行从最后一个成功的批次之后继续
+	expectRange(mock, "3", "4").WithArgs(2, "2")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users").WithArgs("3", "4").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	expectRange(mock, nil, nil).WithArgs(2, "4")
+
+	progress, err := runner.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, migrationtools.BackfillCompleted, progress.Status)
+	assert.Equal(t, int64(4), progress.RowsAffected)
+	assert.Empty(t, progress.LastError)
+}
+
+func TestColumnRename_Phases(t *testing.T) {
+	rename := migrationtools.NewColumnRename("users", "nickname", "display_name", migrationtools.PhaseOld)
+	assert.Equal(t, "nickname = $2", rename.Assign("$2"))
+	assert.Equal(t, "nickname AS name", rename.Select("name"))
+
+	rename.SetPhase(migrationtools.PhaseDualWrite)
+	assert.Equal(t, "nickname = $2, display_name = $2", rename.Assign("$2"))
+	assert.Equal(t, "nickname", rename.ReadExpr())
+
+	rename.SetPhase(migrationtools.PhaseDualRead)
+	assert.Equal(t, "COALESCE(display_name, nickname)", rename.ReadExpr())
+
+	phase, err := migrationtools.ParseRenamePhase("new")
+	require.NoError(t, err)
+	rename.SetPhase(phase)
+	assert.Equal(t, []string{"display_name"}, rename.WriteColumns())
+	assert.Equal(t, "display_name AS name", rename.Select("name"))
+}
+
+func TestTableRename_DualReadFallsBackToOldTable(t *testing.T) {
+	rename := migrationtools.NewTableRename("user_profiles", "profiles", migrationtools.PhaseDualRead)
+	assert.Equal(t, []string{"profiles", "user_profiles"}, rename.WriteTables())
+
+	var tried []string
+	err := rename.Read(func(table string) error {
+		tried = append(tried, table)
+		if table == "profiles" {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"profiles", "user_profiles"}, tried)
+
+	rename.SetPhase(migrationtools.PhaseNew)
+	tried = nil
+	err = rename.Read(func(table string) error {
+		tried = append(tried, table)
+		return sql.ErrNoRows
+	})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Equal(t, []string{"profiles"}, tried)
+}
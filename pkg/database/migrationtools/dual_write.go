@@ -0,0 +1,200 @@
+package migrationtools
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RenamePhase 列或表重命名所处的阶段。按顺序推进，每个阶段在所有实例上生效后再进入下一阶段，
+// 任何阶段都可以退回上一阶段
+type RenamePhase int32
+
+const (
+	PhaseOld       RenamePhase = iota // 只读写旧名称（重命名开始前）
+	PhaseDualWrite                    // 同时写新旧两处，读旧的；此阶段回填历史数据
+	PhaseDualRead                     // 同时写新旧两处，读新的，新的缺失时回退到旧的
+	PhaseNew                          // 只读写新名称，之后的迁移可以删除旧列或旧表
+)
+
+var renamePhaseNames = []string{"old", "dual_write", "dual_read", "new"}
+
+func (p RenamePhase) String() string {
+	if p < 0 || int(p) >= len(renamePhaseNames) {
+		return fmt.Sprintf("RenamePhase(%d)", int32(p))
+	}
+	return renamePhaseNames[p]
+}
+
+// ParseRenamePhase 解析配置中的阶段名：old、dual_write、dual_read 或 new
+func ParseRenamePhase(name string) (RenamePhase, error) {
+	for i, phaseName := range renamePhaseNames {
+		if name == phaseName {
+			return RenamePhase(i), nil
+		}
+	}
+	return PhaseOld, fmt.Errorf("unknown rename phase: %s", name)
+}
+
+// ColumnRename 列重命名期间生成读写 SQL 片段。阶段可在运行时切换（如由配置或功能开关驱动）
+type ColumnRename struct {
+	Table string
+	Old   string
+	New   string
+	phase atomic.Int32
+}
+
+// NewColumnRename 创建列重命名
+func NewColumnRename(table, oldColumn, newColumn string, phase RenamePhase) *ColumnRename {
+	r := &ColumnRename{Table: table, Old: oldColumn, New: newColumn}
+	r.phase.Store(int32(phase))
+	return r
+}
+
+// Phase 当前阶段
+func (r *ColumnRename) Phase() RenamePhase {
+	return RenamePhase(r.phase.Load())
+}
+
+// SetPhase 切换阶段
+func (r *ColumnRename) SetPhase(phase RenamePhase) {
+	if old := RenamePhase(r.phase.Swap(int32(phase))); old != phase {
+		log.Printf("Column rename %s.%s -> %s: phase %s -> %s", r.Table, r.Old, r.New, old, phase)
+	}
+}
+
+// WriteColumns 写入时需要赋值的列
+func (r *ColumnRename) WriteColumns() []string {
+	switch r.Phase() {
+	case PhaseOld:
+		return []string{r.Old}
+	case PhaseNew:
+		return []string{r.New}
+	default:
+		return []string{r.Old, r.New}
+	}
+}
+
+// Assign UPDATE 的 SET 子句片段，所有写入列使用同一个占位符，如 "nickname = $2, display_name = $2"
+func (r *ColumnRename) Assign(placeholder string) string {
+	columns := r.WriteColumns()
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = column + " = " + placeholder
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ReadExpr 读取该列的表达式，可用于 SELECT、WHERE 与 ORDER BY；双读阶段新列为空时回退到旧列
+func (r *ColumnRename) ReadExpr() string {
+	switch r.Phase() {
+	case PhaseDualRead:
+		return fmt.Sprintf("COALESCE(%s, %s)", r.New, r.Old)
+	case PhaseNew:
+		return r.New
+	default:
+		return r.Old
+	}
+}
+
+// Select 带别名的读取表达式，别名固定时扫描目标结构体的 db 标签不随阶段变化
+func (r *ColumnRename) Select(alias string) string {
+	return r.ReadExpr() + " AS " + alias
+}
+
+// BackfillBatch 把旧列复制到新列的 BatchFunc，用于双写阶段回填历史数据，可重复执行
+func (r *ColumnRename) BackfillBatch(keyColumn string) BatchFunc {
+	return UpdateBatch(fmt.Sprintf(
+		`UPDATE %s SET %s = %s WHERE %s BETWEEN $1 AND $2 AND %s IS DISTINCT FROM %s`,
+		r.Table, r.New, r.Old, keyColumn, r.New, r.Old))
+}
+
+// TableRename 表重命名（或拆分到新表）期间的双写双读
+type TableRename struct {
+	Old   string
+	New   string
+	phase atomic.Int32
+}
+
+// NewTableRename 创建表重命名
+func NewTableRename(oldTable, newTable string, phase RenamePhase) *TableRename {
+	r := &TableRename{Old: oldTable, New: newTable}
+	r.phase.Store(int32(phase))
+	return r
+}
+
+// Phase 当前阶段
+func (r *TableRename) Phase() RenamePhase {
+	return RenamePhase(r.phase.Load())
+}
+
+// SetPhase 切换阶段
+func (r *TableRename) SetPhase(phase RenamePhase) {
+	if old := RenamePhase(r.phase.Swap(int32(phase))); old != phase {
+		log.Printf("Table rename %s -> %s: phase %s -> %s", r.Old, r.New, old, phase)
+	}
+}
+
+// WriteTables 写入时需要写的表，当前读取的表在前
+func (r *TableRename) WriteTables() []string {
+	switch r.Phase() {
+	case PhaseOld:
+		return []string{r.Old}
+	case PhaseDualWrite:
+		return []string{r.Old, r.New}
+	case PhaseDualRead:
+		return []string{r.New, r.Old}
+	default:
+		return []string{r.New}
+	}
+}
+
+// ReadTable 读取使用的表
+func (r *TableRename) ReadTable() string {
+	if r.Phase() >= PhaseDualRead {
+		return r.New
+	}
+	return r.Old
+}
+
+// Write 在一个事务中对每张需要写的表调用 fn，两处写入同时成功或同时失败；
+// 遇到死锁等可重试错误时整个事务重新执行
+func (r *TableRename) Write(ctx context.Context, db *database.DB, fn func(tx *sqlx.Tx, table string) error) error {
+	tables := r.WriteTables()
+	return db.RunInTxWithRetry(ctx, "dual_write_"+r.Old, nil, func(tx *sqlx.Tx) error {
+		for _, table := range tables {
+			if err := fn(tx, table); err != nil {
+				return fmt.Errorf("failed to write %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Read 从当前读取的表读取；双读阶段在新表中找不到（fn 返回 sql.ErrNoRows）时回退到旧表
+func (r *TableRename) Read(fn func(table string) error) error {
+	phase := r.Phase()
+	if phase != PhaseDualRead {
+		return fn(r.ReadTable())
+	}
+	err := fn(r.New)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fn(r.Old)
+	}
+	return err
+}
+
+// CopyBatch 把旧表中的行复制到新表的 BatchFunc，columns 为两表共有的列，新表中已存在的键跳过
+func (r *TableRename) CopyBatch(keyColumn string, columns []string) BatchFunc {
+	list := strings.Join(columns, ", ")
+	return UpdateBatch(fmt.Sprintf(
+		`INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s BETWEEN $1 AND $2 ON CONFLICT (%s) DO NOTHING`,
+		r.New, list, list, r.Old, keyColumn, keyColumn))
+}
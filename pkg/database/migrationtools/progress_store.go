@@ -0,0 +1,71 @@
+package migrationtools
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"user_crud_jwt/pkg/database"
+)
+
+// SQLProgressStore 基于 backfill_progress 表的进度存储
+type SQLProgressStore struct {
+	db *database.DB
+}
+
+var _ ProgressStore = (*SQLProgressStore)(nil)
+
+// NewSQLProgressStore 创建进度存储
+func NewSQLProgressStore(db *database.DB) *SQLProgressStore {
+	return &SQLProgressStore{db: db}
+}
+
+// LoadProgress 读取进度，任务从未运行时返回 nil
+func (s *SQLProgressStore) LoadProgress(ctx context.Context, name string) (*BackfillProgress, error) {
+	query := `
+		SELECT name, table_name, last_key, rows_affected, batches, status, last_error, started_at, updated_at, completed_at
+		FROM backfill_progress
+		WHERE name = $1`
+	var progress BackfillProgress
+	if err := s.db.GetContext(ctx, &progress, query, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load backfill progress: %w", err)
+	}
+	return &progress, nil
+}
+
+// SaveProgress 保存进度
+func (s *SQLProgressStore) SaveProgress(ctx context.Context, progress *BackfillProgress) error {
+	query := `
+		INSERT INTO backfill_progress (name, table_name, last_key, rows_affected, batches, status, last_error,
+			started_at, updated_at, completed_at)
+		VALUES (:name, :table_name, :last_key, :rows_affected, :batches, :status, :last_error,
+			:started_at, :updated_at, :completed_at)
+		ON CONFLICT (name) DO UPDATE SET
+			last_key = EXCLUDED.last_key,
+			rows_affected = EXCLUDED.rows_affected,
+			batches = EXCLUDED.batches,
+			status = EXCLUDED.status,
+			last_error = EXCLUDED.last_error,
+			updated_at = EXCLUDED.updated_at,
+			completed_at = EXCLUDED.completed_at`
+	if _, err := s.db.NamedExec(ctx, query, progress); err != nil {
+		return fmt.Errorf("failed to save backfill progress: %w", err)
+	}
+	return nil
+}
+
+// ListProgress 返回所有回填任务的进度，最近更新的在前
+func (s *SQLProgressStore) ListProgress(ctx context.Context) ([]BackfillProgress, error) {
+	query := `
+		SELECT name, table_name, last_key, rows_affected, batches, status, last_error, started_at, updated_at, completed_at
+		FROM backfill_progress
+		ORDER BY updated_at DESC`
+	var progress []BackfillProgress
+	if err := s.db.SelectContext(ctx, &progress, query); err != nil {
+		return nil, fmt.Errorf("failed to list backfill progress: %w", err)
+	}
+	return progress, nil
+}
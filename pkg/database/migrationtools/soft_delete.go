@@ -0,0 +1,37 @@
+package migrationtools
+
+import (
+	"fmt"
+	"strings"
+	"user_crud_jwt/pkg/database"
+)
+
+// SoftDeleteUnique 软删除表上的唯一性：只约束未删除的行，删除后可以再创建相同的值。
+// 普通的 UNIQUE 约束会让已软删除的行继续占用该值
+type SoftDeleteUnique struct {
+	Table   string
+	Columns []string
+}
+
+// IndexName 部分唯一索引名：uniq_<表>_<列>，与 database.IsUniqueViolation 配合判断冲突的约束
+func (u SoftDeleteUnique) IndexName() string {
+	return "uniq_" + u.Table + "_" + strings.Join(u.Columns, "_")
+}
+
+// UpSQL 创建部分唯一索引的迁移语句，写入 *.up.sql：
+//
+//	CREATE UNIQUE INDEX IF NOT EXISTS uniq_topics_name ON topics(name) WHERE deleted_at IS NULL;
+func (u SoftDeleteUnique) UpSQL() string {
+	return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s) WHERE %s IS NULL;",
+		u.IndexName(), u.Table, strings.Join(u.Columns, ", "), database.SoftDeleteColumn)
+}
+
+// DownSQL 对应的回滚语句，写入 *.down.sql
+func (u SoftDeleteUnique) DownSQL() string {
+	return fmt.Sprintf("DROP INDEX IF EXISTS %s;", u.IndexName())
+}
+
+// OnConflict INSERT 使用的冲突目标，需带上与索引相同的谓词才能匹配部分唯一索引
+func (u SoftDeleteUnique) OnConflict() string {
+	return fmt.Sprintf("ON CONFLICT (%s) WHERE %s IS NULL", strings.Join(u.Columns, ", "), database.SoftDeleteColumn)
+}
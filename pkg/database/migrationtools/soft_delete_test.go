@@ -0,0 +1,28 @@
+package migrationtools_test
+
+import (
+	"os"
+	"testing"
+	"user_crud_jwt/pkg/database/migrationtools"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoftDeleteUnique_MatchesMigration(t *testing.T) {
+	up, err := os.ReadFile("../../../migrations/000028_soft_delete_unique_indexes.up.sql")
+	require.NoError(t, err)
+	down, err := os.ReadFile("../../../migrations/000028_soft_delete_unique_indexes.down.sql")
+	require.NoError(t, err)
+
+	for _, unique := range []migrationtools.SoftDeleteUnique{
+		{Table: "users", Columns: []string{"tenant_id", "mobile"}},
+		{Table: "topics", Columns: []string{"name"}},
+	} {
+		assert.Contains(t, string(up), unique.UpSQL())
+		assert.Contains(t, string(down), unique.DownSQL())
+	}
+
+	topics := migrationtools.SoftDeleteUnique{Table: "topics", Columns: []string{"name"}}
+	assert.Equal(t, "ON CONFLICT (name) WHERE deleted_at IS NULL", topics.OnConflict())
+}
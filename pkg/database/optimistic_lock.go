@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrStaleObject 乐观锁冲突：读取之后数据已被其他请求修改或删除，应重新读取后再修改
+var ErrStaleObject = errors.New("stale object")
+
+// StaleObjectError 乐观锁冲突的详细信息，errors.Is(err, ErrStaleObject) 为 true
+type StaleObjectError struct {
+	Table   string
+	Key     interface{}
+	Version int64 // 修改所依据的版本号
+}
+
+func (e *StaleObjectError) Error() string {
+	return fmt.Sprintf("stale object: %s %v is no longer at version %d", e.Table, e.Key, e.Version)
+}
+
+// Is 实现 errors.Is
+func (e *StaleObjectError) Is(target error) bool {
+	return target == ErrStaleObject
+}
+
+// Versioned 乐观锁版本号，嵌入到模型中，对应表中的 version 列（BIGINT NOT NULL DEFAULT 1）
+type Versioned struct {
+	Version int64 `db:"version" json:"version"`
+}
+
+// Execer *DB 与 *sqlx.Tx 都实现了该接口
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// UpdateVersioned 执行带版本检查的 UPDATE。set 为 SET 子句，占位符从 $1 开始对应 args；
+// 自动追加 version = version + 1 与 WHERE keyColumn = key AND version = v.Version。
+// 成功后 v.Version 加一；没有行被更新（版本已变化或行已删除）时返回 *StaleObjectError
+//
+//	err := database.UpdateVersioned(ctx, tx, "feature_flags", "key", flag.Key, &flag.Versioned,
+//		"enabled = $1, percentage = $2", flag.Enabled, flag.Percentage)
+func UpdateVersioned(ctx context.Context, exec Execer, table, keyColumn string, key interface{}, v *Versioned, set string, args ...interface{}) error {
+	query := fmt.Sprintf(`UPDATE %s SET %s, version = version + 1 WHERE %s = $%d AND version = $%d`,
+		table, set, keyColumn, len(args)+1, len(args)+2)
+	result, err := exec.ExecContext(ctx, query, append(args, key, v.Version)...)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", table, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", table, err)
+	}
+	if rows == 0 {
+		return &StaleObjectError{Table: table, Key: key, Version: v.Version}
+	}
+	v.Version++
+	return nil
+}
@@ -0,0 +1,66 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateVersioned(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	ctx := context.Background()
+	v := database.Versioned{Version: 3}
+
+	mock.ExpectExec(`UPDATE feature_flags SET enabled = \$1, version = version \+ 1 WHERE key = \$2 AND version = \$3`).
+		WithArgs(true, "new_ui", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, database.UpdateVersioned(ctx, db, "feature_flags", "key", "new_ui", &v, "enabled = $1", true))
+	assert.Equal(t, int64(4), v.Version)
+
+	// 版本已被其他请求修改
+	mock.ExpectExec("UPDATE feature_flags").
+		WithArgs(false, "new_ui", int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	err := database.UpdateVersioned(ctx, db, "feature_flags", "key", "new_ui", &v, "enabled = $1", false)
+	require.ErrorIs(t, err, database.ErrStaleObject)
+	var stale *database.StaleObjectError
+	require.True(t, errors.As(err, &stale))
+	assert.Equal(t, int64(4), stale.Version)
+	assert.Equal(t, int64(4), v.Version, "version is unchanged on conflict")
+}
+
+func TestRetry_ReloadsAfterStaleObject(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	ctx := context.Background()
+
+	// 第一次读取到版本 1，写入时已被修改为 2；重新读取后写入成功
+	for _, version := range []int64{1, 2} {
+		mock.ExpectQuery("SELECT version FROM feature_flags").
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+		affected := int64(1)
+		if version == 1 {
+			affected = 0
+		}
+		mock.ExpectExec("UPDATE feature_flags").
+			WithArgs(true, "new_ui", version).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+	}
+
+	attempts := 0
+	err := database.Retry(ctx, "test_flag_patch", fastRetry(3), func() error {
+		attempts++
+		var v database.Versioned
+		if err := db.GetContext(ctx, &v.Version, "SELECT version FROM feature_flags WHERE key = $1", "new_ui"); err != nil {
+			return err
+		}
+		return database.UpdateVersioned(ctx, db, "feature_flags", "key", "new_ui", &v, "enabled = $1", true)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,367 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// PartitionInterval 分区粒度
+type PartitionInterval string
+
+const (
+	PartitionWeekly  PartitionInterval = "weekly"
+	PartitionMonthly PartitionInterval = "monthly"
+)
+
+// PartitionConfig 单张分区表配置
+type PartitionConfig struct {
+	Table     string            `json:"table"`     // 父表名
+	Column    string            `json:"column"`    // 分区键（时间列）
+	Interval  PartitionInterval `json:"interval"`  // 分区粒度
+	Premake   int               `json:"premake"`   // 提前创建的分区数量
+	Retention time.Duration     `json:"retention"` // 数据保留时间，0 表示不清理
+}
+
+// PartitionInfo 分区信息
+type PartitionInfo struct {
+	Name  string    `json:"name"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Table string    `json:"table"`
+}
+
+// PartitionManager PostgreSQL 声明式分区管理器
+type PartitionManager struct {
+	db               *DB
+	configs          map[string]*PartitionConfig
+	metricsCollector *metrics.MetricsCollector
+	mu               sync.RWMutex
+}
+
+// NewPartitionManager 创建分区管理器
+func NewPartitionManager(db *DB) *PartitionManager {
+	return &PartitionManager{
+		db:               db,
+		configs:          make(map[string]*PartitionConfig),
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// Register 注册分区表
+func (pm *PartitionManager) Register(config *PartitionConfig) error {
+	if config.Table == "" || config.Column == "" {
+		return fmt.Errorf("partition table and column are required")
+	}
+	if config.Interval != PartitionWeekly && config.Interval != PartitionMonthly {
+		return fmt.Errorf("unsupported partition interval: %s", config.Interval)
+	}
+	if config.Premake <= 0 {
+		config.Premake = 2
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.configs[config.Table] = config
+	return nil
+}
+
+// Run 周期性维护所有分区表，直到 ctx 取消
+func (pm *PartitionManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pm.maintainAll(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			pm.maintainAll(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// maintainAll 维护所有分区表
+func (pm *PartitionManager) maintainAll(ctx context.Context) {
+	pm.mu.RLock()
+	configs := make([]*PartitionConfig, 0, len(pm.configs))
+	for _, config := range pm.configs {
+		configs = append(configs, config)
+	}
+	pm.mu.RUnlock()
+
+	for _, config := range configs {
+		if err := pm.Maintain(ctx, config.Table); err != nil {
+			log.Printf("Partition maintenance failed for %s: %v", config.Table, err)
+		}
+	}
+}
+
+// Maintain 提前创建分区并清理过期分区
+func (pm *PartitionManager) Maintain(ctx context.Context, table string) error {
+	start := time.Now()
+	config, err := pm.getConfig(table)
+	if err != nil {
+		return err
+	}
+
+	if _, err := pm.EnsurePartitions(ctx, config, time.Now()); err != nil {
+		pm.recordMetrics("ensure_error", time.Since(start), false)
+		return err
+	}
+
+	if config.Retention > 0 {
+		if _, err := pm.DropExpired(ctx, config, time.Now()); err != nil {
+			pm.recordMetrics("drop_error", time.Since(start), false)
+			return err
+		}
+	}
+
+	pm.recordMetrics("maintain", time.Since(start), true)
+	return nil
+}
+
+// EnsurePartitions 创建从 now 所在分区起的 Premake+1 个分区
+func (pm *PartitionManager) EnsurePartitions(ctx context.Context, config *PartitionConfig, now time.Time) ([]PartitionInfo, error) {
+	created := make([]PartitionInfo, 0, config.Premake+1)
+	from := partitionStart(config.Interval, now)
+	for i := 0; i <= config.Premake; i++ {
+		to := partitionNext(config.Interval, from)
+		info := PartitionInfo{Name: partitionName(config, from), From: from, To: to, Table: config.Table}
+
+		query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			quoteIdentifier(info.Name), quoteIdentifier(config.Table),
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+		if _, err := pm.db.ExecContext(ctx, query); err != nil {
+			return created, fmt.Errorf("failed to create partition %s: %w", info.Name, err)
+		}
+
+		created = append(created, info)
+		from = to
+	}
+	return created, nil
+}
+
+// ListPartitions 列出父表的所有分区（仅识别本管理器命名规则的分区）
+func (pm *PartitionManager) ListPartitions(ctx context.Context, config *PartitionConfig) ([]PartitionInfo, error) {
+	var names []string
+	query := `
+		SELECT child.relname
+		FROM pg_inherits
+		JOIN pg_class parent ON pg_inherits.inhparent = parent.oid
+		JOIN pg_class child ON pg_inherits.inhrelid = child.oid
+		WHERE parent.oid = to_regclass($1)
+		ORDER BY child.relname`
+	if err := pm.db.SelectContext(ctx, &names, query, config.Table); err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", config.Table, err)
+	}
+
+	partitions := make([]PartitionInfo, 0, len(names))
+	for _, name := range names {
+		from, ok := parsePartitionName(config, name)
+		if !ok {
+			continue
+		}
+		partitions = append(partitions, PartitionInfo{
+			Name:  name,
+			From:  from,
+			To:    partitionNext(config.Interval, from),
+			Table: config.Table,
+		})
+	}
+	return partitions, nil
+}
+
+// DropExpired 分离并删除上界早于保留期的分区
+func (pm *PartitionManager) DropExpired(ctx context.Context, config *PartitionConfig, now time.Time) ([]string, error) {
+	partitions, err := pm.ListPartitions(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.Add(-config.Retention)
+	dropped := make([]string, 0)
+	for _, partition := range partitions {
+		if partition.To.After(cutoff) {
+			continue
+		}
+		if err := pm.DetachPartition(ctx, config.Table, partition.Name); err != nil {
+			return dropped, err
+		}
+		if _, err := pm.db.ExecContext(ctx, "DROP TABLE IF EXISTS "+quoteIdentifier(partition.Name)); err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", partition.Name, err)
+		}
+		log.Printf("Dropped expired partition %s of %s", partition.Name, config.Table)
+		dropped = append(dropped, partition.Name)
+	}
+	return dropped, nil
+}
+
+// AttachPartition 将已有表挂载为分区
+func (pm *PartitionManager) AttachPartition(ctx context.Context, table, partition string, from, to time.Time) error {
+	query := fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')",
+		quoteIdentifier(table), quoteIdentifier(partition), from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if _, err := pm.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to attach partition %s: %w", partition, err)
+	}
+	return nil
+}
+
+// DetachPartition 分离分区，分离后的表保留数据，可用于归档
+func (pm *PartitionManager) DetachPartition(ctx context.Context, table, partition string) error {
+	query := fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", quoteIdentifier(table), quoteIdentifier(partition))
+	if _, err := pm.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to detach partition %s: %w", partition, err)
+	}
+	return nil
+}
+
+// MigrateToPartitioned 将普通表迁移为分区表
+//
+// 在单个事务内：原表重命名为 <table>_legacy，按原表结构创建分区父表，
+// 创建覆盖已有数据的分区并拷贝数据。索引和主键需在迁移后按分区键重新创建
+// （PostgreSQL 要求分区表的唯一约束包含分区键）。
+func (pm *PartitionManager) MigrateToPartitioned(ctx context.Context, config *PartitionConfig) error {
+	legacy := config.Table + "_legacy"
+
+	tx, err := pm.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	var bounds struct {
+		Min *time.Time `db:"min"`
+		Max *time.Time `db:"max"`
+	}
+	query := fmt.Sprintf("SELECT MIN(%s) AS min, MAX(%s) AS max FROM %s",
+		quoteIdentifier(config.Column), quoteIdentifier(config.Column), quoteIdentifier(config.Table))
+	if err := tx.GetContext(ctx, &bounds, query); err != nil {
+		return fmt.Errorf("failed to read data range of %s: %w", config.Table, err)
+	}
+
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdentifier(config.Table), quoteIdentifier(legacy)),
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (%s)",
+			quoteIdentifier(config.Table), quoteIdentifier(legacy), quoteIdentifier(config.Column)),
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", config.Table, err)
+		}
+	}
+
+	// 为已有数据和未来 Premake 个周期创建分区
+	now := time.Now()
+	from := partitionStart(config.Interval, now)
+	if bounds.Min != nil && bounds.Min.Before(from) {
+		from = partitionStart(config.Interval, *bounds.Min)
+	}
+	until := partitionStart(config.Interval, now)
+	if bounds.Max != nil && bounds.Max.After(until) {
+		until = partitionStart(config.Interval, *bounds.Max)
+	}
+	for i := 0; i < config.Premake; i++ {
+		until = partitionNext(config.Interval, until)
+	}
+
+	for !from.After(until) {
+		to := partitionNext(config.Interval, from)
+		statement := fmt.Sprintf("CREATE TABLE %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			quoteIdentifier(partitionName(config, from)), quoteIdentifier(config.Table),
+			from.Format(time.RFC3339), to.Format(time.RFC3339))
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create partition: %w", err)
+		}
+		from = to
+	}
+
+	copyData := fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quoteIdentifier(config.Table), quoteIdentifier(legacy))
+	if _, err := tx.ExecContext(ctx, copyData); err != nil {
+		return fmt.Errorf("failed to copy data into partitioned %s: %w", config.Table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+
+	log.Printf("Migrated %s to partitioned table, legacy data kept in %s", config.Table, legacy)
+	return nil
+}
+
+// getConfig 获取分区表配置
+func (pm *PartitionManager) getConfig(table string) (*PartitionConfig, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	config, exists := pm.configs[table]
+	if !exists {
+		return nil, fmt.Errorf("partitioned table not registered: %s", table)
+	}
+	return config, nil
+}
+
+// recordMetrics 记录指标
+func (pm *PartitionManager) recordMetrics(operation string, duration time.Duration, success bool) {
+	pm.metricsCollector.RecordDBQuery("partition_manager", operation, duration, success)
+	if !success {
+		pm.metricsCollector.RecordDBError("partition_manager_error", operation)
+	}
+}
+
+// partitionStart 计算时间所在分区的起点（UTC，周一或月初）
+func partitionStart(interval PartitionInterval, t time.Time) time.Time {
+	t = t.UTC()
+	if interval == PartitionWeekly {
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionNext 计算下一个分区起点
+func partitionNext(interval PartitionInterval, start time.Time) time.Time {
+	if interval == PartitionWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// partitionName 分区命名：月分区 <table>_p202601，周分区 <table>_p20260105
+func partitionName(config *PartitionConfig, start time.Time) string {
+	table := config.Table
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		table = table[idx+1:]
+	}
+	if config.Interval == PartitionWeekly {
+		return fmt.Sprintf("%s_p%s", table, start.Format("20060102"))
+	}
+	return fmt.Sprintf("%s_p%s", table, start.Format("200601"))
+}
+
+// parsePartitionName 从分区名解析分区起点
+func parsePartitionName(config *PartitionConfig, name string) (time.Time, bool) {
+	table := config.Table
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		table = table[idx+1:]
+	}
+	prefix := table + "_p"
+	if !strings.HasPrefix(name, prefix) {
+		return time.Time{}, false
+	}
+
+	layout := "200601"
+	if config.Interval == PartitionWeekly {
+		layout = "20060102"
+	}
+	start, err := time.ParseInLocation(layout, strings.TrimPrefix(name, prefix), time.UTC)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// ReportPoolStats 每隔 interval 将连接池统计写入指标，直到 ctx 结束
+func (db *DB) ReportPoolStats(ctx context.Context, collector *metrics.MetricsCollector, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev sql.DBStats
+	for {
+		curr := db.DB.Stats()
+		collector.RecordDBPoolStats(prev, curr)
+		prev = curr
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// PoolTuneMode 连接池调优模式
+type PoolTuneMode string
+
+const (
+	// PoolTuneSuggest 只记录建议，不修改连接池
+	PoolTuneSuggest PoolTuneMode = "suggest"
+	// PoolTuneApply 逐步修改连接池，观察期内等待增多时回滚
+	PoolTuneApply PoolTuneMode = "apply"
+)
+
+// PoolTuningAction 调优事件类型
+type PoolTuningAction string
+
+const (
+	PoolTuningSuggested  PoolTuningAction = "suggested"   // 建议模式下的调整建议
+	PoolTuningApplied    PoolTuningAction = "applied"     // 已修改，进入观察期
+	PoolTuningConfirmed  PoolTuningAction = "confirmed"   // 观察期内等待没有增多，保留修改
+	PoolTuningRolledBack PoolTuningAction = "rolled_back" // 观察期内等待增多，恢复修改前的值
+)
+
+// PoolLimits 连接池上限
+type PoolLimits struct {
+	MaxOpen int `json:"max_open"`
+	MaxIdle int `json:"max_idle"`
+}
+
+// PoolController 可调优的连接池，*sql.DB 实现了该接口
+type PoolController interface {
+	Stats() sql.DBStats
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+}
+
+// PoolTuningEvent 一次调优记录，每次建议、修改、确认与回滚都会记录，便于审计
+type PoolTuningEvent struct {
+	Action       PoolTuningAction `json:"action"`
+	From         PoolLimits       `json:"from"`
+	To           PoolLimits       `json:"to"`
+	Reason       string           `json:"reason"`
+	WaitCount    int64            `json:"wait_count"`    // 决策所依据的采样区间内的等待次数
+	WaitDuration time.Duration    `json:"wait_duration"` // 同一区间内的等待总时长
+	InUse        int              `json:"in_use"`
+	Timestamp    time.Time        `json:"timestamp"`
+}
+
+// PoolTuningStore 调优事件的持久化存储
+type PoolTuningStore interface {
+	InsertTuningEvent(ctx context.Context, event PoolTuningEvent) error
+}
+
+// PoolTunerConfig 连接池调优配置
+type PoolTunerConfig struct {
+	Mode     PoolTuneMode  `json:"mode"`
+	Interval time.Duration `json:"interval"` // 采样间隔
+	// Initial 启动时连接池已生效的上限，database/sql 不提供读取 MaxIdle 的方法，需与实际配置一致
+	Initial      PoolLimits `json:"initial"`
+	MinOpenConns int        `json:"min_open_conns"` // 最大连接数的下限
+	MaxOpenConns int        `json:"max_open_conns"` // 最大连接数的上限
+	MinIdleConns int        `json:"min_idle_conns"` // 最大空闲连接数的下限
+	MaxIdleConns int        `json:"max_idle_conns"` // 最大空闲连接数的上限
+	Step         int        `json:"step"`           // 每次调整的最大幅度
+	// LowUtilization 采样区间内没有等待且使用中的连接低于最大连接数的该比例时缩小连接池
+	LowUtilization float64 `json:"low_utilization"`
+	// StabilizationWindow 修改后的观察期，期间不做新的调整
+	StabilizationWindow time.Duration `json:"stabilization_window"`
+	// RollbackTolerance 观察期内每秒等待次数超过修改前的 (1+RollbackTolerance) 倍时回滚；修改前没有等待时出现等待即回滚
+	RollbackTolerance float64     `json:"rollback_tolerance"`
+	HistorySize       int         `json:"history_size"` // 内存中保留的调优事件数
+	Clock             clock.Clock `json:"-"`
+}
+
+// DefaultPoolTunerConfig 默认连接池调优配置，Initial 与 configureConnectionPool 一致
+func DefaultPoolTunerConfig() *PoolTunerConfig {
+	return &PoolTunerConfig{
+		Mode:                PoolTuneSuggest,
+		Interval:            time.Minute,
+		Initial:             PoolLimits{MaxOpen: 100, MaxIdle: 10},
+		MinOpenConns:        20,
+		MaxOpenConns:        200,
+		MinIdleConns:        5,
+		MaxIdleConns:        50,
+		Step:                10,
+		LowUtilization:      0.3,
+		StabilizationWindow: 5 * time.Minute,
+		RollbackTolerance:   0.2,
+		HistorySize:         100,
+	}
+}
+
+// Validate 校验调优配置
+func (c *PoolTunerConfig) Validate() error {
+	if c.Mode != PoolTuneSuggest && c.Mode != PoolTuneApply {
+		return fmt.Errorf("invalid pool tune mode %q", c.Mode)
+	}
+	if c.Interval <= 0 || c.Step <= 0 {
+		return fmt.Errorf("pool tuner interval and step must be positive")
+	}
+	if c.MinOpenConns <= 0 || c.MinOpenConns > c.MaxOpenConns {
+		return fmt.Errorf("invalid open conns range [%d, %d]", c.MinOpenConns, c.MaxOpenConns)
+	}
+	if c.MinIdleConns < 0 || c.MinIdleConns > c.MaxIdleConns {
+		return fmt.Errorf("invalid idle conns range [%d, %d]", c.MinIdleConns, c.MaxIdleConns)
+	}
+	if c.Initial.MaxOpen <= 0 {
+		return fmt.Errorf("initial max open conns must be positive")
+	}
+	return nil
+}
+
+// pendingChange 观察期内的修改
+type pendingChange struct {
+	event        PoolTuningEvent
+	appliedAt    time.Time
+	waitCount    int64   // 修改时连接池的累计等待次数
+	baselineRate float64 // 修改前每秒等待次数
+}
+
+// PoolTuner 连接池调优器：定期采样 sql.DBStats，连接不够用（出现等待）时增大、长期空闲时缩小，
+// 空闲连接因超过上限被频繁关闭时增大空闲上限。apply 模式下每次只调整 Step，修改后进入观察期，
+// 期间每秒等待次数上升则恢复原值，并在一个观察期内不再调整
+type PoolTuner struct {
+	pool             PoolController
+	store            PoolTuningStore
+	config           *PoolTunerConfig
+	clock            clock.Clock
+	metricsCollector *metrics.MetricsCollector
+
+	mu        sync.Mutex
+	limits    PoolLimits
+	last      sql.DBStats
+	lastAt    time.Time
+	sampled   bool
+	pending   *pendingChange
+	holdUntil time.Time
+	suggested PoolLimits
+	history   []PoolTuningEvent
+}
+
+// NewPoolTuner 创建连接池调优器，store 为 nil 时调优事件只保留在内存与日志中
+func NewPoolTuner(pool PoolController, store PoolTuningStore, config *PoolTunerConfig) (*PoolTuner, error) {
+	if config == nil {
+		config = DefaultPoolTunerConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &PoolTuner{
+		pool:             pool,
+		store:            store,
+		config:           config,
+		clock:            clock.OrReal(config.Clock),
+		metricsCollector: metrics.GetGlobalCollector(),
+		limits:           config.Initial,
+	}, nil
+}
+
+// Run 按采样间隔调优，直到 ctx 结束
+func (t *PoolTuner) Run(ctx context.Context) {
+	ticker := t.clock.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			t.Tick(ctx)
+		}
+	}
+}
+
+// Tick 采样一次并做出调整，第一次调用只记录基线
+func (t *PoolTuner) Tick(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	stats := t.pool.Stats()
+	prev, prevAt, sampled := t.last, t.lastAt, t.sampled
+	t.last, t.lastAt, t.sampled = stats, now, true
+	if !sampled {
+		return
+	}
+
+	if t.pending != nil {
+		t.observe(ctx, stats, now)
+		return
+	}
+	if now.Before(t.holdUntil) {
+		return
+	}
+
+	waits := stats.WaitCount - prev.WaitCount
+	next, reason := t.propose(stats, waits, stats.MaxIdleClosed-prev.MaxIdleClosed)
+	if next == t.limits {
+		return
+	}
+
+	event := PoolTuningEvent{
+		Action:       PoolTuningSuggested,
+		From:         t.limits,
+		To:           next,
+		Reason:       reason,
+		WaitCount:    waits,
+		WaitDuration: stats.WaitDuration - prev.WaitDuration,
+		InUse:        stats.InUse,
+		Timestamp:    now,
+	}
+	if t.config.Mode != PoolTuneApply {
+		// 建议模式下连接池不变，同样的建议只记录一次
+		if next != t.suggested {
+			t.suggested = next
+			t.record(ctx, event)
+		}
+		return
+	}
+
+	event.Action = PoolTuningApplied
+	t.apply(next)
+	t.record(ctx, event)
+	t.pending = &pendingChange{
+		event:        event,
+		appliedAt:    now,
+		waitCount:    stats.WaitCount,
+		baselineRate: perSecond(waits, now.Sub(prevAt)),
+	}
+}
+
+// propose 根据一个采样区间的等待次数与空闲连接关闭次数给出下一步的上限
+func (t *PoolTuner) propose(stats sql.DBStats, waits, idleClosed int64) (PoolLimits, string) {
+	next := t.limits
+	var reason string
+
+	switch {
+	case waits > 0 && next.MaxOpen < t.config.MaxOpenConns:
+		next.MaxOpen = min(next.MaxOpen+t.config.Step, t.config.MaxOpenConns)
+		reason = fmt.Sprintf("%d waits for a connection in the last interval", waits)
+	case waits == 0 && float64(stats.InUse) < float64(next.MaxOpen)*t.config.LowUtilization &&
+		next.MaxOpen > t.config.MinOpenConns:
+		next.MaxOpen = max(next.MaxOpen-t.config.Step, t.config.MinOpenConns)
+		reason = fmt.Sprintf("only %d of %d connections in use", stats.InUse, t.limits.MaxOpen)
+	}
+
+	// 空闲连接因超过上限被关闭说明连接在反复创建，空闲上限不超过最大连接数
+	idleCeiling := min(t.config.MaxIdleConns, next.MaxOpen)
+	if idleClosed > 0 && next.MaxIdle < idleCeiling {
+		next.MaxIdle = min(next.MaxIdle+t.config.Step, idleCeiling)
+		if reason != "" {
+			reason += "; "
+		}
+		reason += fmt.Sprintf("%d idle connections closed over the idle limit", idleClosed)
+	}
+	next.MaxIdle = max(min(next.MaxIdle, idleCeiling), min(t.config.MinIdleConns, next.MaxOpen))
+	return next, reason
+}
+
+// observe 观察期内比较修改前后的每秒等待次数，上升则回滚，观察期结束仍未上升则确认
+func (t *PoolTuner) observe(ctx context.Context, stats sql.DBStats, now time.Time) {
+	p := t.pending
+	waits := stats.WaitCount - p.waitCount
+	elapsed := now.Sub(p.appliedAt)
+	rate := perSecond(waits, elapsed)
+
+	event := PoolTuningEvent{
+		From:      p.event.To,
+		WaitCount: waits,
+		InUse:     stats.InUse,
+		Timestamp: now,
+	}
+	switch {
+	case waits > 0 && rate > p.baselineRate*(1+t.config.RollbackTolerance):
+		event.Action = PoolTuningRolledBack
+		event.To = p.event.From
+		event.Reason = fmt.Sprintf("wait rate rose from %.2f/s to %.2f/s", p.baselineRate, rate)
+		t.apply(p.event.From)
+		t.holdUntil = now.Add(t.config.StabilizationWindow)
+	case elapsed >= t.config.StabilizationWindow:
+		event.Action = PoolTuningConfirmed
+		event.To = p.event.To
+		event.Reason = fmt.Sprintf("wait rate %.2f/s within tolerance of %.2f/s", rate, p.baselineRate)
+	default:
+		return
+	}
+	t.pending = nil
+	t.record(ctx, event)
+}
+
+// apply 修改连接池上限，先调整的一方保证空闲上限不超过最大连接数
+func (t *PoolTuner) apply(limits PoolLimits) {
+	if limits.MaxOpen < t.limits.MaxOpen {
+		t.pool.SetMaxIdleConns(limits.MaxIdle)
+		t.pool.SetMaxOpenConns(limits.MaxOpen)
+	} else {
+		t.pool.SetMaxOpenConns(limits.MaxOpen)
+		t.pool.SetMaxIdleConns(limits.MaxIdle)
+	}
+	t.limits = limits
+}
+
+// record 记录调优事件到日志、指标、内存与存储，存储失败不影响调优
+func (t *PoolTuner) record(ctx context.Context, event PoolTuningEvent) {
+	log.Printf("Connection pool tuning %s: max_open %d -> %d, max_idle %d -> %d (%s)",
+		event.Action, event.From.MaxOpen, event.To.MaxOpen, event.From.MaxIdle, event.To.MaxIdle, event.Reason)
+
+	t.metricsCollector.RecordEvent("pool_tuner", string(event.Action), 1)
+
+	t.history = append(t.history, event)
+	if over := len(t.history) - t.config.HistorySize; over > 0 {
+		t.history = append(t.history[:0:0], t.history[over:]...)
+	}
+	if t.store != nil {
+		if err := t.store.InsertTuningEvent(ctx, event); err != nil {
+			log.Printf("Failed to save pool tuning event: %v", err)
+		}
+	}
+}
+
+// Limits 当前生效的连接池上限
+func (t *PoolTuner) Limits() PoolLimits {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limits
+}
+
+// Events 最近的调优事件，按时间先后排列
+func (t *PoolTuner) Events() []PoolTuningEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]PoolTuningEvent(nil), t.history...)
+}
+
+// perSecond 每秒次数，elapsed 不大于 0 时返回 0
+func perSecond(count int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
@@ -0,0 +1,149 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePool 统计数据由测试设置的连接池
+type fakePool struct {
+	stats   sql.DBStats
+	maxOpen int
+	maxIdle int
+}
+
+func (p *fakePool) Stats() sql.DBStats    { return p.stats }
+func (p *fakePool) SetMaxOpenConns(n int) { p.maxOpen = n }
+func (p *fakePool) SetMaxIdleConns(n int) { p.maxIdle = n }
+
+// memoryTuningStore 记录写入的调优事件
+type memoryTuningStore struct {
+	events []database.PoolTuningEvent
+}
+
+func (s *memoryTuningStore) InsertTuningEvent(ctx context.Context, event database.PoolTuningEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func newTestTuner(t *testing.T, mode database.PoolTuneMode) (*database.PoolTuner, *fakePool, *memoryTuningStore, *fakes.Clock) {
+	clk := fakes.NewClock(time.Time{})
+	config := database.DefaultPoolTunerConfig()
+	config.Mode = mode
+	config.Initial = database.PoolLimits{MaxOpen: 40, MaxIdle: 10}
+	config.MinOpenConns = 30
+	config.MaxOpenConns = 50
+	config.Clock = clk
+
+	pool := &fakePool{maxOpen: 40, maxIdle: 10, stats: sql.DBStats{InUse: 30}}
+	store := &memoryTuningStore{}
+	tuner, err := database.NewPoolTuner(pool, store, config)
+	require.NoError(t, err)
+	tuner.Tick(context.Background())
+	return tuner, pool, store, clk
+}
+
+func actions(events []database.PoolTuningEvent) []database.PoolTuningAction {
+	result := make([]database.PoolTuningAction, len(events))
+	for i, event := range events {
+		result[i] = event.Action
+	}
+	return result
+}
+
+func TestPoolTuner_AppliesAndConfirms(t *testing.T) {
+	ctx := context.Background()
+	tuner, pool, store, clk := newTestTuner(t, database.PoolTuneApply)
+
+	// 一分钟内等待 60 次，增大一个步长
+	clk.Advance(time.Minute)
+	pool.stats.WaitCount = 60
+	tuner.Tick(ctx)
+	assert.Equal(t, 50, pool.maxOpen)
+	assert.Equal(t, database.PoolLimits{MaxOpen: 50, MaxIdle: 10}, tuner.Limits())
+
+	// 观察期内等待减少，期满后确认；仍有等待也不会超过上限
+	for i := 0; i < 5; i++ {
+		clk.Advance(time.Minute)
+		pool.stats.WaitCount += 5
+		tuner.Tick(ctx)
+	}
+	clk.Advance(time.Minute)
+	pool.stats.WaitCount += 5
+	tuner.Tick(ctx)
+
+	assert.Equal(t, 50, pool.maxOpen)
+	assert.Equal(t, []database.PoolTuningAction{database.PoolTuningApplied, database.PoolTuningConfirmed}, actions(store.events))
+	assert.Equal(t, store.events, tuner.Events())
+}
+
+func TestPoolTuner_RollsBackWhenWaitsRise(t *testing.T) {
+	ctx := context.Background()
+	tuner, pool, store, clk := newTestTuner(t, database.PoolTuneApply)
+
+	// 没有等待且使用率低，缩小一个步长，不低于下限
+	pool.stats.InUse = 5
+	clk.Advance(time.Minute)
+	tuner.Tick(ctx)
+	assert.Equal(t, 30, pool.maxOpen)
+
+	// 缩小后出现等待，回滚到原值
+	pool.stats.WaitCount = 3
+	clk.Advance(time.Minute)
+	tuner.Tick(ctx)
+	assert.Equal(t, 40, pool.maxOpen)
+	assert.Equal(t, 10, pool.maxIdle)
+	require.Len(t, store.events, 2)
+	rollback := store.events[1]
+	assert.Equal(t, database.PoolTuningRolledBack, rollback.Action)
+	assert.Equal(t, database.PoolLimits{MaxOpen: 30, MaxIdle: 10}, rollback.From)
+	assert.Equal(t, database.PoolLimits{MaxOpen: 40, MaxIdle: 10}, rollback.To)
+
+	// 回滚后一个观察期内不再调整
+	clk.Advance(time.Minute)
+	tuner.Tick(ctx)
+	assert.Equal(t, 40, pool.maxOpen)
+	assert.Len(t, store.events, 2)
+
+	clk.Advance(5 * time.Minute)
+	tuner.Tick(ctx)
+	assert.Equal(t, 30, pool.maxOpen)
+}
+
+func TestPoolTuner_SuggestDoesNotApply(t *testing.T) {
+	ctx := context.Background()
+	tuner, pool, store, clk := newTestTuner(t, database.PoolTuneSuggest)
+
+	for i := 1; i <= 3; i++ {
+		clk.Advance(time.Minute)
+		pool.stats.WaitCount += 10
+		pool.stats.MaxIdleClosed += 20
+		tuner.Tick(ctx)
+	}
+
+	assert.Equal(t, 40, pool.maxOpen)
+	assert.Equal(t, database.PoolLimits{MaxOpen: 40, MaxIdle: 10}, tuner.Limits())
+	require.Len(t, store.events, 1, "the same suggestion is recorded once")
+	assert.Equal(t, database.PoolTuningSuggested, store.events[0].Action)
+	assert.Equal(t, database.PoolLimits{MaxOpen: 50, MaxIdle: 20}, store.events[0].To)
+}
+
+func TestPoolTunerConfig_Validate(t *testing.T) {
+	config := database.DefaultPoolTunerConfig()
+	require.NoError(t, config.Validate())
+
+	config.Mode = "auto"
+	assert.Error(t, config.Validate())
+
+	config = database.DefaultPoolTunerConfig()
+	config.MinOpenConns = 300
+	_, err := database.NewPoolTuner(&fakePool{}, nil, config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// poolTuningEventRow pool_tuning_events 表的行
+type poolTuningEventRow struct {
+	Action         string    `db:"action"`
+	FromMaxOpen    int       `db:"from_max_open"`
+	FromMaxIdle    int       `db:"from_max_idle"`
+	ToMaxOpen      int       `db:"to_max_open"`
+	ToMaxIdle      int       `db:"to_max_idle"`
+	Reason         string    `db:"reason"`
+	WaitCount      int64     `db:"wait_count"`
+	WaitDurationMs int64     `db:"wait_duration_ms"`
+	InUse          int       `db:"in_use"`
+	OccurredAt     time.Time `db:"occurred_at"`
+}
+
+// SQLPoolTuningStore 基于 pool_tuning_events 表的调优事件存储
+type SQLPoolTuningStore struct {
+	db *DB
+}
+
+var _ PoolTuningStore = (*SQLPoolTuningStore)(nil)
+
+// NewSQLPoolTuningStore 创建调优事件存储
+func NewSQLPoolTuningStore(db *DB) *SQLPoolTuningStore {
+	return &SQLPoolTuningStore{db: db}
+}
+
+// InsertTuningEvent 写入一条调优事件
+func (s *SQLPoolTuningStore) InsertTuningEvent(ctx context.Context, event PoolTuningEvent) error {
+	query := `
+		INSERT INTO pool_tuning_events (action, from_max_open, from_max_idle, to_max_open, to_max_idle,
+			reason, wait_count, wait_duration_ms, in_use, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	if _, err := s.db.ExecContext(ctx, query, string(event.Action), event.From.MaxOpen, event.From.MaxIdle,
+		event.To.MaxOpen, event.To.MaxIdle, event.Reason, event.WaitCount, event.WaitDuration.Milliseconds(),
+		event.InUse, event.Timestamp); err != nil {
+		return fmt.Errorf("failed to insert pool tuning event: %w", err)
+	}
+	return nil
+}
+
+// ListTuningEvents 按时间倒序返回最近 limit 条调优事件
+func (s *SQLPoolTuningStore) ListTuningEvents(ctx context.Context, limit int) ([]PoolTuningEvent, error) {
+	query := `
+		SELECT action, from_max_open, from_max_idle, to_max_open, to_max_idle,
+			reason, wait_count, wait_duration_ms, in_use, occurred_at
+		FROM pool_tuning_events
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $1`
+	var rows []poolTuningEventRow
+	if err := s.db.SelectContext(ctx, &rows, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list pool tuning events: %w", err)
+	}
+
+	events := make([]PoolTuningEvent, len(rows))
+	for i, row := range rows {
+		events[i] = PoolTuningEvent{
+			Action:       PoolTuningAction(row.Action),
+			From:         PoolLimits{MaxOpen: row.FromMaxOpen, MaxIdle: row.FromMaxIdle},
+			To:           PoolLimits{MaxOpen: row.ToMaxOpen, MaxIdle: row.ToMaxIdle},
+			Reason:       row.Reason,
+			WaitCount:    row.WaitCount,
+			WaitDuration: time.Duration(row.WaitDurationMs) * time.Millisecond,
+			InUse:        row.InUse,
+			Timestamp:    row.OccurredAt,
+		}
+	}
+	return events, nil
+}
@@ -5,43 +5,80 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"user_crud_jwt/internal/pkg/config"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
 
 // DB wraps sqlx.DB for additional functionality
 type DB struct {
 	*sqlx.DB
+
+	slowQueryThreshold  time.Duration
+	slowQueryConfigured bool
+	onSlowQuery         func(SlowQuery)
+	faultHook           FaultHook
+	queryTimeouts       *QueryTimeouts
+	rowSecurity         atomic.Bool
+
+	// 建立连接使用的连接串与 application_name，Reconnect 与副本连接沿用
+	dsn             string
+	applicationName string
 }
 
-// InitDatabase 初始化数据库连接
+// InitDatabase 初始化 api 组件的数据库连接
 func InitDatabase() *DB {
+	return InitDatabaseFor(ComponentAPI)
+}
+
+// InitDatabaseFor 以组件的 application_name 与登录角色（database.roles）建立连接池
+func InitDatabaseFor(component Component) *DB {
 	cfg := config.GlobalConfig.Database
 
 	// Build connection string
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
-		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode, cfg.TimeZone)
+	dsn := ConnectionString(cfg, component)
 
 	// Connect using pgx driver
-	db, err := sqlx.Connect("pgx", dsn)
+	d := &DB{dsn: dsn, applicationName: ApplicationName(cfg, component)}
+	db, err := d.open(context.Background(), dsn)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to connect to database as %s: %v", component, err)
 	}
+	d.DB = db
 
 	// Configure connection pool
 	configureConnectionPool(db.DB)
+	// 最大连接数按组件区分，空闲连接数保持为其 10%
+	limit := maxOpenConns(cfg, component)
+	db.SetMaxOpenConns(limit)
+	db.SetMaxIdleConns(max(limit/10, 1))
 
 	// Test connection
 	if err := db.Ping(); err != nil {
 		log.Fatalf("Failed to ping database: %v", err)
 	}
 
-	log.Println("Database connected successfully")
-	return &DB{DB: db}
+	log.Printf("Database connected successfully as %s", ApplicationName(cfg, component))
+	return d
+}
+
+// open 以 pgx 驱动建立连接池并测试连接，连接再次取出前由 resetSession 清空行级安全的会话变量
+func (db *DB) open(ctx context.Context, dsn string) (*sqlx.DB, error) {
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn := sqlx.NewDb(stdlib.OpenDB(*connConfig, stdlib.OptionResetSession(db.resetSession)), "pgx")
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
 }
 
 // configureConnectionPool 配置数据库连接池
@@ -61,60 +98,161 @@ func configureConnectionPool(sqlDB *sql.DB) {
 	log.Println("Database connection pool configured successfully")
 }
 
-// BeginTx 开始事务
+// BeginTx 开始事务，启用行级安全时写入 ctx 中的租户与用户
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
-	return db.DB.BeginTxx(ctx, opts)
+	if err := db.injectFault(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+	tx, err := db.DB.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.applyRowSecurity(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
 }
 
 // ExecContext 执行SQL语句
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return db.DB.ExecContext(ctx, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	defer cancel()
+	defer db.observeQuery(ctx, query, time.Now())
+	if err := db.injectFault(ctx, query); err != nil {
+		return nil, err
+	}
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return conn.ExecContext(ctx, query, args...)
 }
 
-// QueryContext 查询多行
+// QueryContext 查询多行，超时覆盖到读取结果集结束
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
-	return db.DB.QueryxContext(ctx, query, args...)
+	// 结果集在返回后读取，不能在返回前取消；超时到达时上下文自行释放
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	_ = cancel
+	defer db.observeQuery(ctx, query, time.Now())
+	if err := db.injectFault(ctx, query); err != nil {
+		return nil, err
+	}
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseAfterRows(release)
+	return conn.QueryxContext(ctx, query, args...)
 }
 
-// QueryRowContext 查询单行
+// QueryRowContext 查询单行，故障注入只对其生效延迟（sqlx.Row 无法携带外部错误）
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
-	return db.DB.QueryRowxContext(ctx, query, args...)
+	// 行在返回后才 Scan，同 QueryContext 不能提前取消
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	_ = cancel
+	defer db.observeQuery(ctx, query, time.Now())
+	db.injectFault(ctx, query)
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		// sqlx.Row 无法携带外部错误，以已取消的上下文查询，Scan 时返回 context.Canceled
+		log.Printf("Failed to acquire row security session: %v", err)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		return db.DB.QueryRowxContext(canceled, query, args...)
+	}
+	defer releaseAfterRows(release)
+	return conn.QueryRowxContext(ctx, query, args...)
 }
 
 // GetContext 查询单行到结构体
 func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return db.DB.GetContext(ctx, dest, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	defer cancel()
+	defer db.observeQuery(ctx, query, time.Now())
+	if err := db.injectFault(ctx, query); err != nil {
+		return err
+	}
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return conn.GetContext(ctx, dest, query, args...)
 }
 
 // SelectContext 查询多行到切片
 func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
-	return db.DB.SelectContext(ctx, dest, query, args...)
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	defer cancel()
+	defer db.observeQuery(ctx, query, time.Now())
+	if err := db.injectFault(ctx, query); err != nil {
+		return err
+	}
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return conn.SelectContext(ctx, dest, query, args...)
 }
 
 // NamedExec 执行命名参数SQL
 func (db *DB) NamedExec(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
-	return db.DB.NamedExecContext(ctx, query, arg)
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	defer cancel()
+	defer db.observeQuery(ctx, query, time.Now())
+	if err := db.injectFault(ctx, query); err != nil {
+		return nil, err
+	}
+	bound, args, err := db.DB.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return conn.ExecContext(ctx, bound, args...)
 }
 
 // NamedQuery 查询命名参数SQL
 func (db *DB) NamedQuery(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
-	return db.DB.NamedQueryContext(ctx, query, arg)
+	// 同 QueryContext，结果集在返回后读取
+	ctx, cancel := db.withQueryTimeout(ctx, query)
+	_ = cancel
+	defer db.observeQuery(ctx, query, time.Now())
+	if err := db.injectFault(ctx, query); err != nil {
+		return nil, err
+	}
+	bound, args, err := db.DB.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	conn, release, err := db.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseAfterRows(release)
+	return conn.QueryxContext(ctx, bound, args...)
 }
 
 // Reconnect 重新连接数据库
-func (db *DB) Reconnect() error {
-	if err := db.DB.Ping(); err != nil {
+func (db *DB) Reconnect(ctx context.Context) error {
+	if err := db.DB.PingContext(ctx); err != nil {
 		log.Printf("Database connection lost, attempting to reconnect: %v", err)
 
 		// Close existing connection
 		db.DB.Close()
 
 		// Reconnect
-		cfg := config.GlobalConfig.Database
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
-			cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode, cfg.TimeZone)
+		dsn := db.dsn
+		if dsn == "" {
+			dsn = ConnectionString(config.GlobalConfig.Database, ComponentAPI)
+		}
 
-		newDB, err := sqlx.Connect("postgres", dsn)
+		newDB, err := db.open(ctx, dsn)
 		if err != nil {
 			return fmt.Errorf("failed to reconnect to database: %v", err)
 		}
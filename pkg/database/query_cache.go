@@ -0,0 +1,286 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// QueryCacheConfig 查询缓存配置
+type QueryCacheConfig struct {
+	Enabled       bool          `json:"enabled"`
+	TTL           time.Duration `json:"ttl"`         // 查询结果缓存时间
+	VersionTTL    time.Duration `json:"version_ttl"` // 表版本号保留时间，应大于 TTL
+	EnableMetrics bool          `json:"enable_metrics"`
+}
+
+// DefaultQueryCacheConfig 默认查询缓存配置
+func DefaultQueryCacheConfig() *QueryCacheConfig {
+	return &QueryCacheConfig{
+		Enabled:       true,
+		TTL:           time.Minute,
+		VersionTTL:    24 * time.Hour,
+		EnableMetrics: true,
+	}
+}
+
+// QueryCache 基于规范化 SQL 的读穿查询缓存
+//
+// 每条缓存结果记录其依赖表及写入时的表版本号；对表执行写操作时递增版本号，
+// 读取时版本不一致即视为未命中，因此无需枚举删除该表的所有查询缓存。
+type QueryCache struct {
+	db               *DB
+	cache            cache.CacheService
+	config           *QueryCacheConfig
+	metricsCollector *metrics.MetricsCollector
+}
+
+// queryCacheEntry 查询缓存条目
+type queryCacheEntry struct {
+	Versions map[string]string `json:"versions"`
+	Data     json.RawMessage   `json:"data"`
+}
+
+type queryCacheCtxKey struct{}
+
+// NoQueryCache 返回跳过查询缓存的上下文，用于需要强一致读的场景
+func NoQueryCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCacheCtxKey{}, true)
+}
+
+func skipQueryCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(queryCacheCtxKey{}).(bool)
+	return skip
+}
+
+// NewQueryCache 创建查询缓存
+func NewQueryCache(db *DB, cacheService cache.CacheService, config *QueryCacheConfig) *QueryCache {
+	if config == nil {
+		config = DefaultQueryCacheConfig()
+	}
+	return &QueryCache{
+		db:               db,
+		cache:            cacheService,
+		config:           config,
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// SelectContext 带缓存的多行查询
+func (qc *QueryCache) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return qc.readThrough(ctx, dest, query, args, func() error {
+		return qc.db.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+// GetContext 带缓存的单行查询
+func (qc *QueryCache) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return qc.readThrough(ctx, dest, query, args, func() error {
+		return qc.db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+// ExecContext 执行写操作并失效相关表的查询缓存
+func (qc *QueryCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := qc.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := qc.InvalidateTables(ctx, ExtractTables(query)...); err != nil {
+		log.Printf("Failed to invalidate query cache after write: %v", err)
+	}
+	return result, nil
+}
+
+// NamedExec 执行命名参数写操作并失效相关表的查询缓存
+func (qc *QueryCache) NamedExec(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	result, err := qc.db.NamedExec(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := qc.InvalidateTables(ctx, ExtractTables(query)...); err != nil {
+		log.Printf("Failed to invalidate query cache after write: %v", err)
+	}
+	return result, nil
+}
+
+// InvalidateTables 递增表版本号，使依赖这些表的缓存结果失效
+func (qc *QueryCache) InvalidateTables(ctx context.Context, tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+
+	version := strconv.FormatInt(time.Now().UnixNano(), 10)
+	entries := make([]cache.CacheEntry, 0, len(tables))
+	for _, table := range tables {
+		entries = append(entries, cache.CacheEntry{
+			Key:        tableVersionKey(table),
+			Value:      version,
+			Expiration: qc.config.VersionTTL,
+		})
+	}
+
+	result, err := qc.cache.SetMany(ctx, entries)
+	if err != nil {
+		return fmt.Errorf("failed to bump table versions: %w", err)
+	}
+	if result.HasErrors() {
+		return fmt.Errorf("failed to bump versions for %d tables", len(result.Errors))
+	}
+	return nil
+}
+
+// readThrough 读穿缓存
+func (qc *QueryCache) readThrough(ctx context.Context, dest interface{}, query string, args []interface{}, load func() error) error {
+	tables := ExtractTables(query)
+	if !qc.config.Enabled || skipQueryCache(ctx) || len(tables) == 0 {
+		return load()
+	}
+
+	start := time.Now()
+	key, err := queryCacheKey(query, args)
+	if err != nil {
+		return load()
+	}
+
+	versions, err := qc.tableVersions(ctx, tables)
+	if err != nil {
+		log.Printf("Failed to read table versions, bypassing query cache: %v", err)
+		return load()
+	}
+
+	var entry queryCacheEntry
+	if err := qc.cache.Get(ctx, key, &entry); err == nil && versionsEqual(entry.Versions, versions) {
+		if err := json.Unmarshal(entry.Data, dest); err == nil {
+			qc.recordMetrics("hit", time.Since(start), true)
+			return nil
+		}
+	}
+
+	if err := load(); err != nil {
+		qc.recordMetrics("load_error", time.Since(start), false)
+		return err
+	}
+
+	data, err := json.Marshal(dest)
+	if err != nil {
+		return nil
+	}
+	entry = queryCacheEntry{Versions: versions, Data: data}
+	if err := qc.cache.Set(ctx, key, entry, qc.config.TTL); err != nil {
+		log.Printf("Failed to cache query result: %v", err)
+	}
+
+	qc.recordMetrics("miss", time.Since(start), true)
+	return nil
+}
+
+// tableVersions 获取依赖表的当前版本号，未记录的表版本为空
+func (qc *QueryCache) tableVersions(ctx context.Context, tables []string) (map[string]string, error) {
+	keys := make([]string, len(tables))
+	for i, table := range tables {
+		keys[i] = tableVersionKey(table)
+	}
+
+	result, err := qc.cache.GetMany(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if result.HasErrors() {
+		return nil, fmt.Errorf("failed to read %d table versions", len(result.Errors))
+	}
+
+	versions := make(map[string]string, len(tables))
+	for i, table := range tables {
+		var version string
+		if result.Hit(keys[i]) {
+			if err := result.Decode(keys[i], &version); err != nil {
+				return nil, err
+			}
+		}
+		versions[table] = version
+	}
+	return versions, nil
+}
+
+// recordMetrics 记录指标
+func (qc *QueryCache) recordMetrics(operation string, duration time.Duration, success bool) {
+	if !qc.config.EnableMetrics {
+		return
+	}
+
+	qc.metricsCollector.RecordDBQuery("query_cache", operation, duration, success)
+	if !success {
+		qc.metricsCollector.RecordDBError("query_cache_error", operation)
+	}
+}
+
+func versionsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for table, version := range b {
+		if a[table] != version {
+			return false
+		}
+	}
+	return true
+}
+
+func tableVersionKey(table string) string {
+	return fmt.Sprintf("query_cache:table_version:%s", table)
+}
+
+// queryCacheKey 根据规范化 SQL 与参数生成缓存键
+func queryCacheKey(query string, args []interface{}) (string, error) {
+	argData, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query args: %w", err)
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(NormalizeSQL(query)))
+	hash.Write([]byte{0})
+	hash.Write(argData)
+	return "query_cache:result:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+// NormalizeSQL 规范化 SQL：合并空白并去除结尾分号，字符串字面量保持不变
+func NormalizeSQL(query string) string {
+	normalized := whitespacePattern.ReplaceAllString(strings.TrimSpace(query), " ")
+	return strings.TrimRight(normalized, "; ")
+}
+
+var tablePattern = regexp.MustCompile(`(?i)\b(?:from|join|into|update)\s+("?[a-zA-Z_][a-zA-Z0-9_]*"?(?:\."?[a-zA-Z_][a-zA-Z0-9_]*"?)?)`)
+
+// ExtractTables 提取 SQL 中引用的表名（小写、去引号、去重并排序）
+func ExtractTables(query string) []string {
+	matches := tablePattern.FindAllStringSubmatch(query, -1)
+	seen := make(map[string]bool, len(matches))
+	tables := make([]string, 0, len(matches))
+	for _, match := range matches {
+		table := strings.ToLower(strings.ReplaceAll(match[1], `"`, ""))
+		if seen[table] {
+			continue
+		}
+		seen[table] = true
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
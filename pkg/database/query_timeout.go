@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// 查询类别，决定使用哪一项超时预算
+const (
+	QueryClassRead        = "read"        // SELECT / WITH
+	QueryClassWrite       = "write"       // INSERT、UPDATE、DELETE 等
+	QueryClassReport      = "report"      // 由 ReportQuery 标记的报表、导出查询
+	QueryClassMaintenance = "maintenance" // CREATE、DROP、ALTER、REINDEX、ANALYZE、VACUUM 等维护语句
+)
+
+// maintenancePrefixes 维护语句的开头
+var maintenancePrefixes = []string{"create", "drop", "alter", "reindex", "analyze", "vacuum", "refresh", "cluster"}
+
+// QueryTimeouts 各类查询的超时预算，小于等于 0 表示不限制。
+// 超时通过 context 截止时间传给驱动，到期时 pgx 取消服务端正在执行的语句
+type QueryTimeouts struct {
+	Read        time.Duration `json:"read"`
+	Write       time.Duration `json:"write"`
+	Report      time.Duration `json:"report"`
+	Maintenance time.Duration `json:"maintenance"`
+}
+
+// DefaultQueryTimeouts 默认超时预算：在线读 5 秒、写 10 秒、报表 2 分钟，维护语句不限制
+// （CREATE INDEX CONCURRENTLY 等可能执行很久）
+func DefaultQueryTimeouts() *QueryTimeouts {
+	return &QueryTimeouts{
+		Read:   5 * time.Second,
+		Write:  10 * time.Second,
+		Report: 2 * time.Minute,
+	}
+}
+
+// SetQueryTimeouts 设置查询超时预算，需在处理请求前设置；nil 表示不限制（调用方传入的 ctx 截止时间仍然生效）。
+// 超时只作用于 DB 上的方法，事务内的语句使用 BeginTx 时传入的 ctx
+func (db *DB) SetQueryTimeouts(timeouts *QueryTimeouts) {
+	db.queryTimeouts = timeouts
+}
+
+type queryClassCtxKey struct{}
+
+type queryTimeoutCtxKey struct{}
+
+// ReportQuery 返回按报表预算执行查询的上下文，用于导出、统计等允许较长耗时的查询
+func ReportQuery(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryClassCtxKey{}, QueryClassReport)
+}
+
+// WithQueryTimeout 返回为单次调用指定超时的上下文，覆盖按类别的预算；timeout 小于等于 0 时不限制
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutCtxKey{}, timeout)
+}
+
+// QueryClass 查询所属的类别
+func QueryClass(ctx context.Context, query string) string {
+	if class, ok := ctx.Value(queryClassCtxKey{}).(string); ok {
+		return class
+	}
+	if queryOperation(query) == "read" {
+		return QueryClassRead
+	}
+	trimmed := strings.ToLower(strings.TrimSpace(query))
+	for _, prefix := range maintenancePrefixes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return QueryClassMaintenance
+		}
+	}
+	return QueryClassWrite
+}
+
+// queryTimeout 查询适用的超时，0 表示不限制
+func (db *DB) queryTimeout(ctx context.Context, query string) time.Duration {
+	if timeout, ok := ctx.Value(queryTimeoutCtxKey{}).(time.Duration); ok {
+		return timeout
+	}
+	if db.queryTimeouts == nil {
+		return 0
+	}
+	switch QueryClass(ctx, query) {
+	case QueryClassRead:
+		return db.queryTimeouts.Read
+	case QueryClassReport:
+		return db.queryTimeouts.Report
+	case QueryClassMaintenance:
+		return db.queryTimeouts.Maintenance
+	default:
+		return db.queryTimeouts.Write
+	}
+}
+
+// withQueryTimeout 按查询类别为 ctx 加上超时；不限制时原样返回
+func (db *DB) withQueryTimeout(ctx context.Context, query string) (context.Context, context.CancelFunc) {
+	timeout := db.queryTimeout(ctx, query)
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
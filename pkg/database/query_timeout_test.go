@@ -0,0 +1,67 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryClass(t *testing.T) {
+	ctx := context.Background()
+	cases := map[string]string{
+		"SELECT * FROM users":                           database.QueryClassRead,
+		"  with recent AS (SELECT 1) SELECT * FROM x":   database.QueryClassRead,
+		"UPDATE users SET name = $1":                    database.QueryClassWrite,
+		"CREATE INDEX CONCURRENTLY idx_a ON users (id)": database.QueryClassMaintenance,
+		"analyze users":                                 database.QueryClassMaintenance,
+	}
+	for query, class := range cases {
+		assert.Equal(t, class, database.QueryClass(ctx, query), query)
+	}
+	assert.Equal(t, database.QueryClassReport, database.QueryClass(database.ReportQuery(ctx), "SELECT 1"))
+}
+
+func TestQueryTimeout_CancelsAndCapturesQuery(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	db.SetQueryTimeouts(&database.QueryTimeouts{Read: 20 * time.Millisecond, Report: time.Second})
+	db.SetSlowQueryThreshold(time.Hour)
+	var captured []database.SlowQuery
+	db.OnSlowQuery(func(q database.SlowQuery) { captured = append(captured, q) })
+
+	mock.ExpectQuery("SELECT count").WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	var count int
+	err := db.GetContext(context.Background(), &count, "SELECT count(*) FROM users")
+	require.Error(t, err)
+
+	require.Len(t, captured, 1, "a timed out query is captured even below the slow query threshold")
+	assert.True(t, captured[0].TimedOut)
+	assert.Equal(t, database.QueryClassRead, captured[0].Class)
+	assert.Less(t, captured[0].Duration, 500*time.Millisecond)
+}
+
+func TestQueryTimeout_ReportAndOverride(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	db.SetQueryTimeouts(&database.QueryTimeouts{Read: 20 * time.Millisecond, Report: time.Second})
+	ctx := context.Background()
+
+	// 报表查询使用更长的预算
+	mock.ExpectQuery("SELECT count").WillDelayFor(50 * time.Millisecond).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	var count int
+	require.NoError(t, db.GetContext(database.ReportQuery(ctx), &count, "SELECT count(*) FROM users"))
+	assert.Equal(t, 3, count)
+
+	// 单次调用指定的超时优先，小于等于 0 时不限制
+	mock.ExpectExec("UPDATE users").WillDelayFor(50 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := db.ExecContext(database.WithQueryTimeout(ctx, 0), "UPDATE users SET name = $1", "a")
+	require.NoError(t, err)
+
+	mock.ExpectExec("UPDATE users").WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err = db.ExecContext(database.WithQueryTimeout(ctx, 20*time.Millisecond), "UPDATE users SET name = $1", "a")
+	assert.Error(t, err)
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"user_crud_jwt/pkg/ctxutil"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+// 行级安全策略读取的会话变量。事务中以 SET LOCAL 语义写入，不在事务中的语句在独占的连接上以会话级写入，
+// 连接再次取出前清空（见 resetSession）
+const (
+	TenantSetting    = "app.tenant_id"
+	UserSetting      = "app.user_id"
+	AllOwnersSetting = "app.all_owners" // 为 on 时不做所有者限定（管理员），仍按租户限定
+	BypassSetting    = "app.bypass"     // 为 on 时不做任何限定，只用于后台任务等系统调用
+)
+
+// RowSecurityPolicy 表的行级安全策略：TenantColumn 非空时只能访问 app.tenant_id 的行，
+// OwnerColumn 非空时只能访问 app.user_id 拥有的行。会话变量为空或未设置时拒绝所有行，
+// 系统调用需写入 app.bypass 才能访问
+type RowSecurityPolicy struct {
+	Table        string
+	TenantColumn string
+	OwnerColumn  string
+}
+
+// DefaultRowSecurityPolicies 带租户列的业务表；用户拥有的数据同时按 user_id 限定所有者。
+// users、coupons 需要跨用户读取（作者资料、领取优惠券），只按租户限定
+var DefaultRowSecurityPolicies = []RowSecurityPolicy{
+	{Table: "users", TenantColumn: TenantColumn},
+	{Table: "coupons", TenantColumn: TenantColumn},
+	{Table: "user_coupons", TenantColumn: TenantColumn, OwnerColumn: "user_id"},
+	{Table: "experiment_exposures", TenantColumn: TenantColumn},
+	{Table: "user_activities", TenantColumn: TenantColumn, OwnerColumn: "user_id"},
+	{Table: "data_exports", TenantColumn: TenantColumn, OwnerColumn: "user_id"},
+	{Table: "account_deletions", TenantColumn: TenantColumn, OwnerColumn: "user_id"},
+}
+
+// RowSecurityMigration 行级安全迁移的内容，Up 启用策略，Down 删除策略并关闭行级安全
+type RowSecurityMigration struct {
+	Up   string
+	Down string
+}
+
+// PolicyName 表的行级安全策略名
+func (p RowSecurityPolicy) PolicyName() string {
+	return p.Table + "_row_security"
+}
+
+// expression 策略的条件：app.bypass 为 on 时放行，否则租户与所有者都须与会话变量相等。
+// 未设置的会话变量 current_setting(..., true) 返回 NULL，空字符串以 NULLIF 同样视为 NULL，比较结果不为真，拒绝访问；
+// 所有者列转为文本比较，兼容整数与 UUID 主键
+func (p RowSecurityPolicy) expression() string {
+	var parts []string
+	if p.TenantColumn != "" {
+		parts = append(parts, fmt.Sprintf("%s = %s", pgx.Identifier{p.TenantColumn}.Sanitize(), settingValue(TenantSetting)))
+	}
+	if p.OwnerColumn != "" {
+		parts = append(parts, fmt.Sprintf("(%s OR %s::text = %s)", settingOn(AllOwnersSetting), pgx.Identifier{p.OwnerColumn}.Sanitize(), settingValue(UserSetting)))
+	}
+	return fmt.Sprintf("%s OR (%s)", settingOn(BypassSetting), strings.Join(parts, " AND "))
+}
+
+// settingValue 会话变量的值，为空时返回 NULL
+func settingValue(setting string) string {
+	return fmt.Sprintf("NULLIF(current_setting('%s', true), '')", setting)
+}
+
+// settingOn 会话变量为 on
+func settingOn(setting string) string {
+	return fmt.Sprintf("current_setting('%s', true) = 'on'", setting)
+}
+
+// GenerateRowSecurity 生成行级安全迁移：启用并强制（表所有者同样受限）行级安全，每张表一条策略，
+// 读取（USING）与写入（WITH CHECK）使用相同的条件，不能写入其他租户或其他用户的行
+func GenerateRowSecurity(policies []RowSecurityPolicy) (*RowSecurityMigration, error) {
+	if len(policies) == 0 {
+		return nil, errors.New("no row security policies")
+	}
+
+	var up, down strings.Builder
+	up.WriteString("-- 行级安全：按会话变量 app.tenant_id、app.user_id 限定可见的行，由 go run ./cmd/migrate -rls 生成；变量为空时拒绝，系统调用写入 app.bypass\n")
+	down.WriteString("-- 删除行级安全策略，由 go run ./cmd/migrate -rls 生成\n")
+
+	for _, policy := range policies {
+		if policy.Table == "" || (policy.TenantColumn == "" && policy.OwnerColumn == "") {
+			return nil, fmt.Errorf("row security policy for %q needs a table and a tenant or owner column", policy.Table)
+		}
+		table := pgx.Identifier{policy.Table}.Sanitize()
+		name := pgx.Identifier{policy.PolicyName()}.Sanitize()
+		expression := policy.expression()
+
+		fmt.Fprintf(&up, "\n-- %s\n", policy.Table)
+		fmt.Fprintf(&up, "ALTER TABLE %s ENABLE ROW LEVEL SECURITY;\n", table)
+		fmt.Fprintf(&up, "ALTER TABLE %s FORCE ROW LEVEL SECURITY;\n", table)
+		fmt.Fprintf(&up, "DROP POLICY IF EXISTS %s ON %s;\n", name, table)
+		fmt.Fprintf(&up, "CREATE POLICY %s ON %s\n    USING (%s)\n    WITH CHECK (%s);\n", name, table, expression, expression)
+
+		fmt.Fprintf(&down, "\n-- %s\n", policy.Table)
+		fmt.Fprintf(&down, "DROP POLICY IF EXISTS %s ON %s;\n", name, table)
+		fmt.Fprintf(&down, "ALTER TABLE %s NO FORCE ROW LEVEL SECURITY;\n", table)
+		fmt.Fprintf(&down, "ALTER TABLE %s DISABLE ROW LEVEL SECURITY;\n", table)
+	}
+	return &RowSecurityMigration{Up: up.String(), Down: down.String()}, nil
+}
+
+// setRowSecurityQuery 写入全部会话变量，$9 为 is_local
+const setRowSecurityQuery = `SELECT set_config($1, $2, $9), set_config($3, $4, $9), set_config($5, $6, $9), set_config($7, $8, $9)`
+
+// resetRowSecurityQuery 清空全部会话变量，之后策略拒绝所有行
+const resetRowSecurityQuery = `SELECT set_config('app.tenant_id', '', false), set_config('app.user_id', '', false), ` +
+	`set_config('app.all_owners', '', false), set_config('app.bypass', '', false)`
+
+// rowSecurityArgs ctx 对应的会话变量。来自请求的上下文（带租户、用户或请求 ID）按其租户与用户限定，
+// 管理员（ctxutil.WithAllOwners）不做所有者限定，缺少租户时策略拒绝所有行；
+// 不来自请求的上下文（后台任务等系统调用）写入 app.bypass
+func rowSecurityArgs(ctx context.Context, local bool) []interface{} {
+	tenantID, userID, allOwners := ctxutil.TenantID(ctx), ctxutil.UserID(ctx), ctxutil.AllOwners(ctx)
+	system := tenantID == "" && userID == "" && !allOwners && ctxutil.RequestID(ctx) == ""
+	return []interface{}{
+		TenantSetting, tenantID,
+		UserSetting, userID,
+		AllOwnersSetting, onOff(allOwners),
+		BypassSetting, onOff(system),
+		local,
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return ""
+}
+
+// SetRowSecurityContext 在事务中写入 ctx 对应的会话变量（set_config 的 is_local 为 true，等同 SET LOCAL），
+// 提交或回滚后失效，不会残留在连接池的连接上
+func SetRowSecurityContext(ctx context.Context, tx *sqlx.Tx) error {
+	if _, err := tx.ExecContext(ctx, setRowSecurityQuery, rowSecurityArgs(ctx, true)...); err != nil {
+		return fmt.Errorf("failed to set row security context: %w", err)
+	}
+	return nil
+}
+
+// EnableRowSecurity 之后 BeginTx 开启的事务与不在事务中的语句都写入 ctx 对应的会话变量，需在处理请求前设置
+func (db *DB) EnableRowSecurity() {
+	db.rowSecurity.Store(true)
+}
+
+// RunScoped 在写入了 ctx 对应会话变量的事务中执行 fn 并提交，未调用 EnableRowSecurity 时同样写入，
+// 用于需要数据库兜底所有权检查的读取与修改
+func (db *DB) RunScoped(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	return db.runTx(ctx, nil, func(tx *sqlx.Tx) error {
+		if !db.rowSecurity.Load() {
+			if err := SetRowSecurityContext(ctx, tx); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// applyRowSecurity 启用行级安全时为新事务写入会话变量
+func (db *DB) applyRowSecurity(ctx context.Context, tx *sqlx.Tx) error {
+	if !db.rowSecurity.Load() {
+		return nil
+	}
+	if err := SetRowSecurityContext(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// session 执行单条语句的连接：启用行级安全时取出一个独占连接并以会话级写入 ctx 对应的会话变量，
+// 语句结束后调用 release 放回连接池；未启用时直接使用连接池
+func (db *DB) session(ctx context.Context) (sessionConn, func(), error) {
+	if !db.rowSecurity.Load() {
+		return db.DB, func() {}, nil
+	}
+	conn, err := db.DB.Connx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.ExecContext(ctx, setRowSecurityQuery, rowSecurityArgs(ctx, false)...); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to set row security context: %w", err)
+	}
+	return conn, func() { conn.Close() }, nil
+}
+
+// releaseAfterRows 结果集在返回后读取，连接的 Close 会等到结果集关闭后才放回连接池，因此在后台调用
+func releaseAfterRows(release func()) {
+	go release()
+}
+
+// sessionConn *sqlx.DB 与 *sqlx.Conn 共有的执行方法
+type sessionConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// resetSession 连接放回连接池后再次取出前清空会话变量，以会话级写入的租户与用户不会带到下一个请求，
+// 绕过 DB 方法直接使用连接的语句被策略拒绝
+func (db *DB) resetSession(ctx context.Context, conn *pgx.Conn) error {
+	if !db.rowSecurity.Load() {
+		return nil
+	}
+	_, err := conn.Exec(ctx, resetRowSecurityQuery)
+	return err
+}
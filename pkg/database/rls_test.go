@@ -0,0 +1,130 @@
+package database_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRowSecurity(t *testing.T) {
+	migration, err := database.GenerateRowSecurity([]database.RowSecurityPolicy{
+		{Table: "coupons", TenantColumn: "tenant_id"},
+		{Table: "user_coupons", TenantColumn: "tenant_id", OwnerColumn: "user_id"},
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, migration.Up, `ALTER TABLE "coupons" ENABLE ROW LEVEL SECURITY;`)
+	assert.Contains(t, migration.Up, `ALTER TABLE "user_coupons" FORCE ROW LEVEL SECURITY;`)
+	assert.Contains(t, migration.Up, `CREATE POLICY "coupons_row_security" ON "coupons"`)
+	assert.Contains(t, migration.Up, `"tenant_id" = NULLIF(current_setting('app.tenant_id', true), '')`, "empty settings deny every row")
+	assert.Contains(t, migration.Up, `"user_id"::text = NULLIF(current_setting('app.user_id', true), '')`)
+	assert.Contains(t, migration.Up, `USING (current_setting('app.bypass', true) = 'on' OR (`)
+	assert.Contains(t, migration.Up, `current_setting('app.all_owners', true) = 'on' OR`)
+	assert.NotContains(t, migration.Up, "COALESCE", "empty settings no longer lift the policy")
+	assert.Contains(t, migration.Up, "WITH CHECK")
+	assert.NotContains(t, migration.Up[strings.Index(migration.Up, "-- coupons"):strings.Index(migration.Up, "-- user_coupons")], "app.user_id", "tenant-only tables are not owner scoped")
+
+	assert.Contains(t, migration.Down, `DROP POLICY IF EXISTS "user_coupons_row_security" ON "user_coupons";`)
+	assert.Contains(t, migration.Down, `ALTER TABLE "coupons" DISABLE ROW LEVEL SECURITY;`)
+}
+
+func TestGenerateRowSecurity_RejectsEmptyPolicies(t *testing.T) {
+	_, err := database.GenerateRowSecurity(nil)
+	assert.Error(t, err)
+
+	_, err = database.GenerateRowSecurity([]database.RowSecurityPolicy{{Table: "coupons"}})
+	assert.Error(t, err)
+}
+
+func TestBeginTx_SetsRowSecurityContext(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	db.EnableRowSecurity()
+	ctx := ctxutil.WithUserID(ctxutil.WithTenantID(context.Background(), "acme"), "42")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").
+		WithArgs(database.TenantSetting, "acme", database.UserSetting, "42", database.AllOwnersSetting, "", database.BypassSetting, "", true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+}
+
+// TestBeginTx_SystemCallsBypass 不来自请求的上下文显式写入 app.bypass，来自请求但没有租户的上下文不放行
+func TestBeginTx_SystemCallsBypass(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	db.EnableRowSecurity()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").
+		WithArgs(database.TenantSetting, "", database.UserSetting, "", database.AllOwnersSetting, "", database.BypassSetting, "on", true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").
+		WithArgs(database.TenantSetting, "", database.UserSetting, "", database.AllOwnersSetting, "", database.BypassSetting, "", true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+	tx, err = db.BeginTx(ctxutil.WithRequestID(context.Background(), "req-1"), nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+}
+
+// TestStatements_SetRowSecurityOutsideTransactions 不在事务中的语句先在同一连接上以会话级写入会话变量，
+// 管理员不做所有者限定
+func TestStatements_SetRowSecurityOutsideTransactions(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	db.EnableRowSecurity()
+	ctx := ctxutil.WithAllOwners(ctxutil.WithTenantID(context.Background(), "acme"))
+	settings := []driver.Value{database.TenantSetting, "acme", database.UserSetting, "", database.AllOwnersSetting, "on", database.BypassSetting, "", false}
+
+	mock.ExpectExec("set_config").WithArgs(settings...).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id FROM user_coupons").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	var ids []int
+	require.NoError(t, db.SelectContext(ctx, &ids, "SELECT id FROM user_coupons"))
+	assert.Equal(t, []int{1, 2}, ids)
+
+	mock.ExpectExec("set_config").WithArgs(settings...).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE coupons").WillReturnResult(sqlmock.NewResult(0, 1))
+	_, err := db.ExecContext(ctx, "UPDATE coupons SET stock = stock - 1 WHERE id = $1", 1)
+	require.NoError(t, err)
+
+	mock.ExpectExec("set_config").WithArgs(settings...).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT name FROM coupons").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("c1"))
+	var name string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT name FROM coupons WHERE id = $1", 1).Scan(&name))
+	assert.Equal(t, "c1", name)
+}
+
+func TestRunScoped_SetsContextWithoutEnableRowSecurity(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	ctx := ctxutil.WithTenantID(context.Background(), "acme")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").
+		WithArgs(database.TenantSetting, "acme", database.UserSetting, "", database.AllOwnersSetting, "", database.BypassSetting, "", true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user_coupons").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := db.RunScoped(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE user_coupons SET status = 'used' WHERE id = $1", 1)
+		return err
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// Router 按读写与分片键选择连接：写入走主库，读取轮询只读副本，分片表按分片键选择分片。
+// 没有配置副本或分片时都使用主库，调用方不需要区分部署形态
+type Router struct {
+	primary  *DB
+	replicas []*DB
+	shards   []*DB
+	next     atomic.Uint64
+
+	// 复制延迟：lags 与 replicas 一一对应，由 SampleLag 更新；maxStaleness 为默认可容忍的延迟，0 表示不限制
+	lags             []atomic.Pointer[replicaLag]
+	maxStaleness     time.Duration
+	clock            clock.Clock
+	metricsCollector *metrics.MetricsCollector
+}
+
+// replicaLag 一次复制延迟采样
+type replicaLag struct {
+	lag time.Duration
+	at  time.Time
+}
+
+// NewRouter 创建路由，replicas 与 shards 可以为空
+func NewRouter(primary *DB, replicas, shards []*DB) *Router {
+	return &Router{
+		primary:          primary,
+		replicas:         replicas,
+		shards:           shards,
+		lags:             make([]atomic.Pointer[replicaLag], len(replicas)),
+		clock:            clock.Real,
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// InitRouter 按配置连接只读副本与分片（database.replica_dsns、database.shard_dsns）。
+// 配置了 database.replica_max_lag_seconds 时调用方需运行 MonitorLag，否则有延迟限制的读取都走主库
+func InitRouter(primary *DB) *Router {
+	cfg := config.GlobalConfig.Database
+	connect := func(kind string, dsns []string) []*DB {
+		dbs := make([]*DB, 0, len(dsns))
+		for i, dsn := range dsns {
+			// 副本与分片沿用主库的连接标签，连接串中已指定 application_name 时保留
+			dsn = withApplicationName(dsn, primary.applicationName)
+			db := &DB{dsn: dsn, applicationName: primary.applicationName}
+			conn, err := db.open(context.Background(), dsn)
+			if err != nil {
+				log.Fatalf("Failed to connect to %s %d: %v", kind, i, err)
+			}
+			configureConnectionPool(conn.DB)
+			db.DB = conn
+			db.SetQueryTimeouts(primary.queryTimeouts)
+			// 副本与分片同样按请求写入行级安全的会话变量
+			if primary.rowSecurity.Load() {
+				db.EnableRowSecurity()
+			}
+			dbs = append(dbs, db)
+		}
+		return dbs
+	}
+
+	router := NewRouter(primary, connect("replica", cfg.ReplicaDSNs), connect("shard", cfg.ShardDSNs))
+	router.SetMaxStaleness(time.Duration(cfg.ReplicaMaxLagSeconds) * time.Second)
+	if len(router.replicas) > 0 || len(router.shards) > 0 {
+		log.Printf("Database router configured with %d replicas and %d shards", len(router.replicas), len(router.shards))
+	}
+	return router
+}
+
+type primaryCtxKey struct{}
+
+// WithPrimary 返回读取也走主库的上下文，用于刚写入后立即读取、不能容忍复制延迟的场景
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+type maxStalenessCtxKey struct{}
+
+// WithMaxStaleness 返回限制复制延迟的上下文：只读取延迟不超过 d 的副本，都超过时读主库。
+// 覆盖路由默认的 database.replica_max_lag_seconds；d 为 0 时读主库，小于 0 表示不限制、可以读任意副本
+func WithMaxStaleness(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, maxStalenessCtxKey{}, d)
+}
+
+// SetMaxStaleness 设置默认可容忍的复制延迟，0 表示不限制
+func (r *Router) SetMaxStaleness(d time.Duration) {
+	r.maxStaleness = d
+}
+
+// SetClock 替换时钟，仅用于测试
+func (r *Router) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// Primary 主库
+func (r *Router) Primary() *DB {
+	return r.primary
+}
+
+// Reader 读取使用的连接：轮询只读副本，没有副本或 ctx 由 WithPrimary 标记时使用主库。
+// 有延迟限制（WithMaxStaleness 或默认值）时跳过延迟超限的副本，延迟按最近一次采样加上采样后经过的时间估算，
+// 尚未采样或采样失败的副本视为超限，因此需要运行 MonitorLag
+func (r *Router) Reader(ctx context.Context) *DB {
+	if len(r.replicas) == 0 {
+		return r.primary
+	}
+	if forced, _ := ctx.Value(primaryCtxKey{}).(bool); forced {
+		return r.primary
+	}
+
+	limit := r.maxStaleness
+	if d, ok := ctx.Value(maxStalenessCtxKey{}).(time.Duration); ok {
+		limit = d
+		if limit == 0 {
+			return r.primary
+		}
+	}
+	n := r.next.Add(1)
+	if limit <= 0 {
+		return r.replicas[n%uint64(len(r.replicas))]
+	}
+
+	now := r.clock.Now()
+	for i := uint64(0); i < uint64(len(r.replicas)); i++ {
+		idx := (n + i) % uint64(len(r.replicas))
+		if lag, ok := r.staleness(int(idx), now); ok && lag <= limit {
+			return r.replicas[idx]
+		}
+	}
+	return r.primary
+}
+
+// staleness 副本当前的估算延迟，未采样或采样失败时 ok 为 false
+func (r *Router) staleness(idx int, now time.Time) (time.Duration, bool) {
+	sample := r.lags[idx].Load()
+	if sample == nil {
+		return 0, false
+	}
+	return sample.lag + now.Sub(sample.at), true
+}
+
+// ReplicaLags 各副本最近一次采样的复制延迟，未采样或采样失败时为 -1
+func (r *Router) ReplicaLags() []time.Duration {
+	lags := make([]time.Duration, len(r.replicas))
+	for i := range r.lags {
+		lags[i] = -1
+		if sample := r.lags[i].Load(); sample != nil {
+			lags[i] = sample.lag
+		}
+	}
+	return lags
+}
+
+// MonitorLag 按间隔采样副本的复制延迟，直到 ctx 结束；启动时立即采样一次
+func (r *Router) MonitorLag(ctx context.Context, interval time.Duration) {
+	if len(r.replicas) == 0 {
+		return
+	}
+	if err := r.SampleLag(ctx); err != nil {
+		log.Printf("Failed to sample replica lag: %v", err)
+	}
+
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := r.SampleLag(ctx); err != nil {
+				log.Printf("Failed to sample replica lag: %v", err)
+			}
+		}
+	}
+}
+
+// SampleLag 采样每个副本的复制延迟。已回放完收到的 WAL 时延迟为 0，
+// 否则为最后回放事务的提交时间到现在的间隔；采样失败的副本在下次成功前不再承接有延迟限制的读取
+func (r *Router) SampleLag(ctx context.Context) error {
+	query := `
+		SELECT CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END::float8`
+	var firstErr error
+	for i, replica := range r.replicas {
+		var seconds float64
+		if err := replica.GetContext(ctx, &seconds, query); err != nil {
+			r.lags[i].Store(nil)
+			r.metricsCollector.UpdateDBReplicaLag(strconv.Itoa(i), 0, false)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to sample lag of replica %d: %w", i, err)
+			}
+			continue
+		}
+		lag := time.Duration(seconds * float64(time.Second))
+		r.lags[i].Store(&replicaLag{lag: lag, at: r.clock.Now()})
+		r.metricsCollector.UpdateDBReplicaLag(strconv.Itoa(i), lag, true)
+	}
+	return firstErr
+}
+
+// Shard 分片键所在的分片（FNV-1a 取模），没有分片时使用主库。分片数变化时需要迁移数据
+func (r *Router) Shard(key string) *DB {
+	if len(r.shards) == 0 {
+		return r.primary
+	}
+	return r.shards[ShardIndex(key, len(r.shards))]
+}
+
+// ShardCount 分片数，没有分片时为 0
+func (r *Router) ShardCount() int {
+	return len(r.shards)
+}
+
+// ShardIndex 分片键对应的分片序号
+func ShardIndex(key string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Close 关闭副本与分片的连接，主库由调用方关闭
+func (r *Router) Close() error {
+	var firstErr error
+	for _, db := range append(append([]*DB{}, r.replicas...), r.shards...) {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to close database: %w", err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,98 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_ReadsFromReplicasWritesToPrimary(t *testing.T) {
+	primary, _ := fakes.NewDB(t)
+	replica1, _ := fakes.NewDB(t)
+	replica2, _ := fakes.NewDB(t)
+	router := database.NewRouter(primary, []*database.DB{replica1, replica2}, nil)
+	ctx := context.Background()
+
+	seen := map[*database.DB]int{}
+	for i := 0; i < 4; i++ {
+		seen[router.Reader(ctx)]++
+	}
+	assert.Equal(t, map[*database.DB]int{replica1: 2, replica2: 2}, seen)
+	assert.Same(t, primary, router.Reader(database.WithPrimary(ctx)))
+	assert.Same(t, primary, router.Primary())
+
+	// 没有副本与分片时都使用主库
+	single := database.NewRouter(primary, nil, nil)
+	assert.Same(t, primary, single.Reader(ctx))
+	assert.Same(t, primary, single.Shard("acme"))
+}
+
+func TestRouter_ShardIsStable(t *testing.T) {
+	primary, _ := fakes.NewDB(t)
+	shard0, _ := fakes.NewDB(t)
+	shard1, _ := fakes.NewDB(t)
+	router := database.NewRouter(primary, nil, []*database.DB{shard0, shard1})
+
+	used := map[*database.DB]bool{}
+	for _, key := range []string{"acme", "globex", "initech", "umbrella", "hooli"} {
+		db := router.Shard(key)
+		assert.Same(t, db, router.Shard(key))
+		assert.NotSame(t, primary, db)
+		used[db] = true
+	}
+	assert.Len(t, used, 2, "keys spread across shards")
+}
+
+// TestRouter_MaxStaleness 有延迟限制时只读延迟足够小的副本，都超限或未采样时读主库，单次调用可覆盖默认值
+func TestRouter_MaxStaleness(t *testing.T) {
+	primary, _ := fakes.NewDB(t)
+	fresh, freshMock := fakes.NewDB(t)
+	lagging, laggingMock := fakes.NewDB(t)
+	router := database.NewRouter(primary, []*database.DB{fresh, lagging}, nil)
+	clock := fakes.NewClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	router.SetClock(clock)
+	router.SetMaxStaleness(5 * time.Second)
+	ctx := context.Background()
+
+	// 尚未采样时无法判断延迟
+	assert.Same(t, primary, router.Reader(ctx))
+	assert.NotSame(t, primary, router.Reader(database.WithMaxStaleness(ctx, -1)))
+
+	freshMock.ExpectQuery("pg_last_xact_replay_timestamp").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.5))
+	laggingMock.ExpectQuery("pg_last_xact_replay_timestamp").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(30.0))
+	require.NoError(t, router.SampleLag(ctx))
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 30 * time.Second}, router.ReplicaLags())
+
+	for i := 0; i < 4; i++ {
+		assert.Same(t, fresh, router.Reader(ctx))
+	}
+	assert.Same(t, primary, router.Reader(database.WithMaxStaleness(ctx, 0)))
+	assert.Same(t, primary, router.Reader(database.WithPrimary(database.WithMaxStaleness(ctx, time.Minute))))
+
+	seen := map[*database.DB]int{}
+	for i := 0; i < 4; i++ {
+		seen[router.Reader(database.WithMaxStaleness(ctx, time.Minute))]++
+	}
+	assert.Equal(t, map[*database.DB]int{fresh: 2, lagging: 2}, seen)
+
+	// 采样后经过的时间计入延迟
+	clock.Advance(10 * time.Second)
+	assert.Same(t, primary, router.Reader(ctx))
+
+	// 采样失败的副本不再承接有延迟限制的读取
+	freshMock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnError(errors.New("connection refused"))
+	laggingMock.ExpectQuery("pg_last_xact_replay_timestamp").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.0))
+	assert.Error(t, router.SampleLag(ctx))
+	assert.Equal(t, []time.Duration{-1, 0}, router.ReplicaLags())
+	assert.Same(t, lagging, router.Reader(ctx))
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SoftDeleteColumn 软删除表的删除时间列，为 NULL 表示未删除
+const SoftDeleteColumn = "deleted_at"
+
+// SQLStateUniqueViolation 唯一约束冲突
+const SQLStateUniqueViolation = "23505"
+
+// Scope 可复用的查询条件，与 TenantScope 相同：追加条件与参数，条件中的占位符序号接在 args 之后
+type Scope func(conditions []string, args []interface{}) ([]string, []interface{})
+
+// Where 依次应用 scopes，返回以 AND 连接的条件（没有条件时为 TRUE）与参数，用于拼接在 WHERE 之后：
+//
+//	where, args := database.Where(database.Cond("id = $%d", id), database.ActiveOnly(""), database.InTenant(ctx, ""))
+//	query := `SELECT ... FROM coupons WHERE ` + where
+func Where(scopes ...Scope) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, scope := range scopes {
+		conditions, args = scope(conditions, args)
+	}
+	if len(conditions) == 0 {
+		return "TRUE", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Cond 任意条件，format 中每个 $%d 依次对应 values 中的一个参数
+//
+//	database.Cond("end_time > $%d", since)
+//	database.Cond("stock > 0")
+func Cond(format string, values ...interface{}) Scope {
+	return func(conditions []string, args []interface{}) ([]string, []interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		if len(placeholders) == 0 {
+			return append(conditions, format), args
+		}
+		return append(conditions, fmt.Sprintf(format, placeholders...)), args
+	}
+}
+
+// ActiveOnly 只查询未软删除的行
+func ActiveOnly(alias string) Scope {
+	return func(conditions []string, args []interface{}) ([]string, []interface{}) {
+		return append(conditions, qualify(alias, SoftDeleteColumn)+" IS NULL"), args
+	}
+}
+
+// InTenant 限定为 ctx 中的租户，没有租户时不限定，同 TenantScope
+func InTenant(ctx context.Context, alias string) Scope {
+	return func(conditions []string, args []interface{}) ([]string, []interface{}) {
+		return TenantScope(ctx, alias, conditions, args)
+	}
+}
+
+// TimeRange 限定 column 位于 [from, to)，零值的一端不限定
+func TimeRange(column string, from, to time.Time) Scope {
+	return func(conditions []string, args []interface{}) ([]string, []interface{}) {
+		if !from.IsZero() {
+			args = append(args, from)
+			conditions = append(conditions, fmt.Sprintf("%s >= $%d", column, len(args)))
+		}
+		if !to.IsZero() {
+			args = append(args, to)
+			conditions = append(conditions, fmt.Sprintf("%s < $%d", column, len(args)))
+		}
+		return conditions, args
+	}
+}
+
+// IsUniqueViolation 是否为唯一约束冲突；constraint 非空时还要求冲突的是该约束或唯一索引。
+// 软删除表的唯一性由 WHERE deleted_at IS NULL 的部分唯一索引保证，已删除的行不会与新行冲突
+func IsUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == SQLStateUniqueViolation && (constraint == "" || pgErr.ConstraintName == constraint)
+	}
+	return constraint == "" && SQLState(err) == SQLStateUniqueViolation
+}
+
+// qualify 为列名加上表别名
+func qualify(alias, column string) string {
+	if alias == "" {
+		return column
+	}
+	return alias + "." + column
+}
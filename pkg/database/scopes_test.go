@@ -0,0 +1,42 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhere_ComposesScopes(t *testing.T) {
+	ctx := ctxutil.WithTenantID(context.Background(), "acme")
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where, args := database.Where(
+		database.Cond("c.coupon_id = $%d", "c1"),
+		database.Cond("c.stock > 0"),
+		database.ActiveOnly("c"),
+		database.TimeRange("c.created_at", from, time.Time{}),
+		database.InTenant(ctx, "c"),
+	)
+	assert.Equal(t, "c.coupon_id = $1 AND c.stock > 0 AND c.deleted_at IS NULL AND c.created_at >= $2 AND c.tenant_id = $3", where)
+	assert.Equal(t, []interface{}{"c1", from, "acme"}, args)
+
+	// 没有租户、没有条件
+	where, args = database.Where(database.InTenant(context.Background(), ""))
+	assert.Equal(t, "TRUE", where)
+	assert.Empty(t, args)
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	err := fmt.Errorf("create topic: %w", &pgconn.PgError{Code: database.SQLStateUniqueViolation, ConstraintName: "uniq_topics_name"})
+	assert.True(t, database.IsUniqueViolation(err, ""))
+	assert.True(t, database.IsUniqueViolation(err, "uniq_topics_name"))
+	assert.False(t, database.IsUniqueViolation(err, "uniq_users_tenant_id_mobile"))
+	assert.False(t, database.IsUniqueViolation(errors.New("connection refused"), ""))
+}
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NodeRole 节点在路由中的角色
+type NodeRole string
+
+const (
+	NodeRolePrimary NodeRole = "primary"
+	NodeRoleReplica NodeRole = "replica"
+	NodeRoleShard   NodeRole = "shard"
+)
+
+// NodeHealth 单个节点的健康状态，连接数来自服务端的 pg_stat_activity，包含所有客户端
+type NodeHealth struct {
+	Role           NodeRole      `json:"role"`
+	Index          int           `json:"index"` // 副本或分片序号，主库为 0
+	Healthy        bool          `json:"healthy"`
+	Error          string        `json:"error,omitempty"`
+	Latency        time.Duration `json:"latency"`
+	Connections    int           `json:"connections"`
+	MaxConnections int           `json:"max_connections"`
+	SizeBytes      int64         `json:"size_bytes"`
+	InRecovery     bool          `json:"in_recovery"`
+	// ReplicationLag 副本最后回放的事务距今的时间，主库为 0；主库长时间没有写入时该值也会增大
+	ReplicationLag time.Duration `json:"replication_lag"`
+}
+
+// nodeHealthQuery 一次查询取回连接数、库大小与复制状态
+const nodeHealthQuery = `
+	SELECT
+		(SELECT count(*) FROM pg_stat_activity WHERE datname = current_database()),
+		current_setting('max_connections')::int,
+		pg_database_size(current_database()),
+		pg_is_in_recovery(),
+		COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)::float8`
+
+// Health 依次检查主库、副本与分片，单个节点失败只记录在其结果中
+func (r *Router) Health(ctx context.Context) []NodeHealth {
+	results := []NodeHealth{checkNode(ctx, r.primary, NodeRolePrimary, 0)}
+	for i, db := range r.replicas {
+		results = append(results, checkNode(ctx, db, NodeRoleReplica, i))
+	}
+	for i, db := range r.shards {
+		results = append(results, checkNode(ctx, db, NodeRoleShard, i))
+	}
+	return results
+}
+
+// checkNode 检查单个节点
+func checkNode(ctx context.Context, db *DB, role NodeRole, index int) NodeHealth {
+	health := NodeHealth{Role: role, Index: index}
+	start := time.Now()
+	var lagSeconds float64
+	err := db.QueryRowContext(ctx, nodeHealthQuery).Scan(
+		&health.Connections, &health.MaxConnections, &health.SizeBytes, &health.InRecovery, &lagSeconds)
+	health.Latency = time.Since(start)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Healthy = true
+	if health.InRecovery {
+		health.ReplicationLag = time.Duration(lagSeconds * float64(time.Second))
+	}
+	return health
+}
+
+// ShardRebalanceConfig 分片数据重新分布配置
+type ShardRebalanceConfig struct {
+	Table     string `json:"table"`
+	KeyColumn string `json:"key_column"` // 分片键列，按其文本值计算所属分片，与 Router.Shard 一致
+	IDColumn  string `json:"id_column"`  // 唯一且有索引的列，按其分批扫描与迁移，默认 id
+	BatchSize int    `json:"batch_size"`
+	DryRun    bool   `json:"dry_run"` // 只统计不在所属分片的行，不迁移
+}
+
+// DefaultShardRebalanceConfig 默认重新分布配置：按 id 每批 500 行
+func DefaultShardRebalanceConfig() *ShardRebalanceConfig {
+	return &ShardRebalanceConfig{
+		IDColumn:  "id",
+		BatchSize: 500,
+	}
+}
+
+// ShardRebalanceStats 单个分片的扫描与迁出统计
+type ShardRebalanceStats struct {
+	Shard     int   `json:"shard"`
+	Scanned   int64 `json:"scanned"`
+	Misplaced int64 `json:"misplaced"` // 不属于该分片的行
+	MovedOut  int64 `json:"moved_out"`
+	Conflicts int64 `json:"conflicts"` // 目标分片已有同一主键但内容不同的行，保留在源分片
+}
+
+// ShardRebalanceResult 重新分布结果
+type ShardRebalanceResult struct {
+	Table  string                `json:"table"`
+	DryRun bool                  `json:"dry_run"`
+	Shards []ShardRebalanceStats `json:"shards"`
+}
+
+// Rebalance 将表中不在所属分片的行迁到所属分片，用于增减分片之后。
+// 每批先在目标分片插入（主键冲突时跳过）再从源分片删除，中断后重新执行即可继续；
+// 迁移期间应用应已按新的分片数写入，否则源分片上新写入的行会在下次执行时才被迁走
+func (r *Router) Rebalance(ctx context.Context, config *ShardRebalanceConfig) (*ShardRebalanceResult, error) {
+	if config == nil || config.Table == "" || config.KeyColumn == "" {
+		return nil, fmt.Errorf("rebalance table and key column are required")
+	}
+	if len(r.shards) == 0 {
+		return nil, fmt.Errorf("no shards configured")
+	}
+	defaults := DefaultShardRebalanceConfig()
+	if config.IDColumn == "" {
+		config.IDColumn = defaults.IDColumn
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+
+	result := &ShardRebalanceResult{Table: config.Table, DryRun: config.DryRun}
+	for i := range r.shards {
+		stats, err := r.rebalanceShard(ctx, config, i)
+		result.Shards = append(result.Shards, stats)
+		if err != nil {
+			return result, fmt.Errorf("failed to rebalance shard %d: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// rebalanceShard 按 IDColumn 顺序扫描一个分片并迁出不属于它的行
+func (r *Router) rebalanceShard(ctx context.Context, config *ShardRebalanceConfig, index int) (ShardRebalanceStats, error) {
+	stats := ShardRebalanceStats{Shard: index}
+	source := r.shards[index]
+	table, idColumn := quoteIdentifier(config.Table), quoteIdentifier(config.IDColumn)
+	first := fmt.Sprintf("SELECT %s, %s::text FROM %s ORDER BY %s LIMIT $1",
+		idColumn, quoteIdentifier(config.KeyColumn), table, idColumn)
+	next := fmt.Sprintf("SELECT %s, %s::text FROM %s WHERE %s > $1 ORDER BY %s LIMIT $2",
+		idColumn, quoteIdentifier(config.KeyColumn), table, idColumn, idColumn)
+
+	var lastID interface{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		var rows *sqlx.Rows
+		var err error
+		if lastID == nil {
+			rows, err = source.QueryContext(ctx, first, config.BatchSize)
+		} else {
+			rows, err = source.QueryContext(ctx, next, lastID, config.BatchSize)
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to scan %s: %w", config.Table, err)
+		}
+
+		scanned := 0
+		misplaced := make(map[int][]interface{})
+		for rows.Next() {
+			var id interface{}
+			var key *string
+			if err := rows.Scan(&id, &key); err != nil {
+				rows.Close()
+				return stats, fmt.Errorf("failed to scan %s: %w", config.Table, err)
+			}
+			scanned++
+			lastID = id
+			// 分片键为空的行无法判断所属分片，保持原位
+			if key == nil {
+				continue
+			}
+			if target := ShardIndex(*key, len(r.shards)); target != index {
+				misplaced[target] = append(misplaced[target], id)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return stats, fmt.Errorf("failed to scan %s: %w", config.Table, err)
+		}
+
+		stats.Scanned += int64(scanned)
+		targets := make([]int, 0, len(misplaced))
+		batchMisplaced := 0
+		for target, ids := range misplaced {
+			batchMisplaced += len(ids)
+			targets = append(targets, target)
+		}
+		stats.Misplaced += int64(batchMisplaced)
+		if !config.DryRun && batchMisplaced > 0 {
+			sort.Ints(targets)
+			log.Printf("Rebalance moving %d rows of %s out of shard %d", batchMisplaced, config.Table, index)
+			for _, target := range targets {
+				moved, conflicts, err := moveRows(ctx, source, r.shards[target], config, misplaced[target])
+				stats.MovedOut += moved
+				stats.Conflicts += conflicts
+				if err != nil {
+					return stats, fmt.Errorf("failed to move rows to shard %d: %w", target, err)
+				}
+			}
+		}
+
+		if scanned < config.BatchSize {
+			return stats, nil
+		}
+	}
+}
+
+// moveRows 读取源分片上的整行写入目标分片，再从源分片删除。目标分片已有同一主键的行时，
+// 与源行完全相同（上次中断前已写入）才删除源行，不同的行计为冲突并保留，需要人工处理
+func moveRows(ctx context.Context, source, target *DB, config *ShardRebalanceConfig, ids []interface{}) (moved, conflicts int64, err error) {
+	records, err := selectRows(ctx, source, config, ids)
+	if err != nil || len(records) == 0 {
+		return 0, 0, err
+	}
+
+	columns := make([]string, 0, len(records[0]))
+	for column := range records[0] {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	values := make([]interface{}, 0, len(records)*len(columns))
+	for _, record := range records {
+		for _, column := range columns {
+			values = append(values, record[column])
+		}
+	}
+	insertSQL := buildInsertSQL(config.Table, columns, len(records)) +
+		" ON CONFLICT DO NOTHING RETURNING " + quoteIdentifier(config.IDColumn) + "::text"
+	var inserted []string
+	if err := target.SelectContext(ctx, &inserted, insertSQL, values...); err != nil {
+		return 0, 0, fmt.Errorf("failed to insert rows: %w", err)
+	}
+
+	deletable := make([]interface{}, 0, len(records))
+	isInserted := make(map[string]bool, len(inserted))
+	for _, id := range inserted {
+		isInserted[id] = true
+	}
+	var existing []interface{}
+	for _, record := range records {
+		id := record[config.IDColumn]
+		if isInserted[fmt.Sprint(id)] {
+			deletable = append(deletable, id)
+		} else {
+			existing = append(existing, id)
+		}
+	}
+	if len(existing) > 0 {
+		current, err := selectRows(ctx, target, config, existing)
+		if err != nil {
+			return 0, 0, err
+		}
+		byID := make(map[string]map[string]interface{}, len(current))
+		for _, record := range current {
+			byID[fmt.Sprint(record[config.IDColumn])] = record
+		}
+		for _, record := range records {
+			id := record[config.IDColumn]
+			if current, ok := byID[fmt.Sprint(id)]; ok && !isInserted[fmt.Sprint(id)] {
+				if reflect.DeepEqual(current, record) {
+					deletable = append(deletable, id)
+				} else {
+					conflicts++
+				}
+			}
+		}
+		if conflicts > 0 {
+			log.Printf("Rebalance skipped %d rows of %s that differ from existing rows on the target shard", conflicts, config.Table)
+		}
+	}
+	if len(deletable) == 0 {
+		return 0, conflicts, nil
+	}
+
+	deleteSQL, args, err := sqlx.In(fmt.Sprintf("DELETE FROM %s WHERE %s IN (?)",
+		quoteIdentifier(config.Table), quoteIdentifier(config.IDColumn)), deletable)
+	if err != nil {
+		return 0, conflicts, err
+	}
+	result, err := source.ExecContext(ctx, source.Rebind(deleteSQL), args...)
+	if err != nil {
+		return 0, conflicts, fmt.Errorf("failed to delete moved rows: %w", err)
+	}
+	moved, _ = result.RowsAffected()
+	return moved, conflicts, nil
+}
+
+// selectRows 按主键读取整行
+func selectRows(ctx context.Context, db *DB, config *ShardRebalanceConfig, ids []interface{}) ([]map[string]interface{}, error) {
+	query, args, err := sqlx.In(fmt.Sprintf("SELECT * FROM %s WHERE %s IN (?)",
+		quoteIdentifier(config.Table), quoteIdentifier(config.IDColumn)), ids)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+	defer rows.Close()
+
+	var records []map[string]interface{}
+	for rows.Next() {
+		record := make(map[string]interface{})
+		if err := rows.MapScan(record); err != nil {
+			return nil, fmt.Errorf("failed to read rows: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,122 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keysForShard 返回 n 个属于指定分片的分片键
+func keysForShard(t *testing.T, shard, shards, n int) []string {
+	t.Helper()
+	var keys []string
+	for i := 0; len(keys) < n && i < 1000; i++ {
+		key := "tenant-" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+		if database.ShardIndex(key, shards) == shard {
+			keys = append(keys, key)
+		}
+	}
+	require.Len(t, keys, n)
+	return keys
+}
+
+func TestRouter_RebalanceMovesMisplacedRows(t *testing.T) {
+	primary, _ := fakes.NewDB(t)
+	shard0, mock0 := fakes.NewDB(t)
+	shard1, mock1 := fakes.NewDB(t)
+	router := database.NewRouter(primary, nil, []*database.DB{shard0, shard1})
+	ctx := context.Background()
+	stay := keysForShard(t, 0, 2, 1)[0]
+	move := keysForShard(t, 1, 2, 2)
+
+	// 分片 0：id 2 属于分片 1 并成功写入；id 3 上次中断前已写入分片 1，内容相同，同样从分片 0 删除
+	mock0.ExpectQuery(regexp.QuoteMeta(`SELECT "id", "tenant_id"::text FROM "orders" ORDER BY "id" LIMIT $1`)).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).
+			AddRow(int64(1), stay).AddRow(int64(2), move[0]).AddRow(int64(3), move[1]))
+	mock0.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE "id" IN ($1, $2)`)).
+		WithArgs(int64(2), int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "amount"}).
+			AddRow(int64(2), move[0], int64(100)).AddRow(int64(3), move[1], int64(300)))
+	mock1.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders" ("amount", "id", "tenant_id") VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT DO NOTHING RETURNING "id"::text`)).
+		WithArgs(int64(100), int64(2), move[0], int64(300), int64(3), move[1]).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2"))
+	mock1.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE "id" IN ($1)`)).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "amount"}).AddRow(int64(3), move[1], int64(300)))
+	mock0.ExpectExec(regexp.QuoteMeta(`DELETE FROM "orders" WHERE "id" IN ($1, $2)`)).
+		WithArgs(int64(2), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// 分片 1：所有行都在所属分片
+	mock1.ExpectQuery(regexp.QuoteMeta(`SELECT "id", "tenant_id"::text FROM "orders" ORDER BY "id" LIMIT $1`)).
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(int64(2), move[0]))
+
+	result, err := router.Rebalance(ctx, &database.ShardRebalanceConfig{Table: "orders", KeyColumn: "tenant_id", BatchSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []database.ShardRebalanceStats{
+		{Shard: 0, Scanned: 3, Misplaced: 2, MovedOut: 2},
+		{Shard: 1, Scanned: 1},
+	}, result.Shards)
+}
+
+func TestRouter_RebalanceKeepsConflictingRows(t *testing.T) {
+	primary, _ := fakes.NewDB(t)
+	shard0, mock0 := fakes.NewDB(t)
+	shard1, mock1 := fakes.NewDB(t)
+	router := database.NewRouter(primary, nil, []*database.DB{shard0, shard1})
+	move := keysForShard(t, 1, 2, 1)[0]
+
+	// 两个分片各自生成的 id 相同但内容不同，源行保留
+	mock0.ExpectQuery(`SELECT "id", "tenant_id"::text FROM "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(int64(7), move))
+	mock0.ExpectQuery(`SELECT \* FROM "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(int64(7), move))
+	mock1.ExpectQuery(`INSERT INTO "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock1.ExpectQuery(`SELECT \* FROM "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}).AddRow(int64(7), "other-tenant"))
+	mock1.ExpectQuery(`SELECT "id", "tenant_id"::text FROM "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id"}))
+
+	result, err := router.Rebalance(context.Background(), &database.ShardRebalanceConfig{Table: "orders", KeyColumn: "tenant_id"})
+	require.NoError(t, err)
+	assert.Equal(t, database.ShardRebalanceStats{Shard: 0, Scanned: 1, Misplaced: 1, Conflicts: 1}, result.Shards[0])
+}
+
+func TestRouter_Health(t *testing.T) {
+	primary, mockPrimary := fakes.NewDB(t)
+	replica, mockReplica := fakes.NewDB(t)
+	router := database.NewRouter(primary, []*database.DB{replica}, nil)
+	columns := []string{"connections", "max_connections", "size", "in_recovery", "lag"}
+
+	mockPrimary.ExpectQuery(`SELECT`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(12, 100, int64(1<<20), false, 0.0))
+	mockReplica.ExpectQuery(`SELECT`).WillReturnError(errors.New("connection refused"))
+
+	nodes := router.Health(context.Background())
+	require.Len(t, nodes, 2)
+	assert.True(t, nodes[0].Healthy)
+	assert.Equal(t, database.NodeRolePrimary, nodes[0].Role)
+	assert.Equal(t, 12, nodes[0].Connections)
+	assert.Equal(t, int64(1<<20), nodes[0].SizeBytes)
+	assert.False(t, nodes[1].Healthy)
+	assert.Equal(t, database.NodeRoleReplica, nodes[1].Role)
+	assert.Equal(t, "connection refused", nodes[1].Error)
+}
+
+func TestIndexRecommendation_SQL(t *testing.T) {
+	create := database.IndexRecommendation{IndexName: "idx_users_mobile", Table: "users", Columns: []string{"mobile"}, Type: "btree"}
+	assert.Equal(t, `CREATE INDEX CONCURRENTLY IF NOT EXISTS "idx_users_mobile" ON "users" ("mobile")`, create.SQL())
+
+	drop := database.IndexRecommendation{IndexName: "idx_orders_legacy", Table: "orders", Type: "drop"}
+	assert.Equal(t, `DROP INDEX CONCURRENTLY IF EXISTS "idx_orders_legacy"`, drop.SQL())
+}
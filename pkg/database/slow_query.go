@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// DefaultSlowQueryThreshold 默认慢查询阈值
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// SlowQuery 慢查询记录
+type SlowQuery struct {
+	RequestID     string        `json:"request_id,omitempty"`
+	CorrelationID string        `json:"correlation_id,omitempty"`
+	Query         string        `json:"query"`
+	Class         string        `json:"class"`
+	Duration      time.Duration `json:"duration"`
+	TimedOut      bool          `json:"timed_out,omitempty"` // 因超时被取消，不论耗时是否超过慢查询阈值都会记录
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+// SetSlowQueryThreshold 设置慢查询阈值，小于等于 0 时关闭慢查询记录
+func (db *DB) SetSlowQueryThreshold(threshold time.Duration) {
+	db.slowQueryThreshold = threshold
+	db.slowQueryConfigured = true
+}
+
+// OnSlowQuery 设置慢查询回调，例如写入安全监控或告警
+func (db *DB) OnSlowQuery(fn func(SlowQuery)) {
+	db.onSlowQuery = fn
+}
+
+// observeQuery 记录超过阈值或因超时被取消的查询，附带请求 ID 便于关联
+func (db *DB) observeQuery(ctx context.Context, query string, start time.Time) {
+	threshold := db.slowQueryThreshold
+	if !db.slowQueryConfigured {
+		threshold = DefaultSlowQueryThreshold
+	}
+	duration := time.Since(start)
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if !timedOut && (threshold <= 0 || duration < threshold) {
+		return
+	}
+
+	record := SlowQuery{
+		RequestID:     ctxutil.RequestID(ctx),
+		CorrelationID: ctxutil.CorrelationID(ctx),
+		Query:         NormalizeSQL(query),
+		Class:         QueryClass(ctx, query),
+		Duration:      duration,
+		TimedOut:      timedOut,
+		Timestamp:     start,
+	}
+	if timedOut {
+		metrics.GetGlobalCollector().RecordDBQueryTimeout(record.Class)
+		log.Printf("Query timed out (%s, %s) request_id=%s: %s", duration, record.Class, record.RequestID, record.Query)
+	} else {
+		log.Printf("Slow query (%s) request_id=%s: %s", duration, record.RequestID, record.Query)
+	}
+
+	if db.onSlowQuery != nil {
+		db.onSlowQuery(record)
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"user_crud_jwt/pkg/ctxutil"
+)
+
+// TenantColumn 多租户表的租户列。动态、关注与时间线、支付订单、通知尚没有租户列，在租户间共享
+const TenantColumn = "tenant_id"
+
+// TenantScope 在查询条件中追加当前租户的限定，占位符序号接在 args 之后。
+// ctx 中没有租户（后台任务等系统调用）时原样返回，不做限定
+//
+//	conditions, args = database.TenantScope(ctx, "c", conditions, args)
+//	// conditions: [..., "c.tenant_id = $3"]
+func TenantScope(ctx context.Context, alias string, conditions []string, args []interface{}) ([]string, []interface{}) {
+	tenantID := ctxutil.TenantID(ctx)
+	if tenantID == "" {
+		return conditions, args
+	}
+	args = append(args, tenantID)
+	return append(conditions, fmt.Sprintf("%s = $%d", qualify(alias, TenantColumn), len(args))), args
+}
+
+// TenantForWrite 写入数据时使用的租户，ctx 中没有租户时归入默认租户
+func TenantForWrite(ctx context.Context) string {
+	if tenantID := ctxutil.TenantID(ctx); tenantID != "" {
+		return tenantID
+	}
+	return ctxutil.DefaultTenantID
+}
+
+// TenantVisible 数据是否对 ctx 中的租户可见，用于无法在 SQL 中限定的场景（如内存仓库、缓存命中的数据）
+func TenantVisible(ctx context.Context, tenantID string) bool {
+	current := ctxutil.TenantID(ctx)
+	if current == "" {
+		return true
+	}
+	if tenantID == "" {
+		tenantID = ctxutil.DefaultTenantID
+	}
+	return current == tenantID
+}
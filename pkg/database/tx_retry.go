@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 可重试的 SQLSTATE：事务被服务端整体回滚，重新执行即可
+const (
+	SQLStateSerializationFailure = "40001"
+	SQLStateDeadlockDetected     = "40P01"
+)
+
+// ErrCommitUnknown 提交时连接出错且没有收到服务端响应，事务可能已提交，不能重试
+var ErrCommitUnknown = errors.New("transaction commit outcome unknown")
+
+// TxRetryConfig 事务重试配置
+type TxRetryConfig struct {
+	MaxAttempts    int            `json:"max_attempts"` // 含首次执行
+	InitialBackoff time.Duration  `json:"initial_backoff"`
+	MaxBackoff     time.Duration  `json:"max_backoff"`
+	Multiplier     float64        `json:"multiplier"`
+	TxOptions      *sql.TxOptions `json:"-"` // 如 sql.LevelSerializable，nil 使用数据库默认隔离级别
+}
+
+// DefaultTxRetryConfig 默认事务重试配置：最多执行 5 次，退避 10ms 起每次翻倍，不超过 500ms
+func DefaultTxRetryConfig() *TxRetryConfig {
+	return &TxRetryConfig{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+// SQLState 错误携带的 SQLSTATE，不是数据库返回的错误时为空
+func SQLState(err error) string {
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState()
+	}
+	return ""
+}
+
+// IsRetryableTxError 是否为序列化失败、死锁或乐观锁冲突
+func IsRetryableTxError(err error) bool {
+	return retryReason(err) != ""
+}
+
+// retryReason 可重试错误的原因，用作指标标签：SQLSTATE 或 stale_object；不可重试时为空
+func retryReason(err error) string {
+	switch code := SQLState(err); code {
+	case SQLStateSerializationFailure, SQLStateDeadlockDetected:
+		return code
+	}
+	if errors.Is(err, ErrStaleObject) {
+		return "stale_object"
+	}
+	return ""
+}
+
+// RunInTxWithRetry 在事务中执行 fn 并提交；fn 或提交返回序列化失败、死锁，或 fn 返回乐观锁冲突（ErrStaleObject）时回滚，
+// 按指数退避（带随机抖动）重新开启事务执行，fn 在新的事务中重新读取数据。
+// fn 可能执行多次，只应通过 tx 修改数据，不要在其中调用外部服务或修改内存状态；插入应依赖唯一约束（如 ON CONFLICT DO NOTHING）保证重复执行无害。
+// 其他错误（包括 fn 返回的业务错误）直接返回；提交结果未知时返回 ErrCommitUnknown，不重试。operation 用于指标与日志
+func (db *DB) RunInTxWithRetry(ctx context.Context, operation string, config *TxRetryConfig, fn func(tx *sqlx.Tx) error) error {
+	if config == nil {
+		config = DefaultTxRetryConfig()
+	}
+	return Retry(ctx, operation, config, func() error {
+		return db.runTx(ctx, config.TxOptions, fn)
+	})
+}
+
+// Retry 执行 fn，返回值满足 IsRetryableTxError 时按退避重试，用于不在单个事务中的读取-修改-写入：
+// fn 每次重新读取数据并以 UpdateVersioned 写回，版本冲突时重新读取
+func Retry(ctx context.Context, operation string, config *TxRetryConfig, fn func() error) error {
+	if config == nil {
+		config = DefaultTxRetryConfig()
+	}
+	collector := metrics.GetGlobalCollector()
+	backoff := config.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsRetryableTxError(err) {
+			return err
+		}
+		if attempt >= config.MaxAttempts {
+			collector.RecordTxRetryExhausted(operation)
+			return fmt.Errorf("%s failed after %d attempts: %w", operation, attempt, err)
+		}
+
+		reason := retryReason(err)
+		collector.RecordTxRetry(operation, reason)
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Printf("Retrying %s in %s after %s (attempt %d/%d)", operation, wait, reason, attempt, config.MaxAttempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s canceled while retrying: %w", operation, errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+		backoff = min(time.Duration(float64(backoff)*config.Multiplier), config.MaxBackoff)
+	}
+}
+
+// runTx 执行一次事务，fn 返回错误时回滚
+func (db *DB) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		// 服务端返回了错误或 ctx 已结束时事务一定已回滚；否则是连接在提交途中出错，结果未知
+		if SQLState(err) == "" && !errors.Is(err, sql.ErrTxDone) && ctx.Err() == nil {
+			return fmt.Errorf("%w: %w", ErrCommitUnknown, err)
+		}
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
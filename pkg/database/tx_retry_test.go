@@ -0,0 +1,88 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetry(attempts int) *database.TxRetryConfig {
+	return &database.TxRetryConfig{MaxAttempts: attempts, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Multiplier: 2}
+}
+
+func decrementStock(ctx context.Context) func(tx *sqlx.Tx) error {
+	return func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE coupons SET stock = stock - 1 WHERE id = $1", "c1"); err != nil {
+			return fmt.Errorf("failed to decrease stock: %w", err)
+		}
+		return nil
+	}
+}
+
+func TestRunInTxWithRetry_RetriesSerializationFailures(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	ctx := context.Background()
+
+	for _, code := range []string{database.SQLStateSerializationFailure, database.SQLStateDeadlockDetected} {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE coupons").WillReturnError(&pgconn.PgError{Code: code})
+		mock.ExpectRollback()
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE coupons").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, db.RunInTxWithRetry(ctx, "test_claim", fastRetry(3), decrementStock(ctx)))
+}
+
+func TestRunInTxWithRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE coupons").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit().WillReturnError(&pgconn.PgError{Code: database.SQLStateSerializationFailure})
+	}
+
+	err := db.RunInTxWithRetry(ctx, "test_claim", fastRetry(2), decrementStock(ctx))
+	require.Error(t, err)
+	assert.Equal(t, database.SQLStateSerializationFailure, database.SQLState(err))
+	assert.Contains(t, err.Error(), "after 2 attempts")
+}
+
+func TestRunInTxWithRetry_DoesNotRetryOtherErrors(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	ctx := context.Background()
+	errClaimed := errors.New("already claimed")
+
+	// 业务错误回滚后直接返回
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	err := db.RunInTxWithRetry(ctx, "test_claim", fastRetry(3), func(tx *sqlx.Tx) error { return errClaimed })
+	assert.ErrorIs(t, err, errClaimed)
+
+	// 唯一约束冲突不是可重试的错误
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE coupons").WillReturnError(&pgconn.PgError{Code: "23505"})
+	mock.ExpectRollback()
+	err = db.RunInTxWithRetry(ctx, "test_claim", fastRetry(3), decrementStock(ctx))
+	assert.Equal(t, "23505", database.SQLState(err))
+
+	// 提交时连接出错，结果未知，不能重试
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE coupons").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit().WillReturnError(errors.New("connection reset by peer"))
+	err = db.RunInTxWithRetry(ctx, "test_claim", fastRetry(3), decrementStock(ctx))
+	assert.ErrorIs(t, err, database.ErrCommitUnknown)
+}
@@ -0,0 +1,58 @@
+package dataloader
+
+import (
+	"context"
+	"log"
+	"time"
+	"user_crud_jwt/pkg/cache"
+)
+
+// Cached 为批量加载函数增加缓存：先批量读取缓存，未命中的键再调用 fetch 并回写缓存。
+// 缓存读写失败不影响加载结果，c 为 nil 时直接调用 fetch
+func Cached[K comparable, V any](c cache.CacheService, cacheKey func(K) string, ttl time.Duration, fetch BatchFunc[K, V]) BatchFunc[K, V] {
+	if c == nil {
+		return fetch
+	}
+	return func(ctx context.Context, keys []K) (map[K]V, error) {
+		cacheKeys := make([]string, len(keys))
+		for i, key := range keys {
+			cacheKeys[i] = cacheKey(key)
+		}
+
+		values := make(map[K]V, len(keys))
+		missing := keys
+		cached, err := c.GetMany(ctx, cacheKeys)
+		if err != nil {
+			log.Printf("dataloader: failed to read cache: %v", err)
+		} else {
+			missing = make([]K, 0, len(keys))
+			for i, key := range keys {
+				var v V
+				if !cached.Hit(cacheKeys[i]) || cached.Decode(cacheKeys[i], &v) != nil {
+					missing = append(missing, key)
+					continue
+				}
+				values[key] = v
+			}
+		}
+		if len(missing) == 0 {
+			return values, nil
+		}
+
+		fetched, err := fetch(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]cache.CacheEntry, 0, len(fetched))
+		for key, v := range fetched {
+			values[key] = v
+			entries = append(entries, cache.CacheEntry{Key: cacheKey(key), Value: v, Expiration: ttl})
+		}
+		if result, err := c.SetMany(ctx, entries); err != nil {
+			log.Printf("dataloader: failed to write cache: %v", err)
+		} else if result.HasErrors() {
+			log.Printf("dataloader: failed to write %d cache entries", len(result.Errors))
+		}
+		return values, nil
+	}
+}
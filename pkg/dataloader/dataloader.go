@@ -0,0 +1,176 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 批量加载结果中不包含请求的键
+var ErrNotFound = errors.New("dataloader: key not found")
+
+// BatchFunc 批量加载函数，返回结果中缺少的键视为不存在
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Config 批量加载配置
+type Config struct {
+	Wait     time.Duration // 收集同一批次键的等待时间
+	MaxBatch int           // 单批次最大键数，达到后立即加载
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		Wait:     2 * time.Millisecond,
+		MaxBatch: 100,
+	}
+}
+
+// result 单个键的加载结果，done 关闭后 value/err 可读
+type result[V any] struct {
+	value V
+	err   error
+	done  chan struct{}
+}
+
+// batch 等待加载的一批键
+type batch[K comparable, V any] struct {
+	ctx     context.Context
+	keys    []K
+	results []*result[V]
+	timer   *time.Timer
+}
+
+// Loader 将短时间内的多次 Load 合并为一次批量加载，并缓存已加载的结果。
+// 结果缓存没有过期机制，Loader 应按请求创建
+type Loader[K comparable, V any] struct {
+	fetch   BatchFunc[K, V]
+	config  Config
+	cache   map[K]*result[V]
+	pending *batch[K, V]
+	mu      sync.Mutex
+}
+
+// New 创建批量加载器
+func New[K comparable, V any](fetch BatchFunc[K, V], config Config) *Loader[K, V] {
+	if config.MaxBatch <= 0 {
+		config.MaxBatch = DefaultConfig().MaxBatch
+	}
+	return &Loader[K, V]{
+		fetch:  fetch,
+		config: config,
+		cache:  make(map[K]*result[V]),
+	}
+}
+
+// Load 加载单个键，同一批次内的键由一次 BatchFunc 调用完成。
+// 批量加载使用该批次第一个调用方的 ctx
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.cache[key] = r
+		l.enqueue(ctx, key, r)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// LoadMany 加载多个键，按 keys 顺序返回结果与错误
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, []error) {
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func(i int, key K) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(ctx, key)
+		}(i, key)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+// Prime 写入已知结果，键已缓存时不覆盖
+func (l *Loader[K, V]) Prime(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.cache[key]; ok {
+		return
+	}
+	r := &result[V]{value: value, done: make(chan struct{})}
+	close(r.done)
+	l.cache[key] = r
+}
+
+// enqueue 将键加入当前批次，调用方需持有 mu
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K, r *result[V]) {
+	if l.pending == nil {
+		b := &batch[K, V]{ctx: ctx}
+		b.timer = time.AfterFunc(l.config.Wait, func() { l.dispatch(b) })
+		l.pending = b
+	}
+	b := l.pending
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+
+	if len(b.keys) >= l.config.MaxBatch {
+		b.timer.Stop()
+		l.pending = nil
+		go l.run(b)
+	}
+}
+
+// dispatch 等待时间到达后加载批次，批次已因达到上限被加载时忽略
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+	l.run(b)
+}
+
+// run 执行批量加载并通知等待的调用方，加载失败的键从缓存移除以便重试
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	values, err := l.call(b)
+
+	l.mu.Lock()
+	for i, key := range b.keys {
+		r := b.results[i]
+		switch v, ok := values[key]; {
+		case err != nil:
+			r.err = err
+			delete(l.cache, key)
+		case ok:
+			r.value = v
+		default:
+			r.err = ErrNotFound
+		}
+		close(r.done)
+	}
+	l.mu.Unlock()
+}
+
+// call 调用批量加载函数，panic 转为错误避免调用方永久阻塞
+func (l *Loader[K, V]) call(b *batch[K, V]) (values map[K]V, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("dataloader: batch function panicked: %v", p)
+		}
+	}()
+	return l.fetch(b.ctx, b.keys)
+}
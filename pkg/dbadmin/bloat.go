@@ -0,0 +1,380 @@
+package dbadmin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/security"
+)
+
+// TableBloat 表的膨胀估算与清理统计
+type TableBloat struct {
+	Schema          string     `json:"schema" db:"schema"`
+	Table           string     `json:"table" db:"table"`
+	SizeBytes       int64      `json:"size_bytes" db:"size_bytes"`   // 堆表大小，不含索引与 TOAST
+	BloatBytes      int64      `json:"bloat_bytes" db:"bloat_bytes"` // 可回收的空间
+	BloatRatio      float64    `json:"bloat_ratio" db:"bloat_ratio"`
+	LiveTuples      int64      `json:"live_tuples" db:"live_tuples"`
+	DeadTuples      int64      `json:"dead_tuples" db:"dead_tuples"`
+	VacuumThreshold int64      `json:"vacuum_threshold" db:"vacuum_threshold"` // 触发 autovacuum 的死元组数
+	LastVacuum      *time.Time `json:"last_vacuum,omitempty" db:"last_vacuum"`
+	LastAutovacuum  *time.Time `json:"last_autovacuum,omitempty" db:"last_autovacuum"`
+	AutovacuumCount int64      `json:"autovacuum_count" db:"autovacuum_count"`
+	// AutovacuumRuns 与上次分析相比新增的 autovacuum 次数，首次分析时为 0
+	AutovacuumRuns int64 `json:"autovacuum_runs" db:"-"`
+	// AutovacuumLagging 死元组已超过触发阈值，但 autovacuum 长时间未执行
+	AutovacuumLagging bool `json:"autovacuum_lagging" db:"-"`
+}
+
+// DeadRatio 死元组占比
+func (t *TableBloat) DeadRatio() float64 {
+	if total := t.LiveTuples + t.DeadTuples; total > 0 {
+		return float64(t.DeadTuples) / float64(total)
+	}
+	return 0
+}
+
+// IndexBloat 索引的膨胀估算，目前只估算 btree 索引
+type IndexBloat struct {
+	Schema     string  `json:"schema" db:"schema"`
+	Table      string  `json:"table" db:"table"`
+	Index      string  `json:"index" db:"index"`
+	SizeBytes  int64   `json:"size_bytes" db:"size_bytes"`
+	BloatBytes int64   `json:"bloat_bytes" db:"bloat_bytes"`
+	BloatRatio float64 `json:"bloat_ratio" db:"bloat_ratio"`
+}
+
+// RecommendationKind 建议的维护操作
+type RecommendationKind string
+
+const (
+	// RecommendVacuum 死元组堆积，autovacuum 未跟上
+	RecommendVacuum RecommendationKind = "vacuum"
+	// RecommendVacuumFull 表膨胀严重，普通 VACUUM 无法归还空间；VACUUM FULL 会锁表，线上可改用 pg_repack
+	RecommendVacuumFull RecommendationKind = "vacuum_full"
+	// RecommendReindex 索引膨胀，可在线重建
+	RecommendReindex RecommendationKind = "reindex"
+)
+
+// Severity 建议的严重程度
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Recommendation 维护建议，只给出语句，不自动执行
+type Recommendation struct {
+	Kind             RecommendationKind `json:"kind"`
+	Severity         Severity           `json:"severity"`
+	Schema           string             `json:"schema"`
+	Table            string             `json:"table"`
+	Index            string             `json:"index,omitempty"`
+	Reason           string             `json:"reason"`
+	Statement        string             `json:"statement"`
+	ReclaimableBytes int64              `json:"reclaimable_bytes"`
+}
+
+// relation 建议针对的表或索引
+func (r *Recommendation) relation() string {
+	if r.Index != "" {
+		return r.Schema + "." + r.Index
+	}
+	return r.Schema + "." + r.Table
+}
+
+// BloatReport 一次膨胀分析的结果
+type BloatReport struct {
+	AnalyzedAt      time.Time        `json:"analyzed_at"`
+	Exact           bool             `json:"exact"` // 使用 pgstattuple 统计，否则为按 pg_stats 的估算
+	Tables          []TableBloat     `json:"tables"`
+	Indexes         []IndexBloat     `json:"indexes"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// BloatSource 表与索引的膨胀统计
+type BloatSource interface {
+	// Exact 是否可使用 pgstattuple 精确统计
+	Exact(ctx context.Context) (bool, error)
+	// Tables 不小于 minSize 字节的表
+	Tables(ctx context.Context, exact bool, minSize int64) ([]TableBloat, error)
+	// Indexes 不小于 minSize 字节的 btree 索引
+	Indexes(ctx context.Context, exact bool, minSize int64) ([]IndexBloat, error)
+}
+
+// EventRecorder 记录告警事件，由 *security.SecurityMonitor 实现
+type EventRecorder interface {
+	RecordEvent(ctx context.Context, event security.SecurityEvent)
+}
+
+// BloatConfig 膨胀分析配置
+type BloatConfig struct {
+	Interval time.Duration // 分析间隔，pgstattuple 需扫描表，不宜过于频繁
+	MinSize  int64         // 小于该字节数的表与索引不分析
+	// 表的可回收空间占比超过阈值时建议 VACUUM FULL
+	TableWarnRatio     float64
+	TableCriticalRatio float64
+	// 索引的可回收空间占比超过阈值时建议 REINDEX
+	IndexWarnRatio     float64
+	IndexCriticalRatio float64
+	// DeadTupleRatio 死元组占比超过该值、且超过 autovacuum 触发阈值 AutovacuumLag 仍未清理时建议 VACUUM
+	DeadTupleRatio float64
+	AutovacuumLag  time.Duration
+}
+
+// DefaultBloatConfig 默认膨胀分析配置
+func DefaultBloatConfig() *BloatConfig {
+	return &BloatConfig{
+		Interval:           time.Hour,
+		MinSize:            16 << 20,
+		TableWarnRatio:     0.3,
+		TableCriticalRatio: 0.5,
+		IndexWarnRatio:     0.4,
+		IndexCriticalRatio: 0.6,
+		DeadTupleRatio:     0.2,
+		AutovacuumLag:      24 * time.Hour,
+	}
+}
+
+// BloatAnalyzer 定期估算表与索引膨胀，跟踪 autovacuum 是否跟上，生成 VACUUM、VACUUM FULL 与 REINDEX 建议；
+// 建议首次出现或升级为 critical 时记录数据库健康事件
+type BloatAnalyzer struct {
+	source   BloatSource
+	recorder EventRecorder // 为 nil 时不记录事件
+	config   *BloatConfig
+	clock    clock.Clock
+
+	mu      sync.RWMutex
+	last    *BloatReport
+	alerted map[string]Severity // 已告警的 relation:kind 与级别，建议消失后移除，再次出现时重新告警
+}
+
+// NewBloatAnalyzer 创建膨胀分析器，config 为 nil 时使用默认配置
+func NewBloatAnalyzer(source BloatSource, recorder EventRecorder, config *BloatConfig) *BloatAnalyzer {
+	if config == nil {
+		config = DefaultBloatConfig()
+	}
+	return &BloatAnalyzer{
+		source:   source,
+		recorder: recorder,
+		config:   config,
+		clock:    clock.OrReal(nil),
+		alerted:  make(map[string]Severity),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (a *BloatAnalyzer) SetClock(c clock.Clock) {
+	a.clock = clock.OrReal(c)
+}
+
+// Run 按间隔分析，直到 ctx 取消
+func (a *BloatAnalyzer) Run(ctx context.Context) {
+	ticker := a.clock.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := a.Analyze(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Bloat analysis failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Last 最近一次分析的结果，尚未分析时为 nil
+func (a *BloatAnalyzer) Last() *BloatReport {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.last
+}
+
+// Analyze 立即分析并更新指标与告警
+func (a *BloatAnalyzer) Analyze(ctx context.Context) (*BloatReport, error) {
+	exact, err := a.source.Exact(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := a.source.Tables(ctx, exact, a.config.MinSize)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := a.source.Indexes(ctx, exact, a.config.MinSize)
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.clock.Now()
+	report := &BloatReport{AnalyzedAt: now, Exact: exact, Tables: tables, Indexes: indexes}
+
+	a.mu.Lock()
+	previous := make(map[string]int64)
+	if a.last != nil {
+		for _, table := range a.last.Tables {
+			previous[table.Schema+"."+table.Table] = table.AutovacuumCount
+		}
+	}
+	for i := range report.Tables {
+		table := &report.Tables[i]
+		if count, ok := previous[table.Schema+"."+table.Table]; ok && table.AutovacuumCount > count {
+			table.AutovacuumRuns = table.AutovacuumCount - count
+		}
+		table.AutovacuumLagging = a.lagging(table, now)
+	}
+	report.Recommendations = a.recommend(report)
+	a.last = report
+	alerts := a.escalations(report.Recommendations)
+	a.mu.Unlock()
+
+	a.recordMetrics(report)
+	for _, rec := range alerts {
+		a.alert(ctx, rec)
+	}
+	return report, nil
+}
+
+// lagging 死元组超过 autovacuum 触发阈值与占比阈值，且最近一次清理早于 AutovacuumLag
+func (a *BloatAnalyzer) lagging(table *TableBloat, now time.Time) bool {
+	if table.DeadTuples <= table.VacuumThreshold || table.DeadRatio() < a.config.DeadTupleRatio {
+		return false
+	}
+	var last time.Time
+	for _, at := range []*time.Time{table.LastVacuum, table.LastAutovacuum} {
+		if at != nil && at.After(last) {
+			last = *at
+		}
+	}
+	return now.Sub(last) >= a.config.AutovacuumLag
+}
+
+// recommend 按阈值生成建议，critical 在前，同级按可回收空间降序
+func (a *BloatAnalyzer) recommend(report *BloatReport) []Recommendation {
+	var recs []Recommendation
+	for _, table := range report.Tables {
+		if table.AutovacuumLagging {
+			recs = append(recs, Recommendation{
+				Kind:     RecommendVacuum,
+				Severity: SeverityWarning,
+				Schema:   table.Schema,
+				Table:    table.Table,
+				Reason: fmt.Sprintf("%d dead tuples (%.0f%%) exceed the autovacuum threshold %d and the table has not been vacuumed for %s",
+					table.DeadTuples, table.DeadRatio()*100, table.VacuumThreshold, a.config.AutovacuumLag),
+				Statement: "VACUUM (ANALYZE) " + quoteIdent(table.Schema, table.Table),
+			})
+		}
+		if severity, ok := severityOf(table.BloatRatio, a.config.TableWarnRatio, a.config.TableCriticalRatio); ok {
+			recs = append(recs, Recommendation{
+				Kind:     RecommendVacuumFull,
+				Severity: severity,
+				Schema:   table.Schema,
+				Table:    table.Table,
+				Reason: fmt.Sprintf("%.0f%% of the table is reclaimable; VACUUM FULL takes an exclusive lock, consider pg_repack on busy tables",
+					table.BloatRatio*100),
+				Statement:        "VACUUM (FULL, ANALYZE) " + quoteIdent(table.Schema, table.Table),
+				ReclaimableBytes: table.BloatBytes,
+			})
+		}
+	}
+	for _, index := range report.Indexes {
+		if severity, ok := severityOf(index.BloatRatio, a.config.IndexWarnRatio, a.config.IndexCriticalRatio); ok {
+			recs = append(recs, Recommendation{
+				Kind:             RecommendReindex,
+				Severity:         severity,
+				Schema:           index.Schema,
+				Table:            index.Table,
+				Index:            index.Index,
+				Reason:           fmt.Sprintf("%.0f%% of the index is reclaimable", index.BloatRatio*100),
+				Statement:        "REINDEX INDEX CONCURRENTLY " + quoteIdent(index.Schema, index.Index),
+				ReclaimableBytes: index.BloatBytes,
+			})
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool {
+		if recs[i].Severity != recs[j].Severity {
+			return recs[i].Severity == SeverityCritical
+		}
+		return recs[i].ReclaimableBytes > recs[j].ReclaimableBytes
+	})
+	return recs
+}
+
+// escalations 首次出现或由 warning 升级为 critical 的建议，调用方需持有锁
+func (a *BloatAnalyzer) escalations(recs []Recommendation) []Recommendation {
+	current := make(map[string]Severity, len(recs))
+	var alerts []Recommendation
+	for _, rec := range recs {
+		key := rec.relation() + ":" + string(rec.Kind)
+		current[key] = rec.Severity
+		if previous, ok := a.alerted[key]; !ok || (previous == SeverityWarning && rec.Severity == SeverityCritical) {
+			alerts = append(alerts, rec)
+		}
+	}
+	a.alerted = current
+	return alerts
+}
+
+func (a *BloatAnalyzer) alert(ctx context.Context, rec Recommendation) {
+	log.Printf("Database maintenance recommended (%s): %s on %s: %s", rec.Severity, rec.Kind, rec.relation(), rec.Reason)
+	if a.recorder == nil {
+		return
+	}
+	level := security.LevelWarning
+	if rec.Severity == SeverityCritical {
+		level = security.LevelCritical
+	}
+	a.recorder.RecordEvent(ctx, security.SecurityEvent{
+		Type:    security.EventDatabaseHealth,
+		Level:   level,
+		Source:  "bloat_analyzer",
+		Message: fmt.Sprintf("%s recommended on %s", rec.Kind, rec.relation()),
+		Details: map[string]interface{}{
+			"kind":              string(rec.Kind),
+			"relation":          rec.relation(),
+			"reason":            rec.Reason,
+			"statement":         rec.Statement,
+			"reclaimable_bytes": rec.ReclaimableBytes,
+		},
+	})
+}
+
+func (a *BloatAnalyzer) recordMetrics(report *BloatReport) {
+	collector := metrics.GetGlobalCollector()
+	// 已删除的表不再上报
+	collector.ResetDBBloat()
+	for _, table := range report.Tables {
+		collector.UpdateDBBloat(table.Schema+"."+table.Table, "table", table.BloatBytes, table.BloatRatio)
+		collector.UpdateDBDeadTuples(table.Schema+"."+table.Table, table.DeadTuples)
+	}
+	for _, index := range report.Indexes {
+		collector.UpdateDBBloat(index.Schema+"."+index.Index, "index", index.BloatBytes, index.BloatRatio)
+	}
+}
+
+// severityOf 按占比与阈值确定级别，阈值为 0 时不检查
+func severityOf(ratio, warn, critical float64) (Severity, bool) {
+	switch {
+	case critical > 0 && ratio >= critical:
+		return SeverityCritical, true
+	case warn > 0 && ratio >= warn:
+		return SeverityWarning, true
+	}
+	return "", false
+}
+
+// quoteIdent 以双引号引用 schema 与对象名
+func quoteIdent(parts ...string) string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		quoted[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, ".")
+}
@@ -0,0 +1,115 @@
+package dbadmin
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource 返回预设的统计
+type fakeSource struct {
+	tables  []TableBloat
+	indexes []IndexBloat
+}
+
+func (s *fakeSource) Exact(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (s *fakeSource) Tables(ctx context.Context, exact bool, minSize int64) ([]TableBloat, error) {
+	return append([]TableBloat(nil), s.tables...), nil
+}
+
+func (s *fakeSource) Indexes(ctx context.Context, exact bool, minSize int64) ([]IndexBloat, error) {
+	return append([]IndexBloat(nil), s.indexes...), nil
+}
+
+// captureRecorder 记录告警事件
+type captureRecorder struct {
+	events []security.SecurityEvent
+}
+
+func (r *captureRecorder) RecordEvent(ctx context.Context, event security.SecurityEvent) {
+	r.events = append(r.events, event)
+}
+
+// TestBloatAnalyzer_Recommendations 膨胀超过阈值建议 VACUUM FULL 或 REINDEX，死元组堆积且长时间未清理建议 VACUUM
+func TestBloatAnalyzer_Recommendations(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	dayAgo := now.Add(-30 * time.Hour)
+	source := &fakeSource{
+		tables: []TableBloat{
+			{Schema: "public", Table: "jobs", SizeBytes: 100 << 20, BloatBytes: 60 << 20, BloatRatio: 0.6,
+				LiveTuples: 1000, DeadTuples: 9000, VacuumThreshold: 250, LastAutovacuum: &dayAgo, AutovacuumCount: 3},
+			{Schema: "public", Table: "users", SizeBytes: 50 << 20, BloatBytes: 5 << 20, BloatRatio: 0.1,
+				LiveTuples: 100000, DeadTuples: 100, VacuumThreshold: 20050, AutovacuumCount: 7},
+		},
+		indexes: []IndexBloat{
+			{Schema: "public", Table: "users", Index: "idx_users_mobile", SizeBytes: 20 << 20, BloatBytes: 9 << 20, BloatRatio: 0.45},
+		},
+	}
+	analyzer := NewBloatAnalyzer(source, nil, nil)
+	analyzer.SetClock(fakes.NewClock(now))
+
+	report, err := analyzer.Analyze(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, report, analyzer.Last())
+	assert.True(t, report.Tables[0].AutovacuumLagging)
+	assert.False(t, report.Tables[1].AutovacuumLagging)
+
+	require.Len(t, report.Recommendations, 3)
+	assert.Equal(t, RecommendVacuumFull, report.Recommendations[0].Kind)
+	assert.Equal(t, SeverityCritical, report.Recommendations[0].Severity)
+	assert.Equal(t, `VACUUM (FULL, ANALYZE) "public"."jobs"`, report.Recommendations[0].Statement)
+	assert.Equal(t, RecommendReindex, report.Recommendations[1].Kind)
+	assert.Equal(t, `REINDEX INDEX CONCURRENTLY "public"."idx_users_mobile"`, report.Recommendations[1].Statement)
+	assert.Equal(t, RecommendVacuum, report.Recommendations[2].Kind)
+
+	// autovacuum 执行后死元组清理，VACUUM 建议消失，并记录新增的执行次数
+	source.tables[0].DeadTuples = 10
+	source.tables[0].AutovacuumCount = 5
+	report, err = analyzer.Analyze(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), report.Tables[0].AutovacuumRuns)
+	assert.Len(t, report.Recommendations, 2)
+}
+
+// TestBloatAnalyzer_Alerts 建议首次出现或升级为 critical 时告警，持续存在时不重复告警，消失后再次出现时重新告警
+func TestBloatAnalyzer_Alerts(t *testing.T) {
+	source := &fakeSource{
+		indexes: []IndexBloat{{Schema: "public", Table: "orders", Index: "idx_orders_user", BloatRatio: 0.45}},
+	}
+	recorder := &captureRecorder{}
+	analyzer := NewBloatAnalyzer(source, recorder, nil)
+	ctx := context.Background()
+
+	_, err := analyzer.Analyze(ctx)
+	require.NoError(t, err)
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, security.EventDatabaseHealth, recorder.events[0].Type)
+	assert.Equal(t, security.LevelWarning, recorder.events[0].Level)
+	assert.Equal(t, "public.idx_orders_user", recorder.events[0].Details["relation"])
+
+	_, err = analyzer.Analyze(ctx)
+	require.NoError(t, err)
+	assert.Len(t, recorder.events, 1)
+
+	source.indexes[0].BloatRatio = 0.7
+	_, err = analyzer.Analyze(ctx)
+	require.NoError(t, err)
+	require.Len(t, recorder.events, 2)
+	assert.Equal(t, security.LevelCritical, recorder.events[1].Level)
+
+	source.indexes[0].BloatRatio = 0.1
+	_, err = analyzer.Analyze(ctx)
+	require.NoError(t, err)
+	source.indexes[0].BloatRatio = 0.5
+	_, err = analyzer.Analyze(ctx)
+	require.NoError(t, err)
+	assert.Len(t, recorder.events, 3)
+}
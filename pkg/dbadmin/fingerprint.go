@@ -0,0 +1,40 @@
+package dbadmin
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+	"user_crud_jwt/pkg/database"
+)
+
+var (
+	stringLiteralPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteralPattern = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	paramPattern         = regexp.MustCompile(`\$\d+`)
+	inListPattern        = regexp.MustCompile(`(?i)\bin\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+)
+
+// fingerprint 查询指纹：字面量与参数替换为 ?，IN 列表折叠，大小写不敏感；取规范化文本的 SHA-1 前 8 字节
+func fingerprint(query string) (id, normalized string) {
+	normalized = database.NormalizeSQL(query)
+	normalized = stringLiteralPattern.ReplaceAllString(normalized, "?")
+	normalized = paramPattern.ReplaceAllString(normalized, "?")
+	normalized = numberLiteralPattern.ReplaceAllString(normalized, "?")
+	normalized = inListPattern.ReplaceAllString(normalized, "IN (...)")
+	normalized = strings.ToLower(normalized)
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:8]), normalized
+}
+
+// truncate 事件与接口中的 SQL 截断到 n 字节，不截断多字节字符
+func truncate(query string, n int) string {
+	if n <= 0 || len(query) <= n {
+		return query
+	}
+	for n > 0 && !utf8.RuneStart(query[n]) {
+		n--
+	}
+	return query[:n] + "..."
+}
@@ -0,0 +1,69 @@
+package dbadmin
+
+import (
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BloatHandler 表与索引膨胀的管理接口
+type BloatHandler struct {
+	analyzer *BloatAnalyzer
+}
+
+// NewBloatHandler 创建膨胀分析管理接口
+func NewBloatHandler(analyzer *BloatAnalyzer) *BloatHandler {
+	return &BloatHandler{analyzer: analyzer}
+}
+
+// RegisterAdminRoutes 注册膨胀分析路由，调用方需挂载管理员权限校验
+func (h *BloatHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/db/bloat", h.GetReport)
+	group.POST("/db/bloat/analyze", h.Analyze)
+}
+
+// GetReport 最近一次分析的结果与维护建议，尚未分析时立即分析
+func (h *BloatHandler) GetReport(c *gin.Context) {
+	if report := h.analyzer.Last(); report != nil {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	h.Analyze(c)
+}
+
+// Analyze 立即分析，pgstattuple 统计需扫描表，大库上耗时较长
+func (h *BloatHandler) Analyze(c *gin.Context) {
+	report, err := h.analyzer.Analyze(c.Request.Context())
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// LockHandler 锁等待与死锁的管理接口
+type LockHandler struct {
+	monitor *LockMonitor
+}
+
+// NewLockHandler 创建锁监控管理接口
+func NewLockHandler(monitor *LockMonitor) *LockHandler {
+	return &LockHandler{monitor: monitor}
+}
+
+// RegisterAdminRoutes 注册锁监控路由，调用方需挂载管理员权限校验
+func (h *LockHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/db/locks", h.ListWaits)
+	group.GET("/db/locks/patterns", h.ListPatterns)
+}
+
+// ListWaits 最近一次采样的锁等待，含阻塞与被阻塞的 SQL
+func (h *LockHandler) ListWaits(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"waits": h.monitor.Waits()})
+}
+
+// ListPatterns 按查询指纹聚合的锁等待模式与处理建议
+func (h *LockHandler) ListPatterns(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"patterns": h.monitor.Patterns()})
+}
@@ -0,0 +1,484 @@
+package dbadmin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/security"
+)
+
+// LockWait 一个会话等待另一个会话持有的锁
+type LockWait struct {
+	BlockedPID          int           `json:"blocked_pid"`
+	BlockedQuery        string        `json:"blocked_query"`
+	Waiting             time.Duration `json:"waiting"` // 被阻塞语句已执行的时长
+	BlockingPID         int           `json:"blocking_pid"`
+	BlockingQuery       string        `json:"blocking_query"` // 阻塞方当前或最近一条语句，未必是加锁的语句
+	BlockingState       string        `json:"blocking_state"` // active、idle in transaction 等
+	BlockingXact        time.Duration `json:"blocking_xact"`  // 阻塞方事务已开启的时长
+	Relation            string        `json:"relation,omitempty"`
+	LockType            string        `json:"lock_type"` // relation、tuple、transactionid 等
+	LockMode            string        `json:"lock_mode"`
+	BlockedFingerprint  string        `json:"blocked_fingerprint"`
+	BlockingFingerprint string        `json:"blocking_fingerprint"`
+	blockedNormal       string
+	blockingNormal      string
+}
+
+// PatternKind 锁等待模式
+type PatternKind string
+
+const (
+	// PatternLongWait 等待超过阈值
+	PatternLongWait PatternKind = "long_wait"
+	// PatternLockOrderInversion 两类语句在同一对象上互相等待过，按不同顺序加锁，并发时可能死锁
+	PatternLockOrderInversion PatternKind = "lock_order_inversion"
+	// PatternDeadlockCycle 采样时等待关系成环，数据库将在 deadlock_timeout 后终止其中一个事务
+	PatternDeadlockCycle PatternKind = "deadlock_cycle"
+)
+
+// LockPattern 按阻塞方与被阻塞方的查询指纹聚合的锁等待
+type LockPattern struct {
+	Kind                PatternKind   `json:"kind"`
+	BlockedFingerprint  string        `json:"blocked_fingerprint"`
+	BlockingFingerprint string        `json:"blocking_fingerprint"`
+	BlockedQuery        string        `json:"blocked_query"`  // 规范化后的示例
+	BlockingQuery       string        `json:"blocking_query"` // 规范化后的示例
+	Relation            string        `json:"relation,omitempty"`
+	LockMode            string        `json:"lock_mode"`
+	Occurrences         int64         `json:"occurrences"` // 观察到的等待次数
+	MaxWait             time.Duration `json:"max_wait"`
+	FirstSeen           time.Time     `json:"first_seen"`
+	LastSeen            time.Time     `json:"last_seen"`
+	Remediation         []string      `json:"remediation"`
+}
+
+// LockSource 锁等待与死锁统计
+type LockSource interface {
+	// Waits 当前数据库中被阻塞的会话，每个阻塞方一行
+	Waits(ctx context.Context) ([]LockWait, error)
+	// Deadlocks 当前数据库累计的死锁次数
+	Deadlocks(ctx context.Context) (int64, error)
+}
+
+// LockConfig 锁监控配置
+type LockConfig struct {
+	Interval       time.Duration // 采样间隔
+	WaitThreshold  time.Duration // 等待超过该时长记为长时间等待
+	PatternWindow  time.Duration // 模式在该时长内未再出现时移除
+	AlertCooldown  time.Duration // 同一模式两次告警的最小间隔
+	MaxQueryLength int           // 事件与接口中 SQL 的最大长度
+}
+
+// DefaultLockConfig 默认锁监控配置
+func DefaultLockConfig() *LockConfig {
+	return &LockConfig{
+		Interval:       10 * time.Second,
+		WaitThreshold:  5 * time.Second,
+		PatternWindow:  24 * time.Hour,
+		AlertCooldown:  15 * time.Minute,
+		MaxQueryLength: 2048,
+	}
+}
+
+// LockMonitor 定期采样 pg_locks 与 pg_stat_activity，按查询指纹聚合长时间的锁等待，
+// 检测等待成环与加锁顺序相反的语句，并在数据库死锁计数增加时记录事件；事件中带阻塞与被阻塞的 SQL 及处理建议
+type LockMonitor struct {
+	source   LockSource
+	recorder EventRecorder // 为 nil 时不记录事件
+	config   *LockConfig
+	clock    clock.Clock
+
+	mu        sync.RWMutex
+	waits     []LockWait
+	patterns  map[string]*LockPattern
+	deadlocks int64 // 上次采样的累计死锁次数，-1 表示尚未采样
+	alerted   map[string]time.Time
+}
+
+// NewLockMonitor 创建锁监控，config 为 nil 时使用默认配置
+func NewLockMonitor(source LockSource, recorder EventRecorder, config *LockConfig) *LockMonitor {
+	if config == nil {
+		config = DefaultLockConfig()
+	}
+	return &LockMonitor{
+		source:    source,
+		recorder:  recorder,
+		config:    config,
+		clock:     clock.OrReal(nil),
+		patterns:  make(map[string]*LockPattern),
+		deadlocks: -1,
+		alerted:   make(map[string]time.Time),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (m *LockMonitor) SetClock(c clock.Clock) {
+	m.clock = clock.OrReal(c)
+}
+
+// Run 按间隔采样，直到 ctx 取消
+func (m *LockMonitor) Run(ctx context.Context) {
+	ticker := m.clock.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.Sample(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Lock sampling failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Waits 最近一次采样的锁等待
+func (m *LockMonitor) Waits() []LockWait {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]LockWait(nil), m.waits...)
+}
+
+// Patterns 窗口内的锁等待模式，死锁环与加锁顺序相反的在前，其余按出现次数降序
+func (m *LockMonitor) Patterns() []LockPattern {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	patterns := make([]LockPattern, 0, len(m.patterns))
+	for _, pattern := range m.patterns {
+		patterns = append(patterns, *pattern)
+	}
+	sortPatterns(patterns)
+	return patterns
+}
+
+// Sample 采样一次，更新模式、指标与告警
+func (m *LockMonitor) Sample(ctx context.Context) error {
+	waits, err := m.source.Waits(ctx)
+	if err != nil {
+		return err
+	}
+	deadlocks, err := m.source.Deadlocks(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := m.clock.Now()
+	var longest time.Duration
+	for i := range waits {
+		wait := &waits[i]
+		wait.BlockedFingerprint, wait.blockedNormal = fingerprint(wait.BlockedQuery)
+		wait.BlockingFingerprint, wait.blockingNormal = fingerprint(wait.BlockingQuery)
+		wait.BlockedQuery = truncate(wait.BlockedQuery, m.config.MaxQueryLength)
+		wait.BlockingQuery = truncate(wait.BlockingQuery, m.config.MaxQueryLength)
+		longest = max(longest, wait.Waiting)
+	}
+
+	m.mu.Lock()
+	m.waits = waits
+	var alerts []*LockPattern
+	for i := range waits {
+		if waits[i].Waiting >= m.config.WaitThreshold {
+			alerts = append(alerts, m.observe(&waits[i], PatternLongWait, now))
+		}
+	}
+	for _, cycle := range findCycles(waits) {
+		for _, wait := range cycle {
+			alerts = append(alerts, m.observe(wait, PatternDeadlockCycle, now))
+		}
+	}
+	alerts = append(alerts, m.inversions(now)...)
+	m.prune(now)
+	alerts = m.due(alerts, now)
+
+	var newDeadlocks int64
+	if m.deadlocks >= 0 && deadlocks > m.deadlocks {
+		newDeadlocks = deadlocks - m.deadlocks
+	}
+	m.deadlocks = deadlocks
+	var suspects []LockPattern
+	if newDeadlocks > 0 {
+		for _, pattern := range m.patterns {
+			if pattern.Kind != PatternLongWait {
+				suspects = append(suspects, *pattern)
+			}
+		}
+		sortPatterns(suspects)
+	}
+	m.mu.Unlock()
+
+	collector := metrics.GetGlobalCollector()
+	collector.UpdateDBLockWaits(len(waits), longest)
+	collector.RecordDBDeadlocks(newDeadlocks)
+	for _, pattern := range alerts {
+		m.alert(ctx, pattern)
+	}
+	if newDeadlocks > 0 {
+		m.alertDeadlocks(ctx, newDeadlocks, suspects)
+	}
+	return nil
+}
+
+// observe 将一次等待计入模式，调用方需持有锁
+func (m *LockMonitor) observe(wait *LockWait, kind PatternKind, now time.Time) *LockPattern {
+	key := patternKey(kind, wait.BlockedFingerprint, wait.BlockingFingerprint, wait.Relation)
+	pattern, ok := m.patterns[key]
+	if !ok {
+		pattern = &LockPattern{
+			Kind:                kind,
+			BlockedFingerprint:  wait.BlockedFingerprint,
+			BlockingFingerprint: wait.BlockingFingerprint,
+			BlockedQuery:        truncate(wait.blockedNormal, m.config.MaxQueryLength),
+			BlockingQuery:       truncate(wait.blockingNormal, m.config.MaxQueryLength),
+			Relation:            wait.Relation,
+			LockMode:            wait.LockMode,
+			FirstSeen:           now,
+		}
+		m.patterns[key] = pattern
+	}
+	pattern.Occurrences++
+	pattern.LastSeen = now
+	pattern.MaxWait = max(pattern.MaxWait, wait.Waiting)
+	pattern.Remediation = remediation(kind, wait)
+	return pattern
+}
+
+// inversions 窗口内 A 等待 B、B 也等待过 A 的语句对，每对记录一个模式，调用方需持有锁
+func (m *LockMonitor) inversions(now time.Time) []*LockPattern {
+	var found []*LockPattern
+	counted := make(map[string]bool)
+	for _, pattern := range m.patterns {
+		if pattern.Kind == PatternLockOrderInversion || pattern.BlockedFingerprint == pattern.BlockingFingerprint ||
+			pattern.BlockedFingerprint > pattern.BlockingFingerprint {
+			continue
+		}
+		var reverse *LockPattern
+		for _, kind := range []PatternKind{PatternLongWait, PatternDeadlockCycle} {
+			if candidate, ok := m.patterns[patternKey(kind, pattern.BlockingFingerprint, pattern.BlockedFingerprint, pattern.Relation)]; ok {
+				reverse = candidate
+			}
+		}
+		// 只在其中一方本次出现时计数
+		if reverse == nil || (!pattern.LastSeen.Equal(now) && !reverse.LastSeen.Equal(now)) {
+			continue
+		}
+		key := patternKey(PatternLockOrderInversion, pattern.BlockedFingerprint, pattern.BlockingFingerprint, pattern.Relation)
+		if counted[key] {
+			continue
+		}
+		counted[key] = true
+		inversion, ok := m.patterns[key]
+		if !ok {
+			inversion = &LockPattern{
+				Kind:                PatternLockOrderInversion,
+				BlockedFingerprint:  pattern.BlockedFingerprint,
+				BlockingFingerprint: pattern.BlockingFingerprint,
+				BlockedQuery:        pattern.BlockedQuery,
+				BlockingQuery:       pattern.BlockingQuery,
+				Relation:            pattern.Relation,
+				LockMode:            pattern.LockMode,
+				FirstSeen:           now,
+				Remediation: []string{
+					"the two statements lock rows of " + relationOrUnknown(pattern.Relation) + " in opposite orders; acquire locks in a consistent order, e.g. SELECT ... ORDER BY id FOR UPDATE before updating",
+				},
+			}
+			m.patterns[key] = inversion
+			found = append(found, inversion)
+		}
+		inversion.Occurrences++
+		inversion.LastSeen = now
+	}
+	return found
+}
+
+// prune 移除窗口外的模式与告警记录，调用方需持有锁
+func (m *LockMonitor) prune(now time.Time) {
+	for key, pattern := range m.patterns {
+		if now.Sub(pattern.LastSeen) > m.config.PatternWindow {
+			delete(m.patterns, key)
+		}
+	}
+	for key, at := range m.alerted {
+		if now.Sub(at) > m.config.AlertCooldown {
+			delete(m.alerted, key)
+		}
+	}
+}
+
+// due 去重并过滤冷却期内已告警的模式，调用方需持有锁
+func (m *LockMonitor) due(patterns []*LockPattern, now time.Time) []*LockPattern {
+	var due []*LockPattern
+	for _, pattern := range patterns {
+		key := patternKey(pattern.Kind, pattern.BlockedFingerprint, pattern.BlockingFingerprint, pattern.Relation)
+		if _, ok := m.alerted[key]; ok {
+			continue
+		}
+		m.alerted[key] = now
+		copied := *pattern
+		due = append(due, &copied)
+	}
+	return due
+}
+
+func (m *LockMonitor) alert(ctx context.Context, pattern *LockPattern) {
+	level := security.LevelWarning
+	if pattern.Kind == PatternDeadlockCycle {
+		level = security.LevelCritical
+	}
+	message := fmt.Sprintf("%s on %s: %s blocked by %s", pattern.Kind, relationOrUnknown(pattern.Relation),
+		pattern.BlockedFingerprint, pattern.BlockingFingerprint)
+	log.Printf("Lock %s (max wait %s): blocked=%q blocking=%q", message, pattern.MaxWait, pattern.BlockedQuery, pattern.BlockingQuery)
+	if m.recorder == nil {
+		return
+	}
+	m.recorder.RecordEvent(ctx, security.SecurityEvent{
+		Type:    security.EventDatabaseHealth,
+		Level:   level,
+		Source:  "lock_monitor",
+		Message: message,
+		Details: map[string]interface{}{
+			"kind":                 string(pattern.Kind),
+			"relation":             pattern.Relation,
+			"lock_mode":            pattern.LockMode,
+			"max_wait_seconds":     pattern.MaxWait.Seconds(),
+			"blocked_fingerprint":  pattern.BlockedFingerprint,
+			"blocked_sql":          pattern.BlockedQuery,
+			"blocking_fingerprint": pattern.BlockingFingerprint,
+			"blocking_sql":         pattern.BlockingQuery,
+			"remediation":          pattern.Remediation,
+		},
+	})
+}
+
+// alertDeadlocks 数据库已终止死锁的事务，附带窗口内的可疑模式；具体语句见数据库日志
+func (m *LockMonitor) alertDeadlocks(ctx context.Context, n int64, suspects []LockPattern) {
+	log.Printf("Database reported %d new deadlocks (%d suspect patterns)", n, len(suspects))
+	if m.recorder == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"deadlocks": n,
+		"remediation": []string{
+			"deadlocked transactions were aborted with SQLSTATE 40P01; see the database log for the statements",
+			"run multi-row updates through RunInTxWithRetry and lock rows in a consistent order",
+		},
+	}
+	if len(suspects) > 0 {
+		details["suspects"] = suspects
+	}
+	m.recorder.RecordEvent(ctx, security.SecurityEvent{
+		Type:    security.EventDatabaseHealth,
+		Level:   security.LevelCritical,
+		Source:  "lock_monitor",
+		Message: fmt.Sprintf("%d deadlocks detected", n),
+		Details: details,
+	})
+}
+
+// remediation 按锁类型与阻塞方状态给出处理建议
+func remediation(kind PatternKind, wait *LockWait) []string {
+	var advice []string
+	if kind == PatternDeadlockCycle {
+		advice = append(advice, "transactions wait on each other; lock rows in a consistent order and keep transactions short")
+	}
+	if strings.HasPrefix(wait.BlockingState, "idle in transaction") {
+		advice = append(advice, fmt.Sprintf("the blocking session is idle in a transaction open for %s; commit earlier and keep external calls out of transactions",
+			wait.BlockingXact.Round(time.Second)))
+	} else if wait.BlockingXact > 0 && wait.BlockingXact >= 2*wait.Waiting {
+		advice = append(advice, fmt.Sprintf("the blocking transaction has been open for %s; split it into shorter transactions",
+			wait.BlockingXact.Round(time.Second)))
+	}
+	switch {
+	case wait.LockMode == "AccessExclusiveLock":
+		advice = append(advice, "an exclusive table lock blocks all access; run DDL with a short lock_timeout off-peak and use CREATE INDEX CONCURRENTLY")
+	case wait.LockType == "transactionid" || wait.LockType == "tuple":
+		if isWrite(wait.blockingNormal) || isWrite(wait.blockedNormal) {
+			advice = append(advice, "row locks are held by UPDATE/DELETE statements; make sure their WHERE clauses use an index so fewer rows are scanned and locked")
+		}
+	}
+	if len(advice) == 0 {
+		advice = append(advice, "keep transactions that touch "+relationOrUnknown(wait.Relation)+" short")
+	}
+	return advice
+}
+
+// findCycles 等待关系中的环，每个环返回组成它的等待
+func findCycles(waits []LockWait) [][]*LockWait {
+	edges := make(map[int][]*LockWait)
+	for i := range waits {
+		edges[waits[i].BlockedPID] = append(edges[waits[i].BlockedPID], &waits[i])
+	}
+	pids := make([]int, 0, len(edges))
+	for pid := range edges {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[int]int)
+	var cycles [][]*LockWait
+	var path []*LockWait
+	var visit func(pid int)
+	visit = func(pid int) {
+		state[pid] = visiting
+		for _, wait := range edges[pid] {
+			path = append(path, wait)
+			switch state[wait.BlockingPID] {
+			case visiting:
+				// 回溯到环的起点
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i].BlockedPID == wait.BlockingPID {
+						cycles = append(cycles, append([]*LockWait(nil), path[i:]...))
+						break
+					}
+				}
+			case unvisited:
+				visit(wait.BlockingPID)
+			}
+			path = path[:len(path)-1]
+		}
+		state[pid] = done
+	}
+	for _, pid := range pids {
+		if state[pid] == unvisited {
+			visit(pid)
+		}
+	}
+	return cycles
+}
+
+func patternKey(kind PatternKind, blocked, blocking, relation string) string {
+	return string(kind) + ":" + blocked + ":" + blocking + ":" + relation
+}
+
+func sortPatterns(patterns []LockPattern) {
+	rank := map[PatternKind]int{PatternDeadlockCycle: 0, PatternLockOrderInversion: 1, PatternLongWait: 2}
+	sort.Slice(patterns, func(i, j int) bool {
+		if rank[patterns[i].Kind] != rank[patterns[j].Kind] {
+			return rank[patterns[i].Kind] < rank[patterns[j].Kind]
+		}
+		return patterns[i].Occurrences > patterns[j].Occurrences
+	})
+}
+
+func isWrite(normalized string) bool {
+	return strings.HasPrefix(normalized, "update") || strings.HasPrefix(normalized, "delete") ||
+		strings.Contains(normalized, "for update") || strings.Contains(normalized, "for no key update")
+}
+
+func relationOrUnknown(relation string) string {
+	if relation == "" {
+		return "an unknown relation"
+	}
+	return relation
+}
@@ -0,0 +1,89 @@
+package dbadmin
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// lockWaitRow 锁等待查询的行
+type lockWaitRow struct {
+	BlockedPID      int     `db:"blocked_pid"`
+	BlockedQuery    string  `db:"blocked_query"`
+	WaitingSeconds  float64 `db:"waiting_seconds"`
+	BlockingPID     int     `db:"blocking_pid"`
+	BlockingQuery   string  `db:"blocking_query"`
+	BlockingState   string  `db:"blocking_state"`
+	BlockingSeconds float64 `db:"blocking_xact_seconds"`
+	Relation        string  `db:"relation"`
+	LockType        string  `db:"lock_type"`
+	LockMode        string  `db:"lock_mode"`
+}
+
+// SQLLockSource 基于 pg_stat_activity、pg_locks 与 pg_stat_database 的锁统计，只统计当前数据库
+type SQLLockSource struct {
+	db *database.DB
+}
+
+var _ LockSource = (*SQLLockSource)(nil)
+
+// NewSQLLockSource 创建锁统计
+func NewSQLLockSource(db *database.DB) *SQLLockSource {
+	return &SQLLockSource{db: db}
+}
+
+// Waits 被阻塞的会话与阻塞方，pg_blocking_pids 同时包含持锁方与排在前面的等待方
+func (s *SQLLockSource) Waits(ctx context.Context) ([]LockWait, error) {
+	query := `
+		SELECT blocked.pid AS blocked_pid, COALESCE(blocked.query, '') AS blocked_query,
+			COALESCE(EXTRACT(EPOCH FROM now() - blocked.query_start), 0)::float8 AS waiting_seconds,
+			blocking.pid AS blocking_pid, COALESCE(blocking.query, '') AS blocking_query,
+			COALESCE(blocking.state, '') AS blocking_state,
+			COALESCE(EXTRACT(EPOCH FROM now() - blocking.xact_start), 0)::float8 AS blocking_xact_seconds,
+			COALESCE(rel.relname, '') AS relation, COALESCE(waiting.locktype, '') AS lock_type,
+			COALESCE(waiting.mode, '') AS lock_mode
+		FROM pg_stat_activity blocked
+		CROSS JOIN LATERAL unnest(pg_blocking_pids(blocked.pid)) AS blocker(pid)
+		JOIN pg_stat_activity blocking ON blocking.pid = blocker.pid
+		LEFT JOIN LATERAL (
+			SELECT l.locktype, l.mode, l.relation FROM pg_locks l
+			WHERE l.pid = blocked.pid AND NOT l.granted
+			LIMIT 1
+		) waiting ON TRUE
+		LEFT JOIN pg_class rel ON rel.oid = waiting.relation
+		WHERE blocked.datname = current_database() AND blocked.wait_event_type = 'Lock'
+		ORDER BY waiting_seconds DESC
+		LIMIT 200`
+	var rows []lockWaitRow
+	if err := s.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, fmt.Errorf("failed to sample lock waits: %w", err)
+	}
+
+	waits := make([]LockWait, len(rows))
+	for i, row := range rows {
+		waits[i] = LockWait{
+			BlockedPID:    row.BlockedPID,
+			BlockedQuery:  row.BlockedQuery,
+			Waiting:       time.Duration(row.WaitingSeconds * float64(time.Second)),
+			BlockingPID:   row.BlockingPID,
+			BlockingQuery: row.BlockingQuery,
+			BlockingState: row.BlockingState,
+			BlockingXact:  time.Duration(row.BlockingSeconds * float64(time.Second)),
+			Relation:      row.Relation,
+			LockType:      row.LockType,
+			LockMode:      row.LockMode,
+		}
+	}
+	return waits, nil
+}
+
+// Deadlocks 当前数据库累计的死锁次数，统计重置后会变小
+func (s *SQLLockSource) Deadlocks(ctx context.Context) (int64, error) {
+	var deadlocks int64
+	if err := s.db.GetContext(ctx, &deadlocks,
+		`SELECT COALESCE(deadlocks, 0) FROM pg_stat_database WHERE datname = current_database()`); err != nil {
+		return 0, fmt.Errorf("failed to read deadlock count: %w", err)
+	}
+	return deadlocks, nil
+}
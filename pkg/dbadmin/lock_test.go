@@ -0,0 +1,131 @@
+package dbadmin
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLockSource 返回预设的采样
+type fakeLockSource struct {
+	waits     []LockWait
+	deadlocks int64
+}
+
+func (s *fakeLockSource) Waits(ctx context.Context) ([]LockWait, error) {
+	return append([]LockWait(nil), s.waits...), nil
+}
+
+func (s *fakeLockSource) Deadlocks(ctx context.Context) (int64, error) {
+	return s.deadlocks, nil
+}
+
+const (
+	debitSQL  = "UPDATE accounts SET balance = balance - 10 WHERE id = 'a'"
+	creditSQL = "UPDATE accounts SET balance = balance + 10 WHERE id = 'b'"
+)
+
+// TestLockMonitor_LongWait 长时间等待按指纹聚合，事件带阻塞双方的 SQL 与建议；冷却期内不重复告警
+func TestLockMonitor_LongWait(t *testing.T) {
+	clock := fakes.NewClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	source := &fakeLockSource{waits: []LockWait{{
+		BlockedPID: 11, BlockedQuery: "UPDATE users SET nickname = $1 WHERE id = $2", Waiting: 8 * time.Second,
+		BlockingPID: 22, BlockingQuery: "UPDATE users SET login_count = login_count + 1 WHERE mobile = '138'",
+		BlockingState: "idle in transaction", BlockingXact: 40 * time.Second,
+		Relation: "users", LockType: "transactionid", LockMode: "ShareLock",
+	}}}
+	recorder := &captureRecorder{}
+	monitor := NewLockMonitor(source, recorder, nil)
+	monitor.SetClock(clock)
+
+	require.NoError(t, monitor.Sample(context.Background()))
+	require.Len(t, recorder.events, 1)
+	event := recorder.events[0]
+	assert.Equal(t, security.EventDatabaseHealth, event.Type)
+	assert.Equal(t, security.LevelWarning, event.Level)
+	assert.Equal(t, "update users set nickname = ? where id = ?", event.Details["blocked_sql"])
+	assert.Equal(t, "update users set login_count = login_count + ? where mobile = ?", event.Details["blocking_sql"])
+	remediation := event.Details["remediation"].([]string)
+	require.Len(t, remediation, 2)
+	assert.Contains(t, remediation[0], "idle in a transaction")
+	assert.Contains(t, remediation[1], "use an index")
+
+	// 同一语句的不同参数归入同一模式
+	source.waits[0].BlockingQuery = "UPDATE users SET login_count = login_count + 1 WHERE mobile = '139'"
+	clock.Advance(10 * time.Second)
+	require.NoError(t, monitor.Sample(context.Background()))
+	assert.Len(t, recorder.events, 1)
+	patterns := monitor.Patterns()
+	require.Len(t, patterns, 1)
+	assert.Equal(t, int64(2), patterns[0].Occurrences)
+	assert.Len(t, monitor.Waits(), 1)
+
+	clock.Advance(16 * time.Minute)
+	require.NoError(t, monitor.Sample(context.Background()))
+	assert.Len(t, recorder.events, 2)
+}
+
+// TestLockMonitor_DeadlockPatterns 等待成环与互相等待过的语句对记为死锁模式，数据库死锁计数增加时附带可疑模式告警
+func TestLockMonitor_DeadlockPatterns(t *testing.T) {
+	clock := fakes.NewClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	source := &fakeLockSource{deadlocks: 3, waits: []LockWait{
+		{BlockedPID: 1, BlockedQuery: debitSQL, BlockingPID: 2, BlockingQuery: creditSQL, Waiting: 6 * time.Second,
+			Relation: "accounts", LockType: "transactionid", LockMode: "ShareLock"},
+	}}
+	recorder := &captureRecorder{}
+	monitor := NewLockMonitor(source, recorder, nil)
+	monitor.SetClock(clock)
+	ctx := context.Background()
+
+	// 首次采样只记录基线死锁次数
+	require.NoError(t, monitor.Sample(ctx))
+	require.Len(t, recorder.events, 1)
+
+	// 反方向等待：两条语句按相反顺序加锁，且本次采样成环
+	clock.Advance(10 * time.Second)
+	source.waits = []LockWait{
+		{BlockedPID: 2, BlockedQuery: creditSQL, BlockingPID: 1, BlockingQuery: debitSQL, Waiting: 7 * time.Second,
+			Relation: "accounts", LockType: "transactionid", LockMode: "ShareLock"},
+		{BlockedPID: 1, BlockedQuery: debitSQL, BlockingPID: 2, BlockingQuery: creditSQL, Waiting: 9 * time.Second,
+			Relation: "accounts", LockType: "transactionid", LockMode: "ShareLock"},
+	}
+	source.deadlocks = 4
+	require.NoError(t, monitor.Sample(ctx))
+
+	kinds := make(map[PatternKind]int)
+	for _, pattern := range monitor.Patterns() {
+		kinds[pattern.Kind]++
+	}
+	assert.Equal(t, map[PatternKind]int{PatternLongWait: 2, PatternDeadlockCycle: 2, PatternLockOrderInversion: 1}, kinds)
+	assert.Equal(t, PatternDeadlockCycle, monitor.Patterns()[0].Kind)
+
+	var deadlockEvent *security.SecurityEvent
+	levels := make(map[security.SecurityEventLevel]int)
+	for i := range recorder.events {
+		levels[recorder.events[i].Level]++
+		if recorder.events[i].Details["deadlocks"] != nil {
+			deadlockEvent = &recorder.events[i]
+		}
+	}
+	// 两条长时间等待、一条顺序相反为 warning，两条成环与一次死锁为 critical
+	assert.Equal(t, 3, levels[security.LevelWarning])
+	assert.Equal(t, 3, levels[security.LevelCritical])
+	require.NotNil(t, deadlockEvent)
+	assert.Equal(t, int64(1), deadlockEvent.Details["deadlocks"])
+	assert.Len(t, deadlockEvent.Details["suspects"], 3)
+}
+
+// TestFingerprint 字面量、参数与 IN 列表归一后指纹相同
+func TestFingerprint(t *testing.T) {
+	a, normalized := fingerprint("SELECT * FROM coupons WHERE id IN (1, 2, 3) AND name = 'x''y'  ;")
+	b, _ := fingerprint("select * from coupons where id in ($1,$2) and name = $3")
+	assert.Equal(t, a, b)
+	assert.Equal(t, "select * from coupons where id in (...) and name = ?", normalized)
+
+	assert.Equal(t, "用户...", truncate("用户资料", 8))
+}
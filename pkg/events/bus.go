@@ -0,0 +1,416 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+	"user_crud_jwt/pkg/lifecycle"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// Event 类型化事件
+type Event[T any] struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Key       string    `json:"key"` // 相同 key 的事件对异步订阅者按发布顺序投递
+	Payload   T         `json:"payload"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Handler 事件处理函数
+type Handler[T any] func(ctx context.Context, event Event[T]) error
+
+// DeadLetter 重试耗尽的事件
+type DeadLetter[T any] struct {
+	Subscriber string    `json:"subscriber"`
+	Event      Event[T]  `json:"event"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// Config 事件总线配置
+type Config struct {
+	QueueSize      int           `json:"queue_size"`      // 每个异步分片的队列长度
+	Shards         int           `json:"shards"`          // 异步订阅者的分片（worker）数
+	HandlerTimeout time.Duration `json:"handler_timeout"` // 单次处理超时
+	MaxRetries     int           `json:"max_retries"`
+	RetryDelay     time.Duration `json:"retry_delay"` // 按重试次数线性增长
+	MaxDeadLetters int           `json:"max_dead_letters"`
+	EnableMetrics  bool          `json:"enable_metrics"`
+}
+
+// DefaultConfig 默认事件总线配置
+func DefaultConfig() *Config {
+	return &Config{
+		QueueSize:      1000,
+		Shards:         4,
+		HandlerTimeout: 5 * time.Second,
+		MaxRetries:     3,
+		RetryDelay:     100 * time.Millisecond,
+		MaxDeadLetters: 1000,
+		EnableMetrics:  true,
+	}
+}
+
+// SubscribeOption 订阅选项
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	async      bool
+	maxRetries int
+	retryDelay time.Duration
+	timeout    time.Duration
+	filter     func(topic string) bool
+}
+
+// Async 异步投递，发布方不等待处理结果
+func Async() SubscribeOption {
+	return func(o *subscribeOptions) { o.async = true }
+}
+
+// WithRetry 覆盖总线的重试配置
+func WithRetry(maxRetries int, delay time.Duration) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.maxRetries = maxRetries
+		o.retryDelay = delay
+	}
+}
+
+// WithTimeout 覆盖总线的处理超时
+func WithTimeout(timeout time.Duration) SubscribeOption {
+	return func(o *subscribeOptions) { o.timeout = timeout }
+}
+
+// WithTopics 只接收指定主题的事件
+func WithTopics(topics ...string) SubscribeOption {
+	set := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		set[topic] = true
+	}
+	return func(o *subscribeOptions) {
+		o.filter = func(topic string) bool { return set[topic] }
+	}
+}
+
+// subscription 订阅者
+type subscription[T any] struct {
+	name    string
+	handler Handler[T]
+	opts    subscribeOptions
+	shards  []chan Event[T]
+}
+
+// Bus 类型化事件总线，支持同步/异步订阅、失败重试、死信与按 key 有序
+type Bus[T any] struct {
+	name             string
+	config           *Config
+	subscribers      []*subscription[T]
+	deadLetters      []DeadLetter[T]
+	onDeadLetter     func(DeadLetter[T])
+	mu               sync.RWMutex
+	wg               sync.WaitGroup
+	closed           atomic.Bool
+	eventSeq         atomic.Uint64
+	dropped          atomic.Uint64
+	metricsCollector *metrics.MetricsCollector
+	lifecycle        *lifecycle.Component
+}
+
+// ErrBusClosed 总线已关闭
+var ErrBusClosed = errors.New("event bus is closed")
+
+// NewBus 创建事件总线，name 用于指标与日志
+func NewBus[T any](name string, config *Config) *Bus[T] {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Shards <= 0 {
+		config.Shards = 1
+	}
+	return &Bus[T]{
+		name:             name,
+		config:           config,
+		metricsCollector: metrics.GetGlobalCollector(),
+		lifecycle:        lifecycle.Register("events:" + name),
+	}
+}
+
+// Subscribe 注册订阅者，name 在同一总线内应唯一
+func (b *Bus[T]) Subscribe(name string, handler Handler[T], options ...SubscribeOption) error {
+	if b.closed.Load() {
+		return ErrBusClosed
+	}
+
+	opts := subscribeOptions{
+		maxRetries: b.config.MaxRetries,
+		retryDelay: b.config.RetryDelay,
+		timeout:    b.config.HandlerTimeout,
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.subscribers {
+		if s.name == name {
+			return fmt.Errorf("subscriber %s already registered on bus %s", name, b.name)
+		}
+	}
+
+	sub := &subscription[T]{name: name, handler: handler, opts: opts}
+	if opts.async {
+		sub.shards = make([]chan Event[T], b.config.Shards)
+		for i := range sub.shards {
+			sub.shards[i] = make(chan Event[T], b.config.QueueSize)
+			b.wg.Add(1)
+			shard := sub.shards[i]
+			b.lifecycle.Go(fmt.Sprintf("%s#%d", name, i), func() { b.runShard(sub, shard) })
+		}
+	}
+	b.subscribers = append(b.subscribers, sub)
+	return nil
+}
+
+// OnDeadLetter 设置死信回调，例如写入发件箱或告警
+func (b *Bus[T]) OnDeadLetter(fn func(DeadLetter[T])) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onDeadLetter = fn
+}
+
+// Publish 发布事件。同步订阅者在当前 goroutine 中处理，返回其错误；
+// 异步订阅者按 key 分片入队，队列满时丢弃并计入指标。
+func (b *Bus[T]) Publish(ctx context.Context, topic, key string, payload T) error {
+	if b.closed.Load() {
+		return ErrBusClosed
+	}
+
+	event := Event[T]{
+		ID:        fmt.Sprintf("%s_%d_%d", b.name, time.Now().UnixNano(), b.eventSeq.Add(1)),
+		Topic:     topic,
+		Key:       key,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subscribers {
+		if sub.opts.filter != nil && !sub.opts.filter(topic) {
+			continue
+		}
+
+		if !sub.opts.async {
+			if err := b.deliver(ctx, sub, event); err != nil {
+				errs = append(errs, fmt.Errorf("subscriber %s: %w", sub.name, err))
+			}
+			continue
+		}
+
+		if !b.enqueue(sub, event) {
+			log.Printf("Event bus %s queue for subscriber %s is full, dropping event: %s", b.name, sub.name, event.ID)
+			b.dropped.Add(1)
+			b.recordMetrics("dropped", 0, false)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// enqueue 非阻塞入队，持有读锁避免与 Close 关闭队列竞争
+func (b *Bus[T]) enqueue(sub *subscription[T], event Event[T]) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed.Load() {
+		return false
+	}
+	select {
+	case sub.shards[shardFor(event.Key, len(sub.shards))] <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// runShard 顺序处理单个分片，保证同一 key 的事件有序
+func (b *Bus[T]) runShard(sub *subscription[T], queue chan Event[T]) {
+	defer b.wg.Done()
+	for event := range queue {
+		// 异步处理与发布方的生命周期无关
+		b.deliver(context.Background(), sub, event)
+	}
+}
+
+// deliver 投递事件，失败后重试，耗尽后进入死信
+func (b *Bus[T]) deliver(ctx context.Context, sub *subscription[T], event Event[T]) error {
+	start := time.Now()
+	attempts := 0
+	err := retry(ctx, sub.opts.maxRetries, sub.opts.retryDelay, func() error {
+		attempts++
+		return b.invoke(ctx, sub, event)
+	})
+	if err == nil {
+		b.recordMetrics("handle", time.Since(start), true)
+		return nil
+	}
+
+	log.Printf("Event bus %s subscriber %s failed to handle event %s after %d attempts: %v", b.name, sub.name, event.ID, attempts, err)
+	b.recordMetrics("dead_letter", time.Since(start), false)
+	b.addDeadLetter(DeadLetter[T]{
+		Subscriber: sub.name,
+		Event:      event,
+		Error:      err.Error(),
+		Attempts:   attempts,
+		FailedAt:   time.Now(),
+	})
+	return err
+}
+
+// retry 执行 fn，失败后按线性退避重试 maxRetries 次
+func retry(ctx context.Context, maxRetries int, delay time.Duration, fn func() error) error {
+	err := fn()
+	for i := 1; i <= maxRetries && err != nil; i++ {
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(delay * time.Duration(i)):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// invoke 调用处理函数，panic 视为处理失败
+func (b *Bus[T]) invoke(ctx context.Context, sub *subscription[T], event Event[T]) (err error) {
+	if sub.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sub.opts.timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return sub.handler(ctx, event)
+}
+
+// addDeadLetter 记录死信，超出上限时淘汰最旧的
+func (b *Bus[T]) addDeadLetter(dl DeadLetter[T]) {
+	b.mu.Lock()
+	b.deadLetters = append(b.deadLetters, dl)
+	if over := len(b.deadLetters) - b.config.MaxDeadLetters; over > 0 {
+		b.deadLetters = b.deadLetters[over:]
+	}
+	onDeadLetter := b.onDeadLetter
+	b.mu.Unlock()
+
+	if onDeadLetter != nil {
+		onDeadLetter(dl)
+	}
+}
+
+// DeadLetters 获取死信
+func (b *Bus[T]) DeadLetters() []DeadLetter[T] {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	deadLetters := make([]DeadLetter[T], len(b.deadLetters))
+	copy(deadLetters, b.deadLetters)
+	return deadLetters
+}
+
+// Redrive 将死信重新投递给原订阅者，返回成功数
+func (b *Bus[T]) Redrive(ctx context.Context) int {
+	b.mu.Lock()
+	deadLetters := b.deadLetters
+	b.deadLetters = nil
+	subscribers := make(map[string]*subscription[T], len(b.subscribers))
+	for _, sub := range b.subscribers {
+		subscribers[sub.name] = sub
+	}
+	b.mu.Unlock()
+
+	redriven := 0
+	for _, dl := range deadLetters {
+		sub, exists := subscribers[dl.Subscriber]
+		if !exists {
+			continue
+		}
+		if err := b.deliver(ctx, sub, dl.Event); err == nil {
+			redriven++
+		}
+	}
+	return redriven
+}
+
+// Pending 异步队列中待处理的事件数
+func (b *Bus[T]) Pending() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	pending := 0
+	for _, sub := range b.subscribers {
+		for _, shard := range sub.shards {
+			pending += len(shard)
+		}
+	}
+	return pending
+}
+
+// Dropped 因异步队列已满（或总线关闭）而丢弃的投递数，每个订阅者分别计数
+func (b *Bus[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close 停止接收事件，等待异步队列处理完毕
+func (b *Bus[T]) Close() {
+	if !b.closed.CompareAndSwap(false, true) {
+		return
+	}
+
+	b.mu.Lock()
+	for _, sub := range b.subscribers {
+		for _, shard := range sub.shards {
+			close(shard)
+		}
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	b.lifecycle.Closed()
+}
+
+// recordMetrics 记录指标
+func (b *Bus[T]) recordMetrics(operation string, duration time.Duration, success bool) {
+	if !b.config.EnableMetrics {
+		return
+	}
+
+	b.metricsCollector.RecordDBQuery("event_bus_"+b.name, operation, duration, success)
+	if !success {
+		b.metricsCollector.RecordDBError("event_bus_error", operation)
+	}
+}
+
+// shardFor 按 key 选择分片，空 key 固定使用第一个分片
+func shardFor(key string, shards int) int {
+	if key == "" || shards <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
@@ -0,0 +1,231 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() *Config {
+	config := DefaultConfig()
+	config.RetryDelay = time.Millisecond
+	config.EnableMetrics = false
+	return config
+}
+
+// TestBus_KeyOrdering 异步订阅者按 key 分片，同一 key 的事件按发布顺序处理
+func TestBus_KeyOrdering(t *testing.T) {
+	bus := NewBus[int]("ordering", testConfig())
+	var (
+		mu   sync.Mutex
+		seen = make(map[string][]int)
+	)
+	require.NoError(t, bus.Subscribe("recorder", func(ctx context.Context, event Event[int]) error {
+		if event.Payload%7 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		mu.Lock()
+		seen[event.Key] = append(seen[event.Key], event.Payload)
+		mu.Unlock()
+		return nil
+	}, Async()))
+
+	for i := 0; i < 200; i++ {
+		require.NoError(t, bus.Publish(context.Background(), "t", fmt.Sprintf("k%d", i%5), i))
+	}
+	bus.Close()
+
+	require.Len(t, seen, 5)
+	for key, payloads := range seen {
+		assert.Len(t, payloads, 40, key)
+		assert.IsIncreasing(t, payloads, key)
+	}
+}
+
+// TestBus_DropOnFull 队列已满时丢弃事件并计数，不阻塞发布方；已入队的事件仍被处理
+func TestBus_DropOnFull(t *testing.T) {
+	config := testConfig()
+	config.Shards = 1
+	config.QueueSize = 1
+	bus := NewBus[int]("drop", config)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var (
+		mu      sync.Mutex
+		handled []int
+	)
+	require.NoError(t, bus.Subscribe("slow", func(ctx context.Context, event Event[int]) error {
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		handled = append(handled, event.Payload)
+		mu.Unlock()
+		return nil
+	}, Async(), WithTimeout(0)))
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, "t", "", 1))
+	<-started
+	require.NoError(t, bus.Publish(ctx, "t", "", 2))
+	assert.Equal(t, 1, bus.Pending())
+	require.NoError(t, bus.Publish(ctx, "t", "", 3))
+	require.NoError(t, bus.Publish(ctx, "t", "", 4))
+	assert.Equal(t, uint64(2), bus.Dropped())
+
+	close(release)
+	bus.Close()
+	assert.Equal(t, []int{1, 2}, handled)
+	assert.Empty(t, bus.DeadLetters())
+}
+
+// TestBus_RetryThenDeadLetter 处理失败按配置重试，耗尽后进入死信并回调；panic 视为失败；
+// 同步订阅者的错误返回给发布方
+func TestBus_RetryThenDeadLetter(t *testing.T) {
+	config := testConfig()
+	config.MaxDeadLetters = 2
+	bus := NewBus[string]("retry", config)
+
+	attempts := 0
+	require.NoError(t, bus.Subscribe("flaky", func(ctx context.Context, event Event[string]) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	}, WithTopics("flaky")))
+	require.NoError(t, bus.Subscribe("broken", func(ctx context.Context, event Event[string]) error {
+		panic("boom")
+	}, WithRetry(1, time.Millisecond), WithTopics("broken")))
+
+	var notified []DeadLetter[string]
+	bus.OnDeadLetter(func(dl DeadLetter[string]) { notified = append(notified, dl) })
+
+	ctx := context.Background()
+	require.NoError(t, bus.Publish(ctx, "flaky", "", "a"))
+	assert.Equal(t, 3, attempts, "the third attempt succeeds within MaxRetries")
+	assert.Empty(t, bus.DeadLetters())
+
+	err := bus.Publish(ctx, "broken", "", "b")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "subscriber broken")
+	assert.Contains(t, err.Error(), "handler panic: boom")
+
+	deadLetters := bus.DeadLetters()
+	require.Len(t, deadLetters, 1)
+	assert.Equal(t, "broken", deadLetters[0].Subscriber)
+	assert.Equal(t, "b", deadLetters[0].Event.Payload)
+	assert.Equal(t, 2, deadLetters[0].Attempts)
+	assert.Equal(t, deadLetters, notified)
+
+	// 超出上限时淘汰最旧的死信
+	require.Error(t, bus.Publish(ctx, "broken", "", "c"))
+	require.Error(t, bus.Publish(ctx, "broken", "", "d"))
+	deadLetters = bus.DeadLetters()
+	require.Len(t, deadLetters, 2)
+	assert.Equal(t, "c", deadLetters[0].Event.Payload)
+	assert.Len(t, notified, 3)
+
+	// 发布方取消时停止重试
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = bus.Publish(cancelled, "broken", "", "e")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// TestBus_Redrive 死信重新投递给原订阅者，成功的移出死信，再次失败的重新进入死信
+func TestBus_Redrive(t *testing.T) {
+	config := testConfig()
+	config.MaxRetries = 0
+	bus := NewBus[string]("redrive", config)
+
+	var (
+		healthy  bool
+		received []string
+	)
+	require.NoError(t, bus.Subscribe("sink", func(ctx context.Context, event Event[string]) error {
+		if !healthy && event.Payload != "ok" {
+			return errors.New("sink unavailable")
+		}
+		received = append(received, event.Payload)
+		return nil
+	}))
+
+	ctx := context.Background()
+	require.Error(t, bus.Publish(ctx, "t", "", "a"))
+	require.Error(t, bus.Publish(ctx, "t", "", "b"))
+	require.Len(t, bus.DeadLetters(), 2)
+
+	assert.Zero(t, bus.Redrive(ctx))
+	require.Len(t, bus.DeadLetters(), 2, "failed redrives go back to the dead letters")
+
+	healthy = true
+	assert.Equal(t, 2, bus.Redrive(ctx))
+	assert.Empty(t, bus.DeadLetters())
+	assert.Equal(t, []string{"a", "b"}, received)
+	assert.Zero(t, bus.Redrive(ctx))
+}
+
+// TestBus_CloseDrains 关闭时等待已入队事件处理完毕；与关闭并发的发布返回 ErrBusClosed 或计为丢弃，不会向已关闭的队列发送
+func TestBus_CloseDrains(t *testing.T) {
+	config := testConfig()
+	config.QueueSize = 10000
+	bus := NewBus[int]("close", config)
+
+	var (
+		mu      sync.Mutex
+		handled int
+	)
+	require.NoError(t, bus.Subscribe("counter", func(ctx context.Context, event Event[int]) error {
+		mu.Lock()
+		handled++
+		mu.Unlock()
+		return nil
+	}, Async()))
+
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, bus.Publish(ctx, "t", fmt.Sprint(i), i))
+	}
+
+	var (
+		wg        sync.WaitGroup
+		accepted  sync.Map
+		publishes = 8
+	)
+	for p := 0; p < publishes; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			count := 0
+			for i := 0; ; i++ {
+				if err := bus.Publish(ctx, "t", fmt.Sprint(i), i); err != nil {
+					assert.ErrorIs(t, err, ErrBusClosed)
+					break
+				}
+				count++
+			}
+			accepted.Store(p, count)
+		}(p)
+	}
+	time.Sleep(5 * time.Millisecond)
+	bus.Close()
+	wg.Wait()
+
+	total := 100
+	accepted.Range(func(_, count any) bool {
+		total += count.(int)
+		return true
+	})
+	assert.Equal(t, total, handled+int(bus.Dropped()), "every accepted event is either handled or counted as dropped")
+	assert.Zero(t, bus.Pending())
+	assert.ErrorIs(t, bus.Publish(ctx, "t", "", 0), ErrBusClosed)
+	assert.ErrorIs(t, bus.Subscribe("late", func(ctx context.Context, event Event[int]) error { return nil }), ErrBusClosed)
+	bus.Close()
+}
@@ -0,0 +1,127 @@
+package features
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// ExperimentStatus 实验状态
+type ExperimentStatus string
+
+const (
+	ExperimentDraft   ExperimentStatus = "draft"
+	ExperimentRunning ExperimentStatus = "running"
+	ExperimentStopped ExperimentStatus = "stopped"
+)
+
+// ExposureTopic 曝光事件的主题
+const ExposureTopic = "experiment.exposure"
+
+var (
+	// ErrExperimentNotFound 实验不存在
+	ErrExperimentNotFound = errors.New("experiment not found")
+	// ErrExperimentRunning 运行中的实验不能修改分组，否则已分配的用户会被重新分组
+	ErrExperimentRunning = errors.New("experiment is running")
+)
+
+// Variant 实验分组
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"` // 流量权重，各组按权重占比分配
+}
+
+// Experiment A/B 实验定义
+type Experiment struct {
+	Key         string           `json:"key"`
+	Description string           `json:"description"`
+	Flag        string           `json:"flag,omitempty"` // 参与实验的人群由该功能开关圈定，为空时所有登录用户参与
+	Status      ExperimentStatus `json:"status"`
+	Variants    []Variant        `json:"variants"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	StoppedAt   *time.Time       `json:"stopped_at,omitempty"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// Validate 校验实验定义
+func (e *Experiment) Validate() error {
+	if !validKeyPattern.MatchString(e.Key) {
+		return fmt.Errorf("invalid experiment key: %q", e.Key)
+	}
+	if e.Flag != "" && !validKeyPattern.MatchString(e.Flag) {
+		return fmt.Errorf("invalid feature flag key: %q", e.Flag)
+	}
+	if len(e.Variants) < 2 || len(e.Variants) > 10 {
+		return fmt.Errorf("experiment requires 2 to 10 variants")
+	}
+	seen := make(map[string]bool, len(e.Variants))
+	for _, variant := range e.Variants {
+		if !validKeyPattern.MatchString(variant.Name) {
+			return fmt.Errorf("invalid variant name: %q", variant.Name)
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("duplicate variant: %s", variant.Name)
+		}
+		seen[variant.Name] = true
+		if variant.Weight <= 0 || variant.Weight > 10000 {
+			return fmt.Errorf("variant weight must be between 1 and 10000")
+		}
+	}
+	return nil
+}
+
+// Assign 为用户分配分组。同一实验下同一用户的分组固定，与请求所在实例、调用次数无关
+func (e *Experiment) Assign(userID string) string {
+	total := 0
+	for _, variant := range e.Variants {
+		total += variant.Weight
+	}
+	if total == 0 || userID == "" {
+		return ""
+	}
+
+	// 盐值与功能开关的分桶区分，避免开关放量比例与实验分组相关
+	h := fnv.New32a()
+	h.Write([]byte("experiment:"))
+	h.Write([]byte(e.Key))
+	h.Write([]byte{':'})
+	h.Write([]byte(userID))
+	point := int(h.Sum32() % uint32(total))
+	for _, variant := range e.Variants {
+		if point < variant.Weight {
+			return variant.Name
+		}
+		point -= variant.Weight
+	}
+	return ""
+}
+
+// Exposure 曝光事件：用户首次看到实验分组
+type Exposure struct {
+	Experiment string    `json:"experiment"`
+	Variant    string    `json:"variant"`
+	UserID     string    `json:"user_id"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+	ExposedAt  time.Time `json:"exposed_at"`
+}
+
+// VariantResult 分组的归因结果，转化指标只统计曝光之后、归因窗口之内的行为
+type VariantResult struct {
+	Variant        string  `db:"variant" json:"variant"`
+	Users          int64   `db:"users" json:"users"`                     // 曝光用户数
+	ClaimedUsers   int64   `db:"claimed_users" json:"claimed_users"`     // 领券用户数
+	Claims         int64   `db:"claims" json:"claims"`                   // 领券次数
+	ConvertedUsers int64   `db:"converted_users" json:"converted_users"` // 支付用户数
+	Orders         int64   `db:"orders" json:"orders"`                   // 支付订单数
+	Revenue        int64   `db:"revenue" json:"revenue"`                 // 支付金额（分）
+	ClaimRate      float64 `db:"-" json:"claim_rate"`
+	ConversionRate float64 `db:"-" json:"conversion_rate"`
+}
+
+// ExperimentResults 实验归因结果
+type ExperimentResults struct {
+	Experiment string          `json:"experiment"`
+	Window     string          `json:"window"`
+	Variants   []VariantResult `json:"variants"`
+}
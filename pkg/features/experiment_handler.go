@@ -0,0 +1,151 @@
+package features
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExperimentRequest 新增或修改实验请求
+type ExperimentRequest struct {
+	Description string    `json:"description" binding:"max=500"`
+	Flag        string    `json:"flag"`
+	Variants    []Variant `json:"variants" binding:"required"`
+}
+
+// ExperimentHandler 实验接口
+type ExperimentHandler struct {
+	experiments *Experiments
+}
+
+// NewExperimentHandler 创建实验接口
+func NewExperimentHandler(experiments *Experiments) *ExperimentHandler {
+	return &ExperimentHandler{experiments: experiments}
+}
+
+// RegisterRoutes 注册用户侧路由，调用方需挂载 AuthMiddleware
+func (h *ExperimentHandler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/experiments/:key/assignment", h.GetAssignment)
+}
+
+// RegisterAdminRoutes 注册实验管理路由，调用方需挂载管理员权限校验
+func (h *ExperimentHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/experiments", h.ListExperiments)
+	group.GET("/experiments/:key", h.GetExperiment)
+	group.PUT("/experiments/:key", h.SaveExperiment)
+	group.POST("/experiments/:key/start", h.StartExperiment)
+	group.POST("/experiments/:key/stop", h.StopExperiment)
+	group.GET("/experiments/:key/results", h.GetResults)
+}
+
+// GetAssignment 当前用户在实验中的分组，首次获取时记录曝光
+func (h *ExperimentHandler) GetAssignment(c *gin.Context) {
+	variant, ok := h.experiments.Variant(c, c.Param("key"))
+	c.JSON(http.StatusOK, gin.H{
+		"experiment":    c.Param("key"),
+		"variant":       variant,
+		"in_experiment": ok,
+	})
+}
+
+// ListExperiments 全部实验
+func (h *ExperimentHandler) ListExperiments(c *gin.Context) {
+	experiments, err := h.experiments.List(c.Request.Context())
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"experiments": experiments,
+	})
+}
+
+// GetExperiment 实验定义
+func (h *ExperimentHandler) GetExperiment(c *gin.Context) {
+	experiment, err := h.experiments.Get(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondExperimentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, experiment)
+}
+
+// SaveExperiment 新增或修改实验，运行中的实验需先停止
+func (h *ExperimentHandler) SaveExperiment(c *gin.Context) {
+	var req ExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	experiment := &Experiment{
+		Key:         c.Param("key"),
+		Description: req.Description,
+		Flag:        req.Flag,
+		Variants:    req.Variants,
+	}
+	if err := experiment.Validate(); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, err.Error()))
+		return
+	}
+	if err := h.experiments.Save(c.Request.Context(), experiment); err != nil {
+		respondExperimentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, experiment)
+}
+
+// StartExperiment 开始实验
+func (h *ExperimentHandler) StartExperiment(c *gin.Context) {
+	experiment, err := h.experiments.Start(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondExperimentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, experiment)
+}
+
+// StopExperiment 停止实验
+func (h *ExperimentHandler) StopExperiment(c *gin.Context) {
+	experiment, err := h.experiments.Stop(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondExperimentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, experiment)
+}
+
+// GetResults 按分组的曝光、领券与支付归因，window_days 为归因窗口天数
+func (h *ExperimentHandler) GetResults(c *gin.Context) {
+	var window time.Duration
+	if days := c.Query("window_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "window_days must be a positive integer"))
+			return
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	}
+
+	results, err := h.experiments.Results(c.Request.Context(), c.Param("key"), window)
+	if err != nil {
+		respondExperimentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+func respondExperimentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrExperimentNotFound):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+	case errors.Is(err, ErrExperimentRunning):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, "stop the experiment before changing it"))
+	default:
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+	}
+}
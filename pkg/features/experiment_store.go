@@ -0,0 +1,180 @@
+package features
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/database"
+)
+
+// ExperimentStore 实验与曝光存储
+type ExperimentStore interface {
+	ListExperiments(ctx context.Context) ([]*Experiment, error)
+	GetExperiment(ctx context.Context, key string) (*Experiment, error)
+	SaveExperiment(ctx context.Context, experiment *Experiment) error
+	// RecordExposure 记录曝光，同一用户在同一实验中只保留首次曝光
+	RecordExposure(ctx context.Context, exposure *Exposure) error
+	// Attribution 按分组汇总曝光用户在归因窗口内的领券与支付
+	Attribution(ctx context.Context, key string, window time.Duration) ([]VariantResult, error)
+}
+
+type experimentRow struct {
+	Key         string       `db:"key"`
+	Description string       `db:"description"`
+	Flag        string       `db:"flag_key"`
+	Status      string       `db:"status"`
+	Variants    []byte       `db:"variants"`
+	StartedAt   sql.NullTime `db:"started_at"`
+	StoppedAt   sql.NullTime `db:"stopped_at"`
+	UpdatedAt   time.Time    `db:"updated_at"`
+}
+
+func (r *experimentRow) toExperiment() (*Experiment, error) {
+	experiment := &Experiment{
+		Key:         r.Key,
+		Description: r.Description,
+		Flag:        r.Flag,
+		Status:      ExperimentStatus(r.Status),
+		UpdatedAt:   r.UpdatedAt,
+	}
+	if err := json.Unmarshal(r.Variants, &experiment.Variants); err != nil {
+		return nil, fmt.Errorf("failed to decode experiment variants: %w", err)
+	}
+	if r.StartedAt.Valid {
+		experiment.StartedAt = &r.StartedAt.Time
+	}
+	if r.StoppedAt.Valid {
+		experiment.StoppedAt = &r.StoppedAt.Time
+	}
+	return experiment, nil
+}
+
+const experimentColumns = `key, description, flag_key, status, variants, started_at, stopped_at, updated_at`
+
+// SQLExperimentStore 基于 experiments、experiment_exposures 表的实验存储
+type SQLExperimentStore struct {
+	db *database.DB
+}
+
+// NewSQLExperimentStore 创建实验存储
+func NewSQLExperimentStore(db *database.DB) *SQLExperimentStore {
+	return &SQLExperimentStore{db: db}
+}
+
+// ListExperiments 全部实验，按 key 排序
+func (s *SQLExperimentStore) ListExperiments(ctx context.Context) ([]*Experiment, error) {
+	var rows []experimentRow
+	if err := s.db.SelectContext(ctx, &rows, `SELECT `+experimentColumns+` FROM experiments ORDER BY key`); err != nil {
+		return nil, fmt.Errorf("failed to list experiments: %w", err)
+	}
+
+	experiments := make([]*Experiment, 0, len(rows))
+	for i := range rows {
+		experiment, err := rows[i].toExperiment()
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, experiment)
+	}
+	return experiments, nil
+}
+
+// GetExperiment 获取实验，不存在时返回 ErrExperimentNotFound
+func (s *SQLExperimentStore) GetExperiment(ctx context.Context, key string) (*Experiment, error) {
+	var row experimentRow
+	err := s.db.GetContext(ctx, &row, `SELECT `+experimentColumns+` FROM experiments WHERE key = $1`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment: %w", err)
+	}
+	return row.toExperiment()
+}
+
+// SaveExperiment 新增或覆盖实验
+func (s *SQLExperimentStore) SaveExperiment(ctx context.Context, experiment *Experiment) error {
+	variants, err := json.Marshal(experiment.Variants)
+	if err != nil {
+		return fmt.Errorf("failed to encode experiment variants: %w", err)
+	}
+
+	err = s.db.GetContext(ctx, &experiment.UpdatedAt, `
+		INSERT INTO experiments (key, description, flag_key, status, variants, started_at, stopped_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description,
+			flag_key = EXCLUDED.flag_key,
+			status = EXCLUDED.status,
+			variants = EXCLUDED.variants,
+			started_at = EXCLUDED.started_at,
+			stopped_at = EXCLUDED.stopped_at,
+			updated_at = NOW()
+		RETURNING updated_at`,
+		experiment.Key, experiment.Description, experiment.Flag, string(experiment.Status), variants,
+		experiment.StartedAt, experiment.StoppedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save experiment: %w", err)
+	}
+	return nil
+}
+
+// RecordExposure 记录首次曝光
+func (s *SQLExperimentStore) RecordExposure(ctx context.Context, exposure *Exposure) error {
+	// 曝光经事件总线异步写入，ctx 中没有请求的租户，以事件中记录的为准
+	tenantID := exposure.TenantID
+	if tenantID == "" {
+		tenantID = ctxutil.DefaultTenantID
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO experiment_exposures (experiment_key, user_id, variant, tenant_id, exposed_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (experiment_key, user_id) DO NOTHING`,
+		exposure.Experiment, exposure.UserID, exposure.Variant, tenantID, exposure.ExposedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record experiment exposure: %w", err)
+	}
+	return nil
+}
+
+// Attribution 以首次曝光时间为起点，关联窗口内的领券记录与已支付订单
+func (s *SQLExperimentStore) Attribution(ctx context.Context, key string, window time.Duration) ([]VariantResult, error) {
+	conditions, args := database.TenantScope(ctx, "e", []string{"e.experiment_key = $1"}, []interface{}{key, window.Seconds()})
+
+	var results []VariantResult
+	err := s.db.SelectContext(ctx, &results, `
+		SELECT e.variant,
+			COUNT(*) AS users,
+			COUNT(*) FILTER (WHERE c.claims > 0) AS claimed_users,
+			COALESCE(SUM(c.claims), 0) AS claims,
+			COUNT(*) FILTER (WHERE o.orders > 0) AS converted_users,
+			COALESCE(SUM(o.orders), 0) AS orders,
+			COALESCE(SUM(o.revenue), 0) AS revenue
+		FROM experiment_exposures e
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS claims
+			FROM user_coupons uc
+			WHERE uc.user_id = e.user_id AND uc.deleted_at IS NULL
+				AND uc.created_at >= e.exposed_at
+				AND uc.created_at < e.exposed_at + $2 * INTERVAL '1 second'
+		) c ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS orders, COALESCE(SUM(ROUND(od.amount * 100)), 0)::BIGINT AS revenue
+			FROM orders od
+			WHERE od.user_id = e.user_id AND od.deleted_at IS NULL
+				AND od.paid_at >= e.exposed_at
+				AND od.paid_at < e.exposed_at + $2 * INTERVAL '1 second'
+		) o ON TRUE
+		WHERE `+strings.Join(conditions, " AND ")+`
+		GROUP BY e.variant
+		ORDER BY e.variant`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query experiment attribution: %w", err)
+	}
+	return results, nil
+}
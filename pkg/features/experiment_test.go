@@ -0,0 +1,269 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryExperiments 内存中的实验存储，曝光按实验与用户去重
+type memoryExperiments struct {
+	mu          sync.Mutex
+	experiments map[string]*Experiment
+	exposures   map[string]Exposure
+}
+
+func newMemoryExperiments(experiments ...*Experiment) *memoryExperiments {
+	m := &memoryExperiments{experiments: make(map[string]*Experiment), exposures: make(map[string]Exposure)}
+	for _, experiment := range experiments {
+		m.experiments[experiment.Key] = experiment
+	}
+	return m
+}
+
+func (m *memoryExperiments) ListExperiments(ctx context.Context) ([]*Experiment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Experiment, 0, len(m.experiments))
+	for _, experiment := range m.experiments {
+		copied := *experiment
+		list = append(list, &copied)
+	}
+	return list, nil
+}
+
+func (m *memoryExperiments) GetExperiment(ctx context.Context, key string) (*Experiment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	experiment, ok := m.experiments[key]
+	if !ok {
+		return nil, ErrExperimentNotFound
+	}
+	copied := *experiment
+	return &copied, nil
+}
+
+func (m *memoryExperiments) SaveExperiment(ctx context.Context, experiment *Experiment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *experiment
+	m.experiments[experiment.Key] = &copied
+	return nil
+}
+
+func (m *memoryExperiments) RecordExposure(ctx context.Context, exposure *Exposure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := exposure.Experiment + ":" + exposure.UserID
+	if _, ok := m.exposures[key]; !ok {
+		m.exposures[key] = *exposure
+	}
+	return nil
+}
+
+func (m *memoryExperiments) Attribution(ctx context.Context, key string, window time.Duration) ([]VariantResult, error) {
+	return nil, nil
+}
+
+// memoryFlags 内存中的开关存储
+type memoryFlags map[string]*Flag
+
+func (m memoryFlags) List(ctx context.Context) ([]*Flag, error) {
+	list := make([]*Flag, 0, len(m))
+	for _, flag := range m {
+		list = append(list, flag)
+	}
+	return list, nil
+}
+
+func (m memoryFlags) Get(ctx context.Context, key string) (*Flag, error) {
+	flag, ok := m[key]
+	if !ok {
+		return nil, ErrFlagNotFound
+	}
+	return flag, nil
+}
+
+func (m memoryFlags) Save(ctx context.Context, flag *Flag) error {
+	m[flag.Key] = flag
+	return nil
+}
+
+func (m memoryFlags) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func threeWay() *Experiment {
+	return &Experiment{Key: "checkout-button", Status: ExperimentRunning, Variants: []Variant{
+		{Name: "control", Weight: 50},
+		{Name: "green", Weight: 30},
+		{Name: "blue", Weight: 20},
+	}}
+}
+
+// TestExperiment_AssignDeterministic 同一用户的分组只取决于实验名与用户，与实例、调用次数和实验状态无关
+func TestExperiment_AssignDeterministic(t *testing.T) {
+	experiment := threeWay()
+	other := threeWay()
+	other.Status = ExperimentStopped
+
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		variant := experiment.Assign(userID)
+		require.NotEmpty(t, variant)
+		assert.Equal(t, variant, experiment.Assign(userID))
+		assert.Equal(t, variant, other.Assign(userID))
+	}
+	assert.Empty(t, experiment.Assign(""), "anonymous users are not assigned")
+	assert.Empty(t, (&Experiment{Key: "empty"}).Assign("u1"))
+}
+
+// TestExperiment_WeightDistribution 各组人数按权重占比分配
+func TestExperiment_WeightDistribution(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []Variant
+	}{
+		{"even", []Variant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}}},
+		{"uneven", threeWay().Variants},
+		{"skewed", []Variant{{Name: "control", Weight: 9900}, {Name: "canary", Weight: 100}}},
+		{"many", []Variant{{Name: "a", Weight: 10}, {Name: "b", Weight: 20}, {Name: "c", Weight: 30}, {Name: "d", Weight: 40}}},
+	}
+	const users = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			experiment := &Experiment{Key: "dist-" + tt.name, Variants: tt.variants}
+			require.NoError(t, experiment.Validate())
+			total := 0
+			for _, variant := range tt.variants {
+				total += variant.Weight
+			}
+
+			counts := make(map[string]int)
+			for i := 0; i < users; i++ {
+				counts[experiment.Assign(fmt.Sprintf("user-%d", i))]++
+			}
+			assert.Len(t, counts, len(tt.variants), "every user lands in a declared variant")
+			for _, variant := range tt.variants {
+				want := float64(users) * float64(variant.Weight) / float64(total)
+				assert.InDelta(t, want, counts[variant.Name], users*0.015, "variant %s", variant.Name)
+			}
+		})
+	}
+}
+
+// TestExperiment_IndependentOfFlags 实验分组与同名功能开关的分桶无关，开关放量不会使某一组偏多
+func TestExperiment_IndependentOfFlags(t *testing.T) {
+	experiment := &Experiment{Key: "rollout", Variants: []Variant{{Name: "a", Weight: 50}, {Name: "b", Weight: 50}}}
+	inA := 0
+	rolledOut := 0
+	for i := 0; i < 20000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if Bucket("rollout", userID) >= 50 {
+			continue
+		}
+		rolledOut++
+		if experiment.Assign(userID) == "a" {
+			inA++
+		}
+	}
+	assert.InDelta(t, 0.5, float64(inA)/float64(rolledOut), 0.03)
+}
+
+// TestExperiment_Validate 分组数、分组名与权重校验
+func TestExperiment_Validate(t *testing.T) {
+	require.NoError(t, threeWay().Validate())
+	for _, invalid := range []*Experiment{
+		{Key: "Bad", Variants: threeWay().Variants},
+		{Key: "single", Variants: []Variant{{Name: "a", Weight: 1}}},
+		{Key: "dup", Variants: []Variant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}},
+		{Key: "zero", Variants: []Variant{{Name: "a", Weight: 0}, {Name: "b", Weight: 1}}},
+		{Key: "heavy", Variants: []Variant{{Name: "a", Weight: 10001}, {Name: "b", Weight: 1}}},
+		{Key: "flag", Flag: "Bad Flag", Variants: threeWay().Variants},
+	} {
+		assert.Error(t, invalid.Validate(), invalid.Key)
+	}
+}
+
+// TestExperiments_Assign 只有运行中的实验分组；开关圈定参与人群；曝光按用户去重后异步写入存储
+func TestExperiments_Assign(t *testing.T) {
+	ctx := context.Background()
+	gated := threeWay()
+	gated.Key = "gated"
+	gated.Flag = "beta"
+	draft := threeWay()
+	draft.Key = "draft"
+	draft.Status = ExperimentDraft
+	store := newMemoryExperiments(threeWay(), gated, draft)
+	flags := NewManager(memoryFlags{"beta": {Key: "beta", Enabled: true, Users: []string{"u1"}}}, nil)
+	experiments := NewExperiments(store, flags, nil)
+
+	u1 := Subject{UserID: "u1", TenantID: "acme"}
+	variant, ok := experiments.Assign(ctx, "checkout-button", u1)
+	require.True(t, ok)
+	assert.Equal(t, threeWay().Assign("u1"), variant)
+	for i := 0; i < 3; i++ {
+		again, ok := experiments.Assign(ctx, "checkout-button", u1)
+		require.True(t, ok)
+		assert.Equal(t, variant, again)
+	}
+
+	_, ok = experiments.Assign(ctx, "checkout-button", Subject{})
+	assert.False(t, ok, "anonymous subjects get the control experience")
+	_, ok = experiments.Assign(ctx, "draft", u1)
+	assert.False(t, ok)
+	_, ok = experiments.Assign(ctx, "missing", u1)
+	assert.False(t, ok)
+	_, ok = experiments.Assign(ctx, "gated", Subject{UserID: "u2"})
+	assert.False(t, ok, "subjects outside the flag do not take part")
+	_, ok = experiments.Assign(ctx, "gated", u1)
+	assert.True(t, ok)
+
+	experiments.Close()
+	assert.Len(t, store.exposures, 2)
+	exposure := store.exposures["checkout-button:u1"]
+	assert.Equal(t, variant, exposure.Variant)
+	assert.Equal(t, "acme", exposure.TenantID)
+	assert.False(t, exposure.ExposedAt.IsZero())
+}
+
+// TestExperiments_Lifecycle 新实验为草稿，运行中不能修改分组，停止后可以修改并重新开始
+func TestExperiments_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryExperiments()
+	experiments := NewExperiments(store, nil, nil)
+	defer experiments.Close()
+
+	experiment := threeWay()
+	experiment.Status = ExperimentRunning
+	require.NoError(t, experiments.Save(ctx, experiment))
+	assert.Equal(t, ExperimentDraft, experiment.Status, "new experiments start as drafts")
+
+	started, err := experiments.Start(ctx, experiment.Key)
+	require.NoError(t, err)
+	require.NotNil(t, started.StartedAt)
+	firstStart := *started.StartedAt
+	assert.ErrorIs(t, experiments.Save(ctx, threeWay()), ErrExperimentRunning)
+
+	variant, ok := experiments.Assign(ctx, experiment.Key, Subject{UserID: "u1"})
+	require.True(t, ok)
+	stopped, err := experiments.Stop(ctx, experiment.Key)
+	require.NoError(t, err)
+	assert.Equal(t, ExperimentStopped, stopped.Status)
+	_, ok = experiments.Assign(ctx, experiment.Key, Subject{UserID: "u1"})
+	assert.False(t, ok, "stopped experiments are not assigned")
+
+	restarted, err := experiments.Start(ctx, experiment.Key)
+	require.NoError(t, err)
+	assert.Equal(t, firstStart, *restarted.StartedAt)
+	assert.Nil(t, restarted.StoppedAt)
+	again, ok := experiments.Assign(ctx, experiment.Key, Subject{UserID: "u1"})
+	require.True(t, ok)
+	assert.Equal(t, variant, again, "assignments survive a restart")
+}
@@ -0,0 +1,231 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/events"
+)
+
+// ExperimentConfig 实验配置
+type ExperimentConfig struct {
+	RefreshInterval   time.Duration `json:"refresh_interval"`   // 实验定义本地快照的有效期
+	DedupeSize        int           `json:"dedupe_size"`        // 本实例记住的已曝光用户数，超出后清空重来，重复的曝光由存储去重
+	AttributionWindow time.Duration `json:"attribution_window"` // 默认归因窗口
+	MaxWindow         time.Duration `json:"max_window"`
+}
+
+// DefaultExperimentConfig 默认实验配置
+func DefaultExperimentConfig() *ExperimentConfig {
+	return &ExperimentConfig{
+		RefreshInterval:   15 * time.Second,
+		DedupeSize:        100000,
+		AttributionWindow: 7 * 24 * time.Hour,
+		MaxWindow:         90 * 24 * time.Hour,
+	}
+}
+
+// Experiments A/B 实验管理：确定性分组、经事件总线异步记录曝光、按分组归因业务指标
+type Experiments struct {
+	store       ExperimentStore
+	flags       *Manager
+	config      *ExperimentConfig
+	bus         *events.Bus[Exposure]
+	experiments *snapshot[*Experiment]
+
+	mu      sync.Mutex
+	exposed map[string]struct{}
+}
+
+// NewExperiments 创建实验管理器。flags 为 nil 时忽略实验上配置的功能开关
+func NewExperiments(store ExperimentStore, flags *Manager, config *ExperimentConfig) *Experiments {
+	if config == nil {
+		config = DefaultExperimentConfig()
+	}
+	e := &Experiments{
+		store:   store,
+		flags:   flags,
+		config:  config,
+		bus:     events.NewBus[Exposure]("experiments", events.DefaultConfig()),
+		exposed: make(map[string]struct{}),
+	}
+	e.experiments = newSnapshot("experiments", config.RefreshInterval, func(ctx context.Context) (map[string]*Experiment, error) {
+		list, err := store.ListExperiments(ctx)
+		if err != nil {
+			return nil, err
+		}
+		experiments := make(map[string]*Experiment, len(list))
+		for _, experiment := range list {
+			experiments[experiment.Key] = experiment
+		}
+		return experiments, nil
+	})
+
+	// 曝光按用户分片，异步写入存储，不阻塞请求
+	if err := e.bus.Subscribe("exposure-store", func(ctx context.Context, event events.Event[Exposure]) error {
+		return store.RecordExposure(ctx, &event.Payload)
+	}, events.Async(), events.WithTopics(ExposureTopic)); err != nil {
+		log.Printf("Failed to subscribe experiment exposure store: %v", err)
+	}
+	return e
+}
+
+// Exposures 曝光事件总线，可订阅曝光事件转发到分析平台
+func (e *Experiments) Exposures() *events.Bus[Exposure] {
+	return e.bus
+}
+
+// Close 关闭事件总线，等待已入队的曝光写入完成
+func (e *Experiments) Close() {
+	e.bus.Close()
+}
+
+// Assign 为调用方分配分组并记录曝光。实验未运行、调用方未登录或不在开关圈定的人群中时返回 false，
+// 调用方应按对照组处理
+func (e *Experiments) Assign(ctx context.Context, key string, subject Subject) (string, bool) {
+	experiment, ok := e.experiments.get(ctx)[key]
+	if !ok || experiment.Status != ExperimentRunning || subject.UserID == "" {
+		return "", false
+	}
+	if experiment.Flag != "" && e.flags != nil && !e.flags.Enabled(ctx, experiment.Flag, subject) {
+		return "", false
+	}
+
+	variant := experiment.Assign(subject.UserID)
+	if variant == "" {
+		return "", false
+	}
+	e.expose(ctx, experiment.Key, variant, subject)
+	return variant, true
+}
+
+// expose 发布曝光事件，本实例已发布过的用户跳过
+func (e *Experiments) expose(ctx context.Context, key, variant string, subject Subject) {
+	dedupeKey := key + ":" + subject.UserID
+	e.mu.Lock()
+	if _, seen := e.exposed[dedupeKey]; seen {
+		e.mu.Unlock()
+		return
+	}
+	if len(e.exposed) >= e.config.DedupeSize {
+		e.exposed = make(map[string]struct{})
+	}
+	e.exposed[dedupeKey] = struct{}{}
+	e.mu.Unlock()
+
+	exposure := Exposure{
+		Experiment: key,
+		Variant:    variant,
+		UserID:     subject.UserID,
+		TenantID:   subject.TenantID,
+		ExposedAt:  time.Now(),
+	}
+	if err := e.bus.Publish(ctx, ExposureTopic, subject.UserID, exposure); err != nil {
+		log.Printf("Failed to publish exposure for experiment %s: %v", key, err)
+	}
+}
+
+// List 全部实验，直接读取存储
+func (e *Experiments) List(ctx context.Context) ([]*Experiment, error) {
+	return e.store.ListExperiments(ctx)
+}
+
+// Get 获取实验，直接读取存储
+func (e *Experiments) Get(ctx context.Context, key string) (*Experiment, error) {
+	return e.store.GetExperiment(ctx, key)
+}
+
+// Save 新增或修改实验定义。运行中的实验不能修改，需先停止
+func (e *Experiments) Save(ctx context.Context, experiment *Experiment) error {
+	if err := experiment.Validate(); err != nil {
+		return err
+	}
+
+	existing, err := e.store.GetExperiment(ctx, experiment.Key)
+	switch {
+	case errors.Is(err, ErrExperimentNotFound):
+		experiment.Status = ExperimentDraft
+	case err != nil:
+		return err
+	case existing.Status == ExperimentRunning:
+		return ErrExperimentRunning
+	default:
+		experiment.Status = existing.Status
+		experiment.StartedAt = existing.StartedAt
+		experiment.StoppedAt = existing.StoppedAt
+	}
+	return e.save(ctx, experiment)
+}
+
+// Start 开始实验，已停止的实验可重新开始，此前的曝光保留
+func (e *Experiments) Start(ctx context.Context, key string) (*Experiment, error) {
+	experiment, err := e.store.GetExperiment(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if experiment.Status == ExperimentRunning {
+		return experiment, nil
+	}
+
+	now := time.Now()
+	experiment.Status = ExperimentRunning
+	if experiment.StartedAt == nil {
+		experiment.StartedAt = &now
+	}
+	experiment.StoppedAt = nil
+	return experiment, e.save(ctx, experiment)
+}
+
+// Stop 停止实验，停止后所有调用方按对照组处理
+func (e *Experiments) Stop(ctx context.Context, key string) (*Experiment, error) {
+	experiment, err := e.store.GetExperiment(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if experiment.Status != ExperimentRunning {
+		return experiment, nil
+	}
+
+	now := time.Now()
+	experiment.Status = ExperimentStopped
+	experiment.StoppedAt = &now
+	return experiment, e.save(ctx, experiment)
+}
+
+// Results 按分组汇总曝光用户在归因窗口内的领券与支付，window 为 0 时使用默认窗口
+func (e *Experiments) Results(ctx context.Context, key string, window time.Duration) (*ExperimentResults, error) {
+	if _, err := e.store.GetExperiment(ctx, key); err != nil {
+		return nil, err
+	}
+	if window <= 0 {
+		window = e.config.AttributionWindow
+	}
+	if window > e.config.MaxWindow {
+		window = e.config.MaxWindow
+	}
+
+	variants, err := e.store.Attribution(ctx, key, window)
+	if err != nil {
+		return nil, err
+	}
+	if variants == nil {
+		variants = []VariantResult{}
+	}
+	for i := range variants {
+		if variants[i].Users > 0 {
+			variants[i].ClaimRate = float64(variants[i].ClaimedUsers) / float64(variants[i].Users)
+			variants[i].ConversionRate = float64(variants[i].ConvertedUsers) / float64(variants[i].Users)
+		}
+	}
+	return &ExperimentResults{Experiment: key, Window: window.String(), Variants: variants}, nil
+}
+
+func (e *Experiments) save(ctx context.Context, experiment *Experiment) error {
+	if err := e.store.SaveExperiment(ctx, experiment); err != nil {
+		return err
+	}
+	e.experiments.invalidate()
+	return nil
+}
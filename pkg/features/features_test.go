@@ -0,0 +1,139 @@
+package features
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userInBucket 找到桶号满足 match 的用户
+func userInBucket(t *testing.T, key string, match func(bucket int) bool) string {
+	t.Helper()
+	for i := 0; i < 10000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if match(Bucket(key, userID)) {
+			return userID
+		}
+	}
+	t.Fatalf("no user matches the bucket condition for %s", key)
+	return ""
+}
+
+// TestFlag_Evaluate 求值顺序：总开关 → 白名单用户 → 租户、角色限定 → 按比例放量
+func TestFlag_Evaluate(t *testing.T) {
+	const key = "new-checkout"
+	inside := userInBucket(t, key, func(b int) bool { return b < 30 })
+	outside := userInBucket(t, key, func(b int) bool { return b >= 30 })
+
+	member := Subject{UserID: inside, Role: 0, HasRole: true, TenantID: "acme"}
+	tests := []struct {
+		name    string
+		flag    Flag
+		subject Subject
+		want    bool
+	}{
+		{"kill switch beats the allowlist", Flag{Users: []string{"vip"}, Percentage: 100}, Subject{UserID: "vip"}, false},
+		{"kill switch beats full rollout", Flag{Percentage: 100}, member, false},
+		{"allowlist bypasses the percentage", Flag{Enabled: true, Users: []string{"vip"}}, Subject{UserID: "vip"}, true},
+		{"allowlist bypasses tenant and role limits", Flag{Enabled: true, Users: []string{"vip"}, Tenants: []string{"globex"}, Roles: []int{1}},
+			Subject{UserID: "vip", TenantID: "acme"}, true},
+		{"other tenants are excluded", Flag{Enabled: true, Percentage: 100, Tenants: []string{"globex"}}, member, false},
+		{"listed tenant gets the rollout", Flag{Enabled: true, Percentage: 100, Tenants: []string{"acme", "globex"}}, member, true},
+		{"tenant limit applies before the percentage", Flag{Enabled: true, Percentage: 30, Tenants: []string{"globex"}}, member, false},
+		{"other roles are excluded", Flag{Enabled: true, Percentage: 100, Roles: []int{1}}, member, false},
+		{"listed role gets the rollout", Flag{Enabled: true, Percentage: 100, Roles: []int{0, 1}}, member, true},
+		{"role 0 without a role claim is excluded", Flag{Enabled: true, Percentage: 100, Roles: []int{0}}, Subject{UserID: inside}, false},
+		{"zero percent", Flag{Enabled: true, Percentage: 0}, member, false},
+		{"full rollout", Flag{Enabled: true, Percentage: 100}, member, true},
+		{"bucket below the percentage", Flag{Enabled: true, Percentage: 30}, member, true},
+		{"bucket at or above the percentage", Flag{Enabled: true, Percentage: 30}, Subject{UserID: outside, TenantID: "acme"}, false},
+		{"anonymous subjects get full rollouts", Flag{Enabled: true, Percentage: 100}, Subject{}, true},
+		{"anonymous subjects are not bucketed", Flag{Enabled: true, Percentage: 99}, Subject{}, false},
+		{"anonymous subjects fail role limits", Flag{Enabled: true, Percentage: 100, Roles: []int{0}}, Subject{TenantID: "acme"}, false},
+		{"anonymous subjects pass tenant limits", Flag{Enabled: true, Percentage: 100, Tenants: []string{"acme"}}, Subject{TenantID: "acme"}, true},
+		{"an empty allowlist entry does not match anonymous subjects", Flag{Enabled: true, Users: []string{""}}, Subject{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := tt.flag
+			flag.Key = key
+			require.NoError(t, flag.Validate())
+			assert.Equal(t, tt.want, flag.Evaluate(tt.subject))
+		})
+	}
+}
+
+// TestFlag_EvaluateByTenant 按租户分桶时同一租户的用户结果一致，匿名用户随租户放量
+func TestFlag_EvaluateByTenant(t *testing.T) {
+	flag := Flag{Key: "tenant-rollout", Enabled: true, Percentage: 50, BucketBy: BucketByTenant}
+	require.NoError(t, flag.Validate())
+
+	for i := 0; i < 20; i++ {
+		tenantID := fmt.Sprintf("tenant-%d", i)
+		want := Bucket(flag.Key, tenantID) < 50
+		for _, userID := range []string{"", "u1", "u2", "u3"} {
+			assert.Equal(t, want, flag.Evaluate(Subject{UserID: userID, TenantID: tenantID}), "%s/%s", tenantID, userID)
+		}
+	}
+	assert.False(t, flag.Evaluate(Subject{UserID: "u1"}), "subjects without a tenant are not bucketed")
+}
+
+// TestFlag_Stickiness 放量比例调大时已开启的用户保持开启，开启人数接近比例
+func TestFlag_Stickiness(t *testing.T) {
+	flag := Flag{Key: "sticky", Enabled: true}
+	require.NoError(t, flag.Validate())
+
+	const users = 10000
+	enabled := make(map[string]bool)
+	for _, percentage := range []int{1, 5, 10, 25, 50, 75, 100} {
+		flag.Percentage = percentage
+		count := 0
+		for i := 0; i < users; i++ {
+			userID := fmt.Sprintf("user-%d", i)
+			on := flag.Evaluate(Subject{UserID: userID})
+			if enabled[userID] {
+				require.True(t, on, "%s was enabled before raising the rollout to %d%%", userID, percentage)
+			}
+			if on {
+				enabled[userID] = true
+				count++
+			}
+		}
+		assert.InDelta(t, percentage*users/100, count, users*0.02, "rollout at %d%%", percentage)
+	}
+}
+
+// TestBucket 桶号稳定且在 0-99 之间，不同开关的分桶相互独立
+func TestBucket(t *testing.T) {
+	same := 0
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		bucket := Bucket("flag-a", userID)
+		assert.GreaterOrEqual(t, bucket, 0)
+		assert.Less(t, bucket, 100)
+		assert.Equal(t, bucket, Bucket("flag-a", userID))
+		if bucket == Bucket("flag-b", userID) {
+			same++
+		}
+	}
+	assert.Less(t, same, 50, "buckets of different flags should be uncorrelated")
+}
+
+// TestFlag_Validate 开关名与比例校验，分桶方式默认按用户
+func TestFlag_Validate(t *testing.T) {
+	flag := Flag{Key: "checkout.v2"}
+	require.NoError(t, flag.Validate())
+	assert.Equal(t, BucketByUser, flag.BucketBy)
+
+	for _, invalid := range []Flag{
+		{Key: "Checkout"},
+		{Key: "-checkout"},
+		{Key: "checkout", Percentage: 101},
+		{Key: "checkout", Percentage: -1},
+		{Key: "checkout", BucketBy: "device"},
+	} {
+		assert.Error(t, invalid.Validate(), "%+v", invalid)
+	}
+}
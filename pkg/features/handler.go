@@ -0,0 +1,169 @@
+package features
+
+import (
+	"errors"
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FlagRequest 新增或覆盖开关请求
+type FlagRequest struct {
+	Description string   `json:"description" binding:"max=500"`
+	Enabled     bool     `json:"enabled"`
+	Percentage  int      `json:"percentage" binding:"min=0,max=100"`
+	BucketBy    BucketBy `json:"bucket_by"`
+	Roles       []int    `json:"roles" binding:"max=20"`
+	Tenants     []string `json:"tenants" binding:"max=1000"`
+	Users       []string `json:"users" binding:"max=1000"`
+	// Version 读取时的版本号，传入时只在版本未变化时覆盖，否则返回 409；为 0 时直接覆盖
+	Version int64 `json:"version" binding:"min=0"`
+}
+
+// PatchRequest 运行时调整开关，只修改传入的字段
+type PatchRequest struct {
+	Enabled    *bool `json:"enabled"`
+	Percentage *int  `json:"percentage" binding:"omitempty,min=0,max=100"`
+}
+
+// Handler 功能开关接口
+type Handler struct {
+	manager *Manager
+}
+
+// NewHandler 创建功能开关接口
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// RegisterRoutes 注册用户侧路由，调用方需挂载 AuthMiddleware
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/features", h.Evaluate)
+}
+
+// RegisterAdminRoutes 注册开关管理路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/features", h.ListFlags)
+	group.GET("/features/:key", h.GetFlag)
+	group.PUT("/features/:key", h.SaveFlag)
+	group.PATCH("/features/:key", h.PatchFlag)
+	group.DELETE("/features/:key", h.DeleteFlag)
+}
+
+// Evaluate 当前调用方的开关求值结果
+func (h *Handler) Evaluate(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"features": h.manager.EvaluateAll(c.Request.Context(), SubjectFromContext(c)),
+	})
+}
+
+// ListFlags 全部开关定义
+func (h *Handler) ListFlags(c *gin.Context) {
+	flags, err := h.manager.List(c.Request.Context())
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"flags": flags,
+	})
+}
+
+// GetFlag 开关定义
+func (h *Handler) GetFlag(c *gin.Context) {
+	flag, err := h.manager.Get(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// SaveFlag 新增或覆盖开关
+func (h *Handler) SaveFlag(c *gin.Context) {
+	var req FlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	flag := &Flag{
+		Key:         c.Param("key"),
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Percentage:  req.Percentage,
+		BucketBy:    req.BucketBy,
+		Roles:       req.Roles,
+		Tenants:     req.Tenants,
+		Users:       req.Users,
+		UpdatedBy:   c.GetString("userID"),
+		Versioned:   database.Versioned{Version: req.Version},
+	}
+	if err := flag.Validate(); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, err.Error()))
+		return
+	}
+	if err := h.manager.Save(c.Request.Context(), flag); err != nil {
+		respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// PatchFlag 开启、关闭开关或调整放量比例。按读取时的版本号保存，期间开关被其他请求修改时重新读取后再修改，
+// 不会覆盖其他字段的并发修改
+func (h *Handler) PatchFlag(c *gin.Context) {
+	var req PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	ctx := c.Request.Context()
+	var flag *Flag
+	err := database.Retry(ctx, "feature_flag_patch", nil, func() error {
+		var err error
+		if flag, err = h.manager.Get(ctx, c.Param("key")); err != nil {
+			return err
+		}
+		if req.Enabled != nil {
+			flag.Enabled = *req.Enabled
+		}
+		if req.Percentage != nil {
+			flag.Percentage = *req.Percentage
+		}
+		flag.UpdatedBy = c.GetString("userID")
+		return h.manager.Save(ctx, flag)
+	})
+	if err != nil {
+		respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// DeleteFlag 删除开关，删除后按关闭处理
+func (h *Handler) DeleteFlag(c *gin.Context) {
+	if err := h.manager.Delete(c.Request.Context(), c.Param("key")); err != nil {
+		respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"key":     c.Param("key"),
+		"deleted": true,
+	})
+}
+
+func respondFlagError(c *gin.Context, err error) {
+	if errors.Is(err, ErrFlagNotFound) {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+		return
+	}
+	if errors.Is(err, database.ErrStaleObject) {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, "feature flag was modified, reload and retry"))
+		return
+	}
+	apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+}
@@ -0,0 +1,99 @@
+package features
+
+import (
+	"context"
+	"time"
+)
+
+// Config 功能开关配置
+type Config struct {
+	// RefreshInterval 本地快照的有效期。本实例的修改立即生效，其他实例的修改最迟在该时间后生效
+	RefreshInterval time.Duration `json:"refresh_interval"`
+}
+
+// DefaultConfig 默认功能开关配置
+func DefaultConfig() *Config {
+	return &Config{
+		RefreshInterval: 15 * time.Second,
+	}
+}
+
+// Manager 功能开关管理：开关定义存放在数据库，求值使用本地快照，避免每次请求访问数据库
+type Manager struct {
+	store  Store
+	config *Config
+	flags  *snapshot[*Flag]
+}
+
+// NewManager 创建功能开关管理器
+func NewManager(store Store, config *Config) *Manager {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	m := &Manager{store: store, config: config}
+	m.flags = newSnapshot("feature flags", config.RefreshInterval, func(ctx context.Context) (map[string]*Flag, error) {
+		list, err := store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		flags := make(map[string]*Flag, len(list))
+		for _, flag := range list {
+			flags[flag.Key] = flag
+		}
+		return flags, nil
+	})
+	return m
+}
+
+// Enabled 开关对调用方是否开启。未定义的开关视为关闭；
+// 读取开关失败时沿用上一次的快照，从未加载成功时视为关闭
+func (m *Manager) Enabled(ctx context.Context, key string, subject Subject) bool {
+	flag, ok := m.flags.get(ctx)[key]
+	return ok && flag.Evaluate(subject)
+}
+
+// EvaluateAll 对调用方求值全部开关，供客户端按开关渲染界面
+func (m *Manager) EvaluateAll(ctx context.Context, subject Subject) map[string]bool {
+	flags := m.flags.get(ctx)
+	result := make(map[string]bool, len(flags))
+	for key, flag := range flags {
+		result[key] = flag.Evaluate(subject)
+	}
+	return result
+}
+
+// List 全部开关定义，直接读取存储
+func (m *Manager) List(ctx context.Context) ([]*Flag, error) {
+	return m.store.List(ctx)
+}
+
+// Get 获取开关定义，直接读取存储
+func (m *Manager) Get(ctx context.Context, key string) (*Flag, error) {
+	return m.store.Get(ctx, key)
+}
+
+// Save 校验并保存开关，本实例立即生效
+func (m *Manager) Save(ctx context.Context, flag *Flag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+	if err := m.store.Save(ctx, flag); err != nil {
+		return err
+	}
+	m.Invalidate()
+	return nil
+}
+
+// Delete 删除开关，本实例立即生效
+func (m *Manager) Delete(ctx context.Context, key string) error {
+	if err := m.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	m.Invalidate()
+	return nil
+}
+
+// Invalidate 使本地快照过期，下一次求值时重新加载
+func (m *Manager) Invalidate() {
+	m.flags.invalidate()
+}
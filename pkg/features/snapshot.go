@@ -0,0 +1,66 @@
+package features
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// snapshot 定义的本地快照：过期后由一个请求回源加载，加载失败时沿用上一次的快照
+type snapshot[T any] struct {
+	name string
+	ttl  time.Duration
+	load func(ctx context.Context) (map[string]T, error)
+
+	mu       sync.RWMutex
+	items    map[string]T
+	loadedAt time.Time
+	// loadMu 保证快照过期时只有一个请求回源
+	loadMu sync.Mutex
+}
+
+func newSnapshot[T any](name string, ttl time.Duration, load func(ctx context.Context) (map[string]T, error)) *snapshot[T] {
+	return &snapshot[T]{name: name, ttl: ttl, load: load}
+}
+
+// get 返回未过期的快照，过期时重新加载
+func (s *snapshot[T]) get(ctx context.Context) map[string]T {
+	if items, fresh := s.current(); fresh {
+		return items
+	}
+
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	// 等待期间其他请求可能已完成加载
+	items, fresh := s.current()
+	if fresh {
+		return items
+	}
+
+	loaded, err := s.load(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 存储故障时不让每个请求都回源，下一个周期再重试
+	s.loadedAt = time.Now()
+	if err != nil {
+		log.Printf("Failed to load %s, using previous snapshot: %v", s.name, err)
+		return items
+	}
+	s.items = loaded
+	return loaded
+}
+
+func (s *snapshot[T]) current() (map[string]T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.items, time.Since(s.loadedAt) < s.ttl
+}
+
+// invalidate 使快照过期，下一次读取时重新加载
+func (s *snapshot[T]) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
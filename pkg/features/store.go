@@ -0,0 +1,174 @@
+package features
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// Store 功能开关存储
+type Store interface {
+	List(ctx context.Context) ([]*Flag, error)
+	Get(ctx context.Context, key string) (*Flag, error)
+	// Save 按 key 新增或覆盖；flag.Version 大于 0 时只在版本一致时修改，否则返回 database.ErrStaleObject。
+	// 保存后 flag.Version 为新的版本号
+	Save(ctx context.Context, flag *Flag) error
+	Delete(ctx context.Context, key string) error
+}
+
+// flagRow feature_flags 表的行
+type flagRow struct {
+	Key         string    `db:"key"`
+	Description string    `db:"description"`
+	Enabled     bool      `db:"enabled"`
+	Percentage  int       `db:"percentage"`
+	BucketBy    string    `db:"bucket_by"`
+	Roles       []byte    `db:"roles"`
+	Tenants     []byte    `db:"tenants"`
+	Users       []byte    `db:"users"`
+	UpdatedBy   string    `db:"updated_by"`
+	UpdatedAt   time.Time `db:"updated_at"`
+	Version     int64     `db:"version"`
+}
+
+func (r *flagRow) toFlag() (*Flag, error) {
+	flag := &Flag{
+		Key:         r.Key,
+		Description: r.Description,
+		Enabled:     r.Enabled,
+		Percentage:  r.Percentage,
+		BucketBy:    BucketBy(r.BucketBy),
+		UpdatedBy:   r.UpdatedBy,
+		UpdatedAt:   r.UpdatedAt,
+		Versioned:   database.Versioned{Version: r.Version},
+	}
+	if err := json.Unmarshal(r.Roles, &flag.Roles); err != nil {
+		return nil, fmt.Errorf("failed to decode feature flag roles: %w", err)
+	}
+	if err := json.Unmarshal(r.Tenants, &flag.Tenants); err != nil {
+		return nil, fmt.Errorf("failed to decode feature flag tenants: %w", err)
+	}
+	if err := json.Unmarshal(r.Users, &flag.Users); err != nil {
+		return nil, fmt.Errorf("failed to decode feature flag users: %w", err)
+	}
+	return flag, nil
+}
+
+const flagColumns = `key, description, enabled, percentage, bucket_by, roles, tenants, users, updated_by, updated_at, version`
+
+// SQLStore 基于 feature_flags 表的开关存储
+type SQLStore struct {
+	db *database.DB
+}
+
+// NewSQLStore 创建开关存储
+func NewSQLStore(db *database.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// List 全部开关，按 key 排序
+func (s *SQLStore) List(ctx context.Context) ([]*Flag, error) {
+	var rows []flagRow
+	if err := s.db.SelectContext(ctx, &rows, `SELECT `+flagColumns+` FROM feature_flags ORDER BY key`); err != nil {
+		return nil, fmt.Errorf("failed to list feature flags: %w", err)
+	}
+
+	flags := make([]*Flag, 0, len(rows))
+	for i := range rows {
+		flag, err := rows[i].toFlag()
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// Get 获取开关，不存在时返回 ErrFlagNotFound
+func (s *SQLStore) Get(ctx context.Context, key string) (*Flag, error) {
+	var row flagRow
+	err := s.db.GetContext(ctx, &row, `SELECT `+flagColumns+` FROM feature_flags WHERE key = $1`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFlagNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feature flag: %w", err)
+	}
+	return row.toFlag()
+}
+
+// Save 新增或覆盖开关
+func (s *SQLStore) Save(ctx context.Context, flag *Flag) error {
+	roles, err := json.Marshal(nonNil(flag.Roles))
+	if err != nil {
+		return fmt.Errorf("failed to encode feature flag roles: %w", err)
+	}
+	tenants, err := json.Marshal(nonNil(flag.Tenants))
+	if err != nil {
+		return fmt.Errorf("failed to encode feature flag tenants: %w", err)
+	}
+	users, err := json.Marshal(nonNil(flag.Users))
+	if err != nil {
+		return fmt.Errorf("failed to encode feature flag users: %w", err)
+	}
+
+	if flag.Version > 0 {
+		flag.UpdatedAt = time.Now()
+		return database.UpdateVersioned(ctx, s.db, "feature_flags", "key", flag.Key, &flag.Versioned, `
+			description = $1, enabled = $2, percentage = $3, bucket_by = $4,
+			roles = $5, tenants = $6, users = $7, updated_by = $8, updated_at = $9`,
+			flag.Description, flag.Enabled, flag.Percentage, string(flag.BucketBy),
+			roles, tenants, users, flag.UpdatedBy, flag.UpdatedAt)
+	}
+
+	var saved struct {
+		UpdatedAt time.Time `db:"updated_at"`
+		Version   int64     `db:"version"`
+	}
+	err = s.db.GetContext(ctx, &saved, `
+		INSERT INTO feature_flags (key, description, enabled, percentage, bucket_by, roles, tenants, users, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description,
+			enabled = EXCLUDED.enabled,
+			percentage = EXCLUDED.percentage,
+			bucket_by = EXCLUDED.bucket_by,
+			roles = EXCLUDED.roles,
+			tenants = EXCLUDED.tenants,
+			users = EXCLUDED.users,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW(),
+			version = feature_flags.version + 1
+		RETURNING updated_at, version`,
+		flag.Key, flag.Description, flag.Enabled, flag.Percentage, string(flag.BucketBy),
+		roles, tenants, users, flag.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	flag.UpdatedAt, flag.Version = saved.UpdatedAt, saved.Version
+	return nil
+}
+
+// Delete 删除开关，不存在时返回 ErrFlagNotFound
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE key = $1`, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrFlagNotFound
+	}
+	return nil
+}
+
+// nonNil 空列表编码为 [] 而不是 null
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DatabaseCheck 数据库连通性检查
+func DatabaseCheck(db *database.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		if db == nil || db.DB == nil {
+			return fmt.Errorf("database not initialized")
+		}
+		return db.PingContext(ctx)
+	}
+}
+
+// RedisCheck Redis 连通性检查
+func RedisCheck(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		if client == nil {
+			return fmt.Errorf("redis client not initialized")
+		}
+		return client.Ping(ctx).Err()
+	}
+}
+
+// RedisClusterCheck Redis 集群状态检查
+func RedisClusterCheck(cluster *cache.RedisCluster) CheckFunc {
+	return func(ctx context.Context) error {
+		if cluster == nil {
+			return fmt.Errorf("redis cluster not initialized")
+		}
+		return cluster.Health(ctx)
+	}
+}
+
+// CacheCheck 缓存读写检查
+func CacheCheck(service cache.CacheService, name string) CheckFunc {
+	return func(ctx context.Context) error {
+		key := fmt.Sprintf("health_check:%s", name)
+		if err := service.Set(ctx, key, "ok", time.Minute); err != nil {
+			return fmt.Errorf("cache write failed: %w", err)
+		}
+		var value string
+		if err := service.Get(ctx, key, &value); err != nil {
+			return fmt.Errorf("cache read failed: %w", err)
+		}
+		return nil
+	}
+}
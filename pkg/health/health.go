@@ -0,0 +1,287 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/lifecycle"
+	"user_crud_jwt/pkg/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status 健康状态
+type Status string
+
+const (
+	StatusUp       Status = "up"
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// CheckFunc 健康检查函数，返回 nil 表示健康
+type CheckFunc func(ctx context.Context) error
+
+// Check 健康检查定义
+type Check struct {
+	Name     string        `json:"name"`
+	Check    CheckFunc     `json:"-"`
+	Timeout  time.Duration `json:"timeout"`  // 单次检查超时
+	Critical bool          `json:"critical"` // 关键依赖失败时服务不可就绪
+}
+
+// Result 单项检查结果
+type Result struct {
+	Name      string        `json:"name"`
+	Status    Status        `json:"status"`
+	Critical  bool          `json:"critical"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// Report 汇总检查结果
+type Report struct {
+	Status    Status    `json:"status"`
+	Checks    []Result  `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Config 健康检查配置
+type Config struct {
+	DefaultTimeout time.Duration `json:"default_timeout"` // 未指定超时的检查使用该值
+	CacheTTL       time.Duration `json:"cache_ttl"`       // 就绪检查结果缓存时间
+	Clock          clock.Clock   `json:"-"`
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		DefaultTimeout: 2 * time.Second,
+		CacheTTL:       5 * time.Second,
+	}
+}
+
+// Registry 健康检查注册表
+type Registry struct {
+	config     *Config
+	clock      clock.Clock
+	checks     map[string]Check
+	lastReport *Report
+	goroutines *lifecycle.Tracker
+	mu         sync.RWMutex
+	reportMu   sync.Mutex
+}
+
+// NewRegistry 创建健康检查注册表
+func NewRegistry(config *Config) *Registry {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Registry{
+		config: config,
+		clock:  clock.OrReal(config.Clock),
+		checks: make(map[string]Check),
+	}
+}
+
+// Register 注册健康检查，同名检查会被覆盖
+func (r *Registry) Register(check Check) error {
+	if check.Name == "" {
+		return fmt.Errorf("health check name is required")
+	}
+	if check.Check == nil {
+		return fmt.Errorf("health check %s has no check function", check.Name)
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = r.config.DefaultTimeout
+	}
+
+	r.mu.Lock()
+	r.checks[check.Name] = check
+	r.mu.Unlock()
+
+	r.invalidate()
+	return nil
+}
+
+// RegisterGoroutines 注册 goroutine 泄漏检查（非关键，失败时状态为 degraded），
+// 并在 /healthz/goroutines 提供按组件分组的 goroutine 清单；需在 RegisterRoutes 之前调用
+func (r *Registry) RegisterGoroutines(tracker *lifecycle.Tracker) error {
+	if err := r.Register(Check{Name: "goroutines", Check: tracker.Check}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.goroutines = tracker
+	r.mu.Unlock()
+	return nil
+}
+
+// Unregister 移除健康检查
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.checks, name)
+	r.mu.Unlock()
+
+	r.invalidate()
+}
+
+// invalidate 清除缓存的检查结果
+func (r *Registry) invalidate() {
+	r.reportMu.Lock()
+	r.lastReport = nil
+	r.reportMu.Unlock()
+}
+
+// Readiness 执行所有依赖检查，结果在 CacheTTL 内复用。检查不随调用方取消：探针断开或超时不会
+// 使依赖被记为 down 并缓存，每项检查仍受各自的 Timeout 限制
+func (r *Registry) Readiness(ctx context.Context) *Report {
+	r.reportMu.Lock()
+	defer r.reportMu.Unlock()
+
+	if r.lastReport != nil && r.clock.Since(r.lastReport.CheckedAt) < r.config.CacheTTL {
+		return r.lastReport
+	}
+
+	report := r.run(context.WithoutCancel(ctx))
+	r.lastReport = report
+	return report
+}
+
+// run 并发执行所有检查
+func (r *Registry) run(ctx context.Context) *Report {
+	r.mu.RLock()
+	checks := make([]Check, 0, len(r.checks))
+	for _, check := range r.checks {
+		checks = append(checks, check)
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	status := StatusUp
+	for _, result := range results {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			status = StatusDown
+			break
+		}
+		status = StatusDegraded
+	}
+
+	return &Report{
+		Status:    status,
+		Checks:    results,
+		CheckedAt: r.clock.Now(),
+	}
+}
+
+// runCheck 带超时执行单项检查
+func runCheck(ctx context.Context, check Check) Result {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				errCh <- fmt.Errorf("health check panicked: %v", rec)
+			}
+		}()
+		errCh <- check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("health check timed out after %s", check.Timeout)
+	}
+
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		Duration:  time.Since(start),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler 存活检查，只反映进程本身是否可响应，不检查依赖
+func (r *Registry) LivenessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": StatusUp,
+		})
+	}
+}
+
+// ReadinessHandler 就绪检查，关键依赖不可用时返回 503
+func (r *Registry) ReadinessHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := r.Readiness(c.Request.Context())
+
+		code := http.StatusOK
+		if report.Status == StatusDown {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	}
+}
+
+// GoroutinesHandler goroutine 清单，存在泄漏时返回 503
+func GoroutinesHandler(tracker *lifecycle.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inventory := tracker.Inventory()
+
+		code := http.StatusOK
+		if inventory.Leaked > 0 {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, inventory)
+	}
+}
+
+// RegisterRoutes 注册 /healthz 与 /readyz，已注册 goroutine 检查时同时注册 /healthz/goroutines
+func (r *Registry) RegisterRoutes(router gin.IRoutes) {
+	liveness, readiness := r.LivenessHandler(), r.ReadinessHandler()
+	openapi.Describe(liveness, openapi.Route{Summary: "存活检查", Tags: []string{"Health"}, Response: map[string]Status{}, Raw: true})
+	openapi.Describe(readiness, openapi.Route{Summary: "就绪检查", Tags: []string{"Health"}, Response: Report{}, Raw: true, Errors: []int{http.StatusServiceUnavailable}})
+
+	router.GET("/healthz", liveness)
+	router.GET("/readyz", readiness)
+
+	r.mu.RLock()
+	tracker := r.goroutines
+	r.mu.RUnlock()
+	if tracker != nil {
+		goroutines := GoroutinesHandler(tracker)
+		openapi.Describe(goroutines, openapi.Route{Summary: "goroutine 清单", Tags: []string{"Health"}, Response: lifecycle.Inventory{}, Raw: true, Errors: []int{http.StatusServiceUnavailable}})
+		router.GET("/healthz/goroutines", goroutines)
+	}
+}
@@ -0,0 +1,100 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry() (*Registry, *fakes.Clock) {
+	clock := fakes.NewClock(time.Time{})
+	config := DefaultConfig()
+	config.DefaultTimeout = 50 * time.Millisecond
+	config.Clock = clock
+	return NewRegistry(config), clock
+}
+
+// TestReadiness_CacheTTL 结果在 CacheTTL 内复用，过期或注册变化后重新检查
+func TestReadiness_CacheTTL(t *testing.T) {
+	registry, clock := newTestRegistry()
+	var calls atomic.Int32
+	require.NoError(t, registry.Register(Check{Name: "db", Critical: true, Check: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}}))
+
+	ctx := context.Background()
+	first := registry.Readiness(ctx)
+	assert.Equal(t, StatusUp, first.Status)
+	clock.Advance(4 * time.Second)
+	assert.Same(t, first, registry.Readiness(ctx))
+	assert.Equal(t, int32(1), calls.Load())
+
+	clock.Advance(time.Second)
+	assert.NotSame(t, first, registry.Readiness(ctx))
+	assert.Equal(t, int32(2), calls.Load())
+
+	require.NoError(t, registry.Register(Check{Name: "cache", Check: func(ctx context.Context) error { return nil }}))
+	report := registry.Readiness(ctx)
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+// TestReadiness_Timeouts 每项检查受各自的超时限制：关键依赖超时为 down，非关键依赖失败为 degraded，panic 记为失败
+func TestReadiness_Timeouts(t *testing.T) {
+	registry, _ := newTestRegistry()
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	require.NoError(t, registry.Register(Check{Name: "search", Check: hang, Timeout: 10 * time.Millisecond}))
+	require.NoError(t, registry.Register(Check{Name: "mq", Check: func(ctx context.Context) error { panic("boom") }}))
+	require.NoError(t, registry.Register(Check{Name: "db", Critical: true, Check: func(ctx context.Context) error { return nil }}))
+
+	start := time.Now()
+	report := registry.Readiness(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusDegraded, report.Status)
+	require.Len(t, report.Checks, 3)
+	assert.Equal(t, "db", report.Checks[0].Name)
+	assert.Equal(t, StatusUp, report.Checks[0].Status)
+	assert.Equal(t, StatusDown, report.Checks[1].Status)
+	assert.Contains(t, report.Checks[1].Error, "panicked")
+	assert.Equal(t, StatusDown, report.Checks[2].Status)
+	assert.Contains(t, report.Checks[2].Error, "timed out after 10ms")
+
+	registry, _ = newTestRegistry()
+	require.NoError(t, registry.Register(Check{Name: "db", Critical: true, Check: hang}))
+	assert.Equal(t, StatusDown, registry.Readiness(context.Background()).Status, "the default timeout applies")
+}
+
+// TestReadiness_CallerCancelled 探针取消不影响检查，不会缓存由取消导致的 down
+func TestReadiness_CallerCancelled(t *testing.T) {
+	registry, _ := newTestRegistry()
+	require.NoError(t, registry.Register(Check{Name: "db", Critical: true, Check: func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+			return nil
+		}
+	}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := registry.Readiness(ctx)
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Same(t, report, registry.Readiness(context.Background()))
+
+	registry, _ = newTestRegistry()
+	require.NoError(t, registry.Register(Check{Name: "db", Critical: true, Check: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}}))
+	assert.Equal(t, StatusDown, registry.Readiness(ctx).Status)
+}
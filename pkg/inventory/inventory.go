@@ -0,0 +1,356 @@
+// Package inventory 基于 Redis 的库存预留：预留 → 确认 → 释放，预留超时未确认时自动释放，
+// 定期以数据库为准对账。适用于优惠券、秒杀 SKU 与座位类资源（每个座位为容量 1 的资源，ReserveAll 原子地预留多个座位）
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrOutOfStock 可用库存不足
+	ErrOutOfStock = errors.New("inventory out of stock")
+	// ErrAlreadyHeld 持有者已预留或占用该资源
+	ErrAlreadyHeld = errors.New("inventory already held")
+	// ErrUnknownResource 资源未初始化且没有可恢复的数据源
+	ErrUnknownResource = errors.New("unknown inventory resource")
+	// ErrReservationNotFound 持有者没有该资源的预留（已释放或已过期）
+	ErrReservationNotFound = errors.New("inventory reservation not found")
+	// ErrInvalidItem 资源 ID 为空或包含 "/"、数量不为正数或同一资源重复出现
+	ErrInvalidItem = errors.New("invalid inventory item")
+)
+
+// ReserveError 预留失败的资源，errors.Is 可判断原因
+type ReserveError struct {
+	Kind       string
+	ResourceID string
+	Err        error
+}
+
+func (e *ReserveError) Error() string {
+	return fmt.Sprintf("failed to reserve %s %s: %v", e.Kind, e.ResourceID, e.Err)
+}
+
+func (e *ReserveError) Unwrap() error {
+	return e.Err
+}
+
+// Config 库存配置
+type Config struct {
+	ReservationTTL    time.Duration // 预留未指定期限时使用
+	SweepInterval     time.Duration // 释放过期预留的间隔
+	SweepBatch        int           // 每次释放的预留数上限
+	ReconcileInterval time.Duration // 与数据源对账的间隔
+}
+
+// DefaultConfig 默认库存配置
+func DefaultConfig() *Config {
+	return &Config{
+		ReservationTTL:    5 * time.Minute,
+		SweepInterval:     30 * time.Second,
+		SweepBatch:        500,
+		ReconcileInterval: 5 * time.Minute,
+	}
+}
+
+// Item 预留的资源与数量
+type Item struct {
+	ResourceID string `json:"resourceId"`
+	Quantity   int64  `json:"quantity"`
+}
+
+// Manager 按资源类型（kind）管理库存。Redis 中每个资源保存可用数量与持有者占用的数量，
+// 未确认的预留记录在该类型的过期集合中；确认后的占用只在释放或对账时归还
+type Manager struct {
+	rdb    *redis.Client
+	config *Config
+	clock  clock.Clock
+
+	mu        sync.RWMutex
+	sources   map[string]Source
+	used      map[string]bool      // 在本实例预留过的类型
+	scheduled map[resourceKey]bool // 等待对账的资源，见 ScheduleReconcile
+}
+
+// resourceKey 资源类型与资源 ID
+type resourceKey struct {
+	kind       string
+	resourceID string
+}
+
+// NewManager 创建库存管理
+func NewManager(rdb *redis.Client, config *Config) *Manager {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Manager{
+		rdb:       rdb,
+		config:    config,
+		clock:     clock.OrReal(nil),
+		sources:   make(map[string]Source),
+		used:      make(map[string]bool),
+		scheduled: make(map[resourceKey]bool),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (m *Manager) SetClock(c clock.Clock) {
+	m.clock = clock.OrReal(c)
+}
+
+// StockKey 资源可用数量的键，供需要在脚本中读取库存的组件使用
+func StockKey(kind, resourceID string) string {
+	return fmt.Sprintf("inventory:%s:%s:stock", kind, resourceID)
+}
+
+func holdsKey(kind, resourceID string) string {
+	return fmt.Sprintf("inventory:%s:%s:holds", kind, resourceID)
+}
+
+// expiringKey 该类型未确认的预留，成员为 资源ID/持有者，分数为过期时间（毫秒）
+func expiringKey(kind string) string {
+	return fmt.Sprintf("inventory:%s:expiring", kind)
+}
+
+func expiringMember(resourceID, holder string) string {
+	return resourceID + "/" + holder
+}
+
+// 预留结果
+const (
+	reserveOK            = 1
+	reserveHeld          = -1
+	reserveOutOfStock    = -2
+	reserveUninitialized = -3
+)
+
+// Lua 脚本：逐个检查资源已初始化、持有者未占用、库存足够，全部满足后扣减并记录，任一不满足时不做任何修改。
+// KEYS[1] 为过期集合，之后每个资源依次为库存键与占用键；ARGV[1] 持有者、ARGV[2] 过期时间，之后每个资源依次为过期成员与数量
+var reserveScript = redis.NewScript(`
+	local n = (#KEYS - 1) / 2
+	for i = 1, n do
+		local stock = redis.call("GET", KEYS[2 * i])
+		if not stock then
+			return {-3, i}
+		end
+		if redis.call("HEXISTS", KEYS[2 * i + 1], ARGV[1]) == 1 then
+			return {-1, i}
+		end
+		if tonumber(stock) < tonumber(ARGV[2 + 2 * i]) then
+			return {-2, i}
+		end
+	end
+	for i = 1, n do
+		redis.call("DECRBY", KEYS[2 * i], ARGV[2 + 2 * i])
+		redis.call("HSET", KEYS[2 * i + 1], ARGV[1], ARGV[2 + 2 * i])
+		redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1 + 2 * i])
+	end
+	return {1, 0}
+`)
+
+// Lua 脚本：确认预留，移出过期集合；持有者没有占用时返回 0
+var confirmScript = redis.NewScript(`
+	if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+		return 0
+	end
+	redis.call("ZREM", KEYS[2], ARGV[2])
+	return 1
+`)
+
+// Lua 脚本：释放占用并归还库存，返回归还的数量，重复释放时返回 0。
+// ARGV[3] 不为空时只释放在该时间之前过期且仍未确认的预留，避免与确认并发时误释放
+var releaseScript = redis.NewScript(`
+	if ARGV[3] ~= "" then
+		local score = redis.call("ZSCORE", KEYS[3], ARGV[2])
+		if not score or tonumber(score) > tonumber(ARGV[3]) then
+			return 0
+		end
+	end
+	redis.call("ZREM", KEYS[3], ARGV[2])
+	local quantity = redis.call("HGET", KEYS[2], ARGV[1])
+	if not quantity then
+		return 0
+	end
+	redis.call("HDEL", KEYS[2], ARGV[1])
+	if redis.call("EXISTS", KEYS[1]) == 1 then
+		redis.call("INCRBY", KEYS[1], quantity)
+	end
+	return tonumber(quantity)
+`)
+
+// Lua 脚本：按 容量 - 已占用 校准库存，返回 {校准前, 校准后}，校准前为 -1 表示未初始化
+var calibrateScript = redis.NewScript(`
+	local held = 0
+	for _, quantity in ipairs(redis.call("HVALS", KEYS[2])) do
+		held = held + tonumber(quantity)
+	end
+	local expected = tonumber(ARGV[1]) - held
+	if expected < 0 then
+		expected = 0
+	end
+	local current = redis.call("GET", KEYS[1])
+	if current == false then
+		current = -1
+	else
+		current = tonumber(current)
+	end
+	if current ~= expected then
+		redis.call("SET", KEYS[1], expected)
+	end
+	return {current, expected}
+`)
+
+// Reserve 为持有者预留资源，ttl 内未确认时自动释放，ttl 为 0 时使用 ReservationTTL
+func (m *Manager) Reserve(ctx context.Context, kind, holder string, item Item, ttl time.Duration) error {
+	return m.ReserveAll(ctx, kind, holder, []Item{item}, ttl)
+}
+
+// ReserveAll 原子地预留多个资源，任一资源不可预留时全部不预留并返回 *ReserveError。
+// 资源未初始化且登记了数据源时，先对账初始化再重试一次
+func (m *Manager) ReserveAll(ctx context.Context, kind, holder string, items []Item, ttl time.Duration) error {
+	if err := validateItems(items); err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		ttl = m.config.ReservationTTL
+	}
+	m.track(kind)
+
+	keys := []string{expiringKey(kind)}
+	args := []interface{}{holder, m.clock.Now().Add(ttl).UnixMilli()}
+	for _, item := range items {
+		keys = append(keys, StockKey(kind, item.ResourceID), holdsKey(kind, item.ResourceID))
+		args = append(args, expiringMember(item.ResourceID, holder), item.Quantity)
+	}
+
+	result, err := reserveScript.Run(ctx, m.rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return fmt.Errorf("failed to reserve inventory: %w", err)
+	}
+	if result[0] == reserveUninitialized && m.source(kind) != nil {
+		// 库存键丢失（如 Redis 重启）或首次使用，按数据源恢复后重试一次
+		if _, err := m.Reconcile(ctx, kind, items[result[1]-1].ResourceID); err != nil {
+			return &ReserveError{Kind: kind, ResourceID: items[result[1]-1].ResourceID, Err: err}
+		}
+		if result, err = reserveScript.Run(ctx, m.rdb, keys, args...).Int64Slice(); err != nil {
+			return fmt.Errorf("failed to reserve inventory: %w", err)
+		}
+	}
+
+	var reason error
+	switch result[0] {
+	case reserveOK:
+		return nil
+	case reserveHeld:
+		reason = ErrAlreadyHeld
+	case reserveOutOfStock:
+		reason = ErrOutOfStock
+	default:
+		reason = ErrUnknownResource
+	}
+	return &ReserveError{Kind: kind, ResourceID: items[result[1]-1].ResourceID, Err: reason}
+}
+
+// Confirm 确认预留，确认后不再自动释放；预留已释放或已过期时返回 ErrReservationNotFound，已确认时直接返回
+func (m *Manager) Confirm(ctx context.Context, kind, holder, resourceID string) error {
+	confirmed, err := confirmScript.Run(ctx, m.rdb, []string{holdsKey(kind, resourceID), expiringKey(kind)},
+		holder, expiringMember(resourceID, holder)).Int()
+	if err != nil {
+		return fmt.Errorf("failed to confirm inventory reservation: %w", err)
+	}
+	if confirmed == 0 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+// Release 释放持有者对资源的预留或占用并归还库存，返回归还的数量，没有占用时返回 0
+func (m *Manager) Release(ctx context.Context, kind, holder, resourceID string) (int64, error) {
+	return m.release(ctx, kind, holder, resourceID, "")
+}
+
+func (m *Manager) release(ctx context.Context, kind, holder, resourceID, deadline string) (int64, error) {
+	released, err := releaseScript.Run(ctx, m.rdb,
+		[]string{StockKey(kind, resourceID), holdsKey(kind, resourceID), expiringKey(kind)},
+		holder, expiringMember(resourceID, holder), deadline).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to release inventory: %w", err)
+	}
+	return released, nil
+}
+
+// Held 持有者对资源占用的数量，没有占用时返回 0
+func (m *Manager) Held(ctx context.Context, kind, holder, resourceID string) (int64, error) {
+	quantity, err := m.rdb.HGet(ctx, holdsKey(kind, resourceID), holder).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read inventory hold: %w", err)
+	}
+	return quantity, nil
+}
+
+// Available 资源的可用数量，未初始化时返回 ErrUnknownResource
+func (m *Manager) Available(ctx context.Context, kind, resourceID string) (int64, error) {
+	stock, err := m.rdb.Get(ctx, StockKey(kind, resourceID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrUnknownResource
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read inventory stock: %w", err)
+	}
+	return stock, nil
+}
+
+// SetCapacity 新建资源时初始化库存，已有占用时按 容量 - 已占用 设置
+func (m *Manager) SetCapacity(ctx context.Context, kind, resourceID string, capacity int64) error {
+	if _, err := m.calibrate(ctx, kind, resourceID, capacity); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Manager) calibrate(ctx context.Context, kind, resourceID string, capacity int64) ([]int64, error) {
+	stock, err := calibrateScript.Run(ctx, m.rdb, []string{StockKey(kind, resourceID), holdsKey(kind, resourceID)}, capacity).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calibrate inventory: %w", err)
+	}
+	return stock, nil
+}
+
+func validateItems(items []Item) error {
+	if len(items) == 0 {
+		return ErrInvalidItem
+	}
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.ResourceID == "" || strings.Contains(item.ResourceID, "/") || item.Quantity <= 0 || seen[item.ResourceID] {
+			return fmt.Errorf("%w: %q", ErrInvalidItem, item.ResourceID)
+		}
+		seen[item.ResourceID] = true
+	}
+	return nil
+}
+
+// parseExpiringMember 拆分过期集合的成员，资源 ID 不含 "/"，持有者可以包含
+func parseExpiringMember(member string) (resourceID, holder string, ok bool) {
+	i := strings.IndexByte(member, '/')
+	if i <= 0 {
+		return "", "", false
+	}
+	return member[:i], member[i+1:], true
+}
+
+// deadline 释放过期预留时的截止时间参数
+func deadline(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
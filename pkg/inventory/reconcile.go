@@ -0,0 +1,317 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Source 资源类型在数据库中的真实数据，对账时以其为准
+type Source interface {
+	// Capacity 资源总量，资源不存在时返回 ErrUnknownResource
+	Capacity(ctx context.Context, resourceID string) (int64, error)
+	// Committed 已落库的占用：持有者 -> 数量
+	Committed(ctx context.Context, resourceID string) (map[string]int64, error)
+	// ActiveResources 需要定期对账的资源
+	ActiveResources(ctx context.Context) ([]string, error)
+}
+
+// ReconcileResult 一次对账的结果
+type ReconcileResult struct {
+	Kind        string `json:"kind"`
+	ResourceID  string `json:"resource_id"`
+	Restored    int    `json:"restored"`    // 数据库有占用但 Redis 缺失，已补回
+	Compensated int    `json:"compensated"` // Redis 已确认但数据库没有记录，已释放
+	Confirmed   int    `json:"confirmed"`   // 已落库但仍在等待确认的预留，已确认
+	StockBefore int64  `json:"stock_before"`
+	StockAfter  int64  `json:"stock_after"`
+}
+
+// SweepResult 一次过期释放的结果
+type SweepResult struct {
+	Kind     string `json:"kind"`
+	Released int    `json:"released"` // 释放的预留数
+	Quantity int64  `json:"quantity"` // 归还的库存数量
+}
+
+// RegisterSource 登记资源类型的数据源，登记后该类型参与定期对账，未初始化的资源在预留时自动恢复
+func (m *Manager) RegisterSource(kind string, source Source) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sources[kind] = source
+}
+
+func (m *Manager) source(kind string) Source {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sources[kind]
+}
+
+// track 记录在本实例预留过的类型，定期释放其过期预留
+func (m *Manager) track(kind string) {
+	m.mu.RLock()
+	tracked := m.used[kind]
+	m.mu.RUnlock()
+	if !tracked {
+		m.mu.Lock()
+		m.used[kind] = true
+		m.mu.Unlock()
+	}
+}
+
+// kinds 已登记数据源的类型，sweeping 为 true 时加上在本实例预留过的类型，按名称排序
+func (m *Manager) kinds(sweeping bool) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	set := make(map[string]bool, len(m.sources)+len(m.used))
+	for kind := range m.sources {
+		set[kind] = true
+	}
+	if sweeping {
+		for kind := range m.used {
+			set[kind] = true
+		}
+	}
+	kinds := make([]string, 0, len(set))
+	for kind := range set {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Reconcile 以数据源为准对账单个资源：补回缺失的占用、释放数据库中没有记录的已确认占用、确认已落库的预留，并校准库存。
+// 仍在等待确认的预留不处理，由过期释放兜底。
+//
+// 先读 Redis 的占用与待确认状态，再读数据库：占用只在领取记录落库后才确认，读到的已确认占用在读数据库时必然可见。
+// 反过来先读数据库时，读取之后落库并确认的占用会被当作没有记录而释放，库存被重复售出
+func (m *Manager) Reconcile(ctx context.Context, kind, resourceID string) (*ReconcileResult, error) {
+	source := m.source(kind)
+	if source == nil {
+		return nil, fmt.Errorf("no inventory source for %s", kind)
+	}
+	capacity, err := source.Capacity(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := m.rdb.HGetAll(ctx, holdsKey(kind, resourceID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory holds: %w", err)
+	}
+	pending, err := m.pending(ctx, kind, resourceID, holds)
+	if err != nil {
+		return nil, err
+	}
+	committed, err := source.Committed(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{Kind: kind, ResourceID: resourceID}
+	for holder := range holds {
+		_, inDB := committed[holder]
+		switch {
+		case inDB && pending[holder]:
+			if err := m.Confirm(ctx, kind, holder, resourceID); err == nil {
+				result.Confirmed++
+			} else if !errors.Is(err, ErrReservationNotFound) {
+				return nil, err
+			}
+		case !inDB && !pending[holder]:
+			released, err := m.Release(ctx, kind, holder, resourceID)
+			if err != nil {
+				return nil, err
+			}
+			if released > 0 {
+				result.Compensated++
+			}
+		}
+	}
+
+	for holder, quantity := range committed {
+		if _, ok := holds[holder]; ok {
+			continue
+		}
+		// 读取后可能有并发的预留写入同一持有者，只在不存在时补回
+		restored, err := m.rdb.HSetNX(ctx, holdsKey(kind, resourceID), holder, quantity).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore inventory hold: %w", err)
+		}
+		if restored {
+			result.Restored++
+		}
+	}
+
+	stock, err := m.calibrate(ctx, kind, resourceID, capacity)
+	if err != nil {
+		return nil, err
+	}
+	result.StockBefore, result.StockAfter = stock[0], stock[1]
+
+	if result.Restored > 0 || result.Compensated > 0 || result.StockBefore != result.StockAfter {
+		log.Printf("Inventory %s %s reconciled: restored=%d compensated=%d stock %d -> %d",
+			kind, resourceID, result.Restored, result.Compensated, result.StockBefore, result.StockAfter)
+	}
+	return result, nil
+}
+
+// ConfirmCommitted 业务记录落库后确认预留。确认失败时（预留已被过期释放、库存已归还，或 Redis 出错）
+// 业务记录已经存在，立即以数据源对账补回占用并重新扣减库存，避免同一份库存再次售出；
+// 对账也失败时登记到 ScheduleReconcile 重试。返回确认失败的原因，供调用方记录
+func (m *Manager) ConfirmCommitted(ctx context.Context, kind, holder, resourceID string) error {
+	err := m.Confirm(ctx, kind, holder, resourceID)
+	if err == nil || m.source(kind) == nil {
+		return err
+	}
+	if _, reconcileErr := m.Reconcile(ctx, kind, resourceID); reconcileErr != nil {
+		log.Printf("Failed to reconcile inventory %s %s after confirm failure: %v", kind, resourceID, reconcileErr)
+		m.ScheduleReconcile(kind, resourceID)
+	}
+	return err
+}
+
+// ScheduleReconcile 登记资源在下次释放过期预留时对账，对账失败时保留登记继续重试。
+// 用于库存可能已与数据库不一致、又不能等待定期对账的情况
+func (m *Manager) ScheduleReconcile(kind, resourceID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduled[resourceKey{kind: kind, resourceID: resourceID}] = true
+}
+
+// reconcileScheduled 对账已登记的资源，成功的移出登记
+func (m *Manager) reconcileScheduled(ctx context.Context) {
+	m.mu.RLock()
+	keys := make([]resourceKey, 0, len(m.scheduled))
+	for key := range m.scheduled {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+
+	for _, key := range keys {
+		if _, err := m.Reconcile(ctx, key.kind, key.resourceID); err != nil {
+			log.Printf("Failed to reconcile scheduled inventory %s %s: %v", key.kind, key.resourceID, err)
+			continue
+		}
+		m.mu.Lock()
+		delete(m.scheduled, key)
+		m.mu.Unlock()
+	}
+}
+
+// pending 占用中仍在等待确认的持有者
+func (m *Manager) pending(ctx context.Context, kind, resourceID string, holds map[string]string) (map[string]bool, error) {
+	pending := make(map[string]bool, len(holds))
+	if len(holds) == 0 {
+		return pending, nil
+	}
+	pipe := m.rdb.Pipeline()
+	scores := make(map[string]*redis.FloatCmd, len(holds))
+	for holder := range holds {
+		scores[holder] = pipe.ZScore(ctx, expiringKey(kind), expiringMember(resourceID, holder))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to read inventory reservations: %w", err)
+	}
+	for holder, cmd := range scores {
+		pending[holder] = cmd.Err() == nil
+	}
+	return pending, nil
+}
+
+// ReconcileAll 对账所有已登记类型的进行中资源，单个资源失败时记录日志后继续
+func (m *Manager) ReconcileAll(ctx context.Context) ([]*ReconcileResult, error) {
+	var results []*ReconcileResult
+	for _, kind := range m.kinds(false) {
+		ids, err := m.source(kind).ActiveResources(ctx)
+		if err != nil {
+			return results, fmt.Errorf("failed to list %s resources: %w", kind, err)
+		}
+		for _, id := range ids {
+			result, err := m.Reconcile(ctx, kind, id)
+			if err != nil {
+				log.Printf("Failed to reconcile inventory %s %s: %v", kind, id, err)
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Sweep 释放该类型已过期且未确认的预留，每批最多 SweepBatch 个，直到没有过期的预留
+func (m *Manager) Sweep(ctx context.Context, kind string) (*SweepResult, error) {
+	now := deadline(m.clock.Now())
+	result := &SweepResult{Kind: kind}
+	for {
+		members, err := m.rdb.ZRangeByScore(ctx, expiringKey(kind), &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   now,
+			Count: int64(m.config.SweepBatch),
+		}).Result()
+		if err != nil {
+			return result, fmt.Errorf("failed to list expired reservations: %w", err)
+		}
+
+		for _, member := range members {
+			resourceID, holder, ok := parseExpiringMember(member)
+			if !ok {
+				m.rdb.ZRem(ctx, expiringKey(kind), member)
+				continue
+			}
+			released, err := m.release(ctx, kind, holder, resourceID, now)
+			if err != nil {
+				return result, err
+			}
+			if released > 0 {
+				result.Released++
+				result.Quantity += released
+			}
+		}
+		if len(members) < m.config.SweepBatch {
+			return result, nil
+		}
+	}
+}
+
+// SweepAll 释放所有已登记数据源或在本实例预留过的类型的过期预留
+func (m *Manager) SweepAll(ctx context.Context) []*SweepResult {
+	var results []*SweepResult
+	for _, kind := range m.kinds(true) {
+		result, err := m.Sweep(ctx, kind)
+		if err != nil {
+			log.Printf("Failed to sweep %s reservations: %v", kind, err)
+		}
+		if result != nil && result.Released > 0 {
+			log.Printf("Released %d expired %s reservations (%d units)", result.Released, kind, result.Quantity)
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// Run 按 SweepInterval 释放过期预留并对账登记的资源、按 ReconcileInterval 对账，直到 ctx 取消
+func (m *Manager) Run(ctx context.Context) {
+	sweep := m.clock.NewTicker(m.config.SweepInterval)
+	defer sweep.Stop()
+	reconcile := m.clock.NewTicker(m.config.ReconcileInterval)
+	defer reconcile.Stop()
+
+	for {
+		select {
+		case <-sweep.C():
+			m.SweepAll(ctx)
+			m.reconcileScheduled(ctx)
+		case <-reconcile.C():
+			if _, err := m.ReconcileAll(ctx); err != nil {
+				log.Printf("Failed to reconcile inventory: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
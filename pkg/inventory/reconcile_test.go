@@ -0,0 +1,183 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRedis 以 Hook 应答对账用到的命令，不连接 Redis：HGETALL 返回 holds，
+// 所有预留都已确认（ZSCORE 不存在），脚本按 SHA 记录调用并返回固定结果
+type stubRedis struct {
+	holds     map[string]string
+	onHGetAll func() // 读取占用后执行，模拟并发的领取
+	released  bool   // 预留已被过期释放，确认返回 0
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newStubClient(stub *stubRedis) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: "stub:6379"})
+	client.AddHook(stub)
+	return client
+}
+
+func (s *stubRedis) record(name string) {
+	s.mu.Lock()
+	s.commands = append(s.commands, name)
+	s.mu.Unlock()
+}
+
+func (s *stubRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, net.ErrClosed
+	}
+}
+
+func (s *stubRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		name := strings.ToLower(cmd.Name())
+		switch c := cmd.(type) {
+		case *redis.MapStringStringCmd:
+			s.record(name)
+			c.SetVal(s.holds)
+			if s.onHGetAll != nil {
+				s.onHGetAll()
+			}
+		case *redis.BoolCmd:
+			s.record(name)
+			c.SetVal(true)
+		case *redis.Cmd:
+			sha, _ := cmd.Args()[1].(string)
+			switch sha {
+			case calibrateScript.Hash():
+				s.record("calibrate")
+				c.SetVal([]interface{}{int64(0), int64(0)})
+			case releaseScript.Hash():
+				s.record("release")
+				c.SetVal(int64(1))
+			case confirmScript.Hash():
+				s.record("confirm")
+				if s.released {
+					c.SetVal(int64(0))
+				} else {
+					c.SetVal(int64(1))
+				}
+			default:
+				c.SetErr(redis.Nil)
+			}
+		}
+		return cmd.Err()
+	}
+}
+
+func (s *stubRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			s.record(strings.ToLower(cmd.Name()))
+			cmd.SetErr(redis.Nil)
+		}
+		return nil
+	}
+}
+
+// stubSource 记录读取顺序的数据源
+type stubSource struct {
+	committed map[string]int64
+	read      func()
+	err       error // 不为 nil 时读取容量失败
+}
+
+func (s *stubSource) Capacity(context.Context, string) (int64, error) { return 10, s.err }
+
+func (s *stubSource) Committed(context.Context, string) (map[string]int64, error) {
+	if s.read != nil {
+		s.read()
+	}
+	committed := make(map[string]int64, len(s.committed))
+	for holder, quantity := range s.committed {
+		committed[holder] = quantity
+	}
+	return committed, nil
+}
+
+func (s *stubSource) ActiveResources(context.Context) ([]string, error) { return nil, nil }
+
+// TestReconcile_ReadsHoldsBeforeDatabase 读取占用之后才落库并确认的领取不被当作多余占用释放
+func TestReconcile_ReadsHoldsBeforeDatabase(t *testing.T) {
+	source := &stubSource{committed: map[string]int64{}}
+	stub := &stubRedis{
+		holds: map[string]string{"u1": "1"},
+		// u1 的领取在读取占用时已确认，说明此前已经落库
+		onHGetAll: func() { source.committed["u1"] = 1 },
+	}
+	source.read = func() { stub.record("committed") }
+
+	m := NewManager(newStubClient(stub), nil)
+	m.RegisterSource("coupon", source)
+
+	result, err := m.Reconcile(context.Background(), "coupon", "c1")
+	require.NoError(t, err)
+	assert.Zero(t, result.Compensated)
+	assert.NotContains(t, stub.commands, "release")
+	assert.Equal(t, []string{"hgetall", "zscore", "committed", "calibrate"}, stub.commands)
+}
+
+// TestReconcile_ReleasesUncommittedHolds 已确认但数据库没有记录的占用被释放，数据库有记录而 Redis 缺失的占用被补回
+func TestReconcile_ReleasesUncommittedHolds(t *testing.T) {
+	stub := &stubRedis{holds: map[string]string{"u1": "1"}}
+	m := NewManager(newStubClient(stub), nil)
+	m.RegisterSource("coupon", &stubSource{committed: map[string]int64{"u2": 1}})
+
+	result, err := m.Reconcile(context.Background(), "coupon", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Compensated)
+	assert.Equal(t, 1, result.Restored)
+	assert.Contains(t, stub.commands, "release")
+	assert.Contains(t, stub.commands, "hsetnx")
+}
+
+// TestConfirmCommitted_ReleasedReservation 预留已被过期释放时，按数据库补回占用并校准库存，库存不会再次售出
+func TestConfirmCommitted_ReleasedReservation(t *testing.T) {
+	stub := &stubRedis{holds: map[string]string{}, released: true}
+	m := NewManager(newStubClient(stub), nil)
+	m.RegisterSource("coupon", &stubSource{committed: map[string]int64{"u1": 1}})
+
+	err := m.ConfirmCommitted(context.Background(), "coupon", "u1", "c1")
+	assert.ErrorIs(t, err, ErrReservationNotFound)
+	assert.Equal(t, []string{"confirm", "hgetall", "hsetnx", "calibrate"}, stub.commands)
+	assert.Empty(t, m.scheduled)
+}
+
+// TestConfirmCommitted_ScheduleReconcile 对账失败时登记资源，释放过期预留时重试，成功后移出登记
+func TestConfirmCommitted_ScheduleReconcile(t *testing.T) {
+	stub := &stubRedis{holds: map[string]string{}, released: true}
+	m := NewManager(newStubClient(stub), nil)
+	source := &stubSource{committed: map[string]int64{"u1": 1}, err: errors.New("database unavailable")}
+	m.RegisterSource("coupon", source)
+
+	assert.ErrorIs(t, m.ConfirmCommitted(context.Background(), "coupon", "u1", "c1"), ErrReservationNotFound)
+	assert.True(t, m.scheduled[resourceKey{kind: "coupon", resourceID: "c1"}])
+
+	m.reconcileScheduled(context.Background())
+	assert.Len(t, m.scheduled, 1, "kept while the source is unavailable")
+
+	source.err = nil
+	m.reconcileScheduled(context.Background())
+	assert.Empty(t, m.scheduled)
+	assert.Contains(t, stub.commands, "hsetnx")
+	assert.Contains(t, stub.commands, "calibrate")
+
+	// 确认成功时不对账
+	stub.released, stub.commands = false, nil
+	require.NoError(t, m.ConfirmCommitted(context.Background(), "coupon", "u2", "c1"))
+	assert.Equal(t, []string{"confirm"}, stub.commands)
+}
@@ -0,0 +1,166 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 标准 5 字段 cron 表达式：分 时 日 月 周
+//
+// 支持 *、*/n、a-b、a-b/n 与逗号列表，以及 @hourly、@daily、@weekly、@monthly 别名。
+type CronSchedule struct {
+	spec    string
+	minute  []bool
+	hour    []bool
+	day     []bool
+	month   []bool
+	weekday []bool
+	dayAny  bool // 日字段为 *
+	weekAny bool // 周字段为 *
+}
+
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseCron 解析 cron 表达式
+func ParseCron(spec string) (*CronSchedule, error) {
+	expr := strings.TrimSpace(spec)
+	if alias, exists := cronAliases[expr]; exists {
+		expr = alias
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron spec %q: expected 5 fields", spec)
+	}
+
+	schedule := &CronSchedule{
+		spec:    spec,
+		dayAny:  fields[2] == "*",
+		weekAny: fields[4] == "*",
+	}
+
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid cron minute: %w", err)
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid cron hour: %w", err)
+	}
+	if schedule.day, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid cron day: %w", err)
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid cron month: %w", err)
+	}
+	if schedule.weekday, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid cron weekday: %w", err)
+	}
+	// 7 与 0 都表示周日
+	if schedule.weekday[7] {
+		schedule.weekday[0] = true
+	}
+
+	return schedule, nil
+}
+
+// parseCronField 解析单个字段，返回下标即取值的位图
+func parseCronField(field string, lo, hi int) ([]bool, error) {
+	values := make([]bool, hi+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:idx]
+		}
+
+		start, end := lo, hi
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			a, err1 := strconv.Atoi(bounds[0])
+			b, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			start, end = a, b
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			start, end = n, n
+			if step > 1 {
+				end = hi
+			}
+		}
+
+		if start < lo || end > hi || start > end {
+			return nil, fmt.Errorf("value out of range [%d, %d] in %q", lo, hi, part)
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Next 返回 after 之后（不含）的下一次触发时间，按分钟精度
+func (cs *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// 最多向后搜索 5 年，覆盖 2 月 29 日等稀疏表达式
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !cs.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !cs.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !cs.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !cs.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay 日与周字段均受限时按 cron 惯例取并集
+func (cs *CronSchedule) matchDay(t time.Time) bool {
+	dayMatch := cs.day[t.Day()]
+	weekMatch := cs.weekday[int(t.Weekday())]
+	switch {
+	case cs.dayAny && cs.weekAny:
+		return true
+	case cs.dayAny:
+		return weekMatch
+	case cs.weekAny:
+		return dayMatch
+	default:
+		return dayMatch || weekMatch
+	}
+}
+
+// String 返回原始表达式
+func (cs *CronSchedule) String() string {
+	return cs.spec
+}
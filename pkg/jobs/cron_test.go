@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCronSchedule_Next 步长、范围、别名、日与周取并集、7 表示周日
+func TestCronSchedule_Next(t *testing.T) {
+	at := func(s string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", s)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	// 2024-01-01 为周一
+	tests := []struct {
+		name  string
+		spec  string
+		after string
+		want  []string
+	}{
+		{"every minute excludes after", "* * * * *", "2024-01-01 10:00:00", []string{"2024-01-01 10:01:00", "2024-01-01 10:02:00"}},
+		{"truncates seconds", "* * * * *", "2024-01-01 10:00:59", []string{"2024-01-01 10:01:00"}},
+		{"step", "*/15 * * * *", "2024-01-01 10:07:00", []string{"2024-01-01 10:15:00", "2024-01-01 10:30:00"}},
+		{"range with step", "10-30/10 * * * *", "2024-01-01 10:25:00", []string{"2024-01-01 10:30:00", "2024-01-01 11:10:00"}},
+		{"value with step runs to the end", "5/20 * * * *", "2024-01-01 10:30:00", []string{"2024-01-01 10:45:00", "2024-01-01 11:05:00"}},
+		{"list", "0 8,20 * * *", "2024-01-01 09:00:00", []string{"2024-01-01 20:00:00", "2024-01-02 08:00:00"}},
+		{"weekdays", "0 9 * * 1-5", "2024-01-05 10:00:00", []string{"2024-01-08 09:00:00"}},
+		{"day of month and weekday are a union", "0 0 13 * 5", "2024-01-01 00:00:00", []string{"2024-01-05 00:00:00", "2024-01-12 00:00:00", "2024-01-13 00:00:00", "2024-01-19 00:00:00"}},
+		{"7 is sunday", "30 8 * * 7", "2024-01-01 00:00:00", []string{"2024-01-07 08:30:00", "2024-01-14 08:30:00"}},
+		{"0 is sunday", "30 8 * * 0", "2024-01-01 00:00:00", []string{"2024-01-07 08:30:00"}},
+		{"restricted day only", "0 0 31 * *", "2024-01-31 00:00:00", []string{"2024-03-31 00:00:00"}},
+		{"monthly alias", "@monthly", "2024-01-15 12:00:00", []string{"2024-02-01 00:00:00"}},
+		{"weekly alias", "@weekly", "2024-01-01 00:00:00", []string{"2024-01-07 00:00:00"}},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00:00", []string{"2028-02-29 00:00:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.spec, schedule.String())
+			next := at(tt.after)
+			for _, want := range tt.want {
+				next = schedule.Next(next)
+				assert.Equal(t, at(want), next)
+			}
+		})
+	}
+
+	impossible, err := ParseCron("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, impossible.Next(at("2024-01-01 00:00:00")).IsZero())
+}
+
+// TestParseCron_Invalid 字段数、取值范围、步长与范围格式错误
+func TestParseCron_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@yearly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"a * * * *",
+	} {
+		_, err := ParseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobView 任务接口视图
+type JobView struct {
+	ID          int64       `json:"id"`
+	Type        string      `json:"type"`
+	Payload     interface{} `json:"payload,omitempty"`
+	Priority    int         `json:"priority"`
+	Status      Status      `json:"status"`
+	Attempts    int         `json:"attempts"`
+	MaxAttempts int         `json:"max_attempts"`
+	LastError   string      `json:"last_error,omitempty"`
+	RunAt       time.Time   `json:"run_at"`
+	LockedBy    string      `json:"locked_by,omitempty"`
+	StartedAt   *time.Time  `json:"started_at,omitempty"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// NewJobView 转换为接口视图
+func NewJobView(job *Job) JobView {
+	view := JobView{
+		ID:          job.ID,
+		Type:        job.Type,
+		Priority:    job.Priority,
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		LastError:   job.LastError.String,
+		RunAt:       job.RunAt,
+		LockedBy:    job.LockedBy.String,
+		CreatedAt:   job.CreatedAt,
+	}
+	if len(job.Payload) > 0 {
+		view.Payload = job.Payload
+	}
+	if job.StartedAt.Valid {
+		view.StartedAt = &job.StartedAt.Time
+	}
+	if job.FinishedAt.Valid {
+		view.FinishedAt = &job.FinishedAt.Time
+	}
+	return view
+}
+
+// Handler 任务管理接口
+type Handler struct {
+	manager *Manager
+}
+
+// NewHandler 创建任务管理接口
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// RegisterRoutes 注册管理路由，调用方需自行挂载管理员鉴权中间件
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/jobs", h.ListJobs)
+	group.GET("/jobs/stats", h.GetStats)
+	group.GET("/jobs/crons", h.ListCrons)
+	group.GET("/jobs/:id", h.GetJob)
+	group.POST("/jobs/:id/retry", h.RetryJob)
+	group.POST("/jobs/:id/cancel", h.CancelJob)
+}
+
+// ListJobs 查询任务状态与历史
+func (h *Handler) ListJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	jobs, err := h.manager.Store().List(c.Request.Context(), JobFilter{
+		Type:   c.Query("type"),
+		Status: Status(c.Query("status")),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to list jobs",
+		})
+		return
+	}
+
+	views := make([]JobView, len(jobs))
+	for i := range jobs {
+		views[i] = NewJobView(&jobs[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobs": views,
+	})
+}
+
+// GetStats 按状态统计任务
+func (h *Handler) GetStats(c *gin.Context) {
+	counts, err := h.manager.Store().Stats(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to count jobs",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"stats": counts,
+	})
+}
+
+// ListCrons 列出定时任务
+func (h *Handler) ListCrons(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"crons": h.manager.Crons(),
+	})
+}
+
+// GetJob 获取任务详情
+func (h *Handler) GetJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.manager.Store().Get(c.Request.Context(), id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, NewJobView(job))
+}
+
+// RetryJob 重试失败或已取消的任务
+func (h *Handler) RetryJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.manager.Store().Retry(c.Request.Context(), id); err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":     id,
+		"status": StatusPending,
+	})
+}
+
+// CancelJob 取消待执行的任务
+func (h *Handler) CancelJob(c *gin.Context) {
+	id, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.manager.Store().Cancel(c.Request.Context(), id); err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":     id,
+		"status": StatusCancelled,
+	})
+}
+
+func parseJobID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid job id",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func respondJobError(c *gin.Context, err error) {
+	if errors.Is(err, ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error": err.Error(),
+	})
+}
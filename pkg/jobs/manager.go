@@ -0,0 +1,349 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// HandlerFunc 任务处理函数，返回错误时按退避策略重试
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+// Config 任务管理器配置
+type Config struct {
+	Workers            int           `json:"workers"`
+	PollInterval       time.Duration `json:"poll_interval"`
+	JobTimeout         time.Duration `json:"job_timeout"`   // 单个任务执行超时
+	StaleTimeout       time.Duration `json:"stale_timeout"` // 运行中任务超过该时间视为执行实例已崩溃
+	MaxAttempts        int           `json:"max_attempts"`
+	BaseBackoff        time.Duration `json:"base_backoff"`
+	MaxBackoff         time.Duration `json:"max_backoff"`
+	HistoryRetention   time.Duration `json:"history_retention"` // 已结束任务保留时间
+	MaintenanceEvery   time.Duration `json:"maintenance_every"`
+	SchedulerLookahead time.Duration `json:"scheduler_lookahead"` // 定时任务提前入队的时间窗口
+}
+
+// DefaultConfig 默认任务管理器配置
+func DefaultConfig() *Config {
+	return &Config{
+		Workers:            4,
+		PollInterval:       time.Second,
+		JobTimeout:         10 * time.Minute,
+		StaleTimeout:       30 * time.Minute,
+		MaxAttempts:        5,
+		BaseBackoff:        5 * time.Second,
+		MaxBackoff:         time.Hour,
+		HistoryRetention:   7 * 24 * time.Hour,
+		MaintenanceEvery:   5 * time.Minute,
+		SchedulerLookahead: time.Minute,
+	}
+}
+
+// cronEntry 定时任务
+type cronEntry struct {
+	Name     string
+	Type     string
+	Payload  json.RawMessage
+	Priority int
+	Schedule *CronSchedule
+	Next     time.Time
+}
+
+// CronInfo 定时任务信息
+type CronInfo struct {
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Spec     string    `json:"spec"`
+	Priority int       `json:"priority"`
+	Next     time.Time `json:"next"`
+}
+
+// Manager 后台任务管理器：持久化队列、优先级、工作池、指数退避重试与定时调度。
+// 多个实例可共享同一张 jobs 表，任务通过 FOR UPDATE SKIP LOCKED 领取。
+type Manager struct {
+	store            *Store
+	config           *Config
+	workerID         string
+	handlers         map[string]HandlerFunc
+	crons            map[string]*cronEntry
+	mu               sync.RWMutex
+	wg               sync.WaitGroup
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewManager 创建任务管理器
+func NewManager(store *Store, config *Config) *Manager {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+
+	hostname, _ := os.Hostname()
+	return &Manager{
+		store:            store,
+		config:           config,
+		workerID:         fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		handlers:         make(map[string]HandlerFunc),
+		crons:            make(map[string]*cronEntry),
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// Store 返回任务存储
+func (m *Manager) Store() *Store {
+	return m.store
+}
+
+// Register 注册任务类型的处理函数
+func (m *Manager) Register(jobType string, handler HandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[jobType] = handler
+}
+
+// Enqueue 创建任务，UniqueKey 重复时返回 0
+func (m *Manager) Enqueue(ctx context.Context, jobType string, payload interface{}, opts EnqueueOptions) (int64, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return 0, fmt.Errorf("failed to marshal job payload: %w", err)
+		}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = m.config.MaxAttempts
+	}
+	return m.store.Insert(ctx, jobType, data, opts)
+}
+
+// EnqueueIn 延迟执行
+func (m *Manager) EnqueueIn(ctx context.Context, delay time.Duration, jobType string, payload interface{}, priority int) (int64, error) {
+	return m.Enqueue(ctx, jobType, payload, EnqueueOptions{Priority: priority, RunAt: time.Now().Add(delay)})
+}
+
+// Schedule 注册定时任务，name 唯一
+func (m *Manager) Schedule(name, spec, jobType string, payload interface{}, priority int) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if payload != nil {
+		if data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("failed to marshal job payload: %w", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.crons[name] = &cronEntry{
+		Name:     name,
+		Type:     jobType,
+		Payload:  data,
+		Priority: priority,
+		Schedule: schedule,
+		Next:     schedule.Next(time.Now()),
+	}
+	return nil
+}
+
+// Unschedule 移除定时任务
+func (m *Manager) Unschedule(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.crons, name)
+}
+
+// Crons 列出定时任务
+func (m *Manager) Crons() []CronInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]CronInfo, 0, len(m.crons))
+	for _, entry := range m.crons {
+		infos = append(infos, CronInfo{
+			Name:     entry.Name,
+			Type:     entry.Type,
+			Spec:     entry.Schedule.String(),
+			Priority: entry.Priority,
+			Next:     entry.Next,
+		})
+	}
+	return infos
+}
+
+// Run 启动调度与工作池，阻塞直到 ctx 取消且进行中的任务结束
+func (m *Manager) Run(ctx context.Context) {
+	queue := make(chan *Job, m.config.Workers)
+
+	for i := 0; i < m.config.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker(ctx, queue)
+	}
+
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+
+	lastMaintenance := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			close(queue)
+			m.wg.Wait()
+			return
+		case <-ticker.C:
+			m.scheduleCrons(ctx)
+
+			if time.Since(lastMaintenance) >= m.config.MaintenanceEvery {
+				m.maintain(ctx)
+				lastMaintenance = time.Now()
+			}
+
+			m.dispatch(ctx, queue)
+		}
+	}
+}
+
+// dispatch 按空闲 worker 数领取任务
+func (m *Manager) dispatch(ctx context.Context, queue chan<- *Job) {
+	free := cap(queue) - len(queue)
+	if free <= 0 {
+		return
+	}
+
+	jobs, err := m.store.Claim(ctx, m.workerID, free)
+	if err != nil {
+		log.Printf("Failed to claim jobs: %v", err)
+		return
+	}
+	for i := range jobs {
+		queue <- &jobs[i]
+	}
+}
+
+// worker 执行任务
+func (m *Manager) worker(ctx context.Context, queue <-chan *Job) {
+	defer m.wg.Done()
+	for job := range queue {
+		m.execute(ctx, job)
+	}
+}
+
+// execute 执行单个任务并记录结果
+func (m *Manager) execute(ctx context.Context, job *Job) {
+	start := time.Now()
+
+	m.mu.RLock()
+	handler, exists := m.handlers[job.Type]
+	m.mu.RUnlock()
+
+	var err error
+	if !exists {
+		err = fmt.Errorf("no handler registered for job type %s", job.Type)
+	} else {
+		err = m.invoke(ctx, handler, job)
+	}
+
+	// 结果写回不随 ctx 取消，避免任务停留在 running 状态
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if err == nil {
+		if err := m.store.Complete(writeCtx, job.ID, m.workerID); err != nil {
+			log.Printf("Failed to complete job %d: %v", job.ID, err)
+		}
+		m.recordMetrics(job.Type, time.Since(start), true)
+		return
+	}
+
+	var retryAt time.Time
+	if exists && job.Attempts < job.MaxAttempts {
+		retryAt = time.Now().Add(m.backoff(job.Attempts))
+	}
+	log.Printf("Job %d (%s) attempt %d/%d failed: %v", job.ID, job.Type, job.Attempts, job.MaxAttempts, err)
+	if err := m.store.Fail(writeCtx, job.ID, m.workerID, err, retryAt); err != nil {
+		log.Printf("Failed to record job %d failure: %v", job.ID, err)
+	}
+	m.recordMetrics(job.Type, time.Since(start), false)
+}
+
+// invoke 带超时调用处理函数，panic 视为失败
+func (m *Manager) invoke(ctx context.Context, handler HandlerFunc, job *Job) (err error) {
+	jobCtx, cancel := context.WithTimeout(ctx, m.config.JobTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+	return handler(jobCtx, job)
+}
+
+// backoff 指数退避
+func (m *Manager) backoff(attempts int) time.Duration {
+	delay := m.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= m.config.MaxBackoff {
+			return m.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// scheduleCrons 将到期的定时任务写入队列。以触发时间作为 UniqueKey，
+// 多个实例同时调度时只会创建一次。
+func (m *Manager) scheduleCrons(ctx context.Context) {
+	horizon := time.Now().Add(m.config.SchedulerLookahead)
+
+	m.mu.Lock()
+	due := make([]cronEntry, 0)
+	for _, entry := range m.crons {
+		for !entry.Next.IsZero() && entry.Next.Before(horizon) {
+			due = append(due, *entry)
+			entry.Next = entry.Schedule.Next(entry.Next)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, entry := range due {
+		_, err := m.store.Insert(ctx, entry.Type, entry.Payload, EnqueueOptions{
+			Priority:    entry.Priority,
+			RunAt:       entry.Next,
+			MaxAttempts: m.config.MaxAttempts,
+			UniqueKey:   fmt.Sprintf("cron:%s:%d", entry.Name, entry.Next.Unix()),
+		})
+		if err != nil {
+			log.Printf("Failed to enqueue cron job %s: %v", entry.Name, err)
+		}
+	}
+}
+
+// maintain 回收崩溃实例遗留的任务并清理历史
+func (m *Manager) maintain(ctx context.Context) {
+	if n, err := m.store.RequeueStale(ctx, m.config.StaleTimeout); err != nil {
+		log.Printf("Job maintenance failed: %v", err)
+	} else if n > 0 {
+		log.Printf("Recovered %d stale jobs", n)
+	}
+
+	if _, err := m.store.Cleanup(ctx, time.Now().Add(-m.config.HistoryRetention)); err != nil {
+		log.Printf("Job maintenance failed: %v", err)
+	}
+}
+
+// recordMetrics 记录指标
+func (m *Manager) recordMetrics(jobType string, duration time.Duration, success bool) {
+	m.metricsCollector.RecordDBQuery("job", jobType, duration, success)
+	if !success {
+		m.metricsCollector.RecordDBError("job_error", jobType)
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestManager_Backoff 退避从 BaseBackoff 开始按尝试次数翻倍，不超过 MaxBackoff
+func TestManager_Backoff(t *testing.T) {
+	config := DefaultConfig()
+	config.BaseBackoff = 5 * time.Second
+	config.MaxBackoff = time.Minute
+	m := NewManager(nil, config)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{4, 40 * time.Second},
+		{5, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, m.backoff(tt.attempts), "attempts %d", tt.attempts)
+	}
+}
@@ -0,0 +1,279 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// 常用优先级
+const (
+	PriorityLow    = -10
+	PriorityNormal = 0
+	PriorityHigh   = 10
+)
+
+// ErrJobNotFound 任务不存在
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobLockLost 任务已不由当前实例持有（超时被回收或由其他实例重新领取），执行结果不再写回
+var ErrJobLockLost = errors.New("job lock lost")
+
+// Job 任务记录
+type Job struct {
+	ID          int64           `db:"id" json:"id"`
+	Type        string          `db:"type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload,omitempty"`
+	Priority    int             `db:"priority" json:"priority"`
+	Status      Status          `db:"status" json:"status"`
+	UniqueKey   sql.NullString  `db:"unique_key" json:"-"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	MaxAttempts int             `db:"max_attempts" json:"max_attempts"`
+	LastError   sql.NullString  `db:"last_error" json:"-"`
+	RunAt       time.Time       `db:"run_at" json:"run_at"`
+	LockedBy    sql.NullString  `db:"locked_by" json:"-"`
+	StartedAt   sql.NullTime    `db:"started_at" json:"-"`
+	FinishedAt  sql.NullTime    `db:"finished_at" json:"-"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// Decode 解析任务参数
+func (j *Job) Decode(dest interface{}) error {
+	if len(j.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Payload, dest)
+}
+
+// EnqueueOptions 入队选项
+type EnqueueOptions struct {
+	Priority    int
+	RunAt       time.Time // 为零值时立即执行
+	MaxAttempts int       // 为 0 时使用管理器默认值
+	UniqueKey   string    // 非空时相同 key 只创建一次
+}
+
+// JobFilter 任务查询条件
+type JobFilter struct {
+	Type   string
+	Status Status
+	Limit  int
+	Offset int
+}
+
+// StatusCount 状态统计
+type StatusCount struct {
+	Status Status `db:"status" json:"status"`
+	Count  int64  `db:"count" json:"count"`
+}
+
+// Store 任务持久化
+type Store struct {
+	db *database.DB
+}
+
+// NewStore 创建任务存储
+func NewStore(db *database.DB) *Store {
+	return &Store{db: db}
+}
+
+const jobColumns = `id, type, payload, priority, status, unique_key, attempts, max_attempts, last_error,
+	run_at, locked_by, started_at, finished_at, created_at, updated_at`
+
+// Insert 写入任务，UniqueKey 冲突时返回 0
+func (s *Store) Insert(ctx context.Context, jobType string, payload []byte, opts EnqueueOptions) (int64, error) {
+	runAt := opts.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	var uniqueKey sql.NullString
+	if opts.UniqueKey != "" {
+		uniqueKey = sql.NullString{String: opts.UniqueKey, Valid: true}
+	}
+
+	query := `
+		INSERT INTO jobs (type, payload, priority, unique_key, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (unique_key) DO NOTHING
+		RETURNING id`
+
+	var id int64
+	err := s.db.GetContext(ctx, &id, query, jobType, payload, opts.Priority, uniqueKey, opts.MaxAttempts, runAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert job: %w", err)
+	}
+	return id, nil
+}
+
+// Claim 按优先级锁定一批到期任务并标记为运行中
+func (s *Store) Claim(ctx context.Context, workerID string, limit int) ([]Job, error) {
+	query := `
+		UPDATE jobs SET status = 'running', locked_by = $1, started_at = NOW(),
+			attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE status = 'pending' AND run_at <= NOW()
+			ORDER BY priority DESC, run_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	var jobs []Job
+	if err := s.db.SelectContext(ctx, &jobs, query, workerID, limit); err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Complete 标记任务成功，仅当任务仍由 workerID 持有时生效
+func (s *Store) Complete(ctx context.Context, id int64, workerID string) error {
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE jobs SET status = 'succeeded', last_error = NULL, finished_at = NOW(), updated_at = NOW() WHERE id = $1 AND status = 'running' AND locked_by = $2",
+		id, workerID)
+	if err != nil {
+		return fmt.Errorf("failed to complete job %d: %w", id, err)
+	}
+	return lockHeld(result, id)
+}
+
+// Fail 记录失败；retryAt 为零值时标记为最终失败，否则等待重试。仅当任务仍由 workerID 持有时生效
+func (s *Store) Fail(ctx context.Context, id int64, workerID string, jobErr error, retryAt time.Time) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	if retryAt.IsZero() {
+		result, err = s.db.ExecContext(ctx,
+			"UPDATE jobs SET status = 'failed', last_error = $1, finished_at = NOW(), updated_at = NOW() WHERE id = $2 AND status = 'running' AND locked_by = $3",
+			jobErr.Error(), id, workerID)
+	} else {
+		result, err = s.db.ExecContext(ctx,
+			"UPDATE jobs SET status = 'pending', last_error = $1, run_at = $2, locked_by = NULL, updated_at = NOW() WHERE id = $3 AND status = 'running' AND locked_by = $4",
+			jobErr.Error(), retryAt, id, workerID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record job %d failure: %w", id, err)
+	}
+	return lockHeld(result, id)
+}
+
+// lockHeld 未更新任何行时说明任务已不由当前实例持有
+func lockHeld(result sql.Result, id int64) error {
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("job %d: %w", id, ErrJobLockLost)
+	}
+	return nil
+}
+
+// Get 获取任务
+func (s *Store) Get(ctx context.Context, id int64) (*Job, error) {
+	var job Job
+	err := s.db.GetContext(ctx, &job, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job %d: %w", id, err)
+	}
+	return &job, nil
+}
+
+// List 按条件查询任务，按创建时间倒序
+func (s *Store) List(ctx context.Context, filter JobFilter) ([]Job, error) {
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 50
+	}
+
+	query := "SELECT " + jobColumns + " FROM jobs WHERE ($1 = '' OR type = $1) AND ($2 = '' OR status = $2) ORDER BY created_at DESC, id DESC LIMIT $3 OFFSET $4"
+	var jobs []Job
+	if err := s.db.SelectContext(ctx, &jobs, query, filter.Type, string(filter.Status), filter.Limit, filter.Offset); err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// Stats 按状态统计任务数
+func (s *Store) Stats(ctx context.Context) ([]StatusCount, error) {
+	var counts []StatusCount
+	if err := s.db.SelectContext(ctx, &counts, "SELECT status, COUNT(*) AS count FROM jobs GROUP BY status ORDER BY status"); err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	return counts, nil
+}
+
+// Retry 将失败或已取消的任务重新置为待执行
+func (s *Store) Retry(ctx context.Context, id int64) error {
+	return s.transition(ctx, id,
+		"UPDATE jobs SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW() WHERE id = $1 AND status IN ('failed', 'cancelled')")
+}
+
+// Cancel 取消尚未执行的任务
+func (s *Store) Cancel(ctx context.Context, id int64) error {
+	return s.transition(ctx, id,
+		"UPDATE jobs SET status = 'cancelled', finished_at = NOW(), updated_at = NOW() WHERE id = $1 AND status = 'pending'")
+}
+
+// RequeueStale 回收超时未完成的运行中任务（执行实例崩溃时）：尝试次数已用尽的标记为最终失败，
+// 其余重新置为待执行
+func (s *Store) RequeueStale(ctx context.Context, timeout time.Duration) (int64, error) {
+	query := `
+		UPDATE jobs SET
+			status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,
+			last_error = $1,
+			finished_at = CASE WHEN attempts >= max_attempts THEN NOW() ELSE finished_at END,
+			locked_by = NULL, updated_at = NOW()
+		WHERE status = 'running' AND started_at < $2`
+	result, err := s.db.ExecContext(ctx, query,
+		fmt.Sprintf("job did not finish within %s", timeout), time.Now().Add(-timeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stale jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Cleanup 删除早于 before 的已结束任务
+func (s *Store) Cleanup(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx,
+		"DELETE FROM jobs WHERE status IN ('succeeded', 'failed', 'cancelled') AND finished_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup jobs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// transition 执行状态变更，未命中时区分任务不存在与状态不允许
+func (s *Store) transition(ctx context.Context, id int64, query string) error {
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to update job %d: %w", id, err)
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return nil
+	}
+
+	job, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("job %d cannot transition from status %s", id, job.Status)
+}
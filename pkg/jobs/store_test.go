@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStore_RequeueStale 超时的运行中任务在尝试次数用尽时标记为失败，否则重新待执行
+func TestStore_RequeueStale(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	store := NewStore(db)
+
+	mock.ExpectExec(`UPDATE jobs SET\s+status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'pending' END,\s+last_error = \$1,\s+finished_at = CASE WHEN attempts >= max_attempts THEN NOW\(\) ELSE finished_at END,\s+locked_by = NULL, updated_at = NOW\(\)\s+WHERE status = 'running' AND started_at < \$2`).
+		WithArgs("job did not finish within 30m0s", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := store.RequeueStale(context.Background(), 30*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+}
+
+// TestStore_ResultRequiresLock 结果只在任务仍由当前实例持有时写回，否则返回 ErrJobLockLost
+func TestStore_ResultRequiresLock(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	store := NewStore(db)
+	ctx := context.Background()
+	retryAt := time.Now().Add(time.Minute)
+
+	mock.ExpectExec(`UPDATE jobs SET status = 'succeeded'.* WHERE id = \$1 AND status = 'running' AND locked_by = \$2`).
+		WithArgs(1, "w1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE jobs SET status = 'succeeded'.* WHERE id = \$1 AND status = 'running' AND locked_by = \$2`).
+		WithArgs(2, "w1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE jobs SET status = 'pending'.* WHERE id = \$3 AND status = 'running' AND locked_by = \$4`).
+		WithArgs("boom", retryAt, 3, "w1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE jobs SET status = 'failed'.* WHERE id = \$2 AND status = 'running' AND locked_by = \$3`).
+		WithArgs("boom", 4, "w1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, store.Complete(ctx, 1, "w1"))
+	assert.ErrorIs(t, store.Complete(ctx, 2, "w1"), ErrJobLockLost)
+	require.NoError(t, store.Fail(ctx, 3, "w1", errors.New("boom"), retryAt))
+	assert.ErrorIs(t, store.Fail(ctx, 4, "w1", errors.New("boom"), time.Time{}), ErrJobLockLost)
+}
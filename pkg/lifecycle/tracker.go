@@ -0,0 +1,324 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config goroutine 泄漏检测配置
+type Config struct {
+	SampleInterval     time.Duration `json:"sample_interval"`     // 比较预期与实际 goroutine 数的间隔
+	LeakGrace          time.Duration `json:"leak_grace"`          // 组件关闭后 goroutine 仍在运行多久视为泄漏
+	UnmanagedThreshold int           `json:"unmanaged_threshold"` // 未登记的 goroutine 较基线增长超过该值时告警，为 0 时不检查
+}
+
+// DefaultConfig 默认泄漏检测配置
+func DefaultConfig() *Config {
+	return &Config{
+		SampleInterval:     30 * time.Second,
+		LeakGrace:          10 * time.Second,
+		UnmanagedThreshold: 1000,
+	}
+}
+
+// Goroutine 受管 goroutine
+type Goroutine struct {
+	Component string    `json:"component"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+	Leaked    bool      `json:"leaked,omitempty"` // 所属组件已关闭超过宽限期
+}
+
+// ComponentInventory 同名组件的 goroutine 汇总，同一组件可能有多个运行中的实例
+type ComponentInventory struct {
+	Name       string      `json:"name"`
+	Instances  int         `json:"instances"`
+	Closed     int         `json:"closed"` // 已关闭但仍有 goroutine 运行的实例
+	Running    int         `json:"running"`
+	Leaked     int         `json:"leaked"`
+	Goroutines []Goroutine `json:"goroutines"`
+}
+
+// Inventory goroutine 清单：预期数为登记的 goroutine，实际数取自 runtime
+type Inventory struct {
+	Total      int                  `json:"total"`
+	Managed    int                  `json:"managed"`
+	Unmanaged  int                  `json:"unmanaged"`
+	Baseline   int                  `json:"baseline"` // 首次采样时未登记的 goroutine 数
+	Leaked     int                  `json:"leaked"`
+	Components []ComponentInventory `json:"components"`
+	CheckedAt  time.Time            `json:"checked_at"`
+}
+
+// Tracker 登记后台组件启动的 goroutine，定期比较预期与实际数量，
+// 并标记组件 Close 后仍未退出的 goroutine
+type Tracker struct {
+	config       *Config
+	numGoroutine func() int
+	mu           sync.Mutex
+	instances    map[uint64]*Component
+	nextID       uint64
+	baseline     int
+	reported     map[*goroutine]bool
+}
+
+// NewTracker 创建 goroutine 登记表
+func NewTracker(config *Config) *Tracker {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Tracker{
+		config:       config,
+		numGoroutine: runtime.NumGoroutine,
+		instances:    make(map[uint64]*Component),
+		baseline:     -1,
+		reported:     make(map[*goroutine]bool),
+	}
+}
+
+var defaultTracker = NewTracker(nil)
+
+// Default 进程级登记表，各组件默认在此登记
+func Default() *Tracker {
+	return defaultTracker
+}
+
+// Register 在进程级登记表中登记组件实例
+func Register(name string) *Component {
+	return defaultTracker.Register(name)
+}
+
+// Component 组件实例，通过 Go 启动的 goroutine 计入该实例
+type Component struct {
+	tracker    *Tracker
+	id         uint64
+	name       string
+	goroutines map[*goroutine]struct{}
+	closedAt   time.Time
+}
+
+type goroutine struct {
+	name      string
+	startedAt time.Time
+}
+
+// Register 登记组件实例，同名组件的多个实例分别统计；实例在有 goroutine 运行时才出现在清单中
+func (t *Tracker) Register(name string) *Component {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	return &Component{
+		tracker:    t,
+		id:         t.nextID,
+		name:       name,
+		goroutines: make(map[*goroutine]struct{}),
+	}
+}
+
+// Go 启动受管 goroutine，fn 返回后注销
+func (c *Component) Go(name string, fn func()) {
+	t := c.tracker
+	g := &goroutine{name: name, startedAt: time.Now()}
+	t.mu.Lock()
+	c.goroutines[g] = struct{}{}
+	t.instances[c.id] = c
+	t.mu.Unlock()
+
+	go func() {
+		defer c.done(g)
+		fn()
+	}()
+}
+
+func (c *Component) done(g *goroutine) {
+	t := c.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(c.goroutines, g)
+	delete(t.reported, g)
+	if len(c.goroutines) == 0 {
+		delete(t.instances, c.id)
+	}
+}
+
+// Closed 标记组件已关闭，此后仍在运行的 goroutine 超过宽限期即视为泄漏。
+// 应在组件的 Close 返回后调用
+func (c *Component) Closed() {
+	t := c.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c.closedAt.IsZero() {
+		c.closedAt = time.Now()
+	}
+	if len(c.goroutines) == 0 {
+		delete(t.instances, c.id)
+	}
+}
+
+// Inventory 当前 goroutine 清单，组件按名称排序
+func (t *Tracker) Inventory() *Inventory {
+	total := t.numGoroutine()
+	now := time.Now()
+
+	t.mu.Lock()
+	byName := make(map[string]*ComponentInventory)
+	inventory := &Inventory{Total: total, Baseline: t.baseline, CheckedAt: now}
+	for _, c := range t.instances {
+		entry, ok := byName[c.name]
+		if !ok {
+			entry = &ComponentInventory{Name: c.name, Goroutines: []Goroutine{}}
+			byName[c.name] = entry
+		}
+		entry.Instances++
+		if !c.closedAt.IsZero() {
+			entry.Closed++
+		}
+		leaked := t.leaked(c, now)
+		for g := range c.goroutines {
+			entry.Running++
+			entry.Goroutines = append(entry.Goroutines, Goroutine{Component: c.name, Name: g.name, StartedAt: g.startedAt, Leaked: leaked})
+			if leaked {
+				entry.Leaked++
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	inventory.Components = make([]ComponentInventory, 0, len(byName))
+	for _, entry := range byName {
+		sort.Slice(entry.Goroutines, func(i, j int) bool { return entry.Goroutines[i].StartedAt.Before(entry.Goroutines[j].StartedAt) })
+		inventory.Managed += entry.Running
+		inventory.Leaked += entry.Leaked
+		inventory.Components = append(inventory.Components, *entry)
+	}
+	sort.Slice(inventory.Components, func(i, j int) bool { return inventory.Components[i].Name < inventory.Components[j].Name })
+	inventory.Unmanaged = total - inventory.Managed
+	return inventory
+}
+
+func (t *Tracker) leaked(c *Component, now time.Time) bool {
+	return !c.closedAt.IsZero() && now.Sub(c.closedAt) >= t.config.LeakGrace
+}
+
+// Leaks 所属组件关闭超过宽限期仍在运行的 goroutine
+func (t *Tracker) Leaks() []Goroutine {
+	var leaks []Goroutine
+	for _, component := range t.Inventory().Components {
+		for _, g := range component.Goroutines {
+			if g.Leaked {
+				leaks = append(leaks, g)
+			}
+		}
+	}
+	return leaks
+}
+
+// Check 存在泄漏或未登记的 goroutine 增长超过阈值时返回错误，供健康检查使用
+func (t *Tracker) Check(ctx context.Context) error {
+	inventory := t.Inventory()
+	if inventory.Leaked > 0 {
+		return fmt.Errorf("%d goroutines still running after close: %s", inventory.Leaked, describe(t.Leaks()))
+	}
+	if threshold := t.config.UnmanagedThreshold; threshold > 0 && inventory.Baseline >= 0 && inventory.Unmanaged-inventory.Baseline > threshold {
+		return fmt.Errorf("%d unmanaged goroutines exceed baseline %d by more than %d", inventory.Unmanaged, inventory.Baseline, threshold)
+	}
+	return nil
+}
+
+// Run 定期比较预期与实际 goroutine 数，首次采样记为基线；新发现的泄漏与异常增长写入日志，阻塞直到 ctx 取消
+func (t *Tracker) Run(ctx context.Context) {
+	t.sample()
+	ticker := time.NewTicker(t.config.SampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.sample()
+		}
+	}
+}
+
+func (t *Tracker) sample() {
+	inventory := t.Inventory()
+
+	t.mu.Lock()
+	if t.baseline < 0 {
+		t.baseline = inventory.Unmanaged
+		inventory.Baseline = t.baseline
+	}
+	var fresh []Goroutine
+	now := time.Now()
+	for _, c := range t.instances {
+		if !t.leaked(c, now) {
+			continue
+		}
+		for g := range c.goroutines {
+			if !t.reported[g] {
+				t.reported[g] = true
+				fresh = append(fresh, Goroutine{Component: c.name, Name: g.name, StartedAt: g.startedAt, Leaked: true})
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if len(fresh) > 0 {
+		log.Printf("Goroutine leak detected: %d goroutines still running after close: %s", len(fresh), describe(fresh))
+	}
+	if threshold := t.config.UnmanagedThreshold; threshold > 0 && inventory.Unmanaged-inventory.Baseline > threshold {
+		log.Printf("Unmanaged goroutines grew from %d to %d (total %d, managed %d)",
+			inventory.Baseline, inventory.Unmanaged, inventory.Total, inventory.Managed)
+	}
+}
+
+// WaitClosed 等待已关闭组件的 goroutine 全部退出，ctx 结束时返回仍在运行的 goroutine，用于优雅关闭时报告泄漏
+func (t *Tracker) WaitClosed(ctx context.Context) []Goroutine {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		remaining := t.closedRunning()
+		if len(remaining) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return remaining
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tracker) closedRunning() []Goroutine {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var running []Goroutine
+	for _, c := range t.instances {
+		if c.closedAt.IsZero() {
+			continue
+		}
+		for g := range c.goroutines {
+			running = append(running, Goroutine{Component: c.name, Name: g.name, StartedAt: g.startedAt, Leaked: true})
+		}
+	}
+	return running
+}
+
+// describe 按 组件/名称 列出 goroutine，最多列出 10 个
+func describe(goroutines []Goroutine) string {
+	names := make([]string, 0, len(goroutines))
+	for _, g := range goroutines {
+		names = append(names, g.Component+"/"+g.Name)
+	}
+	sort.Strings(names)
+	if len(names) > 10 {
+		names = append(names[:10], fmt.Sprintf("and %d more", len(names)-10))
+	}
+	return strings.Join(names, ", ")
+}
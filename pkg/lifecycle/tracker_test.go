@@ -0,0 +1,94 @@
+package lifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTracker_Leak 组件关闭后退出的 goroutine 自动注销，宽限期后仍在运行的标记为泄漏
+func TestTracker_Leak(t *testing.T) {
+	tracker := NewTracker(&Config{SampleInterval: time.Second, LeakGrace: 20 * time.Millisecond})
+	stop, stuck := make(chan struct{}), make(chan struct{})
+	defer close(stuck)
+
+	clean := tracker.Register("writer")
+	clean.Go("run", func() { <-stop })
+	leaky := tracker.Register("bus")
+	leaky.Go("shard#0", func() { <-stop })
+	leaky.Go("shard#1", func() { <-stuck })
+
+	inventory := tracker.Inventory()
+	assert.Equal(t, 3, inventory.Managed)
+	assert.Equal(t, inventory.Total-3, inventory.Unmanaged)
+	require.Len(t, inventory.Components, 2)
+	assert.Equal(t, "bus", inventory.Components[0].Name)
+	assert.Equal(t, 2, inventory.Components[0].Running)
+
+	close(stop)
+	clean.Closed()
+	leaky.Closed()
+	require.Eventually(t, func() bool { return tracker.Inventory().Managed == 1 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, tracker.Check(context.Background()), "within grace period")
+
+	time.Sleep(30 * time.Millisecond)
+	inventory = tracker.Inventory()
+	require.Len(t, inventory.Components, 1)
+	assert.Equal(t, 1, inventory.Components[0].Closed)
+	assert.Equal(t, 1, inventory.Leaked)
+	assert.EqualError(t, tracker.Check(context.Background()), "1 goroutines still running after close: bus/shard#1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	leaks := tracker.WaitClosed(ctx)
+	require.Len(t, leaks, 1)
+	assert.Equal(t, "shard#1", leaks[0].Name)
+}
+
+// TestTracker_UnmanagedGrowth 未登记的 goroutine 较首次采样增长超过阈值时检查失败
+func TestTracker_UnmanagedGrowth(t *testing.T) {
+	tracker := NewTracker(&Config{SampleInterval: time.Second, UnmanagedThreshold: 100})
+	count := 20
+	tracker.numGoroutine = func() int { return count }
+	component := tracker.Register("jobs")
+	stop := make(chan struct{})
+	defer close(stop)
+	component.Go("worker", func() { <-stop })
+
+	assert.NoError(t, tracker.Check(context.Background()), "no baseline before the first sample")
+	tracker.sample()
+	assert.Equal(t, 19, tracker.Inventory().Baseline)
+
+	count = 119
+	assert.NoError(t, tracker.Check(context.Background()))
+	count = 121
+	assert.EqualError(t, tracker.Check(context.Background()), "120 unmanaged goroutines exceed baseline 19 by more than 100")
+}
+
+// TestTracker_WaitClosed 关闭的组件 goroutine 全部退出后立即返回
+func TestTracker_WaitClosed(t *testing.T) {
+	tracker := NewTracker(nil)
+	component := tracker.Register("background")
+	ctx, cancel := context.WithCancel(context.Background())
+	component.Go("ticker", func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+
+	cancel()
+	component.Closed()
+	waitCtx, stop := context.WithTimeout(context.Background(), time.Second)
+	defer stop()
+	assert.Empty(t, tracker.WaitClosed(waitCtx))
+	assert.Empty(t, tracker.Inventory().Components)
+}
@@ -0,0 +1,234 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errAgentUnknown 协调者不认识此代理（如协调者重启），需要重新注册
+var errAgentUnknown = errors.New("agent not registered")
+
+// AgentConfig 代理配置
+type AgentConfig struct {
+	// Coordinator 协调者地址，如 http://10.0.0.1:7070
+	Coordinator string `mapstructure:"coordinator" json:"coordinator"`
+	// Name 代理名称，出现在报告中，为空时由协调者分配
+	Name string `mapstructure:"name" json:"name"`
+	// RetryInterval 连接协调者失败后的重试间隔
+	RetryInterval time.Duration `mapstructure:"retry_interval" json:"retry_interval"`
+	// PollTimeout 长轮询领取任务的等待时间
+	PollTimeout time.Duration `mapstructure:"poll_timeout" json:"poll_timeout"`
+}
+
+// DefaultAgentConfig 默认配置
+func DefaultAgentConfig() *AgentConfig {
+	return &AgentConfig{
+		Coordinator:   "http://localhost:7070",
+		RetryInterval: 2 * time.Second,
+		PollTimeout:   5 * time.Second,
+	}
+}
+
+// BuildFunc 按任务构建场景，代理与协调者需以相同的场景名与参数构建出相同的场景
+type BuildFunc func(job *Job) (*Scenario, error)
+
+// Agent 分布式压测代理：向协调者注册，循环领取任务并执行，按间隔上报累计统计
+type Agent struct {
+	config *AgentConfig
+	build  BuildFunc
+	client *http.Client
+	id     string
+}
+
+// NewAgent 创建代理，config 为 nil 时使用默认配置
+func NewAgent(config *AgentConfig, build BuildFunc) *Agent {
+	if config == nil {
+		config = DefaultAgentConfig()
+	}
+	return &Agent{
+		config: config,
+		build:  build,
+		// 长轮询的请求时间比 PollTimeout 稍长
+		client: &http.Client{Timeout: config.PollTimeout + 10*time.Second},
+	}
+}
+
+// Run 持续领取并执行任务，直到 ctx 取消；与协调者的连接中断时按 RetryInterval 重试
+func (a *Agent) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		if a.id == "" {
+			if err := a.register(ctx); err != nil {
+				log.Printf("Failed to register with coordinator: %v", err)
+				a.sleep(ctx)
+				continue
+			}
+			log.Printf("Registered with coordinator %s as agent %s", a.config.Coordinator, a.id)
+		}
+
+		job, err := a.poll(ctx)
+		if errors.Is(err, errAgentUnknown) {
+			a.id = ""
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to poll job: %v", err)
+				a.sleep(ctx)
+			}
+			continue
+		}
+		if job != nil {
+			a.execute(ctx, job)
+		}
+	}
+	return ctx.Err()
+}
+
+// execute 执行任务并上报统计，构建场景或执行失败时以 Error 上报
+func (a *Agent) execute(ctx context.Context, job *Job) {
+	log.Printf("Running shard %d/%d of %s (run %s, %d vus)", job.Shard+1, job.Shards, job.Scenario, job.RunID, job.Config.VUs)
+
+	scenario, err := a.build(job)
+	if err != nil {
+		a.report(ctx, &MetricsUpdate{RunID: job.RunID, Done: true, Error: fmt.Sprintf("failed to build scenario: %v", err)})
+		return
+	}
+
+	config := job.Config
+	if config.Client == nil {
+		config.Client = DefaultClientConfig()
+	}
+	// 断言不随任务下发，使用默认断言
+	config.Assertions = DefaultConfig().Assertions
+	runner := NewRunner(&config)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		report *Report
+		err    error
+	}
+	finished := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		report, err := runner.RunShard(runCtx, scenario, job.Vars)
+		finished <- result{report, err}
+	}()
+
+	interval := job.ReportInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ack, err := a.report(ctx, &MetricsUpdate{RunID: job.RunID, Snapshot: runner.Snapshot(), Duration: time.Since(start)})
+			if err == nil && ack.Stop {
+				log.Printf("Coordinator stopped run %s", job.RunID)
+				cancel()
+			}
+		case res := <-finished:
+			update := &MetricsUpdate{RunID: job.RunID, Snapshot: runner.Snapshot(), Duration: time.Since(start), Done: true}
+			if res.err != nil {
+				update.Error = res.err.Error()
+			} else {
+				update.Duration = res.report.Duration
+			}
+			// 最终统计必须送达，失败时重试到 ctx 取消
+			for {
+				if _, err := a.report(ctx, update); err == nil || errors.Is(err, errAgentUnknown) || ctx.Err() != nil {
+					return
+				}
+				a.sleep(ctx)
+			}
+		}
+	}
+}
+
+// register 注册并保存分配的 ID
+func (a *Agent) register(ctx context.Context) error {
+	var resp agentRegistration
+	if err := a.call(ctx, http.MethodPost, "/agents/register", agentRegistration{Name: a.config.Name}, &resp); err != nil {
+		return err
+	}
+	if resp.ID == "" {
+		return errors.New("coordinator returned no agent id")
+	}
+	a.id = resp.ID
+	return nil
+}
+
+// poll 长轮询领取任务，没有任务时返回 nil
+func (a *Agent) poll(ctx context.Context) (*Job, error) {
+	var job Job
+	path := "/agents/" + a.id + "/job?wait=" + a.config.PollTimeout.String()
+	if err := a.call(ctx, http.MethodGet, path, nil, &job); err != nil {
+		return nil, err
+	}
+	if job.RunID == "" {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// report 上报统计
+func (a *Agent) report(ctx context.Context, update *MetricsUpdate) (*MetricsAck, error) {
+	var ack MetricsAck
+	if err := a.call(ctx, http.MethodPost, "/agents/"+a.id+"/metrics", update, &ack); err != nil {
+		return nil, err
+	}
+	return &ack, nil
+}
+
+// call 请求协调者，204 时不解析响应
+func (a *Agent) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(a.config.Coordinator, "/")+path, &buf)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return errAgentUnknown
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("coordinator returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// sleep 等待重试间隔或 ctx 取消
+func (a *Agent) sleep(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(a.config.RetryInterval):
+	}
+}
@@ -0,0 +1,63 @@
+package loadgen
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Assertion 响应断言，返回错误时请求计为失败
+type Assertion func(req *Request, resp *Response) error
+
+// StatusIn 状态码须为 codes 之一
+func StatusIn(codes ...int) Assertion {
+	return func(req *Request, resp *Response) error {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+// StatusOK 状态码须为 2xx
+func StatusOK() Assertion {
+	return func(req *Request, resp *Response) error {
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// BusinessCode 统一响应体 {"code": ...} 中的业务码须为 codes 之一
+func BusinessCode(codes ...int) Assertion {
+	return func(req *Request, resp *Response) error {
+		var body struct {
+			Code *int `json:"code"`
+		}
+		if err := resp.JSON(&body); err != nil {
+			return err
+		}
+		if body.Code == nil {
+			return fmt.Errorf("response has no code field")
+		}
+		for _, code := range codes {
+			if *body.Code == code {
+				return nil
+			}
+		}
+		return fmt.Errorf("unexpected code %d", *body.Code)
+	}
+}
+
+// MaxDuration 耗时不得超过 limit
+func MaxDuration(limit time.Duration) Assertion {
+	return func(req *Request, resp *Response) error {
+		if resp.Duration > limit {
+			return fmt.Errorf("took %v, limit %v", resp.Duration, limit)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+package loadgen
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// ClientConfig HTTP 客户端配置，默认值适合单机数千并发
+type ClientConfig struct {
+	Timeout             time.Duration `mapstructure:"timeout" json:"timeout"`
+	MaxConnsPerHost     int           `mapstructure:"max_conns_per_host" json:"max_conns_per_host"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
+	DisableKeepAlives   bool          `mapstructure:"disable_keep_alives" json:"disable_keep_alives"`
+	DisableCompression  bool          `mapstructure:"disable_compression" json:"disable_compression"`
+	InsecureSkipVerify  bool          `mapstructure:"insecure_skip_verify" json:"insecure_skip_verify"` // 压测自签名证书的环境
+}
+
+// DefaultClientConfig 默认客户端配置
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Timeout:             10 * time.Second,
+		MaxConnsPerHost:     2000,
+		MaxIdleConnsPerHost: 2000,
+	}
+}
+
+// NewHTTPClient 按配置创建 HTTP 客户端，config 为 nil 时使用默认配置
+func NewHTTPClient(config *ClientConfig) *http.Client {
+	if config == nil {
+		config = DefaultClientConfig()
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = config.MaxIdleConnsPerHost
+	t.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = config.MaxConnsPerHost
+	t.DisableKeepAlives = config.DisableKeepAlives
+	t.DisableCompression = config.DisableCompression
+	if config.InsecureSkipVerify {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{
+		Transport: t,
+		Timeout:   config.Timeout,
+	}
+}
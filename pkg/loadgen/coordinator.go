@@ -0,0 +1,521 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Job 分配给代理的压测任务
+type Job struct {
+	RunID string `json:"run_id"`
+	// Shard 分片序号（从 0 开始），Shards 分片总数
+	Shard  int `json:"shard"`
+	Shards int `json:"shards"`
+	// Scenario 场景名，Params 构建场景所需的参数，由双方约定
+	Scenario string          `json:"scenario"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	// Config 本分片的执行配置，VUs 与 RPS 已按分片划分
+	Config Config `json:"config"`
+	// Vars 协调者执行 Setup 得到的场景数据
+	Vars map[string]string `json:"vars,omitempty"`
+	// ReportInterval 上报统计的间隔
+	ReportInterval time.Duration `json:"report_interval"`
+}
+
+// MetricsUpdate 代理上报的统计，Snapshot 为本次任务开始以来的累计值
+type MetricsUpdate struct {
+	RunID    string        `json:"run_id"`
+	Snapshot *Snapshot     `json:"snapshot,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Done     bool          `json:"done"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// MetricsAck 协调者对上报的应答，Stop 为 true 时代理应提前结束任务
+type MetricsAck struct {
+	Stop bool `json:"stop"`
+}
+
+// agentRegistration 注册请求与应答
+type agentRegistration struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// CoordinatorConfig 协调者配置
+type CoordinatorConfig struct {
+	// MinAgents 开始压测前至少需要注册的代理数
+	MinAgents int `mapstructure:"min_agents" json:"min_agents"`
+	// AgentWait 等待代理注册的最长时间，0 表示一直等待
+	AgentWait time.Duration `mapstructure:"agent_wait" json:"agent_wait"`
+	// ReportInterval 代理上报统计的间隔
+	ReportInterval time.Duration `mapstructure:"report_interval" json:"report_interval"`
+	// AgentTimeout 代理超过此时间没有任何请求时视为失联
+	AgentTimeout time.Duration `mapstructure:"agent_timeout" json:"agent_timeout"`
+	// PollTimeout 代理领取任务时长轮询的最长时间
+	PollTimeout time.Duration `mapstructure:"poll_timeout" json:"poll_timeout"`
+}
+
+// DefaultCoordinatorConfig 默认配置：至少 1 个代理，每秒上报，10 秒无响应视为失联
+func DefaultCoordinatorConfig() *CoordinatorConfig {
+	return &CoordinatorConfig{
+		MinAgents:      1,
+		ReportInterval: time.Second,
+		AgentTimeout:   10 * time.Second,
+		PollTimeout:    30 * time.Second,
+	}
+}
+
+// Plan 一次分布式压测
+type Plan struct {
+	// Scenario 协调者本地执行 Setup 与 Teardown 的场景，其名称下发给代理用于构建同一场景
+	Scenario *Scenario
+	// ScenarioName 下发给代理的场景名，为空时使用 Scenario.Name
+	ScenarioName string
+	Params       json.RawMessage
+	// Config 整体配置，VUs 与 RPS 为所有代理的合计
+	Config *Config
+	// OnProgress 每个上报间隔以合并后的实时统计调用一次，可为 nil
+	OnProgress func(snapshot *Snapshot, agents int)
+}
+
+// DistributedReport 分布式压测报告：合并后的总报告与各代理的报告
+type DistributedReport struct {
+	*Report
+	Agents []*AgentReport `json:"agents"`
+}
+
+// AgentReport 单个代理的执行结果
+type AgentReport struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Shard  int     `json:"shard"`
+	VUs    int     `json:"vus"`
+	RPS    float64 `json:"rps,omitempty"`
+	Lost   bool    `json:"lost,omitempty"`
+	Error  string  `json:"error,omitempty"`
+	Report *Report `json:"report"`
+}
+
+// Failed 总报告失败，或有代理失联、出错
+func (r *DistributedReport) Failed() bool {
+	if r.Report.Failed() {
+		return true
+	}
+	for _, agent := range r.Agents {
+		if agent.Lost || agent.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 输出总报告与各代理的概要
+func (r *DistributedReport) Print(w io.Writer) {
+	r.Report.Print(w)
+	fmt.Fprintln(w, "代理:")
+	for _, agent := range r.Agents {
+		status := "完成"
+		switch {
+		case agent.Lost:
+			status = "失联"
+		case agent.Error != "":
+			status = "出错: " + agent.Error
+		}
+		req := &agent.Report.Requests
+		fmt.Fprintf(w, "  #%d %s（%s）虚拟用户 %d，请求 %d（失败 %d），QPS %.2f，p99 %v，%s\n",
+			agent.Shard, agent.Name, agent.ID, agent.VUs, req.Count, req.Failures, req.RPS,
+			req.P99.Round(time.Microsecond), status)
+	}
+	fmt.Fprintln(w, "--------------------------------------------------")
+}
+
+// Coordinator 分布式压测协调者：代理通过 HTTP 注册并领取分片，按间隔上报累计统计，协调者合并后生成报告。
+// 同一时间只执行一次压测
+type Coordinator struct {
+	config *CoordinatorConfig
+
+	mu      sync.Mutex
+	agents  map[string]*agentState
+	nextID  int
+	runs    int
+	changed chan struct{}
+}
+
+// agentState 代理的注册信息与当前任务的执行状态
+type agentState struct {
+	id       string
+	name     string
+	lastSeen time.Time
+	jobs     chan *Job
+
+	// 以下为当前任务的状态
+	job      *Job
+	snapshot *Snapshot
+	duration time.Duration
+	done     bool
+	err      string
+	stop     bool
+}
+
+// NewCoordinator 创建协调者，config 为 nil 时使用默认配置
+func NewCoordinator(config *CoordinatorConfig) *Coordinator {
+	if config == nil {
+		config = DefaultCoordinatorConfig()
+	}
+	return &Coordinator{
+		config:  config,
+		agents:  make(map[string]*agentState),
+		changed: make(chan struct{}),
+	}
+}
+
+// Handler 代理使用的 HTTP 接口
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /agents/register", c.handleRegister)
+	mux.HandleFunc("GET /agents/{id}/job", c.handleJob)
+	mux.HandleFunc("POST /agents/{id}/metrics", c.handleMetrics)
+	return mux
+}
+
+// Agents 当前在线的代理数
+func (c *Coordinator) Agents() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.onlineLocked())
+}
+
+// Run 等待足够的代理后执行一次分布式压测：本地执行 Setup，按代理划分虚拟用户与 RPS 下发任务，
+// 合并各代理上报的统计，全部结束（完成、出错或失联）后以合并后的报告执行 Teardown。
+// ctx 取消时通知代理停止并返回已有的报告
+func (c *Coordinator) Run(ctx context.Context, plan *Plan) (*DistributedReport, error) {
+	if plan.Config == nil {
+		plan.Config = DefaultConfig()
+	}
+	runner := NewRunner(plan.Config)
+	if err := runner.validate(plan.Scenario); err != nil {
+		return nil, err
+	}
+
+	agents, err := c.waitAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := runner.Setup(ctx, plan.Scenario)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := c.split(plan, agents, vars)
+	c.mu.Lock()
+	for i, job := range jobs {
+		agent := agents[i]
+		agent.job, agent.snapshot, agent.duration, agent.done, agent.err, agent.stop = job, nil, 0, false, "", false
+		// 丢弃代理失联期间未领取的旧任务
+		select {
+		case <-agent.jobs:
+		default:
+		}
+		agent.jobs <- job
+	}
+	c.mu.Unlock()
+	agents = agents[:len(jobs)]
+	log.Printf("Distributed run %s started on %d agents", jobs[0].RunID, len(jobs))
+
+	ticker := time.NewTicker(c.config.ReportInterval)
+	defer ticker.Stop()
+	done := ctx.Done()
+	cancelled := false
+	for {
+		c.mu.Lock()
+		finished := true
+		for _, agent := range agents {
+			if !agent.done && !c.lostLocked(agent) {
+				finished = false
+			}
+			// 取消后在下次上报的应答中通知代理停止
+			if cancelled {
+				agent.stop = true
+			}
+		}
+		changed := c.changed
+		c.mu.Unlock()
+		if finished {
+			break
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			if plan.OnProgress != nil {
+				plan.OnProgress(c.merge(agents), len(agents))
+			}
+		case <-done:
+			done, cancelled = nil, true
+		}
+	}
+
+	report := c.report(plan, agents)
+	runner.Teardown(ctx, plan.Scenario, vars, report.Report)
+	return report, nil
+}
+
+// waitAgents 等待至少 MinAgents 个代理在线，返回按注册顺序排列的在线代理
+func (c *Coordinator) waitAgents(ctx context.Context) ([]*agentState, error) {
+	if c.config.AgentWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.AgentWait)
+		defer cancel()
+	}
+	minAgents := c.config.MinAgents
+	if minAgents < 1 {
+		minAgents = 1
+	}
+	for {
+		c.mu.Lock()
+		agents := c.onlineLocked()
+		changed := c.changed
+		c.mu.Unlock()
+		if len(agents) >= minAgents {
+			return agents, nil
+		}
+		select {
+		case <-changed:
+		case <-time.After(c.config.ReportInterval):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for agents: %d of %d registered: %w", len(agents), minAgents, ctx.Err())
+		}
+	}
+}
+
+// split 按代理划分任务：虚拟用户平均分配，余数分给前面的代理，RPS 按虚拟用户数比例分配；虚拟用户少于代理数时只使用部分代理
+func (c *Coordinator) split(plan *Plan, agents []*agentState, vars map[string]string) []*Job {
+	c.mu.Lock()
+	c.runs++
+	runID := strconv.Itoa(c.runs)
+	c.mu.Unlock()
+
+	name := plan.ScenarioName
+	if name == "" {
+		name = plan.Scenario.Name
+	}
+	total := plan.Config.VUs
+	shards := len(agents)
+	if total < shards {
+		shards = total
+	}
+
+	jobs := make([]*Job, shards)
+	offset := plan.Config.VUOffset
+	for i := range jobs {
+		config := *plan.Config
+		config.Assertions = nil
+		config.VUs = total / shards
+		if i < total%shards {
+			config.VUs++
+		}
+		config.VUOffset = offset
+		offset += config.VUs
+		config.RPS = plan.Config.RPS * float64(config.VUs) / float64(total)
+		jobs[i] = &Job{
+			RunID:          runID,
+			Shard:          i,
+			Shards:         shards,
+			Scenario:       name,
+			Params:         plan.Params,
+			Config:         config,
+			Vars:           vars,
+			ReportInterval: c.config.ReportInterval,
+		}
+	}
+	return jobs
+}
+
+// merge 合并各代理的最新统计
+func (c *Coordinator) merge(agents []*agentState) *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	merged := NewSnapshot()
+	for _, agent := range agents {
+		merged.Merge(agent.snapshot)
+	}
+	return merged
+}
+
+// report 生成总报告与各代理的报告，总耗时取各代理的最大值
+func (c *Coordinator) report(plan *Plan, agents []*agentState) *DistributedReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	merged := NewSnapshot()
+	var duration time.Duration
+	result := &DistributedReport{Agents: make([]*AgentReport, 0, len(agents))}
+	for _, agent := range agents {
+		snapshot := agent.snapshot
+		if snapshot == nil {
+			snapshot = NewSnapshot()
+		}
+		merged.Merge(snapshot)
+		if agent.duration > duration {
+			duration = agent.duration
+		}
+		result.Agents = append(result.Agents, &AgentReport{
+			ID:     agent.id,
+			Name:   agent.name,
+			Shard:  agent.job.Shard,
+			VUs:    agent.job.Config.VUs,
+			RPS:    agent.job.Config.RPS,
+			Lost:   !agent.done,
+			Error:  agent.err,
+			Report: snapshot.Report(plan.Scenario.Name, agent.job.Config.VUs, agent.duration),
+		})
+	}
+	result.Report = merged.Report(plan.Scenario.Name, plan.Config.VUs, duration)
+	return result
+}
+
+// onlineLocked 未失联的代理，按注册顺序排列，调用方需持有锁
+func (c *Coordinator) onlineLocked() []*agentState {
+	agents := make([]*agentState, 0, len(c.agents))
+	for _, agent := range c.agents {
+		if !c.lostLocked(agent) {
+			agents = append(agents, agent)
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		a, _ := strconv.Atoi(agents[i].id)
+		b, _ := strconv.Atoi(agents[j].id)
+		return a < b
+	})
+	return agents
+}
+
+// lostLocked 代理是否失联，调用方需持有锁
+func (c *Coordinator) lostLocked(agent *agentState) bool {
+	return time.Since(agent.lastSeen) > c.config.AgentTimeout
+}
+
+// notifyLocked 唤醒等待状态变化的 Run，调用方需持有锁
+func (c *Coordinator) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// agent 按 ID 查找代理并刷新活跃时间
+func (c *Coordinator) agent(id string) *agentState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	agent, ok := c.agents[id]
+	if ok {
+		agent.lastSeen = time.Now()
+	}
+	return agent
+}
+
+// handleRegister 注册代理，返回分配的 ID
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req agentRegistration
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid registration: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	agent := &agentState{
+		id:       strconv.Itoa(c.nextID),
+		name:     req.Name,
+		lastSeen: time.Now(),
+		jobs:     make(chan *Job, 1),
+	}
+	if agent.name == "" {
+		agent.name = "agent-" + agent.id
+	}
+	c.agents[agent.id] = agent
+	c.notifyLocked()
+	c.mu.Unlock()
+
+	log.Printf("Agent %s (%s) registered from %s", agent.id, agent.name, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, agentRegistration{ID: agent.id, Name: agent.name})
+}
+
+// handleJob 长轮询领取任务：有任务时返回 200，等待超时返回 204，代理未注册返回 404
+func (c *Coordinator) handleJob(w http.ResponseWriter, r *http.Request) {
+	agent := c.agent(r.PathValue("id"))
+	if agent == nil {
+		http.Error(w, "agent not registered", http.StatusNotFound)
+		return
+	}
+
+	wait := c.config.PollTimeout
+	if d, err := time.ParseDuration(r.URL.Query().Get("wait")); err == nil && d < wait {
+		wait = d
+	}
+	// 长轮询期间代理视为在线
+	if wait > c.config.AgentTimeout/2 {
+		wait = c.config.AgentTimeout / 2
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case job := <-agent.jobs:
+		c.agent(agent.id)
+		writeJSON(w, http.StatusOK, job)
+	case <-timer.C:
+		c.agent(agent.id)
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+	}
+}
+
+// handleMetrics 接收代理上报的累计统计
+func (c *Coordinator) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var update MetricsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid metrics: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	agent, ok := c.agents[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "agent not registered", http.StatusNotFound)
+		return
+	}
+	agent.lastSeen = time.Now()
+	if agent.job == nil || agent.job.RunID != update.RunID {
+		// 过期任务的上报，通知代理停止
+		writeJSON(w, http.StatusOK, MetricsAck{Stop: true})
+		return
+	}
+	if update.Snapshot != nil {
+		agent.snapshot = update.Snapshot
+	}
+	agent.duration = update.Duration
+	if update.Done && !agent.done {
+		agent.done = true
+		agent.err = update.Error
+		c.notifyLocked()
+	}
+	writeJSON(w, http.StatusOK, MetricsAck{Stop: agent.stop})
+}
+
+// writeJSON 以 JSON 写响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
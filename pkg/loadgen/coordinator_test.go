@@ -0,0 +1,166 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// claimScenario 创建优惠券后各虚拟用户领取，seen 记录出现过的虚拟用户编号
+func claimScenario(seen *sync.Map) *Scenario {
+	return &Scenario{
+		Name: "claim",
+		Setup: func(ctx context.Context, env *Env) error {
+			_, err := env.Do(ctx, &Request{Method: http.MethodPost, Path: "/create", Body: map[string]int{"total": 10}})
+			env.Set("id", "c1")
+			return err
+		},
+		Iteration: func(ctx context.Context, vu *VU) error {
+			seen.Store(vu.ID, true)
+			resp, err := vu.Do(ctx, &Request{
+				Name:       "claim",
+				Method:     http.MethodPost,
+				Path:       "/claim/" + vu.Get("id"),
+				Assertions: []Assertion{StatusIn(http.StatusOK, http.StatusConflict)},
+			})
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode == http.StatusOK {
+				vu.Count("claimed", 1)
+			}
+			return nil
+		},
+	}
+}
+
+func TestCoordinator_Distributed(t *testing.T) {
+	target := newTestServer(10)
+	defer target.Close()
+
+	config := DefaultCoordinatorConfig()
+	config.MinAgents = 2
+	config.ReportInterval = 20 * time.Millisecond
+	coordinator := NewCoordinator(config)
+	server := httptest.NewServer(coordinator.Handler())
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	seen := &sync.Map{}
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		agentConfig := DefaultAgentConfig()
+		agentConfig.Coordinator = server.URL
+		agentConfig.Name = name
+		agentConfig.PollTimeout = 100 * time.Millisecond
+		agent := NewAgent(agentConfig, func(job *Job) (*Scenario, error) {
+			if job.Scenario != "claim" {
+				return nil, errors.New("unknown scenario")
+			}
+			return claimScenario(seen), nil
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent.Run(ctx)
+		}()
+	}
+
+	runConfig := DefaultConfig()
+	runConfig.BaseURL = target.URL
+	runConfig.VUs = 5
+	runConfig.Iterations = 4
+	scenario := claimScenario(seen)
+	var teardownClaimed int64
+	scenario.Teardown = func(ctx context.Context, env *Env, report *Report) error {
+		teardownClaimed = report.Counters["claimed"]
+		return nil
+	}
+	report, err := coordinator.Run(ctx, &Plan{Scenario: scenario, Config: runConfig})
+	require.NoError(t, err)
+
+	assert.False(t, report.Failed(), "%v", report.Errors)
+	assert.Equal(t, int64(20), report.Iterations)
+	assert.Equal(t, int64(20), report.ByName["claim"].Count)
+	assert.Equal(t, int64(10), report.ByName["claim"].Status[http.StatusOK])
+	assert.Equal(t, int64(10), report.Counters["claimed"])
+	assert.Equal(t, int64(10), teardownClaimed)
+
+	require.Len(t, report.Agents, 2)
+	assert.Equal(t, 3, report.Agents[0].VUs)
+	assert.Equal(t, 2, report.Agents[1].VUs)
+	assert.Equal(t, int64(12), report.Agents[0].Report.Iterations)
+	assert.Equal(t, int64(8), report.Agents[1].Report.Iterations)
+	assert.ElementsMatch(t, []string{"a", "b"}, []string{report.Agents[0].Name, report.Agents[1].Name})
+
+	// 各代理的虚拟用户编号互不重叠
+	for id := 1; id <= 5; id++ {
+		_, ok := seen.Load(id)
+		assert.True(t, ok, id)
+	}
+	_, ok := seen.Load(6)
+	assert.False(t, ok)
+
+	var out strings.Builder
+	report.Print(&out)
+	assert.Contains(t, out.String(), "代理:")
+
+	// 代理按任务构建场景失败时报告为出错
+	report, err = coordinator.Run(ctx, &Plan{Scenario: scenario, ScenarioName: "missing", Config: runConfig})
+	require.NoError(t, err)
+	assert.True(t, report.Failed())
+	assert.Contains(t, report.Agents[0].Error, "unknown scenario")
+
+	cancel()
+	wg.Wait()
+}
+
+func TestCoordinator_WaitAgentsTimeout(t *testing.T) {
+	config := DefaultCoordinatorConfig()
+	config.AgentWait = 50 * time.Millisecond
+	_, err := NewCoordinator(config).Run(context.Background(), &Plan{
+		Scenario: &Scenario{Iteration: func(ctx context.Context, vu *VU) error { return nil }},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCoordinator_LostAgent(t *testing.T) {
+	config := DefaultCoordinatorConfig()
+	config.ReportInterval = 10 * time.Millisecond
+	config.AgentTimeout = 100 * time.Millisecond
+	coordinator := NewCoordinator(config)
+	server := httptest.NewServer(coordinator.Handler())
+	defer server.Close()
+
+	// 代理领取任务后不再上报
+	go func() {
+		agent := NewAgent(&AgentConfig{Coordinator: server.URL, PollTimeout: time.Second}, nil)
+		ctx := context.Background()
+		if assert.NoError(t, agent.register(ctx)) {
+			for {
+				job, err := agent.poll(ctx)
+				if err != nil || job != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	report, err := coordinator.Run(context.Background(), &Plan{
+		Scenario: &Scenario{Name: "lost", Iteration: func(ctx context.Context, vu *VU) error { return nil }},
+		Config:   DefaultConfig(),
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Agents, 1)
+	assert.True(t, report.Agents[0].Lost)
+	assert.True(t, report.Failed())
+}
@@ -0,0 +1,156 @@
+package loadgen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report 压测报告
+type Report struct {
+	Scenario         string                   `json:"scenario"`
+	VUs              int                      `json:"vus"`
+	Duration         time.Duration            `json:"duration"`
+	Iterations       int64                    `json:"iterations"`
+	FailedIterations int64                    `json:"failed_iterations"`
+	Requests         RequestStats             `json:"requests"`
+	ByName           map[string]*RequestStats `json:"by_name"`
+	Counters         map[string]int64         `json:"counters,omitempty"`
+	Errors           map[string]int64         `json:"errors,omitempty"`
+	// TeardownError Teardown 返回的错误，通常为业务结果校验失败
+	TeardownError string `json:"teardown_error,omitempty"`
+}
+
+// RequestStats 一组请求的统计
+type RequestStats struct {
+	Count    int64         `json:"count"`
+	Failures int64         `json:"failures"`
+	RPS      float64       `json:"rps"`
+	Min      time.Duration `json:"min"`
+	Mean     time.Duration `json:"mean"`
+	P50      time.Duration `json:"p50"`
+	P90      time.Duration `json:"p90"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+	Status   map[int]int64 `json:"status,omitempty"` // 0 表示网络错误
+}
+
+// Failed 是否有失败的请求、迭代或结果校验
+func (r *Report) Failed() bool {
+	return r.Requests.Failures > 0 || r.FailedIterations > 0 || r.TeardownError != ""
+}
+
+// Print 输出文本报告
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintln(w, "--------------------------------------------------")
+	fmt.Fprintf(w, "场景: %s  虚拟用户: %d  耗时: %v\n", r.Scenario, r.VUs, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "迭代: %d（失败 %d）\n", r.Iterations, r.FailedIterations)
+	printStats(w, "全部请求", &r.Requests)
+
+	names := make([]string, 0, len(r.ByName))
+	for name := range r.ByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		printStats(w, name, r.ByName[name])
+	}
+
+	if len(r.Counters) > 0 {
+		fmt.Fprintln(w, "计数:")
+		for _, name := range sortedKeys(r.Counters) {
+			fmt.Fprintf(w, "  %s: %d\n", name, r.Counters[name])
+		}
+	}
+	if len(r.Errors) > 0 {
+		fmt.Fprintln(w, "错误:")
+		for _, message := range sortedKeys(r.Errors) {
+			fmt.Fprintf(w, "  %6d  %s\n", r.Errors[message], message)
+		}
+	}
+	if r.TeardownError != "" {
+		fmt.Fprintf(w, "结果校验失败: %s\n", r.TeardownError)
+	}
+	fmt.Fprintln(w, "--------------------------------------------------")
+}
+
+// printStats 输出一组请求的统计
+func printStats(w io.Writer, name string, s *RequestStats) {
+	fmt.Fprintf(w, "%s: %d 次（失败 %d），QPS %.2f\n", name, s.Count, s.Failures, s.RPS)
+	if s.Count > 0 {
+		fmt.Fprintf(w, "  耗时 min=%v mean=%v p50=%v p90=%v p99=%v max=%v\n",
+			s.Min.Round(time.Microsecond), s.Mean.Round(time.Microsecond), s.P50.Round(time.Microsecond),
+			s.P90.Round(time.Microsecond), s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+}
+
+// sortedKeys 按 key 排序
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// collector 压测过程中的统计，各虚拟用户并发写入
+type collector struct {
+	mu   sync.Mutex
+	snap *Snapshot
+}
+
+func newCollector() *collector {
+	return &collector{snap: NewSnapshot()}
+}
+
+// recordRequest 记录一次请求，err 非 nil 时计为失败
+func (c *collector) recordRequest(name string, elapsed time.Duration, status int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.snap.Groups[name]
+	if !ok {
+		g = newGroupSnapshot()
+		c.snap.Groups[name] = g
+	}
+	g.add(elapsed, status, err != nil)
+	c.snap.Total.add(elapsed, status, err != nil)
+	if err != nil {
+		c.snap.addError(name+": "+err.Error(), 1)
+	}
+}
+
+// recordIteration 记录一次迭代，请求失败导致的错误已按请求记录
+func (c *collector) recordIteration(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snap.Iterations++
+	if err == nil {
+		return
+	}
+	c.snap.FailedIterations++
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) {
+		c.snap.addError("iteration: "+err.Error(), 1)
+	}
+}
+
+// count 累加计数器
+func (c *collector) count(name string, delta int64) {
+	c.mu.Lock()
+	c.snap.Counters[name] += delta
+	c.mu.Unlock()
+}
+
+// snapshot 当前统计的副本
+func (c *collector) snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	copied := NewSnapshot()
+	copied.Merge(c.snap)
+	return copied
+}
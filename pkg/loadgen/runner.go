@@ -0,0 +1,207 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrStopVU Iteration 返回此错误时该虚拟用户不再迭代，本次迭代不计入，用于数据用完等情况
+var ErrStopVU = errors.New("stop virtual user")
+
+// Config 压测配置
+type Config struct {
+	BaseURL string `mapstructure:"base_url" json:"base_url"`
+	// VUs 虚拟用户数，即并发数
+	VUs int `mapstructure:"vus" json:"vus"`
+	// Iterations 每个虚拟用户的迭代次数，0 表示按 Duration 持续执行
+	Iterations int `mapstructure:"iterations" json:"iterations"`
+	// Duration 压测时长，与 Iterations 同时设置时先到者结束
+	Duration time.Duration `mapstructure:"duration" json:"duration"`
+	// RampUp 在此时间内均匀启动虚拟用户，0 表示同时启动
+	RampUp time.Duration `mapstructure:"ramp_up" json:"ramp_up"`
+	// RPS 所有虚拟用户合计每秒开始的迭代数上限，0 表示不限制
+	RPS float64 `mapstructure:"rps" json:"rps"`
+	// VUOffset 虚拟用户编号从 VUOffset+1 开始，分布式压测时各代理的编号互不重叠
+	VUOffset int           `mapstructure:"-" json:"vu_offset"`
+	Client   *ClientConfig `mapstructure:"client" json:"client"`
+	// Assertions 对所有请求生效的断言，默认要求 2xx
+	Assertions []Assertion `mapstructure:"-" json:"-"`
+}
+
+// DefaultConfig 默认配置：10 个虚拟用户各执行 1 次迭代
+func DefaultConfig() *Config {
+	return &Config{
+		BaseURL:    "http://localhost:8080",
+		VUs:        10,
+		Iterations: 1,
+		Client:     DefaultClientConfig(),
+		Assertions: []Assertion{StatusOK()},
+	}
+}
+
+// Runner 压测执行器
+type Runner struct {
+	config *Config
+	stats  atomic.Pointer[collector]
+}
+
+// NewRunner 创建压测执行器，config 为 nil 时使用默认配置
+func NewRunner(config *Config) *Runner {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Runner{config: config}
+}
+
+// Run 执行场景：Setup、并发迭代、Teardown。Setup 失败时返回错误；ctx 取消时提前结束并返回已有的报告
+func (r *Runner) Run(ctx context.Context, scenario *Scenario) (*Report, error) {
+	if err := r.validate(scenario); err != nil {
+		return nil, err
+	}
+	vars, err := r.Setup(ctx, scenario)
+	if err != nil {
+		return nil, err
+	}
+	report, err := r.RunShard(ctx, scenario, vars)
+	if err != nil {
+		return nil, err
+	}
+	r.Teardown(ctx, scenario, vars, report)
+	return report, nil
+}
+
+// Setup 执行场景的 Setup，返回其保存的场景数据
+func (r *Runner) Setup(ctx context.Context, scenario *Scenario) (map[string]string, error) {
+	env := r.newEnv(newCollector(), nil)
+	if scenario.Setup != nil {
+		if err := scenario.Setup(ctx, env); err != nil {
+			return nil, fmt.Errorf("setup failed: %w", err)
+		}
+	}
+	return env.Values(), nil
+}
+
+// RunShard 以 vars 为场景数据执行迭代，不执行 Setup 与 Teardown；分布式压测的代理以此执行分到的部分
+func (r *Runner) RunShard(ctx context.Context, scenario *Scenario, vars map[string]string) (*Report, error) {
+	if err := r.validate(scenario); err != nil {
+		return nil, err
+	}
+	stats := newCollector()
+	r.stats.Store(stats)
+	env := r.newEnv(stats, vars)
+
+	runCtx := ctx
+	if r.config.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, r.config.Duration)
+		defer cancel()
+	}
+	var limiter *rate.Limiter
+	if r.config.RPS > 0 {
+		limiter = rate.NewLimiter(rate.Limit(r.config.RPS), 1)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 1; i <= r.config.VUs; i++ {
+		if delay := r.startDelay(i); delay > 0 {
+			select {
+			case <-runCtx.Done():
+			case <-time.After(time.Until(start.Add(delay))):
+			}
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(vu *VU) {
+			defer wg.Done()
+			r.runVU(runCtx, scenario, vu, limiter)
+		}(&VU{Env: env, ID: r.config.VUOffset + i})
+	}
+	wg.Wait()
+
+	return stats.snapshot().Report(scenario.Name, r.config.VUs, time.Since(start)), nil
+}
+
+// Teardown 执行场景的 Teardown，返回的错误记录在 report 中
+func (r *Runner) Teardown(ctx context.Context, scenario *Scenario, vars map[string]string, report *Report) {
+	if scenario.Teardown == nil {
+		return
+	}
+	// 压测时长到达后仍需完成清理，使用调用方的 ctx
+	if err := scenario.Teardown(ctx, r.newEnv(newCollector(), vars), report); err != nil {
+		report.TeardownError = err.Error()
+	}
+}
+
+// Snapshot 正在执行或最近一次执行的统计，尚未执行时返回 nil
+func (r *Runner) Snapshot() *Snapshot {
+	stats := r.stats.Load()
+	if stats == nil {
+		return nil
+	}
+	return stats.snapshot()
+}
+
+// validate 校验场景与配置
+func (r *Runner) validate(scenario *Scenario) error {
+	if scenario.Iteration == nil {
+		return errors.New("scenario has no iteration")
+	}
+	if r.config.VUs <= 0 {
+		return fmt.Errorf("invalid vus: %d", r.config.VUs)
+	}
+	if r.config.Iterations <= 0 && r.config.Duration <= 0 {
+		return errors.New("either iterations or duration must be set")
+	}
+	return nil
+}
+
+// newEnv 创建场景环境，预置 vars
+func (r *Runner) newEnv(stats *collector, vars map[string]string) *Env {
+	env := &Env{
+		BaseURL:    r.config.BaseURL,
+		Client:     NewHTTPClient(r.config.Client),
+		assertions: r.config.Assertions,
+		stats:      stats,
+	}
+	for key, value := range vars {
+		env.Set(key, value)
+	}
+	return env
+}
+
+// startDelay 第 i 个虚拟用户相对开始时间的启动延迟
+func (r *Runner) startDelay(i int) time.Duration {
+	if r.config.RampUp <= 0 || r.config.VUs <= 1 {
+		return 0
+	}
+	return r.config.RampUp * time.Duration(i-1) / time.Duration(r.config.VUs)
+}
+
+// runVU 顺序执行一个虚拟用户的迭代，直到次数用完或 ctx 结束；limiter 非 nil 时每次迭代前等待配额
+func (r *Runner) runVU(ctx context.Context, scenario *Scenario, vu *VU, limiter *rate.Limiter) {
+	for r.config.Iterations <= 0 || vu.Iteration < r.config.Iterations {
+		if ctx.Err() != nil {
+			return
+		}
+		if limiter != nil && limiter.Wait(ctx) != nil {
+			return
+		}
+		err := scenario.Iteration(ctx, vu)
+		// 压测时长到达时被中断的迭代不计入
+		if errors.Is(err, ErrStopVU) || (err != nil && ctx.Err() != nil) {
+			return
+		}
+		vu.stats.recordIteration(err)
+		vu.Iteration++
+	}
+}
@@ -0,0 +1,187 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer 库存为 stock 的抢券服务：/create 返回 {"code":0}，/claim 在库存用完前返回 200，之后返回 409
+func newTestServer(stock int64) *httptest.Server {
+	var remaining atomic.Int64
+	remaining.Store(stock)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/create":
+			if r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"code":0,"data":{"id":"c1"}}`))
+		case "/claim/c1":
+			if remaining.Add(-1) < 0 {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"code":40901}`))
+				return
+			}
+			w.Write([]byte(`{"code":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRunner_Iterations(t *testing.T) {
+	server := newTestServer(5)
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseURL = server.URL
+	config.VUs = 20
+	config.Iterations = 2
+
+	var teardownClaimed int64
+	report, err := NewRunner(config).Run(context.Background(), &Scenario{
+		Name: "claim",
+		Setup: func(ctx context.Context, env *Env) error {
+			resp, err := env.Do(ctx, &Request{
+				Method:     http.MethodPost,
+				Path:       "/create",
+				Body:       map[string]interface{}{"total": 5},
+				Assertions: []Assertion{StatusOK(), BusinessCode(0)},
+			})
+			if err != nil {
+				return err
+			}
+			var result struct {
+				Data struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			}
+			if err := resp.JSON(&result); err != nil {
+				return err
+			}
+			env.Set("id", result.Data.ID)
+			return nil
+		},
+		Iteration: func(ctx context.Context, vu *VU) error {
+			resp, err := vu.Do(ctx, &Request{
+				Name:       "claim",
+				Method:     http.MethodPost,
+				Path:       "/claim/" + vu.Get("id"),
+				Assertions: []Assertion{StatusIn(http.StatusOK, http.StatusConflict)},
+			})
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode == http.StatusOK {
+				vu.Count("claimed", 1)
+			}
+			return nil
+		},
+		Teardown: func(ctx context.Context, env *Env, report *Report) error {
+			teardownClaimed = report.Counters["claimed"]
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, report.Failed())
+	assert.Equal(t, int64(40), report.Iterations)
+	// Setup 中的请求不计入
+	assert.Equal(t, int64(40), report.Requests.Count)
+	assert.Equal(t, int64(40), report.ByName["claim"].Count)
+	assert.Equal(t, int64(5), report.ByName["claim"].Status[http.StatusOK])
+	assert.Equal(t, int64(35), report.ByName["claim"].Status[http.StatusConflict])
+	assert.Equal(t, int64(5), report.Counters["claimed"])
+	assert.Equal(t, int64(5), teardownClaimed)
+	assert.LessOrEqual(t, report.ByName["claim"].Min, report.ByName["claim"].P50)
+	assert.LessOrEqual(t, report.ByName["claim"].P99, report.ByName["claim"].Max)
+}
+
+func TestRunner_Failures(t *testing.T) {
+	server := newTestServer(0)
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseURL = server.URL
+	config.VUs = 3
+
+	report, err := NewRunner(config).Run(context.Background(), &Scenario{
+		Name: "failures",
+		Iteration: func(ctx context.Context, vu *VU) error {
+			if vu.ID == 1 {
+				return errors.New("custom failure")
+			}
+			// 默认断言要求 2xx
+			_, err := vu.Do(ctx, &Request{Name: "missing", Path: "/missing"})
+			var reqErr *RequestError
+			if assert.ErrorAs(t, err, &reqErr) {
+				assert.Equal(t, http.StatusNotFound, reqErr.StatusCode)
+			}
+			return err
+		},
+		Teardown: func(ctx context.Context, env *Env, report *Report) error {
+			return errors.New("result check failed")
+		},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, report.Failed())
+	assert.Equal(t, int64(3), report.FailedIterations)
+	assert.Equal(t, int64(2), report.Requests.Failures)
+	assert.Equal(t, int64(2), report.Errors["missing: unexpected status 404"])
+	assert.Equal(t, int64(1), report.Errors["iteration: custom failure"])
+	assert.Equal(t, "result check failed", report.TeardownError)
+
+	var out strings.Builder
+	report.Print(&out)
+	assert.Contains(t, out.String(), "result check failed")
+}
+
+func TestRunner_Duration(t *testing.T) {
+	server := newTestServer(1 << 30)
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.BaseURL = server.URL
+	config.VUs = 4
+	config.Iterations = 0
+	config.Duration = 200 * time.Millisecond
+	config.RampUp = 100 * time.Millisecond
+
+	start := time.Now()
+	report, err := NewRunner(config).Run(context.Background(), &Scenario{
+		Name: "duration",
+		Iteration: func(ctx context.Context, vu *VU) error {
+			_, err := vu.Do(ctx, &Request{Method: http.MethodPost, Path: "/claim/c1"})
+			return err
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Greater(t, report.Iterations, int64(4))
+	assert.False(t, report.Failed())
+	assert.Greater(t, report.Requests.RPS, 0.0)
+}
+
+func TestRunner_InvalidConfig(t *testing.T) {
+	_, err := NewRunner(nil).Run(context.Background(), &Scenario{Name: "empty"})
+	assert.Error(t, err)
+
+	config := DefaultConfig()
+	config.Iterations = 0
+	_, err = NewRunner(config).Run(context.Background(), &Scenario{
+		Iteration: func(ctx context.Context, vu *VU) error { return nil },
+	})
+	assert.Error(t, err)
+}
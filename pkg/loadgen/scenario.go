@@ -0,0 +1,238 @@
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scenario 压测场景：Setup 执行一次准备数据，每个虚拟用户（VU）循环执行 Iteration，结束后执行 Teardown
+type Scenario struct {
+	Name string
+	// Setup 可选，在虚拟用户启动前执行，可通过 Env.Set 保存供迭代使用的数据；返回错误时不再压测
+	Setup func(ctx context.Context, env *Env) error
+	// Iteration 一次迭代，返回错误计为失败的迭代
+	Iteration func(ctx context.Context, vu *VU) error
+	// Teardown 可选，所有虚拟用户结束后执行，可根据 Report 校验结果，返回的错误记录在报告中
+	Teardown func(ctx context.Context, env *Env, report *Report) error
+}
+
+// Env 场景共享的环境
+type Env struct {
+	BaseURL string
+	Client  *http.Client
+
+	assertions []Assertion
+	stats      *collector
+
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// Set 保存场景数据，通常在 Setup 中调用。分布式压测时 Setup 在协调器上执行，数据随任务下发给各代理
+func (e *Env) Set(key, value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.values == nil {
+		e.values = make(map[string]string)
+	}
+	e.values[key] = value
+}
+
+// Get 读取场景数据，不存在时返回空字符串
+func (e *Env) Get(key string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.values[key]
+}
+
+// Int 读取整数类型的场景数据，不存在或无法解析时返回 0
+func (e *Env) Int(key string) int {
+	value, _ := strconv.Atoi(e.Get(key))
+	return value
+}
+
+// Values 全部场景数据的副本
+func (e *Env) Values() map[string]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	values := make(map[string]string, len(e.values))
+	for key, value := range e.values {
+		values[key] = value
+	}
+	return values
+}
+
+// Do 发送请求，记录耗时并执行断言；Setup 与 Teardown 中的请求不计入报告
+func (e *Env) Do(ctx context.Context, req *Request) (*Response, error) {
+	name := req.Name
+	if name == "" {
+		name = req.Method + " " + req.Path
+	}
+
+	httpReq, err := req.build(ctx, e.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	httpResp, err := e.Client.Do(httpReq)
+	if err != nil {
+		// 压测时长到达而中断的请求不计入
+		if ctx.Err() == nil {
+			e.stats.recordRequest(name, time.Since(start), 0, err)
+		}
+		return nil, &RequestError{Name: name, Err: err}
+	}
+	body, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	elapsed := time.Since(start)
+	if err != nil {
+		e.stats.recordRequest(name, elapsed, httpResp.StatusCode, err)
+		return nil, &RequestError{Name: name, StatusCode: httpResp.StatusCode, Err: err}
+	}
+
+	resp := &Response{
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Body:       body,
+		Duration:   elapsed,
+	}
+	if err := e.check(req, resp); err != nil {
+		e.stats.recordRequest(name, elapsed, resp.StatusCode, err)
+		return resp, &RequestError{Name: name, StatusCode: resp.StatusCode, Err: err}
+	}
+	e.stats.recordRequest(name, elapsed, resp.StatusCode, nil)
+	return resp, nil
+}
+
+// check 依次执行全局断言与请求自身的断言，请求设置了断言时全局断言不生效
+func (e *Env) check(req *Request, resp *Response) error {
+	assertions := e.assertions
+	if req.Assertions != nil {
+		assertions = req.Assertions
+	}
+	for _, assert := range assertions {
+		if err := assert(req, resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequestError 请求失败：网络错误、读取响应失败或断言不通过
+type RequestError struct {
+	Name       string
+	StatusCode int // 网络错误时为 0
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// VU 虚拟用户，各自顺序执行迭代
+type VU struct {
+	*Env
+	// ID 从 1 开始编号
+	ID int
+	// Iteration 当前迭代序号，从 0 开始
+	Iteration int
+}
+
+// Count 为报告中的计数器加 delta，用于统计业务结果（如抢券成功数）
+func (vu *VU) Count(name string, delta int64) {
+	vu.stats.count(name, delta)
+}
+
+// Request 压测请求
+type Request struct {
+	// Name 报告中的分组名，默认为 "方法 路径"；路径带 ID 时应指定，避免分组过多
+	Name   string
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	// Body 非 nil 时编码为 JSON；[]byte 与 string 原样发送
+	Body interface{}
+	// Assertions 非 nil 时替代全局断言
+	Assertions []Assertion
+}
+
+// build 构造 HTTP 请求，Path 为完整 URL 时不拼接 baseURL
+func (r *Request) build(ctx context.Context, baseURL string) (*http.Request, error) {
+	target := r.Path
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(target, "/")
+	}
+	if len(r.Query) > 0 {
+		separator := "?"
+		if strings.Contains(target, "?") {
+			separator = "&"
+		}
+		target += separator + r.Query.Encode()
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch b := r.Body.(type) {
+	case nil:
+	case []byte:
+		body = bytes.NewReader(b)
+	case string:
+		body = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// Response 压测响应，响应体已完整读取
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Duration   time.Duration
+}
+
+// JSON 解析响应体
+func (r *Response) JSON(v interface{}) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package loadgen
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// maxErrorKinds 保留的不同错误信息数上限，超出后归入 other
+const maxErrorKinds = 20
+
+// bucketGrowth 耗时直方图相邻桶的比例，分位数的相对误差约为其一半
+const bucketGrowth = 1.02
+
+var logBucketGrowth = math.Log(bucketGrowth)
+
+// Snapshot 可合并的统计快照。耗时按对数直方图统计，多个执行器（如分布式压测的各代理）的快照合并后分位数仍然有效
+type Snapshot struct {
+	Groups           map[string]*GroupSnapshot `json:"groups"`
+	Total            *GroupSnapshot            `json:"total"`
+	Counters         map[string]int64          `json:"counters"`
+	Errors           map[string]int64          `json:"errors"`
+	Iterations       int64                     `json:"iterations"`
+	FailedIterations int64                     `json:"failed_iterations"`
+}
+
+// GroupSnapshot 一组请求的统计
+type GroupSnapshot struct {
+	Count    int64         `json:"count"`
+	Failures int64         `json:"failures"`
+	Sum      time.Duration `json:"sum"`
+	Min      time.Duration `json:"min"`
+	Max      time.Duration `json:"max"`
+	Status   map[int]int64 `json:"status"`
+	// Buckets 耗时直方图，桶 b（b > 0）覆盖 bucketGrowth^(b-1)～bucketGrowth^b 微秒，桶 0 为 1 微秒以内
+	Buckets map[int]int64 `json:"buckets"`
+}
+
+// NewSnapshot 创建空快照
+func NewSnapshot() *Snapshot {
+	return &Snapshot{
+		Groups:   make(map[string]*GroupSnapshot),
+		Total:    newGroupSnapshot(),
+		Counters: make(map[string]int64),
+		Errors:   make(map[string]int64),
+	}
+}
+
+func newGroupSnapshot() *GroupSnapshot {
+	return &GroupSnapshot{Status: make(map[int]int64), Buckets: make(map[int]int64)}
+}
+
+// Merge 把 other 累加到 s
+func (s *Snapshot) Merge(other *Snapshot) {
+	if other == nil {
+		return
+	}
+	for name, g := range other.Groups {
+		mine, ok := s.Groups[name]
+		if !ok {
+			mine = newGroupSnapshot()
+			s.Groups[name] = mine
+		}
+		mine.merge(g)
+	}
+	s.Total.merge(other.Total)
+	for name, n := range other.Counters {
+		s.Counters[name] += n
+	}
+	for message, n := range other.Errors {
+		s.addError(message, n)
+	}
+	s.Iterations += other.Iterations
+	s.FailedIterations += other.FailedIterations
+}
+
+// Report 生成报告，duration 为压测耗时，用于计算 QPS
+func (s *Snapshot) Report(scenario string, vus int, duration time.Duration) *Report {
+	r := &Report{
+		Scenario:         scenario,
+		VUs:              vus,
+		Duration:         duration,
+		Iterations:       s.Iterations,
+		FailedIterations: s.FailedIterations,
+		Requests:         *s.Total.stats(duration),
+		ByName:           make(map[string]*RequestStats, len(s.Groups)),
+		Counters:         make(map[string]int64, len(s.Counters)),
+		Errors:           make(map[string]int64, len(s.Errors)),
+	}
+	for name, g := range s.Groups {
+		r.ByName[name] = g.stats(duration)
+	}
+	for name, n := range s.Counters {
+		r.Counters[name] = n
+	}
+	for message, n := range s.Errors {
+		r.Errors[message] = n
+	}
+	return r
+}
+
+// addError 按错误信息计数，不同信息过多时归入 other
+func (s *Snapshot) addError(message string, n int64) {
+	if _, ok := s.Errors[message]; !ok && len(s.Errors) >= maxErrorKinds {
+		message = "other"
+	}
+	s.Errors[message] += n
+}
+
+// add 记录一次请求
+func (g *GroupSnapshot) add(elapsed time.Duration, status int, failed bool) {
+	g.Count++
+	if failed {
+		g.Failures++
+	}
+	g.Sum += elapsed
+	if g.Count == 1 || elapsed < g.Min {
+		g.Min = elapsed
+	}
+	if elapsed > g.Max {
+		g.Max = elapsed
+	}
+	g.Status[status]++
+	g.Buckets[bucketOf(elapsed)]++
+}
+
+// merge 把 other 累加到 g
+func (g *GroupSnapshot) merge(other *GroupSnapshot) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if g.Count == 0 || other.Min < g.Min {
+		g.Min = other.Min
+	}
+	if other.Max > g.Max {
+		g.Max = other.Max
+	}
+	g.Count += other.Count
+	g.Failures += other.Failures
+	g.Sum += other.Sum
+	for status, n := range other.Status {
+		g.Status[status] += n
+	}
+	for bucket, n := range other.Buckets {
+		g.Buckets[bucket] += n
+	}
+}
+
+// stats 汇总为报告中的统计
+func (g *GroupSnapshot) stats(duration time.Duration) *RequestStats {
+	s := &RequestStats{
+		Count:    g.Count,
+		Failures: g.Failures,
+		Min:      g.Min,
+		Max:      g.Max,
+		Status:   make(map[int]int64, len(g.Status)),
+	}
+	for status, n := range g.Status {
+		s.Status[status] = n
+	}
+	if duration > 0 {
+		s.RPS = float64(g.Count) / duration.Seconds()
+	}
+	if g.Count == 0 {
+		return s
+	}
+	s.Mean = g.Sum / time.Duration(g.Count)
+	s.P50 = g.percentile(0.50)
+	s.P90 = g.percentile(0.90)
+	s.P99 = g.percentile(0.99)
+	return s
+}
+
+// percentile 直方图估算的分位数，限制在 Min～Max 之间
+func (g *GroupSnapshot) percentile(p float64) time.Duration {
+	buckets := make([]int, 0, len(g.Buckets))
+	for bucket := range g.Buckets {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+
+	rank := int64(math.Ceil(p * float64(g.Count)))
+	var seen int64
+	value := g.Max
+	for _, bucket := range buckets {
+		seen += g.Buckets[bucket]
+		if seen >= rank {
+			value = bucketValue(bucket)
+			break
+		}
+	}
+	if value < g.Min {
+		value = g.Min
+	}
+	if value > g.Max {
+		value = g.Max
+	}
+	return value
+}
+
+// bucketOf 耗时所在的桶
+func bucketOf(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(us) / logBucketGrowth))
+}
+
+// bucketValue 桶的代表值（上下界的几何平均）
+func bucketValue(bucket int) time.Duration {
+	if bucket <= 0 {
+		return time.Microsecond
+	}
+	us := math.Exp((float64(bucket) - 0.5) * logBucketGrowth)
+	return time.Duration(us * float64(time.Microsecond))
+}
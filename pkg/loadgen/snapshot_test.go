@@ -0,0 +1,59 @@
+package loadgen
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_MergePercentiles(t *testing.T) {
+	// 两个执行器各记录一半：1ms～50ms 与 51ms～100ms
+	first, second := newCollector(), newCollector()
+	for i := 1; i <= 100; i++ {
+		stats := first
+		if i > 50 {
+			stats = second
+		}
+		stats.recordRequest("claim", time.Duration(i)*time.Millisecond, http.StatusOK, nil)
+		stats.recordIteration(nil)
+	}
+	second.recordRequest("claim", time.Second, 0, assert.AnError)
+	second.count("claimed", 3)
+
+	// 快照经 JSON 传输后合并
+	data, err := json.Marshal(second.snapshot())
+	require.NoError(t, err)
+	var decoded Snapshot
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	merged := first.snapshot()
+	merged.Merge(&decoded)
+	report := merged.Report("claim", 2, 2*time.Second)
+
+	stats := report.ByName["claim"]
+	assert.Equal(t, int64(101), stats.Count)
+	assert.Equal(t, int64(1), stats.Failures)
+	assert.Equal(t, int64(100), stats.Status[http.StatusOK])
+	assert.Equal(t, time.Millisecond, stats.Min)
+	assert.Equal(t, time.Second, stats.Max)
+	assert.InEpsilon(t, float64(51*time.Millisecond), float64(stats.P50), 0.02)
+	assert.InEpsilon(t, float64(91*time.Millisecond), float64(stats.P90), 0.02)
+	assert.InEpsilon(t, float64(100*time.Millisecond), float64(stats.P99), 0.02)
+	assert.InDelta(t, 50.5, report.Requests.RPS, 0.01)
+	assert.Equal(t, int64(100), report.Iterations)
+	assert.Equal(t, int64(3), report.Counters["claimed"])
+	assert.Equal(t, int64(1), report.Errors["claim: "+assert.AnError.Error()])
+}
+
+func TestSnapshot_ErrorKindsCapped(t *testing.T) {
+	snapshot := NewSnapshot()
+	for i := 0; i < maxErrorKinds+5; i++ {
+		snapshot.addError(time.Duration(i).String(), 1)
+	}
+	assert.Len(t, snapshot.Errors, maxErrorKinds+1)
+	assert.Equal(t, int64(5), snapshot.Errors["other"])
+}
@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"context"
+	"user_crud_jwt/pkg/ctxutil"
+
+	"go.uber.org/zap"
+)
+
+// FromContext 返回携带请求 ID 与关联 ID 字段的日志器
+func FromContext(ctx context.Context) *zap.Logger {
+	log := Log
+	if log == nil {
+		log = zap.NewNop()
+	}
+
+	if requestID := ctxutil.RequestID(ctx); requestID != "" {
+		log = log.With(zap.String("request_id", requestID))
+	}
+	if correlationID := ctxutil.CorrelationID(ctx); correlationID != "" {
+		log = log.With(zap.String("correlation_id", correlationID))
+	}
+	return log
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"os"
+	"user_crud_jwt/pkg/masking"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -9,6 +10,7 @@ import (
 
 var Log *zap.Logger
 
+// InitLogger 初始化日志器，手机号、邮箱、令牌等字段按 masking.DefaultConfig 自动脱敏
 func InitLogger(mode string) {
 	var config zap.Config
 
@@ -21,7 +23,10 @@ func InitLogger(mode string) {
 	}
 
 	var err error
-	Log, err = config.Build()
+	masker := masking.NewMasker(masking.DefaultConfig())
+	Log, err = config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return NewMaskingCore(core, masker)
+	}))
 	if err != nil {
 		os.Exit(1)
 	}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"encoding/json"
+	"strings"
+	"user_crud_jwt/pkg/masking"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maskingCore 写出前脱敏日志字段：配置的字段名整体脱敏，结构体、对象及 JSON 字符串按其中的字段名脱敏
+type maskingCore struct {
+	zapcore.Core
+	masker *masking.Masker
+}
+
+// NewMaskingCore 为 core 挂载字段脱敏
+func NewMaskingCore(core zapcore.Core, masker *masking.Masker) zapcore.Core {
+	return &maskingCore{Core: core, masker: masker}
+}
+
+func (c *maskingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &maskingCore{Core: c.Core.With(c.maskFields(fields)), masker: c.masker}
+}
+
+func (c *maskingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *maskingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, c.maskFields(fields))
+}
+
+func (c *maskingCore) maskFields(fields []zapcore.Field) []zapcore.Field {
+	masked := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		masked[i] = c.maskField(field)
+	}
+	return masked
+}
+
+func (c *maskingCore) maskField(field zapcore.Field) zapcore.Field {
+	kind, configured := c.masker.Kind(field.Key)
+
+	switch field.Type {
+	case zapcore.StringType:
+		if configured {
+			return zap.String(field.Key, masking.MaskKind(kind, field.String))
+		}
+		// 请求体等以字符串记录的 JSON
+		if trimmed := strings.TrimSpace(field.String); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			if masked, err := c.masker.MaskJSON([]byte(trimmed)); err == nil {
+				return zap.String(field.Key, string(masked))
+			}
+		}
+		return field
+	case zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		// 结构体、对象中可能嵌套配置的字段
+	case zapcore.SkipType, zapcore.NamespaceType, zapcore.InlineMarshalerType:
+		return field
+	default:
+		// 数字、布尔、时间等标量只处理配置的字段
+		if !configured {
+			return field
+		}
+	}
+
+	// 通过 MapObjectEncoder 展开为 {key: value} 后按 JSON 字段名脱敏
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	data, err := json.Marshal(enc.Fields)
+	if err != nil {
+		return zap.String(field.Key, masking.Placeholder)
+	}
+	masked, err := c.masker.MaskJSON(data)
+	if err != nil {
+		return zap.String(field.Key, masking.Placeholder)
+	}
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(masked, &wrapper); err != nil {
+		return zap.String(field.Key, masking.Placeholder)
+	}
+	value := wrapper[field.Key]
+	// 字符串直接记录，避免编码器再次转义引号
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return zap.String(field.Key, str)
+	}
+	return zap.Reflect(field.Key, value)
+}
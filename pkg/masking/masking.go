@@ -0,0 +1,215 @@
+package masking
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kind 字段类型，决定脱敏方式
+type Kind string
+
+const (
+	KindPhone  Kind = "phone"   // 保留前 3 位与后 4 位
+	KindEmail  Kind = "email"   // 保留用户名首字符与域名
+	KindName   Kind = "name"    // 保留首字
+	KindIDCard Kind = "id_card" // 保留前 3 位与后 4 位
+	KindSecret Kind = "secret"  // 整体替换，不保留任何内容
+)
+
+// Placeholder 整体替换时使用的占位符
+const Placeholder = "******"
+
+// maskers 各类型的脱敏函数
+var maskers = map[Kind]func(string) string{
+	KindPhone:  Phone,
+	KindEmail:  Email,
+	KindName:   Name,
+	KindIDCard: IDCard,
+	KindSecret: Secret,
+}
+
+// Phone 手机号脱敏，如 13800138000 -> 138****8000
+func Phone(value string) string {
+	return keepEnds(value, 3, 4)
+}
+
+// Email 邮箱脱敏，如 alice@example.com -> a***@example.com
+func Email(value string) string {
+	at := strings.LastIndex(value, "@")
+	if at <= 0 {
+		return Secret(value)
+	}
+	first, _ := utf8.DecodeRuneInString(value)
+	return string(first) + "***" + value[at:]
+}
+
+// Name 姓名、昵称脱敏，如 张三丰 -> 张**
+func Name(value string) string {
+	n := utf8.RuneCountInString(value)
+	if n <= 1 {
+		return value
+	}
+	first, _ := utf8.DecodeRuneInString(value)
+	return string(first) + strings.Repeat("*", n-1)
+}
+
+// IDCard 证件号脱敏，如 110101199001011234 -> 110***********1234
+func IDCard(value string) string {
+	return keepEnds(value, 3, 4)
+}
+
+// Secret 密码、令牌等整体替换，空值保持为空
+func Secret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Placeholder
+}
+
+// keepEnds 保留首尾字符，中间替换为 *；过短时整体替换
+func keepEnds(value string, head, tail int) string {
+	runes := []rune(value)
+	if len(runes) <= head+tail {
+		return Secret(value)
+	}
+	return string(runes[:head]) + strings.Repeat("*", len(runes)-head-tail) + string(runes[len(runes)-tail:])
+}
+
+// Config 需要脱敏的 JSON 字段名及其类型
+type Config struct {
+	Fields map[string]Kind // 字段名比较时忽略大小写及 "_"、"-"，mobile_phone 与 mobilePhone 视为同一字段
+}
+
+// DefaultConfig 默认脱敏字段
+func DefaultConfig() Config {
+	return Config{
+		Fields: map[string]Kind{
+			"mobile":        KindPhone,
+			"phone":         KindPhone,
+			"email":         KindEmail,
+			"real_name":     KindName,
+			"id_card":       KindIDCard,
+			"password":      KindSecret,
+			"token":         KindSecret,
+			"access_token":  KindSecret,
+			"refresh_token": KindSecret,
+			"secret":        KindSecret,
+			"authorization": KindSecret,
+		},
+	}
+}
+
+// Masker 按字段名脱敏，可用于日志字段与 JSON 响应
+type Masker struct {
+	fields map[string]Kind
+}
+
+// NewMasker 创建脱敏器
+func NewMasker(config Config) *Masker {
+	m := &Masker{fields: make(map[string]Kind, len(config.Fields))}
+	for key, kind := range config.Fields {
+		m.fields[normalizeKey(key)] = kind
+	}
+	return m
+}
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	return strings.NewReplacer("_", "", "-", "").Replace(key)
+}
+
+// Kind 返回字段的脱敏类型，未配置的字段返回 false
+func (m *Masker) Kind(key string) (Kind, bool) {
+	kind, ok := m.fields[normalizeKey(key)]
+	return kind, ok
+}
+
+// MaskString 按字段类型脱敏，未配置的字段原样返回
+func (m *Masker) MaskString(key, value string) string {
+	kind, ok := m.Kind(key)
+	if !ok {
+		return value
+	}
+	return MaskKind(kind, value)
+}
+
+// MaskKind 按类型脱敏，未知类型按 KindSecret 处理
+func MaskKind(kind Kind, value string) string {
+	if fn, ok := maskers[kind]; ok {
+		return fn(value)
+	}
+	return Secret(value)
+}
+
+// MaskValue 脱敏 JSON 解码得到的值（map[string]interface{}、[]interface{} 及标量），原值不被修改
+func (m *Masker) MaskValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			masked[key] = m.maskField(key, item)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(value))
+		for i, item := range value {
+			masked[i] = m.MaskValue(item)
+		}
+		return masked
+	}
+	return v
+}
+
+// maskField 配置的字段为对象或数组时继续向下脱敏，为数字等非字符串标量时整体替换
+func (m *Masker) maskField(key string, v interface{}) interface{} {
+	kind, ok := m.Kind(key)
+	if !ok {
+		return m.MaskValue(v)
+	}
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return MaskKind(kind, value)
+	case map[string]interface{}, []interface{}:
+		return m.MaskValue(value)
+	default:
+		return MaskKind(kind, fmt.Sprint(value))
+	}
+}
+
+// MaskJSON 脱敏 JSON 文本中配置的字段
+func (m *Masker) MaskJSON(data []byte) ([]byte, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m.MaskValue(v))
+}
+
+// Mask 按 JSON 序列化结果脱敏任意值，返回可直接序列化的 json.RawMessage
+func (m *Masker) Mask(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value for masking: %w", err)
+	}
+	masked, err := m.MaskJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mask value: %w", err)
+	}
+	return json.RawMessage(masked), nil
+}
+
+// decode 使用 json.Number 保留数字原样，避免大整数丢失精度
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -2,11 +2,15 @@ package metrics
 
 import (
 	"context"
+	"database/sql"
+	"sort"
 	"sync"
 	"time"
+	"user_crud_jwt/pkg/ctxutil"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // MetricsCollector 指标收集器
@@ -17,17 +21,93 @@ type MetricsCollector struct {
 	httpRequestSize     *prometheus.HistogramVec
 	httpResponseSize    *prometheus.HistogramVec
 
+	// gRPC 指标
+	grpcRequestsTotal   *prometheus.CounterVec
+	grpcRequestDuration *prometheus.HistogramVec
+
 	// 数据库指标
-	dbConnectionsActive prometheus.Gauge
-	dbConnectionsIdle   prometheus.Gauge
-	dbQueryDuration     *prometheus.HistogramVec
-	dbQueryTotal        *prometheus.CounterVec
-	dbErrorsTotal       *prometheus.CounterVec
+	dbConnectionsActive  prometheus.Gauge
+	dbConnectionsIdle    prometheus.Gauge
+	dbQueryDuration      *prometheus.HistogramVec
+	dbQueryTotal         *prometheus.CounterVec
+	dbErrorsTotal        *prometheus.CounterVec
+	dbQueryTimeoutsTotal *prometheus.CounterVec
+	dbTxRetriesTotal     *prometheus.CounterVec
+	dbTxExhaustedTotal   *prometheus.CounterVec
+
+	// 数据库连接池指标，来自 sql.DBStats
+	dbConnectionsOpen        prometheus.Gauge
+	dbConnectionsMaxOpen     prometheus.Gauge
+	dbConnectionWaitsTotal   prometheus.Counter
+	dbConnectionWait         prometheus.Histogram
+	dbConnectionsClosedTotal *prometheus.CounterVec
+
+	// 表与索引膨胀指标，按 schema.relation
+	dbBloatBytes *prometheus.GaugeVec
+	dbBloatRatio *prometheus.GaugeVec
+	dbDeadTuples *prometheus.GaugeVec
+
+	// 锁等待与死锁指标
+	dbLockWaits       prometheus.Gauge
+	dbLockWaitLongest prometheus.Gauge
+	dbDeadlocksTotal  prometheus.Counter
+
+	// 只读副本复制延迟，按副本序号
+	dbReplicaLag *prometheus.GaugeVec
 
 	// 缓存指标
 	cacheHitsTotal         *prometheus.CounterVec
 	cacheMissesTotal       *prometheus.CounterVec
 	cacheOperationDuration *prometheus.HistogramVec
+	cacheTTLSeconds        *prometheus.HistogramVec
+	cacheTTLProfileSeconds *prometheus.GaugeVec
+	cacheMemoryBytes       *prometheus.GaugeVec
+	cacheCallerTotal       *prometheus.CounterVec
+	cacheCallerDuration    *prometheus.HistogramVec
+
+	// 策略决定指标
+	policyDecisionsTotal   *prometheus.CounterVec
+	policyDecisionDuration *prometheus.HistogramVec
+
+	// IP 信誉指标
+	ipReputationActionsTotal *prometheus.CounterVec
+
+	// WAF 指标
+	wafRuleHitsTotal *prometheus.CounterVec
+
+	// 安全事件指标
+	securityEventsTotal *prometheus.CounterVec
+
+	// 组件事件计数，用于各监控、调度组件中不属于错误的计数
+	componentEventsTotal *prometheus.CounterVec
+
+	// 自适应并发限制指标，按路由组
+	concurrencyLimit         *prometheus.GaugeVec
+	concurrencyInflight      *prometheus.GaugeVec
+	concurrencyRejectedTotal *prometheus.CounterVec
+
+	// 优先级准入队列指标，按优先级
+	priorityQueueDepth    *prometheus.GaugeVec
+	priorityInflight      *prometheus.GaugeVec
+	priorityRejectedTotal *prometheus.CounterVec
+	priorityQueueWait     *prometheus.HistogramVec
+
+	// 数据保留指标，按数据集
+	retentionRowsTotal   *prometheus.CounterVec
+	retentionPendingRows *prometheus.GaugeVec
+
+	// Redis 集群拓扑指标
+	redisRedirectsTotal       *prometheus.CounterVec
+	redisRedirectStormsTotal  prometheus.Counter
+	redisTopologyRefreshTotal *prometheus.CounterVec
+	redisTopologyChangesTotal prometheus.Counter
+	redisSlotsMovedTotal      prometheus.Counter
+
+	// Redis 集群节点指标，按节点地址
+	redisNodeCommandDuration *prometheus.HistogramVec
+	redisNodeCommandErrors   *prometheus.CounterVec
+	redisNodeConnections     *prometheus.GaugeVec
+	redisNodePoolEventsTotal *prometheus.CounterVec
 
 	// 应用指标
 	activeGoroutines prometheus.Gauge
@@ -75,6 +155,24 @@ func NewMetricsCollector() *MetricsCollector {
 			[]string{"method", "endpoint"},
 		),
 
+		// gRPC 指标
+		grpcRequestsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "grpc_requests_total",
+				Help: "Total number of gRPC requests",
+			},
+			[]string{"method", "code"},
+		),
+
+		grpcRequestDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "grpc_request_duration_seconds",
+				Help:    "gRPC request duration in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"method"},
+		),
+
 		// 数据库指标
 		dbConnectionsActive: promauto.NewGauge(
 			prometheus.GaugeOpts{
@@ -115,6 +213,124 @@ func NewMetricsCollector() *MetricsCollector {
 			[]string{"operation", "error_type"},
 		),
 
+		dbQueryTimeoutsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_query_timeouts_total",
+				Help: "Total number of database queries cancelled by their timeout",
+			},
+			[]string{"class"},
+		),
+
+		dbTxRetriesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_tx_retries_total",
+				Help: "Total number of transaction retries after serialization failures, deadlocks or stale versions",
+			},
+			[]string{"operation", "sqlstate"},
+		),
+
+		dbTxExhaustedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_tx_retries_exhausted_total",
+				Help: "Total number of transactions that still failed after all retry attempts",
+			},
+			[]string{"operation"},
+		),
+
+		// 数据库连接池指标
+		dbConnectionsOpen: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_connections_open",
+				Help: "Number of established database connections, both in use and idle",
+			},
+		),
+
+		dbConnectionsMaxOpen: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_connections_max_open",
+				Help: "Maximum number of open database connections",
+			},
+		),
+
+		dbConnectionWaitsTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "db_connection_waits_total",
+				Help: "Total number of times a query waited for a database connection",
+			},
+		),
+
+		dbConnectionWait: promauto.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "db_connection_wait_duration_seconds",
+				Help:    "Time spent waiting for a database connection, averaged per sampling interval",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+			},
+		),
+
+		dbConnectionsClosedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "db_connections_closed_total",
+				Help: "Total number of database connections closed by the pool",
+			},
+			[]string{"reason"},
+		),
+
+		// 表与索引膨胀指标
+		dbBloatBytes: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_bloat_bytes",
+				Help: "Estimated reclaimable bytes of tables and indexes",
+			},
+			[]string{"relation", "kind"},
+		),
+
+		dbBloatRatio: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_bloat_ratio",
+				Help: "Estimated reclaimable fraction of tables and indexes",
+			},
+			[]string{"relation", "kind"},
+		),
+
+		dbDeadTuples: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_dead_tuples",
+				Help: "Dead tuples per table awaiting vacuum",
+			},
+			[]string{"relation"},
+		),
+
+		// 锁等待与死锁指标
+		dbLockWaits: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_lock_waits",
+				Help: "Sessions waiting for a lock at the last sample",
+			},
+		),
+
+		dbLockWaitLongest: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "db_lock_wait_longest_seconds",
+				Help: "Longest running statement waiting for a lock at the last sample",
+			},
+		),
+
+		dbDeadlocksTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "db_deadlocks_total",
+				Help: "Total number of deadlocks reported by the database since monitoring started",
+			},
+		),
+
+		// 只读副本复制延迟
+		dbReplicaLag: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "db_replica_lag_seconds",
+				Help: "Replication lag of each read replica at the last sample, -1 when unknown",
+			},
+			[]string{"replica"},
+		),
+
 		// 缓存指标
 		cacheHitsTotal: promauto.NewCounterVec(
 			prometheus.CounterOpts{
@@ -141,6 +357,250 @@ func NewMetricsCollector() *MetricsCollector {
 			[]string{"operation", "cache_type"},
 		),
 
+		cacheTTLSeconds: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cache_ttl_seconds",
+				Help:    "TTL applied to cache writes, by TTL profile namespace (default when no profile matches) and cache level",
+				Buckets: []float64{1, 10, 30, 60, 300, 900, 1800, 3600, 21600, 86400, 604800},
+			},
+			[]string{"namespace", "level"},
+		),
+
+		cacheTTLProfileSeconds: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cache_ttl_profile_seconds",
+				Help: "Configured TTL of each cache TTL profile and cache level, 0 when the caller default applies",
+			},
+			[]string{"namespace", "level"},
+		),
+
+		cacheMemoryBytes: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cache_memory_bytes",
+				Help: "Redis memory used by each key namespace, estimated from the latest sampled memory analysis",
+			},
+			[]string{"namespace"},
+		),
+
+		cacheCallerTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cache_caller_reads_total",
+				Help: "Cache key reads by cache type, caller (HTTP route or gRPC method, background without one) and result (hit, miss or error)",
+			},
+			[]string{"cache_type", "caller", "result"},
+		),
+
+		cacheCallerDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cache_caller_read_duration_seconds",
+				Help:    "Cache read latency by cache type and caller, one observation per read call",
+				Buckets: []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5},
+			},
+			[]string{"cache_type", "caller"},
+		),
+
+		// 策略决定指标
+		policyDecisionsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "policy_decisions_total",
+				Help: "Total number of policy decisions",
+			},
+			[]string{"decision", "source"},
+		),
+
+		policyDecisionDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "policy_decision_duration_seconds",
+				Help:    "Policy decision latency in seconds",
+				Buckets: []float64{0.00001, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1},
+			},
+			[]string{"source"},
+		),
+
+		// IP 信誉指标
+		ipReputationActionsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "ip_reputation_actions_total",
+				Help: "Total number of IP reputation blocks and rejected requests",
+			},
+			[]string{"action"},
+		),
+
+		// WAF 指标
+		wafRuleHitsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "waf_rule_hits_total",
+				Help: "Total number of WAF rule hits by rule and resulting request action",
+			},
+			[]string{"rule", "action"},
+		),
+
+		// 安全事件指标
+		securityEventsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "security_events_total",
+				Help: "Total number of security events by type and level",
+			},
+			[]string{"type", "level"},
+		),
+
+		// 组件事件计数
+		componentEventsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "component_events_total",
+				Help: "Total number of non-error events reported by background components",
+			},
+			[]string{"component", "event"},
+		),
+
+		// 自适应并发限制指标
+		concurrencyLimit: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_concurrency_limit",
+				Help: "Current adaptive concurrency limit by route group",
+			},
+			[]string{"group"},
+		),
+
+		concurrencyInflight: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_concurrency_inflight",
+				Help: "Number of in-flight requests by route group",
+			},
+			[]string{"group"},
+		),
+
+		concurrencyRejectedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_concurrency_rejected_total",
+				Help: "Total number of requests shed by the adaptive concurrency limiter",
+			},
+			[]string{"group"},
+		),
+
+		// 优先级准入队列指标
+		priorityQueueDepth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_priority_queue_depth",
+				Help: "Number of requests waiting for admission by priority",
+			},
+			[]string{"priority"},
+		),
+
+		priorityInflight: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_priority_inflight",
+				Help: "Number of admitted in-flight requests by priority",
+			},
+			[]string{"priority"},
+		),
+
+		priorityRejectedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_priority_rejected_total",
+				Help: "Total number of requests rejected by the priority admission queue",
+			},
+			[]string{"priority", "reason"},
+		),
+
+		priorityQueueWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_priority_queue_wait_seconds",
+				Help:    "Time queued requests waited for admission in seconds",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			[]string{"priority"},
+		),
+
+		// 数据保留指标
+		retentionRowsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "retention_rows_total",
+				Help: "Total number of rows purged by retention policies",
+			},
+			[]string{"dataset", "action"},
+		),
+
+		retentionPendingRows: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "retention_pending_rows",
+				Help: "Rows exceeding the retention policy at the last enforcement or dry run",
+			},
+			[]string{"dataset"},
+		),
+
+		// Redis 集群拓扑指标
+		redisRedirectsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_cluster_redirects_total",
+				Help: "Total number of MOVED/ASK redirects returned by Redis cluster nodes",
+			},
+			[]string{"type"},
+		),
+
+		redisRedirectStormsTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "redis_cluster_redirect_storms_total",
+				Help: "Total number of redirect storms that triggered an early topology refresh",
+			},
+		),
+
+		redisTopologyRefreshTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_cluster_topology_refresh_total",
+				Help: "Total number of Redis cluster topology refreshes",
+			},
+			[]string{"status"},
+		),
+
+		redisTopologyChangesTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "redis_cluster_topology_changes_total",
+				Help: "Total number of detected Redis cluster topology changes",
+			},
+		),
+
+		redisSlotsMovedTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "redis_cluster_slots_moved_total",
+				Help: "Total number of hash slots observed moving between nodes",
+			},
+		),
+
+		// Redis 集群节点指标
+		redisNodeCommandDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "redis_node_command_duration_seconds",
+				Help:    "Redis command latency by cluster node in seconds, pipelines count once",
+				Buckets: []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+			},
+			[]string{"node"},
+		),
+
+		redisNodeCommandErrors: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_node_command_errors_total",
+				Help: "Total number of failed Redis commands by cluster node",
+			},
+			[]string{"node"},
+		),
+
+		redisNodeConnections: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "redis_node_pool_connections",
+				Help: "Number of pooled connections by cluster node",
+			},
+			[]string{"node", "state"},
+		),
+
+		redisNodePoolEventsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "redis_node_pool_events_total",
+				Help: "Total number of connection pool hits, misses, timeouts and stale connections by cluster node",
+			},
+			[]string{"node", "event"},
+		),
+
 		// 应用指标
 		activeGoroutines: promauto.NewGauge(
 			prometheus.GaugeOpts{
@@ -168,6 +628,12 @@ func (m *MetricsCollector) RecordHTTPRequest(method, endpoint, status string, du
 	m.httpResponseSize.WithLabelValues(method, endpoint).Observe(float64(responseSize))
 }
 
+// RecordGRPCRequest 记录 gRPC 请求指标，code 为 gRPC 状态码名称
+func (m *MetricsCollector) RecordGRPCRequest(method, code string, duration time.Duration) {
+	m.grpcRequestsTotal.WithLabelValues(method, code).Inc()
+	m.grpcRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
 // RecordDBQuery 记录数据库查询指标
 func (m *MetricsCollector) RecordDBQuery(operation, table string, duration time.Duration, success bool) {
 	status := "success"
@@ -194,12 +660,403 @@ func (m *MetricsCollector) RecordCacheOperation(operation, cacheType, keyPrefix
 	m.cacheOperationDuration.WithLabelValues(operation, cacheType).Observe(duration.Seconds())
 }
 
+// BackgroundCaller 上下文中没有调用方标签（后台任务等）时使用的标签
+const BackgroundCaller = "background"
+
+// RecordCacheOperationContext 同 RecordCacheOperation，并按 ctx 中的调用方记录命中与耗时
+func (m *MetricsCollector) RecordCacheOperationContext(ctx context.Context, operation, cacheType, keyPrefix string, duration time.Duration, hit bool) {
+	m.RecordCacheOperation(operation, cacheType, keyPrefix, duration, hit)
+	if hit {
+		m.RecordCacheCaller(ctx, cacheType, duration, 1, 0, 0)
+	} else {
+		m.RecordCacheCaller(ctx, cacheType, duration, 0, 1, 0)
+	}
+}
+
+// RecordCacheCaller 按 ctx 中的调用方记录一次读取：批量读取的各键分别计入命中、未命中与出错，耗时记录一次
+func (m *MetricsCollector) RecordCacheCaller(ctx context.Context, cacheType string, duration time.Duration, hits, misses, errors int) {
+	caller := ctxutil.Caller(ctx)
+	if caller == "" {
+		caller = BackgroundCaller
+	}
+	for result, n := range map[string]int{"hit": hits, "miss": misses, "error": errors} {
+		if n > 0 {
+			m.cacheCallerTotal.WithLabelValues(cacheType, caller, result).Add(float64(n))
+		}
+	}
+	m.cacheCallerDuration.WithLabelValues(cacheType, caller).Observe(duration.Seconds())
+}
+
+// CacheCallerStat 按缓存类型与调用方汇总的命中情况
+type CacheCallerStat struct {
+	CacheType    string  `json:"cache_type"`
+	Caller       string  `json:"caller"`
+	Hits         uint64  `json:"hits"`
+	Misses       uint64  `json:"misses"`
+	Errors       uint64  `json:"errors"`
+	HitRate      float64 `json:"hit_rate"` // 命中 / (命中 + 未命中)，没有请求时为 0
+	Reads        uint64  `json:"reads"`    // 读取调用次数，批量读取计一次
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// CacheCallerStats 进程启动以来 RecordCacheCaller 记录的统计，按读取的键数降序
+func (m *MetricsCollector) CacheCallerStats() []CacheCallerStat {
+	type series struct{ cacheType, caller string }
+	totals := make(map[series]*CacheCallerStat)
+	collect := func(collector prometheus.Collector, add func(stat *CacheCallerStat, pb *dto.Metric, labels map[string]string)) {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			collector.Collect(ch)
+			close(ch)
+		}()
+		for metric := range ch {
+			var pb dto.Metric
+			if err := metric.Write(&pb); err != nil {
+				continue
+			}
+			labels := make(map[string]string, len(pb.GetLabel()))
+			for _, label := range pb.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			key := series{cacheType: labels["cache_type"], caller: labels["caller"]}
+			stat, ok := totals[key]
+			if !ok {
+				stat = &CacheCallerStat{CacheType: key.cacheType, Caller: key.caller}
+				totals[key] = stat
+			}
+			add(stat, &pb, labels)
+		}
+	}
+	collect(m.cacheCallerTotal, func(stat *CacheCallerStat, pb *dto.Metric, labels map[string]string) {
+		n := uint64(pb.GetCounter().GetValue())
+		switch labels["result"] {
+		case "hit":
+			stat.Hits += n
+		case "miss":
+			stat.Misses += n
+		case "error":
+			stat.Errors += n
+		}
+	})
+	collect(m.cacheCallerDuration, func(stat *CacheCallerStat, pb *dto.Metric, labels map[string]string) {
+		histogram := pb.GetHistogram()
+		stat.Reads = histogram.GetSampleCount()
+		if stat.Reads > 0 {
+			stat.AvgLatencyMs = histogram.GetSampleSum() / float64(stat.Reads) * 1000
+		}
+	})
+
+	stats := make([]CacheCallerStat, 0, len(totals))
+	for _, stat := range totals {
+		if total := stat.Hits + stat.Misses; total > 0 {
+			stat.HitRate = float64(stat.Hits) / float64(total)
+		}
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i].Hits+stats[i].Misses+stats[i].Errors, stats[j].Hits+stats[j].Misses+stats[j].Errors
+		if a != b {
+			return a > b
+		}
+		if stats[i].CacheType != stats[j].CacheType {
+			return stats[i].CacheType < stats[j].CacheType
+		}
+		return stats[i].Caller < stats[j].Caller
+	})
+	return stats
+}
+
+// ObserveCacheTTL 记录一次缓存写入使用的有效期，namespace 为匹配的 TTL 配置，level 为 local 或 remote
+func (m *MetricsCollector) ObserveCacheTTL(namespace, level string, ttl time.Duration) {
+	m.cacheTTLSeconds.WithLabelValues(namespace, level).Observe(ttl.Seconds())
+}
+
+// UpdateCacheTTLProfile 更新 TTL 配置的当前值，删除配置时 removed 为 true
+func (m *MetricsCollector) UpdateCacheTTLProfile(namespace string, ttl, localTTL time.Duration, removed bool) {
+	if removed {
+		m.cacheTTLProfileSeconds.DeleteLabelValues(namespace, "remote")
+		m.cacheTTLProfileSeconds.DeleteLabelValues(namespace, "local")
+		return
+	}
+	m.cacheTTLProfileSeconds.WithLabelValues(namespace, "remote").Set(ttl.Seconds())
+	m.cacheTTLProfileSeconds.WithLabelValues(namespace, "local").Set(localTTL.Seconds())
+}
+
+// UpdateCacheMemory 以最近一次容量分析替换各命名空间的内存占用，未再采样到的命名空间被移除
+func (m *MetricsCollector) UpdateCacheMemory(namespaces map[string]float64) {
+	m.cacheMemoryBytes.Reset()
+	for namespace, bytes := range namespaces {
+		m.cacheMemoryBytes.WithLabelValues(namespace).Set(bytes)
+	}
+}
+
+// CacheHitStat 按缓存类型与键前缀汇总的命中情况
+type CacheHitStat struct {
+	CacheType string  `json:"cache_type"`
+	KeyPrefix string  `json:"key_prefix"`
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	HitRate   float64 `json:"hit_rate"` // 没有请求时为 0
+}
+
+// CacheHitStats 进程启动以来 RecordCacheOperation 记录的命中统计，按缓存类型与键前缀排序
+func (m *MetricsCollector) CacheHitStats() []CacheHitStat {
+	type series struct{ cacheType, keyPrefix string }
+	totals := make(map[series]*CacheHitStat)
+	collect := func(vec *prometheus.CounterVec, add func(stat *CacheHitStat, n uint64)) {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			vec.Collect(ch)
+			close(ch)
+		}()
+		for metric := range ch {
+			var pb dto.Metric
+			if err := metric.Write(&pb); err != nil {
+				continue
+			}
+			var key series
+			for _, label := range pb.GetLabel() {
+				switch label.GetName() {
+				case "cache_type":
+					key.cacheType = label.GetValue()
+				case "key_prefix":
+					key.keyPrefix = label.GetValue()
+				}
+			}
+			stat, ok := totals[key]
+			if !ok {
+				stat = &CacheHitStat{CacheType: key.cacheType, KeyPrefix: key.keyPrefix}
+				totals[key] = stat
+			}
+			add(stat, uint64(pb.GetCounter().GetValue()))
+		}
+	}
+	collect(m.cacheHitsTotal, func(stat *CacheHitStat, n uint64) { stat.Hits += n })
+	collect(m.cacheMissesTotal, func(stat *CacheHitStat, n uint64) { stat.Misses += n })
+
+	stats := make([]CacheHitStat, 0, len(totals))
+	for _, stat := range totals {
+		if total := stat.Hits + stat.Misses; total > 0 {
+			stat.HitRate = float64(stat.Hits) / float64(total)
+		}
+		stats = append(stats, *stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].CacheType != stats[j].CacheType {
+			return stats[i].CacheType < stats[j].CacheType
+		}
+		return stats[i].KeyPrefix < stats[j].KeyPrefix
+	})
+	return stats
+}
+
+// RecordPolicyDecision 记录策略决定指标，source 为 cache（命中决定缓存）或 evaluate（完整求值）
+func (m *MetricsCollector) RecordPolicyDecision(decision, source string, duration time.Duration) {
+	m.policyDecisionsTotal.WithLabelValues(decision, source).Inc()
+	m.policyDecisionDuration.WithLabelValues(source).Observe(duration.Seconds())
+}
+
+// RecordIPReputation 记录 IP 信誉动作：auto_block 为自动封禁，reject_deny、reject_blocked 为拒绝的请求
+func (m *MetricsCollector) RecordIPReputation(action string) {
+	m.ipReputationActionsTotal.WithLabelValues(action).Inc()
+}
+
+// RecordWAFHit 记录 WAF 规则命中，action 为请求最终执行的动作
+func (m *MetricsCollector) RecordWAFHit(rule, action string) {
+	m.wafRuleHitsTotal.WithLabelValues(rule, action).Inc()
+}
+
 // UpdateDBConnections 更新数据库连接指标
 func (m *MetricsCollector) UpdateDBConnections(active, idle int) {
 	m.dbConnectionsActive.Set(float64(active))
 	m.dbConnectionsIdle.Set(float64(idle))
 }
 
+// RecordDBQueryTimeout 记录因超时被取消的查询，class 为 read、write、report 或 maintenance
+func (m *MetricsCollector) RecordDBQueryTimeout(class string) {
+	m.dbQueryTimeoutsTotal.WithLabelValues(class).Inc()
+}
+
+// RecordTxRetry 记录一次事务重试，sqlstate 为触发重试的错误码，乐观锁冲突为 stale_object
+func (m *MetricsCollector) RecordTxRetry(operation, sqlstate string) {
+	m.dbTxRetriesTotal.WithLabelValues(operation, sqlstate).Inc()
+}
+
+// RecordTxRetryExhausted 记录重试次数用尽仍失败的事务
+func (m *MetricsCollector) RecordTxRetryExhausted(operation string) {
+	m.dbTxExhaustedTotal.WithLabelValues(operation).Inc()
+}
+
+// RecordDBPoolStats 记录连接池统计，prev 为上一次采样（首次采样传零值），
+// 累计值（等待次数、关闭的连接数）按两次采样的差值计入计数器
+func (m *MetricsCollector) RecordDBPoolStats(prev, curr sql.DBStats) {
+	m.dbConnectionsActive.Set(float64(curr.InUse))
+	m.dbConnectionsIdle.Set(float64(curr.Idle))
+	m.dbConnectionsOpen.Set(float64(curr.OpenConnections))
+	m.dbConnectionsMaxOpen.Set(float64(curr.MaxOpenConnections))
+
+	// sql.DBStats 只提供累计等待时长，区间内每次等待按平均值计入直方图，直方图的总和与次数准确
+	if waits := curr.WaitCount - prev.WaitCount; waits > 0 {
+		m.dbConnectionWaitsTotal.Add(float64(waits))
+		average := (curr.WaitDuration - prev.WaitDuration).Seconds() / float64(waits)
+		for i := int64(0); i < waits; i++ {
+			m.dbConnectionWait.Observe(average)
+		}
+	}
+
+	closed := map[string]int64{
+		"max_idle":      curr.MaxIdleClosed - prev.MaxIdleClosed,
+		"max_idle_time": curr.MaxIdleTimeClosed - prev.MaxIdleTimeClosed,
+		"max_lifetime":  curr.MaxLifetimeClosed - prev.MaxLifetimeClosed,
+	}
+	for reason, n := range closed {
+		if n > 0 {
+			m.dbConnectionsClosedTotal.WithLabelValues(reason).Add(float64(n))
+		}
+	}
+}
+
+// UpdateDBBloat 更新表或索引的膨胀估算，kind 为 table 或 index
+func (m *MetricsCollector) UpdateDBBloat(relation, kind string, bytes int64, ratio float64) {
+	m.dbBloatBytes.WithLabelValues(relation, kind).Set(float64(bytes))
+	m.dbBloatRatio.WithLabelValues(relation, kind).Set(ratio)
+}
+
+// UpdateDBDeadTuples 更新表的死元组数
+func (m *MetricsCollector) UpdateDBDeadTuples(relation string, n int64) {
+	m.dbDeadTuples.WithLabelValues(relation).Set(float64(n))
+}
+
+// ResetDBBloat 清空膨胀指标，每次分析前调用，已删除的表与索引不再上报
+func (m *MetricsCollector) ResetDBBloat() {
+	m.dbBloatBytes.Reset()
+	m.dbBloatRatio.Reset()
+	m.dbDeadTuples.Reset()
+}
+
+// UpdateDBLockWaits 更新等待锁的会话数与最长等待
+func (m *MetricsCollector) UpdateDBLockWaits(waiting int, longest time.Duration) {
+	m.dbLockWaits.Set(float64(waiting))
+	m.dbLockWaitLongest.Set(longest.Seconds())
+}
+
+// RecordDBDeadlocks 记录数据库新增的死锁次数
+func (m *MetricsCollector) RecordDBDeadlocks(n int64) {
+	if n <= 0 {
+		return
+	}
+	m.dbDeadlocksTotal.Add(float64(n))
+}
+
+// UpdateDBReplicaLag 更新只读副本的复制延迟，ok 为 false 表示采样失败
+func (m *MetricsCollector) UpdateDBReplicaLag(replica string, lag time.Duration, ok bool) {
+	if !ok {
+		m.dbReplicaLag.WithLabelValues(replica).Set(-1)
+		return
+	}
+	m.dbReplicaLag.WithLabelValues(replica).Set(lag.Seconds())
+}
+
+// RecordSecurityEvent 记录安全事件
+func (m *MetricsCollector) RecordSecurityEvent(eventType, level string) {
+	m.securityEventsTotal.WithLabelValues(eventType, level).Inc()
+}
+
+// RecordEvent 记录组件事件，如预热的键数、索引变更、限流拦截；错误仍使用 RecordDBError
+func (m *MetricsCollector) RecordEvent(component, event string, n int) {
+	if n <= 0 {
+		return
+	}
+	m.componentEventsTotal.WithLabelValues(component, event).Add(float64(n))
+}
+
+// UpdateConcurrencyLimit 更新路由组的并发上限与进行中的请求数
+func (m *MetricsCollector) UpdateConcurrencyLimit(group string, limit, inflight int) {
+	m.concurrencyLimit.WithLabelValues(group).Set(float64(limit))
+	m.concurrencyInflight.WithLabelValues(group).Set(float64(inflight))
+}
+
+// RecordConcurrencyRejected 记录超过并发上限被拒绝的请求
+func (m *MetricsCollector) RecordConcurrencyRejected(group string) {
+	m.concurrencyRejectedTotal.WithLabelValues(group).Inc()
+}
+
+// UpdatePriorityQueue 更新优先级的排队数与进行中的请求数
+func (m *MetricsCollector) UpdatePriorityQueue(priority string, depth, inflight int) {
+	m.priorityQueueDepth.WithLabelValues(priority).Set(float64(depth))
+	m.priorityInflight.WithLabelValues(priority).Set(float64(inflight))
+}
+
+// RecordPriorityRejected 记录被优先级准入拒绝的请求，reason 为 queue_full 或 timeout
+func (m *MetricsCollector) RecordPriorityRejected(priority, reason string) {
+	m.priorityRejectedTotal.WithLabelValues(priority, reason).Inc()
+}
+
+// RecordPriorityQueueWait 记录排队请求的等待时长
+func (m *MetricsCollector) RecordPriorityQueueWait(priority string, duration time.Duration) {
+	m.priorityQueueWait.WithLabelValues(priority).Observe(duration.Seconds())
+}
+
+// RecordRetention 记录数据保留策略清理的行数，action 为 delete 或 archive
+func (m *MetricsCollector) RecordRetention(dataset, action string, rows int64) {
+	if rows <= 0 {
+		return
+	}
+	m.retentionRowsTotal.WithLabelValues(dataset, action).Add(float64(rows))
+}
+
+// UpdateRetentionPending 更新数据集超出保留策略、尚未清理的行数
+func (m *MetricsCollector) UpdateRetentionPending(dataset string, rows int64) {
+	m.retentionPendingRows.WithLabelValues(dataset).Set(float64(rows))
+}
+
+// RecordRedisRedirect 记录 Redis 集群节点返回的重定向，kind 为 moved 或 ask
+func (m *MetricsCollector) RecordRedisRedirect(kind string) {
+	m.redisRedirectsTotal.WithLabelValues(kind).Inc()
+}
+
+// RecordRedisRedirectStorm 记录触发提前刷新拓扑的重定向风暴
+func (m *MetricsCollector) RecordRedisRedirectStorm() {
+	m.redisRedirectStormsTotal.Inc()
+}
+
+// RecordRedisTopologyRefresh 记录 Redis 集群拓扑刷新
+func (m *MetricsCollector) RecordRedisTopologyRefresh(success bool) {
+	status := "success"
+	if !success {
+		status = "error"
+	}
+	m.redisTopologyRefreshTotal.WithLabelValues(status).Inc()
+}
+
+// RecordRedisTopologyChange 记录 Redis 集群拓扑变化及迁移的槽位数
+func (m *MetricsCollector) RecordRedisTopologyChange(movedSlots int) {
+	m.redisTopologyChangesTotal.Inc()
+	m.redisSlotsMovedTotal.Add(float64(movedSlots))
+}
+
+// RecordRedisNodeCommand 记录 Redis 集群节点上的命令延迟
+func (m *MetricsCollector) RecordRedisNodeCommand(node string, duration time.Duration, success bool) {
+	m.redisNodeCommandDuration.WithLabelValues(node).Observe(duration.Seconds())
+	if !success {
+		m.redisNodeCommandErrors.WithLabelValues(node).Inc()
+	}
+}
+
+// UpdateRedisNodeConns 更新 Redis 集群节点连接池的总连接数与空闲连接数
+func (m *MetricsCollector) UpdateRedisNodeConns(node string, total, idle int) {
+	m.redisNodeConnections.WithLabelValues(node, "total").Set(float64(total))
+	m.redisNodeConnections.WithLabelValues(node, "idle").Set(float64(idle))
+}
+
+// RecordRedisNodePool 记录 Redis 集群节点连接池事件，event 为 hit、miss、timeout 或 stale
+func (m *MetricsCollector) RecordRedisNodePool(node, event string, n int) {
+	if n <= 0 {
+		return
+	}
+	m.redisNodePoolEventsTotal.WithLabelValues(node, event).Add(float64(n))
+}
+
 // UpdateActiveGoroutines 更新活跃 goroutine 数量
 func (m *MetricsCollector) UpdateActiveGoroutines(count int) {
 	m.activeGoroutines.Set(float64(count))
@@ -336,13 +1193,13 @@ func (m *MetricsMiddleware) TrackRequest(method, endpoint string) func(status in
 
 	return func(status int, requestSize, responseSize int) {
 		duration := time.Since(start)
-		statusStr := getStatusCategory(status)
+		statusStr := StatusClass(status)
 		m.collector.RecordHTTPRequest(method, endpoint, statusStr, duration, requestSize, responseSize)
 	}
 }
 
-// getStatusCategory 获取状态分类
-func getStatusCategory(status int) string {
+// StatusClass 获取状态分类
+func StatusClass(status int) string {
 	switch {
 	case status >= 200 && status < 300:
 		return "2xx"
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordDBPoolStats(t *testing.T) {
+	m := GetGlobalCollector()
+	waitsBefore := testutil.ToFloat64(m.dbConnectionWaitsTotal)
+	idleClosedBefore := testutil.ToFloat64(m.dbConnectionsClosedTotal.WithLabelValues("max_idle"))
+
+	prev := sql.DBStats{WaitCount: 10, WaitDuration: time.Second, MaxIdleClosed: 3}
+	curr := sql.DBStats{
+		MaxOpenConnections: 100,
+		OpenConnections:    12,
+		InUse:              8,
+		Idle:               4,
+		WaitCount:          14,
+		WaitDuration:       time.Second + 200*time.Millisecond,
+		MaxIdleClosed:      5,
+	}
+	m.RecordDBPoolStats(prev, curr)
+
+	assert.Equal(t, 8.0, testutil.ToFloat64(m.dbConnectionsActive))
+	assert.Equal(t, 4.0, testutil.ToFloat64(m.dbConnectionsIdle))
+	assert.Equal(t, 12.0, testutil.ToFloat64(m.dbConnectionsOpen))
+	assert.Equal(t, 100.0, testutil.ToFloat64(m.dbConnectionsMaxOpen))
+	assert.Equal(t, 4.0, testutil.ToFloat64(m.dbConnectionWaitsTotal)-waitsBefore, "counters take the difference between samples")
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.dbConnectionsClosedTotal.WithLabelValues("max_idle"))-idleClosedBefore)
+
+	// 每次等待按区间平均值 50ms 计入直方图
+	expected := `
+		# HELP db_connection_wait_duration_seconds Time spent waiting for a database connection, averaged per sampling interval
+		# TYPE db_connection_wait_duration_seconds histogram
+		db_connection_wait_duration_seconds_bucket{le="0.001"} 0
+		db_connection_wait_duration_seconds_bucket{le="0.005"} 0
+		db_connection_wait_duration_seconds_bucket{le="0.01"} 0
+		db_connection_wait_duration_seconds_bucket{le="0.05"} 4
+		db_connection_wait_duration_seconds_bucket{le="0.1"} 4
+		db_connection_wait_duration_seconds_bucket{le="0.5"} 4
+		db_connection_wait_duration_seconds_bucket{le="1"} 4
+		db_connection_wait_duration_seconds_bucket{le="5"} 4
+		db_connection_wait_duration_seconds_bucket{le="+Inf"} 4
+		db_connection_wait_duration_seconds_sum 0.2
+		db_connection_wait_duration_seconds_count 4
+	`
+	assert.NoError(t, testutil.CollectAndCompare(m.dbConnectionWait, strings.NewReader(expected)))
+}
+
+func TestRecordEvent(t *testing.T) {
+	m := GetGlobalCollector()
+	m.RecordEvent("cache_warmup", "success_keys", 25)
+	m.RecordEvent("cache_warmup", "success_keys", 0)
+
+	assert.Equal(t, 25.0, testutil.ToFloat64(m.componentEventsTotal.WithLabelValues("cache_warmup", "success_keys")))
+}
+
+func TestCacheHitStats(t *testing.T) {
+	m := GetGlobalCollector()
+	for i := 0; i < 3; i++ {
+		m.RecordCacheOperation("get", "hit_stats_test", "user:", time.Millisecond, true)
+	}
+	m.RecordCacheOperation("get", "hit_stats_test", "user:", time.Millisecond, false)
+	m.RecordCacheOperation("get", "hit_stats_test", "coupon:", time.Millisecond, false)
+
+	var stats []CacheHitStat
+	for _, stat := range m.CacheHitStats() {
+		if stat.CacheType == "hit_stats_test" {
+			stats = append(stats, stat)
+		}
+	}
+	assert.Equal(t, []CacheHitStat{
+		{CacheType: "hit_stats_test", KeyPrefix: "coupon:", Hits: 0, Misses: 1, HitRate: 0},
+		{CacheType: "hit_stats_test", KeyPrefix: "user:", Hits: 3, Misses: 1, HitRate: 0.75},
+	}, stats)
+}
@@ -0,0 +1,481 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// Status 投递状态
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusSent       Status = "sent"
+	StatusDead       Status = "dead"
+	StatusSuppressed Status = "suppressed" // 超出频率限制，未发送
+)
+
+// Delivery 单渠道投递记录
+type Delivery struct {
+	ID            int64      `db:"id" json:"id"`
+	UserID        string     `db:"user_id" json:"user_id"`
+	Category      string     `db:"category" json:"category"`
+	Template      string     `db:"template" json:"template"`
+	Channel       Channel    `db:"channel" json:"channel"`
+	Locale        string     `db:"locale" json:"locale"`
+	Recipient     string     `db:"recipient" json:"-"`
+	Subject       string     `db:"subject" json:"subject"`
+	Body          string     `db:"body" json:"body"`
+	HTML          bool       `db:"html" json:"-"`
+	Status        Status     `db:"status" json:"status"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	LastError     string     `db:"last_error" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"-"`
+	SentAt        *time.Time `db:"sent_at" json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+// RateLimit 单用户单渠道的频率限制
+type RateLimit struct {
+	Count  int           `json:"count"`
+	Window time.Duration `json:"window"`
+}
+
+// Config 通知分发配置
+type Config struct {
+	DefaultChannels []Channel             `json:"default_channels"`
+	RateLimits      map[Channel]RateLimit `json:"rate_limits"` // 未配置的渠道不限流
+	PollInterval    time.Duration         `json:"poll_interval"`
+	BatchSize       int                   `json:"batch_size"`
+	MaxAttempts     int                   `json:"max_attempts"` // 超过后进入死信
+	BaseBackoff     time.Duration         `json:"base_backoff"`
+	MaxBackoff      time.Duration         `json:"max_backoff"`
+	SendTimeout     time.Duration         `json:"send_timeout"`
+	SentRetention   time.Duration         `json:"sent_retention"` // 已发送记录保留时间
+}
+
+// DefaultConfig 默认通知分发配置
+func DefaultConfig() *Config {
+	return &Config{
+		DefaultChannels: []Channel{ChannelPush, ChannelEmail},
+		RateLimits: map[Channel]RateLimit{
+			ChannelEmail: {Count: 20, Window: time.Hour},
+			ChannelSMS:   {Count: 5, Window: time.Hour},
+			ChannelPush:  {Count: 60, Window: time.Hour},
+		},
+		PollInterval:  time.Second,
+		BatchSize:     50,
+		MaxAttempts:   8,
+		BaseBackoff:   5 * time.Second,
+		MaxBackoff:    30 * time.Minute,
+		SendTimeout:   10 * time.Second,
+		SentRetention: 30 * 24 * time.Hour,
+	}
+}
+
+const deliveryColumns = `id, user_id, category, template, channel, locale, recipient, subject, body, html,
+	status, attempts, COALESCE(last_error, '') AS last_error, next_attempt_at, sent_at, created_at`
+
+// Dispatcher 通知分发器：按偏好拆分渠道、渲染模板、限流后写入投递表，由 Run 异步发送
+type Dispatcher struct {
+	db               *database.DB
+	rdb              *redis.Client
+	templates        *Templates
+	prefs            PreferenceStore
+	contacts         ContactResolver
+	drivers          map[Channel]Driver
+	config           *Config
+	metricsCollector *metrics.MetricsCollector
+	mu               sync.RWMutex
+}
+
+// NewDispatcher 创建通知分发器，rdb 为 nil 时不限流
+func NewDispatcher(db *database.DB, rdb *redis.Client, templates *Templates, prefs PreferenceStore, contacts ContactResolver, config *Config) *Dispatcher {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Dispatcher{
+		db:               db,
+		rdb:              rdb,
+		templates:        templates,
+		prefs:            prefs,
+		contacts:         contacts,
+		drivers:          make(map[Channel]Driver),
+		config:           config,
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// RegisterDriver 注册渠道驱动
+func (d *Dispatcher) RegisterDriver(driver Driver) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.drivers[driver.Channel()] = driver
+}
+
+// Preferences 偏好存储
+func (d *Dispatcher) Preferences() PreferenceStore {
+	return d.prefs
+}
+
+func (d *Dispatcher) driver(channel Channel) (Driver, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	driver, ok := d.drivers[channel]
+	return driver, ok
+}
+
+// Notify 按用户偏好生成各渠道的投递记录，返回写入的投递（重复的去重键不会返回）。
+// 渠道关闭、无驱动或缺少联系方式的渠道被跳过；超出频率限制的投递记录为 suppressed。
+func (d *Dispatcher) Notify(ctx context.Context, n *Notification) ([]*Delivery, error) {
+	prefs, err := d.prefs.Get(ctx, n.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !n.Critical && prefs.Muted(n.Category) {
+		return nil, nil
+	}
+
+	contact, err := d.contacts.Resolve(ctx, n.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := n.Channels
+	if len(channels) == 0 {
+		channels = d.config.DefaultChannels
+	}
+
+	var deliveries []*Delivery
+	for _, channel := range channels {
+		if !n.Critical && !prefs.ChannelEnabled(channel) {
+			continue
+		}
+		if _, ok := d.driver(channel); !ok {
+			continue
+		}
+		recipient := recipientFor(channel, n.UserID, contact, prefs)
+		if recipient == "" {
+			continue
+		}
+
+		subject, body, html, locale, err := d.templates.Render(n.Template, channel, prefs.Locale, n.Data)
+		if err != nil {
+			return deliveries, err
+		}
+
+		delivery := &Delivery{
+			UserID:    n.UserID,
+			Category:  n.Category,
+			Template:  n.Template,
+			Channel:   channel,
+			Locale:    locale,
+			Recipient: recipient,
+			Subject:   subject,
+			Body:      body,
+			HTML:      html,
+			Status:    StatusPending,
+		}
+		if allowed, err := d.allow(ctx, n.UserID, channel); err != nil {
+			log.Printf("Notify rate limit check failed, allowing: %v", err)
+		} else if !allowed {
+			delivery.Status = StatusSuppressed
+			delivery.LastError = "rate limited"
+		}
+
+		var dedupeKey string
+		if n.DedupeKey != "" {
+			dedupeKey = n.DedupeKey + ":" + string(channel)
+		}
+		inserted, err := d.insert(ctx, delivery, dedupeKey)
+		if err != nil {
+			return deliveries, err
+		}
+		if inserted {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func recipientFor(channel Channel, userID string, contact *Contact, prefs *Preferences) string {
+	switch channel {
+	case ChannelEmail:
+		return contact.Email
+	case ChannelSMS:
+		return contact.Mobile
+	case ChannelPush:
+		return userID
+	case ChannelWebhook:
+		return prefs.WebhookURL
+	default:
+		return ""
+	}
+}
+
+// allow 固定窗口计数限流
+func (d *Dispatcher) allow(ctx context.Context, userID string, channel Channel) (bool, error) {
+	limit, ok := d.config.RateLimits[channel]
+	if !ok || d.rdb == nil || limit.Count <= 0 || limit.Window <= 0 {
+		return true, nil
+	}
+
+	window := time.Now().UnixNano() / int64(limit.Window)
+	key := fmt.Sprintf("notify:rate:%s:%s:%d", channel, userID, window)
+
+	pipe := d.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, limit.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return incr.Val() <= int64(limit.Count), nil
+}
+
+func (d *Dispatcher) insert(ctx context.Context, delivery *Delivery, dedupeKey string) (bool, error) {
+	start := time.Now()
+	query := `
+		INSERT INTO notification_deliveries
+			(user_id, category, template, channel, locale, recipient, subject, body, html, dedupe_key, status, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''))
+		ON CONFLICT (dedupe_key) DO NOTHING
+		RETURNING id, next_attempt_at, created_at`
+	err := d.db.QueryRowContext(ctx, query,
+		delivery.UserID, delivery.Category, delivery.Template, delivery.Channel, delivery.Locale,
+		delivery.Recipient, delivery.Subject, delivery.Body, delivery.HTML, dedupeKey,
+		delivery.Status, delivery.LastError,
+	).Scan(&delivery.ID, &delivery.NextAttemptAt, &delivery.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	d.recordMetrics("enqueue", time.Since(start), err == nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert notification delivery: %w", err)
+	}
+	return true, nil
+}
+
+// Run 循环发送直到 ctx 取消
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := d.DeliverBatch(ctx)
+				if err != nil {
+					log.Printf("Notify dispatch failed: %v", err)
+					break
+				}
+				if n < d.config.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+
+			if time.Since(lastCleanup) > time.Hour {
+				if _, err := d.Cleanup(ctx); err != nil {
+					log.Printf("Notify cleanup failed: %v", err)
+				}
+				lastCleanup = time.Now()
+			}
+		}
+	}
+}
+
+// DeliverBatch 锁定一批待发送投递并发送，返回处理的数量。
+// 使用 FOR UPDATE SKIP LOCKED，多个实例可并行运行。
+func (d *Dispatcher) DeliverBatch(ctx context.Context) (int, error) {
+	start := time.Now()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin notify transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deliveries []Delivery
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM notification_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= NOW()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+	if err := tx.SelectContext(ctx, &deliveries, query, d.config.BatchSize); err != nil {
+		return 0, fmt.Errorf("failed to load notification deliveries: %w", err)
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		err := d.send(ctx, delivery)
+		if err == nil {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE notification_deliveries
+				SET status = 'sent', sent_at = NOW(), attempts = attempts + 1, updated_at = NOW()
+				WHERE id = $1`, delivery.ID); err != nil {
+				return 0, fmt.Errorf("failed to mark notification %d sent: %w", delivery.ID, err)
+			}
+			continue
+		}
+		if err := d.markFailed(ctx, tx, delivery, err); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit notify batch: %w", err)
+	}
+
+	d.recordMetrics("deliver_batch", time.Since(start), true)
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery *Delivery) error {
+	driver, ok := d.driver(delivery.Channel)
+	if !ok {
+		return fmt.Errorf("no driver registered for channel %s", delivery.Channel)
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, d.config.SendTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := driver.Send(sendCtx, &Message{
+		DeliveryID: delivery.ID,
+		Channel:    delivery.Channel,
+		UserID:     delivery.UserID,
+		Category:   delivery.Category,
+		To:         delivery.Recipient,
+		Subject:    delivery.Subject,
+		Body:       delivery.Body,
+		HTML:       delivery.HTML,
+	})
+	d.recordMetrics("send_"+string(delivery.Channel), time.Since(start), err == nil)
+	return err
+}
+
+// markFailed 记录失败并计算下次重试时间，不可重试的错误直接进入死信
+func (d *Dispatcher) markFailed(ctx context.Context, tx *sqlx.Tx, delivery *Delivery, sendErr error) error {
+	attempts := delivery.Attempts + 1
+	status := StatusPending
+	if attempts >= d.config.MaxAttempts || IsPermanent(sendErr) {
+		status = StatusDead
+		log.Printf("Notification %d (%s) moved to dead after %d attempts: %v", delivery.ID, delivery.Channel, attempts, sendErr)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE notification_deliveries
+		SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4, updated_at = NOW()
+		WHERE id = $5`,
+		status, attempts, sendErr.Error(), time.Now().Add(d.backoff(attempts)), delivery.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification %d failed: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// backoff 指数退避
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Get 查询投递记录
+func (d *Dispatcher) Get(ctx context.Context, id int64) (*Delivery, error) {
+	var delivery Delivery
+	err := d.db.GetContext(ctx, &delivery,
+		`SELECT `+deliveryColumns+` FROM notification_deliveries WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// ListByUser 用户的投递记录，beforeID 为 0 时从最新开始
+func (d *Dispatcher) ListByUser(ctx context.Context, userID string, beforeID int64, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM notification_deliveries
+		WHERE user_id = $1 AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3`
+	if err := d.db.SelectContext(ctx, &deliveries, query, userID, beforeID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list notification deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// ListDead 死信列表
+func (d *Dispatcher) ListDead(ctx context.Context, limit int) ([]Delivery, error) {
+	var deliveries []Delivery
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM notification_deliveries
+		WHERE status = 'dead'
+		ORDER BY updated_at DESC
+		LIMIT $1`
+	if err := d.db.SelectContext(ctx, &deliveries, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list dead notifications: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Retry 将死信重新置为待发送
+func (d *Dispatcher) Retry(ctx context.Context, id int64) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE notification_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'`, id)
+	if err != nil {
+		return fmt.Errorf("failed to retry notification %d: %w", id, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// Cleanup 删除超过保留期的已发送与已抑制记录
+func (d *Dispatcher) Cleanup(ctx context.Context) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM notification_deliveries
+		WHERE status IN ('sent', 'suppressed') AND created_at < $1`,
+		time.Now().Add(-d.config.SentRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup notification deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// recordMetrics 记录指标
+func (d *Dispatcher) recordMetrics(operation string, duration time.Duration, success bool) {
+	d.metricsCollector.RecordDBQuery("notify", operation, duration, success)
+	if !success {
+		d.metricsCollector.RecordDBError("notify_error", operation)
+	}
+}
@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver 按顺序返回预设的错误，记录收到的消息
+type fakeDriver struct {
+	channel Channel
+	mu      sync.Mutex
+	errs    []error
+	sent    []*Message
+}
+
+func (d *fakeDriver) Channel() Channel {
+	return d.channel
+}
+
+func (d *fakeDriver) Send(ctx context.Context, msg *Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent = append(d.sent, msg)
+	if len(d.errs) == 0 {
+		return nil
+	}
+	err := d.errs[0]
+	d.errs = d.errs[1:]
+	return err
+}
+
+// retryAt 匹配约为 NOW() + delay 的下次重试时间
+type retryAt time.Duration
+
+func (r retryAt) Match(v driver.Value) bool {
+	at, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	want := time.Now().Add(time.Duration(r))
+	return at.After(want.Add(-time.Second)) && at.Before(want.Add(time.Second))
+}
+
+func deliveryRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "user_id", "category", "template", "channel", "locale", "recipient",
+		"subject", "body", "html", "status", "attempts", "last_error", "next_attempt_at", "sent_at", "created_at"})
+}
+
+func addDelivery(rows *sqlmock.Rows, id int64, channel Channel, attempts int) *sqlmock.Rows {
+	now := time.Now()
+	return rows.AddRow(id, "u1", "coupon_claimed", "claimed", channel, "zh-CN", "to-"+string(channel),
+		"subject", "body", false, StatusPending, attempts, "", now, nil, now)
+}
+
+func newTestDispatcher(t *testing.T, drivers ...Driver) (*Dispatcher, sqlmock.Sqlmock) {
+	db, mock := fakes.NewDB(t)
+	config := DefaultConfig()
+	config.MaxAttempts = 3
+	config.BaseBackoff = time.Minute
+	config.MaxBackoff = 10 * time.Minute
+	d := NewDispatcher(db, nil, NewTemplates("zh-CN"), nil, nil, config)
+	for _, driver := range drivers {
+		d.RegisterDriver(driver)
+	}
+	return d, mock
+}
+
+const (
+	selectPending = `SELECT .* FROM notification_deliveries\s+WHERE status = 'pending' AND next_attempt_at <= NOW\(\)\s+ORDER BY id\s+LIMIT \$1\s+FOR UPDATE SKIP LOCKED`
+	markSent      = `UPDATE notification_deliveries\s+SET status = 'sent', sent_at = NOW\(\), attempts = attempts \+ 1`
+	markFailed    = `UPDATE notification_deliveries\s+SET status = \$1, attempts = \$2, last_error = \$3, next_attempt_at = \$4`
+)
+
+// TestDispatcher_Backoff 从 BaseBackoff 开始每次翻倍，不超过 MaxBackoff
+func TestDispatcher_Backoff(t *testing.T) {
+	d, _ := newTestDispatcher(t)
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{30, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, d.backoff(tt.attempts), "attempts=%d", tt.attempts)
+	}
+}
+
+// TestDeliverBatch_RetryAndDeadLetter 发送成功标记为 sent；临时失败按退避重试；
+// 尝试次数用尽或驱动返回不可重试错误时进入死信；没有驱动的渠道按临时失败处理
+func TestDeliverBatch_RetryAndDeadLetter(t *testing.T) {
+	email := &fakeDriver{channel: ChannelEmail}
+	sms := &fakeDriver{channel: ChannelSMS, errs: []error{errors.New("gateway timeout")}}
+	push := &fakeDriver{channel: ChannelPush, errs: []error{errors.New("service unavailable")}}
+	webhook := &fakeDriver{channel: ChannelWebhook, errs: []error{Permanent(errors.New("410 gone"))}}
+	d, mock := newTestDispatcher(t, email, sms, push)
+
+	rows := deliveryRows()
+	addDelivery(rows, 1, ChannelEmail, 0)
+	addDelivery(rows, 2, ChannelSMS, 1)
+	addDelivery(rows, 3, ChannelPush, 2)
+	addDelivery(rows, 4, ChannelWebhook, 0)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectPending).WithArgs(50).WillReturnRows(rows)
+	mock.ExpectExec(markSent).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(markFailed).WithArgs(StatusPending, 2, "gateway timeout", retryAt(2*time.Minute), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(markFailed).WithArgs(StatusDead, 3, "service unavailable", sqlmock.AnyArg(), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(markFailed).WithArgs(StatusPending, 1, "no driver registered for channel webhook", retryAt(time.Minute), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := d.DeliverBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	require.Len(t, email.sent, 1)
+	assert.Equal(t, &Message{DeliveryID: 1, Channel: ChannelEmail, UserID: "u1", Category: "coupon_claimed",
+		To: "to-email", Subject: "subject", Body: "body"}, email.sent[0])
+	assert.Len(t, sms.sent, 1)
+	assert.Len(t, push.sent, 1)
+	assert.Empty(t, webhook.sent)
+
+	// 不可重试的错误在第一次尝试后即进入死信
+	d.RegisterDriver(webhook)
+	rows = addDelivery(deliveryRows(), 4, ChannelWebhook, 1)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectPending).WithArgs(50).WillReturnRows(rows)
+	mock.ExpectExec(markFailed).WithArgs(StatusDead, 2, "permanent: 410 gone", sqlmock.AnyArg(), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err = d.DeliverBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Len(t, webhook.sent, 1)
+}
+
+// TestDeliverBatch_UpdateFailure 状态写入失败时整批回滚，投递保持待发送
+func TestDeliverBatch_UpdateFailure(t *testing.T) {
+	d, mock := newTestDispatcher(t, &fakeDriver{channel: ChannelEmail, errs: []error{errors.New("smtp down")}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectPending).WillReturnRows(addDelivery(deliveryRows(), 1, ChannelEmail, 0))
+	mock.ExpectExec(markFailed).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err := d.DeliverBatch(context.Background())
+	assert.ErrorContains(t, err, "failed to mark notification 1 failed")
+}
+
+// TestRetry 只有死信可以重新投递，重置尝试次数
+func TestRetry(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	retry := `UPDATE notification_deliveries\s+SET status = 'pending', attempts = 0, next_attempt_at = NOW\(\).*WHERE id = \$1 AND status = 'dead'`
+	mock.ExpectExec(retry).WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(retry).WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.NoError(t, d.Retry(context.Background(), 3))
+	assert.ErrorIs(t, d.Retry(context.Background(), 1), ErrDeliveryNotFound)
+}
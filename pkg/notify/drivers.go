@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"strconv"
+)
+
+// SMSSender 短信发送接口，由具体服务商实现
+type SMSSender interface {
+	SendSMS(ctx context.Context, mobile, content string) error
+}
+
+// SMSSenderFunc 函数形式的 SMSSender
+type SMSSenderFunc func(ctx context.Context, mobile, content string) error
+
+// SendSMS 实现 SMSSender
+func (f SMSSenderFunc) SendSMS(ctx context.Context, mobile, content string) error {
+	return f(ctx, mobile, content)
+}
+
+// LogSMSSender 开发环境使用，仅打印日志
+var LogSMSSender = SMSSenderFunc(func(ctx context.Context, mobile, content string) error {
+	log.Printf("[SMS] Sending to %s: %s", mobile, content)
+	return nil
+})
+
+// SMSDriver 短信驱动，正文为模板渲染结果
+type SMSDriver struct {
+	sender SMSSender
+}
+
+// NewSMSDriver 创建短信驱动
+func NewSMSDriver(sender SMSSender) *SMSDriver {
+	return &SMSDriver{sender: sender}
+}
+
+// Channel 渠道
+func (d *SMSDriver) Channel() Channel {
+	return ChannelSMS
+}
+
+// Send 发送短信
+func (d *SMSDriver) Send(ctx context.Context, msg *Message) error {
+	return d.sender.SendSMS(ctx, msg.To, msg.Body)
+}
+
+// PushSender 按账号推送的接口，与 internal/pkg/push.PushService 的 PushToAccount 签名一致
+type PushSender interface {
+	PushToAccount(accountID string, title, body string, extParameters map[string]string) error
+}
+
+// PushDriver 推送驱动，以用户 ID 作为推送账号
+type PushDriver struct {
+	sender PushSender
+}
+
+// NewPushDriver 创建推送驱动
+func NewPushDriver(sender PushSender) *PushDriver {
+	return &PushDriver{sender: sender}
+}
+
+// Channel 渠道
+func (d *PushDriver) Channel() Channel {
+	return ChannelPush
+}
+
+// Send 推送通知，扩展参数带上投递 ID 与类别便于客户端跳转
+func (d *PushDriver) Send(ctx context.Context, msg *Message) error {
+	return d.sender.PushToAccount(msg.To, msg.Subject, msg.Body, map[string]string{
+		"notification_id": strconv.FormatInt(msg.DeliveryID, 10),
+		"category":        msg.Category,
+	})
+}
+
+var (
+	_ Driver = (*SMSDriver)(nil)
+	_ Driver = (*PushDriver)(nil)
+)
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig 邮件服务器配置
+type SMTPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"` // 如 "Notice <noreply@example.com>"
+}
+
+// DefaultSMTPConfig 默认邮件配置
+func DefaultSMTPConfig() *SMTPConfig {
+	return &SMTPConfig{
+		Host: "localhost",
+		Port: 587,
+	}
+}
+
+// EmailDriver SMTP 邮件驱动，服务器支持时自动使用 STARTTLS
+type EmailDriver struct {
+	config *SMTPConfig
+	auth   smtp.Auth
+	from   *mail.Address
+}
+
+// NewEmailDriver 创建邮件驱动
+func NewEmailDriver(config *SMTPConfig) (*EmailDriver, error) {
+	if config == nil {
+		config = DefaultSMTPConfig()
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse smtp from address: %w", err)
+	}
+
+	driver := &EmailDriver{config: config, from: from}
+	if config.Username != "" {
+		driver.auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+	return driver, nil
+}
+
+// Channel 渠道
+func (d *EmailDriver) Channel() Channel {
+	return ChannelEmail
+}
+
+// Send 发送邮件，5xx 响应视为不可重试
+func (d *EmailDriver) Send(ctx context.Context, msg *Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return Permanent(fmt.Errorf("invalid email address: %w", err))
+	}
+
+	addr := net.JoinHostPort(d.config.Host, strconv.Itoa(d.config.Port))
+	data := buildMessage(d.from, to, msg)
+
+	// net/smtp 不支持 context，超时通过独立 goroutine 返回
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, d.auth, d.from.Address, []string{to.Address}, data)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return Permanent(err)
+	}
+	return err
+}
+
+// buildMessage 构造 MIME 邮件，正文使用 base64 编码
+func buildMessage(from, to *mail.Address, msg *Message) []byte {
+	contentType := "text/plain; charset=UTF-8"
+	if msg.HTML {
+		contentType = "text/html; charset=UTF-8"
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <notify-%d-%d@%s>\r\n", msg.DeliveryID, time.Now().UnixNano(), domainOf(from.Address))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n", contentType)
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+func domainOf(address string) string {
+	for i := len(address) - 1; i >= 0; i-- {
+		if address[i] == '@' {
+			return address[i+1:]
+		}
+	}
+	return "localhost"
+}
+
+var _ Driver = (*EmailDriver)(nil)
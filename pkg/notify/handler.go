@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// preferencesRequest 更新偏好请求
+type preferencesRequest struct {
+	Locale          string           `json:"locale" binding:"max=16"`
+	Channels        map[Channel]bool `json:"channels"`
+	MutedCategories []string         `json:"muted_categories" binding:"max=50"`
+	WebhookURL      string           `json:"webhook_url" binding:"max=500"`
+}
+
+// Handler 通知接口
+type Handler struct {
+	dispatcher *Dispatcher
+}
+
+// NewHandler 创建通知接口
+func NewHandler(dispatcher *Dispatcher) *Handler {
+	return &Handler{dispatcher: dispatcher}
+}
+
+// RegisterRoutes 注册用户侧路由，调用方需挂载 AuthMiddleware
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/notifications", h.ListDeliveries)
+	group.GET("/notifications/preferences", h.GetPreferences)
+	group.PUT("/notifications/preferences", h.UpdatePreferences)
+}
+
+// RegisterAdminRoutes 注册死信管理路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/notifications/dead", h.ListDead)
+	group.POST("/notifications/:id/retry", h.Retry)
+}
+
+// ListDeliveries 当前用户的通知记录，before 为上一页最后一条的 ID
+func (h *Handler) ListDeliveries(c *gin.Context) {
+	before, _ := strconv.ParseInt(c.Query("before"), 10, 64)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	deliveries, err := h.dispatcher.ListByUser(c.Request.Context(), c.GetString("userID"), before, limit)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": deliveries,
+	})
+}
+
+// GetPreferences 当前用户的通知偏好
+func (h *Handler) GetPreferences(c *gin.Context) {
+	prefs, err := h.dispatcher.Preferences().Get(c.Request.Context(), c.GetString("userID"))
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences 更新当前用户的通知偏好
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	var req preferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	for channel := range req.Channels {
+		if !knownChannel(channel) {
+			apperrors.Render(c, apperrors.Newf(apperrors.CodeInvalidParam, "unknown channel: %s", channel))
+			return
+		}
+	}
+	if req.WebhookURL != "" {
+		if err := ValidateWebhookURL(req.WebhookURL, false); err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, err.Error()))
+			return
+		}
+	}
+
+	prefs := &Preferences{
+		UserID:          c.GetString("userID"),
+		Locale:          normalizeLocale(req.Locale),
+		Channels:        req.Channels,
+		MutedCategories: req.MutedCategories,
+		WebhookURL:      req.WebhookURL,
+	}
+	if prefs.Channels == nil {
+		prefs.Channels = map[Channel]bool{}
+	}
+	if err := h.dispatcher.Preferences().Save(c.Request.Context(), prefs); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// ListDead 死信列表
+func (h *Handler) ListDead(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	deliveries, err := h.dispatcher.ListDead(c.Request.Context(), limit)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": deliveries,
+	})
+}
+
+// Retry 重新投递死信
+func (h *Handler) Retry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "invalid notification id"))
+		return
+	}
+
+	if err := h.dispatcher.Retry(c.Request.Context(), id); err != nil {
+		if errors.Is(err, ErrDeliveryNotFound) {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+			return
+		}
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"retried": id,
+	})
+}
+
+func knownChannel(channel Channel) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
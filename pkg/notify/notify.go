@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Channel 通知渠道
+type Channel string
+
+const (
+	ChannelEmail   Channel = "email"
+	ChannelSMS     Channel = "sms"
+	ChannelPush    Channel = "push"
+	ChannelWebhook Channel = "webhook"
+)
+
+// Channels 所有内置渠道
+var Channels = []Channel{ChannelEmail, ChannelSMS, ChannelPush, ChannelWebhook}
+
+var (
+	ErrTemplateNotFound = errors.New("notify: template not found")
+	ErrDeliveryNotFound = errors.New("notify: delivery not found")
+	ErrInvalidWebhook   = errors.New("notify: invalid webhook url")
+)
+
+// PermanentError 不可重试的发送错误（地址无效、被拒收等），投递直接进入死信
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return fmt.Sprintf("permanent: %v", e.Err)
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 将错误标记为不可重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent 是否为不可重试错误
+func IsPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+// Notification 待发送的通知，按用户偏好拆分为各渠道的投递
+type Notification struct {
+	UserID   string
+	Category string // 业务类别，如 coupon_claimed、security_alert，用户可按类别退订
+	Template string
+	Data     map[string]interface{}
+	Channels []Channel // 候选渠道，为空时使用默认渠道
+	// Critical 安全类通知忽略用户的退订与渠道关闭设置
+	Critical bool
+	// DedupeKey 相同去重键的通知在每个渠道只投递一次，为空时不去重
+	DedupeKey string
+}
+
+// Message 渲染后的单渠道消息
+type Message struct {
+	DeliveryID int64
+	Channel    Channel
+	UserID     string
+	Category   string
+	To         string // 邮箱、手机号、推送账号或 Webhook 地址
+	Subject    string
+	Body       string
+	HTML       bool
+}
+
+// Driver 渠道驱动
+type Driver interface {
+	Channel() Channel
+	Send(ctx context.Context, msg *Message) error
+}
@@ -0,0 +1,22 @@
+package notify
+
+import "user_crud_jwt/pkg/security"
+
+// 通知管理权限
+const (
+	PermissionNotificationRead  security.Permission = "notification:read"
+	PermissionNotificationWrite security.Permission = "notification:write"
+)
+
+func init() {
+	security.RegisterPermission(security.PermissionDefinition{
+		Name:        PermissionNotificationRead,
+		Module:      "notification",
+		Description: "查看投递记录与死信",
+	})
+	security.RegisterPermission(security.PermissionDefinition{
+		Name:        PermissionNotificationWrite,
+		Module:      "notification",
+		Description: "重试死信投递",
+	})
+}
@@ -0,0 +1,179 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"user_crud_jwt/pkg/database"
+)
+
+// Preferences 用户通知偏好
+type Preferences struct {
+	UserID          string           `json:"user_id"`
+	Locale          string           `json:"locale"`
+	Channels        map[Channel]bool `json:"channels"` // 渠道开关，未设置的渠道视为开启
+	MutedCategories []string         `json:"muted_categories"`
+	WebhookURL      string           `json:"webhook_url,omitempty"`
+}
+
+// ChannelEnabled 渠道是否开启
+func (p *Preferences) ChannelEnabled(channel Channel) bool {
+	enabled, ok := p.Channels[channel]
+	return !ok || enabled
+}
+
+// Muted 是否退订了该类别
+func (p *Preferences) Muted(category string) bool {
+	for _, c := range p.MutedCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Contact 用户联系方式
+type Contact struct {
+	Email  string
+	Mobile string
+}
+
+// PreferenceStore 通知偏好存储
+type PreferenceStore interface {
+	// Get 获取偏好，未设置时返回默认偏好
+	Get(ctx context.Context, userID string) (*Preferences, error)
+	Save(ctx context.Context, prefs *Preferences) error
+}
+
+// ContactResolver 解析用户联系方式
+type ContactResolver interface {
+	Resolve(ctx context.Context, userID string) (*Contact, error)
+}
+
+// SQLPreferenceStore 基于 notification_preferences 表的偏好存储
+type SQLPreferenceStore struct {
+	db *database.DB
+}
+
+// NewSQLPreferenceStore 创建偏好存储
+func NewSQLPreferenceStore(db *database.DB) *SQLPreferenceStore {
+	return &SQLPreferenceStore{db: db}
+}
+
+// Get 获取偏好
+func (s *SQLPreferenceStore) Get(ctx context.Context, userID string) (*Preferences, error) {
+	var row struct {
+		Locale          string `db:"locale"`
+		Channels        []byte `db:"channels"`
+		MutedCategories []byte `db:"muted_categories"`
+		WebhookURL      string `db:"webhook_url"`
+	}
+	err := s.db.GetContext(ctx, &row, `
+		SELECT locale, channels, muted_categories, COALESCE(webhook_url, '') AS webhook_url
+		FROM notification_preferences
+		WHERE user_id = $1`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Preferences{UserID: userID, Channels: map[Channel]bool{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	prefs := &Preferences{UserID: userID, Locale: row.Locale, WebhookURL: row.WebhookURL}
+	if err := json.Unmarshal(row.Channels, &prefs.Channels); err != nil {
+		return nil, fmt.Errorf("failed to decode notification channels: %w", err)
+	}
+	if err := json.Unmarshal(row.MutedCategories, &prefs.MutedCategories); err != nil {
+		return nil, fmt.Errorf("failed to decode muted categories: %w", err)
+	}
+	if prefs.Channels == nil {
+		prefs.Channels = map[Channel]bool{}
+	}
+	return prefs, nil
+}
+
+// Save 保存偏好
+func (s *SQLPreferenceStore) Save(ctx context.Context, prefs *Preferences) error {
+	channels, err := json.Marshal(prefs.Channels)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification channels: %w", err)
+	}
+	if prefs.MutedCategories == nil {
+		prefs.MutedCategories = []string{}
+	}
+	muted, err := json.Marshal(prefs.MutedCategories)
+	if err != nil {
+		return fmt.Errorf("failed to encode muted categories: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, locale, channels, muted_categories, webhook_url, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			locale = EXCLUDED.locale,
+			channels = EXCLUDED.channels,
+			muted_categories = EXCLUDED.muted_categories,
+			webhook_url = EXCLUDED.webhook_url,
+			updated_at = NOW()`,
+		prefs.UserID, prefs.Locale, channels, muted, prefs.WebhookURL)
+	if err != nil {
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return nil
+}
+
+// SQLContactResolver 从 users 表读取邮箱与手机号
+type SQLContactResolver struct {
+	db *database.DB
+}
+
+// NewSQLContactResolver 创建联系方式解析器
+func NewSQLContactResolver(db *database.DB) *SQLContactResolver {
+	return &SQLContactResolver{db: db}
+}
+
+// Resolve 解析联系方式，已删除的用户返回空联系方式
+func (r *SQLContactResolver) Resolve(ctx context.Context, userID string) (*Contact, error) {
+	var contact struct {
+		Email  string `db:"email"`
+		Mobile string `db:"mobile"`
+	}
+	err := r.db.GetContext(ctx, &contact, `
+		SELECT COALESCE(email, '') AS email, COALESCE(mobile, '') AS mobile
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Contact{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve contact: %w", err)
+	}
+	return &Contact{Email: contact.Email, Mobile: contact.Mobile}, nil
+}
+
+// ValidateWebhookURL 校验用户配置的 Webhook 地址，拒绝非 HTTPS 与内网地址
+func ValidateWebhookURL(raw string, allowInsecure bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidWebhook, raw)
+	}
+	if u.Scheme != "https" && !(allowInsecure && u.Scheme == "http") {
+		return fmt.Errorf("%w: scheme must be https", ErrInvalidWebhook)
+	}
+	if allowInsecure {
+		return nil
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return fmt.Errorf("%w: private address", ErrInvalidWebhook)
+	}
+	if ip := net.ParseIP(host); ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()) {
+		return fmt.Errorf("%w: private address", ErrInvalidWebhook)
+	}
+	return nil
+}
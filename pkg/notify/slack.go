@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ChannelSlack Slack Incoming Webhook，用于运维报告等发往团队频道的通知，不在用户偏好的可选渠道中
+const ChannelSlack Channel = "slack"
+
+// SlackConfig Slack 驱动配置
+type SlackConfig struct {
+	Timeout       time.Duration `json:"timeout"`
+	AllowInsecure bool          `json:"allow_insecure"` // 允许 http 与内网地址，仅用于开发环境
+}
+
+// DefaultSlackConfig 默认 Slack 配置
+func DefaultSlackConfig() *SlackConfig {
+	return &SlackConfig{
+		Timeout: 5 * time.Second,
+	}
+}
+
+// slackPayload Incoming Webhook 请求体，text 支持 Slack 的 mrkdwn 语法
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// SlackDriver 以 Incoming Webhook 发送消息，收件地址为 Webhook URL
+type SlackDriver struct {
+	config *SlackConfig
+	client *http.Client
+}
+
+// NewSlackDriver 创建 Slack 驱动
+func NewSlackDriver(config *SlackConfig) *SlackDriver {
+	if config == nil {
+		config = DefaultSlackConfig()
+	}
+	return &SlackDriver{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Channel 渠道
+func (d *SlackDriver) Channel() Channel {
+	return ChannelSlack
+}
+
+// Send 发送消息，主题加粗作为首行；4xx（429 除外）视为不可重试
+func (d *SlackDriver) Send(ctx context.Context, msg *Message) error {
+	if err := ValidateWebhookURL(msg.To, d.config.AllowInsecure); err != nil {
+		return Permanent(err)
+	}
+
+	text := msg.Body
+	if msg.Subject != "" {
+		text = "*" + msg.Subject + "*\n" + text
+	}
+	body, err := json.Marshal(slackPayload{Text: text})
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to marshal slack payload: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to create slack request: %w", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("slack responded with status %d", resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+var _ Driver = (*SlackDriver)(nil)
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Template 通知模板，Subject 与 Body 使用 Go 模板语法
+type Template struct {
+	Subject string
+	Body    string
+	HTML    bool // 正文按 HTML 转义渲染，仅邮件渠道使用
+}
+
+// bodyTemplate text/template 与 html/template 的公共接口
+type bodyTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+type compiledTemplate struct {
+	subject *template.Template
+	body    bodyTemplate
+	html    bool
+}
+
+// Templates 按 名称/渠道/语言 注册的模板集合。
+// 查找顺序：指定渠道优先于通用模板（渠道为空），语言按 zh-CN → zh → 默认语言 回退。
+type Templates struct {
+	defaultLocale string
+	items         map[string]*compiledTemplate
+	mu            sync.RWMutex
+}
+
+// NewTemplates 创建模板集合
+func NewTemplates(defaultLocale string) *Templates {
+	return &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		items:         make(map[string]*compiledTemplate),
+	}
+}
+
+// Register 注册模板，channel 为空表示所有渠道通用
+func (t *Templates) Register(name string, channel Channel, locale string, tpl Template) error {
+	subject, err := template.New(name + ".subject").Option("missingkey=error").Parse(tpl.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s subject: %w", name, err)
+	}
+
+	var body bodyTemplate
+	if tpl.HTML {
+		body, err = htmltemplate.New(name + ".body").Option("missingkey=error").Parse(tpl.Body)
+	} else {
+		body, err = template.New(name + ".body").Option("missingkey=error").Parse(tpl.Body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse template %s body: %w", name, err)
+	}
+
+	compiled := &compiledTemplate{subject: subject, body: body, html: tpl.HTML}
+
+	t.mu.Lock()
+	t.items[templateKey(name, channel, normalizeLocale(locale))] = compiled
+	t.mu.Unlock()
+	return nil
+}
+
+// Render 渲染模板，返回实际使用的语言
+func (t *Templates) Render(name string, channel Channel, locale string, data interface{}) (subject, body string, html bool, usedLocale string, err error) {
+	compiled, usedLocale := t.lookup(name, channel, locale)
+	if compiled == nil {
+		return "", "", false, "", fmt.Errorf("%w: %s (%s, %s)", ErrTemplateNotFound, name, channel, locale)
+	}
+
+	var subjectBuf, bodyBuf bytes.Buffer
+	if err := compiled.subject.Execute(&subjectBuf, data); err != nil {
+		return "", "", false, "", fmt.Errorf("failed to render template %s subject: %w", name, err)
+	}
+	if err := compiled.body.Execute(&bodyBuf, data); err != nil {
+		return "", "", false, "", fmt.Errorf("failed to render template %s body: %w", name, err)
+	}
+	return subjectBuf.String(), bodyBuf.String(), compiled.html, usedLocale, nil
+}
+
+func (t *Templates) lookup(name string, channel Channel, locale string) (*compiledTemplate, string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, l := range localeChain(normalizeLocale(locale), t.defaultLocale) {
+		for _, ch := range []Channel{channel, ""} {
+			if compiled, ok := t.items[templateKey(name, ch, l)]; ok {
+				return compiled, l
+			}
+		}
+	}
+	return nil, ""
+}
+
+func templateKey(name string, channel Channel, locale string) string {
+	return name + "|" + string(channel) + "|" + locale
+}
+
+// localeChain 语言回退链：zh-CN → zh → 默认语言
+func localeChain(locale, defaultLocale string) []string {
+	chain := make([]string, 0, 3)
+	if locale != "" {
+		chain = append(chain, locale)
+		if i := strings.IndexByte(locale, '-'); i > 0 {
+			chain = append(chain, locale[:i])
+		}
+	}
+	if defaultLocale != "" && (len(chain) == 0 || chain[len(chain)-1] != defaultLocale) {
+		chain = append(chain, defaultLocale)
+	}
+	return chain
+}
+
+// normalizeLocale 统一为 zh-CN 形式
+func normalizeLocale(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	parts := strings.SplitN(locale, "-", 2)
+	if len(parts) == 2 {
+		return strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1])
+	}
+	return strings.ToLower(locale)
+}
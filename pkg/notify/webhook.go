@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Webhook 请求头
+const (
+	HeaderWebhookDelivery  = "X-Notify-Delivery"
+	HeaderWebhookTimestamp = "X-Notify-Timestamp"
+	HeaderWebhookSignature = "X-Notify-Signature"
+)
+
+// WebhookConfig Webhook 驱动配置
+type WebhookConfig struct {
+	Secret        string        `json:"secret"` // HMAC-SHA256 签名密钥
+	Timeout       time.Duration `json:"timeout"`
+	AllowInsecure bool          `json:"allow_insecure"` // 允许 http 与内网地址，仅用于开发环境
+}
+
+// DefaultWebhookConfig 默认 Webhook 配置
+func DefaultWebhookConfig() *WebhookConfig {
+	return &WebhookConfig{
+		Timeout: 5 * time.Second,
+	}
+}
+
+// webhookPayload Webhook 请求体
+type webhookPayload struct {
+	ID       int64  `json:"id"`
+	UserID   string `json:"user_id"`
+	Category string `json:"category"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+	SentAt   int64  `json:"sent_at"`
+}
+
+// WebhookDriver 以签名的 JSON POST 请求推送通知
+type WebhookDriver struct {
+	config *WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookDriver 创建 Webhook 驱动
+func NewWebhookDriver(config *WebhookConfig) *WebhookDriver {
+	if config == nil {
+		config = DefaultWebhookConfig()
+	}
+	return &WebhookDriver{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// 不跟随重定向，避免绕过地址校验
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Channel 渠道
+func (d *WebhookDriver) Channel() Channel {
+	return ChannelWebhook
+}
+
+// Send 发送 Webhook，4xx（408/429 除外）视为不可重试
+func (d *WebhookDriver) Send(ctx context.Context, msg *Message) error {
+	if err := ValidateWebhookURL(msg.To, d.config.AllowInsecure); err != nil {
+		return Permanent(err)
+	}
+
+	now := time.Now().Unix()
+	body, err := json.Marshal(webhookPayload{
+		ID:       msg.DeliveryID,
+		UserID:   msg.UserID,
+		Category: msg.Category,
+		Subject:  msg.Subject,
+		Body:     msg.Body,
+		SentAt:   now,
+	})
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to marshal webhook payload: %w", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to create webhook request: %w", err))
+	}
+	timestamp := strconv.FormatInt(now, 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookDelivery, strconv.FormatInt(msg.DeliveryID, 10))
+	req.Header.Set(HeaderWebhookTimestamp, timestamp)
+	if d.config.Secret != "" {
+		req.Header.Set(HeaderWebhookSignature, SignWebhook(d.config.Secret, timestamp, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+// SignWebhook 计算签名：hex(HMAC-SHA256(secret, timestamp + "." + body))，接收方可用于验签
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ Driver = (*WebhookDriver)(nil)
@@ -0,0 +1,314 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/ctxutil"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidState state 不存在、已使用或已过期
+var ErrInvalidState = errors.New("invalid or expired oidc state")
+
+// stateTTL 授权请求有效期
+const stateTTL = 10 * time.Minute
+
+// authState 发起授权时保存的一次性状态
+type authState struct {
+	Provider   string `json:"provider"`
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"verifier"` // PKCE code_verifier
+	RedirectTo string `json:"redirect_to"`
+	TenantID   string `json:"tenant_id,omitempty"` // 发起登录的请求所属租户
+}
+
+// Identity 身份提供方返回的用户身份
+type Identity struct {
+	Provider      string        `json:"provider"`
+	Subject       string        `json:"subject"`
+	Email         string        `json:"email"`
+	EmailVerified bool          `json:"email_verified"`
+	Name          string        `json:"name"`
+	Picture       string        `json:"picture"`
+	Groups        []string      `json:"groups"` // RoleClaim 对应的声明值
+	Claims        jwt.MapClaims `json:"-"`
+	// TenantID 发起登录时请求所属的租户。身份提供方的回调不带租户请求头，开户与签发令牌以此为准
+	TenantID string `json:"-"`
+}
+
+// Client 多身份提供方的授权码 + PKCE 客户端，state 保存在共享存储中以支持多实例
+type Client struct {
+	mu        sync.RWMutex
+	providers map[string]*Provider
+	states    StateStore
+}
+
+// NewClient 创建 OIDC 客户端
+func NewClient(states StateStore) *Client {
+	return &Client{
+		providers: make(map[string]*Provider),
+		states:    states,
+	}
+}
+
+// Setup 按全局配置完成各身份提供方的发现并注册，单个提供方失败不影响其他提供方
+func (c *Client) Setup(ctx context.Context, providers []config.OIDCProviderConfig) {
+	for _, cfg := range providers {
+		provider, err := NewProvider(ctx, FromConfig(cfg), nil)
+		if err != nil {
+			log.Printf("Failed to set up OIDC provider %s: %v", cfg.Name, err)
+			continue
+		}
+		c.AddProvider(provider)
+		log.Printf("OIDC provider %s registered", cfg.Name)
+	}
+}
+
+// AddProvider 注册身份提供方
+func (c *Client) AddProvider(provider *Provider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.providers[provider.config.Name] = provider
+}
+
+// Provider 获取身份提供方
+func (c *Client) Provider(name string) (*Provider, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	provider, ok := c.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Providers 已注册的身份提供方名称
+func (c *Client) Providers() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, 0, len(c.providers))
+	for name := range c.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// AuthCodeURL 生成授权地址，同时生成并保存 state、nonce 与 PKCE verifier。
+// 返回的 state 应绑定到发起登录的浏览器（见 Handler 的 state cookie），回调时核对
+func (c *Client) AuthCodeURL(ctx context.Context, providerName, redirectTo string) (string, string, error) {
+	provider, err := c.Provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomString(48)
+	if err != nil {
+		return "", "", err
+	}
+
+	saved, err := json.Marshal(authState{
+		Provider:   providerName,
+		Nonce:      nonce,
+		Verifier:   verifier,
+		RedirectTo: redirectTo,
+		TenantID:   ctxutil.TenantID(ctx),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode oidc state: %w", err)
+	}
+	if err := c.states.Save(ctx, stateKey(state), saved, stateTTL); err != nil {
+		return "", "", err
+	}
+
+	scopes := provider.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientID)
+	query.Set("redirect_uri", provider.config.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	endpoint := provider.discovery.AuthorizationEndpoint
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode(), state, nil
+}
+
+// Exchange 校验 state 后用授权码换取令牌，并校验 ID Token。返回身份与发起时的跳转地址
+func (c *Client) Exchange(ctx context.Context, providerName, state, code string) (*Identity, string, error) {
+	if state == "" || code == "" {
+		return nil, "", ErrInvalidState
+	}
+
+	// state 只能使用一次：读取与删除是一个原子操作，同一 state 的并发回调只有一个能继续
+	raw, err := c.states.Take(ctx, stateKey(state))
+	if err != nil {
+		return nil, "", err
+	}
+	var saved authState
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		return nil, "", ErrInvalidState
+	}
+	if saved.Provider != providerName {
+		return nil, "", ErrInvalidState
+	}
+
+	provider, err := c.Provider(providerName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rawIDToken, err := provider.exchangeCode(ctx, code, saved.Verifier)
+	if err != nil {
+		return nil, "", err
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, saved.Nonce)
+	if err != nil {
+		return nil, "", err
+	}
+	identity := provider.identity(claims)
+	identity.TenantID = saved.TenantID
+	return identity, saved.RedirectTo, nil
+}
+
+// exchangeCode 调用令牌端点，返回 id_token
+func (p *Provider) exchangeCode(ctx context.Context, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		// client_secret_basic 要求先做表单编码（RFC 6749 2.3.1）
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+	return token.IDToken, nil
+}
+
+// identity 从声明中提取身份
+func (p *Provider) identity(claims jwt.MapClaims) *Identity {
+	identity := &Identity{
+		Provider: p.config.Name,
+		Claims:   claims,
+	}
+	identity.Subject, _ = claims.GetSubject()
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Picture, _ = claims["picture"].(string)
+
+	// 部分身份提供方以字符串返回 email_verified
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+
+	if p.config.RoleClaim != "" {
+		switch v := claims[p.config.RoleClaim].(type) {
+		case string:
+			identity.Groups = []string{v}
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					identity.Groups = append(identity.Groups, s)
+				}
+			}
+		}
+	}
+	return identity
+}
+
+// MapRole 按配置将声明值映射为应用角色，命中多个时取最大值
+func (p *ProviderConfig) MapRole(groups []string) int {
+	role := p.DefaultRole
+	for _, group := range groups {
+		// viper 会将 map 键转为小写，这里统一按小写比较
+		for key, mapped := range p.RoleMapping {
+			if strings.EqualFold(key, group) && mapped > role {
+				role = mapped
+			}
+		}
+	}
+	return role
+}
+
+func stateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
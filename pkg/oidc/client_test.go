@@ -0,0 +1,415 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdP 最小的身份提供方：发现文档、JWKS 与令牌端点。授权页由测试直接调用 authorize 模拟，
+// 令牌端点按授权时的 code_challenge 校验 code_verifier
+type fakeIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+	nonce string // 不为空时 ID Token 使用该 nonce 而非授权请求中的 nonce
+}
+
+// grant 已签发的授权码对应的授权请求
+type grant struct {
+	challenge string
+	nonce     string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &fakeIdP{key: key, codes: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, discoveryDocument{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+			SigningAlgs:           []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string][]jwk{"keys": {{
+			Kid: "k1",
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize 模拟用户在授权页同意，返回回调携带的授权码
+func (idp *fakeIdP) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	code := "code-" + query.Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = grant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	idp.mu.Unlock()
+	return code
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	idp.mu.Lock()
+	g, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	nonce := g.nonce
+	if idp.nonce != "" {
+		nonce = idp.nonce
+	}
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            "app",
+		"sub":            "alice",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"nonce":          nonce,
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+	})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// stubStates 以 Hook 应答 SET 与 GETDEL，不连接 Redis，并记录执行过的命令
+type stubStates struct {
+	mu       sync.Mutex
+	values   map[string]string
+	commands []string
+}
+
+func newStubStateStore(stub *stubStates) *RedisStateStore {
+	stub.values = make(map[string]string)
+	client := redis.NewClient(&redis.Options{Addr: "stub:6379"})
+	client.AddHook(stub)
+	return NewRedisStateStore(client)
+}
+
+func (s *stubStates) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, net.ErrClosed
+	}
+}
+
+func (s *stubStates) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		name := strings.ToLower(cmd.Name())
+		s.commands = append(s.commands, name)
+		key, _ := cmd.Args()[1].(string)
+		switch name {
+		case "set":
+			value, _ := cmd.Args()[2].([]byte)
+			s.values[key] = string(value)
+			cmd.(*redis.StatusCmd).SetVal("OK")
+		case "getdel":
+			value, ok := s.values[key]
+			if !ok {
+				cmd.SetErr(redis.Nil)
+				return redis.Nil
+			}
+			delete(s.values, key)
+			cmd.(*redis.StringCmd).SetVal(value)
+		}
+		return nil
+	}
+}
+
+func (s *stubStates) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return net.ErrClosed
+	}
+}
+
+func (s *stubStates) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// recordingProvisioner 记录开户时 ctx 中的租户，用户归入该租户
+type recordingProvisioner struct {
+	mu      sync.Mutex
+	tenants []string
+}
+
+func (p *recordingProvisioner) Provision(ctx context.Context, identity *Identity, role int, syncRole bool) (*User, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tenants = append(p.tenants, ctxutil.TenantID(ctx))
+	return &User{ID: "u-" + identity.Subject, Role: role, TenantID: ctxutil.TenantID(ctx)}, nil
+}
+
+// loginFixture 接入假身份提供方的单点登录路由
+type loginFixture struct {
+	idp         *fakeIdP
+	client      *Client
+	states      *stubStates
+	provisioner *recordingProvisioner
+	router      *gin.Engine
+}
+
+func newLoginFixture(t *testing.T) *loginFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	previous := config.GlobalConfig.JWT.Secret
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	t.Cleanup(func() { config.GlobalConfig.JWT.Secret = previous })
+
+	f := &loginFixture{idp: newFakeIdP(t), states: &stubStates{}, provisioner: &recordingProvisioner{}}
+	f.client = NewClient(newStubStateStore(f.states))
+	f.client.Setup(context.Background(), []config.OIDCProviderConfig{{
+		Name:        "test",
+		Issuer:      f.idp.server.URL,
+		ClientID:    "app",
+		RedirectURL: "https://app.example.com/auth/oidc/test/callback",
+	}})
+	_, err := f.client.Provider("test")
+	require.NoError(t, err, "provider discovery")
+
+	f.router = gin.New()
+	// 租户由请求头指定，身份提供方的回调不带该请求头
+	f.router.Use(func(c *gin.Context) {
+		if tenantID := c.GetHeader("X-Tenant-ID"); tenantID != "" {
+			c.Request = c.Request.WithContext(ctxutil.WithTenantID(c.Request.Context(), tenantID))
+		}
+	})
+	NewHandler(f.client, f.provisioner).RegisterRoutes(f.router.Group("/auth"))
+	return f
+}
+
+// login 发起登录，返回授权地址与浏览器收到的 state cookie
+func (f *loginFixture) login(t *testing.T, tenantID string) (string, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/test/login", nil)
+	if tenantID != "" {
+		req.Header.Set("X-Tenant-ID", tenantID)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == stateCookie {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie, "login sets the state cookie")
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	return w.Header().Get("Location"), cookie
+}
+
+// callback 以给定的 cookie 访问回调地址
+func (f *loginFixture) callback(state, code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	query := url.Values{"state": {state}, "code": {code}}
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/test/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func stateOf(t *testing.T, authURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	return parsed.Query().Get("state")
+}
+
+// TestHandler_StateBoundToCookieAndConsumedOnce 回调的 state 必须与发起登录的浏览器 cookie 一致；
+// state 以 GETDEL 一次性读取，重放同一回调被拒绝；开户使用发起登录时的租户，令牌绑定用户的租户
+func TestHandler_StateBoundToCookieAndConsumedOnce(t *testing.T) {
+	f := newLoginFixture(t)
+
+	authURL, cookie := f.login(t, "acme")
+	state := stateOf(t, authURL)
+	assert.Equal(t, state, cookie.Value)
+	code := f.idp.authorize(t, authURL)
+
+	w := f.callback(state, code, cookie)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	claims, err := utils.ParseToken(body.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "u-alice", claims.UserID)
+	assert.Equal(t, "acme", claims.TenantID)
+	assert.Equal(t, []string{AMRSSO}, claims.AMR)
+	assert.Equal(t, []string{"acme"}, f.provisioner.tenants)
+	assert.Contains(t, f.states.Commands(), "getdel")
+	assert.NotContains(t, f.states.Commands(), "get")
+
+	// 重放：cookie 与 state 仍然匹配，但 state 已被取走
+	w = f.callback(state, code, cookie)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Len(t, f.provisioner.tenants, 1)
+}
+
+// TestHandler_RejectsCookieMismatch 攻击者把自己的回调链接交给受害者：受害者浏览器没有 state cookie
+// 或持有其他登录的 state，回调在换取令牌之前被拒绝
+func TestHandler_RejectsCookieMismatch(t *testing.T) {
+	f := newLoginFixture(t)
+
+	attackerURL, _ := f.login(t, "")
+	attackerState := stateOf(t, attackerURL)
+	attackerCode := f.idp.authorize(t, attackerURL)
+	_, victimCookie := f.login(t, "")
+
+	w := f.callback(attackerState, attackerCode, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = f.callback(attackerState, attackerCode, victimCookie)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, f.provisioner.tenants)
+	assert.NotContains(t, f.states.Commands(), "getdel", "mismatched callbacks never reach the state store")
+
+	// 拒绝时清除 cookie
+	var cleared bool
+	for _, c := range w.Result().Cookies() {
+		if c.Name == stateCookie && c.MaxAge < 0 {
+			cleared = true
+		}
+	}
+	assert.True(t, cleared)
+}
+
+// TestClient_RejectsNonceMismatch ID Token 中的 nonce 与发起授权时保存的不一致时拒绝
+func TestClient_RejectsNonceMismatch(t *testing.T) {
+	f := newLoginFixture(t)
+	f.idp.nonce = "replayed-nonce"
+
+	authURL, state, err := f.client.AuthCodeURL(context.Background(), "test", "")
+	require.NoError(t, err)
+	code := f.idp.authorize(t, authURL)
+
+	_, _, err = f.client.Exchange(context.Background(), "test", state, code)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "nonce mismatch")
+}
+
+// TestClient_PKCERoundTrip 令牌请求携带的 code_verifier 与授权地址中的 S256 challenge 对应；
+// challenge 不符时令牌端点拒绝，state 也已被消费
+func TestClient_PKCERoundTrip(t *testing.T) {
+	f := newLoginFixture(t)
+
+	authURL, state, err := f.client.AuthCodeURL(context.Background(), "test", "/welcome")
+	require.NoError(t, err)
+	code := f.idp.authorize(t, authURL)
+	identity, redirectTo, err := f.client.Exchange(context.Background(), "test", state, code)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", identity.Subject)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, "/welcome", redirectTo)
+
+	authURL, state, err = f.client.AuthCodeURL(context.Background(), "test", "")
+	require.NoError(t, err)
+	code = f.idp.authorize(t, authURL)
+	f.idp.mu.Lock()
+	f.idp.codes[code] = grant{challenge: "tampered", nonce: f.idp.codes[code].nonce}
+	f.idp.mu.Unlock()
+	_, _, err = f.client.Exchange(context.Background(), "test", state, code)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_grant")
+
+	_, _, err = f.client.Exchange(context.Background(), "test", state, code)
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+// TestRedisStateStore_TakeOnce 并发取同一 state 只有一个成功
+func TestRedisStateStore_TakeOnce(t *testing.T) {
+	stub := &stubStates{}
+	store := newStubStateStore(stub)
+	ctx := context.Background()
+	require.NoError(t, store.Save(ctx, "oidc:state:s1", []byte(`{"provider":"test"}`), stateTTL))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := store.Take(ctx, "oidc:state:s1")
+			if err == nil {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+				assert.JSONEq(t, `{"provider":"test"}`, string(value))
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidState)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, taken)
+}
@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 本地用户状态，与用户模块保持一致
+const (
+	statusBanned  = 1
+	statusDeleted = 2
+)
+
+// AMRSSO 令牌中表示通过外部身份提供方登录的认证方式
+const AMRSSO = "sso"
+
+// stateCookie 保存发起登录的浏览器持有的 state，回调时必须与参数中的 state 一致，
+// 防止攻击者把自己的授权回调链接塞给受害者完成登录（login CSRF）
+const stateCookie = "oidc_state"
+
+// Handler 单点登录接口
+type Handler struct {
+	client      *Client
+	provisioner Provisioner
+}
+
+// NewHandler 创建单点登录接口
+func NewHandler(client *Client, provisioner Provisioner) *Handler {
+	return &Handler{client: client, provisioner: provisioner}
+}
+
+// RegisterRoutes 注册路由（无需鉴权）
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/oidc/providers", h.ListProviders)
+	group.GET("/oidc/:provider/login", h.Login)
+	group.GET("/oidc/:provider/callback", h.Callback)
+}
+
+// ListProviders 可用的身份提供方
+func (h *Handler) ListProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"providers": h.client.Providers(),
+	})
+}
+
+// Login 重定向到身份提供方授权页，redirect 参数为登录完成后前端跳转的相对路径
+func (h *Handler) Login(c *gin.Context) {
+	authURL, state, err := h.client.AuthCodeURL(c.Request.Context(), c.Param("provider"), safeRedirect(c.Query("redirect")))
+	if err != nil {
+		apperrors.Render(c, exchangeError(err))
+		return
+	}
+	setStateCookie(c, state, int(stateTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 身份提供方回调：校验 state/nonce，开户并签发应用令牌
+func (h *Handler) Callback(c *gin.Context) {
+	bound, _ := c.Cookie(stateCookie)
+	// 无论结果如何，state 都只能使用一次
+	setStateCookie(c, "", -1)
+	state := c.Query("state")
+	if bound == "" || subtle.ConstantTimeCompare([]byte(bound), []byte(state)) != 1 {
+		apperrors.Render(c, exchangeError(ErrInvalidState))
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		apperrors.Render(c, apperrors.Newf(apperrors.CodeAuthFailed, "identity provider returned %s", errCode))
+		return
+	}
+
+	providerName := c.Param("provider")
+	identity, redirectTo, err := h.client.Exchange(c.Request.Context(), providerName, state, c.Query("code"))
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", providerName, err)
+		apperrors.Render(c, exchangeError(err))
+		return
+	}
+
+	provider, err := h.client.Provider(providerName)
+	if err != nil {
+		apperrors.Render(c, exchangeError(err))
+		return
+	}
+	cfg := provider.Config()
+
+	ctx := c.Request.Context()
+	if identity.TenantID != "" {
+		ctx = ctxutil.WithTenantID(ctx, identity.TenantID)
+	}
+	user, err := h.provisioner.Provision(ctx, identity, cfg.MapRole(identity.Groups), cfg.SyncRole)
+	if errors.Is(err, ErrTenantMismatch) {
+		log.Printf("OIDC login with %s rejected: %v", providerName, err)
+		apperrors.Render(c, apperrors.New(apperrors.CodeAuthFailed, ""))
+		return
+	}
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	switch user.Status {
+	case statusBanned:
+		apperrors.Render(c, apperrors.New(apperrors.CodeUserBanned, ""))
+		return
+	case statusDeleted:
+		apperrors.Render(c, apperrors.New(apperrors.CodeUserDeleted, ""))
+		return
+	}
+
+	// 与密码登录一致，令牌绑定用户所在的租户
+	token, expireAt, err := utils.GenerateTenantToken(user.TenantID, user.ID, user.Role, []string{AMRSSO})
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+
+	if redirectTo != "" {
+		// 令牌放在 fragment 中，不会发送到服务端或出现在 Referer 里
+		fragment := url.Values{}
+		fragment.Set("token", token)
+		c.Redirect(http.StatusFound, redirectTo+"#"+fragment.Encode())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expireAt,
+		"user_id":    user.ID,
+		"role":       user.Role,
+		"created":    user.Created,
+	})
+}
+
+// setStateCookie 写入或清除（maxAge < 0）state cookie。身份提供方回调是跨站的顶层 GET 跳转，
+// SameSite=Lax 下 cookie 仍会随回调发送
+func setStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, maxAge, "/", "", secure, true)
+}
+
+// safeRedirect 只允许站内相对路径，防止开放重定向
+func safeRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.Contains(redirect, "\\") {
+		return ""
+	}
+	return redirect
+}
+
+func exchangeError(err error) error {
+	switch {
+	case errors.Is(err, ErrUnknownProvider):
+		return apperrors.Wrap(err, apperrors.CodeNotFound, err.Error())
+	case errors.Is(err, ErrInvalidState):
+		return apperrors.Wrap(err, apperrors.CodeAuthFailed, err.Error())
+	default:
+		return apperrors.Wrap(err, apperrors.CodeAuthFailed, "")
+	}
+}
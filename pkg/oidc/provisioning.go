@@ -0,0 +1,201 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxUsernameLength users.username 列的长度
+const maxUsernameLength = 100
+
+// identityConstraint user_identities 上 (provider, subject) 的唯一约束
+const identityConstraint = "user_identities_provider_subject_key"
+
+// ErrTenantMismatch 外部身份已关联到其他租户的用户
+var ErrTenantMismatch = errors.New("identity belongs to another tenant")
+
+// User 登录后的本地用户
+type User struct {
+	ID       string `db:"id" json:"id"`
+	Role     int    `db:"role" json:"role"`
+	Status   int    `db:"status" json:"status"`
+	TenantID string `db:"tenant_id" json:"tenant_id"`
+	Created  bool   `db:"-" json:"created"` // 本次登录时创建
+}
+
+// Provisioner 将外部身份映射为本地用户，必要时即时创建（JIT）
+type Provisioner interface {
+	Provision(ctx context.Context, identity *Identity, role int, syncRole bool) (*User, error)
+}
+
+// SQLProvisioner 基于 user_identities 表的即时开户
+type SQLProvisioner struct {
+	db *database.DB
+	// linkByEmail 首次登录时按已验证邮箱关联已有账号。仅在信任身份提供方邮箱验证时开启，
+	// 否则可能导致账号被接管
+	linkByEmail bool
+}
+
+// NewSQLProvisioner 创建即时开户器
+func NewSQLProvisioner(db *database.DB, linkByEmail bool) *SQLProvisioner {
+	return &SQLProvisioner{db: db, linkByEmail: linkByEmail}
+}
+
+// Provision 查找外部身份关联的用户，不存在时在 ctx 的租户（没有租户时为默认租户）内按邮箱关联或创建新用户。
+// ctx 中有租户而身份已关联到其他租户的用户时返回 ErrTenantMismatch
+func (p *SQLProvisioner) Provision(ctx context.Context, identity *Identity, role int, syncRole bool) (*User, error) {
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("identity has no subject")
+	}
+
+	user, err := p.provision(ctx, identity, role, syncRole)
+	// 同一身份的并发首次登录：另一个请求已先关联，重新执行即可读到关联的用户
+	if database.IsUniqueViolation(err, identityConstraint) {
+		user, err = p.provision(ctx, identity, role, syncRole)
+	}
+	return user, err
+}
+
+func (p *SQLProvisioner) provision(ctx context.Context, identity *Identity, role int, syncRole bool) (*User, error) {
+	tenantID := database.TenantForWrite(ctx)
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var user User
+	err = tx.GetContext(ctx, &user, `
+		SELECT u.id, u.role, u.status, u.tenant_id
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL`,
+		identity.Provider, identity.Subject)
+	switch {
+	case err == nil:
+		if !database.TenantVisible(ctx, user.TenantID) {
+			return nil, ErrTenantMismatch
+		}
+		if syncRole && user.Role != role {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE users SET role = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, user.ID, role); err != nil {
+				return nil, fmt.Errorf("failed to sync user role: %w", err)
+			}
+			user.Role = role
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE user_identities SET email = $3, last_login_at = CURRENT_TIMESTAMP WHERE provider = $1 AND subject = $2`,
+			identity.Provider, identity.Subject, identity.Email); err != nil {
+			return nil, fmt.Errorf("failed to update identity: %w", err)
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		linked := false
+		if p.linkByEmail && identity.EmailVerified && identity.Email != "" {
+			err = tx.GetContext(ctx, &user,
+				`SELECT id, role, status, tenant_id FROM users
+				WHERE LOWER(email) = LOWER($1) AND tenant_id = $2 AND deleted_at IS NULL LIMIT 1`, identity.Email, tenantID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("failed to find user by email: %w", err)
+			}
+			linked = err == nil
+		}
+
+		if !linked {
+			username, err := p.uniqueUsername(ctx, tx, identity)
+			if err != nil {
+				return nil, err
+			}
+			err = tx.GetContext(ctx, &user, `
+				INSERT INTO users (username, email, nickname, avatar_url, role, tenant_id)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id, role, status, tenant_id`,
+				username, identity.Email, identity.Name, identity.Picture, role, tenantID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create user: %w", err)
+			}
+			user.Created = true
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+			VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)`,
+			user.ID, identity.Provider, identity.Subject, identity.Email); err != nil {
+			return nil, fmt.Errorf("failed to link identity: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to find identity: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &user, nil
+}
+
+// uniqueUsername 为新用户生成未被占用的用户名。邮箱不能直接作为用户名：未验证的邮箱可以被任意声明，
+// 且不同提供方可能返回相同邮箱
+func (p *SQLProvisioner) uniqueUsername(ctx context.Context, tx *sqlx.Tx, identity *Identity) (string, error) {
+	for _, suffixLen := range []int{8, 16, sha256.Size * 2} {
+		username := deriveUsername(identity, suffixLen)
+		var taken bool
+		if err := tx.GetContext(ctx, &taken,
+			`SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`, username); err != nil {
+			return "", fmt.Errorf("failed to check username: %w", err)
+		}
+		if !taken {
+			return username, nil
+		}
+	}
+	return "", fmt.Errorf("no available username for %s identity", identity.Provider)
+}
+
+// deriveUsername 由邮箱前缀（或姓名、提供方名）与 provider:subject 摘要的前 suffixLen 位组成用户名，
+// 同一外部身份总是得到相同的结果
+func deriveUsername(identity *Identity, suffixLen int) string {
+	sum := sha256.Sum256([]byte(identity.Provider + ":" + identity.Subject))
+	suffix := hex.EncodeToString(sum[:])
+	if suffixLen < len(suffix) {
+		suffix = suffix[:suffixLen]
+	}
+
+	base := identity.Email
+	if at := strings.LastIndex(base, "@"); at >= 0 {
+		base = base[:at]
+	}
+	if base == "" {
+		base = identity.Name
+	}
+	base = sanitizeUsername(base)
+	if base == "" {
+		base = sanitizeUsername(identity.Provider)
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	if limit := maxUsernameLength - len(suffix) - 1; len(base) > limit {
+		base = strings.TrimRight(base[:limit], "._-")
+	}
+	return base + "_" + suffix
+}
+
+// sanitizeUsername 只保留 ASCII 字母、数字与 . _ -，并转为小写
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+		}
+	}
+	return strings.Trim(b.String(), "._-")
+}
@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeriveUsername 用户名由净化后的邮箱前缀与 provider:subject 摘要组成，同一身份结果稳定，
+// 不同身份的同名邮箱得到不同用户名；超长时截断前缀以保留摘要
+func TestDeriveUsername(t *testing.T) {
+	alice := &Identity{Provider: "okta", Subject: "1", Email: "Alice.Smith+sso@example.com"}
+	username := deriveUsername(alice, 8)
+	assert.Regexp(t, `^alice.smithsso_[0-9a-f]{8}$`, username)
+	assert.Equal(t, username, deriveUsername(alice, 8))
+
+	// 另一个提供方声明了相同邮箱
+	impostor := &Identity{Provider: "google", Subject: "1", Email: "alice.smith+sso@example.com"}
+	assert.NotEqual(t, username, deriveUsername(impostor, 8))
+
+	assert.Regexp(t, `^bob_[0-9a-f]{8}$`, deriveUsername(&Identity{Provider: "okta", Subject: "2", Name: "Bob"}, 8))
+	assert.Regexp(t, `^okta_[0-9a-f]{8}$`, deriveUsername(&Identity{Provider: "okta", Subject: "3", Name: "张三"}, 8))
+	assert.Regexp(t, `^user_[0-9a-f]{8}$`, deriveUsername(&Identity{Provider: "!!", Subject: "4"}, 8))
+
+	long := deriveUsername(&Identity{Provider: "okta", Subject: "5", Email: strings.Repeat("a", 200) + "@example.com"}, 64)
+	assert.Len(t, long, maxUsernameLength)
+	assert.Regexp(t, `_[0-9a-f]{64}$`, long)
+}
+
+// TestUniqueUsername_Collision 候选用户名被占用时加长摘要后缀重试，全部被占用时报错
+func TestUniqueUsername_Collision(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	p := NewSQLProvisioner(db, false)
+	identity := &Identity{Provider: "okta", Subject: "1", Email: "alice@example.com"}
+	exists := `SELECT EXISTS\(SELECT 1 FROM users WHERE username = \$1\)`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(exists).WithArgs(deriveUsername(identity, 8)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(exists).WithArgs(deriveUsername(identity, 16)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(exists).WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(exists).WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(exists).WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	username, err := p.uniqueUsername(context.Background(), tx, identity)
+	require.NoError(t, err)
+	assert.Equal(t, deriveUsername(identity, 16), username)
+
+	_, err = p.uniqueUsername(context.Background(), tx, identity)
+	assert.Error(t, err)
+}
+
+const (
+	selectIdentity = `SELECT u.id, u.role, u.status, u.tenant_id\s+FROM user_identities i`
+	insertUser     = `INSERT INTO users \(username, email, nickname, avatar_url, role, tenant_id\)`
+	insertIdentity = `INSERT INTO user_identities`
+)
+
+var userColumns = []string{"id", "role", "status", "tenant_id"}
+
+// TestProvision_ConcurrentFirstLogin 同一身份的两个首次登录并发：后关联的请求违反 (provider, subject)
+// 唯一约束，重新执行后读到先关联的用户，而不是返回错误
+func TestProvision_ConcurrentFirstLogin(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	p := NewSQLProvisioner(db, false)
+	identity := &Identity{Provider: "okta", Subject: "alice", Email: "alice@example.com"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectIdentity).WithArgs("okta", "alice").WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(insertUser).
+		WithArgs(sqlmock.AnyArg(), "alice@example.com", "", "", 0, ctxutil.DefaultTenantID).
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("loser", 0, 0, ctxutil.DefaultTenantID))
+	mock.ExpectExec(insertIdentity).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: identityConstraint})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectIdentity).WithArgs("okta", "alice").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("winner", 0, 0, ctxutil.DefaultTenantID))
+	mock.ExpectExec(`UPDATE user_identities SET email`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	user, err := p.Provision(context.Background(), identity, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, "winner", user.ID)
+	assert.False(t, user.Created)
+}
+
+// TestProvision_TenantScoped 新用户与按邮箱关联都限定在请求的租户内；已关联其他租户用户的身份被拒绝
+func TestProvision_TenantScoped(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	p := NewSQLProvisioner(db, true)
+	acme := ctxutil.WithTenantID(context.Background(), "acme")
+	identity := &Identity{Provider: "okta", Subject: "alice", Email: "alice@example.com", EmailVerified: true}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectIdentity).WithArgs("okta", "alice").WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectQuery(`SELECT id, role, status, tenant_id FROM users\s+WHERE LOWER\(email\) = LOWER\(\$1\) AND tenant_id = \$2`).
+		WithArgs("alice@example.com", "acme").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(insertUser).
+		WithArgs(sqlmock.AnyArg(), "alice@example.com", "", "", 0, "acme").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("u1", 0, 0, "acme"))
+	mock.ExpectExec(insertIdentity).WithArgs("u1", "okta", "alice", "alice@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	user, err := p.Provision(acme, identity, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", user.TenantID)
+	assert.True(t, user.Created)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectIdentity).WithArgs("okta", "alice").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("u1", 0, 0, "acme"))
+	mock.ExpectRollback()
+
+	_, err = p.Provision(ctxutil.WithTenantID(context.Background(), "globex"), identity, 0, false)
+	assert.ErrorIs(t, err, ErrTenantMismatch)
+}
@@ -0,0 +1,45 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StateStore 保存授权请求的一次性状态，多实例部署时须共享
+type StateStore interface {
+	Save(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Take 原子地读取并删除，并发的回调只有一个能取到；不存在或已过期时返回 ErrInvalidState
+	Take(ctx context.Context, key string) ([]byte, error)
+}
+
+// RedisStateStore 基于 Redis 的状态存储，以 GETDEL 读取并删除（需要 Redis 6.2 及以上）
+type RedisStateStore struct {
+	rdb *redis.Client
+}
+
+// NewRedisStateStore 创建基于 Redis 的状态存储
+func NewRedisStateStore(rdb *redis.Client) *RedisStateStore {
+	return &RedisStateStore{rdb: rdb}
+}
+
+func (s *RedisStateStore) Save(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := s.rdb.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save oidc state: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStateStore) Take(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.rdb.GetDel(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to take oidc state: %w", err)
+	}
+	return value, nil
+}
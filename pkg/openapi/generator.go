@@ -0,0 +1,318 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"user_crud_jwt/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route 路由注解，补充路由注册信息中无法推断的部分
+type Route struct {
+	Summary     string
+	Description string
+	Tags        []string            // 默认取路径第一段
+	Auth        bool                // 需要 Bearer Token
+	Query       interface{}         // 查询参数结构体，按 form 标签生成参数
+	Body        interface{}         // JSON 请求体类型
+	Response    interface{}         // 成功响应中 data 字段的类型
+	Status      int                 // 成功状态码，默认 200
+	Responses   map[int]interface{} // 其他成功状态码及对应 data 字段的类型，如排队时的 202
+	Errors      []int               // 可能返回的错误状态码
+	Raw         bool                // 响应体直接为 Response 类型，不使用 {code, message, data} 统一结构
+	Paginated   bool                // 列表接口，响应包含 pagination 字段并支持 ?fields= 筛选，Response 应为切片类型
+}
+
+// annotations 按处理函数名索引的路由注解，与 gin.RouteInfo.Handler 一致
+var annotations = struct {
+	routes map[string]Route
+	mu     sync.RWMutex
+}{routes: make(map[string]Route)}
+
+// Describe 为处理函数添加注解，同一处理函数挂在多个路由上时共用注解
+func Describe(handler gin.HandlerFunc, route Route) {
+	annotations.mu.Lock()
+	defer annotations.mu.Unlock()
+	annotations.routes[handlerName(handler)] = route
+}
+
+func lookup(handler string) (Route, bool) {
+	annotations.mu.RLock()
+	defer annotations.mu.RUnlock()
+	route, ok := annotations.routes[handler]
+	return route, ok
+}
+
+// handlerName 与 gin 内部计算 RouteInfo.Handler 的方式一致
+func handlerName(handler gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+}
+
+// 文档中使用的组件名
+const (
+	bearerAuth        = "bearerAuth"
+	errorResponseName = "ErrorResponse"
+)
+
+// Generator 根据 gin 路由表与注解生成 OpenAPI 文档
+type Generator struct {
+	info    Info
+	servers []Server
+	skip    map[string]bool
+	doc     *Document
+	mu      sync.RWMutex
+}
+
+// NewGenerator 创建文档生成器
+func NewGenerator(info Info, servers ...Server) *Generator {
+	return &Generator{
+		info:    info,
+		servers: servers,
+		skip:    map[string]bool{SpecPath: true, DocsPath: true},
+	}
+}
+
+// Generate 生成文档，未注解的路由只包含路径参数与通用响应
+func (g *Generator) Generate(routes gin.RoutesInfo) *Document {
+	builder := newSchemaBuilder()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    g.info,
+		Servers: g.servers,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	builder.schemas[errorResponseName] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":       {Type: "integer", Format: "int64"},
+			"message":    {Type: "string"},
+			"request_id": {Type: "string"},
+			"details":    {Type: "object", Nullable: true},
+		},
+		Required: []string{"code", "message"},
+	}
+
+	operationIDs := make(map[string]int)
+	for _, ri := range routes {
+		if g.skip[ri.Path] {
+			continue
+		}
+		route, described := lookup(ri.Handler)
+		template, params := convertPath(ri.Path)
+
+		op := &Operation{
+			OperationID: uniqueOperationID(operationIDs, ri.Handler),
+			Summary:     route.Summary,
+			Description: route.Description,
+			Tags:        route.Tags,
+			Parameters:  params,
+			Responses:   make(map[string]*Response),
+		}
+		if len(op.Tags) == 0 {
+			op.Tags = defaultTags(ri.Path)
+		}
+		if !described {
+			// 未注解的路由不约束响应结构
+			op.Responses[strconv.Itoa(http.StatusOK)] = &Response{Description: "OK", Content: jsonContent(&Schema{})}
+			op.Responses["default"] = &Response{Description: "Error", Content: jsonContent(&Schema{})}
+		} else {
+			describeOperation(builder, op, route)
+		}
+
+		item, ok := doc.Paths[template]
+		if !ok {
+			item = &PathItem{}
+		}
+		if item.setOperation(ri.Method, op) {
+			doc.Paths[template] = item
+		}
+	}
+
+	doc.Components.Schemas = builder.schemas
+	return doc
+}
+
+// describeOperation 按注解补充参数、请求体与响应
+func describeOperation(builder *schemaBuilder, op *Operation, route Route) {
+	if route.Auth {
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, queryParameters(builder, route.Query)...)
+	}
+	if route.Paginated {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:        response.FieldsParam,
+			In:          "query",
+			Description: "逗号分隔的字段名，只返回 data 中列表元素的这些字段",
+			Schema:      &Schema{Type: "string"},
+		})
+	}
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(builder.SchemaOf(route.Body)),
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := builder.SchemaOf(route.Response)
+	if !route.Raw {
+		success = envelope(success)
+		if route.Paginated {
+			success.Properties["pagination"] = builder.SchemaOf(response.Pagination{})
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = &Response{
+		Description: http.StatusText(status),
+		Content:     jsonContent(success),
+	}
+	for code, data := range route.Responses {
+		schema := builder.SchemaOf(data)
+		if !route.Raw {
+			schema = envelope(schema)
+		}
+		op.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     jsonContent(schema),
+		}
+	}
+
+	errorSchema := &Schema{Ref: schemaRef(errorResponseName)}
+	if route.Raw {
+		errorSchema = builder.SchemaOf(route.Response)
+	}
+	errorCodes := route.Errors
+	if route.Auth {
+		errorCodes = append([]int{http.StatusUnauthorized}, errorCodes...)
+	}
+	for _, code := range errorCodes {
+		op.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     jsonContent(errorSchema),
+		}
+	}
+	// 中间件（限流、鉴权等）可能返回未列出的错误状态码
+	op.Responses["default"] = &Response{Description: "Error", Content: jsonContent(&Schema{Ref: schemaRef(errorResponseName)})}
+}
+
+// Document 返回缓存的文档，首次调用时根据路由表生成。
+// 路由需在首次调用前注册完成
+func (g *Generator) Document(router *gin.Engine) *Document {
+	g.mu.RLock()
+	doc := g.doc
+	g.mu.RUnlock()
+	if doc != nil {
+		return doc
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.doc == nil {
+		g.doc = g.Generate(router.Routes())
+	}
+	return g.doc
+}
+
+// envelope 包装为统一响应结构 {code, message, data}
+func envelope(data *Schema) *Schema {
+	if data.Ref == "" && data.Type != "" {
+		data.Nullable = true
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Format: "int64"},
+			"message": {Type: "string"},
+			"data":    data,
+		},
+		Required: []string{"code", "message"},
+	}
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// convertPath 将 gin 路径 /users/:id 转换为 /users/{id} 并生成路径参数
+func convertPath(ginPath string) (string, []*Parameter) {
+	segments := strings.Split(ginPath, "/")
+	var params []*Parameter
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			name := seg[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// queryParameters 按结构体 form 标签生成查询参数
+func queryParameters(builder *schemaBuilder, query interface{}) []*Parameter {
+	t := reflect.TypeOf(query)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		// 与 gin 绑定一致，展开未加标签的嵌入结构体
+		if name == "" && field.Anonymous {
+			params = append(params, queryParameters(builder, reflect.New(field.Type).Elem().Interface())...)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		params = append(params, &Parameter{
+			Name:     name,
+			In:       "query",
+			Required: hasBindingRule(field, "required"),
+			Schema:   builder.schemaFor(field.Type),
+		})
+	}
+	return params
+}
+
+func defaultTags(ginPath string) []string {
+	first, _, _ := strings.Cut(strings.TrimPrefix(ginPath, "/"), "/")
+	if first == "" || first[0] == ':' || first[0] == '*' {
+		return nil
+	}
+	return []string{first}
+}
+
+// uniqueOperationID 取处理函数的方法名，闭包取外层函数名，重复时追加序号
+func uniqueOperationID(used map[string]int, handler string) string {
+	parts := strings.Split(strings.TrimSuffix(handler, "-fm"), ".")
+	name := parts[len(parts)-1]
+	if strings.HasPrefix(name, "func") && len(parts) > 1 {
+		name = parts[len(parts)-2]
+	}
+	name = sanitizeName(name)
+
+	used[name]++
+	if n := used[name]; n > 1 {
+		return name + strconv.Itoa(n)
+	}
+	return name
+}
@@ -0,0 +1,42 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 文档路由
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// swaggerUIPage Swagger UI 页面，静态资源从 CDN 加载
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API Docs</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({ url: "` + SpecPath + `", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+// RegisterRoutes 注册 /openapi.json 与 /docs，文档在首次访问时根据 router 的路由表生成
+func (g *Generator) RegisterRoutes(router *gin.Engine) {
+	router.GET(SpecPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, g.Document(router))
+	})
+	router.GET(DocsPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+	})
+}
@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"user_crud_jwt/pkg/cache"
+)
+
+// TopicCacheInvalidate 缓存失效事件：与业务数据在同一事务中写入，提交后由投递器删除缓存，
+// 避免事务回滚时缓存已被删除、或进程在提交后退出导致缓存未失效
+const TopicCacheInvalidate = "cache.invalidate"
+
+// CacheInvalidation 缓存失效事件内容
+type CacheInvalidation struct {
+	Keys []string `json:"keys,omitempty"` // 直接删除的键，按租户隔离的键需带上租户前缀（见 cache.TenantKey）
+	Tags []string `json:"tags,omitempty"` // 更新版本号的缓存标签
+}
+
+// NewCacheInvalidationEvent 创建缓存失效事件
+func NewCacheInvalidationEvent(key, dedupeKey string, invalidation CacheInvalidation) (*Event, error) {
+	return NewEvent(TopicCacheInvalidate, key, dedupeKey, invalidation)
+}
+
+// CacheInvalidationPublisher 删除事件中的键并失效标签，重复投递无副作用；tags 为 nil 时忽略标签
+func CacheInvalidationPublisher(store cache.CacheService, tags cache.TagInvalidator) Publisher {
+	return PublisherFunc(func(ctx context.Context, event *Event) error {
+		var invalidation CacheInvalidation
+		if err := json.Unmarshal(event.Payload, &invalidation); err != nil {
+			return fmt.Errorf("failed to decode cache invalidation %d: %w", event.ID, err)
+		}
+		for _, key := range invalidation.Keys {
+			if err := store.Delete(ctx, key); err != nil {
+				return fmt.Errorf("failed to delete cache key %s: %w", key, err)
+			}
+		}
+		if tags != nil && len(invalidation.Tags) > 0 {
+			if err := tags.InvalidateTags(ctx, invalidation.Tags...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,339 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// Status 事件状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusPublished Status = "published"
+	StatusDead      Status = "dead"
+)
+
+// Event 发件箱事件
+type Event struct {
+	ID            int64             `db:"id" json:"id"`
+	Topic         string            `db:"topic" json:"topic"`
+	Key           string            `db:"event_key" json:"key"`
+	DedupeKey     string            `db:"dedupe_key" json:"dedupe_key"`
+	Payload       json.RawMessage   `db:"payload" json:"payload"`
+	Headers       map[string]string `db:"-" json:"headers,omitempty"`
+	Attempts      int               `db:"attempts" json:"attempts"`
+	CreatedAt     time.Time         `db:"created_at" json:"created_at"`
+	NextAttemptAt time.Time         `db:"next_attempt_at" json:"-"`
+}
+
+// eventRow 投递器读取的行，headers 列为 JSONB
+type eventRow struct {
+	Event
+	Headers []byte `db:"headers"`
+}
+
+// Publisher 事件投递目标（事件总线、消息队列等）
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// PublisherFunc 函数形式的 Publisher
+type PublisherFunc func(ctx context.Context, event *Event) error
+
+// Publish 实现 Publisher
+func (f PublisherFunc) Publish(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// TopicRouter 按主题分发事件，未登记的主题返回错误，事件按失败重试直至 dead
+type TopicRouter map[string]Publisher
+
+// Publish 实现 Publisher
+func (r TopicRouter) Publish(ctx context.Context, event *Event) error {
+	publisher, ok := r[event.Topic]
+	if !ok {
+		return fmt.Errorf("no publisher for outbox topic %s", event.Topic)
+	}
+	return publisher.Publish(ctx, event)
+}
+
+// NewEvent 创建事件，dedupeKey 为空时自动生成
+func NewEvent(topic, key, dedupeKey string, payload interface{}) (*Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+	if dedupeKey == "" {
+		dedupeKey = fmt.Sprintf("%s:%s:%d", topic, key, time.Now().UnixNano())
+	}
+	return &Event{
+		Topic:     topic,
+		Key:       key,
+		DedupeKey: dedupeKey,
+		Payload:   data,
+	}, nil
+}
+
+// Enqueue 在业务事务中写入事件，与业务数据同时提交或回滚。
+// 相同 dedupeKey 的事件只写入一次。
+func Enqueue(ctx context.Context, tx *sqlx.Tx, event *Event) error {
+	var headers []byte
+	if len(event.Headers) > 0 {
+		data, err := json.Marshal(event.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal outbox headers: %w", err)
+		}
+		headers = data
+	}
+
+	query := `
+		INSERT INTO outbox_events (topic, event_key, dedupe_key, payload, headers)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (dedupe_key) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, event.Topic, event.Key, event.DedupeKey, []byte(event.Payload), headers); err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+	return nil
+}
+
+// RelayConfig 投递器配置
+type RelayConfig struct {
+	PollInterval       time.Duration `json:"poll_interval"`
+	BatchSize          int           `json:"batch_size"`
+	MaxAttempts        int           `json:"max_attempts"` // 超过后标记为 dead
+	BaseBackoff        time.Duration `json:"base_backoff"` // 重试退避基数
+	MaxBackoff         time.Duration `json:"max_backoff"`
+	PublishTimeout     time.Duration `json:"publish_timeout"`
+	PublishedRetention time.Duration `json:"published_retention"` // 已投递事件保留时间
+}
+
+// DefaultRelayConfig 默认投递器配置
+func DefaultRelayConfig() *RelayConfig {
+	return &RelayConfig{
+		PollInterval:       time.Second,
+		BatchSize:          100,
+		MaxAttempts:        10,
+		BaseBackoff:        time.Second,
+		MaxBackoff:         5 * time.Minute,
+		PublishTimeout:     5 * time.Second,
+		PublishedRetention: 7 * 24 * time.Hour,
+	}
+}
+
+// Relay 发件箱投递器，至少一次投递
+type Relay struct {
+	db               *database.DB
+	publisher        Publisher
+	config           *RelayConfig
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewRelay 创建发件箱投递器
+func NewRelay(db *database.DB, publisher Publisher, config *RelayConfig) *Relay {
+	if config == nil {
+		config = DefaultRelayConfig()
+	}
+	return &Relay{
+		db:               db,
+		publisher:        publisher,
+		config:           config,
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// Run 循环投递直到 ctx 取消
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 批次已满说明可能还有积压，立即继续
+			for {
+				n, err := r.RelayBatch(ctx)
+				if err != nil {
+					log.Printf("Outbox relay failed: %v", err)
+					break
+				}
+				if n < r.config.BatchSize || ctx.Err() != nil {
+					break
+				}
+			}
+
+			if time.Since(lastCleanup) > time.Hour {
+				if _, err := r.Cleanup(ctx); err != nil {
+					log.Printf("Outbox cleanup failed: %v", err)
+				}
+				lastCleanup = time.Now()
+			}
+		}
+	}
+}
+
+// RelayBatch 锁定一批待投递事件并投递，返回处理的事件数。
+// 使用 FOR UPDATE SKIP LOCKED，多个实例可并行运行。
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	start := time.Now()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin outbox transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 每个 key 只取最早一条未投递的事件：更早的事件仍在退避或已 dead 时，该 key 的后续事件都不投递，
+	// dead 事件经 Retry 重新投递后才放行，以此保证同一 key 按写入顺序投递
+	var rows []eventRow
+	query := `
+		SELECT e.id, e.topic, e.event_key, e.dedupe_key, e.payload, e.headers, e.attempts, e.created_at, e.next_attempt_at
+		FROM outbox_events e
+		WHERE e.status = 'pending' AND e.next_attempt_at <= NOW()
+			AND (e.event_key = '' OR NOT EXISTS (
+				SELECT 1 FROM outbox_events p
+				WHERE p.event_key = e.event_key AND p.id < e.id AND p.status IN ('pending', 'dead')))
+		ORDER BY e.id
+		LIMIT $1
+		FOR UPDATE OF e SKIP LOCKED`
+	if err := tx.SelectContext(ctx, &rows, query, r.config.BatchSize); err != nil {
+		return 0, fmt.Errorf("failed to load outbox events: %w", err)
+	}
+
+	for i := range rows {
+		event := &rows[i].Event
+		if len(rows[i].Headers) > 0 {
+			if err := json.Unmarshal(rows[i].Headers, &event.Headers); err != nil {
+				log.Printf("Failed to unmarshal headers of outbox event %d: %v", event.ID, err)
+			}
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, r.config.PublishTimeout)
+		err := r.publisher.Publish(publishCtx, event)
+		cancel()
+
+		if err == nil {
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE outbox_events SET status = 'published', published_at = NOW(), attempts = attempts + 1 WHERE id = $1",
+				event.ID); err != nil {
+				return 0, fmt.Errorf("failed to mark outbox event %d published: %w", event.ID, err)
+			}
+			continue
+		}
+
+		if err := r.markFailed(ctx, tx, event, err); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox batch: %w", err)
+	}
+
+	r.recordMetrics("relay_batch", time.Since(start), true)
+	return len(rows), nil
+}
+
+// markFailed 记录失败并计算下次重试时间
+func (r *Relay) markFailed(ctx context.Context, tx *sqlx.Tx, event *Event, publishErr error) error {
+	attempts := event.Attempts + 1
+	status := StatusPending
+	if attempts >= r.config.MaxAttempts {
+		status = StatusDead
+		log.Printf("Outbox event %d (%s) moved to dead after %d attempts: %v", event.ID, event.Topic, attempts, publishErr)
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"UPDATE outbox_events SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4 WHERE id = $5",
+		status, attempts, publishErr.Error(), time.Now().Add(r.backoff(attempts)), event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event %d failed: %w", event.ID, err)
+	}
+
+	r.recordMetrics("publish_error", 0, false)
+	return nil
+}
+
+// backoff 指数退避
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= r.config.MaxBackoff {
+			return r.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Cleanup 删除超过保留期的已投递事件
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM outbox_events WHERE status = 'published' AND published_at < $1",
+		time.Now().Add(-r.config.PublishedRetention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup outbox events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Retry 将 dead 事件重新置为待投递
+func (r *Relay) Retry(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox_events SET status = 'pending', attempts = 0, next_attempt_at = NOW() WHERE id = $1 AND status = 'dead'", id)
+	if err != nil {
+		return fmt.Errorf("failed to retry outbox event %d: %w", id, err)
+	}
+	return nil
+}
+
+// recordMetrics 记录指标
+func (r *Relay) recordMetrics(operation string, duration time.Duration, success bool) {
+	r.metricsCollector.RecordDBQuery("outbox", operation, duration, success)
+	if !success {
+		r.metricsCollector.RecordDBError("outbox_error", operation)
+	}
+}
+
+// Deduper 消费端去重，至少一次投递下用于丢弃重复事件
+type Deduper struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewDeduper 创建消费端去重器
+func NewDeduper(client *redis.Client, ttl time.Duration) *Deduper {
+	return &Deduper{client: client, ttl: ttl}
+}
+
+// FirstSeen 首次见到该去重键时返回 true
+func (d *Deduper) FirstSeen(ctx context.Context, consumer, dedupeKey string) (bool, error) {
+	ok, err := d.client.SetNX(ctx, dedupeRedisKey(consumer, dedupeKey), 1, d.ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check dedupe key: %w", err)
+	}
+	return ok, nil
+}
+
+// Forget 删除去重键，处理失败时调用，使重新投递的事件不被丢弃
+func (d *Deduper) Forget(ctx context.Context, consumer, dedupeKey string) error {
+	if err := d.client.Del(ctx, dedupeRedisKey(consumer, dedupeKey)).Err(); err != nil {
+		return fmt.Errorf("failed to forget dedupe key: %w", err)
+	}
+	return nil
+}
+
+func dedupeRedisKey(consumer, dedupeKey string) string {
+	return fmt.Sprintf("outbox:dedupe:%s:%s", consumer, dedupeKey)
+}
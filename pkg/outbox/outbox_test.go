@@ -0,0 +1,222 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	selectPending = `SELECT e.id, e.topic, e.event_key, e.dedupe_key, e.payload, e.headers, e.attempts, e.created_at, e.next_attempt_at\s+FROM outbox_events e\s+WHERE e.status = 'pending'.*LIMIT \$1\s+FOR UPDATE OF e SKIP LOCKED`
+	markPublished = `UPDATE outbox_events SET status = 'published'`
+	markFailed    = `UPDATE outbox_events SET status = \$1, attempts = \$2`
+)
+
+var eventColumns = []string{"id", "topic", "event_key", "dedupe_key", "payload", "headers", "attempts", "created_at", "next_attempt_at"}
+
+func testRelayConfig() *RelayConfig {
+	config := DefaultRelayConfig()
+	config.BatchSize = 4
+	config.MaxAttempts = 3
+	return config
+}
+
+// TestRelayBatch 按批锁定待投递事件：成功的标记为已投递，失败的按退避重试，达到上限后标记为 dead；
+// headers 随事件一起读出
+func TestRelayBatch(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	now := time.Now()
+	var published []*Event
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, event *Event) error {
+		published = append(published, event)
+		if event.Topic == "fail" {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	}), testRelayConfig())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectPending).WithArgs(4).WillReturnRows(sqlmock.NewRows(eventColumns).
+		AddRow(1, "ok", "a", "d1", []byte(`{}`), []byte(`{"trace_id":"t1"}`), 0, now, now).
+		AddRow(2, "fail", "b", "d2", []byte(`{}`), nil, 0, now, now).
+		AddRow(4, "fail", "c", "d4", []byte(`{}`), nil, 2, now, now))
+	mock.ExpectExec(markPublished).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(markFailed).WithArgs(StatusPending, 1, "broker unavailable", sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(markFailed).WithArgs(StatusDead, 3, "broker unavailable", sqlmock.AnyArg(), 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	require.Len(t, published, 3)
+	assert.Equal(t, map[string]string{"trace_id": "t1"}, published[0].Headers)
+	assert.Nil(t, published[1].Headers)
+}
+
+// TestRelayBatch_KeyOrder 同一 key 只投递最早一条未投递的事件，更早的事件在退避中或已 dead 时后续事件不被选出
+func TestRelayBatch_KeyOrder(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, event *Event) error { return nil }), testRelayConfig())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`e.event_key = '' OR NOT EXISTS \(\s+SELECT 1 FROM outbox_events p\s+WHERE p.event_key = e.event_key AND p.id < e.id AND p.status IN \('pending', 'dead'\)\)`).
+		WithArgs(4).WillReturnRows(sqlmock.NewRows(eventColumns))
+	mock.ExpectCommit()
+
+	n, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+// TestRelayBatch_MarkFailure 标记失败时整批回滚，事件保持待投递，之后再次投递（至少一次）
+func TestRelayBatch_MarkFailure(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	now := time.Now()
+	relay := NewRelay(db, PublisherFunc(func(ctx context.Context, event *Event) error { return nil }), testRelayConfig())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectPending).WithArgs(4).WillReturnRows(sqlmock.NewRows(eventColumns).
+		AddRow(1, "ok", "a", "d1", []byte(`{}`), nil, 0, now, now))
+	mock.ExpectExec(markPublished).WithArgs(1).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err := relay.RelayBatch(context.Background())
+	assert.Error(t, err)
+}
+
+// TestRelay_Backoff 退避按次数翻倍，不超过上限
+func TestRelay_Backoff(t *testing.T) {
+	relay := NewRelay(nil, nil, &RelayConfig{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	assert.Equal(t, time.Second, relay.backoff(1))
+	assert.Equal(t, 4*time.Second, relay.backoff(3))
+	assert.Equal(t, 5*time.Second, relay.backoff(10))
+}
+
+// TestTopicRouter 按主题分发，未登记的主题返回错误
+func TestTopicRouter(t *testing.T) {
+	var got string
+	router := TopicRouter{"a": PublisherFunc(func(ctx context.Context, event *Event) error {
+		got = event.DedupeKey
+		return nil
+	})}
+
+	require.NoError(t, router.Publish(context.Background(), &Event{Topic: "a", DedupeKey: "d1"}))
+	assert.Equal(t, "d1", got)
+	assert.Error(t, router.Publish(context.Background(), &Event{Topic: "b"}))
+}
+
+// TestCacheInvalidationPublisher 删除事件中的键并失效标签，重复投递结果相同
+func TestCacheInvalidationPublisher(t *testing.T) {
+	ctx := context.Background()
+	store := fakes.NewCache(nil)
+	require.NoError(t, store.Set(ctx, "graphql:coupon:c1", "cached", 0))
+	require.NoError(t, store.Set(ctx, "graphql:coupon:c2", "cached", 0))
+	tags := &tagRecorder{}
+	event, err := NewCacheInvalidationEvent("coupon:c1", "", CacheInvalidation{Keys: []string{"graphql:coupon:c1"}, Tags: []string{"coupons"}})
+	require.NoError(t, err)
+	assert.Equal(t, TopicCacheInvalidate, event.Topic)
+
+	publisher := CacheInvalidationPublisher(store, tags)
+	require.NoError(t, publisher.Publish(ctx, event))
+	require.NoError(t, publisher.Publish(ctx, event))
+	assert.Equal(t, []string{"graphql:coupon:c2"}, store.Keys())
+	assert.Equal(t, []string{"coupons", "coupons"}, tags.tags)
+
+	assert.Error(t, publisher.Publish(ctx, &Event{Topic: TopicCacheInvalidate, Payload: []byte(`[`)}))
+}
+
+type tagRecorder struct {
+	tags []string
+}
+
+func (r *tagRecorder) InvalidateTags(ctx context.Context, tags ...string) error {
+	r.tags = append(r.tags, tags...)
+	return nil
+}
+
+// TestDeduper 同一消费方的去重键只有第一次返回 true，Forget 后可再次处理；不同消费方互不影响
+func TestDeduper(t *testing.T) {
+	ctx := context.Background()
+	stub := &setNXStub{keys: make(map[string]time.Duration)}
+	client := redis.NewClient(&redis.Options{Addr: "stub:6379"})
+	client.AddHook(stub)
+	t.Cleanup(func() { client.Close() })
+	deduper := NewDeduper(client, time.Hour)
+
+	first, err := deduper.FirstSeen(ctx, "activity", "d1")
+	require.NoError(t, err)
+	assert.True(t, first)
+	first, err = deduper.FirstSeen(ctx, "activity", "d1")
+	require.NoError(t, err)
+	assert.False(t, first)
+	first, err = deduper.FirstSeen(ctx, "search", "d1")
+	require.NoError(t, err)
+	assert.True(t, first)
+	assert.Equal(t, time.Hour, stub.keys["outbox:dedupe:activity:d1"])
+
+	require.NoError(t, deduper.Forget(ctx, "activity", "d1"))
+	first, err = deduper.FirstSeen(ctx, "activity", "d1")
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	stub.down = true
+	_, err = deduper.FirstSeen(ctx, "activity", "d2")
+	assert.Error(t, err)
+}
+
+// setNXStub 以 Hook 应答 SET NX 与 DEL，记录键的有效期
+type setNXStub struct {
+	keys map[string]time.Duration
+	down bool
+}
+
+func (s *setNXStub) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, net.ErrClosed
+	}
+}
+
+func (s *setNXStub) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if s.down {
+			return net.ErrClosed
+		}
+		args := cmd.Args()
+		key, _ := args[1].(string)
+		switch strings.ToLower(cmd.Name()) {
+		case "set":
+			if _, ok := s.keys[key]; ok {
+				cmd.(*redis.BoolCmd).SetVal(false)
+				return nil
+			}
+			for i, arg := range args {
+				if arg == "ex" {
+					s.keys[key] = time.Duration(args[i+1].(int64)) * time.Second
+				}
+			}
+			cmd.(*redis.BoolCmd).SetVal(true)
+			return nil
+		case "del":
+			delete(s.keys, key)
+			cmd.(*redis.IntCmd).SetVal(1)
+			return nil
+		}
+		return net.ErrClosed
+	}
+}
+
+func (s *setNXStub) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return net.ErrClosed
+	}
+}
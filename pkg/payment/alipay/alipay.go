@@ -0,0 +1,195 @@
+package alipay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/payment"
+
+	alipaysdk "github.com/smartwalle/alipay/v3"
+)
+
+// Channel 渠道名
+const Channel = "alipay"
+
+// 交易不存在（用户未扫码或未登录支付宝）
+const subCodeTradeNotExist = "ACQ.TRADE_NOT_EXIST"
+
+// alipayTimeLayout 支付宝接口的时间格式（北京时间）
+const alipayTimeLayout = "2006-01-02 15:04:05"
+
+var beijing = time.FixedZone("CST", 8*3600)
+
+// Provider 支付宝 App 支付驱动
+type Provider struct {
+	client *alipaysdk.Client
+	config config.AlipayConfig
+}
+
+// NewProvider 创建支付宝驱动
+func NewProvider(cfg config.AlipayConfig) (*Provider, error) {
+	if cfg.AppID == "" {
+		return nil, errors.New("alipay config missing")
+	}
+
+	client, err := alipaysdk.New(cfg.AppID, cfg.PrivateKey, cfg.IsProduction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alipay client: %w", err)
+	}
+	// 加载支付宝公钥（用于验证回调签名）
+	if err := client.LoadAliPayPublicKey(cfg.PublicKey); err != nil {
+		return nil, fmt.Errorf("failed to load alipay public key: %w", err)
+	}
+	return &Provider{client: client, config: cfg}, nil
+}
+
+// Name 渠道名
+func (p *Provider) Name() string {
+	return Channel
+}
+
+// CreatePayment 生成 App 支付参数串
+func (p *Provider) CreatePayment(ctx context.Context, req *payment.PaymentRequest) (*payment.PaymentResult, error) {
+	param := alipaysdk.TradeAppPay{}
+	param.NotifyURL = p.config.NotifyURL
+	param.Subject = req.Subject
+	param.OutTradeNo = req.OrderNo
+	param.TotalAmount = payment.FormatYuan(req.Amount)
+	param.ProductCode = "QUICK_MSECURITY_PAY"
+	if !req.ExpireAt.IsZero() {
+		param.TimeExpire = req.ExpireAt.In(beijing).Format(alipayTimeLayout)
+	}
+
+	orderString, err := p.client.TradeAppPay(param)
+	if err != nil {
+		return nil, err
+	}
+	return &payment.PaymentResult{
+		Params: map[string]string{"order_string": orderString},
+	}, nil
+}
+
+// Query 查询交易状态
+func (p *Provider) Query(ctx context.Context, orderNo string) (*payment.QueryResult, error) {
+	rsp, err := p.client.TradeQuery(alipaysdk.TradeQuery{OutTradeNo: orderNo})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.IsFailure() {
+		if rsp.SubCode == subCodeTradeNotExist {
+			return &payment.QueryResult{OrderNo: orderNo, Status: payment.StatusPending}, nil
+		}
+		return nil, rsp.Error
+	}
+
+	amount, err := payment.ParseYuan(rsp.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+	result := &payment.QueryResult{
+		OrderNo: orderNo,
+		TradeNo: rsp.TradeNo,
+		Status:  tradeStatus(rsp.TradeStatus),
+		Amount:  amount,
+	}
+	if paidAt, err := time.ParseInLocation(alipayTimeLayout, rsp.SendPayDate, beijing); err == nil {
+		result.PaidAt = &paidAt
+	}
+	return result, nil
+}
+
+// Close 关闭交易，交易尚未在支付宝创建时视为成功
+func (p *Provider) Close(ctx context.Context, orderNo string) error {
+	rsp, err := p.client.TradeClose(alipaysdk.TradeClose{OutTradeNo: orderNo})
+	if err != nil {
+		return err
+	}
+	if rsp.IsFailure() && rsp.SubCode != subCodeTradeNotExist {
+		return rsp.Error
+	}
+	return nil
+}
+
+// Refund 退款，支付宝同步返回结果；OutRequestNo 相同的重试请求不会重复退款
+func (p *Provider) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResult, error) {
+	rsp, err := p.client.TradeRefund(alipaysdk.TradeRefund{
+		OutTradeNo:   req.OrderNo,
+		RefundAmount: payment.FormatYuan(req.Amount),
+		RefundReason: req.Reason,
+		OutRequestNo: req.RefundNo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rsp.IsFailure() {
+		return &payment.RefundResult{RefundNo: req.RefundNo, Status: payment.RefundFailed}, nil
+	}
+	return &payment.RefundResult{RefundNo: req.RefundNo, Status: payment.RefundSucceeded}, nil
+}
+
+// ParseWebhook 验签并解析异步通知
+func (p *Provider) ParseWebhook(ctx context.Context, r *http.Request) (*payment.WebhookEvent, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("failed to parse notification: %w", err)
+	}
+	notification, err := p.client.DecodeNotification(r.PostForm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", payment.ErrInvalidSignature, err)
+	}
+	if notification.AppId != p.config.AppID {
+		return nil, fmt.Errorf("%w: unexpected app id %s", payment.ErrInvalidSignature, notification.AppId)
+	}
+
+	event := &payment.WebhookEvent{
+		Provider:   Channel,
+		OrderNo:    notification.OutTradeNo,
+		TradeNo:    notification.TradeNo,
+		OccurredAt: time.Now(),
+		Payload:    []byte(r.PostForm.Encode()),
+	}
+	switch {
+	case notification.OutBizNo != "" && notification.GmtRefund != "":
+		// 退款通知中的 refund_fee 为累计退款金额，按在途退款金额入账
+		event.ID, event.Type = payment.RefundEventID(notification.OutBizNo), payment.EventRefunded
+	case notification.TradeStatus == alipaysdk.TradeStatusSuccess || notification.TradeStatus == alipaysdk.TradeStatusFinished:
+		amount, err := payment.ParseYuan(notification.TotalAmount)
+		if err != nil {
+			return nil, err
+		}
+		event.ID, event.Type, event.Amount = payment.PaidEventID(notification.OutTradeNo), payment.EventPaid, amount
+		if paidAt, err := time.ParseInLocation(alipayTimeLayout, notification.GmtPayment, beijing); err == nil {
+			event.OccurredAt = paidAt
+		}
+	case notification.TradeStatus == alipaysdk.TradeStatusClosed:
+		event.ID, event.Type = payment.ClosedEventID(notification.OutTradeNo), payment.EventClosed
+	default:
+		return nil, payment.ErrIgnoredEvent
+	}
+	return event, nil
+}
+
+// AckWebhook 应答 success，否则支付宝会按策略重发通知
+func (p *Provider) AckWebhook(w http.ResponseWriter, err error) {
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("fail"))
+		return
+	}
+	alipaysdk.ACKNotification(w)
+}
+
+func tradeStatus(status alipaysdk.TradeStatus) payment.Status {
+	switch status {
+	case alipaysdk.TradeStatusSuccess, alipaysdk.TradeStatusFinished:
+		return payment.StatusPaid
+	case alipaysdk.TradeStatusClosed:
+		return payment.StatusCancelled
+	default:
+		return payment.StatusPending
+	}
+}
+
+var _ payment.Provider = (*Provider)(nil)
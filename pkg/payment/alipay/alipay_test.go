@@ -0,0 +1,127 @@
+package alipay
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/payment"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestProvider 以临时生成的密钥创建驱动，返回模拟支付宝签名使用的私钥
+func newTestProvider(t *testing.T) (*Provider, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	provider, err := NewProvider(config.AlipayConfig{
+		AppID:      "2021000000000001",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+	})
+	require.NoError(t, err)
+	return provider, key
+}
+
+// signedNotification 按支付宝规则签名：除 sign、sign_type 外的参数排序后以 & 连接，RSA2 签名
+func signedNotification(t *testing.T, key *rsa.PrivateKey, values url.Values) *http.Request {
+	t.Helper()
+	var pairs []string
+	for k, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+	digest := sha256.Sum256([]byte(strings.Join(pairs, "&")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	form := url.Values{}
+	for k, vs := range values {
+		form[k] = vs
+	}
+	form.Set("sign", base64.StdEncoding.EncodeToString(signature))
+	form.Set("sign_type", "RSA2")
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook/alipay", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func tradeValues(appID, status string) url.Values {
+	return url.Values{
+		"app_id":       {appID},
+		"notify_id":    {"n1"},
+		"out_trade_no": {"NO1"},
+		"trade_no":     {"T1"},
+		"trade_status": {status},
+		"total_amount": {"9.90"},
+		"gmt_payment":  {"2024-01-01 08:00:00"},
+	}
+}
+
+// TestParseWebhook_Paid 验签通过的支付成功通知解析为支付事件，金额转为分
+func TestParseWebhook_Paid(t *testing.T) {
+	provider, key := newTestProvider(t)
+
+	event, err := provider.ParseWebhook(context.Background(), signedNotification(t, key, tradeValues(provider.config.AppID, "TRADE_SUCCESS")))
+	require.NoError(t, err)
+	assert.Equal(t, payment.PaidEventID("NO1"), event.ID)
+	assert.Equal(t, payment.EventPaid, event.Type)
+	assert.Equal(t, "T1", event.TradeNo)
+	assert.Equal(t, int64(990), event.Amount)
+	assert.Equal(t, "2024-01-01T00:00:00Z", event.OccurredAt.UTC().Format("2006-01-02T15:04:05Z"))
+
+	_, err = provider.ParseWebhook(context.Background(), signedNotification(t, key, tradeValues(provider.config.AppID, "WAIT_BUYER_PAY")))
+	assert.ErrorIs(t, err, payment.ErrIgnoredEvent)
+}
+
+// TestParseWebhook_InvalidSignature 篡改参数、其他密钥签名或其他应用的通知都按签名错误拒绝
+func TestParseWebhook_InvalidSignature(t *testing.T) {
+	provider, key := newTestProvider(t)
+
+	req := signedNotification(t, key, tradeValues(provider.config.AppID, "TRADE_SUCCESS"))
+	require.NoError(t, req.ParseForm())
+	req.PostForm.Set("total_amount", "0.01")
+	tampered := httptest.NewRequest(http.MethodPost, "/payments/webhook/alipay", strings.NewReader(req.PostForm.Encode()))
+	tampered.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err := provider.ParseWebhook(context.Background(), tampered)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = provider.ParseWebhook(context.Background(), signedNotification(t, other, tradeValues(provider.config.AppID, "TRADE_SUCCESS")))
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+
+	_, err = provider.ParseWebhook(context.Background(), signedNotification(t, key, tradeValues("2021000000000002", "TRADE_SUCCESS")))
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
+
+// TestAckWebhook 成功应答 success，失败应答 fail 以便支付宝重发
+func TestAckWebhook(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	w := httptest.NewRecorder()
+	provider.AckWebhook(w, nil)
+	assert.Equal(t, "success", w.Body.String())
+
+	w = httptest.NewRecorder()
+	provider.AckWebhook(w, payment.ErrInvalidSignature)
+	assert.Equal(t, "fail", w.Body.String())
+}
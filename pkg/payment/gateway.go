@@ -0,0 +1,443 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatusHook 订单状态变化回调，在状态写入后调用
+type StatusHook func(ctx context.Context, order *Order, from Status)
+
+// CreateOrderRequest 创建订单参数，金额单位为分
+type CreateOrderRequest struct {
+	UserID   string
+	Channel  string
+	Subject  string
+	Amount   int64
+	ClientIP string
+}
+
+// Gateway 支付网关：统一管理各支付渠道，维护订单状态机并保证回调幂等
+type Gateway struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+	hooks     []StatusHook
+	store     Store
+	// orderTTL 未支付订单的有效期，传给渠道作为交易超时时间
+	orderTTL time.Duration
+}
+
+// NewGateway 创建支付网关
+func NewGateway(store Store) *Gateway {
+	return &Gateway{
+		providers: make(map[string]Provider),
+		store:     store,
+		orderTTL:  30 * time.Minute,
+	}
+}
+
+// Register 注册支付渠道
+func (g *Gateway) Register(provider Provider) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.providers[provider.Name()] = provider
+}
+
+// Provider 获取支付渠道
+func (g *Gateway) Provider(name string) (Provider, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	provider, ok := g.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Providers 已注册的支付渠道名称
+func (g *Gateway) Providers() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	names := make([]string, 0, len(g.providers))
+	for name := range g.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OnStatusChange 注册状态变化回调，如支付成功后发放权益
+func (g *Gateway) OnStatusChange(hook StatusHook) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hooks = append(g.hooks, hook)
+}
+
+// Get 查询订单
+func (g *Gateway) Get(ctx context.Context, orderNo string) (*Order, error) {
+	return g.store.Get(ctx, orderNo)
+}
+
+// CreateOrder 创建订单并在渠道侧下单，渠道下单失败时订单标记为 failed
+func (g *Gateway) CreateOrder(ctx context.Context, req *CreateOrderRequest) (*Order, *PaymentResult, error) {
+	if req.Amount <= 0 {
+		return nil, nil, fmt.Errorf("invalid payment amount %d", req.Amount)
+	}
+	provider, err := g.Provider(req.Channel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := &Order{
+		OrderNo: time.Now().Format("20060102150405") + uuid.New().String()[:8],
+		UserID:  req.UserID,
+		Amount:  req.Amount,
+		Status:  StatusPending,
+		Channel: req.Channel,
+		Subject: req.Subject,
+	}
+	if err := g.store.Create(ctx, order); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := provider.CreatePayment(ctx, &PaymentRequest{
+		OrderNo:  order.OrderNo,
+		Amount:   order.Amount,
+		Subject:  order.Subject,
+		ClientIP: req.ClientIP,
+		ExpireAt: time.Now().Add(g.orderTTL),
+	})
+	if err != nil {
+		if _, updateErr := g.update(ctx, order.OrderNo, nil, func(o *Order) error {
+			_, err := o.transition(StatusFailed)
+			return err
+		}); updateErr != nil {
+			log.Printf("Failed to mark order %s as failed: %v", order.OrderNo, updateErr)
+		}
+		return nil, nil, fmt.Errorf("failed to create %s payment: %w", req.Channel, err)
+	}
+	return order, result, nil
+}
+
+// Cancel 关闭未支付的订单
+func (g *Gateway) Cancel(ctx context.Context, orderNo string) (*Order, error) {
+	order, err := g.store.Get(ctx, orderNo)
+	if err != nil {
+		return nil, err
+	}
+	if !CanTransition(order.Status, StatusCancelled) {
+		return nil, &InvalidTransitionError{From: order.Status, To: StatusCancelled}
+	}
+	provider, err := g.Provider(order.Channel)
+	if err != nil {
+		return nil, err
+	}
+	// 先关闭渠道侧交易，避免取消后用户仍能完成支付
+	if err := provider.Close(ctx, orderNo); err != nil {
+		return nil, fmt.Errorf("failed to close %s trade: %w", order.Channel, err)
+	}
+	return g.update(ctx, orderNo, nil, func(o *Order) error {
+		_, err := o.transition(StatusCancelled)
+		return err
+	})
+}
+
+// Refund 发起退款，amount 为 0 时退还全部可退金额。同一订单同时只允许一笔退款在途；
+// 只有渠道明确拒绝时才恢复为已支付
+func (g *Gateway) Refund(ctx context.Context, orderNo string, amount int64, reason string) (*Order, error) {
+	var refundNo string
+	order, err := g.update(ctx, orderNo, nil, func(o *Order) error {
+		if amount == 0 {
+			amount = o.Refundable()
+		}
+		if amount <= 0 || amount > o.Refundable() {
+			return ErrRefundExceeded
+		}
+		if o.Status == StatusRefunding {
+			// 在途退款结果未确定前不能覆盖其退款单号与金额
+			return &InvalidTransitionError{From: o.Status, To: StatusRefunding}
+		}
+		if _, err := o.transition(StatusRefunding); err != nil {
+			return err
+		}
+		o.RefundingAmount = amount
+		o.RefundNo = o.OrderNo + "R" + uuid.New().String()[:8]
+		refundNo = o.RefundNo
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := g.Provider(order.Channel)
+	if err != nil {
+		return nil, err
+	}
+	result, err := provider.Refund(ctx, &RefundRequest{
+		OrderNo:  orderNo,
+		RefundNo: refundNo,
+		Total:    order.Amount,
+		Amount:   amount,
+		Reason:   reason,
+	})
+	if err != nil {
+		// 超时、网络错误时渠道可能已受理退款，保持退款中，由 Run 主动查询或退款回调确定结果
+		return nil, fmt.Errorf("refund %s awaiting %s confirmation: %w", refundNo, order.Channel, err)
+	}
+	if result.Status == RefundFailed {
+		if _, revertErr := g.update(ctx, orderNo, nil, revertRefund); revertErr != nil {
+			log.Printf("Failed to revert refund of order %s: %v", orderNo, revertErr)
+		}
+		return nil, fmt.Errorf("refund %s rejected by %s", refundNo, order.Channel)
+	}
+	if result.Status == RefundProcessing {
+		return order, nil
+	}
+
+	// 同步退款成功，与退款回调使用相同事件 ID，避免重复入账
+	order, err = g.apply(ctx, &WebhookEvent{
+		ID:         RefundEventID(refundNo),
+		Provider:   order.Channel,
+		Type:       EventRefunded,
+		OrderNo:    orderNo,
+		Amount:     amount,
+		OccurredAt: time.Now(),
+	})
+	if errors.Is(err, ErrDuplicateEvent) {
+		return g.store.Get(ctx, orderNo)
+	}
+	return order, err
+}
+
+// HandleWebhook 验签并处理渠道回调，重复投递的事件直接应答成功
+func (g *Gateway) HandleWebhook(ctx context.Context, providerName string, w http.ResponseWriter, r *http.Request) error {
+	provider, err := g.Provider(providerName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return err
+	}
+
+	event, err := provider.ParseWebhook(ctx, r)
+	if errors.Is(err, ErrIgnoredEvent) {
+		provider.AckWebhook(w, nil)
+		return nil
+	}
+	if err != nil {
+		provider.AckWebhook(w, err)
+		return err
+	}
+	event.Provider = providerName
+
+	_, err = g.apply(ctx, event)
+	if errors.Is(err, ErrDuplicateEvent) {
+		err = nil
+	}
+	provider.AckWebhook(w, err)
+	return err
+}
+
+// Sync 主动查询渠道侧状态并同步到订单，用于回调丢失或延迟的情况
+func (g *Gateway) Sync(ctx context.Context, orderNo string) (*Order, error) {
+	order, err := g.store.Get(ctx, orderNo)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != StatusPending && order.Status != StatusFailed && order.Status != StatusRefunding {
+		return order, nil
+	}
+	provider, err := g.Provider(order.Channel)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := provider.Query(ctx, orderNo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s trade: %w", order.Channel, err)
+	}
+
+	event := &WebhookEvent{
+		Provider:   order.Channel,
+		OrderNo:    orderNo,
+		TradeNo:    remote.TradeNo,
+		OccurredAt: time.Now(),
+	}
+	switch {
+	case order.Status == StatusRefunding:
+		// 仅在渠道返回累计退款金额时可判断在途退款是否完成，否则等待退款回调
+		if remote.RefundedAmount < order.RefundedAmount+order.RefundingAmount {
+			return order, nil
+		}
+		event.ID, event.Type, event.Amount = RefundEventID(order.RefundNo), EventRefunded, order.RefundingAmount
+	case remote.Status == StatusPaid || remote.Status == StatusRefunded:
+		event.ID, event.Type, event.Amount = PaidEventID(orderNo), EventPaid, remote.Amount
+		if remote.PaidAt != nil {
+			event.OccurredAt = *remote.PaidAt
+		}
+	case remote.Status == StatusCancelled:
+		event.ID, event.Type = ClosedEventID(orderNo), EventClosed
+	default:
+		return order, nil
+	}
+
+	updated, err := g.apply(ctx, event)
+	if errors.Is(err, ErrDuplicateEvent) {
+		return g.store.Get(ctx, orderNo)
+	}
+	return updated, err
+}
+
+// Run 定期同步长时间未收到回调的订单
+func (g *Gateway) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, status := range []Status{StatusPending, StatusRefunding} {
+				orderNos, err := g.store.ListByStatus(ctx, status, time.Now().Add(-interval), 100)
+				if err != nil {
+					log.Printf("Failed to list %s orders: %v", status, err)
+					continue
+				}
+				for _, orderNo := range orderNos {
+					if _, err := g.Sync(ctx, orderNo); err != nil {
+						log.Printf("Failed to sync order %s: %v", orderNo, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// apply 在记录事件的同一事务中按事件推进订单状态
+func (g *Gateway) apply(ctx context.Context, event *WebhookEvent) (*Order, error) {
+	return g.update(ctx, event.OrderNo, event, func(o *Order) error {
+		switch event.Type {
+		case EventPaid:
+			// 支付成功后的迟到事件（如退款后重放）不再处理
+			if o.Status == StatusPaid || o.Status == StatusRefunding || o.Status == StatusRefunded {
+				return nil
+			}
+			// 已取消的订单不再发放权益：保持取消状态，记录渠道交易号与付款时间，事件改记为 paid_after_cancel
+			// 待退款（status = cancelled 且 paid_at 非空）。正常应答，避免渠道无限重发
+			if o.Status == StatusCancelled {
+				event.Type = EventPaidAfterCancel
+				paidAt := event.OccurredAt
+				o.PaidAt = &paidAt
+				o.TradeNo = event.TradeNo
+				log.Printf("Order %s paid after cancellation (trade %s, amount %d), refund required", o.OrderNo, event.TradeNo, event.Amount)
+				return nil
+			}
+			if event.Amount != o.Amount {
+				return fmt.Errorf("%w: order %d, paid %d", ErrAmountMismatch, o.Amount, event.Amount)
+			}
+			if _, err := o.transition(StatusPaid); err != nil {
+				return err
+			}
+			paidAt := event.OccurredAt
+			o.PaidAt = &paidAt
+			o.TradeNo = event.TradeNo
+		case EventClosed:
+			// 全额退款后渠道也会发送关闭事件，已支付的订单忽略
+			if o.Status == StatusPending || o.Status == StatusFailed {
+				_, err := o.transition(StatusCancelled)
+				return err
+			}
+		case EventPaymentFailed:
+			if o.Status == StatusPending {
+				_, err := o.transition(StatusFailed)
+				return err
+			}
+		case EventRefunded:
+			if o.Status != StatusPaid && o.Status != StatusRefunding {
+				return nil
+			}
+			amount := event.Amount
+			if amount == 0 {
+				amount = o.RefundingAmount
+			}
+			o.RefundedAmount += amount
+			if o.RefundedAmount > o.Amount {
+				o.RefundedAmount = o.Amount
+			}
+			o.RefundingAmount -= amount
+			if o.RefundingAmount < 0 {
+				o.RefundingAmount = 0
+			}
+			next := StatusPaid
+			if o.RefundedAmount >= o.Amount {
+				next = StatusRefunded
+			} else if o.RefundingAmount > 0 {
+				next = StatusRefunding
+			}
+			_, err := o.transition(next)
+			return err
+		case EventRefundFailed:
+			if o.Status == StatusRefunding && event.ID == RefundFailedEventID(o.RefundNo) {
+				return revertRefund(o)
+			}
+		default:
+			return fmt.Errorf("unknown payment event type %q", event.Type)
+		}
+		return nil
+	})
+}
+
+// update 修改订单并在状态变化时触发回调
+func (g *Gateway) update(ctx context.Context, orderNo string, event *WebhookEvent, fn func(o *Order) error) (*Order, error) {
+	var from Status
+	order, err := g.store.Update(ctx, orderNo, event, func(o *Order) error {
+		from = o.Status
+		return fn(o)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Status != from {
+		g.mu.RLock()
+		hooks := g.hooks
+		g.mu.RUnlock()
+		for _, hook := range hooks {
+			hook(ctx, order, from)
+		}
+	}
+	return order, nil
+}
+
+// revertRefund 退款失败时恢复为已支付
+func revertRefund(o *Order) error {
+	o.RefundingAmount = 0
+	_, err := o.transition(StatusPaid)
+	return err
+}
+
+// PaidEventID 支付成功事件 ID，各渠道驱动统一使用，保证回调与主动查询不会重复处理
+func PaidEventID(orderNo string) string {
+	return "paid:" + orderNo
+}
+
+// ClosedEventID 交易关闭事件 ID
+func ClosedEventID(orderNo string) string {
+	return "closed:" + orderNo
+}
+
+// RefundEventID 退款完成事件 ID
+func RefundEventID(refundNo string) string {
+	return "refund:" + refundNo
+}
+
+// RefundFailedEventID 退款失败事件 ID
+func RefundFailedEventID(refundNo string) string {
+	return "refund_failed:" + refundNo
+}
@@ -0,0 +1,225 @@
+package payment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGateway 以内存存储与模拟渠道创建网关，返回挂载了回调路由的路由器
+func newTestGateway(t *testing.T) (*Gateway, *MockProvider, *gin.Engine) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gateway := NewGateway(NewMemoryStore())
+	provider := NewMockProvider(nil)
+	gateway.Register(provider)
+	router := gin.New()
+	NewHandler(gateway).RegisterWebhookRoutes(router.Group(""))
+	return gateway, provider, router
+}
+
+func deliver(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestGateway_WebhookIdempotent 支付回调推进订单并触发一次状态回调，重复投递直接应答成功且不再触发
+func TestGateway_WebhookIdempotent(t *testing.T) {
+	ctx := context.Background()
+	gateway, provider, router := newTestGateway(t)
+	var changes []Status
+	gateway.OnStatusChange(func(ctx context.Context, order *Order, from Status) {
+		changes = append(changes, order.Status)
+	})
+
+	order, result, err := gateway.CreateOrder(ctx, &CreateOrderRequest{UserID: "u1", Channel: ChannelMock, Subject: "VIP", Amount: 990})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, order.Status)
+	assert.NotEmpty(t, result.PayURL)
+
+	req, err := provider.SimulatePayment(order.OrderNo)
+	require.NoError(t, err)
+	w := deliver(router, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	replay, err := provider.WebhookRequest(PaidEventID(order.OrderNo), EventPaid, order.OrderNo, "T1", 990)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, deliver(router, replay).Code)
+
+	order, err = gateway.Get(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
+	assert.NotNil(t, order.PaidAt)
+	assert.Equal(t, []Status{StatusPaid}, changes)
+}
+
+// TestGateway_WebhookRejectsBadSignature 签名错误或金额不符的回调返回非 2xx，订单不变
+func TestGateway_WebhookRejectsBadSignature(t *testing.T) {
+	ctx := context.Background()
+	gateway, provider, router := newTestGateway(t)
+	order, _, err := gateway.CreateOrder(ctx, &CreateOrderRequest{UserID: "u1", Channel: ChannelMock, Subject: "VIP", Amount: 990})
+	require.NoError(t, err)
+
+	forged, err := NewMockProvider(&MockConfig{Secret: "other", WebhookTolerance: DefaultMockConfig().WebhookTolerance}).
+		WebhookRequest(PaidEventID(order.OrderNo), EventPaid, order.OrderNo, "T1", 990)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, deliver(router, forged).Code)
+
+	underpaid, err := provider.WebhookRequest(PaidEventID(order.OrderNo), EventPaid, order.OrderNo, "T1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, deliver(router, underpaid).Code)
+
+	order, err = gateway.Get(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, order.Status)
+
+	w := deliver(router, httptest.NewRequest(http.MethodPost, "/payments/webhook/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestGateway_PaidAfterCancel 取消后到达的支付回调应答成功，订单保持取消并记录交易号待退款，不触发状态回调
+func TestGateway_PaidAfterCancel(t *testing.T) {
+	ctx := context.Background()
+	gateway, provider, router := newTestGateway(t)
+	order, _, err := gateway.CreateOrder(ctx, &CreateOrderRequest{UserID: "u1", Channel: ChannelMock, Subject: "VIP", Amount: 990})
+	require.NoError(t, err)
+	_, err = gateway.Cancel(ctx, order.OrderNo)
+	require.NoError(t, err)
+
+	var changes int
+	gateway.OnStatusChange(func(ctx context.Context, order *Order, from Status) { changes++ })
+
+	for i := 0; i < 2; i++ {
+		req, err := provider.WebhookRequest(PaidEventID(order.OrderNo), EventPaid, order.OrderNo, "T1", 990)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, deliver(router, req).Code)
+	}
+
+	order, err = gateway.Get(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, order.Status)
+	assert.NotNil(t, order.PaidAt)
+	assert.Equal(t, "T1", order.TradeNo)
+	assert.Zero(t, changes)
+
+	_, err = gateway.Refund(ctx, order.OrderNo, 0, "")
+	var transitionErr *InvalidTransitionError
+	assert.ErrorAs(t, err, &transitionErr, "refunds of cancelled orders are handled manually")
+}
+
+// TestGateway_Refund 部分退款后仍为已支付，退完全部金额后为已退款，超出可退金额被拒绝
+func TestGateway_Refund(t *testing.T) {
+	ctx := context.Background()
+	gateway, provider, router := newTestGateway(t)
+	order, _, err := gateway.CreateOrder(ctx, &CreateOrderRequest{UserID: "u1", Channel: ChannelMock, Subject: "VIP", Amount: 1000})
+	require.NoError(t, err)
+	req, err := provider.SimulatePayment(order.OrderNo)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, deliver(router, req).Code)
+
+	order, err = gateway.Refund(ctx, order.OrderNo, 400, "partial")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
+	assert.Equal(t, int64(400), order.RefundedAmount)
+	assert.Equal(t, int64(600), order.Refundable())
+
+	_, err = gateway.Refund(ctx, order.OrderNo, 700, "")
+	assert.ErrorIs(t, err, ErrRefundExceeded)
+
+	order, err = gateway.Refund(ctx, order.OrderNo, 0, "rest")
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunded, order.Status)
+	assert.Equal(t, int64(1000), order.RefundedAmount)
+	assert.Zero(t, order.RefundingAmount)
+
+	// 全额退款后渠道发来的关闭事件被忽略
+	closed, err := provider.WebhookRequest(ClosedEventID(order.OrderNo), EventClosed, order.OrderNo, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, deliver(router, closed).Code)
+	order, err = gateway.Get(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunded, order.Status)
+}
+
+// TestGateway_Sync 回调丢失时主动查询渠道补记支付，与随后到达的回调不重复处理
+func TestGateway_Sync(t *testing.T) {
+	ctx := context.Background()
+	gateway, provider, router := newTestGateway(t)
+	order, _, err := gateway.CreateOrder(ctx, &CreateOrderRequest{UserID: "u1", Channel: ChannelMock, Subject: "VIP", Amount: 990})
+	require.NoError(t, err)
+
+	order, err = gateway.Sync(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, order.Status)
+
+	late, err := provider.SimulatePayment(order.OrderNo)
+	require.NoError(t, err)
+	order, err = gateway.Sync(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
+
+	assert.Equal(t, http.StatusOK, deliver(router, late).Code)
+}
+
+// flakyRefundProvider 退款请求实际送达渠道，但可以模拟应答丢失或渠道明确拒绝
+type flakyRefundProvider struct {
+	*MockProvider
+	lost     bool
+	rejected bool
+}
+
+func (p *flakyRefundProvider) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	if p.rejected {
+		return &RefundResult{RefundNo: req.RefundNo, Status: RefundFailed}, nil
+	}
+	result, err := p.MockProvider.Refund(ctx, req)
+	if p.lost {
+		return nil, context.DeadlineExceeded
+	}
+	return result, err
+}
+
+// TestGateway_RefundOutcomeUnknown 退款应答丢失时保持退款中，由主动查询补记；渠道明确拒绝时恢复为已支付
+func TestGateway_RefundOutcomeUnknown(t *testing.T) {
+	ctx := context.Background()
+	gateway, mock, router := newTestGateway(t)
+	provider := &flakyRefundProvider{MockProvider: mock}
+	gateway.Register(provider)
+	order, _, err := gateway.CreateOrder(ctx, &CreateOrderRequest{UserID: "u1", Channel: ChannelMock, Subject: "VIP", Amount: 1000})
+	require.NoError(t, err)
+	req, err := mock.SimulatePayment(order.OrderNo)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, deliver(router, req).Code)
+
+	provider.rejected = true
+	_, err = gateway.Refund(ctx, order.OrderNo, 300, "")
+	require.Error(t, err)
+	order, err = gateway.Get(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
+	assert.Zero(t, order.RefundingAmount)
+
+	provider.rejected, provider.lost = false, true
+	_, err = gateway.Refund(ctx, order.OrderNo, 300, "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	order, err = gateway.Get(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRefunding, order.Status, "the refund may have been accepted")
+	assert.Equal(t, int64(300), order.RefundingAmount)
+
+	_, err = gateway.Refund(ctx, order.OrderNo, 100, "")
+	var transitionErr *InvalidTransitionError
+	assert.ErrorAs(t, err, &transitionErr, "only one refund may be in flight")
+
+	order, err = gateway.Sync(ctx, order.OrderNo)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPaid, order.Status)
+	assert.Equal(t, int64(300), order.RefundedAmount)
+	assert.Zero(t, order.RefundingAmount)
+}
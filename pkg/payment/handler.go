@@ -0,0 +1,170 @@
+package payment
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createOrderRequest 下单请求，金额单位为分
+type createOrderRequest struct {
+	Channel string `json:"channel" binding:"required"`
+	Subject string `json:"subject" binding:"required,max=200"`
+	Amount  int64  `json:"amount" binding:"required,gt=0"`
+}
+
+// refundRequest 退款请求，amount 为 0 时全额退款
+type refundRequest struct {
+	Amount int64  `json:"amount" binding:"gte=0"`
+	Reason string `json:"reason" binding:"max=80"`
+}
+
+// Handler 支付接口
+type Handler struct {
+	gateway *Gateway
+}
+
+// NewHandler 创建支付接口
+func NewHandler(gateway *Gateway) *Handler {
+	return &Handler{gateway: gateway}
+}
+
+// RegisterRoutes 注册用户侧路由，调用方需挂载 AuthMiddleware（对应 payment:write / payment:read 权限）
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/payments/channels", h.ListChannels)
+	group.POST("/payments", h.CreateOrder)
+	group.GET("/payments/:orderNo", h.GetOrder)
+	group.POST("/payments/:orderNo/cancel", h.CancelOrder)
+}
+
+// RegisterAdminRoutes 注册管理路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.POST("/payments/:orderNo/refund", h.Refund)
+	group.POST("/payments/:orderNo/sync", h.Sync)
+}
+
+// RegisterWebhookRoutes 注册渠道回调路由（无需鉴权，由各渠道验签）
+func (h *Handler) RegisterWebhookRoutes(group *gin.RouterGroup) {
+	group.POST("/payments/webhook/:provider", h.Webhook)
+}
+
+// ListChannels 可用的支付渠道
+func (h *Handler) ListChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"channels": h.gateway.Providers(),
+	})
+}
+
+// CreateOrder 创建订单并返回客户端拉起支付所需参数
+func (h *Handler) CreateOrder(c *gin.Context) {
+	var req createOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	order, result, err := h.gateway.CreateOrder(c.Request.Context(), &CreateOrderRequest{
+		UserID:   c.GetString("userID"),
+		Channel:  req.Channel,
+		Subject:  req.Subject,
+		Amount:   req.Amount,
+		ClientIP: c.ClientIP(),
+	})
+	if err != nil {
+		apperrors.Render(c, gatewayError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"order":   order,
+		"payment": result,
+	})
+}
+
+// GetOrder 查询本人订单
+func (h *Handler) GetOrder(c *gin.Context) {
+	order, ok := h.ownOrder(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// CancelOrder 取消本人未支付的订单
+func (h *Handler) CancelOrder(c *gin.Context) {
+	order, ok := h.ownOrder(c)
+	if !ok {
+		return
+	}
+	order, err := h.gateway.Cancel(c.Request.Context(), order.OrderNo)
+	if err != nil {
+		apperrors.Render(c, gatewayError(err))
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// Refund 发起退款
+func (h *Handler) Refund(c *gin.Context) {
+	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	order, err := h.gateway.Refund(c.Request.Context(), c.Param("orderNo"), req.Amount, req.Reason)
+	if err != nil {
+		apperrors.Render(c, gatewayError(err))
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// Sync 主动向渠道查询并同步订单状态
+func (h *Handler) Sync(c *gin.Context) {
+	order, err := h.gateway.Sync(c.Request.Context(), c.Param("orderNo"))
+	if err != nil {
+		apperrors.Render(c, gatewayError(err))
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// Webhook 渠道异步通知，应答格式由各渠道驱动决定
+func (h *Handler) Webhook(c *gin.Context) {
+	provider := c.Param("provider")
+	if err := h.gateway.HandleWebhook(c.Request.Context(), provider, c.Writer, c.Request); err != nil {
+		log.Printf("Payment webhook from %s failed: %v", provider, err)
+	}
+}
+
+// ownOrder 查询订单并校验归属，不属于当前用户时按不存在处理
+func (h *Handler) ownOrder(c *gin.Context) (*Order, bool) {
+	order, err := h.gateway.Get(c.Request.Context(), c.Param("orderNo"))
+	if err == nil && order.UserID != c.GetString("userID") {
+		err = ErrOrderNotFound
+	}
+	if err != nil {
+		apperrors.Render(c, gatewayError(err))
+		return nil, false
+	}
+	return order, true
+}
+
+func gatewayError(err error) error {
+	var transitionErr *InvalidTransitionError
+	switch {
+	case errors.Is(err, ErrUnknownProvider):
+		return apperrors.Wrap(err, apperrors.CodeUnsupportedChannel, "")
+	case errors.Is(err, ErrOrderNotFound):
+		return apperrors.Wrap(err, apperrors.CodeOrderNotFound, "")
+	case errors.Is(err, ErrRefundExceeded):
+		return apperrors.Wrap(err, apperrors.CodeInvalidParam, err.Error())
+	case errors.As(err, &transitionErr):
+		return apperrors.Wrap(err, apperrors.CodeConflict, err.Error())
+	default:
+		return apperrors.Wrap(err, apperrors.CodeUnavailable, "")
+	}
+}
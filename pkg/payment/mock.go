@@ -0,0 +1,296 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ChannelMock 模拟渠道名
+const ChannelMock = "mock"
+
+// 模拟回调的签名请求头
+const (
+	mockSignatureHeader = "X-Mock-Signature"
+	mockTimestampHeader = "X-Mock-Timestamp"
+)
+
+// MockConfig 模拟渠道配置
+type MockConfig struct {
+	// Secret 回调签名密钥
+	Secret string `json:"secret"`
+	// Latency 每次调用的模拟延迟
+	Latency time.Duration `json:"latency"`
+	// FailureRate 下单、查询、退款随机失败的概率（0~1）
+	FailureRate float64 `json:"failure_rate"`
+	// WebhookTolerance 回调时间戳允许的偏差
+	WebhookTolerance time.Duration `json:"webhook_tolerance"`
+}
+
+// DefaultMockConfig 默认模拟渠道配置
+func DefaultMockConfig() *MockConfig {
+	return &MockConfig{
+		Secret:           "mock-secret",
+		WebhookTolerance: 5 * time.Minute,
+	}
+}
+
+// mockTrade 模拟渠道侧交易
+type mockTrade struct {
+	tradeNo  string
+	amount   int64
+	refunded int64
+	status   Status
+	paidAt   *time.Time
+}
+
+// mockWebhook 模拟回调报文
+type mockWebhook struct {
+	ID      string    `json:"id"`
+	Type    EventType `json:"type"`
+	OrderNo string    `json:"order_no"`
+	TradeNo string    `json:"trade_no"`
+	Amount  int64     `json:"amount"`
+	Time    int64     `json:"time"`
+}
+
+// ErrMockFailure 模拟渠道随机失败
+var ErrMockFailure = errors.New("mock provider simulated failure")
+
+// MockProvider 模拟支付渠道，行为与真实渠道一致（异步回调、HMAC 签名），用于测试与压测
+type MockProvider struct {
+	mu     sync.RWMutex
+	trades map[string]*mockTrade
+	config *MockConfig
+}
+
+// NewMockProvider 创建模拟渠道
+func NewMockProvider(config *MockConfig) *MockProvider {
+	if config == nil {
+		config = DefaultMockConfig()
+	}
+	return &MockProvider{
+		trades: make(map[string]*mockTrade),
+		config: config,
+	}
+}
+
+// Name 渠道名
+func (m *MockProvider) Name() string {
+	return ChannelMock
+}
+
+// CreatePayment 创建模拟交易
+func (m *MockProvider) CreatePayment(ctx context.Context, req *PaymentRequest) (*PaymentResult, error) {
+	if err := m.simulate(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trade, ok := m.trades[req.OrderNo]
+	if !ok {
+		trade = &mockTrade{
+			tradeNo: "MOCK" + strconv.FormatInt(time.Now().UnixNano(), 10),
+			amount:  req.Amount,
+			status:  StatusPending,
+		}
+		m.trades[req.OrderNo] = trade
+	}
+	return &PaymentResult{
+		PayURL: "mock://pay/" + req.OrderNo,
+		Params: map[string]string{"trade_no": trade.tradeNo},
+	}, nil
+}
+
+// Query 查询模拟交易
+func (m *MockProvider) Query(ctx context.Context, orderNo string) (*QueryResult, error) {
+	if err := m.simulate(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	trade, ok := m.trades[orderNo]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	return &QueryResult{
+		OrderNo:        orderNo,
+		TradeNo:        trade.tradeNo,
+		Status:         trade.status,
+		Amount:         trade.amount,
+		RefundedAmount: trade.refunded,
+		PaidAt:         trade.paidAt,
+	}, nil
+}
+
+// Close 关闭模拟交易
+func (m *MockProvider) Close(ctx context.Context, orderNo string) error {
+	if err := m.simulate(ctx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trade, ok := m.trades[orderNo]
+	if !ok {
+		return nil
+	}
+	if trade.status != StatusPending {
+		return fmt.Errorf("mock trade %s is %s", orderNo, trade.status)
+	}
+	trade.status = StatusCancelled
+	return nil
+}
+
+// Refund 模拟退款，同步成功
+func (m *MockProvider) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	if err := m.simulate(ctx); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trade, ok := m.trades[req.OrderNo]
+	if !ok || (trade.status != StatusPaid && trade.status != StatusRefunded) {
+		return &RefundResult{RefundNo: req.RefundNo, Status: RefundFailed}, nil
+	}
+	if trade.refunded+req.Amount > trade.amount {
+		return &RefundResult{RefundNo: req.RefundNo, Status: RefundFailed}, nil
+	}
+	trade.refunded += req.Amount
+	if trade.refunded == trade.amount {
+		trade.status = StatusRefunded
+	}
+	return &RefundResult{RefundNo: req.RefundNo, Status: RefundSucceeded}, nil
+}
+
+// ParseWebhook 校验 HMAC 签名与时间戳并解析回调
+func (m *MockProvider) ParseWebhook(ctx context.Context, r *http.Request) (*WebhookEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+
+	timestamp := r.Header.Get(mockTimestampHeader)
+	expected := m.sign(timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(mockSignatureHeader))) {
+		return nil, ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > m.config.WebhookTolerance || skew < -m.config.WebhookTolerance {
+		return nil, fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	var payload mockWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %w", err)
+	}
+	return &WebhookEvent{
+		ID:         payload.ID,
+		Provider:   ChannelMock,
+		Type:       payload.Type,
+		OrderNo:    payload.OrderNo,
+		TradeNo:    payload.TradeNo,
+		Amount:     payload.Amount,
+		OccurredAt: time.Unix(payload.Time, 0),
+		Payload:    body,
+	}, nil
+}
+
+// AckWebhook 应答回调
+func (m *MockProvider) AckWebhook(w http.ResponseWriter, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("success"))
+}
+
+// SimulatePayment 模拟用户完成支付，返回渠道将要发送的已签名回调请求
+func (m *MockProvider) SimulatePayment(orderNo string) (*http.Request, error) {
+	m.mu.Lock()
+	trade, ok := m.trades[orderNo]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrOrderNotFound
+	}
+	now := time.Now()
+	trade.status = StatusPaid
+	trade.paidAt = &now
+	payload := mockWebhook{
+		ID:      PaidEventID(orderNo),
+		Type:    EventPaid,
+		OrderNo: orderNo,
+		TradeNo: trade.tradeNo,
+		Amount:  trade.amount,
+		Time:    now.Unix(),
+	}
+	m.mu.Unlock()
+
+	return m.WebhookRequest(payload.ID, payload.Type, orderNo, payload.TradeNo, payload.Amount)
+}
+
+// WebhookRequest 构造已签名的回调请求，可用于重放、篡改等场景的测试
+func (m *MockProvider) WebhookRequest(id string, eventType EventType, orderNo, tradeNo string, amount int64) (*http.Request, error) {
+	body, err := json.Marshal(mockWebhook{
+		ID:      id,
+		Type:    eventType,
+		OrderNo: orderNo,
+		TradeNo: tradeNo,
+		Amount:  amount,
+		Time:    time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/payments/webhook/"+ChannelMock, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(mockTimestampHeader, timestamp)
+	req.Header.Set(mockSignatureHeader, m.sign(timestamp, body))
+	return req, nil
+}
+
+func (m *MockProvider) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(m.config.Secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// simulate 模拟网络延迟与随机失败
+func (m *MockProvider) simulate(ctx context.Context) error {
+	if m.config.Latency > 0 {
+		select {
+		case <-time.After(m.config.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if m.config.FailureRate > 0 && rand.Float64() < m.config.FailureRate {
+		return ErrMockFailure
+	}
+	return nil
+}
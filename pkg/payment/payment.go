@@ -0,0 +1,138 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrUnknownProvider 支付渠道未注册
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	// ErrOrderNotFound 订单不存在
+	ErrOrderNotFound = errors.New("payment order not found")
+	// ErrDuplicateEvent 回调事件已处理过
+	ErrDuplicateEvent = errors.New("duplicate payment event")
+	// ErrInvalidSignature 回调签名校验失败
+	ErrInvalidSignature = errors.New("invalid payment webhook signature")
+	// ErrAmountMismatch 回调金额与订单金额不一致
+	ErrAmountMismatch = errors.New("payment amount mismatch")
+	// ErrRefundExceeded 退款金额超过可退金额
+	ErrRefundExceeded = errors.New("refund amount exceeds refundable amount")
+	// ErrIgnoredEvent 回调合法但无需处理（如等待付款通知），直接应答成功
+	ErrIgnoredEvent = errors.New("payment event ignored")
+)
+
+// EventType 回调事件类型
+type EventType string
+
+const (
+	EventPaid          EventType = "paid"           // 支付成功
+	EventClosed        EventType = "closed"         // 交易关闭（超时未支付或主动关闭）
+	EventRefunded      EventType = "refunded"       // 退款成功
+	EventRefundFailed  EventType = "refund_failed"  // 退款失败
+	EventPaymentFailed EventType = "payment_failed" // 支付失败
+	// EventPaidAfterCancel 订单取消后渠道仍扣款成功，由支付回调改记，需退款
+	EventPaidAfterCancel EventType = "paid_after_cancel"
+)
+
+// PaymentRequest 发起支付参数，金额单位为分
+type PaymentRequest struct {
+	OrderNo  string
+	Amount   int64
+	Subject  string
+	ClientIP string
+	ExpireAt time.Time
+}
+
+// PaymentResult 发起支付结果，客户端据此拉起支付
+type PaymentResult struct {
+	// PayURL 跳转或二维码地址（部分渠道为空）
+	PayURL string `json:"payUrl,omitempty"`
+	// Params 客户端 SDK 所需参数，如支付宝 App 支付串、微信 prepay_id
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// QueryResult 渠道侧交易状态
+type QueryResult struct {
+	OrderNo        string
+	TradeNo        string // 渠道交易号
+	Status         Status // 只会是 pending、paid、cancelled、failed、refunded 之一
+	Amount         int64
+	RefundedAmount int64
+	PaidAt         *time.Time
+}
+
+// RefundRequest 退款参数，RefundNo 用于渠道侧幂等
+type RefundRequest struct {
+	OrderNo  string
+	RefundNo string
+	Total    int64
+	Amount   int64
+	Reason   string
+}
+
+// RefundStatus 渠道侧退款受理状态
+type RefundStatus string
+
+const (
+	RefundSucceeded  RefundStatus = "succeeded"
+	RefundProcessing RefundStatus = "processing" // 异步退款，结果通过回调或查询获得
+	RefundFailed     RefundStatus = "failed"
+)
+
+// RefundResult 退款结果
+type RefundResult struct {
+	RefundNo string
+	Status   RefundStatus
+}
+
+// WebhookEvent 验签后的回调事件
+type WebhookEvent struct {
+	// ID 渠道侧事件唯一标识，用于幂等
+	ID       string    `json:"id"`
+	Provider string    `json:"provider"`
+	Type     EventType `json:"type"`
+	OrderNo  string    `json:"orderNo"`
+	TradeNo  string    `json:"tradeNo"`
+	// Amount 支付事件为实付金额，退款事件为本次退款金额（为 0 时按在途退款金额入账）
+	Amount     int64     `json:"amount"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Payload    []byte    `json:"-"`
+}
+
+// Provider 支付渠道驱动
+type Provider interface {
+	// Name 渠道名，与订单 channel 字段一致
+	Name() string
+	// CreatePayment 在渠道侧创建交易
+	CreatePayment(ctx context.Context, req *PaymentRequest) (*PaymentResult, error)
+	// Query 查询渠道侧交易状态，用于回调丢失时主动同步
+	Query(ctx context.Context, orderNo string) (*QueryResult, error)
+	// Close 关闭未支付的交易
+	Close(ctx context.Context, orderNo string) error
+	// Refund 发起退款
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
+	// ParseWebhook 校验回调签名并解析事件，签名错误时返回 ErrInvalidSignature
+	ParseWebhook(ctx context.Context, r *http.Request) (*WebhookEvent, error)
+	// AckWebhook 按渠道要求的格式应答回调，err 非空时渠道会稍后重试
+	AckWebhook(w http.ResponseWriter, err error)
+}
+
+// FormatYuan 分转为元，保留两位小数
+func FormatYuan(cents int64) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// ParseYuan 元转为分
+func ParseYuan(yuan string) (int64, error) {
+	value, err := strconv.ParseFloat(yuan, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", yuan, err)
+	}
+	return int64(math.Round(value * 100)), nil
+}
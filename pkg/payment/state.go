@@ -0,0 +1,63 @@
+package payment
+
+import "fmt"
+
+// Status 订单状态
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // 待支付
+	StatusPaid      Status = "paid"      // 已支付（含部分退款）
+	StatusCancelled Status = "cancelled" // 已取消/关闭
+	StatusFailed    Status = "failed"    // 下单或支付失败
+	StatusRefunding Status = "refunding" // 退款处理中
+	StatusRefunded  Status = "refunded"  // 已全额退款
+)
+
+// transitions 允许的状态流转
+var transitions = map[Status][]Status{
+	StatusPending: {StatusPaid, StatusCancelled, StatusFailed},
+	// 下单失败后用户可能仍在渠道侧完成支付，以渠道结果为准
+	StatusFailed: {StatusPaid, StatusCancelled},
+	// 已取消为终态：关单与用户付款并发时渠道仍可能扣款成功，此时订单保持取消，
+	// 回调记为 paid_after_cancel 事件并由人工或业务原路退款，见 Gateway.apply
+	StatusPaid:      {StatusRefunding, StatusRefunded},
+	StatusRefunding: {StatusPaid, StatusRefunded},
+}
+
+// InvalidTransitionError 非法的状态流转
+type InvalidTransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid order status transition %s -> %s", e.From, e.To)
+}
+
+// CanTransition 判断状态能否从 from 流转到 to
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal 是否为终态
+func (s Status) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// transition 校验并修改订单状态，状态未变化时返回 false
+func (o *Order) transition(to Status) (bool, error) {
+	if o.Status == to {
+		return false, nil
+	}
+	if !CanTransition(o.Status, to) {
+		return false, &InvalidTransitionError{From: o.Status, To: to}
+	}
+	o.Status = to
+	return true, nil
+}
@@ -0,0 +1,60 @@
+package payment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCanTransition 状态流转表：已取消与已全额退款为终态，已支付只能进入退款
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to Status
+		ok       bool
+	}{
+		{StatusPending, StatusPaid, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusPending, StatusFailed, true},
+		{StatusPending, StatusRefunding, false},
+		{StatusFailed, StatusPaid, true},
+		{StatusFailed, StatusCancelled, true},
+		{StatusCancelled, StatusPaid, false},
+		{StatusCancelled, StatusPending, false},
+		{StatusPaid, StatusRefunding, true},
+		{StatusPaid, StatusRefunded, true},
+		{StatusPaid, StatusCancelled, false},
+		{StatusRefunding, StatusPaid, true},
+		{StatusRefunding, StatusRefunded, true},
+		{StatusRefunded, StatusPaid, false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.ok, CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	assert.True(t, StatusCancelled.IsFinal())
+	assert.True(t, StatusRefunded.IsFinal())
+	assert.False(t, StatusPending.IsFinal())
+	assert.False(t, StatusPaid.IsFinal())
+}
+
+// TestOrderTransition 相同状态不算变化，非法流转返回 InvalidTransitionError 且不修改订单
+func TestOrderTransition(t *testing.T) {
+	order := &Order{Status: StatusPending}
+
+	changed, err := order.transition(StatusPending)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = order.transition(StatusPaid)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, StatusPaid, order.Status)
+
+	_, err = order.transition(StatusCancelled)
+	var transitionErr *InvalidTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	assert.Equal(t, StatusPaid, transitionErr.From)
+	assert.Equal(t, StatusCancelled, transitionErr.To)
+	assert.Equal(t, StatusPaid, order.Status)
+}
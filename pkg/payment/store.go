@@ -0,0 +1,243 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// Order 支付订单，金额单位为分
+type Order struct {
+	ID              string     `db:"id" json:"id"`
+	OrderNo         string     `db:"order_no" json:"orderNo"`
+	UserID          string     `db:"user_id" json:"userId"`
+	Amount          int64      `db:"amount" json:"amount"`
+	Status          Status     `db:"status" json:"status"`
+	Channel         string     `db:"channel" json:"channel"`
+	Subject         string     `db:"subject" json:"subject"`
+	TradeNo         string     `db:"trade_no" json:"tradeNo,omitempty"`
+	RefundedAmount  int64      `db:"refunded_amount" json:"refundedAmount"`
+	RefundingAmount int64      `db:"refunding_amount" json:"refundingAmount"`
+	RefundNo        string     `db:"refund_no" json:"refundNo,omitempty"` // 在途或最近一笔退款单号
+	PaidAt          *time.Time `db:"paid_at" json:"paidAt,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// Refundable 可退金额
+func (o *Order) Refundable() int64 {
+	return o.Amount - o.RefundedAmount - o.RefundingAmount
+}
+
+// Store 订单存储
+type Store interface {
+	Create(ctx context.Context, order *Order) error
+	Get(ctx context.Context, orderNo string) (*Order, error)
+	// Update 锁定订单后交由 fn 修改并写回，fn 返回错误时放弃修改。
+	// event 非空时在同一事务中记录回调事件（以 fn 执行后的类型为准），已记录过则返回 ErrDuplicateEvent
+	Update(ctx context.Context, orderNo string, event *WebhookEvent, fn func(order *Order) error) (*Order, error)
+	// ListByStatus 列出处于指定状态且更新时间早于 before 的订单号，用于主动对账
+	ListByStatus(ctx context.Context, status Status, before time.Time, limit int) ([]string, error)
+}
+
+// SQLStore 基于 orders 与 payment_events 表的订单存储
+type SQLStore struct {
+	db *database.DB
+}
+
+// NewSQLStore 创建订单存储
+func NewSQLStore(db *database.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// 金额在表中以元（DECIMAL）存储，读写时与分互转
+const selectOrder = `
+	SELECT id, order_no, user_id, ROUND(amount * 100)::BIGINT AS amount, status,
+		COALESCE(channel, '') AS channel, COALESCE(subject, '') AS subject, COALESCE(trade_no, '') AS trade_no,
+		ROUND(refunded_amount * 100)::BIGINT AS refunded_amount, ROUND(refunding_amount * 100)::BIGINT AS refunding_amount,
+		COALESCE(refund_no, '') AS refund_no, paid_at, created_at, updated_at
+	FROM orders`
+
+// Create 创建订单
+func (s *SQLStore) Create(ctx context.Context, order *Order) error {
+	err := s.db.GetContext(ctx, order, `
+		INSERT INTO orders (order_no, user_id, amount, status, channel, subject)
+		VALUES ($1, $2, $3::NUMERIC / 100, $4, $5, $6)
+		RETURNING id, created_at, updated_at`,
+		order.OrderNo, order.UserID, order.Amount, order.Status, order.Channel, order.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+	return nil
+}
+
+// Get 按订单号查询
+func (s *SQLStore) Get(ctx context.Context, orderNo string) (*Order, error) {
+	var order Order
+	err := s.db.GetContext(ctx, &order, selectOrder+` WHERE order_no = $1 AND deleted_at IS NULL`, orderNo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return &order, nil
+}
+
+// Update 在事务中以行锁读取订单、记录事件并写回
+func (s *SQLStore) Update(ctx context.Context, orderNo string, event *WebhookEvent, fn func(order *Order) error) (*Order, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var order Order
+	err = tx.GetContext(ctx, &order, selectOrder+` WHERE order_no = $1 AND deleted_at IS NULL FOR UPDATE`, orderNo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	// 事件在 fn 之后记录，fn 可按订单状态改写事件类型；重复事件使整个事务回滚
+	if err := fn(&order); err != nil {
+		return nil, err
+	}
+
+	if event != nil {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO payment_events (provider, event_id, order_no, event_type, amount, payload)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (provider, event_id) DO NOTHING`,
+			event.Provider, event.ID, event.OrderNo, event.Type, event.Amount, string(event.Payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record payment event: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return nil, ErrDuplicateEvent
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE orders
+		SET status = $2, trade_no = NULLIF($3, ''), refunded_amount = $4::NUMERIC / 100,
+			refunding_amount = $5::NUMERIC / 100, refund_no = NULLIF($6, ''), paid_at = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE order_no = $1`,
+		order.OrderNo, order.Status, order.TradeNo, order.RefundedAmount, order.RefundingAmount, order.RefundNo, order.PaidAt); err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &order, nil
+}
+
+// ListByStatus 列出待对账的订单号
+func (s *SQLStore) ListByStatus(ctx context.Context, status Status, before time.Time, limit int) ([]string, error) {
+	var orderNos []string
+	err := s.db.SelectContext(ctx, &orderNos, `
+		SELECT order_no FROM orders
+		WHERE status = $1 AND updated_at < $2 AND deleted_at IS NULL
+		ORDER BY updated_at
+		LIMIT $3`, status, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	return orderNos, nil
+}
+
+// MemoryStore 内存订单存储，用于测试与压测
+type MemoryStore struct {
+	mu     sync.RWMutex
+	orders map[string]*Order
+	events map[string]bool
+	seq    int
+}
+
+// NewMemoryStore 创建内存订单存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders: make(map[string]*Order),
+		events: make(map[string]bool),
+	}
+}
+
+// Create 创建订单
+func (s *MemoryStore) Create(ctx context.Context, order *Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.orders[order.OrderNo]; exists {
+		return fmt.Errorf("order %s already exists", order.OrderNo)
+	}
+	s.seq++
+	now := time.Now()
+	order.ID = fmt.Sprintf("mem-%d", s.seq)
+	order.CreatedAt, order.UpdatedAt = now, now
+	saved := *order
+	s.orders[order.OrderNo] = &saved
+	return nil
+}
+
+// Get 按订单号查询
+func (s *MemoryStore) Get(ctx context.Context, orderNo string) (*Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	order, ok := s.orders[orderNo]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	copied := *order
+	return &copied, nil
+}
+
+// Update 加锁修改订单
+func (s *MemoryStore) Update(ctx context.Context, orderNo string, event *WebhookEvent, fn func(order *Order) error) (*Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, ok := s.orders[orderNo]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+
+	var eventKey string
+	if event != nil {
+		eventKey = event.Provider + ":" + event.ID
+		if s.events[eventKey] {
+			return nil, ErrDuplicateEvent
+		}
+	}
+
+	order := *saved
+	if err := fn(&order); err != nil {
+		return nil, err
+	}
+	if event != nil {
+		s.events[eventKey] = true
+	}
+	order.UpdatedAt = time.Now()
+	*saved = order
+	return &order, nil
+}
+
+// ListByStatus 列出待对账的订单号
+func (s *MemoryStore) ListByStatus(ctx context.Context, status Status, before time.Time, limit int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var orderNos []string
+	for _, order := range s.orders {
+		if len(orderNos) >= limit {
+			break
+		}
+		if order.Status == status && order.UpdatedAt.Before(before) {
+			orderNos = append(orderNos, order.OrderNo)
+		}
+	}
+	return orderNos, nil
+}
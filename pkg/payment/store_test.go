@@ -0,0 +1,84 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var orderColumns = []string{"id", "order_no", "user_id", "amount", "status", "channel", "subject", "trade_no",
+	"refunded_amount", "refunding_amount", "refund_no", "paid_at", "created_at", "updated_at"}
+
+const (
+	lockOrder   = `FROM orders WHERE order_no = \$1 AND deleted_at IS NULL FOR UPDATE`
+	insertEvent = `INSERT INTO payment_events .* ON CONFLICT \(provider, event_id\) DO NOTHING`
+	updateOrder = `UPDATE orders\s+SET status = \$2`
+)
+
+func orderRow(status Status) *sqlmock.Rows {
+	now := time.Now()
+	return sqlmock.NewRows(orderColumns).
+		AddRow("1", "NO1", "u1", 990, status, ChannelMock, "VIP", "", 0, 0, "", nil, now, now)
+}
+
+// TestSQLStore_UpdateRecordsEvent 事件与订单在同一事务中写入，事件按 fn 执行后的类型记录
+func TestSQLStore_UpdateRecordsEvent(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	store := NewSQLStore(db)
+	gateway := NewGateway(store)
+	event := &WebhookEvent{ID: PaidEventID("NO1"), Provider: ChannelMock, Type: EventPaid, OrderNo: "NO1", TradeNo: "T1", Amount: 990, OccurredAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockOrder).WithArgs("NO1").WillReturnRows(orderRow(StatusCancelled))
+	mock.ExpectExec(insertEvent).
+		WithArgs(ChannelMock, PaidEventID("NO1"), "NO1", EventPaidAfterCancel, int64(990), "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(updateOrder).
+		WithArgs("NO1", StatusCancelled, "T1", int64(0), int64(0), "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	order, err := gateway.apply(context.Background(), event)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, order.Status)
+	assert.NotNil(t, order.PaidAt)
+}
+
+// TestSQLStore_UpdateDuplicateEvent 事件已记录（ON CONFLICT 未插入）时回滚事务，不修改订单
+func TestSQLStore_UpdateDuplicateEvent(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	store := NewSQLStore(db)
+	event := &WebhookEvent{ID: PaidEventID("NO1"), Provider: ChannelMock, Type: EventPaid, OrderNo: "NO1", Amount: 990}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockOrder).WithArgs("NO1").WillReturnRows(orderRow(StatusPending))
+	mock.ExpectExec(insertEvent).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	called := false
+	_, err := store.Update(context.Background(), "NO1", event, func(order *Order) error {
+		called = true
+		_, err := order.transition(StatusPaid)
+		return err
+	})
+	assert.ErrorIs(t, err, ErrDuplicateEvent)
+	assert.True(t, called)
+}
+
+// TestSQLStore_UpdateMissingOrder 订单不存在时返回 ErrOrderNotFound
+func TestSQLStore_UpdateMissingOrder(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	store := NewSQLStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockOrder).WithArgs("NO1").WillReturnRows(sqlmock.NewRows(orderColumns))
+	mock.ExpectRollback()
+
+	_, err := store.Update(context.Background(), "NO1", nil, func(order *Order) error { return nil })
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+}
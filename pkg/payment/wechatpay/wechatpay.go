@@ -0,0 +1,268 @@
+package wechatpay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/payment"
+
+	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/auth/verifiers"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/downloader"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/option"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/payments/app"
+	"github.com/wechatpay-apiv3/wechatpay-go/services/refunddomestic"
+	"github.com/wechatpay-apiv3/wechatpay-go/utils"
+)
+
+// Channel 渠道名
+const Channel = "wechat"
+
+// 交易状态
+const (
+	tradeStateSuccess = "SUCCESS"
+	tradeStateRefund  = "REFUND"
+	tradeStateClosed  = "CLOSED"
+	tradeStateRevoked = "REVOKED"
+	tradeStatePayErr  = "PAYERROR"
+)
+
+// 通知事件类型
+const (
+	eventTransactionSuccess = "TRANSACTION.SUCCESS"
+	eventRefundSuccess      = "REFUND.SUCCESS"
+	eventRefundAbnormal     = "REFUND.ABNORMAL"
+	eventRefundClosed       = "REFUND.CLOSED"
+)
+
+// refundNotification 退款通知解密后的内容
+type refundNotification struct {
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionID string `json:"transaction_id"`
+	OutRefundNo   string `json:"out_refund_no"`
+	RefundStatus  string `json:"refund_status"`
+	Amount        struct {
+		Total  int64 `json:"total"`
+		Refund int64 `json:"refund"`
+	} `json:"amount"`
+}
+
+// Provider 微信支付 APIv3 App 支付驱动
+type Provider struct {
+	client  *core.Client
+	handler *notify.Handler
+	config  config.WechatPayConfig
+}
+
+// NewProvider 创建微信支付驱动，平台证书由 SDK 自动下载与更新
+func NewProvider(ctx context.Context, cfg config.WechatPayConfig) (*Provider, error) {
+	if cfg.MchID == "" {
+		return nil, errors.New("wechat pay config missing")
+	}
+
+	privateKey, err := utils.LoadPrivateKey(cfg.MchPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wechat pay private key: %w", err)
+	}
+	client, err := core.NewClient(ctx,
+		option.WithWechatPayAutoAuthCipher(cfg.MchID, cfg.MchCertificateSerial, privateKey, cfg.APIv3Key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wechat pay client: %w", err)
+	}
+
+	certVisitor := downloader.MgrInstance().GetCertificateVisitor(cfg.MchID)
+	handler := notify.NewNotifyHandler(cfg.APIv3Key, verifiers.NewSHA256WithRSAVerifier(certVisitor))
+	return &Provider{client: client, handler: handler, config: cfg}, nil
+}
+
+// Name 渠道名
+func (p *Provider) Name() string {
+	return Channel
+}
+
+// CreatePayment App 下单，返回 prepay_id
+func (p *Provider) CreatePayment(ctx context.Context, req *payment.PaymentRequest) (*payment.PaymentResult, error) {
+	prepay := app.PrepayRequest{
+		Appid:       core.String(p.config.AppID),
+		Mchid:       core.String(p.config.MchID),
+		Description: core.String(req.Subject),
+		OutTradeNo:  core.String(req.OrderNo),
+		NotifyUrl:   core.String(p.config.NotifyURL),
+		Amount:      &app.Amount{Total: core.Int64(req.Amount)},
+	}
+	if !req.ExpireAt.IsZero() {
+		prepay.TimeExpire = core.Time(req.ExpireAt)
+	}
+
+	svc := app.AppApiService{Client: p.client}
+	resp, _, err := svc.Prepay(ctx, prepay)
+	if err != nil {
+		return nil, err
+	}
+	return &payment.PaymentResult{
+		Params: map[string]string{"prepay_id": stringValue(resp.PrepayId)},
+	}, nil
+}
+
+// Query 查询交易状态
+func (p *Provider) Query(ctx context.Context, orderNo string) (*payment.QueryResult, error) {
+	svc := app.AppApiService{Client: p.client}
+	transaction, _, err := svc.QueryOrderByOutTradeNo(ctx, app.QueryOrderByOutTradeNoRequest{
+		OutTradeNo: core.String(orderNo),
+		Mchid:      core.String(p.config.MchID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &payment.QueryResult{
+		OrderNo: orderNo,
+		TradeNo: stringValue(transaction.TransactionId),
+		Status:  tradeState(stringValue(transaction.TradeState)),
+	}
+	if transaction.Amount != nil {
+		result.Amount = int64Value(transaction.Amount.Total)
+	}
+	if paidAt, err := time.Parse(time.RFC3339, stringValue(transaction.SuccessTime)); err == nil {
+		result.PaidAt = &paidAt
+	}
+	return result, nil
+}
+
+// Close 关闭未支付的交易
+func (p *Provider) Close(ctx context.Context, orderNo string) error {
+	svc := app.AppApiService{Client: p.client}
+	_, err := svc.CloseOrder(ctx, app.CloseOrderRequest{
+		OutTradeNo: core.String(orderNo),
+		Mchid:      core.String(p.config.MchID),
+	})
+	return err
+}
+
+// Refund 申请退款，微信退款为异步处理，结果以退款通知或查询为准
+func (p *Provider) Refund(ctx context.Context, req *payment.RefundRequest) (*payment.RefundResult, error) {
+	svc := refunddomestic.RefundsApiService{Client: p.client}
+	refund, _, err := svc.Create(ctx, refunddomestic.CreateRequest{
+		OutTradeNo:  core.String(req.OrderNo),
+		OutRefundNo: core.String(req.RefundNo),
+		Reason:      core.String(req.Reason),
+		NotifyUrl:   core.String(p.config.NotifyURL),
+		Amount: &refunddomestic.AmountReq{
+			Refund:   core.Int64(req.Amount),
+			Total:    core.Int64(req.Total),
+			Currency: core.String("CNY"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &payment.RefundResult{RefundNo: req.RefundNo, Status: payment.RefundProcessing}
+	if refund.Status != nil {
+		switch *refund.Status {
+		case refunddomestic.STATUS_SUCCESS:
+			result.Status = payment.RefundSucceeded
+		case refunddomestic.STATUS_CLOSED, refunddomestic.STATUS_ABNORMAL:
+			result.Status = payment.RefundFailed
+		}
+	}
+	return result, nil
+}
+
+// ParseWebhook 验签并解密支付与退款通知
+func (p *Provider) ParseWebhook(ctx context.Context, r *http.Request) (*payment.WebhookEvent, error) {
+	var content json.RawMessage
+	notification, err := p.handler.ParseNotifyRequest(ctx, r, &content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", payment.ErrInvalidSignature, err)
+	}
+
+	event := &payment.WebhookEvent{
+		Provider:   Channel,
+		OccurredAt: time.Now(),
+		Payload:    content,
+	}
+	switch notification.EventType {
+	case eventTransactionSuccess:
+		var transaction payments.Transaction
+		if err := json.Unmarshal(content, &transaction); err != nil {
+			return nil, fmt.Errorf("failed to decode transaction: %w", err)
+		}
+		if stringValue(transaction.Mchid) != p.config.MchID {
+			return nil, fmt.Errorf("%w: unexpected mchid", payment.ErrInvalidSignature)
+		}
+		event.OrderNo = stringValue(transaction.OutTradeNo)
+		event.TradeNo = stringValue(transaction.TransactionId)
+		event.ID, event.Type = payment.PaidEventID(event.OrderNo), payment.EventPaid
+		if transaction.Amount != nil {
+			event.Amount = int64Value(transaction.Amount.Total)
+		}
+		if paidAt, err := time.Parse(time.RFC3339, stringValue(transaction.SuccessTime)); err == nil {
+			event.OccurredAt = paidAt
+		}
+	case eventRefundSuccess, eventRefundAbnormal, eventRefundClosed:
+		var refund refundNotification
+		if err := json.Unmarshal(content, &refund); err != nil {
+			return nil, fmt.Errorf("failed to decode refund: %w", err)
+		}
+		event.OrderNo = refund.OutTradeNo
+		event.TradeNo = refund.TransactionID
+		event.Amount = refund.Amount.Refund
+		if notification.EventType == eventRefundSuccess {
+			event.ID, event.Type = payment.RefundEventID(refund.OutRefundNo), payment.EventRefunded
+		} else {
+			event.ID, event.Type = payment.RefundFailedEventID(refund.OutRefundNo), payment.EventRefundFailed
+		}
+	default:
+		return nil, payment.ErrIgnoredEvent
+	}
+	return event, nil
+}
+
+// AckWebhook 成功时返回 200 空响应，失败时返回错误码使微信重发
+func (p *Provider) AckWebhook(w http.ResponseWriter, err error) {
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(map[string]string{"code": "FAIL", "message": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func tradeState(state string) payment.Status {
+	switch state {
+	case tradeStateSuccess:
+		return payment.StatusPaid
+	case tradeStateRefund:
+		return payment.StatusRefunded
+	case tradeStateClosed, tradeStateRevoked:
+		return payment.StatusCancelled
+	case tradeStatePayErr:
+		return payment.StatusFailed
+	default:
+		return payment.StatusPending
+	}
+}
+
+var _ payment.Provider = (*Provider)(nil)
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func int64Value(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
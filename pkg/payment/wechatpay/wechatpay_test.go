@@ -0,0 +1,161 @@
+package wechatpay
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/payment"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wechatpay-apiv3/wechatpay-go/core"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/auth/verifiers"
+	"github.com/wechatpay-apiv3/wechatpay-go/core/notify"
+	"github.com/wechatpay-apiv3/wechatpay-go/utils"
+)
+
+const testAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+// platform 模拟微信支付平台：以平台私钥签名通知，以 APIv3 密钥加密通知内容
+type platform struct {
+	key    *rsa.PrivateKey
+	serial string
+}
+
+// newTestProvider 以本地平台证书创建驱动（NewProvider 会联网下载平台证书）
+func newTestProvider(t *testing.T) (*Provider, *platform) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x5157F09EFDC096DE),
+		Subject:      pkix.Name{CommonName: "Tenpay.com Root CA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	certs := core.NewCertificateMapWithList([]*x509.Certificate{cert})
+	return &Provider{
+		handler: notify.NewNotifyHandler(testAPIv3Key, verifiers.NewSHA256WithRSAVerifier(certs)),
+		config:  config.WechatPayConfig{MchID: "1900000001"},
+	}, &platform{key: key, serial: utils.GetCertificateSerialNumber(*cert)}
+}
+
+// notification 构造已加密、已签名的通知请求
+func (p *platform) notification(t *testing.T, eventType string, content interface{}) *http.Request {
+	t.Helper()
+	plaintext, err := json.Marshal(content)
+	require.NoError(t, err)
+	block, err := aes.NewCipher([]byte(testAPIv3Key))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce, associated := "0123456789ab", "transaction"
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":            "EV-1",
+		"event_type":    eventType,
+		"resource_type": "encrypt-resource",
+		"resource": map[string]string{
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      base64.StdEncoding.EncodeToString(aead.Seal(nil, []byte(nonce), plaintext, []byte(associated))),
+			"associated_data": associated,
+			"nonce":           nonce,
+		},
+	})
+	require.NoError(t, err)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature, err := utils.SignSHA256WithRSA(timestamp+"\nnonce-1\n"+string(body)+"\n", p.key)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook/wechat", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Wechatpay-Serial", p.serial)
+	req.Header.Set("Wechatpay-Signature", signature)
+	req.Header.Set("Wechatpay-Timestamp", timestamp)
+	req.Header.Set("Wechatpay-Nonce", "nonce-1")
+	return req
+}
+
+func transaction(mchID string) map[string]interface{} {
+	return map[string]interface{}{
+		"mchid":          mchID,
+		"out_trade_no":   "NO1",
+		"transaction_id": "4200000001",
+		"trade_state":    "SUCCESS",
+		"success_time":   "2024-01-01T08:00:00+08:00",
+		"amount":         map[string]int64{"total": 990},
+	}
+}
+
+// TestParseWebhook_Paid 验签并解密支付成功通知
+func TestParseWebhook_Paid(t *testing.T) {
+	provider, platform := newTestProvider(t)
+
+	event, err := provider.ParseWebhook(context.Background(), platform.notification(t, eventTransactionSuccess, transaction("1900000001")))
+	require.NoError(t, err)
+	assert.Equal(t, payment.PaidEventID("NO1"), event.ID)
+	assert.Equal(t, payment.EventPaid, event.Type)
+	assert.Equal(t, "4200000001", event.TradeNo)
+	assert.Equal(t, int64(990), event.Amount)
+	assert.True(t, event.OccurredAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+// TestParseWebhook_Refund 退款成功与异常通知分别解析为退款完成与退款失败事件
+func TestParseWebhook_Refund(t *testing.T) {
+	provider, platform := newTestProvider(t)
+	refund := map[string]interface{}{
+		"out_trade_no":   "NO1",
+		"transaction_id": "4200000001",
+		"out_refund_no":  "NO1R1",
+		"amount":         map[string]int64{"total": 990, "refund": 400},
+	}
+
+	event, err := provider.ParseWebhook(context.Background(), platform.notification(t, eventRefundSuccess, refund))
+	require.NoError(t, err)
+	assert.Equal(t, payment.RefundEventID("NO1R1"), event.ID)
+	assert.Equal(t, payment.EventRefunded, event.Type)
+	assert.Equal(t, int64(400), event.Amount)
+
+	event, err = provider.ParseWebhook(context.Background(), platform.notification(t, eventRefundAbnormal, refund))
+	require.NoError(t, err)
+	assert.Equal(t, payment.RefundFailedEventID("NO1R1"), event.ID)
+	assert.Equal(t, payment.EventRefundFailed, event.Type)
+}
+
+// TestParseWebhook_InvalidSignature 签名与报文不符、签名过期或其他商户的通知都按签名错误拒绝
+func TestParseWebhook_InvalidSignature(t *testing.T) {
+	provider, platform := newTestProvider(t)
+
+	req := platform.notification(t, eventTransactionSuccess, transaction("1900000001"))
+	req.Header.Set("Wechatpay-Nonce", "nonce-2")
+	_, err := provider.ParseWebhook(context.Background(), req)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+
+	req = platform.notification(t, eventTransactionSuccess, transaction("1900000001"))
+	req.Header.Set("Wechatpay-Timestamp", strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10))
+	_, err = provider.ParseWebhook(context.Background(), req)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+
+	_, err = provider.ParseWebhook(context.Background(), platform.notification(t, eventTransactionSuccess, transaction("1900000002")))
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
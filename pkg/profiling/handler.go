@@ -0,0 +1,119 @@
+package profiling
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPprofRoutes 在 /debug/pprof 下注册标准 pprof 接口，调用方需挂载管理员权限校验。
+// 挂载在路由组下时 pprof.Index 无法从路径解析剖析名称，命名剖析由 pprof.Handler 单独提供
+func RegisterPprofRoutes(group *gin.RouterGroup) {
+	group.GET("/debug/pprof/", gin.WrapF(pprof.Index))
+	group.GET("/debug/pprof/:name", servePprof)
+	group.POST("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+}
+
+func servePprof(c *gin.Context) {
+	switch name := c.Param("name"); name {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// CaptureRequest 手动采集请求
+type CaptureRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// Handler 剖析采集管理接口
+type Handler struct {
+	profiler *Profiler
+}
+
+// NewHandler 创建剖析采集管理接口
+func NewHandler(profiler *Profiler) *Handler {
+	return &Handler{profiler: profiler}
+}
+
+// RegisterAdminRoutes 注册采集查询、下载与手动采集路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/profiles", h.ListCaptures)
+	group.POST("/profiles", h.CreateCapture)
+	group.GET("/profiles/:id", h.GetCapture)
+	group.GET("/profiles/:id/:kind", h.DownloadProfile)
+}
+
+// ListCaptures 已保存的采集，按时间倒序
+func (h *Handler) ListCaptures(c *gin.Context) {
+	captures, err := h.profiler.List()
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"captures": captures})
+}
+
+// CreateCapture 立即采集，CPU 剖析期间请求保持等待
+func (h *Handler) CreateCapture(c *gin.Context) {
+	var req CaptureRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "manual capture by " + c.GetString("userID")
+	}
+
+	capture, err := h.profiler.Capture(c.Request.Context(), Request{Trigger: TriggerManual, Reason: req.Reason})
+	if errors.Is(err, ErrCaptureInProgress) {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, ""))
+		return
+	}
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	c.JSON(http.StatusCreated, capture)
+}
+
+// GetCapture 采集元数据
+func (h *Handler) GetCapture(c *gin.Context) {
+	capture, err := h.profiler.Get(c.Param("id"))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, capture)
+}
+
+// DownloadProfile 下载剖析文件，可直接用 go tool pprof 打开
+func (h *Handler) DownloadProfile(c *gin.Context) {
+	path, err := h.profiler.ProfilePath(c.Param("id"), Kind(c.Param("kind")))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.FileAttachment(path, c.Param("id")+"-"+c.Param("kind")+".pb.gz")
+}
+
+func renderError(c *gin.Context, err error) {
+	if errors.Is(err, ErrCaptureNotFound) {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+		return
+	}
+	apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+}
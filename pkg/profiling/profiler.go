@@ -0,0 +1,426 @@
+package profiling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/security"
+)
+
+// Kind 剖析类型
+type Kind string
+
+const (
+	KindCPU       Kind = "cpu"
+	KindHeap      Kind = "heap"
+	KindGoroutine Kind = "goroutine"
+)
+
+// Trigger 采集原因
+type Trigger string
+
+const (
+	TriggerManual       Trigger = "manual"
+	TriggerGoroutines   Trigger = "goroutines"    // goroutine 数超过阈值
+	TriggerSlowRequests Trigger = "slow_requests" // 时间窗口内的慢请求数超过阈值
+)
+
+var (
+	ErrCaptureNotFound   = errors.New("profile capture not found")
+	ErrCaptureInProgress = errors.New("profile capture in progress")
+	ErrCaptureCooldown   = errors.New("profile capture cooling down")
+)
+
+// Config 自动剖析配置
+type Config struct {
+	Dir                  string        `json:"dir"` // 剖析文件存放目录，每次采集一个子目录
+	Kinds                []Kind        `json:"kinds"`
+	CPUDuration          time.Duration `json:"cpu_duration"`
+	Cooldown             time.Duration `json:"cooldown"` // 两次自动采集的最小间隔，手动采集不受限制
+	Retention            time.Duration `json:"retention"`
+	MaxCaptures          int           `json:"max_captures"` // 超过后删除最早的采集
+	SampleInterval       time.Duration `json:"sample_interval"`
+	GoroutineThreshold   int           `json:"goroutine_threshold"`    // 为 0 时不按 goroutine 数触发
+	SlowRequestThreshold int           `json:"slow_request_threshold"` // 为 0 时不按慢请求触发
+	SlowRequestWindow    time.Duration `json:"slow_request_window"`
+}
+
+// DefaultConfig 默认自动剖析配置
+func DefaultConfig() *Config {
+	return &Config{
+		Dir:                  filepath.Join(os.TempDir(), "profiles"),
+		Kinds:                []Kind{KindCPU, KindHeap, KindGoroutine},
+		CPUDuration:          10 * time.Second,
+		Cooldown:             10 * time.Minute,
+		Retention:            7 * 24 * time.Hour,
+		MaxCaptures:          50,
+		SampleInterval:       10 * time.Second,
+		GoroutineThreshold:   10000,
+		SlowRequestThreshold: 5,
+		SlowRequestWindow:    time.Minute,
+	}
+}
+
+// Capture 一次采集的元数据，与剖析文件一起存放
+type Capture struct {
+	ID         string          `json:"id"`
+	Trigger    Trigger         `json:"trigger"`
+	Reason     string          `json:"reason"`
+	Value      int             `json:"value,omitempty"`     // 触发时的 goroutine 数或慢请求数
+	Threshold  int             `json:"threshold,omitempty"` // 对应的阈值
+	EventID    string          `json:"event_id,omitempty"`  // 触发采集的最后一个安全事件
+	Files      map[Kind]int64  `json:"files"`               // 成功写入的剖析文件及大小
+	Errors     map[Kind]string `json:"errors,omitempty"`
+	Goroutines int             `json:"goroutines"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+}
+
+// Request 采集请求
+type Request struct {
+	Trigger   Trigger
+	Reason    string
+	Value     int
+	Threshold int
+	EventID   string
+}
+
+const metadataFile = "capture.json"
+
+var captureIDPattern = regexp.MustCompile(`^prof_[0-9]+$`)
+
+// Profiler 自动剖析器：goroutine 数或慢请求超过阈值时采集 CPU、堆与 goroutine 剖析，
+// 写入本地目录并按保留期清理。自动采集后记录 performance_anomaly 安全事件，链接到采集结果
+type Profiler struct {
+	config       *Config
+	monitor      *security.SecurityMonitor
+	numGoroutine func() int
+	mu           sync.Mutex
+	capturing    bool
+	lastCapture  time.Time
+	slow         []time.Time
+}
+
+// NewProfiler 创建自动剖析器，monitor 为 nil 时不记录事件
+func NewProfiler(config *Config, monitor *security.SecurityMonitor) (*Profiler, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if err := os.MkdirAll(config.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create profile dir: %w", err)
+	}
+	return &Profiler{
+		config:       config,
+		monitor:      monitor,
+		numGoroutine: runtime.NumGoroutine,
+	}, nil
+}
+
+// Run 定期检查 goroutine 数并清理过期采集，阻塞直到 ctx 取消
+func (p *Profiler) Run(ctx context.Context) {
+	if _, err := p.Cleanup(); err != nil {
+		log.Printf("Profile cleanup failed: %v", err)
+	}
+
+	ticker := time.NewTicker(p.config.SampleInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkGoroutines(ctx)
+			if time.Since(lastCleanup) > time.Hour {
+				if _, err := p.Cleanup(); err != nil {
+					log.Printf("Profile cleanup failed: %v", err)
+				}
+				lastCleanup = time.Now()
+			}
+		}
+	}
+}
+
+func (p *Profiler) checkGoroutines(ctx context.Context) {
+	threshold := p.config.GoroutineThreshold
+	if threshold <= 0 {
+		return
+	}
+	if n := p.numGoroutine(); n > threshold {
+		p.triggerAsync(ctx, Request{
+			Trigger:   TriggerGoroutines,
+			Reason:    fmt.Sprintf("%d goroutines exceed threshold %d", n, threshold),
+			Value:     n,
+			Threshold: threshold,
+		})
+	}
+}
+
+// OnSecurityEvent 统计时间窗口内的慢请求，超过阈值时采集
+func (p *Profiler) OnSecurityEvent(ctx context.Context, event security.SecurityEvent) {
+	threshold := p.config.SlowRequestThreshold
+	if event.Type != security.EventSlowRequest || threshold <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	cutoff := event.Timestamp.Add(-p.config.SlowRequestWindow)
+	kept := p.slow[:0]
+	for _, at := range p.slow {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	p.slow = append(kept, event.Timestamp)
+	count := len(p.slow)
+	if count >= threshold {
+		p.slow = nil
+	}
+	p.mu.Unlock()
+
+	if count >= threshold {
+		p.triggerAsync(ctx, Request{
+			Trigger:   TriggerSlowRequests,
+			Reason:    fmt.Sprintf("%d slow requests within %s, last %s %s", count, p.config.SlowRequestWindow, event.Method, event.Path),
+			Value:     count,
+			Threshold: threshold,
+			EventID:   event.ID,
+		})
+	}
+}
+
+// triggerAsync 在冷却期外后台采集，不阻塞调用方
+func (p *Profiler) triggerAsync(ctx context.Context, req Request) {
+	if err := p.begin(false); err != nil {
+		return
+	}
+	go func() {
+		defer p.end()
+		captureCtx := context.WithoutCancel(ctx)
+		capture, err := p.capture(captureCtx, req)
+		if err != nil {
+			log.Printf("Automatic profile capture failed: %v", err)
+			return
+		}
+		p.recordEvent(captureCtx, capture)
+	}()
+}
+
+// Capture 立即采集，用于手动触发；同一时间只进行一次采集
+func (p *Profiler) Capture(ctx context.Context, req Request) (*Capture, error) {
+	if err := p.begin(true); err != nil {
+		return nil, err
+	}
+	defer p.end()
+	return p.capture(ctx, req)
+}
+
+func (p *Profiler) begin(manual bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.capturing {
+		return ErrCaptureInProgress
+	}
+	if !manual && time.Since(p.lastCapture) < p.config.Cooldown {
+		return ErrCaptureCooldown
+	}
+	p.capturing = true
+	p.lastCapture = time.Now()
+	return nil
+}
+
+func (p *Profiler) end() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.capturing = false
+}
+
+// capture 依次写入各类剖析，单项失败（如 CPU 剖析正被 pprof 接口占用）记入 Errors，其余照常写入
+func (p *Profiler) capture(ctx context.Context, req Request) (*Capture, error) {
+	now := time.Now()
+	capture := &Capture{
+		ID:         fmt.Sprintf("prof_%d", now.UnixNano()),
+		Trigger:    req.Trigger,
+		Reason:     req.Reason,
+		Value:      req.Value,
+		Threshold:  req.Threshold,
+		EventID:    req.EventID,
+		Files:      make(map[Kind]int64),
+		Errors:     make(map[Kind]string),
+		Goroutines: p.numGoroutine(),
+		StartedAt:  now,
+	}
+	dir := filepath.Join(p.config.Dir, capture.ID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create capture dir: %w", err)
+	}
+
+	for _, kind := range p.config.Kinds {
+		size, err := p.writeProfile(ctx, filepath.Join(dir, string(kind)+".pb.gz"), kind)
+		if err != nil {
+			capture.Errors[kind] = err.Error()
+			continue
+		}
+		capture.Files[kind] = size
+	}
+	capture.FinishedAt = time.Now()
+
+	data, err := json.MarshalIndent(capture, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal capture: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, metadataFile), data, 0o640); err != nil {
+		return nil, fmt.Errorf("failed to write capture metadata: %w", err)
+	}
+
+	if _, err := p.Cleanup(); err != nil {
+		log.Printf("Profile cleanup failed: %v", err)
+	}
+	return capture, nil
+}
+
+func (p *Profiler) writeProfile(ctx context.Context, path string, kind Kind) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	switch kind {
+	case KindCPU:
+		if err := pprof.StartCPUProfile(file); err != nil {
+			os.Remove(path)
+			return 0, err
+		}
+		select {
+		case <-time.After(p.config.CPUDuration):
+		case <-ctx.Done():
+		}
+		pprof.StopCPUProfile()
+	case KindHeap, KindGoroutine:
+		if err := pprof.Lookup(string(kind)).WriteTo(file, 0); err != nil {
+			os.Remove(path)
+			return 0, err
+		}
+	default:
+		os.Remove(path)
+		return 0, fmt.Errorf("unsupported profile kind %q", kind)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// recordEvent 记录性能异常事件，告警与事件查询中可据此找到剖析文件
+func (p *Profiler) recordEvent(ctx context.Context, capture *Capture) {
+	if p.monitor == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"trigger":     string(capture.Trigger),
+		"value":       capture.Value,
+		"threshold":   capture.Threshold,
+		"profile_id":  capture.ID,
+		"profile_url": "/admin/profiles/" + capture.ID,
+	}
+	if capture.EventID != "" {
+		details["trigger_event_id"] = capture.EventID
+	}
+	p.monitor.RecordEvent(ctx, security.SecurityEvent{
+		Type:    security.EventPerformanceAnomaly,
+		Level:   security.LevelWarning,
+		Source:  "profiler",
+		Message: capture.Reason,
+		Details: details,
+	})
+}
+
+// List 已保存的采集，按时间倒序
+func (p *Profiler) List() ([]Capture, error) {
+	entries, err := os.ReadDir(p.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile dir: %w", err)
+	}
+	captures := make([]Capture, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !captureIDPattern.MatchString(entry.Name()) {
+			continue
+		}
+		capture, err := p.Get(entry.Name())
+		if err != nil {
+			// 采集中尚未写入元数据
+			continue
+		}
+		captures = append(captures, *capture)
+	}
+	sort.Slice(captures, func(i, j int) bool { return captures[i].StartedAt.After(captures[j].StartedAt) })
+	return captures, nil
+}
+
+// Get 读取采集元数据
+func (p *Profiler) Get(id string) (*Capture, error) {
+	if !captureIDPattern.MatchString(id) {
+		return nil, ErrCaptureNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(p.config.Dir, id, metadataFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCaptureNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture metadata: %w", err)
+	}
+	var capture Capture
+	if err := json.Unmarshal(data, &capture); err != nil {
+		return nil, fmt.Errorf("failed to decode capture metadata: %w", err)
+	}
+	return &capture, nil
+}
+
+// ProfilePath 剖析文件路径
+func (p *Profiler) ProfilePath(id string, kind Kind) (string, error) {
+	capture, err := p.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := capture.Files[kind]; !ok {
+		return "", ErrCaptureNotFound
+	}
+	return filepath.Join(p.config.Dir, id, string(kind)+".pb.gz"), nil
+}
+
+// Cleanup 删除超过保留期或超出数量上限的采集，返回删除的数量；两项配置为 0 时不限制
+func (p *Profiler) Cleanup() (int, error) {
+	captures, err := p.List()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-p.config.Retention)
+	removed := 0
+	for i, capture := range captures {
+		withinCount := p.config.MaxCaptures <= 0 || i < p.config.MaxCaptures
+		withinAge := p.config.Retention <= 0 || capture.StartedAt.After(cutoff)
+		if withinCount && withinAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(p.config.Dir, capture.ID)); err != nil {
+			return removed, fmt.Errorf("failed to remove capture %s: %w", capture.ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+var _ security.SecurityEventListener = (*Profiler)(nil)
@@ -0,0 +1,157 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProfiler(t *testing.T, configure func(*Config)) (*Profiler, *security.SecurityMonitor) {
+	t.Helper()
+	config := DefaultConfig()
+	config.Dir = t.TempDir()
+	config.CPUDuration = 50 * time.Millisecond
+	if configure != nil {
+		configure(config)
+	}
+	monitor := security.NewSecurityMonitor(fakes.NewCache(nil), metrics.GetGlobalCollector(), security.NewDefaultSecurityLogger())
+	profiler, err := NewProfiler(config, monitor)
+	require.NoError(t, err)
+	monitor.AddEventListener(profiler)
+	return profiler, monitor
+}
+
+// anomalies 剖析器记录的性能异常事件
+func anomalies(monitor *security.SecurityMonitor) []security.SecurityEvent {
+	var events []security.SecurityEvent
+	for _, event := range monitor.GenerateReport(time.Minute).Events {
+		if event.Type == security.EventPerformanceAnomaly {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// TestProfiler_SlowRequests 窗口内慢请求达到阈值时采集一次，冷却期内不再采集，事件链接到采集结果
+func TestProfiler_SlowRequests(t *testing.T) {
+	profiler, monitor := newTestProfiler(t, func(c *Config) { c.SlowRequestThreshold = 3 })
+	ctx := context.Background()
+	slow := func(id string) {
+		monitor.RecordEvent(ctx, security.SecurityEvent{ID: id, Type: security.EventSlowRequest, Level: security.LevelWarning,
+			Method: http.MethodGet, Path: "/api/v1/moments"})
+	}
+
+	slow("evt_1")
+	slow("evt_2")
+	assert.Empty(t, anomalies(monitor))
+	slow("evt_3")
+	require.Eventually(t, func() bool { return len(anomalies(monitor)) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	event := anomalies(monitor)[0]
+	assert.Equal(t, security.LevelWarning, event.Level)
+	assert.Equal(t, "evt_3", event.Details["trigger_event_id"])
+	id := event.Details["profile_id"].(string)
+	assert.Equal(t, "/admin/profiles/"+id, event.Details["profile_url"])
+
+	capture, err := profiler.Get(id)
+	require.NoError(t, err)
+	assert.Equal(t, TriggerSlowRequests, capture.Trigger)
+	assert.Equal(t, 3, capture.Value)
+	assert.Contains(t, capture.Reason, "GET /api/v1/moments")
+	for _, kind := range []Kind{KindCPU, KindHeap, KindGoroutine} {
+		assert.Positive(t, capture.Files[kind], kind)
+	}
+
+	for _, id := range []string{"evt_4", "evt_5", "evt_6"} {
+		slow(id)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, anomalies(monitor), 1, "cooldown suppresses the second capture")
+}
+
+// TestProfiler_GoroutineThreshold goroutine 数超过阈值时采集
+func TestProfiler_GoroutineThreshold(t *testing.T) {
+	profiler, monitor := newTestProfiler(t, func(c *Config) {
+		c.GoroutineThreshold = 100
+		c.Kinds = []Kind{KindGoroutine}
+	})
+	profiler.numGoroutine = func() int { return 100 }
+	profiler.checkGoroutines(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, anomalies(monitor))
+
+	profiler.numGoroutine = func() int { return 250 }
+	profiler.checkGoroutines(context.Background())
+	require.Eventually(t, func() bool { return len(anomalies(monitor)) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "250 goroutines exceed threshold 100", anomalies(monitor)[0].Message)
+}
+
+// TestProfiler_Retention 超出数量上限时删除最早的采集
+func TestProfiler_Retention(t *testing.T) {
+	profiler, _ := newTestProfiler(t, func(c *Config) {
+		c.MaxCaptures = 2
+		c.Kinds = []Kind{KindHeap}
+	})
+	var ids []string
+	for i := 0; i < 3; i++ {
+		capture, err := profiler.Capture(context.Background(), Request{Trigger: TriggerManual, Reason: "test"})
+		require.NoError(t, err)
+		ids = append(ids, capture.ID)
+	}
+
+	captures, err := profiler.List()
+	require.NoError(t, err)
+	require.Len(t, captures, 2)
+	assert.Equal(t, ids[2], captures[0].ID)
+	assert.Equal(t, ids[1], captures[1].ID)
+	_, err = profiler.Get(ids[0])
+	assert.ErrorIs(t, err, ErrCaptureNotFound)
+	_, err = profiler.Get("../" + ids[1])
+	assert.ErrorIs(t, err, ErrCaptureNotFound)
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	profiler, _ := newTestProfiler(t, func(c *Config) { c.Kinds = []Kind{KindHeap, KindGoroutine} })
+	router := gin.New()
+	group := router.Group("/admin")
+	RegisterPprofRoutes(group)
+	NewHandler(profiler).RegisterAdminRoutes(group)
+	serve := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/admin/debug/pprof/", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+	w = serve(http.MethodGet, "/admin/debug/pprof/goroutine?debug=1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile: total")
+
+	w = serve(http.MethodPost, "/admin/profiles", []byte(`{"reason":"INC-7 latency spike"}`))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var capture Capture
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &capture))
+	assert.Equal(t, "INC-7 latency spike", capture.Reason)
+
+	w = serve(http.MethodGet, "/admin/profiles/"+capture.ID+"/heap", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []byte{0x1f, 0x8b}, w.Body.Bytes()[:2], "pprof profiles are gzipped")
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/profiles/"+capture.ID+"/cpu", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/admin/profiles/prof_1", nil).Code)
+}
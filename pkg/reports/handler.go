@@ -0,0 +1,106 @@
+package reports
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportQuery 预览与立即投递的参数
+type ReportQuery struct {
+	Format Format    `form:"format"` // 仅预览使用，默认 html
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// Handler 定时报告管理接口
+type Handler struct {
+	scheduler *Scheduler
+}
+
+// NewHandler 创建定时报告管理接口
+func NewHandler(scheduler *Scheduler) *Handler {
+	return &Handler{scheduler: scheduler}
+}
+
+// RegisterAdminRoutes 注册报告路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/reports", h.ListReports)
+	group.GET("/reports/:name/preview", h.PreviewReport)
+	group.POST("/reports/:name/deliver", h.DeliverReport)
+}
+
+// ListReports 已配置的报告与下次生成时间
+func (h *Handler) ListReports(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"reports": h.scheduler.Reports()})
+}
+
+// PreviewReport 生成报告并直接返回，不发送
+func (h *Handler) PreviewReport(c *gin.Context) {
+	query, ok := bindQuery(c)
+	if !ok {
+		return
+	}
+	if query.Format == "" {
+		query.Format = FormatHTML
+	}
+
+	report, err := h.scheduler.Generate(c.Request.Context(), c.Param("name"), query.To)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	body, err := Export(report, query.Format)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	switch query.Format {
+	case FormatJSON:
+		contentType = "application/json; charset=utf-8"
+	case FormatHTML:
+		contentType = "text/html; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, []byte(body))
+}
+
+// DeliverReport 立即生成并发送给全部接收方，用于验证接收方配置
+func (h *Handler) DeliverReport(c *gin.Context) {
+	query, ok := bindQuery(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.scheduler.Deliver(c.Request.Context(), c.Param("name"), query.To)
+	if err != nil && delivery == nil {
+		renderError(c, err)
+		return
+	}
+	// 全部接收方失败时仍返回各自的错误，便于排查
+	c.JSON(http.StatusOK, delivery)
+}
+
+// bindQuery 解析参数，区间终点默认为当前时间
+func bindQuery(c *gin.Context) (*ReportQuery, bool) {
+	var query ReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return nil, false
+	}
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	return &query, true
+}
+
+func renderError(c *gin.Context, err error) {
+	if errors.Is(err, ErrReportNotFound) {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+		return
+	}
+	apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+}
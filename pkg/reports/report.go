@@ -0,0 +1,141 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report 一份运维报告，由数据源生成各章节，调度器补充名称与统计区间
+type Report struct {
+	Name        string      `json:"name"`
+	Title       string      `json:"title"`
+	From        time.Time   `json:"from"`
+	To          time.Time   `json:"to"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Sections    []Section   `json:"sections"`
+	Data        interface{} `json:"data,omitempty"` // 数据源的原始结果，仅 JSON 格式输出
+}
+
+// Section 报告章节：指标、表格与说明均可为空
+type Section struct {
+	Title   string     `json:"title"`
+	Facts   []Fact     `json:"facts,omitempty"`
+	Columns []string   `json:"columns,omitempty"`
+	Rows    [][]string `json:"rows,omitempty"`
+	Notes   []string   `json:"notes,omitempty"`
+}
+
+// Fact 单项指标
+type Fact struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Source 报告数据源，生成 [from, to) 区间的报告
+type Source interface {
+	Build(ctx context.Context, from, to time.Time) (*Report, error)
+}
+
+// SourceFunc 函数形式的 Source
+type SourceFunc func(ctx context.Context, from, to time.Time) (*Report, error)
+
+// Build 实现 Source
+func (f SourceFunc) Build(ctx context.Context, from, to time.Time) (*Report, error) {
+	return f(ctx, from, to)
+}
+
+// Format 报告输出格式
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatText     Format = "text"
+	FormatMarkdown Format = "markdown" // 纯文本置于代码块中，用于 Slack 等等宽显示受限的渠道
+	FormatHTML     Format = "html"
+)
+
+// Subject 报告标题与统计区间，用作邮件主题
+func (r *Report) Subject() string {
+	return fmt.Sprintf("%s %s ~ %s", r.Title, r.From.Format(time.DateTime), r.To.Format(time.DateTime))
+}
+
+// Export 按格式输出报告
+func Export(report *Report, format Format) (string, error) {
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal report: %w", err)
+		}
+		return string(data), nil
+	case FormatText:
+		return exportText(report), nil
+	case FormatMarkdown:
+		return "```\n" + exportText(report) + "```", nil
+	case FormatHTML:
+		return exportHTML(report)
+	default:
+		return "", fmt.Errorf("unsupported report format %q", format)
+	}
+}
+
+// exportText 指标与表格按列对齐
+func exportText(report *Report) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n", report.Subject())
+	for _, section := range report.Sections {
+		fmt.Fprintf(&buf, "\n== %s ==\n", section.Title)
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		for _, fact := range section.Facts {
+			fmt.Fprintf(w, "%s:\t%s\n", fact.Label, fact.Value)
+		}
+		w.Flush()
+		if len(section.Columns) > 0 {
+			if len(section.Facts) > 0 {
+				buf.WriteString("\n")
+			}
+			w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, strings.Join(section.Columns, "\t"))
+			for _, row := range section.Rows {
+				fmt.Fprintln(w, strings.Join(row, "\t"))
+			}
+			if len(section.Rows) == 0 {
+				fmt.Fprintln(w, "(none)")
+			}
+			w.Flush()
+		}
+		for _, note := range section.Notes {
+			fmt.Fprintf(&buf, "- %s\n", note)
+		}
+	}
+	return buf.String()
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title>
+<style>body{font-family:sans-serif;font-size:14px}table{border-collapse:collapse;margin:8px 0}td,th{border:1px solid #ccc;padding:4px 8px;text-align:left}th{background:#f3f3f3}</style>
+</head><body>
+<h2>{{.Title}}</h2>
+<p>{{.From.Format "2006-01-02 15:04:05"}} ~ {{.To.Format "2006-01-02 15:04:05"}}</p>
+{{range .Sections}}<h3>{{.Title}}</h3>
+{{if .Facts}}<table>{{range .Facts}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>{{end}}</table>
+{{end}}{{if .Columns}}<table><tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>{{else}}<tr><td colspan="{{len .Columns}}">(none)</td></tr>{{end}}</table>
+{{end}}{{if .Notes}}<ul>{{range .Notes}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{end}}<p><small>Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05"}}</small></p>
+</body></html>
+`))
+
+// exportHTML 邮件正文，内容经 html/template 转义
+func exportHTML(report *Report) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, report); err != nil {
+		return "", fmt.Errorf("failed to render html report: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,367 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/notify"
+)
+
+// JobType 定时生成并投递报告的任务类型
+const JobType = "reports.deliver"
+
+// ErrReportNotFound 报告未配置
+var ErrReportNotFound = errors.New("report not found")
+
+// 常用调度周期，其他取值按 cron 表达式解析
+const (
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+var scheduleSpecs = map[string]string{
+	ScheduleDaily:  "@daily",
+	ScheduleWeekly: "@weekly",
+}
+
+var schedulePeriods = map[string]time.Duration{
+	ScheduleDaily:  24 * time.Hour,
+	ScheduleWeekly: 7 * 24 * time.Hour,
+}
+
+// Recipient 报告接收方
+type Recipient struct {
+	Channel notify.Channel `json:"channel"`
+	To      string         `json:"to"`     // 邮箱、Slack 或 Webhook 地址
+	Format  Format         `json:"format"` // 为空时按渠道选择，见 defaultFormat
+}
+
+// ReportConfig 单份定时报告
+type ReportConfig struct {
+	Name       string        `json:"name"`
+	Source     string        `json:"source"`   // 数据源名称，如 cache、database、security
+	Schedule   string        `json:"schedule"` // daily、weekly 或 cron 表达式
+	Period     time.Duration `json:"period"`   // 统计区间，为 0 时 daily 为 24 小时、weekly 为 7 天，cron 表达式须指定
+	Recipients []Recipient   `json:"recipients"`
+}
+
+// Config 报告调度配置
+type Config struct {
+	Reports     []ReportConfig `json:"reports"`
+	SendTimeout time.Duration  `json:"send_timeout"` // 单个接收方的发送超时
+}
+
+// DefaultConfig 默认报告调度配置
+func DefaultConfig() *Config {
+	return &Config{
+		SendTimeout: 30 * time.Second,
+	}
+}
+
+// ReportInfo 报告配置的接口视图，不包含接收地址
+type ReportInfo struct {
+	Name     string           `json:"name"`
+	Source   string           `json:"source"`
+	Schedule string           `json:"schedule"`
+	Period   string           `json:"period"`
+	Channels []notify.Channel `json:"channels"`
+	Next     time.Time        `json:"next"`
+}
+
+// RecipientResult 单个接收方的投递结果
+type RecipientResult struct {
+	Channel notify.Channel `json:"channel"`
+	Format  Format         `json:"format"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Delivery 一次报告投递的结果
+type Delivery struct {
+	Report     string            `json:"report"`
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Recipients []RecipientResult `json:"recipients"`
+}
+
+// deliverPayload 定时任务参数
+type deliverPayload struct {
+	Report string `json:"report"`
+}
+
+// Scheduler 定时生成缓存、数据库与安全报告，按接收方的渠道输出为对应格式并通过通知驱动发送。
+// 调度依托 jobs.Manager 的定时任务，多实例部署时每个触发时间只投递一次。
+type Scheduler struct {
+	config           *Config
+	sources          map[string]Source
+	drivers          map[notify.Channel]notify.Driver
+	mu               sync.RWMutex
+	metricsCollector *metrics.MetricsCollector
+}
+
+// NewScheduler 创建报告调度器
+func NewScheduler(config *Config) *Scheduler {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Scheduler{
+		config:           config,
+		sources:          make(map[string]Source),
+		drivers:          make(map[notify.Channel]notify.Driver),
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// AddSource 注册数据源
+func (s *Scheduler) AddSource(name string, source Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[name] = source
+}
+
+// RegisterDriver 注册渠道驱动
+func (s *Scheduler) RegisterDriver(driver notify.Driver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drivers[driver.Channel()] = driver
+}
+
+// Validate 检查报告名称、数据源、调度周期、接收方渠道与格式
+func (s *Scheduler) Validate() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make(map[string]bool)
+	for _, report := range s.config.Reports {
+		if report.Name == "" {
+			return errors.New("report name is required")
+		}
+		if names[report.Name] {
+			return fmt.Errorf("duplicate report %q", report.Name)
+		}
+		names[report.Name] = true
+
+		if _, ok := s.sources[report.Source]; !ok {
+			return fmt.Errorf("report %q: unknown source %q", report.Name, report.Source)
+		}
+		if _, err := jobs.ParseCron(specOf(report.Schedule)); err != nil {
+			return fmt.Errorf("report %q: %w", report.Name, err)
+		}
+		if periodOf(&report) <= 0 {
+			return fmt.Errorf("report %q: period is required for schedule %q", report.Name, report.Schedule)
+		}
+		if len(report.Recipients) == 0 {
+			return fmt.Errorf("report %q: at least one recipient is required", report.Name)
+		}
+		for _, recipient := range report.Recipients {
+			if _, ok := s.drivers[recipient.Channel]; !ok {
+				return fmt.Errorf("report %q: no driver registered for channel %q", report.Name, recipient.Channel)
+			}
+			if recipient.To == "" {
+				return fmt.Errorf("report %q: recipient address is required for channel %q", report.Name, recipient.Channel)
+			}
+			switch formatOf(recipient) {
+			case FormatJSON, FormatText, FormatMarkdown, FormatHTML:
+			default:
+				return fmt.Errorf("report %q: unsupported format %q", report.Name, recipient.Format)
+			}
+		}
+	}
+	return nil
+}
+
+// Register 校验配置，在任务管理器上注册投递任务并为每份报告创建定时任务
+func (s *Scheduler) Register(manager *jobs.Manager) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	manager.Register(JobType, s.handleJob)
+	for _, report := range s.config.Reports {
+		if err := manager.Schedule("report:"+report.Name, specOf(report.Schedule), JobType,
+			deliverPayload{Report: report.Name}, jobs.PriorityLow); err != nil {
+			return fmt.Errorf("failed to schedule report %s: %w", report.Name, err)
+		}
+	}
+	return nil
+}
+
+// handleJob 以任务的计划执行时间作为区间终点，重试时报告内容不变
+func (s *Scheduler) handleJob(ctx context.Context, job *jobs.Job) error {
+	var payload deliverPayload
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode report job: %w", err)
+	}
+	_, err := s.Deliver(ctx, payload.Report, job.RunAt)
+	return err
+}
+
+// Reports 已配置的报告，按名称排序
+func (s *Scheduler) Reports() []ReportInfo {
+	now := time.Now()
+	infos := make([]ReportInfo, 0, len(s.config.Reports))
+	for _, report := range s.config.Reports {
+		info := ReportInfo{
+			Name:     report.Name,
+			Source:   report.Source,
+			Schedule: report.Schedule,
+			Period:   periodOf(&report).String(),
+			Channels: make([]notify.Channel, 0, len(report.Recipients)),
+		}
+		if schedule, err := jobs.ParseCron(specOf(report.Schedule)); err == nil {
+			info.Next = schedule.Next(now)
+		}
+		for _, recipient := range report.Recipients {
+			info.Channels = append(info.Channels, recipient.Channel)
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Generate 生成截至 to 的一个统计区间的报告
+func (s *Scheduler) Generate(ctx context.Context, name string, to time.Time) (*Report, error) {
+	config, ok := s.report(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReportNotFound, name)
+	}
+	s.mu.RLock()
+	source, ok := s.sources[config.Source]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("report %s: unknown source %q", name, config.Source)
+	}
+
+	start := time.Now()
+	from := to.Add(-periodOf(config))
+	report, err := source.Build(ctx, from, to)
+	s.recordMetrics("generate_"+config.Source, time.Since(start), err == nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build report %s: %w", name, err)
+	}
+	report.Name = name
+	report.From = from
+	report.To = to
+	report.GeneratedAt = time.Now()
+	return report, nil
+}
+
+// Deliver 生成报告并发送给全部接收方。部分接收方失败时只记录日志，全部失败才返回错误，
+// 避免任务重试时向已收到的接收方重复发送
+func (s *Scheduler) Deliver(ctx context.Context, name string, to time.Time) (*Delivery, error) {
+	report, err := s.Generate(ctx, name, to)
+	if err != nil {
+		return nil, err
+	}
+	config, _ := s.report(name)
+
+	delivery := &Delivery{Report: name, From: report.From, To: report.To}
+	rendered := make(map[Format]string)
+	var errs []error
+	for _, recipient := range config.Recipients {
+		format := formatOf(recipient)
+		result := RecipientResult{Channel: recipient.Channel, Format: format}
+		if err := s.send(ctx, report, recipient, format, rendered); err != nil {
+			log.Printf("Failed to deliver report %s via %s: %v", name, recipient.Channel, err)
+			result.Error = err.Error()
+			errs = append(errs, err)
+		}
+		delivery.Recipients = append(delivery.Recipients, result)
+	}
+
+	if len(errs) == len(config.Recipients) && len(errs) > 0 {
+		return delivery, fmt.Errorf("failed to deliver report %s: %w", name, errors.Join(errs...))
+	}
+	return delivery, nil
+}
+
+// send 同一格式只渲染一次
+func (s *Scheduler) send(ctx context.Context, report *Report, recipient Recipient, format Format, rendered map[Format]string) error {
+	s.mu.RLock()
+	driver, ok := s.drivers[recipient.Channel]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no driver registered for channel %s", recipient.Channel)
+	}
+
+	body, ok := rendered[format]
+	if !ok {
+		var err error
+		if body, err = Export(report, format); err != nil {
+			return err
+		}
+		rendered[format] = body
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, s.config.SendTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := driver.Send(sendCtx, &notify.Message{
+		Channel:  recipient.Channel,
+		Category: "report:" + report.Name,
+		To:       recipient.To,
+		Subject:  report.Subject(),
+		Body:     body,
+		HTML:     format == FormatHTML,
+	})
+	s.recordMetrics("send_"+string(recipient.Channel), time.Since(start), err == nil)
+	return err
+}
+
+func (s *Scheduler) report(name string) (*ReportConfig, bool) {
+	for i := range s.config.Reports {
+		if s.config.Reports[i].Name == name {
+			return &s.config.Reports[i], true
+		}
+	}
+	return nil, false
+}
+
+func specOf(schedule string) string {
+	if spec, ok := scheduleSpecs[schedule]; ok {
+		return spec
+	}
+	return schedule
+}
+
+func periodOf(report *ReportConfig) time.Duration {
+	if report.Period > 0 {
+		return report.Period
+	}
+	return schedulePeriods[report.Schedule]
+}
+
+// defaultFormat 邮件为 HTML，Slack 为代码块中的文本，Webhook 为 JSON
+func defaultFormat(channel notify.Channel) Format {
+	switch channel {
+	case notify.ChannelEmail:
+		return FormatHTML
+	case notify.ChannelSlack:
+		return FormatMarkdown
+	case notify.ChannelWebhook:
+		return FormatJSON
+	default:
+		return FormatText
+	}
+}
+
+func formatOf(recipient Recipient) Format {
+	if recipient.Format != "" {
+		return recipient.Format
+	}
+	return defaultFormat(recipient.Channel)
+}
+
+// recordMetrics 记录指标
+func (s *Scheduler) recordMetrics(operation string, duration time.Duration, success bool) {
+	s.metricsCollector.RecordDBQuery("report", operation, duration, success)
+	if !success {
+		s.metricsCollector.RecordDBError("report_error", operation)
+	}
+}
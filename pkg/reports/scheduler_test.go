@@ -0,0 +1,228 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/notify"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureDriver 记录发送的消息，err 非空时发送失败
+type captureDriver struct {
+	channel  notify.Channel
+	err      error
+	messages []*notify.Message
+}
+
+func (d *captureDriver) Channel() notify.Channel {
+	return d.channel
+}
+
+func (d *captureDriver) Send(ctx context.Context, msg *notify.Message) error {
+	d.messages = append(d.messages, msg)
+	return d.err
+}
+
+// captureServer 记录收到的请求体
+func captureServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+// TestScheduler_DeliverSecurityReport 邮件收到 HTML，Slack 收到代码块中的文本，Webhook 收到带原始数据的 JSON
+func TestScheduler_DeliverSecurityReport(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	to := time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)
+	for _, column := range []string{"type", "level", "ip", "user_id"} {
+		rows := sqlmock.NewRows([]string{"name", "count"})
+		switch column {
+		case "type":
+			rows.AddRow("login", 7).AddRow("rate_limit", 3)
+		case "level":
+			rows.AddRow("warning", 10)
+		case "ip":
+			rows.AddRow("10.0.0.9", 6)
+		}
+		mock.ExpectQuery(`SELECT `+column+` AS name, COUNT\(\*\) AS count FROM security_events WHERE occurred_at >= \$1 AND occurred_at < \$2`).
+			WithArgs(to.Add(-24*time.Hour), to).
+			WillReturnRows(rows)
+	}
+
+	slack, slackBodies := captureServer(t)
+	webhook, webhookBodies := captureServer(t)
+	email := &captureDriver{channel: notify.ChannelEmail}
+	scheduler := NewScheduler(&Config{
+		SendTimeout: time.Second,
+		Reports: []ReportConfig{{
+			Name:     "security-daily",
+			Source:   SourceSecurity,
+			Schedule: ScheduleDaily,
+			Recipients: []Recipient{
+				{Channel: notify.ChannelEmail, To: "secops@example.com"},
+				{Channel: notify.ChannelSlack, To: slack.URL},
+				{Channel: notify.ChannelWebhook, To: webhook.URL},
+			},
+		}},
+	})
+	scheduler.AddSource(SourceSecurity, NewSecuritySource(security.NewSQLSecurityEventStore(db), 5))
+	scheduler.RegisterDriver(email)
+	scheduler.RegisterDriver(notify.NewSlackDriver(&notify.SlackConfig{Timeout: time.Second, AllowInsecure: true}))
+	scheduler.RegisterDriver(notify.NewWebhookDriver(&notify.WebhookConfig{Timeout: time.Second, AllowInsecure: true}))
+	require.NoError(t, scheduler.Validate())
+
+	delivery, err := scheduler.Deliver(context.Background(), "security-daily", to)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	for _, result := range delivery.Recipients {
+		assert.Empty(t, result.Error, result.Channel)
+	}
+
+	subject := "Security report 2026-10-15 00:00:00 ~ 2026-10-16 00:00:00"
+	require.Len(t, email.messages, 1)
+	assert.Equal(t, subject, email.messages[0].Subject)
+	assert.True(t, email.messages[0].HTML)
+	assert.Contains(t, email.messages[0].Body, "<td>10.0.0.9</td><td>6</td>")
+
+	require.Len(t, *slackBodies, 1)
+	var slackPayload struct {
+		Text string `json:"text"`
+	}
+	require.NoError(t, json.Unmarshal([]byte((*slackBodies)[0]), &slackPayload))
+	assert.True(t, strings.HasPrefix(slackPayload.Text, "*"+subject+"*\n```\n"), slackPayload.Text)
+	assert.Regexp(t, `login\s+7\n`, slackPayload.Text)
+
+	require.Len(t, *webhookBodies, 1)
+	var webhookPayload struct {
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+	}
+	require.NoError(t, json.Unmarshal([]byte((*webhookBodies)[0]), &webhookPayload))
+	var report struct {
+		Name string                        `json:"name"`
+		Data security.SecurityEventSummary `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(webhookPayload.Body), &report))
+	assert.Equal(t, "security-daily", report.Name)
+	assert.Equal(t, int64(10), report.Data.Total)
+	assert.Empty(t, report.Data.TopUsers)
+}
+
+// TestScheduler_DeliverPartialFailure 部分接收方失败不返回错误，避免重试时重复发送；全部失败才返回错误
+func TestScheduler_DeliverPartialFailure(t *testing.T) {
+	var builds int
+	source := SourceFunc(func(ctx context.Context, from, to time.Time) (*Report, error) {
+		builds++
+		return &Report{Title: "Pool", Sections: []Section{{Title: "Stats", Facts: []Fact{{"In use", "3"}}}}}, nil
+	})
+	email := &captureDriver{channel: notify.ChannelEmail, err: errors.New("smtp unavailable")}
+	slack := &captureDriver{channel: notify.ChannelSlack}
+	scheduler := NewScheduler(&Config{
+		SendTimeout: time.Second,
+		Reports: []ReportConfig{
+			{Name: "both", Source: "pool", Schedule: ScheduleWeekly, Recipients: []Recipient{
+				{Channel: notify.ChannelEmail, To: "ops@example.com", Format: FormatText},
+				{Channel: notify.ChannelSlack, To: "https://hooks.slack.com/services/x"},
+			}},
+			{Name: "email-only", Source: "pool", Schedule: "0 8 * * 1", Period: time.Hour, Recipients: []Recipient{
+				{Channel: notify.ChannelEmail, To: "ops@example.com"},
+			}},
+		},
+	})
+	scheduler.AddSource("pool", source)
+	scheduler.RegisterDriver(email)
+	scheduler.RegisterDriver(slack)
+
+	to := time.Now()
+	delivery, err := scheduler.Deliver(context.Background(), "both", to)
+	require.NoError(t, err)
+	assert.Equal(t, to.Add(-7*24*time.Hour), delivery.From)
+	assert.Equal(t, "smtp unavailable", delivery.Recipients[0].Error)
+	assert.Equal(t, FormatText, delivery.Recipients[0].Format)
+	assert.False(t, email.messages[0].HTML)
+	assert.Regexp(t, `In use:\s+3`, email.messages[0].Body)
+	assert.Equal(t, FormatMarkdown, delivery.Recipients[1].Format)
+	require.Len(t, slack.messages, 1)
+
+	delivery, err = scheduler.Deliver(context.Background(), "email-only", to)
+	assert.ErrorContains(t, err, "smtp unavailable")
+	assert.Equal(t, to.Add(-time.Hour), delivery.From)
+
+	_, err = scheduler.Deliver(context.Background(), "missing", to)
+	assert.ErrorIs(t, err, ErrReportNotFound)
+	assert.Equal(t, 2, builds)
+}
+
+// TestScheduler_Register 每份报告一个定时任务，配置错误时拒绝注册
+func TestScheduler_Register(t *testing.T) {
+	db, _ := fakes.NewDB(t)
+	email := &captureDriver{channel: notify.ChannelEmail}
+	newScheduler := func(report ReportConfig) *Scheduler {
+		scheduler := NewScheduler(&Config{Reports: []ReportConfig{report}})
+		scheduler.AddSource(SourceCache, NewCacheSource(nil, nil))
+		scheduler.RegisterDriver(email)
+		return scheduler
+	}
+	recipients := []Recipient{{Channel: notify.ChannelEmail, To: "ops@example.com"}}
+
+	manager := jobs.NewManager(jobs.NewStore(db), nil)
+	require.NoError(t, newScheduler(ReportConfig{Name: "cache-daily", Source: SourceCache, Schedule: ScheduleDaily, Recipients: recipients}).Register(manager))
+	crons := manager.Crons()
+	require.Len(t, crons, 1)
+	assert.Equal(t, "report:cache-daily", crons[0].Name)
+	assert.Equal(t, JobType, crons[0].Type)
+	assert.Equal(t, "@daily", crons[0].Spec)
+
+	invalid := []struct {
+		report  ReportConfig
+		message string
+	}{
+		{ReportConfig{Name: "a", Source: "queue", Schedule: ScheduleDaily, Recipients: recipients}, `unknown source "queue"`},
+		{ReportConfig{Name: "b", Source: SourceCache, Schedule: "0 8 * * 1", Recipients: recipients}, "period is required"},
+		{ReportConfig{Name: "c", Source: SourceCache, Schedule: "hourly-ish", Recipients: recipients}, "invalid cron spec"},
+		{ReportConfig{Name: "d", Source: SourceCache, Schedule: ScheduleDaily,
+			Recipients: []Recipient{{Channel: notify.ChannelSlack, To: "x"}}}, `no driver registered for channel "slack"`},
+		{ReportConfig{Name: "e", Source: SourceCache, Schedule: ScheduleDaily,
+			Recipients: []Recipient{{Channel: notify.ChannelEmail, To: "ops@example.com", Format: "pdf"}}}, `unsupported format "pdf"`},
+	}
+	for _, tc := range invalid {
+		err := newScheduler(tc.report).Register(jobs.NewManager(jobs.NewStore(db), nil))
+		assert.ErrorContains(t, err, tc.message, tc.report.Name)
+	}
+}
+
+// TestExport_HTMLEscapes HTML 报告转义数据源中的内容
+func TestExport_HTMLEscapes(t *testing.T) {
+	report := &Report{
+		Title: "Security report",
+		Sections: []Section{
+			{Title: "Top users", Columns: []string{"USER", "COUNT"}, Rows: [][]string{{"<script>", "1"}}},
+			{Title: "Empty", Columns: []string{"IP"}},
+		},
+	}
+	body, err := Export(report, FormatHTML)
+	require.NoError(t, err)
+	assert.Contains(t, body, "<td>&lt;script&gt;</td>")
+	assert.Contains(t, body, `<td colspan="1">(none)</td>`)
+
+	_, err = Export(report, "pdf")
+	assert.Error(t, err)
+}
@@ -0,0 +1,296 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/database"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/security"
+)
+
+// 内置数据源名称
+const (
+	SourceCache    = "cache"
+	SourceDatabase = "database"
+	SourceSecurity = "security"
+)
+
+// CacheSource 缓存报告：CacheMonitor 的摘要、告警与建议，各键前缀与各调用方的命中率，以及 Redis 容量分析
+type CacheSource struct {
+	monitor     *cache.CacheMonitor
+	hitStats    func() []metrics.CacheHitStat
+	callerStats func() []metrics.CacheCallerStat
+	memory      func(ctx context.Context) (*cache.MemoryReport, error)
+}
+
+// NewCacheSource 创建缓存数据源，monitor 与 hitStats 均可为 nil
+func NewCacheSource(monitor *cache.CacheMonitor, hitStats func() []metrics.CacheHitStat) *CacheSource {
+	return &CacheSource{monitor: monitor, hitStats: hitStats}
+}
+
+// SetMemory 在报告中加入容量分析，memory 返回最近一次分析的报告
+func (s *CacheSource) SetMemory(memory func(ctx context.Context) (*cache.MemoryReport, error)) {
+	s.memory = memory
+}
+
+// SetCallerStats 在报告中加入按调用方（HTTP 路由、gRPC 方法）拆分的命中率
+func (s *CacheSource) SetCallerStats(callerStats func() []metrics.CacheCallerStat) {
+	s.callerStats = callerStats
+}
+
+// Build 实现 Source
+func (s *CacheSource) Build(ctx context.Context, from, to time.Time) (*Report, error) {
+	report := &Report{Title: "Cache report"}
+
+	if s.monitor != nil {
+		cacheReport, err := s.monitor.GenerateReport(ctx, to.Sub(from))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate cache report: %w", err)
+		}
+		summary := cacheReport.Summary
+		report.Sections = append(report.Sections, Section{
+			Title: "Summary",
+			Facts: []Fact{
+				{"Requests", strconv.FormatInt(summary.TotalRequests, 10)},
+				{"Hit rate", percent(summary.HitRate)},
+				{"Error rate", percent(summary.ErrorRate)},
+				{"Avg response time", summary.AvgResponseTime.String()},
+				{"Memory usage", strconv.FormatInt(summary.MemoryUsage, 10)},
+				{"Performance score", fmt.Sprintf("%.1f", summary.PerformanceScore)},
+			},
+		})
+
+		alerts := Section{Title: "Alerts", Columns: []string{"TIME", "SEVERITY", "MESSAGE", "VALUE", "THRESHOLD"}}
+		for _, alert := range cacheReport.Alerts {
+			if alert.Timestamp.Before(from) {
+				continue
+			}
+			alerts.Rows = append(alerts.Rows, []string{
+				alert.Timestamp.Format(time.DateTime), alert.Severity, alert.Message,
+				fmt.Sprintf("%.4g", alert.Value), fmt.Sprintf("%.4g", alert.Threshold),
+			})
+		}
+		alerts.Notes = cacheReport.Recommendations
+		report.Sections = append(report.Sections, alerts)
+		report.Data = cacheReport
+	}
+
+	if s.hitStats != nil {
+		section := Section{
+			Title:   "Hit rate by key prefix",
+			Columns: []string{"CACHE", "PREFIX", "HITS", "MISSES", "HIT RATE"},
+			Notes:   []string{"Counted since the reporting instance started."},
+		}
+		for _, stat := range s.hitStats() {
+			section.Rows = append(section.Rows, []string{
+				stat.CacheType, stat.KeyPrefix,
+				strconv.FormatUint(stat.Hits, 10), strconv.FormatUint(stat.Misses, 10), percent(stat.HitRate),
+			})
+		}
+		report.Sections = append(report.Sections, section)
+	}
+
+	if s.callerStats != nil {
+		section := Section{
+			Title:   "Hit rate by caller",
+			Columns: []string{"CACHE", "CALLER", "HITS", "MISSES", "ERRORS", "HIT RATE", "AVG LATENCY"},
+			Notes:   []string{"Counted since the reporting instance started; reads outside a request are reported as " + metrics.BackgroundCaller + "."},
+		}
+		for _, stat := range s.callerStats() {
+			section.Rows = append(section.Rows, []string{
+				stat.CacheType, stat.Caller,
+				strconv.FormatUint(stat.Hits, 10), strconv.FormatUint(stat.Misses, 10), strconv.FormatUint(stat.Errors, 10),
+				percent(stat.HitRate), fmt.Sprintf("%.2fms", stat.AvgLatencyMs),
+			})
+		}
+		report.Sections = append(report.Sections, section)
+	}
+
+	if s.memory != nil {
+		memory, err := s.memory(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze cache memory: %w", err)
+		}
+		report.Sections = append(report.Sections, memorySections(memory)...)
+	}
+	return report, nil
+}
+
+// memorySections 容量分析的摘要、各命名空间的占用与最大的键
+func memorySections(memory *cache.MemoryReport) []Section {
+	info := memory.Info
+	maxMemory := "unlimited"
+	if info.MaxMemory > 0 {
+		maxMemory = strconv.FormatInt(info.MaxMemory, 10)
+	}
+	summary := Section{
+		Title: "Redis memory",
+		Facts: []Fact{
+			{"Analyzed at", memory.GeneratedAt.Format(time.DateTime)},
+			{"Used memory", strconv.FormatInt(info.UsedMemory, 10)},
+			{"Maxmemory", maxMemory},
+			{"Keys", strconv.FormatInt(info.Keys, 10)},
+			{"Evicted keys", strconv.FormatInt(info.EvictedKeys, 10)},
+			{"Sampled keys", strconv.Itoa(memory.SampledKeys)},
+			{"Memory without TTL", percent(memory.NoTTLShare)},
+			{"Current policy", memory.Recommendation.Current},
+			{"Recommended policy", memory.Recommendation.Recommended},
+		},
+		Notes: memory.Recommendation.Reasons,
+	}
+
+	namespaces := Section{
+		Title:   "Memory by namespace",
+		Columns: []string{"NAMESPACE", "EST. KEYS", "EST. BYTES", "SHARE", "AVG BYTES", "NO TTL", "AVG TTL LEFT"},
+		Notes:   []string{"Estimated by scaling the sampled keys to the total key count."},
+	}
+	for _, namespace := range memory.Namespaces {
+		namespaces.Rows = append(namespaces.Rows, []string{
+			namespace.Namespace,
+			strconv.FormatInt(namespace.EstimatedKeys, 10), strconv.FormatInt(namespace.EstimatedBytes, 10),
+			percent(namespace.Share), strconv.FormatInt(namespace.AvgBytes, 10),
+			strconv.Itoa(namespace.NoTTLKeys), namespace.AvgTTL.Round(time.Second).String(),
+		})
+	}
+
+	largest := Section{Title: "Largest sampled keys", Columns: []string{"KEY", "TYPE", "BYTES", "TTL"}}
+	for _, key := range memory.TopKeys {
+		largest.Rows = append(largest.Rows, []string{key.Key, key.Type, strconv.FormatInt(key.Bytes, 10), key.TTL.Round(time.Second).String()})
+	}
+	return []Section{summary, namespaces, largest}
+}
+
+// PoolStatsProvider 连接池统计，*sql.DB 实现了该接口
+type PoolStatsProvider interface {
+	Stats() sql.DBStats
+}
+
+// TuningEventLister 调优事件查询，*database.SQLPoolTuningStore 实现了该接口
+type TuningEventLister interface {
+	ListTuningEvents(ctx context.Context, limit int) ([]database.PoolTuningEvent, error)
+}
+
+// PoolReport 数据库报告的原始数据
+type PoolReport struct {
+	Stats        sql.DBStats                `json:"stats"`
+	TuningEvents []database.PoolTuningEvent `json:"tuning_events"`
+}
+
+// maxTuningEvents 单份报告读取的调优事件上限
+const maxTuningEvents = 500
+
+// DatabaseSource 数据库报告：连接池当前状态与区间内的自动调优记录
+type DatabaseSource struct {
+	pool   PoolStatsProvider
+	tuning TuningEventLister
+}
+
+// NewDatabaseSource 创建数据库数据源，未启用连接池调优时 tuning 可为 nil
+func NewDatabaseSource(pool PoolStatsProvider, tuning TuningEventLister) *DatabaseSource {
+	return &DatabaseSource{pool: pool, tuning: tuning}
+}
+
+// Build 实现 Source
+func (s *DatabaseSource) Build(ctx context.Context, from, to time.Time) (*Report, error) {
+	data := &PoolReport{Stats: s.pool.Stats()}
+	stats := data.Stats
+	report := &Report{
+		Title: "Database report",
+		Data:  data,
+		Sections: []Section{{
+			Title: "Connection pool",
+			Facts: []Fact{
+				{"Max open connections", strconv.Itoa(stats.MaxOpenConnections)},
+				{"Open connections", strconv.Itoa(stats.OpenConnections)},
+				{"In use", strconv.Itoa(stats.InUse)},
+				{"Idle", strconv.Itoa(stats.Idle)},
+				{"Wait count", strconv.FormatInt(stats.WaitCount, 10)},
+				{"Wait duration", stats.WaitDuration.String()},
+				{"Closed (max idle)", strconv.FormatInt(stats.MaxIdleClosed, 10)},
+				{"Closed (max idle time)", strconv.FormatInt(stats.MaxIdleTimeClosed, 10)},
+				{"Closed (max lifetime)", strconv.FormatInt(stats.MaxLifetimeClosed, 10)},
+			},
+			Notes: []string{"Counters are cumulative since the reporting instance started."},
+		}},
+	}
+
+	if s.tuning != nil {
+		events, err := s.tuning.ListTuningEvents(ctx, maxTuningEvents)
+		if err != nil {
+			return nil, err
+		}
+		section := Section{
+			Title:   "Pool tuning",
+			Columns: []string{"TIME", "ACTION", "FROM (OPEN/IDLE)", "TO (OPEN/IDLE)", "WAITS", "REASON"},
+		}
+		for _, event := range events {
+			if event.Timestamp.Before(from) || !event.Timestamp.Before(to) {
+				continue
+			}
+			data.TuningEvents = append(data.TuningEvents, event)
+			section.Rows = append(section.Rows, []string{
+				event.Timestamp.Format(time.DateTime), string(event.Action),
+				fmt.Sprintf("%d/%d", event.From.MaxOpen, event.From.MaxIdle),
+				fmt.Sprintf("%d/%d", event.To.MaxOpen, event.To.MaxIdle),
+				strconv.FormatInt(event.WaitCount, 10), event.Reason,
+			})
+		}
+		report.Sections = append(report.Sections, section)
+	}
+	return report, nil
+}
+
+// SecuritySource 安全报告：按类型、级别统计的安全事件与事件最多的 IP、用户
+type SecuritySource struct {
+	store security.SecurityEventStore
+	top   int
+}
+
+// NewSecuritySource 创建安全数据源，top 为 IP 与用户排名的条数
+func NewSecuritySource(store security.SecurityEventStore, top int) *SecuritySource {
+	if top <= 0 {
+		top = 10
+	}
+	return &SecuritySource{store: store, top: top}
+}
+
+// Build 实现 Source
+func (s *SecuritySource) Build(ctx context.Context, from, to time.Time) (*Report, error) {
+	summary, err := s.store.SummarizeEvents(ctx, security.SecurityEventFilter{From: from, To: to}, s.top)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		Title: "Security report",
+		Data:  summary,
+		Sections: []Section{
+			{Title: "Summary", Facts: []Fact{{"Events", strconv.FormatInt(summary.Total, 10)}}},
+			countSection("Events by type", "TYPE", summary.ByType),
+			countSection("Events by level", "LEVEL", summary.ByLevel),
+			countSection("Top IPs", "IP", summary.TopIPs),
+			countSection("Top users", "USER", summary.TopUsers),
+		},
+	}, nil
+}
+
+func countSection(title, column string, counts []security.SecurityEventCount) Section {
+	section := Section{Title: title, Columns: []string{column, "COUNT"}}
+	for _, count := range counts {
+		section.Rows = append(section.Rows, []string{count.Name, strconv.FormatInt(count.Count, 10)})
+	}
+	return section
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+var (
+	_ Source = (*CacheSource)(nil)
+	_ Source = (*DatabaseSource)(nil)
+	_ Source = (*SecuritySource)(nil)
+)
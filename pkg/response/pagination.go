@@ -0,0 +1,122 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// FieldsParam 按字段筛选响应的查询参数，如 ?fields=id,nickname
+	FieldsParam = "fields"
+	// UnknownTotal 总数未知，Page 不返回 total，按本页是否取满判断 has_more
+	UnknownTotal int64 = -1
+)
+
+// Pagination 列表响应的分页信息。页码分页返回 Page 与 Total，游标分页返回 NextCursor，
+// 无法精确计数时可返回 EstimatedTotal
+type Pagination struct {
+	Limit          int    `json:"limit"`
+	HasMore        bool   `json:"has_more"`
+	Page           int    `json:"page,omitempty"`
+	Total          *int64 `json:"total,omitempty"`
+	NextCursor     string `json:"next_cursor,omitempty"`
+	EstimatedTotal int64  `json:"estimated_total,omitempty"`
+}
+
+// Page 页码分页的列表响应，data 为 list；total 为 UnknownTotal 时不返回总数
+func Page(c *gin.Context, list interface{}, total int64, page, limit int) {
+	pagination := &Pagination{Limit: limit, Page: page}
+	if total == UnknownTotal {
+		v := reflect.ValueOf(list)
+		pagination.HasMore = v.Kind() == reflect.Slice && v.Len() >= limit
+	} else {
+		pagination.HasMore = int64(page)*int64(limit) < total
+		pagination.Total = &total
+	}
+	write(c, http.StatusOK, "success", emptyList(list), pagination)
+}
+
+// CursorPage 游标分页的列表响应，data 为 list；estimatedTotal 为 0 时不返回
+func CursorPage(c *gin.Context, list interface{}, limit int, nextCursor string, hasMore bool, estimatedTotal int64) {
+	write(c, http.StatusOK, "success", emptyList(list), &Pagination{
+		Limit:          limit,
+		HasMore:        hasMore,
+		NextCursor:     nextCursor,
+		EstimatedTotal: estimatedTotal,
+	})
+}
+
+// emptyList nil 切片返回空数组，使列表接口的 data 总是数组
+func emptyList(list interface{}) interface{} {
+	v := reflect.ValueOf(list)
+	if !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		return []interface{}{}
+	}
+	return list
+}
+
+// selectFields 按 ?fields= 只保留 data 的指定顶层字段，data 为对象或对象数组时生效，其他类型原样返回。
+// 筛选在脱敏之后进行，不会绕过脱敏
+func selectFields(c *gin.Context, data interface{}) (interface{}, bool) {
+	fields := parseFields(c.Query(FieldsParam))
+	if len(fields) == 0 || data == nil {
+		return data, true
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		Error(c, http.StatusInternalServerError, ErrServerInternal, "Failed to serialize response")
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	// 保留数字原样，避免大整数 ID 变为浮点数
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		Error(c, http.StatusInternalServerError, ErrServerInternal, "Failed to serialize response")
+		return nil, false
+	}
+
+	switch value := decoded.(type) {
+	case map[string]interface{}:
+		return pickFields(value, fields), true
+	case []interface{}:
+		for i, item := range value {
+			if object, ok := item.(map[string]interface{}); ok {
+				value[i] = pickFields(object, fields)
+			}
+		}
+		return value, true
+	default:
+		return data, true
+	}
+}
+
+// parseFields 解析逗号分隔的字段名，忽略空白与空项
+func parseFields(param string) map[string]bool {
+	if param == "" {
+		return nil
+	}
+	fields := make(map[string]bool)
+	for _, field := range strings.Split(param, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields[field] = true
+		}
+	}
+	return fields
+}
+
+func pickFields(object map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	picked := make(map[string]interface{}, len(fields))
+	for key, value := range object {
+		if fields[key] {
+			picked[key] = value
+		}
+	}
+	return picked
+}
@@ -0,0 +1,51 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUser struct {
+	ID       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+	Mobile   string `json:"mobile"`
+}
+
+func serve(t *testing.T, target string, handler gin.HandlerFunc) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/list", handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+// TestPage_Envelope 页码与游标分页的 pagination 字段，总数未知时按本页是否取满判断 has_more，
+// ?fields= 只保留列表元素的指定字段且大整数不丢精度，nil 列表返回空数组
+func TestPage_Envelope(t *testing.T) {
+	users := []testUser{{ID: 9007199254740993, Nickname: "a", Mobile: "13800000000"}, {ID: 2, Nickname: "b"}}
+
+	body := serve(t, "/list?page=1&limit=2", func(c *gin.Context) { Page(c, users, 5, 1, 2) })
+	assert.JSONEq(t, `{"code":0,"message":"success","data":[
+		{"id":9007199254740993,"nickname":"a","mobile":"13800000000"},{"id":2,"nickname":"b","mobile":""}],
+		"pagination":{"limit":2,"page":1,"total":5,"has_more":true}}`, body)
+
+	body = serve(t, "/list?fields=id,%20nickname,", func(c *gin.Context) { Page(c, users, UnknownTotal, 3, 2) })
+	assert.JSONEq(t, `{"code":0,"message":"success","data":[
+		{"id":9007199254740993,"nickname":"a"},{"id":2,"nickname":"b"}],
+		"pagination":{"limit":2,"page":3,"has_more":true}}`, body)
+
+	body = serve(t, "/list", func(c *gin.Context) { CursorPage(c, []testUser(nil), 20, "", false, 0) })
+	assert.JSONEq(t, `{"code":0,"message":"success","data":[],"pagination":{"limit":20,"has_more":false}}`, body)
+
+	body = serve(t, "/list?fields=nickname", func(c *gin.Context) { CursorPage(c, users[1:], 1, "eyJpZCI6Mn0", true, 40) })
+	assert.JSONEq(t, `{"code":0,"message":"success","data":[{"nickname":"b"}],
+		"pagination":{"limit":1,"has_more":true,"next_cursor":"eyJpZCI6Mn0","estimated_total":40}}`, body)
+}
@@ -8,34 +8,85 @@ import (
 
 // Response 统一响应结构
 type Response struct {
-	Code    int         `json:"code"`    // 业务码
-	Message string      `json:"message"` // 提示信息
-	Data    interface{} `json:"data"`    // 数据
+	Code       int         `json:"code"`                 // 业务码
+	Message    string      `json:"message"`              // 提示信息
+	Data       interface{} `json:"data"`                 // 数据，列表接口为数组
+	Pagination *Pagination `json:"pagination,omitempty"` // 分页信息，仅列表接口携带
+
+	// RequestID 请求 ID，仅错误响应携带，便于排查；成功响应可能被响应缓存复用，请求 ID 见 X-Request-ID 响应头
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// MaskerKey 上下文中设置 DataMasker 后，Success 与 Accepted 的 data 先脱敏再返回
+const MaskerKey = "response.masker"
+
+// DataMasker 响应数据脱敏
+type DataMasker interface {
+	Mask(v interface{}) (interface{}, error)
+}
+
+// maskData 按上下文中的脱敏器处理 data，脱敏失败时返回 500 而不是原始数据
+func maskData(c *gin.Context, data interface{}) (interface{}, bool) {
+	value, exists := c.Get(MaskerKey)
+	if !exists || data == nil {
+		return data, true
+	}
+	masker, ok := value.(DataMasker)
+	if !ok {
+		return data, true
+	}
+	masked, err := masker.Mask(data)
+	if err != nil {
+		Error(c, http.StatusInternalServerError, ErrServerInternal, "Failed to serialize response")
+		return nil, false
+	}
+	return masked, true
+}
+
+// write 脱敏并按 ?fields= 筛选 data 后写出成功响应
+func write(c *gin.Context, status int, message string, data interface{}, pagination *Pagination) {
+	data, ok := maskData(c, data)
+	if !ok {
+		return
+	}
+	data, ok = selectFields(c, data)
+	if !ok {
+		return
+	}
+	c.JSON(status, Response{
+		Code:       CodeSuccess,
+		Message:    message,
+		Data:       data,
+		Pagination: pagination,
+	})
 }
 
 // Success 成功响应
 func Success(c *gin.Context, data interface{}) {
-	c.JSON(http.StatusOK, Response{
-		Code:    CodeSuccess,
-		Message: "success",
-		Data:    data,
-	})
+	write(c, http.StatusOK, "success", data, nil)
+}
+
+// Accepted 已受理但尚未完成（如进入排队）
+func Accepted(c *gin.Context, data interface{}) {
+	write(c, http.StatusAccepted, "accepted", data, nil)
 }
 
 // Error 错误响应
 func Error(c *gin.Context, httpCode int, errCode int, msg string) {
 	c.JSON(httpCode, Response{
-		Code:    errCode,
-		Message: msg,
-		Data:    nil,
+		Code:      errCode,
+		Message:   msg,
+		Data:      nil,
+		RequestID: c.GetString("request_id"),
 	})
 }
 
 // Fail 业务失败响应 (HTTP 200, 业务码非 0)
 func Fail(c *gin.Context, errCode int, msg string) {
 	c.JSON(http.StatusOK, Response{
-		Code:    errCode,
-		Message: msg,
-		Data:    nil,
+		Code:      errCode,
+		Message:   msg,
+		Data:      nil,
+		RequestID: c.GetString("request_id"),
 	})
 }
@@ -0,0 +1,259 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/jobs"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// JobType 按策略定时清理数据集的任务类型
+const JobType = "retention.enforce"
+
+// ErrPolicyNotFound 数据集未配置保留策略
+var ErrPolicyNotFound = errors.New("retention policy not found")
+
+// Config 保留策略执行配置
+type Config struct {
+	Schedule   string // 未单独配置调度周期的策略使用的 cron 表达式
+	BatchSize  int    // 单条语句清理的行数，避免长事务与大量锁
+	MaxBatches int    // 单次执行的批次上限，剩余的行留到下次执行
+}
+
+// DefaultConfig 默认执行配置，低峰期每日执行一次
+func DefaultConfig() *Config {
+	return &Config{
+		Schedule:   "30 3 * * *",
+		BatchSize:  5000,
+		MaxBatches: 200,
+	}
+}
+
+// Run 一次执行或预演的结果
+type Run struct {
+	Dataset   string        `json:"dataset"`
+	DryRun    bool          `json:"dry_run"`
+	Action    Action        `json:"action"`
+	Cutoff    *time.Time    `json:"cutoff,omitempty"` // 策略不按时间清理时为空
+	Expired   int64         `json:"expired"`          // 早于 Cutoff 的行数
+	Excess    int64         `json:"excess"`           // 清理过期行后仍超出 MaxRows 的行数
+	Purged    int64         `json:"purged"`           // 实际删除或归档的行数，预演时为 0
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Pending 执行后仍待清理的行数
+func (r *Run) Pending() int64 {
+	if pending := r.Expired + r.Excess - r.Purged; pending > 0 {
+		return pending
+	}
+	return 0
+}
+
+// enforcePayload 定时任务参数
+type enforcePayload struct {
+	Dataset string `json:"dataset"`
+}
+
+// Engine 数据保留策略引擎：按数据集定时删除或归档超过保留期限、超出行数上限的行，
+// 支持预演统计待清理的行数，清理量与待清理量记录为指标
+type Engine struct {
+	store    Store
+	config   *Config
+	clock    clock.Clock
+	mu       sync.RWMutex
+	policies map[string]*Policy
+}
+
+// NewEngine 创建保留策略引擎，config 为 nil 时使用默认配置
+func NewEngine(store Store, config *Config) *Engine {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &Engine{
+		store:    store,
+		config:   config,
+		clock:    clock.OrReal(nil),
+		policies: make(map[string]*Policy),
+	}
+}
+
+// SetClock 替换时钟，仅用于测试
+func (e *Engine) SetClock(c clock.Clock) {
+	e.clock = clock.OrReal(c)
+}
+
+// AddPolicy 添加或替换数据集的保留策略
+func (e *Engine) AddPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policies[policy.Dataset] = &policy
+	return nil
+}
+
+// Policies 已配置的策略，按数据集名称排序
+func (e *Engine) Policies() []Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	policies := make([]Policy, 0, len(e.policies))
+	for _, policy := range e.policies {
+		policies = append(policies, *policy)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Dataset < policies[j].Dataset })
+	return policies
+}
+
+// ScheduleOf 策略的 cron 表达式
+func (e *Engine) ScheduleOf(policy *Policy) string {
+	if policy.Schedule != "" {
+		return policy.Schedule
+	}
+	return e.config.Schedule
+}
+
+// Register 注册清理任务，并为每个策略登记定时计划；多实例部署时每个周期只执行一次
+func (e *Engine) Register(manager *jobs.Manager) error {
+	manager.Register(JobType, e.handleJob)
+	for _, policy := range e.Policies() {
+		if err := manager.Schedule("retention:"+policy.Dataset, e.ScheduleOf(&policy), JobType,
+			enforcePayload{Dataset: policy.Dataset}, jobs.PriorityLow); err != nil {
+			return fmt.Errorf("failed to schedule retention of %s: %w", policy.Dataset, err)
+		}
+	}
+	return nil
+}
+
+// handleJob 执行定时清理，策略已移除时跳过
+func (e *Engine) handleJob(ctx context.Context, job *jobs.Job) error {
+	var payload enforcePayload
+	if err := job.Decode(&payload); err != nil {
+		return fmt.Errorf("failed to decode retention job: %w", err)
+	}
+	_, err := e.Enforce(ctx, payload.Dataset, false)
+	if errors.Is(err, ErrPolicyNotFound) {
+		log.Printf("Retention policy %s removed, skipping scheduled run", payload.Dataset)
+		return nil
+	}
+	return err
+}
+
+// Report 预演全部策略，单个数据集统计失败时记录在其结果中
+func (e *Engine) Report(ctx context.Context) []*Run {
+	policies := e.Policies()
+	runs := make([]*Run, 0, len(policies))
+	for i := range policies {
+		run, err := e.enforce(ctx, &policies[i], true)
+		if err != nil {
+			run.Error = err.Error()
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// Enforce 按策略清理数据集；dryRun 时只统计待清理的行数
+func (e *Engine) Enforce(ctx context.Context, dataset string, dryRun bool) (*Run, error) {
+	e.mu.RLock()
+	policy, ok := e.policies[dataset]
+	e.mu.RUnlock()
+	if !ok {
+		return nil, ErrPolicyNotFound
+	}
+
+	run, err := e.enforce(ctx, policy, dryRun)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if dryRun {
+		return run, err
+	}
+	if saveErr := e.store.SaveRun(ctx, run); saveErr != nil {
+		log.Printf("Failed to save retention run of %s: %v", dataset, saveErr)
+	}
+	return run, err
+}
+
+// History 最近的执行记录
+func (e *Engine) History(ctx context.Context, dataset string, limit int) ([]*Run, error) {
+	return e.store.ListRuns(ctx, dataset, limit)
+}
+
+func (e *Engine) enforce(ctx context.Context, policy *Policy, dryRun bool) (*Run, error) {
+	now := e.clock.Now()
+	run := &Run{
+		Dataset:   policy.Dataset,
+		DryRun:    dryRun,
+		Action:    policy.Action,
+		StartedAt: now,
+	}
+	// 零值时间早于任何行，不按时间清理
+	var cutoff time.Time
+	if policy.MaxAge > 0 {
+		cutoff = now.Add(-policy.MaxAge)
+		run.Cutoff = &cutoff
+	}
+
+	collector := metrics.GetGlobalCollector()
+	defer func() {
+		run.Duration = e.clock.Since(now)
+		collector.UpdateRetentionPending(policy.Dataset, run.Pending())
+	}()
+
+	expired, total, err := e.store.Pending(ctx, policy, cutoff)
+	if err != nil {
+		return run, err
+	}
+	run.Expired = expired
+	if policy.MaxRows > 0 && total-expired > policy.MaxRows {
+		run.Excess = total - expired - policy.MaxRows
+	}
+	if dryRun {
+		return run, nil
+	}
+
+	batchSize := policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = e.config.BatchSize
+	}
+	// 先清理过期的行，剩余行数仍超出上限时再清理最旧的行
+	var steps []func() (int64, error)
+	if run.Expired > 0 {
+		steps = append(steps, func() (int64, error) { return e.store.PurgeExpired(ctx, policy, cutoff, batchSize) })
+	}
+	if run.Excess > 0 {
+		steps = append(steps, func() (int64, error) { return e.store.PurgeExcess(ctx, policy, batchSize) })
+	}
+	batches := 0
+	for _, purge := range steps {
+		for batches < e.config.MaxBatches {
+			if err := ctx.Err(); err != nil {
+				return run, err
+			}
+			n, err := purge()
+			if err != nil {
+				return run, err
+			}
+			batches++
+			run.Purged += n
+			collector.RecordRetention(policy.Dataset, string(policy.Action), n)
+			if n < int64(batchSize) {
+				break
+			}
+		}
+	}
+
+	if run.Purged > 0 {
+		log.Printf("Retention %s of %s: %d rows (%d still pending)", policy.Action, policy.Dataset, run.Purged, run.Pending())
+	}
+	return run, nil
+}
@@ -0,0 +1,174 @@
+package retention
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore 按数据集保存行的时间，忽略 Filter
+type memoryStore struct {
+	rows     map[string][]time.Time
+	archived map[string]int64
+	runs     []*Run
+	purges   int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{rows: make(map[string][]time.Time), archived: make(map[string]int64)}
+}
+
+func (s *memoryStore) Pending(ctx context.Context, p *Policy, cutoff time.Time) (int64, int64, error) {
+	var expired int64
+	for _, at := range s.rows[p.Dataset] {
+		if at.Before(cutoff) {
+			expired++
+		}
+	}
+	return expired, int64(len(s.rows[p.Dataset])), nil
+}
+
+func (s *memoryStore) PurgeExpired(ctx context.Context, p *Policy, cutoff time.Time, limit int) (int64, error) {
+	return s.purge(p, limit, func(i int, rows []time.Time) bool { return rows[i].Before(cutoff) }), nil
+}
+
+func (s *memoryStore) PurgeExcess(ctx context.Context, p *Policy, limit int) (int64, error) {
+	rows := s.rows[p.Dataset]
+	sort.Slice(rows, func(i, j int) bool { return rows[i].After(rows[j]) })
+	return s.purge(p, limit, func(i int, rows []time.Time) bool { return int64(i) >= p.MaxRows }), nil
+}
+
+func (s *memoryStore) purge(p *Policy, limit int, doomed func(int, []time.Time) bool) int64 {
+	s.purges++
+	rows := s.rows[p.Dataset]
+	kept := rows[:0:0]
+	var n int64
+	for i := range rows {
+		if n < int64(limit) && doomed(i, rows) {
+			n++
+			continue
+		}
+		kept = append(kept, rows[i])
+	}
+	s.rows[p.Dataset] = kept
+	if p.Action == ActionArchive {
+		s.archived[p.Dataset] += n
+	}
+	return n
+}
+
+func (s *memoryStore) SaveRun(ctx context.Context, run *Run) error {
+	s.runs = append(s.runs, run)
+	return nil
+}
+
+func (s *memoryStore) ListRuns(ctx context.Context, dataset string, limit int) ([]*Run, error) {
+	return s.runs, nil
+}
+
+func seed(store *memoryStore, dataset string, now time.Time, ages ...time.Duration) {
+	for _, age := range ages {
+		store.rows[dataset] = append(store.rows[dataset], now.Add(-age))
+	}
+}
+
+// TestEngine_DryRunThenEnforce 预演只统计不清理；实际执行先清理过期的行，再按行数上限清理最旧的行
+func TestEngine_DryRunThenEnforce(t *testing.T) {
+	now := time.Date(2026, 10, 16, 3, 30, 0, 0, time.UTC)
+	store := newMemoryStore()
+	engine := NewEngine(store, &Config{Schedule: "30 3 * * *", BatchSize: 2, MaxBatches: 10})
+	engine.SetClock(fakes.NewClock(now))
+	require.NoError(t, engine.AddPolicy(Policy{
+		Dataset:    "events",
+		Table:      "events",
+		TimeColumn: "occurred_at",
+		MaxAge:     30 * day,
+		MaxRows:    3,
+		Action:     ActionArchive,
+	}))
+	// 3 行过期，剩余 5 行超出上限 2 行
+	seed(store, "events", now, 40*day, 35*day, 31*day, 20*day, 10*day, 5*day, 2*day, time.Hour)
+
+	run, err := engine.Enforce(context.Background(), "events", true)
+	require.NoError(t, err)
+	assert.True(t, run.DryRun)
+	assert.Equal(t, int64(3), run.Expired)
+	assert.Equal(t, int64(2), run.Excess)
+	assert.Equal(t, int64(5), run.Pending())
+	assert.Equal(t, now.Add(-30*day), *run.Cutoff)
+	assert.Len(t, store.rows["events"], 8)
+	assert.Empty(t, store.runs, "dry runs are not recorded")
+
+	run, err = engine.Enforce(context.Background(), "events", false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), run.Purged)
+	assert.Zero(t, run.Pending())
+	assert.Equal(t, int64(5), store.archived["events"])
+	// 保留最新的 3 行
+	assert.ElementsMatch(t, []time.Time{now.Add(-5 * day), now.Add(-2 * day), now.Add(-time.Hour)}, store.rows["events"])
+	require.Len(t, store.runs, 1)
+	assert.Empty(t, store.runs[0].Error)
+
+	_, err = engine.Enforce(context.Background(), "missing", false)
+	assert.ErrorIs(t, err, ErrPolicyNotFound)
+}
+
+// TestEngine_MaxBatches 达到批次上限后停止，剩余的行留到下次执行
+func TestEngine_MaxBatches(t *testing.T) {
+	now := time.Date(2026, 10, 16, 3, 30, 0, 0, time.UTC)
+	store := newMemoryStore()
+	engine := NewEngine(store, &Config{Schedule: "30 3 * * *", BatchSize: 2, MaxBatches: 2})
+	engine.SetClock(fakes.NewClock(now))
+	require.NoError(t, engine.AddPolicy(Policy{
+		Dataset:    "slow",
+		Table:      "security_events",
+		TimeColumn: "occurred_at",
+		MaxAge:     day,
+		Action:     ActionDelete,
+	}))
+	seed(store, "slow", now, 9*day, 8*day, 7*day, 6*day, 5*day, time.Hour)
+
+	run, err := engine.Enforce(context.Background(), "slow", false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), run.Expired)
+	assert.Equal(t, int64(4), run.Purged)
+	assert.Equal(t, int64(1), run.Pending())
+	assert.Equal(t, 2, store.purges)
+	assert.Zero(t, store.archived["slow"])
+
+	run, err = engine.Enforce(context.Background(), "slow", false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), run.Purged)
+	assert.Len(t, store.rows["slow"], 1)
+}
+
+// TestMerge 配置覆盖默认策略的非零字段，可禁用或追加数据集；非法标识符与缺少清理条件的策略被拒绝
+func TestMerge(t *testing.T) {
+	policies := Merge(DefaultPolicies(), []Policy{
+		{Dataset: "security_slow_requests", MaxAge: 7 * day},
+		{Dataset: "cache_stats_minute", Disabled: true},
+		{Dataset: "retention_archive", Table: "retention_archive", TimeColumn: "archived_at", MaxAge: 5 * 365 * day, Action: ActionDelete},
+	})
+
+	byDataset := make(map[string]Policy)
+	for _, policy := range policies {
+		require.NoError(t, policy.Validate(), policy.Dataset)
+		byDataset[policy.Dataset] = policy
+	}
+	assert.NotContains(t, byDataset, "cache_stats_minute")
+	assert.Equal(t, 7*day, byDataset["security_slow_requests"].MaxAge)
+	assert.Equal(t, "type = 'slow_request'", byDataset["security_slow_requests"].Filter)
+	assert.Equal(t, "archived_at", byDataset["retention_archive"].TimeColumn)
+	assert.True(t, sort.SliceIsSorted(policies, func(i, j int) bool { return policies[i].Dataset < policies[j].Dataset }))
+
+	engine := NewEngine(newMemoryStore(), nil)
+	assert.Error(t, engine.AddPolicy(Policy{Dataset: "x", Table: "users; DROP TABLE users", TimeColumn: "created_at", MaxAge: day, Action: ActionDelete}))
+	assert.Error(t, engine.AddPolicy(Policy{Dataset: "x", Table: "users", TimeColumn: "created_at", Action: ActionDelete}))
+	assert.Error(t, engine.AddPolicy(Policy{Dataset: "x", Table: "users", TimeColumn: "created_at", MaxAge: day, Action: "truncate"}))
+	assert.Error(t, engine.AddPolicy(Policy{Dataset: "x", Table: "users", TimeColumn: "created_at", MaxAge: day, Action: ActionDelete, Schedule: "bogus"}))
+}
@@ -0,0 +1,124 @@
+package retention
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PolicyInfo 策略的接口视图
+type PolicyInfo struct {
+	Dataset    string    `json:"dataset"`
+	Table      string    `json:"table"`
+	TimeColumn string    `json:"time_column"`
+	Filter     string    `json:"filter,omitempty"`
+	MaxAge     string    `json:"max_age,omitempty"`
+	MaxRows    int64     `json:"max_rows,omitempty"`
+	Action     Action    `json:"action"`
+	Schedule   string    `json:"schedule"`
+	Next       time.Time `json:"next"`
+}
+
+// EnforceQuery 立即执行的参数
+type EnforceQuery struct {
+	DryRun bool `form:"dry_run"`
+}
+
+// RunsQuery 执行记录的查询参数
+type RunsQuery struct {
+	Dataset string `form:"dataset"`
+	Limit   int    `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// Handler 数据保留策略管理接口
+type Handler struct {
+	engine *Engine
+}
+
+// NewHandler 创建数据保留策略管理接口
+func NewHandler(engine *Engine) *Handler {
+	return &Handler{engine: engine}
+}
+
+// RegisterAdminRoutes 注册保留策略路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/retention/policies", h.ListPolicies)
+	group.GET("/retention/report", h.Report)
+	group.GET("/retention/runs", h.ListRuns)
+	group.POST("/retention/:dataset/enforce", h.Enforce)
+}
+
+// ListPolicies 已配置的策略与下次执行时间
+func (h *Handler) ListPolicies(c *gin.Context) {
+	now := time.Now()
+	policies := h.engine.Policies()
+	infos := make([]PolicyInfo, 0, len(policies))
+	for _, policy := range policies {
+		info := PolicyInfo{
+			Dataset:    policy.Dataset,
+			Table:      policy.Table,
+			TimeColumn: policy.TimeColumn,
+			Filter:     policy.Filter,
+			MaxRows:    policy.MaxRows,
+			Action:     policy.Action,
+			Schedule:   h.engine.ScheduleOf(&policy),
+		}
+		if policy.MaxAge > 0 {
+			info.MaxAge = policy.MaxAge.String()
+		}
+		if schedule, err := jobs.ParseCron(info.Schedule); err == nil {
+			info.Next = schedule.Next(now)
+		}
+		infos = append(infos, info)
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": infos})
+}
+
+// Report 预演全部策略，返回各数据集待清理的行数
+func (h *Handler) Report(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"datasets": h.engine.Report(c.Request.Context())})
+}
+
+// Enforce 立即按策略清理数据集，dry_run=true 时只统计
+func (h *Handler) Enforce(c *gin.Context) {
+	var query EnforceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	run, err := h.engine.Enforce(c.Request.Context(), c.Param("dataset"), query.DryRun)
+	if errors.Is(err, ErrPolicyNotFound) {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+		return
+	}
+	if err != nil && run == nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	// 部分批次失败时仍返回已清理的行数与错误
+	c.JSON(http.StatusOK, run)
+}
+
+// ListRuns 最近的执行记录
+func (h *Handler) ListRuns(c *gin.Context) {
+	var query RunsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+
+	runs, err := h.engine.History(c.Request.Context(), query.Dataset, query.Limit)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
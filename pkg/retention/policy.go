@@ -0,0 +1,189 @@
+package retention
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+	"user_crud_jwt/pkg/jobs"
+)
+
+// Action 超出保留期限的行的处理方式
+type Action string
+
+const (
+	// ActionDelete 直接删除
+	ActionDelete Action = "delete"
+	// ActionArchive 先以 JSON 写入 retention_archive 表再删除，同一事务内完成
+	ActionArchive Action = "archive"
+)
+
+// identifierPattern 表名与列名只允许小写标识符，二者直接拼接进 SQL
+var identifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Policy 单个数据集的保留策略。同一张表可按 Filter 划分为多个数据集，各自设置保留期限
+type Policy struct {
+	Dataset    string        `json:"dataset"`
+	Table      string        `json:"table"`
+	TimeColumn string        `json:"time_column"` // 判断新旧的时间列，须有索引
+	Filter     string        `json:"filter"`      // 限定数据集的 SQL 条件，来自配置而非用户输入，为空时为整张表
+	MaxAge     time.Duration `json:"max_age"`     // 为 0 时不按时间清理
+	MaxRows    int64         `json:"max_rows"`    // 为 0 时不限行数，超出时清理最旧的行
+	Action     Action        `json:"action"`
+	Schedule   string        `json:"schedule"`   // cron 表达式，为空时使用 Config.Schedule
+	BatchSize  int           `json:"batch_size"` // 单条语句清理的行数，为 0 时使用 Config.BatchSize
+	Disabled   bool          `json:"disabled"`   // 仅用于配置覆盖默认策略，见 Merge
+}
+
+// Validate 检查标识符、清理条件与调度周期
+func (p *Policy) Validate() error {
+	if p.Dataset == "" {
+		return fmt.Errorf("retention dataset is required")
+	}
+	if !identifierPattern.MatchString(p.Table) {
+		return fmt.Errorf("retention policy %q: invalid table %q", p.Dataset, p.Table)
+	}
+	if !identifierPattern.MatchString(p.TimeColumn) {
+		return fmt.Errorf("retention policy %q: invalid time column %q", p.Dataset, p.TimeColumn)
+	}
+	if p.MaxAge <= 0 && p.MaxRows <= 0 {
+		return fmt.Errorf("retention policy %q: max age or max rows is required", p.Dataset)
+	}
+	if p.MaxAge < 0 || p.MaxRows < 0 || p.BatchSize < 0 {
+		return fmt.Errorf("retention policy %q: limits must not be negative", p.Dataset)
+	}
+	switch p.Action {
+	case ActionDelete, ActionArchive:
+	default:
+		return fmt.Errorf("retention policy %q: unknown action %q", p.Dataset, p.Action)
+	}
+	if p.Schedule != "" {
+		if _, err := jobs.ParseCron(p.Schedule); err != nil {
+			return fmt.Errorf("retention policy %q: %w", p.Dataset, err)
+		}
+	}
+	return nil
+}
+
+// where 数据集的筛选条件
+func (p *Policy) where() string {
+	if p.Filter == "" {
+		return "TRUE"
+	}
+	return "(" + p.Filter + ")"
+}
+
+const day = 24 * time.Hour
+
+// DefaultPolicies 内置数据集的默认保留策略。security_events 另由分区管理按整月清理超过 365 天的分区，
+// 这里按类型更早清理大量的低价值事件，管理操作在分区删除前归档
+func DefaultPolicies() []Policy {
+	return []Policy{
+		{
+			Dataset:    "security_slow_requests",
+			Table:      "security_events",
+			TimeColumn: "occurred_at",
+			Filter:     "type = 'slow_request'",
+			MaxAge:     30 * day,
+			Action:     ActionDelete,
+		},
+		{
+			Dataset:    "security_info_events",
+			Table:      "security_events",
+			TimeColumn: "occurred_at",
+			Filter:     "level = 'info' AND type <> 'admin_action'",
+			MaxAge:     90 * day,
+			Action:     ActionDelete,
+		},
+		{
+			Dataset:    "admin_audit",
+			Table:      "security_events",
+			TimeColumn: "occurred_at",
+			Filter:     "type = 'admin_action'",
+			MaxAge:     180 * day,
+			Action:     ActionArchive,
+		},
+		{
+			Dataset:    "cache_stats_minute",
+			Table:      "cache_stats_rollups",
+			TimeColumn: "bucket_start",
+			Filter:     "resolution = 'minute'",
+			MaxAge:     7 * day,
+			Action:     ActionDelete,
+		},
+		{
+			Dataset:    "cache_stats_hour",
+			Table:      "cache_stats_rollups",
+			TimeColumn: "bucket_start",
+			Filter:     "resolution = 'hour'",
+			MaxAge:     90 * day,
+			Action:     ActionDelete,
+		},
+		{
+			Dataset:    "pool_tuning_events",
+			Table:      "pool_tuning_events",
+			TimeColumn: "occurred_at",
+			MaxAge:     180 * day,
+			MaxRows:    10000,
+			Action:     ActionDelete,
+		},
+		{
+			Dataset:    "compliance_audit",
+			Table:      "compliance_audit",
+			TimeColumn: "created_at",
+			MaxAge:     3 * 365 * day,
+			Action:     ActionArchive,
+		},
+	}
+}
+
+// Merge 以 overrides 覆盖 defaults 中同名数据集的非零字段，Disabled 的数据集被移除；
+// 不在 defaults 中的数据集作为新策略追加。结果按数据集名称排序
+func Merge(defaults, overrides []Policy) []Policy {
+	merged := make(map[string]Policy, len(defaults)+len(overrides))
+	for _, policy := range defaults {
+		merged[policy.Dataset] = policy
+	}
+	for _, override := range overrides {
+		policy, ok := merged[override.Dataset]
+		if !ok {
+			merged[override.Dataset] = override
+			continue
+		}
+		if override.Table != "" {
+			policy.Table = override.Table
+		}
+		if override.TimeColumn != "" {
+			policy.TimeColumn = override.TimeColumn
+		}
+		if override.Filter != "" {
+			policy.Filter = override.Filter
+		}
+		if override.MaxAge > 0 {
+			policy.MaxAge = override.MaxAge
+		}
+		if override.MaxRows > 0 {
+			policy.MaxRows = override.MaxRows
+		}
+		if override.Action != "" {
+			policy.Action = override.Action
+		}
+		if override.Schedule != "" {
+			policy.Schedule = override.Schedule
+		}
+		if override.BatchSize > 0 {
+			policy.BatchSize = override.BatchSize
+		}
+		policy.Disabled = override.Disabled
+		merged[override.Dataset] = policy
+	}
+
+	policies := make([]Policy, 0, len(merged))
+	for _, policy := range merged {
+		if !policy.Disabled {
+			policies = append(policies, policy)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Dataset < policies[j].Dataset })
+	return policies
+}
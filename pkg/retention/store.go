@@ -0,0 +1,150 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// Store 保留策略的清理与执行记录
+type Store interface {
+	// Pending 数据集中早于 cutoff 的行数与总行数，cutoff 为零值时 expired 为 0
+	Pending(ctx context.Context, p *Policy, cutoff time.Time) (expired, total int64, err error)
+	// PurgeExpired 按策略处理最多 limit 行早于 cutoff 的行
+	PurgeExpired(ctx context.Context, p *Policy, cutoff time.Time, limit int) (int64, error)
+	// PurgeExcess 按策略处理最多 limit 行超出 MaxRows 的最旧的行
+	PurgeExcess(ctx context.Context, p *Policy, limit int) (int64, error)
+	SaveRun(ctx context.Context, run *Run) error
+	// ListRuns 最近的执行记录，dataset 为空时不限数据集
+	ListRuns(ctx context.Context, dataset string, limit int) ([]*Run, error)
+}
+
+// SQLStore 基于 PostgreSQL 的存储。按 (tableoid, ctid) 定位行，适用于无主键与分区表
+type SQLStore struct {
+	db *database.DB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// NewSQLStore 创建保留策略存储
+func NewSQLStore(db *database.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Pending 统计待清理的行数
+func (s *SQLStore) Pending(ctx context.Context, p *Policy, cutoff time.Time) (int64, int64, error) {
+	query := fmt.Sprintf(`
+		SELECT COUNT(*) FILTER (WHERE %s < $1) AS expired, COUNT(*) AS total
+		FROM %s WHERE %s`, p.TimeColumn, p.Table, p.where())
+	var counts struct {
+		Expired int64 `db:"expired"`
+		Total   int64 `db:"total"`
+	}
+	if err := s.db.GetContext(ctx, &counts, query, cutoff); err != nil {
+		return 0, 0, fmt.Errorf("failed to count %s: %w", p.Dataset, err)
+	}
+	return counts.Expired, counts.Total, nil
+}
+
+// PurgeExpired 清理一批过期的行
+func (s *SQLStore) PurgeExpired(ctx context.Context, p *Policy, cutoff time.Time, limit int) (int64, error) {
+	doomed := fmt.Sprintf(`
+		SELECT tableoid, ctid FROM %s WHERE %s AND %s < $1 LIMIT $2`, p.Table, p.where(), p.TimeColumn)
+	return s.purge(ctx, p, doomed, cutoff, limit)
+}
+
+// PurgeExcess 清理一批超出行数上限的最旧的行
+func (s *SQLStore) PurgeExcess(ctx context.Context, p *Policy, limit int) (int64, error) {
+	if p.MaxRows <= 0 {
+		return 0, nil
+	}
+	doomed := fmt.Sprintf(`
+		SELECT tableoid, ctid FROM %s WHERE %s ORDER BY %s DESC OFFSET $1 LIMIT $2`, p.Table, p.where(), p.TimeColumn)
+	return s.purge(ctx, p, doomed, p.MaxRows, limit)
+}
+
+// purge 删除 doomed 选出的行；归档时删除与写入归档在同一条语句内完成
+func (s *SQLStore) purge(ctx context.Context, p *Policy, doomed string, args ...interface{}) (int64, error) {
+	var query string
+	if p.Action == ActionArchive {
+		query = fmt.Sprintf(`
+			WITH doomed AS (%s),
+			deleted AS (
+				DELETE FROM %s AS t WHERE (t.tableoid, t.ctid) IN (SELECT tableoid, ctid FROM doomed)
+				RETURNING t.%s AS occurred_at, to_jsonb(t) AS row_data
+			)
+			INSERT INTO retention_archive (dataset, source_table, occurred_at, row_data)
+			SELECT $3, $4, occurred_at, row_data FROM deleted`, doomed, p.Table, p.TimeColumn)
+		args = append(args, p.Dataset, p.Table)
+	} else {
+		query = fmt.Sprintf(`
+			WITH doomed AS (%s)
+			DELETE FROM %s AS t WHERE (t.tableoid, t.ctid) IN (SELECT tableoid, ctid FROM doomed)`, doomed, p.Table)
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge %s: %w", p.Dataset, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge %s: %w", p.Dataset, err)
+	}
+	return n, nil
+}
+
+// runRow retention_runs 表的行
+type runRow struct {
+	Dataset    string     `db:"dataset"`
+	Action     string     `db:"action"`
+	Cutoff     *time.Time `db:"cutoff"`
+	Expired    int64      `db:"expired"`
+	Excess     int64      `db:"excess"`
+	Purged     int64      `db:"purged"`
+	Error      string     `db:"error"`
+	StartedAt  time.Time  `db:"started_at"`
+	DurationMs int64      `db:"duration_ms"`
+}
+
+// SaveRun 写入一次执行记录，只记录实际执行，不记录预演
+func (s *SQLStore) SaveRun(ctx context.Context, run *Run) error {
+	query := `
+		INSERT INTO retention_runs (dataset, action, cutoff, expired, excess, purged, error, started_at, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	if _, err := s.db.ExecContext(ctx, query, run.Dataset, string(run.Action), run.Cutoff, run.Expired,
+		run.Excess, run.Purged, run.Error, run.StartedAt, run.Duration.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to save retention run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns 按时间倒序返回最近的执行记录
+func (s *SQLStore) ListRuns(ctx context.Context, dataset string, limit int) ([]*Run, error) {
+	query := `
+		SELECT dataset, action, cutoff, expired, excess, purged, error, started_at, duration_ms
+		FROM retention_runs
+		WHERE $1 = '' OR dataset = $1
+		ORDER BY started_at DESC, id DESC
+		LIMIT $2`
+	var rows []runRow
+	if err := s.db.SelectContext(ctx, &rows, query, dataset, limit); err != nil {
+		return nil, fmt.Errorf("failed to list retention runs: %w", err)
+	}
+
+	runs := make([]*Run, len(rows))
+	for i, row := range rows {
+		runs[i] = &Run{
+			Dataset:   row.Dataset,
+			Action:    Action(row.Action),
+			Cutoff:    row.Cutoff,
+			Expired:   row.Expired,
+			Excess:    row.Excess,
+			Purged:    row.Purged,
+			Error:     row.Error,
+			StartedAt: row.StartedAt,
+			Duration:  time.Duration(row.DurationMs) * time.Millisecond,
+		}
+	}
+	return runs, nil
+}
@@ -0,0 +1,85 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAdminAuditMiddleware 修改类请求记录操作者、路由、参数、请求体与原因，查询请求不记录
+func TestAdminAuditMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	monitor := NewSecurityMonitor(fakes.NewCache(nil), metrics.GetGlobalCollector(), NewDefaultSecurityLogger())
+	router := gin.New()
+	group := router.Group("/admin", func(c *gin.Context) {
+		c.Set("userID", "apikey:secctl")
+		c.Set("apiKey", "secctl")
+	}, monitor.AdminAuditMiddleware())
+	var received string
+	group.PUT("/users/:id/role", func(c *gin.Context) {
+		var req UserRoleRequest
+		require.NoError(t, c.ShouldBindJSON(&req))
+		received = string(req.Role)
+		c.Status(http.StatusOK)
+	})
+	group.GET("/users/:id/access", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/users/42/role", strings.NewReader(`{"role":"moderator"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(AuditReasonHeader, "ticket SEC-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/users/42/access", nil))
+
+	assert.Equal(t, "moderator", received, "the handler still reads the full body")
+	events := monitor.getEventsInPeriod(time.Minute)
+	require.Len(t, events, 1)
+	event := events[0]
+	assert.Equal(t, EventAdminAction, event.Type)
+	assert.Equal(t, LevelInfo, event.Level)
+	assert.Equal(t, "apikey:secctl", event.UserID)
+	assert.Equal(t, http.StatusOK, event.Status)
+	assert.Equal(t, "/admin/users/:id/role", event.Details["route"])
+	assert.Equal(t, map[string]string{"id": "42"}, event.Details["params"])
+	assert.JSONEq(t, `{"role":"moderator"}`, string(event.Details["body"].(json.RawMessage)))
+	assert.Equal(t, "ticket SEC-1", event.Details["reason"])
+	assert.Equal(t, "secctl", event.Details["api_key"])
+}
+
+// TestSessionRevoker 吊销前签发的令牌失效，之后签发的令牌有效，没有签发时间的旧令牌一律失效
+func TestSessionRevoker(t *testing.T) {
+	ctx := context.Background()
+	store := fakes.NewCache(nil)
+	revoker := NewSessionRevoker(store, utils.TokenLifetime)
+	claims := func(issuedAt time.Time) *utils.Claims {
+		c := &utils.Claims{UserID: "u1"}
+		if !issuedAt.IsZero() {
+			c.IssuedAt = jwt.NewNumericDate(issuedAt)
+		}
+		return c
+	}
+
+	assert.False(t, revoker.IsRevoked(ctx, claims(time.Now())))
+	revokedAt, err := revoker.RevokeUser(ctx, "u1")
+	require.NoError(t, err)
+	ttl, ok := store.TTL("auth:revoked_before:u1")
+	require.True(t, ok)
+	assert.Equal(t, utils.TokenLifetime, ttl)
+
+	assert.True(t, revoker.IsRevoked(ctx, claims(revokedAt.Add(-time.Hour))))
+	assert.True(t, revoker.IsRevoked(ctx, claims(revokedAt)))
+	assert.True(t, revoker.IsRevoked(ctx, claims(time.Time{})))
+	assert.False(t, revoker.IsRevoked(ctx, claims(revokedAt.Add(time.Second))))
+	assert.False(t, revoker.IsRevoked(ctx, &utils.Claims{UserID: "u2"}))
+}
@@ -0,0 +1,137 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrInvalidHash 哈希格式无法解析
+var ErrInvalidHash = errors.New("invalid password hash format")
+
+// Argon2Params Argon2id 参数
+type Argon2Params struct {
+	Memory      uint32 `json:"memory"` // KiB
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+	SaltLength  uint32 `json:"salt_length"`
+	KeyLength   uint32 `json:"key_length"`
+}
+
+// DefaultArgon2Params 默认参数（OWASP 推荐的 64MiB / 3 次迭代）
+func DefaultArgon2Params() *Argon2Params {
+	return &Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hasher Argon2id 密码哈希，输出 PHC 字符串格式：
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Hasher struct {
+	params *Argon2Params
+}
+
+// NewHasher 创建密码哈希器
+func NewHasher(params *Argon2Params) *Hasher {
+	if params == nil {
+		params = DefaultArgon2Params()
+	}
+	return &Hasher{params: params}
+}
+
+// Hash 计算密码哈希
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return encodeHash(h.params, salt, key), nil
+}
+
+// Verify 校验密码；needsRehash 表示哈希参数与当前配置不同，应在登录成功后重新哈希
+func (h *Hasher) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	params, salt, key, err := decodeHash(encoded)
+	if err != nil {
+		return false, false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	return true, h.NeedsRehash(params, salt, key), nil
+}
+
+// VerifyAndRehash 校验密码，参数变化时返回新哈希（否则为空字符串）
+func (h *Hasher) VerifyAndRehash(password, encoded string) (bool, string, error) {
+	match, needsRehash, err := h.Verify(password, encoded)
+	if err != nil || !match || !needsRehash {
+		return match, "", err
+	}
+
+	rehashed, err := h.Hash(password)
+	if err != nil {
+		return true, "", err
+	}
+	return true, rehashed, nil
+}
+
+// NeedsRehash 判断已存储哈希的参数是否落后于当前配置
+func (h *Hasher) NeedsRehash(params *Argon2Params, salt, key []byte) bool {
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+func encodeHash(params *Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key))
+}
+
+func decodeHash(encoded string) (*Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	params := &Argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,66 @@
+package credentials
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams 测试用的小参数，避免每次哈希占用 64MiB
+func testParams(memory, iterations uint32) *Argon2Params {
+	return &Argon2Params{Memory: memory, Iterations: iterations, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+}
+
+// TestHasher_HashAndVerify 输出 PHC 格式，相同密码每次盐不同，错误密码不匹配
+func TestHasher_HashAndVerify(t *testing.T) {
+	hasher := NewHasher(testParams(1024, 1))
+
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), hash)
+	other, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	match, needsRehash, err := hasher.Verify("correct horse", hash)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+
+	match, _, err = hasher.Verify("wrong horse", hash)
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	_, _, err = hasher.Verify("correct horse", "$2a$10$bcrypthash")
+	assert.ErrorIs(t, err, ErrInvalidHash)
+}
+
+// TestHasher_ParamUpgrade 旧参数的哈希在新配置下仍可校验，并返回以新参数重新计算的哈希；
+// 错误密码不会触发重新哈希
+func TestHasher_ParamUpgrade(t *testing.T) {
+	old, err := NewHasher(testParams(1024, 1)).Hash("correct horse")
+	require.NoError(t, err)
+	upgraded := NewHasher(testParams(2048, 2))
+
+	match, needsRehash, err := upgraded.Verify("correct horse", old)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, needsRehash)
+
+	match, rehashed, err := upgraded.VerifyAndRehash("correct horse", old)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, strings.HasPrefix(rehashed, "$argon2id$v=19$m=2048,t=2,p=1$"), rehashed)
+
+	match, needsRehash, err = upgraded.Verify("correct horse", rehashed)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.False(t, needsRehash)
+
+	match, rehashed, err = upgraded.VerifyAndRehash("wrong horse", old)
+	require.NoError(t, err)
+	assert.False(t, match)
+	assert.Empty(t, rehashed)
+}
@@ -0,0 +1,107 @@
+// Package credentials 密码凭证：Argon2id 哈希与参数升级、强度策略、泄露库检查与历史密码。
+//
+// 目前用户只能以手机号验证码（user 模块）或企业单点登录（pkg/oidc）登录，users.password 没有写入路径，
+// 本包尚未挂载到接口上。新增密码登录时调用 Manager.Authenticate，修改密码时调用 Manager.SetPassword，
+// 新哈希与密码历史由 PasswordStore 在同一事务中写入
+package credentials
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+var (
+	// ErrPasswordReused 新密码与最近使用过的密码相同
+	ErrPasswordReused = errors.New("password was used recently")
+	// ErrNoPasswordStore 未配置密码存储，无法保存密码
+	ErrNoPasswordStore = errors.New("password store not configured")
+)
+
+// Manager 组合哈希、密码策略、泄露检查与密码存储
+type Manager struct {
+	hasher *Hasher
+	policy *PasswordPolicy
+	breach BreachChecker
+	store  PasswordStore
+}
+
+// NewManager 创建凭证管理器，breach 可为 nil；store 为 nil 时只能校验密码
+func NewManager(hasher *Hasher, policy *PasswordPolicy, breach BreachChecker, store PasswordStore) *Manager {
+	if hasher == nil {
+		hasher = NewHasher(nil)
+	}
+	if policy == nil {
+		policy = DefaultPasswordPolicy()
+	}
+	return &Manager{
+		hasher: hasher,
+		policy: policy,
+		breach: breach,
+		store:  store,
+	}
+}
+
+// SetPassword 校验并保存新密码：依次检查策略、泄露库与最近 HistoryDepth 个历史密码，
+// 通过后写入用户记录，历史只在哈希写入成功的同一事务中记录。userInputs 为用户名、手机号等个人信息，用于强度扣分
+func (m *Manager) SetPassword(ctx context.Context, userID, password string, userInputs ...string) error {
+	if m.store == nil {
+		return ErrNoPasswordStore
+	}
+	if err := m.policy.Check(ctx, password, m.breach, userInputs...); err != nil {
+		return err
+	}
+
+	if m.policy.HistoryDepth > 0 {
+		recent, err := m.store.Recent(ctx, userID, m.policy.HistoryDepth)
+		if err != nil {
+			return err
+		}
+		for _, old := range recent {
+			match, _, err := m.hasher.Verify(password, old)
+			if err != nil {
+				// 历史中的非 Argon2 哈希无法比较，跳过
+				continue
+			}
+			if match {
+				return ErrPasswordReused
+			}
+		}
+	}
+
+	hash, err := m.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return m.store.Save(ctx, userID, hash, m.policy.HistoryDepth)
+}
+
+// Authenticate 登录时校验用户密码，encoded 为用户记录中的哈希；哈希参数落后于当前配置时
+// 以新参数重新哈希并写回，写回失败只记录日志，不影响本次登录
+func (m *Manager) Authenticate(ctx context.Context, userID, password, encoded string) (bool, error) {
+	match, rehashed, err := m.hasher.VerifyAndRehash(password, encoded)
+	if err != nil || !match {
+		return false, err
+	}
+	if rehashed != "" && m.store != nil {
+		if err := m.store.Rehash(ctx, userID, encoded, rehashed); err != nil {
+			log.Printf("Failed to rehash password of user %s: %v", userID, err)
+		}
+	}
+	return true, nil
+}
+
+// Verify 登录时校验密码；参数变化时返回新哈希，调用方应将其写回用户记录
+func (m *Manager) Verify(password, encoded string) (bool, string, error) {
+	return m.hasher.VerifyAndRehash(password, encoded)
+}
+
+// Strength 评估密码强度
+func (m *Manager) Strength(password string, userInputs ...string) Strength {
+	return Score(password, userInputs...)
+}
+
+// Policy 当前密码策略
+func (m *Manager) Policy() *PasswordPolicy {
+	return m.policy
+}
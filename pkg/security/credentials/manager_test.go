@@ -0,0 +1,144 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPasswordStore 内存密码存储，history 按时间倒序
+type memoryPasswordStore struct {
+	password map[string]string
+	history  map[string][]string
+	rehashed int
+}
+
+func newMemoryPasswordStore() *memoryPasswordStore {
+	return &memoryPasswordStore{password: make(map[string]string), history: make(map[string][]string)}
+}
+
+func (s *memoryPasswordStore) Recent(ctx context.Context, userID string, n int) ([]string, error) {
+	recent := s.history[userID]
+	if len(recent) > n {
+		recent = recent[:n]
+	}
+	return recent, nil
+}
+
+func (s *memoryPasswordStore) Save(ctx context.Context, userID, hash string, keep int) error {
+	s.password[userID] = hash
+	history := append([]string{hash}, s.history[userID]...)
+	if len(history) > keep {
+		history = history[:keep]
+	}
+	s.history[userID] = history
+	return nil
+}
+
+func (s *memoryPasswordStore) Rehash(ctx context.Context, userID, old, hash string) error {
+	if s.password[userID] == old {
+		s.password[userID] = hash
+		s.rehashed++
+	}
+	return nil
+}
+
+func newTestManager(store PasswordStore, depth int) *Manager {
+	policy := DefaultPasswordPolicy()
+	policy.HistoryDepth = depth
+	return NewManager(NewHasher(testParams(1024, 1)), policy, nil, store)
+}
+
+// TestManager_HistoryReuse 最近 HistoryDepth 个密码不能重复使用，更早的密码可以再次使用
+func TestManager_HistoryReuse(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryPasswordStore()
+	manager := newTestManager(store, 2)
+
+	require.NoError(t, manager.SetPassword(ctx, "u1", "Tr0ub4dor&3-first"))
+	require.NoError(t, manager.SetPassword(ctx, "u1", "Tr0ub4dor&3-second"))
+
+	assert.ErrorIs(t, manager.SetPassword(ctx, "u1", "Tr0ub4dor&3-second"), ErrPasswordReused)
+	assert.ErrorIs(t, manager.SetPassword(ctx, "u1", "Tr0ub4dor&3-first"), ErrPasswordReused)
+	// 其他用户的历史互不影响
+	require.NoError(t, manager.SetPassword(ctx, "u2", "Tr0ub4dor&3-first"))
+
+	require.NoError(t, manager.SetPassword(ctx, "u1", "Tr0ub4dor&3-third"))
+	require.NoError(t, manager.SetPassword(ctx, "u1", "Tr0ub4dor&3-first"), "only the last 2 passwords are kept")
+
+	var perr *PolicyError
+	assert.ErrorAs(t, manager.SetPassword(ctx, "u1", "password"), &perr)
+	assert.ErrorIs(t, NewManager(nil, nil, nil, nil).SetPassword(ctx, "u1", "Tr0ub4dor&3-first"), ErrNoPasswordStore)
+}
+
+// TestManager_AuthenticateRehash 登录成功且参数落后时写回新哈希，参数一致或密码错误时不写
+func TestManager_AuthenticateRehash(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryPasswordStore()
+	old, err := NewHasher(testParams(512, 1)).Hash("Tr0ub4dor&3-first")
+	require.NoError(t, err)
+	store.password["u1"] = old
+	manager := newTestManager(store, 5)
+
+	match, err := manager.Authenticate(ctx, "u1", "wrong", old)
+	require.NoError(t, err)
+	assert.False(t, match)
+	assert.Equal(t, old, store.password["u1"])
+
+	match, err = manager.Authenticate(ctx, "u1", "Tr0ub4dor&3-first", old)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.Equal(t, 1, store.rehashed)
+	assert.Contains(t, store.password["u1"], "$m=1024,t=1,p=1$")
+	assert.Empty(t, store.history["u1"], "rehashing keeps the same password and is not recorded in history")
+
+	match, err = manager.Authenticate(ctx, "u1", "Tr0ub4dor&3-first", store.password["u1"])
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.Equal(t, 1, store.rehashed)
+}
+
+const (
+	updatePassword = `UPDATE users SET password = \$2`
+	insertHistory  = `INSERT INTO password_history`
+	pruneHistory   = `DELETE FROM password_history`
+)
+
+// TestSQLPasswordStore_Save 哈希与历史在同一事务中写入；写历史失败时整体回滚，用户不存在时不写历史
+func TestSQLPasswordStore_Save(t *testing.T) {
+	ctx := context.Background()
+	db, mock := fakes.NewDB(t)
+	store := NewSQLPasswordStore(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePassword).WithArgs("u1", "h1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertHistory).WithArgs("u1", "h1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(pruneHistory).WithArgs("u1", 5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	require.NoError(t, store.Save(ctx, "u1", "h1", 5))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePassword).WithArgs("u1", "h2").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insertHistory).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+	assert.Error(t, store.Save(ctx, "u1", "h2", 5))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(updatePassword).WithArgs("gone", "h3").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, store.Save(ctx, "gone", "h3", 5), ErrUserNotFound)
+}
+
+// TestSQLPasswordStore_Rehash 以旧哈希为条件更新，不写历史
+func TestSQLPasswordStore_Rehash(t *testing.T) {
+	db, mock := fakes.NewDB(t)
+	mock.ExpectExec(`UPDATE users SET password = \$3 WHERE id = \$1 AND password = \$2`).
+		WithArgs("u1", "old", "new").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, NewSQLPasswordStore(db).Rehash(context.Background(), "u1", "old", "new"))
+}
@@ -0,0 +1,290 @@
+package credentials
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BreachChecker 泄露密码库检查接口
+type BreachChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	MinLength    int `json:"min_length"`
+	MaxLength    int `json:"max_length"` // Argon2 输入无上限，限制长度避免资源滥用
+	MinScore     int `json:"min_score"`  // 0-4
+	HistoryDepth int `json:"history_depth"`
+}
+
+// DefaultPasswordPolicy 默认密码策略
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:    8,
+		MaxLength:    128,
+		MinScore:     3,
+		HistoryDepth: 5,
+	}
+}
+
+// Strength 强度评估结果
+type Strength struct {
+	Score    int      `json:"score"`   // 0-4
+	Entropy  float64  `json:"entropy"` // 估算熵（bit）
+	Feedback []string `json:"feedback,omitempty"`
+}
+
+// commonPasswords 常见弱密码（小写），命中直接判为 0 分
+var commonPasswords = map[string]bool{
+	"password": true, "123456": true, "12345678": true, "123456789": true, "1234567890": true,
+	"qwerty": true, "qwerty123": true, "abc123": true, "111111": true, "123123": true,
+	"admin": true, "admin123": true, "letmein": true, "welcome": true, "iloveyou": true,
+	"monkey": true, "dragon": true, "football": true, "baseball": true, "sunshine": true,
+	"princess": true, "passw0rd": true, "password1": true, "password123": true, "1q2w3e4r": true,
+	"qwertyuiop": true, "asdfghjkl": true, "zxcvbnm": true, "000000": true, "woaini1314": true,
+}
+
+// keyboardRows 键盘序列
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890"}
+
+// Score 估算密码强度（zxcvbn 风格的简化实现）：按字符集与长度估算熵，
+// 对常见密码、重复、序列、键盘序列与用户信息扣分
+func Score(password string, userInputs ...string) Strength {
+	lower := strings.ToLower(password)
+	strength := Strength{}
+
+	if commonPasswords[lower] {
+		strength.Feedback = append(strength.Feedback, "password is too common")
+		return strength
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	for _, r := range password {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLower(r):
+			hasLower = true
+		case r < unicode.MaxASCII && unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case r < unicode.MaxASCII:
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+	}
+
+	pool := 0
+	if hasLower {
+		pool += 26
+	}
+	if hasUpper {
+		pool += 26
+	}
+	if hasDigit {
+		pool += 10
+	}
+	if hasSymbol {
+		pool += 33
+	}
+	if hasOther {
+		pool += 100
+	}
+
+	// 有效长度：扣除重复字符与连续序列
+	effective := float64(utf8.RuneCountInString(password))
+	if n := countRepeats(lower); n > 0 {
+		effective -= float64(n) * 0.75
+		strength.Feedback = append(strength.Feedback, "avoid repeated characters")
+	}
+	if n := countSequences(lower); n > 0 {
+		effective -= float64(n) * 0.75
+		strength.Feedback = append(strength.Feedback, "avoid sequences like abc or 123 and keyboard patterns")
+	}
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if len(input) >= 3 && strings.Contains(lower, input) {
+			effective -= float64(utf8.RuneCountInString(input))
+			strength.Feedback = append(strength.Feedback, "avoid using personal information")
+		}
+	}
+	for common := range commonPasswords {
+		if len(common) >= 6 && strings.Contains(lower, common) {
+			effective -= float64(len(common)) * 0.75
+			strength.Feedback = append(strength.Feedback, "avoid common words and passwords")
+			break
+		}
+	}
+
+	if effective < 0 || pool == 0 {
+		effective = 0
+	}
+	strength.Entropy = effective * math.Log2(float64(max(pool, 1)))
+
+	switch {
+	case strength.Entropy < 28:
+		strength.Score = 0
+	case strength.Entropy < 36:
+		strength.Score = 1
+	case strength.Entropy < 60:
+		strength.Score = 2
+	case strength.Entropy < 80:
+		strength.Score = 3
+	default:
+		strength.Score = 4
+	}
+
+	if strength.Score < 3 && pool < 60 {
+		strength.Feedback = append(strength.Feedback, "mix upper and lower case letters, digits and symbols")
+	}
+	return strength
+}
+
+// countRepeats 统计与前一字符相同的字符数
+func countRepeats(s string) int {
+	count := 0
+	var prev rune = -1
+	for _, r := range s {
+		if r == prev {
+			count++
+		}
+		prev = r
+	}
+	return count
+}
+
+// countSequences 统计处于递增/递减序列或键盘序列中的字符数
+func countSequences(s string) int {
+	runes := []rune(s)
+	count := 0
+	for i := 2; i < len(runes); i++ {
+		d1 := runes[i-1] - runes[i-2]
+		d2 := runes[i] - runes[i-1]
+		if (d1 == 1 || d1 == -1) && d1 == d2 {
+			count++
+			continue
+		}
+		if isKeyboardRun(string(runes[i-2 : i+1])) {
+			count++
+		}
+	}
+	return count
+}
+
+func isKeyboardRun(s string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Violation 密码策略违规
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError 密码不满足策略
+type PolicyError struct {
+	Violations []Violation `json:"violations"`
+	Strength   Strength    `json:"strength"`
+}
+
+// Error 实现 error
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password policy violated: " + strings.Join(messages, "; ")
+}
+
+// Check 检查密码是否满足长度与强度要求，并检查泄露库（checker 可为 nil）
+func (p *PasswordPolicy) Check(ctx context.Context, password string, checker BreachChecker, userInputs ...string) error {
+	perr := &PolicyError{}
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		perr.Violations = append(perr.Violations, Violation{"min_length", fmt.Sprintf("password must be at least %d characters", p.MinLength)})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		perr.Violations = append(perr.Violations, Violation{"max_length", fmt.Sprintf("password must be at most %d characters", p.MaxLength)})
+	}
+
+	perr.Strength = Score(password, userInputs...)
+	if perr.Strength.Score < p.MinScore {
+		perr.Violations = append(perr.Violations, Violation{"strength", "password is too weak"})
+	}
+
+	if checker != nil && len(perr.Violations) == 0 {
+		breached, err := checker.IsBreached(ctx, password)
+		if err != nil {
+			return fmt.Errorf("failed to check breached passwords: %w", err)
+		}
+		if breached {
+			perr.Violations = append(perr.Violations, Violation{"breached", "password has appeared in a data breach"})
+		}
+	}
+
+	if len(perr.Violations) > 0 {
+		return perr
+	}
+	return nil
+}
+
+// PwnedPasswordsChecker 基于 k-anonymity 的泄露密码查询，只发送 SHA-1 前 5 位
+type PwnedPasswordsChecker struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewPwnedPasswordsChecker 创建泄露密码查询器
+func NewPwnedPasswordsChecker() *PwnedPasswordsChecker {
+	return &PwnedPasswordsChecker{
+		BaseURL: "https://api.pwnedpasswords.com/range/",
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// IsBreached 实现 BreachChecker
+func (pc *PwnedPasswordsChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pc.BaseURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := pc.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// 每行格式：<后 35 位>:<出现次数>，补位行次数为 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		hashSuffix, count, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if found && hashSuffix == suffix && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
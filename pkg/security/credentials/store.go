@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"user_crud_jwt/pkg/database"
+)
+
+// ErrUserNotFound 用户不存在或已删除
+var ErrUserNotFound = errors.New("user not found")
+
+// PasswordStore 用户密码与密码历史存储
+type PasswordStore interface {
+	// Recent 返回用户最近 n 个密码哈希，按时间倒序
+	Recent(ctx context.Context, userID string, n int) ([]string, error)
+	// Save 写入用户的新密码哈希并记入历史，只保留最近 keep 条；两者在同一事务中提交
+	Save(ctx context.Context, userID, hash string, keep int) error
+	// Rehash 登录时以新参数的哈希替换旧哈希，仅当存储的仍是 old 时生效（密码未变，不记历史）
+	Rehash(ctx context.Context, userID, old, hash string) error
+}
+
+// SQLPasswordStore 基于 users.password 与 password_history 表的密码存储
+type SQLPasswordStore struct {
+	db *database.DB
+}
+
+// NewSQLPasswordStore 创建密码存储
+func NewSQLPasswordStore(db *database.DB) *SQLPasswordStore {
+	return &SQLPasswordStore{db: db}
+}
+
+// Recent 实现 PasswordStore
+func (s *SQLPasswordStore) Recent(ctx context.Context, userID string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	var hashes []string
+	query := `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+	if err := s.db.SelectContext(ctx, &hashes, query, userID, n); err != nil {
+		return nil, fmt.Errorf("failed to query password history: %w", err)
+	}
+	return hashes, nil
+}
+
+// Save 实现 PasswordStore：先写用户记录，成功后在同一事务中记录历史，任一步失败都不会留下历史
+func (s *SQLPasswordStore) Save(ctx context.Context, userID, hash string, keep int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE users SET password = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL`, userID, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrUserNotFound
+	}
+
+	if keep > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO password_history (user_id, password_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("failed to insert password history: %w", err)
+		}
+		query := `
+			DELETE FROM password_history
+			WHERE user_id = $1 AND id NOT IN (
+				SELECT id FROM password_history
+				WHERE user_id = $1
+				ORDER BY created_at DESC, id DESC
+				LIMIT $2
+			)`
+		if _, err := tx.ExecContext(ctx, query, userID, keep); err != nil {
+			return fmt.Errorf("failed to prune password history: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Rehash 实现 PasswordStore，以旧哈希为条件更新，避免覆盖并发修改的密码
+func (s *SQLPasswordStore) Rehash(ctx context.Context, userID, old, hash string) error {
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE users SET password = $3 WHERE id = $1 AND password = $2 AND deleted_at IS NULL`, userID, old, hash); err != nil {
+		return fmt.Errorf("failed to rehash password: %w", err)
+	}
+	return nil
+}
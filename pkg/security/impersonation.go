@@ -0,0 +1,300 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PermissionImpersonate 以用户身份临时登录排查问题，需单独授予，admin 角色默认没有
+const PermissionImpersonate Permission = "user:impersonate"
+
+// EventImpersonation 代登录会话的开始、结束与期间的每个请求，details.action 为 started、ended 或 request
+const EventImpersonation SecurityEventType = "impersonation"
+
+var (
+	// ErrImpersonationNotFound 代登录会话不存在或已过期
+	ErrImpersonationNotFound = errors.New("impersonation session not found")
+	// ErrImpersonationDenied 操作者没有代登录权限，或目标用户不允许被代登录
+	ErrImpersonationDenied = errors.New("impersonation denied")
+)
+
+// userRoleAdmin 用户表中的管理员角色，与用户模块的 model.RoleAdmin 一致；管理员不允许被代登录
+const userRoleAdmin = 1
+
+// ImpersonationConfig 代登录配置
+type ImpersonationConfig struct {
+	DefaultDuration time.Duration // 未指定时长时的会话时长
+	MaxDuration     time.Duration // 会话时长上限，超过时按上限
+}
+
+// DefaultImpersonationConfig 默认代登录配置
+func DefaultImpersonationConfig() *ImpersonationConfig {
+	return &ImpersonationConfig{
+		DefaultDuration: 30 * time.Minute,
+		MaxDuration:     2 * time.Hour,
+	}
+}
+
+// ImpersonationSession 代登录会话
+type ImpersonationSession struct {
+	ID        string     `json:"id"`
+	ActorID   string     `json:"actor_id"`
+	UserID    string     `json:"user_id"`
+	TenantID  string     `json:"tenant_id,omitempty"`
+	Reason    string     `json:"reason"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	EndedBy   string     `json:"ended_by,omitempty"`
+}
+
+// Active 会话是否仍然有效
+func (s *ImpersonationSession) Active() bool {
+	return s.EndedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// ImpersonationManager 代登录会话：具备 user:impersonate 权限的管理员以用户身份获得限时令牌，
+// 会话存放在共享缓存中，结束后令牌在所有实例上立即失效。实现 utils.TokenRevocations
+type ImpersonationManager struct {
+	cache   cache.CacheService
+	rbac    *RBAC
+	roles   utils.RoleSource
+	monitor *SecurityMonitor
+	config  *ImpersonationConfig
+}
+
+// NewImpersonationManager 创建代登录管理，monitor 为空时不记录审计事件
+func NewImpersonationManager(cacheService cache.CacheService, rbac *RBAC, monitor *SecurityMonitor, config *ImpersonationConfig) *ImpersonationManager {
+	if config == nil {
+		config = DefaultImpersonationConfig()
+	}
+	return &ImpersonationManager{
+		cache:   cacheService,
+		rbac:    rbac,
+		monitor: monitor,
+		config:  config,
+	}
+}
+
+// SetRoleSource 设置目标用户当前角色的来源（由用户模块提供），须在处理请求之前调用；未设置时无法开始代登录
+func (m *ImpersonationManager) SetRoleSource(roles utils.RoleSource) {
+	m.roles = roles
+}
+
+// Start 开始代登录会话并签发令牌。reason 必填，记录在审计事件中；duration 为 0 时使用默认时长。
+// 不允许代登录自己或管理员，令牌的角色为目标用户当前的角色
+func (m *ImpersonationManager) Start(ctx context.Context, actorID, userID, reason string, duration time.Duration) (*ImpersonationSession, string, error) {
+	if reason == "" {
+		return nil, "", fmt.Errorf("reason is required")
+	}
+	if actorID == "" || actorID == userID {
+		return nil, "", fmt.Errorf("%w: cannot impersonate yourself", ErrImpersonationDenied)
+	}
+	allowed, err := m.rbac.HasPermission(ctx, actorID, PermissionImpersonate)
+	if err != nil || !allowed {
+		return nil, "", fmt.Errorf("%w: %s requires %s", ErrImpersonationDenied, actorID, PermissionImpersonate)
+	}
+	if m.roles == nil {
+		return nil, "", fmt.Errorf("user roles are not available")
+	}
+	role, err := m.roles.CurrentRole(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrImpersonationNotFound, err)
+	}
+	if role == userRoleAdmin {
+		return nil, "", fmt.Errorf("%w: administrators cannot be impersonated", ErrImpersonationDenied)
+	}
+
+	if duration <= 0 {
+		duration = m.config.DefaultDuration
+	}
+	if duration > m.config.MaxDuration {
+		duration = m.config.MaxDuration
+	}
+	id, err := newImpersonationID()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := &ImpersonationSession{
+		ID:        id,
+		ActorID:   actorID,
+		UserID:    userID,
+		TenantID:  ctxutil.TenantID(ctx),
+		Reason:    reason,
+		StartedAt: now,
+		ExpiresAt: now.Add(duration),
+	}
+	if err := m.save(ctx, session); err != nil {
+		return nil, "", err
+	}
+	token, err := utils.GenerateImpersonationToken(session.TenantID, userID, role,
+		utils.Impersonation{SessionID: id, ActorID: actorID}, session.ExpiresAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to issue impersonation token: %w", err)
+	}
+
+	m.record(ctx, session, LevelWarning, "started", fmt.Sprintf("Admin %s started impersonating user %s", actorID, userID))
+	return session, token, nil
+}
+
+// Get 代登录会话，结束后保留到原到期时间；不存在或已过期时返回 ErrImpersonationNotFound
+func (m *ImpersonationManager) Get(ctx context.Context, id string) (*ImpersonationSession, error) {
+	var session ImpersonationSession
+	if err := m.cache.Get(ctx, impersonationKey(id), &session); err != nil {
+		if err.Error() == "cache miss" {
+			return nil, ErrImpersonationNotFound
+		}
+		return nil, fmt.Errorf("failed to get impersonation session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// End 结束代登录会话，令牌随即失效；已结束的会话原样返回
+func (m *ImpersonationManager) End(ctx context.Context, id, endedBy string) (*ImpersonationSession, error) {
+	session, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.EndedAt != nil {
+		return session, nil
+	}
+	now := time.Now()
+	session.EndedAt = &now
+	session.EndedBy = endedBy
+	if err := m.save(ctx, session); err != nil {
+		return nil, err
+	}
+
+	m.record(ctx, session, LevelInfo, "ended", fmt.Sprintf("Impersonation of user %s by %s ended by %s", session.UserID, session.ActorID, endedBy))
+	return session, nil
+}
+
+// IsRevoked 代登录令牌的会话已结束、过期或无法读取时视为已吊销；与 SessionRevoker 不同，读取失败时拒绝
+func (m *ImpersonationManager) IsRevoked(ctx context.Context, claims *utils.Claims) bool {
+	if claims.Impersonation == nil {
+		return false
+	}
+	session, err := m.Get(ctx, claims.Impersonation.SessionID)
+	if err != nil {
+		if !errors.Is(err, ErrImpersonationNotFound) {
+			log.Printf("Failed to check impersonation session %s: %v", claims.Impersonation.SessionID, err)
+		}
+		return true
+	}
+	return !session.Active() || session.UserID != claims.UserID || session.ActorID != claims.Impersonation.ActorID
+}
+
+// save 保存会话，保留到原到期时间
+func (m *ImpersonationManager) save(ctx context.Context, session *ImpersonationSession) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	if err := m.cache.Set(ctx, impersonationKey(session.ID), session, ttl); err != nil {
+		return fmt.Errorf("failed to save impersonation session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+// record 记录会话开始或结束的审计事件
+func (m *ImpersonationManager) record(ctx context.Context, session *ImpersonationSession, level SecurityEventLevel, action, message string) {
+	if m.monitor == nil {
+		return
+	}
+	details := map[string]interface{}{
+		"action":     action,
+		"session_id": session.ID,
+		"actor_id":   session.ActorID,
+		"reason":     session.Reason,
+		"expires_at": session.ExpiresAt,
+	}
+	if session.EndedBy != "" {
+		details["ended_by"] = session.EndedBy
+	}
+	m.monitor.RecordEvent(ctx, SecurityEvent{
+		Type:    EventImpersonation,
+		Level:   level,
+		Source:  "impersonation",
+		UserID:  session.UserID,
+		Message: message,
+		Details: details,
+	})
+}
+
+// impersonationKey 代登录会话的缓存键
+func impersonationKey(id string) string {
+	return "auth:impersonation:" + id
+}
+
+// newImpersonationID 随机的会话 ID
+func newImpersonationID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate impersonation id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ImpersonationAuditMiddleware 将代登录令牌发起的每个请求记录为 impersonation 安全事件，包括发起的管理员、会话、
+// 路由与状态码，修改类请求一并记录不超过 4 KiB 的 JSON 请求体。
+// 在路由之前全局挂载，请求结束后根据 AuthMiddleware 写入的 impersonationID 判断，被拒绝的请求同样记录
+func (sm *SecurityMonitor) ImpersonationAuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body json.RawMessage
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			body = auditBody(c)
+		}
+
+		c.Next()
+
+		sessionID := c.GetString("impersonationID")
+		if sessionID == "" {
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		details := map[string]interface{}{
+			"action":     "request",
+			"session_id": sessionID,
+			"actor_id":   c.GetString("impersonatorID"),
+			"route":      route,
+		}
+		if body != nil {
+			details["body"] = body
+		}
+		level := LevelInfo
+		if c.Writer.Status() >= http.StatusBadRequest {
+			level = LevelWarning
+		}
+		sm.RecordEvent(c.Request.Context(), SecurityEvent{
+			Type:      EventImpersonation,
+			Level:     level,
+			Source:    "impersonation",
+			UserID:    c.GetString("userID"),
+			IP:        c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+			Path:      c.Request.URL.Path,
+			Method:    c.Request.Method,
+			Status:    c.Writer.Status(),
+			Message:   fmt.Sprintf("Impersonated %s %s by %s", c.Request.Method, route, c.GetString("impersonatorID")),
+			Details:   details,
+		})
+	}
+}
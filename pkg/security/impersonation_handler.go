@@ -0,0 +1,120 @@
+package security
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartImpersonationRequest 开始代登录请求
+type StartImpersonationRequest struct {
+	Reason          string `json:"reason" binding:"required,max=500"`
+	DurationSeconds int    `json:"duration_seconds" binding:"min=0"` // 为 0 时使用默认时长，超过上限时按上限
+}
+
+// ImpersonationHandler 代登录管理接口
+type ImpersonationHandler struct {
+	manager *ImpersonationManager
+}
+
+// NewImpersonationHandler 创建代登录接口
+func NewImpersonationHandler(manager *ImpersonationManager) *ImpersonationHandler {
+	return &ImpersonationHandler{manager: manager}
+}
+
+// RegisterAdminRoutes 注册开始、查看与结束代登录的路由，调用方需挂载管理员权限校验
+func (h *ImpersonationHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.POST("/users/:id/impersonate", h.Start)
+	group.GET("/impersonations/:id", h.Get)
+	group.DELETE("/impersonations/:id", h.End)
+}
+
+// RegisterRoutes 注册代登录期间查看与结束当前会话的路由，调用方需挂载 AuthMiddleware
+func (h *ImpersonationHandler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/impersonation", h.Current)
+	group.DELETE("/impersonation", h.EndCurrent)
+}
+
+// Start 以用户身份开始代登录，返回会话与限时令牌
+func (h *ImpersonationHandler) Start(c *gin.Context) {
+	var req StartImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	session, token, err := h.manager.Start(c.Request.Context(), c.GetString("userID"), c.Param("id"), req.Reason,
+		time.Duration(req.DurationSeconds)*time.Second)
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"session":    session,
+		"token":      token,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// Get 代登录会话
+func (h *ImpersonationHandler) Get(c *gin.Context) {
+	session, err := h.manager.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// End 结束代登录会话，令牌立即失效
+func (h *ImpersonationHandler) End(c *gin.Context) {
+	session, err := h.manager.End(c.Request.Context(), c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// Current 当前的代登录会话，前端据此显示代登录提示；不是代登录令牌时返回 404
+func (h *ImpersonationHandler) Current(c *gin.Context) {
+	id := c.GetString("impersonationID")
+	if id == "" {
+		respondImpersonationError(c, ErrImpersonationNotFound)
+		return
+	}
+	session, err := h.manager.Get(c.Request.Context(), id)
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// EndCurrent 代登录期间结束当前会话
+func (h *ImpersonationHandler) EndCurrent(c *gin.Context) {
+	id := c.GetString("impersonationID")
+	if id == "" {
+		respondImpersonationError(c, ErrImpersonationNotFound)
+		return
+	}
+	session, err := h.manager.End(c.Request.Context(), id, c.GetString("impersonatorID"))
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+func respondImpersonationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrImpersonationNotFound):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, err.Error()))
+	case errors.Is(err, ErrImpersonationDenied):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNoPermission, err.Error()))
+	default:
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+	}
+}
@@ -0,0 +1,107 @@
+package security
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticRoles 固定的用户角色，未列出的用户不存在
+type staticRoles map[string]int
+
+func (r staticRoles) CurrentRole(ctx context.Context, userID string) (int, error) {
+	role, ok := r[userID]
+	if !ok {
+		return 0, ErrImpersonationNotFound
+	}
+	return role, nil
+}
+
+// TestImpersonationManager 需单独授予 user:impersonate，不能代登录管理员；时长按上限截断，
+// 结束会话后令牌立即被拒绝，开始与结束都记录审计事件
+func TestImpersonationManager(t *testing.T) {
+	previous := config.GlobalConfig.JWT.Secret
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	t.Cleanup(func() {
+		config.GlobalConfig.JWT.Secret = previous
+		utils.SetTokenRevocations()
+	})
+	ctx := context.Background()
+
+	monitor := NewSecurityMonitor(fakes.NewCache(nil), metrics.GetGlobalCollector(), NewDefaultSecurityLogger())
+	rbac := NewRBAC(fakes.NewCache(nil))
+	require.NoError(t, rbac.AssignRole(ctx, "support", RoleAdmin))
+	manager := NewImpersonationManager(fakes.NewCache(nil), rbac, monitor, nil)
+	manager.SetRoleSource(staticRoles{"u1": 0, "root": 1})
+	utils.SetTokenRevocations(manager)
+
+	_, _, err := manager.Start(ctx, "support", "u1", "ticket CS-1", 0)
+	assert.ErrorIs(t, err, ErrImpersonationDenied, "admin role does not include user:impersonate")
+
+	require.NoError(t, rbac.GrantPermission(ctx, "support", PermissionImpersonate))
+	_, _, err = manager.Start(ctx, "support", "root", "ticket CS-1", 0)
+	assert.ErrorIs(t, err, ErrImpersonationDenied)
+	_, _, err = manager.Start(ctx, "support", "missing", "ticket CS-1", 0)
+	assert.ErrorIs(t, err, ErrImpersonationNotFound)
+
+	session, token, err := manager.Start(ctx, "support", "u1", "ticket CS-1", 24*time.Hour)
+	require.NoError(t, err)
+	assert.WithinDuration(t, session.StartedAt.Add(2*time.Hour), session.ExpiresAt, time.Second)
+
+	claims, err := utils.ParseTokenContext(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+	assert.Equal(t, 0, claims.Role)
+	require.NotNil(t, claims.Impersonation)
+	assert.Equal(t, utils.Impersonation{SessionID: session.ID, ActorID: "support"}, *claims.Impersonation)
+	assert.WithinDuration(t, session.ExpiresAt, claims.ExpiresAt.Time, time.Second)
+
+	ended, err := manager.End(ctx, session.ID, "support")
+	require.NoError(t, err)
+	assert.False(t, ended.Active())
+	_, err = utils.ParseTokenContext(ctx, token)
+	assert.ErrorIs(t, err, utils.ErrTokenRevoked)
+
+	events := monitor.GetEvents(EventImpersonation, 10)
+	require.Len(t, events, 2)
+	actions := []interface{}{events[0].Details["action"], events[1].Details["action"]}
+	assert.ElementsMatch(t, []interface{}{"started", "ended"}, actions)
+}
+
+// TestImpersonationAuditMiddleware 代登录令牌的请求逐条记录发起的管理员与会话，普通请求不记录
+func TestImpersonationAuditMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	monitor := NewSecurityMonitor(fakes.NewCache(nil), metrics.GetGlobalCollector(), NewDefaultSecurityLogger())
+	router := gin.New()
+	router.Use(monitor.ImpersonationAuditMiddleware())
+	router.POST("/moments/:id/like", func(c *gin.Context) {
+		c.Set("userID", "u1")
+		if c.GetHeader("X-Test-Impersonated") != "" {
+			c.Set("impersonatorID", "support")
+			c.Set("impersonationID", "s1")
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/moments/7/like", nil)
+	req.Header.Set("X-Test-Impersonated", "1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/moments/7/like", nil))
+
+	events := monitor.GetEvents(EventImpersonation, 10)
+	require.Len(t, events, 1)
+	assert.Equal(t, "u1", events[0].UserID)
+	assert.Equal(t, "support", events[0].Details["actor_id"])
+	assert.Equal(t, "s1", events[0].Details["session_id"])
+	assert.Equal(t, "/moments/:id/like", events[0].Details["route"])
+}
@@ -0,0 +1,327 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// IPReputationConfig IP 信誉配置
+type IPReputationConfig struct {
+	// EventScores 各类安全事件为来源 IP 增加的分数
+	EventScores map[SecurityEventType]float64 `json:"event_scores"`
+	// FailedLoginScore 登录失败（级别高于 info 的登录事件）增加的分数
+	FailedLoginScore float64 `json:"failed_login_score"`
+	// BlockThreshold 分数达到阈值时临时封禁
+	BlockThreshold float64 `json:"block_threshold"`
+	// HalfLife 分数衰减一半所需的时间
+	HalfLife time.Duration `json:"half_life"`
+	// BlockDuration 首次封禁时长，此后每次封禁时长翻倍，不超过 MaxBlockDuration
+	BlockDuration    time.Duration `json:"block_duration"`
+	MaxBlockDuration time.Duration `json:"max_block_duration"`
+	// RefreshInterval 手动规则的刷新间隔，其他实例修改的规则最迟在此间隔后生效
+	RefreshInterval time.Duration `json:"refresh_interval"`
+}
+
+// DefaultIPReputationConfig 默认 IP 信誉配置
+func DefaultIPReputationConfig() *IPReputationConfig {
+	return &IPReputationConfig{
+		EventScores: map[SecurityEventType]float64{
+			EventUnauthorized:    5,
+			EventRateLimit:       3,
+			EventSuspicious:      10,
+			EventSQLInjection:    40,
+			EventXSS:             40,
+			EventCSRF:            20,
+			EventForbidden:       2,
+			EventInputValidation: 1,
+		},
+		FailedLoginScore: 10,
+		BlockThreshold:   100,
+		HalfLife:         10 * time.Minute,
+		BlockDuration:    15 * time.Minute,
+		MaxBlockDuration: 24 * time.Hour,
+		RefreshInterval:  15 * time.Second,
+	}
+}
+
+// IPDecisionAction IP 检查结果
+type IPDecisionAction string
+
+const (
+	IPActionNone    IPDecisionAction = "none"    // 无规则，放行
+	IPActionAllow   IPDecisionAction = "allow"   // 命中允许规则，放行且不累计分数
+	IPActionDeny    IPDecisionAction = "deny"    // 命中拒绝规则
+	IPActionBlocked IPDecisionAction = "blocked" // 分数超过阈值被临时封禁
+)
+
+// IPDecision IP 检查结果
+type IPDecision struct {
+	Action       IPDecisionAction `json:"action"`
+	Rule         *IPRule          `json:"rule,omitempty"`
+	BlockedUntil time.Time        `json:"blocked_until,omitempty"`
+	Reason       string           `json:"reason,omitempty"`
+}
+
+// Rejected 是否拒绝请求
+func (d IPDecision) Rejected() bool {
+	return d.Action == IPActionDeny || d.Action == IPActionBlocked
+}
+
+// IPReputationInfo IP 的当前信誉
+type IPReputationInfo struct {
+	IP       string     `json:"ip"`
+	Score    float64    `json:"score"`
+	Blocks   int        `json:"blocks"` // 分数记录有效期内的封禁次数
+	Decision IPDecision `json:"decision"`
+}
+
+// ipScore 缓存中的分数记录
+type ipScore struct {
+	Score     float64   `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Blocks    int       `json:"blocks"`
+}
+
+// ipBlock 缓存中的封禁记录
+type ipBlock struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// scoreRetention 分数记录的保留时间，决定封禁时长翻倍的记忆期
+const scoreRetention = 24 * time.Hour
+
+// IPReputation IP 信誉管理：按安全事件累计来源 IP 的分数并随时间衰减，超过阈值时临时封禁；
+// 另有按 CIDR 配置的手动允许、拒绝规则。分数与封禁存放在共享缓存中，各实例共用；
+// 并发事件对分数的读改写不加锁，极端情况下少计个别事件
+type IPReputation struct {
+	cache            cache.CacheService
+	store            IPRuleStore
+	config           *IPReputationConfig
+	metricsCollector *metrics.MetricsCollector
+
+	mu       sync.RWMutex
+	rules    []*IPRule
+	loadedAt time.Time
+}
+
+// NewIPReputation 创建 IP 信誉管理器，config 为 nil 时使用默认配置
+func NewIPReputation(cacheService cache.CacheService, store IPRuleStore, config *IPReputationConfig) *IPReputation {
+	if config == nil {
+		config = DefaultIPReputationConfig()
+	}
+	return &IPReputation{
+		cache:            cacheService,
+		store:            store,
+		config:           config,
+		metricsCollector: metrics.GetGlobalCollector(),
+	}
+}
+
+// OnSecurityEvent 按事件类型为来源 IP 增加分数，达到阈值时封禁。允许名单内或已被封禁的 IP 不计分
+func (r *IPReputation) OnSecurityEvent(ctx context.Context, event SecurityEvent) {
+	points := r.config.EventScores[event.Type]
+	if event.Type == EventLogin && event.Level != LevelInfo {
+		points = r.config.FailedLoginScore
+	}
+	if points <= 0 {
+		return
+	}
+	addr, err := netip.ParseAddr(event.IP)
+	if err != nil {
+		return
+	}
+
+	decision := r.Check(ctx, addr.String())
+	if decision.Action != IPActionNone {
+		return
+	}
+	if err := r.AddScore(ctx, addr.String(), points, string(event.Type)); err != nil {
+		log.Printf("Failed to update reputation of %s: %v", addr, err)
+	}
+}
+
+// AddScore 为 IP 增加分数，衰减后的分数达到阈值时按封禁次数递增时长封禁
+func (r *IPReputation) AddScore(ctx context.Context, ip string, points float64, reason string) error {
+	now := time.Now()
+	score := r.loadScore(ctx, ip, now)
+	score.Score += points
+
+	if score.Score >= r.config.BlockThreshold {
+		duration := r.blockDuration(score.Blocks)
+		block := ipBlock{Until: now.Add(duration), Reason: fmt.Sprintf("score %.0f reached threshold, last event: %s", score.Score, reason)}
+		if err := r.cache.Set(ctx, ipBlockKey(ip), block, duration); err != nil {
+			return fmt.Errorf("failed to block ip: %w", err)
+		}
+		log.Printf("Blocked IP %s for %s: %s", ip, duration, block.Reason)
+		r.metricsCollector.RecordIPReputation("auto_block")
+		score.Blocks++
+		score.Score = 0
+	}
+
+	if err := r.cache.Set(ctx, ipScoreKey(ip), score, scoreRetention); err != nil {
+		return fmt.Errorf("failed to save ip score: %w", err)
+	}
+	return nil
+}
+
+// Check 检查 IP：最具体的手动规则优先，前缀长度相同时拒绝优先；没有规则时检查临时封禁
+func (r *IPReputation) Check(ctx context.Context, ip string) IPDecision {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return IPDecision{Action: IPActionNone}
+	}
+	addr = addr.Unmap()
+
+	if rule := matchIPRule(r.currentRules(ctx), addr); rule != nil {
+		if rule.Action == IPRuleAllow {
+			return IPDecision{Action: IPActionAllow, Rule: rule}
+		}
+		return IPDecision{Action: IPActionDeny, Rule: rule, Reason: rule.Note}
+	}
+
+	var block ipBlock
+	if err := r.cache.Get(ctx, ipBlockKey(addr.String()), &block); err == nil && time.Now().Before(block.Until) {
+		return IPDecision{Action: IPActionBlocked, BlockedUntil: block.Until, Reason: block.Reason}
+	}
+	return IPDecision{Action: IPActionNone}
+}
+
+// Reputation IP 的当前分数与检查结果
+func (r *IPReputation) Reputation(ctx context.Context, ip string) (*IPReputationInfo, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ip address: %q", ip)
+	}
+	addr = addr.Unmap()
+
+	score := r.loadScore(ctx, addr.String(), time.Now())
+	return &IPReputationInfo{
+		IP:       addr.String(),
+		Score:    math.Round(score.Score*100) / 100,
+		Blocks:   score.Blocks,
+		Decision: r.Check(ctx, addr.String()),
+	}, nil
+}
+
+// Unblock 解除临时封禁并清零分数，封禁次数保留
+func (r *IPReputation) Unblock(ctx context.Context, ip string) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid ip address: %q", ip)
+	}
+	addr = addr.Unmap()
+
+	if err := r.cache.Delete(ctx, ipBlockKey(addr.String())); err != nil {
+		return fmt.Errorf("failed to unblock ip: %w", err)
+	}
+	score := r.loadScore(ctx, addr.String(), time.Now())
+	score.Score = 0
+	if err := r.cache.Set(ctx, ipScoreKey(addr.String()), score, scoreRetention); err != nil {
+		return fmt.Errorf("failed to reset ip score: %w", err)
+	}
+	return nil
+}
+
+// ListRules 未过期的手动规则
+func (r *IPReputation) ListRules(ctx context.Context) ([]*IPRule, error) {
+	return r.store.ListIPRules(ctx)
+}
+
+// AddRule 添加手动规则，本实例立即生效
+func (r *IPReputation) AddRule(ctx context.Context, rule *IPRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := r.store.CreateIPRule(ctx, rule); err != nil {
+		return err
+	}
+	r.Invalidate()
+	return nil
+}
+
+// DeleteRule 删除手动规则，本实例立即生效
+func (r *IPReputation) DeleteRule(ctx context.Context, id int64) error {
+	if err := r.store.DeleteIPRule(ctx, id); err != nil {
+		return err
+	}
+	r.Invalidate()
+	return nil
+}
+
+// Invalidate 下次检查时重新加载手动规则
+func (r *IPReputation) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadedAt = time.Time{}
+}
+
+// currentRules 手动规则，超过刷新间隔时重新加载；加载失败时沿用旧规则并在一个间隔后重试
+func (r *IPReputation) currentRules(ctx context.Context) []*IPRule {
+	r.mu.RLock()
+	rules, fresh := r.rules, time.Since(r.loadedAt) < r.config.RefreshInterval
+	r.mu.RUnlock()
+	if fresh {
+		return rules
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.loadedAt) < r.config.RefreshInterval {
+		return r.rules
+	}
+	r.loadedAt = time.Now()
+
+	loaded, err := r.store.ListIPRules(ctx)
+	if err != nil {
+		log.Printf("Failed to load ip rules: %v", err)
+		return r.rules
+	}
+	valid := make([]*IPRule, 0, len(loaded))
+	for _, rule := range loaded {
+		if err := rule.Validate(); err != nil {
+			log.Printf("Skipping ip rule %d: %v", rule.ID, err)
+			continue
+		}
+		valid = append(valid, rule)
+	}
+	r.rules = valid
+	return valid
+}
+
+// loadScore 读取分数并按半衰期衰减到 now
+func (r *IPReputation) loadScore(ctx context.Context, ip string, now time.Time) ipScore {
+	var score ipScore
+	if err := r.cache.Get(ctx, ipScoreKey(ip), &score); err != nil {
+		return ipScore{UpdatedAt: now}
+	}
+	if elapsed := now.Sub(score.UpdatedAt); elapsed > 0 && r.config.HalfLife > 0 {
+		score.Score *= math.Pow(0.5, float64(elapsed)/float64(r.config.HalfLife))
+	}
+	score.UpdatedAt = now
+	return score
+}
+
+// blockDuration 第 n+1 次封禁的时长
+func (r *IPReputation) blockDuration(previousBlocks int) time.Duration {
+	duration := r.config.BlockDuration
+	for i := 0; i < previousBlocks && duration < r.config.MaxBlockDuration; i++ {
+		duration *= 2
+	}
+	return min(duration, r.config.MaxBlockDuration)
+}
+
+func ipScoreKey(ip string) string {
+	return "ip_reputation:score:" + ip
+}
+
+func ipBlockKey(ip string) string {
+	return "ip_reputation:block:" + ip
+}
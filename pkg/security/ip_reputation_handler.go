@@ -0,0 +1,136 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// blockPageTemplate 浏览器请求被拒绝时的提示页
+const blockPageTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>403</title></head>
+<body><h1>403</h1><p>%s</p><p>%s</p></body></html>`
+
+// Middleware 拒绝命中拒绝规则或被临时封禁的 IP。浏览器请求返回提示页，其他请求返回统一错误响应；
+// 临时封禁带 Retry-After 响应头
+func (r *IPReputation) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision := r.Check(c.Request.Context(), c.ClientIP())
+		if !decision.Rejected() {
+			c.Next()
+			return
+		}
+
+		r.metricsCollector.RecordIPReputation("reject_" + string(decision.Action))
+		if decision.Action == IPActionBlocked {
+			retryAfter := int(math.Ceil(time.Until(decision.BlockedUntil).Seconds()))
+			c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+		}
+
+		if strings.Contains(c.GetHeader("Accept"), "text/html") {
+			message := apperrors.CodeIPBlocked.Message(apperrors.Language(c))
+			c.Data(http.StatusForbidden, "text/html; charset=utf-8",
+				[]byte(fmt.Sprintf(blockPageTemplate, html.EscapeString(message), html.EscapeString(c.GetString("request_id")))))
+			c.Abort()
+			return
+		}
+		apperrors.Render(c, apperrors.New(apperrors.CodeIPBlocked, ""))
+		c.Abort()
+	}
+}
+
+// IPReputationHandler IP 信誉与访问规则管理接口
+type IPReputationHandler struct {
+	reputation *IPReputation
+}
+
+// NewIPReputationHandler 创建 IP 信誉管理接口
+func NewIPReputationHandler(reputation *IPReputation) *IPReputationHandler {
+	return &IPReputationHandler{reputation: reputation}
+}
+
+// RegisterAdminRoutes 注册 IP 信誉路由，调用方需挂载管理员权限校验
+func (h *IPReputationHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/security/ip/:ip", h.GetReputation)
+	group.DELETE("/security/ip/:ip/block", h.Unblock)
+	group.GET("/security/ip-rules", h.ListRules)
+	group.POST("/security/ip-rules", h.CreateRule)
+	group.DELETE("/security/ip-rules/:id", h.DeleteRule)
+}
+
+// GetReputation IP 的当前分数、封禁次数与检查结果
+func (h *IPReputationHandler) GetReputation(c *gin.Context) {
+	info, err := h.reputation.Reputation(c.Request.Context(), c.Param("ip"))
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// Unblock 解除临时封禁并清零分数，手动拒绝规则不受影响
+func (h *IPReputationHandler) Unblock(c *gin.Context) {
+	if err := h.reputation.Unblock(c.Request.Context(), c.Param("ip")); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListRules 未过期的手动规则
+func (h *IPReputationHandler) ListRules(c *gin.Context) {
+	rules, err := h.reputation.ListRules(c.Request.Context())
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+	})
+}
+
+// CreateRule 添加允许或拒绝规则
+func (h *IPReputationHandler) CreateRule(c *gin.Context) {
+	var rule IPRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	rule.CreatedBy = c.GetString("userID")
+
+	if err := h.reputation.AddRule(c.Request.Context(), &rule); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DeleteRule 删除手动规则
+func (h *IPReputationHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if err := h.reputation.DeleteRule(c.Request.Context(), id); err != nil {
+		if errors.Is(err, ErrIPRuleNotFound) {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, ""))
+			return
+		}
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeDatabase, ""))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package security
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIPRuleStore 内存 IP 规则存储
+type memoryIPRuleStore struct {
+	mu     sync.Mutex
+	rules  []*IPRule
+	nextID int64
+}
+
+func (s *memoryIPRuleStore) ListIPRules(ctx context.Context) ([]*IPRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := make([]*IPRule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	return rules, nil
+}
+
+func (s *memoryIPRuleStore) CreateIPRule(ctx context.Context, rule *IPRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	rule.ID = s.nextID
+	rule.CreatedAt = time.Now()
+	copied := *rule
+	s.rules = append(s.rules, &copied)
+	return nil
+}
+
+func (s *memoryIPRuleStore) DeleteIPRule(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrIPRuleNotFound
+}
+
+func newTestIPReputation() *IPReputation {
+	config := DefaultIPReputationConfig()
+	config.RefreshInterval = time.Hour
+	return NewIPReputation(cache.NewMemoryCache(), &memoryIPRuleStore{}, config)
+}
+
+// TestIPReputation_BlocksAfterThreshold 事件累计到阈值后封禁，中间件拒绝该 IP 的请求
+func TestIPReputation_BlocksAfterThreshold(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	reputation := newTestIPReputation()
+
+	// 两次注入各 40 分，尚未达到 100
+	for i := 0; i < 2; i++ {
+		reputation.OnSecurityEvent(ctx, SecurityEvent{Type: EventSQLInjection, IP: "203.0.113.7"})
+	}
+	assert.Equal(t, IPActionNone, reputation.Check(ctx, "203.0.113.7").Action)
+
+	// 信息级别的登录事件不计分，失败的登录计分
+	reputation.OnSecurityEvent(ctx, SecurityEvent{Type: EventLogin, Level: LevelInfo, IP: "203.0.113.7"})
+	reputation.OnSecurityEvent(ctx, SecurityEvent{Type: EventLogin, Level: LevelWarning, IP: "203.0.113.7"})
+	reputation.OnSecurityEvent(ctx, SecurityEvent{Type: EventLogin, Level: LevelWarning, IP: "203.0.113.7"})
+	assert.Equal(t, IPActionNone, reputation.Check(ctx, "203.0.113.7").Action)
+	reputation.OnSecurityEvent(ctx, SecurityEvent{Type: EventLogin, Level: LevelWarning, IP: "203.0.113.7"})
+
+	decision := reputation.Check(ctx, "203.0.113.7")
+	require.Equal(t, IPActionBlocked, decision.Action)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), decision.BlockedUntil, 5*time.Second)
+
+	info, err := reputation.Reputation(ctx, "203.0.113.7")
+	require.NoError(t, err)
+	assert.Equal(t, 1, info.Blocks)
+	assert.Zero(t, info.Score)
+
+	router := gin.New()
+	router.Use(reputation.Middleware())
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":50013`)
+
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+
+	// 其他 IP 不受影响
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.RemoteAddr = "203.0.113.8:1234"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 解封后放行，封禁次数保留，下次封禁时长翻倍
+	require.NoError(t, reputation.Unblock(ctx, "203.0.113.7"))
+	assert.Equal(t, IPActionNone, reputation.Check(ctx, "203.0.113.7").Action)
+	require.NoError(t, reputation.AddScore(ctx, "203.0.113.7", 100, "test"))
+	decision = reputation.Check(ctx, "203.0.113.7")
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), decision.BlockedUntil, 5*time.Second)
+}
+
+// TestIPReputation_ScoreDecays 分数按半衰期衰减
+func TestIPReputation_ScoreDecays(t *testing.T) {
+	ctx := context.Background()
+	reputation := newTestIPReputation()
+
+	require.NoError(t, reputation.cache.Set(ctx, ipScoreKey("198.51.100.1"), ipScore{
+		Score:     80,
+		UpdatedAt: time.Now().Add(-20 * time.Minute),
+	}, time.Hour))
+
+	info, err := reputation.Reputation(ctx, "198.51.100.1")
+	require.NoError(t, err)
+	assert.InDelta(t, 20, info.Score, 0.1)
+
+	// 衰减后再累计不会触发封禁
+	require.NoError(t, reputation.AddScore(ctx, "198.51.100.1", 40, "test"))
+	assert.Equal(t, IPActionNone, reputation.Check(ctx, "198.51.100.1").Action)
+}
+
+// TestIPReputation_Rules 最具体的规则优先，允许名单内的 IP 不计分
+func TestIPReputation_Rules(t *testing.T) {
+	ctx := context.Background()
+	reputation := newTestIPReputation()
+
+	require.NoError(t, reputation.AddRule(ctx, &IPRule{CIDR: "10.0.0.0/8", Action: IPRuleDeny, Note: "internal scanners"}))
+	require.NoError(t, reputation.AddRule(ctx, &IPRule{CIDR: "10.1.2.0/24", Action: IPRuleAllow}))
+	require.NoError(t, reputation.AddRule(ctx, &IPRule{CIDR: "2001:db8::1", Action: IPRuleDeny}))
+
+	assert.Equal(t, IPActionDeny, reputation.Check(ctx, "10.9.9.9").Action)
+	assert.Equal(t, IPActionAllow, reputation.Check(ctx, "10.1.2.3").Action)
+	assert.Equal(t, IPActionAllow, reputation.Check(ctx, "::ffff:10.1.2.3").Action)
+	assert.Equal(t, IPActionDeny, reputation.Check(ctx, "2001:db8::1").Action)
+	assert.Equal(t, IPActionNone, reputation.Check(ctx, "2001:db8::2").Action)
+
+	for i := 0; i < 5; i++ {
+		reputation.OnSecurityEvent(ctx, SecurityEvent{Type: EventXSS, IP: "10.1.2.3"})
+	}
+	info, err := reputation.Reputation(ctx, "10.1.2.3")
+	require.NoError(t, err)
+	assert.Zero(t, info.Score)
+	assert.Equal(t, IPActionAllow, info.Decision.Action)
+
+	// 同样具体的规则拒绝优先
+	require.NoError(t, reputation.AddRule(ctx, &IPRule{CIDR: "10.1.2.0/24", Action: IPRuleDeny}))
+	assert.Equal(t, IPActionDeny, reputation.Check(ctx, "10.1.2.3").Action)
+
+	// 过期的规则不生效
+	expired := time.Now().Add(-time.Minute)
+	require.NoError(t, reputation.AddRule(ctx, &IPRule{CIDR: "192.0.2.0/24", Action: IPRuleDeny, ExpiresAt: &expired}))
+	assert.Equal(t, IPActionNone, reputation.Check(ctx, "192.0.2.1").Action)
+}
+
+// TestIPRule_Validate 规范化 CIDR 并拒绝非法规则
+func TestIPRule_Validate(t *testing.T) {
+	rule := &IPRule{CIDR: "10.1.2.3/16", Action: IPRuleDeny}
+	require.NoError(t, rule.Validate())
+	assert.Equal(t, "10.1.0.0/16", rule.CIDR)
+
+	rule = &IPRule{CIDR: "203.0.113.7", Action: IPRuleAllow}
+	require.NoError(t, rule.Validate())
+	assert.Equal(t, "203.0.113.7/32", rule.CIDR)
+
+	assert.Error(t, (&IPRule{CIDR: "not-an-ip", Action: IPRuleDeny}).Validate())
+	assert.Error(t, (&IPRule{CIDR: "10.0.0.0/8", Action: "block"}).Validate())
+}
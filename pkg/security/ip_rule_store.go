@@ -0,0 +1,147 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// ErrIPRuleNotFound IP 规则不存在
+var ErrIPRuleNotFound = errors.New("ip rule not found")
+
+// IPRuleAction 手动规则的动作
+type IPRuleAction string
+
+const (
+	IPRuleAllow IPRuleAction = "allow" // 允许名单：放行且不累计分数、不自动封禁
+	IPRuleDeny  IPRuleAction = "deny"  // 拒绝名单
+)
+
+// IPRule 按 IP 或 CIDR 配置的手动规则
+type IPRule struct {
+	ID        int64        `json:"id" db:"id"`
+	CIDR      string       `json:"cidr" db:"cidr" binding:"required"` // 单个 IP 视为 /32 或 /128
+	Action    IPRuleAction `json:"action" db:"action" binding:"required,oneof=allow deny"`
+	Note      string       `json:"note" db:"note"`
+	CreatedBy string       `json:"created_by" db:"created_by"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty" db:"expires_at"` // 为空时长期有效
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+
+	prefix netip.Prefix
+}
+
+// Validate 校验动作并规范化 CIDR（去掉主机位）
+func (r *IPRule) Validate() error {
+	if r.Action != IPRuleAllow && r.Action != IPRuleDeny {
+		return fmt.Errorf("invalid ip rule action: %q", r.Action)
+	}
+	prefix, err := parseIPPrefix(r.CIDR)
+	if err != nil {
+		return err
+	}
+	r.prefix = prefix
+	r.CIDR = prefix.String()
+	return nil
+}
+
+// Expired 规则是否已过期
+func (r *IPRule) Expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// parseIPPrefix 解析 CIDR 或单个 IP
+func parseIPPrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid ip address: %q", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid cidr: %q", s)
+	}
+	return prefix.Masked(), nil
+}
+
+// matchIPRule 选出包含 addr 的最具体的未过期规则，前缀长度相同时拒绝优先
+func matchIPRule(rules []*IPRule, addr netip.Addr) *IPRule {
+	now := time.Now()
+	var best *IPRule
+	for _, rule := range rules {
+		if rule.Expired(now) || !rule.prefix.Contains(addr) {
+			continue
+		}
+		if best == nil || rule.prefix.Bits() > best.prefix.Bits() ||
+			(rule.prefix.Bits() == best.prefix.Bits() && rule.Action == IPRuleDeny) {
+			best = rule
+		}
+	}
+	return best
+}
+
+// IPRuleStore IP 规则的持久化存储
+type IPRuleStore interface {
+	// ListIPRules 未过期的规则
+	ListIPRules(ctx context.Context) ([]*IPRule, error)
+	// CreateIPRule 写入规则并回填 ID 与创建时间
+	CreateIPRule(ctx context.Context, rule *IPRule) error
+	// DeleteIPRule 删除规则，不存在时返回 ErrIPRuleNotFound
+	DeleteIPRule(ctx context.Context, id int64) error
+}
+
+// SQLIPRuleStore 基于 ip_access_rules 表的 IP 规则存储
+type SQLIPRuleStore struct {
+	db *database.DB
+}
+
+// NewSQLIPRuleStore 创建 IP 规则存储
+func NewSQLIPRuleStore(db *database.DB) *SQLIPRuleStore {
+	return &SQLIPRuleStore{db: db}
+}
+
+// ListIPRules 未过期的规则，按创建时间排序
+func (s *SQLIPRuleStore) ListIPRules(ctx context.Context) ([]*IPRule, error) {
+	var rules []*IPRule
+	err := s.db.SelectContext(ctx, &rules, `
+		SELECT id, cidr, action, note, created_by, expires_at, created_at
+		FROM ip_access_rules
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ip rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateIPRule 写入规则
+func (s *SQLIPRuleStore) CreateIPRule(ctx context.Context, rule *IPRule) error {
+	err := s.db.GetContext(ctx, rule, `
+		INSERT INTO ip_access_rules (cidr, action, note, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, cidr, action, note, created_by, expires_at, created_at`,
+		rule.CIDR, string(rule.Action), rule.Note, rule.CreatedBy, rule.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create ip rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteIPRule 删除规则
+func (s *SQLIPRuleStore) DeleteIPRule(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM ip_access_rules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete ip rule: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrIPRuleNotFound
+	}
+	return nil
+}
@@ -152,7 +152,7 @@ func (js *JWTSecurity) generateRefreshToken(userID string) (string, error) {
 }
 
 // ValidateToken 验证令牌
-func (js *JWTSecurity) ValidateToken(tokenString string) (*Claims, error) {
+func (js *JWTSecurity) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -170,7 +170,7 @@ func (js *JWTSecurity) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	// 检查令牌是否在黑名单中
-	if js.isTokenBlacklisted(claims.GetJWTID()) {
+	if js.isTokenBlacklisted(ctx, claims.GetJWTID()) {
 		return nil, fmt.Errorf("token is blacklisted")
 	}
 
@@ -189,9 +189,9 @@ func (js *JWTSecurity) ValidateToken(tokenString string) (*Claims, error) {
 }
 
 // RefreshToken 刷新令牌
-func (js *JWTSecurity) RefreshToken(refreshTokenString string) (string, string, error) {
+func (js *JWTSecurity) RefreshToken(ctx context.Context, refreshTokenString string) (string, string, error) {
 	// 验证刷新令牌
-	claims, err := js.ValidateToken(refreshTokenString)
+	claims, err := js.ValidateToken(ctx, refreshTokenString)
 	if err != nil {
 		return "", "", fmt.Errorf("invalid refresh token: %w", err)
 	}
@@ -208,7 +208,7 @@ func (js *JWTSecurity) RefreshToken(refreshTokenString string) (string, string,
 	js.mu.Unlock()
 
 	// 将旧的刷新令牌加入黑名单
-	js.addToBlacklist(claims.GetJWTID(), claims.ExpiresAt.Time)
+	js.addToBlacklist(ctx, claims.GetJWTID(), claims.ExpiresAt.Time)
 
 	// 生成新的令牌对
 	newAccessToken, newRefreshToken, err := js.GenerateTokenPair(claims.UserID, claims.Role, claims.Permissions)
@@ -220,14 +220,14 @@ func (js *JWTSecurity) RefreshToken(refreshTokenString string) (string, string,
 }
 
 // RevokeToken 撤销令牌
-func (js *JWTSecurity) RevokeToken(tokenString string) error {
-	claims, err := js.ValidateToken(tokenString)
+func (js *JWTSecurity) RevokeToken(ctx context.Context, tokenString string) error {
+	claims, err := js.ValidateToken(ctx, tokenString)
 	if err != nil {
 		return fmt.Errorf("invalid token: %w", err)
 	}
 
 	// 将令牌加入黑名单
-	js.addToBlacklist(claims.GetJWTID(), claims.ExpiresAt.Time)
+	js.addToBlacklist(ctx, claims.GetJWTID(), claims.ExpiresAt.Time)
 
 	// 如果是刷新令牌，标记为已撤销
 	if claims.Type == "refresh" {
@@ -242,7 +242,7 @@ func (js *JWTSecurity) RevokeToken(tokenString string) error {
 }
 
 // RevokeUserTokens 撤销用户的所有令牌
-func (js *JWTSecurity) RevokeUserTokens(userID string) error {
+func (js *JWTSecurity) RevokeUserTokens(ctx context.Context, userID string) error {
 	js.mu.Lock()
 	defer js.mu.Unlock()
 
@@ -250,7 +250,7 @@ func (js *JWTSecurity) RevokeUserTokens(userID string) error {
 	for tokenID, refreshInfo := range js.refreshTokens {
 		if refreshInfo.UserID == userID {
 			refreshInfo.Revoked = true
-			js.addToBlacklist(tokenID, refreshInfo.ExpiresAt)
+			js.addToBlacklist(ctx, tokenID, refreshInfo.ExpiresAt)
 		}
 	}
 
@@ -258,7 +258,7 @@ func (js *JWTSecurity) RevokeUserTokens(userID string) error {
 }
 
 // isTokenBlacklisted 检查令牌是否在黑名单中
-func (js *JWTSecurity) isTokenBlacklisted(tokenID string) bool {
+func (js *JWTSecurity) isTokenBlacklisted(ctx context.Context, tokenID string) bool {
 	js.mu.RLock()
 	defer js.mu.RUnlock()
 
@@ -270,7 +270,7 @@ func (js *JWTSecurity) isTokenBlacklisted(tokenID string) bool {
 	// 检查缓存黑名单
 	cacheKey := fmt.Sprintf("token_blacklist:%s", tokenID)
 	var blacklisted bool
-	if err := js.cache.Get(context.Background(), cacheKey, &blacklisted); err == nil && blacklisted {
+	if err := js.cache.Get(ctx, cacheKey, &blacklisted); err == nil && blacklisted {
 		return true
 	}
 
@@ -278,7 +278,7 @@ func (js *JWTSecurity) isTokenBlacklisted(tokenID string) bool {
 }
 
 // addToBlacklist 将令牌加入黑名单
-func (js *JWTSecurity) addToBlacklist(tokenID string, expiresAt time.Time) {
+func (js *JWTSecurity) addToBlacklist(ctx context.Context, tokenID string, expiresAt time.Time) {
 	js.mu.Lock()
 	js.tokenBlacklist[tokenID] = true
 	js.mu.Unlock()
@@ -287,7 +287,7 @@ func (js *JWTSecurity) addToBlacklist(tokenID string, expiresAt time.Time) {
 	cacheKey := fmt.Sprintf("token_blacklist:%s", tokenID)
 	ttl := time.Until(expiresAt)
 	if ttl > 0 {
-		js.cache.Set(context.Background(), cacheKey, true, ttl)
+		js.cache.Set(ctx, cacheKey, true, ttl)
 	}
 }
 
@@ -308,8 +308,8 @@ func (js *JWTSecurity) CleanupExpiredTokens() {
 }
 
 // GetTokenInfo 获取令牌信息
-func (js *JWTSecurity) GetTokenInfo(tokenString string) (*TokenInfo, error) {
-	claims, err := js.ValidateToken(tokenString)
+func (js *JWTSecurity) GetTokenInfo(ctx context.Context, tokenString string) (*TokenInfo, error) {
+	claims, err := js.ValidateToken(ctx, tokenString)
 	if err != nil {
 		return nil, err
 	}
@@ -376,13 +376,13 @@ func (tm *TokenMiddleware) ExtractToken(authHeader string) (string, error) {
 }
 
 // ValidateRequest 验证请求令牌
-func (tm *TokenMiddleware) ValidateRequest(authHeader string) (*Claims, error) {
+func (tm *TokenMiddleware) ValidateRequest(ctx context.Context, authHeader string) (*Claims, error) {
 	token, err := tm.ExtractToken(authHeader)
 	if err != nil {
 		return nil, err
 	}
 
-	return tm.jwtSecurity.ValidateToken(token)
+	return tm.jwtSecurity.ValidateToken(ctx, token)
 }
 
 // ShouldSkipPath 检查是否跳过路径
@@ -0,0 +1,58 @@
+package jwtkeys
+
+import (
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSPath 公钥集合的发布路径
+const JWKSPath = "/.well-known/jwks.json"
+
+// Handler 公钥发布与签名密钥管理接口
+type Handler struct {
+	manager *Manager
+}
+
+// NewHandler 创建签名密钥接口
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// RegisterRoutes 注册公开的 /.well-known/jwks.json，其他服务据此验证非对称签名的令牌
+func (h *Handler) RegisterRoutes(router gin.IRoutes) {
+	router.GET(JWKSPath, h.JWKS)
+}
+
+// RegisterAdminRoutes 注册签名密钥管理路由，调用方需挂载管理员权限校验
+func (h *Handler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/jwt/keys", h.ListKeys)
+	group.POST("/jwt/keys/rotate", h.RotateKey)
+}
+
+// JWKS 当前可用于验证的公钥，客户端可缓存 5 分钟；轮换后新公钥在旧密钥过期前已发布
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.manager.JWKS())
+}
+
+// keysResponse 密钥列表，不含私钥材料
+type keysResponse struct {
+	Current string `json:"current"`
+	Keys    []*Key `json:"keys"`
+}
+
+// ListKeys 可用于验证的密钥及当前签名密钥
+func (h *Handler) ListKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, keysResponse{Current: h.manager.CurrentKeyID(), Keys: h.manager.Keys()})
+}
+
+// RotateKey 立即轮换，旧密钥在宽限期内仍可验证
+func (h *Handler) RotateKey(c *gin.Context) {
+	if err := h.manager.Rotate(c.Request.Context()); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	h.ListKeys(c)
+}
@@ -0,0 +1,209 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithm 签名算法
+type Algorithm string
+
+const (
+	HS256 Algorithm = "HS256" // 对称密钥，只有持有密钥的服务能验证
+	RS256 Algorithm = "RS256" // RSA 2048，公钥通过 JWKS 发布
+	EdDSA Algorithm = "EdDSA" // Ed25519，公钥通过 JWKS 发布
+)
+
+// ParseAlgorithm 解析算法名
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch alg := Algorithm(name); alg {
+	case HS256, RS256, EdDSA:
+		return alg, nil
+	}
+	return "", fmt.Errorf("unsupported signing algorithm: %q", name)
+}
+
+// Asymmetric 是否为非对称算法
+func (a Algorithm) Asymmetric() bool {
+	return a == RS256 || a == EdDSA
+}
+
+// signingMethod 对应的 jwt 签名方法
+func (a Algorithm) signingMethod() jwt.SigningMethod {
+	switch a {
+	case RS256:
+		return jwt.SigningMethodRS256
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodHS256
+}
+
+// Key 签名密钥
+type Key struct {
+	ID        string     `json:"kid"`
+	Algorithm Algorithm  `json:"alg"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // 被轮换后设置，此后不再用于验证；为空时仍可签名
+
+	secret  []byte
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// GenerateKey 生成新密钥，kid 为随机值
+func GenerateKey(alg Algorithm) (*Key, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	key := &Key{ID: hex.EncodeToString(id), Algorithm: alg, CreatedAt: time.Now()}
+
+	switch alg {
+	case HS256:
+		key.secret = make([]byte, 32)
+		if _, err := rand.Read(key.secret); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+	case RS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate rsa key: %w", err)
+		}
+		key.private, key.public = private, &private.PublicKey
+	case EdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ed25519 key: %w", err)
+		}
+		key.private, key.public = private, public
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %q", alg)
+	}
+	return key, nil
+}
+
+// usable 在 now 是否仍可用于验证
+func (k *Key) usable(now time.Time) bool {
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// signingKey jwt 签名所需的密钥
+func (k *Key) signingKey() interface{} {
+	if k.Algorithm == HS256 {
+		return k.secret
+	}
+	return k.private
+}
+
+// verificationKey jwt 验证所需的密钥
+func (k *Key) verificationKey() interface{} {
+	if k.Algorithm == HS256 {
+		return k.secret
+	}
+	return k.public
+}
+
+// marshalPrivate 私钥材料的存储形式：HS256 为 base64 密钥，非对称算法为 PKCS#8 PEM
+func (k *Key) marshalPrivate() (string, error) {
+	if k.Algorithm == HS256 {
+		return base64.StdEncoding.EncodeToString(k.secret), nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// unmarshalPrivate 从存储形式恢复私钥材料
+func (k *Key) unmarshalPrivate(data string) error {
+	if k.Algorithm == HS256 {
+		secret, err := base64.StdEncoding.DecodeString(data)
+		if err != nil || len(secret) == 0 {
+			return fmt.Errorf("invalid secret for key %s", k.ID)
+		}
+		k.secret = secret
+		return nil
+	}
+
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return fmt.Errorf("invalid private key pem for key %s", k.ID)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse private key %s: %w", k.ID, err)
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.Algorithm != RS256 {
+			return fmt.Errorf("key %s: rsa key for algorithm %s", k.ID, k.Algorithm)
+		}
+		k.private, k.public = private, &private.PublicKey
+	case ed25519.PrivateKey:
+		if k.Algorithm != EdDSA {
+			return fmt.Errorf("key %s: ed25519 key for algorithm %s", k.ID, k.Algorithm)
+		}
+		k.private, k.public = private, private.Public()
+	default:
+		return fmt.Errorf("key %s: unsupported private key type %T", k.ID, parsed)
+	}
+	return nil
+}
+
+// JWK JSON Web Key（RFC 7517）中的公钥
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP（Ed25519）
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS JWK 集合
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// jwk 公钥的 JWK 形式，对称密钥返回 false
+func (k *Key) jwk() (JWK, bool) {
+	encode := base64.RawURLEncoding.EncodeToString
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     k.ID,
+			Algorithm: string(k.Algorithm),
+			Use:       "sig",
+			N:         encode(public.N.Bytes()),
+			E:         encode(big.NewInt(int64(public.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     k.ID,
+			Algorithm: string(k.Algorithm),
+			Use:       "sig",
+			Curve:     "Ed25519",
+			X:         encode(public),
+		}, true
+	}
+	return JWK{}, false
+}
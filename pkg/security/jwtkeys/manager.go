@@ -0,0 +1,366 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrUnknownKey 令牌的 kid 不对应任何可用的密钥
+	ErrUnknownKey = errors.New("unknown signing key")
+	// ErrNoSigningKey 没有可用于签名的密钥
+	ErrNoSigningKey = errors.New("no signing key available")
+)
+
+// encryptedPrefix 加密存储的私钥前缀
+const encryptedPrefix = "enc:"
+
+// unknownKeyReloadInterval 遇到未知 kid 时重新加载密钥的最小间隔，其他实例刚轮换的密钥可立即验证
+const unknownKeyReloadInterval = 5 * time.Second
+
+// startupRotationWindow 启动时其他实例在此时间内已生成同算法的密钥则直接使用，避免同时启动的实例重复轮换
+const startupRotationWindow = time.Minute
+
+// Config 签名密钥配置
+type Config struct {
+	Algorithm Algorithm `json:"algorithm"`
+	// RotationInterval 当前密钥使用多久后轮换，0 表示只手动轮换
+	RotationInterval time.Duration `json:"rotation_interval"`
+	// GracePeriod 轮换后旧密钥继续用于验证的时长，应不短于令牌有效期
+	GracePeriod time.Duration `json:"grace_period"`
+	// TokenLifetime 令牌有效期，GracePeriod 短于它时轮换前签发的令牌会在过期前失效，NewManager 拒绝该配置
+	TokenLifetime time.Duration `json:"token_lifetime"`
+	// RefreshInterval 重新加载密钥、检查是否需要轮换的间隔
+	RefreshInterval time.Duration `json:"refresh_interval"`
+	// EncryptionKey 非空时以 AES-GCM 加密存储私钥
+	EncryptionKey string `json:"-"`
+	// LegacySecret 验证不带 kid 的 HS256 令牌，用于启用密钥管理之前签发的令牌
+	LegacySecret string `json:"-"`
+}
+
+// DefaultConfig 默认配置：HS256，每 7 天轮换，旧密钥保留 31 天（令牌有效期为 30 天）
+func DefaultConfig() *Config {
+	return &Config{
+		Algorithm:        HS256,
+		RotationInterval: 7 * 24 * time.Hour,
+		GracePeriod:      31 * 24 * time.Hour,
+		TokenLifetime:    30 * 24 * time.Hour,
+		RefreshInterval:  time.Minute,
+	}
+}
+
+// Manager 签名密钥管理：用最新的密钥签名并在头部写入 kid，按 kid 选择密钥验证；
+// 定期轮换，轮换后旧密钥在宽限期内仍可验证
+type Manager struct {
+	config *Config
+	store  Store
+	aead   cipher.AEAD
+
+	mu           sync.RWMutex
+	keys         map[string]*Key
+	current      *Key
+	lastReloadAt time.Time
+}
+
+// NewManager 创建签名密钥管理器，config 为 nil 时使用默认配置
+func NewManager(store Store, config *Config) (*Manager, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if _, err := ParseAlgorithm(string(config.Algorithm)); err != nil {
+		return nil, err
+	}
+	if config.GracePeriod < config.TokenLifetime {
+		return nil, fmt.Errorf("grace period %s is shorter than token lifetime %s", config.GracePeriod, config.TokenLifetime)
+	}
+
+	m := &Manager{
+		config: config,
+		store:  store,
+		keys:   make(map[string]*Key),
+	}
+	if config.EncryptionKey != "" {
+		key := sha256.Sum256([]byte(config.EncryptionKey))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		m.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Start 加载密钥，没有可用的当前密钥或当前密钥的算法与配置不同时立即轮换。须在签发令牌之前调用
+func (m *Manager) Start(ctx context.Context) error {
+	if err := m.reload(ctx); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	current := m.current
+	m.mu.RUnlock()
+
+	if current == nil || current.Algorithm != m.config.Algorithm {
+		return m.rotate(ctx, startupRotationWindow)
+	}
+	return nil
+}
+
+// Run 每隔 RefreshInterval 重新加载密钥，当前密钥超过轮换间隔时轮换，直到 ctx 取消
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.reload(ctx); err != nil {
+				log.Printf("Failed to reload signing keys: %v", err)
+				continue
+			}
+			if m.config.RotationInterval <= 0 {
+				continue
+			}
+			m.mu.RLock()
+			due := m.current == nil || time.Since(m.current.CreatedAt) >= m.config.RotationInterval
+			m.mu.RUnlock()
+			if due {
+				if err := m.rotate(ctx, m.config.RotationInterval); err != nil {
+					log.Printf("Failed to rotate signing key: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// Rotate 立即轮换：生成新的当前密钥，旧密钥在宽限期内仍可验证
+func (m *Manager) Rotate(ctx context.Context) error {
+	return m.rotate(ctx, 0)
+}
+
+// rotate 生成新密钥并写入存储；其他实例已在 minAge 内轮换时只重新加载
+func (m *Manager) rotate(ctx context.Context, minAge time.Duration) error {
+	key, err := GenerateKey(m.config.Algorithm)
+	if err != nil {
+		return err
+	}
+	private, err := key.marshalPrivate()
+	if err != nil {
+		return err
+	}
+	sealed, err := m.seal(private)
+	if err != nil {
+		return err
+	}
+
+	rotated, err := m.store.Rotate(ctx, StoredKey{
+		ID:         key.ID,
+		Algorithm:  string(key.Algorithm),
+		PrivateKey: sealed,
+		CreatedAt:  key.CreatedAt,
+	}, minAge, time.Now().Add(m.config.GracePeriod))
+	if err != nil {
+		return err
+	}
+	if rotated {
+		log.Printf("Rotated signing key, new kid %s (%s)", key.ID, key.Algorithm)
+	}
+	return m.reload(ctx)
+}
+
+// reload 从存储加载可用的密钥，无法解析的密钥跳过
+func (m *Manager) reload(ctx context.Context) error {
+	stored, err := m.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*Key, len(stored))
+	var current *Key
+	for _, s := range stored {
+		key, err := m.decode(s)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", s.ID, err)
+			continue
+		}
+		keys[key.ID] = key
+		if key.ExpiresAt == nil && (current == nil || key.CreatedAt.After(current.CreatedAt)) {
+			current = key
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.current = current
+	m.lastReloadAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+// decode 解密并解析存储的密钥
+func (m *Manager) decode(s StoredKey) (*Key, error) {
+	alg, err := ParseAlgorithm(s.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	private, err := m.open(s.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{ID: s.ID, Algorithm: alg, CreatedAt: s.CreatedAt, ExpiresAt: s.ExpiresAt}
+	if err := key.unmarshalPrivate(private); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Sign 用当前密钥签名，头部带 kid
+func (m *Manager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	key := m.current
+	m.mu.RUnlock()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(key.Algorithm.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.signingKey())
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// Keyfunc 供 jwt.Parse 使用：按 kid 选择密钥，令牌声明的算法须与密钥一致；
+// 不带 kid 的令牌按 HS256 用 LegacySecret 验证
+func (m *Manager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || m.config.LegacySecret == "" {
+			return nil, ErrUnknownKey
+		}
+		return []byte(m.config.LegacySecret), nil
+	}
+
+	key := m.lookup(kid)
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Algorithm.signingMethod().Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.verificationKey(), nil
+}
+
+// lookup 按 kid 查找可用的密钥，未知 kid 时限频重新加载一次，以便验证其他实例刚签发的令牌
+func (m *Manager) lookup(kid string) *Key {
+	now := time.Now()
+	m.mu.RLock()
+	key, reloadedAt := m.keys[kid], m.lastReloadAt
+	m.mu.RUnlock()
+
+	if key == nil && now.Sub(reloadedAt) >= unknownKeyReloadInterval {
+		if err := m.reload(context.Background()); err != nil {
+			log.Printf("Failed to reload signing keys: %v", err)
+		}
+		m.mu.RLock()
+		key = m.keys[kid]
+		m.mu.RUnlock()
+	}
+	if key == nil || !key.usable(now) {
+		return nil
+	}
+	return key
+}
+
+// Keys 可用于验证的密钥，按创建时间倒序
+func (m *Manager) Keys() []*Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(m.keys))
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// CurrentKeyID 当前签名密钥的 kid
+func (m *Manager) CurrentKeyID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.current == nil {
+		return ""
+	}
+	return m.current.ID
+}
+
+// JWKS 可用于验证的非对称公钥，供其他服务验证令牌
+func (m *Manager) JWKS() JWKS {
+	now := time.Now()
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range m.Keys() {
+		if !key.usable(now) {
+			continue
+		}
+		if jwk, ok := key.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// seal 加密私钥，未配置密钥时原样返回
+func (m *Manager) seal(private string) (string, error) {
+	if m.aead == nil {
+		return private, nil
+	}
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := m.aead.Seal(nonce, nonce, []byte(private), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open 解密私钥，兼容未加密存储的密钥
+func (m *Manager) open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if m.aead == nil {
+		return "", fmt.Errorf("signing key is encrypted but no encryption key is configured")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil || len(data) < m.aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted signing key")
+	}
+	nonce, ciphertext := data[:m.aead.NonceSize()], data[m.aead.NonceSize():]
+	plain, err := m.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt signing key: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,241 @@
+package jwtkeys
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore 内存密钥存储
+type memoryStore struct {
+	mu   sync.Mutex
+	keys []StoredKey
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []StoredKey
+	now := time.Now()
+	for _, key := range s.keys {
+		if key.ExpiresAt == nil || key.ExpiresAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryStore) Rotate(ctx context.Context, next StoredKey, minAge time.Duration, retireAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if minAge > 0 && key.ExpiresAt == nil && key.Algorithm == next.Algorithm && time.Since(key.CreatedAt) < minAge {
+			return false, nil
+		}
+	}
+	for i := range s.keys {
+		if s.keys[i].ExpiresAt == nil {
+			s.keys[i].ExpiresAt = &retireAt
+		}
+	}
+	s.keys = append(s.keys, next)
+	return true, nil
+}
+
+// expireAll 让已轮换的密钥立即过期
+func (s *memoryStore) expireAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	past := time.Now().Add(-time.Second)
+	for i := range s.keys {
+		if s.keys[i].ExpiresAt != nil {
+			s.keys[i].ExpiresAt = &past
+		}
+	}
+}
+
+func newTestManager(t *testing.T, store Store, alg Algorithm) *Manager {
+	config := DefaultConfig()
+	config.Algorithm = alg
+	config.EncryptionKey = "test-encryption-key"
+	config.LegacySecret = "legacy-secret"
+	m, err := NewManager(store, config)
+	require.NoError(t, err)
+	require.NoError(t, m.Start(context.Background()))
+	return m
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+}
+
+func parse(m *Manager, token string) error {
+	_, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, m.Keyfunc)
+	return err
+}
+
+func TestManager_SignAndVerify(t *testing.T) {
+	for _, alg := range []Algorithm{HS256, RS256, EdDSA} {
+		t.Run(string(alg), func(t *testing.T) {
+			m := newTestManager(t, &memoryStore{}, alg)
+
+			token, err := m.Sign(testClaims())
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, m.CurrentKeyID(), parsed.Header["kid"])
+			assert.Equal(t, string(alg), parsed.Header["alg"])
+			assert.NoError(t, parse(m, token))
+		})
+	}
+}
+
+func TestManager_StoresEncryptedKeys(t *testing.T) {
+	store := &memoryStore{}
+	m := newTestManager(t, store, EdDSA)
+	token, err := m.Sign(testClaims())
+	require.NoError(t, err)
+
+	require.Len(t, store.keys, 1)
+	assert.True(t, strings.HasPrefix(store.keys[0].PrivateKey, encryptedPrefix))
+
+	// 其他实例用同一加密密钥加载后可验证
+	other := newTestManager(t, store, EdDSA)
+	assert.Equal(t, m.CurrentKeyID(), other.CurrentKeyID())
+	assert.NoError(t, parse(other, token))
+}
+
+func TestManager_RotationGracePeriod(t *testing.T) {
+	store := &memoryStore{}
+	m := newTestManager(t, store, RS256)
+	oldKid := m.CurrentKeyID()
+	oldToken, err := m.Sign(testClaims())
+	require.NoError(t, err)
+
+	require.NoError(t, m.Rotate(context.Background()))
+	assert.NotEqual(t, oldKid, m.CurrentKeyID())
+	assert.Len(t, m.Keys(), 2)
+
+	// 旧密钥在宽限期内仍可验证
+	assert.NoError(t, parse(m, oldToken))
+	newToken, err := m.Sign(testClaims())
+	require.NoError(t, err)
+	assert.NoError(t, parse(m, newToken))
+
+	// 宽限期结束后旧令牌被拒绝
+	store.expireAll()
+	require.NoError(t, m.reload(context.Background()))
+	assert.ErrorIs(t, parse(m, oldToken), ErrUnknownKey)
+	assert.NoError(t, parse(m, newToken))
+}
+
+func TestManager_UnknownKidReloads(t *testing.T) {
+	store := &memoryStore{}
+	m := newTestManager(t, store, HS256)
+	other := newTestManager(t, store, HS256)
+
+	// 另一实例轮换后签发的令牌，本实例遇到未知 kid 时重新加载
+	require.NoError(t, other.Rotate(context.Background()))
+	token, err := other.Sign(testClaims())
+	require.NoError(t, err)
+
+	m.mu.Lock()
+	m.lastReloadAt = time.Time{}
+	m.mu.Unlock()
+	assert.NoError(t, parse(m, token))
+	assert.Equal(t, other.CurrentKeyID(), m.CurrentKeyID())
+}
+
+func TestManager_StartReusesRecentKey(t *testing.T) {
+	store := &memoryStore{}
+	m := newTestManager(t, store, EdDSA)
+	other := newTestManager(t, store, EdDSA)
+	assert.Equal(t, m.CurrentKeyID(), other.CurrentKeyID())
+	assert.Len(t, store.keys, 1)
+
+	// 算法变更后启动时轮换
+	switched := newTestManager(t, store, RS256)
+	assert.NotEqual(t, m.CurrentKeyID(), switched.CurrentKeyID())
+	assert.Len(t, store.keys, 2)
+}
+
+// TestNewManager_GracePeriodCoversTokenLifetime 宽限期短于令牌有效期时拒绝创建，
+// 否则轮换后仍在有效期内的令牌会被拒绝
+func TestNewManager_GracePeriodCoversTokenLifetime(t *testing.T) {
+	config := DefaultConfig()
+	config.GracePeriod = 0
+	_, err := NewManager(&memoryStore{}, config)
+	assert.Error(t, err)
+
+	config.GracePeriod = config.TokenLifetime - time.Hour
+	_, err = NewManager(&memoryStore{}, config)
+	assert.Error(t, err)
+
+	config.GracePeriod = config.TokenLifetime
+	_, err = NewManager(&memoryStore{}, config)
+	assert.NoError(t, err)
+}
+
+func TestManager_RejectsAlgorithmMismatch(t *testing.T) {
+	m := newTestManager(t, &memoryStore{}, RS256)
+	kid := m.CurrentKeyID()
+
+	// 以公钥为 HMAC 密钥伪造的令牌
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	token.Header["kid"] = kid
+	signed, err := token.SignedString([]byte("anything"))
+	require.NoError(t, err)
+	assert.Error(t, parse(m, signed))
+
+	// 未知 kid
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	token.Header["kid"] = "unknown"
+	signed, err = token.SignedString([]byte("anything"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, parse(m, signed), ErrUnknownKey)
+}
+
+func TestManager_LegacyTokens(t *testing.T) {
+	m := newTestManager(t, &memoryStore{}, EdDSA)
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("legacy-secret"))
+	require.NoError(t, err)
+	assert.NoError(t, parse(m, legacy))
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("wrong-secret"))
+	require.NoError(t, err)
+	assert.Error(t, parse(m, forged))
+}
+
+func TestManager_JWKS(t *testing.T) {
+	m := newTestManager(t, &memoryStore{}, EdDSA)
+	require.NoError(t, m.Rotate(context.Background()))
+
+	set := m.JWKS()
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, m.CurrentKeyID(), set.Keys[0].KeyID)
+	for _, key := range set.Keys {
+		assert.Equal(t, "OKP", key.KeyType)
+		assert.Equal(t, "Ed25519", key.Curve)
+		assert.NotEmpty(t, key.X)
+	}
+
+	rsa := newTestManager(t, &memoryStore{}, RS256)
+	set = rsa.JWKS()
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "RSA", set.Keys[0].KeyType)
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+
+	// 对称密钥不发布
+	hmac := newTestManager(t, &memoryStore{}, HS256)
+	assert.Empty(t, hmac.JWKS().Keys)
+}
@@ -0,0 +1,98 @@
+package jwtkeys
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// StoredKey 持久化的密钥
+type StoredKey struct {
+	ID         string     `db:"kid"`
+	Algorithm  string     `db:"algorithm"`
+	PrivateKey string     `db:"private_key"` // 配置了加密密钥时为密文
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+}
+
+// Store 签名密钥存储，多个实例共用
+type Store interface {
+	// List 仍可用于验证的密钥
+	List(ctx context.Context) ([]StoredKey, error)
+	// Rotate 存在 minAge 内创建的当前密钥时不轮换并返回 false；否则为当前密钥设置过期时间 retireAt，
+	// 写入 next 并清理已过期的密钥。多个实例同时轮换时只有一个生效
+	Rotate(ctx context.Context, next StoredKey, minAge time.Duration, retireAt time.Time) (bool, error)
+}
+
+// SQLStore 基于 jwt_signing_keys 表的密钥存储
+type SQLStore struct {
+	db *database.DB
+}
+
+// NewSQLStore 创建密钥存储
+func NewSQLStore(db *database.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// List 实现 Store
+func (s *SQLStore) List(ctx context.Context) ([]StoredKey, error) {
+	var keys []StoredKey
+	query := `
+		SELECT kid, algorithm, private_key, created_at, expires_at
+		FROM jwt_signing_keys
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY created_at DESC`
+	if err := s.db.SelectContext(ctx, &keys, query); err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Rotate 实现 Store，以事务级咨询锁串行化各实例的轮换
+func (s *SQLStore) Rotate(ctx context.Context, next StoredKey, minAge time.Duration, retireAt time.Time) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('jwt_signing_keys'))`); err != nil {
+		return false, fmt.Errorf("failed to lock signing keys: %w", err)
+	}
+
+	if minAge > 0 {
+		var recent bool
+		err := tx.GetContext(ctx, &recent, `
+			SELECT EXISTS (
+				SELECT 1 FROM jwt_signing_keys
+				WHERE expires_at IS NULL AND algorithm = $1 AND created_at > $2
+			)`, next.Algorithm, time.Now().Add(-minAge))
+		if err != nil {
+			return false, fmt.Errorf("failed to check current signing key: %w", err)
+		}
+		if recent {
+			return false, nil
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE jwt_signing_keys SET expires_at = $1 WHERE expires_at IS NULL`, retireAt); err != nil {
+		return false, fmt.Errorf("failed to retire signing keys: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO jwt_signing_keys (kid, algorithm, private_key, created_at)
+		VALUES ($1, $2, $3, $4)`,
+		next.ID, next.Algorithm, next.PrivateKey, next.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to insert signing key: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM jwt_signing_keys WHERE expires_at IS NOT NULL AND expires_at < NOW()`); err != nil {
+		return false, fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit signing key rotation: %w", err)
+	}
+	return true, nil
+}
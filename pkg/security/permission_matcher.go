@@ -0,0 +1,124 @@
+package security
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PermissionAll 匹配全部权限
+const PermissionAll Permission = "*"
+
+// denyPrefix 拒绝规则的前缀，如 "!coupon:delete"
+const denyPrefix = "!"
+
+// validPatternPattern 权限模式：任一段可为 *，末段的 * 匹配其后的全部层级
+var validPatternPattern = regexp.MustCompile(`^(\*|[a-z][a-z0-9_]*)(\.(\*|[a-z][a-z0-9_]*))*:(\*|[a-z][a-z0-9_]*)$`)
+
+// Deny 权限对应的拒绝规则，拒绝规则优先于任何允许规则
+func Deny(permission Permission) Permission {
+	return denyPrefix + permission
+}
+
+// IsDeny 是否为拒绝规则
+func (p Permission) IsDeny() bool {
+	return strings.HasPrefix(string(p), denyPrefix)
+}
+
+// IsPattern 是否包含通配符
+func (p Permission) IsPattern() bool {
+	return strings.Contains(string(p), "*")
+}
+
+// target 去掉拒绝前缀后的权限或模式
+func (p Permission) target() Permission {
+	return Permission(strings.TrimPrefix(string(p), denyPrefix))
+}
+
+// segments 按 "." 与 ":" 拆分层级，如 payment.refund:write -> [payment refund write]
+func (p Permission) segments() []string {
+	return strings.FieldsFunc(string(p), func(r rune) bool { return r == '.' || r == ':' })
+}
+
+// validPattern 模式语法是否合法
+func validPattern(permission Permission) bool {
+	return permission == PermissionAll || validPatternPattern.MatchString(string(permission))
+}
+
+// permissionTrie 按层级存放权限与模式的前缀树
+type permissionTrie struct {
+	children map[string]*permissionTrie
+	// any 非末段的 *，匹配恰好一个层级
+	any *permissionTrie
+	// rest 模式在此处以 * 结尾，匹配其后一个或多个层级
+	rest bool
+	// terminal 完整权限在此处结束
+	terminal bool
+}
+
+func (t *permissionTrie) insert(segments []string) {
+	node := t
+	for i, segment := range segments {
+		if segment == "*" && i == len(segments)-1 {
+			node.rest = true
+			return
+		}
+		if segment == "*" {
+			if node.any == nil {
+				node.any = &permissionTrie{}
+			}
+			node = node.any
+			continue
+		}
+		if node.children == nil {
+			node.children = make(map[string]*permissionTrie)
+		}
+		child, exists := node.children[segment]
+		if !exists {
+			child = &permissionTrie{}
+			node.children[segment] = child
+		}
+		node = child
+	}
+	node.terminal = true
+}
+
+func (t *permissionTrie) match(segments []string) bool {
+	if len(segments) == 0 {
+		return t.terminal
+	}
+	if t.rest {
+		return true
+	}
+	if child, exists := t.children[segments[0]]; exists && child.match(segments[1:]) {
+		return true
+	}
+	return t.any != nil && t.any.match(segments[1:])
+}
+
+// PermissionMatcher 由允许与拒绝规则构建的权限匹配器，检查耗时只与权限的层级数有关
+type PermissionMatcher struct {
+	allow permissionTrie
+	deny  permissionTrie
+}
+
+// NewPermissionMatcher 由权限、模式与拒绝规则构建匹配器
+func NewPermissionMatcher(rules []Permission) *PermissionMatcher {
+	matcher := &PermissionMatcher{}
+	for _, rule := range rules {
+		trie := &matcher.allow
+		if rule.IsDeny() {
+			trie = &matcher.deny
+		}
+		trie.insert(rule.target().segments())
+	}
+	return matcher
+}
+
+// Allows 权限被某条允许规则匹配且未被任何拒绝规则匹配
+func (m *PermissionMatcher) Allows(permission Permission) bool {
+	segments := permission.segments()
+	if len(segments) == 0 {
+		return false
+	}
+	return m.allow.match(segments) && !m.deny.match(segments)
+}
@@ -0,0 +1,75 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPermissionMatcher 通配符按层级匹配，拒绝规则优先于允许规则
+func TestPermissionMatcher(t *testing.T) {
+	matcher := NewPermissionMatcher([]Permission{
+		"coupon:*",
+		"payment:read",
+		"*:monitor",
+		"report.*:export",
+		Deny("coupon:delete"),
+	})
+
+	cases := map[Permission]bool{
+		"coupon:read":             true,
+		"coupon:write":            true,
+		"coupon.batch:import":     true, // 末段的 * 匹配其后的全部层级
+		"coupon:delete":           false,
+		"payment:read":            true,
+		"payment:write":           false,
+		"payment.refund:read":     false,
+		"system:monitor":          true,
+		"system.disk:monitor":     false, // 非末段的 * 只匹配一个层级
+		"report.daily:export":     true,
+		"report.daily.raw:export": false,
+		"":                        false,
+	}
+	for permission, expected := range cases {
+		assert.Equal(t, expected, matcher.Allows(permission), permission)
+	}
+
+	all := NewPermissionMatcher([]Permission{PermissionAll, Deny("admin:*")})
+	assert.True(t, all.Allows("payment.refund:write"))
+	assert.False(t, all.Allows(PermissionAdminSystem))
+}
+
+// TestRBAC_WildcardAndDenyRules 角色定义中的模式与拒绝规则，拒绝规则同样作用于继承该角色的角色
+func TestRBAC_WildcardAndDenyRules(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.DefineRole(ctx, "coupon_manager", []Role{RoleUser}, []Permission{"coupon:*", Deny(PermissionCouponDelete)}))
+	require.NoError(t, rbac.DefineRole(ctx, "coupon_lead", []Role{"coupon_manager"}, []Permission{PermissionCouponDelete}))
+	require.NoError(t, rbac.AssignRole(ctx, "u1", "coupon_manager"))
+	require.NoError(t, rbac.AssignRole(ctx, "u2", "coupon_lead"))
+
+	for _, userID := range []string{"u1", "u2"} {
+		has, err := rbac.HasPermission(ctx, userID, PermissionCouponWrite)
+		require.NoError(t, err)
+		assert.True(t, has)
+		has, err = rbac.HasPermission(ctx, userID, PermissionCouponDelete)
+		require.NoError(t, err)
+		assert.False(t, has, "deny rule should override allow for %s", userID)
+	}
+
+	// 超级管理员拥有此后注册的权限
+	RegisterPermission(PermissionDefinition{Name: "test.wildcard:read", Module: "test"})
+	require.NoError(t, rbac.AssignRole(ctx, "root", RoleSuperAdmin))
+	has, err := rbac.HasPermission(ctx, "root", "test.wildcard:read")
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	// 模式须匹配已注册的权限
+	assert.ErrorIs(t, rbac.DefineRole(ctx, "ghost", nil, []Permission{"ghost:*"}), ErrUnknownPermission)
+	assert.ErrorIs(t, rbac.DefineRole(ctx, "ghost", nil, []Permission{"coupon*"}), ErrUnknownPermission)
+	assert.NoError(t, ValidatePermissions(PermissionAll, "test.*:read", Deny("admin:*")))
+}
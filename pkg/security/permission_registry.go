@@ -0,0 +1,182 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownPermission 权限未注册
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// validPermissionPattern 权限名为 "资源:动作"，资源可用点号分级，如 payment.refund:write
+var validPermissionPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)*:[a-z][a-z0-9_]*$`)
+
+// PermissionDefinition 权限定义
+type PermissionDefinition struct {
+	Name        Permission `json:"name" db:"name"`
+	Module      string     `json:"module" db:"module"`
+	Description string     `json:"description" db:"description"`
+}
+
+// Resource 权限名中的资源部分
+func (d PermissionDefinition) Resource() string {
+	resource, _, _ := strings.Cut(string(d.Name), ":")
+	return resource
+}
+
+var (
+	permissionRegistry   = make(map[Permission]PermissionDefinition)
+	permissionRegistryMu sync.RWMutex
+)
+
+// RegisterPermission 注册权限，各模块在 init 中声明自有权限。
+// 权限名不合法或已被其他模块注册时 panic；同一模块重复注册时覆盖描述
+func RegisterPermission(def PermissionDefinition) {
+	if err := registerPermission(def); err != nil {
+		panic(err)
+	}
+}
+
+func registerPermission(def PermissionDefinition) error {
+	if !validPermissionPattern.MatchString(string(def.Name)) {
+		return fmt.Errorf("invalid permission name: %q", def.Name)
+	}
+	if def.Module == "" {
+		return fmt.Errorf("permission %s: module is required", def.Name)
+	}
+
+	permissionRegistryMu.Lock()
+	defer permissionRegistryMu.Unlock()
+	if existing, exists := permissionRegistry[def.Name]; exists && existing.Module != def.Module {
+		return fmt.Errorf("permission %s already registered by module %s", def.Name, existing.Module)
+	}
+	permissionRegistry[def.Name] = def
+	return nil
+}
+
+// LookupPermission 获取权限定义
+func LookupPermission(permission Permission) (PermissionDefinition, bool) {
+	permissionRegistryMu.RLock()
+	defer permissionRegistryMu.RUnlock()
+	def, exists := permissionRegistry[permission]
+	return def, exists
+}
+
+// ListPermissions 已注册的权限，按名称排序；module 非空时只返回该模块的权限
+func ListPermissions(module string) []PermissionDefinition {
+	permissionRegistryMu.RLock()
+	defer permissionRegistryMu.RUnlock()
+
+	defs := make([]PermissionDefinition, 0, len(permissionRegistry))
+	for _, def := range permissionRegistry {
+		if module == "" || def.Module == module {
+			defs = append(defs, def)
+		}
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// ValidatePermissions 检查权限均已注册，返回的错误列出全部未注册的权限。
+// 拒绝规则检查其目标；模式须语法合法且至少匹配一个已注册的权限
+func ValidatePermissions(permissions ...Permission) error {
+	permissionRegistryMu.RLock()
+	defer permissionRegistryMu.RUnlock()
+
+	var unknown []string
+	for _, permission := range permissions {
+		if !knownLocked(permission.target()) {
+			unknown = append(unknown, string(permission))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownPermission, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func knownLocked(permission Permission) bool {
+	if !permission.IsPattern() {
+		_, exists := permissionRegistry[permission]
+		return exists
+	}
+	if !validPattern(permission) {
+		return false
+	}
+	matcher := NewPermissionMatcher([]Permission{permission})
+	for name := range permissionRegistry {
+		if matcher.Allows(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionStore 权限定义的持久化存储
+type PermissionStore interface {
+	List(ctx context.Context) ([]PermissionDefinition, error)
+	// Save 按名称新增或覆盖
+	Save(ctx context.Context, defs []PermissionDefinition) error
+}
+
+// SyncPermissions 将本实例注册的权限写入存储，并载入其他服务注册的权限，
+// 使管理后台看到全部权限，授予其他服务声明的权限时也能通过校验
+func SyncPermissions(ctx context.Context, store PermissionStore) error {
+	if err := store.Save(ctx, ListPermissions("")); err != nil {
+		return fmt.Errorf("failed to save permissions: %w", err)
+	}
+
+	defs, err := store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load permissions: %w", err)
+	}
+	for _, def := range defs {
+		if _, exists := LookupPermission(def.Name); exists {
+			continue
+		}
+		if err := registerPermission(def); err != nil {
+			log.Printf("Skipping stored permission %s: %v", def.Name, err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	builtins := []struct {
+		module      string
+		permission  Permission
+		description string
+	}{
+		{"user", PermissionUserRead, "查看用户"},
+		{"user", PermissionUserWrite, "创建、修改用户"},
+		{"user", PermissionUserDelete, "删除用户"},
+		{"coupon", PermissionCouponRead, "查看优惠券"},
+		{"coupon", PermissionCouponWrite, "创建、修改优惠券"},
+		{"coupon", PermissionCouponDelete, "删除优惠券"},
+		{"moment", PermissionMomentRead, "查看动态"},
+		{"moment", PermissionMomentWrite, "发布、修改动态"},
+		{"moment", PermissionMomentDelete, "删除动态"},
+		{"payment", PermissionPaymentRead, "查看订单与支付记录"},
+		{"payment", PermissionPaymentWrite, "修改订单与支付记录"},
+		{"user", PermissionImpersonate, "以用户身份临时登录排查问题，操作逐条审计"},
+		{"admin", PermissionAdminRead, "查看管理后台数据"},
+		{"admin", PermissionAdminWrite, "修改管理后台数据"},
+		{"admin", PermissionAdminDelete, "删除管理后台数据"},
+		{"admin", PermissionAdminSystem, "管理系统设置"},
+		{"system", PermissionSystemMonitor, "查看系统监控"},
+		{"system", PermissionSystemConfig, "修改系统配置"},
+	}
+	for _, builtin := range builtins {
+		RegisterPermission(PermissionDefinition{
+			Name:        builtin.permission,
+			Module:      builtin.module,
+			Description: builtin.description,
+		})
+	}
+}
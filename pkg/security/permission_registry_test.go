@@ -0,0 +1,81 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPermissionStore 内存中的权限定义存储
+type memoryPermissionStore struct {
+	defs map[Permission]PermissionDefinition
+}
+
+func (s *memoryPermissionStore) List(ctx context.Context) ([]PermissionDefinition, error) {
+	defs := make([]PermissionDefinition, 0, len(s.defs))
+	for _, def := range s.defs {
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+func (s *memoryPermissionStore) Save(ctx context.Context, defs []PermissionDefinition) error {
+	for _, def := range defs {
+		s.defs[def.Name] = def
+	}
+	return nil
+}
+
+// TestRegisterPermission 模块注册命名空间权限，名称不合法或与其他模块冲突时拒绝
+func TestRegisterPermission(t *testing.T) {
+	RegisterPermission(PermissionDefinition{Name: "test.search:read", Module: "test", Description: "搜索"})
+
+	def, exists := LookupPermission("test.search:read")
+	require.True(t, exists)
+	assert.Equal(t, "test.search", def.Resource())
+	assert.Contains(t, ListPermissions("test"), def)
+	assert.NotContains(t, ListPermissions("user"), def)
+
+	assert.Panics(t, func() {
+		RegisterPermission(PermissionDefinition{Name: "test.search:read", Module: "other"})
+	})
+	for _, name := range []Permission{"search", "Search:read", "search:", ":read", "search:read:all"} {
+		assert.Error(t, registerPermission(PermissionDefinition{Name: name, Module: "test"}), name)
+	}
+}
+
+// TestRBAC_RejectsUnknownPermissions 授予角色或用户未注册的权限时拒绝
+func TestRBAC_RejectsUnknownPermissions(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+
+	assert.ErrorIs(t, rbac.AddPermissionToRole(ctx, RoleUser, "test.unknown:read"), ErrUnknownPermission)
+	assert.ErrorIs(t, rbac.GrantPermission(ctx, "u1", "test.unknown:read"), ErrUnknownPermission)
+	assert.ErrorIs(t, rbac.DefineRole(ctx, "tester", []Role{RoleUser}, []Permission{PermissionUserRead, "test.unknown:read"}), ErrUnknownPermission)
+	_, err := rbac.GetRole("tester")
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+
+	RegisterPermission(PermissionDefinition{Name: "test.refund:write", Module: "test"})
+	require.NoError(t, rbac.GrantPermission(ctx, "u1", "test.refund:write"))
+	has, err := rbac.HasPermission(ctx, "u1", "test.refund:write")
+	require.NoError(t, err)
+	assert.True(t, has)
+}
+
+// TestSyncPermissions 本实例的权限写入存储，其他服务写入的权限载入后可授予
+func TestSyncPermissions(t *testing.T) {
+	store := &memoryPermissionStore{defs: map[Permission]PermissionDefinition{
+		"test.remote:read": {Name: "test.remote:read", Module: "remote", Description: "其他服务的权限"},
+	}}
+	require.NoError(t, SyncPermissions(context.Background(), store))
+
+	assert.Contains(t, store.defs, PermissionUserRead)
+	def, exists := LookupPermission("test.remote:read")
+	require.True(t, exists)
+	assert.Equal(t, "remote", def.Module)
+	assert.NoError(t, ValidatePermissions("test.remote:read", PermissionAdminRead))
+}
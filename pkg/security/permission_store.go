@@ -0,0 +1,54 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"user_crud_jwt/pkg/database"
+)
+
+// SQLPermissionStore 基于 permissions 表的权限定义存储，多个服务共用同一张表
+type SQLPermissionStore struct {
+	db *database.DB
+}
+
+// NewSQLPermissionStore 创建权限定义存储
+func NewSQLPermissionStore(db *database.DB) *SQLPermissionStore {
+	return &SQLPermissionStore{db: db}
+}
+
+// List 全部权限定义，按名称排序
+func (s *SQLPermissionStore) List(ctx context.Context) ([]PermissionDefinition, error) {
+	var defs []PermissionDefinition
+	if err := s.db.SelectContext(ctx, &defs, `SELECT name, module, description FROM permissions ORDER BY name`); err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	return defs, nil
+}
+
+// Save 在一个事务中新增或覆盖权限定义
+func (s *SQLPermissionStore) Save(ctx context.Context, defs []PermissionDefinition) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, def := range defs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO permissions (name, module, description, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (name) DO UPDATE SET
+				module = EXCLUDED.module,
+				description = EXCLUDED.description,
+				updated_at = NOW()`,
+			string(def.Name), def.Module, def.Description)
+		if err != nil {
+			return fmt.Errorf("failed to save permission %s: %w", def.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit permissions: %w", err)
+	}
+	return nil
+}
@@ -3,11 +3,15 @@ package security
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/events"
+	"user_crud_jwt/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
 )
@@ -58,30 +62,65 @@ const (
 
 // PermissionChecker 权限检查器接口
 type PermissionChecker interface {
-	HasPermission(userID string, permission Permission) (bool, error)
-	HasRole(userID string, role Role) (bool, error)
-	HasAnyPermission(userID string, permissions []Permission) (bool, error)
-	HasAllPermissions(userID string, permissions []Permission) (bool, error)
-	GetUserPermissions(userID string) ([]Permission, error)
-	GetUserRole(userID string) (Role, error)
+	HasPermission(ctx context.Context, userID string, permission Permission) (bool, error)
+	HasRole(ctx context.Context, userID string, role Role) (bool, error)
+	HasAnyPermission(ctx context.Context, userID string, permissions []Permission) (bool, error)
+	HasAllPermissions(ctx context.Context, userID string, permissions []Permission) (bool, error)
+	GetUserPermissions(ctx context.Context, userID string) ([]Permission, error)
+	GetUserRole(ctx context.Context, userID string) (Role, error)
 }
 
-// RBAC 基于角色的访问控制
+// permissionCacheTTL 权限检查结果的缓存时间
+const permissionCacheTTL = 30 * time.Minute
+
+// 权限变更事件主题
+const (
+	// TopicUserPermissionsChanged 用户的角色或有效权限发生变化
+	TopicUserPermissionsChanged = "rbac.user_permissions_changed"
+	// TopicPolicyChanged 策略引擎的策略发生变化
+	TopicPolicyChanged = "rbac.policy_changed"
+)
+
+// PermissionChange 权限变更事件
+type PermissionChange struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+	Policy  string   `json:"policy,omitempty"`
+}
+
+// RBAC 基于角色的访问控制。
+// 检查结果缓存在以用户缓存代数为键的条目中：读取时先取代数再读内存状态，
+// 变更时先更新内存状态再递增代数，与变更并发写入的旧结果落在旧代数的键下，不会再被读取
 type RBAC struct {
-	cache           cache.CacheService
+	cache    cache.CacheService
+	versions *cache.TagInvalidationStrategy
+	// rolePermissions 角色直接拥有的权限，继承的权限由 roleParents 解析
 	rolePermissions map[Role][]Permission
+	roleParents     map[Role][]Role
 	userRoles       map[string]Role
+	// userGrants 单独授予用户的权限
+	userGrants map[string][]Permission
+	// userPermissions 用户的有效权限规则：角色解析出的规则与单独授予的规则，角色或授权变化时重新计算。
+	// 规则可以是权限、模式（如 coupon:*）或拒绝规则（如 !coupon:delete）
 	userPermissions map[string][]Permission
+	// userMatchers 由 userPermissions 构建的匹配器
+	userMatchers map[string]*PermissionMatcher
 	mu              sync.RWMutex
+	// changes 权限变更事件，策略决定缓存等下游缓存据此失效
+	changes *events.Bus[PermissionChange]
 }
 
 // NewRBAC 创建 RBAC 实例
-func NewRBAC(cache cache.CacheService) *RBAC {
+func NewRBAC(cacheService cache.CacheService) *RBAC {
 	rbac := &RBAC{
-		cache:           cache,
+		cache:           cacheService,
+		versions:        cache.NewTagInvalidationStrategy(cacheService),
 		rolePermissions: make(map[Role][]Permission),
+		roleParents:     make(map[Role][]Role),
 		userRoles:       make(map[string]Role),
+		userGrants:      make(map[string][]Permission),
 		userPermissions: make(map[string][]Permission),
+		userMatchers:    make(map[string]*PermissionMatcher),
+		changes:         events.NewBus[PermissionChange]("rbac", events.DefaultConfig()),
 	}
 
 	// 初始化角色权限映射
@@ -90,7 +129,8 @@ func NewRBAC(cache cache.CacheService) *RBAC {
 	return rbac
 }
 
-// initDefaultRoles 初始化默认角色
+// initDefaultRoles 初始化默认角色：super_admin > admin > moderator > user，
+// 每个角色只列出相对上一级新增的权限
 func (rbac *RBAC) initDefaultRoles() {
 	// 普通用户权限
 	rbac.rolePermissions[RoleUser] = []Permission{
@@ -101,120 +141,64 @@ func (rbac *RBAC) initDefaultRoles() {
 		PermissionMomentWrite,
 	}
 
-	// 管理员权限
+	// 版主权限
+	rbac.roleParents[RoleModerator] = []Role{RoleUser}
 	rbac.rolePermissions[RoleModerator] = []Permission{
-		PermissionUserRead,
-		PermissionUserWrite,
 		PermissionUserDelete,
-		PermissionCouponRead,
 		PermissionCouponWrite,
 		PermissionCouponDelete,
-		PermissionMomentRead,
-		PermissionMomentWrite,
 		PermissionMomentDelete,
 		PermissionPaymentRead,
 	}
 
 	// 管理员权限
+	rbac.roleParents[RoleAdmin] = []Role{RoleModerator}
 	rbac.rolePermissions[RoleAdmin] = []Permission{
-		PermissionUserRead,
-		PermissionUserWrite,
-		PermissionUserDelete,
-		PermissionCouponRead,
-		PermissionCouponWrite,
-		PermissionCouponDelete,
-		PermissionMomentRead,
-		PermissionMomentWrite,
-		PermissionMomentDelete,
-		PermissionPaymentRead,
 		PermissionPaymentWrite,
 		PermissionAdminRead,
 		PermissionAdminWrite,
 		PermissionAdminDelete,
 	}
 
-	// 超级管理员权限
+	// 超级管理员拥有全部权限，包括各模块此后注册的权限
+	rbac.roleParents[RoleSuperAdmin] = []Role{RoleAdmin}
 	rbac.rolePermissions[RoleSuperAdmin] = []Permission{
-		PermissionUserRead,
-		PermissionUserWrite,
-		PermissionUserDelete,
-		PermissionCouponRead,
-		PermissionCouponWrite,
-		PermissionCouponDelete,
-		PermissionMomentRead,
-		PermissionMomentWrite,
-		PermissionMomentDelete,
-		PermissionPaymentRead,
-		PermissionPaymentWrite,
-		PermissionAdminRead,
-		PermissionAdminWrite,
-		PermissionAdminDelete,
-		PermissionAdminSystem,
-		PermissionSystemMonitor,
-		PermissionSystemConfig,
+		PermissionAll,
 	}
 }
 
-// HasPermission 检查用户是否有指定权限
-func (rbac *RBAC) HasPermission(userID string, permission Permission) (bool, error) {
-	// 首先检查缓存
-	cacheKey := fmt.Sprintf("user_permission:%s:%s", userID, permission)
-	var hasPermission bool
-	if err := rbac.cache.Get(context.Background(), cacheKey, &hasPermission); err == nil {
-		return hasPermission, nil
-	}
-
-	// 检查用户权限
-	rbac.mu.RLock()
-	userPerms, exists := rbac.userPermissions[userID]
-	rbac.mu.RUnlock()
-
-	if !exists {
-		return false, fmt.Errorf("user not found: %s", userID)
-	}
+// HasPermission 检查用户是否有指定权限：被某条允许规则或模式匹配，且未被拒绝规则匹配
+func (rbac *RBAC) HasPermission(ctx context.Context, userID string, permission Permission) (bool, error) {
+	return cachedLookup(ctx, rbac, userID, "permission:"+string(permission), func() (bool, error) {
+		rbac.mu.RLock()
+		defer rbac.mu.RUnlock()
 
-	for _, perm := range userPerms {
-		if perm == permission {
-			// 缓存结果
-			rbac.cache.Set(context.Background(), cacheKey, true, time.Minute*30)
-			return true, nil
+		matcher, exists := rbac.userMatchers[userID]
+		if !exists {
+			return false, fmt.Errorf("user not found: %s", userID)
 		}
-	}
-
-	// 缓存结果
-	rbac.cache.Set(context.Background(), cacheKey, false, time.Minute*30)
-	return false, nil
+		return matcher.Allows(permission), nil
+	})
 }
 
-// HasRole 检查用户是否有指定角色
-func (rbac *RBAC) HasRole(userID string, role Role) (bool, error) {
-	// 首先检查缓存
-	cacheKey := fmt.Sprintf("user_role:%s:%s", userID, role)
-	var hasRole bool
-	if err := rbac.cache.Get(context.Background(), cacheKey, &hasRole); err == nil {
-		return hasRole, nil
-	}
-
-	// 检查用户角色
-	rbac.mu.RLock()
-	userRole, exists := rbac.userRoles[userID]
-	rbac.mu.RUnlock()
+// HasRole 检查用户是否有指定角色，继承自该角色的上级角色同样视为拥有
+func (rbac *RBAC) HasRole(ctx context.Context, userID string, role Role) (bool, error) {
+	return cachedLookup(ctx, rbac, userID, "has_role:"+string(role), func() (bool, error) {
+		rbac.mu.RLock()
+		defer rbac.mu.RUnlock()
 
-	if !exists {
-		return false, fmt.Errorf("user not found: %s", userID)
-	}
-
-	hasRole = (userRole == role)
-
-	// 缓存结果
-	rbac.cache.Set(context.Background(), cacheKey, hasRole, time.Minute*30)
-	return hasRole, nil
+		userRole, exists := rbac.userRoles[userID]
+		if !exists {
+			return false, fmt.Errorf("user not found: %s", userID)
+		}
+		return rbac.inheritsLocked(userRole, role), nil
+	})
 }
 
 // HasAnyPermission 检查用户是否有任意一个权限
-func (rbac *RBAC) HasAnyPermission(userID string, permissions []Permission) (bool, error) {
+func (rbac *RBAC) HasAnyPermission(ctx context.Context, userID string, permissions []Permission) (bool, error) {
 	for _, permission := range permissions {
-		if has, err := rbac.HasPermission(userID, permission); err != nil {
+		if has, err := rbac.HasPermission(ctx, userID, permission); err != nil {
 			return false, err
 		} else if has {
 			return true, nil
@@ -224,9 +208,9 @@ func (rbac *RBAC) HasAnyPermission(userID string, permissions []Permission) (boo
 }
 
 // HasAllPermissions 检查用户是否拥有所有权限
-func (rbac *RBAC) HasAllPermissions(userID string, permissions []Permission) (bool, error) {
+func (rbac *RBAC) HasAllPermissions(ctx context.Context, userID string, permissions []Permission) (bool, error) {
 	for _, permission := range permissions {
-		if has, err := rbac.HasPermission(userID, permission); err != nil {
+		if has, err := rbac.HasPermission(ctx, userID, permission); err != nil {
 			return false, err
 		} else if !has {
 			return false, nil
@@ -235,148 +219,302 @@ func (rbac *RBAC) HasAllPermissions(userID string, permissions []Permission) (bo
 	return true, nil
 }
 
-// GetUserPermissions 获取用户所有权限
-func (rbac *RBAC) GetUserPermissions(userID string) ([]Permission, error) {
-	// 首先检查缓存
-	cacheKey := fmt.Sprintf("user_permissions:%s", userID)
-	var permissions []Permission
-	if err := rbac.cache.Get(context.Background(), cacheKey, &permissions); err == nil {
-		return permissions, nil
-	}
+// GetUserPermissions 获取用户所有权限规则，包括模式与拒绝规则
+func (rbac *RBAC) GetUserPermissions(ctx context.Context, userID string) ([]Permission, error) {
+	return cachedLookup(ctx, rbac, userID, "permissions", func() ([]Permission, error) {
+		rbac.mu.RLock()
+		defer rbac.mu.RUnlock()
 
-	// 获取用户权限
-	rbac.mu.RLock()
-	userPerms, exists := rbac.userPermissions[userID]
-	rbac.mu.RUnlock()
+		userPerms, exists := rbac.userPermissions[userID]
+		if !exists {
+			return nil, fmt.Errorf("user not found: %s", userID)
+		}
+		return slices.Clone(userPerms), nil
+	})
+}
+
+// GetUserRole 获取用户角色
+func (rbac *RBAC) GetUserRole(ctx context.Context, userID string) (Role, error) {
+	return cachedLookup(ctx, rbac, userID, "role", func() (Role, error) {
+		rbac.mu.RLock()
+		defer rbac.mu.RUnlock()
+
+		userRole, exists := rbac.userRoles[userID]
+		if !exists {
+			return "", fmt.Errorf("user not found: %s", userID)
+		}
+		return userRole, nil
+	})
+}
 
-	if !exists {
-		return nil, fmt.Errorf("user not found: %s", userID)
+// AssignRole 为用户分配角色，单独授予的权限保留
+func (rbac *RBAC) AssignRole(ctx context.Context, userID string, role Role) error {
+	rbac.mu.Lock()
+	if !rbac.roleExistsLocked(role) {
+		rbac.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
 	}
+	rbac.userRoles[userID] = role
+	rbac.materializeLocked(userID)
+	rbac.mu.Unlock()
+
+	// 清除相关缓存
+	rbac.clearUserCache(ctx, userID)
 
-	// 缓存结果
-	rbac.cache.Set(context.Background(), cacheKey, userPerms, time.Minute*30)
-	return userPerms, nil
+	return nil
 }
 
-// GetUserRole 获取用户角色
-func (rbac *RBAC) GetUserRole(userID string) (Role, error) {
-	// 首先检查缓存
-	cacheKey := fmt.Sprintf("user_role:%s", userID)
-	var role Role
-	if err := rbac.cache.Get(context.Background(), cacheKey, &role); err == nil {
-		return role, nil
+// AddPermissionToRole 为角色添加权限，继承该角色的角色同时获得。权限须已注册
+func (rbac *RBAC) AddPermissionToRole(ctx context.Context, role Role, permission Permission) error {
+	if err := ValidatePermissions(permission); err != nil {
+		return err
 	}
 
-	// 获取用户角色
-	rbac.mu.RLock()
-	userRole, exists := rbac.userRoles[userID]
-	rbac.mu.RUnlock()
+	rbac.mu.Lock()
 
-	if !exists {
-		return "", fmt.Errorf("user not found: %s", userID)
+	// 更新角色权限
+	if !rbac.roleExistsLocked(role) {
+		rbac.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
 	}
+	permissions := rbac.rolePermissions[role]
+	if slices.Contains(permissions, permission) {
+		rbac.mu.Unlock()
+		return fmt.Errorf("permission already exists for role %s: %s", role, permission)
+	}
+	rbac.rolePermissions[role] = append(slices.Clone(permissions), permission)
+
+	// 更新拥有该角色及其上级角色的用户权限
+	affected := rbac.syncRoleUsersLocked(role)
+	rbac.mu.Unlock()
 
-	// 缓存结果
-	rbac.cache.Set(context.Background(), cacheKey, userRole, time.Minute*30)
-	return userRole, nil
+	rbac.clearUserCache(ctx, affected...)
+	return nil
 }
 
-// AssignRole 为用户分配角色
-func (rbac *RBAC) AssignRole(userID string, role Role) error {
+// RemovePermissionFromRole 从角色移除直接拥有的权限，继承而来的权限需从上游角色移除
+func (rbac *RBAC) RemovePermissionFromRole(ctx context.Context, role Role, permission Permission) error {
 	rbac.mu.Lock()
-	defer rbac.mu.Unlock()
 
-	// 更新用户角色
-	rbac.userRoles[userID] = role
+	// 更新角色权限
+	permissions := rbac.rolePermissions[role]
+	if !slices.Contains(permissions, permission) {
+		rbac.mu.Unlock()
+		return fmt.Errorf("permission not found for role %s: %s", role, permission)
+	}
+	rbac.rolePermissions[role] = slices.DeleteFunc(slices.Clone(permissions), func(perm Permission) bool {
+		return perm == permission
+	})
 
-	// 更新用户权限
-	rbac.userPermissions[userID] = rbac.rolePermissions[role]
+	// 更新拥有该角色及其上级角色的用户权限
+	affected := rbac.syncRoleUsersLocked(role)
+	rbac.mu.Unlock()
 
-	// 清除相关缓存
-	rbac.clearUserCache(userID)
+	rbac.clearUserCache(ctx, affected...)
+	return nil
+}
+
+// GrantPermission 单独授予用户权限，不随角色变化。权限须已注册
+func (rbac *RBAC) GrantPermission(ctx context.Context, userID string, permission Permission) error {
+	if err := ValidatePermissions(permission); err != nil {
+		return err
+	}
 
+	rbac.mu.Lock()
+	if slices.Contains(rbac.userGrants[userID], permission) {
+		rbac.mu.Unlock()
+		return nil
+	}
+	rbac.userGrants[userID] = append(slices.Clone(rbac.userGrants[userID]), permission)
+	rbac.materializeLocked(userID)
+	rbac.mu.Unlock()
+
+	// 状态更新之后再清除缓存，之前缓存的"无此权限"随之失效
+	rbac.clearUserCache(ctx, userID)
 	return nil
 }
 
-// AddPermissionToRole 为角色添加权限
-func (rbac *RBAC) AddPermissionToRole(role Role, permission Permission) error {
+// RevokeRole 取消用户的角色，单独授予的权限保留。用户没有角色时不做任何事
+func (rbac *RBAC) RevokeRole(ctx context.Context, userID string) error {
 	rbac.mu.Lock()
-	defer rbac.mu.Unlock()
+	if _, exists := rbac.userRoles[userID]; !exists {
+		rbac.mu.Unlock()
+		return nil
+	}
+	delete(rbac.userRoles, userID)
+	rbac.materializeLocked(userID)
+	rbac.mu.Unlock()
 
-	// 更新角色权限
-	permissions := rbac.rolePermissions[role]
-	for _, perm := range permissions {
-		if perm == permission {
-			return fmt.Errorf("permission already exists for role %s: %s", role, permission)
+	rbac.clearUserCache(ctx, userID)
+	return nil
+}
+
+// RevokePermission 撤销单独授予用户的权限，角色带来的权限不受影响。未授予时不做任何事
+func (rbac *RBAC) RevokePermission(ctx context.Context, userID string, permission Permission) error {
+	rbac.mu.Lock()
+	if !slices.Contains(rbac.userGrants[userID], permission) {
+		rbac.mu.Unlock()
+		return nil
+	}
+	rbac.userGrants[userID] = slices.DeleteFunc(slices.Clone(rbac.userGrants[userID]), func(perm Permission) bool {
+		return perm == permission
+	})
+	rbac.materializeLocked(userID)
+	rbac.mu.Unlock()
+
+	rbac.clearUserCache(ctx, userID)
+	return nil
+}
+
+// UserAccess 用户的角色、单独授予的权限与有效权限规则
+type UserAccess struct {
+	UserID      string       `json:"user_id"`
+	Role        Role         `json:"role,omitempty"`
+	Grants      []Permission `json:"grants"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// GetUserAccess 用户当前的访问权限，直接读取内存状态，不经过缓存
+func (rbac *RBAC) GetUserAccess(userID string) UserAccess {
+	rbac.mu.RLock()
+	defer rbac.mu.RUnlock()
+
+	access := UserAccess{
+		UserID:      userID,
+		Role:        rbac.userRoles[userID],
+		Grants:      slices.Clone(rbac.userGrants[userID]),
+		Permissions: slices.Clone(rbac.userPermissions[userID]),
+	}
+	if access.Grants == nil {
+		access.Grants = []Permission{}
+	}
+	if access.Permissions == nil {
+		access.Permissions = []Permission{}
+	}
+	return access
+}
+
+// materializeLocked 重新计算用户的有效权限。调用方需持有写锁
+func (rbac *RBAC) materializeLocked(userID string) {
+	var permissions []Permission
+	if role, exists := rbac.userRoles[userID]; exists {
+		// 定义角色时已拒绝循环继承，此处解析不会失败
+		permissions, _ = rbac.resolveLocked(role)
+	}
+	for _, permission := range rbac.userGrants[userID] {
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
 		}
 	}
-	rbac.rolePermissions[role] = append(permissions, permission)
+	if permissions == nil {
+		permissions = []Permission{}
+	}
+	rbac.userPermissions[userID] = permissions
+	rbac.userMatchers[userID] = NewPermissionMatcher(permissions)
+}
 
-	// 更新拥有该角色的用户权限
+// syncRoleUsersLocked 重新计算角色及继承它的角色下所有用户的有效权限，返回受影响的用户。调用方需持有写锁
+func (rbac *RBAC) syncRoleUsersLocked(role Role) []string {
+	var affected []string
 	for userID, userRole := range rbac.userRoles {
-		if userRole == role {
-			rbac.userPermissions[userID] = rbac.rolePermissions[role]
-			rbac.clearUserCache(userID)
+		if rbac.inheritsLocked(userRole, role) {
+			rbac.materializeLocked(userID)
+			affected = append(affected, userID)
 		}
 	}
-
-	return nil
+	return affected
 }
 
-// RemovePermissionFromRole 从角色移除权限
-func (rbac *RBAC) RemovePermissionFromRole(role Role, permission Permission) error {
-	rbac.mu.Lock()
-	defer rbac.mu.Unlock()
+// clearUserCache 清除用户相关缓存。须在内存状态更新之后、释放 rbac.mu 之后调用：
+// 递增缓存代数使该用户已缓存的全部结果失效，与变更前的角色、权限无关
+func (rbac *RBAC) clearUserCache(ctx context.Context, userIDs ...string) {
+	if len(userIDs) == 0 {
+		return
+	}
 
-	// 更新角色权限
-	permissions := rbac.rolePermissions[role]
-	newPermissions := make([]Permission, 0, len(permissions))
-	found := false
+	tags := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		tags[i] = userCacheTag(userID)
+	}
+	if err := rbac.versions.InvalidateTags(ctx, tags...); err != nil {
+		log.Printf("Failed to bump permission cache generation: %v", err)
+	}
 
-	for _, perm := range permissions {
-		if perm != permission {
-			newPermissions = append(newPermissions, perm)
-		} else {
-			found = true
+	// 旧代数的键已不可达，按前缀删除只为及早释放空间；代数未能递增时也靠它清除旧结果
+	for _, userID := range userIDs {
+		if err := rbac.cache.InvalidatePattern(ctx, userCacheTag(userID)+":*"); err != nil {
+			log.Printf("Failed to delete permission cache for user %s: %v", userID, err)
 		}
 	}
 
-	if !found {
-		return fmt.Errorf("permission not found for role %s: %s", role, permission)
+	if err := rbac.changes.Publish(ctx, TopicUserPermissionsChanged, "", PermissionChange{UserIDs: userIDs}); err != nil {
+		log.Printf("Failed to publish permission change: %v", err)
 	}
+}
 
-	rbac.rolePermissions[role] = newPermissions
+// Changes 权限变更事件总线，用户的角色或有效权限变化后发布 TopicUserPermissionsChanged
+func (rbac *RBAC) Changes() *events.Bus[PermissionChange] {
+	return rbac.changes
+}
 
-	// 更新拥有该角色的用户权限
-	for userID, userRole := range rbac.userRoles {
-		if userRole == role {
-			rbac.userPermissions[userID] = newPermissions
-			rbac.clearUserCache(userID)
-		}
+// PermissionsVersion 用户的权限版本，即权限缓存代数：角色、单独授权或所属角色的权限变化时递增，
+// 各实例共享。实现 utils.PermissionVersions，令牌中的版本落后时在认证阶段重新解析角色
+func (rbac *RBAC) PermissionsVersion(ctx context.Context, userID string) (int64, error) {
+	tag := userCacheTag(userID)
+	versions, err := rbac.versions.Versions(ctx, []string{tag})
+	if err != nil {
+		return 0, err
 	}
+	return versions[tag], nil
+}
 
-	return nil
+// InvalidateUsers 角色来源于 RBAC 之外（如用户表的 role）的变更后调用，
+// 与 RBAC 内部的变更相同，递增权限版本、清除缓存并发布 TopicUserPermissionsChanged
+func (rbac *RBAC) InvalidateUsers(ctx context.Context, userIDs ...string) {
+	rbac.clearUserCache(ctx, userIDs...)
 }
 
-// clearUserCache 清除用户相关缓存
-func (rbac *RBAC) clearUserCache(userID string) {
-	// 清除角色缓存
-	rbac.cache.Delete(context.Background(), fmt.Sprintf("user_role:%s", userID))
+// generation 用户当前的缓存代数，读取失败时返回 false，调用方应绕过缓存
+func (rbac *RBAC) generation(ctx context.Context, userID string) (int64, bool) {
+	generation, err := rbac.PermissionsVersion(ctx, userID)
+	if err != nil {
+		return 0, false
+	}
+	return generation, true
+}
 
-	// 清除权限缓存
-	for _, perm := range rbac.rolePermissions[rbac.userRoles[userID]] {
-		rbac.cache.Delete(context.Background(), fmt.Sprintf("user_permission:%s:%s", userID, perm))
+// userCacheTag 用户权限缓存的标签，同时是该用户全部缓存键的前缀
+func userCacheTag(userID string) string {
+	return "rbac:user:" + userID
+}
+
+// cachedLookup 读穿缓存：先取用户的缓存代数，再由 load 读取内存状态，结果以读到的代数为键写入
+func cachedLookup[T any](ctx context.Context, rbac *RBAC, userID, name string, load func() (T, error)) (T, error) {
+	generation, ok := rbac.generation(ctx, userID)
+	cacheKey := fmt.Sprintf("%s:g%d:%s", userCacheTag(userID), generation, name)
+	if ok {
+		var cached T
+		if err := rbac.cache.Get(ctx, cacheKey, &cached); err == nil {
+			return cached, nil
+		}
 	}
 
-	// 清除权限列表缓存
-	rbac.cache.Delete(context.Background(), fmt.Sprintf("user_permissions:%s", userID))
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if ok {
+		rbac.cache.Set(ctx, cacheKey, value, permissionCacheTTL)
+	}
+	return value, nil
 }
 
-// GetRolePermissions 获取角色权限
+// GetRolePermissions 获取角色的有效权限，包括继承的权限
 func (rbac *RBAC) GetRolePermissions(role Role) []Permission {
 	rbac.mu.RLock()
 	defer rbac.mu.RUnlock()
-	return rbac.rolePermissions[role]
+	permissions, _ := rbac.resolveLocked(role)
+	return permissions
 }
 
 // GetUsersByRole 获取拥有指定角色的用户
@@ -422,7 +560,7 @@ func (pm *PermissionMiddleware) Middleware() gin.HandlerFunc {
 		}
 
 		// 检查权限
-		hasPermission, err := pm.rbac.HasPermission(userID.(string), pm.required)
+		hasPermission, err := pm.rbac.HasPermission(c.Request.Context(), userID.(string), pm.required)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "permission check failed",
@@ -471,7 +609,7 @@ func (rm *RoleMiddleware) Middleware() gin.HandlerFunc {
 		}
 
 		// 检查角色
-		hasRole, err := rm.rbac.HasRole(userID.(string), rm.required)
+		hasRole, err := rm.rbac.HasRole(c.Request.Context(), userID.(string), rm.required)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "role check failed",
@@ -526,10 +664,10 @@ func (mpm *MultiPermissionMiddleware) Middleware() gin.HandlerFunc {
 
 		if mpm.requireAll {
 			// 需要所有权限
-			hasPermission, err = mpm.rbac.HasAllPermissions(userID.(string), mpm.required)
+			hasPermission, err = mpm.rbac.HasAllPermissions(c.Request.Context(), userID.(string), mpm.required)
 		} else {
 			// 需要任意权限
-			hasPermission, err = mpm.rbac.HasAnyPermission(userID.(string), mpm.required)
+			hasPermission, err = mpm.rbac.HasAnyPermission(c.Request.Context(), userID.(string), mpm.required)
 		}
 
 		if err != nil {
@@ -588,7 +726,7 @@ func (om *OwnershipMiddleware) Middleware() gin.HandlerFunc {
 		}
 
 		// 检查所有权（这里简化处理，实际应该检查数据库）
-		if !om.checkOwnership(userID.(string), resourceID, c.Request.URL.Path) {
+		if !om.checkOwnership(c.Request.Context(), userID.(string), resourceID, c.Request.URL.Path) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "access denied: you don't own this resource",
 			})
@@ -601,12 +739,12 @@ func (om *OwnershipMiddleware) Middleware() gin.HandlerFunc {
 }
 
 // checkOwnership 检查所有权
-func (om *OwnershipMiddleware) checkOwnership(userID, resourceID, path string) bool {
+func (om *OwnershipMiddleware) checkOwnership(ctx context.Context, userID, resourceID, path string) bool {
 	// 简化实现：用户只能访问自己的资源
 	// 实际项目中应该查询数据库验证所有权
 
 	// 如果是管理员，可以访问所有资源
-	if role, err := om.rbac.GetUserRole(userID); err == nil {
+	if role, err := om.rbac.GetUserRole(ctx, userID); err == nil {
 		if role == RoleAdmin || role == RoleSuperAdmin {
 			return true
 		}
@@ -626,10 +764,14 @@ func CheckResourceOwnership(userID string, resource ResourceOwner) bool {
 	return resource.GetOwnerID() == userID
 }
 
-// PolicyEngine 策略引擎
+// PolicyEngine 策略引擎。决定按用户、资源、动作与上下文短暂缓存，
+// 用户的角色、权限或策略变化时经 RBAC 的变更事件失效
 type PolicyEngine struct {
 	policies map[string]Policy
 	rbac     *RBAC
+	cache    *decisionCache
+	metrics  *metrics.MetricsCollector
+	mu       sync.RWMutex
 }
 
 // Policy 策略接口
@@ -654,27 +796,107 @@ const (
 	DecisionNotApplicable
 )
 
-// NewPolicyEngine 创建策略引擎
-func NewPolicyEngine(rbac *RBAC) *PolicyEngine {
-	return &PolicyEngine{
+// String 决定的名称，用于指标标签
+func (d PolicyDecision) String() string {
+	switch d {
+	case DecisionAllow:
+		return "allow"
+	case DecisionDeny:
+		return "deny"
+	default:
+		return "not_applicable"
+	}
+}
+
+// NewPolicyEngine 创建策略引擎，config 为 nil 时使用默认缓存配置
+func NewPolicyEngine(rbac *RBAC, config *PolicyCacheConfig) *PolicyEngine {
+	if config == nil {
+		config = DefaultPolicyCacheConfig()
+	}
+	pe := &PolicyEngine{
 		policies: make(map[string]Policy),
 		rbac:     rbac,
+		cache:    newDecisionCache(config),
+		metrics:  metrics.GetGlobalCollector(),
+	}
+
+	// 同步订阅：变更方返回前相关决定已失效
+	if err := rbac.Changes().Subscribe(fmt.Sprintf("policy-cache-%p", pe), func(ctx context.Context, event events.Event[PermissionChange]) error {
+		switch event.Topic {
+		case TopicUserPermissionsChanged:
+			pe.cache.invalidateUsers(event.Payload.UserIDs...)
+		case TopicPolicyChanged:
+			pe.cache.invalidateAll()
+		}
+		return nil
+	}); err != nil {
+		log.Printf("Failed to subscribe policy cache to permission changes: %v", err)
 	}
+	return pe
 }
 
-// AddPolicy 添加策略
+// AddPolicy 添加或替换策略，已缓存的决定全部失效
 func (pe *PolicyEngine) AddPolicy(name string, policy Policy) {
+	pe.mu.Lock()
 	pe.policies[name] = policy
+	pe.mu.Unlock()
+	pe.publishPolicyChange(name)
 }
 
-// Evaluate 评估策略
+// RemovePolicy 移除策略，已缓存的决定全部失效
+func (pe *PolicyEngine) RemovePolicy(name string) {
+	pe.mu.Lock()
+	delete(pe.policies, name)
+	pe.mu.Unlock()
+	pe.publishPolicyChange(name)
+}
+
+func (pe *PolicyEngine) publishPolicyChange(name string) {
+	if err := pe.rbac.Changes().Publish(context.Background(), TopicPolicyChanged, "", PermissionChange{Policy: name}); err != nil {
+		// 事件未送达时直接失效本引擎的缓存
+		log.Printf("Failed to publish policy change: %v", err)
+		pe.cache.invalidateAll()
+	}
+}
+
+// CacheStats 决定缓存的命中统计
+func (pe *PolicyEngine) CacheStats() PolicyCacheStats {
+	return pe.cache.stats()
+}
+
+// Evaluate 评估策略，命中缓存时直接返回缓存的决定；出错的评估不缓存
 func (pe *PolicyEngine) Evaluate(ctx context.Context, request PolicyRequest) (PolicyDecision, error) {
+	start := time.Now()
+
+	key, cacheable := decisionKey(request)
+	if cacheable {
+		if decision, ok := pe.cache.get(key, request.UserID); ok {
+			pe.metrics.RecordPolicyDecision(decision.String(), "cache", time.Since(start))
+			return decision, nil
+		}
+	}
+
+	userGeneration, policyGeneration := pe.cache.generations(request.UserID)
+	decision, err := pe.evaluate(ctx, request)
+	if err != nil {
+		pe.metrics.RecordPolicyDecision("error", "evaluate", time.Since(start))
+		return decision, err
+	}
+	if cacheable {
+		pe.cache.set(key, decision, userGeneration, policyGeneration)
+	}
+	pe.metrics.RecordPolicyDecision(decision.String(), "evaluate", time.Since(start))
+	return decision, nil
+}
+
+// evaluate 依次检查 RBAC 与自定义策略
+func (pe *PolicyEngine) evaluate(ctx context.Context, request PolicyRequest) (PolicyDecision, error) {
 	// 优先检查 RBAC
 	if request.Action != "" {
 		// 将动作映射为权限
 		permission := mapActionToPermission(request.Action)
 		if permission != "" {
-			hasPermission, err := pe.rbac.HasPermission(request.UserID, permission)
+			hasPermission, err := pe.rbac.HasPermission(ctx, request.UserID, permission)
 			if err != nil {
 				return DecisionDeny, err
 			}
@@ -684,8 +906,15 @@ func (pe *PolicyEngine) Evaluate(ctx context.Context, request PolicyRequest) (Po
 		}
 	}
 
-	// 检查自定义策略
+	pe.mu.RLock()
+	policies := make([]Policy, 0, len(pe.policies))
 	for _, policy := range pe.policies {
+		policies = append(policies, policy)
+	}
+	pe.mu.RUnlock()
+
+	// 检查自定义策略
+	for _, policy := range policies {
 		decision, err := policy.Evaluate(ctx, request)
 		if err != nil {
 			return DecisionDeny, err
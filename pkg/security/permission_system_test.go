@@ -0,0 +1,269 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"user_crud_jwt/internal/pkg/config"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRBAC_AssignRoleInvalidatesPreviousRole 降级后不再命中旧角色的权限缓存
+func TestRBAC_AssignRoleInvalidatesPreviousRole(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleAdmin))
+	has, err := rbac.HasPermission(ctx, "u1", PermissionAdminRead)
+	require.NoError(t, err)
+	require.True(t, has)
+	isAdmin, err := rbac.HasRole(ctx, "u1", RoleAdmin)
+	require.NoError(t, err)
+	require.True(t, isAdmin)
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+
+	has, err = rbac.HasPermission(ctx, "u1", PermissionAdminRead)
+	require.NoError(t, err)
+	assert.False(t, has)
+	isAdmin, err = rbac.HasRole(ctx, "u1", RoleAdmin)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+	role, err := rbac.GetUserRole(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, RoleUser, role)
+}
+
+// TestRBAC_RolePermissionChangesInvalidateUsers 角色增减权限后，拥有该角色的用户立即生效
+func TestRBAC_RolePermissionChangesInvalidateUsers(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleModerator))
+	require.NoError(t, rbac.AssignRole(ctx, "u2", RoleUser))
+
+	has, err := rbac.HasPermission(ctx, "u1", PermissionCouponDelete)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	require.NoError(t, rbac.RemovePermissionFromRole(ctx, RoleModerator, PermissionCouponDelete))
+	has, err = rbac.HasPermission(ctx, "u1", PermissionCouponDelete)
+	require.NoError(t, err)
+	assert.False(t, has)
+	perms, err := rbac.GetUserPermissions(ctx, "u1")
+	require.NoError(t, err)
+	assert.NotContains(t, perms, PermissionCouponDelete)
+
+	has, err = rbac.HasPermission(ctx, "u1", PermissionSystemMonitor)
+	require.NoError(t, err)
+	require.False(t, has)
+	require.NoError(t, rbac.AddPermissionToRole(ctx, RoleModerator, PermissionSystemMonitor))
+	has, err = rbac.HasPermission(ctx, "u1", PermissionSystemMonitor)
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	// 其他角色的用户不受影响
+	has, err = rbac.HasPermission(ctx, "u2", PermissionSystemMonitor)
+	require.NoError(t, err)
+	assert.False(t, has)
+}
+
+// TestRBAC_WritePermissionIsPerUser 单独授予的权限立即生效，且不影响同角色的其他用户
+func TestRBAC_WritePermissionIsPerUser(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+	require.NoError(t, rbac.AssignRole(ctx, "u2", RoleUser))
+	has, err := rbac.HasPermission(ctx, "u1", PermissionPaymentRead)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	require.NoError(t, NewPermissionWrite(rbac).WritePermission(ctx, "u1", PermissionPaymentRead))
+
+	has, err = rbac.HasPermission(ctx, "u1", PermissionPaymentRead)
+	require.NoError(t, err)
+	assert.True(t, has)
+	has, err = rbac.HasPermission(ctx, "u2", PermissionPaymentRead)
+	require.NoError(t, err)
+	assert.False(t, has)
+	assert.NotContains(t, rbac.GetRolePermissions(RoleUser), PermissionPaymentRead)
+}
+
+// TestRBAC_RevokeRoleKeepsGrants 取消角色后单独授予的权限保留，撤销授权后立即失效
+func TestRBAC_RevokeRoleKeepsGrants(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleAdmin))
+	require.NoError(t, rbac.GrantPermission(ctx, "u1", PermissionPaymentRead))
+	has, err := rbac.HasPermission(ctx, "u1", PermissionAdminRead)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	require.NoError(t, rbac.RevokeRole(ctx, "u1"))
+	has, err = rbac.HasPermission(ctx, "u1", PermissionAdminRead)
+	require.NoError(t, err)
+	assert.False(t, has)
+	has, err = rbac.HasPermission(ctx, "u1", PermissionPaymentRead)
+	require.NoError(t, err)
+	assert.True(t, has)
+	_, err = rbac.GetUserRole(ctx, "u1")
+	assert.Error(t, err)
+
+	require.NoError(t, rbac.RevokePermission(ctx, "u1", PermissionPaymentRead))
+	has, err = rbac.HasPermission(ctx, "u1", PermissionPaymentRead)
+	require.NoError(t, err)
+	assert.False(t, has)
+	assert.Equal(t, UserAccess{UserID: "u1", Grants: []Permission{}, Permissions: []Permission{}}, rbac.GetUserAccess("u1"))
+
+	// 未授予的权限与没有角色的用户重复撤销不报错
+	require.NoError(t, rbac.RevokePermission(ctx, "u1", PermissionPaymentRead))
+	require.NoError(t, rbac.RevokeRole(ctx, "u1"))
+}
+
+// TestRBAC_StaleWriteAfterInvalidation 检查读到旧状态后、写入缓存前角色发生变化，旧结果不会被后续读取命中
+func TestRBAC_StaleWriteAfterInvalidation(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleAdmin))
+
+	stale, err := cachedLookup(ctx, rbac, "u1", "permission:"+string(PermissionAdminRead), func() (bool, error) {
+		// 读到管理员身份后，变更在结果写入缓存之前完成
+		require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+		return true, nil
+	})
+	require.NoError(t, err)
+	require.True(t, stale)
+
+	has, err := rbac.HasPermission(ctx, "u1", PermissionAdminRead)
+	require.NoError(t, err)
+	assert.False(t, has)
+}
+
+// TestRBAC_ConcurrentRoleChanges 并发检查与变更角色，结束后所有检查结果与最终角色一致
+func TestRBAC_ConcurrentRoleChanges(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	const numUsers = 20
+	const numReaders = 10
+	const numChanges = 50
+
+	for i := 0; i < numUsers; i++ {
+		require.NoError(t, rbac.AssignRole(ctx, fmt.Sprintf("u%d", i), RoleUser))
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < numReaders; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for i := 0; i < numUsers; i++ {
+					userID := fmt.Sprintf("u%d", i)
+					_, _ = rbac.HasPermission(ctx, userID, PermissionAdminRead)
+					_, _ = rbac.HasRole(ctx, userID, RoleAdmin)
+					_, _ = rbac.GetUserPermissions(ctx, userID)
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i := 0; i < numUsers; i++ {
+		writers.Add(1)
+		go func(userID string) {
+			defer writers.Done()
+			for j := 0; j < numChanges; j++ {
+				role := RoleUser
+				if j%2 == 0 {
+					role = RoleAdmin
+				}
+				_ = rbac.AssignRole(ctx, userID, role)
+			}
+		}(fmt.Sprintf("u%d", i))
+	}
+	writers.Wait()
+	close(stop)
+	wg.Wait()
+
+	// 最后一次变更为普通用户
+	for i := 0; i < numUsers; i++ {
+		userID := fmt.Sprintf("u%d", i)
+		has, err := rbac.HasPermission(ctx, userID, PermissionAdminRead)
+		require.NoError(t, err)
+		assert.False(t, has, "user %s still has admin permission", userID)
+		isAdmin, err := rbac.HasRole(ctx, userID, RoleAdmin)
+		require.NoError(t, err)
+		assert.False(t, isAdmin, "user %s still has admin role", userID)
+		perms, err := rbac.GetUserPermissions(ctx, userID)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, rbac.GetRolePermissions(RoleUser), perms)
+	}
+}
+
+// stubRoleSource 固定返回的用户角色
+type stubRoleSource struct {
+	role int
+	err  error
+}
+
+func (s *stubRoleSource) CurrentRole(ctx context.Context, userID string) (int, error) {
+	return s.role, s.err
+}
+
+// TestRBAC_PermissionChangesRefreshTokens 撤销角色后，此前签发的令牌在验证时按角色来源重新解析，重新签发的令牌不再落后；
+// 没有角色来源或解析失败时拒绝
+func TestRBAC_PermissionChangesRefreshTokens(t *testing.T) {
+	previous := config.GlobalConfig.JWT.Secret
+	config.GlobalConfig.JWT.Secret = "test-secret"
+	rbac := NewRBAC(cache.NewMemoryCache())
+	utils.SetPermissionVersions(rbac)
+	t.Cleanup(func() {
+		config.GlobalConfig.JWT.Secret = previous
+		utils.SetPermissionVersions(nil)
+		utils.SetRoleSource(nil)
+	})
+	ctx := context.Background()
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleAdmin))
+	token, _, err := utils.GenerateToken("u1", 1)
+	require.NoError(t, err)
+	claims, err := utils.ParseTokenContext(ctx, token)
+	require.NoError(t, err)
+	assert.False(t, claims.Refreshed)
+
+	require.NoError(t, rbac.RevokeRole(ctx, "u1"))
+	_, err = utils.ParseTokenContext(ctx, token)
+	assert.ErrorIs(t, err, utils.ErrPermissionsChanged)
+
+	utils.SetRoleSource(&stubRoleSource{err: errors.New("user u1 is not active")})
+	_, err = utils.ParseTokenContext(ctx, token)
+	assert.ErrorIs(t, err, utils.ErrPermissionsChanged)
+
+	utils.SetRoleSource(&stubRoleSource{role: 0})
+	claims, err = utils.ParseTokenContext(ctx, token)
+	require.NoError(t, err)
+	assert.True(t, claims.Refreshed)
+	assert.Equal(t, 0, claims.Role)
+
+	reissued, _, err := utils.ReissueToken(claims)
+	require.NoError(t, err)
+	claims, err = utils.ParseTokenContext(ctx, reissued)
+	require.NoError(t, err)
+	assert.False(t, claims.Refreshed)
+	assert.Equal(t, 0, claims.Role)
+}
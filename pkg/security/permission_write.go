@@ -1,5 +1,7 @@
 package security
 
+import "context"
+
 // PermissionWrite 权限写入操作
 type PermissionWrite struct {
 	rbac *RBAC
@@ -10,23 +12,7 @@ func NewPermissionWrite(rbac *RBAC) *PermissionWrite {
 	return &PermissionWrite{rbac: rbac}
 }
 
-// WritePermission 写入权限
-func (pw *PermissionWrite) WritePermission(userID string, permission Permission) error {
-	pw.rbac.mu.Lock()
-	defer pw.rbac.mu.Unlock()
-	
-	if pw.rbac.userPermissions == nil {
-		pw.rbac.userPermissions = make(map[string][]Permission)
-	}
-	
-	// 检查权限是否已存在
-	for _, p := range pw.rbac.userPermissions[userID] {
-		if p == permission {
-			return nil // 权限已存在
-		}
-	}
-	
-	// 添加新权限
-	pw.rbac.userPermissions[userID] = append(pw.rbac.userPermissions[userID], permission)
-	return nil
+// WritePermission 写入权限，单独授予用户，不随角色变化
+func (pw *PermissionWrite) WritePermission(ctx context.Context, userID string, permission Permission) error {
+	return pw.rbac.GrantPermission(ctx, userID, permission)
 }
@@ -0,0 +1,158 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PolicyCacheConfig 策略决定缓存配置
+type PolicyCacheConfig struct {
+	// TTL 决定的缓存时间。基于时间、位置等外部条件的策略在 TTL 内可能给出过期的结果，应保持较短
+	TTL time.Duration `json:"ttl"`
+	// MaxEntries 缓存条目上限，达到上限且没有过期条目可清理时不再写入
+	MaxEntries int `json:"max_entries"`
+}
+
+// DefaultPolicyCacheConfig 默认策略决定缓存配置
+func DefaultPolicyCacheConfig() *PolicyCacheConfig {
+	return &PolicyCacheConfig{
+		TTL:        5 * time.Second,
+		MaxEntries: 10000,
+	}
+}
+
+// PolicyCacheStats 策略决定缓存统计
+type PolicyCacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+	Entries int     `json:"entries"`
+}
+
+// decisionEntry 缓存的决定，记录写入时读到的代数，代数变化后条目失效
+type decisionEntry struct {
+	decision         PolicyDecision
+	userGeneration   uint64
+	policyGeneration uint64
+	expiresAt        time.Time
+}
+
+// decisionCache 进程内的策略决定缓存。
+// 与 RBAC 的权限缓存相同，求值前先读代数、写入时带上读到的代数：用户的角色或权限变化时递增该用户的代数，
+// 策略变化时递增全局代数，与变更并发写入的旧决定因代数不符不会被读取
+type decisionCache struct {
+	config *PolicyCacheConfig
+
+	mu               sync.Mutex
+	entries          map[string]decisionEntry
+	userGenerations  map[string]uint64
+	policyGeneration uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newDecisionCache(config *PolicyCacheConfig) *decisionCache {
+	return &decisionCache{
+		config:          config,
+		entries:         make(map[string]decisionEntry),
+		userGenerations: make(map[string]uint64),
+	}
+}
+
+// generations 用户与策略的当前代数，求值前读取
+func (dc *decisionCache) generations(userID string) (uint64, uint64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.userGenerations[userID], dc.policyGeneration
+}
+
+// get 读取未过期且代数一致的决定
+func (dc *decisionCache) get(key, userID string) (PolicyDecision, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	entry, exists := dc.entries[key]
+	if exists && time.Now().Before(entry.expiresAt) &&
+		entry.userGeneration == dc.userGenerations[userID] && entry.policyGeneration == dc.policyGeneration {
+		dc.hits.Add(1)
+		return entry.decision, true
+	}
+	if exists {
+		delete(dc.entries, key)
+	}
+	dc.misses.Add(1)
+	return DecisionNotApplicable, false
+}
+
+// set 以求值前读到的代数写入决定
+func (dc *decisionCache) set(key string, decision PolicyDecision, userGeneration, policyGeneration uint64) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	now := time.Now()
+	if len(dc.entries) >= dc.config.MaxEntries {
+		for k, entry := range dc.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(dc.entries, k)
+			}
+		}
+		if len(dc.entries) >= dc.config.MaxEntries {
+			return
+		}
+	}
+	dc.entries[key] = decisionEntry{
+		decision:         decision,
+		userGeneration:   userGeneration,
+		policyGeneration: policyGeneration,
+		expiresAt:        now.Add(dc.config.TTL),
+	}
+}
+
+// invalidateUsers 递增用户的代数，该用户已缓存的决定全部失效
+func (dc *decisionCache) invalidateUsers(userIDs ...string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	for _, userID := range userIDs {
+		dc.userGenerations[userID]++
+	}
+}
+
+// invalidateAll 递增策略代数，全部决定失效
+func (dc *decisionCache) invalidateAll() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.policyGeneration++
+	clear(dc.entries)
+}
+
+func (dc *decisionCache) stats() PolicyCacheStats {
+	dc.mu.Lock()
+	entries := len(dc.entries)
+	dc.mu.Unlock()
+
+	stats := PolicyCacheStats{
+		Hits:    dc.hits.Load(),
+		Misses:  dc.misses.Load(),
+		Entries: entries,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// decisionKey 决定的缓存键：用户、资源、动作与上下文的哈希。上下文无法编码时返回 false，不缓存
+func decisionKey(request PolicyRequest) (string, bool) {
+	// map 按键排序编码，相同上下文得到相同哈希
+	encoded, err := json.Marshal(request.Context)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(encoded)
+	return request.UserID + "\x00" + request.Resource + "\x00" + request.Action + "\x00" + hex.EncodeToString(sum[:16]), true
+}
@@ -0,0 +1,108 @@
+package security
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPolicy 记录求值次数的策略
+type countingPolicy struct {
+	decision PolicyDecision
+	calls    atomic.Int64
+}
+
+func (p *countingPolicy) Evaluate(ctx context.Context, request PolicyRequest) (PolicyDecision, error) {
+	p.calls.Add(1)
+	return p.decision, nil
+}
+
+// TestPolicyEngine_CachesDecisions 相同请求命中缓存，上下文不同时分别求值
+func TestPolicyEngine_CachesDecisions(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+
+	engine := NewPolicyEngine(rbac, nil)
+	policy := &countingPolicy{decision: DecisionAllow}
+	engine.AddPolicy("counting", policy)
+
+	request := PolicyRequest{UserID: "u1", Resource: "moment", Action: "read", Context: map[string]interface{}{"ip": "10.0.0.1"}}
+	for i := 0; i < 3; i++ {
+		decision, err := engine.Evaluate(ctx, request)
+		require.NoError(t, err)
+		assert.Equal(t, DecisionAllow, decision)
+	}
+	assert.EqualValues(t, 1, policy.calls.Load())
+
+	request.Context = map[string]interface{}{"ip": "10.0.0.2"}
+	_, err := engine.Evaluate(ctx, request)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, policy.calls.Load())
+
+	stats := engine.CacheStats()
+	assert.EqualValues(t, 2, stats.Hits)
+	assert.EqualValues(t, 2, stats.Misses)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+// TestPolicyEngine_InvalidatesOnChanges 用户角色或策略变化后缓存的决定立即失效，其他用户不受影响
+func TestPolicyEngine_InvalidatesOnChanges(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+	require.NoError(t, rbac.AssignRole(ctx, "u2", RoleUser))
+
+	engine := NewPolicyEngine(rbac, nil)
+	deleteRequest := PolicyRequest{UserID: "u1", Resource: "user", Action: "delete"}
+	decision, err := engine.Evaluate(ctx, deleteRequest)
+	require.NoError(t, err)
+	require.Equal(t, DecisionDeny, decision)
+	_, err = engine.Evaluate(ctx, PolicyRequest{UserID: "u2", Resource: "user", Action: "delete"})
+	require.NoError(t, err)
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleModerator))
+	decision, err = engine.Evaluate(ctx, deleteRequest)
+	require.NoError(t, err)
+	assert.Equal(t, DecisionAllow, decision)
+
+	// u2 的决定仍命中缓存
+	hits := engine.CacheStats().Hits
+	_, err = engine.Evaluate(ctx, PolicyRequest{UserID: "u2", Resource: "user", Action: "delete"})
+	require.NoError(t, err)
+	assert.Equal(t, hits+1, engine.CacheStats().Hits)
+
+	engine.AddPolicy("deny-all", &countingPolicy{decision: DecisionDeny})
+	decision, err = engine.Evaluate(ctx, deleteRequest)
+	require.NoError(t, err)
+	assert.Equal(t, DecisionDeny, decision)
+
+	engine.RemovePolicy("deny-all")
+	decision, err = engine.Evaluate(ctx, deleteRequest)
+	require.NoError(t, err)
+	assert.Equal(t, DecisionAllow, decision)
+}
+
+// TestPolicyEngine_DecisionExpires 超过 TTL 后重新求值
+func TestPolicyEngine_DecisionExpires(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+
+	engine := NewPolicyEngine(rbac, &PolicyCacheConfig{TTL: 20 * time.Millisecond, MaxEntries: 100})
+	policy := &countingPolicy{decision: DecisionAllow}
+	engine.AddPolicy("counting", policy)
+
+	request := PolicyRequest{UserID: "u1", Resource: "moment", Action: "read"}
+	_, err := engine.Evaluate(ctx, request)
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	_, err = engine.Evaluate(ctx, request)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, policy.calls.Load())
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/clock"
 )
 
 // RateLimiter 限流器接口
@@ -35,6 +36,7 @@ type Reservation struct {
 // TokenBucket 令牌桶限流器
 type TokenBucket struct {
 	cache  cache.CacheService
+	clock  clock.Clock
 	mu     sync.RWMutex
 	limits map[string]Limit
 }
@@ -43,10 +45,16 @@ type TokenBucket struct {
 func NewTokenBucket(cache cache.CacheService) *TokenBucket {
 	return &TokenBucket{
 		cache:  cache,
+		clock:  clock.Real,
 		limits: make(map[string]Limit),
 	}
 }
 
+// SetClock 替换时钟，测试中与 fakes.Cache 共用 fakes.Clock
+func (tb *TokenBucket) SetClock(c clock.Clock) {
+	tb.clock = clock.OrReal(c)
+}
+
 // Allow 检查是否允许请求
 func (tb *TokenBucket) Allow(ctx context.Context, key string) (bool, error) {
 	limit, err := tb.getLimit(key)
@@ -130,7 +138,7 @@ func (tb *TokenBucket) allowN(ctx context.Context, key string, limit Limit, n in
 	if err != nil {
 		// 首次访问，创建新的令牌桶
 		bucket.Tokens = float64(limit.Burst - n)
-		bucket.LastRefill = time.Now().Unix()
+		bucket.LastRefill = tb.clock.Now().Unix()
 
 		if n <= limit.Burst {
 			tb.cache.Set(ctx, cacheKey, bucket, limit.Window*2)
@@ -166,6 +174,7 @@ func (tb *TokenBucket) reserve(ctx context.Context, key string, limit Limit, n i
 // SlidingWindowLog 滑动窗口日志限流器
 type SlidingWindowLog struct {
 	cache cache.CacheService
+	clock clock.Clock
 	limit Limit
 }
 
@@ -173,10 +182,16 @@ type SlidingWindowLog struct {
 func NewSlidingWindowLog(cache cache.CacheService, limit Limit) *SlidingWindowLog {
 	return &SlidingWindowLog{
 		cache: cache,
+		clock: clock.Real,
 		limit: limit,
 	}
 }
 
+// SetClock 替换时钟，测试中与 fakes.Cache 共用 fakes.Clock
+func (swl *SlidingWindowLog) SetClock(c clock.Clock) {
+	swl.clock = clock.OrReal(c)
+}
+
 // Allow 检查是否允许请求
 func (swl *SlidingWindowLog) Allow(ctx context.Context, key string) (bool, error) {
 	return swl.AllowN(ctx, key, 1)
@@ -184,7 +199,7 @@ func (swl *SlidingWindowLog) Allow(ctx context.Context, key string) (bool, error
 
 // AllowN 检查是否允许 n 个请求
 func (swl *SlidingWindowLog) AllowN(ctx context.Context, key string, n int) (bool, error) {
-	now := time.Now()
+	now := swl.clock.Now()
 	windowStart := now.Add(-swl.limit.Window)
 
 	cacheKey := fmt.Sprintf("sliding_window:%s", key)
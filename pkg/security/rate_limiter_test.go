@@ -0,0 +1,37 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlidingWindowLog_WindowSlidesWithClock(t *testing.T) {
+	clock := fakes.NewClock(time.Time{})
+	limiter := NewSlidingWindowLog(fakes.NewCache(clock), Limit{Rate: 2, Window: 2 * time.Second})
+	limiter.SetClock(clock)
+	ctx := context.Background()
+
+	for i := 0; i < 4; i++ {
+		allowed, err := limiter.Allow(ctx, "ip:1")
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d", i)
+		clock.Advance(100 * time.Millisecond)
+	}
+	allowed, err := limiter.Allow(ctx, "ip:1")
+	require.NoError(t, err)
+	assert.False(t, allowed, "window is full")
+
+	// 第一个请求滑出窗口后腾出一个名额
+	clock.Advance(1650 * time.Millisecond)
+	allowed, err = limiter.Allow(ctx, "ip:1")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = limiter.Allow(ctx, "ip:1")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
@@ -0,0 +1,174 @@
+package security
+
+import (
+	"errors"
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleRequest 定义自定义角色请求
+type RoleRequest struct {
+	Parents     []Role       `json:"parents" binding:"max=10"`
+	Permissions []Permission `json:"permissions" binding:"max=100"`
+}
+
+// UserRoleRequest 为用户分配角色请求
+type UserRoleRequest struct {
+	Role Role `json:"role" binding:"required"`
+}
+
+// UserPermissionRequest 单独授予用户权限请求
+type UserPermissionRequest struct {
+	Permission Permission `json:"permission" binding:"required"`
+}
+
+// RoleHandler 角色与权限管理接口
+type RoleHandler struct {
+	rbac *RBAC
+}
+
+// NewRoleHandler 创建角色与权限管理接口
+func NewRoleHandler(rbac *RBAC) *RoleHandler {
+	return &RoleHandler{rbac: rbac}
+}
+
+// RegisterAdminRoutes 注册角色与权限管理路由，调用方需挂载管理员权限校验
+func (h *RoleHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/permissions", h.ListPermissions)
+	group.GET("/roles", h.ListRoles)
+	group.GET("/roles/:role", h.GetRole)
+	group.PUT("/roles/:role", h.DefineRole)
+	group.DELETE("/roles/:role", h.DeleteRole)
+	group.GET("/users/:id/access", h.GetUserAccess)
+	group.PUT("/users/:id/role", h.AssignUserRole)
+	group.DELETE("/users/:id/role", h.RevokeUserRole)
+	group.POST("/users/:id/permissions", h.GrantUserPermission)
+	group.DELETE("/users/:id/permissions/:permission", h.RevokeUserPermission)
+}
+
+// ListPermissions 已注册的权限，可按 module 过滤，供管理后台选择可授予的权限
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"permissions": ListPermissions(c.Query("module")),
+	})
+}
+
+// ListRoles 全部角色及其继承关系与有效权限
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.rbac.ListRoles()
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"roles": roles,
+	})
+}
+
+// GetRole 角色定义
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, err := h.rbac.GetRole(Role(c.Param("role")))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+// DefineRole 定义或修改自定义角色
+func (h *RoleHandler) DefineRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	role := Role(c.Param("role"))
+	if err := h.rbac.DefineRole(c.Request.Context(), role, req.Parents, req.Permissions); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	info, err := h.rbac.GetRole(role)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// DeleteRole 删除自定义角色
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.rbac.DeleteRole(c.Request.Context(), Role(c.Param("role"))); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"role":    c.Param("role"),
+		"deleted": true,
+	})
+}
+
+// GetUserAccess 用户的角色、单独授予的权限与有效权限规则
+func (h *RoleHandler) GetUserAccess(c *gin.Context) {
+	c.JSON(http.StatusOK, h.rbac.GetUserAccess(c.Param("id")))
+}
+
+// AssignUserRole 为用户分配角色，替换原有角色
+func (h *RoleHandler) AssignUserRole(c *gin.Context) {
+	var req UserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if err := h.rbac.AssignRole(c.Request.Context(), c.Param("id"), req.Role); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.rbac.GetUserAccess(c.Param("id")))
+}
+
+// RevokeUserRole 取消用户的角色
+func (h *RoleHandler) RevokeUserRole(c *gin.Context) {
+	if err := h.rbac.RevokeRole(c.Request.Context(), c.Param("id")); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.rbac.GetUserAccess(c.Param("id")))
+}
+
+// GrantUserPermission 单独授予用户权限
+func (h *RoleHandler) GrantUserPermission(c *gin.Context) {
+	var req UserPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if err := h.rbac.GrantPermission(c.Request.Context(), c.Param("id"), req.Permission); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.rbac.GetUserAccess(c.Param("id")))
+}
+
+// RevokeUserPermission 撤销单独授予用户的权限
+func (h *RoleHandler) RevokeUserPermission(c *gin.Context) {
+	if err := h.rbac.RevokePermission(c.Request.Context(), c.Param("id"), Permission(c.Param("permission"))); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.rbac.GetUserAccess(c.Param("id")))
+}
+
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrRoleNotFound):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeNotFound, err.Error()))
+	case errors.Is(err, ErrRoleInUse), errors.Is(err, ErrBuiltinRole):
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeConflict, err.Error()))
+	default:
+		// 角色名不合法、循环继承、权限未注册
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, err.Error()))
+	}
+}
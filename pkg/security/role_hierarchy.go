@@ -0,0 +1,261 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrRoleNotFound 角色不存在
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleCycle 角色继承存在循环
+	ErrRoleCycle = errors.New("role inheritance cycle")
+	// ErrBuiltinRole 内置角色的继承关系不可修改、不可删除
+	ErrBuiltinRole = errors.New("built-in role cannot be redefined")
+	// ErrRoleInUse 角色仍被用户使用或被其他角色继承
+	ErrRoleInUse = errors.New("role is in use")
+)
+
+// builtinRoles 内置角色
+var builtinRoles = []Role{RoleUser, RoleModerator, RoleAdmin, RoleSuperAdmin}
+
+// validRolePattern 自定义角色名只接受小写字母、数字与下划线
+var validRolePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,63}$`)
+
+// RoleInfo 角色定义
+type RoleInfo struct {
+	Name        Role         `json:"name"`
+	Parents     []Role       `json:"parents"`
+	Permissions []Permission `json:"permissions"` // 直接拥有的权限
+	Effective   []Permission `json:"effective"`   // 包括继承的全部权限
+	BuiltIn     bool         `json:"built_in"`
+}
+
+// DefineRole 定义或修改自定义角色，parents 为继承的角色，可以是内置角色或其他自定义角色。
+// 修改后拥有该角色及继承它的角色的用户权限立即更新。权限须已注册
+func (rbac *RBAC) DefineRole(ctx context.Context, role Role, parents []Role, permissions []Permission) error {
+	if !validRolePattern.MatchString(string(role)) {
+		return fmt.Errorf("invalid role name: %q", role)
+	}
+	if slices.Contains(builtinRoles, role) {
+		return fmt.Errorf("%w: %s", ErrBuiltinRole, role)
+	}
+	if err := ValidatePermissions(permissions...); err != nil {
+		return err
+	}
+
+	rbac.mu.Lock()
+	for _, parent := range parents {
+		if !rbac.roleExistsLocked(parent) {
+			rbac.mu.Unlock()
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, parent)
+		}
+	}
+
+	previousParents, existed := rbac.roleParents[role]
+	previousPermissions := rbac.rolePermissions[role]
+	rbac.roleParents[role] = dedupe(parents)
+	rbac.rolePermissions[role] = dedupe(permissions)
+
+	// 修改已有角色的继承关系可能形成循环，检查所有继承它的角色
+	for _, other := range rbac.rolesLocked() {
+		if _, err := rbac.resolveLocked(other); err != nil {
+			if existed {
+				rbac.roleParents[role] = previousParents
+				rbac.rolePermissions[role] = previousPermissions
+			} else {
+				delete(rbac.roleParents, role)
+				delete(rbac.rolePermissions, role)
+			}
+			rbac.mu.Unlock()
+			return err
+		}
+	}
+
+	affected := rbac.syncRoleUsersLocked(role)
+	rbac.mu.Unlock()
+
+	rbac.clearUserCache(ctx, affected...)
+	return nil
+}
+
+// DeleteRole 删除自定义角色，仍有用户或其他角色使用时拒绝
+func (rbac *RBAC) DeleteRole(ctx context.Context, role Role) error {
+	if slices.Contains(builtinRoles, role) {
+		return fmt.Errorf("%w: %s", ErrBuiltinRole, role)
+	}
+
+	rbac.mu.Lock()
+	defer rbac.mu.Unlock()
+
+	if !rbac.roleExistsLocked(role) {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	for userID, userRole := range rbac.userRoles {
+		if userRole == role {
+			return fmt.Errorf("%w: assigned to user %s", ErrRoleInUse, userID)
+		}
+	}
+	for other, parents := range rbac.roleParents {
+		if slices.Contains(parents, role) {
+			return fmt.Errorf("%w: inherited by role %s", ErrRoleInUse, other)
+		}
+	}
+
+	delete(rbac.roleParents, role)
+	delete(rbac.rolePermissions, role)
+	return nil
+}
+
+// GetRole 角色定义
+func (rbac *RBAC) GetRole(role Role) (*RoleInfo, error) {
+	rbac.mu.RLock()
+	defer rbac.mu.RUnlock()
+
+	if !rbac.roleExistsLocked(role) {
+		return nil, fmt.Errorf("%w: %s", ErrRoleNotFound, role)
+	}
+	return rbac.roleInfoLocked(role)
+}
+
+// ListRoles 全部角色定义，按名称排序
+func (rbac *RBAC) ListRoles() ([]*RoleInfo, error) {
+	rbac.mu.RLock()
+	defer rbac.mu.RUnlock()
+
+	roles := rbac.rolesLocked()
+	infos := make([]*RoleInfo, 0, len(roles))
+	for _, role := range roles {
+		info, err := rbac.roleInfoLocked(role)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (rbac *RBAC) roleInfoLocked(role Role) (*RoleInfo, error) {
+	effective, err := rbac.resolveLocked(role)
+	if err != nil {
+		return nil, err
+	}
+	return &RoleInfo{
+		Name:        role,
+		Parents:     nonNilSlice(slices.Clone(rbac.roleParents[role])),
+		Permissions: nonNilSlice(slices.Clone(rbac.rolePermissions[role])),
+		Effective:   effective,
+		BuiltIn:     slices.Contains(builtinRoles, role),
+	}, nil
+}
+
+// resolveLocked 沿继承关系解析角色的全部权限，上游角色的权限在前。调用方需持有锁
+func (rbac *RBAC) resolveLocked(role Role) ([]Permission, error) {
+	permissions := []Permission{}
+	seen := make(map[Permission]bool)
+	visited := make(map[Role]bool)
+	var path []Role
+
+	var walk func(role Role) error
+	walk = func(role Role) error {
+		if slices.Contains(path, role) {
+			cycle := append(slices.Clone(path[slices.Index(path, role):]), role)
+			names := make([]string, len(cycle))
+			for i, r := range cycle {
+				names[i] = string(r)
+			}
+			return fmt.Errorf("%w: %s", ErrRoleCycle, strings.Join(names, " -> "))
+		}
+		// 菱形继承时共同的上游角色只展开一次
+		if visited[role] {
+			return nil
+		}
+
+		path = append(path, role)
+		for _, parent := range rbac.roleParents[role] {
+			if err := walk(parent); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		visited[role] = true
+
+		for _, permission := range rbac.rolePermissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(role); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// inheritsLocked role 是否为 ancestor 本身或继承自 ancestor。调用方需持有锁
+func (rbac *RBAC) inheritsLocked(role, ancestor Role) bool {
+	visited := make(map[Role]bool)
+	queue := []Role{role}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == ancestor {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		queue = append(queue, rbac.roleParents[current]...)
+	}
+	return false
+}
+
+func (rbac *RBAC) roleExistsLocked(role Role) bool {
+	_, hasPermissions := rbac.rolePermissions[role]
+	_, hasParents := rbac.roleParents[role]
+	return hasPermissions || hasParents
+}
+
+// rolesLocked 全部角色名，按名称排序
+func (rbac *RBAC) rolesLocked() []Role {
+	set := make(map[Role]bool)
+	for role := range rbac.rolePermissions {
+		set[role] = true
+	}
+	for role := range rbac.roleParents {
+		set[role] = true
+	}
+	roles := make([]Role, 0, len(set))
+	for role := range set {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles
+}
+
+// dedupe 去重并保持顺序，返回新切片
+func dedupe[T comparable](values []T) []T {
+	result := make([]T, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func nonNilSlice[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,87 @@
+package security
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRBAC_BuiltinHierarchy 内置角色逐级继承下级角色的权限
+func TestRBAC_BuiltinHierarchy(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	superAdmin := rbac.GetRolePermissions(RoleSuperAdmin)
+	for _, role := range []Role{RoleUser, RoleModerator, RoleAdmin} {
+		assert.Subset(t, superAdmin, rbac.GetRolePermissions(role), "super_admin should inherit %s", role)
+	}
+	assert.Contains(t, rbac.GetRolePermissions(RoleModerator), PermissionMomentRead)
+	assert.NotContains(t, rbac.GetRolePermissions(RoleModerator), PermissionAdminRead)
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleAdmin))
+	isModerator, err := rbac.HasRole(ctx, "u1", RoleModerator)
+	require.NoError(t, err)
+	assert.True(t, isModerator)
+	isSuperAdmin, err := rbac.HasRole(ctx, "u1", RoleSuperAdmin)
+	require.NoError(t, err)
+	assert.False(t, isSuperAdmin)
+
+	// 下级角色新增的权限传递给上级角色的用户
+	require.NoError(t, rbac.AddPermissionToRole(ctx, RoleUser, PermissionSystemMonitor))
+	has, err := rbac.HasPermission(ctx, "u1", PermissionSystemMonitor)
+	require.NoError(t, err)
+	assert.True(t, has)
+}
+
+// TestRBAC_CustomRole 自定义角色继承内置角色并追加权限
+func TestRBAC_CustomRole(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.DefineRole(ctx, "auditor", []Role{RoleModerator}, []Permission{PermissionSystemMonitor}))
+	require.NoError(t, rbac.AssignRole(ctx, "u1", "auditor"))
+
+	for _, permission := range []Permission{PermissionUserRead, PermissionPaymentRead, PermissionSystemMonitor} {
+		has, err := rbac.HasPermission(ctx, "u1", permission)
+		require.NoError(t, err)
+		assert.True(t, has, "auditor should have %s", permission)
+	}
+
+	// 修改自定义角色后用户权限立即更新
+	require.NoError(t, rbac.DefineRole(ctx, "auditor", []Role{RoleUser}, nil))
+	has, err := rbac.HasPermission(ctx, "u1", PermissionPaymentRead)
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	assert.ErrorIs(t, rbac.DefineRole(ctx, RoleAdmin, []Role{RoleUser}, nil), ErrBuiltinRole)
+	assert.ErrorIs(t, rbac.DefineRole(ctx, "ghost", []Role{"missing"}, nil), ErrRoleNotFound)
+	assert.ErrorIs(t, rbac.AssignRole(ctx, "u2", "missing"), ErrRoleNotFound)
+	assert.ErrorIs(t, rbac.DeleteRole(ctx, "auditor"), ErrRoleInUse)
+
+	require.NoError(t, rbac.AssignRole(ctx, "u1", RoleUser))
+	require.NoError(t, rbac.DeleteRole(ctx, "auditor"))
+	_, err = rbac.GetRole("auditor")
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+// TestRBAC_RoleCycleRejected 形成循环继承的定义被拒绝，原定义保持不变
+func TestRBAC_RoleCycleRejected(t *testing.T) {
+	rbac := NewRBAC(cache.NewMemoryCache())
+	ctx := context.Background()
+
+	require.NoError(t, rbac.DefineRole(ctx, "team_a", []Role{RoleUser}, nil))
+	require.NoError(t, rbac.DefineRole(ctx, "team_b", []Role{"team_a"}, nil))
+
+	err := rbac.DefineRole(ctx, "team_a", []Role{"team_b"}, nil)
+	assert.ErrorIs(t, err, ErrRoleCycle)
+	err = rbac.DefineRole(ctx, "team_b", []Role{"team_b"}, nil)
+	assert.ErrorIs(t, err, ErrRoleCycle)
+
+	info, err := rbac.GetRole("team_a")
+	require.NoError(t, err)
+	assert.Equal(t, []Role{RoleUser}, info.Parents)
+	assert.ElementsMatch(t, rbac.GetRolePermissions(RoleUser), info.Effective)
+}
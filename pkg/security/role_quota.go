@@ -0,0 +1,379 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/clock"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// RoleQuota 角色配额配置，JSON 中窗口以秒表示（window_seconds），与 UpdateQuota 的请求一致
+type RoleQuota struct {
+	Role      Role          `json:"role"`
+	Requests  int           `json:"requests"`  // 窗口内允许的请求数
+	Window    time.Duration `json:"-"`         // 统计窗口
+	Unlimited bool          `json:"unlimited"` // 是否不限流
+}
+
+// roleQuotaJSON RoleQuota 的 JSON 形式
+type roleQuotaJSON struct {
+	Role          Role  `json:"role"`
+	Requests      int   `json:"requests"`
+	WindowSeconds int64 `json:"window_seconds"`
+	Unlimited     bool  `json:"unlimited"`
+}
+
+// MarshalJSON 窗口输出为秒
+func (q RoleQuota) MarshalJSON() ([]byte, error) {
+	return json.Marshal(roleQuotaJSON{
+		Role:          q.Role,
+		Requests:      q.Requests,
+		WindowSeconds: int64(q.Window / time.Second),
+		Unlimited:     q.Unlimited,
+	})
+}
+
+// UnmarshalJSON 读取以秒表示的窗口
+func (q *RoleQuota) UnmarshalJSON(data []byte) error {
+	var raw roleQuotaJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*q = RoleQuota{
+		Role:      raw.Role,
+		Requests:  raw.Requests,
+		Window:    time.Duration(raw.WindowSeconds) * time.Second,
+		Unlimited: raw.Unlimited,
+	}
+	return nil
+}
+
+// QuotaStatus 配额状态
+type QuotaStatus struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Time
+	Unlimited bool
+}
+
+// QuotaCounter 固定窗口计数，多实例部署时应共享（见 NewRedisQuotaCounter）
+type QuotaCounter interface {
+	// Incr 计数加一，返回窗口内的计数与窗口剩余时长；窗口从第一次计数开始，window 后清零
+	Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
+
+// quotaScript KEYS: 计数键；ARGV: 窗口毫秒数。返回 {计数, 剩余毫秒数}。
+// 计数与设置过期在一个脚本中完成，并发的请求不会漏计，也不会留下没有过期时间的计数
+var quotaScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {count, ttl}
+`)
+
+// RedisQuotaCounter 基于 Redis 的配额计数
+type RedisQuotaCounter struct {
+	rdb *redis.Client
+}
+
+// NewRedisQuotaCounter 创建基于 Redis 的配额计数
+func NewRedisQuotaCounter(rdb *redis.Client) *RedisQuotaCounter {
+	return &RedisQuotaCounter{rdb: rdb}
+}
+
+func (q *RedisQuotaCounter) Incr(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	result, err := quotaScript.Run(ctx, q.rdb, []string{key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count quota: %w", err)
+	}
+	return result[0], time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// memoryQuotaCounter 进程内的配额计数，未设置 QuotaCounter 时使用，只对单实例有效
+type memoryQuotaCounter struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	windows map[string]memoryQuotaWindow
+}
+
+type memoryQuotaWindow struct {
+	count   int64
+	resetAt time.Time
+}
+
+func newMemoryQuotaCounter(c clock.Clock) *memoryQuotaCounter {
+	return &memoryQuotaCounter{clock: clock.OrReal(c), windows: make(map[string]memoryQuotaWindow)}
+}
+
+func (q *memoryQuotaCounter) Incr(_ context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+
+	entry, ok := q.windows[key]
+	if !ok || !now.Before(entry.resetAt) {
+		// 新窗口开始时顺带清理已过期的计数，避免长期运行时无限增长
+		for k, w := range q.windows {
+			if !now.Before(w.resetAt) {
+				delete(q.windows, k)
+			}
+		}
+		entry = memoryQuotaWindow{resetAt: now.Add(window)}
+	}
+	entry.count++
+	q.windows[key] = entry
+	return entry.count, entry.resetAt.Sub(now), nil
+}
+
+// DefaultRoleQuotas 默认角色配额
+func DefaultRoleQuotas() map[Role]RoleQuota {
+	return map[Role]RoleQuota{
+		RoleUser:       {Role: RoleUser, Requests: 100, Window: time.Minute},
+		RoleModerator:  {Role: RoleModerator, Requests: 500, Window: time.Minute},
+		RoleAdmin:      {Role: RoleAdmin, Unlimited: true},
+		RoleSuperAdmin: {Role: RoleSuperAdmin, Unlimited: true},
+	}
+}
+
+// RoleQuotaManager 基于角色的配额管理器
+type RoleQuotaManager struct {
+	counter      QuotaCounter
+	rbac         *RBAC
+	clock        clock.Clock
+	quotas       map[Role]RoleQuota
+	defaultQuota RoleQuota
+	mu           sync.RWMutex
+}
+
+// NewRoleQuotaManager 创建角色配额管理器。计数默认保存在进程内，多实例部署时应以 SetCounter 设置共享的计数
+func NewRoleQuotaManager(rbac *RBAC) *RoleQuotaManager {
+	return &RoleQuotaManager{
+		counter:      newMemoryQuotaCounter(nil),
+		rbac:         rbac,
+		clock:        clock.OrReal(nil),
+		quotas:       DefaultRoleQuotas(),
+		defaultQuota: RoleQuota{Requests: 60, Window: time.Minute},
+	}
+}
+
+// SetCounter 设置配额计数，如 NewRedisQuotaCounter
+func (rqm *RoleQuotaManager) SetCounter(counter QuotaCounter) {
+	rqm.counter = counter
+}
+
+// SetClock 替换时钟，仅用于测试；进程内计数同时改用该时钟
+func (rqm *RoleQuotaManager) SetClock(c clock.Clock) {
+	rqm.clock = clock.OrReal(c)
+	if _, ok := rqm.counter.(*memoryQuotaCounter); ok {
+		rqm.counter = newMemoryQuotaCounter(c)
+	}
+}
+
+// GetQuota 获取角色配额，未配置的角色使用默认配额
+func (rqm *RoleQuotaManager) GetQuota(role Role) RoleQuota {
+	rqm.mu.RLock()
+	defer rqm.mu.RUnlock()
+
+	if quota, exists := rqm.quotas[role]; exists {
+		return quota
+	}
+
+	quota := rqm.defaultQuota
+	quota.Role = role
+	return quota
+}
+
+// SetQuota 运行时调整角色配额
+func (rqm *RoleQuotaManager) SetQuota(quota RoleQuota) error {
+	if quota.Role == "" {
+		return fmt.Errorf("role is required")
+	}
+	if !quota.Unlimited {
+		if quota.Requests <= 0 {
+			return fmt.Errorf("requests must be positive: %d", quota.Requests)
+		}
+		if quota.Window <= 0 {
+			return fmt.Errorf("window must be positive: %s", quota.Window)
+		}
+	}
+
+	rqm.mu.Lock()
+	defer rqm.mu.Unlock()
+	rqm.quotas[quota.Role] = quota
+	return nil
+}
+
+// GetQuotas 获取所有角色配额
+func (rqm *RoleQuotaManager) GetQuotas() []RoleQuota {
+	rqm.mu.RLock()
+	defer rqm.mu.RUnlock()
+
+	quotas := make([]RoleQuota, 0, len(rqm.quotas))
+	for _, quota := range rqm.quotas {
+		quotas = append(quotas, quota)
+	}
+	return quotas
+}
+
+// ResolveRole 获取用户角色，未知用户按普通用户处理
+func (rqm *RoleQuotaManager) ResolveRole(ctx context.Context, userID string) Role {
+	if rqm.rbac == nil {
+		return RoleUser
+	}
+	role, err := rqm.rbac.GetUserRole(ctx, userID)
+	if err != nil || role == "" {
+		return RoleUser
+	}
+	return role
+}
+
+// Consume 消耗一次配额
+func (rqm *RoleQuotaManager) Consume(ctx context.Context, clientID string, role Role) (*QuotaStatus, error) {
+	quota := rqm.GetQuota(role)
+	if quota.Unlimited {
+		return &QuotaStatus{Allowed: true, Unlimited: true}, nil
+	}
+
+	// 窗口长度是键的一部分，运行时调整窗口后按新窗口重新计数
+	key := fmt.Sprintf("role_quota:%s:%d:%s", role, quota.Window.Milliseconds(), clientID)
+	count, remaining, err := rqm.counter.Incr(ctx, key, quota.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &QuotaStatus{
+		Allowed: count <= int64(quota.Requests),
+		Limit:   quota.Requests,
+		Reset:   rqm.clock.Now().Add(remaining),
+	}
+	if status.Allowed {
+		status.Remaining = quota.Requests - int(count)
+	}
+	return status, nil
+}
+
+// SetQuotaHeaders 设置配额响应头，Retry-After 按管理器的时钟计算
+func (rqm *RoleQuotaManager) SetQuotaHeaders(c *gin.Context, status *QuotaStatus) {
+	if status == nil || status.Unlimited {
+		return
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(status.Reset.Unix(), 10))
+	if !status.Allowed {
+		retryAfter := int(status.Reset.Sub(rqm.clock.Now()).Seconds())
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+	}
+}
+
+// Middleware 返回角色配额中间件，须挂在认证之后
+func (rqm *RoleQuotaManager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rqm.Enforce(c) {
+			c.Next()
+		}
+	}
+}
+
+// Enforce 已登录用户按角色配额计数，超出时中止请求返回 429 并返回 false；未登录的请求不计数。
+// 可作为 middleware.SetAuthPolicies 的策略在每次认证后执行
+func (rqm *RoleQuotaManager) Enforce(c *gin.Context) bool {
+	userID := c.GetString("userID")
+	if userID == "" {
+		return true
+	}
+
+	ctx := c.Request.Context()
+	status, err := rqm.Consume(ctx, fmt.Sprintf("user:%s", userID), rqm.ResolveRole(ctx, userID))
+	if err != nil {
+		// 配额存储异常时放行
+		log.Printf("Failed to check role quota for user %s: %v", userID, err)
+		return true
+	}
+
+	rqm.SetQuotaHeaders(c, status)
+	if !status.Allowed {
+		apperrors.Abort(c, apperrors.New(apperrors.CodeTooManyRequests, ""))
+		return false
+	}
+	return true
+}
+
+// RoleQuotaHandler 角色配额管理接口
+type RoleQuotaHandler struct {
+	manager *RoleQuotaManager
+}
+
+// NewRoleQuotaHandler 创建角色配额管理接口
+func NewRoleQuotaHandler(manager *RoleQuotaManager) *RoleQuotaHandler {
+	return &RoleQuotaHandler{manager: manager}
+}
+
+// updateQuotaRequest 更新配额请求
+type updateQuotaRequest struct {
+	Requests      int   `json:"requests"`
+	WindowSeconds int64 `json:"window_seconds"`
+	Unlimited     bool  `json:"unlimited"`
+}
+
+// RegisterAdminRoutes 注册管理路由，调用方需自行挂载管理员鉴权中间件
+func (h *RoleQuotaHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/rate-limit/tiers", h.ListQuotas)
+	group.GET("/rate-limit/tiers/:role", h.GetQuota)
+	group.PUT("/rate-limit/tiers/:role", h.UpdateQuota)
+}
+
+// ListQuotas 列出所有角色配额
+func (h *RoleQuotaHandler) ListQuotas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"tiers": h.manager.GetQuotas(),
+	})
+}
+
+// GetQuota 获取指定角色配额
+func (h *RoleQuotaHandler) GetQuota(c *gin.Context) {
+	c.JSON(http.StatusOK, h.manager.GetQuota(Role(c.Param("role"))))
+}
+
+// UpdateQuota 更新指定角色配额
+func (h *RoleQuotaHandler) UpdateQuota(c *gin.Context) {
+	var req updateQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+		})
+		return
+	}
+
+	quota := RoleQuota{
+		Role:      Role(c.Param("role")),
+		Requests:  req.Requests,
+		Window:    time.Duration(req.WindowSeconds) * time.Second,
+		Unlimited: req.Unlimited,
+	}
+	if err := h.manager.SetQuota(quota); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
@@ -0,0 +1,165 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoleQuotaManager_TierResolution 按 RBAC 角色选择配额：未分配角色的用户按普通用户处理，
+// 管理员不限流，未配置的角色使用默认配额
+func TestRoleQuotaManager_TierResolution(t *testing.T) {
+	ctx := context.Background()
+	rbac := NewRBAC(fakes.NewCache(nil))
+	require.NoError(t, rbac.AssignRole(ctx, "mod", RoleModerator))
+	require.NoError(t, rbac.AssignRole(ctx, "root", RoleAdmin))
+	rqm := NewRoleQuotaManager(rbac)
+
+	assert.Equal(t, RoleUser, rqm.ResolveRole(ctx, "stranger"))
+	assert.Equal(t, RoleModerator, rqm.ResolveRole(ctx, "mod"))
+	assert.Equal(t, RoleAdmin, rqm.ResolveRole(ctx, "root"))
+	assert.Equal(t, RoleUser, NewRoleQuotaManager(nil).ResolveRole(ctx, "mod"))
+
+	assert.Equal(t, 100, rqm.GetQuota(RoleUser).Requests)
+	assert.Equal(t, 500, rqm.GetQuota(RoleModerator).Requests)
+	assert.True(t, rqm.GetQuota(RoleAdmin).Unlimited)
+	fallback := rqm.GetQuota(Role("auditor"))
+	assert.Equal(t, Role("auditor"), fallback.Role)
+	assert.Equal(t, 60, fallback.Requests)
+
+	status, err := rqm.Consume(ctx, "user:root", RoleAdmin)
+	require.NoError(t, err)
+	assert.True(t, status.Allowed)
+	assert.True(t, status.Unlimited)
+
+	assert.Error(t, rqm.SetQuota(RoleQuota{Role: RoleUser, Requests: 0, Window: time.Minute}))
+	assert.Error(t, rqm.SetQuota(RoleQuota{Requests: 1, Window: time.Minute}))
+}
+
+// TestRoleQuotaManager_Exhaustion 窗口内超过配额后拒绝并返回 429 与重试时间，窗口结束后恢复；
+// 各用户分别计数
+func TestRoleQuotaManager_Exhaustion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clk := fakes.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	rqm := NewRoleQuotaManager(NewRBAC(fakes.NewCache(nil)))
+	rqm.SetClock(clk)
+	require.NoError(t, rqm.SetQuota(RoleQuota{Role: RoleUser, Requests: 2, Window: time.Minute}))
+
+	router := gin.New()
+	router.Use(apperrors.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-User"))
+	})
+	router.Use(rqm.Middleware())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-User", userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("u1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, request("u1").Code)
+
+	clk.Advance(15 * time.Second)
+	w = request("u1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "45", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, request("u2").Code, "quotas are counted per user")
+
+	clk.Advance(45 * time.Second)
+	assert.Equal(t, http.StatusOK, request("u1").Code)
+
+	// 未登录的请求不计入角色配额
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, request("").Code)
+	}
+}
+
+// quotaScriptStub 以 Hook 应答配额脚本，返回固定的计数与剩余时间
+type quotaScriptStub struct {
+	result []interface{}
+	args   []interface{}
+}
+
+func (s *quotaScriptStub) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, net.ErrClosed
+	}
+}
+
+func (s *quotaScriptStub) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if strings.ToLower(cmd.Name()) == "evalsha" {
+			s.args = cmd.Args()
+			cmd.(*redis.Cmd).SetVal(s.result)
+			return nil
+		}
+		return net.ErrClosed
+	}
+}
+
+func (s *quotaScriptStub) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return net.ErrClosed
+	}
+}
+
+// TestRedisQuotaCounter 计数在一个脚本中完成，返回脚本给出的计数与剩余时间
+func TestRedisQuotaCounter(t *testing.T) {
+	stub := &quotaScriptStub{result: []interface{}{int64(3), int64(1500)}}
+	client := redis.NewClient(&redis.Options{Addr: "stub:6379"})
+	client.AddHook(stub)
+	t.Cleanup(func() { client.Close() })
+
+	count, remaining, err := NewRedisQuotaCounter(client).Incr(context.Background(), "role_quota:k", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, 1500*time.Millisecond, remaining)
+	require.Len(t, stub.args, 5)
+	assert.Equal(t, quotaScript.Hash(), stub.args[1])
+	assert.Equal(t, "role_quota:k", stub.args[3])
+	assert.EqualValues(t, time.Minute.Milliseconds(), stub.args[4])
+}
+
+// TestRoleQuotaHandler_WindowSeconds 管理接口读写的窗口都以秒表示
+func TestRoleQuotaHandler_WindowSeconds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rqm := NewRoleQuotaManager(nil)
+	router := gin.New()
+	NewRoleQuotaHandler(rqm).RegisterAdminRoutes(router.Group("/admin"))
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/rate-limit/tiers/user", strings.NewReader(`{"requests":10,"window_seconds":30}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"role":"user","requests":10,"window_seconds":30,"unlimited":false}`, w.Body.String())
+	assert.Equal(t, 30*time.Second, rqm.GetQuota(RoleUser).Window)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/rate-limit/tiers/user", nil))
+	assert.JSONEq(t, `{"role":"user","requests":10,"window_seconds":30,"unlimited":false}`, w.Body.String())
+
+	var quota RoleQuota
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &quota))
+	assert.Equal(t, rqm.GetQuota(RoleUser), quota)
+}
@@ -0,0 +1,284 @@
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/database"
+)
+
+// SecurityEventFilter 安全事件查询条件，零值字段不参与过滤
+type SecurityEventFilter struct {
+	Type   SecurityEventType  `form:"type"`
+	Level  SecurityEventLevel `form:"level"`
+	UserID string             `form:"user_id"`
+	IP     string             `form:"ip"`
+	From   time.Time          `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     time.Time          `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// SecurityEventStore 安全事件的持久化存储
+type SecurityEventStore interface {
+	// InsertEvents 批量写入，ID 已存在的事件忽略
+	InsertEvents(ctx context.Context, events []SecurityEvent) error
+	// SearchEvents 按时间倒序分页查询，返回当前页与总数
+	SearchEvents(ctx context.Context, filter SecurityEventFilter, offset, limit int) ([]SecurityEvent, int64, error)
+	// ExportEvents 按时间倒序逐条回调全部匹配的事件，返回已回调的条数
+	ExportEvents(ctx context.Context, filter SecurityEventFilter, fn func(SecurityEvent) error) (int, error)
+	// SummarizeEvents 按类型、级别统计匹配的事件，并列出事件最多的 top 个 IP 与用户
+	SummarizeEvents(ctx context.Context, filter SecurityEventFilter, top int) (*SecurityEventSummary, error)
+}
+
+// SecurityEventCount 按某一维度分组的事件数
+type SecurityEventCount struct {
+	Name  string `json:"name" db:"name"`
+	Count int64  `json:"count" db:"count"`
+}
+
+// SecurityEventSummary 安全事件汇总，各分组按事件数降序
+type SecurityEventSummary struct {
+	From     time.Time            `json:"from"`
+	To       time.Time            `json:"to"`
+	Total    int64                `json:"total"`
+	ByType   []SecurityEventCount `json:"by_type"`
+	ByLevel  []SecurityEventCount `json:"by_level"`
+	TopIPs   []SecurityEventCount `json:"top_ips"`
+	TopUsers []SecurityEventCount `json:"top_users"`
+}
+
+// securityEventRow security_events 表的行
+type securityEventRow struct {
+	ID         string    `db:"id"`
+	Type       string    `db:"type"`
+	Level      string    `db:"level"`
+	OccurredAt time.Time `db:"occurred_at"`
+	Source     string    `db:"source"`
+	UserID     string    `db:"user_id"`
+	IP         string    `db:"ip"`
+	UserAgent  string    `db:"user_agent"`
+	Path       string    `db:"path"`
+	Method     string    `db:"method"`
+	Status     int       `db:"status"`
+	Message    string    `db:"message"`
+	RequestID  string    `db:"request_id"`
+	Details    []byte    `db:"details"`
+}
+
+func (r *securityEventRow) toEvent() (SecurityEvent, error) {
+	event := SecurityEvent{
+		ID:        r.ID,
+		Type:      SecurityEventType(r.Type),
+		Level:     SecurityEventLevel(r.Level),
+		Timestamp: r.OccurredAt,
+		Source:    r.Source,
+		UserID:    r.UserID,
+		IP:        r.IP,
+		UserAgent: r.UserAgent,
+		Path:      r.Path,
+		Method:    r.Method,
+		Status:    r.Status,
+		Message:   r.Message,
+		RequestID: r.RequestID,
+	}
+	if err := json.Unmarshal(r.Details, &event.Details); err != nil {
+		return event, fmt.Errorf("failed to decode security event details: %w", err)
+	}
+	return event, nil
+}
+
+const securityEventColumns = `id, type, level, occurred_at, source, user_id, ip, user_agent, path, method, status, message, request_id, details`
+
+// securityEventColumnCount 每行的绑定参数数量
+const securityEventColumnCount = 14
+
+// exportPageSize 导出时每次查询的行数
+const exportPageSize = 1000
+
+// SQLSecurityEventStore 基于 security_events 分区表的安全事件存储
+type SQLSecurityEventStore struct {
+	db *database.DB
+}
+
+// NewSQLSecurityEventStore 创建安全事件存储
+func NewSQLSecurityEventStore(db *database.DB) *SQLSecurityEventStore {
+	return &SQLSecurityEventStore{db: db}
+}
+
+// InsertEvents 以单条多行 INSERT 写入一批事件
+func (s *SQLSecurityEventStore) InsertEvents(ctx context.Context, events []SecurityEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(events))
+	args := make([]interface{}, 0, len(events)*securityEventColumnCount)
+	for i, event := range events {
+		details, err := json.Marshal(event.Details)
+		if err != nil || event.Details == nil {
+			details = []byte("{}")
+		}
+
+		values := make([]string, securityEventColumnCount)
+		for j := range values {
+			values[j] = fmt.Sprintf("$%d", i*securityEventColumnCount+j+1)
+		}
+		placeholders[i] = "(" + strings.Join(values, ", ") + ")"
+		args = append(args, event.ID, string(event.Type), string(event.Level), event.Timestamp,
+			event.Source, event.UserID, event.IP, event.UserAgent, event.Path, event.Method,
+			event.Status, event.Message, event.RequestID, details)
+	}
+
+	query := `INSERT INTO security_events (` + securityEventColumns + `) VALUES ` +
+		strings.Join(placeholders, ", ") + ` ON CONFLICT DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert security events: %w", err)
+	}
+	return nil
+}
+
+// SearchEvents 按时间倒序分页查询
+func (s *SQLSecurityEventStore) SearchEvents(ctx context.Context, filter SecurityEventFilter, offset, limit int) ([]SecurityEvent, int64, error) {
+	where, args := securityEventWhere(filter)
+
+	var total int64
+	if err := s.db.GetContext(ctx, &total, `SELECT COUNT(*) FROM security_events`+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count security events: %w", err)
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf(`SELECT %s FROM security_events%s ORDER BY occurred_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+		securityEventColumns, where, len(args)-1, len(args))
+	var rows []securityEventRow
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to search security events: %w", err)
+	}
+
+	events := make([]SecurityEvent, 0, len(rows))
+	for i := range rows {
+		event, err := rows[i].toEvent()
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, nil
+}
+
+// ExportEvents 按 (occurred_at, id) 键集分页读取，避免大偏移量扫描
+func (s *SQLSecurityEventStore) ExportEvents(ctx context.Context, filter SecurityEventFilter, fn func(SecurityEvent) error) (int, error) {
+	where, args := securityEventWhere(filter)
+	count := 0
+
+	var cursor *securityEventRow
+	for {
+		pageWhere, pageArgs := where, args
+		if cursor != nil {
+			pageArgs = append(append([]interface{}{}, args...), cursor.OccurredAt, cursor.ID)
+			pageWhere = andWhere(where, fmt.Sprintf("(occurred_at, id) < ($%d, $%d)", len(pageArgs)-1, len(pageArgs)))
+		}
+
+		var rows []securityEventRow
+		query := fmt.Sprintf(`SELECT %s FROM security_events%s ORDER BY occurred_at DESC, id DESC LIMIT %d`,
+			securityEventColumns, pageWhere, exportPageSize)
+		if err := s.db.SelectContext(ctx, &rows, query, pageArgs...); err != nil {
+			return count, fmt.Errorf("failed to export security events: %w", err)
+		}
+
+		for i := range rows {
+			event, err := rows[i].toEvent()
+			if err != nil {
+				return count, err
+			}
+			if err := fn(event); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(rows) < exportPageSize {
+			return count, nil
+		}
+		cursor = &rows[len(rows)-1]
+	}
+}
+
+// SummarizeEvents 每个维度一条 GROUP BY 查询，IP 与用户为空的事件不参与排名
+func (s *SQLSecurityEventStore) SummarizeEvents(ctx context.Context, filter SecurityEventFilter, top int) (*SecurityEventSummary, error) {
+	where, args := securityEventWhere(filter)
+	summary := &SecurityEventSummary{From: filter.From, To: filter.To}
+
+	groups := []struct {
+		column string
+		limit  int
+		dest   *[]SecurityEventCount
+	}{
+		{"type", 0, &summary.ByType},
+		{"level", 0, &summary.ByLevel},
+		{"ip", top, &summary.TopIPs},
+		{"user_id", top, &summary.TopUsers},
+	}
+	for _, group := range groups {
+		groupWhere := where
+		if group.limit > 0 {
+			groupWhere = andWhere(where, group.column+" <> ''")
+		}
+		query := fmt.Sprintf(`SELECT %s AS name, COUNT(*) AS count FROM security_events%s GROUP BY %s ORDER BY count DESC, name`,
+			group.column, groupWhere, group.column)
+		if group.limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", group.limit)
+		}
+		counts := []SecurityEventCount{}
+		if err := s.db.SelectContext(ctx, &counts, query, args...); err != nil {
+			return nil, fmt.Errorf("failed to summarize security events by %s: %w", group.column, err)
+		}
+		*group.dest = counts
+	}
+
+	for _, count := range summary.ByType {
+		summary.Total += count.Count
+	}
+	return summary, nil
+}
+
+// andWhere 在 WHERE 子句后追加条件
+func andWhere(where, condition string) string {
+	if where == "" {
+		return " WHERE " + condition
+	}
+	return where + " AND " + condition
+}
+
+// securityEventWhere 由查询条件构建 WHERE 子句与参数
+func securityEventWhere(filter SecurityEventFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Type != "" {
+		add("type = $%d", string(filter.Type))
+	}
+	if filter.Level != "" {
+		add("level = $%d", string(filter.Level))
+	}
+	if filter.UserID != "" {
+		add("user_id = $%d", filter.UserID)
+	}
+	if filter.IP != "" {
+		add("ip = $%d", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		add("occurred_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("occurred_at < $%d", filter.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
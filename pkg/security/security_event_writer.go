@@ -0,0 +1,130 @@
+package security
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/lifecycle"
+	"user_crud_jwt/pkg/metrics"
+)
+
+// EventPersistenceConfig 安全事件持久化配置
+type EventPersistenceConfig struct {
+	BatchSize     int           `json:"batch_size"`     // 攒够一批立即写入
+	FlushInterval time.Duration `json:"flush_interval"` // 不足一批时的最长等待
+	QueueSize     int           `json:"queue_size"`     // 待写入队列长度，满时丢弃并计入指标
+	WriteTimeout  time.Duration `json:"write_timeout"`
+}
+
+// DefaultEventPersistenceConfig 默认安全事件持久化配置
+func DefaultEventPersistenceConfig() *EventPersistenceConfig {
+	return &EventPersistenceConfig{
+		BatchSize:     200,
+		FlushInterval: time.Second,
+		QueueSize:     10000,
+		WriteTimeout:  10 * time.Second,
+	}
+}
+
+// eventWriter 异步批量写入安全事件，不阻塞记录事件的请求
+type eventWriter struct {
+	store            SecurityEventStore
+	config           *EventPersistenceConfig
+	metricsCollector *metrics.MetricsCollector
+	queue            chan SecurityEvent
+	done             chan struct{}
+	lifecycle        *lifecycle.Component
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newEventWriter(store SecurityEventStore, config *EventPersistenceConfig, metricsCollector *metrics.MetricsCollector) *eventWriter {
+	w := &eventWriter{
+		store:            store,
+		config:           config,
+		metricsCollector: metricsCollector,
+		queue:            make(chan SecurityEvent, config.QueueSize),
+		done:             make(chan struct{}),
+		lifecycle:        lifecycle.Register("security.event_writer"),
+	}
+	w.lifecycle.Go("run", w.run)
+	return w
+}
+
+// enqueue 非阻塞入队，持有读锁避免与 close 关闭队列竞争
+func (w *eventWriter) enqueue(event SecurityEvent) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.queue <- event:
+	default:
+		log.Printf("Security event queue is full, dropping event: %s", event.ID)
+		w.metricsCollector.RecordDBError("security_events", "dropped")
+	}
+}
+
+func (w *eventWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SecurityEvent, 0, w.config.BatchSize)
+	for {
+		select {
+		case event, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= w.config.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush 写入一批事件，失败时只记录日志与指标，不重试
+func (w *eventWriter) flush(batch []SecurityEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.config.WriteTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := w.store.InsertEvents(ctx, batch)
+	w.metricsCollector.RecordDBQuery("insert_batch", "security_events", time.Since(start), err == nil)
+	if err != nil {
+		log.Printf("Failed to persist %d security events: %v", len(batch), err)
+	}
+}
+
+// close 停止接收事件，等待队列中的事件写完
+func (w *eventWriter) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+	w.lifecycle.Closed()
+}
@@ -0,0 +1,89 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryEventStore 记录每批写入的事件
+type memoryEventStore struct {
+	mu      sync.Mutex
+	batches [][]SecurityEvent
+}
+
+func (s *memoryEventStore) InsertEvents(ctx context.Context, events []SecurityEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]SecurityEvent(nil), events...))
+	return nil
+}
+
+func (s *memoryEventStore) SearchEvents(ctx context.Context, filter SecurityEventFilter, offset, limit int) ([]SecurityEvent, int64, error) {
+	return nil, 0, nil
+}
+
+func (s *memoryEventStore) ExportEvents(ctx context.Context, filter SecurityEventFilter, fn func(SecurityEvent) error) (int, error) {
+	return 0, nil
+}
+
+func (s *memoryEventStore) SummarizeEvents(ctx context.Context, filter SecurityEventFilter, top int) (*SecurityEventSummary, error) {
+	return &SecurityEventSummary{}, nil
+}
+
+func (s *memoryEventStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+// TestSecurityMonitor_PersistsEventsInBatches 攒够一批立即写入，关闭时写入剩余事件
+func TestSecurityMonitor_PersistsEventsInBatches(t *testing.T) {
+	store := &memoryEventStore{}
+	monitor := NewSecurityMonitor(cache.NewMemoryCache(), metrics.GetGlobalCollector(), NewDefaultSecurityLogger())
+	monitor.EnablePersistence(store, &EventPersistenceConfig{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		QueueSize:     100,
+		WriteTimeout:  time.Second,
+	})
+
+	for i := 0; i < 25; i++ {
+		monitor.RecordEvent(context.Background(), SecurityEvent{
+			ID:    fmt.Sprintf("evt_%d", i),
+			Type:  EventLogin,
+			Level: LevelInfo,
+		})
+	}
+	require.Eventually(t, func() bool { return len(store.batchSizes()) == 2 }, time.Second, 10*time.Millisecond)
+
+	monitor.Close()
+	assert.Equal(t, []int{10, 10, 5}, store.batchSizes())
+
+	// 关闭后记录的事件不再写入
+	monitor.RecordEvent(context.Background(), SecurityEvent{Type: EventLogin, Level: LevelInfo})
+	assert.Len(t, store.batchSizes(), 3)
+}
+
+// TestSecurityEventWhere 查询条件按顺序编号参数
+func TestSecurityEventWhere(t *testing.T) {
+	where, args := securityEventWhere(SecurityEventFilter{})
+	assert.Empty(t, where)
+	assert.Empty(t, args)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	where, args = securityEventWhere(SecurityEventFilter{Type: EventForbidden, IP: "10.0.0.1", From: from})
+	assert.Equal(t, " WHERE type = $1 AND ip = $2 AND occurred_at >= $3", where)
+	assert.Equal(t, []interface{}{"forbidden", "10.0.0.1", from}, args)
+}
@@ -18,6 +18,8 @@ type SecurityMiddleware struct {
 	jwtSecurity    *JWTSecurity
 	rateLimiter    RateLimiter
 	inputFilter    *InputFilter
+	roleQuota      *RoleQuotaManager
+	waf            *WAF
 	metricsCollector *metrics.MetricsCollector
 }
 
@@ -61,15 +63,27 @@ func DefaultSecurityConfig() SecurityConfig {
 
 // NewSecurityMiddleware 创建安全中间件
 func NewSecurityMiddleware(config SecurityConfig, jwtSecurity *JWTSecurity, rateLimiter RateLimiter, inputFilter *InputFilter) *SecurityMiddleware {
+	waf, _ := NewWAF(nil)
 	return &SecurityMiddleware{
 		config:         config,
 		jwtSecurity:    jwtSecurity,
 		rateLimiter:    rateLimiter,
 		inputFilter:    inputFilter,
 		metricsCollector: metrics.GetGlobalCollector(),
+		waf:            waf,
 	}
 }
 
+// SetWAF 设置请求检查规则引擎，默认使用内置规则集
+func (sm *SecurityMiddleware) SetWAF(waf *WAF) {
+	sm.waf = waf
+}
+
+// SetRoleQuotaManager 设置角色配额管理器，已登录用户按角色配额限流
+func (sm *SecurityMiddleware) SetRoleQuotaManager(manager *RoleQuotaManager) {
+	sm.roleQuota = manager
+}
+
 // Middleware 返回 Gin 中间件
 func (sm *SecurityMiddleware) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -183,6 +197,11 @@ func (sm *SecurityMiddleware) handleCORS(c *gin.Context) {
 func (sm *SecurityMiddleware) checkRateLimit(c *gin.Context) bool {
 	// 获取客户端标识
 	clientID := sm.getClientID(c)
+
+	// 已登录用户按角色配额限流
+	if userID := c.GetString("userID"); userID != "" && sm.roleQuota != nil {
+		return sm.checkRoleQuota(c, clientID, userID)
+	}
 	
 	// 根据路径选择不同的限流策略
 	var key string
@@ -201,10 +220,10 @@ func (sm *SecurityMiddleware) checkRateLimit(c *gin.Context) bool {
 	}
 
 	// 设置限流配置
-	sm.rateLimiter.SetLimit(context.Background(), key, limit)
+	sm.rateLimiter.SetLimit(c.Request.Context(), key, limit)
 	
 	// 检查是否允许请求
-	allowed, err := sm.rateLimiter.Allow(context.Background(), key)
+	allowed, err := sm.rateLimiter.Allow(c.Request.Context(), key)
 	if err != nil {
 		// 记录错误但允许请求
 		sm.metricsCollector.RecordDBError("rate_limit", "check_error")
@@ -213,16 +232,33 @@ func (sm *SecurityMiddleware) checkRateLimit(c *gin.Context) bool {
 
 	if !allowed {
 		// 记录限流事件
-		sm.metricsCollector.RecordDBError("rate_limit", "blocked")
+		sm.metricsCollector.RecordEvent("rate_limit", "blocked", 1)
 	}
 
 	return allowed
 }
 
+// checkRoleQuota 检查角色配额
+func (sm *SecurityMiddleware) checkRoleQuota(c *gin.Context, clientID, userID string) bool {
+	status, err := sm.roleQuota.Consume(c.Request.Context(), clientID, sm.roleQuota.ResolveRole(c.Request.Context(), userID))
+	if err != nil {
+		// 记录错误但允许请求
+		sm.metricsCollector.RecordDBError("rate_limit", "check_error")
+		return true
+	}
+
+	sm.roleQuota.SetQuotaHeaders(c, status)
+	if !status.Allowed {
+		sm.metricsCollector.RecordEvent("rate_limit", "blocked", 1)
+	}
+
+	return status.Allowed
+}
+
 // getClientID 获取客户端标识
 func (sm *SecurityMiddleware) getClientID(c *gin.Context) string {
 	// 优先使用用户 ID
-	if userID := c.GetString("user_id"); userID != "" {
+	if userID := c.GetString("userID"); userID != "" {
 		return fmt.Sprintf("user:%s", userID)
 	}
 
@@ -280,39 +316,12 @@ func (sm *SecurityMiddleware) validateInput(c *gin.Context) {
 // logSecurityEvent 记录安全事件
 func (sm *SecurityMiddleware) logSecurityEvent(c *gin.Context) {
 	// 记录可疑的请求
-	if sm.isSuspiciousRequest(c) {
-		sm.metricsCollector.RecordDBError("security", "suspicious_request")
+	if sm.waf.Inspect(c).Matched() {
+		sm.metricsCollector.RecordEvent("security", "suspicious_request", 1)
 		// 这里可以添加日志记录或告警
 	}
 }
 
-// isSuspiciousRequest 检查是否为可疑请求
-func (sm *SecurityMiddleware) isSuspiciousRequest(c *gin.Context) bool {
-	// 检查 User-Agent
-	userAgent := c.GetHeader("User-Agent")
-	if userAgent == "" || strings.Contains(userAgent, "bot") || strings.Contains(userAgent, "scanner") {
-		return true
-	}
-
-	// 检查请求路径
-	path := c.Request.URL.Path
-	suspiciousPaths := []string{"/admin", "/config", "/system", "/debug"}
-	for _, suspiciousPath := range suspiciousPaths {
-		if strings.Contains(path, suspiciousPath) {
-			return true
-		}
-	}
-
-	// 检查请求参数
-	for key := range c.Request.URL.Query() {
-		if strings.Contains(key, "sql") || strings.Contains(key, "script") || strings.Contains(key, "alert") {
-			return true
-		}
-	}
-
-	return false
-}
-
 // IPWhitelistMiddleware IP 白名单中间件
 type IPWhitelistMiddleware struct {
 	allowedIPs map[string]bool
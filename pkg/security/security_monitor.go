@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
 	"sync"
 	"time"
+	"user_crud_jwt/pkg/apperrors"
 	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/ctxutil"
 	"user_crud_jwt/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
@@ -27,6 +28,7 @@ type SecurityEvent struct {
 	Method    string                 `json:"method"`
 	Status    int                    `json:"status"`
 	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id,omitempty"`
 	Details   map[string]interface{} `json:"details,omitempty"`
 }
 
@@ -47,6 +49,14 @@ const (
 	EventForbidden        SecurityEventType = "forbidden"
 	EventInputValidation  SecurityEventType = "input_validation"
 	EventPermissionDenied SecurityEventType = "permission_denied"
+	EventAdminAction      SecurityEventType = "admin_action" // 管理接口上的修改操作，见 AdminAuditMiddleware
+	EventSlowRequest      SecurityEventType = "slow_request"
+	// EventPerformanceAnomaly 慢请求或 goroutine 数超过阈值，details 中的 profile_url 指向自动采集的性能剖析，见 pkg/profiling
+	EventPerformanceAnomaly SecurityEventType = "performance_anomaly"
+	// EventDatabaseHealth 表或索引膨胀等数据库健康问题超过阈值，details 中带建议的维护语句，见 pkg/dbadmin
+	EventDatabaseHealth SecurityEventType = "database_health"
+	// EventTwoFactor 两步验证的失败、锁定、确认与关闭，details.action 为 failed、locked、confirmed 或 disabled，见 pkg/security/twofactor
+	EventTwoFactor SecurityEventType = "two_factor"
 )
 
 // SecurityEventLevel 安全事件级别
@@ -67,7 +77,12 @@ type SecurityMonitor struct {
 	mu               sync.RWMutex
 	alertThresholds  map[SecurityEventType]int
 	alertHandlers    []AlertHandler
-	logger           SecurityLogger
+	listeners        []SecurityEventListener
+	// waf 请求检查规则引擎，默认使用内置规则集
+	waf    *WAF
+	logger SecurityLogger
+	// writer 持久化写入器，未启用持久化时为 nil
+	writer *eventWriter
 }
 
 // AlertHandler 告警处理器接口
@@ -75,8 +90,14 @@ type AlertHandler interface {
 	Handle(event SecurityEvent) error
 }
 
+// SecurityEventListener 安全事件监听器，每个事件记录后同步调用
+type SecurityEventListener interface {
+	OnSecurityEvent(ctx context.Context, event SecurityEvent)
+}
+
 // NewSecurityMonitor 创建安全监控器
 func NewSecurityMonitor(cache cache.CacheService, metricsCollector *metrics.MetricsCollector, logger SecurityLogger) *SecurityMonitor {
+	waf, _ := NewWAF(nil)
 	return &SecurityMonitor{
 		cache:            cache,
 		metricsCollector: metricsCollector,
@@ -86,14 +107,43 @@ func NewSecurityMonitor(cache cache.CacheService, metricsCollector *metrics.Metr
 			EventSuspicious:   5,  // 5次/分钟
 			EventUnauthorized: 20, // 20次/分钟
 			EventForbidden:    10, // 10次/分钟
+			// 每次自动剖析都告警，事件详情中带剖析链接
+			EventPerformanceAnomaly: 1,
 		},
 		alertHandlers: make([]AlertHandler, 0),
 		logger:        logger,
+		waf:           waf,
+	}
+}
+
+// SetWAF 替换请求检查规则引擎，须在处理请求之前调用
+func (sm *SecurityMonitor) SetWAF(waf *WAF) {
+	sm.waf = waf
+}
+
+// WAF 当前的请求检查规则引擎
+func (sm *SecurityMonitor) WAF() *WAF {
+	return sm.waf
+}
+
+// EnablePersistence 启用持久化：事件异步批量写入 store，内存中只保留最近的事件用于告警计数。
+// 须在记录事件之前调用，config 为 nil 时使用默认配置
+func (sm *SecurityMonitor) EnablePersistence(store SecurityEventStore, config *EventPersistenceConfig) {
+	if config == nil {
+		config = DefaultEventPersistenceConfig()
+	}
+	sm.writer = newEventWriter(store, config, sm.metricsCollector)
+}
+
+// Close 等待已记录的事件写入完成
+func (sm *SecurityMonitor) Close() {
+	if sm.writer != nil {
+		sm.writer.close()
 	}
 }
 
 // RecordEvent 记录安全事件
-func (sm *SecurityMonitor) RecordEvent(event SecurityEvent) {
+func (sm *SecurityMonitor) RecordEvent(ctx context.Context, event SecurityEvent) {
 	// 设置时间戳
 	if event.Timestamp.IsZero() {
 		event.Timestamp = time.Now()
@@ -104,6 +154,11 @@ func (sm *SecurityMonitor) RecordEvent(event SecurityEvent) {
 		event.ID = generateEventID()
 	}
 
+	// 关联请求 ID
+	if event.RequestID == "" {
+		event.RequestID = ctxutil.RequestID(ctx)
+	}
+
 	// 存储事件
 	sm.mu.Lock()
 	sm.events = append(sm.events, event)
@@ -114,8 +169,12 @@ func (sm *SecurityMonitor) RecordEvent(event SecurityEvent) {
 	}
 	sm.mu.Unlock()
 
-	// 记录到缓存
-	sm.cacheEvent(event)
+	// 启用持久化时批量写入数据库，否则逐条缓存
+	if sm.writer != nil {
+		sm.writer.enqueue(event)
+	} else {
+		sm.cacheEvent(ctx, event)
+	}
 
 	// 记录指标
 	sm.recordMetrics(event)
@@ -125,28 +184,21 @@ func (sm *SecurityMonitor) RecordEvent(event SecurityEvent) {
 
 	// 检查告警
 	sm.checkAlerts(event)
+
+	for _, listener := range sm.listeners {
+		listener.OnSecurityEvent(ctx, event)
+	}
 }
 
 // cacheEvent 缓存事件
-func (sm *SecurityMonitor) cacheEvent(event SecurityEvent) {
+func (sm *SecurityMonitor) cacheEvent(ctx context.Context, event SecurityEvent) {
 	cacheKey := fmt.Sprintf("security_event:%s", event.ID)
-	sm.cache.Set(context.Background(), cacheKey, event, time.Hour*24)
+	sm.cache.Set(ctx, cacheKey, event, time.Hour*24)
 }
 
 // recordMetrics 记录指标
 func (sm *SecurityMonitor) recordMetrics(event SecurityEvent) {
-	// 记录安全事件计数
-	sm.metricsCollector.RecordDBError("security_event", string(event.Type))
-
-	// 根据事件级别记录不同的指标
-	switch event.Level {
-	case LevelCritical:
-		sm.metricsCollector.RecordDBError("security_critical", string(event.Type))
-	case LevelError:
-		sm.metricsCollector.RecordDBError("security_error", string(event.Type))
-	case LevelWarning:
-		sm.metricsCollector.RecordDBError("security_warning", string(event.Type))
-	}
+	sm.metricsCollector.RecordSecurityEvent(string(event.Type), string(event.Level))
 }
 
 // logEvent 记录日志
@@ -172,6 +224,10 @@ func (sm *SecurityMonitor) logEvent(event SecurityEvent) {
 		logData["user_agent"] = event.UserAgent
 	}
 
+	if event.RequestID != "" {
+		logData["request_id"] = event.RequestID
+	}
+
 	if len(event.Details) > 0 {
 		logData["details"] = event.Details
 	}
@@ -274,6 +330,11 @@ func (sm *SecurityMonitor) AddAlertHandler(handler AlertHandler) {
 	sm.alertHandlers = append(sm.alertHandlers, handler)
 }
 
+// AddEventListener 添加事件监听器，须在记录事件之前调用
+func (sm *SecurityMonitor) AddEventListener(listener SecurityEventListener) {
+	sm.listeners = append(sm.listeners, listener)
+}
+
 // SetAlertThreshold 设置告警阈值
 func (sm *SecurityMonitor) SetAlertThreshold(eventType SecurityEventType, threshold int) {
 	sm.alertThresholds[eventType] = threshold
@@ -308,22 +369,37 @@ func NewSecurityMonitoringMiddleware(monitor *SecurityMonitor) *SecurityMonitori
 	return &SecurityMonitoringMiddleware{monitor: monitor}
 }
 
-// Middleware 返回中间件
+// Middleware 返回中间件：请求处理前按 WAF 规则检查，命中拦截或质询规则时中止请求
 func (smm *SecurityMonitoringMiddleware) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 在请求处理前记录
 		c.Set("security_start_time", time.Now())
 
+		waf := smm.monitor.waf
+		result := waf.Inspect(c)
+		switch result.Action {
+		case WAFActionBlock:
+			apperrors.Render(c, apperrors.New(apperrors.CodeRequestBlocked, ""))
+			c.Abort()
+		case WAFActionChallenge:
+			if !waf.challenger.Verify(c) {
+				waf.challenger.Challenge(c)
+			}
+		}
+
 		// 处理请求
-		c.Next()
+		rejected := c.IsAborted()
+		if !rejected {
+			c.Next()
+		}
 
 		// 在请求处理后检查安全事件
-		smm.checkSecurityEvents(c)
+		smm.checkSecurityEvents(c, result, rejected)
 	}
 }
 
-// checkSecurityEvents 检查安全事件
-func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
+// checkSecurityEvents 检查安全事件，被 WAF 拒绝的请求只记录规则命中事件
+func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context, waf WAFResult, rejected bool) {
 	startTime, exists := c.Get("security_start_time")
 	if !exists {
 		return
@@ -341,8 +417,11 @@ func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
 
 	// 检查不同的安全事件
 	switch {
+	case rejected:
+		// 拒绝原因已由下方的规则命中事件记录
+
 	case status == 401:
-		smm.monitor.RecordEvent(SecurityEvent{
+		smm.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
 			Type:      EventUnauthorized,
 			Level:     LevelWarning,
 			Source:    "api",
@@ -359,7 +438,7 @@ func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
 		})
 
 	case status == 403:
-		smm.monitor.RecordEvent(SecurityEvent{
+		smm.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
 			Type:      EventForbidden,
 			Level:     LevelWarning,
 			Source:    "api",
@@ -375,8 +454,25 @@ func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
 			},
 		})
 
+	case status == 429:
+		smm.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
+			Type:      EventRateLimit,
+			Level:     LevelWarning,
+			Source:    "api",
+			UserID:    toString(userID),
+			IP:        ip,
+			UserAgent: userAgent,
+			Path:      path,
+			Method:    method,
+			Status:    status,
+			Message:   "Rate limit exceeded",
+			Details: map[string]interface{}{
+				"duration_ms": duration.Milliseconds(),
+			},
+		})
+
 	case status >= 500:
-		smm.monitor.RecordEvent(SecurityEvent{
+		smm.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
 			Type:      "server_error",
 			Level:     LevelError,
 			Source:    "api",
@@ -393,8 +489,8 @@ func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
 		})
 
 	case duration > time.Second*5:
-		smm.monitor.RecordEvent(SecurityEvent{
-			Type:      "slow_request",
+		smm.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
+			Type:      EventSlowRequest,
 			Level:     LevelWarning,
 			Source:    "api",
 			UserID:    toString(userID),
@@ -410,11 +506,15 @@ func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
 		})
 	}
 
-	// 检查可疑请求
-	if smm.isSuspiciousRequest(c) {
-		smm.monitor.RecordEvent(SecurityEvent{
-			Type:      EventSuspicious,
-			Level:     LevelWarning,
+	// WAF 规则命中
+	if waf.Matched() {
+		level := LevelWarning
+		if waf.Action == WAFActionBlock {
+			level = LevelError
+		}
+		smm.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
+			Type:      waf.EventType(),
+			Level:     level,
 			Source:    "api",
 			UserID:    toString(userID),
 			IP:        ip,
@@ -422,41 +522,18 @@ func (smm *SecurityMonitoringMiddleware) checkSecurityEvents(c *gin.Context) {
 			Path:      path,
 			Method:    method,
 			Status:    status,
-			Message:   "Suspicious request detected",
+			Message:   "Request matched waf rules",
 			Details: map[string]interface{}{
 				"duration_ms": duration.Milliseconds(),
+				"waf_rules":   waf.RuleIDs(),
+				"waf_score":   waf.Score,
+				"waf_action":  string(waf.Action),
+				"waf_matches": waf.Matches,
 			},
 		})
 	}
 }
 
-// isSuspiciousRequest 检查是否为可疑请求
-func (smm *SecurityMonitoringMiddleware) isSuspiciousRequest(c *gin.Context) bool {
-	// 检查 User-Agent
-	userAgent := c.GetHeader("User-Agent")
-	if userAgent == "" || strings.Contains(userAgent, "bot") || strings.Contains(userAgent, "scanner") {
-		return true
-	}
-
-	// 检查请求路径
-	path := c.Request.URL.Path
-	suspiciousPaths := []string{"/admin", "/config", "/system", "/debug", "/env", "/proc"}
-	for _, suspiciousPath := range suspiciousPaths {
-		if strings.Contains(path, suspiciousPath) {
-			return true
-		}
-	}
-
-	// 检查请求参数
-	for key := range c.Request.URL.Query() {
-		if strings.Contains(key, "sql") || strings.Contains(key, "script") || strings.Contains(key, "alert") {
-			return true
-		}
-	}
-
-	return false
-}
-
 // toString 安全转换 interface{} 到 string
 func toString(value interface{}) string {
 	if value == nil {
@@ -581,6 +658,10 @@ func (sm *SecurityMonitor) GenerateReport(duration time.Duration) SecurityReport
 		Events:    sm.getEventsInPeriod(duration),
 		TopEvents: sm.getTopEvents(10),
 	}
+	if sm.waf != nil {
+		stats := sm.waf.Stats()
+		report.WAF = &stats
+	}
 
 	return report
 }
@@ -593,6 +674,7 @@ type SecurityReport struct {
 	Metrics   SecurityMetrics `json:"metrics"`
 	Events    []SecurityEvent `json:"events"`
 	TopEvents []EventCount    `json:"top_events"`
+	WAF       *WAFStats       `json:"waf,omitempty"` // WAF 规则命中统计
 }
 
 // EventCount 事件计数
@@ -0,0 +1,113 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionRevoker 按用户吊销已签发的令牌：记录吊销时间，签发时间不晚于该时间的令牌视为已吊销。
+// 吊销记录存放在共享缓存中，各实例共用，保留到吊销前签发的令牌全部过期为止
+type SessionRevoker struct {
+	cache    cache.CacheService
+	lifetime time.Duration
+}
+
+// NewSessionRevoker 创建会话吊销器，tokenLifetime 为令牌有效期，决定吊销记录的保留时间
+func NewSessionRevoker(cacheService cache.CacheService, tokenLifetime time.Duration) *SessionRevoker {
+	return &SessionRevoker{cache: cacheService, lifetime: tokenLifetime}
+}
+
+// RevokeUser 吊销用户此前签发的全部令牌，返回记录的吊销时间。
+// 签发时间按秒记录，与吊销同一秒内重新登录得到的令牌同样失效，需稍后重新登录
+func (r *SessionRevoker) RevokeUser(ctx context.Context, userID string) (time.Time, error) {
+	if userID == "" {
+		return time.Time{}, fmt.Errorf("user id is required")
+	}
+	revokedAt := time.Now().Truncate(time.Second)
+	if err := r.cache.Set(ctx, revokedBeforeKey(userID), revokedAt.Unix(), r.lifetime); err != nil {
+		return time.Time{}, fmt.Errorf("failed to revoke sessions of user %s: %w", userID, err)
+	}
+	return revokedAt, nil
+}
+
+// RevokedAt 用户最近一次吊销的时间，没有未过期的吊销记录时返回 false
+func (r *SessionRevoker) RevokedAt(ctx context.Context, userID string) (time.Time, bool, error) {
+	var unix int64
+	if err := r.cache.Get(ctx, revokedBeforeKey(userID), &unix); err != nil {
+		if err.Error() == "cache miss" {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	return time.Unix(unix, 0), true, nil
+}
+
+// IsRevoked 令牌是否签发于用户最近一次吊销之前，未带签发时间的旧令牌在吊销后一律失效。
+// 读取吊销记录失败时放行，避免缓存故障导致全部请求被拒绝
+func (r *SessionRevoker) IsRevoked(ctx context.Context, claims *utils.Claims) bool {
+	revokedAt, ok, err := r.RevokedAt(ctx, claims.UserID)
+	if err != nil {
+		log.Printf("Failed to check session revocation of user %s: %v", claims.UserID, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	return claims.IssuedAt == nil || !claims.IssuedAt.After(revokedAt)
+}
+
+// revokedBeforeKey 用户吊销时间的缓存键
+func revokedBeforeKey(userID string) string {
+	return "auth:revoked_before:" + userID
+}
+
+// SessionHandler 会话吊销管理接口
+type SessionHandler struct {
+	revoker *SessionRevoker
+}
+
+// NewSessionHandler 创建会话吊销接口
+func NewSessionHandler(revoker *SessionRevoker) *SessionHandler {
+	return &SessionHandler{revoker: revoker}
+}
+
+// RegisterAdminRoutes 注册会话吊销路由，调用方需挂载管理员权限校验
+func (h *SessionHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/users/:id/sessions", h.GetRevocation)
+	group.DELETE("/users/:id/sessions", h.RevokeSessions)
+}
+
+// GetRevocation 用户最近一次吊销的时间
+func (h *SessionHandler) GetRevocation(c *gin.Context) {
+	revokedAt, ok, err := h.revoker.RevokedAt(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeCache, ""))
+		return
+	}
+	resp := gin.H{"user_id": c.Param("id")}
+	if ok {
+		resp["revoked_before"] = revokedAt
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeSessions 吊销用户此前签发的全部令牌，用户需重新登录
+func (h *SessionHandler) RevokeSessions(c *gin.Context) {
+	revokedAt, err := h.revoker.RevokeUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeCache, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":        c.Param("id"),
+		"revoked_before": revokedAt,
+	})
+}
@@ -0,0 +1,278 @@
+package security
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateTag 结构体验证标签名
+//
+// 格式为 "类型,选项..."，类型对应已有验证器：
+//
+//	validate:"string,required,min=2,max=50,pattern=^[a-z]+$"
+//	validate:"email,required"
+//	validate:"phone,country=CN"
+//	validate:"number,min=0,max=100" / validate:"int,min=1"
+//	validate:"array,min=1,max=10,item=string"
+//
+// pattern 应放在最后，其值可以包含逗号。
+const ValidateTag = "validate"
+
+// validatedBodyKey gin 上下文中已验证请求体的键
+const validatedBodyKey = "validated_body"
+
+// structValidator 结构体字段验证器
+type structValidator struct {
+	set    *ValidatorSet
+	fields []structField
+}
+
+// structField 参与验证的字段
+type structField struct {
+	index []int
+	name  string
+}
+
+var structValidators sync.Map // reflect.Type -> *structValidator
+
+// ParseValidateTag 将标签解析为验证器
+func ParseValidateTag(tag string) (Validator, error) {
+	kind, rest, _ := strings.Cut(tag, ",")
+
+	opts := make(map[string]string)
+	for rest != "" {
+		var part string
+		// pattern 的值可能包含逗号，取剩余全部
+		if strings.HasPrefix(rest, "pattern=") {
+			part, rest = rest, ""
+		} else {
+			part, rest, _ = strings.Cut(rest, ",")
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		opts[key] = value
+	}
+	_, required := opts["required"]
+
+	switch strings.TrimSpace(kind) {
+	case "string":
+		minLength, err := intOption(opts, "min", 0)
+		if err != nil {
+			return nil, err
+		}
+		maxLength, err := intOption(opts, "max", math.MaxInt32)
+		if err != nil {
+			return nil, err
+		}
+		sv := NewStringValidator(minLength, maxLength, required)
+		if pattern, ok := opts["pattern"]; ok {
+			if err := sv.SetPattern(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+		return sv, nil
+	case "email":
+		return NewEmailValidator(required), nil
+	case "phone":
+		return NewPhoneValidator(required, opts["country"]), nil
+	case "number", "int":
+		nv := NewNumberValidator(required)
+		nv.SetInteger(kind == "int")
+		if v, ok := opts["min"]; ok {
+			min, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid min %q: %w", v, err)
+			}
+			nv.SetMin(min)
+		}
+		if v, ok := opts["max"]; ok {
+			max, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max %q: %w", v, err)
+			}
+			nv.SetMax(max)
+		}
+		return nv, nil
+	case "array":
+		minLength, err := intOption(opts, "min", 0)
+		if err != nil {
+			return nil, err
+		}
+		maxLength, err := intOption(opts, "max", 0)
+		if err != nil {
+			return nil, err
+		}
+		av := NewArrayValidator(minLength, maxLength, required)
+		if item, ok := opts["item"]; ok {
+			itemValidator, err := ParseValidateTag(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid item validator: %w", err)
+			}
+			av.SetItemValidator(itemValidator)
+		}
+		return av, nil
+	default:
+		return nil, fmt.Errorf("unknown validator type %q", kind)
+	}
+}
+
+func intOption(opts map[string]string, key string, def int) (int, error) {
+	v, ok := opts[key]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return n, nil
+}
+
+// validatorFor 获取类型对应的验证器，按类型缓存
+func validatorFor(t reflect.Type) (*structValidator, error) {
+	if cached, ok := structValidators.Load(t); ok {
+		return cached.(*structValidator), nil
+	}
+
+	sv := &structValidator{set: NewValidatorSet()}
+	for _, field := range reflect.VisibleFields(t) {
+		tag, ok := field.Tag.Lookup(ValidateTag)
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		validator, err := ParseValidateTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		name := fieldName(field)
+		sv.set.AddRule(name, validator, "")
+		sv.fields = append(sv.fields, structField{index: field.Index, name: name})
+	}
+
+	structValidators.Store(t, sv)
+	return sv, nil
+}
+
+// fieldName 使用 json 标签作为字段名，与请求体保持一致
+func fieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// ValidateStruct 按 validate 标签验证结构体，nil 指针字段视为缺失
+func ValidateStruct(v interface{}) (*ValidationResult, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("cannot validate nil value")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot validate %s, struct required", rv.Kind())
+	}
+
+	sv, err := validatorFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]interface{}, len(sv.fields))
+	for _, field := range sv.fields {
+		fv, err := rv.FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+		if value, ok := validationValue(fv); ok {
+			data[field.name] = value
+		}
+	}
+	return sv.set.Validate(data), nil
+}
+
+// validationValue 将字段值转换为验证器接受的类型
+func validationValue(v reflect.Value) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if item, ok := validationValue(v.Index(i)); ok {
+				items = append(items, item)
+			}
+		}
+		return items, true
+	default:
+		return v.Interface(), true
+	}
+}
+
+// ValidationError 将验证结果转换为标准错误，字段错误放在 details.fields 中
+func ValidationError(result *ValidationResult) error {
+	if result == nil || result.Valid {
+		return nil
+	}
+	return apperrors.New(apperrors.CodeInvalidParam, "").WithDetail("fields", result.Errors)
+}
+
+// BindAndValidate 绑定请求体到 T 并按 validate 标签验证，失败时以标准错误格式返回字段级错误。
+// 通过 ValidatedBody 获取结果。
+func BindAndValidate[T any]() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := new(T)
+		if err := c.ShouldBind(body); err != nil {
+			apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, "invalid request body"))
+			c.Abort()
+			return
+		}
+
+		result, err := ValidateStruct(body)
+		if err != nil {
+			apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+			c.Abort()
+			return
+		}
+		if err := ValidationError(result); err != nil {
+			apperrors.Render(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Set(validatedBodyKey, body)
+		c.Next()
+	}
+}
+
+// ValidatedBody 获取 BindAndValidate 绑定的请求体
+func ValidatedBody[T any](c *gin.Context) (*T, bool) {
+	value, exists := c.Get(validatedBodyKey)
+	if !exists {
+		return nil, false
+	}
+	body, ok := value.(*T)
+	return body, ok
+}
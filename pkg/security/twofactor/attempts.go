@@ -0,0 +1,153 @@
+package twofactor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Attempts 验证失败计数，多实例部署时应共享（见 NewRedisAttempts）
+type Attempts interface {
+	// Locked 返回用户剩余的锁定时长，未锁定时为 0
+	Locked(ctx context.Context, userID string) (time.Duration, error)
+	// Fail 登记一次失败，返回连续失败次数；计数在最后一次失败 window 后过期，
+	// 达到 limit 时清零计数并锁定 lockout
+	Fail(ctx context.Context, userID string, limit int, window, lockout time.Duration) (int, error)
+	// Reset 验证通过后清零失败计数
+	Reset(ctx context.Context, userID string) error
+}
+
+// failScript KEYS: 计数键、锁定键；ARGV: 次数上限、计数窗口毫秒数、锁定毫秒数。返回连续失败次数
+var failScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+if count >= tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[2], '1', 'PX', ARGV[3])
+	redis.call('DEL', KEYS[1])
+end
+return count
+`)
+
+// RedisAttempts 基于 Redis 的失败计数，计数与锁定在一个脚本中完成，并发的失败不会漏计
+type RedisAttempts struct {
+	rdb *redis.Client
+}
+
+// NewRedisAttempts 创建基于 Redis 的失败计数
+func NewRedisAttempts(rdb *redis.Client) *RedisAttempts {
+	return &RedisAttempts{rdb: rdb}
+}
+
+func (a *RedisAttempts) Locked(ctx context.Context, userID string) (time.Duration, error) {
+	ttl, err := a.rdb.PTTL(ctx, lockKey(userID)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read two-factor lockout: %w", err)
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (a *RedisAttempts) Fail(ctx context.Context, userID string, limit int, window, lockout time.Duration) (int, error) {
+	count, err := failScript.Run(ctx, a.rdb, []string{failuresKey(userID), lockKey(userID)},
+		limit, window.Milliseconds(), lockout.Milliseconds()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record two-factor failure: %w", err)
+	}
+	return count, nil
+}
+
+func (a *RedisAttempts) Reset(ctx context.Context, userID string) error {
+	if err := a.rdb.Del(ctx, failuresKey(userID)).Err(); err != nil {
+		return fmt.Errorf("failed to reset two-factor failures: %w", err)
+	}
+	return nil
+}
+
+func failuresKey(userID string) string {
+	return "2fa:failures:" + userID
+}
+
+func lockKey(userID string) string {
+	return "2fa:locked:" + userID
+}
+
+// memoryAttempts 进程内的失败计数，未设置 Attempts 时使用，只对单实例有效
+type memoryAttempts struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	failures map[string]memoryFailures
+	locked   map[string]time.Time
+}
+
+type memoryFailures struct {
+	count     int
+	expiresAt time.Time
+}
+
+func newMemoryAttempts(c clock.Clock) *memoryAttempts {
+	return &memoryAttempts{
+		clock:    clock.OrReal(c),
+		failures: make(map[string]memoryFailures),
+		locked:   make(map[string]time.Time),
+	}
+}
+
+func (a *memoryAttempts) Locked(_ context.Context, userID string) (time.Duration, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	remaining := a.locked[userID].Sub(a.clock.Now())
+	if remaining <= 0 {
+		delete(a.locked, userID)
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+func (a *memoryAttempts) Fail(_ context.Context, userID string, limit int, window, lockout time.Duration) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.clock.Now()
+	a.sweep(now)
+
+	entry := a.failures[userID]
+	if !now.Before(entry.expiresAt) {
+		entry.count = 0
+	}
+	entry.count++
+	entry.expiresAt = now.Add(window)
+	if entry.count >= limit {
+		a.locked[userID] = now.Add(lockout)
+		delete(a.failures, userID)
+		return entry.count, nil
+	}
+	a.failures[userID] = entry
+	return entry.count, nil
+}
+
+func (a *memoryAttempts) Reset(_ context.Context, userID string) error {
+	a.mu.Lock()
+	delete(a.failures, userID)
+	a.mu.Unlock()
+	return nil
+}
+
+// sweep 删除已过期的计数与锁定，避免长期运行时记录无限增长
+func (a *memoryAttempts) sweep(now time.Time) {
+	for userID, entry := range a.failures {
+		if !now.Before(entry.expiresAt) {
+			delete(a.failures, userID)
+		}
+	}
+	for userID, until := range a.locked {
+		if !now.Before(until) {
+			delete(a.locked, userID)
+		}
+	}
+}
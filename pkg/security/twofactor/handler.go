@@ -0,0 +1,158 @@
+package twofactor
+
+import (
+	"net/http"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/ctxutil"
+	"user_crud_jwt/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// codeRequest 携带验证码的请求
+type codeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// enrollRequest 登记请求，account 显示在验证器应用中
+type enrollRequest struct {
+	Account string `json:"account"`
+}
+
+// Handler 两步验证接口
+type Handler struct {
+	manager *Manager
+}
+
+// NewHandler 创建两步验证接口
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{manager: manager}
+}
+
+// RegisterRoutes 注册路由，调用方需挂载 AuthMiddleware，且不要挂载 EnforcePolicy
+func (h *Handler) RegisterRoutes(group *gin.RouterGroup) {
+	group.GET("/2fa/status", h.Status)
+	group.POST("/2fa/enroll", h.Enroll)
+	group.POST("/2fa/confirm", h.Confirm)
+	group.POST("/2fa/verify", h.Verify)
+	group.POST("/2fa/disable", h.Disable)
+	group.POST("/2fa/backup-codes", h.RegenerateBackupCodes)
+}
+
+// Status 查询两步验证状态
+func (h *Handler) Status(c *gin.Context) {
+	userID := c.GetString("userID")
+	enrolled, err := h.manager.IsEnrolled(c.Request.Context(), userID)
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enrolled": enrolled,
+		"required": h.manager.RequiresEnrollment(roleFromContext(c)),
+		"verified": hasOTP(c),
+		"flagged":  h.manager.IsFlagged(c.Request.Context(), userID),
+	})
+}
+
+// Enroll 生成密钥、二维码 URI 与备用码
+func (h *Handler) Enroll(c *gin.Context) {
+	var req enrollRequest
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.GetString("userID")
+	account := req.Account
+	if account == "" {
+		account = userID
+	}
+
+	provisioning, err := h.manager.Enroll(c.Request.Context(), userID, account)
+	if err != nil {
+		apperrors.Render(c, verifyError(err))
+		return
+	}
+	c.JSON(http.StatusOK, provisioning)
+}
+
+// Confirm 用首个验证码确认登记，成功后签发已通过两步验证的令牌
+func (h *Handler) Confirm(c *gin.Context) {
+	var req codeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	userID := c.GetString("userID")
+	if err := h.manager.Confirm(c.Request.Context(), userID, req.Code); err != nil {
+		apperrors.Render(c, verifyError(err))
+		return
+	}
+	h.issueToken(c, userID)
+}
+
+// Verify 校验验证码或备用码，签发已通过两步验证的令牌
+func (h *Handler) Verify(c *gin.Context) {
+	var req codeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	userID := c.GetString("userID")
+	if err := h.manager.Verify(c.Request.Context(), userID, req.Code); err != nil {
+		apperrors.Render(c, verifyError(err))
+		return
+	}
+	h.issueToken(c, userID)
+}
+
+// Disable 关闭两步验证，必须开启的角色不允许关闭
+func (h *Handler) Disable(c *gin.Context) {
+	var req codeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if h.manager.RequiresEnrollment(roleFromContext(c)) {
+		apperrors.Render(c, apperrors.New(apperrors.CodeNoPermission, "two-factor authentication is mandatory for this role"))
+		return
+	}
+
+	if err := h.manager.Disable(c.Request.Context(), c.GetString("userID"), req.Code); err != nil {
+		apperrors.Render(c, verifyError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enrolled": false,
+	})
+}
+
+// RegenerateBackupCodes 重新生成备用码
+func (h *Handler) RegenerateBackupCodes(c *gin.Context) {
+	var req codeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.Render(c, apperrors.New(apperrors.CodeInvalidParam, ""))
+		return
+	}
+
+	codes, err := h.manager.RegenerateBackupCodes(c.Request.Context(), c.GetString("userID"), req.Code)
+	if err != nil {
+		apperrors.Render(c, verifyError(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"backup_codes": codes,
+	})
+}
+
+func (h *Handler) issueToken(c *gin.Context, userID string) {
+	token, expireAt, err := utils.GenerateTenantToken(ctxutil.TenantID(c.Request.Context()), userID, roleFromContext(c), []string{AMRPassword, AMROTP})
+	if err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_at": expireAt,
+	})
+}
@@ -0,0 +1,452 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/clock"
+	"user_crud_jwt/pkg/security"
+)
+
+var (
+	// ErrInvalidCode 验证码或备用码错误
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	// ErrAlreadyEnrolled 已开启两步验证
+	ErrAlreadyEnrolled = errors.New("two-factor authentication is already enrolled")
+	// ErrNotConfirmed 已登记但尚未通过首次验证码确认
+	ErrNotConfirmed = errors.New("two-factor enrollment is not confirmed")
+	// ErrLockedOut 连续验证失败次数过多，锁定期间不再校验验证码
+	ErrLockedOut = errors.New("too many failed two-factor attempts")
+)
+
+// encryptedPrefix 加密存储的密钥前缀
+const encryptedPrefix = "enc:"
+
+// Config 两步验证配置
+type Config struct {
+	Issuer          string        `json:"issuer"`
+	DriftWindow     int           `json:"drift_window"` // 允许的前后时间步数
+	BackupCodeCount int           `json:"backup_code_count"`
+	EncryptionKey   string        `json:"-"`                // 非空时以 AES-GCM 加密存储 TOTP 密钥
+	RequiredRoles   []int         `json:"required_roles"`   // 必须开启两步验证的角色
+	FlagTTL         time.Duration `json:"flag_ttl"`         // 会话被标记为需要二次验证的时长
+	MaxFailures     int           `json:"max_failures"`     // 连续失败多少次后锁定
+	FailureWindow   time.Duration `json:"failure_window"`   // 最后一次失败后多久清零计数
+	LockoutDuration time.Duration `json:"lockout_duration"` // 锁定时长，锁定期间不校验验证码
+	Clock           clock.Clock   `json:"-"`                // 校验验证码与记录确认时间使用的时钟，为 nil 时使用系统时钟
+}
+
+// DefaultConfig 默认配置：管理员必须开启两步验证
+func DefaultConfig() *Config {
+	return &Config{
+		Issuer:          "go-progres",
+		DriftWindow:     1,
+		BackupCodeCount: 10,
+		RequiredRoles:   []int{1},
+		FlagTTL:         30 * time.Minute,
+		MaxFailures:     5,
+		FailureWindow:   15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+	}
+}
+
+// Provisioning 登记时返回给用户的信息，备用码只在此时以明文出现
+type Provisioning struct {
+	Secret      string   `json:"secret"`
+	URI         string   `json:"uri"`
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// EventRecorder 记录审计事件，由 *security.SecurityMonitor 实现
+type EventRecorder interface {
+	RecordEvent(ctx context.Context, event security.SecurityEvent)
+}
+
+// Manager 两步验证管理器
+type Manager struct {
+	config   *Config
+	store    Store
+	cache    cache.CacheService
+	aead     cipher.AEAD
+	clock    clock.Clock
+	attempts Attempts
+	recorder EventRecorder // 为 nil 时不记录事件
+}
+
+// NewManager 创建两步验证管理器，cacheService 用于会话标记，可为 nil。
+// 失败计数默认保存在进程内，多实例部署时应以 SetAttempts 设置共享的计数
+func NewManager(config *Config, store Store, cacheService cache.CacheService) (*Manager, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	defaults := DefaultConfig()
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = defaults.MaxFailures
+	}
+	if config.FailureWindow <= 0 {
+		config.FailureWindow = defaults.FailureWindow
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = defaults.LockoutDuration
+	}
+
+	m := &Manager{
+		config: config,
+		store:  store,
+		cache:  cacheService,
+		clock:  clock.OrReal(config.Clock),
+	}
+	m.attempts = newMemoryAttempts(m.clock)
+	if config.EncryptionKey != "" {
+		key := sha256.Sum256([]byte(config.EncryptionKey))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		m.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// SetAttempts 设置失败计数，如 NewRedisAttempts
+func (m *Manager) SetAttempts(attempts Attempts) {
+	m.attempts = attempts
+}
+
+// SetRecorder 设置审计事件的记录器，验证失败、锁定、确认与关闭记录为 two_factor 事件
+func (m *Manager) SetRecorder(recorder EventRecorder) {
+	m.recorder = recorder
+}
+
+// Enroll 生成新的密钥与备用码；已确认的登记需先关闭才能重新登记
+func (m *Manager) Enroll(ctx context.Context, userID, account string) (*Provisioning, error) {
+	existing, err := m.store.Get(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotEnrolled) {
+		return nil, err
+	}
+	if existing != nil && existing.Confirmed {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := m.newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := m.seal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.store.Save(ctx, &Enrollment{
+		UserID:      userID,
+		Secret:      sealed,
+		BackupCodes: hashes,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &Provisioning{
+		Secret:      secret,
+		URI:         ProvisioningURI(m.config.Issuer, account, secret),
+		BackupCodes: codes,
+	}, nil
+}
+
+// Confirm 用验证器应用生成的首个验证码确认登记
+func (m *Manager) Confirm(ctx context.Context, userID, code string) error {
+	enrollment, err := m.store.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if enrollment.Confirmed {
+		return ErrAlreadyEnrolled
+	}
+	if err := m.checkLocked(ctx, userID); err != nil {
+		return err
+	}
+
+	step, err := m.validateTOTP(enrollment, code)
+	if err != nil {
+		return m.failed(ctx, userID, err)
+	}
+
+	enrollment.Confirmed = true
+	enrollment.LastUsedStep = step
+	enrollment.ConfirmedAt = sql.NullTime{Time: m.clock.Now(), Valid: true}
+	if err := m.store.Save(ctx, enrollment); err != nil {
+		return err
+	}
+	m.succeeded(ctx, userID)
+	m.record(ctx, userID, security.LevelInfo, "confirmed", "two-factor authentication enrolled", nil)
+	return nil
+}
+
+// Verify 校验 TOTP 验证码或备用码，备用码使用后失效。连续失败 MaxFailures 次后锁定 LockoutDuration，
+// 锁定期间返回 ErrLockedOut，不再校验验证码
+func (m *Manager) Verify(ctx context.Context, userID, code string) error {
+	if err := m.checkLocked(ctx, userID); err != nil {
+		return err
+	}
+	if err := m.verify(ctx, userID, code); err != nil {
+		return m.failed(ctx, userID, err)
+	}
+	m.succeeded(ctx, userID)
+	return nil
+}
+
+func (m *Manager) verify(ctx context.Context, userID, code string) error {
+	enrollment, err := m.store.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Confirmed {
+		return ErrNotConfirmed
+	}
+
+	if step, err := m.validateTOTP(enrollment, code); err == nil {
+		advanced, err := m.store.AdvanceStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			// 同一时间步的验证码已使用过
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	consumed, err := m.store.ConsumeBackupCode(ctx, userID, HashBackupCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// Disable 校验验证码后关闭两步验证
+func (m *Manager) Disable(ctx context.Context, userID, code string) error {
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	if err := m.store.Delete(ctx, userID); err != nil {
+		return err
+	}
+	m.record(ctx, userID, security.LevelWarning, "disabled", "two-factor authentication disabled", nil)
+	return nil
+}
+
+// RegenerateBackupCodes 校验验证码后重新生成备用码，旧备用码全部失效
+func (m *Manager) RegenerateBackupCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := m.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	enrollment, err := m.store.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := m.newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	enrollment.BackupCodes = hashes
+	if err := m.store.Save(ctx, enrollment); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IsEnrolled 用户是否已开启并确认两步验证
+func (m *Manager) IsEnrolled(ctx context.Context, userID string) (bool, error) {
+	enrollment, err := m.store.Get(ctx, userID)
+	if errors.Is(err, ErrNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enrollment.Confirmed, nil
+}
+
+// RequiresEnrollment 角色是否必须开启两步验证
+func (m *Manager) RequiresEnrollment(role int) bool {
+	return slices.Contains(m.config.RequiredRoles, role)
+}
+
+// sessionFlag 会话标记：FlaggedAt 之前签发的令牌即使已通过两步验证也需重新验证
+type sessionFlag struct {
+	Reason    string    `json:"reason"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// FlagSession 标记用户会话需要二次验证（如异地登录、风险操作），持续 FlagTTL。
+// 标记期间只认可标记之后签发的两步验证令牌，标记前签发的令牌需重新验证
+func (m *Manager) FlagSession(ctx context.Context, userID, reason string) error {
+	if m.cache == nil {
+		return fmt.Errorf("cache is not configured")
+	}
+	return m.cache.Set(ctx, flagKey(userID), sessionFlag{Reason: reason, FlaggedAt: m.clock.Now()}, m.config.FlagTTL)
+}
+
+// IsFlagged 用户会话是否被标记
+func (m *Manager) IsFlagged(ctx context.Context, userID string) bool {
+	_, flagged := m.FlaggedAt(ctx, userID)
+	return flagged
+}
+
+// FlaggedAt 会话被标记的时间，未标记或读取失败时返回 false
+func (m *Manager) FlaggedAt(ctx context.Context, userID string) (time.Time, bool) {
+	if m.cache == nil {
+		return time.Time{}, false
+	}
+	var flag sessionFlag
+	if err := m.cache.Get(ctx, flagKey(userID), &flag); err != nil {
+		return time.Time{}, false
+	}
+	return flag.FlaggedAt, true
+}
+
+// ClearFlag 提前解除标记，解除后标记前签发的两步验证令牌重新有效；
+// 验证通过时不解除，新签发的令牌晚于标记时间即可通过
+func (m *Manager) ClearFlag(ctx context.Context, userID string) error {
+	if m.cache == nil {
+		return nil
+	}
+	return m.cache.Delete(ctx, flagKey(userID))
+}
+
+func flagKey(userID string) string {
+	return "2fa:flagged:" + userID
+}
+
+// checkLocked 锁定期间返回 ErrLockedOut；读取计数失败时不放行
+func (m *Manager) checkLocked(ctx context.Context, userID string) error {
+	remaining, err := m.attempts.Locked(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return ErrLockedOut
+	}
+	return nil
+}
+
+// failed 验证码错误时登记失败并记录审计事件，达到上限时锁定；其他错误原样返回
+func (m *Manager) failed(ctx context.Context, userID string, err error) error {
+	if !errors.Is(err, ErrInvalidCode) {
+		return err
+	}
+	count, failErr := m.attempts.Fail(ctx, userID, m.config.MaxFailures, m.config.FailureWindow, m.config.LockoutDuration)
+	if failErr != nil {
+		return failErr
+	}
+	details := map[string]interface{}{"failures": count}
+	if count >= m.config.MaxFailures {
+		details["lockout_seconds"] = int(m.config.LockoutDuration.Seconds())
+		m.record(ctx, userID, security.LevelError, "locked", "two-factor locked after repeated failures", details)
+		return ErrLockedOut
+	}
+	m.record(ctx, userID, security.LevelWarning, "failed", "invalid two-factor code", details)
+	return err
+}
+
+// succeeded 验证通过后清零失败计数，清零失败时计数随窗口过期
+func (m *Manager) succeeded(ctx context.Context, userID string) {
+	_ = m.attempts.Reset(ctx, userID)
+}
+
+// record 记录 two_factor 审计事件，details.action 为 failed、locked、confirmed 或 disabled
+func (m *Manager) record(ctx context.Context, userID string, level security.SecurityEventLevel, action, message string, details map[string]interface{}) {
+	if m.recorder == nil {
+		return
+	}
+	if details == nil {
+		details = make(map[string]interface{}, 1)
+	}
+	details["action"] = action
+	m.recorder.RecordEvent(ctx, security.SecurityEvent{
+		Type:    security.EventTwoFactor,
+		Level:   level,
+		Source:  "two_factor",
+		UserID:  userID,
+		Message: message,
+		Details: details,
+	})
+}
+
+func (m *Manager) validateTOTP(enrollment *Enrollment, code string) (int64, error) {
+	secret, err := m.open(enrollment.Secret)
+	if err != nil {
+		return 0, err
+	}
+	step, ok := ValidateCode(secret, code, m.clock.Now(), m.config.DriftWindow)
+	if !ok || step <= enrollment.LastUsedStep {
+		return 0, ErrInvalidCode
+	}
+	return step, nil
+}
+
+func (m *Manager) newBackupCodes() ([]string, []string, error) {
+	codes, err := GenerateBackupCodes(m.config.BackupCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = HashBackupCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// seal 加密 TOTP 密钥，未配置密钥时原样返回
+func (m *Manager) seal(secret string) (string, error) {
+	if m.aead == nil {
+		return secret, nil
+	}
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := m.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open 解密 TOTP 密钥，兼容未加密存储的旧数据
+func (m *Manager) open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if m.aead == nil {
+		return "", fmt.Errorf("two-factor secret is encrypted but no encryption key is configured")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil || len(data) < m.aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted secret")
+	}
+	nonce, ciphertext := data[:m.aead.NonceSize()], data[m.aead.NonceSize():]
+	plain, err := m.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plain), nil
+}
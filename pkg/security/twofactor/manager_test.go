@@ -0,0 +1,237 @@
+package twofactor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/security"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore 进程内的登记存储
+type memoryStore struct {
+	mu          sync.Mutex
+	enrollments map[string]Enrollment
+}
+
+func (s *memoryStore) Get(_ context.Context, userID string) (*Enrollment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userID]
+	if !ok {
+		return nil, ErrNotEnrolled
+	}
+	enrollment.BackupCodes = slices.Clone(enrollment.BackupCodes)
+	return &enrollment, nil
+}
+
+func (s *memoryStore) Save(_ context.Context, enrollment *Enrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enrollments == nil {
+		s.enrollments = make(map[string]Enrollment)
+	}
+	s.enrollments[enrollment.UserID] = *enrollment
+	return nil
+}
+
+func (s *memoryStore) AdvanceStep(_ context.Context, userID string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userID]
+	if !ok || enrollment.LastUsedStep >= step {
+		return false, nil
+	}
+	enrollment.LastUsedStep = step
+	s.enrollments[userID] = enrollment
+	return true, nil
+}
+
+func (s *memoryStore) ConsumeBackupCode(_ context.Context, userID, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrollment, ok := s.enrollments[userID]
+	if !ok || !slices.Contains(enrollment.BackupCodes, hash) {
+		return false, nil
+	}
+	enrollment.BackupCodes = slices.DeleteFunc(slices.Clone(enrollment.BackupCodes), func(h string) bool { return h == hash })
+	s.enrollments[userID] = enrollment
+	return true, nil
+}
+
+func (s *memoryStore) Delete(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.enrollments, userID)
+	return nil
+}
+
+// eventLog 记录审计事件
+type eventLog struct {
+	events []security.SecurityEvent
+}
+
+func (l *eventLog) RecordEvent(_ context.Context, event security.SecurityEvent) {
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) actions() []string {
+	var actions []string
+	for _, event := range l.events {
+		actions = append(actions, event.Details["action"].(string))
+	}
+	return actions
+}
+
+// newEnrolledManager 创建已为 u1 确认登记的管理器，返回 TOTP 密钥
+func newEnrolledManager(t *testing.T) (*Manager, *fakes.Clock, *eventLog, string) {
+	t.Helper()
+	clk := fakes.NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config := DefaultConfig()
+	config.Clock = clk
+	m, err := NewManager(config, &memoryStore{}, nil)
+	require.NoError(t, err)
+	events := &eventLog{}
+	m.SetRecorder(events)
+
+	provisioning, err := m.Enroll(context.Background(), "u1", "u1@example.com")
+	require.NoError(t, err)
+	require.NoError(t, m.Confirm(context.Background(), "u1", currentCode(t, clk, provisioning.Secret)))
+	return m, clk, events, provisioning.Secret
+}
+
+func currentCode(t *testing.T, clk *fakes.Clock, secret string) string {
+	t.Helper()
+	code, err := GenerateCode(secret, Step(clk.Now()))
+	require.NoError(t, err)
+	return code
+}
+
+// TestManager_VerifyLockout 连续失败达到上限后锁定，锁定期间正确的验证码也被拒绝；
+// 失败与锁定记录审计事件，锁定结束后可以验证
+func TestManager_VerifyLockout(t *testing.T) {
+	ctx := context.Background()
+	m, clk, events, secret := newEnrolledManager(t)
+
+	for i := 1; i < m.config.MaxFailures; i++ {
+		assert.ErrorIs(t, m.Verify(ctx, "u1", "000000"), ErrInvalidCode)
+	}
+	assert.ErrorIs(t, m.Verify(ctx, "u1", "000000"), ErrLockedOut)
+
+	clk.Advance(time.Minute)
+	assert.ErrorIs(t, m.Verify(ctx, "u1", currentCode(t, clk, secret)), ErrLockedOut)
+	assert.Equal(t, []string{"confirmed", "failed", "failed", "failed", "failed", "locked"}, events.actions())
+	assert.Equal(t, security.EventTwoFactor, events.events[len(events.events)-1].Type)
+
+	clk.Advance(m.config.LockoutDuration)
+	assert.NoError(t, m.Verify(ctx, "u1", currentCode(t, clk, secret)))
+}
+
+// TestManager_VerifyResetsFailures 验证通过后清零失败计数，超过窗口未再失败时计数过期
+func TestManager_VerifyResetsFailures(t *testing.T) {
+	ctx := context.Background()
+	m, clk, _, secret := newEnrolledManager(t)
+
+	for i := 1; i < m.config.MaxFailures; i++ {
+		assert.ErrorIs(t, m.Verify(ctx, "u1", "000000"), ErrInvalidCode)
+	}
+	clk.Advance(time.Minute)
+	require.NoError(t, m.Verify(ctx, "u1", currentCode(t, clk, secret)))
+	assert.ErrorIs(t, m.Verify(ctx, "u1", "000000"), ErrInvalidCode, "counter reset after success")
+
+	for i := 2; i < m.config.MaxFailures; i++ {
+		assert.ErrorIs(t, m.Verify(ctx, "u1", "000000"), ErrInvalidCode)
+	}
+	clk.Advance(m.config.FailureWindow)
+	assert.ErrorIs(t, m.Verify(ctx, "u1", "000000"), ErrInvalidCode, "counter expired after window")
+}
+
+// TestManager_EnforceLockout 策略中间件以请求头验证码二次验证，锁定后返回 429
+func TestManager_EnforceLockout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, _, _, _ := newEnrolledManager(t)
+
+	router := gin.New()
+	router.Use(apperrors.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-User"))
+		c.Set("role", 1)
+	})
+	router.Use(m.EnforcePolicy())
+	router.GET("/admin", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	request := func(user, code string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("X-User", user)
+		if code != "" {
+			req.Header.Set(CodeHeader, code)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, request("u2", ""), "admin without enrollment")
+	for i := 1; i < m.config.MaxFailures; i++ {
+		assert.Equal(t, http.StatusBadRequest, request("u1", "000000"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, request("u1", "000000"))
+}
+
+// TestManager_EnforceFlaggedSession 被标记的会话只认可标记之后签发的两步验证令牌；
+// 更早的令牌以请求头验证码通过后标记仍然有效，标记到期后恢复
+func TestManager_EnforceFlaggedSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m, clk, _, secret := newEnrolledManager(t)
+	m.cache = fakes.NewCache(clk)
+	ctx := context.Background()
+
+	router := gin.New()
+	router.Use(apperrors.ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		c.Set("userID", "u1")
+		c.Set("role", 0)
+		c.Set("amr", []string{AMRPassword, AMROTP})
+		issued, _ := time.Parse(time.RFC3339, c.GetHeader("X-Issued-At"))
+		c.Set("issuedAt", issued)
+	})
+	router.Use(m.EnforcePolicy())
+	router.GET("/orders", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	request := func(issued time.Time, code string) int {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set("X-Issued-At", issued.Format(time.RFC3339))
+		if code != "" {
+			req.Header.Set(CodeHeader, code)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	earlier := clk.Now().Add(-24 * time.Hour)
+	assert.Equal(t, http.StatusNoContent, request(earlier, ""), "session is not flagged")
+
+	require.NoError(t, m.FlagSession(ctx, "u1", "new_location"))
+	assert.True(t, m.IsFlagged(ctx, "u1"))
+	clk.Advance(time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, request(earlier, ""), "otp token issued before the flag")
+	assert.Equal(t, http.StatusNoContent, request(clk.Now(), ""), "otp token issued after the flag")
+
+	assert.Equal(t, http.StatusNoContent, request(earlier, currentCode(t, clk, secret)))
+	assert.True(t, m.IsFlagged(ctx, "u1"), "a one-off code does not clear the flag")
+	assert.Equal(t, http.StatusUnauthorized, request(earlier, ""))
+
+	clk.Advance(m.config.FlagTTL)
+	assert.False(t, m.IsFlagged(ctx, "u1"))
+	assert.Equal(t, http.StatusNoContent, request(earlier, ""))
+}
@@ -0,0 +1,146 @@
+package twofactor
+
+import (
+	"errors"
+	"slices"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AMROTP 令牌中表示已通过两步验证的认证方式
+	AMROTP = "otp"
+	// AMRPassword 密码认证方式
+	AMRPassword = "pwd"
+	// CodeHeader 单次请求携带验证码的请求头，用于敏感操作的即时确认
+	CodeHeader = "X-OTP-Code"
+)
+
+// EnforcePolicy 全局策略中间件，需挂在 AuthMiddleware 之后，检查见 Enforce。
+// 两步验证自身的登记接口不应挂载此中间件
+func (m *Manager) EnforcePolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.Enforce(c) {
+			c.Next()
+		}
+	}
+}
+
+// Enforce 必须开启两步验证的角色未登记时拒绝访问；该角色或被标记的会话需已通过二次验证，
+// 被标记的会话只认可标记之后签发的两步验证令牌（或本次请求携带的验证码）。
+// 不满足时中止请求并返回 false，可作为 middleware.SetAuthPolicies 的策略在每次认证后执行
+func (m *Manager) Enforce(c *gin.Context) bool {
+	userID := c.GetString("userID")
+	if userID == "" {
+		return true
+	}
+
+	required := m.RequiresEnrollment(roleFromContext(c))
+	if required {
+		enrolled, err := m.IsEnrolled(c.Request.Context(), userID)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+			return false
+		}
+		if !enrolled {
+			apperrors.Abort(c, apperrors.New(apperrors.CodeTwoFactorEnrollmentRequired, ""))
+			return false
+		}
+	}
+
+	flaggedAt, flagged := m.FlaggedAt(c.Request.Context(), userID)
+	if required || flagged {
+		return m.satisfied(c, userID, flaggedAt)
+	}
+	return true
+}
+
+// RequireSecondFactor 敏感路由中间件：要求令牌已通过两步验证，或请求头携带有效验证码。
+// 未开启两步验证的用户会被要求先登记
+func (m *Manager) RequireSecondFactor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			apperrors.Abort(c, apperrors.New(apperrors.CodeTokenInvalid, ""))
+			return
+		}
+
+		if hasOTP(c) {
+			c.Next()
+			return
+		}
+
+		enrolled, err := m.IsEnrolled(c.Request.Context(), userID)
+		if err != nil {
+			apperrors.Abort(c, apperrors.Wrap(err, apperrors.CodeInternal, ""))
+			return
+		}
+		if !enrolled {
+			apperrors.Abort(c, apperrors.New(apperrors.CodeTwoFactorEnrollmentRequired, ""))
+			return
+		}
+		if !m.satisfied(c, userID, time.Time{}) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// satisfied 检查令牌或请求头中的二次验证，失败时中止请求。
+// since 不为零时令牌须签发于 since 之后，更早的两步验证令牌需以请求头验证码重新验证
+func (m *Manager) satisfied(c *gin.Context, userID string, since time.Time) bool {
+	if hasOTP(c) && (since.IsZero() || issuedAt(c).After(since)) {
+		return true
+	}
+
+	code := c.GetHeader(CodeHeader)
+	if code == "" {
+		apperrors.Abort(c, apperrors.New(apperrors.CodeTwoFactorRequired, ""))
+		return false
+	}
+	if err := m.Verify(c.Request.Context(), userID, code); err != nil {
+		apperrors.Abort(c, verifyError(err))
+		return false
+	}
+	return true
+}
+
+// verifyError 转换为标准错误
+func verifyError(err error) error {
+	switch {
+	case errors.Is(err, ErrLockedOut):
+		return apperrors.Wrap(err, apperrors.CodeTooManyRequests, "too many failed verification attempts, try again later")
+	case errors.Is(err, ErrInvalidCode):
+		return apperrors.Wrap(err, apperrors.CodeInvalidOTP, "")
+	case errors.Is(err, ErrNotEnrolled), errors.Is(err, ErrNotConfirmed):
+		return apperrors.Wrap(err, apperrors.CodeTwoFactorEnrollmentRequired, "")
+	case errors.Is(err, ErrAlreadyEnrolled):
+		return apperrors.Wrap(err, apperrors.CodeConflict, err.Error())
+	default:
+		return apperrors.Wrap(err, apperrors.CodeInternal, "")
+	}
+}
+
+func hasOTP(c *gin.Context) bool {
+	return slices.Contains(c.GetStringSlice("amr"), AMROTP)
+}
+
+// issuedAt 读取 AuthMiddleware 写入的令牌签发时间，没有时为零值
+func issuedAt(c *gin.Context) time.Time {
+	return c.GetTime("issuedAt")
+}
+
+// roleFromContext 读取 AuthMiddleware 写入的角色
+func roleFromContext(c *gin.Context) int {
+	value, _ := c.Get("role")
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,123 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/lib/pq"
+)
+
+// ErrNotEnrolled 用户未开启两步验证
+var ErrNotEnrolled = errors.New("two-factor authentication is not enrolled")
+
+// Enrollment 用户的两步验证登记信息
+type Enrollment struct {
+	UserID       string         `db:"user_id"`
+	Secret       string         `db:"secret"` // 配置了加密密钥时为密文
+	Confirmed    bool           `db:"confirmed"`
+	BackupCodes  pq.StringArray `db:"backup_codes"`
+	LastUsedStep int64          `db:"last_used_step"`
+	ConfirmedAt  sql.NullTime   `db:"confirmed_at"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+}
+
+// Store 两步验证登记存储
+type Store interface {
+	Get(ctx context.Context, userID string) (*Enrollment, error)
+	Save(ctx context.Context, enrollment *Enrollment) error
+	// AdvanceStep 仅当 step 大于已记录值时更新，返回是否更新成功，用于原子地防止验证码重放
+	AdvanceStep(ctx context.Context, userID string, step int64) (bool, error)
+	// ConsumeBackupCode 原子地移除一个备用码哈希，返回是否存在
+	ConsumeBackupCode(ctx context.Context, userID, hash string) (bool, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// SQLStore 基于 user_two_factor 表的存储
+type SQLStore struct {
+	db *database.DB
+}
+
+// NewSQLStore 创建两步验证存储
+func NewSQLStore(db *database.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Get 获取登记信息，不存在时返回 ErrNotEnrolled
+func (s *SQLStore) Get(ctx context.Context, userID string) (*Enrollment, error) {
+	var enrollment Enrollment
+	query := `
+		SELECT user_id, secret, confirmed, backup_codes, last_used_step, confirmed_at, created_at, updated_at
+		FROM user_two_factor WHERE user_id = $1`
+	err := s.db.GetContext(ctx, &enrollment, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor enrollment: %w", err)
+	}
+	return &enrollment, nil
+}
+
+// Save 新增或覆盖登记信息
+func (s *SQLStore) Save(ctx context.Context, enrollment *Enrollment) error {
+	query := `
+		INSERT INTO user_two_factor (user_id, secret, confirmed, backup_codes, last_used_step, confirmed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			confirmed = EXCLUDED.confirmed,
+			backup_codes = EXCLUDED.backup_codes,
+			last_used_step = EXCLUDED.last_used_step,
+			confirmed_at = EXCLUDED.confirmed_at,
+			updated_at = CURRENT_TIMESTAMP`
+	_, err := s.db.ExecContext(ctx, query,
+		enrollment.UserID, enrollment.Secret, enrollment.Confirmed,
+		enrollment.BackupCodes, enrollment.LastUsedStep, enrollment.ConfirmedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save two-factor enrollment: %w", err)
+	}
+	return nil
+}
+
+// AdvanceStep 实现 Store
+func (s *SQLStore) AdvanceStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE user_two_factor SET last_used_step = $2, updated_at = CURRENT_TIMESTAMP
+		 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to update last used step: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// ConsumeBackupCode 实现 Store
+func (s *SQLStore) ConsumeBackupCode(ctx context.Context, userID, hash string) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE user_two_factor SET backup_codes = array_remove(backup_codes, $2), updated_at = CURRENT_TIMESTAMP
+		 WHERE user_id = $1 AND $2 = ANY(backup_codes)`, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume backup code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// Delete 删除登记信息（关闭两步验证）
+func (s *SQLStore) Delete(ctx context.Context, userID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete two-factor enrollment: %w", err)
+	}
+	return nil
+}
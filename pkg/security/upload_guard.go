@@ -0,0 +1,414 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventUploadRejected 上传被拒绝
+const EventUploadRejected SecurityEventType = "upload_rejected"
+
+// checkedUploadsKey gin 上下文中已检查文件的键
+const checkedUploadsKey = "checked_uploads"
+
+// UploadGuardConfig 上传检查配置
+type UploadGuardConfig struct {
+	MaxSize           int64         `json:"max_size"`  // 单个文件最大字节数
+	MaxFiles          int           `json:"max_files"` // 单次请求最多文件数
+	AllowedExtensions []string      `json:"allowed_extensions"`
+	AllowedMIMETypes  []string      `json:"allowed_mime_types"`
+	ReencodeImages    bool          `json:"reencode_images"`     // 重新编码图片以去除元数据与附加载荷
+	MaxImageDimension int           `json:"max_image_dimension"` // 重新编码前按图片头部检查，宽或高的最大像素数
+	MaxImagePixels    int64         `json:"max_image_pixels"`    // 宽乘高的最大值，避免声明巨大尺寸的小文件解码时耗尽内存
+	ScanTimeout       time.Duration `json:"scan_timeout"`
+}
+
+// DefaultUploadGuardConfig 默认上传检查配置（图片）
+func DefaultUploadGuardConfig() *UploadGuardConfig {
+	return &UploadGuardConfig{
+		MaxSize:           10 << 20,
+		MaxFiles:          9,
+		AllowedExtensions: []string{".jpg", ".jpeg", ".png", ".gif", ".webp"},
+		AllowedMIMETypes:  []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+		ReencodeImages:    true,
+		MaxImageDimension: 10000,
+		MaxImagePixels:    40_000_000,
+		ScanTimeout:       30 * time.Second,
+	}
+}
+
+// ScanResult 扫描结果
+type ScanResult struct {
+	Clean     bool   `json:"clean"`
+	Signature string `json:"signature,omitempty"` // 命中的特征名
+}
+
+// Scanner 病毒扫描接口
+type Scanner interface {
+	Scan(ctx context.Context, filename string, r io.Reader) (*ScanResult, error)
+}
+
+// CheckedFile 通过检查的文件，Data 为可能重新编码后的内容
+type CheckedFile struct {
+	Field       string                `json:"field"`
+	Header      *multipart.FileHeader `json:"-"`
+	Filename    string                `json:"filename"`
+	Extension   string                `json:"extension"`
+	ContentType string                `json:"content_type"`
+	Size        int64                 `json:"size"`
+	Data        []byte                `json:"-"`
+	Reencoded   bool                  `json:"reencoded"`
+}
+
+// UploadGuard 上传检查：大小、扩展名、MIME 嗅探、图片重编码与病毒扫描
+type UploadGuard struct {
+	config     *UploadGuardConfig
+	scanner    Scanner
+	monitor    *SecurityMonitor
+	extensions map[string]bool
+	mimeTypes  map[string]bool
+}
+
+// NewUploadGuard 创建上传检查器，scanner 与 monitor 可为 nil
+func NewUploadGuard(config *UploadGuardConfig, scanner Scanner, monitor *SecurityMonitor) *UploadGuard {
+	if config == nil {
+		config = DefaultUploadGuardConfig()
+	}
+
+	ug := &UploadGuard{
+		config:     config,
+		scanner:    scanner,
+		monitor:    monitor,
+		extensions: make(map[string]bool, len(config.AllowedExtensions)),
+		mimeTypes:  make(map[string]bool, len(config.AllowedMIMETypes)),
+	}
+	for _, ext := range config.AllowedExtensions {
+		ug.extensions[strings.ToLower(ext)] = true
+	}
+	for _, mimeType := range config.AllowedMIMETypes {
+		ug.mimeTypes[strings.ToLower(mimeType)] = true
+	}
+	return ug
+}
+
+// Check 检查单个上传文件
+func (ug *UploadGuard) Check(ctx context.Context, field string, header *multipart.FileHeader) (*CheckedFile, error) {
+	if header.Size > ug.config.MaxSize {
+		return nil, apperrors.Newf(apperrors.CodeFileTooLarge, "file %s exceeds %d bytes", header.Filename, ug.config.MaxSize)
+	}
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if len(ug.extensions) > 0 && !ug.extensions[ext] {
+		return nil, apperrors.Newf(apperrors.CodeUnsupportedFile, "file extension %q is not allowed", ext)
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInvalidParam, "failed to open upload")
+	}
+	defer src.Close()
+
+	// 多读一个字节以识别超出限制的文件（Size 由客户端声明）
+	data, err := io.ReadAll(io.LimitReader(src, ug.config.MaxSize+1))
+	if err != nil {
+		return nil, apperrors.Wrap(err, apperrors.CodeInvalidParam, "failed to read upload")
+	}
+	if int64(len(data)) > ug.config.MaxSize {
+		return nil, apperrors.Newf(apperrors.CodeFileTooLarge, "file %s exceeds %d bytes", header.Filename, ug.config.MaxSize)
+	}
+
+	// 以嗅探结果为准，声明类型不一致视为伪装
+	sniffed := baseMIMEType(http.DetectContentType(data))
+	if len(ug.mimeTypes) > 0 && !ug.mimeTypes[sniffed] {
+		return nil, apperrors.Newf(apperrors.CodeUnsupportedFile, "content type %s is not allowed", sniffed)
+	}
+	declared := baseMIMEType(header.Header.Get("Content-Type"))
+	if declared != "" && declared != "application/octet-stream" && declared != sniffed {
+		return nil, apperrors.Newf(apperrors.CodeUnsupportedFile, "declared content type %s does not match content %s", declared, sniffed)
+	}
+	if extType := baseMIMEType(mime.TypeByExtension(ext)); extType != "" && extType != sniffed {
+		return nil, apperrors.Newf(apperrors.CodeUnsupportedFile, "file extension %s does not match content %s", ext, sniffed)
+	}
+
+	checked := &CheckedFile{
+		Field:       field,
+		Header:      header,
+		Filename:    header.Filename,
+		Extension:   ext,
+		ContentType: sniffed,
+		Data:        data,
+	}
+
+	if ug.config.ReencodeImages {
+		if err := ug.checkImageSize(data, sniffed); err != nil {
+			return nil, err
+		}
+		reencoded, ok, err := reencodeImage(data, sniffed)
+		if err != nil {
+			return nil, apperrors.Wrap(err, apperrors.CodeUnsupportedFile, "invalid image")
+		}
+		if ok {
+			checked.Data = reencoded
+			checked.Reencoded = true
+		}
+	}
+
+	if ug.scanner != nil {
+		scanCtx, cancel := context.WithTimeout(ctx, ug.config.ScanTimeout)
+		defer cancel()
+
+		result, err := ug.scanner.Scan(scanCtx, header.Filename, bytes.NewReader(checked.Data))
+		if err != nil {
+			// 扫描不可用时拒绝，避免未经扫描的文件入库
+			return nil, apperrors.Wrap(err, apperrors.CodeUnavailable, "virus scan unavailable")
+		}
+		if !result.Clean {
+			return nil, apperrors.New(apperrors.CodeMalwareDetected, "").WithDetail("signature", result.Signature)
+		}
+	}
+
+	checked.Size = int64(len(checked.Data))
+	return checked, nil
+}
+
+// Middleware 检查 multipart 请求中的文件；fields 为空时检查所有文件字段。
+// 通过检查的文件可用 CheckedUploads 获取，上传应使用 CheckedFile.Data 而非原始文件。
+func (ug *UploadGuard) Middleware(fields ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxMemory := ug.config.MaxSize * int64(max(ug.config.MaxFiles, 1))
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMemory+1<<20)
+
+		if err := c.Request.ParseMultipartForm(maxMemory); err != nil {
+			ug.reject(c, "", apperrors.Wrap(err, apperrors.CodeInvalidParam, "invalid multipart form"))
+			return
+		}
+
+		files := make(map[string][]*multipart.FileHeader)
+		if len(fields) == 0 {
+			files = c.Request.MultipartForm.File
+		} else {
+			for _, field := range fields {
+				files[field] = c.Request.MultipartForm.File[field]
+			}
+		}
+
+		total := 0
+		for _, headers := range files {
+			total += len(headers)
+		}
+		if ug.config.MaxFiles > 0 && total > ug.config.MaxFiles {
+			ug.reject(c, "", apperrors.Newf(apperrors.CodeInvalidParam, "at most %d files allowed", ug.config.MaxFiles))
+			return
+		}
+
+		checked := make([]*CheckedFile, 0, total)
+		for field, headers := range files {
+			for _, header := range headers {
+				file, err := ug.Check(c.Request.Context(), field, header)
+				if err != nil {
+					ug.reject(c, header.Filename, err)
+					return
+				}
+				checked = append(checked, file)
+			}
+		}
+
+		c.Set(checkedUploadsKey, checked)
+		c.Next()
+	}
+}
+
+// reject 记录安全事件并返回标准错误
+func (ug *UploadGuard) reject(c *gin.Context, filename string, err error) {
+	if ug.monitor != nil {
+		ug.monitor.RecordEvent(c.Request.Context(), SecurityEvent{
+			Type:      EventUploadRejected,
+			Level:     LevelWarning,
+			Source:    "upload_guard",
+			UserID:    c.GetString("userID"),
+			IP:        c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+			Path:      c.Request.URL.Path,
+			Method:    c.Request.Method,
+			Status:    apperrors.From(err).HTTPStatus(),
+			Message:   "Upload rejected",
+			Details: map[string]interface{}{
+				"filename": filename,
+				"reason":   err.Error(),
+			},
+		})
+	}
+
+	apperrors.Render(c, err)
+	c.Abort()
+}
+
+// CheckedUploads 获取通过检查的文件
+func CheckedUploads(c *gin.Context) []*CheckedFile {
+	value, exists := c.Get(checkedUploadsKey)
+	if !exists {
+		return nil
+	}
+	files, _ := value.([]*CheckedFile)
+	return files
+}
+
+// baseMIMEType 去除参数并转为小写
+func baseMIMEType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+// checkImageSize 只读取图片头部，声明的宽高或像素数超出限制时拒绝；重新编码不支持的格式不检查
+func (ug *UploadGuard) checkImageSize(data []byte, contentType string) error {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return apperrors.Wrap(err, apperrors.CodeUnsupportedFile, "invalid image")
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return apperrors.Newf(apperrors.CodeUnsupportedFile, "invalid image dimensions %dx%d", config.Width, config.Height)
+	}
+	if limit := ug.config.MaxImageDimension; limit > 0 && (config.Width > limit || config.Height > limit) {
+		return apperrors.Newf(apperrors.CodeFileTooLarge, "image dimensions %dx%d exceed %d pixels", config.Width, config.Height, limit)
+	}
+	if limit := ug.config.MaxImagePixels; limit > 0 && int64(config.Width)*int64(config.Height) > limit {
+		return apperrors.Newf(apperrors.CodeFileTooLarge, "image dimensions %dx%d exceed %d pixels in total", config.Width, config.Height, limit)
+	}
+	return nil
+}
+
+// reencodeImage 解码并重新编码图片，丢弃 EXIF 等元数据及尾部附加数据。
+// 不支持的格式（如 webp）原样返回 ok=false。
+func reencodeImage(data []byte, contentType string) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, err
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return nil, false, err
+		}
+	case "image/png":
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, err
+		}
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, false, err
+		}
+	case "image/gif":
+		img, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, err
+		}
+		if err := gif.EncodeAll(&buf, img); err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, nil
+	}
+	return buf.Bytes(), true, nil
+}
+
+// ClamdScanner 通过 clamd INSTREAM 协议扫描
+type ClamdScanner struct {
+	Network   string // tcp 或 unix
+	Address   string
+	ChunkSize int
+}
+
+// NewClamdScanner 创建 clamd 扫描器
+func NewClamdScanner(network, address string) *ClamdScanner {
+	return &ClamdScanner{Network: network, Address: address, ChunkSize: 64 << 10}
+}
+
+// Scan 实现 Scanner
+func (cs *ClamdScanner) Scan(ctx context.Context, filename string, r io.Reader) (*ScanResult, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, cs.Network, cs.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to start clamd stream: %w", err)
+	}
+
+	chunk := make([]byte, cs.ChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+			if _, err := conn.Write(chunk[:n]); err != nil {
+				return nil, fmt.Errorf("failed to stream to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to finish clamd stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+
+	// 回复格式：stream: OK / stream: <signature> FOUND / <message> ERROR
+	switch {
+	case strings.HasSuffix(reply, "OK"):
+		return &ScanResult{Clean: true}, nil
+	case strings.HasSuffix(reply, "FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return &ScanResult{Clean: false, Signature: signature}, nil
+	default:
+		return nil, fmt.Errorf("clamd error: %s", reply)
+	}
+}
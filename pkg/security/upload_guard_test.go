@@ -0,0 +1,131 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+	"user_crud_jwt/pkg/apperrors"
+	"user_crud_jwt/pkg/metrics"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG 编码 width x height 的 PNG
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+// withDeclaredSize 改写 PNG 头部 IHDR 声明的宽高并重算校验和，图像数据不变
+func withDeclaredSize(data []byte, width, height uint32) []byte {
+	patched := bytes.Clone(data)
+	binary.BigEndian.PutUint32(patched[16:20], width)
+	binary.BigEndian.PutUint32(patched[20:24], height)
+	binary.BigEndian.PutUint32(patched[29:33], crc32.ChecksumIEEE(patched[12:29]))
+	return patched
+}
+
+// multipartBody 构造只含 files 字段的表单
+func multipartBody(t *testing.T, filename, contentType string, data []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="files"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return &body, writer.FormDataContentType()
+}
+
+// fileHeader 解析表单得到上传文件
+func fileHeader(t *testing.T, filename, contentType string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	body, formType := multipartBody(t, filename, contentType, data)
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", formType)
+	require.NoError(t, req.ParseMultipartForm(1<<20))
+	return req.MultipartForm.File["files"][0]
+}
+
+func TestUploadGuard_Check(t *testing.T) {
+	small := testPNG(t, 4, 4)
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		code     apperrors.Code // 0 表示通过
+	}{
+		{"valid image", "a.png", small, 0},
+		{"trailing payload removed", "a.png", append(bytes.Clone(small), []byte("<?php system($_GET['c']); ?>")...), 0},
+		{"declared width too large", "a.png", withDeclaredSize(small, 20000, 4), apperrors.CodeFileTooLarge},
+		{"declared pixels too large", "a.png", withDeclaredSize(small, 9000, 9000), apperrors.CodeFileTooLarge},
+		{"extension mismatch", "a.jpg", small, apperrors.CodeUnsupportedFile},
+		{"not an image", "a.png", []byte("plain text"), apperrors.CodeUnsupportedFile},
+	}
+
+	guard := NewUploadGuard(nil, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checked, err := guard.Check(context.Background(), "files", fileHeader(t, tt.filename, "image/png", tt.data))
+			if tt.code != 0 {
+				require.Error(t, err)
+				assert.True(t, apperrors.IsCode(err, tt.code), "got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, checked.Reencoded)
+			assert.NotContains(t, string(checked.Data), "<?php")
+			config, _, err := image.DecodeConfig(bytes.NewReader(checked.Data))
+			require.NoError(t, err)
+			assert.Equal(t, 4, config.Width)
+		})
+	}
+}
+
+// TestUploadGuard_Middleware 通过检查的文件交给处理器，被拒绝的上传不进入处理器并记录安全事件
+func TestUploadGuard_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	monitor := NewSecurityMonitor(fakes.NewCache(nil), metrics.GetGlobalCollector(), NewDefaultSecurityLogger())
+	guard := NewUploadGuard(nil, nil, monitor)
+
+	var received []*CheckedFile
+	router := gin.New()
+	router.POST("/upload", guard.Middleware("files"), func(c *gin.Context) {
+		received = CheckedUploads(c)
+		c.Status(http.StatusNoContent)
+	})
+	upload := func(data []byte) int {
+		body, formType := multipartBody(t, "a.png", "image/png", data)
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", formType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, upload(testPNG(t, 4, 4)))
+	require.Len(t, received, 1)
+	assert.True(t, received[0].Reencoded)
+
+	received = nil
+	assert.Equal(t, http.StatusRequestEntityTooLarge, upload(withDeclaredSize(testPNG(t, 4, 4), 50000, 50000)))
+	assert.Nil(t, received)
+	assert.Len(t, monitor.GetEvents(EventUploadRejected, 10), 1)
+}
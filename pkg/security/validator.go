@@ -406,20 +406,46 @@ func (vs *ValidatorSet) Validate(data map[string]interface{}) *ValidationResult
 	for _, rule := range vs.rules {
 		value, exists := data[rule.Field]
 		if !exists {
-			if sv, ok := rule.Validator.(*StringValidator); ok && sv.Required {
-				result.AddError(rule.Field, rule.Message)
+			if isRequired(rule.Validator) {
+				result.AddError(rule.Field, ruleMessage(rule, fmt.Errorf("value is required")))
 			}
 			continue
 		}
 
 		if err := rule.Validator.Validate(value); err != nil {
-			result.AddError(rule.Field, rule.Message)
+			result.AddError(rule.Field, ruleMessage(rule, err))
 		}
 	}
 
 	return result
 }
 
+// ruleMessage 规则未配置提示时使用验证器返回的错误
+func ruleMessage(rule ValidationRule, err error) string {
+	if rule.Message != "" {
+		return rule.Message
+	}
+	return err.Error()
+}
+
+// isRequired 判断验证器是否要求字段必填
+func isRequired(validator Validator) bool {
+	switch v := validator.(type) {
+	case *StringValidator:
+		return v.Required
+	case *EmailValidator:
+		return v.Required
+	case *PhoneValidator:
+		return v.Required
+	case *NumberValidator:
+		return v.Required
+	case *ArrayValidator:
+		return v.Required
+	default:
+		return false
+	}
+}
+
 // SanitizeData 清理数据
 func (vs *ValidatorSet) SanitizeData(data map[string]interface{}) map[string]interface{} {
 	sanitized := make(map[string]interface{})
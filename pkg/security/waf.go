@@ -0,0 +1,622 @@
+package security
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WAFAction 规则命中后的动作
+type WAFAction string
+
+const (
+	WAFActionNone      WAFAction = ""          // 未命中
+	WAFActionLog       WAFAction = "log"       // 只记录安全事件
+	WAFActionChallenge WAFAction = "challenge" // 要求完成人机验证
+	WAFActionBlock     WAFAction = "block"     // 拒绝请求
+)
+
+// severity 动作的严重程度，多条规则命中时取最严重的动作
+func (a WAFAction) severity() int {
+	switch a {
+	case WAFActionLog:
+		return 1
+	case WAFActionChallenge:
+		return 2
+	case WAFActionBlock:
+		return 3
+	}
+	return 0
+}
+
+// WAF 规则的检查目标
+const (
+	WAFTargetPath        = "path"         // 解码后的路径
+	WAFTargetQuery       = "query"        // 解码后的完整查询串
+	WAFTargetParamNames  = "param_names"  // 查询参数名
+	WAFTargetParamValues = "param_values" // 查询参数值
+	WAFTargetHeaders     = "headers"      // 全部请求头，每个值为 "Name: value"
+	WAFTargetUserAgent   = "user_agent"   // 等同 header:User-Agent
+	WAFTargetBody        = "body"         // 文本类请求体的前 MaxBodyBytes 字节
+	WAFTargetMethod      = "method"
+	// WAFTargetHeaderPrefix 单个请求头，如 header:Referer；请求头不存在时按空串匹配
+	WAFTargetHeaderPrefix = "header:"
+)
+
+// WAFRule 请求检查规则：任一目标的任一值匹配正则即命中
+type WAFRule struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	Targets     []string          `json:"targets"`
+	Pattern     string            `json:"pattern"` // RE2 正则，忽略大小写时以 (?i) 开头
+	Score       int               `json:"score"`   // 命中时累计的异常分数
+	Action      WAFAction         `json:"action"`
+	EventType   SecurityEventType `json:"event_type,omitempty"` // 记录的安全事件类型，默认 suspicious
+	Disabled    bool              `json:"disabled,omitempty"`
+}
+
+// WAFRuleSet 规则集。请求的异常分数为命中规则的分数之和，达到阈值时至少执行对应动作
+type WAFRuleSet struct {
+	Version            string    `json:"version"`
+	DetectOnly         bool      `json:"detect_only"`         // 只记录不拦截，用于新规则试运行
+	ChallengeThreshold int       `json:"challenge_threshold"` // 0 表示不按分数质询
+	BlockThreshold     int       `json:"block_threshold"`     // 0 表示不按分数拦截
+	MaxBodyBytes       int64     `json:"max_body_bytes"`      // 请求体检查的字节数，默认 64KB
+	Rules              []WAFRule `json:"rules"`
+}
+
+// defaultWAFMaxBodyBytes 请求体默认检查的字节数
+const defaultWAFMaxBodyBytes = 64 << 10
+
+// DefaultWAFRuleSet 内置规则集：可疑 User-Agent、敏感路径与参数名，只记录不拦截
+func DefaultWAFRuleSet() *WAFRuleSet {
+	return &WAFRuleSet{
+		Version: "builtin",
+		Rules: []WAFRule{
+			{
+				ID:          "ua-missing",
+				Description: "missing User-Agent",
+				Targets:     []string{WAFTargetUserAgent},
+				Pattern:     `^$`,
+				Score:       2,
+				Action:      WAFActionLog,
+			},
+			{
+				ID:          "ua-scanner",
+				Description: "crawler or scanner User-Agent",
+				Targets:     []string{WAFTargetUserAgent},
+				Pattern:     `bot|scanner`,
+				Score:       2,
+				Action:      WAFActionLog,
+			},
+			// /admin 为管理后台的正常路由，不计为可疑，以免管理员的请求累计 IP 信誉分
+			{
+				ID:          "sensitive-path",
+				Description: "access to configuration or diagnostic paths",
+				Targets:     []string{WAFTargetPath},
+				Pattern:     `/(config|system|debug|env|proc)`,
+				Score:       2,
+				Action:      WAFActionLog,
+			},
+			{
+				ID:          "suspicious-param-name",
+				Description: "parameter name suggesting injection probes",
+				Targets:     []string{WAFTargetParamNames},
+				Pattern:     `sql|script|alert`,
+				Score:       2,
+				Action:      WAFActionLog,
+			},
+		},
+	}
+}
+
+// LoadWAFRuleSet 从 JSON 文件加载规则集
+func LoadWAFRuleSet(path string) (*WAFRuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read waf rules: %w", err)
+	}
+	var ruleSet WAFRuleSet
+	if err := json.Unmarshal(data, &ruleSet); err != nil {
+		return nil, fmt.Errorf("failed to parse waf rules %s: %w", path, err)
+	}
+	return &ruleSet, nil
+}
+
+// wafTarget 解析后的检查目标
+type wafTarget struct {
+	kind   string
+	header string
+}
+
+// compiledWAFRule 编译后的规则
+type compiledWAFRule struct {
+	WAFRule
+	pattern *regexp.Regexp
+	targets []wafTarget
+}
+
+// compiledWAFRuleSet 编译后的规则集，加载后只读
+type compiledWAFRuleSet struct {
+	*WAFRuleSet
+	rules       []*compiledWAFRule
+	inspectBody bool
+	loadedAt    time.Time
+}
+
+// compileWAFRuleSet 校验并编译规则集
+func compileWAFRuleSet(ruleSet *WAFRuleSet) (*compiledWAFRuleSet, error) {
+	if ruleSet.MaxBodyBytes <= 0 {
+		ruleSet.MaxBodyBytes = defaultWAFMaxBodyBytes
+	}
+	compiled := &compiledWAFRuleSet{WAFRuleSet: ruleSet, loadedAt: time.Now()}
+	seen := make(map[string]bool, len(ruleSet.Rules))
+	for _, rule := range ruleSet.Rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("waf rule without id")
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("duplicate waf rule id: %s", rule.ID)
+		}
+		seen[rule.ID] = true
+		if rule.Disabled {
+			continue
+		}
+		if rule.Action.severity() == 0 {
+			return nil, fmt.Errorf("waf rule %s: invalid action %q", rule.ID, rule.Action)
+		}
+		if len(rule.Targets) == 0 {
+			return nil, fmt.Errorf("waf rule %s: no targets", rule.ID)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("waf rule %s: invalid pattern: %w", rule.ID, err)
+		}
+		if rule.EventType == "" {
+			rule.EventType = EventSuspicious
+		}
+
+		c := &compiledWAFRule{WAFRule: rule, pattern: pattern}
+		for _, target := range rule.Targets {
+			t, err := parseWAFTarget(target)
+			if err != nil {
+				return nil, fmt.Errorf("waf rule %s: %w", rule.ID, err)
+			}
+			if t.kind == WAFTargetBody {
+				compiled.inspectBody = true
+			}
+			c.targets = append(c.targets, t)
+		}
+		compiled.rules = append(compiled.rules, c)
+	}
+	return compiled, nil
+}
+
+func parseWAFTarget(target string) (wafTarget, error) {
+	switch target {
+	case WAFTargetPath, WAFTargetQuery, WAFTargetParamNames, WAFTargetParamValues,
+		WAFTargetHeaders, WAFTargetBody, WAFTargetMethod:
+		return wafTarget{kind: target}, nil
+	case WAFTargetUserAgent:
+		return wafTarget{kind: WAFTargetHeaderPrefix, header: "User-Agent"}, nil
+	}
+	if name, ok := strings.CutPrefix(target, WAFTargetHeaderPrefix); ok && name != "" {
+		return wafTarget{kind: WAFTargetHeaderPrefix, header: name}, nil
+	}
+	return wafTarget{}, fmt.Errorf("unknown target %q", target)
+}
+
+// WAFMatch 命中的规则
+type WAFMatch struct {
+	RuleID    string            `json:"rule_id"`
+	Target    string            `json:"target"`
+	Value     string            `json:"value"` // 命中的片段，最多 64 字节
+	Score     int               `json:"score"`
+	Action    WAFAction         `json:"action"`
+	EventType SecurityEventType `json:"event_type"`
+}
+
+// WAFResult 请求的检查结果
+type WAFResult struct {
+	Score   int        `json:"score"`
+	Matches []WAFMatch `json:"matches"`
+	Action  WAFAction  `json:"action"`
+}
+
+// Matched 是否命中任何规则
+func (r WAFResult) Matched() bool {
+	return len(r.Matches) > 0
+}
+
+// EventType 分数最高的命中规则的事件类型
+func (r WAFResult) EventType() SecurityEventType {
+	eventType, best := EventSuspicious, -1
+	for _, match := range r.Matches {
+		if match.Score > best {
+			eventType, best = match.EventType, match.Score
+		}
+	}
+	return eventType
+}
+
+// RuleIDs 命中的规则 ID
+func (r WAFResult) RuleIDs() []string {
+	ids := make([]string, len(r.Matches))
+	for i, match := range r.Matches {
+		ids[i] = match.RuleID
+	}
+	return ids
+}
+
+// WAFRuleStats 单条规则的命中统计
+type WAFRuleStats struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	Action      WAFAction `json:"action"`
+	Hits        int64     `json:"hits"`
+	LastHit     time.Time `json:"last_hit,omitempty"`
+}
+
+// WAFStats 规则引擎统计，计数自进程启动起累计
+type WAFStats struct {
+	Version    string         `json:"version"`
+	Source     string         `json:"source"`
+	LoadedAt   time.Time      `json:"loaded_at"`
+	DetectOnly bool           `json:"detect_only"`
+	Inspected  int64          `json:"inspected"`
+	Matched    int64          `json:"matched"`
+	Challenged int64          `json:"challenged"`
+	Blocked    int64          `json:"blocked"`
+	Rules      []WAFRuleStats `json:"rules"` // 按命中次数倒序
+}
+
+// wafRuleCounter 规则的命中计数
+type wafRuleCounter struct {
+	hits    int64
+	lastHit time.Time
+}
+
+// WAF 请求检查规则引擎。规则集可从文件加载并在文件修改后热更新，加载失败时沿用当前规则
+type WAF struct {
+	ruleSet          atomic.Pointer[compiledWAFRuleSet]
+	source           string
+	modTime          time.Time
+	challenger       *WAFChallenger
+	metricsCollector *metrics.MetricsCollector
+
+	inspected, matched, challenged, blocked atomic.Int64
+
+	mu       sync.Mutex
+	counters map[string]*wafRuleCounter
+}
+
+// NewWAF 以给定规则集创建规则引擎，ruleSet 为 nil 时使用内置规则集
+func NewWAF(ruleSet *WAFRuleSet) (*WAF, error) {
+	if ruleSet == nil {
+		ruleSet = DefaultWAFRuleSet()
+	}
+	compiled, err := compileWAFRuleSet(ruleSet)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAF{
+		source:           "builtin",
+		challenger:       NewWAFChallenger(nil),
+		metricsCollector: metrics.GetGlobalCollector(),
+		counters:         make(map[string]*wafRuleCounter),
+	}
+	w.ruleSet.Store(compiled)
+	return w, nil
+}
+
+// LoadWAF 从 JSON 文件创建规则引擎，配合 Watch 在文件修改后热更新
+func LoadWAF(path string) (*WAF, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat waf rules: %w", err)
+	}
+	ruleSet, err := LoadWAFRuleSet(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWAF(ruleSet)
+	if err != nil {
+		return nil, err
+	}
+	w.source = path
+	w.modTime = info.ModTime()
+	return w, nil
+}
+
+// SetChallenger 替换质询器，多实例部署时各实例须使用相同的密钥
+func (w *WAF) SetChallenger(challenger *WAFChallenger) {
+	w.challenger = challenger
+}
+
+// SetRuleSet 替换规则集，校验失败时保留当前规则集
+func (w *WAF) SetRuleSet(ruleSet *WAFRuleSet) error {
+	compiled, err := compileWAFRuleSet(ruleSet)
+	if err != nil {
+		return err
+	}
+	w.ruleSet.Store(compiled)
+	return nil
+}
+
+// Reload 重新加载规则文件
+func (w *WAF) Reload() error {
+	if w.source == "builtin" {
+		return nil
+	}
+	ruleSet, err := LoadWAFRuleSet(w.source)
+	if err != nil {
+		return err
+	}
+	if err := w.SetRuleSet(ruleSet); err != nil {
+		return err
+	}
+	log.Printf("Reloaded waf rules from %s, version %s, %d rules", w.source, ruleSet.Version, len(ruleSet.Rules))
+	return nil
+}
+
+// Watch 每隔 interval 检查规则文件的修改时间，修改后重新加载，直到 ctx 取消。只由一个 goroutine 调用
+func (w *WAF) Watch(ctx context.Context, interval time.Duration) {
+	if w.source == "builtin" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(w.source)
+			if err != nil {
+				log.Printf("Failed to stat waf rules: %v", err)
+				continue
+			}
+			if info.ModTime().Equal(w.modTime) {
+				continue
+			}
+			w.modTime = info.ModTime()
+			if err := w.Reload(); err != nil {
+				log.Printf("Failed to reload waf rules, keeping current rules: %v", err)
+			}
+		}
+	}
+}
+
+// Inspect 按当前规则集检查请求。检查请求体时读取其前 MaxBodyBytes 字节，之后的处理器仍可读到完整的请求体
+func (w *WAF) Inspect(c *gin.Context) WAFResult {
+	ruleSet := w.ruleSet.Load()
+	w.inspected.Add(1)
+
+	var body string
+	if ruleSet.inspectBody {
+		body = peekTextBody(c, ruleSet.MaxBodyBytes)
+	}
+
+	var result WAFResult
+	for _, rule := range ruleSet.rules {
+		target, value, ok := rule.match(c, body)
+		if !ok {
+			continue
+		}
+		result.Score += rule.Score
+		result.Matches = append(result.Matches, WAFMatch{
+			RuleID:    rule.ID,
+			Target:    target,
+			Value:     truncateMatch(value),
+			Score:     rule.Score,
+			Action:    rule.Action,
+			EventType: rule.EventType,
+		})
+		if rule.Action.severity() > result.Action.severity() {
+			result.Action = rule.Action
+		}
+	}
+	if !result.Matched() {
+		return result
+	}
+
+	if ruleSet.BlockThreshold > 0 && result.Score >= ruleSet.BlockThreshold {
+		result.Action = WAFActionBlock
+	} else if ruleSet.ChallengeThreshold > 0 && result.Score >= ruleSet.ChallengeThreshold &&
+		result.Action.severity() < WAFActionChallenge.severity() {
+		result.Action = WAFActionChallenge
+	}
+	if ruleSet.DetectOnly {
+		result.Action = WAFActionLog
+	}
+
+	w.record(result)
+	return result
+}
+
+// record 累计命中统计
+func (w *WAF) record(result WAFResult) {
+	w.matched.Add(1)
+	switch result.Action {
+	case WAFActionChallenge:
+		w.challenged.Add(1)
+	case WAFActionBlock:
+		w.blocked.Add(1)
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	for _, match := range result.Matches {
+		counter, ok := w.counters[match.RuleID]
+		if !ok {
+			counter = &wafRuleCounter{}
+			w.counters[match.RuleID] = counter
+		}
+		counter.hits++
+		counter.lastHit = now
+	}
+	w.mu.Unlock()
+
+	for _, match := range result.Matches {
+		w.metricsCollector.RecordWAFHit(match.RuleID, string(result.Action))
+	}
+}
+
+// Stats 当前规则集的统计，已移除的规则不再列出
+func (w *WAF) Stats() WAFStats {
+	ruleSet := w.ruleSet.Load()
+	stats := WAFStats{
+		Version:    ruleSet.Version,
+		Source:     w.source,
+		LoadedAt:   ruleSet.loadedAt,
+		DetectOnly: ruleSet.DetectOnly,
+		Inspected:  w.inspected.Load(),
+		Matched:    w.matched.Load(),
+		Challenged: w.challenged.Load(),
+		Blocked:    w.blocked.Load(),
+		Rules:      make([]WAFRuleStats, 0, len(ruleSet.rules)),
+	}
+
+	w.mu.Lock()
+	for _, rule := range ruleSet.rules {
+		ruleStats := WAFRuleStats{ID: rule.ID, Description: rule.Description, Action: rule.Action}
+		if counter, ok := w.counters[rule.ID]; ok {
+			ruleStats.Hits = counter.hits
+			ruleStats.LastHit = counter.lastHit
+		}
+		stats.Rules = append(stats.Rules, ruleStats)
+	}
+	w.mu.Unlock()
+
+	sort.SliceStable(stats.Rules, func(i, j int) bool {
+		return stats.Rules[i].Hits > stats.Rules[j].Hits
+	})
+	return stats
+}
+
+// match 返回第一个匹配的目标与值
+func (r *compiledWAFRule) match(c *gin.Context, body string) (string, string, bool) {
+	for _, target := range r.targets {
+		for _, value := range target.values(c, body) {
+			if loc := r.pattern.FindStringIndex(value); loc != nil {
+				name := target.kind
+				if target.kind == WAFTargetHeaderPrefix {
+					name = WAFTargetHeaderPrefix + target.header
+				}
+				return name, value[loc[0]:loc[1]], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// values 目标在请求中的取值
+func (t wafTarget) values(c *gin.Context, body string) []string {
+	req := c.Request
+	switch t.kind {
+	case WAFTargetPath:
+		return []string{req.URL.Path}
+	case WAFTargetQuery:
+		if req.URL.RawQuery == "" {
+			return nil
+		}
+		query, err := url.QueryUnescape(req.URL.RawQuery)
+		if err != nil {
+			query = req.URL.RawQuery
+		}
+		return []string{query}
+	case WAFTargetParamNames:
+		query := req.URL.Query()
+		names := make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name)
+		}
+		return names
+	case WAFTargetParamValues:
+		var values []string
+		for _, vs := range req.URL.Query() {
+			values = append(values, vs...)
+		}
+		return values
+	case WAFTargetHeaders:
+		var values []string
+		for name, vs := range req.Header {
+			for _, v := range vs {
+				values = append(values, name+": "+v)
+			}
+		}
+		return values
+	case WAFTargetHeaderPrefix:
+		return []string{req.Header.Get(t.header)}
+	case WAFTargetBody:
+		if body == "" {
+			return nil
+		}
+		return []string{body}
+	case WAFTargetMethod:
+		return []string{req.Method}
+	}
+	return nil
+}
+
+// peekTextBody 读取文本类请求体的前 limit 字节并放回，二进制与上传内容不检查
+func peekTextBody(c *gin.Context, limit int64) string {
+	req := c.Request
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return ""
+	}
+	contentType := strings.ToLower(req.Header.Get("Content-Type"))
+	textual := false
+	for _, prefix := range []string{"application/json", "application/x-www-form-urlencoded", "application/xml", "text/"} {
+		if strings.HasPrefix(contentType, prefix) {
+			textual = true
+			break
+		}
+	}
+	if !textual {
+		return ""
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, limit))
+	req.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), req.Body), Closer: req.Body}
+	if err != nil {
+		return ""
+	}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if decoded, err := url.QueryUnescape(string(buf)); err == nil {
+			return decoded
+		}
+	}
+	return string(buf)
+}
+
+// replayBody 先返回已读取的部分，再继续读取原请求体
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// truncateMatch 截断命中的片段，避免事件中写入过长的请求内容
+func truncateMatch(value string) string {
+	const max = 64
+	if len(value) <= max {
+		return value
+	}
+	return strings.ToValidUTF8(value[:max], "")
+}
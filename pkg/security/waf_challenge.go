@@ -0,0 +1,164 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WAFChallengeConfig 质询配置
+type WAFChallengeConfig struct {
+	// Secret 签名密钥，为空时随机生成；多实例部署时须配置相同的密钥
+	Secret []byte
+	// Difficulty 工作量证明要求的 SHA-256 前导零比特数
+	Difficulty int
+	// TTL 通过质询后的有效期
+	TTL time.Duration
+	// CookieName 浏览器提交答案的 Cookie
+	CookieName string
+	// Header 接口调用方提交答案的请求头
+	Header string
+}
+
+// DefaultWAFChallengeConfig 默认质询配置
+func DefaultWAFChallengeConfig() *WAFChallengeConfig {
+	return &WAFChallengeConfig{
+		Difficulty: 16,
+		TTL:        30 * time.Minute,
+		CookieName: "_waf_pass",
+		Header:     "X-WAF-Challenge",
+	}
+}
+
+// WAFChallenger 工作量证明质询：服务端签发绑定客户端 IP 与过期时间的种子，
+// 客户端找到计数器使 SHA-256(种子:计数器) 的前导零比特数不少于难度，
+// 之后在有效期内通过 Cookie 或请求头携带 "种子:计数器" 即可放行
+type WAFChallenger struct {
+	config *WAFChallengeConfig
+}
+
+// NewWAFChallenger 创建质询器，config 为 nil 时使用默认配置
+func NewWAFChallenger(config *WAFChallengeConfig) *WAFChallenger {
+	if config == nil {
+		config = DefaultWAFChallengeConfig()
+	}
+	if len(config.Secret) == 0 {
+		config.Secret = make([]byte, 32)
+		if _, err := rand.Read(config.Secret); err != nil {
+			panic(fmt.Sprintf("failed to generate waf challenge secret: %v", err))
+		}
+	}
+	return &WAFChallenger{config: config}
+}
+
+// Verify 请求是否携带了本 IP 有效的答案
+func (ch *WAFChallenger) Verify(c *gin.Context) bool {
+	answer := c.GetHeader(ch.config.Header)
+	if answer == "" {
+		answer, _ = c.Cookie(ch.config.CookieName)
+	}
+	return ch.verify(c.ClientIP(), answer, time.Now())
+}
+
+// Challenge 签发种子并中止请求。浏览器请求返回自动求解后重试的页面，其他请求返回包含种子与难度的错误响应
+func (ch *WAFChallenger) Challenge(c *gin.Context) {
+	seed := ch.issue(c.ClientIP(), time.Now())
+
+	if strings.Contains(c.GetHeader("Accept"), "text/html") {
+		page := fmt.Sprintf(challengePageTemplate,
+			html.EscapeString(apperrors.CodeChallengeRequired.Message(apperrors.Language(c))),
+			strconv.Quote(seed), ch.config.Difficulty, strconv.Quote(ch.config.CookieName), int(ch.config.TTL.Seconds()))
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusForbidden, "text/html; charset=utf-8", []byte(page))
+		c.Abort()
+		return
+	}
+
+	apperrors.Render(c, apperrors.New(apperrors.CodeChallengeRequired, "").
+		WithDetail("seed", seed).
+		WithDetail("difficulty", ch.config.Difficulty).
+		WithDetail("header", ch.config.Header))
+	c.Abort()
+}
+
+// issue 签发种子：过期时间戳.签名
+func (ch *WAFChallenger) issue(ip string, now time.Time) string {
+	expires := strconv.FormatInt(now.Add(ch.config.TTL).Unix(), 10)
+	return expires + "." + ch.sign(ip, expires)
+}
+
+// verify 校验种子签名、有效期与工作量证明
+func (ch *WAFChallenger) verify(ip, answer string, now time.Time) bool {
+	seed, counter, ok := strings.Cut(answer, ":")
+	if !ok || counter == "" || len(counter) > 20 {
+		return false
+	}
+	expires, signature, ok := strings.Cut(seed, ".")
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= expiresAt {
+		return false
+	}
+	if !hmac.Equal([]byte(signature), []byte(ch.sign(ip, expires))) {
+		return false
+	}
+	return leadingZeroBits(sha256.Sum256([]byte(answer))) >= ch.config.Difficulty
+}
+
+func (ch *WAFChallenger) sign(ip, expires string) string {
+	mac := hmac.New(sha256.New, ch.config.Secret)
+	mac.Write([]byte(ip + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// leadingZeroBits 摘要的前导零比特数
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// challengePageTemplate 质询页：在浏览器中求解后写入 Cookie 并重新加载
+const challengePageTemplate = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>403</title></head>
+<body><p>%s</p>
+<script>
+(async function () {
+  var seed = %s, difficulty = %d;
+  function zeros(buf) {
+    var n = 0, bytes = new Uint8Array(buf);
+    for (var i = 0; i < bytes.length; i++) {
+      if (bytes[i] === 0) { n += 8; continue; }
+      return n + Math.clz32(bytes[i]) - 24;
+    }
+    return n;
+  }
+  for (var counter = 0; ; counter++) {
+    var answer = seed + ":" + counter;
+    var digest = await crypto.subtle.digest("SHA-256", new TextEncoder().encode(answer));
+    if (zeros(digest) >= difficulty) {
+      document.cookie = %s + "=" + answer + "; path=/; max-age=%d; SameSite=Lax";
+      location.reload();
+      return;
+    }
+  }
+})();
+</script></body></html>`
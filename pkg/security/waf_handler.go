@@ -0,0 +1,59 @@
+package security
+
+import (
+	"net/http"
+	"time"
+	"user_crud_jwt/pkg/apperrors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SecurityReportQuery 安全报告查询参数
+type SecurityReportQuery struct {
+	Hours int `form:"hours" binding:"omitempty,min=1,max=168"`
+}
+
+// SecurityMonitorHandler 安全报告与 WAF 规则管理接口
+type SecurityMonitorHandler struct {
+	monitor *SecurityMonitor
+}
+
+// NewSecurityMonitorHandler 创建安全报告接口
+func NewSecurityMonitorHandler(monitor *SecurityMonitor) *SecurityMonitorHandler {
+	return &SecurityMonitorHandler{monitor: monitor}
+}
+
+// RegisterAdminRoutes 注册安全报告与 WAF 路由，调用方需挂载管理员权限校验
+func (h *SecurityMonitorHandler) RegisterAdminRoutes(group *gin.RouterGroup) {
+	group.GET("/security/report", h.Report)
+	group.GET("/security/waf", h.WAFStats)
+	group.POST("/security/waf/reload", h.ReloadWAF)
+}
+
+// Report 本实例最近 hours 小时（默认 24）的安全报告，包含 WAF 规则命中统计
+func (h *SecurityMonitorHandler) Report(c *gin.Context) {
+	var query SecurityReportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	if query.Hours == 0 {
+		query.Hours = 24
+	}
+	c.JSON(http.StatusOK, h.monitor.GenerateReport(time.Duration(query.Hours)*time.Hour))
+}
+
+// WAFStats 当前规则集与各规则的命中次数
+func (h *SecurityMonitorHandler) WAFStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.monitor.WAF().Stats())
+}
+
+// ReloadWAF 立即重新加载规则文件，失败时保留当前规则
+func (h *SecurityMonitorHandler) ReloadWAF(c *gin.Context) {
+	waf := h.monitor.WAF()
+	if err := waf.Reload(); err != nil {
+		apperrors.Render(c, apperrors.Wrap(err, apperrors.CodeInvalidParam, ""))
+		return
+	}
+	c.JSON(http.StatusOK, waf.Stats())
+}
@@ -0,0 +1,270 @@
+package security
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testWAFRuleSet() *WAFRuleSet {
+	return &WAFRuleSet{
+		Version:            "test",
+		ChallengeThreshold: 10,
+		BlockThreshold:     20,
+		Rules: []WAFRule{
+			{ID: "bot", Targets: []string{WAFTargetUserAgent}, Pattern: `(?i)bot`, Score: 2, Action: WAFActionLog},
+			{ID: "sqli", Targets: []string{WAFTargetParamValues, WAFTargetBody}, Pattern: `(?i)union\s+select`, Score: 10, Action: WAFActionLog, EventType: EventSQLInjection},
+			{ID: "xss", Targets: []string{WAFTargetBody, "header:Referer"}, Pattern: `(?i)<script`, Score: 10, Action: WAFActionLog, EventType: EventXSS},
+			{ID: "scanner", Targets: []string{WAFTargetUserAgent}, Pattern: `sqlmap`, Score: 1, Action: WAFActionBlock},
+			{ID: "disabled", Targets: []string{WAFTargetPath}, Pattern: `.`, Score: 100, Action: WAFActionBlock, Disabled: true},
+		},
+	}
+}
+
+// newWAFRouter 挂载安全监控中间件的测试路由，处理器回显请求体
+func newWAFRouter(t *testing.T, waf *WAF) (*gin.Engine, *SecurityMonitor) {
+	gin.SetMode(gin.TestMode)
+	monitor := NewSecurityMonitor(cache.NewMemoryCache(), metrics.GetGlobalCollector(), NewDefaultSecurityLogger())
+	monitor.SetWAF(waf)
+
+	router := gin.New()
+	router.Use(NewSecurityMonitoringMiddleware(monitor).Middleware())
+	router.Any("/echo", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		c.String(http.StatusOK, string(body))
+	})
+	return router, monitor
+}
+
+func wafRequest(method, target, body, userAgent string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("User-Agent", userAgent)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// TestWAF_DefaultRuleSetOnlyLogs 内置规则只记录可疑请求，不拦截
+func TestWAF_DefaultRuleSetOnlyLogs(t *testing.T) {
+	waf, err := NewWAF(nil)
+	require.NoError(t, err)
+	router, monitor := newWAFRouter(t, waf)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodGet, "/echo?script=1", "", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	events := monitor.getEventsInPeriod(time.Minute)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventSuspicious, events[0].Type)
+	assert.Equal(t, []string{"ua-missing", "suspicious-param-name"}, events[0].Details["waf_rules"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodGet, "/echo", "", "Mozilla/5.0"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, monitor.getEventsInPeriod(time.Minute), 1)
+}
+
+// TestWAF_AnomalyScoring 分数达到阈值时质询或拦截，单条拦截规则直接拦截；检查后处理器仍能读到完整请求体
+func TestWAF_AnomalyScoring(t *testing.T) {
+	waf, err := NewWAF(testWAFRuleSet())
+	require.NoError(t, err)
+	router, monitor := newWAFRouter(t, waf)
+
+	// 2 分只记录
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodPost, "/echo", `{"name":"ok"}`, "friendly-bot"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"name":"ok"}`, w.Body.String())
+
+	// 10 分质询
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodGet, "/echo?q=1+UNION+SELECT+password", "", "Mozilla/5.0"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":50015`)
+
+	// 20 分拦截，请求体超过检查长度的部分不影响处理
+	body := `{"bio":"<script>alert(1)</script> union select 1","padding":"` + strings.Repeat("x", 100<<10) + `"}`
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodPost, "/echo", body, "Mozilla/5.0"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":50014`)
+
+	// 拦截规则 1 分也直接拦截
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodGet, "/echo", "", "sqlmap/1.7"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 未命中规则时大请求体完整传给处理器
+	body = `{"padding":"` + strings.Repeat("y", 100<<10) + `"}`
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodPost, "/echo", body, "Mozilla/5.0"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+
+	stats := waf.Stats()
+	assert.Equal(t, int64(5), stats.Inspected)
+	assert.Equal(t, int64(4), stats.Matched)
+	assert.Equal(t, int64(1), stats.Challenged)
+	assert.Equal(t, int64(2), stats.Blocked)
+	hits := make(map[string]int64)
+	for _, rule := range stats.Rules {
+		hits[rule.ID] = rule.Hits
+	}
+	assert.Equal(t, map[string]int64{"bot": 1, "sqli": 2, "xss": 1, "scanner": 1}, hits)
+	assert.Equal(t, "sqli", stats.Rules[0].ID)
+
+	// 安全报告包含规则命中统计，事件类型取分数最高的规则
+	report := monitor.GenerateReport(time.Hour)
+	require.NotNil(t, report.WAF)
+	assert.Equal(t, int64(2), report.WAF.Blocked)
+	assert.Equal(t, EventSQLInjection, monitor.getEventsInPeriod(time.Minute)[1].Type)
+}
+
+// TestWAF_DetectOnly 试运行模式只记录
+func TestWAF_DetectOnly(t *testing.T) {
+	ruleSet := testWAFRuleSet()
+	ruleSet.DetectOnly = true
+	waf, err := NewWAF(ruleSet)
+	require.NoError(t, err)
+
+	router, _ := newWAFRouter(t, waf)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodGet, "/echo", "", "sqlmap/1.7"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Zero(t, waf.Stats().Blocked)
+}
+
+// TestWAF_Challenge 完成工作量证明后在有效期内放行
+func TestWAF_Challenge(t *testing.T) {
+	waf, err := NewWAF(testWAFRuleSet())
+	require.NoError(t, err)
+	challenge := DefaultWAFChallengeConfig()
+	challenge.Difficulty = 8
+	waf.SetChallenger(NewWAFChallenger(challenge))
+	router, _ := newWAFRouter(t, waf)
+
+	target := "/echo?q=union+select+1"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, wafRequest(http.MethodGet, target, "", "Mozilla/5.0"))
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	var resp struct {
+		Details struct {
+			Seed       string `json:"seed"`
+			Difficulty int    `json:"difficulty"`
+		} `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.NotEmpty(t, resp.Details.Seed)
+
+	var answer string
+	for counter := 0; ; counter++ {
+		answer = resp.Details.Seed + ":" + strconv.Itoa(counter)
+		if leadingZeroBits(sha256.Sum256([]byte(answer))) >= resp.Details.Difficulty {
+			break
+		}
+	}
+
+	req := wafRequest(http.MethodGet, target, "", "Mozilla/5.0")
+	req.Header.Set("X-WAF-Challenge", answer)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// 答案绑定 IP
+	req = wafRequest(http.MethodGet, target, "", "Mozilla/5.0")
+	req.RemoteAddr = "198.51.100.9:1234"
+	req.Header.Set("X-WAF-Challenge", answer)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 浏览器得到求解页
+	req = wafRequest(http.MethodGet, target, "", "Mozilla/5.0")
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "crypto.subtle.digest")
+}
+
+// TestWAF_HotReload 规则文件修改后重新加载，文件无效时保留当前规则
+func TestWAF_HotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waf_rules.json")
+	writeRuleSet := func(ruleSet *WAFRuleSet, modTime time.Time) {
+		data, err := json.Marshal(ruleSet)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	start := time.Now().Add(-time.Hour)
+	writeRuleSet(&WAFRuleSet{Version: "v1", Rules: []WAFRule{
+		{ID: "probe", Targets: []string{WAFTargetPath}, Pattern: `^/\.env`, Score: 1, Action: WAFActionBlock},
+	}}, start)
+
+	waf, err := LoadWAF(path)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go waf.Watch(ctx, 10*time.Millisecond)
+
+	router, _ := newWAFRouter(t, waf)
+	status := func(target string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, wafRequest(http.MethodGet, target, "", "Mozilla/5.0"))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusForbidden, status("/.env"))
+	assert.Equal(t, http.StatusNotFound, status("/.git/config"))
+
+	writeRuleSet(&WAFRuleSet{Version: "v2", Rules: []WAFRule{
+		{ID: "probe", Targets: []string{WAFTargetPath}, Pattern: `^/\.(env|git)`, Score: 1, Action: WAFActionBlock},
+	}}, start.Add(time.Minute))
+	require.Eventually(t, func() bool { return waf.Stats().Version == "v2" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusForbidden, status("/.git/config"))
+
+	// 无效的正则不替换当前规则
+	writeRuleSet(&WAFRuleSet{Version: "v3", Rules: []WAFRule{
+		{ID: "broken", Targets: []string{WAFTargetPath}, Pattern: `(`, Score: 1, Action: WAFActionBlock},
+	}}, start.Add(2*time.Minute))
+	assert.Error(t, waf.Reload())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "v2", waf.Stats().Version)
+}
+
+// TestCompileWAFRuleSet_Invalid 规则集校验
+func TestCompileWAFRuleSet_Invalid(t *testing.T) {
+	cases := map[string]WAFRule{
+		"missing id":     {Targets: []string{WAFTargetPath}, Pattern: `x`, Action: WAFActionLog},
+		"invalid action": {ID: "a", Targets: []string{WAFTargetPath}, Pattern: `x`, Action: "drop"},
+		"no targets":     {ID: "a", Pattern: `x`, Action: WAFActionLog},
+		"unknown target": {ID: "a", Targets: []string{"cookie"}, Pattern: `x`, Action: WAFActionLog},
+		"bad pattern":    {ID: "a", Targets: []string{WAFTargetPath}, Pattern: `(`, Action: WAFActionLog},
+	}
+	for name, rule := range cases {
+		_, err := compileWAFRuleSet(&WAFRuleSet{Rules: []WAFRule{rule}})
+		assert.Error(t, err, name)
+	}
+
+	rule := WAFRule{ID: "a", Targets: []string{WAFTargetPath}, Pattern: `x`, Action: WAFActionLog}
+	_, err := compileWAFRuleSet(&WAFRuleSet{Rules: []WAFRule{rule, rule}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,216 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format 表格文件格式
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ErrUnsupportedFormat 不支持的文件格式
+var ErrUnsupportedFormat = errors.New("unsupported spreadsheet format, expected csv or xlsx")
+
+// utf8BOM Excel 依赖 BOM 识别 UTF-8 编码的 CSV
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseFormat 按格式名或文件扩展名解析格式，如 "csv"、"users.xlsx"
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if ext := filepath.Ext(name); ext != "" {
+		name = strings.TrimPrefix(ext, ".")
+	}
+	switch Format(name) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatXLSX:
+		return FormatXLSX, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ContentType 下载时使用的内容类型
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Reader 逐行读取表格
+type Reader interface {
+	// Read 返回下一行，读完时返回 io.EOF
+	Read() ([]string, error)
+	Close() error
+}
+
+// NewReader 创建表格读取器。CSV 边读边解析；
+// XLSX 为 zip 格式需整体读入，工作表超过 16MB 时由 excelize 解压到临时文件，之后同样按行迭代
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		br := bufio.NewReader(r)
+		if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+			br.Discard(len(utf8BOM))
+		}
+		cr := csv.NewReader(br)
+		cr.FieldsPerRecord = -1 // 行的列数可以不同，缺失的列按空值处理
+		cr.TrimLeadingSpace = true
+		return &csvReader{reader: cr}, nil
+	case FormatXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xlsx: %w", err)
+		}
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			f.Close()
+			return nil, fmt.Errorf("failed to open xlsx: no worksheet")
+		}
+		rows, err := f.Rows(sheets[0])
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read worksheet %s: %w", sheets[0], err)
+		}
+		return &xlsxReader{file: f, rows: rows}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvReader struct {
+	reader *csv.Reader
+}
+
+func (r *csvReader) Read() ([]string, error) {
+	return r.reader.Read()
+}
+
+func (r *csvReader) Close() error {
+	return nil
+}
+
+// xlsxReader 只读取第一个工作表
+type xlsxReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+}
+
+func (r *xlsxReader) Read() ([]string, error) {
+	if !r.rows.Next() {
+		if err := r.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return r.rows.Columns()
+}
+
+func (r *xlsxReader) Close() error {
+	r.rows.Close()
+	return r.file.Close()
+}
+
+// Writer 逐行写出表格
+type Writer interface {
+	Write(row []string) error
+	// Close 写出剩余数据。XLSX 在此时才写出完整文件，期间超过 16MB 的行数据暂存在临时文件中
+	Close() error
+}
+
+// NewWriter 创建表格写出器
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		if _, err := w.Write(utf8BOM); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		f := excelize.NewFile()
+		sw, err := f.NewStreamWriter(f.GetSheetList()[0])
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create xlsx stream writer: %w", err)
+		}
+		return &xlsxWriter{out: w, file: f, stream: sw}, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(row []string) error {
+	escaped := make([]string, len(row))
+	for i, value := range row {
+		escaped[i] = escapeFormula(value)
+	}
+	return w.writer.Write(escaped)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// escapeFormula 防止 CSV 注入：以公式字符开头的文本在打开时会被电子表格当作公式执行，
+// 加单引号前缀使其按文本显示；负数等合法数字保持原样
+func escapeFormula(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+// Write 单元格均按文本写入，不会被解释为公式
+func (w *xlsxWriter) Write(row []string) error {
+	w.row++
+	if w.row > excelize.TotalRows {
+		return fmt.Errorf("xlsx supports at most %d rows", excelize.TotalRows)
+	}
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(row))
+	for i, value := range row {
+		values[i] = value
+	}
+	return w.stream.SetRow(cell, values)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to flush xlsx rows: %w", err)
+	}
+	if err := w.file.Write(w.out); err != nil {
+		return fmt.Errorf("failed to write xlsx: %w", err)
+	}
+	return nil
+}
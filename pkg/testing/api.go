@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+	"user_crud_jwt/pkg/openapi"
 )
 
 // APITest API 性能测试
 type APITest struct {
-	baseURL string
-	client  *http.Client
+	baseURL  string
+	client   *http.Client
+	contract *Contract // 非空时每个响应都按 OpenAPI 文档校验
 }
 
 // NewAPITest 创建 API 测试
@@ -25,15 +27,49 @@ func NewAPITest(baseURL string) *APITest {
 	}
 }
 
+// WithContract 开启契约校验，不符合文档的响应按请求失败处理
+func (at *APITest) WithContract(contract *Contract) *APITest {
+	at.contract = contract
+	return at
+}
+
+// LoadContract 从被测服务加载 OpenAPI 文档并开启契约校验
+func (at *APITest) LoadContract(ctx context.Context) error {
+	contract, err := LoadContract(ctx, at.client, at.baseURL+openapi.SpecPath)
+	if err != nil {
+		return err
+	}
+	at.WithContract(contract)
+	return nil
+}
+
+// Contract 返回当前的契约校验器，未开启时为 nil
+func (at *APITest) Contract() *Contract {
+	return at.contract
+}
+
+// do 发送请求，开启契约校验时同时校验响应
+func (at *APITest) do(req *http.Request) (*http.Response, error) {
+	resp, err := at.client.Do(req)
+	if err != nil || at.contract == nil {
+		return resp, err
+	}
+	if err := at.contract.ValidateResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
 // HealthCheckTest 健康检查测试
 func (at *APITest) HealthCheckTest() RequestFunc {
 	return func(ctx context.Context) error {
-		req, err := http.NewRequestWithContext(ctx, "GET", at.baseURL+"/health", nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", at.baseURL+"/healthz", nil)
 		if err != nil {
 			return err
 		}
 
-		resp, err := at.client.Do(req)
+		resp, err := at.do(req)
 		if err != nil {
 			return err
 		}
@@ -59,7 +95,7 @@ func (at *APITest) UserListTest(token string) RequestFunc {
 			req.Header.Set("Authorization", "Bearer "+token)
 		}
 
-		resp, err := at.client.Do(req)
+		resp, err := at.do(req)
 		if err != nil {
 			return err
 		}
@@ -94,7 +130,7 @@ func (at *APITest) LoginTest(mobile, code string) RequestFunc {
 
 		req.Header.Set("Content-Type", "application/json")
 
-		resp, err := at.client.Do(req)
+		resp, err := at.do(req)
 		if err != nil {
 			return err
 		}
@@ -125,7 +161,7 @@ func (at *APITest) UploadTest(token string) RequestFunc {
 		}
 		req.Header.Set("Content-Type", "application/octet-stream")
 
-		resp, err := at.client.Do(req)
+		resp, err := at.do(req)
 		if err != nil {
 			return err
 		}
@@ -190,6 +226,48 @@ func (at *APITest) RunAPITests() {
 	fmt.Println("✅ API 性能测试完成")
 }
 
+// RunContractTests 每个接口请求一次并按 OpenAPI 文档校验响应，返回违规数量
+func (at *APITest) RunContractTests() int {
+	fmt.Println("📜 开始契约测试")
+	fmt.Println("================================")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	if at.contract == nil {
+		if err := at.LoadContract(ctx); err != nil {
+			fmt.Printf("❌ 加载 OpenAPI 文档失败: %v\n", err)
+			return 1
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		request RequestFunc
+	}{
+		{"health_check", at.HealthCheckTest()},
+		{"user_list", at.UserListTest("")},
+		{"login", at.LoginTest("13800138000", "123456")},
+		{"upload", at.UploadTest("")},
+	}
+	for _, tc := range testCases {
+		if err := tc.request(ctx); err != nil {
+			fmt.Printf("❌ %-15s %v\n", tc.name, err)
+			continue
+		}
+		fmt.Printf("✅ %-15s\n", tc.name)
+	}
+
+	violations := at.contract.Violations()
+	fmt.Println("================================")
+	if len(violations) > 0 {
+		fmt.Printf("❌ 契约测试发现 %d 处违规\n", len(violations))
+	} else {
+		fmt.Println("✅ 契约测试通过")
+	}
+	return len(violations)
+}
+
 // RunLoadTest 运行负载测试
 func (at *APITest) RunLoadTest() {
 	fmt.Println("🔄 开始负载测试")
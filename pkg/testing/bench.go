@@ -0,0 +1,593 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	gotesting "testing"
+	"text/tabwriter"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/testing/fakes"
+)
+
+// 基准测试的三组用例
+const (
+	SuiteCache  = "cache"
+	SuiteCodec  = "codec"
+	SuiteWarmup = "warmup"
+)
+
+// CacheCandidate 参与对比的一级缓存实现
+type CacheCandidate struct {
+	Name string
+	New  func() cache.CacheService
+}
+
+// DefaultCacheCandidates 项目中的一级缓存实现：memory 为多级缓存使用的 cache.MemoryCache（保存原值，读取时序列化），
+// json_bytes 写入时即序列化为 JSON 并按时钟判断过期（fakes.Cache，与 Redis 的存储方式相同）
+func DefaultCacheCandidates() []CacheCandidate {
+	return []CacheCandidate{
+		{Name: "memory", New: cache.NewMemoryCache},
+		{Name: "json_bytes", New: func() cache.CacheService { return fakes.NewCache(nil) }},
+	}
+}
+
+// Codec 参与对比的序列化方式
+type Codec struct {
+	Name      string
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+// DefaultCodecs 标准库中的序列化方式：json 为缓存当前使用的格式，gob 每个值单独编码（包含类型信息）
+func DefaultCodecs() []Codec {
+	return []Codec{
+		{Name: "json", Marshal: json.Marshal, Unmarshal: json.Unmarshal},
+		{Name: "gob", Marshal: gobMarshal, Unmarshal: gobUnmarshal},
+	}
+}
+
+// gobMarshal 以 gob 编码单个值
+func gobMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gobUnmarshal 以 gob 解码单个值
+func gobUnmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// DefaultWarmupStrategies 参与对比的预热策略。progressive 每一级之间固定等待 2 秒，
+// 耗时主要取决于等待而不是实现，默认不参与，需要时在 BenchConfig.WarmupStrategies 中加入
+func DefaultWarmupStrategies() []string {
+	return []string{"immediate", "batch", "priority", "smart"}
+}
+
+// BenchConfig 基准测试配置，Caches、Codecs、WarmupStrategies 为空时跳过对应的用例组
+type BenchConfig struct {
+	Distributions    []Distribution   `json:"distributions"`
+	Caches           []CacheCandidate `json:"-"`
+	Codecs           []Codec          `json:"-"`
+	WarmupStrategies []string         `json:"warmup_strategies"`
+	// WarmupCache 预热用例使用的缓存，为 nil 时使用 cache.NewMemoryCache
+	WarmupCache func() cache.CacheService `json:"-"`
+	// Ops 缓存与序列化用例的操作次数，也是预热后验证命中率的访问次数
+	Ops int `json:"ops"`
+	// Concurrency 缓存与序列化用例的并发数
+	Concurrency int `json:"concurrency"`
+	// WarmupKeys 预热的热点键数量
+	WarmupKeys int           `json:"warmup_keys"`
+	TTL        time.Duration `json:"ttl"`
+	Seed       int64         `json:"seed"`
+}
+
+// DefaultBenchConfig 默认配置：一万个键的均匀分布与 Zipf(1.1) 分布，每个用例十万次操作
+func DefaultBenchConfig() *BenchConfig {
+	return &BenchConfig{
+		Distributions:    []Distribution{Uniform(10000), Zipfian(10000, 1.1)},
+		Caches:           DefaultCacheCandidates(),
+		Codecs:           DefaultCodecs(),
+		WarmupStrategies: DefaultWarmupStrategies(),
+		Ops:              100000,
+		Concurrency:      4,
+		WarmupKeys:       1000,
+		TTL:              time.Minute,
+		Seed:             1,
+	}
+}
+
+// BenchResult 一个用例的结果，同一 Suite 与 Distribution 下的结果可以直接比较
+type BenchResult struct {
+	Suite        string        `json:"suite"`
+	Candidate    string        `json:"candidate"`
+	Distribution string        `json:"distribution"`
+	Ops          int           `json:"ops"`
+	Duration     time.Duration `json:"duration"`
+	NsPerOp      float64       `json:"ns_per_op"`
+	OpsPerSec    float64       `json:"ops_per_sec"`
+	AllocsPerOp  float64       `json:"allocs_per_op"`
+	BytesPerOp   float64       `json:"bytes_per_op"`
+	// HitRatio 缓存用例为读穿透访问的命中率，预热用例为预热后按分布访问的命中率
+	HitRatio float64 `json:"hit_ratio"`
+	Errors   int64   `json:"errors"`
+	// Extra 用例特有的指标，如序列化后的平均字节数 encoded_bytes、预热耗时 warmup_ms
+	Extra map[string]float64 `json:"extra,omitempty"`
+}
+
+// BenchReport 基准测试报告
+type BenchReport struct {
+	Config    *BenchConfig  `json:"config"`
+	GoVersion string        `json:"go_version"`
+	CPUs      int           `json:"cpus"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Results   []BenchResult `json:"results"`
+}
+
+// RunBenchmarks 依次运行配置中的全部用例，config 为 nil 时使用默认配置；ctx 取消时返回已完成的结果
+func RunBenchmarks(ctx context.Context, config *BenchConfig) (*BenchReport, error) {
+	if config == nil {
+		config = DefaultBenchConfig()
+	}
+	if config.Ops <= 0 {
+		return nil, fmt.Errorf("bench ops must be positive")
+	}
+	if len(config.Distributions) == 0 {
+		return nil, fmt.Errorf("bench requires at least one distribution")
+	}
+	for _, dist := range config.Distributions {
+		if err := dist.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &BenchReport{
+		Config:    config,
+		GoVersion: runtime.Version(),
+		CPUs:      runtime.NumCPU(),
+		StartedAt: time.Now(),
+	}
+	defer func() { report.Duration = time.Since(report.StartedAt) }()
+
+	for _, dist := range config.Distributions {
+		sample := dist.Sample(config.Seed, config.Ops)
+
+		for _, candidate := range config.Caches {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			w := newCacheWorkload(candidate.New(), sample, config.TTL)
+			result := measure(config.Ops, config.Concurrency, w.op)
+			report.add(SuiteCache, candidate.Name, dist, result)
+		}
+
+		for _, codec := range config.Codecs {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			w, err := newCodecWorkload(codec, sample)
+			if err != nil {
+				return report, err
+			}
+			result := measure(config.Ops, config.Concurrency, w.op)
+			result.extra = map[string]float64{"encoded_bytes": w.encodedBytes()}
+			report.add(SuiteCodec, codec.Name, dist, result)
+		}
+
+		for _, strategy := range config.WarmupStrategies {
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			result, err := runWarmup(ctx, config, strategy, sample)
+			if err != nil {
+				return report, err
+			}
+			report.add(SuiteWarmup, strategy, dist, result)
+		}
+	}
+	return report, nil
+}
+
+// add 记录一个用例的结果
+func (r *BenchReport) add(suite, candidate string, dist Distribution, m *measurement) {
+	result := BenchResult{
+		Suite:        suite,
+		Candidate:    candidate,
+		Distribution: dist.Name,
+		Ops:          m.ops,
+		Duration:     m.elapsed,
+		Errors:       m.errors,
+		Extra:        m.extra,
+	}
+	if m.ops > 0 {
+		result.NsPerOp = float64(m.elapsed.Nanoseconds()) / float64(m.ops)
+		result.AllocsPerOp = float64(m.allocs) / float64(m.ops)
+		result.BytesPerOp = float64(m.bytes) / float64(m.ops)
+		result.HitRatio = float64(m.hits) / float64(m.ops)
+	}
+	if m.elapsed > 0 {
+		result.OpsPerSec = float64(m.ops) / m.elapsed.Seconds()
+	}
+	if m.hitRatio >= 0 {
+		result.HitRatio = m.hitRatio
+	}
+	r.Results = append(r.Results, result)
+}
+
+// Best 某组用例在某个分布下每次操作最快的结果，没有结果时返回 nil
+func (r *BenchReport) Best(suite, distribution string) *BenchResult {
+	var best *BenchResult
+	for i := range r.Results {
+		result := &r.Results[i]
+		if result.Suite != suite || result.Distribution != distribution {
+			continue
+		}
+		if best == nil || result.NsPerOp < best.NsPerOp {
+			best = result
+		}
+	}
+	return best
+}
+
+// Print 按用例组与分布输出对比表，相对值以同组最快的结果为 1
+func (r *BenchReport) Print(w io.Writer) {
+	fmt.Fprintln(w, "--------------------------------------------------")
+	fmt.Fprintf(w, "基准测试: %s，%d 核，耗时 %v\n", r.GoVersion, r.CPUs, r.Duration.Round(time.Millisecond))
+
+	type group struct{ suite, distribution string }
+	var groups []group
+	seen := make(map[group]bool)
+	for _, result := range r.Results {
+		g := group{result.Suite, result.Distribution}
+		if !seen[g] {
+			seen[g] = true
+			groups = append(groups, g)
+		}
+	}
+
+	for _, g := range groups {
+		results := make([]BenchResult, 0)
+		for _, result := range r.Results {
+			if result.Suite == g.suite && result.Distribution == g.distribution {
+				results = append(results, result)
+			}
+		}
+		sort.SliceStable(results, func(a, b int) bool { return results[a].NsPerOp < results[b].NsPerOp })
+		best := results[0].NsPerOp
+
+		fmt.Fprintf(w, "\n[%s] %s\n", g.suite, g.distribution)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "候选\tns/op\tops/s\tallocs/op\tB/op\t命中率\t相对\t附加\t")
+		for _, result := range results {
+			relative := 1.0
+			if best > 0 {
+				relative = result.NsPerOp / best
+			}
+			fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t%.1f\t%.0f\t%s\t%.2fx\t%s\t\n",
+				result.Candidate, result.NsPerOp, result.OpsPerSec, result.AllocsPerOp, result.BytesPerOp,
+				formatHitRatio(g.suite, result.HitRatio), relative, formatExtra(result))
+		}
+		tw.Flush()
+	}
+	fmt.Fprintln(w, "--------------------------------------------------")
+}
+
+// formatHitRatio 序列化用例没有命中率
+func formatHitRatio(suite string, ratio float64) string {
+	if suite == SuiteCodec {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", ratio*100)
+}
+
+// formatExtra 附加指标按名称排序输出
+func formatExtra(result BenchResult) string {
+	if len(result.Extra) == 0 && result.Errors == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(result.Extra))
+	for name := range result.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s=%.1f ", name, result.Extra[name])
+	}
+	if result.Errors > 0 {
+		fmt.Fprintf(&buf, "errors=%d ", result.Errors)
+	}
+	return string(bytes.TrimSpace(buf.Bytes()))
+}
+
+// measurement 一次计时的原始数据，hitRatio 小于 0 时按 hits/ops 计算
+type measurement struct {
+	ops      int
+	elapsed  time.Duration
+	hits     int64
+	errors   int64
+	allocs   uint64
+	bytes    uint64
+	hitRatio float64
+	extra    map[string]float64
+}
+
+// measure 以 concurrency 个协程执行 ops 次 op，统计耗时与内存分配（包含并发期间其他协程的分配）
+func measure(ops, concurrency int, op func(ctx context.Context, i int) (bool, error)) *measurement {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	ctx := context.Background()
+	m := &measurement{ops: ops, hitRatio: -1}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	var next atomic.Int64
+	var hits, errors atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= ops {
+					return
+				}
+				hit, err := op(ctx, i)
+				if err != nil {
+					errors.Add(1)
+				} else if hit {
+					hits.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	m.elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	m.allocs = after.Mallocs - before.Mallocs
+	m.bytes = after.TotalAlloc - before.TotalAlloc
+	m.hits, m.errors = hits.Load(), errors.Load()
+	return m
+}
+
+// cacheWorkload 读穿透访问：按样本读取键，未命中时写入对应的值
+type cacheWorkload struct {
+	cache  cache.CacheService
+	sample []int
+	ttl    time.Duration
+}
+
+// newCacheWorkload 创建缓存用例
+func newCacheWorkload(c cache.CacheService, sample []int, ttl time.Duration) *cacheWorkload {
+	return &cacheWorkload{cache: c, sample: sample, ttl: ttl}
+}
+
+// op 第 i 次访问，返回是否命中
+func (w *cacheWorkload) op(ctx context.Context, i int) (bool, error) {
+	id := w.sample[i%len(w.sample)]
+	key := BenchKey(id)
+	var value BenchPayload
+	if err := w.cache.Get(ctx, key, &value); err == nil {
+		return true, nil
+	}
+	return false, w.cache.Set(ctx, key, NewBenchPayload(id), w.ttl)
+}
+
+// codecWorkload 按样本对值编码再解码，值在计时前生成
+type codecWorkload struct {
+	codec    Codec
+	sample   []int
+	payloads map[int]*BenchPayload
+	encoded  map[int]int
+}
+
+// newCodecWorkload 创建序列化用例，并校验编码后能还原
+func newCodecWorkload(codec Codec, sample []int) (*codecWorkload, error) {
+	w := &codecWorkload{codec: codec, sample: sample, payloads: make(map[int]*BenchPayload), encoded: make(map[int]int)}
+	for _, id := range sample {
+		if _, ok := w.payloads[id]; ok {
+			continue
+		}
+		payload := NewBenchPayload(id)
+		data, err := codec.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("codec %s failed to marshal payload: %w", codec.Name, err)
+		}
+		var decoded BenchPayload
+		if err := codec.Unmarshal(data, &decoded); err != nil {
+			return nil, fmt.Errorf("codec %s failed to unmarshal payload: %w", codec.Name, err)
+		}
+		if decoded.ID != payload.ID || decoded.Body != payload.Body {
+			return nil, fmt.Errorf("codec %s does not round-trip payload %d", codec.Name, id)
+		}
+		w.payloads[id] = payload
+		w.encoded[id] = len(data)
+	}
+	return w, nil
+}
+
+// op 第 i 次编码与解码
+func (w *codecWorkload) op(ctx context.Context, i int) (bool, error) {
+	data, err := w.codec.Marshal(w.payloads[w.sample[i%len(w.sample)]])
+	if err != nil {
+		return false, err
+	}
+	var decoded BenchPayload
+	return false, w.codec.Unmarshal(data, &decoded)
+}
+
+// encodedBytes 样本中每次访问的平均编码字节数，热点键按访问次数加权
+func (w *codecWorkload) encodedBytes() float64 {
+	if len(w.sample) == 0 {
+		return 0
+	}
+	total := 0
+	for _, id := range w.sample {
+		total += w.encoded[id]
+	}
+	return float64(total) / float64(len(w.sample))
+}
+
+// newWarmupManager 创建预热用例的管理器，BenchKey 形式的键由 benchPayloadLoader 批量生成
+func newWarmupManager(c cache.CacheService) (*cache.CacheWarmupManager, error) {
+	manager := cache.NewCacheWarmupManager(c, nil, &cache.WarmupConfig{})
+	if err := manager.RegisterBatchLoader("bench:key:{id}", benchPayloadLoader, 0); err != nil {
+		manager.Close()
+		return nil, err
+	}
+	return manager, nil
+}
+
+// benchPayloadLoader 按键中的序号生成 BenchPayload，代替预热时的数据库查询
+func benchPayloadLoader(ctx context.Context, ids []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bench key id %q: %w", id, err)
+		}
+		values[id] = NewBenchPayload(n)
+	}
+	return values, nil
+}
+
+// runWarmup 以策略预热样本中最热的 WarmupKeys 个键，再按样本访问统计命中率；
+// 操作数为预热的键数，ns/op 为每个键的预热耗时
+func runWarmup(ctx context.Context, config *BenchConfig, strategy string, sample []int) (*measurement, error) {
+	newCache := config.WarmupCache
+	if newCache == nil {
+		newCache = cache.NewMemoryCache
+	}
+	c := newCache()
+	manager, err := newWarmupManager(c)
+	if err != nil {
+		return nil, err
+	}
+	defer manager.Close()
+	keys := HotKeys(sample, config.WarmupKeys)
+
+	var warmErr error
+	m := measure(1, 1, func(ctx context.Context, i int) (bool, error) {
+		_, warmErr = manager.Warmup(ctx, strategy, keys)
+		return false, warmErr
+	})
+	if warmErr != nil {
+		return nil, fmt.Errorf("failed to benchmark warmup strategy %s: %w", strategy, warmErr)
+	}
+
+	hits := 0
+	for _, id := range sample {
+		if exists, err := c.Exists(ctx, BenchKey(id)); err == nil && exists {
+			hits++
+		}
+	}
+	m.ops = len(keys)
+	m.hits = 0
+	m.hitRatio = float64(hits) / float64(len(sample))
+	m.extra = map[string]float64{
+		"warmed_keys": float64(len(keys)),
+		"warmup_ms":   float64(m.elapsed.Microseconds()) / 1000,
+	}
+	return m, nil
+}
+
+// BenchmarkCache 以 go test -bench 的方式对一级缓存做读穿透访问，额外报告命中率 hits/op
+func BenchmarkCache(b *gotesting.B, candidate CacheCandidate, dist Distribution) {
+	w := newCacheWorkload(candidate.New(), dist.Sample(1, benchSampleSize(b, dist)), time.Minute)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	hits := 0
+	for i := 0; i < b.N; i++ {
+		hit, err := w.op(ctx, i)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if hit {
+			hits++
+		}
+	}
+	b.ReportMetric(float64(hits)/float64(b.N), "hits/op")
+}
+
+// BenchmarkCodec 以 go test -bench 的方式对序列化方式做编码再解码，额外报告平均编码字节数
+func BenchmarkCodec(b *gotesting.B, codec Codec, dist Distribution) {
+	w, err := newCodecWorkload(codec, dist.Sample(1, benchSampleSize(b, dist)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := w.op(ctx, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(w.encodedBytes(), "encoded-B/op")
+}
+
+// BenchmarkWarmup 以 go test -bench 的方式对预热策略计时，每次操作预热 keys 个热点键到新的 cache.MemoryCache，
+// 额外报告预热后按分布访问的命中率
+func BenchmarkWarmup(b *gotesting.B, strategy string, dist Distribution, keys int) {
+	sample := dist.Sample(1, benchSampleSize(b, dist))
+	hotKeys := HotKeys(sample, keys)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var c cache.CacheService
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c = cache.NewMemoryCache()
+		manager, err := newWarmupManager(c)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if _, err := manager.Warmup(ctx, strategy, hotKeys); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	hits := 0
+	for _, id := range sample {
+		if exists, _ := c.Exists(ctx, BenchKey(id)); exists {
+			hits++
+		}
+	}
+	b.ReportMetric(float64(hits)/float64(len(sample)), "hit-ratio")
+}
+
+// benchSampleSize go test -bench 使用的样本长度，为键数的 10 倍，至少 10000
+func benchSampleSize(b *gotesting.B, dist Distribution) int {
+	if err := dist.Validate(); err != nil {
+		b.Fatal(err)
+	}
+	if n := dist.Keys * 10; n > 10000 {
+		return n
+	}
+	return 10000
+}
@@ -0,0 +1,104 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	gotesting "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistribution_Sample(t *gotesting.T) {
+	uniform := Uniform(100).Sample(1, 10000)
+	zipf := Zipfian(100, 1.5).Sample(1, 10000)
+	assert.Equal(t, zipf, Zipfian(100, 1.5).Sample(1, 10000), "same seed yields same sample")
+
+	count := func(sample []int, key int) int {
+		n := 0
+		for _, id := range sample {
+			assert.True(t, id >= 0 && id < 100)
+			if id == key {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Less(t, count(uniform, 0), 300)
+	assert.Greater(t, count(zipf, 0), 3000, "zipf concentrates on the hottest key")
+	assert.Equal(t, []string{BenchKey(0), BenchKey(1)}, HotKeys(zipf, 2))
+
+	assert.Error(t, Zipfian(100, 1).Validate())
+	assert.Error(t, Uniform(0).Validate())
+}
+
+func TestRunBenchmarks(t *gotesting.T) {
+	config := DefaultBenchConfig()
+	config.Distributions = []Distribution{Uniform(200), Zipfian(200, 1.2)}
+	config.WarmupStrategies = []string{"immediate", "batch"}
+	config.Ops = 2000
+	config.WarmupKeys = 20
+
+	report, err := RunBenchmarks(context.Background(), config)
+	require.NoError(t, err)
+	// 每个分布：2 个缓存、2 种序列化、2 个预热策略
+	require.Len(t, report.Results, 12)
+
+	for _, result := range report.Results {
+		assert.Zero(t, result.Errors, "%s/%s", result.Suite, result.Candidate)
+		assert.Greater(t, result.NsPerOp, 0.0)
+	}
+	best := report.Best(SuiteCache, "zipf(1.2)")
+	require.NotNil(t, best)
+	// 读穿透访问：每个键第一次未命中，之后都命中
+	assert.Greater(t, best.HitRatio, 0.9)
+
+	codec := report.Best(SuiteCodec, "uniform")
+	require.NotNil(t, codec)
+	assert.Greater(t, codec.Extra["encoded_bytes"], 64.0)
+
+	for _, result := range report.Results {
+		if result.Suite == SuiteWarmup {
+			assert.Equal(t, 20, result.Ops)
+			if result.Distribution == "zipf(1.2)" {
+				assert.Greater(t, result.HitRatio, 0.5, "warming the hot set covers most zipf traffic")
+			} else {
+				assert.Less(t, result.HitRatio, 0.2)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	assert.Contains(t, out.String(), "[cache] zipf(1.2)")
+	assert.Contains(t, out.String(), "json_bytes")
+	assert.Contains(t, out.String(), "warmed_keys=20.0")
+
+	_, err = RunBenchmarks(context.Background(), &BenchConfig{Distributions: []Distribution{Zipfian(10, 0.5)}, Ops: 1})
+	assert.Error(t, err)
+}
+
+// go test -bench . ./pkg/testing 对比一级缓存、序列化方式与预热策略
+func BenchmarkCaches(b *gotesting.B) {
+	for _, dist := range []Distribution{Uniform(10000), Zipfian(10000, 1.1)} {
+		for _, candidate := range DefaultCacheCandidates() {
+			b.Run(candidate.Name+"/"+dist.Name, func(b *gotesting.B) { BenchmarkCache(b, candidate, dist) })
+		}
+	}
+}
+
+func BenchmarkCodecs(b *gotesting.B) {
+	for _, dist := range []Distribution{Uniform(10000), Zipfian(10000, 1.1)} {
+		for _, codec := range DefaultCodecs() {
+			b.Run(codec.Name+"/"+dist.Name, func(b *gotesting.B) { BenchmarkCodec(b, codec, dist) })
+		}
+	}
+}
+
+func BenchmarkWarmupStrategies(b *gotesting.B) {
+	for _, dist := range []Distribution{Uniform(10000), Zipfian(10000, 1.1)} {
+		for _, strategy := range DefaultWarmupStrategies() {
+			b.Run(strategy+"/"+dist.Name, func(b *gotesting.B) { BenchmarkWarmup(b, strategy, dist, 1000) })
+		}
+	}
+}
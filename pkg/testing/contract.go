@@ -0,0 +1,264 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"user_crud_jwt/pkg/openapi"
+)
+
+// Violation 一次不符合契约的响应
+type Violation struct {
+	Method string
+	Path   string
+	Status int
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("contract violation: %s %s -> %d: %s", v.Method, v.Path, v.Status, v.Reason)
+}
+
+// contractRoute 文档中的一个操作，路径模板编译为正则用于匹配实际请求路径
+type contractRoute struct {
+	method   string
+	template string
+	pattern  *regexp.Regexp
+	params   int
+	op       *openapi.Operation
+}
+
+// Contract 按 OpenAPI 文档校验实际响应：状态码必须已声明（错误状态码可由 default 兜底），
+// JSON 响应体必须符合声明的结构
+type Contract struct {
+	doc        *openapi.Document
+	routes     []contractRoute
+	violations []Violation
+	mu         sync.RWMutex
+}
+
+// NewContract 根据文档创建契约校验器
+func NewContract(doc *openapi.Document) *Contract {
+	c := &Contract{doc: doc}
+	for template, item := range doc.Paths {
+		pattern, params := compileTemplate(template)
+		for _, method := range []string{"GET", "PUT", "POST", "DELETE", "PATCH", "HEAD"} {
+			if op := item.Operation(method); op != nil {
+				c.routes = append(c.routes, contractRoute{method: method, template: template, pattern: pattern, params: params, op: op})
+			}
+		}
+	}
+	// 静态路径优先于带参数的路径，与 gin 的匹配顺序一致
+	sort.Slice(c.routes, func(i, j int) bool {
+		if c.routes[i].params != c.routes[j].params {
+			return c.routes[i].params < c.routes[j].params
+		}
+		return c.routes[i].template < c.routes[j].template
+	})
+	return c
+}
+
+// LoadContract 从服务的 /openapi.json 加载文档
+func LoadContract(ctx context.Context, client *http.Client, specURL string) (*Contract, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, specURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch openapi spec: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch openapi spec: unexpected status code %d", resp.StatusCode)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode openapi spec: %w", err)
+	}
+	return NewContract(&doc), nil
+}
+
+// compileTemplate /users/{id} 编译为 ^/users/[^/]+$
+func compileTemplate(template string) (*regexp.Regexp, int) {
+	segments := strings.Split(template, "/")
+	params := 0
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params++
+			segments[i] = "[^/]+"
+			if i == len(segments)-1 {
+				// gin 的 *name 通配参数可以匹配多级路径
+				segments[i] = ".+"
+			}
+			continue
+		}
+		segments[i] = regexp.QuoteMeta(seg)
+	}
+	return regexp.MustCompile("^" + strings.Join(segments, "/") + "$"), params
+}
+
+func (c *Contract) match(method, path string) *contractRoute {
+	for i := range c.routes {
+		if c.routes[i].method == method && c.routes[i].pattern.MatchString(path) {
+			return &c.routes[i]
+		}
+	}
+	return nil
+}
+
+// ValidateResponse 校验响应并保留响应体供调用方继续读取
+func (c *Contract) ValidateResponse(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var contentType string
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		contentType = mediaType
+	}
+	return c.Validate(resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, contentType, body)
+}
+
+// Validate 校验一次响应，不符合时记录并返回 *Violation
+func (c *Contract) Validate(method, path string, status int, contentType string, body []byte) error {
+	route := c.match(method, path)
+	if route == nil {
+		return c.record(method, path, status, "route is not documented")
+	}
+
+	response, ok := route.op.Responses[strconv.Itoa(status)]
+	if !ok && status >= http.StatusBadRequest {
+		response, ok = route.op.Responses["default"]
+	}
+	if !ok {
+		return c.record(method, path, status, fmt.Sprintf("status %d is not documented for %s %s", status, route.method, route.template))
+	}
+
+	media, ok := response.Content[contentType]
+	if !ok || media.Schema == nil || contentType != "application/json" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return c.record(method, path, status, fmt.Sprintf("invalid JSON body: %v", err))
+	}
+
+	var problems []string
+	c.validateValue(media.Schema, value, "$", &problems)
+	if len(problems) > 0 {
+		return c.record(method, path, status, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Violations 返回已记录的全部违规
+func (c *Contract) Violations() []Violation {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Violation(nil), c.violations...)
+}
+
+func (c *Contract) record(method, path string, status int, reason string) error {
+	v := Violation{Method: method, Path: path, Status: status, Reason: reason}
+	c.mu.Lock()
+	c.violations = append(c.violations, v)
+	c.mu.Unlock()
+	return &v
+}
+
+// validateValue 按 Schema 校验解码后的 JSON 值，问题追加到 problems
+func (c *Contract) validateValue(schema *openapi.Schema, value interface{}, at string, problems *[]string) {
+	if schema.Ref != "" {
+		resolved := c.doc.ResolveRef(schema)
+		if resolved == nil {
+			*problems = append(*problems, fmt.Sprintf("%s: unresolved reference %s", at, schema.Ref))
+			return
+		}
+		schema = resolved
+	}
+
+	if value == nil {
+		if !schema.Nullable && (schema.Type != "" || len(schema.AllOf) > 0) {
+			*problems = append(*problems, fmt.Sprintf("%s: must not be null", at))
+		}
+		return
+	}
+	for _, sub := range schema.AllOf {
+		c.validateValue(sub, value, at, problems)
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected object", at))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", at, name))
+			}
+		}
+		for name, v := range obj {
+			if prop, ok := schema.Properties[name]; ok {
+				c.validateValue(prop, v, at+"."+name, problems)
+			} else if schema.AdditionalProperties != nil {
+				c.validateValue(schema.AdditionalProperties, v, at+"."+name, problems)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected array", at))
+			return
+		}
+		if schema.Items != nil {
+			for i, v := range arr {
+				c.validateValue(schema.Items, v, fmt.Sprintf("%s[%d]", at, i), problems)
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected string", at))
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: expected integer", at))
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected number", at))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: expected boolean", at))
+		}
+	}
+
+	if len(schema.Enum) > 0 {
+		for _, allowed := range schema.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				return
+			}
+		}
+		*problems = append(*problems, fmt.Sprintf("%s: value %v is not one of %v", at, value, schema.Enum))
+	}
+}
@@ -0,0 +1,307 @@
+package fakes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/cache"
+)
+
+// 确保 Cache 实现了 cache.CacheService
+var _ cache.CacheService = (*Cache)(nil)
+
+// Cache 内存中的 cache.CacheService，行为与 RedisCache 一致：值以 JSON 保存，未命中返回 "cache miss"，
+// 过期时间为 0 表示不过期，InvalidatePattern 按 Redis 的 glob 规则匹配。过期按 Clock 判断，测试中以 Clock.Advance 触发。
+// 有序集合使用 cache.SortedSets，不过期
+type Cache struct {
+	clock *Clock
+
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	sortedSets *cache.SortedSets
+	calls      map[string]int
+}
+
+// cacheEntry 一个键的 JSON 值与过期时间
+type cacheEntry struct {
+	data      []byte
+	expiresAt time.Time // 零值表示不过期
+}
+
+// defaultTTL SetWithTTL 使用的过期时间，与 RedisCache 相同
+const defaultTTL = time.Hour
+
+// NewCache 创建内存缓存，clock 为 nil 时使用 NewClock(time.Time{})
+func NewCache(clock *Clock) *Cache {
+	if clock == nil {
+		clock = NewClock(time.Time{})
+	}
+	return &Cache{
+		clock:      clock,
+		entries:    make(map[string]cacheEntry),
+		sortedSets: &cache.SortedSets{},
+		calls:      make(map[string]int),
+	}
+}
+
+// Clock 缓存使用的时钟
+func (c *Cache) Clock() *Clock {
+	return c.clock
+}
+
+// Get 实现 cache.CacheService
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
+	_, err := c.get("get", key, dest)
+	return err
+}
+
+// Set 实现 cache.CacheService
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache marshal error: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["set"]++
+	c.setLocked(key, data, expiration)
+	return nil
+}
+
+// Delete 实现 cache.CacheService
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["delete"]++
+	delete(c.entries, key)
+	c.sortedSets.Remove(key)
+	return nil
+}
+
+// Exists 实现 cache.CacheService
+func (c *Cache) Exists(ctx context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["exists"]++
+	_, ok := c.lookupLocked(key)
+	return ok || c.sortedSets.Has(key), nil
+}
+
+// GetWithTTL 实现 cache.CacheService，不过期的键剩余时间为 0
+func (c *Cache) GetWithTTL(ctx context.Context, key string, dest interface{}) (time.Duration, error) {
+	return c.get("get_with_ttl", key, dest)
+}
+
+// get 读取键并返回剩余时间，按 operation 计数
+func (c *Cache) get(operation, key string, dest interface{}) (time.Duration, error) {
+	c.mu.Lock()
+	c.calls[operation]++
+	entry, ok := c.lookupLocked(key)
+	now := c.clock.Now()
+	c.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("cache miss")
+	}
+
+	if err := json.Unmarshal(entry.data, dest); err != nil {
+		return 0, fmt.Errorf("cache unmarshal error: %w", err)
+	}
+	if entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return entry.expiresAt.Sub(now), nil
+}
+
+// SetWithTTL 实现 cache.CacheService，过期时间为 1 小时
+func (c *Cache) SetWithTTL(ctx context.Context, key string, value interface{}) error {
+	return c.Set(ctx, key, value, defaultTTL)
+}
+
+// InvalidatePattern 实现 cache.CacheService
+func (c *Cache) InvalidatePattern(ctx context.Context, pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("cache invalidate pattern error: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["invalidate"]++
+	for key := range c.entries {
+		if matched, _ := path.Match(pattern, key); matched {
+			delete(c.entries, key)
+		}
+	}
+	c.sortedSets.RemoveMatching(pattern)
+	return nil
+}
+
+// GetMultiple 实现 cache.CacheService，未命中的位置为 null
+func (c *Cache) GetMultiple(ctx context.Context, keys []string, dest interface{}) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.calls["get_multiple"]++
+	results := make([]json.RawMessage, len(keys))
+	for i, key := range keys {
+		if entry, ok := c.lookupLocked(key); ok {
+			results[i] = entry.data
+		} else {
+			results[i] = json.RawMessage("null")
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("cache marshal error: %w", err)
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// GetMany 实现 cache.CacheService
+func (c *Cache) GetMany(ctx context.Context, keys []string) (*cache.BatchGetResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["get_many"]++
+
+	result := cache.NewBatchGetResult()
+	for _, key := range keys {
+		entry, ok := c.lookupLocked(key)
+		if !ok {
+			result.Missing = append(result.Missing, key)
+			continue
+		}
+		result.Values[key] = append(json.RawMessage(nil), entry.data...)
+	}
+	return result, nil
+}
+
+// SetMany 实现 cache.CacheService
+func (c *Cache) SetMany(ctx context.Context, entries []cache.CacheEntry) (*cache.BatchSetResult, error) {
+	result := cache.NewBatchSetResult()
+	encoded := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		data, err := json.Marshal(entry.Value)
+		if err != nil {
+			result.Errors[entry.Key] = fmt.Errorf("cache marshal error: %w", err)
+			continue
+		}
+		encoded[entry.Key] = data
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls["set_many"]++
+	for _, entry := range entries {
+		data, ok := encoded[entry.Key]
+		if !ok {
+			continue
+		}
+		c.setLocked(entry.Key, data, entry.Expiration)
+		result.Succeeded = append(result.Succeeded, entry.Key)
+	}
+	return result, nil
+}
+
+// ZAdd 实现 cache.CacheService
+func (c *Cache) ZAdd(ctx context.Context, key string, members ...cache.ScoredMember) error {
+	c.count("zadd")
+	return c.sortedSets.ZAdd(ctx, key, members...)
+}
+
+// ZIncrBy 实现 cache.CacheService
+func (c *Cache) ZIncrBy(ctx context.Context, key, member string, increment float64) (float64, error) {
+	c.count("zincrby")
+	return c.sortedSets.ZIncrBy(ctx, key, member, increment)
+}
+
+// ZRangeWithScores 实现 cache.CacheService
+func (c *Cache) ZRangeWithScores(ctx context.Context, key string, start, stop int64, desc bool) ([]cache.ScoredMember, error) {
+	c.count("zrange")
+	return c.sortedSets.ZRangeWithScores(ctx, key, start, stop, desc)
+}
+
+// ZRank 实现 cache.CacheService
+func (c *Cache) ZRank(ctx context.Context, key, member string, desc bool) (int64, error) {
+	c.count("zrank")
+	return c.sortedSets.ZRank(ctx, key, member, desc)
+}
+
+// Keys 未过期的键与有序集合的键，按字典序排列
+func (c *Cache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		if _, ok := c.lookupLocked(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	keys = append(keys, c.sortedSets.Keys()...)
+	sort.Strings(keys)
+	return keys
+}
+
+// TTL 键的剩余时间，键不存在时 ok 为 false，不过期的键返回 0
+func (c *Cache) TTL(key string) (ttl time.Duration, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.lookupLocked(key)
+	if !ok || entry.expiresAt.IsZero() {
+		return 0, ok
+	}
+	return entry.expiresAt.Sub(c.clock.Now()), true
+}
+
+// Calls 某个操作被调用的次数，操作名为 get、set、delete、exists、get_with_ttl、invalidate、get_multiple、get_many、set_many、
+// zadd、zincrby、zrange、zrank；Set 经 SetWithTTL 调用时计为 set
+func (c *Cache) Calls(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[operation]
+}
+
+// Reset 清空数据与调用计数
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+	c.sortedSets = &cache.SortedSets{}
+	c.calls = make(map[string]int)
+}
+
+// count 按操作计数
+func (c *Cache) count(operation string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[operation]++
+}
+
+// setLocked 写入键，调用方需持有锁
+func (c *Cache) setLocked(key string, data []byte, expiration time.Duration) {
+	entry := cacheEntry{data: data}
+	if expiration > 0 {
+		entry.expiresAt = c.clock.Now().Add(expiration)
+	}
+	c.entries[key] = entry
+}
+
+// lookupLocked 读取未过期的键，已过期的键被删除，调用方需持有锁
+func (c *Cache) lookupLocked(key string) (cacheEntry, bool) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+	if !entry.expiresAt.IsZero() && !c.clock.Now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
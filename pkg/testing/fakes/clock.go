@@ -0,0 +1,182 @@
+package fakes
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"user_crud_jwt/pkg/clock"
+)
+
+// 确保 Clock 实现了 clock.Clock
+var _ clock.Clock = (*Clock)(nil)
+
+// Clock 可控时钟，只有调用 Advance 或 Set 时才前进，供缓存过期、调度器、延迟任务等依赖时间的逻辑做确定性测试。
+// 前进时按到期顺序触发定时器：After 与 Ticker 的通道缓冲为 1，接收方跟不上时丢弃；AfterFunc 的回调在 Advance 中同步执行
+type Clock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter 一个未触发的定时器，period 大于 0 时为 Ticker
+type waiter struct {
+	clock  *Clock
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+// NewClock 创建停在 start 的时钟，start 为零值时使用固定的 2024-01-01 00:00:00 UTC
+func NewClock(start time.Time) *Clock {
+	if start.IsZero() {
+		start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now 当前时间，可直接作为 func() time.Time 注入
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since 自 t 以来经过的时间
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// After 实现 clock.Clock
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	w := &waiter{ch: make(chan time.Time, 1)}
+	c.add(w, d)
+	return w.ch
+}
+
+// NewTicker 实现 clock.Clock，d 必须大于 0
+func (c *Clock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("fakes: non-positive interval for NewTicker")
+	}
+	w := &waiter{period: d, ch: make(chan time.Time, 1)}
+	c.add(w, d)
+	return ticker{w}
+}
+
+// AfterFunc 实现 clock.Clock
+func (c *Clock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	w := &waiter{fn: f}
+	c.add(w, d)
+	return w
+}
+
+// Advance 前进 d，途中到期的定时器依次触发
+func (c *Clock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set 设置为 t，t 之前到期的定时器依次触发；t 早于当前时间时只回拨时间
+func (c *Clock) Set(t time.Time) {
+	for {
+		c.mu.Lock()
+		w := c.nextLocked(t)
+		if w == nil {
+			c.now = t
+			c.mu.Unlock()
+			return
+		}
+		if w.when.After(c.now) {
+			c.now = w.when
+		}
+		fired := c.now
+		if w.period > 0 {
+			w.when = w.when.Add(w.period)
+		} else {
+			c.removeLocked(w)
+		}
+		c.mu.Unlock()
+
+		if w.fn != nil {
+			w.fn()
+			continue
+		}
+		select {
+		case w.ch <- fired:
+		default:
+		}
+	}
+}
+
+// Waiters 未触发的定时器数量（含 Ticker）
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil 阻塞到至少有 n 个未触发的定时器，用于等待后台协程创建好定时器后再推进时间
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// ticker 实现 clock.Ticker
+type ticker struct {
+	*waiter
+}
+
+// C 实现 clock.Ticker
+func (t ticker) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop 实现 clock.Ticker
+func (t ticker) Stop() {
+	t.waiter.Stop()
+}
+
+// Stop 实现 clock.Timer，定时器尚未触发时返回 true
+func (w *waiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.removeLocked(w)
+}
+
+// add 登记在 d 之后到期的定时器
+func (c *Clock) add(w *waiter, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.clock = c
+	w.when = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+}
+
+// nextLocked 不晚于 t 的最早到期定时器，同时到期时按登记顺序，调用方需持有锁
+func (c *Clock) nextLocked(t time.Time) *waiter {
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].when.Before(c.waiters[j].when)
+	})
+	if len(c.waiters) == 0 || c.waiters[0].when.After(t) {
+		return nil
+	}
+	return c.waiters[0]
+}
+
+// removeLocked 移除定时器，不存在时返回 false，调用方需持有锁
+func (c *Clock) removeLocked(w *waiter) bool {
+	for i, existing := range c.waiters {
+		if existing == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
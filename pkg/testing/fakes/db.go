@@ -0,0 +1,26 @@
+package fakes
+
+import (
+	"testing"
+	"user_crud_jwt/pkg/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+// NewDB 以 sqlmock 为底层连接的 *database.DB，用于测试直接执行 SQL 的仓库：
+// SQL 按正则匹配期望，测试结束时关闭连接并校验所有期望都已满足
+func NewDB(t testing.TB) (*database.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet database expectations: %v", err)
+		}
+		conn.Close()
+	})
+	return &database.DB{DB: sqlx.NewDb(conn, "pgx")}, mock
+}
@@ -0,0 +1,186 @@
+package fakes
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Expiry(t *testing.T) {
+	c := NewCache(nil)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "user:1", map[string]string{"name": "a"}, time.Minute))
+	require.NoError(t, c.Set(ctx, "user:2", "b", 0))
+	require.NoError(t, c.SetWithTTL(ctx, "coupon:1", 3))
+
+	var value map[string]string
+	ttl, err := c.GetWithTTL(ctx, "user:1", &value)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+	assert.Equal(t, "a", value["name"])
+
+	c.Clock().Advance(59 * time.Second)
+	ttl, ok := c.TTL("user:1")
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, ttl)
+
+	c.Clock().Advance(time.Second)
+	assert.EqualError(t, c.Get(ctx, "user:1", &value), "cache miss")
+	exists, err := c.Exists(ctx, "user:2")
+	require.NoError(t, err)
+	assert.True(t, exists, "zero expiration never expires")
+	assert.Equal(t, []string{"coupon:1", "user:2"}, c.Keys())
+
+	c.Clock().Advance(time.Hour)
+	assert.Equal(t, []string{"user:2"}, c.Keys())
+	assert.Equal(t, 1, c.Calls("get"))
+	assert.Equal(t, 3, c.Calls("set"), "SetWithTTL counts as set")
+}
+
+func TestCache_Batch(t *testing.T) {
+	c := NewCache(NewClock(time.Now()))
+	ctx := context.Background()
+
+	result, err := c.SetMany(ctx, []cache.CacheEntry{
+		{Key: "a", Value: 1, Expiration: time.Minute},
+		{Key: "b", Value: make(chan int)},
+		{Key: "c", Value: "x"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, result.Succeeded)
+	assert.Contains(t, result.Errors, "b")
+
+	got, err := c.GetMany(ctx, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, got.Missing)
+	var n int
+	require.NoError(t, got.Decode("a", &n))
+	assert.Equal(t, 1, n)
+
+	var values []interface{}
+	require.NoError(t, c.GetMultiple(ctx, []string{"a", "b", "c"}, &values))
+	assert.Equal(t, []interface{}{1.0, nil, "x"}, values)
+
+	require.NoError(t, c.InvalidatePattern(ctx, "[ab]*"))
+	assert.Equal(t, []string{"c"}, c.Keys())
+	assert.Error(t, c.InvalidatePattern(ctx, "["))
+
+	c.Reset()
+	assert.Empty(t, c.Keys())
+	assert.Zero(t, c.Calls("get_many"))
+}
+
+type fakeCoupon struct {
+	ID    string
+	Name  string
+	Stock int
+}
+
+func TestTable(t *testing.T) {
+	table := NewTable(func(c *fakeCoupon) string { return c.ID })
+
+	require.NoError(t, table.Insert(&fakeCoupon{ID: "1", Name: "a", Stock: 1}))
+	require.NoError(t, table.Insert(&fakeCoupon{ID: "2", Name: "b", Stock: 0}))
+	assert.ErrorIs(t, table.Insert(&fakeCoupon{ID: "1"}), ErrDuplicate)
+	table.Upsert(&fakeCoupon{ID: "3", Name: "c", Stock: 5})
+
+	// 返回值是副本
+	row, err := table.Get("1")
+	require.NoError(t, err)
+	row.Stock = 100
+	row, _ = table.Get("1")
+	assert.Equal(t, 1, row.Stock)
+
+	inStock := table.Find(func(c *fakeCoupon) bool { return c.Stock > 0 })
+	require.Len(t, inStock, 2)
+	assert.Equal(t, "1", inStock[0].ID)
+	assert.Equal(t, "3", inStock[1].ID)
+
+	page := table.Page(nil, 1, 1)
+	require.Len(t, page, 1)
+	assert.Equal(t, "2", page[0].ID)
+	assert.Empty(t, table.Page(nil, 10, 3))
+
+	require.NoError(t, table.Update(&fakeCoupon{ID: "2", Stock: 9}))
+	assert.ErrorIs(t, table.Update(&fakeCoupon{ID: "9"}), ErrNotFound)
+	first, err := table.First(func(c *fakeCoupon) bool { return c.Stock == 9 })
+	require.NoError(t, err)
+	assert.Equal(t, "2", first.ID)
+
+	require.NoError(t, table.Delete("1"))
+	assert.ErrorIs(t, table.Delete("1"), ErrNotFound)
+	_, err = table.Get("1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, table.Len())
+}
+
+func TestNewDB(t *testing.T) {
+	db, mock := NewDB(t)
+	mock.ExpectQuery(`SELECT name FROM coupons WHERE id = \$1`).
+		WithArgs("c1").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("新人券"))
+
+	var name string
+	require.NoError(t, db.GetContext(context.Background(), &name, "SELECT name FROM coupons WHERE id = $1", "c1"))
+	assert.Equal(t, "新人券", name)
+}
+
+func TestClock_Timers(t *testing.T) {
+	c := NewClock(time.Time{})
+	start := c.Now()
+
+	after := c.After(time.Minute)
+	ticker := c.NewTicker(10 * time.Second)
+	var fired []time.Time
+	timer := c.AfterFunc(30*time.Second, func() { fired = append(fired, c.Now()) })
+	stopped := c.AfterFunc(time.Second, func() { t.Error("stopped timer fired") })
+	assert.Equal(t, 4, c.Waiters())
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	c.Advance(15 * time.Second)
+	assert.Equal(t, start.Add(10*time.Second), <-ticker.C())
+	assert.Empty(t, fired)
+
+	// 接收方跟不上时丢弃多余的 tick
+	c.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(20*time.Second), <-ticker.C())
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker should drop ticks while the channel is full")
+	default:
+	}
+	assert.Equal(t, []time.Time{start.Add(30 * time.Second)}, fired)
+	assert.False(t, timer.Stop())
+
+	c.Advance(15 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-after)
+	ticker.Stop()
+	assert.Equal(t, 0, c.Waiters())
+	assert.Equal(t, start.Add(time.Minute), c.Now())
+}
+
+func TestClock_BlockUntil(t *testing.T) {
+	c := NewClock(time.Time{})
+	ticks := make(chan time.Time, 1)
+	go func() {
+		ticker := c.NewTicker(time.Second)
+		defer ticker.Stop()
+		ticks <- <-ticker.C()
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	select {
+	case tick := <-ticks:
+		assert.Equal(t, c.Now(), tick)
+	case <-time.After(time.Second):
+		t.Fatal("ticker did not fire")
+	}
+}
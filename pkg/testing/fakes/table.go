@@ -0,0 +1,140 @@
+package fakes
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrNotFound 记录不存在，与 sql.ErrNoRows 相同，仓库假实现可与真实实现使用同样的判断
+	ErrNotFound = sql.ErrNoRows
+	// ErrDuplicate 主键重复
+	ErrDuplicate = errors.New("duplicate key")
+)
+
+// Table 内存中的一张表，用于编写仓库假实现：按主键存取，查询结果按插入顺序返回，读写都复制值，
+// 调用方修改返回的记录不会影响表中数据（结构体中的指针、切片等字段为浅复制）
+type Table[T any] struct {
+	key func(*T) string
+
+	mu    sync.Mutex
+	rows  map[string]T
+	order []string
+}
+
+// NewTable 创建表，key 返回记录的主键
+func NewTable[T any](key func(*T) string) *Table[T] {
+	return &Table[T]{key: key, rows: make(map[string]T)}
+}
+
+// Insert 插入记录，主键已存在时返回 ErrDuplicate
+func (t *Table[T]) Insert(row *T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := t.key(row)
+	if _, ok := t.rows[key]; ok {
+		return ErrDuplicate
+	}
+	t.rows[key] = *row
+	t.order = append(t.order, key)
+	return nil
+}
+
+// Update 按主键替换记录，不存在时返回 ErrNotFound
+func (t *Table[T]) Update(row *T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := t.key(row)
+	if _, ok := t.rows[key]; !ok {
+		return ErrNotFound
+	}
+	t.rows[key] = *row
+	return nil
+}
+
+// Upsert 插入或替换记录
+func (t *Table[T]) Upsert(row *T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := t.key(row)
+	if _, ok := t.rows[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.rows[key] = *row
+}
+
+// Get 按主键读取，不存在时返回 ErrNotFound
+func (t *Table[T]) Get(key string) (*T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &row, nil
+}
+
+// Delete 按主键删除，不存在时返回 ErrNotFound
+func (t *Table[T]) Delete(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[key]; !ok {
+		return ErrNotFound
+	}
+	delete(t.rows, key)
+	for i, k := range t.order {
+		if k == key {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Find 满足 match 的记录，按插入顺序返回；match 为 nil 时返回全部
+func (t *Table[T]) Find(match func(*T) bool) []*T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var rows []*T
+	for _, key := range t.order {
+		row := t.rows[key]
+		if match == nil || match(&row) {
+			rows = append(rows, &row)
+		}
+	}
+	return rows
+}
+
+// First 第一条满足 match 的记录，没有时返回 ErrNotFound
+func (t *Table[T]) First(match func(*T) bool) (*T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range t.order {
+		row := t.rows[key]
+		if match(&row) {
+			return &row, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Page 满足 match 的记录按插入顺序分页，offset 超出时返回空
+func (t *Table[T]) Page(match func(*T) bool, limit, offset int) []*T {
+	rows := t.Find(match)
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// Len 记录数
+func (t *Table[T]) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.rows)
+}
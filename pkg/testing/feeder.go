@@ -0,0 +1,172 @@
+package testing
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrFeederExhausted unique 模式下数据已用完
+var ErrFeederExhausted = errors.New("feeder exhausted")
+
+// FeedMode 数据分配方式
+type FeedMode string
+
+const (
+	// FeedCircular 按顺序分配，用完后从头开始
+	FeedCircular FeedMode = "circular"
+	// FeedUnique 按顺序分配，每条记录只用一次，用完后返回 ErrFeederExhausted
+	FeedUnique FeedMode = "unique"
+	// FeedRandom 随机分配，可重复
+	FeedRandom FeedMode = "random"
+)
+
+// Record 一条数据，字段名对应场景中的变量名
+type Record map[string]string
+
+// Feeder 为每次迭代提供一条数据，各虚拟用户并发调用
+type Feeder interface {
+	Next() (Record, error)
+}
+
+// SliceFeeder 内存中的数据集
+type SliceFeeder struct {
+	records []Record
+	mode    FeedMode
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewSliceFeeder 创建数据集，mode 为空时按 circular 分配
+func NewSliceFeeder(records []Record, mode FeedMode) (*SliceFeeder, error) {
+	if len(records) == 0 {
+		return nil, errors.New("feeder has no records")
+	}
+	switch mode {
+	case "":
+		mode = FeedCircular
+	case FeedCircular, FeedUnique, FeedRandom:
+	default:
+		return nil, fmt.Errorf("unknown feed mode: %q", mode)
+	}
+	return &SliceFeeder{records: records, mode: mode}, nil
+}
+
+// Next 实现 Feeder
+func (f *SliceFeeder) Next() (Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var record Record
+	switch f.mode {
+	case FeedRandom:
+		record = f.records[rand.Intn(len(f.records))]
+	case FeedUnique:
+		if f.next >= len(f.records) {
+			return nil, ErrFeederExhausted
+		}
+		record = f.records[f.next]
+		f.next++
+	default:
+		record = f.records[f.next%len(f.records)]
+		f.next++
+	}
+	return record, nil
+}
+
+// Len 记录数
+func (f *SliceFeeder) Len() int {
+	return len(f.records)
+}
+
+// Shard 第 index 个（从 0 开始）分片，共 count 片，按记录序号取模划分；分布式压测时各代理使用互不重叠的数据。
+// 记录数少于 count 时返回全部记录
+func (f *SliceFeeder) Shard(index, count int) *SliceFeeder {
+	if count <= 1 || len(f.records) < count {
+		return &SliceFeeder{records: f.records, mode: f.mode}
+	}
+	records := make([]Record, 0, len(f.records)/count+1)
+	for i := index; i < len(f.records); i += count {
+		records = append(records, f.records[i])
+	}
+	return &SliceFeeder{records: records, mode: f.mode}
+}
+
+// ReadCSVRecords 读取 CSV，首行为字段名
+func ReadCSVRecords(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("csv has no header")
+	}
+
+	header := rows[0]
+	records := make([]Record, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(Record, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				record[strings.TrimSpace(name)] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ReadJSONRecords 读取 JSON 对象数组，非字符串的值按 JSON 文本保存，可直接嵌入请求体模板
+func ReadJSONRecords(r io.Reader) ([]Record, error) {
+	var rows []map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to read json: %w", err)
+	}
+
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		record := make(Record, len(row))
+		for name, raw := range row {
+			var s string
+			if err := json.Unmarshal(raw, &s); err == nil {
+				record[name] = s
+			} else {
+				record[name] = string(raw)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// LoadFeeder 从 .csv 或 .json 文件创建数据集
+func LoadFeeder(path string, mode FeedMode) (*SliceFeeder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feeder file: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	switch {
+	case strings.HasSuffix(strings.ToLower(path), ".csv"):
+		records, err = ReadCSVRecords(file)
+	case strings.HasSuffix(strings.ToLower(path), ".json"):
+		records, err = ReadJSONRecords(file)
+	default:
+		return nil, fmt.Errorf("unsupported feeder file: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSliceFeeder(records, mode)
+}
@@ -0,0 +1,420 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"user_crud_jwt/pkg/loadgen"
+)
+
+// variablePattern 模板中的变量引用 ${name}
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_.]+)\}`)
+
+// Flow 数据驱动的端到端场景：每次迭代从 Feeder 取一条数据，按顺序执行步骤，
+// 步骤之间有思考时间，前一步响应中提取的值（如令牌、ID）可在后续步骤中以 ${name} 引用
+type Flow struct {
+	Name string `json:"name"`
+	// Vars 初始变量
+	Vars map[string]string `json:"vars,omitempty"`
+	// Feeder 每次迭代取一条记录合并到变量；脚本中以 feeder_file 指定 CSV 或 JSON 文件
+	Feeder     Feeder   `json:"-"`
+	FeederFile string   `json:"feeder_file,omitempty"`
+	FeedMode   FeedMode `json:"feed_mode,omitempty"`
+	// Setup 压测开始前执行一次，提取的变量对所有虚拟用户可见，如创建测试用的优惠券
+	Setup []Step `json:"setup,omitempty"`
+	Steps []Step `json:"steps"`
+	// Think 每个步骤之后的思考时间，步骤可单独覆盖
+	Think *ThinkTime `json:"think,omitempty"`
+}
+
+// Step 一个请求步骤，Path、Header、Body 与 Expect 的 JSON、Contains 中的 ${name} 替换为变量值
+type Step struct {
+	// Name 报告中的分组名，默认为 "方法 路径模板"
+	Name   string            `json:"name,omitempty"`
+	Method string            `json:"method,omitempty"`
+	Path   string            `json:"path"`
+	Header map[string]string `json:"header,omitempty"`
+	// Body 请求体模板，通常为 JSON；变量值按 JSON 字符串转义后替换
+	Body    json.RawMessage `json:"body,omitempty"`
+	Extract []Extract       `json:"extract,omitempty"`
+	// Expect 成功条件，为空时要求 2xx
+	Expect *Expect    `json:"expect,omitempty"`
+	Think  *ThinkTime `json:"think,omitempty"`
+	// ContinueOnFailure 失败时继续执行后续步骤，默认结束本次迭代
+	ContinueOnFailure bool `json:"continue_on_failure,omitempty"`
+}
+
+// Extract 从响应中提取变量，JSON、Header、Regex 三选一
+type Extract struct {
+	Var string `json:"var"`
+	// JSON 响应体中以点分隔的路径，数组用下标，如 data.id、data.items.0.id
+	JSON   string `json:"json,omitempty"`
+	Header string `json:"header,omitempty"`
+	// Regex 匹配响应体，取第一个分组
+	Regex string `json:"regex,omitempty"`
+	// Optional 未找到时不视为失败
+	Optional bool `json:"optional,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Expect 步骤的成功条件
+type Expect struct {
+	// Status 允许的状态码，为空时要求 2xx
+	Status      []int    `json:"status,omitempty"`
+	MaxDuration Duration `json:"max_duration,omitempty"`
+	// JSON 响应体路径与期望值（字符串形式比较）
+	JSON     map[string]string `json:"json,omitempty"`
+	Contains string            `json:"contains,omitempty"`
+}
+
+// LoadFlow 读取 JSON 场景脚本，feeder_file 为相对路径时相对于脚本所在目录
+func LoadFlow(path string) (*Flow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flow: %w", err)
+	}
+	var flow Flow
+	if err := json.Unmarshal(data, &flow); err != nil {
+		return nil, fmt.Errorf("failed to parse flow: %w", err)
+	}
+
+	if flow.FeederFile != "" {
+		feederPath := flow.FeederFile
+		if !filepath.IsAbs(feederPath) {
+			feederPath = filepath.Join(filepath.Dir(path), feederPath)
+		}
+		flow.Feeder, err = LoadFeeder(feederPath, flow.FeedMode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if flow.Name == "" {
+		flow.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &flow, nil
+}
+
+// Scenario 编译为压测场景；feeder 为 unique 模式且数据用完时，虚拟用户停止迭代
+func (f *Flow) Scenario() (*loadgen.Scenario, error) {
+	if len(f.Steps) == 0 {
+		return nil, errors.New("flow has no steps")
+	}
+	if f.Think != nil {
+		if err := f.Think.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, steps := range [][]Step{f.Setup, f.Steps} {
+		for i := range steps {
+			if err := steps[i].compile(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &loadgen.Scenario{
+		Name: f.Name,
+		// Setup 得到的变量保存在场景数据中，分布式压测时随任务下发给各代理
+		Setup: func(ctx context.Context, env *loadgen.Env) error {
+			vars := make(map[string]string, len(f.Vars))
+			for name, value := range f.Vars {
+				vars[name] = value
+			}
+			for i := range f.Setup {
+				if err := f.Setup[i].run(ctx, env, vars); err != nil {
+					return err
+				}
+			}
+			for name, value := range vars {
+				env.Set(name, value)
+			}
+			return nil
+		},
+		Iteration: func(ctx context.Context, vu *loadgen.VU) error {
+			vars := vu.Values()
+			vars["vu_id"] = strconv.Itoa(vu.ID)
+			vars["iteration"] = strconv.Itoa(vu.Iteration)
+			if f.Feeder != nil {
+				record, err := f.Feeder.Next()
+				if errors.Is(err, ErrFeederExhausted) {
+					return loadgen.ErrStopVU
+				}
+				if err != nil {
+					return err
+				}
+				for name, value := range record {
+					vars[name] = value
+				}
+			}
+
+			var failed error
+			for i := range f.Steps {
+				step := &f.Steps[i]
+				if err := step.run(ctx, vu.Env, vars); err != nil {
+					if !step.ContinueOnFailure {
+						return err
+					}
+					if failed == nil {
+						failed = err
+					}
+				}
+
+				think := step.Think
+				if think == nil {
+					think = f.Think
+				}
+				if err := think.Wait(ctx); err != nil {
+					return err
+				}
+			}
+			return failed
+		},
+	}, nil
+}
+
+// compile 校验步骤并预编译正则
+func (s *Step) compile() error {
+	if s.Path == "" {
+		return errors.New("step has no path")
+	}
+	if s.Method == "" {
+		s.Method = http.MethodGet
+	}
+	if s.Name == "" {
+		s.Name = s.Method + " " + s.Path
+	}
+	if s.Think != nil {
+		if err := s.Think.Validate(); err != nil {
+			return fmt.Errorf("step %s: %w", s.Name, err)
+		}
+	}
+	for i := range s.Extract {
+		e := &s.Extract[i]
+		if e.Var == "" {
+			return fmt.Errorf("step %s: extract has no var", s.Name)
+		}
+		sources := 0
+		for _, source := range []string{e.JSON, e.Header, e.Regex} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("step %s: extract %s needs exactly one of json, header, regex", s.Name, e.Var)
+		}
+		if e.Regex != "" {
+			regex, err := regexp.Compile(e.Regex)
+			if err != nil {
+				return fmt.Errorf("step %s: extract %s: %w", s.Name, e.Var, err)
+			}
+			if regex.NumSubexp() < 1 {
+				return fmt.Errorf("step %s: extract %s: regex needs a capture group", s.Name, e.Var)
+			}
+			e.regex = regex
+		}
+	}
+	return nil
+}
+
+// run 执行步骤并把提取的值写入 vars
+func (s *Step) run(ctx context.Context, env *loadgen.Env, vars map[string]string) error {
+	req, err := s.request(vars)
+	if err != nil {
+		return fmt.Errorf("step %s: %w", s.Name, err)
+	}
+	resp, err := env.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for i := range s.Extract {
+		e := &s.Extract[i]
+		value, ok := e.extract(resp)
+		if !ok {
+			if e.Optional {
+				continue
+			}
+			return fmt.Errorf("step %s: %s not found in response", s.Name, e.Var)
+		}
+		vars[e.Var] = value
+	}
+	return nil
+}
+
+// request 按变量渲染请求
+func (s *Step) request(vars map[string]string) (*loadgen.Request, error) {
+	path, err := render(s.Path, vars, false)
+	if err != nil {
+		return nil, err
+	}
+	req := &loadgen.Request{Name: s.Name, Method: s.Method, Path: path}
+
+	if len(s.Header) > 0 {
+		req.Header = make(http.Header, len(s.Header))
+		for name, value := range s.Header {
+			rendered, err := render(value, vars, false)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(name, rendered)
+		}
+	}
+	if len(s.Body) > 0 {
+		body, err := render(string(s.Body), vars, true)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = []byte(body)
+		if req.Header == nil {
+			req.Header = make(http.Header)
+		}
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+
+	if s.Expect != nil {
+		assertions, err := s.Expect.assertions(vars)
+		if err != nil {
+			return nil, err
+		}
+		req.Assertions = assertions
+	}
+	return req, nil
+}
+
+// assertions 成功条件对应的断言
+func (e *Expect) assertions(vars map[string]string) ([]loadgen.Assertion, error) {
+	assertions := []loadgen.Assertion{loadgen.StatusOK()}
+	if len(e.Status) > 0 {
+		assertions[0] = loadgen.StatusIn(e.Status...)
+	}
+	if e.MaxDuration > 0 {
+		assertions = append(assertions, loadgen.MaxDuration(time.Duration(e.MaxDuration)))
+	}
+	if e.Contains != "" {
+		contains, err := render(e.Contains, vars, false)
+		if err != nil {
+			return nil, err
+		}
+		assertions = append(assertions, func(req *loadgen.Request, resp *loadgen.Response) error {
+			if !strings.Contains(string(resp.Body), contains) {
+				return fmt.Errorf("response does not contain %q", contains)
+			}
+			return nil
+		})
+	}
+	if len(e.JSON) > 0 {
+		expected := make(map[string]string, len(e.JSON))
+		for path, value := range e.JSON {
+			rendered, err := render(value, vars, false)
+			if err != nil {
+				return nil, err
+			}
+			expected[path] = rendered
+		}
+		assertions = append(assertions, func(req *loadgen.Request, resp *loadgen.Response) error {
+			for path, want := range expected {
+				got, ok := jsonPath(resp.Body, path)
+				if !ok {
+					return fmt.Errorf("%s not found", path)
+				}
+				if got != want {
+					return fmt.Errorf("%s is %q, want %q", path, got, want)
+				}
+			}
+			return nil
+		})
+	}
+	return assertions, nil
+}
+
+// extract 从响应中取值
+func (e *Extract) extract(resp *loadgen.Response) (string, bool) {
+	switch {
+	case e.JSON != "":
+		return jsonPath(resp.Body, e.JSON)
+	case e.Header != "":
+		value := resp.Header.Get(e.Header)
+		return value, value != ""
+	case e.regex != nil:
+		match := e.regex.FindSubmatch(resp.Body)
+		if match == nil {
+			return "", false
+		}
+		return string(match[1]), true
+	}
+	return "", false
+}
+
+// jsonPath 取响应体中点分隔路径的值，字符串原样返回，其他值返回 JSON 文本
+func jsonPath(body []byte, path string) (string, bool) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", false
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			next, ok := node[part]
+			if !ok {
+				return "", false
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			value = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", false
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(data), true
+	}
+}
+
+// render 替换模板中的 ${name}，变量未定义时返回错误；escapeJSON 为 true 时按 JSON 字符串内容转义
+func render(template string, vars map[string]string, escapeJSON bool) (string, error) {
+	var missing string
+	rendered := variablePattern.ReplaceAllStringFunc(template, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, ok := vars[name]
+		if !ok {
+			if missing == "" {
+				missing = name
+			}
+			return ref
+		}
+		if escapeJSON {
+			quoted, _ := json.Marshal(value)
+			return string(quoted[1 : len(quoted)-1])
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable ${%s}", missing)
+	}
+	return rendered, nil
+}
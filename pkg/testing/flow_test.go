@@ -0,0 +1,243 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	gotesting "testing"
+	"time"
+	"user_crud_jwt/pkg/loadgen"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlowServer 登录返回 token-<mobile>，/me 校验令牌后返回手机号，/coupons 创建 ID 为 c1 的优惠券
+func newFlowServer(t *gotesting.T) (*httptest.Server, *sync.Map) {
+	seen := &sync.Map{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/coupons":
+			w.Write([]byte(`{"code":0,"data":{"id":"c1"}}`))
+		case "/login":
+			var body struct {
+				Mobile string `json:"mobile"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Header().Set("X-Session", "s-"+body.Mobile)
+			w.Write([]byte(`{"code":0,"data":"token-` + body.Mobile + `"}`))
+		case "/me":
+			mobile := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer token-")
+			seen.Store(mobile, r.URL.Query().Get("coupon"))
+			w.Write([]byte(`{"code":0,"data":{"mobile":"` + mobile + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, seen
+}
+
+func TestFlow_EndToEnd(t *gotesting.T) {
+	server, seen := newFlowServer(t)
+	defer server.Close()
+
+	feeder, err := NewSliceFeeder([]Record{{"mobile": "1001"}, {"mobile": "1002"}, {"mobile": "1003"}}, FeedUnique)
+	require.NoError(t, err)
+
+	flow := &Flow{
+		Name:   "login",
+		Feeder: feeder,
+		Setup: []Step{{
+			Method:  http.MethodPost,
+			Path:    "/coupons",
+			Extract: []Extract{{Var: "coupon_id", JSON: "data.id"}},
+		}},
+		Think: ConstantThink(time.Millisecond),
+		Steps: []Step{
+			{
+				Name:    "login",
+				Method:  http.MethodPost,
+				Path:    "/login",
+				Body:    json.RawMessage(`{"mobile":"${mobile}"}`),
+				Expect:  &Expect{JSON: map[string]string{"code": "0"}},
+				Extract: []Extract{{Var: "token", JSON: "data"}, {Var: "session", Header: "X-Session"}},
+			},
+			{
+				Name:   "me",
+				Path:   "/me?coupon=${coupon_id}",
+				Header: map[string]string{"Authorization": "Bearer ${token}"},
+				Expect: &Expect{JSON: map[string]string{"data.mobile": "${mobile}"}, Contains: "${mobile}"},
+			},
+		},
+	}
+	scenario, err := flow.Scenario()
+	require.NoError(t, err)
+
+	config := loadgen.DefaultConfig()
+	config.BaseURL = server.URL
+	config.VUs = 2
+	config.Iterations = 5
+	report, err := loadgen.NewRunner(config).Run(context.Background(), scenario)
+	require.NoError(t, err)
+
+	// 三条数据各用一次后虚拟用户停止
+	assert.False(t, report.Failed(), "%v", report.Errors)
+	assert.Equal(t, int64(3), report.Iterations)
+	assert.Equal(t, int64(3), report.ByName["login"].Count)
+	assert.Equal(t, int64(3), report.ByName["me"].Count)
+	for _, mobile := range []string{"1001", "1002", "1003"} {
+		coupon, ok := seen.Load(mobile)
+		assert.True(t, ok, mobile)
+		assert.Equal(t, "c1", coupon)
+	}
+}
+
+func TestFlow_StepFailures(t *gotesting.T) {
+	server, _ := newFlowServer(t)
+	defer server.Close()
+
+	flow := &Flow{
+		Name: "failures",
+		Vars: map[string]string{"mobile": "1001"},
+		Steps: []Step{
+			{Name: "missing", Path: "/missing", Expect: &Expect{Status: []int{200}}, ContinueOnFailure: true},
+			{Name: "extract", Method: http.MethodPost, Path: "/login", Body: json.RawMessage(`{"mobile":"${mobile}"}`),
+				Extract: []Extract{{Var: "id", Regex: `"id":"([^"]+)"`}}},
+			{Name: "never", Path: "/me"},
+		},
+	}
+	scenario, err := flow.Scenario()
+	require.NoError(t, err)
+
+	config := loadgen.DefaultConfig()
+	config.BaseURL = server.URL
+	config.VUs = 1
+	report, err := loadgen.NewRunner(config).Run(context.Background(), scenario)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), report.FailedIterations)
+	assert.Equal(t, int64(1), report.ByName["missing"].Failures)
+	assert.Equal(t, int64(1), report.ByName["extract"].Count)
+	assert.Equal(t, int64(1), report.Errors["iteration: step extract: id not found in response"])
+	assert.Nil(t, report.ByName["never"])
+}
+
+func TestFlow_Validation(t *gotesting.T) {
+	_, err := (&Flow{}).Scenario()
+	assert.Error(t, err)
+
+	_, err = (&Flow{Steps: []Step{{Path: "/a", Extract: []Extract{{Var: "x", JSON: "a", Header: "b"}}}}}).Scenario()
+	assert.Error(t, err)
+
+	_, err = (&Flow{Steps: []Step{{Path: "/a", Extract: []Extract{{Var: "x", Regex: "no-group"}}}}}).Scenario()
+	assert.Error(t, err)
+
+	_, err = (&Flow{Steps: []Step{{Path: "/a"}}, Think: &ThinkTime{Distribution: "poisson"}}).Scenario()
+	assert.Error(t, err)
+}
+
+func TestRender(t *gotesting.T) {
+	vars := map[string]string{"name": `a"b`, "id": "7"}
+
+	out, err := render(`/users/${id}`, vars, false)
+	require.NoError(t, err)
+	assert.Equal(t, "/users/7", out)
+
+	out, err = render(`{"name":"${name}","id":${id}}`, vars, true)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"a\"b","id":7}`, out)
+
+	_, err = render(`${missing}`, vars, false)
+	assert.EqualError(t, err, "undefined variable ${missing}")
+}
+
+func TestJSONPath(t *gotesting.T) {
+	body := []byte(`{"data":{"items":[{"id":"x1","n":3}],"ok":true}}`)
+
+	for path, want := range map[string]string{
+		"data.items.0.id": "x1",
+		"data.items.0.n":  "3",
+		"data.ok":         "true",
+	} {
+		got, ok := jsonPath(body, path)
+		assert.True(t, ok, path)
+		assert.Equal(t, want, got, path)
+	}
+	for _, path := range []string{"data.items.1.id", "data.missing", "data.ok.x"} {
+		_, ok := jsonPath(body, path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestThinkTime(t *gotesting.T) {
+	uniform := UniformThink(10*time.Millisecond, 20*time.Millisecond)
+	normal := NormalThink(10*time.Millisecond, 5*time.Millisecond)
+	exponential := ExponentialThink(10 * time.Millisecond)
+	for i := 0; i < 1000; i++ {
+		d := uniform.Sample()
+		assert.True(t, d >= 10*time.Millisecond && d < 20*time.Millisecond, d)
+		d = normal.Sample()
+		assert.True(t, d >= 0 && d <= 25*time.Millisecond, d)
+		d = exponential.Sample()
+		assert.True(t, d >= 0 && d <= 50*time.Millisecond, d)
+	}
+	assert.Equal(t, 5*time.Millisecond, ConstantThink(5*time.Millisecond).Sample())
+	assert.Zero(t, (*ThinkTime)(nil).Sample())
+
+	var parsed ThinkTime
+	require.NoError(t, json.Unmarshal([]byte(`{"distribution":"uniform","min":"1s","max":1500}`), &parsed))
+	assert.Equal(t, Duration(time.Second), parsed.Min)
+	assert.Equal(t, Duration(1500*time.Millisecond), parsed.Max)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, ConstantThink(time.Hour).Wait(ctx))
+}
+
+func TestFeeders(t *gotesting.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "users.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("mobile, name\n1001, Alice\n1002,\"Bob, Jr\"\n"), 0o600))
+	jsonPath := filepath.Join(dir, "payloads.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`[{"sku":"a","qty":2,"tags":["x"]}]`), 0o600))
+
+	feeder, err := LoadFeeder(csvPath, FeedCircular)
+	require.NoError(t, err)
+	assert.Equal(t, 2, feeder.Len())
+	first, _ := feeder.Next()
+	second, _ := feeder.Next()
+	third, _ := feeder.Next()
+	assert.Equal(t, Record{"mobile": "1001", "name": "Alice"}, first)
+	assert.Equal(t, "Bob, Jr", second["name"])
+	assert.Equal(t, first, third)
+
+	feeder, err = LoadFeeder(jsonPath, FeedUnique)
+	require.NoError(t, err)
+	record, err := feeder.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Record{"sku": "a", "qty": "2", "tags": `["x"]`}, record)
+	_, err = feeder.Next()
+	assert.ErrorIs(t, err, ErrFeederExhausted)
+
+	_, err = NewSliceFeeder(nil, FeedRandom)
+	assert.Error(t, err)
+	_, err = NewSliceFeeder([]Record{{}}, "shuffle")
+	assert.Error(t, err)
+}
+
+func TestLoadFlow(t *gotesting.T) {
+	flow, err := LoadFlow("../../configs/loadtest/login_claim.json")
+	require.NoError(t, err)
+	assert.Equal(t, "login_claim", flow.Name)
+	require.NotNil(t, flow.Feeder)
+
+	_, err = flow.Scenario()
+	require.NoError(t, err)
+	assert.Equal(t, "claim coupon", flow.Steps[3].Name)
+	assert.Equal(t, http.MethodGet, flow.Steps[2].Method)
+}
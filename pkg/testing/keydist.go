@@ -0,0 +1,112 @@
+package testing
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Distribution 合成的键访问分布，Skew 为 0 时均匀分布，大于 1 时为 Zipf 分布（越大越集中在少数热点键）
+type Distribution struct {
+	Name string  `json:"name"`
+	Keys int     `json:"keys"`
+	Skew float64 `json:"skew,omitempty"`
+}
+
+// Uniform keys 个键均匀访问
+func Uniform(keys int) Distribution {
+	return Distribution{Name: "uniform", Keys: keys}
+}
+
+// Zipfian keys 个键按 Zipf 分布访问，s 必须大于 1，常用 1.1（接近真实流量）到 2（极端热点）
+func Zipfian(keys int, s float64) Distribution {
+	return Distribution{Name: fmt.Sprintf("zipf(%.2g)", s), Keys: keys, Skew: s}
+}
+
+// Validate 校验分布
+func (d Distribution) Validate() error {
+	if d.Keys <= 0 {
+		return fmt.Errorf("distribution %s requires at least one key", d.Name)
+	}
+	if d.Skew != 0 && d.Skew <= 1 {
+		return fmt.Errorf("distribution %s: zipf skew must be greater than 1, got %v", d.Name, d.Skew)
+	}
+	return nil
+}
+
+// Sample 按分布生成 n 个键序号，相同的 seed 得到相同的序列；序号 0 是最热的键
+func (d Distribution) Sample(seed int64, n int) []int {
+	r := rand.New(rand.NewSource(seed))
+	sample := make([]int, n)
+	if d.Skew == 0 {
+		for i := range sample {
+			sample[i] = r.Intn(d.Keys)
+		}
+		return sample
+	}
+	zipf := rand.NewZipf(r, d.Skew, 1, uint64(d.Keys-1))
+	for i := range sample {
+		sample[i] = int(zipf.Uint64())
+	}
+	return sample
+}
+
+// BenchKey 键序号对应的缓存键
+func BenchKey(i int) string {
+	return fmt.Sprintf("bench:key:%d", i)
+}
+
+// HotKeys 样本中出现次数最多的 n 个键，次数相同时按序号排列
+func HotKeys(sample []int, n int) []string {
+	counts := make(map[int]int)
+	for _, key := range sample {
+		counts[key]++
+	}
+	ids := make([]int, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		if counts[ids[a]] != counts[ids[b]] {
+			return counts[ids[a]] > counts[ids[b]]
+		}
+		return ids[a] < ids[b]
+	})
+	if n > len(ids) {
+		n = len(ids)
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = BenchKey(ids[i])
+	}
+	return keys
+}
+
+// BenchPayload 基准测试使用的缓存值，结构与动态、用户资料等业务缓存相近
+type BenchPayload struct {
+	ID        int64             `json:"id"`
+	Name      string            `json:"name"`
+	Tags      []string          `json:"tags"`
+	Score     float64           `json:"score"`
+	Attrs     map[string]string `json:"attrs"`
+	Body      string            `json:"body"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// NewBenchPayload 键序号 i 对应的值，内容确定，正文长度在 64～1024 字节之间随序号变化
+func NewBenchPayload(i int) *BenchPayload {
+	return &BenchPayload{
+		ID:    int64(i),
+		Name:  fmt.Sprintf("user-%d", i),
+		Tags:  []string{"bench", fmt.Sprintf("group-%d", i%16), fmt.Sprintf("shard-%d", i%4)},
+		Score: float64(i%1000) / 10,
+		Attrs: map[string]string{
+			"city":   fmt.Sprintf("city-%d", i%32),
+			"source": "bench",
+		},
+		Body:      strings.Repeat("x", 64+(i*37)%961),
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(i) * time.Minute),
+	}
+}
@@ -0,0 +1,133 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Duration 可从 "1.5s" 这样的字符串解析的时长，用于场景脚本
+type Duration time.Duration
+
+// UnmarshalJSON 支持字符串（time.ParseDuration 格式）与毫秒数
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	*d = Duration(ms * float64(time.Millisecond))
+	return nil
+}
+
+// MarshalJSON 输出为字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ThinkTime 步骤之间的思考时间分布
+type ThinkTime struct {
+	// Distribution constant（Min）、uniform（Min～Max）、normal（Mean、StdDev，截断到 Min～Max）
+	// 或 exponential（Mean，截断到 Max），为空时不等待
+	Distribution string   `json:"distribution"`
+	Min          Duration `json:"min,omitempty"`
+	Max          Duration `json:"max,omitempty"`
+	Mean         Duration `json:"mean,omitempty"`
+	StdDev       Duration `json:"stddev,omitempty"`
+}
+
+// ConstantThink 固定思考时间
+func ConstantThink(d time.Duration) *ThinkTime {
+	return &ThinkTime{Distribution: "constant", Min: Duration(d)}
+}
+
+// UniformThink min～max 之间均匀分布
+func UniformThink(min, max time.Duration) *ThinkTime {
+	return &ThinkTime{Distribution: "uniform", Min: Duration(min), Max: Duration(max)}
+}
+
+// NormalThink 正态分布，截断到 0～mean+3*stddev
+func NormalThink(mean, stddev time.Duration) *ThinkTime {
+	return &ThinkTime{Distribution: "normal", Mean: Duration(mean), StdDev: Duration(stddev), Max: Duration(mean + 3*stddev)}
+}
+
+// ExponentialThink 指数分布，模拟相互独立的用户操作间隔，截断到 mean 的 5 倍
+func ExponentialThink(mean time.Duration) *ThinkTime {
+	return &ThinkTime{Distribution: "exponential", Mean: Duration(mean), Max: Duration(5 * mean)}
+}
+
+// Validate 校验分布参数
+func (t *ThinkTime) Validate() error {
+	switch t.Distribution {
+	case "", "constant":
+	case "uniform":
+		if t.Max < t.Min {
+			return fmt.Errorf("uniform think time: max %v < min %v", time.Duration(t.Max), time.Duration(t.Min))
+		}
+	case "normal", "exponential":
+		if t.Mean <= 0 {
+			return fmt.Errorf("%s think time requires a positive mean", t.Distribution)
+		}
+	default:
+		return fmt.Errorf("unknown think time distribution: %q", t.Distribution)
+	}
+	return nil
+}
+
+// Sample 抽取一次思考时间
+func (t *ThinkTime) Sample() time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	var d time.Duration
+	switch t.Distribution {
+	case "constant":
+		return time.Duration(t.Min)
+	case "uniform":
+		d = time.Duration(t.Min)
+		if span := int64(t.Max - t.Min); span > 0 {
+			d += time.Duration(rand.Int63n(span))
+		}
+		return d
+	case "normal":
+		d = time.Duration(rand.NormFloat64()*float64(t.StdDev) + float64(t.Mean))
+	case "exponential":
+		d = time.Duration(rand.ExpFloat64() * float64(t.Mean))
+	default:
+		return 0
+	}
+	if d < time.Duration(t.Min) {
+		d = time.Duration(t.Min)
+	}
+	if t.Max > 0 && d > time.Duration(t.Max) {
+		d = time.Duration(t.Max)
+	}
+	return d
+}
+
+// Wait 等待一次思考时间，ctx 结束时提前返回
+func (t *ThinkTime) Wait(ctx context.Context) error {
+	d := t.Sample()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
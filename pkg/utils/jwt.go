@@ -1,6 +1,10 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"time"
 	"user_crud_jwt/internal/pkg/config"
 
@@ -11,40 +15,156 @@ import (
 type Claims struct {
 	UserID string `json:"user_id"`
 	Role   int    `json:"role"`
+	// AMR 认证方式（RFC 8176），如 pwd、otp；包含 otp 表示会话已通过两步验证
+	AMR []string `json:"amr,omitempty"`
+	// TenantID 所属租户，请求指定的租户与之不符时被拒绝
+	TenantID string `json:"tenant_id,omitempty"`
+	// PermissionsVersion 签发时用户的权限版本，落后于当前版本时验证阶段重新解析角色
+	PermissionsVersion int64 `json:"pv,omitempty"`
+	// Impersonation 管理员以该用户身份登录的会话，普通令牌为空
+	Impersonation *Impersonation `json:"imp,omitempty"`
+	// Refreshed 验证时已按当前权限重新解析角色，调用方应下发重新签发的令牌，不写入令牌
+	Refreshed bool `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// Impersonation 代登录会话：ActorID 为发起的管理员，会话结束或到期后令牌失效
+type Impersonation struct {
+	SessionID string `json:"sid"`
+	ActorID   string `json:"act"`
+}
+
+// TokenKeys 签名密钥管理（如 jwtkeys.Manager），设置后令牌按 kid 签名与验证
+type TokenKeys interface {
+	Sign(claims jwt.Claims) (string, error)
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+var tokenKeys TokenKeys
+
+// TokenLifetime 令牌有效期
+const TokenLifetime = 30 * 24 * time.Hour
+
+// ErrTokenRevoked 令牌已被吊销
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// TokenRevocations 令牌吊销检查（如 security.SessionRevoker、security.ImpersonationManager）
+type TokenRevocations interface {
+	IsRevoked(ctx context.Context, claims *Claims) bool
+}
+
+var tokenRevocations []TokenRevocations
+
+// ErrPermissionsChanged 令牌签发后用户的角色或权限已变化，且无法重新解析，需重新登录
+var ErrPermissionsChanged = errors.New("permissions have changed since the token was issued")
+
+// PermissionVersions 用户的权限版本（如 security.RBAC），角色或权限变化时递增
+type PermissionVersions interface {
+	PermissionsVersion(ctx context.Context, userID string) (int64, error)
+}
+
+// RoleSource 用户当前的角色（如用户表），令牌中的权限版本落后时据此重新解析
+type RoleSource interface {
+	CurrentRole(ctx context.Context, userID string) (int, error)
+}
+
+var (
+	permissionVersions PermissionVersions
+	roleSource         RoleSource
+)
+
+// SetPermissionVersions 启用权限版本检查，须在处理请求之前调用；未设置时令牌中的角色在过期前一直有效
+func SetPermissionVersions(versions PermissionVersions) {
+	permissionVersions = versions
+}
+
+// SetRoleSource 设置角色来源，权限版本落后的令牌据此重新解析角色；未设置时这类令牌被拒绝，需重新登录
+func SetRoleSource(source RoleSource) {
+	roleSource = source
+}
+
+// SetTokenRevocations 启用令牌吊销检查，任一检查认为已吊销即拒绝，须在处理请求之前调用；未设置时令牌在过期前一直有效
+func SetTokenRevocations(revocations ...TokenRevocations) {
+	tokenRevocations = revocations
+}
+
+// SetTokenKeys 启用签名密钥管理，须在处理请求之前调用；未设置时使用配置的 secret
+func SetTokenKeys(keys TokenKeys) {
+	tokenKeys = keys
+}
+
 // GenerateToken 生成JWT Token
 func GenerateToken(userID string, role int) (string, *time.Time, error) {
+	return GenerateTokenWithAMR(userID, role, nil)
+}
+
+// GenerateTokenWithAMR 生成带认证方式的JWT Token
+func GenerateTokenWithAMR(userID string, role int, amr []string) (string, *time.Time, error) {
+	return GenerateTenantToken("", userID, role, amr)
+}
+
+// GenerateTenantToken 生成绑定租户的JWT Token，tenantID 为空时不绑定
+func GenerateTenantToken(tenantID, userID string, role int, amr []string) (string, *time.Time, error) {
 	now := time.Now()
 	// 设置token过期时间为1个月
-	expireTime := now.Add(30 * 24 * time.Hour) // 30天
+	expireTime := now.Add(TokenLifetime)
 
-	claims := Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expireTime),
-			Issuer:    "user-crud",
-		},
-	}
-
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := tokenClaims.SignedString([]byte(config.GlobalConfig.JWT.Secret))
+	token, err := signClaims(Claims{
+		UserID:   userID,
+		Role:     role,
+		AMR:      amr,
+		TenantID: tenantID,
+	}, now, expireTime)
 	if err != nil {
 		return "", nil, err
 	}
 	return token, &expireTime, nil
 }
 
+// GenerateImpersonationToken 生成代登录令牌，在 expireAt 到期，不随普通令牌的有效期
+func GenerateImpersonationToken(tenantID, userID string, role int, impersonation Impersonation, expireAt time.Time) (string, error) {
+	return signClaims(Claims{
+		UserID:        userID,
+		Role:          role,
+		TenantID:      tenantID,
+		Impersonation: &impersonation,
+	}, time.Now(), expireAt)
+}
+
+// signClaims 补充权限版本、签发与过期时间后签名
+func signClaims(claims Claims, now, expireAt time.Time) (string, error) {
+	// 签发时的权限版本，之后角色或权限变化的令牌在验证时重新解析
+	claims.PermissionsVersion = currentPermissionsVersion(context.Background(), claims.UserID)
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expireAt),
+		IssuedAt:  jwt.NewNumericDate(now), // 吊销检查以签发时间判断令牌是否签发于吊销之前
+		Issuer:    "user-crud",
+	}
+
+	var token string
+	var err error
+	if tokenKeys != nil {
+		token, err = tokenKeys.Sign(claims)
+	} else {
+		tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token, err = tokenClaims.SignedString([]byte(config.GlobalConfig.JWT.Secret))
+	}
+	return token, err
+}
+
 // ParseToken 验证JWT Token
 func ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+	keyfunc := func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
 		}
 		return []byte(config.GlobalConfig.JWT.Secret), nil
-	})
+	}
+	if tokenKeys != nil {
+		keyfunc = tokenKeys.Keyfunc
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keyfunc)
 
 	if err != nil {
 		return nil, err
@@ -56,3 +176,77 @@ func ParseToken(tokenString string) (*Claims, error) {
 
 	return nil, jwt.ErrTokenInvalidClaims
 }
+
+// ParseTokenContext 验证JWT Token 并检查是否已被吊销
+func ParseTokenContext(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return CheckClaims(ctx, claims)
+}
+
+// CheckClaims 检查已由 ParseToken 验证签名的令牌是否已被吊销，权限版本落后时按当前角色重新解析
+func CheckClaims(ctx context.Context, claims *Claims) (*Claims, error) {
+	for _, revocations := range tokenRevocations {
+		if revocations.IsRevoked(ctx, claims) {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return resolvePermissions(ctx, claims)
+}
+
+// ReissueToken 按验证后的 Claims 重新签发令牌，保留租户与认证方式，带上当前的角色与权限版本；
+// 代登录令牌保留会话与原到期时间
+func ReissueToken(claims *Claims) (string, *time.Time, error) {
+	if claims.Impersonation != nil && claims.ExpiresAt != nil {
+		expireAt := claims.ExpiresAt.Time
+		token, err := GenerateImpersonationToken(claims.TenantID, claims.UserID, claims.Role, *claims.Impersonation, expireAt)
+		if err != nil {
+			return "", nil, err
+		}
+		return token, &expireAt, nil
+	}
+	return GenerateTenantToken(claims.TenantID, claims.UserID, claims.Role, claims.AMR)
+}
+
+// resolvePermissions 令牌中的权限版本落后于用户当前的版本时，从角色来源重新解析角色并标记 Refreshed。
+// 与吊销检查相同，读取版本失败时放行；重新解析失败时拒绝，避免继续使用可能已被撤销的角色
+func resolvePermissions(ctx context.Context, claims *Claims) (*Claims, error) {
+	if permissionVersions == nil || claims.UserID == "" {
+		return claims, nil
+	}
+	current, err := permissionVersions.PermissionsVersion(ctx, claims.UserID)
+	if err != nil {
+		log.Printf("Failed to check permissions version of user %s: %v", claims.UserID, err)
+		return claims, nil
+	}
+	// 版本过期后回到 0，早于此签发的令牌不再视为落后
+	if current <= claims.PermissionsVersion {
+		return claims, nil
+	}
+	if roleSource == nil {
+		return nil, ErrPermissionsChanged
+	}
+	role, err := roleSource.CurrentRole(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPermissionsChanged, err)
+	}
+	claims.Role = role
+	claims.PermissionsVersion = current
+	claims.Refreshed = true
+	return claims, nil
+}
+
+// currentPermissionsVersion 签发令牌时用户的权限版本，读取失败时为 0，令牌在下次验证时重新解析
+func currentPermissionsVersion(ctx context.Context, userID string) int64 {
+	if permissionVersions == nil {
+		return 0
+	}
+	version, err := permissionVersions.PermissionsVersion(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to read permissions version of user %s: %v", userID, err)
+		return 0
+	}
+	return version
+}
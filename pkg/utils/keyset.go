@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SortColumn 排序列
+type SortColumn struct {
+	Name string
+	Desc bool
+}
+
+// Keyset 键集（seek）分页定义，最后一列必须唯一以保证排序稳定
+type Keyset struct {
+	Columns []SortColumn
+}
+
+// CursorPagination 游标分页请求参数
+type CursorPagination struct {
+	Cursor string `json:"cursor" form:"cursor"`
+	Limit  int    `json:"limit" form:"limit"`
+}
+
+var columnNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// NewKeyset 创建键集分页定义，tieBreaker（通常为主键）追加为最后一列，方向与首列一致
+func NewKeyset(tieBreaker string, columns ...SortColumn) (*Keyset, error) {
+	desc := false
+	if len(columns) > 0 {
+		desc = columns[0].Desc
+	}
+	all := append(append([]SortColumn{}, columns...), SortColumn{Name: tieBreaker, Desc: desc})
+
+	for _, column := range all {
+		if !columnNamePattern.MatchString(column.Name) {
+			return nil, fmt.Errorf("invalid sort column: %q", column.Name)
+		}
+	}
+	return &Keyset{Columns: all}, nil
+}
+
+// GetLimit 规范化每页数量，查询时应使用 limit+1 判断是否还有下一页
+func (p *CursorPagination) GetLimit() int {
+	if p.Limit <= 0 {
+		p.Limit = 10
+	}
+	if p.Limit > 100 {
+		p.Limit = 100
+	}
+	return p.Limit
+}
+
+// OrderBy 返回 ORDER BY 子句内容
+func (k *Keyset) OrderBy() string {
+	parts := make([]string, len(k.Columns))
+	for i, column := range k.Columns {
+		if column.Desc {
+			parts[i] = column.Name + " DESC"
+		} else {
+			parts[i] = column.Name + " ASC"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Where 根据游标生成 seek 条件，startParam 为第一个占位符序号（$n）
+// 游标为空时返回空条件
+func (k *Keyset) Where(cursor string, startParam int) (string, []interface{}, error) {
+	if cursor == "" {
+		return "", nil, nil
+	}
+
+	values, err := DecodeCursor(cursor)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(values) != len(k.Columns) {
+		return "", nil, fmt.Errorf("cursor has %d values, expected %d", len(values), len(k.Columns))
+	}
+
+	// 方向一致时使用行比较，可以直接利用复合索引
+	if k.uniformDirection() {
+		op := ">"
+		if k.Columns[0].Desc {
+			op = "<"
+		}
+		names := make([]string, len(k.Columns))
+		params := make([]string, len(k.Columns))
+		for i, column := range k.Columns {
+			names[i] = column.Name
+			params[i] = fmt.Sprintf("$%d", startParam+i)
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(names, ", "), op, strings.Join(params, ", ")), values, nil
+	}
+
+	// 方向混合时展开为 (a > $1) OR (a = $1 AND b < $2) ...
+	var args []interface{}
+	param := startParam
+	ors := make([]string, len(k.Columns))
+	for i, column := range k.Columns {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("%s = $%d", k.Columns[j].Name, param))
+			args = append(args, values[j])
+			param++
+		}
+		op := ">"
+		if column.Desc {
+			op = "<"
+		}
+		ands = append(ands, fmt.Sprintf("%s %s $%d", column.Name, op, param))
+		args = append(args, values[i])
+		param++
+		ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args, nil
+}
+
+// NextCursor 根据当前页最后一行的排序列值生成下一页游标
+func (k *Keyset) NextCursor(values ...interface{}) (string, error) {
+	if len(values) != len(k.Columns) {
+		return "", fmt.Errorf("got %d cursor values, expected %d", len(values), len(k.Columns))
+	}
+	return EncodeCursor(values)
+}
+
+func (k *Keyset) uniformDirection() bool {
+	for _, column := range k.Columns[1:] {
+		if column.Desc != k.Columns[0].Desc {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeCursor 将排序列值编码为不透明游标
+func EncodeCursor(values []interface{}) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor 解码游标，数值保留为 json.Number 以避免大整数精度丢失
+func DecodeCursor(cursor string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var values []interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	for i, value := range values {
+		if number, ok := value.(json.Number); ok {
+			values[i] = number.String()
+		}
+	}
+	return values, nil
+}
@@ -6,14 +6,6 @@ type Pagination struct {
 	Limit int `json:"limit" form:"limit"`
 }
 
-// PageResult 分页响应结果
-type PageResult struct {
-	List  interface{} `json:"list"`
-	Total int64       `json:"total"`
-	Page  int         `json:"page"`
-	Limit int         `json:"limit"`
-}
-
 // GetPageOffset 计算分页偏移量
 func (p *Pagination) GetPageOffset() (int, int) {
 	if p.Page <= 0 {
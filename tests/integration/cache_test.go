@@ -0,0 +1,105 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedisCache 清空 Redis 后返回 RedisCache
+func newRedisCache(t *testing.T) cache.CacheService {
+	t.Helper()
+	require.NoError(t, deps.redis.FlushDB(context.Background()).Err())
+	return cache.NewRedisCache(deps.redis)
+}
+
+func TestCacheConsistency_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	redisCache := newRedisCache(t)
+	clock := fakes.NewClock(time.Time{})
+	manager := cache.NewCacheConsistencyManager(redisCache, nil, &cache.ConsistencyConfig{Clock: clock})
+	defer manager.Close()
+
+	for _, key := range []string{"user:1", "user:2", "user:3"} {
+		require.NoError(t, redisCache.Set(ctx, key, map[string]string{"key": key}, time.Minute))
+	}
+
+	require.NoError(t, manager.Invalidate(ctx, "immediate", []string{"user:1"}))
+	exists, err := redisCache.Exists(ctx, "user:1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// 延迟失效在时钟推进 5 秒后删除 Redis 中的键
+	require.NoError(t, manager.Invalidate(ctx, "delayed", []string{"user:2"}))
+	clock.Advance(4 * time.Second)
+	exists, err = redisCache.Exists(ctx, "user:2")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	clock.Advance(time.Second)
+	exists, err = redisCache.Exists(ctx, "user:2")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, redisCache.InvalidatePattern(ctx, "user:*"))
+	exists, err = redisCache.Exists(ctx, "user:3")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestCacheConsistency_TagVersionsSharedAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	redisCache := newRedisCache(t)
+	// 两个实例各自创建策略，版本号保存在共享的 Redis 中
+	writer := cache.NewTagInvalidationStrategy(redisCache)
+	reader := cache.NewTagInvalidationStrategy(cache.NewRedisCache(deps.redis))
+
+	versions, err := reader.Versions(ctx, []string{"user:1", "user:2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"user:1": 0, "user:2": 0}, versions)
+
+	require.NoError(t, writer.InvalidateTags(ctx, "user:1"))
+	versions, err = reader.Versions(ctx, []string{"user:1", "user:2"})
+	require.NoError(t, err)
+	assert.Positive(t, versions["user:1"])
+	assert.Zero(t, versions["user:2"])
+}
+
+func TestRedisCache_Batch(t *testing.T) {
+	ctx := context.Background()
+	redisCache := newRedisCache(t)
+
+	entries := make([]cache.CacheEntry, 0, 50)
+	keys := make([]string, 0, 51)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("coupon:%d", i)
+		entries = append(entries, cache.CacheEntry{Key: key, Value: i, Expiration: time.Minute})
+		keys = append(keys, key)
+	}
+	keys = append(keys, "coupon:missing")
+
+	setResult, err := redisCache.SetMany(ctx, entries)
+	require.NoError(t, err)
+	assert.False(t, setResult.HasErrors())
+	assert.Len(t, setResult.Succeeded, 50)
+
+	getResult, err := redisCache.GetMany(ctx, keys)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"coupon:missing"}, getResult.Missing)
+	var value int
+	require.NoError(t, getResult.Decode("coupon:42", &value))
+	assert.Equal(t, 42, value)
+
+	var got int
+	ttl, err := redisCache.GetWithTTL(ctx, "coupon:7", &got)
+	require.NoError(t, err)
+	assert.Equal(t, 7, got)
+	assert.InDelta(t, time.Minute.Seconds(), ttl.Seconds(), 2)
+}
@@ -0,0 +1,84 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedisCluster 连接测试集群，测试结束时关闭
+func newRedisCluster(t *testing.T) *cache.RedisCluster {
+	t.Helper()
+	cluster, err := cache.NewRedisCluster(&cache.RedisClusterConfig{
+		Nodes:               deps.clusterNodes,
+		PoolSize:            10,
+		HealthCheckInterval: time.Minute,
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { cluster.Close() })
+	return cluster
+}
+
+func TestRedisCluster_CrossSlotBatch(t *testing.T) {
+	ctx := context.Background()
+	cluster := newRedisCluster(t)
+	require.NoError(t, cluster.Health(ctx))
+
+	// 不同的键分布在不同槽位上，批量读写按槽位拆分，不会返回 CROSSSLOT 错误
+	prefix := fmt.Sprintf("it:%d", time.Now().UnixNano())
+	entries := make([]cache.CacheEntry, 0, 30)
+	keys := make([]string, 0, 30)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("%s:user:%d", prefix, i)
+		entries = append(entries, cache.CacheEntry{Key: key, Value: map[string]int{"id": i}, Expiration: time.Minute})
+		keys = append(keys, key)
+	}
+	setResult, err := cluster.SetMany(ctx, entries)
+	require.NoError(t, err)
+	assert.False(t, setResult.HasErrors())
+
+	getResult, err := cluster.GetMany(ctx, append(keys, prefix+":missing"))
+	require.NoError(t, err)
+	assert.False(t, getResult.HasErrors())
+	assert.Equal(t, []string{prefix + ":missing"}, getResult.Missing)
+	var value map[string]int
+	require.NoError(t, getResult.Decode(keys[17], &value))
+	assert.Equal(t, 17, value["id"])
+
+	values, err := cluster.MGet(ctx, keys...)
+	require.NoError(t, err)
+	assert.Len(t, values, 30)
+	for i, v := range values {
+		assert.NotNil(t, v, keys[i])
+	}
+}
+
+func TestRedisCluster_Counters(t *testing.T) {
+	ctx := context.Background()
+	cluster := newRedisCluster(t)
+	key := fmt.Sprintf("it:%d:stock", time.Now().UnixNano())
+
+	require.NoError(t, cluster.SetJSON(ctx, key, 10, time.Minute))
+	remaining, err := cluster.Decrement(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), remaining)
+
+	var stored int
+	require.NoError(t, cluster.GetJSON(ctx, key, &stored))
+	assert.Equal(t, 9, stored)
+
+	ttl, err := cluster.TTL(ctx, key)
+	require.NoError(t, err)
+	assert.Positive(t, ttl)
+	require.NoError(t, cluster.Delete(ctx, key))
+	exists, err := cluster.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,80 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// operationRecorder 记录 database.DB 对每条语句的读写判定
+type operationRecorder struct {
+	mu         sync.Mutex
+	operations []string
+	rejectWith error // 非 nil 时拒绝写操作
+}
+
+func (r *operationRecorder) hook(ctx context.Context, operation, query string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, operation)
+	if operation == "write" && r.rejectWith != nil {
+		return r.rejectWith
+	}
+	return nil
+}
+
+// 项目没有读写分离的连接路由，database.DB 按语句判定读写供故障注入等使用，这里针对真实数据库验证判定结果
+func TestDatabase_ReadWriteRouting(t *testing.T) {
+	ctx := context.Background()
+	recorder := &operationRecorder{}
+	deps.db.SetFaultHook(recorder.hook)
+	t.Cleanup(func() { deps.db.SetFaultHook(nil) })
+
+	var admins int
+	require.NoError(t, deps.db.GetContext(ctx, &admins, `SELECT COUNT(*) FROM users WHERE username = $1`, "it_admin"))
+	assert.Equal(t, 1, admins, "seed data is loaded")
+
+	var names []string
+	require.NoError(t, deps.db.SelectContext(ctx, &names, `
+		WITH seeded AS (SELECT username FROM users WHERE username LIKE 'it\_%')
+		SELECT username FROM seeded ORDER BY username`))
+	assert.Equal(t, []string{"it_admin", "it_alice"}, names)
+
+	_, err := deps.db.ExecContext(ctx, `UPDATE users SET email = $1 WHERE username = $2`, "alice@example.org", "it_alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "read", "write"}, recorder.operations)
+
+	// 拒绝写操作时语句不会发送到数据库，读操作不受影响
+	rejected := errors.New("primary unavailable")
+	recorder.rejectWith = rejected
+	_, err = deps.db.ExecContext(ctx, `UPDATE users SET email = $1 WHERE username = $2`, "changed@example.org", "it_alice")
+	assert.ErrorIs(t, err, rejected)
+	_, err = deps.db.BeginTx(ctx, nil)
+	assert.ErrorIs(t, err, rejected)
+
+	var email string
+	require.NoError(t, deps.db.GetContext(ctx, &email, `SELECT email FROM users WHERE username = $1`, "it_alice"))
+	assert.Equal(t, "alice@example.org", email)
+}
+
+func TestDatabase_ReadOnlyTransactionRejectsWrites(t *testing.T) {
+	ctx := context.Background()
+	tx, err := deps.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	var count int
+	require.NoError(t, tx.GetContext(ctx, &count, `SELECT COUNT(*) FROM users`))
+	assert.GreaterOrEqual(t, count, 2)
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET email = email WHERE username = $1`, "it_admin")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read-only transaction")
+}
@@ -0,0 +1,10 @@
+// Package integration 端到端集成测试：以 docker 启动 PostgreSQL、Redis 单机与 Redis 集群，执行迁移与种子数据后，
+// 针对真实服务验证缓存一致性、数据库读写路由、RBAC 持久化与库存预留。
+//
+// 测试带 integration 构建标签，默认的 go test ./... 不会运行：
+//
+//	go test -tags integration ./tests/integration/...
+//
+// 已有服务时可通过 INTEGRATION_POSTGRES_DSN、INTEGRATION_REDIS_ADDR、INTEGRATION_REDIS_CLUSTER（逗号分隔的节点地址）
+// 指定，不再启动对应的容器；没有 docker 且未指定服务时跳过全部测试
+package integration
@@ -0,0 +1,92 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// container 通过 docker 命令行启动的容器，不依赖 docker SDK
+type container struct {
+	name string
+	id   string
+}
+
+// dockerAvailable 本机是否可以使用 docker
+func dockerAvailable() bool {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return false
+	}
+	return exec.Command("docker", "info").Run() == nil
+}
+
+// startContainer 以 docker run -d 启动容器，args 为镜像前的参数（如 -e、-p），cmd 为镜像后的命令
+func startContainer(name, image string, args []string, cmd ...string) (*container, error) {
+	runArgs := append([]string{"run", "-d", "--rm", "--name", name}, args...)
+	runArgs = append(runArgs, image)
+	runArgs = append(runArgs, cmd...)
+	out, err := docker(runArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", image, err)
+	}
+	return &container{name: name, id: strings.TrimSpace(out)}, nil
+}
+
+// hostAddr 容器端口映射到本机的地址，如 127.0.0.1:49153
+func (c *container) hostAddr(port string) (string, error) {
+	out, err := docker("port", c.id, port)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect port %s of %s: %w", port, c.name, err)
+	}
+	// 同时映射 IPv4 与 IPv6 时输出多行，取第一行
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	addr := strings.TrimSpace(line)
+	if strings.HasPrefix(addr, "0.0.0.0:") {
+		addr = "127.0.0.1:" + strings.TrimPrefix(addr, "0.0.0.0:")
+	}
+	if addr == "" {
+		return "", fmt.Errorf("port %s of %s is not published", port, c.name)
+	}
+	return addr, nil
+}
+
+// logs 容器日志的最后若干行，启动失败时附在错误中
+func (c *container) logs() string {
+	out, _ := docker("logs", "--tail", "20", c.id)
+	return out
+}
+
+// remove 删除容器及其匿名卷
+func (c *container) remove() {
+	docker("rm", "-f", "-v", c.id)
+}
+
+// docker 执行 docker 命令并返回标准输出
+func docker(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// waitFor 每隔 500ms 调用 check，直到成功或超时
+func waitFor(timeout time.Duration, check func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v: %w", timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
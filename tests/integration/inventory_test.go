@@ -0,0 +1,140 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/inventory"
+	"user_crud_jwt/pkg/testing/fakes"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySource 内存中的库存数据源
+type memorySource struct {
+	capacity  map[string]int64
+	committed map[string]map[string]int64
+}
+
+func (s *memorySource) Capacity(ctx context.Context, resourceID string) (int64, error) {
+	capacity, ok := s.capacity[resourceID]
+	if !ok {
+		return 0, inventory.ErrUnknownResource
+	}
+	return capacity, nil
+}
+
+func (s *memorySource) Committed(ctx context.Context, resourceID string) (map[string]int64, error) {
+	return s.committed[resourceID], nil
+}
+
+func (s *memorySource) ActiveResources(ctx context.Context) ([]string, error) {
+	var ids []string
+	for id := range s.capacity {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// newInventory 清空 Redis 后返回使用假时钟的库存管理
+func newInventory(t *testing.T) (*inventory.Manager, *fakes.Clock) {
+	t.Helper()
+	require.NoError(t, deps.redis.FlushDB(context.Background()).Err())
+	clock := fakes.NewClock(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	manager := inventory.NewManager(deps.redis, nil)
+	manager.SetClock(clock)
+	return manager, clock
+}
+
+func assertAvailable(t *testing.T, manager *inventory.Manager, kind, resourceID string, expected int64) {
+	t.Helper()
+	available, err := manager.Available(context.Background(), kind, resourceID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, available, resourceID)
+}
+
+func TestInventory_ReserveConfirmAndExpire(t *testing.T) {
+	ctx := context.Background()
+	manager, clock := newInventory(t)
+	require.NoError(t, manager.SetCapacity(ctx, "sku", "tea", 5))
+	for _, seat := range []string{"A1", "A2", "A3"} {
+		require.NoError(t, manager.SetCapacity(ctx, "seat", seat, 1))
+	}
+
+	require.NoError(t, manager.Reserve(ctx, "sku", "order-1", inventory.Item{ResourceID: "tea", Quantity: 3}, 0))
+	assertAvailable(t, manager, "sku", "tea", 2)
+	assert.ErrorIs(t, manager.Reserve(ctx, "sku", "order-2", inventory.Item{ResourceID: "tea", Quantity: 3}, 0), inventory.ErrOutOfStock)
+	assert.ErrorIs(t, manager.Reserve(ctx, "sku", "order-1", inventory.Item{ResourceID: "tea", Quantity: 1}, 0), inventory.ErrAlreadyHeld)
+	require.NoError(t, manager.Reserve(ctx, "sku", "order-2", inventory.Item{ResourceID: "tea", Quantity: 2}, time.Hour))
+	require.NoError(t, manager.Confirm(ctx, "sku", "order-1", "tea"))
+
+	// 座位整体预留，任一座位被占时其他座位也不预留
+	require.NoError(t, manager.ReserveAll(ctx, "seat", "u1", []inventory.Item{{ResourceID: "A1", Quantity: 1}, {ResourceID: "A2", Quantity: 1}}, 0))
+	err := manager.ReserveAll(ctx, "seat", "u2", []inventory.Item{{ResourceID: "A3", Quantity: 1}, {ResourceID: "A2", Quantity: 1}}, 0)
+	var reserveErr *inventory.ReserveError
+	require.ErrorAs(t, err, &reserveErr)
+	assert.Equal(t, "A2", reserveErr.ResourceID)
+	assert.ErrorIs(t, err, inventory.ErrOutOfStock)
+	assertAvailable(t, manager, "seat", "A3", 1)
+	assert.ErrorIs(t, manager.ReserveAll(ctx, "seat", "u2", []inventory.Item{{ResourceID: "A3", Quantity: 1}, {ResourceID: "A3", Quantity: 1}}, 0), inventory.ErrInvalidItem)
+
+	// 超过默认 5 分钟未确认的预留被释放，已确认与未到期的保留
+	clock.Advance(6 * time.Minute)
+	results := manager.SweepAll(ctx)
+	require.Len(t, results, 1)
+	assert.Equal(t, &inventory.SweepResult{Kind: "seat", Released: 2, Quantity: 2}, results[0])
+	assertAvailable(t, manager, "seat", "A1", 1)
+	assertAvailable(t, manager, "seat", "A2", 1)
+	assertAvailable(t, manager, "sku", "tea", 0)
+	assert.ErrorIs(t, manager.Confirm(ctx, "seat", "u1", "A1"), inventory.ErrReservationNotFound)
+
+	released, err := manager.Release(ctx, "sku", "order-1", "tea")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), released)
+	released, err = manager.Release(ctx, "sku", "order-1", "tea")
+	require.NoError(t, err)
+	assert.Zero(t, released, "release is idempotent")
+	assertAvailable(t, manager, "sku", "tea", 3)
+}
+
+func TestInventory_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	manager, _ := newInventory(t)
+	source := &memorySource{
+		capacity:  map[string]int64{"c1": 3},
+		committed: map[string]map[string]int64{"c1": {"u1": 1}},
+	}
+	manager.RegisterSource("coupon", source)
+
+	// 库存未初始化时按数据源恢复后预留
+	require.NoError(t, manager.Reserve(ctx, "coupon", "u2", inventory.Item{ResourceID: "c1", Quantity: 1}, 0))
+	assertAvailable(t, manager, "coupon", "c1", 1)
+	assert.ErrorIs(t, manager.Reserve(ctx, "coupon", "u1", inventory.Item{ResourceID: "c1", Quantity: 1}, 0), inventory.ErrAlreadyHeld)
+	assert.ErrorIs(t, manager.Reserve(ctx, "coupon", "u1", inventory.Item{ResourceID: "missing", Quantity: 1}, 0), inventory.ErrUnknownResource)
+
+	// u2 落库但未确认、u3 已确认但未落库、u1 的 Redis 记录丢失
+	require.NoError(t, manager.Reserve(ctx, "coupon", "u3", inventory.Item{ResourceID: "c1", Quantity: 1}, 0))
+	require.NoError(t, manager.Confirm(ctx, "coupon", "u3", "c1"))
+	source.committed["c1"]["u2"] = 1
+	_, err := manager.Release(ctx, "coupon", "u1", "c1")
+	require.NoError(t, err)
+
+	results, err := manager.ReconcileAll(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, &inventory.ReconcileResult{
+		Kind:        "coupon",
+		ResourceID:  "c1",
+		Restored:    1,
+		Compensated: 1,
+		Confirmed:   1,
+		StockBefore: 2,
+		StockAfter:  1,
+	}, results[0])
+	held, err := manager.Held(ctx, "coupon", "u1", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), held)
+}
@@ -0,0 +1,211 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"user_crud_jwt/pkg/database"
+
+	redisv8 "github.com/go-redis/redis/v8"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+)
+
+// errNoDocker 没有 docker 且未指定已有服务
+var errNoDocker = errors.New("docker is not available")
+
+// 容器镜像，与 docker-compose 中使用的大版本一致
+const (
+	postgresImage     = "postgres:16-alpine"
+	redisImage        = "redis:7-alpine"
+	redisClusterImage = "grokzen/redis-cluster:7.0.10"
+	// redisClusterPort 集群镜像的起始端口，三主三从占用 7000～7005，需映射到本机相同端口，
+	// 因为集群向客户端通告的是节点端口
+	redisClusterPort = 7000
+)
+
+// dependencies 全部测试共用的服务，每个测试开始前自行清理用到的数据
+type dependencies struct {
+	db           *database.DB
+	redis        *redis.Client
+	clusterNodes []string
+	containers   []*container
+}
+
+// deps 由 TestMain 初始化
+var deps *dependencies
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+// run 准备依赖、执行测试并清理容器
+func run(m *testing.M) int {
+	d := &dependencies{}
+	defer d.close()
+
+	if err := d.setup(); err != nil {
+		if errors.Is(err, errNoDocker) {
+			log.Printf("Skipping integration tests: %v", err)
+			return 0
+		}
+		log.Printf("Failed to set up integration dependencies: %v", err)
+		return 1
+	}
+	deps = d
+	return m.Run()
+}
+
+// setup 启动或连接 PostgreSQL、Redis 与 Redis 集群，执行迁移与种子数据
+func (d *dependencies) setup() error {
+	dsn := os.Getenv("INTEGRATION_POSTGRES_DSN")
+	redisAddr := os.Getenv("INTEGRATION_REDIS_ADDR")
+	clusterNodes := os.Getenv("INTEGRATION_REDIS_CLUSTER")
+	if (dsn == "" || redisAddr == "" || clusterNodes == "") && !dockerAvailable() {
+		return errNoDocker
+	}
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	if dsn == "" {
+		c, err := d.start("go-progres-it-postgres-"+suffix, postgresImage, []string{
+			"-e", "POSTGRES_USER=test", "-e", "POSTGRES_PASSWORD=test", "-e", "POSTGRES_DB=go_progres",
+			"-p", "127.0.0.1::5432",
+		})
+		if err != nil {
+			return err
+		}
+		addr, err := c.hostAddr("5432/tcp")
+		if err != nil {
+			return err
+		}
+		dsn = fmt.Sprintf("postgres://test:test@%s/go_progres?sslmode=disable", addr)
+	}
+	if err := d.connectPostgres(dsn); err != nil {
+		return err
+	}
+
+	if redisAddr == "" {
+		c, err := d.start("go-progres-it-redis-"+suffix, redisImage, []string{"-p", "127.0.0.1::6379"})
+		if err != nil {
+			return err
+		}
+		if redisAddr, err = c.hostAddr("6379/tcp"); err != nil {
+			return err
+		}
+	}
+	d.redis = redis.NewClient(&redis.Options{Addr: redisAddr})
+	if err := waitFor(30*time.Second, func() error { return d.redis.Ping(context.Background()).Err() }); err != nil {
+		return fmt.Errorf("redis is not ready: %w", err)
+	}
+
+	if clusterNodes == "" {
+		ports := fmt.Sprintf("%d-%d:%d-%d", redisClusterPort, redisClusterPort+5, redisClusterPort, redisClusterPort+5)
+		if _, err := d.start("go-progres-it-redis-cluster-"+suffix, redisClusterImage, []string{
+			"-e", "IP=0.0.0.0", "-e", fmt.Sprintf("INITIAL_PORT=%d", redisClusterPort), "-p", ports,
+		}); err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			d.clusterNodes = append(d.clusterNodes, fmt.Sprintf("127.0.0.1:%d", redisClusterPort+i))
+		}
+	} else {
+		d.clusterNodes = strings.Split(clusterNodes, ",")
+	}
+	return d.waitForCluster()
+}
+
+// start 启动容器并登记，close 时删除
+func (d *dependencies) start(name, image string, args []string) (*container, error) {
+	c, err := startContainer(name, image, args)
+	if err != nil {
+		return nil, err
+	}
+	d.containers = append(d.containers, c)
+	return c, nil
+}
+
+// connectPostgres 等待数据库就绪，执行全部迁移与种子数据
+func (d *dependencies) connectPostgres(dsn string) error {
+	err := waitFor(60*time.Second, func() error {
+		db, err := sqlx.Connect("pgx", dsn)
+		if err != nil {
+			return err
+		}
+		d.db = &database.DB{DB: db}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("postgres is not ready: %w", err)
+	}
+
+	m, err := migrate.New("file://"+filepath.Join(repoRoot(), "migrations"), dsn)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	seed, err := os.ReadFile(filepath.Join(repoRoot(), "tests", "integration", "testdata", "seed.sql"))
+	if err != nil {
+		return fmt.Errorf("failed to read seed data: %w", err)
+	}
+	if _, err := d.db.ExecContext(context.Background(), string(seed)); err != nil {
+		return fmt.Errorf("failed to seed database: %w", err)
+	}
+	return nil
+}
+
+// waitForCluster 等待集群完成槽位分配
+func (d *dependencies) waitForCluster() error {
+	client := redisv8.NewClusterClient(&redisv8.ClusterOptions{Addrs: d.clusterNodes})
+	defer client.Close()
+	err := waitFor(90*time.Second, func() error {
+		info, err := client.ClusterInfo(context.Background()).Result()
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(info, "cluster_state:ok") {
+			return errors.New("cluster state is not ok")
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis cluster is not ready: %w", err)
+	}
+	return nil
+}
+
+// close 关闭连接并删除启动的容器
+func (d *dependencies) close() {
+	if d.db != nil {
+		d.db.Close()
+	}
+	if d.redis != nil {
+		d.redis.Close()
+	}
+	for _, c := range d.containers {
+		c.remove()
+	}
+}
+
+// repoRoot 仓库根目录，迁移与种子数据按此定位，与执行 go test 的目录无关
+func repoRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
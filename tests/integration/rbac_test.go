@@ -0,0 +1,67 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"user_crud_jwt/pkg/security"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBAC_PermissionDefinitionsPersisted(t *testing.T) {
+	ctx := context.Background()
+	store := security.NewSQLPermissionStore(deps.db)
+
+	// 种子数据中 ledger-service 声明的权限在同步后可被本实例识别
+	require.NoError(t, security.SyncPermissions(ctx, store))
+	def, ok := security.LookupPermission("ledger.entry:write")
+	require.True(t, ok)
+	assert.Equal(t, "ledger-service", def.Module)
+
+	defs, err := store.List(ctx)
+	require.NoError(t, err)
+	names := make(map[security.Permission]string, len(defs))
+	for _, d := range defs {
+		names[d.Name] = d.Module
+	}
+	for _, local := range security.ListPermissions("") {
+		assert.Contains(t, names, local.Name, "local permissions are written to the table")
+	}
+
+	// 另一个服务之后注册的权限在下次同步时载入，重复保存覆盖描述
+	require.NoError(t, store.Save(ctx, []security.PermissionDefinition{
+		{Name: "report.export:read", Module: "report-service", Description: "导出报表"},
+	}))
+	require.NoError(t, store.Save(ctx, []security.PermissionDefinition{
+		{Name: "report.export:read", Module: "report-service", Description: "导出经营报表"},
+	}))
+	require.NoError(t, security.SyncPermissions(ctx, store))
+	def, ok = security.LookupPermission("report.export:read")
+	require.True(t, ok)
+	assert.Equal(t, "导出经营报表", def.Description)
+}
+
+func TestRBAC_GrantInvalidatesRedisCache(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, security.SyncPermissions(ctx, security.NewSQLPermissionStore(deps.db)))
+	rbac := security.NewRBAC(newRedisCache(t))
+
+	require.NoError(t, rbac.AssignRole(ctx, "it-user-1", security.RoleUser))
+	allowed, err := rbac.HasPermission(ctx, "it-user-1", "ledger.entry:read")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// 否定结果已缓存在 Redis 中，授权后随版本号更新失效
+	require.NoError(t, rbac.GrantPermission(ctx, "it-user-1", "ledger.entry:read"))
+	allowed, err = rbac.HasPermission(ctx, "it-user-1", "ledger.entry:read")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	require.NoError(t, rbac.AssignRole(ctx, "it-user-1", security.RoleAdmin))
+	allowed, err = rbac.HasPermission(ctx, "it-user-1", security.PermissionAdminWrite)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
@@ -0,0 +1,10 @@
+-- 集成测试种子数据：两个用户与另一个服务声明的权限
+INSERT INTO users (username, password, email, role) VALUES
+    ('it_admin', 'not-a-real-hash', 'admin@example.com', 1),
+    ('it_alice', 'not-a-real-hash', 'alice@example.com', 0)
+ON CONFLICT (username) DO NOTHING;
+
+INSERT INTO permissions (name, module, description) VALUES
+    ('ledger.entry:read', 'ledger-service', '查看账本分录'),
+    ('ledger.entry:write', 'ledger-service', '记录账本分录')
+ON CONFLICT (name) DO NOTHING;